@@ -2,6 +2,8 @@ package main
 
 import (
 	"embed"
+	"errors"
+	"os"
 	"strings"
 
 	"github.com/seuros/kaunta/internal/cli"
@@ -47,6 +49,10 @@ func run() error {
 
 func main() {
 	if err := run(); err != nil {
+		var exitErr *cli.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
 		logging.Fatal("kaunta execution failed", zap.Error(err))
 	}
 }
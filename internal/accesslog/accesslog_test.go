@@ -0,0 +1,127 @@
+package accesslog
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const firefox = "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
+
+func TestParseCombined(t *testing.T) {
+	line := `203.0.113.7 - - [15/Jun/2025:09:30:00 +0200] "GET /pricing?utm_source=news HTTP/1.1" 200 5120 "https://www.google.com/search?q=kaunta" "` + firefox + `"`
+	e, err := ParseCombined(line)
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.7", e.IP)
+	assert.Equal(t, "GET", e.Method)
+	assert.Equal(t, "/pricing?utm_source=news", e.URI)
+	assert.Equal(t, 200, e.Status)
+	assert.Equal(t, "https://www.google.com/search?q=kaunta", e.Referrer)
+	assert.Equal(t, firefox, e.UserAgent)
+	assert.True(t, e.Time.Equal(time.Date(2025, 6, 15, 7, 30, 0, 0, time.UTC)))
+
+	e, err = ParseCombined(`::1 - bob [15/Jun/2025:09:30:00 +0000] "GET / HTTP/2.0" 304 0 "-" "say \"hi\" \x22there\x22"`)
+	require.NoError(t, err)
+	assert.Empty(t, e.Referrer)
+	assert.Equal(t, `say "hi" "there"`, e.UserAgent)
+
+	_, err = ParseCombined("not a log line")
+	assert.ErrorIs(t, err, ErrFormat)
+}
+
+func TestParseCaddyJSON(t *testing.T) {
+	line := `{"level":"info","ts":1749979800.5,"logger":"http.log.access","msg":"handled request",` +
+		`"request":{"remote_ip":"10.0.0.2","client_ip":"203.0.113.7","method":"GET","host":"example.com","uri":"/blog/",` +
+		`"headers":{"User-Agent":["` + firefox + `"],"Referer":["https://news.ycombinator.com/"]}},"status":200}`
+	e, err := ParseCaddyJSON(line)
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.7", e.IP, "client_ip wins over the proxy's remote_ip")
+	assert.Equal(t, "example.com", e.Host)
+	assert.Equal(t, "/blog/", e.URI)
+	assert.Equal(t, "https://news.ycombinator.com/", e.Referrer)
+	assert.Equal(t, int64(1749979800), e.Time.Unix())
+
+	_, err = ParseCaddyJSON(`{"msg":"started"}`)
+	assert.ErrorIs(t, err, ErrFormat)
+}
+
+func TestIsPage(t *testing.T) {
+	page := Entry{IP: "203.0.113.7", Method: "GET", URI: "/docs/index.html", Status: 200}
+	assert.True(t, page.IsPage())
+
+	for name, e := range map[string]Entry{
+		"asset":    {IP: "203.0.113.7", Method: "GET", URI: "/app.js?v=3", Status: 200},
+		"post":     {IP: "203.0.113.7", Method: "POST", URI: "/login", Status: 200},
+		"redirect": {IP: "203.0.113.7", Method: "GET", URI: "/old", Status: 301},
+		"missing":  {IP: "203.0.113.7", Method: "GET", URI: "/nope", Status: 404},
+		"bad ip":   {IP: "unix:", Method: "GET", URI: "/", Status: 200},
+	} {
+		assert.False(t, e.IsPage(), name)
+	}
+}
+
+func TestImportSessionsAndVisits(t *testing.T) {
+	websiteID := uuid.New()
+	im := &Importer{WebsiteID: websiteID, Hostname: "example.com", Format: FormatCombined, DryRun: true}
+
+	log := strings.Join([]string{
+		`203.0.113.7 - - [15/Jun/2025:09:00:00 +0000] "GET / HTTP/1.1" 200 100 "-" "` + firefox + `"`,
+		`203.0.113.7 - - [15/Jun/2025:09:00:01 +0000] "GET /style.css HTTP/1.1" 200 100 "-" "` + firefox + `"`,
+		`203.0.113.7 - - [15/Jun/2025:09:10:00 +0000] "GET /pricing HTTP/1.1" 200 100 "-" "` + firefox + `"`,
+		`203.0.113.7 - - [15/Jun/2025:11:00:00 +0000] "GET / HTTP/1.1" 200 100 "-" "` + firefox + `"`,
+		`198.51.100.4 - - [15/Jun/2025:09:05:00 +0000] "GET / HTTP/1.1" 200 100 "-" "Googlebot/2.1"`,
+		`garbage`,
+	}, "\n")
+	require.NoError(t, im.Import(context.Background(), strings.NewReader(log)))
+	result := im.Finish()
+
+	assert.Equal(t, int64(6), result.Lines)
+	assert.Equal(t, int64(1), result.Invalid)
+	assert.Equal(t, int64(2), result.Skipped)
+	assert.Equal(t, int64(3), result.Pageviews)
+	assert.Equal(t, int64(1), result.Sessions)
+	assert.Equal(t, int64(2), result.Visits, "two hours apart is a new visit")
+	assert.Equal(t, []time.Time{time.Date(2025, 6, 15, 0, 0, 0, 0, time.UTC)}, result.Days)
+}
+
+func TestImportWrites(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	websiteID := uuid.New()
+	browser := "Firefox"
+	im := &Importer{
+		DB: db, WebsiteID: websiteID, Hostname: "example.com", Format: FormatCaddyJSON,
+		UserAgent: func(string) (*string, *string, *string) { return &browser, nil, nil },
+		Locate:    func(string) (string, string, string) { return "DE", "", "Berlin" },
+	}
+
+	line := `{"ts":1749979800,"request":{"remote_ip":"203.0.113.7","method":"GET","host":"www.example.com","uri":"/?utm_source=news",` +
+		`"headers":{"User-Agent":["` + firefox + `"]}},"status":200}` + "\n" +
+		`{"ts":1749979900,"request":{"remote_ip":"203.0.113.7","method":"GET","host":"other.org","uri":"/",` +
+		`"headers":{"User-Agent":["` + firefox + `"]}},"status":200}`
+
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "website_event_2025_06_15"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO session \(session_id, website_id, browser, os, device, country, region, city, created_at\) VALUES \(\$1.*ON CONFLICT DO NOTHING`).
+		WithArgs(sqlmock.AnyArg(), websiteID, "Firefox", nil, nil, "DE", nil, "Berlin", time.Unix(1749979800, 0).UTC()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO website_event .* ON CONFLICT DO NOTHING`).
+		WithArgs(sqlmock.AnyArg(), websiteID, sqlmock.AnyArg(), sqlmock.AnyArg(), time.Unix(1749979800, 0).UTC(), 1,
+			"www.example.com", "/", "utm_source=news", nil, nil, nil, "news", nil, nil, nil, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, im.Import(context.Background(), strings.NewReader(line)))
+	result := im.Finish()
+	assert.Equal(t, int64(1), result.Pageviews)
+	assert.Equal(t, int64(1), result.Skipped, "requests for other hosts are skipped")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
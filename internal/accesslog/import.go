@@ -0,0 +1,320 @@
+package accesslog
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// VisitTimeout is how long a visitor goes without a hit before their next
+// one starts a new visit
+const VisitTimeout = 30 * time.Minute
+
+// batchSize is how many pageviews are written per INSERT
+const batchSize = 500
+
+// maxLineSize bounds a log line (long URLs and user agents)
+const maxLineSize = 1 << 20
+
+// Result counts what an import read and wrote
+type Result struct {
+	Lines     int64 // lines read
+	Invalid   int64 // lines not in the format
+	Skipped   int64 // requests that aren't pageviews: assets, errors, bots, other hosts
+	Pageviews int64 // pageviews read (written unless DryRun, or already imported)
+	Sessions  int64
+	Visits    int64
+	// Days are the UTC days with pageviews, sorted
+	Days []time.Time
+}
+
+// Importer backfills a website's pageviews from access logs. One importer
+// reads all the logs of an import, oldest first, so visits continue across
+// rotated files.
+type Importer struct {
+	DB        *sql.DB
+	WebsiteID uuid.UUID
+	// Hostname is the website's domain: pageviews of logs recording hosts
+	// (Caddy) are kept only for it and its subdomains, the others get it
+	Hostname string
+	Format   string
+	// UserAgent parses the browser, OS and device of a user agent
+	UserAgent func(ua string) (browser, os, device *string)
+	// Locate returns the country, region and city of an IP ("" unknown)
+	Locate func(ip string) (country, region, city string)
+	// DryRun reads the logs without writing anything
+	DryRun bool
+
+	Result Result
+
+	visitors   map[string]*visitor
+	days       map[time.Time]bool
+	partitions map[time.Time]bool
+	sessions   []*session
+	events     []*store.Event
+	seen       map[uuid.UUID]bool
+}
+
+// session is a session row; unlike the tracker's, it starts at its first hit
+type session struct {
+	id                    uuid.UUID
+	browser, os, device   *string
+	country, region, city *string
+	createdAt             time.Time
+}
+
+// visitor is the state of one IP and user agent
+type visitor struct {
+	session uuid.UUID
+	visit   uuid.UUID
+	last    time.Time
+}
+
+// Import reads a log and writes its pageviews
+func (im *Importer) Import(ctx context.Context, r io.Reader) error {
+	if im.visitors == nil {
+		im.visitors = map[string]*visitor{}
+		im.days = map[time.Time]bool{}
+		im.partitions = map[time.Time]bool{}
+		im.seen = map[uuid.UUID]bool{}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		im.Result.Lines++
+		e, err := Parse(im.Format, line)
+		if errors.Is(err, ErrFormat) {
+			im.Result.Invalid++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !im.keep(e) {
+			im.Result.Skipped++
+			continue
+		}
+		im.add(e)
+		if len(im.events) >= batchSize {
+			if err := im.flush(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read log: %w", err)
+	}
+	return im.flush(ctx)
+}
+
+// keep reports whether e is a pageview of the website
+func (im *Importer) keep(e Entry) bool {
+	if !e.IsPage() || store.IsBotUserAgent(e.UserAgent) || e.UserAgent == "" {
+		return false
+	}
+	if e.Host == "" || im.Hostname == "" {
+		return true
+	}
+	host := strings.ToLower(strings.Split(e.Host, ":")[0])
+	return host == im.Hostname || strings.HasSuffix(host, "."+im.Hostname)
+}
+
+// add turns e into a pageview, with its session and visit
+func (im *Importer) add(e Entry) {
+	at := e.Time.UTC()
+	key := e.IP + "\x00" + e.UserAgent
+	month := at.Format("2006-01")
+	sessionID := uuid.NewSHA1(im.WebsiteID, []byte(key+"\x00"+month))
+
+	v := im.visitors[key]
+	if v == nil || v.session != sessionID {
+		v = &visitor{session: sessionID}
+		im.visitors[key] = v
+		if !im.seen[sessionID] {
+			im.seen[sessionID] = true
+			im.Result.Sessions++
+			im.sessions = append(im.sessions, im.newSession(sessionID, e))
+		}
+	}
+	if v.visit == uuid.Nil || at.Sub(v.last) > VisitTimeout {
+		v.visit = uuid.NewSHA1(sessionID, []byte(at.Format(time.RFC3339Nano)))
+		im.Result.Visits++
+	}
+	if at.After(v.last) {
+		v.last = at
+	}
+
+	day := at.Truncate(24 * time.Hour)
+	im.days[day] = true
+	im.Result.Pageviews++
+	im.events = append(im.events, im.event(sessionID, v.visit, at, e))
+}
+
+// newSession is the session of a visitor's first hit
+func (im *Importer) newSession(id uuid.UUID, e Entry) *session {
+	s := &session{id: id, createdAt: e.Time.UTC()}
+	if im.UserAgent != nil {
+		s.browser, s.os, s.device = im.UserAgent(e.UserAgent)
+	}
+	if im.Locate != nil {
+		country, region, city := im.Locate(e.IP)
+		s.country, s.region, s.city = optional(country), optional(region), optional(city)
+	}
+	return s
+}
+
+// event is the pageview of e
+func (im *Importer) event(sessionID, visitID uuid.UUID, at time.Time, e Entry) *store.Event {
+	ev := &store.Event{
+		EventID:   uuid.NewSHA1(sessionID, []byte(at.Format(time.RFC3339Nano)+"\x00"+e.URI)),
+		WebsiteID: im.WebsiteID,
+		SessionID: sessionID,
+		VisitID:   visitID,
+		CreatedAt: at,
+		EventType: 1,
+		Hostname:  optional(im.Hostname),
+	}
+	if e.Host != "" {
+		ev.Hostname = optional(strings.ToLower(strings.Split(e.Host, ":")[0]))
+	}
+	if u, err := url.ParseRequestURI(e.URI); err == nil {
+		ev.URLPath = optional(u.Path)
+		ev.URLQuery = optional(u.RawQuery)
+		q := u.Query()
+		ev.UTMSource = optional(q.Get("utm_source"))
+		ev.UTMMedium = optional(q.Get("utm_medium"))
+		ev.UTMCampaign = optional(q.Get("utm_campaign"))
+		ev.UTMContent = optional(q.Get("utm_content"))
+		ev.UTMTerm = optional(q.Get("utm_term"))
+	}
+	if u, err := url.Parse(e.Referrer); err == nil && u.Hostname() != "" {
+		ev.ReferrerDomain = optional(strings.TrimPrefix(u.Hostname(), "www."))
+		ev.ReferrerPath = optional(u.Path)
+		ev.ReferrerQuery = optional(u.RawQuery)
+	}
+	return ev
+}
+
+// flush writes the buffered sessions and pageviews
+func (im *Importer) flush(ctx context.Context) error {
+	sessions, events := im.sessions, im.events
+	im.sessions, im.events = nil, nil
+	if im.DryRun || len(events) == 0 && len(sessions) == 0 {
+		return nil
+	}
+
+	for _, e := range events {
+		day := e.CreatedAt.Truncate(24 * time.Hour)
+		if im.partitions[day] {
+			continue
+		}
+		if err := createPartition(ctx, im.DB, day); err != nil {
+			return err
+		}
+		im.partitions[day] = true
+	}
+
+	tx, err := im.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if len(sessions) > 0 {
+		args := make([]interface{}, 0, len(sessions)*9)
+		for _, s := range sessions {
+			args = append(args, s.id, im.WebsiteID, s.browser, s.os, s.device, s.country, s.region, s.city, s.createdAt)
+		}
+		if _, err := tx.ExecContext(ctx, insertStatement("session", sessionColumns, len(sessions)), args...); err != nil {
+			return fmt.Errorf("failed to write sessions: %w", err)
+		}
+	}
+	args := make([]interface{}, 0, len(events)*len(eventColumns))
+	for _, e := range events {
+		args = append(args, e.EventID, e.WebsiteID, e.SessionID, e.VisitID, e.CreatedAt, e.EventType,
+			e.Hostname, e.URLPath, e.URLQuery, e.ReferrerDomain, e.ReferrerPath, e.ReferrerQuery,
+			e.UTMSource, e.UTMMedium, e.UTMCampaign, e.UTMContent, e.UTMTerm)
+	}
+	if _, err := tx.ExecContext(ctx, insertStatement("website_event", eventColumns, len(events)), args...); err != nil {
+		return fmt.Errorf("failed to write pageviews: %w", err)
+	}
+	return tx.Commit()
+}
+
+// Finish completes Result once every log was imported
+func (im *Importer) Finish() *Result {
+	im.Result.Days = im.Result.Days[:0]
+	for day := range im.days {
+		im.Result.Days = append(im.Result.Days, day)
+	}
+	sort.Slice(im.Result.Days, func(i, j int) bool { return im.Result.Days[i].Before(im.Result.Days[j]) })
+	return &im.Result
+}
+
+var (
+	sessionColumns = []string{"session_id", "website_id", "browser", "os", "device", "country", "region", "city", "created_at"}
+	eventColumns   = []string{"event_id", "website_id", "session_id", "visit_id", "created_at", "event_type",
+		"hostname", "url_path", "url_query", "referrer_domain", "referrer_path", "referrer_query",
+		"utm_source", "utm_medium", "utm_campaign", "utm_content", "utm_term"}
+)
+
+// insertStatement is a multi-row INSERT skipping rows already present
+func insertStatement(table string, columns []string, rows int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "INSERT INTO %s (%s) VALUES ", table, strings.Join(columns, ", "))
+	n := 1
+	for r := 0; r < rows; r++ {
+		if r > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("(")
+		for c := range columns {
+			if c > 0 {
+				sb.WriteString(", ")
+			}
+			fmt.Fprintf(&sb, "$%d", n)
+			n++
+		}
+		sb.WriteString(")")
+	}
+	sb.WriteString(" ON CONFLICT DO NOTHING")
+	return sb.String()
+}
+
+// createPartition creates the website_event partition of a past day
+func createPartition(ctx context.Context, db *sql.DB, day time.Time) error {
+	partition := "website_event_" + day.Format("2006_01_02")
+	_, err := db.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		PARTITION OF website_event
+		FOR VALUES FROM ('%s') TO ('%s')
+	`, pq.QuoteIdentifier(partition), day.Format("2006-01-02"), day.AddDate(0, 0, 1).Format("2006-01-02")))
+	if err != nil {
+		return fmt.Errorf("failed to create partition %s: %w", partition, err)
+	}
+	return nil
+}
+
+// optional is s, nil when empty
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,175 @@
+// Package accesslog backfills websites from web server access logs, for
+// sites that never ran the tracker or for the time before they did.
+//
+// Lines are read in the combined log format (Apache, nginx) or as Caddy's
+// JSON access log. Page requests become pageviews: successful GETs of paths
+// that aren't static assets, from user agents that aren't known bots. Hits
+// are grouped into sessions heuristically, by IP and user agent per month as
+// the tracker does, and into visits ending after VisitTimeout without a hit.
+//
+// IDs are derived from the hits themselves, so importing a log again adds
+// nothing new.
+package accesslog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats
+const (
+	FormatCombined  = "combined"
+	FormatCaddyJSON = "caddy-json"
+)
+
+// Formats are the supported log formats
+var Formats = []string{FormatCombined, FormatCaddyJSON}
+
+// ErrFormat is returned for lines that aren't in the expected format
+var ErrFormat = errors.New("malformed log line")
+
+// Entry is one request of a log
+type Entry struct {
+	Time      time.Time
+	IP        string
+	Method    string
+	Host      string // "" when the format doesn't record it
+	URI       string // path and query
+	Status    int
+	Referrer  string
+	UserAgent string
+}
+
+// combinedPattern matches `%h %l %u [%t] "%r" %>s %b "%{Referer}i" "%{User-Agent}i"`;
+// quotes inside fields are escaped (\" by Apache, \x22 by nginx)
+var combinedPattern = regexp.MustCompile(
+	`^(\S+) \S+ \S+ \[([^\]]+)\] "(\S+) (\S+)[^"]*" (\d{3}) \S+ "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)"`)
+
+// combinedTime is the layout of %t
+const combinedTime = "02/Jan/2006:15:04:05 -0700"
+
+// ValidFormat checks format is a supported log format
+func ValidFormat(format string) error {
+	if !slices.Contains(Formats, format) {
+		return fmt.Errorf("unknown format: %s (use %s)", format, strings.Join(Formats, " or "))
+	}
+	return nil
+}
+
+// Parse reads a line in format
+func Parse(format, line string) (Entry, error) {
+	switch format {
+	case FormatCombined:
+		return ParseCombined(line)
+	case FormatCaddyJSON:
+		return ParseCaddyJSON(line)
+	}
+	return Entry{}, ValidFormat(format)
+}
+
+// ParseCombined reads a line of the combined log format
+func ParseCombined(line string) (Entry, error) {
+	m := combinedPattern.FindStringSubmatch(line)
+	if m == nil {
+		return Entry{}, ErrFormat
+	}
+	at, err := time.Parse(combinedTime, m[2])
+	if err != nil {
+		return Entry{}, fmt.Errorf("%w: bad time %q", ErrFormat, m[2])
+	}
+	status, _ := strconv.Atoi(m[5])
+	return Entry{
+		Time:      at,
+		IP:        m[1],
+		Method:    m[3],
+		URI:       m[4],
+		Status:    status,
+		Referrer:  unescape(m[6]),
+		UserAgent: unescape(m[7]),
+	}, nil
+}
+
+// unescape undoes the quoting of combined log fields; "-" is an empty field
+func unescape(s string) string {
+	if s == "-" {
+		return ""
+	}
+	s = strings.ReplaceAll(s, `\x22`, `"`)
+	return strings.ReplaceAll(s, `\"`, `"`)
+}
+
+// caddyLine is the part of a Caddy access log entry that is read
+type caddyLine struct {
+	TS      float64 `json:"ts"`
+	Status  int     `json:"status"`
+	Request struct {
+		RemoteIP string              `json:"remote_ip"`
+		ClientIP string              `json:"client_ip"`
+		Method   string              `json:"method"`
+		Host     string              `json:"host"`
+		URI      string              `json:"uri"`
+		Headers  map[string][]string `json:"headers"`
+	} `json:"request"`
+}
+
+// ParseCaddyJSON reads a line of Caddy's JSON access log
+func ParseCaddyJSON(line string) (Entry, error) {
+	var l caddyLine
+	if err := json.Unmarshal([]byte(line), &l); err != nil || l.TS == 0 || l.Request.URI == "" {
+		return Entry{}, ErrFormat
+	}
+	ip := l.Request.ClientIP
+	if ip == "" {
+		ip = l.Request.RemoteIP
+	}
+	sec := int64(l.TS)
+	return Entry{
+		Time:      time.Unix(sec, int64((l.TS-float64(sec))*1e9)),
+		IP:        ip,
+		Method:    l.Request.Method,
+		Host:      l.Request.Host,
+		URI:       l.Request.URI,
+		Status:    l.Status,
+		Referrer:  header(l.Request.Headers, "Referer"),
+		UserAgent: header(l.Request.Headers, "User-Agent"),
+	}, nil
+}
+
+// header is the first value of a header, whatever the case of its name
+func header(headers map[string][]string, name string) string {
+	for k, values := range headers {
+		if strings.EqualFold(k, name) && len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// pageExtensions are the file extensions of pages; other extensions are
+// assets (scripts, styles, images, fonts, feeds, ...)
+var pageExtensions = map[string]bool{"": true, ".html": true, ".htm": true, ".php": true, ".asp": true, ".aspx": true}
+
+// IsPage reports whether e requested a page successfully: a GET answered
+// with 2xx or 304, of a path without an asset's extension
+func (e Entry) IsPage() bool {
+	if e.Method != "GET" || !(e.Status >= 200 && e.Status < 300 || e.Status == 304) {
+		return false
+	}
+	if net.ParseIP(e.IP) == nil {
+		return false
+	}
+	u, err := url.ParseRequestURI(e.URI)
+	if err != nil {
+		return false
+	}
+	return pageExtensions[strings.ToLower(path.Ext(u.Path))]
+}
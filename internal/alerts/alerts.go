@@ -0,0 +1,287 @@
+// Package alerts watches websites' traffic for anomalies. A rule compares a
+// metric (pageviews, visitors or the conversions of a goal) over its window
+// with a threshold, or with the window before it, and notifies a webhook,
+// Slack, Discord or email when it starts firing and again when it resolves.
+//
+// Rules live in PostgreSQL (alert_rule) and are evaluated by the "alerts"
+// worker task. Notifications go through the job queue, so a failing
+// endpoint is retried instead of dropping the alert.
+package alerts
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/notify"
+)
+
+// Conditions a rule fires on: drop and rise compare the window with the one
+// before (threshold in percent), below and above compare it with the
+// threshold
+var Conditions = []string{"drop", "rise", "below", "above"}
+
+// Channels are where notifications are sent
+var Channels = []string{"webhook", "slack", "discord", "email"}
+
+// goalPrefix marks goal metrics: goal:<event name> counts custom events
+const goalPrefix = "goal:"
+
+// Window limits
+const (
+	MinWindow = 5 * time.Minute
+	MaxWindow = 7 * 24 * time.Hour
+)
+
+// ErrNotFound is returned for a name no rule of the website is saved under
+var ErrNotFound = errors.New("alert rule not found")
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+
+// Rule is an alert rule of a website
+type Rule struct {
+	ID        uuid.UUID `json:"rule_id"`
+	WebsiteID uuid.UUID `json:"website_id"`
+	Domain    string    `json:"domain"`
+	Name      string    `json:"name"`
+	// Metric is pageviews, visitors or goal:<event name>
+	Metric    string        `json:"metric"`
+	Condition string        `json:"condition"`
+	Threshold float64       `json:"threshold"`
+	Window    time.Duration `json:"-"`
+	Channel   string        `json:"channel"`
+	// Target is the URL of webhook and slack channels, the address of email
+	Target  string `json:"target"`
+	Enabled bool   `json:"enabled"`
+	Firing  bool   `json:"firing"`
+
+	LastValue     *float64   `json:"last_value,omitempty"`
+	LastCheckedAt *time.Time `json:"last_checked_at,omitempty"`
+	LastFiredAt   *time.Time `json:"last_fired_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// MarshalJSON writes the window as its label (1h, 30m, 1d)
+func (r Rule) MarshalJSON() ([]byte, error) {
+	type plain Rule
+	return json.Marshal(struct {
+		plain
+		Window string `json:"window"`
+	}{plain(r), r.WindowLabel()})
+}
+
+// UnmarshalJSON reads the window from its label
+func (r *Rule) UnmarshalJSON(data []byte) error {
+	type plain Rule
+	aux := struct {
+		*plain
+		Window string `json:"window"`
+	}{plain: (*plain)(r)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if aux.Window != "" {
+		window, err := ParseWindow(aux.Window)
+		if err != nil {
+			return err
+		}
+		r.Window = window
+	}
+	return nil
+}
+
+// WindowLabel formats the rule's window as minutes, hours or days
+func (r *Rule) WindowLabel() string {
+	switch {
+	case r.Window%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", r.Window/(24*time.Hour))
+	case r.Window%time.Hour == 0:
+		return fmt.Sprintf("%dh", r.Window/time.Hour)
+	default:
+		return fmt.Sprintf("%dm", r.Window/time.Minute)
+	}
+}
+
+// Goal is the event name of goal metrics, empty for the others
+func (r *Rule) Goal() string {
+	goal, ok := strings.CutPrefix(r.Metric, goalPrefix)
+	if !ok {
+		return ""
+	}
+	return goal
+}
+
+// ParseWindow reads a window such as 30m, 1h or 1d
+func ParseWindow(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid window: %q (use e.g. 30m, 1h or 1d)", s)
+	}
+	return d, nil
+}
+
+// ParseNotify reads a channel and its target written as channel:target, e.g.
+// slack:https://hooks.slack.com/services/... or email:ops@example.com. A
+// bare slack or discord posts to the website's webhook.
+func ParseNotify(s string) (channel, target string, err error) {
+	if s == "slack" || s == "discord" {
+		return s, "", nil
+	}
+	channel, target, ok := strings.Cut(s, ":")
+	if !ok || target == "" {
+		return "", "", fmt.Errorf("invalid notification target: %q (use webhook:<url>, slack[:<url>], discord[:<url>] or email:<address>)", s)
+	}
+	return channel, target, nil
+}
+
+// Validate checks a rule before it is saved
+func (r *Rule) Validate() error {
+	if !namePattern.MatchString(r.Name) {
+		return fmt.Errorf("invalid rule name: %q (use lowercase letters, digits, _ and -, up to 50 characters)", r.Name)
+	}
+	switch {
+	case r.Metric == "pageviews", r.Metric == "visitors":
+	case r.Goal() != "" && len(r.Goal()) <= 50:
+	default:
+		return fmt.Errorf("invalid metric: %q (use pageviews, visitors or goal:<event name>)", r.Metric)
+	}
+	if !slices.Contains(Conditions, r.Condition) {
+		return fmt.Errorf("invalid condition: %q (use %s)", r.Condition, strings.Join(Conditions, ", "))
+	}
+	if r.Threshold < 0 {
+		return errors.New("threshold must not be negative")
+	}
+	switch r.Condition {
+	case "drop":
+		if r.Threshold == 0 || r.Threshold > 100 {
+			return errors.New("a drop threshold is a percentage between 0 and 100")
+		}
+	case "rise":
+		if r.Threshold == 0 {
+			return errors.New("a rise threshold is a percentage above 0")
+		}
+	}
+	if r.Window < MinWindow || r.Window > MaxWindow {
+		return fmt.Errorf("window must be between %s and 7d", MinWindow)
+	}
+	if r.Window%time.Minute != 0 {
+		return errors.New("window must be whole minutes")
+	}
+	switch r.Channel {
+	case "slack", "discord":
+		// Without a target, the website's (or kaunta.toml's) webhook
+		if r.Target != "" {
+			return notify.ValidURL(r.Channel, r.Target)
+		}
+	case "webhook":
+		u, err := url.Parse(r.Target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid %s URL: %q", r.Channel, r.Target)
+		}
+	case "email":
+		if _, err := mail.ParseAddress(r.Target); err != nil {
+			return fmt.Errorf("invalid email address: %q", r.Target)
+		}
+	default:
+		return fmt.Errorf("invalid channel: %q (use %s)", r.Channel, strings.Join(Channels, ", "))
+	}
+	return nil
+}
+
+// Save stores a rule, replacing the website's rule of the same name. A
+// replaced rule starts over as not firing.
+func Save(ctx context.Context, db *sql.DB, r *Rule) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO alert_rule (website_id, name, metric, condition, threshold, window_minutes, channel, target, enabled)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (website_id, name) DO UPDATE
+		SET metric = EXCLUDED.metric, condition = EXCLUDED.condition, threshold = EXCLUDED.threshold,
+			window_minutes = EXCLUDED.window_minutes, channel = EXCLUDED.channel, target = EXCLUDED.target,
+			enabled = EXCLUDED.enabled, firing = FALSE, last_value = NULL, last_checked_at = NULL,
+			updated_at = NOW()
+		RETURNING rule_id, created_at
+	`, r.WebsiteID, r.Name, r.Metric, r.Condition, r.Threshold, int(r.Window/time.Minute),
+		r.Channel, r.Target, r.Enabled).Scan(&r.ID, &r.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save alert rule: %w", err)
+	}
+	r.Firing, r.LastValue, r.LastCheckedAt = false, nil, nil
+	return nil
+}
+
+const selectRules = `
+	SELECT r.rule_id, r.website_id, w.domain, r.name, r.metric, r.condition, r.threshold,
+		r.window_minutes, r.channel, r.target, r.enabled, r.firing, r.last_value,
+		r.last_checked_at, r.last_fired_at, r.created_at
+	FROM alert_rule r
+	JOIN website w ON w.website_id = r.website_id
+	WHERE w.deleted_at IS NULL`
+
+func scanRule(row interface{ Scan(...interface{}) error }) (*Rule, error) {
+	var r Rule
+	var minutes int
+	if err := row.Scan(&r.ID, &r.WebsiteID, &r.Domain, &r.Name, &r.Metric, &r.Condition, &r.Threshold,
+		&minutes, &r.Channel, &r.Target, &r.Enabled, &r.Firing, &r.LastValue,
+		&r.LastCheckedAt, &r.LastFiredAt, &r.CreatedAt); err != nil {
+		return nil, err
+	}
+	r.Window = time.Duration(minutes) * time.Minute
+	return &r, nil
+}
+
+func queryRules(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]*Rule, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alert rules: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var rules []*Rule
+	for rows.Next() {
+		r, err := scanRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read alert rule: %w", err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// List returns the rules of a website, or of all websites for uuid.Nil
+func List(ctx context.Context, db *sql.DB, websiteID uuid.UUID) ([]*Rule, error) {
+	if websiteID == uuid.Nil {
+		return queryRules(ctx, db, selectRules+` ORDER BY w.domain, r.name`)
+	}
+	return queryRules(ctx, db, selectRules+` AND r.website_id = $1 ORDER BY r.name`, websiteID)
+}
+
+// Remove deletes a rule of a website
+func Remove(ctx context.Context, db *sql.DB, websiteID uuid.UUID, name string) error {
+	result, err := db.ExecContext(ctx, `DELETE FROM alert_rule WHERE website_id = $1 AND name = $2`, websiteID, name)
+	if err != nil {
+		return fmt.Errorf("failed to remove alert rule: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
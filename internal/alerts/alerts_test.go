@@ -0,0 +1,103 @@
+package alerts
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validRule() *Rule {
+	return &Rule{
+		Name:      "traffic-drop",
+		Metric:    "pageviews",
+		Condition: "drop",
+		Threshold: 50,
+		Window:    time.Hour,
+		Channel:   "slack",
+		Target:    "https://hooks.slack.com/services/T000/B000/XXXX",
+		Enabled:   true,
+	}
+}
+
+func TestValidate(t *testing.T) {
+	require.NoError(t, validRule().Validate())
+
+	for _, tc := range []struct {
+		change func(*Rule)
+		want   string
+	}{
+		{func(r *Rule) { r.Name = "Traffic Drop" }, "invalid rule name"},
+		{func(r *Rule) { r.Metric = "sessions" }, "invalid metric"},
+		{func(r *Rule) { r.Metric = "goal:" }, "invalid metric"},
+		{func(r *Rule) { r.Condition = "equals" }, "invalid condition"},
+		{func(r *Rule) { r.Threshold = 150 }, "between 0 and 100"},
+		{func(r *Rule) { r.Condition, r.Threshold = "rise", 0 }, "above 0"},
+		{func(r *Rule) { r.Condition, r.Threshold = "below", -1 }, "must not be negative"},
+		{func(r *Rule) { r.Window = time.Minute }, "window must be between"},
+		{func(r *Rule) { r.Window = 90 * time.Second * 5 }, "whole minutes"},
+		{func(r *Rule) { r.Target = "hooks.slack.com/services" }, "invalid slack webhook URL"},
+		{func(r *Rule) { r.Channel, r.Target = "webhook", "" }, "invalid webhook URL"},
+		{func(r *Rule) { r.Channel, r.Target = "email", "not an address" }, "invalid email address"},
+		{func(r *Rule) { r.Channel = "sms" }, "invalid channel"},
+	} {
+		r := validRule()
+		tc.change(r)
+		err := r.Validate()
+		require.Error(t, err, tc.want)
+		assert.Contains(t, err.Error(), tc.want)
+	}
+
+	bare := validRule()
+	bare.Channel, bare.Target = "discord", ""
+	require.NoError(t, bare.Validate())
+
+	goal := validRule()
+	goal.Metric, goal.Condition, goal.Threshold, goal.Window = "goal:signup", "below", 1, 24*time.Hour
+	goal.Channel, goal.Target = "email", "ops@example.com"
+	require.NoError(t, goal.Validate())
+	assert.Equal(t, "signup", goal.Goal())
+	assert.Equal(t, "", validRule().Goal())
+}
+
+func TestParseWindowAndLabel(t *testing.T) {
+	for in, want := range map[string]string{"30m": "30m", "1h": "1h", "90m": "90m", "24h": "1d", "2d": "2d"} {
+		window, err := ParseWindow(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, (&Rule{Window: window}).WindowLabel(), in)
+	}
+	_, err := ParseWindow("soon")
+	assert.Error(t, err)
+}
+
+func TestParseNotify(t *testing.T) {
+	channel, target, err := ParseNotify("webhook:https://example.com/hook")
+	require.NoError(t, err)
+	assert.Equal(t, "webhook", channel)
+	assert.Equal(t, "https://example.com/hook", target)
+
+	channel, target, err = ParseNotify("discord")
+	require.NoError(t, err)
+	assert.Equal(t, "discord", channel)
+	assert.Empty(t, target)
+
+	_, _, err = ParseNotify("https")
+	assert.Error(t, err)
+	_, _, err = ParseNotify("webhook")
+	assert.Error(t, err)
+}
+
+func TestRuleJSONWindow(t *testing.T) {
+	data, err := json.Marshal(validRule())
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"window":"1h"`)
+
+	var r Rule
+	require.NoError(t, json.Unmarshal(data, &r))
+	assert.Equal(t, time.Hour, r.Window)
+	assert.Equal(t, "traffic-drop", r.Name)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"window":"later"}`), &r))
+}
@@ -0,0 +1,119 @@
+package alerts
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/jobs"
+	"github.com/seuros/kaunta/internal/logging"
+)
+
+// minBaseline is the fewest events the window before must have for drop and
+// rise rules to fire: a change from a handful of events is noise
+const minBaseline = 10
+
+// Check is the outcome of evaluating a rule
+type Check struct {
+	Rule     *Rule   `json:"rule"`
+	Value    float64 `json:"value"`
+	Previous float64 `json:"previous"`
+	// Change is the change from the window before, in percent; nil when
+	// the window before had nothing
+	Change *float64 `json:"change_percent,omitempty"`
+	Firing bool     `json:"firing"`
+}
+
+// Measure reads the rule's metric over its window ending at now, and over
+// the window before
+func Measure(ctx context.Context, db *sql.DB, r *Rule, now time.Time) (value, previous float64, err error) {
+	count, filter := "COUNT(*)", "e.event_type = 1"
+	args := []interface{}{r.WebsiteID, now.Add(-2 * r.Window), now.Add(-r.Window), now}
+	switch {
+	case r.Metric == "visitors":
+		count = "COUNT(DISTINCT e.session_id)"
+	case r.Goal() != "":
+		filter = "e.event_type = 2 AND e.event_name = $5"
+		args = append(args, r.Goal())
+	}
+
+	err = db.QueryRowContext(ctx, `
+		SELECT `+count+` FILTER (WHERE e.created_at >= $3),
+			`+count+` FILTER (WHERE e.created_at < $3)
+		FROM website_event e
+		WHERE e.website_id = $1 AND e.created_at >= $2 AND e.created_at < $4 AND `+filter,
+		args...).Scan(&value, &previous)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to measure %s: %w", r.Metric, err)
+	}
+	return value, previous, nil
+}
+
+// Evaluate checks a rule against its measurements
+func Evaluate(r *Rule, value, previous float64) *Check {
+	check := &Check{Rule: r, Value: value, Previous: previous}
+	if previous > 0 {
+		change := (value - previous) / previous * 100
+		check.Change = &change
+	}
+
+	switch r.Condition {
+	case "drop":
+		check.Firing = previous >= minBaseline && -*check.Change >= r.Threshold
+	case "rise":
+		check.Firing = previous >= minBaseline && *check.Change >= r.Threshold
+	case "below":
+		check.Firing = value < r.Threshold
+	case "above":
+		check.Firing = value > r.Threshold
+	}
+	return check
+}
+
+// Run evaluates every enabled rule, recording their state and queueing a
+// notification for each rule that starts firing or resolves
+func Run(ctx context.Context, db *sql.DB, now time.Time) error {
+	rules, err := queryRules(ctx, db, selectRules+` AND r.enabled ORDER BY w.domain, r.name`)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range rules {
+		value, previous, err := Measure(ctx, db, r, now)
+		if err != nil {
+			logging.L().Warn("failed to evaluate alert rule", zap.String("rule", r.Name),
+				zap.String("website", r.Domain), zap.Error(err))
+			continue
+		}
+		check := Evaluate(r, value, previous)
+
+		if check.Firing != r.Firing {
+			if err := resolveTarget(ctx, db, r); err != nil {
+				logging.L().Warn("failed to find alert webhook", zap.String("rule", r.Name),
+					zap.String("website", r.Domain), zap.Error(err))
+			}
+			if _, err := jobs.Enqueue(ctx, db, JobKind, check, jobs.EnqueueOptions{}); err != nil {
+				// Keep the old state so the next run tries again
+				logging.L().Error("failed to queue alert notification", zap.String("rule", r.Name),
+					zap.String("website", r.Domain), zap.Error(err))
+				continue
+			}
+			logging.L().Info("alert rule changed state", zap.String("rule", r.Name),
+				zap.String("website", r.Domain), zap.Bool("firing", check.Firing), zap.Float64("value", value))
+		}
+
+		_, err = db.ExecContext(ctx, `
+			UPDATE alert_rule
+			SET firing = $2, last_value = $3, last_checked_at = $4,
+				last_fired_at = CASE WHEN $2 AND NOT firing THEN $4 ELSE last_fired_at END
+			WHERE rule_id = $1
+		`, r.ID, check.Firing, value, now)
+		if err != nil {
+			return fmt.Errorf("failed to record alert rule %s: %w", r.Name, err)
+		}
+	}
+	return nil
+}
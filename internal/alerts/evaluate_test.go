@@ -0,0 +1,144 @@
+package alerts
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/test"
+)
+
+func TestEvaluate(t *testing.T) {
+	drop := validRule()
+	assert.True(t, Evaluate(drop, 40, 100).Firing)
+	assert.False(t, Evaluate(drop, 60, 100).Firing)
+	// Too few events before to mean anything
+	assert.False(t, Evaluate(drop, 0, 5).Firing)
+	assert.False(t, Evaluate(drop, 0, 0).Firing)
+
+	rise := validRule()
+	rise.Condition, rise.Threshold = "rise", 200
+	assert.True(t, Evaluate(rise, 300, 100).Firing)
+	assert.False(t, Evaluate(rise, 250, 100).Firing)
+
+	below := validRule()
+	below.Condition, below.Threshold = "below", 1
+	assert.True(t, Evaluate(below, 0, 12).Firing)
+	assert.False(t, Evaluate(below, 1, 0).Firing)
+
+	above := validRule()
+	above.Condition, above.Threshold = "above", 1000
+	assert.True(t, Evaluate(above, 1001, 0).Firing)
+
+	check := Evaluate(drop, 40, 100)
+	require.NotNil(t, check.Change)
+	assert.Equal(t, -60.0, *check.Change)
+}
+
+func TestMeasureGoal(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	r := validRule()
+	r.WebsiteID = uuid.New()
+	r.Metric, r.Window = "goal:signup", 24*time.Hour
+
+	mock.ExpectQuery(`COUNT\(\*\) FILTER \(WHERE e.created_at >= \$3\).*e.event_type = 2 AND e.event_name = \$5`).
+		WithArgs(r.WebsiteID, now.Add(-48*time.Hour), now.Add(-24*time.Hour), now, "signup").
+		WillReturnRows(sqlmock.NewRows([]string{"value", "previous"}).AddRow(0, 14))
+
+	value, previous, err := Measure(context.Background(), db, r, now)
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, value)
+	assert.Equal(t, 14.0, previous)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func ruleRows(r *Rule) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"rule_id", "website_id", "domain", "name", "metric", "condition", "threshold",
+		"window_minutes", "channel", "target", "enabled", "firing", "last_value", "last_checked_at", "last_fired_at", "created_at"}).
+		AddRow(r.ID, r.WebsiteID, "example.com", r.Name, r.Metric, r.Condition, r.Threshold,
+			int(r.Window/time.Minute), r.Channel, r.Target, true, r.Firing, nil, nil, nil, time.Now())
+}
+
+func TestRunNotifiesOnStateChange(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	now := time.Now()
+	r := validRule()
+	r.ID, r.WebsiteID = uuid.New(), uuid.New()
+
+	mock.ExpectQuery(`FROM alert_rule r.*AND r.enabled`).WillReturnRows(ruleRows(r))
+	mock.ExpectQuery(`FROM website_event e`).
+		WillReturnRows(sqlmock.NewRows([]string{"value", "previous"}).AddRow(20, 100))
+	mock.ExpectQuery(`INSERT INTO jobs`).WithArgs(JobKind, sqlmock.AnyArg(), 5, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}).AddRow(uuid.New()))
+	mock.ExpectExec(`UPDATE alert_rule`).WithArgs(r.ID, true, 20.0, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, Run(context.Background(), db, now))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunStaysQuietWhileFiring(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	now := time.Now()
+	r := validRule()
+	r.ID, r.WebsiteID, r.Firing = uuid.New(), uuid.New(), true
+
+	mock.ExpectQuery(`FROM alert_rule r`).WillReturnRows(ruleRows(r))
+	mock.ExpectQuery(`FROM website_event e`).
+		WillReturnRows(sqlmock.NewRows([]string{"value", "previous"}).AddRow(10, 100))
+	mock.ExpectExec(`UPDATE alert_rule`).WithArgs(r.ID, true, 10.0, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, Run(context.Background(), db, now))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunResolvesWebsiteWebhook(t *testing.T) {
+	original := loadNotify
+	t.Cleanup(func() { loadNotify = original })
+	loadNotify = func() (config.NotifyConfig, error) {
+		return config.NotifyConfig{SlackWebhookURL: "https://hooks.slack.com/services/default"}, nil
+	}
+
+	db, mock := test.NewMockDB(t)
+	now := time.Now()
+	r := validRule()
+	r.ID, r.WebsiteID, r.Channel, r.Target = uuid.New(), uuid.New(), "discord", ""
+
+	mock.ExpectQuery(`FROM alert_rule r`).WillReturnRows(ruleRows(r))
+	mock.ExpectQuery(`FROM website_event e`).
+		WillReturnRows(sqlmock.NewRows([]string{"value", "previous"}).AddRow(20, 100))
+	mock.ExpectQuery(`FROM website`).WithArgs(r.WebsiteID).
+		WillReturnRows(sqlmock.NewRows([]string{"website_id", "domain", "slack", "discord", "daily_summary", "summary_sent_on"}).
+			AddRow(r.WebsiteID, "example.com", "", "https://discord.com/api/webhooks/1/x", nil, nil))
+	mock.ExpectQuery(`INSERT INTO jobs`).
+		WithArgs(JobKind, payloadContaining(`"target":"https://discord.com/api/webhooks/1/x"`), 5, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}).AddRow(uuid.New()))
+	mock.ExpectExec(`UPDATE alert_rule`).WithArgs(r.ID, true, 20.0, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, Run(context.Background(), db, now))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// payloadContaining matches a job payload containing s
+type payloadContaining string
+
+func (p payloadContaining) Match(v driver.Value) bool {
+	switch data := v.(type) {
+	case string:
+		return strings.Contains(data, string(p))
+	case []byte:
+		return strings.Contains(string(data), string(p))
+	}
+	return false
+}
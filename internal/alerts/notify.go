@@ -0,0 +1,179 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/jobs"
+	"github.com/seuros/kaunta/internal/notify"
+	"github.com/seuros/kaunta/internal/offline"
+)
+
+// JobKind is the job kind of alert notifications
+const JobKind = "alert"
+
+var (
+	httpClient = &http.Client{Timeout: 15 * time.Second}
+	sendMail   = smtp.SendMail
+	loadSMTP   = func() (config.SMTPConfig, error) {
+		cfg, err := config.Load()
+		if err != nil {
+			return config.SMTPConfig{}, err
+		}
+		return cfg.SMTP, nil
+	}
+	loadNotify = func() (config.NotifyConfig, error) {
+		cfg, err := config.Load()
+		if err != nil {
+			return config.NotifyConfig{}, err
+		}
+		return cfg.Notify, nil
+	}
+)
+
+// Message describes the check for people
+func (c *Check) Message() string {
+	r := c.Rule
+	metric := r.Metric
+	if goal := r.Goal(); goal != "" {
+		metric = goal + " conversions"
+	}
+
+	var what string
+	switch r.Condition {
+	case "drop", "rise":
+		change := 0.0
+		if c.Change != nil {
+			change = *c.Change
+		}
+		what = fmt.Sprintf("%s changed %+.0f%% in the last %s (%.0f, was %.0f); alert when it %ss %.0f%%",
+			metric, change, r.WindowLabel(), c.Value, c.Previous, r.Condition, r.Threshold)
+	default:
+		what = fmt.Sprintf("%s: %.0f in the last %s; alert when %s %.0f",
+			metric, c.Value, r.WindowLabel(), r.Condition, r.Threshold)
+	}
+
+	state := "FIRING"
+	if !c.Firing {
+		state = "RESOLVED"
+	}
+	return fmt.Sprintf("[%s] %s %s: %s", state, r.Domain, r.Name, what)
+}
+
+// Notification is the check as a Slack or Discord message
+func (c *Check) Notification() *notify.Message {
+	_, text, _ := strings.Cut(c.Message(), ": ")
+	m := &notify.Message{Text: text, Color: notify.ColorFiring}
+	state := "Firing"
+	if !c.Firing {
+		state, m.Color = "Resolved", notify.ColorResolved
+	}
+	m.Title = fmt.Sprintf("%s: %s on %s", state, c.Rule.Name, c.Rule.Domain)
+	return m
+}
+
+// Deliver sends the notification of an alert job to the rule's channel
+func Deliver(ctx context.Context, job *jobs.Job) error {
+	var check Check
+	if err := json.Unmarshal(job.Payload, &check); err != nil {
+		return fmt.Errorf("invalid alert payload: %w", err)
+	}
+	if check.Rule == nil {
+		return fmt.Errorf("alert payload has no rule")
+	}
+	if err := offline.Check("alert notification", check.Rule.Target); err != nil {
+		return err
+	}
+
+	switch check.Rule.Channel {
+	case "webhook":
+		return post(ctx, check.Rule.Target, check)
+	case "slack", "discord":
+		if check.Rule.Target == "" {
+			return fmt.Errorf("no %s webhook set for %s", check.Rule.Channel, check.Rule.Domain)
+		}
+		return notify.Post(ctx, notify.Target{Service: check.Rule.Channel, URL: check.Rule.Target}, check.Notification())
+	case "email":
+		return email(check.Rule.Target, &check)
+	}
+	return fmt.Errorf("unknown alert channel: %s", check.Rule.Channel)
+}
+
+// post sends body as JSON, expecting a 2xx answer
+func post(ctx context.Context, url string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Kaunta-Alerts")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send alert: %w", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("alert endpoint answered %s", resp.Status)
+	}
+	return nil
+}
+
+// email sends the notification through the configured SMTP server
+func email(to string, check *Check) error {
+	cfg, err := loadSMTP()
+	if err != nil {
+		return err
+	}
+	if cfg.Host == "" || cfg.From == "" {
+		return fmt.Errorf("alert emails need smtp_host and smtp_from")
+	}
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	message := check.Message()
+	subject, _, _ := strings.Cut(message, ":")
+	body := "From: " + cfg.From + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: Kaunta alert " + subject + "\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" + message + "\r\n"
+	if err := sendMail(net.JoinHostPort(cfg.Host, cfg.Port), auth, cfg.From, []string{to}, []byte(body)); err != nil {
+		return fmt.Errorf("failed to send alert email: %w", err)
+	}
+	return nil
+}
+
+// resolveTarget points a Slack or Discord rule without a target to the
+// website's webhook, else the configured one
+func resolveTarget(ctx context.Context, db *sql.DB, r *Rule) error {
+	if (r.Channel != "slack" && r.Channel != "discord") || r.Target != "" {
+		return nil
+	}
+	site, err := notify.GetSite(ctx, db, r.WebsiteID)
+	if err != nil {
+		return err
+	}
+	cfg, err := loadNotify()
+	if err != nil {
+		return err
+	}
+	if t, ok := site.Target(r.Channel, cfg); ok {
+		r.Target = t.URL
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/jobs"
+	"github.com/seuros/kaunta/internal/notify"
+	"github.com/seuros/kaunta/internal/offline"
+)
+
+func alertJob(t *testing.T, check *Check) *jobs.Job {
+	t.Helper()
+	payload, err := json.Marshal(check)
+	require.NoError(t, err)
+	return &jobs.Job{Kind: JobKind, Payload: payload}
+}
+
+func TestMessage(t *testing.T) {
+	r := validRule()
+	r.Domain = "example.com"
+	assert.Equal(t, "[FIRING] example.com traffic-drop: pageviews changed -60% in the last 1h (40, was 100); alert when it drops 50%",
+		Evaluate(r, 40, 100).Message())
+
+	r.Metric, r.Condition, r.Threshold = "goal:signup", "below", 1
+	assert.Equal(t, "[RESOLVED] example.com traffic-drop: signup conversions: 3 in the last 1h; alert when below 1",
+		Evaluate(r, 3, 0).Message())
+}
+
+func TestNotification(t *testing.T) {
+	r := validRule()
+	r.Domain = "example.com"
+	m := Evaluate(r, 40, 100).Notification()
+	assert.Equal(t, "Firing: traffic-drop on example.com", m.Title)
+	assert.Equal(t, "pageviews changed -60% in the last 1h (40, was 100); alert when it drops 50%", m.Text)
+	assert.Equal(t, notify.ColorFiring, m.Color)
+
+	m = Evaluate(r, 100, 100).Notification()
+	assert.Equal(t, "Resolved: traffic-drop on example.com", m.Title)
+	assert.Equal(t, notify.ColorResolved, m.Color)
+}
+
+func TestDeliverSlack(t *testing.T) {
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer server.Close()
+
+	r := validRule()
+	r.Domain, r.Target = "example.com", server.URL
+	require.NoError(t, Deliver(context.Background(), alertJob(t, Evaluate(r, 40, 100))))
+	assert.Equal(t, "Firing: traffic-drop on example.com", got["text"])
+
+	// A rule without a target whose website has no webhook can't be sent
+	r.Target = ""
+	err := Deliver(context.Background(), alertJob(t, Evaluate(r, 40, 100)))
+	assert.EqualError(t, err, "no slack webhook set for example.com")
+}
+
+func TestDeliverWebhookFailureRetries(t *testing.T) {
+	var got Check
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	r := validRule()
+	r.Channel, r.Target = "webhook", server.URL
+	err := Deliver(context.Background(), alertJob(t, Evaluate(r, 40, 100)))
+	assert.ErrorContains(t, err, "502")
+	assert.True(t, got.Firing)
+	assert.Equal(t, "1h", got.Rule.WindowLabel())
+}
+
+func TestDeliverEmail(t *testing.T) {
+	originalLoad, originalSend := loadSMTP, sendMail
+	t.Cleanup(func() { loadSMTP, sendMail = originalLoad, originalSend })
+	loadSMTP = func() (config.SMTPConfig, error) {
+		return config.SMTPConfig{Host: "mail.example.com", Port: "587", From: "kaunta@example.com"}, nil
+	}
+	var addr string
+	var body []byte
+	sendMail = func(a string, auth smtp.Auth, from string, to []string, msg []byte) error {
+		addr, body = a, msg
+		assert.Nil(t, auth)
+		assert.Equal(t, []string{"ops@example.com"}, to)
+		return nil
+	}
+
+	r := validRule()
+	r.Domain, r.Channel, r.Target = "example.com", "email", "ops@example.com"
+	require.NoError(t, Deliver(context.Background(), alertJob(t, Evaluate(r, 40, 100))))
+	assert.Equal(t, "mail.example.com:587", addr)
+	assert.Contains(t, string(body), "Subject: Kaunta alert [FIRING] example.com traffic-drop\r\n")
+
+	loadSMTP = func() (config.SMTPConfig, error) { return config.SMTPConfig{Port: "587"}, nil }
+	assert.ErrorContains(t, Deliver(context.Background(), alertJob(t, Evaluate(r, 40, 100))), "smtp_host")
+}
+
+func TestDeliverOffline(t *testing.T) {
+	offline.Set(true)
+	t.Cleanup(func() { offline.Set(false) })
+
+	err := Deliver(context.Background(), alertJob(t, Evaluate(validRule(), 40, 100)))
+	assert.ErrorIs(t, err, offline.ErrOffline)
+}
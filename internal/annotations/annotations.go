@@ -0,0 +1,142 @@
+// Package annotations keeps the moments users mark on a website's traffic:
+// deploys, the start of campaigns, anything that may explain a change. The
+// dashboard draws them on the pageviews chart, and deploy pipelines can post
+// them through the API.
+//
+// Notes (package notes) explain a whole day; annotations have a time.
+// Annotations live in PostgreSQL (annotation).
+package annotations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of annotations
+const (
+	Deploy   = "deploy"
+	Campaign = "campaign"
+	Other    = "other"
+)
+
+// Kinds lists the kinds of annotations
+var Kinds = []string{Deploy, Campaign, Other}
+
+// MaxLabel is the longest label, in characters
+const MaxLabel = 100
+
+// ErrNotFound is returned for an annotation the website doesn't have
+var ErrNotFound = errors.New("annotation not found")
+
+// Annotation marks a moment of a website's traffic
+type Annotation struct {
+	ID        uuid.UUID `json:"annotation_id"`
+	WebsiteID uuid.UUID `json:"website_id"`
+	At        time.Time `json:"at"`
+	Label     string    `json:"label"`
+	Kind      string    `json:"kind"`
+	Author    string    `json:"author"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ParseKind reads a kind of annotation, Other when empty
+func ParseKind(kind string) (string, error) {
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	if kind == "" {
+		return Other, nil
+	}
+	for _, k := range Kinds {
+		if kind == k {
+			return k, nil
+		}
+	}
+	return "", fmt.Errorf("invalid annotation kind: %q (use %s)", kind, strings.Join(Kinds, ", "))
+}
+
+// Validate checks the label of an annotation, returning it trimmed
+func Validate(label string) (string, error) {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return "", errors.New("an annotation needs a label")
+	}
+	if utf8.RuneCountInString(label) > MaxLabel {
+		return "", fmt.Errorf("an annotation label is at most %d characters", MaxLabel)
+	}
+	return label, nil
+}
+
+// Add marks a moment of a website
+func Add(ctx context.Context, db *sql.DB, websiteID uuid.UUID, at time.Time, label, kind, author string) (*Annotation, error) {
+	label, err := Validate(label)
+	if err != nil {
+		return nil, err
+	}
+	if kind, err = ParseKind(kind); err != nil {
+		return nil, err
+	}
+	a := &Annotation{WebsiteID: websiteID, At: at.UTC().Truncate(time.Second), Label: label, Kind: kind, Author: author}
+	err = db.QueryRowContext(ctx, `
+		INSERT INTO annotation (website_id, annotated_at, label, kind, author)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING annotation_id, created_at
+	`, websiteID, a.At, label, kind, author).Scan(&a.ID, &a.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add annotation: %w", err)
+	}
+	return a, nil
+}
+
+// List returns the annotations of a website from one time (included) to
+// another (excluded), oldest first
+func List(ctx context.Context, db *sql.DB, websiteID uuid.UUID, from, to time.Time) ([]Annotation, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT annotation_id, website_id, annotated_at, label, kind, author, created_at
+		FROM annotation
+		WHERE website_id = $1 AND annotated_at >= $2 AND annotated_at < $3
+		ORDER BY annotated_at, created_at
+	`, websiteID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list annotations: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	list := []Annotation{}
+	for rows.Next() {
+		var a Annotation
+		if err := rows.Scan(&a.ID, &a.WebsiteID, &a.At, &a.Label, &a.Kind, &a.Author, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to read annotation: %w", err)
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
+
+// LastDays returns the annotations of a website's last days days, today
+// included (UTC), like notes.LastDays
+func LastDays(ctx context.Context, db *sql.DB, websiteID uuid.UUID, days int, now time.Time) ([]Annotation, error) {
+	today := now.UTC().Truncate(24 * time.Hour)
+	return List(ctx, db, websiteID, today.AddDate(0, 0, -days), today.AddDate(0, 0, 1))
+}
+
+// Remove deletes an annotation of a website
+func Remove(ctx context.Context, db *sql.DB, websiteID, annotationID uuid.UUID) error {
+	res, err := db.ExecContext(ctx, `DELETE FROM annotation WHERE website_id = $1 AND annotation_id = $2`, websiteID, annotationID)
+	if err != nil {
+		return fmt.Errorf("failed to remove annotation: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
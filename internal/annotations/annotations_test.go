@@ -0,0 +1,85 @@
+package annotations
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/test"
+)
+
+func TestValidate(t *testing.T) {
+	label, err := Validate("  v2.3.0 \n")
+	require.NoError(t, err)
+	assert.Equal(t, "v2.3.0", label)
+
+	_, err = Validate("   ")
+	assert.EqualError(t, err, "an annotation needs a label")
+	_, err = Validate(strings.Repeat("é", MaxLabel+1))
+	assert.EqualError(t, err, "an annotation label is at most 100 characters")
+}
+
+func TestParseKind(t *testing.T) {
+	kind, err := ParseKind(" Deploy ")
+	require.NoError(t, err)
+	assert.Equal(t, Deploy, kind)
+	kind, err = ParseKind("")
+	require.NoError(t, err)
+	assert.Equal(t, Other, kind)
+
+	_, err = ParseKind("outage")
+	assert.EqualError(t, err, `invalid annotation kind: "outage" (use deploy, campaign, other)`)
+}
+
+func TestAdd(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID, annotationID := uuid.New(), uuid.New()
+	at := time.Date(2025, 6, 15, 16, 4, 5, 600, time.FixedZone("CEST", 2*3600))
+	created := time.Date(2025, 6, 15, 14, 5, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`INSERT INTO annotation`).
+		WithArgs(websiteID, time.Date(2025, 6, 15, 14, 4, 5, 0, time.UTC), "v2.3.0", Deploy, "ci").
+		WillReturnRows(sqlmock.NewRows([]string{"annotation_id", "created_at"}).AddRow(annotationID, created))
+
+	a, err := Add(context.Background(), db, websiteID, at, " v2.3.0 ", "deploy", "ci")
+	require.NoError(t, err)
+	assert.Equal(t, &Annotation{
+		ID: annotationID, WebsiteID: websiteID, At: time.Date(2025, 6, 15, 14, 4, 5, 0, time.UTC),
+		Label: "v2.3.0", Kind: Deploy, Author: "ci", CreatedAt: created,
+	}, a)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLastDays(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+	now := time.Date(2025, 6, 15, 23, 30, 0, 0, time.FixedZone("CEST", 2*3600))
+	at := time.Date(2025, 6, 10, 9, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`FROM annotation\s+WHERE website_id = \$1 AND annotated_at >= \$2 AND annotated_at < \$3`).
+		WithArgs(websiteID, time.Date(2025, 6, 8, 0, 0, 0, 0, time.UTC), time.Date(2025, 6, 16, 0, 0, 0, 0, time.UTC)).
+		WillReturnRows(sqlmock.NewRows([]string{"annotation_id", "website_id", "annotated_at", "label", "kind", "author", "created_at"}).
+			AddRow(uuid.New(), websiteID, at, "spring sale", Campaign, "", at))
+
+	list, err := LastDays(context.Background(), db, websiteID, 7, now)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "spring sale", list[0].Label)
+	assert.Equal(t, Campaign, list[0].Kind)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRemoveUnknown(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID, annotationID := uuid.New(), uuid.New()
+
+	mock.ExpectExec(`DELETE FROM annotation`).WithArgs(websiteID, annotationID).WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.ErrorIs(t, Remove(context.Background(), db, websiteID, annotationID), ErrNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,409 @@
+// Package archive moves old daily website_event partitions to cold storage
+// as Parquet files, and restores them.
+//
+// Each archived day becomes two objects:
+//
+//	events/2025/05/2025-05-09.parquet    the partition's rows
+//	sessions/2025/05/2025-05-09.parquet  the sessions those events belong to
+//
+// Sessions are kept with the events so a day can be restored after
+// retention removed them. A partition is only dropped once both uploads are
+// verified; rollups keep covering dropped days in long-range reports.
+package archive
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/seuros/kaunta/internal/blobstore"
+	"github.com/seuros/kaunta/internal/parquet"
+	"github.com/seuros/kaunta/internal/retention"
+)
+
+// restoreBatch is the number of rows per INSERT on restore
+const restoreBatch = 500
+
+const contentType = "application/vnd.apache.parquet"
+
+// eventColumns are the archived website_event columns, in file order
+var eventColumns = []parquet.Column{
+	{Name: "event_id", Type: parquet.String},
+	{Name: "website_id", Type: parquet.String},
+	{Name: "session_id", Type: parquet.String},
+	{Name: "visit_id", Type: parquet.String},
+	{Name: "created_at", Type: parquet.Timestamp},
+	{Name: "url_path", Type: parquet.String},
+	{Name: "url_query", Type: parquet.String},
+	{Name: "referrer_path", Type: parquet.String},
+	{Name: "referrer_query", Type: parquet.String},
+	{Name: "referrer_domain", Type: parquet.String},
+	{Name: "page_title", Type: parquet.String},
+	{Name: "hostname", Type: parquet.String},
+	{Name: "event_type", Type: parquet.Int32},
+	{Name: "event_name", Type: parquet.String},
+	{Name: "tag", Type: parquet.String},
+	{Name: "scroll_depth", Type: parquet.Int32},
+	{Name: "engagement_time", Type: parquet.Int32},
+	{Name: "props", Type: parquet.String},
+	{Name: "utm_source", Type: parquet.String},
+	{Name: "utm_medium", Type: parquet.String},
+	{Name: "utm_campaign", Type: parquet.String},
+	{Name: "utm_content", Type: parquet.String},
+	{Name: "utm_term", Type: parquet.String},
+}
+
+// sessionColumns are the archived session columns, in file order
+var sessionColumns = []parquet.Column{
+	{Name: "session_id", Type: parquet.String},
+	{Name: "website_id", Type: parquet.String},
+	{Name: "hostname", Type: parquet.String},
+	{Name: "browser", Type: parquet.String},
+	{Name: "os", Type: parquet.String},
+	{Name: "device", Type: parquet.String},
+	{Name: "screen", Type: parquet.String},
+	{Name: "language", Type: parquet.String},
+	{Name: "country", Type: parquet.String},
+	{Name: "subdivision1", Type: parquet.String},
+	{Name: "subdivision2", Type: parquet.String},
+	{Name: "city", Type: parquet.String},
+	{Name: "region", Type: parquet.String},
+	{Name: "created_at", Type: parquet.Timestamp},
+	{Name: "distinct_id", Type: parquet.String},
+}
+
+// Day is a daily partition old enough to archive
+type Day struct {
+	Partition string    `json:"partition"`
+	Day       time.Time `json:"day"`
+	Rows      int64     `json:"estimated_rows"`
+	Archived  bool      `json:"archived"` // already in the destination
+}
+
+// Result describes one archived or restored day
+type Result struct {
+	Day      time.Time `json:"day"`
+	Events   int64     `json:"events"`
+	Sessions int64     `json:"sessions"`
+	Bytes    int64     `json:"bytes,omitempty"`
+	Dropped  bool      `json:"dropped,omitempty"`
+}
+
+// Archiver archives to and restores from one bucket
+type Archiver struct {
+	DB     *sql.DB
+	Bucket blobstore.Bucket
+	Prefix string // key prefix inside the bucket, e.g. "archive/"
+}
+
+// EventsKey is the object key of a day's events
+func (a *Archiver) EventsKey(day time.Time) string {
+	return a.Prefix + day.Format("events/2006/01/2006-01-02.parquet")
+}
+
+// SessionsKey is the object key of a day's sessions
+func (a *Archiver) SessionsKey(day time.Time) string {
+	return a.Prefix + day.Format("sessions/2006/01/2006-01-02.parquet")
+}
+
+// Plan lists the daily partitions that end before the cutoff, oldest first
+func (a *Archiver) Plan(ctx context.Context, before time.Time) ([]Day, error) {
+	partitions, err := retention.ExpiredPartitions(ctx, a.DB, before)
+	if err != nil {
+		return nil, err
+	}
+
+	days := make([]Day, 0, len(partitions))
+	for _, p := range partitions {
+		d := Day{Partition: p.Name, Day: p.Day, Rows: p.Rows}
+		_, err := a.Bucket.Stat(ctx, a.EventsKey(p.Day))
+		switch {
+		case err == nil:
+			d.Archived = true
+		case !errors.Is(err, blobstore.ErrNotFound):
+			return nil, fmt.Errorf("failed to check archive of %s: %w", p.Name, err)
+		}
+		days = append(days, d)
+	}
+	return days, nil
+}
+
+// Archive exports a partition and its sessions, then drops the partition if
+// drop is set. The events object is written last, so its presence marks a
+// complete archive.
+func (a *Archiver) Archive(ctx context.Context, day Day, drop bool) (*Result, error) {
+	table := pq.QuoteIdentifier(day.Partition)
+	result := &Result{Day: day.Day}
+
+	sessions, size, err := a.export(ctx, a.SessionsKey(day.Day), sessionColumns, `
+		SELECT `+selectList(sessionColumns)+`
+		FROM session
+		WHERE session_id IN (SELECT DISTINCT session_id FROM `+table+`)
+		ORDER BY created_at
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to archive sessions of %s: %w", day.Partition, err)
+	}
+	result.Sessions, result.Bytes = sessions, size
+
+	events, size, err := a.export(ctx, a.EventsKey(day.Day), eventColumns, `
+		SELECT `+selectList(eventColumns)+`
+		FROM `+table+`
+		ORDER BY created_at
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to archive %s: %w", day.Partition, err)
+	}
+	result.Events = events
+	result.Bytes += size
+
+	if drop {
+		if _, err := a.DB.ExecContext(ctx, "DROP TABLE IF EXISTS "+table); err != nil {
+			return result, fmt.Errorf("failed to drop partition %s: %w", day.Partition, err)
+		}
+		result.Dropped = true
+	}
+	return result, nil
+}
+
+// selectList casts every column to the type it is archived as
+func selectList(columns []parquet.Column) string {
+	parts := make([]string, len(columns))
+	for i, col := range columns {
+		parts[i] = col.Name
+		if col.Type == parquet.String {
+			parts[i] += "::text"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// export writes the query's rows to a temporary Parquet file, uploads it
+// and checks the stored size. It returns the row and byte counts.
+func (a *Archiver) export(ctx context.Context, key string, columns []parquet.Column, query string) (int64, int64, error) {
+	f, err := os.CreateTemp("", "kaunta-archive-*.parquet")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+
+	rows, err := a.DB.QueryContext(ctx, query)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	w, err := parquet.NewWriter(f, columns)
+	if err != nil {
+		return 0, 0, err
+	}
+	dest := make([]any, len(columns))
+	for i, col := range columns {
+		switch col.Type {
+		case parquet.Int32:
+			dest[i] = new(sql.NullInt32)
+		case parquet.Timestamp:
+			dest[i] = new(sql.NullTime)
+		default:
+			dest[i] = new(sql.NullString)
+		}
+	}
+	row := make([]any, len(columns))
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return 0, 0, err
+		}
+		for i, d := range dest {
+			row[i] = nil
+			switch v := d.(type) {
+			case *sql.NullString:
+				if v.Valid {
+					row[i] = v.String
+				}
+			case *sql.NullInt32:
+				if v.Valid {
+					row[i] = v.Int32
+				}
+			case *sql.NullTime:
+				if v.Valid {
+					row[i] = v.Time
+				}
+			}
+		}
+		if err := w.Write(row); err != nil {
+			return 0, 0, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+	if err := w.Close(); err != nil {
+		return 0, 0, err
+	}
+
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+	if err := a.Bucket.Put(ctx, key, f, size, contentType); err != nil {
+		return 0, 0, fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	obj, err := a.Bucket.Stat(ctx, key)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to verify %s: %w", key, err)
+	}
+	if obj.Size != size {
+		return 0, 0, fmt.Errorf("upload of %s is incomplete: %d of %d bytes", key, obj.Size, size)
+	}
+	return w.Rows(), size, nil
+}
+
+// Archived lists the days stored in the bucket, oldest first
+func (a *Archiver) Archived(ctx context.Context) ([]time.Time, error) {
+	objects, err := a.Bucket.List(ctx, a.Prefix+"events/")
+	if err != nil {
+		return nil, err
+	}
+	var days []time.Time
+	for _, obj := range objects {
+		day, err := time.Parse("2006-01-02.parquet", path.Base(obj.Key))
+		if err != nil {
+			continue
+		}
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days, nil
+}
+
+// Restore loads an archived day back, recreating its partition. Rows that
+// are already present are skipped, so a restore can be repeated. The
+// websites the rows belong to must still exist.
+func (a *Archiver) Restore(ctx context.Context, day time.Time) (*Result, error) {
+	day = day.UTC().Truncate(24 * time.Hour)
+	result := &Result{Day: day}
+
+	partition := "website_event_" + day.Format("2006_01_02")
+	if _, err := a.DB.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		PARTITION OF website_event
+		FOR VALUES FROM ('%s') TO ('%s')
+	`, pq.QuoteIdentifier(partition), day.Format("2006-01-02"), day.AddDate(0, 0, 1).Format("2006-01-02"))); err != nil {
+		return nil, fmt.Errorf("failed to create partition %s: %w", partition, err)
+	}
+
+	var err error
+	if result.Sessions, err = a.load(ctx, a.SessionsKey(day), "session", sessionColumns); err != nil {
+		return nil, err
+	}
+	if result.Events, err = a.load(ctx, a.EventsKey(day), "website_event", eventColumns); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// load inserts the rows of an archived file into table and returns how many
+// were new
+func (a *Archiver) load(ctx context.Context, key, table string, known []parquet.Column) (int64, error) {
+	body, err := a.Bucket.Get(ctx, key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	defer func() { _ = body.Close() }()
+
+	f, err := os.CreateTemp("", "kaunta-restore-*.parquet")
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+	size, err := io.Copy(f, body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to download %s: %w", key, err)
+	}
+
+	r, err := parquet.NewReader(f, size)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+
+	// Column names end up in SQL, so only accept the ones we archive
+	allowed := make(map[string]parquet.Type, len(known))
+	for _, col := range known {
+		allowed[col.Name] = col.Type
+	}
+	names := make([]string, len(r.Columns()))
+	for i, col := range r.Columns() {
+		if typ, ok := allowed[col.Name]; !ok || typ != col.Type {
+			return 0, fmt.Errorf("%s has unexpected column %s (%s)", key, col.Name, col.Type)
+		}
+		names[i] = col.Name
+	}
+
+	var restored int64
+	batch := make([]any, 0, restoreBatch*len(names))
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		res, err := a.DB.ExecContext(ctx, insertStatement(table, names, len(batch)/len(names)), batch...)
+		if err != nil {
+			return fmt.Errorf("failed to restore %s: %w", table, err)
+		}
+		n, _ := res.RowsAffected()
+		restored += n
+		batch = batch[:0]
+		return nil
+	}
+
+	err = r.Each(func(row []any) error {
+		batch = append(batch, row...)
+		if len(batch) >= restoreBatch*len(names) {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return restored, err
+	}
+	return restored, flush()
+}
+
+func insertStatement(table string, columns []string, rows int) string {
+	var sb strings.Builder
+	sb.WriteString("INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES ")
+	n := 1
+	for i := 0; i < rows; i++ {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("(")
+		for j := range columns {
+			if j > 0 {
+				sb.WriteString(", ")
+			}
+			fmt.Fprintf(&sb, "$%d", n)
+			n++
+		}
+		sb.WriteString(")")
+	}
+	sb.WriteString(" ON CONFLICT DO NOTHING")
+	return sb.String()
+}
@@ -0,0 +1,143 @@
+package archive
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/blobstore"
+)
+
+func newArchiver(t *testing.T) (*Archiver, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	bucket, err := blobstore.NewLocal(t.TempDir())
+	require.NoError(t, err)
+	return &Archiver{DB: db, Bucket: bucket, Prefix: "archive/"}, mock
+}
+
+func columnNames(t *testing.T, which string) []string {
+	t.Helper()
+	cols := eventColumns
+	if which == "session" {
+		cols = sessionColumns
+	}
+	names := make([]string, len(cols))
+	for i, c := range cols {
+		names[i] = c.Name
+	}
+	return names
+}
+
+var (
+	day     = time.Date(2025, 5, 9, 0, 0, 0, 0, time.UTC)
+	website = "7f9c2a1e-0000-4000-8000-000000000001"
+	session = "7f9c2a1e-0000-4000-8000-0000000000aa"
+)
+
+func sessionRow() []driver.Value {
+	return []driver.Value{session, website, "example.com", "chrome", "linux", "desktop", "1920x1080",
+		"en-US", "DE", nil, nil, "Berlin", nil, day.Add(9 * time.Hour), nil}
+}
+
+func eventRow(id string, at time.Time, props any) []driver.Value {
+	return []driver.Value{id, website, session, session, at, "/pricing", nil, nil, nil, "google.com",
+		"Pricing", "example.com", int64(1), nil, nil, int64(80), int64(45000), props,
+		"newsletter", nil, nil, nil, nil}
+}
+
+func TestArchiveAndRestore(t *testing.T) {
+	ctx := context.Background()
+	a, mock := newArchiver(t)
+
+	mock.ExpectQuery(`FROM session\s+WHERE session_id IN \(SELECT DISTINCT session_id FROM "website_event_2025_05_09"\)`).
+		WillReturnRows(sqlmock.NewRows(columnNames(t, "session")).AddRow(sessionRow()...))
+	mock.ExpectQuery(`FROM "website_event_2025_05_09"\s+ORDER BY created_at`).
+		WillReturnRows(sqlmock.NewRows(columnNames(t, "event")).
+			AddRow(eventRow("e1", day.Add(9*time.Hour), `{"plan":"pro"}`)...).
+			AddRow(eventRow("e2", day.Add(10*time.Hour), nil)...))
+	mock.ExpectExec(`DROP TABLE IF EXISTS "website_event_2025_05_09"`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	result, err := a.Archive(ctx, Day{Partition: "website_event_2025_05_09", Day: day}, true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), result.Events)
+	assert.Equal(t, int64(1), result.Sessions)
+	assert.True(t, result.Dropped)
+	assert.Positive(t, result.Bytes)
+
+	days, err := a.Archived(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{day}, days)
+
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "website_event_2025_05_09"\s+PARTITION OF website_event\s+FOR VALUES FROM \('2025-05-09'\) TO \('2025-05-10'\)`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	sessionArgs := make([]driver.Value, 0, len(sessionColumns))
+	for _, v := range sessionRow() {
+		if at, ok := v.(time.Time); ok {
+			v = at.UTC()
+		}
+		sessionArgs = append(sessionArgs, v)
+	}
+	mock.ExpectExec(`INSERT INTO session \(session_id, website_id, .*distinct_id\) VALUES \(\$1, .*\$15\) ON CONFLICT DO NOTHING`).
+		WithArgs(sessionArgs...).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO website_event \(event_id, .*utm_term\) VALUES \(\$1, .*\), \(.*\$46\) ON CONFLICT DO NOTHING`).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	restored, err := a.Restore(ctx, day.Add(5*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, &Result{Day: day, Events: 2, Sessions: 1}, restored)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPlanMarksArchivedDays(t *testing.T) {
+	ctx := context.Background()
+	a, mock := newArchiver(t)
+
+	mock.ExpectQuery(`FROM pg_inherits`).
+		WillReturnRows(sqlmock.NewRows([]string{"relname", "reltuples"}).
+			AddRow("website_event_2025_05_09", 1000).
+			AddRow("website_event_2025_05_10", 1200).
+			AddRow("website_event_2025_06_01", 10))
+
+	require.NoError(t, a.Bucket.Put(ctx, a.EventsKey(day), strings.NewReader("PAR1"), 4, contentType))
+
+	days, err := a.Plan(ctx, time.Date(2025, 5, 20, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Len(t, days, 2)
+	assert.Equal(t, "website_event_2025_05_09", days[0].Partition)
+	assert.True(t, days[0].Archived)
+	assert.Equal(t, "website_event_2025_05_10", days[1].Partition)
+	assert.False(t, days[1].Archived)
+	assert.Equal(t, "archive/events/2025/05/2025-05-10.parquet", a.EventsKey(days[1].Day))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRestoreRejectsUnknownColumns(t *testing.T) {
+	ctx := context.Background()
+	a, mock := newArchiver(t)
+
+	// An events file holding session columns must not be inserted
+	mock.ExpectQuery(`FROM session`).
+		WillReturnRows(sqlmock.NewRows(columnNames(t, "session")).AddRow(sessionRow()...))
+	_, _, err := a.export(ctx, a.EventsKey(day), sessionColumns, `SELECT 1 FROM session`)
+	require.NoError(t, err)
+
+	_, err = a.load(ctx, a.EventsKey(day), "website_event", eventColumns)
+	assert.ErrorContains(t, err, "unexpected column")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInsertStatement(t *testing.T) {
+	assert.Equal(t,
+		"INSERT INTO session (a, b) VALUES ($1, $2), ($3, $4) ON CONFLICT DO NOTHING",
+		insertStatement("session", []string{"a", "b"}, 2))
+}
@@ -0,0 +1,129 @@
+// Package blobstore provides object storage for subsystems that produce files
+// (archives, exports, reports, GeoIP databases).
+//
+// A single Bucket is configured once through the storage_* keys in
+// kaunta.toml and shared process-wide via Current:
+//
+//   - local: a directory on disk (default, <data_dir>/storage)
+//   - s3:    any S3-compatible service (AWS, MinIO, R2, ...)
+//   - gcs:   Google Cloud Storage through its S3-compatible XML API,
+//     authenticated with HMAC keys
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seuros/kaunta/internal/config"
+)
+
+// ErrNotFound is returned when a key does not exist
+var ErrNotFound = errors.New("object not found")
+
+// Object describes a stored blob
+type Object struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Bucket is a flat key/value blob store. Keys use forward slashes
+// ("exports/2025/03/site.csv") regardless of backend.
+type Bucket interface {
+	// Name identifies the backend ("local", "s3" or "gcs")
+	Name() string
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Stat(ctx context.Context, key string) (*Object, error)
+	Delete(ctx context.Context, key string) error
+	List(ctx context.Context, prefix string) ([]Object, error)
+}
+
+var (
+	currentMu sync.RWMutex
+	current   Bucket
+)
+
+// Current returns the process-wide bucket, or nil if none was configured
+func Current() Bucket {
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	return current
+}
+
+// SetCurrent replaces the process-wide bucket
+func SetCurrent(b Bucket) {
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	current = b
+}
+
+// Open builds the bucket described by the storage settings. dataDir is used
+// for the default local path.
+func Open(cfg config.StorageConfig, dataDir string) (Bucket, error) {
+	switch strings.ToLower(cfg.Backend) {
+	case "", "local":
+		root := cfg.Path
+		if root == "" {
+			root = filepath.Join(dataDir, "storage")
+		}
+		return NewLocal(root)
+	case "s3":
+		return NewS3(cfg)
+	case "gcs":
+		return NewGCS(cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", cfg.Backend)
+	}
+}
+
+// OpenURL builds a bucket from a destination such as s3://bucket/prefix,
+// gs://bucket/prefix or a local directory (optionally file://). Endpoint,
+// region and credentials still come from the storage settings. An empty
+// destination opens the configured bucket.
+func OpenURL(dest string, cfg config.StorageConfig, dataDir string) (Bucket, error) {
+	if dest == "" {
+		return Open(cfg, dataDir)
+	}
+
+	scheme, rest, ok := strings.Cut(dest, "://")
+	if !ok {
+		return NewLocal(dest)
+	}
+	switch strings.ToLower(scheme) {
+	case "file":
+		return NewLocal(rest)
+	case "s3", "gs":
+		bucket, prefix, _ := strings.Cut(rest, "/")
+		if bucket == "" {
+			return nil, fmt.Errorf("missing bucket in %s", dest)
+		}
+		cfg.Bucket, cfg.Prefix = bucket, prefix
+		if scheme == "gs" {
+			return NewGCS(cfg)
+		}
+		return NewS3(cfg)
+	default:
+		return nil, fmt.Errorf("unsupported destination: %s (use s3://, gs:// or a directory)", dest)
+	}
+}
+
+// cleanKey normalises a key and rejects ones that escape the bucket root
+func cleanKey(key string) (string, error) {
+	key = strings.TrimPrefix(filepath.ToSlash(key), "/")
+	if key == "" {
+		return "", fmt.Errorf("empty object key")
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("invalid object key: %s", key)
+		}
+	}
+	return key, nil
+}
@@ -0,0 +1,147 @@
+package blobstore
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/offline"
+)
+
+func TestLocalRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	bucket, err := NewLocal(t.TempDir())
+	require.NoError(t, err)
+
+	require.NoError(t, bucket.Put(ctx, "exports/2025/site.csv", strings.NewReader("a,b\n"), 4, "text/csv"))
+	require.NoError(t, bucket.Put(ctx, "/archives/site.parquet", strings.NewReader("PAR1"), -1, ""))
+
+	r, err := bucket.Get(ctx, "exports/2025/site.csv")
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, "a,b\n", string(data))
+
+	obj, err := bucket.Stat(ctx, "archives/site.parquet")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), obj.Size)
+	assert.Equal(t, "archives/site.parquet", obj.Key)
+
+	objects, err := bucket.List(ctx, "exports/")
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	assert.Equal(t, "exports/2025/site.csv", objects[0].Key)
+
+	all, err := bucket.List(ctx, "")
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	require.NoError(t, bucket.Delete(ctx, "exports/2025/site.csv"))
+	require.NoError(t, bucket.Delete(ctx, "exports/2025/site.csv"))
+	_, err = bucket.Get(ctx, "exports/2025/site.csv")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = bucket.Stat(ctx, "exports")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestLocalRejectsEscapingKeys(t *testing.T) {
+	bucket, err := NewLocal(t.TempDir())
+	require.NoError(t, err)
+
+	err = bucket.Put(context.Background(), "../outside.txt", strings.NewReader("x"), 1, "")
+	assert.Error(t, err)
+	_, err = bucket.Get(context.Background(), "")
+	assert.Error(t, err)
+}
+
+func TestOpen(t *testing.T) {
+	dataDir := t.TempDir()
+
+	bucket, err := Open(config.StorageConfig{}, dataDir)
+	require.NoError(t, err)
+	assert.Equal(t, "local", bucket.Name())
+	assert.DirExists(t, dataDir+"/storage")
+
+	_, err = Open(config.StorageConfig{Backend: "ftp"}, dataDir)
+	assert.ErrorContains(t, err, "unknown storage backend")
+
+	_, err = Open(config.StorageConfig{Backend: "s3"}, dataDir)
+	assert.ErrorContains(t, err, "storage_bucket")
+
+	_, err = Open(config.StorageConfig{Backend: "gcs", Bucket: "kaunta"}, dataDir)
+	assert.ErrorContains(t, err, "HMAC")
+
+	bucket, err = Open(config.StorageConfig{
+		Backend:   "s3",
+		Bucket:    "kaunta",
+		Endpoint:  "http://minio.internal:9000",
+		AccessKey: "key",
+		SecretKey: "secret",
+		Prefix:    "/prod/",
+	}, dataDir)
+	require.NoError(t, err)
+	assert.Equal(t, "s3", bucket.Name())
+	s3, ok := bucket.(*S3)
+	require.True(t, ok)
+	assert.Equal(t, "prod/", s3.prefix)
+	assert.Equal(t, "minio.internal:9000", s3.client.EndpointURL().Host)
+	assert.Equal(t, "http", s3.client.EndpointURL().Scheme)
+}
+
+func TestOpenURL(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.StorageConfig{Endpoint: "http://minio.internal:9000", AccessKey: "key", SecretKey: "secret"}
+
+	bucket, err := OpenURL("s3://archive/kaunta/events", cfg, dir)
+	require.NoError(t, err)
+	s3, ok := bucket.(*S3)
+	require.True(t, ok)
+	assert.Equal(t, "archive", s3.bucket)
+	assert.Equal(t, "kaunta/events/", s3.prefix)
+
+	bucket, err = OpenURL("gs://archive", cfg, dir)
+	require.NoError(t, err)
+	assert.Equal(t, "gcs", bucket.Name())
+
+	bucket, err = OpenURL("file://"+dir+"/cold", cfg, dir)
+	require.NoError(t, err)
+	assert.Equal(t, "local", bucket.Name())
+	assert.DirExists(t, dir+"/cold")
+
+	bucket, err = OpenURL("", config.StorageConfig{}, dir)
+	require.NoError(t, err)
+	assert.Equal(t, "local", bucket.Name())
+
+	_, err = OpenURL("s3://", cfg, dir)
+	assert.ErrorContains(t, err, "missing bucket")
+	_, err = OpenURL("ftp://host/dir", cfg, dir)
+	assert.ErrorContains(t, err, "unsupported destination")
+}
+
+func TestOpenCloudBackendsOffline(t *testing.T) {
+	offline.Set(true)
+	t.Cleanup(func() { offline.Set(false) })
+
+	_, err := Open(config.StorageConfig{Backend: "s3", Bucket: "kaunta"}, t.TempDir())
+	assert.ErrorIs(t, err, offline.ErrOffline)
+
+	// Self-hosted endpoints stay reachable in offline mode
+	_, err = Open(config.StorageConfig{Backend: "s3", Bucket: "kaunta", Endpoint: "minio:9000"}, t.TempDir())
+	assert.NoError(t, err)
+}
+
+func TestCurrent(t *testing.T) {
+	original := Current()
+	t.Cleanup(func() { SetCurrent(original) })
+
+	bucket, err := NewLocal(t.TempDir())
+	require.NoError(t, err)
+	SetCurrent(bucket)
+	assert.Same(t, bucket, Current())
+}
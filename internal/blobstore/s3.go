@@ -0,0 +1,191 @@
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/offline"
+)
+
+const (
+	awsEndpoint = "s3.amazonaws.com"
+	gcsEndpoint = "storage.googleapis.com"
+)
+
+// S3 stores objects in an S3-compatible bucket. It also backs the GCS
+// backend, which uses the XML interoperability API.
+type S3 struct {
+	name   string
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// NewS3 returns a bucket for AWS S3 or any S3-compatible endpoint. Without
+// explicit keys, credentials come from the AWS_* environment or the instance
+// role.
+func NewS3(cfg config.StorageConfig) (*S3, error) {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = awsEndpoint
+	}
+	return newS3("s3", endpoint, cfg)
+}
+
+// NewGCS returns a bucket for Google Cloud Storage. Create HMAC keys for a
+// service account and set them as storage_access_key / storage_secret_key.
+func NewGCS(cfg config.StorageConfig) (*S3, error) {
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("gcs storage requires HMAC keys (storage_access_key, storage_secret_key)")
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = gcsEndpoint
+	}
+	return newS3("gcs", endpoint, cfg)
+}
+
+func newS3(name, endpoint string, cfg config.StorageConfig) (*S3, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("%s storage requires storage_bucket", name)
+	}
+
+	secure := !cfg.Insecure
+	if rest, ok := strings.CutPrefix(endpoint, "https://"); ok {
+		endpoint = rest
+	} else if rest, ok := strings.CutPrefix(endpoint, "http://"); ok {
+		endpoint, secure = rest, false
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+
+	// Public cloud endpoints are outbound calls; self-hosted ones are not
+	if endpoint == awsEndpoint || endpoint == gcsEndpoint {
+		if err := offline.Check(name+" storage", endpoint); err != nil {
+			return nil, err
+		}
+	}
+
+	var creds *credentials.Credentials
+	if cfg.AccessKey != "" {
+		creds = credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, "")
+	} else {
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.IAM{},
+		})
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: secure,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s client: %w", name, err)
+	}
+
+	prefix := strings.Trim(cfg.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &S3{name: name, client: client, bucket: cfg.Bucket, prefix: prefix}, nil
+}
+
+// Name implements Bucket
+func (s *S3) Name() string { return s.name }
+
+func (s *S3) objectName(key string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return s.prefix + key, nil
+}
+
+// translate maps a missing-key response to ErrNotFound
+func translate(err error) error {
+	if err == nil {
+		return nil
+	}
+	if resp := minio.ToErrorResponse(err); resp.Code == "NoSuchKey" || resp.StatusCode == 404 {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Put implements Bucket. A negative size streams with multipart upload.
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	name, err := s.objectName(key)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.PutObject(ctx, s.bucket, name, r, size, minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+// Get implements Bucket
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	name, err := s.objectName(key)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := s.client.GetObject(ctx, s.bucket, name, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, translate(err)
+	}
+	// GetObject is lazy; Stat surfaces a missing key before the caller reads
+	if _, err := obj.Stat(); err != nil {
+		_ = obj.Close()
+		return nil, translate(err)
+	}
+	return obj, nil
+}
+
+// Stat implements Bucket
+func (s *S3) Stat(ctx context.Context, key string) (*Object, error) {
+	name, err := s.objectName(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := s.client.StatObject(ctx, s.bucket, name, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &Object{Key: strings.TrimPrefix(info.Key, s.prefix), Size: info.Size, ModTime: info.LastModified}, nil
+}
+
+// Delete implements Bucket. Deleting a missing key is not an error.
+func (s *S3) Delete(ctx context.Context, key string) error {
+	name, err := s.objectName(key)
+	if err != nil {
+		return err
+	}
+	return s.client.RemoveObject(ctx, s.bucket, name, minio.RemoveObjectOptions{})
+}
+
+// List implements Bucket
+func (s *S3) List(ctx context.Context, prefix string) ([]Object, error) {
+	prefix = strings.TrimPrefix(prefix, "/")
+
+	var objects []Object
+	for info := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+		Prefix:    s.prefix + prefix,
+		Recursive: true,
+	}) {
+		if info.Err != nil {
+			return nil, info.Err
+		}
+		objects = append(objects, Object{
+			Key:     strings.TrimPrefix(info.Key, s.prefix),
+			Size:    info.Size,
+			ModTime: info.LastModified,
+		})
+	}
+	return objects, nil
+}
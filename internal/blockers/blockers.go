@@ -0,0 +1,133 @@
+// Package blockers estimates the share of visitors whose browser blocks the
+// JavaScript tracker.
+//
+// Sites opt in by adding the baseline pixel (GET /b/<website-id>.gif) to
+// their pages. A plain image from the site's own markup is rarely blocked,
+// so the pixel sees nearly every visitor. Each pixel hit is stored under the
+// session the tracker would have used (baseline_hit); pixel visitors with no
+// event in that session on the same day blocked the tracker. The ratio gives
+// a per-website correction factor for visitor counts.
+package blockers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MinSample is the number of pixel visitors needed before a correction
+// factor is trusted
+const MinSample = 100
+
+// keepDays is how long baseline hits are kept
+const keepDays = 90
+
+// Website is the blocking estimate for one website
+type Website struct {
+	Domain          string  `json:"domain"`
+	PixelVisitors   int64   `json:"pixel_visitors"`
+	TrackedVisitors int64   `json:"tracked_visitors"` // pixel visitors the tracker also saw
+	Blocked         int64   `json:"blocked"`
+	BlockRate       float64 `json:"block_rate"` // percent of pixel visitors
+	Correction      float64 `json:"correction"` // multiply tracked visitors by this
+	Reliable        bool    `json:"reliable"`   // at least MinSample pixel visitors
+}
+
+// visitorCounts matches pixel visitors to tracked sessions on the same day
+const visitorCounts = `
+	SELECT
+		w.domain,
+		COUNT(*),
+		COUNT(*) FILTER (WHERE EXISTS (
+			SELECT 1 FROM website_event e
+			WHERE e.website_id = b.website_id
+			  AND e.session_id = b.session_id
+			  AND e.created_at >= b.day
+			  AND e.created_at < b.day + 1
+		))
+	FROM baseline_hit b
+	JOIN website w ON w.website_id = b.website_id
+	WHERE b.day >= CURRENT_DATE - $1::int
+`
+
+// Report estimates blocking for every website with pixel hits in the last
+// days, highest block rate first
+func Report(ctx context.Context, db *sql.DB, days int) ([]Website, error) {
+	if days < 1 {
+		return nil, fmt.Errorf("days must be at least 1")
+	}
+
+	rows, err := db.QueryContext(ctx, visitorCounts+`
+		GROUP BY w.domain
+		ORDER BY w.domain
+	`, days)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query baseline hits: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	websites := []Website{}
+	for rows.Next() {
+		var w Website
+		if err := rows.Scan(&w.Domain, &w.PixelVisitors, &w.TrackedVisitors); err != nil {
+			return nil, err
+		}
+		websites = append(websites, estimate(w))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(websites, func(i, j int) bool {
+		return websites[i].BlockRate > websites[j].BlockRate
+	})
+	return websites, nil
+}
+
+// Correction returns the factor to multiply a website's tracked visitors by.
+// It is 1 until the website has MinSample pixel visitors in the period.
+func Correction(ctx context.Context, db *sql.DB, websiteID uuid.UUID, days int) (float64, error) {
+	if days < 1 {
+		return 0, fmt.Errorf("days must be at least 1")
+	}
+
+	w := Website{}
+	err := db.QueryRowContext(ctx, visitorCounts+`
+		AND b.website_id = $2
+		GROUP BY w.domain
+	`, days, websiteID).Scan(&w.Domain, &w.PixelVisitors, &w.TrackedVisitors)
+	if err == sql.ErrNoRows {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to query baseline hits: %w", err)
+	}
+	return estimate(w).Correction, nil
+}
+
+func estimate(w Website) Website {
+	w.Blocked = w.PixelVisitors - w.TrackedVisitors
+	w.Correction = 1
+	if w.PixelVisitors > 0 {
+		w.BlockRate = float64(w.Blocked) / float64(w.PixelVisitors) * 100
+	}
+	w.Reliable = w.PixelVisitors >= MinSample && w.TrackedVisitors > 0
+	if w.Reliable {
+		w.Correction = float64(w.PixelVisitors) / float64(w.TrackedVisitors)
+	}
+	return w
+}
+
+// Cleanup deletes baseline hits older than keepDays
+func Cleanup(ctx context.Context, db *sql.DB) (int64, error) {
+	res, err := db.ExecContext(ctx, `DELETE FROM baseline_hit WHERE day < $1`,
+		time.Now().UTC().AddDate(0, 0, -keepDays).Format("2006-01-02"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete baseline hits: %w", err)
+	}
+	return res.RowsAffected()
+}
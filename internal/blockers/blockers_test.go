@@ -0,0 +1,74 @@
+package blockers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/test"
+)
+
+func TestReportOrdersByBlockRate(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+
+	mock.ExpectQuery(`FROM baseline_hit b`).WithArgs(30).
+		WillReturnRows(sqlmock.NewRows([]string{"domain", "pixel", "tracked"}).
+			AddRow("docs.example", 1000, 900).
+			AddRow("dev.example", 400, 240).
+			AddRow("tiny.example", 20, 10))
+
+	websites, err := Report(context.Background(), db, 30)
+	require.NoError(t, err)
+	require.Len(t, websites, 3)
+
+	assert.Equal(t, "tiny.example", websites[0].Domain)
+	assert.InDelta(t, 50.0, websites[0].BlockRate, 0.001)
+	assert.False(t, websites[0].Reliable, "too few pixel visitors")
+	assert.Equal(t, 1.0, websites[0].Correction)
+
+	assert.Equal(t, "dev.example", websites[1].Domain)
+	assert.Equal(t, int64(160), websites[1].Blocked)
+	assert.InDelta(t, 40.0, websites[1].BlockRate, 0.001)
+	assert.True(t, websites[1].Reliable)
+	assert.InDelta(t, 400.0/240.0, websites[1].Correction, 0.0001)
+
+	assert.Equal(t, "docs.example", websites[2].Domain)
+	assert.InDelta(t, 10.0, websites[2].BlockRate, 0.001)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReportRejectsBadDays(t *testing.T) {
+	db, _ := test.NewMockDB(t)
+	_, err := Report(context.Background(), db, 0)
+	assert.Error(t, err)
+}
+
+func TestCorrection(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+
+	mock.ExpectQuery(`AND b.website_id = \$2`).WithArgs(7, websiteID).
+		WillReturnRows(sqlmock.NewRows([]string{"domain", "pixel", "tracked"}).
+			AddRow("blog.example", 500, 400))
+	factor, err := Correction(context.Background(), db, websiteID, 7)
+	require.NoError(t, err)
+	assert.InDelta(t, 1.25, factor, 0.0001)
+
+	mock.ExpectQuery(`AND b.website_id = \$2`).WithArgs(7, websiteID).
+		WillReturnRows(sqlmock.NewRows([]string{"domain", "pixel", "tracked"}))
+	factor, err = Correction(context.Background(), db, websiteID, 7)
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, factor, "no pixel hits means no correction")
+
+	mock.ExpectQuery(`AND b.website_id = \$2`).WithArgs(7, websiteID).
+		WillReturnRows(sqlmock.NewRows([]string{"domain", "pixel", "tracked"}).
+			AddRow("blog.example", 300, 0))
+	factor, err = Correction(context.Background(), db, websiteID, 7)
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, factor, "a tracker that never fired gives no usable ratio")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,100 @@
+// Package buildinfo describes the running binary: its release, the commit
+// and date it was built from, the Go toolchain and the optional features
+// compiled in. Fleet operators read it from `kaunta version` and
+// /api/version to tell builds apart.
+//
+// Release builds stamp Commit and Date at link time:
+//
+//	go build -ldflags "-X github.com/seuros/kaunta/internal/buildinfo.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/seuros/kaunta/internal/buildinfo.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Other builds fall back to the VCS information the Go toolchain records.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+// Set at link time
+var (
+	Commit string
+	Date   string
+)
+
+// Info describes a build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	// Modified is set for builds of a working tree with local changes
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go_version"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	// Tags are the build tags the binary was compiled with
+	Tags []string `json:"tags"`
+	// Features are the optional features and whether this build has them
+	Features map[string]bool `json:"features"`
+}
+
+// Get describes the running binary; features are the optional features the
+// caller knows about
+func Get(version string, features map[string]bool) Info {
+	info := Info{
+		Version:   version,
+		Commit:    Commit,
+		BuildDate: Date,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		Tags:      []string{},
+		Features:  features,
+	}
+	if info.Features == nil {
+		info.Features = map[string]bool{}
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		apply(&info, bi.Settings)
+	}
+	return info
+}
+
+// apply fills what the linker didn't stamp from the toolchain's settings
+func apply(info *Info, settings []debug.BuildSetting) {
+	for _, s := range settings {
+		switch s.Key {
+		case "-tags":
+			info.Tags = splitTags(s.Value)
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = s.Value
+			}
+		case "vcs.time":
+			if info.BuildDate == "" {
+				info.BuildDate = s.Value
+			}
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+}
+
+func splitTags(value string) []string {
+	tags := []string{}
+	for _, tag := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// ShortCommit is the commit abbreviated to 12 characters
+func (i Info) ShortCommit() string {
+	if len(i.Commit) > 12 {
+		return i.Commit[:12]
+	}
+	return i.Commit
+}
@@ -0,0 +1,47 @@
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApply(t *testing.T) {
+	info := Info{Tags: []string{}}
+	apply(&info, []debug.BuildSetting{
+		{Key: "-tags", Value: "sqlite,docker"},
+		{Key: "CGO_ENABLED", Value: "1"},
+		{Key: "vcs.revision", Value: "0123456789abcdef0123"},
+		{Key: "vcs.time", Value: "2025-06-15T09:00:00Z"},
+		{Key: "vcs.modified", Value: "true"},
+	})
+
+	assert.Equal(t, []string{"docker", "sqlite"}, info.Tags)
+	assert.Equal(t, "0123456789abcdef0123", info.Commit)
+	assert.Equal(t, "0123456789ab", info.ShortCommit())
+	assert.Equal(t, "2025-06-15T09:00:00Z", info.BuildDate)
+	assert.True(t, info.Modified)
+}
+
+func TestApplyKeepsLinkerValues(t *testing.T) {
+	info := Info{Commit: "abc123", BuildDate: "2025-07-01T00:00:00Z"}
+	apply(&info, []debug.BuildSetting{
+		{Key: "vcs.revision", Value: "0123456789abcdef0123"},
+		{Key: "vcs.time", Value: "2025-06-15T09:00:00Z"},
+	})
+
+	assert.Equal(t, "abc123", info.ShortCommit())
+	assert.Equal(t, "2025-07-01T00:00:00Z", info.BuildDate)
+}
+
+func TestGet(t *testing.T) {
+	info := Get("1.2.3", map[string]bool{"sqlite": false})
+
+	assert.Equal(t, "1.2.3", info.Version)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+	assert.Equal(t, runtime.GOOS+"/"+runtime.GOARCH, info.OS+"/"+info.Arch)
+	assert.NotNil(t, info.Tags)
+	assert.Equal(t, map[string]bool{"sqlite": false}, info.Features)
+}
@@ -0,0 +1,222 @@
+// Package cardinality puts a soft quota on the distinct values a website can
+// record per dimension and day, so random URLs or garbage referrers can't
+// blow up tables, indexes and breakdowns.
+//
+// Each server process tracks the values it saw today (as 64-bit hashes). Once
+// a dimension of a website reaches the cap, new values are recorded as
+// "(other)" until midnight UTC, while values already seen today still count
+// as themselves. The first overflow of the day is logged and stored in
+// cardinality_overflow, where `kaunta website check` reports it.
+//
+// The quota is per process and starts over on restart, so it is a guard rail
+// rather than an exact limit.
+package cardinality
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/logging"
+)
+
+// Other replaces values past the cap
+const Other = "(other)"
+
+// Dimensions with a quota
+const (
+	Page        = "page"
+	Referrer    = "referrer"
+	UTMSource   = "utm_source"
+	UTMMedium   = "utm_medium"
+	UTMCampaign = "utm_campaign"
+	UTMContent  = "utm_content"
+	UTMTerm     = "utm_term"
+	Event       = "event"
+)
+
+// keepDays is how long overflows are kept
+const keepDays = 30
+
+type key struct {
+	websiteID uuid.UUID
+	dimension string
+}
+
+type values struct {
+	seen map[uint64]struct{}
+	over bool
+}
+
+// Tracker counts the distinct values of the current UTC day
+type Tracker struct {
+	mu       sync.Mutex
+	valueCap int
+	day      string
+	dims     map[key]*values
+	clock    func() time.Time
+	// overflow is called once per website, dimension and day, when the
+	// first value is bucketed
+	overflow func(websiteID uuid.UUID, dimension, day string, valueCap int)
+}
+
+// NewTracker returns a tracker allowing valueCap distinct values per
+// website, dimension and day; 0 or less disables the quota
+func NewTracker(valueCap int, overflow func(websiteID uuid.UUID, dimension, day string, valueCap int)) *Tracker {
+	return &Tracker{valueCap: valueCap, dims: map[key]*values{}, clock: time.Now, overflow: overflow}
+}
+
+// Limit returns value, or Other when it is new and the website already
+// recorded the cap of distinct values of the dimension today. Empty values
+// are returned as they are.
+func (t *Tracker) Limit(websiteID uuid.UUID, dimension, value string) string {
+	if t == nil || t.valueCap <= 0 || value == "" {
+		return value
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(value))
+	sum := h.Sum64()
+
+	t.mu.Lock()
+	day := t.clock().UTC().Format(time.DateOnly)
+	if day != t.day {
+		t.day, t.dims = day, map[key]*values{}
+	}
+	k := key{websiteID, dimension}
+	v := t.dims[k]
+	if v == nil {
+		v = &values{seen: map[uint64]struct{}{}}
+		t.dims[k] = v
+	}
+	if _, ok := v.seen[sum]; ok {
+		t.mu.Unlock()
+		return value
+	}
+	if len(v.seen) < t.valueCap {
+		v.seen[sum] = struct{}{}
+		t.mu.Unlock()
+		return value
+	}
+	first := !v.over
+	v.over = true
+	t.mu.Unlock()
+
+	if first {
+		logging.L().Warn("dimension over its cardinality cap, recording new values as (other)",
+			zap.String("website_id", websiteID.String()),
+			zap.String("dimension", dimension),
+			zap.Int("cap", t.valueCap),
+		)
+		if t.overflow != nil {
+			t.overflow(websiteID, dimension, day, t.valueCap)
+		}
+	}
+	return Other
+}
+
+// LimitPtr is Limit for optional values
+func (t *Tracker) LimitPtr(websiteID uuid.UUID, dimension string, value *string) *string {
+	if value == nil {
+		return nil
+	}
+	limited := t.Limit(websiteID, dimension, *value)
+	if limited == *value {
+		return value
+	}
+	return &limited
+}
+
+var (
+	currentMu sync.RWMutex
+	current   *Tracker
+)
+
+// Configure sets the process tracker; overflows are stored in db when it
+// isn't nil
+func Configure(valueCap int, db func() *sql.DB) {
+	tracker := NewTracker(valueCap, func(websiteID uuid.UUID, dimension, day string, valueCap int) {
+		conn := db()
+		if conn == nil {
+			return
+		}
+		// Off the ingestion path: a slow database mustn't hold up tracking
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := Record(ctx, conn, websiteID, dimension, day, valueCap); err != nil {
+				logging.L().Warn("failed to record cardinality overflow", zap.Error(err))
+			}
+		}()
+	})
+	currentMu.Lock()
+	current = tracker
+	currentMu.Unlock()
+}
+
+// Current returns the process tracker, nil until Configure is called
+func Current() *Tracker {
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	return current
+}
+
+// Overflow is a day a website's dimension went over the cap
+type Overflow struct {
+	Dimension string    `json:"dimension"`
+	Day       string    `json:"day"`
+	Cap       int       `json:"cap"`
+	FirstAt   time.Time `json:"first_at"`
+}
+
+// String describes the overflow for website issues
+func (o Overflow) String() string {
+	return fmt.Sprintf("%s: more than %d distinct values on %s; new values were recorded as %s",
+		o.Dimension, o.Cap, o.Day, Other)
+}
+
+// Record stores an overflow, keeping the first one of the day
+func Record(ctx context.Context, db *sql.DB, websiteID uuid.UUID, dimension, day string, valueCap int) error {
+	if _, err := db.ExecContext(ctx, `
+		INSERT INTO cardinality_overflow (website_id, dimension, day, value_cap)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (website_id, dimension, day) DO NOTHING
+	`, websiteID, dimension, day, valueCap); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx,
+		`DELETE FROM cardinality_overflow WHERE website_id = $1 AND day < CURRENT_DATE - $2::int`,
+		websiteID, keepDays)
+	return err
+}
+
+// Recent returns a website's overflows of the last days days, latest first
+func Recent(ctx context.Context, db *sql.DB, websiteID uuid.UUID, days int) ([]Overflow, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT dimension, day, value_cap, first_at
+		FROM cardinality_overflow
+		WHERE website_id = $1 AND day >= CURRENT_DATE - $2::int
+		ORDER BY day DESC, dimension
+	`, websiteID, days)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cardinality overflows: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var overflows []Overflow
+	for rows.Next() {
+		var o Overflow
+		var day time.Time
+		if err := rows.Scan(&o.Dimension, &day, &o.Cap, &o.FirstAt); err != nil {
+			return nil, fmt.Errorf("failed to read cardinality overflow: %w", err)
+		}
+		o.Day = day.Format(time.DateOnly)
+		overflows = append(overflows, o)
+	}
+	return overflows, rows.Err()
+}
@@ -0,0 +1,84 @@
+package cardinality
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimit(t *testing.T) {
+	type overflow struct {
+		websiteID uuid.UUID
+		dimension string
+		day       string
+	}
+	var overflows []overflow
+	tracker := NewTracker(2, func(websiteID uuid.UUID, dimension, day string, valueCap int) {
+		assert.Equal(t, 2, valueCap)
+		overflows = append(overflows, overflow{websiteID, dimension, day})
+	})
+	now := time.Date(2025, 6, 15, 23, 0, 0, 0, time.UTC)
+	tracker.clock = func() time.Time { return now }
+	site, other := uuid.New(), uuid.New()
+
+	assert.Equal(t, "/a", tracker.Limit(site, Page, "/a"))
+	assert.Equal(t, "/b", tracker.Limit(site, Page, "/b"))
+	assert.Equal(t, Other, tracker.Limit(site, Page, "/c"))
+	assert.Equal(t, Other, tracker.Limit(site, Page, "/d"))
+	// Values seen before the cap still count as themselves
+	assert.Equal(t, "/a", tracker.Limit(site, Page, "/a"))
+	assert.Equal(t, "", tracker.Limit(site, Page, ""))
+	// Other dimensions and websites have their own quota
+	assert.Equal(t, "google.com", tracker.Limit(site, Referrer, "google.com"))
+	assert.Equal(t, "/c", tracker.Limit(other, Page, "/c"))
+	assert.Equal(t, []overflow{{site, Page, "2025-06-15"}}, overflows)
+
+	// Counts start over at midnight UTC
+	now = now.Add(2 * time.Hour)
+	assert.Equal(t, "/c", tracker.Limit(site, Page, "/c"))
+}
+
+func TestLimitDisabled(t *testing.T) {
+	tracker := NewTracker(0, nil)
+	for i := 0; i < 100; i++ {
+		path := fmt.Sprintf("/%d", i)
+		assert.Equal(t, path, tracker.Limit(uuid.New(), Page, path))
+	}
+	var none *Tracker
+	assert.Equal(t, "/", none.Limit(uuid.New(), Page, "/"))
+}
+
+func TestLimitPtr(t *testing.T) {
+	tracker := NewTracker(1, nil)
+	site := uuid.New()
+	first, second := "a", "b"
+
+	assert.Nil(t, tracker.LimitPtr(site, UTMSource, nil))
+	assert.Same(t, &first, tracker.LimitPtr(site, UTMSource, &first))
+	assert.Equal(t, Other, *tracker.LimitPtr(site, UTMSource, &second))
+	assert.Equal(t, "b", second)
+}
+
+func TestRecent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	websiteID := uuid.New()
+
+	mock.ExpectQuery(`FROM cardinality_overflow`).WithArgs(websiteID, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"dimension", "day", "value_cap", "first_at"}).
+			AddRow(Page, time.Date(2025, 6, 15, 0, 0, 0, 0, time.UTC), 10000, time.Now()))
+
+	overflows, err := Recent(context.Background(), db, websiteID, 7)
+	require.NoError(t, err)
+	require.Len(t, overflows, 1)
+	assert.Equal(t, "page: more than 10000 distinct values on 2025-06-15; new values were recorded as (other)",
+		overflows[0].String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
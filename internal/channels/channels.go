@@ -0,0 +1,110 @@
+// Package channels sorts traffic into the channels it came through (Organic
+// Search, Social, Email, Paid, Referral or Direct) from an event's referrer,
+// its utm_source and utm_medium and the ad click IDs of its URL, with the
+// bundled lists of search engines, social networks and webmail hosts in
+// rules.go.
+//
+// A channel is decided once, when the event is recorded, so later changes
+// to the rules don't move stored traffic between channels.
+package channels
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Channels
+const (
+	OrganicSearch = "Organic Search"
+	Social        = "Social"
+	Email         = "Email"
+	Paid          = "Paid"
+	Referral      = "Referral"
+	Direct        = "Direct"
+)
+
+// All lists the channels, for help texts
+var All = []string{OrganicSearch, Social, Email, Paid, Referral, Direct}
+
+// Source is what an event says about where its visitor came from
+type Source struct {
+	// ReferrerDomain is the referrer's host, without www.
+	ReferrerDomain string
+	// UTMSource and UTMMedium are the page's utm_source and utm_medium
+	UTMSource string
+	UTMMedium string
+	// Query is the page URL's query, which may carry an ad click ID
+	Query url.Values
+}
+
+// Classify returns the channel of a source. Tagged campaigns are believed
+// over the referrer: a newsletter read in a webmail is Email, an ad on a
+// search engine Paid.
+func Classify(s Source) string {
+	medium := strings.ToLower(strings.TrimSpace(s.UTMMedium))
+	source := strings.ToLower(strings.TrimSpace(s.UTMSource))
+	referrer := strings.ToLower(strings.TrimSpace(s.ReferrerDomain))
+
+	switch {
+	case paidMediums[medium] || strings.HasPrefix(medium, "paid") || hasClickID(s.Query):
+		return Paid
+	case emailMediums[medium] || emailMediums[source] || matchSource(source, webmail) || matchHost(referrer, webmail):
+		return Email
+	case socialMediums[medium] || matchSource(source, socialNetworks) || matchHost(referrer, socialNetworks):
+		return Social
+	case medium == "organic" || matchSource(source, searchEngines) || matchHost(referrer, searchEngines):
+		return OrganicSearch
+	case referrer != "" || source != "" || medium != "":
+		return Referral
+	}
+	return Direct
+}
+
+// hasClickID reports whether a page URL carries the click ID an ad network
+// appends to its ads' links
+func hasClickID(query url.Values) bool {
+	for _, key := range clickIDs {
+		if query.Get(key) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSource reports whether a utm_source names one of the sites, by name
+// ("google", "fb") or by host
+func matchSource(source string, sites map[string]bool) bool {
+	if source == "" {
+		return false
+	}
+	return sites[source] || matchHost(source, sites)
+}
+
+// matchHost reports whether host belongs to one of the sites, named without
+// their top-level domain ("google" covers google.com, google.co.uk and
+// www.google.de) or in full (mastodon.social)
+func matchHost(host string, sites map[string]bool) bool {
+	labels := strings.Split(host, ".")
+	for end := len(labels); end > 0; end-- {
+		if end < len(labels) && !isSuffixLabel(labels[end]) {
+			break
+		}
+		for start := 0; start < end; start++ {
+			// A host's own top-level domain is no site (.info, .so)
+			if end == len(labels) && start == end-1 {
+				continue
+			}
+			if sites[strings.Join(labels[start:end], ".")] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isSuffixLabel reports whether a host label may be part of a public suffix
+// (com, co, uk, app): top-level domains and the second levels of countries
+// are at most 4 letters
+func isSuffixLabel(label string) bool {
+	return label != "" && len(label) <= 4
+}
@@ -0,0 +1,43 @@
+package channels
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name   string
+		source Source
+		want   string
+	}{
+		{"no referrer", Source{}, Direct},
+		{"search engine", Source{ReferrerDomain: "google.com"}, OrganicSearch},
+		{"country search engine", Source{ReferrerDomain: "google.co.uk"}, OrganicSearch},
+		{"search subdomain", Source{ReferrerDomain: "search.brave.com"}, OrganicSearch},
+		{"brave's own site", Source{ReferrerDomain: "brave.com"}, Referral},
+		{"social network", Source{ReferrerDomain: "t.co"}, Social},
+		{"social subdomain", Source{ReferrerDomain: "l.facebook.com"}, Social},
+		{"full host", Source{ReferrerDomain: "mastodon.social"}, Social},
+		{"webmail", Source{ReferrerDomain: "mail.google.com"}, Email},
+		{"other site", Source{ReferrerDomain: "blog.example.com"}, Referral},
+		{"top-level domain isn't a site", Source{ReferrerDomain: "example.info"}, Referral},
+		{"email medium", Source{UTMSource: "spring", UTMMedium: "Email"}, Email},
+		{"newsletter source", Source{UTMSource: "newsletter"}, Email},
+		{"social source name", Source{UTMSource: "fb"}, Social},
+		{"social medium", Source{UTMSource: "partner", UTMMedium: "social"}, Social},
+		{"search source", Source{UTMSource: "bing", UTMMedium: "organic"}, OrganicSearch},
+		{"paid medium over search referrer", Source{ReferrerDomain: "google.com", UTMSource: "google", UTMMedium: "cpc"}, Paid},
+		{"paid social", Source{UTMSource: "facebook", UTMMedium: "paid_social"}, Paid},
+		{"click ID", Source{ReferrerDomain: "google.com", Query: url.Values{"gclid": {"abc"}}}, Paid},
+		{"fbclid isn't an ad", Source{ReferrerDomain: "facebook.com", Query: url.Values{"fbclid": {"abc"}}}, Social},
+		{"unknown campaign", Source{UTMSource: "partner", UTMMedium: "affiliate"}, Referral},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Classify(tt.source))
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package channels
+
+// The bundled ruleset. Sites are named without their top-level domain (see
+// matchHost), or in full where the top-level domain is part of the name.
+
+// searchEngines are the search engines whose results count as Organic
+// Search
+var searchEngines = map[string]bool{
+	"google":       true,
+	"bing":         true,
+	"yahoo":        true,
+	"duckduckgo":   true,
+	"yandex":       true,
+	"ya":           true,
+	"baidu":        true,
+	"ecosia":       true,
+	"qwant":        true,
+	"startpage":    true,
+	"search.brave": true,
+	"kagi":         true,
+	"naver":        true,
+	"daum":         true,
+	"seznam":       true,
+	"ask":          true,
+	"aol":          true,
+	"sogou":        true,
+	"so":           true,
+	"mojeek":       true,
+	"presearch":    true,
+	"perplexity":   true,
+	"you":          true,
+	"searx":        true,
+	"metager":      true,
+	"search.lilo":  true,
+	"petalsearch":  true,
+	"go.mail":      true,
+	"coccoc":       true,
+	"yep":          true,
+	"swisscows":    true,
+	"info":         true,
+	"dogpile":      true,
+	"webcrawler":   true,
+}
+
+// socialNetworks are the social networks, by site and by the short names
+// campaigns use for them (fb, ig)
+var socialNetworks = map[string]bool{
+	"facebook":         true,
+	"fb":               true,
+	"messenger":        true,
+	"instagram":        true,
+	"ig":               true,
+	"threads":          true,
+	"twitter":          true,
+	"x":                true,
+	"t":                true,
+	"linkedin":         true,
+	"lnkd":             true,
+	"reddit":           true,
+	"pinterest":        true,
+	"pin":              true,
+	"tiktok":           true,
+	"youtube":          true,
+	"youtu":            true,
+	"snapchat":         true,
+	"tumblr":           true,
+	"quora":            true,
+	"vk":               true,
+	"ok":               true,
+	"weibo":            true,
+	"douyin":           true,
+	"xing":             true,
+	"discord":          true,
+	"telegram":         true,
+	"whatsapp":         true,
+	"wa":               true,
+	"bsky":             true,
+	"bluesky":          true,
+	"mastodon.social":  true,
+	"mastodon.online":  true,
+	"news.ycombinator": true,
+	"lobste.rs":        true,
+	"producthunt":      true,
+	"medium":           true,
+	"substack":         true,
+	"slack":            true,
+	"twitch":           true,
+	"line":             true,
+	"kakao":            true,
+	"nextdoor":         true,
+	"meetup":           true,
+}
+
+// webmail are the webmail apps whose links count as Email
+var webmail = map[string]bool{
+	"mail.google":       true,
+	"outlook.live":      true,
+	"outlook.office":    true,
+	"outlook.office365": true,
+	"mail.yahoo":        true,
+	"mail.aol":          true,
+	"mail.proton":       true,
+	"app.fastmail":      true,
+	"mail.zoho":         true,
+	"mail.yandex":       true,
+	"e.mail":            true,
+	"mail.gmx":          true,
+	"navigator.gmx":     true,
+	"web.de":            true,
+	"mail.tutanota":     true,
+	"app.tuta":          true,
+	"mail.hey":          true,
+	"webmail":           true,
+	"mailchimp":         true,
+	"list-manage":       true,
+	"sendgrid":          true,
+	"mail.icloud":       true,
+}
+
+// Mediums of the channels: utm_medium values (lower case) as campaign
+// tools and GA4's default channel grouping use them
+var (
+	paidMediums = map[string]bool{
+		"cpc": true, "ppc": true, "cpm": true, "cpv": true, "cpa": true, "cpp": true,
+		"display": true, "banner": true, "retargeting": true, "ads": true, "ad": true,
+	}
+	emailMediums = map[string]bool{
+		"email": true, "e-mail": true, "e_mail": true, "e mail": true, "newsletter": true,
+	}
+	socialMediums = map[string]bool{
+		"social": true, "social-network": true, "social-media": true, "sm": true,
+		"social network": true, "social media": true,
+	}
+)
+
+// clickIDs are the parameters ad networks add to the links of their ads
+// (fbclid is left out: Facebook adds it to every outbound link)
+var clickIDs = []string{"gclid", "gbraid", "wbraid", "dclid", "msclkid", "ttclid", "twclid", "li_fat_id", "epik", "yclid"}
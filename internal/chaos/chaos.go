@@ -0,0 +1,44 @@
+// Package chaos injects failures so the resilience features (batch write
+// retries, webhook job retries, slow lookups) can be exercised in
+// integration tests.
+//
+// Failure injection is only compiled in with -tags chaos. Other builds get
+// no-op hooks and Enabled is false, so release binaries can't be made to
+// fail on purpose. In chaos builds the failures are configured at runtime
+// through PUT /api/chaos and start disabled.
+package chaos
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInjected is the failure chaos hooks return
+var ErrInjected = errors.New("chaos: injected failure")
+
+// ErrDisabled is returned by Set in builds without -tags chaos
+var ErrDisabled = errors.New("failure injection is not compiled in (build with -tags chaos)")
+
+// Settings are the failures to inject
+type Settings struct {
+	// DropWrites is the percentage of ingest batch writes that fail
+	DropWrites int `json:"drop_writes_percent"`
+	// GeoIPLatencyMS is added to every GeoIP lookup
+	GeoIPLatencyMS int `json:"geoip_latency_ms"`
+	// FailWebhooks is the percentage of webhook deliveries that fail
+	FailWebhooks int `json:"fail_webhooks_percent"`
+}
+
+// Validate checks the percentages and latency are in range
+func (s Settings) Validate() error {
+	if s.DropWrites < 0 || s.DropWrites > 100 {
+		return fmt.Errorf("drop_writes_percent must be between 0 and 100")
+	}
+	if s.FailWebhooks < 0 || s.FailWebhooks > 100 {
+		return fmt.Errorf("fail_webhooks_percent must be between 0 and 100")
+	}
+	if s.GeoIPLatencyMS < 0 || s.GeoIPLatencyMS > 60000 {
+		return fmt.Errorf("geoip_latency_ms must be between 0 and 60000")
+	}
+	return nil
+}
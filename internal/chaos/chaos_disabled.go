@@ -0,0 +1,22 @@
+//go:build !chaos
+
+package chaos
+
+// Enabled reports whether failure injection is compiled in. Default builds
+// leave it out; build with -tags chaos for resilience tests.
+const Enabled = false
+
+// Get returns the failures being injected: none
+func Get() Settings { return Settings{} }
+
+// Set fails: there is nothing to inject failures with
+func Set(Settings) error { return ErrDisabled }
+
+// DropWrite never fails
+func DropWrite() error { return nil }
+
+// GeoIPDelay doesn't sleep
+func GeoIPDelay() {}
+
+// FailWebhook never fails
+func FailWebhook() error { return nil }
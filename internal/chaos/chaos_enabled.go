@@ -0,0 +1,63 @@
+//go:build chaos
+
+package chaos
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Enabled reports whether failure injection is compiled in
+const Enabled = true
+
+var (
+	mu       sync.RWMutex
+	settings Settings
+)
+
+// Get returns the failures being injected
+func Get() Settings {
+	mu.RLock()
+	defer mu.RUnlock()
+	return settings
+}
+
+// Set replaces the failures being injected; the zero Settings stops them
+func Set(s Settings) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	settings = s
+	return nil
+}
+
+// hit draws whether a failure injected percent of the time happens
+func hit(percent int) bool {
+	return percent > 0 && rand.IntN(100) < percent
+}
+
+// DropWrite fails DropWrites percent of ingest batch writes
+func DropWrite() error {
+	if hit(Get().DropWrites) {
+		return ErrInjected
+	}
+	return nil
+}
+
+// GeoIPDelay sleeps for GeoIPLatencyMS
+func GeoIPDelay() {
+	if ms := Get().GeoIPLatencyMS; ms > 0 {
+		time.Sleep(time.Duration(ms) * time.Millisecond)
+	}
+}
+
+// FailWebhook fails FailWebhooks percent of webhook deliveries
+func FailWebhook() error {
+	if hit(Get().FailWebhooks) {
+		return ErrInjected
+	}
+	return nil
+}
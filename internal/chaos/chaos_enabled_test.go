@@ -0,0 +1,28 @@
+//go:build chaos
+
+package chaos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInjectedFailures(t *testing.T) {
+	t.Cleanup(func() { _ = Set(Settings{}) })
+
+	assert.NoError(t, DropWrite())
+	assert.NoError(t, FailWebhook())
+
+	require.NoError(t, Set(Settings{DropWrites: 100, FailWebhooks: 100, GeoIPLatencyMS: 20}))
+	assert.ErrorIs(t, DropWrite(), ErrInjected)
+	assert.ErrorIs(t, FailWebhook(), ErrInjected)
+	start := time.Now()
+	GeoIPDelay()
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	assert.Error(t, Set(Settings{DropWrites: 150}))
+	assert.Equal(t, 100, Get().DropWrites, "invalid settings are not applied")
+}
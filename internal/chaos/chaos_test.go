@@ -0,0 +1,15 @@
+package chaos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettingsValidate(t *testing.T) {
+	assert.NoError(t, Settings{}.Validate())
+	assert.NoError(t, Settings{DropWrites: 100, FailWebhooks: 50, GeoIPLatencyMS: 250}.Validate())
+	assert.Error(t, Settings{DropWrites: 101}.Validate())
+	assert.Error(t, Settings{FailWebhooks: -1}.Validate())
+	assert.Error(t, Settings{GeoIPLatencyMS: -5}.Validate())
+}
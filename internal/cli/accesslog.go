@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/accesslog"
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/geoip"
+	"github.com/seuros/kaunta/internal/handlers"
+	"github.com/seuros/kaunta/internal/rollup"
+)
+
+// Access log import flags
+var (
+	accessLogWebsite string
+	accessLogFormat  string
+	accessLogDryRun  bool
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Backfill websites from other sources",
+}
+
+var importAccessLogCmd = &cobra.Command{
+	Use:   "accesslog --website <domain> [--format combined|caddy-json] <files...>",
+	Short: "Backfill pageviews from web server access logs",
+	Long: `Read Apache, nginx or Caddy access logs and record their page requests as
+pageviews of a website, for sites that never ran the tracker or for the time
+before they did.
+
+Formats:
+  combined    Apache and nginx combined log format (default)
+  caddy-json  Caddy's JSON access log; requests for other hosts are skipped
+
+Successful GETs of pages are kept; assets (scripts, styles, images, fonts),
+errors, redirects and known bots are skipped. Sessions are derived from IP
+and user agent, and a visit ends after 30 minutes without a request. Browser,
+OS, device and location come from the user agent and the GeoIP database.
+
+Give the files oldest first so visits continue across rotated logs; .gz files
+are decompressed and "-" reads stdin. Importing a log again adds nothing.
+The rollups of the imported days are refreshed afterwards.
+
+Examples:
+  kaunta import accesslog --website example.com /var/log/nginx/access.log.2.gz /var/log/nginx/access.log.1 /var/log/nginx/access.log
+  kaunta import accesslog --website example.com --format caddy-json --dry-run access.json`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runImportAccessLog(accessLogWebsite, accessLogFormat, args, accessLogDryRun)
+	},
+}
+
+func runImportAccessLog(domain, format string, files []string, dryRun bool) error {
+	if domain == "" {
+		return fmt.Errorf("--website is required")
+	}
+	if err := accesslog.ValidFormat(format); err != nil {
+		return err
+	}
+
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	website, err := GetWebsiteByDomain(ctx, domain, nil)
+	if err != nil {
+		return err
+	}
+	websiteID, err := uuid.Parse(website.WebsiteID)
+	if err != nil {
+		return err
+	}
+
+	initImportGeoIP()
+	im := &accesslog.Importer{
+		DB:        database.DB,
+		WebsiteID: websiteID,
+		Hostname:  strings.ToLower(website.Domain),
+		Format:    format,
+		UserAgent: handlers.ParseUserAgent,
+		Locate: func(ip string) (country, region, city string) {
+			country, city, region = geoip.LookupIP(ip)
+			return country, region, city
+		},
+		DryRun: dryRun,
+	}
+	for _, file := range files {
+		if err := importAccessLogFile(ctx, im, file); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	result := im.Finish()
+
+	fmt.Printf("Lines read:     %d (%d not in %s format)\n", result.Lines, result.Invalid, format)
+	fmt.Printf("Skipped:        %d (assets, errors, bots, other hosts)\n", result.Skipped)
+	fmt.Printf("Pageviews:      %d\n", result.Pageviews)
+	fmt.Printf("Sessions:       %d\n", result.Sessions)
+	fmt.Printf("Visits:         %d\n", result.Visits)
+	if len(result.Days) > 0 {
+		fmt.Printf("Days:           %s to %s\n",
+			result.Days[0].Format("2006-01-02"), result.Days[len(result.Days)-1].Format("2006-01-02"))
+	}
+	if dryRun {
+		fmt.Println("Dry run: nothing was written")
+		return nil
+	}
+
+	// Days in the website's time zone straddle UTC days, so neighbours are
+	// refreshed too
+	refreshed := map[string]bool{}
+	for _, day := range result.Days {
+		for _, d := range []int{-1, 0, 1} {
+			day := day.AddDate(0, 0, d)
+			if refreshed[day.Format("2006-01-02")] || day.After(rollup.Today()) {
+				continue
+			}
+			refreshed[day.Format("2006-01-02")] = true
+			if err := rollup.RefreshDay(ctx, database.DB, day); err != nil {
+				return fmt.Errorf("failed to refresh rollups of %s: %w", day.Format("2006-01-02"), err)
+			}
+		}
+	}
+	fmt.Printf("Imported %d pageviews into %s\n", result.Pageviews, website.Domain)
+	return nil
+}
+
+// importAccessLogFile imports one log file, "-" being stdin
+func importAccessLogFile(ctx context.Context, im *accesslog.Importer, file string) error {
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		r = f
+	}
+	if strings.HasSuffix(file, ".gz") {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = gz.Close() }()
+		r = gz
+	}
+	return im.Import(ctx, r)
+}
+
+// initImportGeoIP opens the GeoIP database the server uses; without it
+// locations are left unknown
+var initImportGeoIP = func() {
+	dataDir := "./data"
+	var options geoip.Options
+	if cfg, err := config.Load(); err == nil {
+		dataDir = cfg.DataDir
+		options = geoip.Options{
+			Provider:         cfg.GeoIPProvider,
+			LicenseKey:       cfg.MaxMindLicenseKey,
+			IP2LocationToken: cfg.IP2LocationToken,
+		}
+	}
+	_ = geoip.Init(dataDir, options)
+}
+
+func init() {
+	RootCmd.AddCommand(importCmd)
+	importCmd.AddCommand(importAccessLogCmd)
+	importAccessLogCmd.Flags().StringVar(&accessLogWebsite, "website", "", "Domain of the website to import into (required)")
+	importAccessLogCmd.Flags().StringVar(&accessLogFormat, "format", accesslog.FormatCombined, "Log format (combined, caddy-json)")
+	importAccessLogCmd.Flags().BoolVar(&accessLogDryRun, "dry-run", false, "Read the logs and report without writing")
+}
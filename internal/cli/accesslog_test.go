@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunImportAccessLogDryRun(t *testing.T) {
+	mock := mockJobsDB(t)
+	original := initImportGeoIP
+	initImportGeoIP = func() {}
+	t.Cleanup(func() { initImportGeoIP = original })
+
+	dir := t.TempDir()
+	ua := `"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_5) AppleWebKit/605.1.15 Safari/605.1.15"`
+	older := filepath.Join(dir, "access.log.1.gz")
+	f, err := os.Create(older)
+	require.NoError(t, err)
+	gz := gzip.NewWriter(f)
+	_, err = gz.Write([]byte(`203.0.113.7 - - [14/Jun/2025:23:50:00 +0000] "GET / HTTP/1.1" 200 100 "-" ` + ua + "\n"))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	require.NoError(t, f.Close())
+	current := filepath.Join(dir, "access.log")
+	require.NoError(t, os.WriteFile(current, []byte(
+		`203.0.113.7 - - [15/Jun/2025:00:05:00 +0000] "GET /about HTTP/1.1" 200 100 "-" `+ua+"\n"+
+			`203.0.113.7 - - [15/Jun/2025:00:05:01 +0000] "GET /logo.png HTTP/1.1" 200 100 "-" `+ua+"\n"), 0o644))
+
+	expectWebsiteLookup(mock, uuid.New(), "example.com")
+
+	output, err := captureOutput(t, func() error {
+		return runImportAccessLog("example.com", "combined", []string{older, current}, true)
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "Pageviews:      2")
+	assert.Contains(t, output, "Visits:         1")
+	assert.Contains(t, output, "Days:           2025-06-14 to 2025-06-15")
+	assert.Contains(t, output, "Dry run: nothing was written")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunImportAccessLogValidates(t *testing.T) {
+	assert.ErrorContains(t, runImportAccessLog("", "combined", []string{"access.log"}, false), "--website is required")
+	assert.ErrorContains(t, runImportAccessLog("example.com", "w3c", []string{"access.log"}, false), "unknown format")
+}
@@ -0,0 +1,259 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/alerts"
+	"github.com/seuros/kaunta/internal/database"
+)
+
+// Alert command flags
+var (
+	alertMetric     string
+	alertCondition  string
+	alertThreshold  float64
+	alertWindow     string
+	alertNotify     string
+	alertDisabled   bool
+	alertListFormat string
+)
+
+var alertCmd = &cobra.Command{
+	Use:   "alert",
+	Short: "Manage traffic alert rules",
+	Long: `Alert rules watch a website's traffic and notify a webhook, Slack, Discord
+or email when they start firing and when they resolve. The "alerts" worker
+task evaluates them every 5 minutes; notifications are delivered by the job
+queue.`,
+}
+
+var alertAddCmd = &cobra.Command{
+	Use:   "add <domain> <name> --metric <m> --when <condition> --threshold <n> --window <w> --notify <channel:target>",
+	Short: "Add or replace an alert rule",
+	Long: `Add an alert rule to a website, replacing its rule of the same name.
+
+Options:
+  --metric      pageviews, visitors or goal:<event name> (default pageviews)
+  --when        drop or rise: change from the window before, in percent
+                below or above: the window's total
+  --threshold   Percentage (drop, rise) or total (below, above)
+  --window      Period the metric is counted over: 5m to 7d (default 1h)
+  --notify      webhook:<url>, slack:<incoming webhook url>, discord:<webhook url>
+                or email:<address>; a bare slack or discord posts to the
+                website's webhook (see 'kaunta website notify')
+  --disabled    Save the rule without evaluating it
+
+Drop and rise rules need at least 10 events in the window before.
+Email needs smtp_host and smtp_from (SMTP_HOST, SMTP_FROM, ...).
+
+Examples:
+  kaunta alert add example.com traffic-drop --when drop --threshold 50 --window 1h \
+    --notify slack:https://hooks.slack.com/services/T000/B000/XXXX
+  kaunta alert add example.com no-signups --metric goal:signup --when below --threshold 1 \
+    --window 24h --notify email:ops@example.com`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runAlertAdd(args[0], args[1], alertMetric, alertCondition, alertThreshold, alertWindow, alertNotify, !alertDisabled)
+	},
+}
+
+var alertListCmd = &cobra.Command{
+	Use:   "list [domain] [--format json|table]",
+	Short: "List alert rules and their state",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		domain := ""
+		if len(args) == 1 {
+			domain = args[0]
+		}
+		return runAlertList(domain, alertListFormat)
+	},
+}
+
+var alertRemoveCmd = &cobra.Command{
+	Use:   "remove <domain> <name>",
+	Short: "Delete an alert rule",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runAlertRemove(args[0], args[1])
+	},
+}
+
+var alertCheckCmd = &cobra.Command{
+	Use:   "check <domain>",
+	Short: "Evaluate a website's alert rules now, without notifying",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runAlertCheck(args[0])
+	},
+}
+
+func runAlertAdd(domain, name, metric, condition string, threshold float64, window, notify string, enabled bool) error {
+	rule := &alerts.Rule{Name: name, Metric: metric, Condition: condition, Threshold: threshold, Enabled: enabled}
+	var err error
+	if rule.Window, err = alerts.ParseWindow(window); err != nil {
+		return err
+	}
+	if rule.Channel, rule.Target, err = alerts.ParseNotify(notify); err != nil {
+		return err
+	}
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		rule.WebsiteID = websiteID
+		if err := alerts.Save(ctx, database.DB, rule); err != nil {
+			return err
+		}
+		fmt.Printf("Alert rule %q saved for %s\n", name, domain)
+		return nil
+	})
+}
+
+func runAlertList(domain, format string) error {
+	if format != "table" && format != "json" {
+		return fmt.Errorf("invalid format: %s (use json or table)", format)
+	}
+
+	list := func(ctx context.Context, websiteID uuid.UUID) error {
+		rules, err := alerts.List(ctx, database.DB, websiteID)
+		if err != nil {
+			return err
+		}
+		if format == "json" {
+			if rules == nil {
+				rules = []*alerts.Rule{}
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(rules)
+		}
+
+		if len(rules) == 0 {
+			fmt.Println("No alert rules")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "WEBSITE\tNAME\tRULE\tNOTIFY\tSTATE\tLAST VALUE")
+		_, _ = fmt.Fprintln(w, "-------\t----\t----\t------\t-----\t----------")
+		for _, r := range rules {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.Domain, r.Name, describeRule(r),
+				r.Channel+":"+r.Target, ruleState(r), lastValue(r))
+		}
+		return w.Flush()
+	}
+
+	if domain == "" {
+		done, err := ensureDatabase()
+		if err != nil {
+			return err
+		}
+		defer done()
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		return list(ctx, uuid.Nil)
+	}
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		return list(ctx, websiteID)
+	})
+}
+
+func runAlertRemove(domain, name string) error {
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		err := alerts.Remove(ctx, database.DB, websiteID, name)
+		if errors.Is(err, alerts.ErrNotFound) {
+			return fmt.Errorf("no alert rule %q for %s", name, domain)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Alert rule %q removed from %s\n", name, domain)
+		return nil
+	})
+}
+
+func runAlertCheck(domain string) error {
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		rules, err := alerts.List(ctx, database.DB, websiteID)
+		if err != nil {
+			return err
+		}
+		if len(rules) == 0 {
+			fmt.Printf("No alert rules for %s\n", domain)
+			return nil
+		}
+
+		now := time.Now()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "NAME\tRULE\tVALUE\tBEFORE\tRESULT")
+		_, _ = fmt.Fprintln(w, "----\t----\t-----\t------\t------")
+		for _, r := range rules {
+			value, previous, err := alerts.Measure(ctx, database.DB, r, now)
+			if err != nil {
+				return err
+			}
+			result := "ok"
+			if alerts.Evaluate(r, value, previous).Firing {
+				result = "FIRING"
+			}
+			if !r.Enabled {
+				result += " (disabled)"
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%.0f\t%.0f\t%s\n", r.Name, describeRule(r), value, previous, result)
+		}
+		return w.Flush()
+	})
+}
+
+// describeRule writes a rule's condition, e.g. "pageviews drop 50% / 1h"
+func describeRule(r *alerts.Rule) string {
+	threshold := fmt.Sprintf("%g", r.Threshold)
+	if r.Condition == "drop" || r.Condition == "rise" {
+		threshold += "%"
+	}
+	return strings.Join([]string{r.Metric, r.Condition, threshold, "/", r.WindowLabel()}, " ")
+}
+
+func ruleState(r *alerts.Rule) string {
+	switch {
+	case !r.Enabled:
+		return "disabled"
+	case r.Firing:
+		return "FIRING"
+	case r.LastCheckedAt == nil:
+		return "pending"
+	}
+	return "ok"
+}
+
+func lastValue(r *alerts.Rule) string {
+	if r.LastValue == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%g", *r.LastValue)
+}
+
+func init() {
+	RootCmd.AddCommand(alertCmd)
+	alertCmd.AddCommand(alertAddCmd, alertListCmd, alertRemoveCmd, alertCheckCmd)
+
+	alertAddCmd.Flags().StringVar(&alertMetric, "metric", "pageviews", "pageviews, visitors or goal:<event name>")
+	alertAddCmd.Flags().StringVar(&alertCondition, "when", "", "drop, rise, below or above")
+	alertAddCmd.Flags().Float64Var(&alertThreshold, "threshold", 0, "Percentage (drop, rise) or total (below, above)")
+	alertAddCmd.Flags().StringVar(&alertWindow, "window", "1h", "Period the metric is counted over")
+	alertAddCmd.Flags().StringVar(&alertNotify, "notify", "", "webhook:<url>, slack[:<url>], discord[:<url>] or email:<address>")
+	alertAddCmd.Flags().BoolVar(&alertDisabled, "disabled", false, "Save the rule without evaluating it")
+
+	alertListCmd.Flags().StringVar(&alertListFormat, "format", "table", "Output format: json, table")
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunAlertAdd(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectQuery(`INSERT INTO alert_rule`).
+		WithArgs(websiteID, "no-signups", "goal:signup", "below", 1.0, 1440, "email", "ops@example.com", true).
+		WillReturnRows(sqlmock.NewRows([]string{"rule_id", "created_at"}).AddRow(uuid.New(), time.Now()))
+
+	output, err := captureOutput(t, func() error {
+		return runAlertAdd("example.com", "no-signups", "goal:signup", "below", 1, "1d", "email:ops@example.com", true)
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, `Alert rule "no-signups" saved for example.com`)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunAlertAddInvalid(t *testing.T) {
+	err := runAlertAdd("example.com", "drop", "pageviews", "drop", 50, "1h", "pager", true)
+	assert.ErrorContains(t, err, "invalid notification target")
+
+	err = runAlertAdd("example.com", "drop", "pageviews", "drop", 50, "1h", "sms:+15550100", true)
+	assert.ErrorContains(t, err, "invalid channel")
+
+	err = runAlertAdd("example.com", "drop", "pageviews", "", 50, "1h", "slack:https://hooks.slack.com/x", true)
+	assert.ErrorContains(t, err, "invalid condition")
+}
+
+func TestRunAlertList(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+	value := 42.0
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectQuery(`FROM alert_rule r.*AND r.website_id = \$1`).WithArgs(websiteID).
+		WillReturnRows(sqlmock.NewRows([]string{"rule_id", "website_id", "domain", "name", "metric", "condition", "threshold",
+			"window_minutes", "channel", "target", "enabled", "firing", "last_value", "last_checked_at", "last_fired_at", "created_at"}).
+			AddRow(uuid.New(), websiteID, "example.com", "traffic-drop", "pageviews", "drop", 50.0,
+				60, "slack", "https://hooks.slack.com/x", true, true, value, time.Now(), time.Now(), time.Now()))
+
+	output, err := captureOutput(t, func() error {
+		return runAlertList("example.com", "table")
+	})
+	require.NoError(t, err)
+	assert.Regexp(t, `traffic-drop\s+pageviews drop 50% / 1h\s+slack:https://hooks.slack.com/x\s+FIRING\s+42`, output)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -5,7 +5,9 @@ import (
 	"database/sql"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"os/signal"
 	"strings"
@@ -14,50 +16,25 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/seuros/kaunta/internal/blockers"
 	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/dimensions"
+	"github.com/seuros/kaunta/internal/realtime"
+	"github.com/seuros/kaunta/internal/segments"
+	"github.com/seuros/kaunta/internal/stats"
+	"github.com/seuros/kaunta/internal/store"
 	"github.com/spf13/cobra"
 )
 
-// Data structures for analytics
-
-type OverviewStats struct {
-	TotalVisitors       int64            `json:"total_visitors"`
-	TotalPageviews      int64            `json:"total_pageviews"`
-	TopPage             *PageStat        `json:"top_page,omitempty"`
-	TopReferrer         *ReferrerStat    `json:"top_referrer,omitempty"`
-	BrowserDistribution map[string]int64 `json:"browser_distribution"`
-	DeviceDistribution  map[string]int64 `json:"device_distribution"`
-	CountryDistribution map[string]int64 `json:"country_distribution"`
-	AvgEngagement       float64          `json:"avg_engagement_seconds"`
-}
-
-type PageStat struct {
-	Path           string  `json:"path"`
-	Pageviews      int64   `json:"pageviews"`
-	UniqueVisitors int64   `json:"unique_visitors"`
-	BounceRate     float64 `json:"bounce_rate"`
-	AvgTime        float64 `json:"avg_time_seconds"`
-}
-
-type ReferrerStat struct {
-	Domain    string `json:"domain"`
-	Visitors  int64  `json:"visitors"`
-	Pageviews int64  `json:"pageviews"`
-}
-
-type BreakdownStat struct {
-	Dimension string                   `json:"dimension"`
-	Items     []map[string]interface{} `json:"items"`
-}
+// Data structures for analytics, shared with the dashboard
 
-type LiveStatsData struct {
-	Timestamp           time.Time                `json:"timestamp"`
-	ActiveVisitorsNow   int64                    `json:"active_visitors_now"`
-	PageviewsLastMinute int64                    `json:"pageviews_last_minute"`
-	TopPageNow          *PageStat                `json:"top_page_now,omitempty"`
-	RecentReferrers     []map[string]interface{} `json:"recent_referrers,omitempty"`
-	RecentEvents        int64                    `json:"recent_events"`
-}
+type (
+	OverviewStats = stats.Overview
+	PageStat      = stats.Page
+	ReferrerStat  = stats.Referrer
+	BreakdownStat = stats.Breakdown
+	LiveStatsData = stats.Live
+)
 
 // Stats command structure
 var statsCmd = &cobra.Command{
@@ -76,7 +53,9 @@ var (
 	getOverviewStats       = GetOverviewStats
 	getTopPagesFn          = GetTopPages
 	getBreakdownStatsFn    = GetBreakdownStats
+	getSegmentFiltersFn    = GetSegmentFilters
 	getLiveStatsFn         = GetLiveStats
+	getBlockerCorrectionFn = GetBlockerCorrection
 	tickerFactory          = func(d time.Duration) (<-chan time.Time, func()) {
 		ticker := time.NewTicker(d)
 		return ticker.C, ticker.Stop
@@ -84,16 +63,27 @@ var (
 	signalNotifyFunc = func(c chan<- os.Signal, sig ...os.Signal) {
 		signal.Notify(c, sig...)
 	}
+	liveEventsFn = func(ctx context.Context, websiteID string) (<-chan realtime.EventPayload, error) {
+		return realtime.Listen(ctx, os.Getenv("DATABASE_URL"), websiteID)
+	}
 )
 
+// liveIdleRefresh is how often `stats live` refreshes without new events, as
+// visitors leave the five-minute window
+const liveIdleRefresh = time.Minute
+
 // Overview command flags
 var (
-	overviewDays   int
-	overviewFormat string
+	overviewDays          int
+	overviewFormat        string
+	overviewAdjustBlocked bool
+	overviewSegment       string
+	overviewCompare       bool
+	overviewRange         dateRange
 )
 
 var statsOverviewCmd = &cobra.Command{
-	Use:   "overview <website-domain> [--days <N>] [--format json|table|text]",
+	Use:   "overview <website-domain> [--days <N> | --from <date> [--to <date>]] [--segment <name>] [--compare] [--format json|table|text]",
 	Short: "Show analytics overview dashboard",
 	Long: `Display a quick overview/dashboard for a website with key metrics.
 
@@ -108,23 +98,34 @@ Shows:
   - Average Engagement (in seconds)
 
 Options:
-  --days N     Time period in days (1-365, default 7)
-  --format     Output format: json, table, text (default table)`,
+  --days N           Time period in days (1-365, default 7)
+  --from, --to       Date range instead, YYYY-MM-DD with both days
+                     included (--to defaults to today, up to 366 days)
+  --tz ZONE          Time zone of the range's days (default UTC)
+  --segment NAME     Only count the events of a saved segment
+                     (see 'kaunta segment')
+  --format           Output format: json, table, text (default table)
+  --compare          Also compare visitors, pageviews, bounce rate and
+                     engagement with the previous N days
+  --adjust-blocked   Also estimate visitors including those blocking the
+                     tracker (see 'kaunta stats blockers')`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runStatsOverview(args[0], overviewDays, overviewFormat)
+		return runStatsOverview(args[0], overviewDays, overviewRange, overviewSegment, overviewFormat, overviewAdjustBlocked, overviewCompare)
 	},
 }
 
 // Pages command flags
 var (
-	pagesDays   int
-	pagesTop    int
-	pagesFormat string
+	pagesDays    int
+	pagesTop     int
+	pagesFormat  string
+	pagesSegment string
+	pagesRange   dateRange
 )
 
 var statsPagesCmd = &cobra.Command{
-	Use:   "pages <website-domain> [--days <N>] [--top <N>] [--format json|table|csv]",
+	Use:   "pages <website-domain> [--days <N> | --from <date> [--to <date>]] [--top <N>] [--segment <name>] [--format json|table|csv]",
 	Short: "Show top pages by pageview count",
 	Long: `Display top pages sorted by pageview count.
 
@@ -132,11 +133,16 @@ Columns: URL Path, Pageviews, Unique Visitors, Bounce Rate, Avg Time
 
 Options:
   --days N      Time period in days (1-365, default 7)
+  --from, --to  Date range instead, YYYY-MM-DD with both days included
+                (--to defaults to today, up to 366 days)
+  --tz ZONE     Time zone of the range's days (default UTC)
   --top N       Number of pages to show (1-100, default 10)
+  --segment     Only count the events of a saved segment (its page
+                filter doesn't apply, as on the dashboard)
   --format      Output format: json, table, csv (default table)`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runStatsPages(args[0], pagesDays, pagesTop, pagesFormat)
+		return runStatsPages(args[0], pagesDays, pagesRange, pagesTop, pagesSegment, pagesFormat)
 	},
 }
 
@@ -146,32 +152,55 @@ var (
 	breakdownDays      int
 	breakdownTop       int
 	breakdownFormat    string
+	breakdownSegment   string
+	breakdownRange     dateRange
 )
 
 var statsBreakdownCmd = &cobra.Command{
-	Use:   "breakdown <website-domain> --by <dimension> [--days <N>] [--top <N>] [--format json|table|csv]",
+	Use:   "breakdown <website-domain> --by <dimension> [--days <N> | --from <date> [--to <date>]] [--top <N>] [--segment <name>] [--format json|table|csv]",
 	Short: "Show metrics breakdown by dimension",
 	Long: `Display metrics broken down by a specific dimension.
 
 Valid dimensions:
-  country  - Country Name, Visitors, Pageviews, Bounce Rate
-  browser  - Browser, Visitors, Pageviews, Bounce Rate
-  device   - Device Type, Visitors, Pageviews, Bounce Rate
-  referrer - Referrer Domain, Visitors, Pageviews, Bounce Rate
-  os       - OS, Visitors, Pageviews, Bounce Rate
+  country       - Country Name, Visitors, Pageviews, Bounce Rate
+  browser       - Browser, Visitors, Pageviews, Bounce Rate
+  device        - Device Type, Visitors, Pageviews, Bounce Rate
+  referrer      - Referrer Domain, Visitors, Pageviews, Bounce Rate
+  os            - OS, Visitors, Pageviews, Bounce Rate
+  city          - City, Region, Country, Visitors, Pageviews, Bounce Rate
+  region        - Region, Country, Visitors, Pageviews, Bounce Rate
+  author        - Author, Visitors, Pageviews, Bounce Rate
+  asn           - Network (AS number and ISP), Visitors, Pageviews, Bounce Rate
+                  (needs an ASN database, see GeoIP in the README)
+  screen        - Screen Width Range, Visitors, Pageviews, Bounce Rate
+  viewport      - Mobile, Tablet, Desktop or Wide, Visitors, Pageviews, Bounce Rate
+  content-group - Content Group, Visitors, Pageviews, Bounce Rate
+                  (rules from 'kaunta website content-group')
+
+Custom dimensions registered with 'kaunta website dimension add' can be
+used by name.
 
 Options:
   --by          Dimension to break down by (required)
   --days N      Time period in days (1-365, default 7)
+  --from, --to  Date range instead, YYYY-MM-DD with both days included
+                (--to defaults to today, up to 366 days)
+  --tz ZONE     Time zone of the range's days (default UTC)
   --top N       Number of items to show (1-100, default 10)
+  --segment     Only count the events of a saved segment (its filter on
+                the dimension itself doesn't apply, as on the dashboard)
   --format      Output format: json, table, csv (default table)
 
 Examples:
   kaunta stats breakdown mysite.com --by country
-  kaunta stats breakdown mysite.com --by browser --top 5 --days 30`,
+  kaunta stats breakdown mysite.com --by browser --top 5 --days 30
+  kaunta stats breakdown mysite.com --by city --top 20
+  kaunta stats breakdown mysite.com --by referrer --from 2025-06-01 --to 2025-06-30
+  kaunta stats breakdown mysite.com --by content_type --days 30
+  kaunta stats breakdown mysite.com --by browser --segment german-mobile`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runStatsBreakdown(args[0], breakdownDimension, breakdownDays, breakdownTop, breakdownFormat)
+		return runStatsBreakdown(args[0], breakdownDimension, breakdownDays, breakdownRange, breakdownTop, breakdownSegment, breakdownFormat)
 	},
 }
 
@@ -182,9 +211,10 @@ var (
 )
 
 var statsLiveCmd = &cobra.Command{
-	Use:   "live <website-domain> [--interval <seconds>] [--format json|text]",
+	Use:   "live <website-domain> [--interval <seconds>] [--format json|text] [--follow [--server <url>]]",
 	Short: "Real-time streaming stats",
-	Long: `Display real-time streaming statistics that update every N seconds.
+	Long: `Display real-time streaming statistics, refreshed as the website's events
+arrive (at most every N seconds) and at least once a minute.
 
 Shows:
   - Active visitors (last 5 minutes)
@@ -194,22 +224,72 @@ Shows:
   - Recent events
 
 Options:
-  --interval N  Update interval in seconds (2-60, default 5)
+  --interval N  Minimum seconds between updates (2-60, default 5)
   --format      Output format: json, text (default text)
+  --follow      Stream from a running server instead of the database
+  --server URL  Server for --follow (default $KAUNTA_SERVER, else the local one)
+  --token       API token for --follow (default $KAUNTA_API_TOKEN)
 
 Press Ctrl+C to stop.`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if liveFollow {
+			return runStatsLiveFollow(args[0], liveServer, liveToken, liveFormat)
+		}
 		return runStatsLive(args[0], liveInterval, liveFormat)
 	},
 }
 
+// dateRange is the --from, --to and --tz flags of the stats commands
+type dateRange struct {
+	From string
+	To   string
+	TZ   string
+}
+
+// period parses the range; nil when neither --from nor --to is set
+func (r dateRange) period() (*stats.Period, error) {
+	if r.From == "" && r.To == "" {
+		return nil, nil
+	}
+	period, err := stats.ParseRange(r.From, r.To, r.TZ, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &period, nil
+}
+
+// addRangeFlags registers the dateRange flags of a stats command
+func addRangeFlags(cmd *cobra.Command, r *dateRange) {
+	cmd.Flags().StringVar(&r.From, "from", "", "First day of a date range, YYYY-MM-DD (replaces --days)")
+	cmd.Flags().StringVar(&r.To, "to", "", "Last day of the date range, YYYY-MM-DD (default today)")
+	cmd.Flags().StringVar(&r.TZ, "tz", "", "Time zone of the range's days, e.g. Europe/Berlin (default UTC)")
+}
+
+// periodLabel names the period of a report
+func periodLabel(days int, period *stats.Period) string {
+	if period != nil {
+		if outputLocale == plainLocale {
+			return period.Label
+		}
+		return outputLocale.Date(period.From) + " – " + outputLocale.Date(period.To.AddDate(0, 0, -1))
+	}
+	return fmt.Sprintf("last %d days", days)
+}
+
 // Command implementations
 
-func runStatsOverview(domain string, days int, format string) error {
-	if days < 1 || days > 365 {
+func runStatsOverview(domain string, days int, r dateRange, segment string, format string, adjustBlocked, compare bool) error {
+	period, err := r.period()
+	if err != nil {
+		return err
+	}
+	if period == nil && (days < 1 || days > 365) {
 		return fmt.Errorf("days must be between 1 and 365")
 	}
+	if period != nil && compare {
+		return fmt.Errorf("--compare works with --days, not with a date range")
+	}
 
 	if format == "" {
 		format = "table"
@@ -231,25 +311,47 @@ func runStatsOverview(domain string, days int, format string) error {
 		return err
 	}
 
-	stats, err := getOverviewStats(ctx, database.DB, websiteID, days)
+	filters, err := getSegmentFiltersFn(ctx, database.DB, websiteID, segment)
+	if err != nil {
+		return err
+	}
+	label := periodLabel(days, period)
+	if period != nil {
+		days, filters = period.Days(time.Now()), period.Narrow(filters)
+	}
+
+	stats, err := getOverviewStats(ctx, database.DB, websiteID, days, filters, compare)
 	if err != nil {
 		return err
 	}
 
+	if adjustBlocked {
+		correction, err := getBlockerCorrectionFn(ctx, database.DB, websiteID, days)
+		if err != nil {
+			return err
+		}
+		stats.BlockerCorrection = correction
+		stats.AdjustedVisitors = int64(math.Round(float64(stats.TotalVisitors) * correction))
+	}
+
 	switch format {
 	case "json":
 		return outputOverviewJSON(stats)
 	case "text":
-		return outputOverviewText(stats, domain, days)
+		return outputOverviewText(stats, domain, label, days)
 	case "table":
-		return outputOverviewTable(stats, domain, days)
+		return outputOverviewTable(stats, domain, label, days)
 	default:
 		return fmt.Errorf("invalid format: %s (use json, table, or text)", format)
 	}
 }
 
-func runStatsPages(domain string, days int, top int, format string) error {
-	if days < 1 || days > 365 {
+func runStatsPages(domain string, days int, r dateRange, top int, segment string, format string) error {
+	period, err := r.period()
+	if err != nil {
+		return err
+	}
+	if period == nil && (days < 1 || days > 365) {
 		return fmt.Errorf("days must be between 1 and 365")
 	}
 
@@ -276,7 +378,15 @@ func runStatsPages(domain string, days int, top int, format string) error {
 		return err
 	}
 
-	pages, err := getTopPagesFn(ctx, database.DB, websiteID, days, top)
+	filters, err := getSegmentFiltersFn(ctx, database.DB, websiteID, segment)
+	if err != nil {
+		return err
+	}
+	if period != nil {
+		days, filters = period.Days(time.Now()), period.Narrow(filters)
+	}
+
+	pages, err := getTopPagesFn(ctx, database.DB, websiteID, days, top, filters)
 	if err != nil {
 		return err
 	}
@@ -293,24 +403,22 @@ func runStatsPages(domain string, days int, top int, format string) error {
 	}
 }
 
-func runStatsBreakdown(domain string, dimension string, days int, top int, format string) error {
+func runStatsBreakdown(domain string, dimension string, days int, r dateRange, top int, segment string, format string) error {
 	if dimension == "" {
-		return fmt.Errorf("--by dimension is required (valid: country, browser, device, referrer, os)")
+		return fmt.Errorf("--by dimension is required (valid: %s)", strings.Join(stats.Names(), ", "))
 	}
 
-	validDimensions := map[string]bool{
-		"country":  true,
-		"browser":  true,
-		"device":   true,
-		"referrer": true,
-		"os":       true,
+	// Other names must be custom dimensions; GetBreakdownStats checks that
+	// they are registered
+	if _, builtin := stats.Lookup(dimension); !builtin && dimension != "content-group" && dimensions.ValidName(dimension) != nil {
+		return fmt.Errorf("invalid dimension: %s (valid: %s or a custom dimension)", dimension, strings.Join(stats.Names(), ", "))
 	}
 
-	if !validDimensions[dimension] {
-		return fmt.Errorf("invalid dimension: %s (valid: country, browser, device, referrer, os)", dimension)
+	period, err := r.period()
+	if err != nil {
+		return err
 	}
-
-	if days < 1 || days > 365 {
+	if period == nil && (days < 1 || days > 365) {
 		return fmt.Errorf("days must be between 1 and 365")
 	}
 
@@ -337,7 +445,15 @@ func runStatsBreakdown(domain string, dimension string, days int, top int, forma
 		return err
 	}
 
-	stats, err := getBreakdownStatsFn(ctx, database.DB, websiteID, dimension, days, top)
+	filters, err := getSegmentFiltersFn(ctx, database.DB, websiteID, segment)
+	if err != nil {
+		return err
+	}
+	if period != nil {
+		days, filters = period.Days(time.Now()), period.Narrow(filters)
+	}
+
+	stats, err := getBreakdownStatsFn(ctx, database.DB, websiteID, dimension, days, top, filters)
 	if err != nil {
 		return err
 	}
@@ -385,7 +501,17 @@ func runStatsLive(domain string, interval int, format string) error {
 	tickCh, stopTicker := tickerFactory(time.Duration(interval) * time.Second)
 	defer stopTicker()
 
-	fmt.Printf("Live stats for %s (updating every %d seconds, press Ctrl+C to exit)\n\n", domain, interval)
+	// Following the website's events spares the database a query per tick;
+	// without them, every tick refreshes
+	events, err := liveEventsFn(ctx, websiteID)
+	if err != nil {
+		events = nil
+		fmt.Printf("Live stats for %s (updating every %d seconds, press Ctrl+C to exit)\n\n", domain, interval)
+	} else {
+		fmt.Printf("Live stats for %s (updating on new events, at most every %d seconds, press Ctrl+C to exit)\n\n", domain, interval)
+	}
+	pending := events == nil
+	refreshed := time.Now()
 
 	// Display initial stats
 	liveData, _ := getLiveStatsFn(ctx, database.DB, websiteID)
@@ -400,7 +526,18 @@ func runStatsLive(domain string, interval int, format string) error {
 		case <-sigChan:
 			fmt.Println("\n\nExiting live stats...")
 			return nil
-		case <-tickCh:
+		case _, ok := <-events:
+			if !ok {
+				events = nil
+			}
+			pending = true
+		case now := <-tickCh:
+			if !pending && now.Sub(refreshed) < liveIdleRefresh {
+				continue
+			}
+			pending = events == nil
+			refreshed = now
+
 			liveData, err := getLiveStatsFn(ctx, database.DB, websiteID)
 			if err != nil {
 				fmt.Printf("Error fetching live stats: %v\n", err)
@@ -431,622 +568,80 @@ func GetWebsiteIDByDomain(ctx context.Context, domain string) (string, error) {
 	return websiteID, nil
 }
 
-func GetOverviewStats(ctx context.Context, db *sql.DB, websiteID string, days int) (*OverviewStats, error) {
-	stats := &OverviewStats{
-		BrowserDistribution: make(map[string]int64),
-		DeviceDistribution:  make(map[string]int64),
-		CountryDistribution: make(map[string]int64),
-	}
-
-	// Parse UUID
-	parsedID, err := uuid.Parse(websiteID)
+// GetBlockerCorrection returns the baseline pixel correction for a website,
+// 1 when there isn't enough pixel data
+func GetBlockerCorrection(ctx context.Context, db *sql.DB, websiteID string, days int) (float64, error) {
+	id, err := uuid.Parse(websiteID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid website ID: %w", err)
-	}
-
-	// Total unique visitors
-	query := `
-		SELECT COUNT(DISTINCT e.session_id)
-		FROM website_event e
-		WHERE e.website_id = $1
-		  AND e.created_at >= NOW() - INTERVAL '1 day' * $2
-		  AND e.event_type = 1`
-
-	err = db.QueryRowContext(ctx, query, parsedID, days).Scan(&stats.TotalVisitors)
-	if err != nil && err != sql.ErrNoRows {
-		return nil, fmt.Errorf("failed to query visitors: %w", err)
-	}
-
-	// Total pageviews
-	query = `
-		SELECT COUNT(*)
-		FROM website_event e
-		WHERE e.website_id = $1
-		  AND e.created_at >= NOW() - INTERVAL '1 day' * $2
-		  AND e.event_type = 1`
-
-	err = db.QueryRowContext(ctx, query, parsedID, days).Scan(&stats.TotalPageviews)
-	if err != nil && err != sql.ErrNoRows {
-		return nil, fmt.Errorf("failed to query pageviews: %w", err)
-	}
-
-	// Top page
-	topPage, err := getTopPageDetail(ctx, db, parsedID, days)
-	if err == nil && topPage != nil {
-		stats.TopPage = topPage
-	}
-
-	// Top referrer
-	topRef, err := getTopReferrer(ctx, db, parsedID, days)
-	if err == nil && topRef != nil {
-		stats.TopReferrer = topRef
-	}
-
-	// Browser distribution (top 3)
-	browsers, err := getBrowserDistribution(ctx, db, parsedID, days, 3)
-	if err == nil {
-		stats.BrowserDistribution = browsers
-	}
-
-	// Device distribution
-	devices, err := getDeviceDistribution(ctx, db, parsedID, days)
-	if err == nil {
-		stats.DeviceDistribution = devices
-	}
-
-	// Country distribution (top 3)
-	countries, err := getCountryDistribution(ctx, db, parsedID, days, 3)
-	if err == nil {
-		stats.CountryDistribution = countries
-	}
-
-	// Average engagement time
-	avgTime, err := getAverageEngagement(ctx, db, parsedID, days)
-	if err == nil {
-		stats.AvgEngagement = avgTime
+		return 0, fmt.Errorf("invalid website ID: %w", err)
 	}
-
-	return stats, nil
+	return blockers.Correction(ctx, db, id, days)
 }
 
-func GetTopPages(ctx context.Context, db *sql.DB, websiteID string, days int, limit int) ([]*PageStat, error) {
-	parsedID, err := uuid.Parse(websiteID)
-	if err != nil {
-		return nil, fmt.Errorf("invalid website ID: %w", err)
-	}
-
-	query := `
-		SELECT
-			e.url_path,
-			COUNT(*) as pageviews,
-			COUNT(DISTINCT e.session_id) as unique_visitors
-		FROM website_event e
-		WHERE e.website_id = $1
-		  AND e.created_at >= NOW() - INTERVAL '1 day' * $2
-		  AND e.event_type = 1
-		  AND e.url_path IS NOT NULL
-		GROUP BY e.url_path
-		ORDER BY pageviews DESC
-		LIMIT $3`
-
-	rows, err := db.QueryContext(ctx, query, parsedID, days, limit)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query top pages: %w", err)
+// GetSegmentFilters returns the filters of a website's saved segment, none
+// without a segment
+func GetSegmentFilters(ctx context.Context, db *sql.DB, websiteID string, segment string) (store.Filters, error) {
+	if segment == "" {
+		return store.Filters{}, nil
 	}
-	defer func() { _ = rows.Close() }()
-
-	var pages []*PageStat
-	for rows.Next() {
-		var path string
-		var pageviews, uniqueVisitors int64
-
-		if err := rows.Scan(&path, &pageviews, &uniqueVisitors); err != nil {
-			continue
-		}
-
-		// Calculate bounce rate for this page
-		bounceRate := calculatePageBounceRate(ctx, db, parsedID, path, days)
-
-		// Calculate average time on page
-		avgTime := calculatePageAvgTime(ctx, db, parsedID, path, days)
-
-		pages = append(pages, &PageStat{
-			Path:           path,
-			Pageviews:      pageviews,
-			UniqueVisitors: uniqueVisitors,
-			BounceRate:     bounceRate,
-			AvgTime:        avgTime,
-		})
-	}
-
-	return pages, rows.Err()
-}
-
-func GetBreakdownStats(ctx context.Context, db *sql.DB, websiteID string, dimension string, days int, limit int) (*BreakdownStat, error) {
 	parsedID, err := uuid.Parse(websiteID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid website ID: %w", err)
+		return store.Filters{}, fmt.Errorf("invalid website ID: %w", err)
 	}
-
-	var query string
-	var column string
-
-	switch dimension {
-	case "country":
-		column = "COALESCE(s.country, 'Unknown')"
-	case "browser":
-		column = "COALESCE(s.browser, 'Unknown')"
-	case "device":
-		column = "COALESCE(s.device, 'Unknown')"
-	case "referrer":
-		column = "COALESCE(e.referrer_domain, 'Direct / None')"
-	case "os":
-		column = "COALESCE(s.os, 'Unknown')"
-	default:
-		return nil, fmt.Errorf("invalid dimension: %s", dimension)
+	filters, err := segments.Filters(ctx, db, parsedID, segment)
+	if errors.Is(err, segments.ErrNotFound) {
+		return store.Filters{}, fmt.Errorf("segment not found: %s (see 'kaunta segment list')", segment)
 	}
-
-	// Join with session if needed
-	var joinClause string
-	if dimension != "referrer" {
-		joinClause = "JOIN session s ON e.session_id = s.session_id"
-	} else {
-		joinClause = "JOIN session s ON e.session_id = s.session_id"
-	}
-
-	query = fmt.Sprintf(`
-		SELECT
-			%s as name,
-			COUNT(DISTINCT e.session_id) as visitors,
-			COUNT(*) as pageviews
-		FROM website_event e
-		%s
-		WHERE e.website_id = $1
-		  AND e.created_at >= NOW() - INTERVAL '1 day' * $2
-		  AND e.event_type = 1
-		GROUP BY %s
-		ORDER BY visitors DESC
-		LIMIT $3`, column, joinClause, column)
-
-	rows, err := db.QueryContext(ctx, query, parsedID, days, limit)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query breakdown: %w", err)
-	}
-	defer func() { _ = rows.Close() }()
-
-	stats := &BreakdownStat{
-		Dimension: dimension,
-		Items:     []map[string]interface{}{},
-	}
-
-	for rows.Next() {
-		var name string
-		var visitors, pageviews int64
-
-		if err := rows.Scan(&name, &visitors, &pageviews); err != nil {
-			continue
-		}
-
-		// Calculate bounce rate for this dimension value
-		bounceRate := calculateDimensionBounceRate(ctx, db, parsedID, dimension, name, days)
-
-		item := map[string]interface{}{
-			"name":        name,
-			"visitors":    visitors,
-			"pageviews":   pageviews,
-			"bounce_rate": bounceRate,
-		}
-
-		stats.Items = append(stats.Items, item)
-	}
-
-	return stats, rows.Err()
+	return filters, err
 }
 
-func GetLiveStats(ctx context.Context, db *sql.DB, websiteID string) (*LiveStatsData, error) {
+// GetOverviewStats summarizes the range like the dashboard does and, with
+// compare, compares it with the range of the same length before
+func GetOverviewStats(ctx context.Context, db *sql.DB, websiteID string, days int, filters store.Filters, compare bool) (*OverviewStats, error) {
 	parsedID, err := uuid.Parse(websiteID)
 	if err != nil {
 		return nil, fmt.Errorf("invalid website ID: %w", err)
 	}
-
-	liveData := &LiveStatsData{
-		Timestamp: time.Now(),
-	}
-
-	// Active visitors (last 5 minutes)
-	query := `
-		SELECT COUNT(DISTINCT e.session_id)
-		FROM website_event e
-		WHERE e.website_id = $1
-		  AND e.created_at >= NOW() - INTERVAL '5 minutes'
-		  AND e.event_type = 1`
-
-	_ = db.QueryRowContext(ctx, query, parsedID).Scan(&liveData.ActiveVisitorsNow)
-
-	// Pageviews last minute
-	query = `
-		SELECT COUNT(*)
-		FROM website_event e
-		WHERE e.website_id = $1
-		  AND e.created_at >= NOW() - INTERVAL '1 minute'
-		  AND e.event_type = 1`
-
-	_ = db.QueryRowContext(ctx, query, parsedID).Scan(&liveData.PageviewsLastMinute)
-
-	// Top page right now
-	topPage, _ := getTopPageDetail(ctx, db, parsedID, 0) // 0 = last 5 minutes
-	liveData.TopPageNow = topPage
-
-	// Recent referrers
-	liveData.RecentReferrers, _ = getRecentReferrers(ctx, db, parsedID)
-
-	// Recent events count
-	query = `
-		SELECT COUNT(*)
-		FROM website_event e
-		WHERE e.website_id = $1
-		  AND e.created_at >= NOW() - INTERVAL '5 minutes'
-		  AND e.event_type = 1`
-
-	_ = db.QueryRowContext(ctx, query, parsedID).Scan(&liveData.RecentEvents)
-
-	return liveData, nil
-}
-
-// Helper utility functions
-
-func getTopPageDetail(ctx context.Context, db *sql.DB, websiteID uuid.UUID, days int) (*PageStat, error) {
-	var query string
-
-	if days == 0 {
-		// Last 5 minutes
-		query = `
-			SELECT e.url_path, COUNT(*) as pageviews, COUNT(DISTINCT e.session_id) as unique_visitors
-			FROM website_event e
-			WHERE e.website_id = $1
-			  AND e.created_at >= NOW() - INTERVAL '5 minutes'
-			  AND e.event_type = 1
-			  AND e.url_path IS NOT NULL
-			GROUP BY e.url_path
-			ORDER BY pageviews DESC
-			LIMIT 1`
-
-		var path string
-		var pageviews, uniqueVisitors int64
-
-		err := db.QueryRowContext(ctx, query, websiteID).Scan(&path, &pageviews, &uniqueVisitors)
-		if err != nil {
-			return nil, err
-		}
-
-		return &PageStat{
-			Path:           path,
-			Pageviews:      pageviews,
-			UniqueVisitors: uniqueVisitors,
-		}, nil
-	}
-
-	query = `
-		SELECT e.url_path, COUNT(*) as pageviews, COUNT(DISTINCT e.session_id) as unique_visitors
-		FROM website_event e
-		WHERE e.website_id = $1
-		  AND e.created_at >= NOW() - INTERVAL '1 day' * $2
-		  AND e.event_type = 1
-		  AND e.url_path IS NOT NULL
-		GROUP BY e.url_path
-		ORDER BY pageviews DESC
-		LIMIT 1`
-
-	var path string
-	var pageviews, uniqueVisitors int64
-
-	err := db.QueryRowContext(ctx, query, websiteID, days).Scan(&path, &pageviews, &uniqueVisitors)
-	if err != nil {
-		return nil, err
+	overview, err := stats.GetOverview(ctx, db, parsedID, days, filters)
+	if err != nil || !compare {
+		return overview, err
 	}
-
-	return &PageStat{
-		Path:           path,
-		Pageviews:      pageviews,
-		UniqueVisitors: uniqueVisitors,
-	}, nil
-}
-
-func getTopReferrer(ctx context.Context, db *sql.DB, websiteID uuid.UUID, days int) (*ReferrerStat, error) {
-	query := `
-		SELECT
-			COALESCE(e.referrer_domain, 'Direct / None') as domain,
-			COUNT(DISTINCT e.session_id) as visitors,
-			COUNT(*) as pageviews
-		FROM website_event e
-		WHERE e.website_id = $1
-		  AND e.created_at >= NOW() - INTERVAL '1 day' * $2
-		  AND e.event_type = 1
-		GROUP BY e.referrer_domain
-		ORDER BY visitors DESC
-		LIMIT 1`
-
-	var domain string
-	var visitors, pageviews int64
-
-	err := db.QueryRowContext(ctx, query, websiteID, days).Scan(&domain, &visitors, &pageviews)
-	if err != nil {
+	if overview.Comparison, err = stats.GetComparison(ctx, db, parsedID, days, filters); err != nil {
 		return nil, err
 	}
-
-	return &ReferrerStat{
-		Domain:    domain,
-		Visitors:  visitors,
-		Pageviews: pageviews,
-	}, nil
+	return overview, nil
 }
 
-func getBrowserDistribution(ctx context.Context, db *sql.DB, websiteID uuid.UUID, days int, limit int) (map[string]int64, error) {
-	query := `
-		SELECT COALESCE(s.browser, 'Unknown') as browser, COUNT(DISTINCT e.session_id) as visitors
-		FROM website_event e
-		JOIN session s ON e.session_id = s.session_id
-		WHERE e.website_id = $1
-		  AND e.created_at >= NOW() - INTERVAL '1 day' * $2
-		  AND e.event_type = 1
-		GROUP BY s.browser
-		ORDER BY visitors DESC
-		LIMIT $3`
-
-	rows, err := db.QueryContext(ctx, query, websiteID, days, limit)
+// GetTopPages returns the most viewed pages with their bounce rate and
+// average engagement time, as computed by get_top_pages() for the dashboard
+func GetTopPages(ctx context.Context, db *sql.DB, websiteID string, days int, limit int, filters store.Filters) ([]*PageStat, error) {
+	parsedID, err := uuid.Parse(websiteID)
 	if err != nil {
-		return nil, err
-	}
-	defer func() { _ = rows.Close() }()
-
-	distribution := make(map[string]int64)
-	for rows.Next() {
-		var browser string
-		var visitors int64
-
-		if err := rows.Scan(&browser, &visitors); err != nil {
-			continue
-		}
-
-		distribution[browser] = visitors
+		return nil, fmt.Errorf("invalid website ID: %w", err)
 	}
-
-	return distribution, rows.Err()
+	return stats.GetTopPages(ctx, db, parsedID, days, limit, filters)
 }
 
-func getDeviceDistribution(ctx context.Context, db *sql.DB, websiteID uuid.UUID, days int) (map[string]int64, error) {
-	query := `
-		SELECT COALESCE(s.device, 'Unknown') as device, COUNT(DISTINCT e.session_id) as visitors
-		FROM website_event e
-		JOIN session s ON e.session_id = s.session_id
-		WHERE e.website_id = $1
-		  AND e.created_at >= NOW() - INTERVAL '1 day' * $2
-		  AND e.event_type = 1
-		GROUP BY s.device
-		ORDER BY visitors DESC`
-
-	rows, err := db.QueryContext(ctx, query, websiteID, days)
+// GetBreakdownStats groups the range's pageviews by a session or referrer
+// dimension, including each value's bounce rate
+func GetBreakdownStats(ctx context.Context, db *sql.DB, websiteID string, dimension string, days int, limit int, filters store.Filters) (*BreakdownStat, error) {
+	parsedID, err := uuid.Parse(websiteID)
 	if err != nil {
-		return nil, err
-	}
-	defer func() { _ = rows.Close() }()
-
-	distribution := make(map[string]int64)
-	for rows.Next() {
-		var device string
-		var visitors int64
-
-		if err := rows.Scan(&device, &visitors); err != nil {
-			continue
-		}
-
-		distribution[device] = visitors
+		return nil, fmt.Errorf("invalid website ID: %w", err)
 	}
-
-	return distribution, rows.Err()
-}
-
-func getCountryDistribution(ctx context.Context, db *sql.DB, websiteID uuid.UUID, days int, limit int) (map[string]int64, error) {
-	query := `
-		SELECT COALESCE(s.country, 'Unknown') as country, COUNT(DISTINCT e.session_id) as visitors
-		FROM website_event e
-		JOIN session s ON e.session_id = s.session_id
-		WHERE e.website_id = $1
-		  AND e.created_at >= NOW() - INTERVAL '1 day' * $2
-		  AND e.event_type = 1
-		GROUP BY s.country
-		ORDER BY visitors DESC
-		LIMIT $3`
-
-	rows, err := db.QueryContext(ctx, query, websiteID, days, limit)
+	d, err := stats.Resolve(ctx, db, parsedID, dimension)
 	if err != nil {
 		return nil, err
 	}
-	defer func() { _ = rows.Close() }()
-
-	distribution := make(map[string]int64)
-	for rows.Next() {
-		var country string
-		var visitors int64
-
-		if err := rows.Scan(&country, &visitors); err != nil {
-			continue
-		}
-
-		distribution[country] = visitors
-	}
-
-	return distribution, rows.Err()
-}
-
-func getAverageEngagement(ctx context.Context, db *sql.DB, websiteID uuid.UUID, days int) (float64, error) {
-	// Calculate average time between first and last pageview per session
-	query := `
-		SELECT AVG(engagement_time)
-		FROM (
-			SELECT
-				e.session_id,
-				EXTRACT(EPOCH FROM (MAX(e.created_at) - MIN(e.created_at))) as engagement_time
-			FROM website_event e
-			WHERE e.website_id = $1
-			  AND e.created_at >= NOW() - INTERVAL '1 day' * $2
-			  AND e.event_type = 1
-			GROUP BY e.session_id
-		) session_engagement`
-
-	var avgTime sql.NullFloat64
-	err := db.QueryRowContext(ctx, query, websiteID, days).Scan(&avgTime)
-	if err != nil || !avgTime.Valid {
-		return 0, nil
-	}
-
-	return avgTime.Float64, nil
-}
-
-func calculatePageBounceRate(ctx context.Context, db *sql.DB, websiteID uuid.UUID, path string, days int) float64 {
-	query := `
-		SELECT
-			COUNT(DISTINCT CASE WHEN pageview_count = 1 THEN e.session_id END)::float / NULLIF(COUNT(DISTINCT e.session_id), 0) * 100 as bounce_rate
-		FROM website_event e
-		LEFT JOIN (
-			SELECT session_id, COUNT(*) as pageview_count
-			FROM website_event
-			WHERE website_id = $1
-			  AND created_at >= NOW() - INTERVAL '1 day' * $2
-			  AND event_type = 1
-			GROUP BY session_id
-		) pv ON e.session_id = pv.session_id
-		WHERE e.website_id = $1
-		  AND e.url_path = $3
-		  AND e.created_at >= NOW() - INTERVAL '1 day' * $2
-		  AND e.event_type = 1`
-
-	var bounceRate sql.NullFloat64
-	_ = db.QueryRowContext(ctx, query, websiteID, days, path).Scan(&bounceRate)
-
-	if bounceRate.Valid {
-		return bounceRate.Float64
-	}
-	return 0
-}
-
-func calculatePageAvgTime(ctx context.Context, db *sql.DB, websiteID uuid.UUID, path string, days int) float64 {
-	query := `
-		SELECT AVG(engagement_time)
-		FROM (
-			SELECT
-				e.session_id,
-				EXTRACT(EPOCH FROM (MAX(e.created_at) - MIN(e.created_at))) as engagement_time
-			FROM website_event e
-			WHERE e.website_id = $1
-			  AND e.url_path = $2
-			  AND e.created_at >= NOW() - INTERVAL '1 day' * $3
-			  AND e.event_type = 1
-			GROUP BY e.session_id
-		) session_engagement`
-
-	var avgTime sql.NullFloat64
-	_ = db.QueryRowContext(ctx, query, websiteID, path, days).Scan(&avgTime)
-
-	if avgTime.Valid {
-		return avgTime.Float64
-	}
-	return 0
-}
-
-func calculateDimensionBounceRate(ctx context.Context, db *sql.DB, websiteID uuid.UUID, dimension string, value string, days int) float64 {
-	var column string
-	var table string
-
-	switch dimension {
-	case "country":
-		column = "s.country"
-		table = "JOIN session s ON e.session_id = s.session_id"
-	case "browser":
-		column = "s.browser"
-		table = "JOIN session s ON e.session_id = s.session_id"
-	case "device":
-		column = "s.device"
-		table = "JOIN session s ON e.session_id = s.session_id"
-	case "referrer":
-		column = "e.referrer_domain"
-		table = "JOIN session s ON e.session_id = s.session_id"
-	case "os":
-		column = "s.os"
-		table = "JOIN session s ON e.session_id = s.session_id"
-	default:
-		return 0
-	}
-
-	var whereClause string
-	if dimension == "referrer" {
-		whereClause = fmt.Sprintf("COALESCE(%s, 'Direct / None') = $3", column)
-	} else {
-		whereClause = fmt.Sprintf("COALESCE(%s, 'Unknown') = $3", column)
-	}
-
-	query := fmt.Sprintf(`
-		SELECT
-			COUNT(DISTINCT CASE WHEN pageview_count = 1 THEN e.session_id END)::float / NULLIF(COUNT(DISTINCT e.session_id), 0) * 100 as bounce_rate
-		FROM website_event e
-		%s
-		LEFT JOIN (
-			SELECT session_id, COUNT(*) as pageview_count
-			FROM website_event
-			WHERE website_id = $1
-			  AND created_at >= NOW() - INTERVAL '1 day' * $2
-			  AND event_type = 1
-			GROUP BY session_id
-		) pv ON e.session_id = pv.session_id
-		WHERE e.website_id = $1
-		  AND %s
-		  AND e.created_at >= NOW() - INTERVAL '1 day' * $2
-		  AND e.event_type = 1`, table, whereClause)
-
-	var bounceRate sql.NullFloat64
-	_ = db.QueryRowContext(ctx, query, websiteID, days, value).Scan(&bounceRate)
-
-	if bounceRate.Valid {
-		return bounceRate.Float64
-	}
-	return 0
+	return stats.GetBreakdown(ctx, db, parsedID, d, days, limit, filters)
 }
 
-func getRecentReferrers(ctx context.Context, db *sql.DB, websiteID uuid.UUID) ([]map[string]interface{}, error) {
-	query := `
-		SELECT
-			COALESCE(e.referrer_domain, 'Direct / None') as referrer,
-			COUNT(*) as count
-		FROM website_event e
-		WHERE e.website_id = $1
-		  AND e.created_at >= NOW() - INTERVAL '5 minutes'
-		  AND e.event_type = 1
-		GROUP BY e.referrer_domain
-		ORDER BY count DESC
-		LIMIT 5`
-
-	rows, err := db.QueryContext(ctx, query, websiteID)
+func GetLiveStats(ctx context.Context, db *sql.DB, websiteID string) (*LiveStatsData, error) {
+	parsedID, err := uuid.Parse(websiteID)
 	if err != nil {
-		return nil, err
-	}
-	defer func() { _ = rows.Close() }()
-
-	var referrers []map[string]interface{}
-	for rows.Next() {
-		var referrer string
-		var count int64
-
-		if err := rows.Scan(&referrer, &count); err != nil {
-			continue
-		}
-
-		referrers = append(referrers, map[string]interface{}{
-			"referrer": referrer,
-			"count":    count,
-		})
+		return nil, fmt.Errorf("invalid website ID: %w", err)
 	}
-
-	return referrers, rows.Err()
+	return stats.GetLive(ctx, db, parsedID)
 }
 
 // Output formatting functions
@@ -1060,60 +655,90 @@ func outputOverviewJSON(stats *OverviewStats) error {
 	return nil
 }
 
-func outputOverviewText(stats *OverviewStats, domain string, days int) error {
-	fmt.Printf("Analytics Overview for %s (last %d days)\n", domain, days)
+func outputOverviewText(stats *OverviewStats, domain, period string, days int) error {
+	l := outputLocale
+	fmt.Printf("Analytics Overview for %s (%s)\n", domain, period)
 	fmt.Println(strings.Repeat("=", 60))
-	fmt.Printf("\nTotal Visitors:        %d\n", stats.TotalVisitors)
-	fmt.Printf("Total Pageviews:       %d\n", stats.TotalPageviews)
+	fmt.Printf("\nTotal Visitors:        %s\n", l.Int(stats.TotalVisitors))
+	if stats.BlockerCorrection > 0 {
+		fmt.Printf("Adjusted Visitors:     %s (x%s for blocked trackers)\n", l.Int(stats.AdjustedVisitors), l.Float(stats.BlockerCorrection, 2))
+	}
+	fmt.Printf("Total Pageviews:       %s\n", l.Int(stats.TotalPageviews))
+	if pending := stats.TotalPageviews - stats.ViewedPageviews; pending > 0 {
+		fmt.Printf("Viewed Pageviews:      %s (%s not seen)\n", l.Int(stats.ViewedPageviews), l.Int(pending))
+	}
 
 	if stats.TotalVisitors > 0 {
-		fmt.Printf("Avg Pageviews/Visitor: %.1f\n", float64(stats.TotalPageviews)/float64(stats.TotalVisitors))
+		fmt.Printf("Avg Pageviews/Visitor: %s\n", l.Float(float64(stats.TotalPageviews)/float64(stats.TotalVisitors), 1))
 	}
 
-	fmt.Printf("Avg Engagement Time:   %.1f seconds\n\n", stats.AvgEngagement)
+	fmt.Printf("Avg Engagement Time:   %s seconds\n\n", l.Float(stats.AvgEngagement, 1))
+
+	if c := stats.Comparison; c != nil {
+		fmt.Printf("Compared with the previous %d days:\n", days)
+		for _, line := range comparisonLines(c) {
+			fmt.Printf("  %-16s %s\n", line[0]+":", line[1])
+		}
+		fmt.Println()
+	}
 
 	if stats.TopPage != nil {
-		fmt.Printf("Top Page:              %s (%d pageviews)\n\n", stats.TopPage.Path, stats.TopPage.Pageviews)
+		fmt.Printf("Top Page:              %s (%s pageviews)\n\n", stats.TopPage.Path, l.Int(stats.TopPage.Pageviews))
 	}
 
 	if stats.TopReferrer != nil {
-		fmt.Printf("Top Referrer:          %s (%d visitors)\n\n", stats.TopReferrer.Domain, stats.TopReferrer.Visitors)
+		fmt.Printf("Top Referrer:          %s (%s visitors)\n\n", stats.TopReferrer.Domain, l.Int(stats.TopReferrer.Visitors))
 	}
 
 	fmt.Println("Browser Distribution:")
 	for browser, count := range stats.BrowserDistribution {
-		fmt.Printf("  %s: %d\n", browser, count)
+		fmt.Printf("  %s: %s\n", browser, l.Int(count))
 	}
 
 	fmt.Println("\nDevice Distribution:")
 	for device, count := range stats.DeviceDistribution {
-		fmt.Printf("  %s: %d\n", device, count)
+		fmt.Printf("  %s: %s\n", device, l.Int(count))
 	}
 
 	fmt.Println("\nTop Countries:")
 	for country, count := range stats.CountryDistribution {
-		fmt.Printf("  %s: %d\n", country, count)
+		fmt.Printf("  %s: %s\n", country, l.Int(count))
 	}
 
 	return nil
 }
 
-func outputOverviewTable(stats *OverviewStats, domain string, days int) error {
-	fmt.Printf("Analytics Overview for %s (last %d days)\n", domain, days)
+func outputOverviewTable(stats *OverviewStats, domain, period string, days int) error {
+	fmt.Printf("Analytics Overview for %s (%s)\n", domain, period)
 	fmt.Println(strings.Repeat("=", 60))
 
+	l := outputLocale
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 
-	_, _ = fmt.Fprintf(w, "Total Visitors:\t%d\n", stats.TotalVisitors)
-	_, _ = fmt.Fprintf(w, "Total Pageviews:\t%d\n", stats.TotalPageviews)
-	_, _ = fmt.Fprintf(w, "Avg Engagement Time:\t%.1f seconds\n\n", stats.AvgEngagement)
+	_, _ = fmt.Fprintf(w, "Total Visitors:\t%s\n", l.Int(stats.TotalVisitors))
+	if stats.BlockerCorrection > 0 {
+		_, _ = fmt.Fprintf(w, "Adjusted Visitors:\t%s (x%s for blocked trackers)\n", l.Int(stats.AdjustedVisitors), l.Float(stats.BlockerCorrection, 2))
+	}
+	_, _ = fmt.Fprintf(w, "Total Pageviews:\t%s\n", l.Int(stats.TotalPageviews))
+	if pending := stats.TotalPageviews - stats.ViewedPageviews; pending > 0 {
+		_, _ = fmt.Fprintf(w, "Viewed Pageviews:\t%s (%s not seen)\n", l.Int(stats.ViewedPageviews), l.Int(pending))
+	}
+	_, _ = fmt.Fprintf(w, "Avg Engagement Time:\t%s seconds\n\n", l.Float(stats.AvgEngagement, 1))
+
+	if c := stats.Comparison; c != nil {
+		_, _ = fmt.Fprintf(w, "vs Previous %d Days:\t\n", days)
+		for _, line := range comparisonLines(c) {
+			_, _ = fmt.Fprintf(w, "  %s:\t%s\n", line[0], line[1])
+		}
+		_, _ = fmt.Fprintln(w)
+	}
 
 	if stats.TopPage != nil {
-		_, _ = fmt.Fprintf(w, "Top Page:\t%s (%d pageviews)\n", stats.TopPage.Path, stats.TopPage.Pageviews)
+		_, _ = fmt.Fprintf(w, "Top Page:\t%s (%s pageviews)\n", stats.TopPage.Path, l.Int(stats.TopPage.Pageviews))
 	}
 
 	if stats.TopReferrer != nil {
-		_, _ = fmt.Fprintf(w, "Top Referrer:\t%s (%d visitors)\n\n", stats.TopReferrer.Domain, stats.TopReferrer.Visitors)
+		_, _ = fmt.Fprintf(w, "Top Referrer:\t%s (%s visitors)\n\n", stats.TopReferrer.Domain, l.Int(stats.TopReferrer.Visitors))
 	}
 
 	_ = w.Flush()
@@ -1121,24 +746,46 @@ func outputOverviewTable(stats *OverviewStats, domain string, days int) error {
 	// Browser distribution
 	fmt.Println("Browser Distribution:")
 	for browser, count := range stats.BrowserDistribution {
-		fmt.Printf("  %s: %d\n", browser, count)
+		fmt.Printf("  %s: %s\n", browser, l.Int(count))
 	}
 
 	// Device distribution
 	fmt.Println("\nDevice Distribution:")
 	for device, count := range stats.DeviceDistribution {
-		fmt.Printf("  %s: %d\n", device, count)
+		fmt.Printf("  %s: %s\n", device, l.Int(count))
 	}
 
 	// Country distribution
 	fmt.Println("\nTop Countries:")
 	for country, count := range stats.CountryDistribution {
-		fmt.Printf("  %s: %d\n", country, count)
+		fmt.Printf("  %s: %s\n", country, l.Int(count))
 	}
 
 	return nil
 }
 
+// comparisonLines describes each metric's change as "now (was before,
+// change)"
+func comparisonLines(c *stats.Comparison) [][2]string {
+	l := outputLocale
+	percent := func(ch stats.Change) string {
+		if ch.Percent == nil {
+			return ""
+		}
+		return ", " + l.SignedPercent(*ch.Percent)
+	}
+	return [][2]string{
+		{"Visitors", fmt.Sprintf("%s (was %s, %s%s)", l.Int(c.Current.Visitors), l.Int(c.Previous.Visitors),
+			l.Signed(c.Current.Visitors-c.Previous.Visitors), percent(c.Visitors))},
+		{"Pageviews", fmt.Sprintf("%s (was %s, %s%s)", l.Int(c.Current.Pageviews), l.Int(c.Previous.Pageviews),
+			l.Signed(c.Current.Pageviews-c.Previous.Pageviews), percent(c.Pageviews))},
+		{"Bounce Rate", fmt.Sprintf("%s (was %s, %s points)", l.Percent(c.Current.BounceRate), l.Percent(c.Previous.BounceRate),
+			l.SignedFloat(c.BounceRate.Delta, 1))},
+		{"Avg Engagement", fmt.Sprintf("%ss (was %ss, %ss%s)", l.Float(c.Current.AvgEngagement, 1), l.Float(c.Previous.AvgEngagement, 1),
+			l.SignedFloat(c.AvgEngagement.Delta, 1), percent(c.AvgEngagement))},
+	}
+}
+
 func outputPagesJSON(pages []*PageStat) error {
 	data, err := json.MarshalIndent(pages, "", "  ")
 	if err != nil {
@@ -1160,13 +807,14 @@ func outputPagesTable(pages []*PageStat) error {
 	_, _ = fmt.Fprintln(w, "PATH\tPAGEVIEWS\tUNIQUE VISITORS\tBOUNCE RATE\tAVG TIME")
 	_, _ = fmt.Fprintln(w, "----\t----------\t---------------\t-----------\t--------")
 
+	l := outputLocale
 	for _, page := range pages {
-		_, _ = fmt.Fprintf(w, "%s\t%d\t%d\t%.1f%%\t%.1fs\n",
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%ss\n",
 			page.Path,
-			page.Pageviews,
-			page.UniqueVisitors,
-			page.BounceRate,
-			page.AvgTime,
+			l.Int(page.Pageviews),
+			l.Int(page.UniqueVisitors),
+			l.Percent(page.BounceRate),
+			l.Float(page.AvgTime, 1),
 		)
 	}
 
@@ -1221,12 +869,13 @@ func outputBreakdownTable(stats *BreakdownStat) error {
 	_, _ = fmt.Fprintf(w, "NAME\tVISITORS\tPAGEVIEWS\tBOUNCE RATE\n")
 	_, _ = fmt.Fprintf(w, "----\t--------\t---------\t-----------\n")
 
+	l := outputLocale
 	for _, item := range stats.Items {
-		_, _ = fmt.Fprintf(w, "%v\t%v\t%v\t%.1f%%\n",
-			item["name"],
-			item["visitors"],
-			item["pageviews"],
-			item["bounce_rate"],
+		_, _ = fmt.Fprintf(w, "%v\t%s\t%s\t%s\n",
+			item.Name,
+			l.Int(item.Visitors),
+			l.Int(item.Pageviews),
+			l.Percent(item.BounceRate),
 		)
 	}
 
@@ -1246,10 +895,10 @@ func outputBreakdownCSV(stats *BreakdownStat) error {
 	// Write rows
 	for _, item := range stats.Items {
 		err := w.Write([]string{
-			fmt.Sprintf("%v", item["name"]),
-			fmt.Sprintf("%v", item["visitors"]),
-			fmt.Sprintf("%v", item["pageviews"]),
-			fmt.Sprintf("%.1f", item["bounce_rate"]),
+			item.Name,
+			fmt.Sprintf("%d", item.Visitors),
+			fmt.Sprintf("%d", item.Pageviews),
+			fmt.Sprintf("%.1f", item.BounceRate),
 		})
 		if err != nil {
 			return fmt.Errorf("failed to write CSV row: %w", err)
@@ -1276,18 +925,19 @@ func outputLiveTerm(data *LiveStatsData) error {
 	fmt.Printf("Live Analytics - %s\n", data.Timestamp.Format("15:04:05"))
 	fmt.Println(strings.Repeat("=", 60))
 
-	fmt.Printf("\nActive Visitors (last 5 min): %d\n", data.ActiveVisitorsNow)
-	fmt.Printf("Pageviews (last minute):      %d\n", data.PageviewsLastMinute)
-	fmt.Printf("Recent Events (last 5 min):   %d\n\n", data.RecentEvents)
+	l := outputLocale
+	fmt.Printf("\nActive Visitors (last 5 min): %s\n", l.Int(data.ActiveVisitorsNow))
+	fmt.Printf("Pageviews (last minute):      %s\n", l.Int(data.PageviewsLastMinute))
+	fmt.Printf("Recent Events (last 5 min):   %s\n\n", l.Int(data.RecentEvents))
 
 	if data.TopPageNow != nil {
-		fmt.Printf("Top Page Now: %s (%d pageviews)\n\n", data.TopPageNow.Path, data.TopPageNow.Pageviews)
+		fmt.Printf("Top Page Now: %s (%s pageviews)\n\n", data.TopPageNow.Path, l.Int(data.TopPageNow.Pageviews))
 	}
 
 	if len(data.RecentReferrers) > 0 {
 		fmt.Println("Recent Referrers:")
 		for _, ref := range data.RecentReferrers {
-			fmt.Printf("  %v: %v\n", ref["referrer"], ref["count"])
+			fmt.Printf("  %s: %s\n", ref.Referrer, l.Int(ref.Count))
 		}
 	}
 
@@ -1305,19 +955,30 @@ func init() {
 	// Overview command flags
 	statsOverviewCmd.Flags().IntVarP(&overviewDays, "days", "d", 7, "Time period in days (1-365)")
 	statsOverviewCmd.Flags().StringVarP(&overviewFormat, "format", "f", "table", "Output format (json, table, text)")
+	statsOverviewCmd.Flags().StringVar(&overviewSegment, "segment", "", "Saved segment to filter by")
+	statsOverviewCmd.Flags().BoolVar(&overviewCompare, "compare", false, "Compare with the previous period of the same length")
+	addRangeFlags(statsOverviewCmd, &overviewRange)
+	statsOverviewCmd.Flags().BoolVar(&overviewAdjustBlocked, "adjust-blocked", false, "Apply the baseline pixel's blocker correction to visitors")
 
 	// Pages command flags
 	statsPagesCmd.Flags().IntVarP(&pagesDays, "days", "d", 7, "Time period in days (1-365)")
+	addRangeFlags(statsPagesCmd, &pagesRange)
 	statsPagesCmd.Flags().IntVarP(&pagesTop, "top", "t", 10, "Number of pages to show (1-100)")
+	statsPagesCmd.Flags().StringVar(&pagesSegment, "segment", "", "Saved segment to filter by")
 	statsPagesCmd.Flags().StringVarP(&pagesFormat, "format", "f", "table", "Output format (json, table, csv)")
 
 	// Breakdown command flags
-	statsBreakdownCmd.Flags().StringVarP(&breakdownDimension, "by", "b", "", "Dimension to break down by (required: country, browser, device, referrer, os)")
+	statsBreakdownCmd.Flags().StringVarP(&breakdownDimension, "by", "b", "", "Dimension to break down by (required: "+strings.Join(stats.Names(), ", ")+" or a custom dimension)")
 	statsBreakdownCmd.Flags().IntVarP(&breakdownDays, "days", "d", 7, "Time period in days (1-365)")
+	addRangeFlags(statsBreakdownCmd, &breakdownRange)
 	statsBreakdownCmd.Flags().IntVarP(&breakdownTop, "top", "t", 10, "Number of items to show (1-100)")
+	statsBreakdownCmd.Flags().StringVar(&breakdownSegment, "segment", "", "Saved segment to filter by")
 	statsBreakdownCmd.Flags().StringVarP(&breakdownFormat, "format", "f", "table", "Output format (json, table, csv)")
 
 	// Live command flags
 	statsLiveCmd.Flags().IntVarP(&liveInterval, "interval", "i", 5, "Update interval in seconds (2-60)")
 	statsLiveCmd.Flags().StringVarP(&liveFormat, "format", "f", "text", "Output format (json, text)")
+	statsLiveCmd.Flags().BoolVar(&liveFollow, "follow", false, "Stream from a running server (no database access needed)")
+	statsLiveCmd.Flags().StringVar(&liveServer, "server", "", "Server URL for --follow")
+	statsLiveCmd.Flags().StringVar(&liveToken, "token", "", "API token for --follow")
 }
@@ -9,6 +9,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/stats"
 )
 
 func captureStdout(t *testing.T, fn func()) string {
@@ -29,11 +31,12 @@ func captureStdout(t *testing.T, fn func()) string {
 
 func TestOutputOverviewText(t *testing.T) {
 	stats := &OverviewStats{
-		TotalVisitors:  100,
-		TotalPageviews: 250,
-		AvgEngagement:  12.5,
-		TopPage:        &PageStat{Path: "/home", Pageviews: 120},
-		TopReferrer:    &ReferrerStat{Domain: "google.com", Visitors: 80},
+		TotalVisitors:   100,
+		TotalPageviews:  250,
+		ViewedPageviews: 210,
+		AvgEngagement:   12.5,
+		TopPage:         &PageStat{Path: "/home", Pageviews: 120},
+		TopReferrer:     &ReferrerStat{Domain: "google.com", Visitors: 80},
 		BrowserDistribution: map[string]int64{
 			"Chrome":  60,
 			"Firefox": 20,
@@ -49,11 +52,12 @@ func TestOutputOverviewText(t *testing.T) {
 	}
 
 	output := captureStdout(t, func() {
-		require.NoError(t, outputOverviewText(stats, "example.com", 7))
+		require.NoError(t, outputOverviewText(stats, "example.com", "last 7 days", 7))
 	})
 
 	assert.Contains(t, output, "Analytics Overview for example.com (last 7 days)")
 	assert.Contains(t, output, "Total Visitors:        100")
+	assert.Contains(t, output, "Viewed Pageviews:      210 (40 not seen)")
 	assert.Contains(t, output, "Chrome: 60")
 	assert.Contains(t, output, "Desktop: 70")
 	assert.Contains(t, output, "US: 80")
@@ -73,15 +77,15 @@ func TestOutputPagesCSV(t *testing.T) {
 }
 
 func TestOutputBreakdownTable(t *testing.T) {
-	stats := &BreakdownStat{
+	breakdown := &BreakdownStat{
 		Dimension: "country",
-		Items: []map[string]interface{}{
-			{"name": "US", "visitors": 50.0, "pageviews": 120.0, "bounce_rate": 40.0},
+		Items: []stats.BreakdownItem{
+			{Name: "US", Visitors: 50, Pageviews: 120, BounceRate: 40.0},
 		},
 	}
 
 	output := captureStdout(t, func() {
-		require.NoError(t, outputBreakdownTable(stats))
+		require.NoError(t, outputBreakdownTable(breakdown))
 	})
 
 	assert.Contains(t, output, "NAME")
@@ -112,8 +116,8 @@ func TestOutputLiveTerm(t *testing.T) {
 		PageviewsLastMinute: 16,
 		RecentEvents:        4,
 		TopPageNow:          &PageStat{Path: "/home", Pageviews: 3},
-		RecentReferrers: []map[string]interface{}{
-			{"referrer": "google.com", "count": 2},
+		RecentReferrers: []stats.RecentReferrer{
+			{Referrer: "google.com", Count: 2},
 		},
 	}
 
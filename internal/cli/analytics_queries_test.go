@@ -0,0 +1,354 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "github.com/lib/pq"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+func TestGetTopPagesUsesGetTopPages(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	websiteID := uuid.New()
+	// Bounce rate and engagement come from get_top_pages(), like the
+	// dashboard's; sqlmock rejects any other query
+	mock.ExpectQuery(`SELECT path, views, unique_visitors,.*FROM get_top_pages\(\$1, \$2, \$3\)`).
+		WithArgs(websiteID, 7, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"url_path", "pageviews", "unique_visitors", "bounce_rate", "avg_time"}).
+			AddRow("/", 120, 80, 42.5, 31.0).
+			AddRow("/pricing", 40, 30, 10.0, 12.5))
+
+	pages, err := GetTopPages(context.Background(), db, websiteID.String(), 7, 10, store.Filters{})
+	require.NoError(t, err)
+	require.Len(t, pages, 2)
+	assert.Equal(t, &PageStat{Path: "/", Pageviews: 120, UniqueVisitors: 80, BounceRate: 42.5, AvgTime: 31}, pages[0])
+	assert.Equal(t, 12.5, pages[1].AvgTime)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetBreakdownStatsSingleQuery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	websiteID := uuid.New()
+	mock.ExpectQuery(`COALESCE\(e.referrer_domain, \$4\) AS name`).
+		WithArgs(websiteID, 30, 5, "Direct / None").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "visitors", "pageviews", "bounce_rate"}).
+			AddRow("Direct / None", 50, 90, 60.0).
+			AddRow("google.com", 20, 25, 25.0))
+
+	stats, err := GetBreakdownStats(context.Background(), db, websiteID.String(), "referrer", 30, 5, store.Filters{})
+	require.NoError(t, err)
+	require.Len(t, stats.Items, 2)
+	assert.Equal(t, "google.com", stats.Items[1].Name)
+	assert.Equal(t, 25.0, stats.Items[1].BounceRate)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetBreakdownStatsCity(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	websiteID := uuid.New()
+	mock.ExpectQuery(`COALESCE\(s.city \|\| COALESCE\(', ' \|\| s.region, ''\) \|\| COALESCE\(', ' \|\| s.country, ''\), \$4\) AS name`).
+		WithArgs(websiteID, 7, 10, "Unknown").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "visitors", "pageviews", "bounce_rate"}).
+			AddRow("Springfield, Illinois, US", 12, 20, 25.0).
+			AddRow("Springfield, Missouri, US", 4, 5, 50.0))
+	mock.ExpectQuery(`COALESCE\(s.region \|\| COALESCE\(', ' \|\| s.country, ''\), \$4\) AS name`).
+		WithArgs(websiteID, 7, 10, "Unknown").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "visitors", "pageviews", "bounce_rate"}).
+			AddRow("Georgia, US", 8, 9, 0.0))
+
+	stats, err := GetBreakdownStats(context.Background(), db, websiteID.String(), "city", 7, 10, store.Filters{})
+	require.NoError(t, err)
+	require.Len(t, stats.Items, 2)
+	assert.Equal(t, "Springfield, Missouri, US", stats.Items[1].Name)
+
+	stats, err = GetBreakdownStats(context.Background(), db, websiteID.String(), "region", 7, 10, store.Filters{})
+	require.NoError(t, err)
+	assert.Equal(t, "Georgia, US", stats.Items[0].Name)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetBreakdownStatsASN(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	websiteID := uuid.New()
+	mock.ExpectQuery(`COALESCE\('AS' \|\| s.asn \|\| COALESCE\(' ' \|\| s.isp, ''\), \$4\) AS name`).
+		WithArgs(websiteID, 7, 10, "Unknown").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "visitors", "pageviews", "bounce_rate"}).
+			AddRow("AS16509 Amazon.com, Inc.", 40, 41, 97.5))
+
+	stats, err := GetBreakdownStats(context.Background(), db, websiteID.String(), "asn", 7, 10, store.Filters{})
+	require.NoError(t, err)
+	require.Len(t, stats.Items, 1)
+	assert.Equal(t, "AS16509 Amazon.com, Inc.", stats.Items[0].Name)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetBreakdownStatsCustomDimension(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	websiteID := uuid.New()
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM custom_dimension`).
+		WithArgs(websiteID, "content_type").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`COALESCE\(e.dimensions->>\$4, \$5\) AS name`).
+		WithArgs(websiteID, 30, 5, "content_type", "Unknown").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "visitors", "pageviews", "bounce_rate"}).
+			AddRow("tutorial", 12, 30, 50.0))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM custom_dimension`).
+		WithArgs(websiteID, "plan").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	stats, err := GetBreakdownStats(context.Background(), db, websiteID.String(), "content_type", 30, 5, store.Filters{})
+	require.NoError(t, err)
+	require.Len(t, stats.Items, 1)
+	assert.Equal(t, "tutorial", stats.Items[0].Name)
+
+	_, err = GetBreakdownStats(context.Background(), db, websiteID.String(), "plan", 30, 5, store.Filters{})
+	assert.EqualError(t, err, "invalid dimension: plan")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// benchDB opens the database named by KAUNTA_BENCH_DATABASE_URL and seeds a
+// website with KAUNTA_BENCH_EVENTS pageviews (default 1,000,000) spread over
+// the last 6 days. Benchmarks are skipped without a database.
+func benchDB(b *testing.B) (*sql.DB, uuid.UUID) {
+	b.Helper()
+
+	dsn := os.Getenv("KAUNTA_BENCH_DATABASE_URL")
+	if dsn == "" {
+		b.Skip("KAUNTA_BENCH_DATABASE_URL not set")
+	}
+	events := 1_000_000
+	if v := os.Getenv("KAUNTA_BENCH_EVENTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		require.NoError(b, err)
+		events = n
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(b, err)
+	b.Cleanup(func() { _ = db.Close() })
+	require.NoError(b, database.RunMigrations(dsn))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	websiteID := uuid.New()
+	sessions := events / 5
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO website (website_id, domain, name, allowed_domains, created_at, updated_at)
+		VALUES ($1, $2, 'Benchmark', '[]'::jsonb, NOW(), NOW())
+	`, websiteID, fmt.Sprintf("bench-%s.example", websiteID))
+	require.NoError(b, err)
+	b.Cleanup(func() {
+		_, _ = db.Exec(`DELETE FROM website_event WHERE website_id = $1`, websiteID)
+		_, _ = db.Exec(`DELETE FROM session WHERE website_id = $1`, websiteID)
+		_, _ = db.Exec(`DELETE FROM website WHERE website_id = $1`, websiteID)
+	})
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO session (session_id, website_id, browser, os, device, country, created_at)
+		SELECT md5($1::text || g)::uuid, $1,
+			(ARRAY['Chrome', 'Firefox', 'Safari', 'Edge'])[1 + g % 4],
+			(ARRAY['Windows', 'macOS', 'Linux', 'iOS', 'Android'])[1 + g % 5],
+			(ARRAY['desktop', 'mobile', 'tablet'])[1 + g % 3],
+			(ARRAY['US', 'DE', 'FR', 'GB', 'JP', 'BR', NULL])[1 + g % 7],
+			NOW()
+		FROM generate_series(1, $2) g
+	`, websiteID, sessions)
+	require.NoError(b, err)
+
+	// Skewed session sizes give a realistic mix of bounces and long visits
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO website_event (website_id, session_id, visit_id, created_at, url_path, referrer_domain, event_type)
+		SELECT $1, sid, sid, NOW() - (g % 518400) * INTERVAL '1 second',
+			'/page/' || (g * g) % 500,
+			(ARRAY[NULL, 'google.com', 'news.ycombinator.com', 'twitter.com'])[1 + g % 4],
+			1
+		FROM (
+			SELECT g, md5($1::text || (1 + (g * g) % $3))::uuid AS sid
+			FROM generate_series(1, $2) g
+		) e
+	`, websiteID, events, sessions)
+	require.NoError(b, err)
+
+	_, err = db.ExecContext(ctx, `ANALYZE website_event; ANALYZE session`)
+	require.NoError(b, err)
+	return db, websiteID
+}
+
+// BenchmarkTopPages compares the set-based query against the previous
+// implementation, which ran two extra queries per returned page:
+//
+//	KAUNTA_BENCH_DATABASE_URL=postgres://... go test ./internal/cli -run '^$' -bench 'TopPages|Breakdown'
+func BenchmarkTopPages(b *testing.B) {
+	db, websiteID := benchDB(b)
+	ctx := context.Background()
+
+	b.Run("set_based", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, err := GetTopPages(ctx, db, websiteID.String(), 7, 20, store.Filters{})
+			require.NoError(b, err)
+		}
+	})
+	b.Run("per_row", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, err := legacyTopPages(ctx, db, websiteID, 7, 20)
+			require.NoError(b, err)
+		}
+	})
+}
+
+func BenchmarkBreakdownStats(b *testing.B) {
+	db, websiteID := benchDB(b)
+	ctx := context.Background()
+
+	b.Run("set_based", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, err := GetBreakdownStats(ctx, db, websiteID.String(), "browser", 7, 20, store.Filters{})
+			require.NoError(b, err)
+		}
+	})
+	b.Run("per_row", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, err := legacyBrowserBreakdown(ctx, db, websiteID, 7, 20)
+			require.NoError(b, err)
+		}
+	})
+}
+
+// legacyTopPages is the pre-CTE GetTopPages: one query for the list, then a
+// bounce-rate and an avg-time query for every page
+func legacyTopPages(ctx context.Context, db *sql.DB, websiteID uuid.UUID, days, limit int) ([]*PageStat, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT e.url_path, COUNT(*), COUNT(DISTINCT e.session_id)
+		FROM website_event e
+		WHERE e.website_id = $1
+		  AND e.created_at >= NOW() - INTERVAL '1 day' * $2
+		  AND e.event_type = 1
+		  AND e.url_path IS NOT NULL
+		GROUP BY e.url_path
+		ORDER BY 2 DESC
+		LIMIT $3`, websiteID, days, limit)
+	if err != nil {
+		return nil, err
+	}
+	var pages []*PageStat
+	for rows.Next() {
+		page := &PageStat{}
+		if err := rows.Scan(&page.Path, &page.Pageviews, &page.UniqueVisitors); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		pages = append(pages, page)
+	}
+	_ = rows.Close()
+
+	for _, page := range pages {
+		var bounce, avg sql.NullFloat64
+		err := db.QueryRowContext(ctx, `
+			SELECT COUNT(DISTINCT CASE WHEN pageview_count = 1 THEN e.session_id END)::float / NULLIF(COUNT(DISTINCT e.session_id), 0) * 100
+			FROM website_event e
+			LEFT JOIN (
+				SELECT session_id, COUNT(*) AS pageview_count
+				FROM website_event
+				WHERE website_id = $1 AND created_at >= NOW() - INTERVAL '1 day' * $2 AND event_type = 1
+				GROUP BY session_id
+			) pv ON e.session_id = pv.session_id
+			WHERE e.website_id = $1 AND e.url_path = $3
+			  AND e.created_at >= NOW() - INTERVAL '1 day' * $2 AND e.event_type = 1`,
+			websiteID, days, page.Path).Scan(&bounce)
+		if err != nil {
+			return nil, err
+		}
+		err = db.QueryRowContext(ctx, `
+			SELECT AVG(t) FROM (
+				SELECT EXTRACT(EPOCH FROM (MAX(created_at) - MIN(created_at))) AS t
+				FROM website_event
+				WHERE website_id = $1 AND url_path = $2
+				  AND created_at >= NOW() - INTERVAL '1 day' * $3 AND event_type = 1
+				GROUP BY session_id
+			) s`, websiteID, page.Path, days).Scan(&avg)
+		if err != nil {
+			return nil, err
+		}
+		page.BounceRate, page.AvgTime = bounce.Float64, avg.Float64
+	}
+	return pages, nil
+}
+
+// legacyBrowserBreakdown is the pre-CTE GetBreakdownStats for browsers, with
+// a bounce-rate query per value
+func legacyBrowserBreakdown(ctx context.Context, db *sql.DB, websiteID uuid.UUID, days, limit int) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT COALESCE(s.browser, 'Unknown'), COUNT(DISTINCT e.session_id) AS visitors, COUNT(*)
+		FROM website_event e
+		JOIN session s ON e.session_id = s.session_id
+		WHERE e.website_id = $1
+		  AND e.created_at >= NOW() - INTERVAL '1 day' * $2
+		  AND e.event_type = 1
+		GROUP BY 1
+		ORDER BY visitors DESC
+		LIMIT $3`, websiteID, days, limit)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		var visitors, pageviews int64
+		if err := rows.Scan(&name, &visitors, &pageviews); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	_ = rows.Close()
+
+	for _, name := range names {
+		var bounce sql.NullFloat64
+		err := db.QueryRowContext(ctx, `
+			SELECT COUNT(DISTINCT CASE WHEN pageview_count = 1 THEN e.session_id END)::float / NULLIF(COUNT(DISTINCT e.session_id), 0) * 100
+			FROM website_event e
+			JOIN session s ON e.session_id = s.session_id
+			LEFT JOIN (
+				SELECT session_id, COUNT(*) AS pageview_count
+				FROM website_event
+				WHERE website_id = $1 AND created_at >= NOW() - INTERVAL '1 day' * $2 AND event_type = 1
+				GROUP BY session_id
+			) pv ON e.session_id = pv.session_id
+			WHERE e.website_id = $1 AND COALESCE(s.browser, 'Unknown') = $3
+			  AND e.created_at >= NOW() - INTERVAL '1 day' * $2 AND e.event_type = 1`,
+			websiteID, days, name).Scan(&bounce)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
@@ -3,12 +3,17 @@ package cli
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/realtime"
+	"github.com/seuros/kaunta/internal/stats"
+	"github.com/seuros/kaunta/internal/store"
 )
 
 func TestRunStatsOverviewTable(t *testing.T) {
@@ -20,12 +25,13 @@ func TestRunStatsOverviewTable(t *testing.T) {
 		return "site-123", nil
 	})
 
-	stubOverviewFetcher(t, func(ctx context.Context, db *sql.DB, websiteID string, days int) (*OverviewStats, error) {
+	stubOverviewFetcher(t, func(ctx context.Context, db *sql.DB, websiteID string, days int, filters store.Filters, compare bool) (*OverviewStats, error) {
 		assert.Equal(t, "site-123", websiteID)
 		assert.Equal(t, 7, days)
 		return &OverviewStats{
 			TotalVisitors:       42,
 			TotalPageviews:      84,
+			ViewedPageviews:     84,
 			AvgEngagement:       15.5,
 			BrowserDistribution: map[string]int64{"Chrome": 30},
 			DeviceDistribution:  map[string]int64{"Desktop": 40},
@@ -42,20 +48,107 @@ func TestRunStatsOverviewTable(t *testing.T) {
 	})
 
 	output, err := captureOutput(t, func() error {
-		return runStatsOverview("example.com", 7, "table")
+		return runStatsOverview("example.com", 7, dateRange{}, "", "table", false, false)
 	})
 	require.NoError(t, err)
 	assert.Contains(t, output, "Analytics Overview for example.com")
 	assert.Contains(t, output, "Total Visitors")
 	assert.Contains(t, output, "Chrome: 30")
+	assert.NotContains(t, output, "Viewed Pageviews")
+}
+
+func TestRunStatsOverviewAdjustBlocked(t *testing.T) {
+	stubDB(t)
+	stubConnectClose(t)
+
+	stubWebsiteIDLookup(t, func(ctx context.Context, domain string) (string, error) {
+		return "site-123", nil
+	})
+	stubOverviewFetcher(t, func(ctx context.Context, db *sql.DB, websiteID string, days int, filters store.Filters, compare bool) (*OverviewStats, error) {
+		return &OverviewStats{TotalVisitors: 400, TotalPageviews: 900}, nil
+	})
+
+	original := getBlockerCorrectionFn
+	getBlockerCorrectionFn = func(ctx context.Context, db *sql.DB, websiteID string, days int) (float64, error) {
+		assert.Equal(t, "site-123", websiteID)
+		assert.Equal(t, 7, days)
+		return 1.25, nil
+	}
+	t.Cleanup(func() { getBlockerCorrectionFn = original })
+
+	output, err := captureOutput(t, func() error {
+		return runStatsOverview("example.com", 7, dateRange{}, "", "text", true, false)
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "Adjusted Visitors:     500 (x1.25 for blocked trackers)")
+}
+
+func TestRunStatsOverviewCompare(t *testing.T) {
+	stubDB(t)
+	stubConnectClose(t)
+
+	stubWebsiteIDLookup(t, func(ctx context.Context, domain string) (string, error) {
+		return "site-123", nil
+	})
+	up, down := 20.0, -11.1
+	stubOverviewFetcher(t, func(ctx context.Context, db *sql.DB, websiteID string, days int, filters store.Filters, compare bool) (*OverviewStats, error) {
+		assert.True(t, compare)
+		return &OverviewStats{TotalVisitors: 120, TotalPageviews: 300, Comparison: &stats.Comparison{
+			Current:    stats.Summary{Visitors: 120, Pageviews: 300, BounceRate: 40, AvgEngagement: 12.5},
+			Previous:   stats.Summary{Visitors: 100, BounceRate: 45, AvgEngagement: 12.5},
+			Visitors:   stats.Change{Delta: 20, Percent: &up},
+			Pageviews:  stats.Change{Delta: 300},
+			BounceRate: stats.Change{Delta: -5, Percent: &down},
+		}}, nil
+	})
+
+	output, err := captureOutput(t, func() error {
+		return runStatsOverview("example.com", 7, dateRange{}, "", "text", false, true)
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "Compared with the previous 7 days:")
+	assert.Contains(t, output, "Visitors:        120 (was 100, +20, +20.0%)")
+	assert.Contains(t, output, "Pageviews:       300 (was 0, +300)")
+	assert.Contains(t, output, "Bounce Rate:     40.0% (was 45.0%, -5.0 points)")
+	assert.Contains(t, output, "Avg Engagement:  12.5s (was 12.5s, +0.0s)")
 }
 
 func TestRunStatsOverviewInvalidDays(t *testing.T) {
-	err := runStatsOverview("example.com", 0, "table")
+	err := runStatsOverview("example.com", 0, dateRange{}, "", "table", false, false)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "days must be between 1 and 365")
 }
 
+func TestRunStatsOverviewDateRange(t *testing.T) {
+	stubDB(t)
+	stubConnectClose(t)
+
+	stubWebsiteIDLookup(t, func(ctx context.Context, domain string) (string, error) {
+		return "site-123", nil
+	})
+	stubOverviewFetcher(t, func(ctx context.Context, db *sql.DB, websiteID string, days int, filters store.Filters, compare bool) (*OverviewStats, error) {
+		assert.Equal(t, time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), filters.From)
+		assert.Equal(t, time.Date(2025, 6, 16, 0, 0, 0, 0, time.UTC), filters.To)
+		assert.GreaterOrEqual(t, days, int(time.Since(filters.From).Hours()/24))
+		return &OverviewStats{TotalVisitors: 42}, nil
+	})
+
+	output, err := captureOutput(t, func() error {
+		return runStatsOverview("example.com", 7, dateRange{From: "2025-06-01", To: "2025-06-15"}, "", "text", false, false)
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "Analytics Overview for example.com (2025-06-01..2025-06-15)")
+}
+
+func TestRunStatsOverviewInvalidRange(t *testing.T) {
+	err := runStatsOverview("example.com", 7, dateRange{From: "2025-06-01", TZ: "Nowhere/Land"}, "", "text", false, false)
+	assert.EqualError(t, err, `invalid time zone "Nowhere/Land"`)
+
+	from := time.Now().AddDate(0, 0, -3).Format(time.DateOnly)
+	err = runStatsOverview("example.com", 7, dateRange{From: from}, "", "text", false, true)
+	assert.EqualError(t, err, "--compare works with --days, not with a date range")
+}
+
 func TestRunStatsPagesCSV(t *testing.T) {
 	stubDB(t)
 	stubConnectClose(t)
@@ -64,7 +157,7 @@ func TestRunStatsPagesCSV(t *testing.T) {
 		return "site-123", nil
 	})
 
-	stubTopPagesFetcher(t, func(ctx context.Context, db *sql.DB, websiteID string, days int, limit int) ([]*PageStat, error) {
+	stubTopPagesFetcher(t, func(ctx context.Context, db *sql.DB, websiteID string, days int, limit int, filters store.Filters) ([]*PageStat, error) {
 		assert.Equal(t, 5, limit)
 		return []*PageStat{
 			{
@@ -78,7 +171,7 @@ func TestRunStatsPagesCSV(t *testing.T) {
 	})
 
 	output, err := captureOutput(t, func() error {
-		return runStatsPages("example.com", 7, 5, "csv")
+		return runStatsPages("example.com", 7, dateRange{}, 5, "", "csv")
 	})
 	require.NoError(t, err)
 	assert.Contains(t, output, "path,pageviews,unique_visitors")
@@ -86,7 +179,7 @@ func TestRunStatsPagesCSV(t *testing.T) {
 }
 
 func TestRunStatsPagesInvalidTop(t *testing.T) {
-	err := runStatsPages("example.com", 7, 0, "table")
+	err := runStatsPages("example.com", 7, dateRange{}, 0, "", "table")
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "top must be between 1 and 100")
 }
@@ -99,18 +192,18 @@ func TestRunStatsBreakdownJSON(t *testing.T) {
 		return "site-123", nil
 	})
 
-	stubBreakdownFetcher(t, func(ctx context.Context, db *sql.DB, websiteID string, dimension string, days int, limit int) (*BreakdownStat, error) {
+	stubBreakdownFetcher(t, func(ctx context.Context, db *sql.DB, websiteID string, dimension string, days int, limit int, filters store.Filters) (*BreakdownStat, error) {
 		assert.Equal(t, "country", dimension)
 		return &BreakdownStat{
 			Dimension: "country",
-			Items: []map[string]interface{}{
-				{"name": "US", "visitors": 10, "pageviews": 20, "bounce_rate": 40.0},
+			Items: []stats.BreakdownItem{
+				{Name: "US", Visitors: 10, Pageviews: 20, BounceRate: 40.0},
 			},
 		}, nil
 	})
 
 	output, err := captureOutput(t, func() error {
-		return runStatsBreakdown("example.com", "country", 7, 5, "json")
+		return runStatsBreakdown("example.com", "country", 7, dateRange{}, 5, "", "json")
 	})
 	require.NoError(t, err)
 	assert.Contains(t, output, `"dimension": "country"`)
@@ -118,11 +211,11 @@ func TestRunStatsBreakdownJSON(t *testing.T) {
 }
 
 func TestRunStatsBreakdownInvalidDimension(t *testing.T) {
-	err := runStatsBreakdown("example.com", "", 7, 5, "json")
+	err := runStatsBreakdown("example.com", "", 7, dateRange{}, 5, "", "json")
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "--by dimension is required")
 
-	err = runStatsBreakdown("example.com", "invalid", 7, 5, "json")
+	err = runStatsBreakdown("example.com", "not-a-dimension", 7, dateRange{}, 5, "", "json")
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "invalid dimension")
 }
@@ -142,6 +235,10 @@ func TestRunStatsLiveTextHandlesTickerAndSignal(t *testing.T) {
 		return tickCh, func() { stopped = true }
 	})
 
+	stubLiveEvents(t, func(context.Context, string) (<-chan realtime.EventPayload, error) {
+		return nil, errors.New("no listener")
+	})
+
 	var capturedSignal chan<- os.Signal
 	stubSignalNotify(t, func(c chan<- os.Signal, sig ...os.Signal) {
 		capturedSignal = c
@@ -192,6 +289,63 @@ func TestRunStatsLiveTextHandlesTickerAndSignal(t *testing.T) {
 	assert.True(t, stopped)
 }
 
+func TestRunStatsLiveRefreshesOnEvents(t *testing.T) {
+	stubDB(t)
+	stubConnectClose(t)
+	stubWebsiteIDLookup(t, func(ctx context.Context, domain string) (string, error) {
+		return "site-123", nil
+	})
+
+	tickCh := make(chan time.Time)
+	stubTickerFactory(t, func(d time.Duration) (<-chan time.Time, func()) {
+		return tickCh, func() {}
+	})
+
+	events := make(chan realtime.EventPayload)
+	stubLiveEvents(t, func(ctx context.Context, websiteID string) (<-chan realtime.EventPayload, error) {
+		assert.Equal(t, "site-123", websiteID)
+		return events, nil
+	})
+
+	signals := make(chan chan<- os.Signal, 1)
+	stubSignalNotify(t, func(c chan<- os.Signal, sig ...os.Signal) {
+		signals <- c
+	})
+
+	calls := make(chan struct{}, 4)
+	stubLiveStatsFetcher(t, func(ctx context.Context, db *sql.DB, websiteID string) (*LiveStatsData, error) {
+		calls <- struct{}{}
+		return &LiveStatsData{Timestamp: time.Now()}, nil
+	})
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := captureOutput(t, func() error {
+			return runStatsLive("example.com", 2, "text")
+		})
+		errCh <- err
+	}()
+
+	<-calls // initial fetch
+	start := time.Now()
+
+	// A quiet tick doesn't query
+	tickCh <- start.Add(2 * time.Second)
+	assert.Empty(t, calls)
+
+	// An event is picked up on the next tick
+	events <- realtime.EventPayload{Type: "event", WebsiteID: "site-123"}
+	tickCh <- start.Add(4 * time.Second)
+	<-calls
+
+	// Visitors leave the window: a long quiet spell queries anyway
+	tickCh <- start.Add(2 * time.Minute)
+	<-calls
+
+	(<-signals) <- os.Interrupt
+	require.NoError(t, <-errCh)
+}
+
 func stubWebsiteIDLookup(t *testing.T, fn func(ctx context.Context, domain string) (string, error)) {
 	t.Helper()
 	original := getWebsiteIDByDomainFn
@@ -201,7 +355,7 @@ func stubWebsiteIDLookup(t *testing.T, fn func(ctx context.Context, domain strin
 	})
 }
 
-func stubOverviewFetcher(t *testing.T, fn func(context.Context, *sql.DB, string, int) (*OverviewStats, error)) {
+func stubOverviewFetcher(t *testing.T, fn func(context.Context, *sql.DB, string, int, store.Filters, bool) (*OverviewStats, error)) {
 	t.Helper()
 	original := getOverviewStats
 	getOverviewStats = fn
@@ -210,7 +364,7 @@ func stubOverviewFetcher(t *testing.T, fn func(context.Context, *sql.DB, string,
 	})
 }
 
-func stubTopPagesFetcher(t *testing.T, fn func(context.Context, *sql.DB, string, int, int) ([]*PageStat, error)) {
+func stubTopPagesFetcher(t *testing.T, fn func(context.Context, *sql.DB, string, int, int, store.Filters) ([]*PageStat, error)) {
 	t.Helper()
 	original := getTopPagesFn
 	getTopPagesFn = fn
@@ -219,7 +373,7 @@ func stubTopPagesFetcher(t *testing.T, fn func(context.Context, *sql.DB, string,
 	})
 }
 
-func stubBreakdownFetcher(t *testing.T, fn func(context.Context, *sql.DB, string, string, int, int) (*BreakdownStat, error)) {
+func stubBreakdownFetcher(t *testing.T, fn func(context.Context, *sql.DB, string, string, int, int, store.Filters) (*BreakdownStat, error)) {
 	t.Helper()
 	original := getBreakdownStatsFn
 	getBreakdownStatsFn = fn
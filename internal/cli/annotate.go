@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/annotations"
+	"github.com/seuros/kaunta/internal/database"
+)
+
+// Annotate command flags
+var (
+	annotateKind       string
+	annotateAt         string
+	annotateAuthor     string
+	annotateListDays   int
+	annotateListFormat string
+)
+
+var annotateCmd = &cobra.Command{
+	Use:   "annotate",
+	Short: "Manage annotations on moments of a website",
+	Long: `Annotations mark a moment of a website's traffic: a deploy, the start of a
+campaign. The dashboard draws them as markers on the pageviews chart, and
+the time series API returns them with ?annotations=true. Deploy pipelines
+can post them to /api/annotations/:website_id with an API key.`,
+}
+
+var annotateAddCmd = &cobra.Command{
+	Use:   "add <domain> <label> [--kind deploy|campaign|other] [--at <time>] [--author <name>]",
+	Short: "Mark a moment of a website",
+	Long: fmt.Sprintf(`Mark a moment of a website with a label of at most %d characters. The
+moment is now unless --at gives one, as RFC 3339 or in local time
+("2025-06-15 14:00", "2025-06-15").
+
+Examples:
+  kaunta annotate add example.com "v2.3.0" --kind deploy
+  kaunta annotate add example.com "Summer sale" --kind campaign --at "2025-06-15 09:00"`, annotations.MaxLabel),
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runAnnotateAdd(args[0], args[1], annotateKind, annotateAt, annotateAuthor)
+	},
+}
+
+var annotateListCmd = &cobra.Command{
+	Use:   "list <domain> [--days <N>] [--format json|table]",
+	Short: "List the annotations of a website's last days",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runAnnotateList(args[0], annotateListDays, annotateListFormat)
+	},
+}
+
+var annotateRemoveCmd = &cobra.Command{
+	Use:   "remove <domain> <annotation-id>",
+	Short: "Delete an annotation",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runAnnotateRemove(args[0], args[1])
+	},
+}
+
+func runAnnotateAdd(domain, label, kind, at, author string) error {
+	if _, err := annotations.Validate(label); err != nil {
+		return err
+	}
+	if _, err := annotations.ParseKind(kind); err != nil {
+		return err
+	}
+	when, err := parseAnnotationTime(at, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		a, err := annotations.Add(ctx, database.DB, websiteID, when, label, kind, author)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Annotation %s (%s) added to %s at %s\n", a.ID, a.Kind, domain, a.At.Local().Format("2006-01-02 15:04"))
+		return nil
+	})
+}
+
+// parseAnnotationTime reads the --at of an annotation: RFC 3339, or a local
+// time with or without minutes; now when empty
+func parseAnnotationTime(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return now, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf(`invalid time: %q (use RFC 3339, "YYYY-MM-DD HH:MM" or YYYY-MM-DD)`, value)
+}
+
+func runAnnotateList(domain string, days int, format string) error {
+	if format != "table" && format != "json" {
+		return fmt.Errorf("invalid format: %s (use json or table)", format)
+	}
+	if days < 1 || days > 365 {
+		return fmt.Errorf("days must be between 1 and 365")
+	}
+
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		list, err := annotations.LastDays(ctx, database.DB, websiteID, days, time.Now())
+		if err != nil {
+			return err
+		}
+		if format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(list)
+		}
+
+		if len(list) == 0 {
+			fmt.Printf("No annotations for %s in the last %d days\n", domain, days)
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "TIME\tKIND\tLABEL\tAUTHOR\tID")
+		_, _ = fmt.Fprintln(w, "----\t----\t-----\t------\t--")
+		for _, a := range list {
+			author := a.Author
+			if author == "" {
+				author = "-"
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", a.At.Local().Format("2006-01-02 15:04"), a.Kind, a.Label, author, a.ID)
+		}
+		return w.Flush()
+	})
+}
+
+func runAnnotateRemove(domain, id string) error {
+	annotationID, err := uuid.Parse(id)
+	if err != nil {
+		return fmt.Errorf("invalid annotation ID: %s", id)
+	}
+
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		err := annotations.Remove(ctx, database.DB, websiteID, annotationID)
+		if errors.Is(err, annotations.ErrNotFound) {
+			return fmt.Errorf("no annotation %s for %s", annotationID, domain)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Annotation %s removed from %s\n", annotationID, domain)
+		return nil
+	})
+}
+
+func init() {
+	RootCmd.AddCommand(annotateCmd)
+	annotateCmd.AddCommand(annotateAddCmd, annotateListCmd, annotateRemoveCmd)
+
+	annotateAddCmd.Flags().StringVar(&annotateKind, "kind", annotations.Other, "Kind of moment: "+strings.Join(annotations.Kinds, ", "))
+	annotateAddCmd.Flags().StringVar(&annotateAt, "at", "", "When it happened (default now)")
+	annotateAddCmd.Flags().StringVar(&annotateAuthor, "author", "", "Who made the annotation")
+
+	annotateListCmd.Flags().IntVarP(&annotateListDays, "days", "d", 30, "Number of days to list, today included")
+	annotateListCmd.Flags().StringVar(&annotateListFormat, "format", "table", "Output format: json, table")
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunAnnotateAdd(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID, annotationID := uuid.New(), uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectQuery(`INSERT INTO annotation`).
+		WithArgs(websiteID, time.Date(2025, 6, 15, 14, 0, 0, 0, time.UTC), "v2.3.0", "deploy", "ci").
+		WillReturnRows(sqlmock.NewRows([]string{"annotation_id", "created_at"}).AddRow(annotationID, time.Now()))
+
+	output, err := captureOutput(t, func() error {
+		return runAnnotateAdd("example.com", "v2.3.0", "deploy", "2025-06-15T14:00:00Z", "ci")
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "Annotation "+annotationID.String()+" (deploy) added to example.com")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunAnnotateAddInvalid(t *testing.T) {
+	assert.EqualError(t, runAnnotateAdd("example.com", " ", "deploy", "", ""), "an annotation needs a label")
+	assert.EqualError(t, runAnnotateAdd("example.com", "v2.3.0", "release", "", ""),
+		`invalid annotation kind: "release" (use deploy, campaign, other)`)
+	assert.EqualError(t, runAnnotateAdd("example.com", "v2.3.0", "deploy", "June 15", ""),
+		`invalid time: "June 15" (use RFC 3339, "YYYY-MM-DD HH:MM" or YYYY-MM-DD)`)
+	assert.EqualError(t, runAnnotateRemove("example.com", "42"), "invalid annotation ID: 42")
+}
+
+func TestParseAnnotationTime(t *testing.T) {
+	now := time.Now()
+	at, err := parseAnnotationTime("", now)
+	require.NoError(t, err)
+	assert.Equal(t, now, at)
+
+	at, err = parseAnnotationTime("2025-06-15 09:30", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 6, 15, 9, 30, 0, 0, time.Local), at)
+
+	at, err = parseAnnotationTime("2025-06-15", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 6, 15, 0, 0, 0, 0, time.Local), at)
+}
+
+func TestRunAnnotateList(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectQuery(`FROM annotation`).WithArgs(websiteID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"annotation_id", "website_id", "annotated_at", "label", "kind", "author", "created_at"}).
+			AddRow(uuid.New(), websiteID, time.Now(), "Summer sale", "campaign", "", time.Now()))
+
+	output, err := captureOutput(t, func() error {
+		return runAnnotateList("example.com", 30, "table")
+	})
+	require.NoError(t, err)
+	assert.Regexp(t, `campaign\s+Summer sale\s+-\s+`, output)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,295 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/archive"
+	"github.com/seuros/kaunta/internal/blobstore"
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/database"
+)
+
+var (
+	archiveOlderThan  string
+	archiveDest       string
+	archiveDrop       bool
+	archiveForce      bool
+	archiveDryRun     bool
+	archiveFormat     string
+	archiveRestoreAll bool
+)
+
+// openArchiveBucket is swapped in tests
+var openArchiveBucket = func(dest string) (blobstore.Bucket, string, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, "", err
+	}
+	bucket, err := blobstore.OpenURL(dest, cfg.Storage, cfg.DataDir)
+	if err != nil {
+		return nil, "", err
+	}
+	// The shared storage bucket also holds exports and reports
+	if dest == "" {
+		return bucket, "archive/", nil
+	}
+	return bucket, "", nil
+}
+
+var archiveCmd = &cobra.Command{
+	Use:   "archive --older-than <age> [--dest <url>] [--drop]",
+	Short: "Move old events to cold storage as Parquet",
+	Long: `Export daily website_event partitions older than the given age as
+Parquet files, together with the sessions their events belong to.
+
+The destination is s3://bucket/prefix, gs://bucket/prefix or a local
+directory; endpoint and credentials come from the storage_* settings.
+Without --dest the configured storage bucket is used, under archive/.
+Days that are already archived are skipped unless --force is given.
+
+With --drop each partition is dropped once its upload has been verified.
+Rollups are kept, so long-range reports still cover archived days. Use
+'kaunta archive restore' to load days back.
+
+Options:
+  --older-than AGE   Archive partitions older than AGE (180d, 26w or days)
+  --dest URL         Destination bucket or directory
+  --drop             Drop partitions after archiving them
+  --force            Re-export days that are already archived
+  --dry-run          Show what would be archived
+  --format           Output format for --dry-run: table, json
+
+Examples:
+  kaunta archive --older-than 180d --dest s3://analytics-archive/kaunta --dry-run
+  kaunta archive --older-than 180d --dest s3://analytics-archive/kaunta --drop`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runArchive(archiveOlderThan, archiveDest, archiveDrop, archiveForce, archiveDryRun, archiveFormat)
+	},
+}
+
+var archiveListCmd = &cobra.Command{
+	Use:   "list [--dest <url>]",
+	Short: "List archived days",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runArchiveList(archiveDest)
+	},
+}
+
+var archiveRestoreCmd = &cobra.Command{
+	Use:   "restore <YYYY-MM-DD>... [--dest <url>] [--all]",
+	Short: "Load archived days back into the database",
+	Long: `Recreate the partitions of archived days and load their events and
+sessions back. Rows that already exist are skipped, so a restore can be
+repeated. Websites must still exist for their data to be restored.
+
+Examples:
+  kaunta archive restore 2025-05-09 2025-05-10 --dest s3://analytics-archive/kaunta
+  kaunta archive restore --all --dest /mnt/cold/kaunta`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runArchiveRestore(args, archiveRestoreAll, archiveDest)
+	},
+}
+
+// parseAge reads an age such as 180d, 26w or 180 (days) as a number of days
+func parseAge(age string) (int, error) {
+	s := strings.TrimSpace(strings.ToLower(age))
+	unit := 1
+	switch {
+	case strings.HasSuffix(s, "w"):
+		unit, s = 7, strings.TrimSuffix(s, "w")
+	case strings.HasSuffix(s, "d"):
+		s = strings.TrimSuffix(s, "d")
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid age: %q (use e.g. 180d, 26w or a number of days)", age)
+	}
+	return n * unit, nil
+}
+
+func newArchiver(dest string) (*archive.Archiver, error) {
+	bucket, prefix, err := openArchiveBucket(dest)
+	if err != nil {
+		return nil, err
+	}
+	return &archive.Archiver{DB: database.DB, Bucket: bucket, Prefix: prefix}, nil
+}
+
+func runArchive(olderThan, dest string, drop, force, dryRun bool, format string) error {
+	if olderThan == "" {
+		return fmt.Errorf("--older-than is required")
+	}
+	days, err := parseAge(olderThan)
+	if err != nil {
+		return err
+	}
+	if format != "table" && format != "json" {
+		return fmt.Errorf("invalid format: %s (use json or table)", format)
+	}
+
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	a, err := newArchiver(dest)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 12*time.Hour)
+	defer cancel()
+
+	before := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -days)
+	plan, err := a.Plan(ctx, before)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		if format == "json" {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(plan)
+		}
+		return printArchivePlan(plan, before, force)
+	}
+
+	var archived int
+	for _, day := range plan {
+		if day.Archived && !force {
+			fmt.Printf("%s: already archived, skipped (use --force to re-export)\n", day.Partition)
+			continue
+		}
+		result, err := a.Archive(ctx, day, drop)
+		if err != nil {
+			return err
+		}
+		archived++
+		status := ""
+		if result.Dropped {
+			status = ", partition dropped"
+		}
+		fmt.Printf("%s: %d event(s), %d session(s), %d bytes%s\n", day.Partition,
+			result.Events, result.Sessions, result.Bytes, status)
+	}
+	fmt.Printf("Archived %d day(s) before %s\n", archived, before.Format("2006-01-02"))
+	return nil
+}
+
+func printArchivePlan(plan []archive.Day, before time.Time, force bool) error {
+	if len(plan) == 0 {
+		fmt.Printf("No partitions before %s\n", before.Format("2006-01-02"))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "PARTITION\tDAY\tEST. ROWS\tSTATUS")
+	_, _ = fmt.Fprintln(w, "---------\t---\t---------\t------")
+	for _, day := range plan {
+		status := "to archive"
+		if day.Archived {
+			status = "archived"
+			if force {
+				status = "archived, re-export"
+			}
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", day.Partition, day.Day.Format("2006-01-02"), day.Rows, status)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Println("\nDry run: nothing was archived")
+	return nil
+}
+
+func runArchiveList(dest string) error {
+	a, err := newArchiver(dest)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	days, err := a.Archived(ctx)
+	if err != nil {
+		return err
+	}
+	if len(days) == 0 {
+		fmt.Println("No archived days")
+		return nil
+	}
+	for _, day := range days {
+		fmt.Println(day.Format("2006-01-02"))
+	}
+	return nil
+}
+
+func runArchiveRestore(args []string, all bool, dest string) error {
+	if all == (len(args) > 0) {
+		return fmt.Errorf("pass the days to restore or --all")
+	}
+	var days []time.Time
+	for _, arg := range args {
+		day, err := time.Parse("2006-01-02", arg)
+		if err != nil {
+			return fmt.Errorf("invalid day: %s (use YYYY-MM-DD)", arg)
+		}
+		days = append(days, day)
+	}
+
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	a, err := newArchiver(dest)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 12*time.Hour)
+	defer cancel()
+
+	if all {
+		if days, err = a.Archived(ctx); err != nil {
+			return err
+		}
+	}
+	for _, day := range days {
+		result, err := a.Restore(ctx, day)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: restored %d event(s), %d session(s)\n", day.Format("2006-01-02"), result.Events, result.Sessions)
+	}
+	return nil
+}
+
+func init() {
+	RootCmd.AddCommand(archiveCmd)
+	archiveCmd.AddCommand(archiveListCmd)
+	archiveCmd.AddCommand(archiveRestoreCmd)
+
+	archiveCmd.PersistentFlags().StringVar(&archiveDest, "dest", "", "Destination: s3://bucket/prefix, gs://bucket/prefix or a directory (default: configured storage)")
+	archiveCmd.Flags().StringVar(&archiveOlderThan, "older-than", "", "Archive partitions older than this age (e.g. 180d)")
+	archiveCmd.Flags().BoolVar(&archiveDrop, "drop", false, "Drop partitions once archived")
+	archiveCmd.Flags().BoolVar(&archiveForce, "force", false, "Re-export days that are already archived")
+	archiveCmd.Flags().BoolVar(&archiveDryRun, "dry-run", false, "Show what would be archived")
+	archiveCmd.Flags().StringVar(&archiveFormat, "format", "table", "Output format for --dry-run: json, table")
+	archiveRestoreCmd.Flags().BoolVar(&archiveRestoreAll, "all", false, "Restore every archived day")
+}
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/blobstore"
+)
+
+func stubArchiveBucket(t *testing.T) blobstore.Bucket {
+	t.Helper()
+	bucket, err := blobstore.NewLocal(t.TempDir())
+	require.NoError(t, err)
+	original := openArchiveBucket
+	openArchiveBucket = func(dest string) (blobstore.Bucket, string, error) {
+		return bucket, "", nil
+	}
+	t.Cleanup(func() { openArchiveBucket = original })
+	return bucket
+}
+
+func TestRunArchiveDryRun(t *testing.T) {
+	mock := mockJobsDB(t)
+	bucket := stubArchiveBucket(t)
+
+	old := time.Now().UTC().AddDate(0, 0, -200)
+	older := old.AddDate(0, 0, -1)
+	require.NoError(t, bucket.Put(context.Background(),
+		older.Format("events/2006/01/2006-01-02.parquet"), strings.NewReader("PAR1"), 4, ""))
+
+	mock.ExpectQuery(`FROM pg_inherits`).
+		WillReturnRows(sqlmock.NewRows([]string{"relname", "reltuples"}).
+			AddRow(older.Format("website_event_2006_01_02"), 800).
+			AddRow(old.Format("website_event_2006_01_02"), 900).
+			AddRow(time.Now().UTC().Format("website_event_2006_01_02"), 10))
+
+	output, err := captureOutput(t, func() error {
+		return runArchive("180d", "", false, false, true, "table")
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "PARTITION")
+	assert.Contains(t, output, old.Format("website_event_2006_01_02"))
+	assert.Contains(t, output, "to archive")
+	assert.Contains(t, output, "archived")
+	assert.NotContains(t, output, time.Now().UTC().Format("website_event_2006_01_02"))
+	assert.Contains(t, output, "Dry run: nothing was archived")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestParseAge(t *testing.T) {
+	for in, want := range map[string]int{"180d": 180, "26w": 182, "90": 90, " 30D ": 30} {
+		got, err := parseAge(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+	for _, in := range []string{"", "0d", "6m", "-3d"} {
+		_, err := parseAge(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestRunArchiveValidation(t *testing.T) {
+	assert.EqualError(t, runArchive("", "", false, false, true, "table"), "--older-than is required")
+	assert.EqualError(t, runArchive("180d", "", false, false, true, "csv"), "invalid format: csv (use json or table)")
+	assert.EqualError(t, runArchiveRestore(nil, false, ""), "pass the days to restore or --all")
+	assert.EqualError(t, runArchiveRestore([]string{"2025-05-09"}, true, ""), "pass the days to restore or --all")
+	assert.EqualError(t, runArchiveRestore([]string{"May 9"}, false, ""), "invalid day: May 9 (use YYYY-MM-DD)")
+}
@@ -0,0 +1,219 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/spf13/cobra"
+)
+
+// AuthorStat holds readership metrics for one author
+type AuthorStat struct {
+	Author        string  `json:"author"`
+	Articles      int64   `json:"articles"`
+	Visitors      int64   `json:"visitors"`
+	Pageviews     int64   `json:"pageviews"`
+	ReadRatio     float64 `json:"read_ratio"`
+	AvgEngagement float64 `json:"avg_engagement_seconds"`
+}
+
+var getAuthorStatsFn = GetAuthorStats
+
+// Authors command flags
+var (
+	authorsDays   int
+	authorsTop    int
+	authorsFormat string
+)
+
+var statsAuthorsCmd = &cobra.Command{
+	Use:   "authors <website-domain> [--days <N>] [--top <N>] [--format json|table|csv]",
+	Short: "Show readership per author",
+	Long: `Display articles, visitors, pageviews, read ratio and average engagement
+time per author.
+
+The tracker takes the author from a data-author attribute on the page (for
+example on the script tag or the article element) or from
+<meta name="author">. A pageview counts as read once the visitor scrolled
+through 75% of the page; the read ratio is read pageviews / pageviews.
+
+Options:
+  --days N      Time period in days (1-365, default 7)
+  --top N       Number of authors to show (1-100, default 10)
+  --format      Output format: json, table, csv (default table)
+
+Examples:
+  kaunta stats authors mysite.com
+  kaunta stats authors mysite.com --days 30 --top 25`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runStatsAuthors(args[0], authorsDays, authorsTop, authorsFormat)
+	},
+}
+
+func runStatsAuthors(domain string, days int, top int, format string) error {
+	if days < 1 || days > 365 {
+		return fmt.Errorf("days must be between 1 and 365")
+	}
+
+	if top < 1 || top > 100 {
+		return fmt.Errorf("top must be between 1 and 100")
+	}
+
+	if format == "" {
+		format = "table"
+	}
+
+	if database.DB == nil {
+		if err := connectDatabase(); err != nil {
+			return fmt.Errorf("database connection failed: %w", err)
+		}
+		defer func() { _ = closeDatabase() }()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	websiteID, err := getWebsiteIDByDomainFn(ctx, domain)
+	if err != nil {
+		return err
+	}
+
+	authors, err := getAuthorStatsFn(ctx, database.DB, websiteID, days, top)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		return outputAuthorsJSON(authors)
+	case "csv":
+		return outputAuthorsCSV(authors)
+	case "table":
+		return outputAuthorsTable(authors)
+	default:
+		return fmt.Errorf("invalid format: %s (use json, table, or csv)", format)
+	}
+}
+
+// GetAuthorStats returns readership metrics for the range's most viewed
+// authors
+func GetAuthorStats(ctx context.Context, db *sql.DB, websiteID string, days int, limit int) ([]*AuthorStat, error) {
+	parsedID, err := uuid.Parse(websiteID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid website ID: %w", err)
+	}
+
+	query := `
+		SELECT
+			e.author,
+			COUNT(DISTINCT e.url_path) AS articles,
+			COUNT(DISTINCT e.session_id) AS visitors,
+			COUNT(*) AS pageviews,
+			COUNT(*) FILTER (WHERE e.read)::float / COUNT(*) * 100 AS read_ratio,
+			COALESCE(AVG(e.engagement_time) / 1000.0, 0)::float AS avg_engagement
+		FROM website_event e
+		WHERE e.website_id = $1
+		  AND e.created_at >= NOW() - INTERVAL '1 day' * $2
+		  AND e.event_type = 1
+		  AND e.author IS NOT NULL
+		GROUP BY e.author
+		ORDER BY pageviews DESC, e.author
+		LIMIT $3`
+
+	rows, err := db.QueryContext(ctx, query, parsedID, days, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query authors: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	authors := []*AuthorStat{}
+	for rows.Next() {
+		stat := &AuthorStat{}
+		if err := rows.Scan(&stat.Author, &stat.Articles, &stat.Visitors, &stat.Pageviews,
+			&stat.ReadRatio, &stat.AvgEngagement); err != nil {
+			continue
+		}
+		authors = append(authors, stat)
+	}
+
+	return authors, rows.Err()
+}
+
+func outputAuthorsJSON(authors []*AuthorStat) error {
+	data, err := json.MarshalIndent(authors, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func outputAuthorsTable(authors []*AuthorStat) error {
+	if len(authors) == 0 {
+		fmt.Println("No author data available (add data-author or <meta name=\"author\"> to your pages)")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer func() { _ = w.Flush() }()
+
+	_, _ = fmt.Fprintln(w, "AUTHOR\tARTICLES\tVISITORS\tPAGEVIEWS\tREAD RATIO\tAVG ENGAGEMENT")
+	_, _ = fmt.Fprintln(w, "------\t--------\t--------\t---------\t----------\t--------------")
+
+	l := outputLocale
+	for _, a := range authors {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%ss\n",
+			a.Author,
+			l.Int(a.Articles),
+			l.Int(a.Visitors),
+			l.Int(a.Pageviews),
+			l.Percent(a.ReadRatio),
+			l.Float(a.AvgEngagement, 1),
+		)
+	}
+
+	return nil
+}
+
+func outputAuthorsCSV(authors []*AuthorStat) error {
+	w := csv.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	err := w.Write([]string{"author", "articles", "visitors", "pageviews", "read_ratio", "avg_engagement_seconds"})
+	if err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for _, a := range authors {
+		err := w.Write([]string{
+			a.Author,
+			fmt.Sprintf("%d", a.Articles),
+			fmt.Sprintf("%d", a.Visitors),
+			fmt.Sprintf("%d", a.Pageviews),
+			fmt.Sprintf("%.1f", a.ReadRatio),
+			fmt.Sprintf("%.1f", a.AvgEngagement),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	statsCmd.AddCommand(statsAuthorsCmd)
+
+	statsAuthorsCmd.Flags().IntVarP(&authorsDays, "days", "d", 7, "Time period in days (1-365)")
+	statsAuthorsCmd.Flags().IntVarP(&authorsTop, "top", "t", 10, "Number of authors to show (1-100)")
+	statsAuthorsCmd.Flags().StringVarP(&authorsFormat, "format", "f", "table", "Output format (json, table, csv)")
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/store"
+)
+
+func TestGetAuthorStats(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	websiteID := uuid.New()
+	mock.ExpectQuery(`COUNT\(\*\) FILTER \(WHERE e.read\).*AND e.author IS NOT NULL\s+GROUP BY e.author`).
+		WithArgs(websiteID, 30, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"author", "articles", "visitors", "pageviews", "read_ratio", "avg_engagement"}).
+			AddRow("Jane Doe", 12, 800, 1000, 42.5, 61.0).
+			AddRow("John Roe", 3, 90, 100, 10.0, 20.5))
+
+	authors, err := GetAuthorStats(context.Background(), db, websiteID.String(), 30, 10)
+	require.NoError(t, err)
+	require.Len(t, authors, 2)
+	assert.Equal(t, &AuthorStat{Author: "Jane Doe", Articles: 12, Visitors: 800, Pageviews: 1000,
+		ReadRatio: 42.5, AvgEngagement: 61.0}, authors[0])
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetBreakdownStatsAuthor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	websiteID := uuid.New()
+	mock.ExpectQuery(`COALESCE\(e.author, \$4\) AS name`).
+		WithArgs(websiteID, 7, 10, "Unknown").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "visitors", "pageviews", "bounce_rate"}).
+			AddRow("Jane Doe", 30, 41, 40.0))
+
+	stats, err := GetBreakdownStats(context.Background(), db, websiteID.String(), "author", 7, 10, store.Filters{})
+	require.NoError(t, err)
+	require.Len(t, stats.Items, 1)
+	assert.Equal(t, "Jane Doe", stats.Items[0].Name)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunStatsAuthorsTable(t *testing.T) {
+	stubDB(t)
+	stubConnectClose(t)
+
+	stubWebsiteIDLookup(t, func(ctx context.Context, domain string) (string, error) {
+		return uuid.NewString(), nil
+	})
+	original := getAuthorStatsFn
+	t.Cleanup(func() { getAuthorStatsFn = original })
+	getAuthorStatsFn = func(ctx context.Context, db *sql.DB, websiteID string, days int, limit int) ([]*AuthorStat, error) {
+		return []*AuthorStat{{Author: "Jane Doe", Articles: 12, Visitors: 800, Pageviews: 1000, ReadRatio: 42.5, AvgEngagement: 61}}, nil
+	}
+
+	output, err := captureOutput(t, func() error { return runStatsAuthors("example.com", 7, 10, "table") })
+	require.NoError(t, err)
+	assert.Contains(t, output, "READ RATIO")
+	assert.Contains(t, output, "Jane Doe  12        800       1000       42.5%       61.0s")
+
+	assert.EqualError(t, runStatsAuthors("example.com", 7, 0, "table"), "top must be between 1 and 100")
+}
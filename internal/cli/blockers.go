@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/blockers"
+	"github.com/seuros/kaunta/internal/database"
+)
+
+var (
+	blockersDays   int
+	blockersFormat string
+)
+
+var statsBlockersCmd = &cobra.Command{
+	Use:   "blockers [--days <N>] [--format json|table]",
+	Short: "Estimate how much traffic blocks the tracker",
+	Long: `Compare visitors seen by the baseline pixel with visitors seen by the
+JavaScript tracker, per website.
+
+Add the pixel to your pages next to the tracker:
+
+  <img src="https://your-kaunta/b/<website-id>.gif" alt="" width="1" height="1">
+
+A pixel visitor with no tracked event in the same session and day blocked the
+tracker. The correction factor (pixel / tracked visitors) is only applied
+once a website has at least 100 pixel visitors; use
+'kaunta stats overview --adjust-blocked' to apply it to visitor counts.
+
+Options:
+  --days N     Time period in days (1-365, default 30)
+  --format     Output format: json, table (default table)`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runStatsBlockers(blockersDays, blockersFormat)
+	},
+}
+
+func runStatsBlockers(days int, format string) error {
+	if days < 1 || days > 365 {
+		return fmt.Errorf("days must be between 1 and 365")
+	}
+	if format != "table" && format != "json" {
+		return fmt.Errorf("invalid format: %s (use json or table)", format)
+	}
+
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	websites, err := blockers.Report(ctx, database.DB, days)
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(websites)
+	}
+
+	if len(websites) == 0 {
+		fmt.Println("No baseline pixel hits yet")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "WEBSITE\tPIXEL\tTRACKED\tBLOCKED\tBLOCK RATE\tCORRECTION")
+	_, _ = fmt.Fprintln(w, "-------\t-----\t-------\t-------\t----------\t----------")
+	l := outputLocale
+	for _, site := range websites {
+		correction := "x" + l.Float(site.Correction, 2)
+		if !site.Reliable {
+			correction = "n/a (sample too small)"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", site.Domain, l.Int(site.PixelVisitors),
+			l.Int(site.TrackedVisitors), l.Int(site.Blocked), l.Percent(site.BlockRate), correction)
+	}
+	return w.Flush()
+}
+
+func init() {
+	statsCmd.AddCommand(statsBlockersCmd)
+
+	statsBlockersCmd.Flags().IntVar(&blockersDays, "days", 30, "Time period in days (1-365)")
+	statsBlockersCmd.Flags().StringVar(&blockersFormat, "format", "table", "Output format: json, table")
+}
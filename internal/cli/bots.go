@@ -0,0 +1,232 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/database"
+)
+
+var botRangeType string
+
+var websiteBotFilterCmd = &cobra.Command{
+	Use:   "bot-filter <domain> <on|off>",
+	Short: "Drop or keep bot traffic for a website",
+	Long: `Choose what happens to requests detected as bots (known user agents, known
+bot IP ranges, headless browsers).
+
+on (the default) drops them. off keeps them as events flagged as bots: reports
+include them, and dashboard API calls can pass bot=false to exclude them or
+bot=true to see only bots. Requires PostgreSQL; SQLite always drops bots.
+
+Examples:
+  kaunta website bot-filter example.com off`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteBotFilter(args[0], args[1])
+	},
+}
+
+var botsCmd = &cobra.Command{
+	Use:   "bots",
+	Short: "Manage bot detection",
+	Long: `Bot detection runs on every tracking request: user agent patterns, known bot
+IP ranges and headless browser signals (navigator.webdriver, an empty screen,
+no Accept-Language header).`,
+}
+
+var botsRangeCmd = &cobra.Command{
+	Use:   "range",
+	Short: "Manage known bot IP ranges",
+	Long: `Requests from a known bot IP range are treated as bots whatever their user
+agent. Published Googlebot and Bingbot ranges are included.`,
+}
+
+var botsRangeAddCmd = &cobra.Command{
+	Use:   "add <cidr> <name>",
+	Short: "Add a bot IP range",
+	Long: `Add a bot IP range. A single address is stored as /32 (or /128).
+
+Examples:
+  kaunta bots range add 66.249.64.0/19 Googlebot --type search_engine
+  kaunta bots range add 203.0.113.7 "Uptime monitor" --type monitor`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBotsRangeAdd(args[0], args[1], botRangeType)
+	},
+}
+
+var botsRangeListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List bot IP ranges",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBotsRangeList()
+	},
+}
+
+var botsRangeRemoveCmd = &cobra.Command{
+	Use:   "remove <cidr>",
+	Short: "Remove a bot IP range",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBotsRangeRemove(args[0])
+	},
+}
+
+func runWebsiteBotFilter(domain, value string) error {
+	var filter bool
+	switch strings.ToLower(value) {
+	case "on":
+		filter = true
+	case "off":
+		filter = false
+	default:
+		return fmt.Errorf("invalid value: %s (use on or off)", value)
+	}
+
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		_, err := database.DB.ExecContext(ctx,
+			`UPDATE website SET bot_filter = $2, updated_at = NOW() WHERE website_id = $1`, websiteID, filter)
+		if err != nil {
+			return fmt.Errorf("failed to update bot filter: %w", err)
+		}
+		if filter {
+			fmt.Printf("%s now drops bot traffic\n", domain)
+		} else {
+			fmt.Printf("%s now keeps bot traffic, flagged as bots\n", domain)
+		}
+		return nil
+	})
+}
+
+// parseBotRange normalizes a CIDR or a single address to a network
+func parseBotRange(value string) (string, error) {
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return "", fmt.Errorf("invalid IP range: %s", value)
+		}
+		if ip.To4() != nil {
+			return ip.String() + "/32", nil
+		}
+		return ip.String() + "/128", nil
+	}
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid IP range: %s", value)
+	}
+	return network.String(), nil
+}
+
+func runBotsRangeAdd(cidr, name, botType string) error {
+	cidr, err := parseBotRange(cidr)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(name) == "" || len(name) > 100 {
+		return fmt.Errorf("name must be 1-100 characters")
+	}
+	if botType == "" || len(botType) > 50 {
+		return fmt.Errorf("type must be 1-50 characters")
+	}
+
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err = database.DB.ExecContext(ctx, `
+		INSERT INTO bot_ip_range (cidr, bot_type, name)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (cidr) DO UPDATE SET bot_type = EXCLUDED.bot_type, name = EXCLUDED.name
+	`, cidr, botType, name)
+	if err != nil {
+		return fmt.Errorf("failed to add bot IP range: %w", err)
+	}
+	fmt.Printf("Bot IP range %s (%s) added\n", cidr, name)
+	return nil
+}
+
+func runBotsRangeList() error {
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	rows, err := database.DB.QueryContext(ctx, `
+		SELECT cidr::text, bot_type, name
+		FROM bot_ip_range
+		ORDER BY name, cidr
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to list bot IP ranges: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "RANGE\tTYPE\tNAME")
+	_, _ = fmt.Fprintln(w, "-----\t----\t----")
+	for rows.Next() {
+		var cidr, botType, name string
+		if err := rows.Scan(&cidr, &botType, &name); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", cidr, botType, name)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func runBotsRangeRemove(cidr string) error {
+	cidr, err := parseBotRange(cidr)
+	if err != nil {
+		return err
+	}
+
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	res, err := database.DB.ExecContext(ctx, `DELETE FROM bot_ip_range WHERE cidr = $1`, cidr)
+	if err != nil {
+		return fmt.Errorf("failed to remove bot IP range: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("bot IP range %s not found", cidr)
+	}
+	fmt.Printf("Bot IP range %s removed\n", cidr)
+	return nil
+}
+
+func init() {
+	websiteCmd.AddCommand(websiteBotFilterCmd)
+	RootCmd.AddCommand(botsCmd)
+	botsCmd.AddCommand(botsRangeCmd)
+	botsRangeCmd.AddCommand(botsRangeAddCmd, botsRangeListCmd, botsRangeRemoveCmd)
+
+	botsRangeAddCmd.Flags().StringVar(&botRangeType, "type", "crawler", "Bot type (search_engine, llm_crawler, monitor, ...)")
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBotRange(t *testing.T) {
+	tests := map[string]string{
+		"66.249.64.0/19":  "66.249.64.0/19",
+		"66.249.70.1/19":  "66.249.64.0/19",
+		"203.0.113.7":     "203.0.113.7/32",
+		"2001:db8::1":     "2001:db8::1/128",
+		"2001:db8::/32":   "2001:db8::/32",
+		"2001:db8:1::/48": "2001:db8:1::/48",
+	}
+	for input, want := range tests {
+		got, err := parseBotRange(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, got, input)
+	}
+
+	for _, input := range []string{"", "example.com", "10.0.0.0/33", "300.1.1.1"} {
+		_, err := parseBotRange(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestRunWebsiteBotFilter(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectExec(`UPDATE website SET bot_filter = \$2`).WithArgs(websiteID, false).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	output, err := captureOutput(t, func() error { return runWebsiteBotFilter("example.com", "off") })
+	require.NoError(t, err)
+	assert.Contains(t, output, "example.com now keeps bot traffic")
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	assert.EqualError(t, runWebsiteBotFilter("example.com", "maybe"), "invalid value: maybe (use on or off)")
+}
+
+func TestRunBotsRangeAddAndRemove(t *testing.T) {
+	mock := mockJobsDB(t)
+
+	mock.ExpectExec(`INSERT INTO bot_ip_range`).WithArgs("203.0.113.7/32", "monitor", "Uptime").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	output, err := captureOutput(t, func() error { return runBotsRangeAdd("203.0.113.7", "Uptime", "monitor") })
+	require.NoError(t, err)
+	assert.Contains(t, output, "Bot IP range 203.0.113.7/32 (Uptime) added")
+
+	mock.ExpectExec(`DELETE FROM bot_ip_range`).WithArgs("198.51.100.0/24").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.EqualError(t, runBotsRangeRemove("198.51.100.0/24"), "bot IP range 198.51.100.0/24 not found")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
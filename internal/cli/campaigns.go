@@ -0,0 +1,234 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/spf13/cobra"
+)
+
+// CampaignStat holds attribution metrics for a single UTM value
+type CampaignStat struct {
+	Name           string  `json:"name"`
+	Visitors       int64   `json:"visitors"`
+	Pageviews      int64   `json:"pageviews"`
+	Conversions    int64   `json:"conversions"`
+	ConversionRate float64 `json:"conversion_rate"`
+}
+
+var getCampaignStatsFn = GetCampaignStats
+
+// Campaigns command flags
+var (
+	campaignsBy     string
+	campaignsDays   int
+	campaignsTop    int
+	campaignsGoal   string
+	campaignsFormat string
+)
+
+var statsCampaignsCmd = &cobra.Command{
+	Use:   "campaigns <website-domain> [--by <utm-param>] [--days <N>] [--top <N>] [--goal <event>] [--format json|table|csv]",
+	Short: "Show UTM campaign performance",
+	Long: `Display visitors, pageviews and conversions per UTM campaign.
+
+Sessions are attributed to the UTM values they landed with. A conversion is an
+attributed session that fired a custom event (or the --goal event when given).
+
+Valid UTM parameters for --by:
+  source, medium, campaign, content, term
+
+Options:
+  --by          UTM parameter to group by (default campaign)
+  --days N      Time period in days (1-365, default 7)
+  --top N       Number of items to show (1-100, default 10)
+  --goal        Custom event name counted as a conversion (default: any custom event)
+  --format      Output format: json, table, csv (default table)
+
+Examples:
+  kaunta stats campaigns mysite.com
+  kaunta stats campaigns mysite.com --by source --goal signup --days 30`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runStatsCampaigns(args[0], campaignsBy, campaignsDays, campaignsTop, campaignsGoal, campaignsFormat)
+	},
+}
+
+func runStatsCampaigns(domain string, by string, days int, top int, goal string, format string) error {
+	if by == "" {
+		by = "campaign"
+	}
+
+	validParams := map[string]bool{
+		"source":   true,
+		"medium":   true,
+		"campaign": true,
+		"content":  true,
+		"term":     true,
+	}
+
+	if !validParams[by] {
+		return fmt.Errorf("invalid UTM parameter: %s (valid: source, medium, campaign, content, term)", by)
+	}
+
+	if days < 1 || days > 365 {
+		return fmt.Errorf("days must be between 1 and 365")
+	}
+
+	if top < 1 || top > 100 {
+		return fmt.Errorf("top must be between 1 and 100")
+	}
+
+	if format == "" {
+		format = "table"
+	}
+
+	if database.DB == nil {
+		if err := connectDatabase(); err != nil {
+			return fmt.Errorf("database connection failed: %w", err)
+		}
+		defer func() { _ = closeDatabase() }()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	websiteID, err := getWebsiteIDByDomainFn(ctx, domain)
+	if err != nil {
+		return err
+	}
+
+	campaigns, err := getCampaignStatsFn(ctx, database.DB, websiteID, by, days, top, goal)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		return outputCampaignsJSON(campaigns)
+	case "csv":
+		return outputCampaignsCSV(campaigns)
+	case "table":
+		return outputCampaignsTable(campaigns, by)
+	default:
+		return fmt.Errorf("invalid format: %s (use json, table, or csv)", format)
+	}
+}
+
+// GetCampaignStats returns UTM attribution metrics using get_utm_breakdown()
+func GetCampaignStats(ctx context.Context, db *sql.DB, websiteID string, by string, days int, limit int, goal string) ([]*CampaignStat, error) {
+	parsedID, err := uuid.Parse(websiteID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid website ID: %w", err)
+	}
+
+	var goalParam interface{}
+	if goal != "" {
+		goalParam = goal
+	}
+
+	query := `
+		SELECT name, visitors, pageviews, conversions
+		FROM get_utm_breakdown($1, $2, $3, $4, 0, $5)`
+
+	rows, err := db.QueryContext(ctx, query, parsedID, by, days, limit, goalParam)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query campaigns: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	campaigns := []*CampaignStat{}
+	for rows.Next() {
+		stat := &CampaignStat{}
+		if err := rows.Scan(&stat.Name, &stat.Visitors, &stat.Pageviews, &stat.Conversions); err != nil {
+			continue
+		}
+		if stat.Visitors > 0 {
+			stat.ConversionRate = float64(stat.Conversions) / float64(stat.Visitors) * 100
+		}
+		campaigns = append(campaigns, stat)
+	}
+
+	return campaigns, rows.Err()
+}
+
+func outputCampaignsJSON(campaigns []*CampaignStat) error {
+	data, err := json.MarshalIndent(campaigns, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func outputCampaignsTable(campaigns []*CampaignStat, by string) error {
+	if len(campaigns) == 0 {
+		fmt.Printf("No campaign data available for utm_%s\n", by)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer func() { _ = w.Flush() }()
+
+	_, _ = fmt.Fprintf(w, "UTM_%s\tVISITORS\tPAGEVIEWS\tCONVERSIONS\tCONV. RATE\n", strings.ToUpper(by))
+	_, _ = fmt.Fprintf(w, "----\t--------\t---------\t-----------\t----------\n")
+
+	l := outputLocale
+	for _, c := range campaigns {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			c.Name,
+			l.Int(c.Visitors),
+			l.Int(c.Pageviews),
+			l.Int(c.Conversions),
+			l.Percent(c.ConversionRate),
+		)
+	}
+
+	return nil
+}
+
+func outputCampaignsCSV(campaigns []*CampaignStat) error {
+	w := csv.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	// Write header
+	err := w.Write([]string{"name", "visitors", "pageviews", "conversions", "conversion_rate"})
+	if err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	// Write rows
+	for _, c := range campaigns {
+		err := w.Write([]string{
+			c.Name,
+			fmt.Sprintf("%d", c.Visitors),
+			fmt.Sprintf("%d", c.Pageviews),
+			fmt.Sprintf("%d", c.Conversions),
+			fmt.Sprintf("%.1f", c.ConversionRate),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	statsCmd.AddCommand(statsCampaignsCmd)
+
+	statsCampaignsCmd.Flags().StringVarP(&campaignsBy, "by", "b", "campaign", "UTM parameter to group by (source, medium, campaign, content, term)")
+	statsCampaignsCmd.Flags().IntVarP(&campaignsDays, "days", "d", 7, "Time period in days (1-365)")
+	statsCampaignsCmd.Flags().IntVarP(&campaignsTop, "top", "t", 10, "Number of items to show (1-100)")
+	statsCampaignsCmd.Flags().StringVar(&campaignsGoal, "goal", "", "Custom event name counted as a conversion")
+	statsCampaignsCmd.Flags().StringVarP(&campaignsFormat, "format", "f", "table", "Output format (json, table, csv)")
+}
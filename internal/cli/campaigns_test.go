@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunStatsCampaignsTable(t *testing.T) {
+	stubDB(t)
+	stubConnectClose(t)
+
+	stubWebsiteIDLookup(t, func(ctx context.Context, domain string) (string, error) {
+		return "site-123", nil
+	})
+
+	stubCampaignFetcher(t, func(ctx context.Context, db *sql.DB, websiteID string, by string, days int, limit int, goal string) ([]*CampaignStat, error) {
+		assert.Equal(t, "site-123", websiteID)
+		assert.Equal(t, "source", by)
+		assert.Equal(t, "signup", goal)
+		return []*CampaignStat{
+			{Name: "newsletter", Visitors: 40, Pageviews: 90, Conversions: 10, ConversionRate: 25},
+		}, nil
+	})
+
+	output, err := captureOutput(t, func() error {
+		return runStatsCampaigns("example.com", "source", 7, 10, "signup", "table")
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "UTM_SOURCE")
+	assert.Contains(t, output, "newsletter")
+	assert.Contains(t, output, "25.0%")
+}
+
+func TestRunStatsCampaignsDefaultsToCampaign(t *testing.T) {
+	stubDB(t)
+	stubConnectClose(t)
+
+	stubWebsiteIDLookup(t, func(ctx context.Context, domain string) (string, error) {
+		return "site-123", nil
+	})
+
+	stubCampaignFetcher(t, func(ctx context.Context, db *sql.DB, websiteID string, by string, days int, limit int, goal string) ([]*CampaignStat, error) {
+		assert.Equal(t, "campaign", by)
+		return []*CampaignStat{}, nil
+	})
+
+	output, err := captureOutput(t, func() error {
+		return runStatsCampaigns("example.com", "", 7, 10, "", "csv")
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "name,visitors,pageviews,conversions,conversion_rate")
+}
+
+func TestRunStatsCampaignsInvalidParam(t *testing.T) {
+	err := runStatsCampaigns("example.com", "gclid", 7, 10, "", "table")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid UTM parameter")
+}
+
+func stubCampaignFetcher(t *testing.T, fn func(context.Context, *sql.DB, string, string, int, int, string) ([]*CampaignStat, error)) {
+	t.Helper()
+	original := getCampaignStatsFn
+	getCampaignStatsFn = fn
+	t.Cleanup(func() {
+		getCampaignStatsFn = original
+	})
+}
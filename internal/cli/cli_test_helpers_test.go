@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"context"
 	"database/sql"
 	"io"
 	"os"
@@ -8,6 +9,7 @@ import (
 	"time"
 
 	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/realtime"
 	"github.com/stretchr/testify/require"
 )
 
@@ -61,6 +63,15 @@ func stubTickerFactory(t *testing.T, fn func(time.Duration) (<-chan time.Time, f
 	})
 }
 
+func stubLiveEvents(t *testing.T, fn func(context.Context, string) (<-chan realtime.EventPayload, error)) {
+	t.Helper()
+	original := liveEventsFn
+	liveEventsFn = fn
+	t.Cleanup(func() {
+		liveEventsFn = original
+	})
+}
+
 func stubSignalNotify(t *testing.T, fn func(chan<- os.Signal, ...os.Signal)) {
 	t.Helper()
 	original := signalNotifyFunc
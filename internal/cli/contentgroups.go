@@ -0,0 +1,328 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/contentgroups"
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/stats"
+)
+
+// ContentGroupStat compares the traffic and engagement of one content group
+type ContentGroupStat struct {
+	Group          string  `json:"group"`
+	Pages          int64   `json:"pages"`
+	Visitors       int64   `json:"visitors"`
+	Pageviews      int64   `json:"pageviews"`
+	BounceRate     float64 `json:"bounce_rate"`
+	AvgEngagement  float64 `json:"avg_engagement_seconds"`
+	AvgScrollDepth float64 `json:"avg_scroll_depth"`
+}
+
+var getContentGroupStatsFn = GetContentGroupStats
+
+// Content groups command flags
+var (
+	contentGroupsDays   int
+	contentGroupsFormat string
+)
+
+var websiteContentGroupCmd = &cobra.Command{
+	Use:   "content-group",
+	Short: "Manage a website's content group rules",
+	Long: `Group pages into sections such as blog, docs or marketing with path
+patterns. * matches anything: "/blog/*" matches every post, "/pricing" only
+that page.
+
+Rules are applied at query time in the order they were added; the first match
+wins and other paths are reported as Other. Editing rules regroups past
+traffic too. Use the groups with 'kaunta stats breakdown <domain> --by
+content-group' and 'kaunta stats content-groups <domain>'.`,
+}
+
+var websiteContentGroupAddCmd = &cobra.Command{
+	Use:   "add <domain> <pattern> <group>",
+	Short: "Add a content group rule",
+	Long: `Append a rule mapping a path pattern to a group. Adding a pattern again
+changes its group and keeps its place in the order.
+
+Examples:
+  kaunta website content-group add example.com '/blog/*' Blog
+  kaunta website content-group add example.com '/docs*' Docs
+  kaunta website content-group add example.com / Marketing`,
+	Args: cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteContentGroupAdd(args[0], args[1], args[2])
+	},
+}
+
+var websiteContentGroupListCmd = &cobra.Command{
+	Use:   "list <domain>",
+	Short: "List a website's content group rules in evaluation order",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteContentGroupList(args[0])
+	},
+}
+
+var websiteContentGroupRemoveCmd = &cobra.Command{
+	Use:   "remove <domain> <pattern>",
+	Short: "Remove a content group rule",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteContentGroupRemove(args[0], args[1])
+	},
+}
+
+var statsContentGroupsCmd = &cobra.Command{
+	Use:   "content-groups <website-domain> [--days <N>] [--format json|table|csv]",
+	Short: "Compare content groups",
+	Long: `Compare the website's content groups (see 'kaunta website content-group'):
+pages, visitors, pageviews, bounce rate, average engagement time and scroll
+depth per group.
+
+A visitor bounced when the session had a single pageview. Engagement time and
+scroll depth are the tracker's measurements per pageview.
+
+Examples:
+  kaunta stats content-groups mysite.com
+  kaunta stats content-groups mysite.com --days 30 --format csv`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runStatsContentGroups(args[0], contentGroupsDays, contentGroupsFormat)
+	},
+}
+
+func runWebsiteContentGroupAdd(domain, pattern, group string) error {
+	if err := contentgroups.Validate(pattern, group); err != nil {
+		return err
+	}
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		if err := contentgroups.Add(ctx, database.DB, websiteID, pattern, group); err != nil {
+			return err
+		}
+		fmt.Printf("%s pages matching %s are now grouped as %q\n", domain, pattern, group)
+		return nil
+	})
+}
+
+func runWebsiteContentGroupList(domain string) error {
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		rules, err := contentgroups.List(ctx, database.DB, websiteID)
+		if err != nil {
+			return err
+		}
+		if len(rules) == 0 {
+			fmt.Printf("%s has no content group rules\n", domain)
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "#\tPATTERN\tGROUP")
+		_, _ = fmt.Fprintln(w, "-\t-------\t-----")
+		for i, r := range rules {
+			_, _ = fmt.Fprintf(w, "%d\t%s\t%s\n", i+1, r.Pattern, r.Group)
+		}
+		return w.Flush()
+	})
+}
+
+func runWebsiteContentGroupRemove(domain, pattern string) error {
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		err := contentgroups.Remove(ctx, database.DB, websiteID, pattern)
+		if errors.Is(err, contentgroups.ErrNotFound) {
+			return fmt.Errorf("no content group rule for %s on %s", pattern, domain)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Content group rule %s removed from %s\n", pattern, domain)
+		return nil
+	})
+}
+
+func runStatsContentGroups(domain string, days int, format string) error {
+	if days < 1 || days > 365 {
+		return fmt.Errorf("days must be between 1 and 365")
+	}
+
+	if format == "" {
+		format = "table"
+	}
+
+	if database.DB == nil {
+		if err := connectDatabase(); err != nil {
+			return fmt.Errorf("database connection failed: %w", err)
+		}
+		defer func() { _ = closeDatabase() }()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	websiteID, err := getWebsiteIDByDomainFn(ctx, domain)
+	if err != nil {
+		return err
+	}
+
+	groups, err := getContentGroupStatsFn(ctx, database.DB, websiteID, days)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		return outputContentGroupsJSON(groups)
+	case "csv":
+		return outputContentGroupsCSV(groups)
+	case "table":
+		return outputContentGroupsTable(groups)
+	default:
+		return fmt.Errorf("invalid format: %s (use json, table, or csv)", format)
+	}
+}
+
+// GetContentGroupStats compares the range's pageviews per content group
+func GetContentGroupStats(ctx context.Context, db *sql.DB, websiteID string, days int) ([]*ContentGroupStat, error) {
+	parsedID, err := uuid.Parse(websiteID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid website ID: %w", err)
+	}
+
+	rules, err := stats.ContentGroupRules(ctx, db, parsedID)
+	if err != nil {
+		return nil, err
+	}
+	group, groupArgs := contentgroups.CaseExpr(rules, "e.url_path", 3)
+
+	query := fmt.Sprintf(`
+		WITH events AS (
+			SELECT
+				e.session_id,
+				e.url_path,
+				e.engagement_time,
+				e.scroll_depth,
+				%s AS grp,
+				COUNT(*) OVER (PARTITION BY e.session_id) AS session_pageviews
+			FROM website_event e
+			WHERE e.website_id = $1
+			  AND e.created_at >= NOW() - INTERVAL '1 day' * $2
+			  AND e.event_type = 1
+			  AND e.url_path IS NOT NULL
+		)
+		SELECT
+			grp,
+			COUNT(DISTINCT url_path) AS pages,
+			COUNT(DISTINCT session_id) AS visitors,
+			COUNT(*) AS pageviews,
+			COALESCE(COUNT(DISTINCT session_id) FILTER (WHERE session_pageviews = 1)::float
+				/ NULLIF(COUNT(DISTINCT session_id), 0) * 100, 0) AS bounce_rate,
+			COALESCE(AVG(engagement_time) / 1000.0, 0)::float AS avg_engagement,
+			COALESCE(AVG(scroll_depth), 0)::float AS avg_scroll_depth
+		FROM events
+		GROUP BY grp
+		ORDER BY pageviews DESC`, group)
+
+	args := append([]interface{}{parsedID, days}, groupArgs...)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query content groups: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	groups := []*ContentGroupStat{}
+	for rows.Next() {
+		stat := &ContentGroupStat{}
+		if err := rows.Scan(&stat.Group, &stat.Pages, &stat.Visitors, &stat.Pageviews,
+			&stat.BounceRate, &stat.AvgEngagement, &stat.AvgScrollDepth); err != nil {
+			continue
+		}
+		groups = append(groups, stat)
+	}
+
+	return groups, rows.Err()
+}
+
+func outputContentGroupsJSON(groups []*ContentGroupStat) error {
+	data, err := json.MarshalIndent(groups, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func outputContentGroupsTable(groups []*ContentGroupStat) error {
+	if len(groups) == 0 {
+		fmt.Println("No content group data available")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer func() { _ = w.Flush() }()
+
+	_, _ = fmt.Fprintln(w, "GROUP\tPAGES\tVISITORS\tPAGEVIEWS\tBOUNCE RATE\tAVG ENGAGEMENT\tAVG SCROLL")
+	_, _ = fmt.Fprintln(w, "-----\t-----\t--------\t---------\t-----------\t--------------\t----------")
+
+	l := outputLocale
+	for _, g := range groups {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%ss\t%s\n",
+			g.Group,
+			l.Int(g.Pages),
+			l.Int(g.Visitors),
+			l.Int(g.Pageviews),
+			l.Percent(g.BounceRate),
+			l.Float(g.AvgEngagement, 1),
+			l.percentSign(l.Float(g.AvgScrollDepth, 0)),
+		)
+	}
+
+	return nil
+}
+
+func outputContentGroupsCSV(groups []*ContentGroupStat) error {
+	w := csv.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	err := w.Write([]string{"group", "pages", "visitors", "pageviews", "bounce_rate", "avg_engagement_seconds", "avg_scroll_depth"})
+	if err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for _, g := range groups {
+		err := w.Write([]string{
+			g.Group,
+			fmt.Sprintf("%d", g.Pages),
+			fmt.Sprintf("%d", g.Visitors),
+			fmt.Sprintf("%d", g.Pageviews),
+			fmt.Sprintf("%.1f", g.BounceRate),
+			fmt.Sprintf("%.1f", g.AvgEngagement),
+			fmt.Sprintf("%.1f", g.AvgScrollDepth),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	websiteCmd.AddCommand(websiteContentGroupCmd)
+	websiteContentGroupCmd.AddCommand(websiteContentGroupAddCmd, websiteContentGroupListCmd, websiteContentGroupRemoveCmd)
+
+	statsCmd.AddCommand(statsContentGroupsCmd)
+	statsContentGroupsCmd.Flags().IntVarP(&contentGroupsDays, "days", "d", 7, "Time period in days (1-365)")
+	statsContentGroupsCmd.Flags().StringVarP(&contentGroupsFormat, "format", "f", "table", "Output format (json, table, csv)")
+}
@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/store"
+)
+
+func expectContentGroupRules(mock sqlmock.Sqlmock, websiteID uuid.UUID) {
+	mock.ExpectQuery(`SELECT pattern, group_name, position\s+FROM content_group_rule`).WithArgs(websiteID).
+		WillReturnRows(sqlmock.NewRows([]string{"pattern", "group_name", "position"}).
+			AddRow("/blog/*", "Blog", 1).
+			AddRow("/docs*", "Docs", 2))
+}
+
+func TestGetContentGroupStats(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	websiteID := uuid.New()
+	expectContentGroupRules(mock, websiteID)
+	mock.ExpectQuery(`CASE WHEN e.url_path LIKE \$3 ESCAPE '\\' THEN \$4 WHEN e.url_path LIKE \$5 ESCAPE '\\' THEN \$6 ELSE 'Other' END AS grp`).
+		WithArgs(websiteID, 30, "/blog/%", "Blog", "/docs%", "Docs").
+		WillReturnRows(sqlmock.NewRows([]string{"grp", "pages", "visitors", "pageviews", "bounce_rate", "avg_engagement", "avg_scroll_depth"}).
+			AddRow("Blog", 42, 300, 410, 71.5, 48.2, 63.0).
+			AddRow("Docs", 18, 120, 390, 22.0, 95.4, 55.0))
+
+	groups, err := GetContentGroupStats(context.Background(), db, websiteID.String(), 30)
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+	assert.Equal(t, &ContentGroupStat{Group: "Docs", Pages: 18, Visitors: 120, Pageviews: 390,
+		BounceRate: 22.0, AvgEngagement: 95.4, AvgScrollDepth: 55.0}, groups[1])
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetBreakdownStatsContentGroup(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	websiteID := uuid.New()
+	expectContentGroupRules(mock, websiteID)
+	mock.ExpectQuery(`CASE WHEN e.url_path LIKE \$4 .* ELSE 'Other' END AS name`).
+		WithArgs(websiteID, 7, 10, "/blog/%", "Blog", "/docs%", "Docs").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "visitors", "pageviews", "bounce_rate"}).
+			AddRow("Blog", 30, 41, 70.0))
+
+	stats, err := GetBreakdownStats(context.Background(), db, websiteID.String(), "content-group", 7, 10, store.Filters{})
+	require.NoError(t, err)
+	require.Len(t, stats.Items, 1)
+	assert.Equal(t, "Blog", stats.Items[0].Name)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetContentGroupStatsWithoutRules(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	websiteID := uuid.New()
+	mock.ExpectQuery(`FROM content_group_rule`).WithArgs(websiteID).
+		WillReturnRows(sqlmock.NewRows([]string{"pattern", "group_name", "position"}))
+
+	_, err = GetContentGroupStats(context.Background(), db, websiteID.String(), 7)
+	assert.EqualError(t, err, "no content group rules (add one with 'kaunta website content-group add')")
+}
+
+func TestRunStatsContentGroupsTable(t *testing.T) {
+	stubDB(t)
+	stubConnectClose(t)
+
+	stubWebsiteIDLookup(t, func(ctx context.Context, domain string) (string, error) {
+		return uuid.NewString(), nil
+	})
+	original := getContentGroupStatsFn
+	t.Cleanup(func() { getContentGroupStatsFn = original })
+	getContentGroupStatsFn = func(ctx context.Context, db *sql.DB, websiteID string, days int) ([]*ContentGroupStat, error) {
+		return []*ContentGroupStat{{Group: "Blog", Pages: 42, Visitors: 300, Pageviews: 410, BounceRate: 71.5, AvgEngagement: 48.2, AvgScrollDepth: 63}}, nil
+	}
+
+	output, err := captureOutput(t, func() error { return runStatsContentGroups("example.com", 7, "table") })
+	require.NoError(t, err)
+	assert.Contains(t, output, "AVG ENGAGEMENT")
+	assert.Contains(t, output, "Blog   42     300       410        71.5%        48.2s           63%")
+}
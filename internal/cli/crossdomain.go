@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/crossdomain"
+	"github.com/seuros/kaunta/internal/database"
+)
+
+var websiteLinkCmd = &cobra.Command{
+	Use:   "link",
+	Short: "Manage cross-domain linking between websites",
+	Long: `Link websites tracked separately, such as a marketing site and an app on its
+own domain, so a visitor moving from one to the other keeps their journey.
+
+Add the other website's domains to each tracker's data-link-domains. Links
+to them then carry the visitor's session token (?_kaunta=...), and the first
+pageview on the other side is recorded as linked to the session it came
+from. It takes over that visit's referrer and campaign, so signups in the
+app stay attributed to the campaign that brought the visitor to the
+marketing site. Cross-domain linking requires PostgreSQL.`,
+}
+
+var websiteLinkAddCmd = &cobra.Command{
+	Use:   "add <domain> <other-domain>",
+	Short: "Link two websites",
+	Long: `Link two websites, both ways.
+
+Examples:
+  kaunta website link add example.com app.example.io`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteLinkAdd(args[0], args[1])
+	},
+}
+
+var websiteLinkListCmd = &cobra.Command{
+	Use:   "list <domain>",
+	Short: "List the websites linked to a website",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteLinkList(args[0])
+	},
+}
+
+var websiteLinkRemoveCmd = &cobra.Command{
+	Use:   "remove <domain> <other-domain>",
+	Short: "Unlink two websites",
+	Long: `Unlink two websites. Sessions already linked stay linked; tokens in links
+between them are ignored from now on.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteLinkRemove(args[0], args[1])
+	},
+}
+
+// withLinkedWebsites resolves both websites of a link
+func withLinkedWebsites(domain, other string, fn func(ctx context.Context, a, b uuid.UUID, domain, other string) error) error {
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		website, err := GetWebsiteByDomain(ctx, other, nil)
+		if err != nil {
+			return err
+		}
+		otherID, err := uuid.Parse(website.WebsiteID)
+		if err != nil {
+			return err
+		}
+		return fn(ctx, websiteID, otherID, domain, website.Domain)
+	})
+}
+
+func runWebsiteLinkAdd(domain, other string) error {
+	return withLinkedWebsites(domain, other, func(ctx context.Context, a, b uuid.UUID, domain, other string) error {
+		if err := crossdomain.Link(ctx, database.DB, a, b); err != nil {
+			return err
+		}
+		fmt.Printf("%s and %s are linked\n", domain, other)
+		fmt.Printf("Add data-link-domains=\"%s\" to the tracker on %s and data-link-domains=\"%s\" on %s\n",
+			other, domain, domain, other)
+		return nil
+	})
+}
+
+func runWebsiteLinkList(domain string) error {
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		linked, err := crossdomain.List(ctx, database.DB, websiteID)
+		if err != nil {
+			return err
+		}
+		if len(linked) == 0 {
+			fmt.Printf("%s is not linked to other websites\n", domain)
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "DOMAIN\tSESSIONS LINKED (30D)")
+		_, _ = fmt.Fprintln(w, "------\t---------------------")
+		for _, l := range linked {
+			_, _ = fmt.Fprintf(w, "%s\t%d\n", l.Domain, l.Sessions)
+		}
+		return w.Flush()
+	})
+}
+
+func runWebsiteLinkRemove(domain, other string) error {
+	return withLinkedWebsites(domain, other, func(ctx context.Context, a, b uuid.UUID, domain, other string) error {
+		removed, err := crossdomain.Unlink(ctx, database.DB, a, b)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			return fmt.Errorf("%s and %s are not linked", domain, other)
+		}
+		fmt.Printf("%s and %s are no longer linked\n", domain, other)
+		return nil
+	})
+}
+
+func init() {
+	websiteCmd.AddCommand(websiteLinkCmd)
+	websiteLinkCmd.AddCommand(websiteLinkAddCmd)
+	websiteLinkCmd.AddCommand(websiteLinkListCmd)
+	websiteLinkCmd.AddCommand(websiteLinkRemoveCmd)
+}
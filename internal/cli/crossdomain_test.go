@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunWebsiteLink(t *testing.T) {
+	mock := mockJobsDB(t)
+	marketingID, appID := uuid.New(), uuid.New()
+
+	expectWebsiteLookup(mock, marketingID, "example.com")
+	expectWebsiteLookup(mock, appID, "app.example.io")
+	mock.ExpectExec("INSERT INTO website_link").WithArgs(marketingID, appID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	output, err := captureOutput(t, func() error { return runWebsiteLinkAdd("example.com", "app.example.io") })
+	require.NoError(t, err)
+	assert.Contains(t, output, "example.com and app.example.io are linked")
+	assert.Contains(t, output, `data-link-domains="app.example.io" to the tracker on example.com`)
+
+	expectWebsiteLookup(mock, marketingID, "example.com")
+	mock.ExpectQuery("FROM website_link").WithArgs(marketingID).
+		WillReturnRows(sqlmock.NewRows([]string{"website_id", "domain", "sessions"}).
+			AddRow(appID, "app.example.io", int64(42)))
+
+	output, err = captureOutput(t, func() error { return runWebsiteLinkList("example.com") })
+	require.NoError(t, err)
+	assert.Regexp(t, `app\.example\.io\s+42`, output)
+
+	expectWebsiteLookup(mock, marketingID, "example.com")
+	expectWebsiteLookup(mock, appID, "app.example.io")
+	mock.ExpectExec("DELETE FROM website_link").WithArgs(marketingID, appID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = runWebsiteLinkRemove("example.com", "app.example.io")
+	assert.ErrorContains(t, err, "are not linked")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
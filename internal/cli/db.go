@@ -10,6 +10,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/store"
 )
 
 // WebsiteDetail holds complete website information for CLI operations
@@ -239,8 +240,41 @@ func CreateWebsite(ctx context.Context, domain, name string, allowedDomains []st
 	return &website, nil
 }
 
+// CreateWebsiteInStore creates a website through a Store (used for the SQLite backend)
+func CreateWebsiteInStore(ctx context.Context, st store.Store, domain, name string, allowedDomains []string) (*WebsiteDetail, error) {
+	if err := validateDomain(domain); err != nil {
+		return nil, err
+	}
+
+	// Use name as domain if name is empty
+	if name == "" {
+		name = domain
+	}
+
+	websiteID, err := st.CreateWebsite(ctx, domain, name, allowedDomains)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return nil, fmt.Errorf("website with domain '%s' already exists", domain)
+		}
+		return nil, fmt.Errorf("failed to create website: %w", err)
+	}
+
+	now := time.Now().UTC()
+	if allowedDomains == nil {
+		allowedDomains = []string{}
+	}
+	return &WebsiteDetail{
+		WebsiteID:      websiteID.String(),
+		Domain:         domain,
+		Name:           name,
+		AllowedDomains: allowedDomains,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}, nil
+}
+
 // UpdateWebsite updates an existing website by domain
-func UpdateWebsite(ctx context.Context, domain string, name *string, allowedDomains []string) (*WebsiteDetail, error) {
+func UpdateWebsite(ctx context.Context, domain string, name *string, allowedDomains []string, respectDNT, timezone *string) (*WebsiteDetail, error) {
 	// Get website first
 	website, err := GetWebsiteByDomain(ctx, domain, nil)
 	if err != nil {
@@ -264,6 +298,18 @@ func UpdateWebsite(ctx context.Context, domain string, name *string, allowedDoma
 		allowedDomainsJSON := string(data)
 		updates = append(updates, fmt.Sprintf("allowed_domains = $%d::jsonb", argIndex))
 		args = append(args, allowedDomainsJSON)
+		argIndex++
+	}
+
+	if respectDNT != nil {
+		updates = append(updates, fmt.Sprintf("respect_dnt = $%d", argIndex))
+		args = append(args, *respectDNT)
+		argIndex++
+	}
+
+	if timezone != nil {
+		updates = append(updates, fmt.Sprintf("timezone = $%d", argIndex))
+		args = append(args, *timezone)
 	}
 
 	// Build update query
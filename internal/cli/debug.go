@@ -0,0 +1,238 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/debugcapture"
+)
+
+var (
+	debugSample string
+	debugTTL    time.Duration
+	debugLimit  int
+	debugFormat string
+)
+
+var websiteDebugCmd = &cobra.Command{
+	Use:   "debug",
+	Short: "Capture a sample of a website's tracking requests",
+	Long: `Capture a sample of a website's tracking requests, to see what a client
+really sends when its events go missing or look wrong: the request's
+headers and body and the answer it got.
+
+Credentials and cookies are never stored, and visitor IPs (also in
+X-Forwarded-For and similar headers) only as ip_mode allows. A capture
+ends after its TTL; captured requests are deleted after 3 days. Captures
+take up to a minute to reach running servers. Debug capture requires
+PostgreSQL.`,
+}
+
+var websiteDebugEnableCmd = &cobra.Command{
+	Use:   "enable <domain> [--sample 1%] [--ttl 24h]",
+	Short: "Start capturing a website's tracking requests",
+	Long: `Start capturing a sample of a website's tracking requests, or change the
+sample and TTL of a running capture.
+
+Examples:
+  kaunta website debug enable example.com --sample 1% --ttl 24h
+  kaunta website debug enable example.com --sample 100% --ttl 15m`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteDebugEnable(args[0], debugSample, debugTTL)
+	},
+}
+
+var websiteDebugDisableCmd = &cobra.Command{
+	Use:   "disable <domain>",
+	Short: "Stop capturing a website's tracking requests",
+	Long: `Stop capturing a website's tracking requests. The requests already
+captured are kept until they expire or are cleared.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteDebugDisable(args[0])
+	},
+}
+
+var websiteDebugListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the running captures",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteDebugList()
+	},
+}
+
+var websiteDebugShowCmd = &cobra.Command{
+	Use:   "show <domain> [--limit 20] [--format table|json]",
+	Short: "Show a website's captured requests",
+	Long: `Show a website's captured requests, newest first.
+
+Examples:
+  kaunta website debug show example.com
+  kaunta website debug show example.com --limit 100 --format json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteDebugShow(args[0], debugLimit, debugFormat)
+	},
+}
+
+var websiteDebugClearCmd = &cobra.Command{
+	Use:   "clear <domain>",
+	Short: "Delete a website's captured requests",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteDebugClear(args[0])
+	},
+}
+
+func runWebsiteDebugEnable(domain, sample string, ttl time.Duration) error {
+	rate, err := debugcapture.ParseRate(sample)
+	if err != nil {
+		return err
+	}
+	if ttl <= 0 || ttl > debugcapture.MaxTTL {
+		return fmt.Errorf("ttl must be more than 0 and at most %s", formatTimeout(debugcapture.MaxTTL))
+	}
+
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		expiresAt, err := debugcapture.Enable(ctx, database.DB, websiteID, rate, ttl)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Capturing %s of the tracking requests of %s until %s\n",
+			formatRate(rate), domain, expiresAt.Local().Format("2006-01-02 15:04"))
+		fmt.Println("Running servers start within a minute; see them with: kaunta website debug show " + domain)
+		return nil
+	})
+}
+
+func runWebsiteDebugDisable(domain string) error {
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		err := debugcapture.Disable(ctx, database.DB, websiteID)
+		if errors.Is(err, debugcapture.ErrNotFound) {
+			return fmt.Errorf("%s has no debug capture", domain)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Debug capture of %s stopped\n", domain)
+		return nil
+	})
+}
+
+func runWebsiteDebugList() error {
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	captures, err := debugcapture.List(ctx, database.DB)
+	if err != nil {
+		return err
+	}
+	if len(captures) == 0 {
+		fmt.Println("No debug captures running")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "WEBSITE\tSAMPLE\tUNTIL\tCAPTURED")
+	_, _ = fmt.Fprintln(w, "-------\t------\t-----\t--------")
+	for _, c := range captures {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.Domain, formatRate(c.SampleRate),
+			c.ExpiresAt.Local().Format("2006-01-02 15:04"), outputLocale.Int(c.Captured))
+	}
+	return w.Flush()
+}
+
+func runWebsiteDebugShow(domain string, limit int, format string) error {
+	if limit < 1 || limit > 1000 {
+		return fmt.Errorf("limit must be between 1 and 1000")
+	}
+	if format != "table" && format != "json" {
+		return fmt.Errorf("invalid format: %s (use json or table)", format)
+	}
+
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		requests, err := debugcapture.Requests(ctx, database.DB, websiteID, limit)
+		if err != nil {
+			return err
+		}
+		if format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(requests)
+		}
+
+		if len(requests) == 0 {
+			fmt.Printf("No captured requests of %s\n", domain)
+			return nil
+		}
+		for i, r := range requests {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("#%d  %s  %s %s  %d\n", r.ID, r.CapturedAt.Local().Format("2006-01-02 15:04:05"), r.Method, r.URL, r.Status)
+			if r.IP != "" {
+				fmt.Printf("  IP: %s\n", r.IP)
+			}
+			keys := make([]string, 0, len(r.Headers))
+			for key := range r.Headers {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				fmt.Printf("  %s: %s\n", key, r.Headers[key])
+			}
+			if r.Body != "" {
+				fmt.Printf("  Body: %s\n", r.Body)
+			}
+			if r.Response != "" {
+				fmt.Printf("  Response: %s\n", r.Response)
+			}
+		}
+		return nil
+	})
+}
+
+func runWebsiteDebugClear(domain string) error {
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		n, err := debugcapture.Clear(ctx, database.DB, websiteID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Deleted %s captured requests of %s\n", outputLocale.Int(n), domain)
+		return nil
+	})
+}
+
+// formatRate shows a sample rate as a percentage (1%, 0.5%)
+func formatRate(rate float64) string {
+	return strconv.FormatFloat(rate*100, 'f', -1, 64) + "%"
+}
+
+func init() {
+	websiteCmd.AddCommand(websiteDebugCmd)
+	websiteDebugCmd.AddCommand(websiteDebugEnableCmd, websiteDebugDisableCmd, websiteDebugListCmd, websiteDebugShowCmd, websiteDebugClearCmd)
+
+	websiteDebugEnableCmd.Flags().StringVar(&debugSample, "sample", "1%", "Share of requests to capture, as a percentage or fraction")
+	websiteDebugEnableCmd.Flags().DurationVar(&debugTTL, "ttl", debugcapture.DefaultTTL, "How long to capture, at most 168h")
+	websiteDebugShowCmd.Flags().IntVarP(&debugLimit, "limit", "l", 20, "Requests to show (1-1000)")
+	websiteDebugShowCmd.Flags().StringVarP(&debugFormat, "format", "f", "table", "Output format (json, table)")
+}
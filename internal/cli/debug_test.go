@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunWebsiteDebugEnable(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectQuery(`INSERT INTO debug_capture`).
+		WithArgs(websiteID, 0.01, int64(86400)).
+		WillReturnRows(sqlmock.NewRows([]string{"expires_at"}).AddRow(time.Now().Add(24 * time.Hour)))
+
+	output, err := captureOutput(t, func() error { return runWebsiteDebugEnable("example.com", "1%", 24*time.Hour) })
+	require.NoError(t, err)
+	assert.Contains(t, output, "Capturing 1% of the tracking requests of example.com until")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunWebsiteDebugEnableRejectsBadValues(t *testing.T) {
+	assert.ErrorContains(t, runWebsiteDebugEnable("example.com", "150%", time.Hour), "invalid sample rate")
+	assert.ErrorContains(t, runWebsiteDebugEnable("example.com", "1%", 8*24*time.Hour), "at most 168h")
+	assert.ErrorContains(t, runWebsiteDebugEnable("example.com", "1%", 0), "ttl must be")
+}
+
+func TestRunWebsiteDebugShow(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+	headers, _ := json.Marshal(map[string]string{"User-Agent": "curl/8.0", "Cookie": "[redacted]"})
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectQuery(`FROM debug_request`).WithArgs(websiteID, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"request_id", "captured_at", "method", "url", "ip", "headers", "body", "status", "response"}).
+			AddRow(7, time.Now(), "POST", "/api/send", "", headers, `{"type":"event"}`, 400, `{"error":"Invalid website ID"}`))
+
+	output, err := captureOutput(t, func() error { return runWebsiteDebugShow("example.com", 20, "table") })
+	require.NoError(t, err)
+	assert.Contains(t, output, "POST /api/send  400")
+	assert.Contains(t, output, "  Cookie: [redacted]\n  User-Agent: curl/8.0\n")
+	assert.Contains(t, output, `  Body: {"type":"event"}`)
+	assert.NotContains(t, output, "IP:")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunWebsiteDebugShowRejectsBadValues(t *testing.T) {
+	assert.ErrorContains(t, runWebsiteDebugShow("example.com", 0, "table"), "limit must be")
+	assert.ErrorContains(t, runWebsiteDebugShow("example.com", 20, "csv"), "invalid format")
+}
+
+func TestFormatRate(t *testing.T) {
+	assert.Equal(t, "1%", formatRate(0.01))
+	assert.Equal(t, "0.5%", formatRate(0.005))
+	assert.Equal(t, "100%", formatRate(1))
+}
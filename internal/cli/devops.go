@@ -4,9 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
@@ -15,7 +17,10 @@ import (
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 
+	"github.com/seuros/kaunta/internal/cardinality"
 	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/dedup"
+	"github.com/seuros/kaunta/internal/eventloss"
 )
 
 // ============================================================
@@ -212,24 +217,41 @@ func validateOriginInDB(ctx context.Context, websiteID string, originURL string)
 // Diagnostics Command
 // ============================================================
 
+// Diagnostics statuses, each with the exit code of `kaunta diagnostics`
+const (
+	diagnosticsHealthy  = "healthy"
+	diagnosticsDegraded = "degraded"
+	diagnosticsCritical = "critical"
+)
+
+var diagnosticsExitCodes = map[string]int{
+	diagnosticsHealthy:  0,
+	diagnosticsDegraded: 1,
+	diagnosticsCritical: 2,
+}
+
 type DiagnosticsResult struct {
-	DatabaseConnected bool
-	PostgreSQLVersion string
-	ExtensionsLoaded  []string
-	WebsiteCount      int64
-	SessionCount      int64
-	EventCount        int64
-	OldestEvent       *time.Time
-	NewestEvent       *time.Time
-	PartitionCount    int
-	DiskUsageGB       float64
-	EventsPerMinute   float64
-	DataRetentionDays int
-	Status            string
+	DatabaseConnected bool               `json:"database_connected"`
+	PostgreSQLVersion string             `json:"postgresql_version,omitempty"`
+	ExtensionsLoaded  []string           `json:"extensions_loaded"`
+	WebsiteCount      int64              `json:"website_count"`
+	SessionCount      int64              `json:"session_count"`
+	EventCount        int64              `json:"event_count"`
+	OldestEvent       *time.Time         `json:"oldest_event"`
+	NewestEvent       *time.Time         `json:"newest_event"`
+	PartitionCount    int                `json:"partition_count"`
+	DiskUsageGB       float64            `json:"disk_usage_gb"`
+	EventsPerMinute   float64            `json:"events_per_minute"`
+	DataRetentionDays int                `json:"data_retention_days"`
+	EventLoss         *eventloss.Summary `json:"event_loss"`
+	Duplicates        *dedup.Summary     `json:"duplicates"`
+	Status            string             `json:"status"`
+	// Error is why the database couldn't be reached
+	Error string `json:"error,omitempty"`
 }
 
 var diagnosticsCmd = &cobra.Command{
-	Use:   "diagnostics [--full]",
+	Use:   "diagnostics [--full] [--format table|json]",
 	Short: "System health check",
 	Long: `Check database and system health without modifying data.
 
@@ -240,30 +262,80 @@ Displays:
   - Record counts
   - Data retention period
   - Event processing rate
-  - Disk space usage`,
+  - Event loss over the last 7 days (per website with --full)
+  - Duplicate events dropped over the last 7 days (per website with --full)
+  - Disk space usage
+
+The exit code follows the status, for health checks and cron monitors:
+  0  healthy      connected, extensions loaded and events recorded
+  1  degraded     connected, but extensions or events are missing
+  2  critical     the database can't be reached
+
+Examples:
+  kaunta diagnostics
+  kaunta diagnostics --format json`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		full, _ := cmd.Flags().GetBool("full")
-		return runDiagnostics(full)
+		format, _ := cmd.Flags().GetString("format")
+		err := runDiagnostics(full, format)
+		var exitErr *ExitError
+		if errors.As(err, &exitErr) {
+			// The report already says what is wrong
+			cmd.SilenceErrors, cmd.SilenceUsage = true, true
+		}
+		return err
 	},
 }
 
-func runDiagnostics(full bool) error {
-	if database.DB == nil {
-		if err := database.Connect(); err != nil {
-			return fmt.Errorf("database connection failed: %w", err)
-		}
-		defer func() { _ = database.Close() }()
+func runDiagnostics(full bool, format string) error {
+	if format != "table" && format != "json" {
+		return fmt.Errorf("invalid format: %s (use json or table)", format)
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	result, err := RunDiagnostics(ctx, database.DB)
+	var (
+		result *DiagnosticsResult
+		err    error
+	)
+	if database.DB == nil {
+		err = connectDatabase()
+		if err == nil {
+			defer func() { _ = closeDatabase() }()
+		}
+	}
+	if err == nil {
+		result, err = RunDiagnostics(ctx, database.DB)
+	}
 	if err != nil {
-		return fmt.Errorf("diagnostics failed: %w", err)
+		result = &DiagnosticsResult{ExtensionsLoaded: []string{}, Status: diagnosticsCritical}
+		result.Error = err.Error()
 	}
 
-	// Display results
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			return err
+		}
+	} else {
+		printDiagnostics(result)
+		if full && result.DatabaseConnected {
+			fmt.Println("=== Full Diagnostics Report ===")
+			_ = reportFullDiagnostics(ctx, database.DB)
+			reportEventLoss(result.EventLoss)
+			reportDuplicates(result.Duplicates)
+		}
+	}
+
+	if code := diagnosticsExitCodes[result.Status]; code != 0 {
+		return &ExitError{Code: code, Err: fmt.Errorf("diagnostics status: %s", result.Status)}
+	}
+	return nil
+}
+
+func printDiagnostics(result *DiagnosticsResult) {
 	fmt.Println("=== Kaunta System Diagnostics ===")
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
@@ -274,6 +346,9 @@ func runDiagnostics(full bool) error {
 		status = "FAIL"
 	}
 	_, _ = fmt.Fprintf(w, "Database Connected:\t%s\n", status)
+	if result.Error != "" {
+		_, _ = fmt.Fprintf(w, "Error:\t%s\n", result.Error)
+	}
 
 	if result.PostgreSQLVersion != "" {
 		_, _ = fmt.Fprintf(w, "PostgreSQL Version:\t%s\n", result.PostgreSQLVersion)
@@ -283,29 +358,40 @@ func runDiagnostics(full bool) error {
 		_, _ = fmt.Fprintf(w, "Extensions Loaded:\t%v\n", result.ExtensionsLoaded)
 	}
 
-	// Data
-	_, _ = fmt.Fprintf(w, "\nWebsites:\t%d\n", result.WebsiteCount)
-	_, _ = fmt.Fprintf(w, "Sessions:\t%d\n", result.SessionCount)
-	_, _ = fmt.Fprintf(w, "Events:\t%d\n", result.EventCount)
+	if result.DatabaseConnected {
+		// Data
+		_, _ = fmt.Fprintf(w, "\nWebsites:\t%d\n", result.WebsiteCount)
+		_, _ = fmt.Fprintf(w, "Sessions:\t%d\n", result.SessionCount)
+		_, _ = fmt.Fprintf(w, "Events:\t%d\n", result.EventCount)
 
-	if result.OldestEvent != nil {
-		_, _ = fmt.Fprintf(w, "Oldest Event:\t%s\n", result.OldestEvent.Format("2006-01-02 15:04:05"))
-	}
+		if result.OldestEvent != nil {
+			_, _ = fmt.Fprintf(w, "Oldest Event:\t%s\n", result.OldestEvent.Format("2006-01-02 15:04:05"))
+		}
 
-	if result.NewestEvent != nil {
-		_, _ = fmt.Fprintf(w, "Newest Event:\t%s\n", result.NewestEvent.Format("2006-01-02 15:04:05"))
-	}
+		if result.NewestEvent != nil {
+			_, _ = fmt.Fprintf(w, "Newest Event:\t%s\n", result.NewestEvent.Format("2006-01-02 15:04:05"))
+		}
+
+		if result.DataRetentionDays > 0 {
+			_, _ = fmt.Fprintf(w, "Data Retention:\t%d days\n", result.DataRetentionDays)
+		}
+
+		// Performance
+		if result.EventsPerMinute > 0 {
+			_, _ = fmt.Fprintf(w, "Events Per Minute:\t%.1f\n", result.EventsPerMinute)
+		}
 
-	if result.DataRetentionDays > 0 {
-		_, _ = fmt.Fprintf(w, "Data Retention:\t%d days\n", result.DataRetentionDays)
+		_, _ = fmt.Fprintf(w, "Partitions:\t%d\n", result.PartitionCount)
 	}
 
-	// Performance
-	if result.EventsPerMinute > 0 {
-		_, _ = fmt.Fprintf(w, "Events Per Minute:\t%.1f\n", result.EventsPerMinute)
+	if result.EventLoss != nil && result.EventLoss.Sent > 0 {
+		_, _ = fmt.Fprintf(w, "Event Loss (%dd):\t%.1f%% (%d of %d sequenced events)\n",
+			result.EventLoss.Days, result.EventLoss.LossRate, result.EventLoss.Lost, result.EventLoss.Sent)
 	}
 
-	_, _ = fmt.Fprintf(w, "Partitions:\t%d\n", result.PartitionCount)
+	if result.Duplicates != nil && result.Duplicates.Dropped > 0 {
+		_, _ = fmt.Fprintf(w, "Duplicates Dropped (%dd):\t%d\n", result.Duplicates.Days, result.Duplicates.Dropped)
+	}
 
 	// Storage
 	if result.DiskUsageGB > 0 {
@@ -315,13 +401,6 @@ func runDiagnostics(full bool) error {
 	_, _ = fmt.Fprintf(w, "\nStatus:\t%s\n", result.Status)
 
 	_ = w.Flush()
-
-	if full {
-		fmt.Println("=== Full Diagnostics Report ===")
-		_ = reportFullDiagnostics(ctx, database.DB)
-	}
-
-	return nil
 }
 
 func reportFullDiagnostics(ctx context.Context, db *sql.DB) error {
@@ -385,6 +464,39 @@ func reportFullDiagnostics(ctx context.Context, db *sql.DB) error {
 	return nil
 }
 
+// reportEventLoss breaks the event loss estimate down by website
+func reportEventLoss(summary *eventloss.Summary) {
+	if summary == nil || len(summary.Websites) == 0 {
+		return
+	}
+
+	fmt.Printf("\nEvent Loss (last %d days, lower bound):\n", summary.Days)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "  Website\tPages\tSent\tReceived\tLost\tLoss\n")
+	_, _ = fmt.Fprintf(w, "  -------\t-----\t----\t--------\t----\t----\n")
+	for _, site := range summary.Websites {
+		_, _ = fmt.Fprintf(w, "  %s\t%d\t%d\t%d\t%d\t%.1f%%\n",
+			site.Domain, site.Pages, site.Sent, site.Received, site.Lost, site.LossRate)
+	}
+	_ = w.Flush()
+}
+
+// reportDuplicates breaks the duplicate events dropped down by website
+func reportDuplicates(summary *dedup.Summary) {
+	if summary == nil || len(summary.Websites) == 0 {
+		return
+	}
+
+	fmt.Printf("\nDuplicates Dropped (last %d days):\n", summary.Days)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "  Website\tDropped\n")
+	_, _ = fmt.Fprintf(w, "  -------\t-------\n")
+	for _, site := range summary.Websites {
+		_, _ = fmt.Fprintf(w, "  %s\t%d\n", site.Domain, site.Dropped)
+	}
+	_ = w.Flush()
+}
+
 // ============================================================
 // Website Sync Command
 // ============================================================
@@ -521,65 +633,205 @@ func runWebsiteSync(filePath string, dryRun, replace bool) error {
 // ============================================================
 
 var migrateCmd = &cobra.Command{
-	Use:   "migrate [up|down|version] [--step <N>]",
+	Use:   "migrate [up|down|goto|force|version|functions] [version] [--step <N>] [--dry-run] [--apply]",
 	Short: "Manage database migrations",
 	Long: `Run database migrations.
 
 Subcommands:
-  up       Run pending migrations (default: all)
-  down     Rollback migrations
-  version  Show current migration version
+  up         Run pending migrations (default: all, --step N for the next N)
+  down       Roll back the last migration (--step N for the last N)
+  goto       Migrate up or down to a version; 0 rolls every migration back
+  force      Record a version as applied and clear the dirty state, without
+             running any SQL; use it after fixing a migration that failed
+             halfway by hand
+  version    Show current migration version
+  functions  Check the SQL functions the binary calls; --apply (re)creates
+             them from the definitions built into the binary
+
+With --dry-run, up, down and goto print the SQL they would run instead.
 
 Examples:
   kaunta migrate up
   kaunta migrate up --step 1
   kaunta migrate down --step 2
-  kaunta migrate version`,
+  kaunta migrate down --dry-run
+  kaunta migrate goto 50
+  kaunta migrate force 52
+  kaunta migrate version
+  kaunta migrate functions --apply`,
+	Args: cobra.MaximumNArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) == 0 {
 			args = []string{"up"}
 		}
 		action := args[0]
 		step, _ := cmd.Flags().GetInt("step")
+		apply, _ := cmd.Flags().GetBool("apply")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
 
-		return runMigrate(action, step)
+		return runMigrate(action, args[1:], step, apply, dryRun)
 	},
 }
 
-func runMigrate(action string, step int) error {
+var (
+	migrationVersion = database.GetMigrationVersion
+	migrateTo        = database.MigrateTo
+	forceMigration   = database.ForceMigration
+)
+
+func runMigrate(action string, args []string, step int, apply, dryRun bool) error {
 	databaseURL := os.Getenv("DATABASE_URL")
 	if databaseURL == "" {
 		return fmt.Errorf("DATABASE_URL environment variable not set")
 	}
 
+	var version uint
+	switch action {
+	case "goto", "force":
+		if len(args) != 1 {
+			return fmt.Errorf("%s needs a version (see: kaunta migrate version)", action)
+		}
+		v, err := strconv.ParseUint(args[0], 10, 0)
+		if err != nil {
+			return fmt.Errorf("invalid version: %s", args[0])
+		}
+		version = uint(v)
+	default:
+		if len(args) > 0 {
+			return fmt.Errorf("%s takes no version", action)
+		}
+	}
+	if step < 0 {
+		return fmt.Errorf("step must be positive")
+	}
+
 	switch action {
 	case "up":
-		return runMigrateUp(databaseURL, step)
+		return runMigrateUp(databaseURL, step, dryRun)
 	case "down":
-		return runMigrateDown(databaseURL, step)
+		return runMigrateDown(databaseURL, step, dryRun)
+	case "goto":
+		return runMigrateGoto(databaseURL, version, dryRun)
+	case "force":
+		return runMigrateForce(databaseURL, version)
 	case "version":
 		return runMigrateVersion(databaseURL)
+	case "functions":
+		return runMigrateFunctions(apply)
 	default:
-		return fmt.Errorf("unknown action: %s (use up, down, or version)", action)
+		return fmt.Errorf("unknown action: %s (use up, down, goto, force, version, or functions)", action)
 	}
 }
 
-func runMigrateUp(databaseURL string, steps int) error {
-	fmt.Println("Running migrations...")
-	if err := database.RunMigrations(databaseURL); err != nil {
+func runMigrateUp(databaseURL string, steps int, dryRun bool) error {
+	if steps == 0 && !dryRun {
+		fmt.Println("Running migrations...")
+		if err := database.RunMigrations(databaseURL); err != nil {
+			return err
+		}
+		fmt.Println("Migrations completed successfully")
+		return runMigrateVersion(databaseURL)
+	}
+
+	current, err := cleanMigrationVersion(databaseURL)
+	if err != nil {
 		return err
 	}
-	fmt.Println("Migrations completed successfully")
+	target, err := database.LatestMigration()
+	if err != nil {
+		return err
+	}
+	if steps > 0 {
+		if target, err = database.StepTarget(current, steps); err != nil {
+			return err
+		}
+	}
+	return migrateFromTo(databaseURL, current, target, dryRun)
+}
+
+func runMigrateDown(databaseURL string, steps int, dryRun bool) error {
+	if steps == 0 {
+		steps = 1
+	}
+	current, err := cleanMigrationVersion(databaseURL)
+	if err != nil {
+		return err
+	}
+	target, err := database.StepTarget(current, -steps)
+	if err != nil {
+		return err
+	}
+	return migrateFromTo(databaseURL, current, target, dryRun)
+}
+
+func runMigrateGoto(databaseURL string, version uint, dryRun bool) error {
+	current, err := cleanMigrationVersion(databaseURL)
+	if err != nil {
+		return err
+	}
+	return migrateFromTo(databaseURL, current, version, dryRun)
+}
+
+func runMigrateForce(databaseURL string, version uint) error {
+	if err := forceMigration(databaseURL, version); err != nil {
+		return err
+	}
+	fmt.Printf("Forced version %d; no SQL was run\n", version)
 	return runMigrateVersion(databaseURL)
 }
 
-func runMigrateDown(databaseURL string, steps int) error {
-	fmt.Println("Rollback not yet implemented")
-	return nil
+// cleanMigrationVersion returns the database's migration version, refusing
+// a dirty one: which half of that migration ran is for a person to tell
+func cleanMigrationVersion(databaseURL string) (uint, error) {
+	version, dirty, err := migrationVersion(databaseURL)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get migration version: %w", err)
+	}
+	if dirty {
+		return 0, fmt.Errorf("migration %d failed halfway; fix the schema, then run: kaunta migrate force <version>", version)
+	}
+	return version, nil
+}
+
+// migrateFromTo runs the migrations between two versions, or prints their
+// SQL when dryRun is set
+func migrateFromTo(databaseURL string, from, to uint, dryRun bool) error {
+	plan, err := database.PlanMigrations(from, to)
+	if err != nil {
+		return err
+	}
+	if len(plan) == 0 {
+		fmt.Printf("Already at version %d\n", from)
+		return nil
+	}
+
+	if dryRun {
+		fmt.Printf("-- %d migrations from version %d to %d (dry run, nothing applied)\n", len(plan), from, to)
+		for _, m := range plan {
+			direction := "down"
+			if m.Up {
+				direction = "up"
+			}
+			fmt.Printf("\n-- %06d_%s.%s.sql\n", m.Version, m.Identifier, direction)
+			fmt.Println(strings.TrimSpace(m.SQL))
+		}
+		return nil
+	}
+
+	verb := "Rolling back"
+	if to > from {
+		verb = "Applying"
+	}
+	fmt.Printf("%s %d migrations (version %d to %d)...\n", verb, len(plan), from, to)
+	if err := migrateTo(databaseURL, to); err != nil {
+		return err
+	}
+	fmt.Println("Migrations completed successfully")
+	return runMigrateVersion(databaseURL)
 }
 
 func runMigrateVersion(databaseURL string) error {
-	version, dirty, err := database.GetMigrationVersion(databaseURL)
+	version, dirty, err := migrationVersion(databaseURL)
 	if err != nil {
 		return fmt.Errorf("failed to get migration version: %w", err)
 	}
@@ -595,6 +847,43 @@ func runMigrateVersion(databaseURL string) error {
 	return nil
 }
 
+// runMigrateFunctions shows whether the database has the SQL functions the
+// binary calls, after (re)creating them when apply is set
+func runMigrateFunctions(apply bool) error {
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	if apply {
+		if err := database.ApplyFunctions(ctx, database.DB); err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d functions\n", len(database.Functions))
+	}
+
+	statuses, err := database.CheckFunctions(ctx, database.DB)
+	if err != nil {
+		return err
+	}
+	fmt.Println("=== SQL Functions ===")
+	for _, s := range statuses {
+		status := "ok"
+		if !s.OK() {
+			status = s.Problem()
+		}
+		fmt.Printf("%-20s %s\n", s.Name, status)
+	}
+	if missing := database.MissingFunctions(statuses); len(missing) > 0 {
+		return fmt.Errorf("%d functions don't match this binary (run: kaunta migrate functions --apply)", len(missing))
+	}
+	return nil
+}
+
 // ============================================================
 // Check Website Command
 // ============================================================
@@ -609,6 +898,7 @@ Checks:
   - Domain is unique
   - Allowed domains are valid
   - Share ID is unique (if set)
+  - No dimension went over its cardinality cap in the last 7 days
 
 Example:
   kaunta check website example.com`,
@@ -739,13 +1029,23 @@ func RunDiagnostics(ctx context.Context, db *sql.DB) (*DiagnosticsResult, error)
 		result.EventsPerMinute = float64(eventCount) / minutesBack
 	}
 
+	// Event loss reconciliation (tracker sequence numbers vs stored events)
+	if summary, err := eventloss.Report(ctx, db, 7); err == nil {
+		result.EventLoss = summary
+	}
+
+	// Duplicate events dropped within the dedup window
+	if summary, err := dedup.Report(ctx, db, 7); err == nil {
+		result.Duplicates = summary
+	}
+
 	// Status
 	if result.DatabaseConnected && len(result.ExtensionsLoaded) >= 2 && result.EventCount > 0 {
-		result.Status = "healthy"
+		result.Status = diagnosticsHealthy
 	} else if result.DatabaseConnected {
-		result.Status = "operational"
+		result.Status = diagnosticsDegraded
 	} else {
-		result.Status = "critical"
+		result.Status = diagnosticsCritical
 	}
 
 	return result, nil
@@ -873,6 +1173,17 @@ func CheckWebsite(ctx context.Context, db *sql.DB, websiteDomain string) (*Websi
 		}
 	}
 
+	// Dimensions that went over their cardinality cap lately
+	if id, err := uuid.Parse(websiteID); err == nil {
+		overflows, err := cardinality.Recent(ctx, db, id, 7)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range overflows {
+			result.Warnings = append(result.Warnings, o.String())
+		}
+	}
+
 	return result, nil
 }
 
@@ -890,6 +1201,7 @@ func init() {
 	// Add diagnostics command
 	RootCmd.AddCommand(diagnosticsCmd)
 	diagnosticsCmd.Flags().BoolP("full", "f", false, "Show detailed diagnostics")
+	diagnosticsCmd.Flags().String("format", "table", "Output format (table, json)")
 
 	// Add sync command to website
 	websiteCmd.AddCommand(syncCmd)
@@ -900,6 +1212,8 @@ func init() {
 	// Add migrate command
 	RootCmd.AddCommand(migrateCmd)
 	migrateCmd.Flags().IntP("step", "s", 0, "Number of migrations to run/rollback")
+	migrateCmd.Flags().Bool("apply", false, "With functions: (re)create the SQL functions")
+	migrateCmd.Flags().Bool("dry-run", false, "With up, down and goto: print the SQL instead of running it")
 
 	// Add check command to website
 	websiteCmd.AddCommand(checkWebsiteCmd)
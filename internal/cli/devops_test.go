@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/database"
+)
+
+func TestRunDiagnosticsHealthyJSON(t *testing.T) {
+	mock := mockJobsDB(t)
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectQuery(`SELECT version\(\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow("PostgreSQL 17.2"))
+	mock.ExpectQuery(`FROM pg_extension`).
+		WillReturnRows(sqlmock.NewRows([]string{"extname"}).AddRow("uuid-ossp").AddRow("pgcrypto"))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM website_event$`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1200))
+
+	output, err := captureOutput(t, func() error {
+		return runDiagnostics(false, "json")
+	})
+	require.NoError(t, err)
+
+	var result DiagnosticsResult
+	require.NoError(t, json.Unmarshal([]byte(output), &result))
+	assert.Equal(t, diagnosticsHealthy, result.Status)
+	assert.True(t, result.DatabaseConnected)
+	assert.Equal(t, "PostgreSQL 17.2", result.PostgreSQLVersion)
+	assert.Equal(t, int64(1200), result.EventCount)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunDiagnosticsDegradedExitsOne(t *testing.T) {
+	mockJobsDB(t)
+
+	output, err := captureOutput(t, func() error {
+		return runDiagnostics(false, "table")
+	})
+	var exitErr *ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 1, exitErr.Code)
+	assert.Contains(t, output, "Status:  degraded")
+}
+
+func TestRunDiagnosticsUnreachableExitsTwo(t *testing.T) {
+	originalDB := database.DB
+	database.DB = nil
+	t.Cleanup(func() { database.DB = originalDB })
+	originalConnect := connectDatabase
+	connectDatabase = func() error { return errors.New("connection refused") }
+	t.Cleanup(func() { connectDatabase = originalConnect })
+
+	output, err := captureOutput(t, func() error {
+		return runDiagnostics(true, "json")
+	})
+	var exitErr *ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 2, exitErr.Code)
+
+	var result DiagnosticsResult
+	require.NoError(t, json.Unmarshal([]byte(output), &result))
+	assert.Equal(t, diagnosticsCritical, result.Status)
+	assert.False(t, result.DatabaseConnected)
+	assert.Equal(t, "connection refused", result.Error)
+}
+
+func TestRunDiagnosticsInvalidFormat(t *testing.T) {
+	err := runDiagnostics(false, "csv")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid format")
+}
+
+func stubMigrations(t *testing.T, version uint, dirty bool) *[]uint {
+	t.Helper()
+	t.Setenv("DATABASE_URL", "postgres://kaunta@localhost/kaunta")
+	originalVersion, originalTo, originalForce := migrationVersion, migrateTo, forceMigration
+	t.Cleanup(func() {
+		migrationVersion, migrateTo, forceMigration = originalVersion, originalTo, originalForce
+	})
+
+	var applied []uint
+	migrationVersion = func(string) (uint, bool, error) { return version, dirty, nil }
+	migrateTo = func(_ string, to uint) error {
+		applied = append(applied, to)
+		version = to
+		return nil
+	}
+	forceMigration = func(_ string, to uint) error {
+		version, dirty = to, false
+		return nil
+	}
+	return &applied
+}
+
+func TestRunMigrateDown(t *testing.T) {
+	latest, err := database.LatestMigration()
+	require.NoError(t, err)
+	applied := stubMigrations(t, latest, false)
+
+	output, err := captureOutput(t, func() error {
+		return runMigrate("down", nil, 2, false, false)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []uint{latest - 2}, *applied)
+	assert.Contains(t, output, fmt.Sprintf("Rolling back 2 migrations (version %d to %d)", latest, latest-2))
+	assert.Contains(t, output, fmt.Sprintf("Current Version: %d", latest-2))
+}
+
+func TestRunMigrateDownDryRun(t *testing.T) {
+	latest, err := database.LatestMigration()
+	require.NoError(t, err)
+	applied := stubMigrations(t, latest, false)
+
+	output, err := captureOutput(t, func() error {
+		return runMigrate("down", nil, 0, false, true)
+	})
+	require.NoError(t, err)
+	assert.Empty(t, *applied)
+	assert.Contains(t, output, "dry run, nothing applied")
+	assert.Contains(t, output, fmt.Sprintf("-- %06d_", latest))
+	assert.Contains(t, output, ".down.sql")
+	plan, err := database.PlanMigrations(latest, latest-1)
+	require.NoError(t, err)
+	assert.Contains(t, output, plan[0].SQL)
+}
+
+func TestRunMigrateGoto(t *testing.T) {
+	latest, err := database.LatestMigration()
+	require.NoError(t, err)
+	applied := stubMigrations(t, latest-3, false)
+
+	output, err := captureOutput(t, func() error {
+		return runMigrate("goto", []string{fmt.Sprint(latest - 1)}, 0, false, false)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []uint{latest - 1}, *applied)
+	assert.Contains(t, output, "Applying 2 migrations")
+
+	output, err = captureOutput(t, func() error {
+		return runMigrate("goto", []string{fmt.Sprint(latest - 1)}, 0, false, false)
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, fmt.Sprintf("Already at version %d", latest-1))
+	assert.Len(t, *applied, 1)
+}
+
+func TestRunMigrateRefusesDirtyDatabase(t *testing.T) {
+	applied := stubMigrations(t, 52, true)
+
+	err := runMigrate("down", nil, 1, false, false)
+	assert.ErrorContains(t, err, "migration 52 failed halfway")
+	assert.ErrorContains(t, err, "kaunta migrate force")
+	assert.Empty(t, *applied)
+
+	output, err := captureOutput(t, func() error {
+		return runMigrate("force", []string{"51"}, 0, false, false)
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "Forced version 51; no SQL was run")
+	assert.Contains(t, output, "State: CLEAN")
+}
+
+func TestRunMigrateValidation(t *testing.T) {
+	stubMigrations(t, 1, false)
+
+	assert.ErrorContains(t, runMigrate("goto", nil, 0, false, false), "goto needs a version")
+	assert.ErrorContains(t, runMigrate("force", []string{"latest"}, 0, false, false), "invalid version")
+	assert.ErrorContains(t, runMigrate("down", []string{"3"}, 0, false, false), "down takes no version")
+	assert.ErrorContains(t, runMigrate("down", nil, -1, false, false), "step must be positive")
+	assert.ErrorContains(t, runMigrate("down", nil, 2, false, false), "can't roll back 2 migrations")
+	assert.ErrorContains(t, runMigrate("sideways", nil, 0, false, false), "unknown action")
+}
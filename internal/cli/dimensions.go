@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/dimensions"
+)
+
+var websiteDimensionCmd = &cobra.Command{
+	Use:   "dimension",
+	Short: "Manage a website's custom dimensions",
+	Long: fmt.Sprintf(`Register named values such as content_type, plan or section that the
+tracker sets on each pageview (kaunta.setDimensions() or
+<meta name="kaunta-dimension-NAME">).
+
+A website can have up to %d custom dimensions. Values for names that are not
+registered are dropped. Registered names work as breakdowns and filters:
+'kaunta stats breakdown <domain> --by <name>' and dim.<name>=<value> on the
+dashboard API. Custom dimensions require PostgreSQL.`, dimensions.Max),
+}
+
+var websiteDimensionAddCmd = &cobra.Command{
+	Use:   "add <domain> <name>",
+	Short: "Register a custom dimension",
+	Long: `Register a custom dimension. Names use lowercase letters, digits and
+underscores and can't reuse a built-in dimension (country, browser, ...).
+
+Examples:
+  kaunta website dimension add example.com content_type`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteDimensionAdd(args[0], args[1])
+	},
+}
+
+var websiteDimensionListCmd = &cobra.Command{
+	Use:   "list <domain>",
+	Short: "List a website's custom dimensions",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteDimensionList(args[0])
+	},
+}
+
+var websiteDimensionRemoveCmd = &cobra.Command{
+	Use:   "remove <domain> <name>",
+	Short: "Unregister a custom dimension",
+	Long: `Unregister a custom dimension. Values already recorded are kept but no
+longer reported, and new values for the name are dropped.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteDimensionRemove(args[0], args[1])
+	},
+}
+
+// withWebsite connects to the database and resolves a website by domain
+func withWebsite(domain string, fn func(ctx context.Context, websiteID uuid.UUID, domain string) error) error {
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	website, err := GetWebsiteByDomain(ctx, domain, nil)
+	if err != nil {
+		return err
+	}
+	websiteID, err := uuid.Parse(website.WebsiteID)
+	if err != nil {
+		return err
+	}
+	return fn(ctx, websiteID, website.Domain)
+}
+
+func runWebsiteDimensionAdd(domain, name string) error {
+	if err := dimensions.ValidName(name); err != nil {
+		return err
+	}
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		if err := dimensions.Add(ctx, database.DB, websiteID, name); err != nil {
+			return err
+		}
+		fmt.Printf("Custom dimension %q registered for %s\n", name, domain)
+		return nil
+	})
+}
+
+func runWebsiteDimensionList(domain string) error {
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		dims, err := dimensions.List(ctx, database.DB, websiteID)
+		if err != nil {
+			return err
+		}
+		if len(dims) == 0 {
+			fmt.Printf("%s has no custom dimensions\n", domain)
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "NAME\tCREATED")
+		_, _ = fmt.Fprintln(w, "----\t-------")
+		for _, d := range dims {
+			_, _ = fmt.Fprintf(w, "%s\t%s\n", d.Name, d.CreatedAt.Format("2006-01-02 15:04"))
+		}
+		return w.Flush()
+	})
+}
+
+func runWebsiteDimensionRemove(domain, name string) error {
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		err := dimensions.Remove(ctx, database.DB, websiteID, name)
+		if errors.Is(err, dimensions.ErrNotFound) {
+			return fmt.Errorf("custom dimension %q is not registered for %s", name, domain)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Custom dimension %q removed from %s\n", name, domain)
+		return nil
+	})
+}
+
+func init() {
+	websiteCmd.AddCommand(websiteDimensionCmd)
+	websiteDimensionCmd.AddCommand(websiteDimensionAddCmd, websiteDimensionListCmd, websiteDimensionRemoveCmd)
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func expectWebsiteLookup(mock sqlmock.Sqlmock, websiteID uuid.UUID, domain string) {
+	now := time.Now()
+	mock.ExpectQuery(`FROM website\s+WHERE deleted_at IS NULL`).WithArgs(domain, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"website_id", "domain", "name", "allowed_domains", "share_id", "created_at", "updated_at"}).
+			AddRow(websiteID.String(), domain, domain, []byte(`[]`), nil, now, now))
+}
+
+func TestRunWebsiteDimensionList(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectQuery(`SELECT name, created_at\s+FROM custom_dimension`).WithArgs(websiteID).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "created_at"}).
+			AddRow("content_type", time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)))
+
+	output, err := captureOutput(t, func() error { return runWebsiteDimensionList("example.com") })
+	require.NoError(t, err)
+	assert.Contains(t, output, "NAME")
+	assert.Contains(t, output, "content_type  2026-03-01 09:30")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunWebsiteDimensionRemoveUnknown(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectExec(`DELETE FROM custom_dimension`).WithArgs(websiteID, "plan").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := runWebsiteDimensionRemove("example.com", "plan")
+	assert.EqualError(t, err, `custom dimension "plan" is not registered for example.com`)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunWebsiteDimensionAddValidation(t *testing.T) {
+	err := runWebsiteDimensionAdd("example.com", "country")
+	assert.EqualError(t, err, `invalid dimension name: "country" is a built-in dimension`)
+}
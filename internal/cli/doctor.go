@@ -0,0 +1,407 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/geoip"
+)
+
+// Doctor check statuses
+const (
+	doctorOK   = "ok"
+	doctorWarn = "warn"
+	doctorFail = "fail"
+	// doctorSkip is a check that couldn't run for a failed one
+	doctorSkip = "skip"
+)
+
+const (
+	// doctorPartitionDays is how many days ahead partitions must exist; the
+	// worker creates 30
+	doctorPartitionDays = 7
+	// doctorGeoIPMaxAge is the age of a GeoIP database worth refreshing;
+	// the server refreshes it weekly
+	doctorGeoIPMaxAge = 30 * 24 * time.Hour
+	// doctorSkewWarn and doctorSkewFail bound the clock differences that
+	// shift events across minutes and break date boundaries
+	doctorSkewWarn = 5 * time.Second
+	doctorSkewFail = time.Minute
+)
+
+var (
+	doctorURL    string
+	doctorFormat string
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor [--url <server-url>] [--format json|table]",
+	Short: "Check the installation end to end",
+	Long: `Check what Kaunta needs to count visits, from the database to the tracker:
+
+  database    PostgreSQL answers
+  migrations  the schema is at this binary's newest migration and not dirty
+  functions   the SQL functions the dashboard calls (get_dashboard_stats,
+              get_timeseries, ...) match this binary
+  partitions  events of today and the next 7 days have a partition
+  geoip       the GeoIP database exists and is less than 30 days old
+  tracker     the server serves the tracker script over HTTP
+  clock       this machine's clock agrees with the database's and the server's
+
+Checks that depend on a failed one are skipped. The command exits with an
+error when a check fails; warnings don't. Unlike 'kaunta diagnostics', which
+reports counts and sizes, doctor says what to fix.
+
+Examples:
+  kaunta doctor
+  kaunta doctor --url https://stats.example.com --format json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDoctor(doctorURL, doctorFormat)
+	},
+}
+
+// DoctorCheck is the outcome of one doctor check
+type DoctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// DoctorReport is the outcome of every doctor check; Status is the worst
+// of them (ok, warn or fail)
+type DoctorReport struct {
+	Status    string        `json:"status"`
+	CheckedAt time.Time     `json:"checked_at"`
+	Checks    []DoctorCheck `json:"checks"`
+}
+
+// doctorEnv is what the checks look at
+type doctorEnv struct {
+	db *sql.DB
+	// dbErr is why there is no database
+	dbErr     error
+	client    *http.Client
+	url       string
+	geoIPFile string
+	now       func() time.Time
+}
+
+func runDoctor(url, format string) error {
+	if format != "table" && format != "json" {
+		return fmt.Errorf("invalid format: %s (use json or table)", format)
+	}
+	if url == "" {
+		port := os.Getenv("PORT")
+		if port == "" {
+			port = viper.GetString("port")
+		}
+		if port == "" {
+			port = "3000"
+		}
+		url = "http://localhost:" + port
+	}
+
+	env := doctorEnv{
+		client: &http.Client{Timeout: 5 * time.Second},
+		url:    strings.TrimSuffix(url, "/"),
+		now:    time.Now,
+	}
+	dataDir, provider := "./data", ""
+	if cfg, err := config.Load(); err == nil {
+		dataDir, provider = cfg.DataDir, cfg.GeoIPProvider
+	}
+	if file, err := geoip.DatabaseFile(dataDir, provider); err == nil {
+		env.geoIPFile = file
+	}
+	done, err := ensureDatabase()
+	if err != nil {
+		env.dbErr = err
+	} else {
+		defer done()
+		env.db = database.DB
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	report := runDoctorChecks(ctx, env)
+
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "CHECK\tSTATUS\tDETAIL")
+		_, _ = fmt.Fprintln(w, "-----\t------\t------")
+		for _, c := range report.Checks {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", c.Name, strings.ToUpper(c.Status), c.Detail)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	failed := 0
+	for _, c := range report.Checks {
+		if c.Status == doctorFail {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(report.Checks))
+	}
+	return nil
+}
+
+// runDoctorChecks runs every check in order
+func runDoctorChecks(ctx context.Context, env doctorEnv) DoctorReport {
+	report := DoctorReport{Status: doctorOK, CheckedAt: env.now().UTC()}
+	add := func(c DoctorCheck) {
+		report.Checks = append(report.Checks, c)
+		if doctorRank(c.Status) > doctorRank(report.Status) {
+			report.Status = c.Status
+		}
+	}
+
+	dbOK := false
+	switch {
+	case env.dbErr != nil:
+		add(DoctorCheck{"database", doctorFail, env.dbErr.Error()})
+	default:
+		if err := env.db.PingContext(ctx); err != nil {
+			add(DoctorCheck{"database", doctorFail, err.Error()})
+		} else {
+			dbOK = true
+			add(DoctorCheck{"database", doctorOK, "connected"})
+		}
+	}
+	if dbOK {
+		add(doctorCheckMigrations(ctx, env.db))
+		add(doctorCheckFunctions(ctx, env.db))
+		add(doctorCheckPartitions(ctx, env.db, env.now()))
+	} else {
+		for _, name := range []string{"migrations", "functions", "partitions"} {
+			add(DoctorCheck{name, doctorSkip, "needs the database"})
+		}
+	}
+	add(doctorCheckGeoIP(env.geoIPFile, env.now()))
+
+	serverDate, tracker := doctorCheckTracker(ctx, env.client, env.url)
+	add(tracker)
+	var dbNow *time.Time
+	if dbOK {
+		var t time.Time
+		if err := env.db.QueryRowContext(ctx, `SELECT NOW()`).Scan(&t); err == nil {
+			dbNow = &t
+		}
+	}
+	add(doctorCheckClock(env.now(), dbNow, serverDate))
+	return report
+}
+
+// doctorRank orders statuses for the report's; skipped checks don't count
+func doctorRank(status string) int {
+	switch status {
+	case doctorWarn:
+		return 1
+	case doctorFail:
+		return 2
+	}
+	return 0
+}
+
+// doctorCheckMigrations compares the schema's migration with the binary's
+// newest
+func doctorCheckMigrations(ctx context.Context, db *sql.DB) DoctorCheck {
+	latest, err := database.LatestMigration()
+	if err != nil {
+		return DoctorCheck{"migrations", doctorFail, err.Error()}
+	}
+	var version uint
+	var dirty bool
+	err = db.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) || isUndefinedTable(err) {
+		return DoctorCheck{"migrations", doctorFail, "no migrations applied (run: kaunta migrate up)"}
+	}
+	if err != nil {
+		return DoctorCheck{"migrations", doctorFail, fmt.Sprintf("failed to read the migration version: %v", err)}
+	}
+	switch {
+	case dirty:
+		return DoctorCheck{"migrations", doctorFail, fmt.Sprintf("migration %d failed halfway; fix the schema, then force the version", version)}
+	case version < latest:
+		return DoctorCheck{"migrations", doctorFail, fmt.Sprintf("at %d, %d pending (run: kaunta migrate up)", version, latest-version)}
+	case version > latest:
+		return DoctorCheck{"migrations", doctorWarn, fmt.Sprintf("at %d, newer than this binary (%d); upgrade kaunta", version, latest)}
+	}
+	return DoctorCheck{"migrations", doctorOK, fmt.Sprintf("at %d", version)}
+}
+
+// isUndefinedTable reports whether err is PostgreSQL's undefined_table
+func isUndefinedTable(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "42P01"
+}
+
+// doctorCheckFunctions checks the SQL functions the binary calls
+func doctorCheckFunctions(ctx context.Context, db *sql.DB) DoctorCheck {
+	statuses, err := database.CheckFunctions(ctx, db)
+	if err != nil {
+		return DoctorCheck{"functions", doctorFail, err.Error()}
+	}
+	var problems []string
+	for _, s := range statuses {
+		if !s.OK() {
+			problems = append(problems, s.Name+": "+s.Problem())
+		}
+	}
+	if len(problems) > 0 {
+		return DoctorCheck{"functions", doctorFail, strings.Join(problems, "; ") + " (run: kaunta migrate functions --apply)"}
+	}
+	return DoctorCheck{"functions", doctorOK, fmt.Sprintf("%d functions match", len(statuses))}
+}
+
+// doctorCheckPartitions checks that website_event has the partitions of
+// today and the next doctorPartitionDays days, named like the worker names
+// them
+func doctorCheckPartitions(ctx context.Context, db *sql.DB, now time.Time) DoctorCheck {
+	names := make([]string, 0, doctorPartitionDays+1)
+	for i := 0; i <= doctorPartitionDays; i++ {
+		names = append(names, "website_event_"+now.AddDate(0, 0, i).Format("2006_01_02"))
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT tablename FROM pg_tables
+		WHERE schemaname = 'public' AND tablename = ANY($1)
+	`, pq.Array(names))
+	if err != nil {
+		return DoctorCheck{"partitions", doctorFail, fmt.Sprintf("failed to list partitions: %v", err)}
+	}
+	defer func() { _ = rows.Close() }()
+	found := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return DoctorCheck{"partitions", doctorFail, fmt.Sprintf("failed to list partitions: %v", err)}
+		}
+		found[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return DoctorCheck{"partitions", doctorFail, fmt.Sprintf("failed to list partitions: %v", err)}
+	}
+
+	if !found[names[0]] {
+		day := now.Format(time.DateOnly)
+		return DoctorCheck{"partitions", doctorFail, fmt.Sprintf("no partition for today: events can't be stored; the worker only creates future days "+
+			"(CREATE TABLE %s PARTITION OF website_event FOR VALUES FROM ('%s') TO ('%s'))", names[0], day, now.AddDate(0, 0, 1).Format(time.DateOnly))}
+	}
+	var missing []string
+	for _, name := range names[1:] {
+		if !found[name] {
+			missing = append(missing, strings.TrimPrefix(name, "website_event_"))
+		}
+	}
+	if len(missing) > 0 {
+		return DoctorCheck{"partitions", doctorWarn, fmt.Sprintf("no partition for %s; the worker creates them (run: kaunta worker --once --tasks partitions)", strings.Join(missing, ", "))}
+	}
+	return DoctorCheck{"partitions", doctorOK, fmt.Sprintf("today and the next %d days", doctorPartitionDays)}
+}
+
+// doctorCheckGeoIP checks the GeoIP database file's age
+func doctorCheckGeoIP(file string, now time.Time) DoctorCheck {
+	if file == "" {
+		return DoctorCheck{"geoip", doctorWarn, "unknown GeoIP provider"}
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return DoctorCheck{"geoip", doctorWarn, fmt.Sprintf("%s not found: locations are Unknown until the server downloads it", file)}
+	}
+	age := now.Sub(info.ModTime())
+	days := int(age.Hours() / 24)
+	if age > doctorGeoIPMaxAge {
+		return DoctorCheck{"geoip", doctorWarn, fmt.Sprintf("%s is %d days old; locations drift (restart the server or enable updates)", file, days)}
+	}
+	return DoctorCheck{"geoip", doctorOK, fmt.Sprintf("%s, %d days old", file, days)}
+}
+
+// doctorCheckTracker fetches the tracker script, returning the server's
+// Date header for the clock check
+func doctorCheckTracker(ctx context.Context, client *http.Client, url string) (*time.Time, DoctorCheck) {
+	scriptURL := url + "/k.js"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, scriptURL, nil)
+	if err != nil {
+		return nil, DoctorCheck{"tracker", doctorFail, err.Error()}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, DoctorCheck{"tracker", doctorFail, fmt.Sprintf("%s unreachable: %v", scriptURL, err)}
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var serverDate *time.Time
+	if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		serverDate = &date
+	}
+	if resp.StatusCode != http.StatusOK {
+		return serverDate, DoctorCheck{"tracker", doctorFail, fmt.Sprintf("%s answered %d", scriptURL, resp.StatusCode)}
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.Contains(ct, "javascript") {
+		return serverDate, DoctorCheck{"tracker", doctorWarn, fmt.Sprintf("%s is served as %q, not JavaScript", scriptURL, ct)}
+	}
+	return serverDate, DoctorCheck{"tracker", doctorOK, scriptURL + " served"}
+}
+
+// doctorCheckClock compares this machine's clock with the database's and
+// the server's; the Date header has whole seconds, so the server's skew is
+// read with a second of slack
+func doctorCheckClock(now time.Time, dbNow, serverDate *time.Time) DoctorCheck {
+	if dbNow == nil && serverDate == nil {
+		return DoctorCheck{"clock", doctorSkip, "needs the database or the server"}
+	}
+	status := doctorOK
+	var parts []string
+	compare := func(name string, skew, slack time.Duration) {
+		abs := skew.Abs() - slack
+		switch {
+		case abs > doctorSkewFail:
+			status = doctorFail
+		case abs > doctorSkewWarn && status != doctorFail:
+			status = doctorWarn
+		}
+		parts = append(parts, fmt.Sprintf("%s %+.1fs", name, skew.Seconds()))
+	}
+	if dbNow != nil {
+		compare("database", dbNow.Sub(now), 0)
+	}
+	if serverDate != nil {
+		compare("server", serverDate.Sub(now.Truncate(time.Second)), time.Second)
+	}
+	detail := strings.Join(parts, ", ")
+	if status != doctorOK {
+		detail += " (sync the clocks with NTP)"
+	}
+	return DoctorCheck{"clock", status, detail}
+}
+
+func init() {
+	RootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().StringVar(&doctorURL, "url", "", "Server URL to reach the tracker at (default http://localhost:$PORT)")
+	doctorCmd.Flags().StringVarP(&doctorFormat, "format", "f", "table", "Output format (json, table)")
+}
@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/database"
+)
+
+func expectHealthyFunctions(mock sqlmock.Sqlmock) {
+	rows := sqlmock.NewRows([]string{"proname", "args", "md5"})
+	for _, fn := range database.Functions {
+		checksum, _ := fn.Checksum()
+		rows.AddRow(fn.Name, fn.Args, checksum)
+	}
+	mock.ExpectQuery(`FROM pg_proc p`).WillReturnRows(rows)
+}
+
+func TestRunDoctorChecksHealthy(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	latest, err := database.LatestMigration()
+	require.NoError(t, err)
+
+	mock.ExpectPing()
+	mock.ExpectQuery(`SELECT version, dirty FROM schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(latest, false))
+	expectHealthyFunctions(mock)
+	partitions := sqlmock.NewRows([]string{"tablename"})
+	for i := 0; i <= doctorPartitionDays; i++ {
+		partitions.AddRow("website_event_" + now.AddDate(0, 0, i).Format("2006_01_02"))
+	}
+	mock.ExpectQuery(`FROM pg_tables`).WillReturnRows(partitions)
+	mock.ExpectQuery(`SELECT NOW\(\)`).WillReturnRows(sqlmock.NewRows([]string{"now"}).AddRow(now.Add(300 * time.Millisecond)))
+
+	geoIPFile := filepath.Join(t.TempDir(), "GeoLite2-City.mmdb")
+	require.NoError(t, os.WriteFile(geoIPFile, []byte("mmdb"), 0o644))
+	require.NoError(t, os.Chtimes(geoIPFile, now.AddDate(0, 0, -3), now.AddDate(0, 0, -3)))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/k.js", r.URL.Path)
+		w.Header().Set("Content-Type", "application/javascript")
+		w.Header().Set("Date", now.Add(time.Second).Format(http.TimeFormat))
+		_, _ = w.Write([]byte("(function(){})()"))
+	}))
+	defer server.Close()
+
+	report := runDoctorChecks(context.Background(), doctorEnv{
+		db:        db,
+		client:    server.Client(),
+		url:       server.URL,
+		geoIPFile: geoIPFile,
+		now:       func() time.Time { return now },
+	})
+
+	assert.Equal(t, doctorOK, report.Status)
+	require.Len(t, report.Checks, 7)
+	for _, c := range report.Checks {
+		assert.Equal(t, doctorOK, c.Status, "%s: %s", c.Name, c.Detail)
+	}
+	assert.Equal(t, geoIPFile+", 3 days old", report.Checks[4].Detail)
+	assert.Equal(t, "database +0.3s, server +1.0s", report.Checks[6].Detail)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunDoctorChecksWithoutDatabase(t *testing.T) {
+	// The server sends the real Date, so the clock is real too
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	report := runDoctorChecks(context.Background(), doctorEnv{
+		dbErr:     errors.New("database connection failed: connection refused"),
+		client:    server.Client(),
+		url:       server.URL,
+		geoIPFile: filepath.Join(t.TempDir(), "missing.mmdb"),
+		now:       time.Now,
+	})
+
+	assert.Equal(t, doctorFail, report.Status)
+	statuses := map[string]string{}
+	for _, c := range report.Checks {
+		statuses[c.Name] = c.Status
+	}
+	assert.Equal(t, map[string]string{
+		"database":   doctorFail,
+		"migrations": doctorSkip,
+		"functions":  doctorSkip,
+		"partitions": doctorSkip,
+		"geoip":      doctorWarn,
+		"tracker":    doctorFail,
+		"clock":      doctorOK,
+	}, statuses)
+}
+
+func TestDoctorCheckMigrations(t *testing.T) {
+	latest, err := database.LatestMigration()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		expect func(sqlmock.Sqlmock)
+		status string
+		detail string
+	}{
+		{"pending", func(m sqlmock.Sqlmock) {
+			m.ExpectQuery(`schema_migrations`).WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(latest-2, false))
+		}, doctorFail, "2 pending (run: kaunta migrate up)"},
+		{"dirty", func(m sqlmock.Sqlmock) {
+			m.ExpectQuery(`schema_migrations`).WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(latest, true))
+		}, doctorFail, "failed halfway"},
+		{"newer", func(m sqlmock.Sqlmock) {
+			m.ExpectQuery(`schema_migrations`).WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(latest+1, false))
+		}, doctorWarn, "newer than this binary"},
+		{"never migrated", func(m sqlmock.Sqlmock) {
+			m.ExpectQuery(`schema_migrations`).WillReturnError(&pq.Error{Code: "42P01"})
+		}, doctorFail, "no migrations applied"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = db.Close() }()
+			tt.expect(mock)
+
+			c := doctorCheckMigrations(context.Background(), db)
+			assert.Equal(t, tt.status, c.Status)
+			assert.Contains(t, c.Detail, tt.detail)
+		})
+	}
+}
+
+func TestDoctorCheckPartitionsMissing(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`FROM pg_tables`).WillReturnRows(sqlmock.NewRows([]string{"tablename"}).
+		AddRow("website_event_2025_06_15").AddRow("website_event_2025_06_16"))
+	c := doctorCheckPartitions(context.Background(), db, now)
+	assert.Equal(t, doctorWarn, c.Status)
+	assert.Contains(t, c.Detail, "no partition for 2025_06_17, 2025_06_18")
+
+	mock.ExpectQuery(`FROM pg_tables`).WillReturnRows(sqlmock.NewRows([]string{"tablename"}))
+	c = doctorCheckPartitions(context.Background(), db, now)
+	assert.Equal(t, doctorFail, c.Status)
+	assert.Contains(t, c.Detail, "CREATE TABLE website_event_2025_06_15 PARTITION OF website_event FOR VALUES FROM ('2025-06-15') TO ('2025-06-16')")
+}
+
+func TestDoctorCheckFunctionsMismatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	rows := sqlmock.NewRows([]string{"proname", "args", "md5"}).AddRow("get_timeseries", "uuid, integer", "")
+	for _, fn := range database.Functions {
+		if fn.Name == "get_breakdown" {
+			// Created by an older migration
+			rows.AddRow(fn.Name, fn.Args, "0cc175b9c0f1b6a831c399e269772661")
+		}
+	}
+	mock.ExpectQuery(`FROM pg_proc p`).WillReturnRows(rows)
+	c := doctorCheckFunctions(context.Background(), db)
+	assert.Equal(t, doctorFail, c.Status)
+	assert.Contains(t, c.Detail, "get_dashboard_stats: missing")
+	assert.Contains(t, c.Detail, "get_timeseries: signature differs")
+	assert.Contains(t, c.Detail, "get_breakdown: definition differs")
+	assert.Contains(t, c.Detail, "kaunta migrate functions --apply")
+}
+
+func TestDoctorCheckClock(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	db := now.Add(-8 * time.Second)
+	server := now.Add(2 * time.Minute)
+
+	assert.Equal(t, doctorWarn, doctorCheckClock(now, &db, nil).Status)
+	c := doctorCheckClock(now, &db, &server)
+	assert.Equal(t, doctorFail, c.Status)
+	assert.Equal(t, "database -8.0s, server +120.0s (sync the clocks with NTP)", c.Detail)
+	assert.Equal(t, doctorSkip, doctorCheckClock(now, nil, nil).Status)
+}
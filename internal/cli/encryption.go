@@ -0,0 +1,326 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/fieldcrypt"
+)
+
+// configureEncryption installs the column encryption keyring from config.
+// Encryption stays disabled when no key is configured.
+func configureEncryption(cfg *config.Config) error {
+	key, err := cfg.ResolveEncryptionKey()
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		fieldcrypt.Configure(nil)
+		return nil
+	}
+
+	keyring, err := fieldcrypt.NewKeyring(key, cfg.EncryptionPreviousKeys...)
+	if err != nil {
+		return fmt.Errorf("invalid encryption key: %w", err)
+	}
+	fieldcrypt.Configure(keyring)
+	return nil
+}
+
+var encryptionCmd = &cobra.Command{
+	Use:   "encryption",
+	Short: "Manage column-level encryption",
+	Long: `Manage application-level encryption of sensitive columns.
+
+When encryption_key (or ENCRYPTION_KEY) is configured, session.distinct_id and
+website_event.props are sealed with AES-256-GCM before they are written.`,
+}
+
+var encryptionGenerateKeyCmd = &cobra.Command{
+	Use:   "generate-key",
+	Short: "Generate a new random encryption key",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, err := fieldcrypt.GenerateKey()
+		if err != nil {
+			return fmt.Errorf("failed to generate key: %w", err)
+		}
+		fmt.Println(key)
+		return nil
+	},
+}
+
+var (
+	rotateBatchSize int
+	rotateDryRun    bool
+	rotateDecrypt   bool
+)
+
+var encryptionRotateCmd = &cobra.Command{
+	Use:   "rotate [--batch-size N] [--dry-run] [--decrypt]",
+	Short: "Re-encrypt stored values with the current key",
+	Long: `Re-encrypt distinct_id and event props that are stored in plaintext or
+under a previous key, using the current encryption_key.
+
+Rotation procedure:
+  1. Move the current key to encryption_previous_keys
+  2. Set encryption_key to a new key (kaunta encryption generate-key)
+  3. Restart the server, then run: kaunta encryption rotate
+  4. Remove the old key from encryption_previous_keys
+
+Options:
+  --batch-size N  Rows updated per batch (default 1000)
+  --dry-run       Only count rows that would be rewritten
+  --decrypt       Write values back as plaintext (to disable encryption)`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runEncryptionRotate(rotateBatchSize, rotateDryRun, rotateDecrypt)
+	},
+}
+
+// RotationResult summarises a rotation run
+type RotationResult struct {
+	Sessions int64 `json:"sessions"`
+	Events   int64 `json:"events"`
+}
+
+func runEncryptionRotate(batchSize int, dryRun bool, decrypt bool) error {
+	keyring := fieldcrypt.Active()
+	if keyring == nil {
+		return fmt.Errorf("no encryption key configured (set encryption_key or ENCRYPTION_KEY)")
+	}
+	if batchSize < 1 {
+		batchSize = 1000
+	}
+
+	if database.DB == nil {
+		if err := connectDatabase(); err != nil {
+			return fmt.Errorf("database connection failed: %w", err)
+		}
+		defer func() { _ = closeDatabase() }()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 24*time.Hour)
+	defer cancel()
+
+	result, err := RotateEncryptedColumns(ctx, database.DB, keyring, batchSize, dryRun, decrypt)
+	if err != nil {
+		return err
+	}
+
+	verb := "Rewrote"
+	if dryRun {
+		verb = "Would rewrite"
+	}
+	fmt.Printf("%s %d session(s) and %d event(s) (key %s)\n", verb, result.Sessions, result.Events, keyring.PrimaryKeyID())
+	return nil
+}
+
+// RotateEncryptedColumns rewrites every distinct_id and props value that is not
+// sealed with the keyring's primary key. With decrypt set, encrypted values are
+// written back as plaintext instead.
+func RotateEncryptedColumns(ctx context.Context, db *sql.DB, keyring *fieldcrypt.Keyring, batchSize int, dryRun bool, decrypt bool) (*RotationResult, error) {
+	result := &RotationResult{}
+
+	sessions, err := rotateSessions(ctx, db, keyring, batchSize, dryRun, decrypt)
+	if err != nil {
+		return nil, err
+	}
+	result.Sessions = sessions
+
+	events, err := rotateEventProps(ctx, db, keyring, batchSize, dryRun, decrypt)
+	if err != nil {
+		return nil, err
+	}
+	result.Events = events
+
+	return result, nil
+}
+
+// reseal converts a stored value to its rotated form, reporting whether it changed
+func reseal(keyring *fieldcrypt.Keyring, value string, decrypt bool) (string, bool, error) {
+	if decrypt {
+		if !fieldcrypt.IsEncrypted(value) {
+			return value, false, nil
+		}
+		plain, err := keyring.Decrypt(value)
+		if err != nil {
+			return "", false, err
+		}
+		return string(plain), true, nil
+	}
+
+	if !keyring.NeedsRotation(value) {
+		return value, false, nil
+	}
+	plain, err := keyring.Decrypt(value)
+	if err != nil {
+		return "", false, err
+	}
+	sealed, err := keyring.Encrypt(plain)
+	if err != nil {
+		return "", false, err
+	}
+	return sealed, true, nil
+}
+
+func rotateSessions(ctx context.Context, db *sql.DB, keyring *fieldcrypt.Keyring, batchSize int, dryRun bool, decrypt bool) (int64, error) {
+	var rewritten int64
+	lastID := "00000000-0000-0000-0000-000000000000"
+
+	for {
+		rows, err := db.QueryContext(ctx, `
+			SELECT session_id, distinct_id
+			FROM session
+			WHERE distinct_id IS NOT NULL AND session_id > $1
+			ORDER BY session_id
+			LIMIT $2`, lastID, batchSize)
+		if err != nil {
+			return rewritten, fmt.Errorf("failed to query sessions: %w", err)
+		}
+
+		type update struct{ id, value string }
+		var updates []update
+		count := 0
+		for rows.Next() {
+			var id, value string
+			if err := rows.Scan(&id, &value); err != nil {
+				_ = rows.Close()
+				return rewritten, fmt.Errorf("failed to scan session: %w", err)
+			}
+			count++
+			lastID = id
+
+			next, changed, err := reseal(keyring, value, decrypt)
+			if err != nil {
+				_ = rows.Close()
+				return rewritten, fmt.Errorf("session %s: %w", id, err)
+			}
+			if changed {
+				updates = append(updates, update{id, next})
+			}
+		}
+		_ = rows.Close()
+		if err := rows.Err(); err != nil {
+			return rewritten, err
+		}
+
+		for _, u := range updates {
+			if !dryRun {
+				if _, err := db.ExecContext(ctx, `UPDATE session SET distinct_id = $1 WHERE session_id = $2`, u.value, u.id); err != nil {
+					return rewritten, fmt.Errorf("failed to update session %s: %w", u.id, err)
+				}
+			}
+			rewritten++
+		}
+
+		if count < batchSize {
+			return rewritten, nil
+		}
+	}
+}
+
+func rotateEventProps(ctx context.Context, db *sql.DB, keyring *fieldcrypt.Keyring, batchSize int, dryRun bool, decrypt bool) (int64, error) {
+	var rewritten int64
+	lastCreated := time.Time{}
+	lastID := "00000000-0000-0000-0000-000000000000"
+
+	// Only string-typed props can be ciphertext; when encrypting, plain objects need sealing too
+	filter := "jsonb_typeof(props) = 'string'"
+	if !decrypt {
+		filter = "props IS NOT NULL"
+	}
+
+	for {
+		rows, err := db.QueryContext(ctx, fmt.Sprintf(`
+			SELECT event_id, created_at, props::text
+			FROM website_event
+			WHERE %s AND (created_at, event_id) > ($1, $2)
+			ORDER BY created_at, event_id
+			LIMIT $3`, filter), lastCreated, lastID, batchSize)
+		if err != nil {
+			return rewritten, fmt.Errorf("failed to query events: %w", err)
+		}
+
+		type update struct {
+			id        string
+			createdAt time.Time
+			props     []byte
+		}
+		var updates []update
+		count := 0
+		for rows.Next() {
+			var id string
+			var createdAt time.Time
+			var raw []byte
+			if err := rows.Scan(&id, &createdAt, &raw); err != nil {
+				_ = rows.Close()
+				return rewritten, fmt.Errorf("failed to scan event: %w", err)
+			}
+			count++
+			lastID, lastCreated = id, createdAt
+
+			// Stored value is either a plain JSON object or a JSON string holding ciphertext
+			value := string(raw)
+			var sealed string
+			if json.Unmarshal(raw, &sealed) == nil && fieldcrypt.IsEncrypted(sealed) {
+				value = sealed
+			}
+
+			next, changed, err := reseal(keyring, value, decrypt)
+			if err != nil {
+				_ = rows.Close()
+				return rewritten, fmt.Errorf("event %s: %w", id, err)
+			}
+			if !changed {
+				continue
+			}
+
+			stored := []byte(next)
+			if !decrypt {
+				if stored, err = json.Marshal(next); err != nil {
+					_ = rows.Close()
+					return rewritten, err
+				}
+			}
+			updates = append(updates, update{id, createdAt, stored})
+		}
+		_ = rows.Close()
+		if err := rows.Err(); err != nil {
+			return rewritten, err
+		}
+
+		for _, u := range updates {
+			if !dryRun {
+				if _, err := db.ExecContext(ctx,
+					`UPDATE website_event SET props = $1::jsonb WHERE event_id = $2 AND created_at = $3`,
+					string(u.props), u.id, u.createdAt); err != nil {
+					return rewritten, fmt.Errorf("failed to update event %s: %w", u.id, err)
+				}
+			}
+			rewritten++
+		}
+
+		if count < batchSize {
+			return rewritten, nil
+		}
+	}
+}
+
+func init() {
+	encryptionCmd.AddCommand(encryptionGenerateKeyCmd)
+	encryptionCmd.AddCommand(encryptionRotateCmd)
+
+	encryptionRotateCmd.Flags().IntVar(&rotateBatchSize, "batch-size", 1000, "Rows updated per batch")
+	encryptionRotateCmd.Flags().BoolVar(&rotateDryRun, "dry-run", false, "Only count rows that would be rewritten")
+	encryptionRotateCmd.Flags().BoolVar(&rotateDecrypt, "decrypt", false, "Write values back as plaintext")
+
+	RootCmd.AddCommand(encryptionCmd)
+}
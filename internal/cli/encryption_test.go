@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/fieldcrypt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigureEncryption(t *testing.T) {
+	t.Cleanup(func() { fieldcrypt.Configure(nil) })
+
+	require.NoError(t, configureEncryption(&config.Config{}))
+	assert.False(t, fieldcrypt.Enabled())
+
+	key, err := fieldcrypt.GenerateKey()
+	require.NoError(t, err)
+	require.NoError(t, configureEncryption(&config.Config{EncryptionKey: key}))
+	assert.True(t, fieldcrypt.Enabled())
+
+	err = configureEncryption(&config.Config{EncryptionKey: "bogus"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid encryption key")
+}
+
+func TestResealRotatesAndDecrypts(t *testing.T) {
+	oldKey, err := fieldcrypt.GenerateKey()
+	require.NoError(t, err)
+	newKey, err := fieldcrypt.GenerateKey()
+	require.NoError(t, err)
+
+	oldRing, err := fieldcrypt.NewKeyring(oldKey)
+	require.NoError(t, err)
+	ring, err := fieldcrypt.NewKeyring(newKey, oldKey)
+	require.NoError(t, err)
+
+	sealedOld, err := oldRing.Encrypt([]byte("user-1"))
+	require.NoError(t, err)
+
+	// Old ciphertext is re-sealed under the new primary key
+	next, changed, err := reseal(ring, sealedOld, false)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.False(t, ring.NeedsRotation(next))
+
+	// Already current values are left alone
+	_, changed, err = reseal(ring, next, false)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	// Plaintext gets encrypted
+	_, changed, err = reseal(ring, "user-2", false)
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	// Decrypt mode restores plaintext
+	plain, changed, err := reseal(ring, next, true)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "user-1", plain)
+}
+
+func TestRunEncryptionRotateRequiresKey(t *testing.T) {
+	fieldcrypt.Configure(nil)
+	err := runEncryptionRotate(100, true, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no encryption key configured")
+}
@@ -0,0 +1,175 @@
+package cli
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/eventprops"
+	"github.com/seuros/kaunta/internal/stats"
+)
+
+// Events command flags
+var (
+	eventsName   string
+	eventsProp   string
+	eventsWhere  []string
+	eventsDays   int
+	eventsTop    int
+	eventsFormat string
+)
+
+var statsEventsCmd = &cobra.Command{
+	Use:   "events <website-domain> [--name <event>] [--prop <property>] [--where <key=value>]... [--days <N>] [--top <N>] [--format json|table|csv]",
+	Short: "Count custom events and break them down by property",
+	Long: `Count a website's custom events (kaunta.track("signup", {plan: "pro"})).
+
+Without --name, lists the events by name with their unique visitors and the
+trend versus the previous period (a 7-day report compares with the 7 days
+before). With --name, lists the properties that event was sent with; add
+--prop to break it down by the values of one property. --where keeps the
+events sent with a property value; repeat it to require several.
+
+Options:
+  --name EVENT  Custom event to look into
+  --prop KEY    Property of the event to break down by (needs --name)
+  --where K=V   Only events whose property K is V (repeatable)
+  --days N      Period in days (1-365, default 30)
+  --top N       Rows to show (1-100, default 10)
+  --format      Output format: json, table, csv (default table)
+
+Examples:
+  kaunta stats events example.com
+  kaunta stats events example.com --name signup
+  kaunta stats events example.com --name signup --prop plan --days 7
+  kaunta stats events example.com --where plan=pro`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runStatsEvents(args[0], eventsName, eventsProp, eventsWhere, eventsDays, eventsTop, eventsFormat)
+	},
+}
+
+func runStatsEvents(domain, name, prop string, where []string, days, top int, format string) error {
+	if prop != "" && name == "" {
+		return fmt.Errorf("--prop needs --name")
+	}
+	match, err := eventprops.ParseFilterArgs(where)
+	if err != nil {
+		return err
+	}
+	if days < 1 || days > 365 {
+		return fmt.Errorf("days must be between 1 and 365")
+	}
+	if top < 1 || top > 100 {
+		return fmt.Errorf("top must be between 1 and 100")
+	}
+	if format != "table" && format != "json" && format != "csv" {
+		return fmt.Errorf("invalid format: %s (use json, table, or csv)", format)
+	}
+
+	l := localeFor(format)
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		var result interface{}
+		var title string
+		var header []string
+		var rows [][]string
+		switch {
+		case prop != "":
+			props, err := stats.GetEventProperties(ctx, database.DB, websiteID, name, prop, days, top, match)
+			if err != nil {
+				return err
+			}
+			result, title = props, fmt.Sprintf("Event %q by %s", name, prop)
+			header, rows = []string{prop, "events", "visitors"}, eventCountRows(props.Values, l)
+		case name != "":
+			keys, err := stats.GetEventPropertyKeys(ctx, database.DB, websiteID, name, days, top, match)
+			if err != nil {
+				return err
+			}
+			result, title = keys, fmt.Sprintf("Properties of event %q", name)
+			header, rows = []string{"property", "events", "visitors"}, eventCountRows(keys, l)
+		default:
+			events, err := stats.GetEvents(ctx, database.DB, websiteID, days, top, match)
+			if err != nil {
+				return err
+			}
+			result, title = events, "Custom events"
+			header = []string{"event", "events", "visitors", "previous_events", "change"}
+			for _, e := range events {
+				change := "new"
+				if e.ChangePercent != nil {
+					change = l.SignedPercent(*e.ChangePercent)
+				}
+				rows = append(rows, []string{e.Name, l.Int(e.Events), l.Int(e.Visitors), l.Int(e.PreviousEvents), change})
+			}
+		}
+
+		switch format {
+		case "json":
+			data, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to marshal JSON: %w", err)
+			}
+			fmt.Println(string(data))
+			return nil
+		case "csv":
+			w := csv.NewWriter(os.Stdout)
+			defer w.Flush()
+			if err := w.Write(header); err != nil {
+				return fmt.Errorf("failed to write CSV header: %w", err)
+			}
+			for _, row := range rows {
+				if err := w.Write(row); err != nil {
+					return fmt.Errorf("failed to write CSV row: %w", err)
+				}
+			}
+			return nil
+		}
+
+		fmt.Printf("%s on %s (last %d days)\n\n", title, domain, days)
+		if len(rows) == 0 {
+			fmt.Println("No events")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		columns, rules := make([]string, len(header)), make([]string, len(header))
+		for i, h := range header {
+			columns[i] = strings.ToUpper(strings.ReplaceAll(h, "_", " "))
+			rules[i] = strings.Repeat("-", len(h))
+		}
+		_, _ = fmt.Fprintln(w, strings.Join(columns, "\t"))
+		_, _ = fmt.Fprintln(w, strings.Join(rules, "\t"))
+		for _, row := range rows {
+			_, _ = fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		return w.Flush()
+	})
+}
+
+// eventCountRows formats counts as rows of name, events and visitors
+func eventCountRows(counts []stats.EventCount, l numberLocale) [][]string {
+	rows := make([][]string, 0, len(counts))
+	for _, c := range counts {
+		rows = append(rows, []string{c.Name, l.Int(c.Events), l.Int(c.Visitors)})
+	}
+	return rows
+}
+
+func init() {
+	statsCmd.AddCommand(statsEventsCmd)
+
+	statsEventsCmd.Flags().StringVar(&eventsName, "name", "", "Custom event to look into")
+	statsEventsCmd.Flags().StringVar(&eventsProp, "prop", "", "Property to break the event down by")
+	statsEventsCmd.Flags().StringArrayVar(&eventsWhere, "where", nil, "Only events with this property value (key=value, repeatable)")
+	statsEventsCmd.Flags().IntVarP(&eventsDays, "days", "d", 30, "Period in days (1-365)")
+	statsEventsCmd.Flags().IntVarP(&eventsTop, "top", "t", 10, "Rows to show (1-100)")
+	statsEventsCmd.Flags().StringVarP(&eventsFormat, "format", "f", "table", "Output format (json, table, csv)")
+}
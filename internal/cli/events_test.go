@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunStatsEventsByProperty(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectQuery(`e.props ->> \$4`).WithArgs(websiteID, 7, "signup", "plan", 10).
+		WillReturnRows(sqlmock.NewRows([]string{"value", "events", "visitors"}).
+			AddRow("free", 40, 38).AddRow("pro", 12, 10))
+
+	output, err := captureOutput(t, func() error {
+		return runStatsEvents("example.com", "signup", "plan", nil, 7, 10, "table")
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, `Event "signup" by plan on example.com (last 7 days)`)
+	assert.Regexp(t, `PLAN\s+EVENTS\s+VISITORS`, output)
+	assert.Regexp(t, `pro\s+12\s+10`, output)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunStatsEventsByName(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+	rows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"name", "events", "visitors", "previous"}).
+			AddRow("signup", 30, 25, 20).AddRow("download", 12, 9, 0)
+	}
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectQuery(`SELECT e.event_name`).WithArgs(websiteID, 7, 10).WillReturnRows(rows())
+
+	output, err := captureOutput(t, func() error {
+		return runStatsEvents("example.com", "", "", nil, 7, 10, "table")
+	})
+	require.NoError(t, err)
+	assert.Regexp(t, `EVENT\s+EVENTS\s+VISITORS\s+PREVIOUS EVENTS\s+CHANGE`, output)
+	assert.Regexp(t, `signup\s+30\s+25\s+20\s+\+50.0%`, output)
+	assert.Regexp(t, `download\s+12\s+9\s+0\s+new`, output)
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectQuery(`SELECT e.event_name`).WithArgs(websiteID, 7, 10).WillReturnRows(rows())
+
+	output, err = captureOutput(t, func() error {
+		return runStatsEvents("example.com", "", "", nil, 7, 10, "csv")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "event,events,visitors,previous_events,change\nsignup,30,25,20,+50.0%\ndownload,12,9,0,new\n", output)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunStatsEventsValidation(t *testing.T) {
+	assert.EqualError(t, runStatsEvents("example.com", "", "plan", nil, 30, 10, "table"), "--prop needs --name")
+	assert.EqualError(t, runStatsEvents("example.com", "signup", "", nil, 30, 10, "xml"),
+		"invalid format: xml (use json, table, or csv)")
+}
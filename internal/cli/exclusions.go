@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/exclusions"
+)
+
+var websiteExclusionsCmd = &cobra.Command{
+	Use:   "exclusions",
+	Short: "Manage rules excluding internal traffic",
+	Long: `Exclusion rules keep internal traffic out of a website's stats. A tracking
+request matching any rule is dropped before anything about it is recorded:
+
+  ip        an address or CIDR range (10.0.0.0/8, 203.0.113.7)
+  hostname  the page's hostname, exact (staging.example.com) or with a
+            leading wildcard (*.internal.example.com)
+  path      a URL path pattern where * matches anything (/admin/*)
+
+Rule changes take up to a minute to reach running servers. Exclusion rules
+require PostgreSQL.`,
+}
+
+var websiteExclusionsAddCmd = &cobra.Command{
+	Use:   "add <domain> <ip|hostname|path> <value>",
+	Short: "Add an exclusion rule",
+	Long: `Add an exclusion rule. A single IP address is stored as /32 (or /128).
+
+Examples:
+  kaunta website exclusions add example.com ip 203.0.113.0/24
+  kaunta website exclusions add example.com hostname localhost
+  kaunta website exclusions add example.com path "/admin/*"`,
+	Args: cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteExclusionsAdd(args[0], args[1], args[2])
+	},
+}
+
+var websiteExclusionsListCmd = &cobra.Command{
+	Use:   "list <domain>",
+	Short: "List a website's exclusion rules",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteExclusionsList(args[0])
+	},
+}
+
+var websiteExclusionsRemoveCmd = &cobra.Command{
+	Use:   "remove <domain> <ip|hostname|path> <value>",
+	Short: "Remove an exclusion rule",
+	Args:  cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteExclusionsRemove(args[0], args[1], args[2])
+	},
+}
+
+func runWebsiteExclusionsAdd(domain, ruleType, value string) error {
+	ruleType = strings.ToLower(ruleType)
+	if _, err := exclusions.Normalize(ruleType, value); err != nil {
+		return err
+	}
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		stored, err := exclusions.Add(ctx, database.DB, websiteID, ruleType, value)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s now excludes %s %s\n", domain, ruleType, stored)
+		return nil
+	})
+}
+
+func runWebsiteExclusionsList(domain string) error {
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		rules, err := exclusions.List(ctx, database.DB, websiteID)
+		if err != nil {
+			return err
+		}
+		if len(rules) == 0 {
+			fmt.Printf("%s has no exclusion rules\n", domain)
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "TYPE\tVALUE\tCREATED")
+		_, _ = fmt.Fprintln(w, "----\t-----\t-------")
+		for _, r := range rules {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", r.Type, r.Value, r.CreatedAt.Format("2006-01-02 15:04"))
+		}
+		return w.Flush()
+	})
+}
+
+func runWebsiteExclusionsRemove(domain, ruleType, value string) error {
+	ruleType = strings.ToLower(ruleType)
+	if _, err := exclusions.Normalize(ruleType, value); err != nil {
+		return err
+	}
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		err := exclusions.Remove(ctx, database.DB, websiteID, ruleType, value)
+		if errors.Is(err, exclusions.ErrNotFound) {
+			return fmt.Errorf("%s has no %s exclusion %s", domain, ruleType, value)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s exclusion %s removed from %s\n", ruleType, value, domain)
+		return nil
+	})
+}
+
+func init() {
+	websiteCmd.AddCommand(websiteExclusionsCmd)
+	websiteExclusionsCmd.AddCommand(websiteExclusionsAddCmd, websiteExclusionsListCmd, websiteExclusionsRemoveCmd)
+}
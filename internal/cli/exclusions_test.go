@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunWebsiteExclusionsAdd(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectExec(`INSERT INTO website_exclusion`).WithArgs(websiteID, "ip", "203.0.113.7/32").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	output, err := captureOutput(t, func() error { return runWebsiteExclusionsAdd("example.com", "IP", "203.0.113.7") })
+	require.NoError(t, err)
+	assert.Contains(t, output, "example.com now excludes ip 203.0.113.7/32")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunWebsiteExclusionsList(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectQuery(`FROM website_exclusion`).WithArgs(websiteID).
+		WillReturnRows(sqlmock.NewRows([]string{"rule_type", "value", "created_at"}).
+			AddRow("path", "/admin/*", time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)))
+
+	output, err := captureOutput(t, func() error { return runWebsiteExclusionsList("example.com") })
+	require.NoError(t, err)
+	assert.Contains(t, output, "TYPE")
+	assert.Contains(t, output, "path  /admin/*  2026-03-01 09:30")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunWebsiteExclusionsRemoveUnknown(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectExec(`DELETE FROM website_exclusion`).WithArgs(websiteID, "hostname", "localhost").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := runWebsiteExclusionsRemove("example.com", "hostname", "localhost")
+	assert.EqualError(t, err, "example.com has no hostname exclusion localhost")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunWebsiteExclusionsAddValidation(t *testing.T) {
+	err := runWebsiteExclusionsAdd("example.com", "country", "FR")
+	assert.EqualError(t, err, "invalid rule type: country (use ip, hostname or path)")
+}
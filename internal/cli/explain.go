@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/privacy"
+	"github.com/seuros/kaunta/internal/stats"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// Explain command flags
+var (
+	explainWebsite string
+	explainFormat  string
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain [metric] [--website <domain>] [--format text|json]",
+	Short: "Explain how a metric is computed",
+	Long: `Explain how a metric is computed under the current configuration
+(ip_mode, adaptive sampling) and, with --website, the website's settings
+(bot filtering, Do Not Track). Without a metric, every metric is explained.
+
+Metrics: ` + strings.Join(stats.Metrics(), ", ") + `
+
+The dashboard reads the same definitions from GET /api/definitions
+(?website_id=, ?metric=).
+
+Examples:
+  kaunta explain bounce_rate
+  kaunta explain visitors --website example.com`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		metric := ""
+		if len(args) > 0 {
+			metric = args[0]
+		}
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		settings := stats.Settings{IPMode: privacy.CurrentMode(), AdaptiveSampling: cfg.AdaptiveSampling}
+		return runExplain(metric, explainWebsite, settings, explainFormat)
+	},
+}
+
+func runExplain(metric, domain string, settings stats.Settings, format string) error {
+	if format != "text" && format != "json" {
+		return fmt.Errorf("invalid format: %s (use json or text)", format)
+	}
+	if metric != "" {
+		if _, ok := stats.Explain(metric, settings); !ok {
+			return fmt.Errorf("unknown metric: %s (known: %s)", metric, strings.Join(stats.Metrics(), ", "))
+		}
+	}
+
+	if domain == "" {
+		return outputDefinitions(metric, settings, format)
+	}
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		website, err := store.Current().WebsiteSettings(ctx, websiteID)
+		if err != nil {
+			return fmt.Errorf("failed to load website settings: %w", err)
+		}
+		settings.Website = website
+		return outputDefinitions(metric, settings, format)
+	})
+}
+
+func outputDefinitions(metric string, settings stats.Settings, format string) error {
+	list := stats.Definitions(settings)
+	if metric != "" {
+		definition, _ := stats.Explain(metric, settings)
+		list = []stats.Definition{definition}
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(list)
+	}
+	for i, d := range list {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s (%s)\n", d.Title, d.Metric)
+		fmt.Printf("  %s\n", d.Definition)
+		for _, note := range d.Notes {
+			fmt.Printf("  - %s\n", note)
+		}
+	}
+	return nil
+}
+
+func init() {
+	RootCmd.AddCommand(explainCmd)
+
+	explainCmd.Flags().StringVar(&explainWebsite, "website", "", "Website domain, to include its settings")
+	explainCmd.Flags().StringVar(&explainFormat, "format", "text", "Output format: text, json")
+}
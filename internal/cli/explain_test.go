@@ -0,0 +1,25 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/privacy"
+	"github.com/seuros/kaunta/internal/stats"
+)
+
+func TestRunExplain(t *testing.T) {
+	output, err := captureOutput(t, func() error {
+		return runExplain("bounce_rate", "", stats.Settings{IPMode: privacy.Truncate}, "text")
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "Bounce rate (bounce_rate)")
+	assert.Contains(t, output, "- ip_mode is truncate")
+}
+
+func TestRunExplainUnknownMetric(t *testing.T) {
+	err := runExplain("revenue", "", stats.Settings{}, "text")
+	assert.ErrorContains(t, err, "unknown metric: revenue (known: period, visitors,")
+}
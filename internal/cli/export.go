@@ -0,0 +1,230 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// Export popularity command flags
+var (
+	popularityFormat string
+	popularityOut    string
+	popularityDays   int
+	popularityTop    int
+	popularityPrefix string
+	popularityEvery  time.Duration
+)
+
+// popularityPool is how many pages are read when filtering by prefix, so
+// enough of them are left for --top
+const popularityPool = 1000
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export analytics data for other tools",
+}
+
+var exportPopularityCmd = &cobra.Command{
+	Use:   "popularity <website-domain> --format hugo|eleventy --out <file> [--days <N>] [--top <N>] [--prefix <path>] [--every <interval>]",
+	Short: "Write the most viewed pages as a static site data file",
+	Long: `Write a website's most viewed pages as a JSON data file, for static sites to
+render "most read" lists at build time from their own analytics.
+
+Formats:
+  hugo       {"website", "days", "generated_at", "pages": [{"rank", "path",
+             "pageviews", "visitors"}]}; in templates .Site.Data.popular.pages,
+             matched with a page's .RelPermalink
+  eleventy   [{"rank", "url", "pageviews", "visitors"}]; global data
+             (_data/popular.json), matched with page.url
+
+The file is replaced atomically, so a build running at the same time never
+reads half of it. Run the command from cron or CI before building, or keep it
+running with --every.
+
+Options:
+  --days N       Period in days (1-365, default 30)
+  --top N        Number of pages (1-100, default 10)
+  --prefix PATH  Only pages under a path, e.g. /blog/
+  --every D      Rewrite the file every interval (e.g. 1h) until interrupted
+
+Examples:
+  kaunta export popularity example.com --format hugo --out data/popular.json
+  kaunta export popularity example.com --format eleventy --out _data/popular.json --prefix /posts/ --every 6h`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runExportPopularity(args[0], popularityFormat, popularityOut, popularityDays, popularityTop,
+			popularityPrefix, popularityEvery)
+	},
+}
+
+// PopularPage is one page of a popularity export
+type PopularPage struct {
+	Rank      int    `json:"rank"`
+	Path      string `json:"path,omitempty"`
+	URL       string `json:"url,omitempty"`
+	Pageviews int64  `json:"pageviews"`
+	Visitors  int64  `json:"visitors"`
+}
+
+// hugoPopularity is the data file of the hugo format
+type hugoPopularity struct {
+	Website     string        `json:"website"`
+	Days        int           `json:"days"`
+	GeneratedAt time.Time     `json:"generated_at"`
+	Pages       []PopularPage `json:"pages"`
+}
+
+// popularPages ranks the pages under prefix, keeping top of them. Hugo
+// calls a page's path path, Eleventy url.
+func popularPages(pages []*PageStat, format, prefix string, top int) []PopularPage {
+	popular := make([]PopularPage, 0, top)
+	for _, page := range pages {
+		if !strings.HasPrefix(page.Path, prefix) {
+			continue
+		}
+		p := PopularPage{Rank: len(popular) + 1, Pageviews: page.Pageviews, Visitors: page.UniqueVisitors}
+		if format == "eleventy" {
+			p.URL = page.Path
+		} else {
+			p.Path = page.Path
+		}
+		popular = append(popular, p)
+		if len(popular) == top {
+			break
+		}
+	}
+	return popular
+}
+
+// writePopularity writes the data file through a temporary file renamed
+// over it
+func writePopularity(out, format, domain string, days int, pages []PopularPage, now time.Time) error {
+	var data interface{} = pages
+	if format == "hugo" {
+		data = hugoPopularity{Website: domain, Days: days, GeneratedAt: now.UTC(), Pages: pages}
+	}
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(out)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(out)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", out, err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(append(content, '\n')); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", out, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", out, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), out); err != nil {
+		return fmt.Errorf("failed to write %s: %w", out, err)
+	}
+	return nil
+}
+
+func runExportPopularity(domain, format, out string, days, top int, prefix string, every time.Duration) error {
+	if format != "hugo" && format != "eleventy" {
+		return fmt.Errorf("invalid format: %s (use hugo or eleventy)", format)
+	}
+	if out == "" {
+		return fmt.Errorf("--out is required")
+	}
+	if days < 1 || days > 365 {
+		return fmt.Errorf("days must be between 1 and 365")
+	}
+	if top < 1 || top > 100 {
+		return fmt.Errorf("top must be between 1 and 100")
+	}
+	if every != 0 && every < time.Minute {
+		return fmt.Errorf("--every must be at least 1m")
+	}
+
+	if database.DB == nil {
+		if err := connectDatabase(); err != nil {
+			return fmt.Errorf("database connection failed: %w", err)
+		}
+		defer func() { _ = closeDatabase() }()
+	}
+
+	export := func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		websiteID, err := getWebsiteIDByDomainFn(ctx, domain)
+		if err != nil {
+			return err
+		}
+		limit := top
+		if prefix != "" {
+			limit = popularityPool
+		}
+		pages, err := getTopPagesFn(ctx, database.DB, websiteID, days, limit, store.Filters{})
+		if err != nil {
+			return err
+		}
+		popular := popularPages(pages, format, prefix, top)
+		if err := writePopularity(out, format, domain, days, popular, time.Now()); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %d pages to %s\n", len(popular), out)
+		return nil
+	}
+
+	if err := export(); err != nil {
+		return err
+	}
+	if every == 0 {
+		return nil
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signalNotifyFunc(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	tickCh, stopTicker := tickerFactory(every)
+	defer stopTicker()
+
+	for {
+		select {
+		case <-sigChan:
+			return nil
+		case <-tickCh:
+			// A failed run keeps the last file; the next one tries again
+			if err := export(); err != nil {
+				fmt.Fprintf(os.Stderr, "Export failed: %v\n", err)
+			}
+		}
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(exportCmd)
+	exportCmd.AddCommand(exportPopularityCmd)
+
+	exportPopularityCmd.Flags().StringVar(&popularityFormat, "format", "", "Data file format: hugo or eleventy")
+	exportPopularityCmd.Flags().StringVarP(&popularityOut, "out", "o", "", "File to write, e.g. data/popular.json")
+	exportPopularityCmd.Flags().IntVarP(&popularityDays, "days", "d", 30, "Period in days")
+	exportPopularityCmd.Flags().IntVarP(&popularityTop, "top", "t", 10, "Number of pages")
+	exportPopularityCmd.Flags().StringVar(&popularityPrefix, "prefix", "", "Only pages under this path")
+	exportPopularityCmd.Flags().DurationVar(&popularityEvery, "every", 0, "Rewrite the file every interval until interrupted")
+}
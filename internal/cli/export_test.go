@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/store"
+)
+
+func topPages() []*PageStat {
+	return []*PageStat{
+		{Path: "/", Pageviews: 900, UniqueVisitors: 700},
+		{Path: "/blog/go-generics/", Pageviews: 420, UniqueVisitors: 300},
+		{Path: "/about/", Pageviews: 200, UniqueVisitors: 180},
+		{Path: "/blog/sqlite/", Pageviews: 150, UniqueVisitors: 90},
+	}
+}
+
+func TestPopularPages(t *testing.T) {
+	assert.Equal(t, []PopularPage{
+		{Rank: 1, Path: "/", Pageviews: 900, Visitors: 700},
+		{Rank: 2, Path: "/blog/go-generics/", Pageviews: 420, Visitors: 300},
+	}, popularPages(topPages(), "hugo", "", 2))
+
+	assert.Equal(t, []PopularPage{
+		{Rank: 1, URL: "/blog/go-generics/", Pageviews: 420, Visitors: 300},
+		{Rank: 2, URL: "/blog/sqlite/", Pageviews: 150, Visitors: 90},
+	}, popularPages(topPages(), "eleventy", "/blog/", 10))
+}
+
+func TestRunExportPopularity(t *testing.T) {
+	stubDB(t)
+	stubWebsiteIDLookup(t, func(ctx context.Context, domain string) (string, error) {
+		return "website-id", nil
+	})
+	var limit int
+	stubTopPagesFetcher(t, func(ctx context.Context, db *sql.DB, websiteID string, days, top int, filters store.Filters) ([]*PageStat, error) {
+		assert.Equal(t, 7, days)
+		limit = top
+		return topPages(), nil
+	})
+
+	out := filepath.Join(t.TempDir(), "data", "popular.json")
+	_, err := captureOutput(t, func() error {
+		return runExportPopularity("example.com", "hugo", out, 7, 3, "", 0)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, limit)
+
+	var hugo hugoPopularity
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &hugo))
+	assert.Equal(t, "example.com", hugo.Website)
+	assert.Equal(t, 7, hugo.Days)
+	assert.Len(t, hugo.Pages, 3)
+	assert.Equal(t, "/about/", hugo.Pages[2].Path)
+
+	// Filtering by prefix reads more pages to have enough left
+	_, err = captureOutput(t, func() error {
+		return runExportPopularity("example.com", "eleventy", out, 7, 3, "/blog/", 0)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, popularityPool, limit)
+
+	var eleventy []map[string]interface{}
+	data, err = os.ReadFile(out)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &eleventy))
+	assert.Equal(t, []map[string]interface{}{
+		{"rank": 1.0, "url": "/blog/go-generics/", "pageviews": 420.0, "visitors": 300.0},
+		{"rank": 2.0, "url": "/blog/sqlite/", "pageviews": 150.0, "visitors": 90.0},
+	}, eleventy)
+
+	entries, err := os.ReadDir(filepath.Dir(out))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary file is left behind")
+}
+
+func TestRunExportPopularityEvery(t *testing.T) {
+	stubDB(t)
+	stubWebsiteIDLookup(t, func(ctx context.Context, domain string) (string, error) {
+		return "website-id", nil
+	})
+	runs := 0
+	stubTopPagesFetcher(t, func(ctx context.Context, db *sql.DB, websiteID string, days, top int, filters store.Filters) ([]*PageStat, error) {
+		runs++
+		return topPages(), nil
+	})
+	ticks := make(chan time.Time, 2)
+	ticks <- time.Now()
+	ticks <- time.Now()
+	stubTickerFactory(t, func(d time.Duration) (<-chan time.Time, func()) {
+		assert.Equal(t, time.Hour, d)
+		return ticks, func() {}
+	})
+	stubSignalNotify(t, func(c chan<- os.Signal, sig ...os.Signal) {
+		go func() {
+			for len(ticks) > 0 {
+				time.Sleep(time.Millisecond)
+			}
+			time.Sleep(10 * time.Millisecond)
+			c <- os.Interrupt
+		}()
+	})
+
+	out := filepath.Join(t.TempDir(), "popular.json")
+	_, err := captureOutput(t, func() error {
+		return runExportPopularity("example.com", "hugo", out, 30, 10, "", time.Hour)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, runs)
+}
+
+func TestRunExportPopularityValidation(t *testing.T) {
+	assert.EqualError(t, runExportPopularity("example.com", "jekyll", "out.json", 30, 10, "", 0),
+		"invalid format: jekyll (use hugo or eleventy)")
+	assert.EqualError(t, runExportPopularity("example.com", "hugo", "", 30, 10, "", 0), "--out is required")
+	assert.Error(t, runExportPopularity("example.com", "hugo", "out.json", 0, 10, "", 0))
+	assert.Error(t, runExportPopularity("example.com", "hugo", "out.json", 30, 101, "", 0))
+	assert.EqualError(t, runExportPopularity("example.com", "hugo", "out.json", 30, 10, "", time.Second),
+		"--every must be at least 1m")
+}
@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/features"
+)
+
+// Feature command flags
+var (
+	featureWebsite    string
+	featureListFormat string
+)
+
+var featureCmd = &cobra.Command{
+	Use:   "feature",
+	Short: "Switch optional and experimental features on and off",
+	Long: `Feature flags switch optional and experimental subsystems on and off
+without rebuilding or restarting, for the whole instance or one website
+(--website). The features setting of the config (FEATURES=name,-name) sets
+the instance's starting point; the flags set here override it, and a
+website's override wins over the instance's.
+
+Known flags: ` + strings.Join(features.Names(), ", ") + `
+
+The dashboard reads the flags from GET /api/features (?website_id=).`,
+}
+
+var featureListCmd = &cobra.Command{
+	Use:   "list [--website <domain>] [--format json|table]",
+	Short: "Show the flags and where their values come from",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runFeatureList(featureWebsite, featureListFormat)
+	},
+}
+
+var featureEnableCmd = &cobra.Command{
+	Use:   "enable <flag> [--website <domain>]",
+	Short: "Switch a flag on for the instance or a website",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runFeatureSet(args[0], featureWebsite, true)
+	},
+}
+
+var featureDisableCmd = &cobra.Command{
+	Use:   "disable <flag> [--website <domain>]",
+	Short: "Switch a flag off for the instance or a website",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runFeatureSet(args[0], featureWebsite, false)
+	},
+}
+
+var featureResetCmd = &cobra.Command{
+	Use:   "reset <flag> [--website <domain>]",
+	Short: "Remove an override, going back to the config or the default",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runFeatureReset(args[0], featureWebsite)
+	},
+}
+
+// withFeatureScope runs fn for the website of domain, or for the instance
+// (nil website ID) when domain is empty
+func withFeatureScope(domain string, fn func(ctx context.Context, websiteID *uuid.UUID, scope string) error) error {
+	if domain != "" {
+		return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+			return fn(ctx, &websiteID, domain)
+		})
+	}
+
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return fn(ctx, nil, "the instance")
+}
+
+func runFeatureList(domain, format string) error {
+	if format != "table" && format != "json" {
+		return fmt.Errorf("invalid format: %s (use json or table)", format)
+	}
+
+	return withFeatureScope(domain, func(ctx context.Context, websiteID *uuid.UUID, scope string) error {
+		states, err := features.Resolve(ctx, database.DB, websiteID)
+		if err != nil {
+			return err
+		}
+		if format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(states)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "FLAG\tSTATE\tSOURCE\tDESCRIPTION")
+		_, _ = fmt.Fprintln(w, "----\t-----\t------\t-----------")
+		for _, s := range states {
+			state := "off"
+			if s.Enabled {
+				state = "on"
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Name, state, s.Source, s.Description)
+		}
+		return w.Flush()
+	})
+}
+
+func runFeatureSet(name, domain string, enabled bool) error {
+	if _, ok := features.Lookup(name); !ok {
+		return fmt.Errorf("unknown feature flag: %s (known: %s)", name, strings.Join(features.Names(), ", "))
+	}
+
+	return withFeatureScope(domain, func(ctx context.Context, websiteID *uuid.UUID, scope string) error {
+		if err := features.Set(ctx, database.DB, name, websiteID, enabled); err != nil {
+			return err
+		}
+		state := "disabled"
+		if enabled {
+			state = "enabled"
+		}
+		fmt.Printf("Feature %s %s for %s\n", name, state, scope)
+		return nil
+	})
+}
+
+func runFeatureReset(name, domain string) error {
+	return withFeatureScope(domain, func(ctx context.Context, websiteID *uuid.UUID, scope string) error {
+		removed, err := features.Reset(ctx, database.DB, name, websiteID)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			fmt.Printf("Feature %s has no override for %s\n", name, scope)
+			return nil
+		}
+		fmt.Printf("Feature %s reset for %s\n", name, scope)
+		return nil
+	})
+}
+
+func init() {
+	RootCmd.AddCommand(featureCmd)
+	featureCmd.AddCommand(featureListCmd, featureEnableCmd, featureDisableCmd, featureResetCmd)
+
+	featureCmd.PersistentFlags().StringVar(&featureWebsite, "website", "", "Website domain (default: the whole instance)")
+	featureListCmd.Flags().StringVar(&featureListFormat, "format", "table", "Output format: json, table")
+}
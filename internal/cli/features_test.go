@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunFeatureSetForWebsite(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectExec(`INSERT INTO feature_flag .* ON CONFLICT \(name, website_id\)`).
+		WithArgs("sessions", &websiteID, false).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	output, err := captureOutput(t, func() error {
+		return runFeatureSet("sessions", "example.com", false)
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "Feature sessions disabled for example.com")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunFeatureSetUnknown(t *testing.T) {
+	assert.EqualError(t, runFeatureSet("warp-drive", "", true), "unknown feature flag: warp-drive (known: sessions)")
+}
+
+func TestRunFeatureList(t *testing.T) {
+	mock := mockJobsDB(t)
+	mock.ExpectQuery(`FROM feature_flag`).WithArgs(nil).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "website_id", "enabled"}).AddRow("sessions", nil, false))
+
+	output, err := captureOutput(t, func() error {
+		return runFeatureList("", "table")
+	})
+	require.NoError(t, err)
+	assert.Regexp(t, `sessions\s+off\s+instance`, output)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunFeatureResetWithoutOverride(t *testing.T) {
+	mock := mockJobsDB(t)
+	mock.ExpectExec(`DELETE FROM feature_flag WHERE name = \$1 AND website_id IS NULL`).WithArgs("sessions").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	output, err := captureOutput(t, func() error {
+		return runFeatureReset("sessions", "")
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "Feature sessions has no override for the instance")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
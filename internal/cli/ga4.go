@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/ga4"
+)
+
+var (
+	ga4MeasurementID string
+	ga4APISecret     string
+	ga4Disable       bool
+)
+
+var websiteGA4Cmd = &cobra.Command{
+	Use:   "ga4 <domain> --measurement-id G-XXXXXXX [--api-secret SECRET] | --disable",
+	Short: "Receive GA4 Measurement Protocol hits for a website",
+	Long: `Let server-side Google Analytics integrations send their events to Kaunta.
+
+Hits posted to /mp/collect?measurement_id=<id>&api_secret=<secret>, as to
+https://www.google-analytics.com/mp/collect, are recorded for the website:
+page_view events as pageviews, other events as custom events with their
+parameters as event data. /debug/mp/collect checks a hit without recording it.
+
+Use the measurement ID and API secret of the GA web stream to dual-write with
+the same credentials; without --api-secret a new secret is generated. Only a
+hash of the secret is stored, so it is shown once. --disable stops accepting
+hits for the website.
+
+Examples:
+  kaunta website ga4 example.com --measurement-id G-ABC123XYZ --api-secret s3cr3t
+  kaunta website ga4 example.com --disable`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteGA4(args[0], ga4MeasurementID, ga4APISecret, ga4Disable)
+	},
+}
+
+func runWebsiteGA4(domain, measurementID, apiSecret string, disable bool) error {
+	if !disable {
+		measurementID = strings.ToUpper(strings.TrimSpace(measurementID))
+		if err := ga4.ValidMeasurementID(measurementID); err != nil {
+			return err
+		}
+	}
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		if disable {
+			_, err := database.DB.ExecContext(ctx, `
+				UPDATE website SET ga_measurement_id = NULL, ga_api_secret_hash = NULL, updated_at = NOW()
+				WHERE website_id = $1`, websiteID)
+			if err != nil {
+				return fmt.Errorf("failed to disable GA4 ingestion: %w", err)
+			}
+			fmt.Printf("%s no longer receives GA4 hits\n", domain)
+			return nil
+		}
+
+		generated := apiSecret == ""
+		if generated {
+			secret, err := newShareID()
+			if err != nil {
+				return err
+			}
+			apiSecret = secret
+		}
+
+		_, err := database.DB.ExecContext(ctx, `
+			UPDATE website SET ga_measurement_id = $2, ga_api_secret_hash = $3, updated_at = NOW()
+			WHERE website_id = $1`, websiteID, measurementID, ga4.HashSecret(apiSecret))
+		if err != nil {
+			if strings.Contains(err.Error(), "idx_website_ga_measurement_id") {
+				return fmt.Errorf("measurement ID %s is already used by another website", measurementID)
+			}
+			return fmt.Errorf("failed to enable GA4 ingestion: %w", err)
+		}
+
+		fmt.Printf("%s receives GA4 hits for %s\n", domain, measurementID)
+		if generated {
+			fmt.Printf("API secret: %s (shown once)\n", apiSecret)
+		}
+		fmt.Printf("Endpoint: /mp/collect?measurement_id=%s&api_secret=<secret>\n", measurementID)
+		return nil
+	})
+}
+
+func init() {
+	websiteCmd.AddCommand(websiteGA4Cmd)
+	websiteGA4Cmd.Flags().StringVar(&ga4MeasurementID, "measurement-id", "", "GA4 measurement ID (G-XXXXXXX)")
+	websiteGA4Cmd.Flags().StringVar(&ga4APISecret, "api-secret", "", "Measurement Protocol API secret (generated when omitted)")
+	websiteGA4Cmd.Flags().BoolVar(&ga4Disable, "disable", false, "Stop accepting GA4 hits")
+}
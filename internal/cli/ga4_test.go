@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/ga4"
+)
+
+func TestRunWebsiteGA4(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectExec(`UPDATE website SET ga_measurement_id = \$2`).
+		WithArgs(websiteID, "G-ABC123XYZ", ga4.HashSecret("s3cr3t")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	output, err := captureOutput(t, func() error { return runWebsiteGA4("example.com", "g-abc123xyz", "s3cr3t", false) })
+	require.NoError(t, err)
+	assert.Contains(t, output, "example.com receives GA4 hits for G-ABC123XYZ")
+	assert.NotContains(t, output, "API secret:")
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectExec(`UPDATE website SET ga_measurement_id = \$2`).
+		WithArgs(websiteID, "G-ABC123XYZ", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	output, err = captureOutput(t, func() error { return runWebsiteGA4("example.com", "G-ABC123XYZ", "", false) })
+	require.NoError(t, err)
+	assert.Regexp(t, `API secret: [0-9a-f]{24} \(shown once\)`, output)
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectExec(`UPDATE website SET ga_measurement_id = NULL`).WithArgs(websiteID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	output, err = captureOutput(t, func() error { return runWebsiteGA4("example.com", "", "", true) })
+	require.NoError(t, err)
+	assert.Contains(t, output, "example.com no longer receives GA4 hits")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunWebsiteGA4RejectsBadMeasurementID(t *testing.T) {
+	err := runWebsiteGA4("example.com", "UA-12345-1", "s3cr3t", false)
+	assert.ErrorContains(t, err, "invalid measurement ID")
+}
@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/gdpr"
+)
+
+var (
+	gdprDistinctID string
+	gdprOut        string
+	gdprWebsite    string
+)
+
+var gdprCmd = &cobra.Command{
+	Use:   "gdpr",
+	Short: "Answer data-subject requests about a visitor",
+	Long: `Answer data-subject requests about a visitor known by their distinct ID (the
+id field a website sends in its tracking payloads). Visitors tracked without
+one can't be told apart from others, so nothing can be exported about them.
+Requires PostgreSQL.`,
+}
+
+var gdprExportCmd = &cobra.Command{
+	Use:   "export --distinct-id <id> [--out <file>] [--website <domain>]",
+	Short: "Export everything stored about a visitor",
+	Long: `Export everything stored about a visitor as readable JSON: their sessions
+(device, browser, language and location), every pageview and custom event
+with its properties, and derived data (sessions linked across websites,
+baseline pixel hits), with notes explaining each part.
+
+Encrypted distinct IDs and event properties are decrypted, so the keys that
+sealed them must be configured. The file is written readable by its owner
+only; without --out the export is printed.
+
+Examples:
+  kaunta gdpr export --distinct-id user-1842 --out visitor.json
+  kaunta gdpr export --distinct-id user-1842 --website example.com`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runGDPRExport(gdprDistinctID, gdprOut, gdprWebsite)
+	},
+}
+
+func runGDPRExport(distinctID, out, website string) error {
+	distinctID = strings.TrimSpace(distinctID)
+	if distinctID == "" {
+		return fmt.Errorf("--distinct-id is required")
+	}
+
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	websiteID := uuid.Nil
+	if website != "" {
+		w, err := GetWebsiteByDomain(ctx, website, nil)
+		if err != nil {
+			return err
+		}
+		if websiteID, err = uuid.Parse(w.WebsiteID); err != nil {
+			return err
+		}
+	}
+
+	visitor, err := gdpr.Export(ctx, database.DB, distinctID, websiteID, time.Now())
+	if errors.Is(err, gdpr.ErrNotFound) {
+		return fmt.Errorf("no sessions with distinct ID %q", distinctID)
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(visitor, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	if out == "" || out == "-" {
+		fmt.Println(string(data))
+		return nil
+	}
+	if err := os.WriteFile(out, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", out, err)
+	}
+
+	events := 0
+	for _, s := range visitor.Sessions {
+		events += len(s.Events)
+	}
+	fmt.Printf("Wrote %d session(s) and %d event(s) to %s\n", len(visitor.Sessions), events, out)
+	return nil
+}
+
+func init() {
+	gdprCmd.AddCommand(gdprExportCmd)
+	gdprExportCmd.Flags().StringVar(&gdprDistinctID, "distinct-id", "", "Distinct ID of the visitor")
+	gdprExportCmd.Flags().StringVarP(&gdprOut, "out", "o", "", "File to write (default: print)")
+	gdprExportCmd.Flags().StringVar(&gdprWebsite, "website", "", "Only the visitor's sessions on this website")
+	RootCmd.AddCommand(gdprCmd)
+}
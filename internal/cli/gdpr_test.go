@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunGDPRExport(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID, sessionID := uuid.New(), uuid.New()
+	at := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectQuery("FROM session s").WithArgs("user-1842", sqlmock.AnyArg(), websiteID).
+		WillReturnRows(sqlmock.NewRows([]string{"session_id", "domain", "distinct_id", "created_at", "browser", "os",
+			"device", "screen", "language", "country", "region", "city", "asn", "isp"}).
+			AddRow(sessionID, "example.com", "user-1842", at, "Firefox", "Linux", "desktop", nil, nil, "DE", nil, nil, nil, nil))
+	mock.ExpectQuery("FROM website_event").WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}))
+	mock.ExpectQuery("FROM session_link").WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows([]string{"session_id", "domain", "created_at"}))
+	mock.ExpectQuery("FROM baseline_hit").WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows([]string{"day"}))
+
+	out := filepath.Join(t.TempDir(), "visitor.json")
+	output, err := captureOutput(t, func() error { return runGDPRExport("user-1842", out, "example.com") })
+	require.NoError(t, err)
+	assert.Contains(t, output, "Wrote 1 session(s) and 0 event(s)")
+
+	info, err := os.Stat(out)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"distinct_id": "user-1842"`)
+	assert.Contains(t, string(data), `"country": "DE"`)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunGDPRExportNeedsDistinctID(t *testing.T) {
+	assert.ErrorContains(t, runGDPRExport(" ", "", ""), "--distinct-id is required")
+}
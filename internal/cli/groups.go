@@ -0,0 +1,362 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/groups"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// Website group command flags
+var (
+	groupListFormat  string
+	groupStatsDays   int
+	groupStatsBy     string
+	groupStatsLimit  int
+	groupStatsFormat string
+)
+
+// groupStatsDimensions are the breakdowns 'group stats --by' accepts
+var groupStatsDimensions = []string{"page", "referrer", "browser", "device", "country", "region", "city", "channel"}
+
+var websiteGroupCmd = &cobra.Command{
+	Use:   "group",
+	Short: "Manage website groups, roll-up views of several websites",
+	Long: `A website group adds several websites together (all of a company's
+properties), so their overview, pageviews over time and breakdowns read as
+one. The dashboard API serves them under /api/groups/<group-id>.
+
+Sessions belong to one website, so a visitor of two members counts twice.
+Requires PostgreSQL.`,
+}
+
+var websiteGroupCreateCmd = &cobra.Command{
+	Use:   "create <name> [domain...]",
+	Short: "Create a website group, optionally with its first websites",
+	Long: `Create a website group. Names use lowercase letters, digits, _ and -.
+
+Examples:
+  kaunta website group create acme
+  kaunta website group create acme acme.com shop.acme.com blog.acme.com`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteGroupCreate(args[0], args[1:])
+	},
+}
+
+var websiteGroupAddCmd = &cobra.Command{
+	Use:   "add <name> <domain...>",
+	Short: "Add websites to a group",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteGroupAdd(args[0], args[1:])
+	},
+}
+
+var websiteGroupRemoveCmd = &cobra.Command{
+	Use:   "remove <name> <domain>",
+	Short: "Take a website out of a group",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteGroupRemove(args[0], args[1])
+	},
+}
+
+var websiteGroupDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a website group; its websites stay",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteGroupDelete(args[0])
+	},
+}
+
+var websiteGroupListCmd = &cobra.Command{
+	Use:   "list [--format json|table]",
+	Short: "List website groups and their websites",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteGroupList(groupListFormat)
+	},
+}
+
+var websiteGroupStatsCmd = &cobra.Command{
+	Use:   "stats <name> [--days <N>] [--by <dimension>] [--limit <N>] [--format json|table]",
+	Short: "Show the combined stats of a website group",
+	Long: `Show today's overview of a website group, its pageviews per day and its
+top values of a breakdown, each summed over the group's websites.
+
+Days start at midnight in each website's time zone, so members in other
+time zones show their days apart.
+
+Examples:
+  kaunta website group stats acme
+  kaunta website group stats acme --days 30 --by referrer --format json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteGroupStats(args[0], groupStatsDays, groupStatsBy, groupStatsLimit, groupStatsFormat)
+	},
+}
+
+// withGroupsDB runs fn with the database connected
+func withGroupsDB(fn func(ctx context.Context) error) error {
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return fn(ctx)
+}
+
+// addGroupMembers adds websites by domain to a group
+func addGroupMembers(ctx context.Context, name string, domains []string) error {
+	for _, domain := range domains {
+		website, err := GetWebsiteByDomain(ctx, domain, nil)
+		if err != nil {
+			return err
+		}
+		websiteID, err := uuid.Parse(website.WebsiteID)
+		if err != nil {
+			return err
+		}
+		if err := groups.AddMember(ctx, database.DB, name, websiteID); err != nil {
+			return groupError(name, err)
+		}
+		fmt.Printf("Added %s to %s\n", website.Domain, name)
+	}
+	return nil
+}
+
+func runWebsiteGroupCreate(name string, domains []string) error {
+	if err := groups.ValidName(name); err != nil {
+		return err
+	}
+	return withGroupsDB(func(ctx context.Context) error {
+		g, err := groups.Create(ctx, database.DB, name)
+		if errors.Is(err, groups.ErrExists) {
+			return fmt.Errorf("website group %s already exists", name)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Website group %s created (ID %s)\n", g.Name, g.ID)
+		return addGroupMembers(ctx, name, domains)
+	})
+}
+
+func runWebsiteGroupAdd(name string, domains []string) error {
+	return withGroupsDB(func(ctx context.Context) error {
+		return addGroupMembers(ctx, name, domains)
+	})
+}
+
+func runWebsiteGroupRemove(name, domain string) error {
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		err := groups.RemoveMember(ctx, database.DB, name, websiteID)
+		if errors.Is(err, groups.ErrNotFound) {
+			return fmt.Errorf("%s is not in website group %s", domain, name)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed %s from %s\n", domain, name)
+		return nil
+	})
+}
+
+func runWebsiteGroupDelete(name string) error {
+	return withGroupsDB(func(ctx context.Context) error {
+		if err := groups.Delete(ctx, database.DB, name); err != nil {
+			return groupError(name, err)
+		}
+		fmt.Printf("Website group %s deleted\n", name)
+		return nil
+	})
+}
+
+func runWebsiteGroupList(format string) error {
+	if format != "table" && format != "json" {
+		return fmt.Errorf("invalid format: %s (use json or table)", format)
+	}
+	return withGroupsDB(func(ctx context.Context) error {
+		list, err := groups.List(ctx, database.DB)
+		if err != nil {
+			return err
+		}
+		if format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(list)
+		}
+
+		if len(list) == 0 {
+			fmt.Println("No website groups")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "NAME\tID\tWEBSITES")
+		_, _ = fmt.Fprintln(w, "----\t--\t--------")
+		for _, g := range list {
+			domains := make([]string, 0, len(g.Members))
+			for _, m := range g.Members {
+				domains = append(domains, m.Domain)
+			}
+			if len(domains) == 0 {
+				domains = append(domains, "-")
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", g.Name, g.ID, strings.Join(domains, ", "))
+		}
+		return w.Flush()
+	})
+}
+
+// groupStats are the combined stats of a website group
+type groupStats struct {
+	Group     string            `json:"group"`
+	Websites  []string          `json:"websites"`
+	Days      int               `json:"days"`
+	Today     groupToday        `json:"today"`
+	Pageviews []timeseriesPoint `json:"pageviews"`
+	Dimension string            `json:"dimension"`
+	Top       []groupTopRow     `json:"top"`
+}
+
+// groupTopRow is a breakdown value of a website group
+type groupTopRow struct {
+	Name      string `json:"name"`
+	Pageviews int64  `json:"pageviews"`
+}
+
+// groupToday is a website group's overview of today
+type groupToday struct {
+	CurrentVisitors int64   `json:"current_visitors"`
+	Pageviews       int64   `json:"pageviews"`
+	Visitors        int64   `json:"visitors"`
+	BounceRate      float64 `json:"bounce_rate"`
+}
+
+func runWebsiteGroupStats(name string, days int, by string, limit int, format string) error {
+	if days < 1 || days > 366 {
+		return fmt.Errorf("days must be between 1 and 366")
+	}
+	if !slices.Contains(groupStatsDimensions, by) {
+		return fmt.Errorf("invalid dimension: %s (use %s)", by, strings.Join(groupStatsDimensions, ", "))
+	}
+	if limit < 1 || limit > 100 {
+		return fmt.Errorf("limit must be between 1 and 100")
+	}
+	if format != "table" && format != "json" {
+		return fmt.Errorf("invalid format: %s (use json or table)", format)
+	}
+
+	return withGroupsDB(func(ctx context.Context) error {
+		g, err := groups.Get(ctx, database.DB, name)
+		if err != nil {
+			return groupError(name, err)
+		}
+		ids, st := g.WebsiteIDs(), store.Current()
+
+		today, err := groups.DashboardStats(ctx, st, ids, store.Filters{})
+		if err != nil {
+			return fmt.Errorf("failed to query overview: %w", err)
+		}
+		points, err := groups.TimeSeries(ctx, st, ids, days, store.IntervalDay, store.Filters{})
+		if err != nil {
+			return fmt.Errorf("failed to query pageviews: %w", err)
+		}
+		top, _, err := groups.Breakdown(ctx, st, ids, by, days, limit, 0, store.Filters{})
+		if err != nil {
+			return fmt.Errorf("failed to query %s breakdown: %w", by, err)
+		}
+
+		result := groupStats{
+			Group:    g.Name,
+			Websites: []string{},
+			Days:     days,
+			Today: groupToday{
+				CurrentVisitors: today.CurrentVisitors,
+				Pageviews:       today.TodayPageviews,
+				Visitors:        today.TodayVisitors,
+				BounceRate:      today.BounceRate,
+			},
+			Pageviews: make([]timeseriesPoint, 0, len(points)),
+			Dimension: by,
+			Top:       make([]groupTopRow, 0, len(top)),
+		}
+		for _, p := range points {
+			result.Pageviews = append(result.Pageviews, timeseriesPoint{Timestamp: p.Timestamp, Pageviews: p.Views})
+		}
+		for _, r := range top {
+			result.Top = append(result.Top, groupTopRow{Name: r.Name, Pageviews: r.Count})
+		}
+		for _, m := range g.Members {
+			result.Websites = append(result.Websites, m.Domain)
+		}
+		if format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(result)
+		}
+		return outputGroupStatsTable(result)
+	})
+}
+
+func outputGroupStatsTable(s groupStats) error {
+	fmt.Printf("%s: %d websites (%s)\n\n", s.Group, len(s.Websites), strings.Join(s.Websites, ", "))
+	fmt.Printf("Today: %d visitors, %d pageviews, %.1f%% bounce rate, %d online now\n\n",
+		s.Today.Visitors, s.Today.Pageviews, s.Today.BounceRate, s.Today.CurrentVisitors)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "DAY\tPAGEVIEWS")
+	_, _ = fmt.Fprintln(w, "---\t---------")
+	for _, p := range s.Pageviews {
+		_, _ = fmt.Fprintf(w, "%s\t%d\n", p.Timestamp, p.Pageviews)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "%s\tPAGEVIEWS\n", strings.ToUpper(s.Dimension))
+	_, _ = fmt.Fprintf(w, "%s\t---------\n", strings.Repeat("-", len(s.Dimension)))
+	for _, r := range s.Top {
+		_, _ = fmt.Fprintf(w, "%s\t%d\n", r.Name, r.Pageviews)
+	}
+	return w.Flush()
+}
+
+// groupError names the group of groups.ErrNotFound
+func groupError(name string, err error) error {
+	if errors.Is(err, groups.ErrNotFound) {
+		return fmt.Errorf("no website group %s (create it with: kaunta website group create %s)", name, name)
+	}
+	return err
+}
+
+func init() {
+	websiteCmd.AddCommand(websiteGroupCmd)
+	websiteGroupCmd.AddCommand(websiteGroupCreateCmd, websiteGroupAddCmd, websiteGroupRemoveCmd,
+		websiteGroupDeleteCmd, websiteGroupListCmd, websiteGroupStatsCmd)
+
+	websiteGroupListCmd.Flags().StringVar(&groupListFormat, "format", "table", "Output format: json, table")
+
+	websiteGroupStatsCmd.Flags().IntVarP(&groupStatsDays, "days", "d", 7, "Time period in days (1-366)")
+	websiteGroupStatsCmd.Flags().StringVar(&groupStatsBy, "by", "page", "Breakdown: "+strings.Join(groupStatsDimensions, ", "))
+	websiteGroupStatsCmd.Flags().IntVar(&groupStatsLimit, "limit", 10, "Rows of the breakdown (1-100)")
+	websiteGroupStatsCmd.Flags().StringVarP(&groupStatsFormat, "format", "f", "table", "Output format (json, table)")
+}
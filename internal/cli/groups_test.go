@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var groupRowColumns = []string{"group_id", "name", "created_at", "website_id", "domain"}
+
+func TestRunWebsiteGroupCreate(t *testing.T) {
+	mock := mockJobsDB(t)
+	groupID, websiteID := uuid.New(), uuid.New()
+
+	mock.ExpectQuery(`INSERT INTO website_group`).WithArgs("acme").
+		WillReturnRows(sqlmock.NewRows([]string{"group_id", "created_at"}).AddRow(groupID, time.Now()))
+	expectWebsiteLookup(mock, websiteID, "shop.acme.com")
+	mock.ExpectExec(`INSERT INTO website_group_member`).WithArgs("acme", websiteID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	output, err := captureOutput(t, func() error {
+		return runWebsiteGroupCreate("acme", []string{"shop.acme.com"})
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "Website group acme created (ID "+groupID.String()+")")
+	assert.Contains(t, output, "Added shop.acme.com to acme")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunWebsiteGroupAddUnknownGroup(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "shop.acme.com")
+	mock.ExpectExec(`INSERT INTO website_group_member`).WithArgs("acme", websiteID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := runWebsiteGroupAdd("acme", []string{"shop.acme.com"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no website group acme")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunWebsiteGroupList(t *testing.T) {
+	mock := mockJobsDB(t)
+	groupID := uuid.New()
+
+	mock.ExpectQuery(`FROM website_group g`).
+		WillReturnRows(sqlmock.NewRows(groupRowColumns).
+			AddRow(groupID, "acme", time.Now(), uuid.New(), "acme.com").
+			AddRow(groupID, "acme", time.Now(), uuid.New(), "shop.acme.com"))
+
+	output, err := captureOutput(t, func() error {
+		return runWebsiteGroupList("table")
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "acme  "+groupID.String()+"  acme.com, shop.acme.com")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunWebsiteGroupStats(t *testing.T) {
+	mock := mockJobsDB(t)
+	groupID, a, b := uuid.New(), uuid.New(), uuid.New()
+
+	mock.ExpectQuery(`FROM website_group g`).WithArgs("acme").
+		WillReturnRows(sqlmock.NewRows(groupRowColumns).
+			AddRow(groupID, "acme", time.Now(), a, "acme.com").
+			AddRow(groupID, "acme", time.Now(), b, "shop.acme.com"))
+	statsColumns := []string{"current_visitors", "today_pageviews", "today_visitors", "bounce_rate"}
+	mock.ExpectQuery(`get_dashboard_stats`).WithArgs(a, nil, nil, nil, nil, nil, nil).
+		WillReturnRows(sqlmock.NewRows(statsColumns).AddRow(2, 90, 30, 40.0))
+	mock.ExpectQuery(`get_dashboard_stats`).WithArgs(b, nil, nil, nil, nil, nil, nil).
+		WillReturnRows(sqlmock.NewRows(statsColumns).AddRow(1, 10, 10, 80.0))
+	mock.ExpectQuery(`get_timeseries`).
+		WillReturnRows(sqlmock.NewRows([]string{"timestamp", "views"}).AddRow("2025-06-01", 90))
+	mock.ExpectQuery(`get_timeseries`).
+		WillReturnRows(sqlmock.NewRows([]string{"timestamp", "views"}).AddRow("2025-06-01", 10))
+	mock.ExpectQuery(`get_breakdown`).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "count", "total_count"}).AddRow("/", 60, 2).AddRow("/pricing", 30, 2))
+	mock.ExpectQuery(`get_breakdown`).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "count", "total_count"}).AddRow("/cart", 10, 1))
+
+	output, err := captureOutput(t, func() error {
+		return runWebsiteGroupStats("acme", 1, "page", 10, "table")
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "acme: 2 websites (acme.com, shop.acme.com)")
+	assert.Contains(t, output, "Today: 40 visitors, 100 pageviews, 50.0% bounce rate, 3 online now")
+	assert.Contains(t, output, "2025-06-01  100")
+	assert.Contains(t, output, "/pricing  30")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunWebsiteGroupStatsValidation(t *testing.T) {
+	assert.ErrorContains(t, runWebsiteGroupStats("acme", 0, "page", 10, "table"), "days must be between")
+	assert.ErrorContains(t, runWebsiteGroupStats("acme", 7, "password", 10, "table"), "invalid dimension")
+	assert.ErrorContains(t, runWebsiteGroupStats("acme", 7, "page", 0, "table"), "limit must be between")
+	assert.ErrorContains(t, runWebsiteGroupStats("acme", 7, "page", 10, "csv"), "invalid format")
+}
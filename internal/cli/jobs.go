@@ -0,0 +1,286 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/jobs"
+)
+
+// Jobs command flags
+var (
+	jobsStatus        string
+	jobsKind          string
+	jobsLimit         int
+	jobsFormat        string
+	jobsRetryAll      bool
+	jobsPurgeStatus   string
+	jobsPurgeOlderDay int
+)
+
+var jobsCmd = &cobra.Command{
+	Use:   "jobs",
+	Short: "Inspect and manage the background job queue",
+	Long: `Inspect and manage the queue behind webhooks, report emails, exports and imports.
+
+Failed jobs are retried with exponential backoff. After max_attempts they
+are moved to the 'dead' status, where they stay until retried or purged.`,
+}
+
+var jobsListCmd = &cobra.Command{
+	Use:   "list [--status <status>] [--kind <kind>] [--limit <N>] [--format json|table]",
+	Short: "List recent jobs",
+	Long: `List jobs, most recently updated first.
+
+Options:
+  --status      pending, running, succeeded or dead
+  --kind        Only jobs of this kind
+  --limit N     Number of jobs to show (1-1000, default 50)
+  --format      Output format: json, table (default table)`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runJobsList(jobsStatus, jobsKind, jobsLimit, jobsFormat)
+	},
+}
+
+var jobsStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show job counts by kind and status",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runJobsStats()
+	},
+}
+
+var jobsRetryCmd = &cobra.Command{
+	Use:   "retry [job-id...] [--kind <kind>] [--all]",
+	Short: "Requeue dead jobs",
+	Long: `Move dead jobs back to pending with a fresh attempt budget.
+
+Pass job IDs to retry specific jobs, --kind to retry every dead job of a
+kind, or --all to retry every dead job.
+
+Examples:
+  kaunta jobs retry 0b7c5a8e-2f0e-4a51-9d43-5f8f0e6f1a2b
+  kaunta jobs retry --kind webhook
+  kaunta jobs retry --all`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runJobsRetry(args, jobsKind, jobsRetryAll)
+	},
+}
+
+var jobsPurgeCmd = &cobra.Command{
+	Use:   "purge [--status succeeded|dead|all] [--older-than-days <N>]",
+	Short: "Delete finished jobs",
+	Long: `Delete succeeded and/or dead jobs that finished more than N days ago.
+
+Options:
+  --status             succeeded, dead or all (default succeeded)
+  --older-than-days N  Minimum age in days (default 7, 0 deletes all)`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runJobsPurge(jobsPurgeStatus, jobsPurgeOlderDay)
+	},
+}
+
+func ensureDatabase() (func(), error) {
+	if database.DB != nil {
+		return func() {}, nil
+	}
+	if err := connectDatabase(); err != nil {
+		return nil, fmt.Errorf("database connection failed: %w", err)
+	}
+	return func() { _ = closeDatabase() }, nil
+}
+
+func runJobsList(status, kind string, limit int, format string) error {
+	if status != "" && !validJobStatus(status) {
+		return fmt.Errorf("invalid status: %s (valid: pending, running, succeeded, dead)", status)
+	}
+	if limit < 1 || limit > 1000 {
+		return fmt.Errorf("limit must be between 1 and 1000")
+	}
+	if format == "" {
+		format = "table"
+	}
+	if format != "table" && format != "json" {
+		return fmt.Errorf("invalid format: %s (use json or table)", format)
+	}
+
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	list, err := jobs.List(ctx, database.DB, jobs.ListOptions{Status: status, Kind: kind, Limit: limit})
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		if list == nil {
+			list = []*jobs.Job{}
+		}
+		data, err := json.MarshalIndent(list, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(list) == 0 {
+		fmt.Println("No jobs found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer func() { _ = w.Flush() }()
+
+	_, _ = fmt.Fprintln(w, "ID\tKIND\tSTATUS\tATTEMPTS\tRUN AT\tLAST ERROR")
+	_, _ = fmt.Fprintln(w, "--\t----\t------\t--------\t------\t----------")
+	for _, job := range list {
+		lastError := ""
+		if job.LastError != nil {
+			lastError = strings.ReplaceAll(*job.LastError, "\n", " ")
+			if len(lastError) > 60 {
+				lastError = lastError[:57] + "..."
+			}
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d/%d\t%s\t%s\n",
+			job.ID, job.Kind, job.Status, job.Attempts, job.MaxAttempts,
+			job.RunAt.Format("2006-01-02 15:04:05"), lastError)
+	}
+	return nil
+}
+
+func runJobsStats() error {
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	counts, err := jobs.Counts(ctx, database.DB)
+	if err != nil {
+		return err
+	}
+	if len(counts) == 0 {
+		fmt.Println("Job queue is empty")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer func() { _ = w.Flush() }()
+
+	_, _ = fmt.Fprintln(w, "KIND\tSTATUS\tJOBS")
+	_, _ = fmt.Fprintln(w, "----\t------\t----")
+	for _, c := range counts {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%d\n", c.Kind, c.Status, c.Jobs)
+	}
+	return nil
+}
+
+func runJobsRetry(args []string, kind string, all bool) error {
+	if len(args) == 0 && kind == "" && !all {
+		return fmt.Errorf("specify job IDs, --kind or --all")
+	}
+
+	ids := make([]uuid.UUID, 0, len(args))
+	for _, arg := range args {
+		id, err := uuid.Parse(arg)
+		if err != nil {
+			return fmt.Errorf("invalid job ID: %s", arg)
+		}
+		ids = append(ids, id)
+	}
+
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	n, err := jobs.Retry(ctx, database.DB, ids, kind)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Requeued %d dead job(s)\n", n)
+	return nil
+}
+
+func runJobsPurge(status string, olderThanDays int) error {
+	var statuses []string
+	switch status {
+	case "", jobs.StatusSucceeded:
+		statuses = []string{jobs.StatusSucceeded}
+	case jobs.StatusDead:
+		statuses = []string{jobs.StatusDead}
+	case "all":
+		statuses = []string{jobs.StatusSucceeded, jobs.StatusDead}
+	default:
+		return fmt.Errorf("invalid status: %s (valid: succeeded, dead, all)", status)
+	}
+	if olderThanDays < 0 {
+		return fmt.Errorf("older-than-days must not be negative")
+	}
+
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	n, err := jobs.Purge(ctx, database.DB, statuses, time.Duration(olderThanDays)*24*time.Hour)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Deleted %d %s job(s)\n", n, strings.Join(statuses, "/"))
+	return nil
+}
+
+func validJobStatus(status string) bool {
+	switch status {
+	case jobs.StatusPending, jobs.StatusRunning, jobs.StatusSucceeded, jobs.StatusDead:
+		return true
+	}
+	return false
+}
+
+func init() {
+	RootCmd.AddCommand(jobsCmd)
+	jobsCmd.AddCommand(jobsListCmd, jobsStatsCmd, jobsRetryCmd, jobsPurgeCmd)
+
+	jobsListCmd.Flags().StringVar(&jobsStatus, "status", "", "Filter by status")
+	jobsListCmd.Flags().StringVar(&jobsKind, "kind", "", "Filter by kind")
+	jobsListCmd.Flags().IntVar(&jobsLimit, "limit", 50, "Number of jobs to show")
+	jobsListCmd.Flags().StringVar(&jobsFormat, "format", "table", "Output format: json, table")
+
+	jobsRetryCmd.Flags().StringVar(&jobsKind, "kind", "", "Retry every dead job of this kind")
+	jobsRetryCmd.Flags().BoolVar(&jobsRetryAll, "all", false, "Retry every dead job")
+
+	jobsPurgeCmd.Flags().StringVar(&jobsPurgeStatus, "status", "succeeded", "succeeded, dead or all")
+	jobsPurgeCmd.Flags().IntVar(&jobsPurgeOlderDay, "older-than-days", 7, "Minimum age in days")
+}
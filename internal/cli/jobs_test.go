@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/database"
+)
+
+func mockJobsDB(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	stubConnectClose(t)
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	originalDB := database.DB
+	database.DB = mockDB
+	t.Cleanup(func() {
+		database.DB = originalDB
+		_ = mockDB.Close()
+	})
+	return mock
+}
+
+func TestRunJobsStats(t *testing.T) {
+	mock := mockJobsDB(t)
+	mock.ExpectQuery(`SELECT kind, status, COUNT\(\*\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"kind", "status", "count"}).
+			AddRow("webhook", "dead", 2).
+			AddRow("webhook", "succeeded", 40))
+
+	output, err := captureOutput(t, runJobsStats)
+	require.NoError(t, err)
+	assert.Contains(t, output, "KIND")
+	assert.Contains(t, output, "webhook  dead       2")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunJobsRetry(t *testing.T) {
+	mock := mockJobsDB(t)
+
+	_, err := captureOutput(t, func() error { return runJobsRetry(nil, "", false) })
+	assert.EqualError(t, err, "specify job IDs, --kind or --all")
+
+	_, err = captureOutput(t, func() error { return runJobsRetry([]string{"nope"}, "", false) })
+	assert.EqualError(t, err, "invalid job ID: nope")
+
+	mock.ExpectExec(`WHERE status = 'dead' AND kind = \$1`).
+		WithArgs("webhook").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	output, err := captureOutput(t, func() error { return runJobsRetry(nil, "webhook", false) })
+	require.NoError(t, err)
+	assert.Contains(t, output, "Requeued 3 dead job(s)")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunJobsPurge(t *testing.T) {
+	mock := mockJobsDB(t)
+
+	_, err := captureOutput(t, func() error { return runJobsPurge("pending", 7) })
+	assert.EqualError(t, err, "invalid status: pending (valid: succeeded, dead, all)")
+
+	mock.ExpectExec(`DELETE FROM jobs`).WillReturnResult(sqlmock.NewResult(0, 12))
+	output, err := captureOutput(t, func() error { return runJobsPurge("all", 30) })
+	require.NoError(t, err)
+	assert.Contains(t, output, "Deleted 12 succeeded/dead job(s)")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunJobsListValidation(t *testing.T) {
+	_, err := captureOutput(t, func() error { return runJobsList("stuck", "", 50, "table") })
+	assert.EqualError(t, err, "invalid status: stuck (valid: pending, running, succeeded, dead)")
+
+	_, err = captureOutput(t, func() error { return runJobsList("", "", 0, "table") })
+	assert.EqualError(t, err, "limit must be between 1 and 1000")
+
+	_, err = captureOutput(t, func() error { return runJobsList("", "", 50, "csv") })
+	assert.EqualError(t, err, "invalid format: csv (use json or table)")
+}
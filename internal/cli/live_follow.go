@@ -0,0 +1,200 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Follow mode flags
+var (
+	liveFollow bool
+	liveServer string
+	liveToken  string
+)
+
+// followReconnectDelay is how long --follow waits before reconnecting a
+// dropped stream
+var followReconnectDelay = 5 * time.Second
+
+// liveServerURL is the server --follow connects to: --server, then
+// KAUNTA_SERVER, then the local server
+func liveServerURL(server string) string {
+	if server == "" {
+		server = os.Getenv("KAUNTA_SERVER")
+	}
+	if server == "" {
+		port := os.Getenv("PORT")
+		if port == "" {
+			port = "3000"
+		}
+		server = "http://localhost:" + port
+	}
+	return strings.TrimRight(server, "/")
+}
+
+// liveClient calls a Kaunta server's API with an API token
+type liveClient struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+func (c *liveClient) get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.server+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		if resp.StatusCode == http.StatusUnauthorized {
+			return nil, fmt.Errorf("%s: unauthorized (pass an API token with --token or KAUNTA_API_TOKEN)", c.server)
+		}
+		return nil, fmt.Errorf("%s%s: %s", c.server, path, resp.Status)
+	}
+	return resp, nil
+}
+
+// websiteID finds the website of domain through the websites API; website
+// IDs are taken as they are
+func (c *liveClient) websiteID(ctx context.Context, domain string) (string, error) {
+	if id, err := uuid.Parse(domain); err == nil {
+		return id.String(), nil
+	}
+
+	for page := 1; ; page++ {
+		resp, err := c.get(ctx, "/api/websites?per=100&page="+fmt.Sprint(page))
+		if err != nil {
+			return "", err
+		}
+		var body struct {
+			Data []struct {
+				ID     string `json:"id"`
+				Domain string `json:"domain"`
+			} `json:"data"`
+			Pagination struct {
+				TotalPages int `json:"total_pages"`
+			} `json:"pagination"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return "", fmt.Errorf("failed to read websites: %w", err)
+		}
+		for _, site := range body.Data {
+			if strings.EqualFold(site.Domain, domain) {
+				return site.ID, nil
+			}
+		}
+		if page >= body.Pagination.TotalPages {
+			return "", fmt.Errorf("website not found: %s", domain)
+		}
+	}
+}
+
+// stream reads the website's live stream, passing each snapshot to show,
+// until the stream ends
+func (c *liveClient) stream(ctx context.Context, websiteID string, show func(*LiveStatsData)) error {
+	resp, err := c.get(ctx, "/api/sse/live/"+url.PathEscape(websiteID))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var event, data string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if event == "live" {
+				var live LiveStatsData
+				if err := json.Unmarshal([]byte(data), &live); err == nil {
+					show(&live)
+				}
+			}
+			event, data = "", ""
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data += strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errors.New("stream closed by the server")
+}
+
+// runStatsLiveFollow shows live stats streamed by a running server, for
+// machines without database access
+func runStatsLiveFollow(domain, server, token, format string) error {
+	if format == "" {
+		format = "text"
+	}
+	if token == "" {
+		token = os.Getenv("KAUNTA_API_TOKEN")
+	}
+	client := &liveClient{server: liveServerURL(server), token: token, http: &http.Client{}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signalNotifyFunc(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sigChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	lookupCtx, lookupCancel := context.WithTimeout(ctx, 30*time.Second)
+	websiteID, err := client.websiteID(lookupCtx, domain)
+	lookupCancel()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Live stats for %s from %s (press Ctrl+C to exit)\n\n", domain, client.server)
+
+	show := func(live *LiveStatsData) {
+		if format == "json" {
+			_ = outputLiveJSON(live)
+		} else {
+			_ = outputLiveTerm(live)
+		}
+	}
+	for {
+		err := client.stream(ctx, websiteID, show)
+		if ctx.Err() != nil {
+			fmt.Println("\n\nExiting live stats...")
+			return nil
+		}
+		fmt.Printf("Live stream interrupted (%v), reconnecting in %s...\n", err, followReconnectDelay)
+		select {
+		case <-ctx.Done():
+			fmt.Println("\n\nExiting live stats...")
+			return nil
+		case <-time.After(followReconnectDelay):
+		}
+	}
+}
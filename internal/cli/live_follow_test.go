@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunStatsLiveFollowStreamsFromServer(t *testing.T) {
+	const websiteID = "0b7c5a8e-2f0e-4a51-9d43-5f8f0e6f1a2b"
+
+	signals := make(chan chan<- os.Signal, 1)
+	stubSignalNotify(t, func(c chan<- os.Signal, sig ...os.Signal) {
+		signals <- c
+	})
+
+	originalDelay := followReconnectDelay
+	followReconnectDelay = 0
+	t.Cleanup(func() { followReconnectDelay = originalDelay })
+
+	streams := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/api/websites":
+			_, _ = fmt.Fprintf(w, `{"data":[{"id":%q,"domain":"example.com"}],"pagination":{"total_pages":1}}`, websiteID)
+		case "/api/sse/live/" + websiteID:
+			w.Header().Set("Content-Type", "text/event-stream")
+			if streams++; streams == 1 {
+				_, _ = fmt.Fprint(w, "event: event\ndata: {\"type\":\"event\"}\n\n")
+				_, _ = fmt.Fprint(w, "event: live\ndata: {\"active_visitors_now\":7,\"top_page_now\":{\"path\":\"/live\",\"pageviews\":3}}\n\n")
+				return
+			}
+			// The client read the first stream and reconnected: stop it like
+			// Ctrl+C would
+			w.(http.Flusher).Flush()
+			(<-signals) <- os.Interrupt
+			<-r.Context().Done()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	output, err := captureOutput(t, func() error {
+		return runStatsLiveFollow("example.com", server.URL, "secret", "text")
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "Live stats for example.com from "+server.URL)
+	assert.Contains(t, output, "Active Visitors (last 5 min)")
+	assert.Contains(t, output, "/live")
+	assert.Contains(t, output, "Live stream interrupted (stream closed by the server)")
+	assert.Contains(t, output, "Exiting live stats")
+}
+
+func TestLiveServerURL(t *testing.T) {
+	t.Setenv("KAUNTA_SERVER", "")
+	t.Setenv("PORT", "4000")
+	assert.Equal(t, "http://localhost:4000", liveServerURL(""))
+	assert.Equal(t, "https://stats.example.com", liveServerURL("https://stats.example.com/"))
+
+	t.Setenv("KAUNTA_SERVER", "https://kaunta.internal")
+	assert.Equal(t, "https://kaunta.internal", liveServerURL(""))
+}
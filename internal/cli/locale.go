@@ -0,0 +1,230 @@
+package cli
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// numberLocale is how the text and table outputs of the stats commands write
+// numbers, percentages and dates. JSON and CSV outputs always use the plain
+// locale, so scripts don't have to parse them.
+type numberLocale struct {
+	Name      string
+	Thousands string
+	Decimal   string
+	// PercentSpace puts a (non-breaking) space before the percent sign
+	PercentSpace bool
+	// DateLayout and TimeLayout are time.Format layouts
+	DateLayout string
+	TimeLayout string
+}
+
+// plainLocale is the default: digits without grouping, ISO dates
+var plainLocale = numberLocale{Name: "C", Decimal: ".", DateLayout: time.DateOnly, TimeLayout: "15:04"}
+
+// locales are the known locales by lowercase tag; a tag with a region falls
+// back to its language (de-AT uses de)
+var locales = map[string]numberLocale{
+	"c":     plainLocale,
+	"en":    {Name: "en-US", Thousands: ",", Decimal: ".", DateLayout: "01/02/2006", TimeLayout: "3:04 PM"},
+	"en-us": {Name: "en-US", Thousands: ",", Decimal: ".", DateLayout: "01/02/2006", TimeLayout: "3:04 PM"},
+	"en-gb": {Name: "en-GB", Thousands: ",", Decimal: ".", DateLayout: "02/01/2006", TimeLayout: "15:04"},
+	"en-ie": {Name: "en-IE", Thousands: ",", Decimal: ".", DateLayout: "02/01/2006", TimeLayout: "15:04"},
+	"en-au": {Name: "en-AU", Thousands: ",", Decimal: ".", DateLayout: "02/01/2006", TimeLayout: "15:04"},
+	"en-ca": {Name: "en-CA", Thousands: ",", Decimal: ".", DateLayout: time.DateOnly, TimeLayout: "15:04"},
+	"de":    {Name: "de", Thousands: ".", Decimal: ",", PercentSpace: true, DateLayout: "02.01.2006", TimeLayout: "15:04"},
+	"de-ch": {Name: "de-CH", Thousands: "\u2019", Decimal: ".", DateLayout: "02.01.2006", TimeLayout: "15:04"},
+	"fr":    {Name: "fr", Thousands: "\u202f", Decimal: ",", PercentSpace: true, DateLayout: "02/01/2006", TimeLayout: "15:04"},
+	"es":    {Name: "es", Thousands: ".", Decimal: ",", PercentSpace: true, DateLayout: "02/01/2006", TimeLayout: "15:04"},
+	"it":    {Name: "it", Thousands: ".", Decimal: ",", DateLayout: "02/01/2006", TimeLayout: "15:04"},
+	"nl":    {Name: "nl", Thousands: ".", Decimal: ",", DateLayout: "02-01-2006", TimeLayout: "15:04"},
+	"pt":    {Name: "pt", Thousands: "\u00a0", Decimal: ",", DateLayout: "02/01/2006", TimeLayout: "15:04"},
+	"pt-br": {Name: "pt-BR", Thousands: ".", Decimal: ",", DateLayout: "02/01/2006", TimeLayout: "15:04"},
+	"sv":    {Name: "sv", Thousands: "\u00a0", Decimal: ",", PercentSpace: true, DateLayout: time.DateOnly, TimeLayout: "15:04"},
+	"da":    {Name: "da", Thousands: ".", Decimal: ",", PercentSpace: true, DateLayout: "02.01.2006", TimeLayout: "15.04"},
+	"nb":    {Name: "nb", Thousands: "\u00a0", Decimal: ",", PercentSpace: true, DateLayout: "02.01.2006", TimeLayout: "15:04"},
+	"fi":    {Name: "fi", Thousands: "\u00a0", Decimal: ",", PercentSpace: true, DateLayout: "2.1.2006", TimeLayout: "15.04"},
+	"pl":    {Name: "pl", Thousands: "\u00a0", Decimal: ",", DateLayout: "02.01.2006", TimeLayout: "15:04"},
+	"cs":    {Name: "cs", Thousands: "\u00a0", Decimal: ",", PercentSpace: true, DateLayout: "2. 1. 2006", TimeLayout: "15:04"},
+	"ru":    {Name: "ru", Thousands: "\u00a0", Decimal: ",", PercentSpace: true, DateLayout: "02.01.2006", TimeLayout: "15:04"},
+	"uk":    {Name: "uk", Thousands: "\u00a0", Decimal: ",", DateLayout: "02.01.2006", TimeLayout: "15:04"},
+	"tr":    {Name: "tr", Thousands: ".", Decimal: ",", DateLayout: "02.01.2006", TimeLayout: "15:04"},
+	"ja":    {Name: "ja", Thousands: ",", Decimal: ".", DateLayout: "2006/01/02", TimeLayout: "15:04"},
+	"zh":    {Name: "zh", Thousands: ",", Decimal: ".", DateLayout: "2006/01/02", TimeLayout: "15:04"},
+	"ko":    {Name: "ko", Thousands: ",", Decimal: ".", DateLayout: "2006. 1. 2.", TimeLayout: "15:04"},
+}
+
+// outputLocale is the locale of the current stats command, set from --locale
+// or the environment before it runs
+var outputLocale = plainLocale
+
+// statsLocale is the --locale flag of the stats commands
+var statsLocale string
+
+// lookupLocale finds the locale of a tag such as de, de-DE or de_DE.UTF-8
+func lookupLocale(tag string) (numberLocale, bool) {
+	tag, _, _ = strings.Cut(tag, ".")
+	tag, _, _ = strings.Cut(tag, "@")
+	tag = strings.ToLower(strings.ReplaceAll(tag, "_", "-"))
+	if tag == "posix" {
+		tag = "c"
+	}
+	if l, ok := locales[tag]; ok {
+		return l, true
+	}
+	language, _, _ := strings.Cut(tag, "-")
+	l, ok := locales[language]
+	return l, ok
+}
+
+// resolveLocale picks the locale of the flag or, without it, of LC_ALL,
+// LC_NUMERIC or LANG. An unknown flag is an error; an unknown environment
+// locale falls back to the plain one.
+func resolveLocale(flag string) (numberLocale, error) {
+	if flag != "" {
+		l, ok := lookupLocale(flag)
+		if !ok {
+			return plainLocale, fmt.Errorf("unknown locale: %s (known: %s)", flag, strings.Join(localeNames(), ", "))
+		}
+		return l, nil
+	}
+	for _, name := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			l, _ := lookupLocale(value)
+			if l.Name == "" {
+				l = plainLocale
+			}
+			return l, nil
+		}
+	}
+	return plainLocale, nil
+}
+
+// localeNames are the known locale tags
+func localeNames() []string {
+	names := make([]string, 0, len(locales))
+	for _, l := range locales {
+		names = append(names, l.Name)
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// localeFor is the locale of an output format: plain for json and csv
+func localeFor(format string) numberLocale {
+	if format == "json" || format == "csv" {
+		return plainLocale
+	}
+	return outputLocale
+}
+
+// Int writes n with thousands separators
+func (l numberLocale) Int(n int64) string {
+	digits := strconv.FormatInt(n, 10)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	return sign + l.group(digits)
+}
+
+// Signed writes n with its sign, +1,234 or -5
+func (l numberLocale) Signed(n int64) string {
+	if n >= 0 {
+		return "+" + l.Int(n)
+	}
+	return l.Int(n)
+}
+
+// Float writes f with decimals digits after the decimal separator
+func (l numberLocale) Float(f float64, decimals int) string {
+	s := strconv.FormatFloat(math.Abs(f), 'f', decimals, 64)
+	whole, fraction, _ := strings.Cut(s, ".")
+	out := l.group(whole)
+	if fraction != "" {
+		out += l.Decimal + fraction
+	}
+	if f < 0 && strings.Trim(s, "0.") != "" {
+		out = "-" + out
+	}
+	return out
+}
+
+// SignedFloat writes f with its sign
+func (l numberLocale) SignedFloat(f float64, decimals int) string {
+	s := l.Float(f, decimals)
+	if !strings.HasPrefix(s, "-") {
+		s = "+" + s
+	}
+	return s
+}
+
+// Percent writes a percentage with one decimal, 12.5% or 12,5 %
+func (l numberLocale) Percent(f float64) string {
+	return l.percentSign(l.Float(f, 1))
+}
+
+// SignedPercent writes a percentage change with its sign
+func (l numberLocale) SignedPercent(f float64) string {
+	return l.percentSign(l.SignedFloat(f, 1))
+}
+
+func (l numberLocale) percentSign(s string) string {
+	if l.PercentSpace {
+		return s + "\u00a0%"
+	}
+	return s + "%"
+}
+
+// Date writes the day of t
+func (l numberLocale) Date(t time.Time) string {
+	return t.Format(l.DateLayout)
+}
+
+// DateTime writes the day and time of t
+func (l numberLocale) DateTime(t time.Time) string {
+	return t.Format(l.DateLayout + " " + l.TimeLayout)
+}
+
+// group inserts thousands separators into a run of digits
+func (l numberLocale) group(digits string) string {
+	if l.Thousands == "" || len(digits) <= 3 {
+		return digits
+	}
+	var b strings.Builder
+	head := len(digits) % 3
+	if head > 0 {
+		b.WriteString(digits[:head])
+	}
+	for i := head; i < len(digits); i += 3 {
+		if b.Len() > 0 {
+			b.WriteString(l.Thousands)
+		}
+		b.WriteString(digits[i : i+3])
+	}
+	return b.String()
+}
+
+func init() {
+	statsCmd.PersistentFlags().StringVar(&statsLocale, "locale", "",
+		"Number and date format of text and table output, e.g. de-DE (default: LC_ALL, LC_NUMERIC or LANG)")
+
+	statsCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if err := RootCmd.PersistentPreRunE(cmd, args); err != nil {
+			return err
+		}
+		l, err := resolveLocale(statsLocale)
+		if err != nil {
+			return err
+		}
+		outputLocale = l
+		return nil
+	}
+}
@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useLocale(t *testing.T, tag string) {
+	t.Helper()
+	l, ok := lookupLocale(tag)
+	require.True(t, ok, tag)
+	original := outputLocale
+	outputLocale = l
+	t.Cleanup(func() { outputLocale = original })
+}
+
+func TestNumberLocaleFormats(t *testing.T) {
+	day := time.Date(2025, 6, 30, 14, 5, 0, 0, time.UTC)
+
+	plain := plainLocale
+	assert.Equal(t, "1234567", plain.Int(1234567))
+	assert.Equal(t, "12.5%", plain.Percent(12.5))
+	assert.Equal(t, "2025-06-30 14:05", plain.DateTime(day))
+
+	en, _ := lookupLocale("en_US.UTF-8")
+	assert.Equal(t, "1,234,567", en.Int(1234567))
+	assert.Equal(t, "-1,234", en.Int(-1234))
+	assert.Equal(t, "+1,000", en.Signed(1000))
+	assert.Equal(t, "1,234.57", en.Float(1234.567, 2))
+	assert.Equal(t, "06/30/2025 2:05 PM", en.DateTime(day))
+
+	de, _ := lookupLocale("de-AT")
+	assert.Equal(t, "1.234.567", de.Int(1234567))
+	assert.Equal(t, "12,5\u00a0%", de.Percent(12.5))
+	assert.Equal(t, "-3,2\u00a0%", de.SignedPercent(-3.2))
+	assert.Equal(t, "+0,0", de.SignedFloat(-0.01, 1))
+	assert.Equal(t, "30.06.2025", de.Date(day))
+
+	fr, _ := lookupLocale("fr_FR")
+	assert.Equal(t, "12\u202f345,0", fr.Float(12345, 1))
+}
+
+func TestResolveLocale(t *testing.T) {
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_NUMERIC", "")
+	t.Setenv("LANG", "")
+
+	l, err := resolveLocale("")
+	require.NoError(t, err)
+	assert.Equal(t, plainLocale, l)
+
+	t.Setenv("LANG", "de_DE.UTF-8")
+	l, err = resolveLocale("")
+	require.NoError(t, err)
+	assert.Equal(t, "de", l.Name)
+
+	// LC_ALL overrides LANG, the flag overrides both
+	t.Setenv("LC_ALL", "C.UTF-8")
+	l, err = resolveLocale("")
+	require.NoError(t, err)
+	assert.Equal(t, plainLocale, l)
+
+	l, err = resolveLocale("en-GB")
+	require.NoError(t, err)
+	assert.Equal(t, "en-GB", l.Name)
+
+	// Unknown environment locales fall back, unknown flags are errors
+	t.Setenv("LC_ALL", "xx_YY")
+	l, err = resolveLocale("")
+	require.NoError(t, err)
+	assert.Equal(t, plainLocale, l)
+
+	_, err = resolveLocale("klingon")
+	assert.ErrorContains(t, err, "unknown locale: klingon")
+}
+
+func TestOutputPagesTableLocale(t *testing.T) {
+	useLocale(t, "de")
+
+	output, err := captureOutput(t, func() error {
+		return outputPagesTable([]*PageStat{{Path: "/", Pageviews: 12345, UniqueVisitors: 2345, BounceRate: 41.25, AvgTime: 3.5}})
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "12.345")
+	assert.Contains(t, output, "2.345")
+	assert.Contains(t, output, "41,2\u00a0%")
+	assert.Contains(t, output, "3,5s")
+}
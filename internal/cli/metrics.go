@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/seuros/kaunta/internal/dedup"
+	"github.com/seuros/kaunta/internal/handlers"
+	"github.com/seuros/kaunta/internal/ingest"
+	"github.com/seuros/kaunta/internal/jobs"
+)
+
+// metricsHandler serves Prometheus metrics for this process. Queue backlog
+// gauges read db on every scrape; pass nil to skip them.
+func metricsHandler(db *sql.DB) http.Handler {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	reg.MustRegister(jobs.Collectors(db)...)
+	reg.MustRegister(handlers.Collectors()...)
+	reg.MustRegister(ingest.Collectors()...)
+	reg.MustRegister(dedup.Collectors()...)
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+}
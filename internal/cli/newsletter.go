@@ -0,0 +1,177 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/newsletter"
+)
+
+var (
+	emailCampaign string
+	emailURLs     []string
+	emailBase     string
+	emailDays     int
+	emailTop      int
+	emailGoal     string
+	emailFormat   string
+)
+
+var emailCmd = &cobra.Command{
+	Use:   "email",
+	Short: "Track email campaigns",
+	Long: `Track the opens and clicks of newsletters and other emails. An image in the
+email records an open (email_open), and links sent through the server record
+a click (email_click) before redirecting to the website. Both are tagged
+utm_medium=email with the campaign as utm_campaign, so the visits clicks
+bring count in the campaign reports.`,
+}
+
+var emailLinksCmd = &cobra.Command{
+	Use:   "links <domain> --campaign name [--url https://example.com/page]... [--base https://stats.example.com]",
+	Short: "Print the open pixel and tracked links of a campaign",
+	Long: `Print the open pixel and tracked links of an email campaign, to paste into
+the email's template. Links must point to the website's domains. Add
+&r=<token> per recipient (a mailing tool's subscriber ID, never an address)
+to count opens per reader when email_recipients is enabled.
+
+The addresses are on the Kaunta server, --base, which defaults to https://
+and the first of trusted_origins.
+
+Examples:
+  kaunta email links example.com --campaign spring
+  kaunta email links example.com --campaign spring --url https://example.com/sale --url https://example.com/blog/launch`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runEmailLinks(args[0], emailCampaign, emailURLs, emailBase)
+	},
+}
+
+var statsEmailCmd = &cobra.Command{
+	Use:   "email <website-domain> [--days <N>] [--top <N>] [--goal <event>] [--format json|table]",
+	Short: "Show email campaign opens, clicks and conversions",
+	Long: `Display the opens and clicks of email campaigns (see kaunta email), the
+sessions their emails brought and how many converted.
+
+Unique opens and clicks count recipients when their tokens are recorded
+(email_recipients), sessions otherwise: opens loaded through a mail proxy
+share its session. Sessions count every visit tagged utm_medium=email with
+the campaign, tracked links or not. A conversion is such a session that
+fired a custom event (or the --goal event). Email campaigns require
+PostgreSQL.
+
+Options:
+  --days N      Time period in days (1-365, default 30)
+  --top N       Number of campaigns to show (1-100, default 20)
+  --goal        Custom event name counted as a conversion (default: any custom event)
+  --format      Output format: json, table (default table)
+
+Examples:
+  kaunta stats email example.com
+  kaunta stats email example.com --goal signup --days 90`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runStatsEmail(args[0], emailDays, emailTop, emailGoal, emailFormat)
+	},
+}
+
+func runEmailLinks(domain, campaign string, urls []string, base string) error {
+	if strings.TrimSpace(campaign) == "" {
+		return fmt.Errorf("--campaign is required")
+	}
+	for _, target := range urls {
+		if _, err := newsletter.Tag(target, campaign, ""); err != nil {
+			return err
+		}
+	}
+	if base == "" {
+		base = defaultLinkBase()
+	}
+	if base == "" {
+		return fmt.Errorf("no server address for the links: pass --base https://stats.example.com")
+	}
+	if !strings.Contains(base, "://") {
+		base = "https://" + base
+	}
+	if u, err := url.Parse(base); err != nil || u.Host == "" {
+		return fmt.Errorf("invalid base URL: %q", base)
+	}
+
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		fmt.Printf("Campaign %q of %s\n\n", campaign, domain)
+		fmt.Println("Open pixel:")
+		fmt.Printf("  <img src=\"%s\" width=\"1\" height=\"1\" alt=\"\">\n", newsletter.OpenURL(base, websiteID, campaign))
+		if len(urls) == 0 {
+			fmt.Println("\nTracked link:")
+			fmt.Printf("  %s\n", newsletter.ClickURL(base, websiteID, campaign, "https://"+domain+"/"))
+			return nil
+		}
+		fmt.Println("\nTracked links:")
+		for _, target := range urls {
+			fmt.Printf("  %s\n", newsletter.ClickURL(base, websiteID, campaign, target))
+		}
+		return nil
+	})
+}
+
+func runStatsEmail(domain string, days, top int, goal, format string) error {
+	if days < 1 || days > 365 {
+		return fmt.Errorf("days must be between 1 and 365")
+	}
+	if top < 1 || top > 100 {
+		return fmt.Errorf("top must be between 1 and 100")
+	}
+	if format != "table" && format != "json" {
+		return fmt.Errorf("invalid format: %s (use json or table)", format)
+	}
+
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		campaigns, err := newsletter.Report(ctx, database.DB, websiteID, days, top, goal)
+		if err != nil {
+			return err
+		}
+		if format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(campaigns)
+		}
+
+		fmt.Printf("Email campaigns of %s (last %d days)\n\n", domain, days)
+		if len(campaigns) == 0 {
+			fmt.Println("No email campaigns")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "CAMPAIGN\tOPENS\tUNIQUE\tCLICKS\tUNIQUE\tSESSIONS\tCONVERSIONS")
+		_, _ = fmt.Fprintln(w, "--------\t-----\t------\t------\t------\t--------\t-----------")
+		l := outputLocale
+		for _, c := range campaigns {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", c.Name, l.Int(c.Opens), l.Int(c.UniqueOpens),
+				l.Int(c.Clicks), l.Int(c.UniqueClicks), l.Int(c.Sessions), l.Int(c.Conversions))
+		}
+		return w.Flush()
+	})
+}
+
+func init() {
+	RootCmd.AddCommand(emailCmd)
+	emailCmd.AddCommand(emailLinksCmd)
+	statsCmd.AddCommand(statsEmailCmd)
+
+	emailLinksCmd.Flags().StringVar(&emailCampaign, "campaign", "", "Name of the campaign (utm_campaign)")
+	emailLinksCmd.Flags().StringArrayVar(&emailURLs, "url", nil, "Page to link to (repeatable; default: the website's home page)")
+	emailLinksCmd.Flags().StringVar(&emailBase, "base", "", "Address of the Kaunta server (default: first trusted origin)")
+	statsEmailCmd.Flags().IntVarP(&emailDays, "days", "d", 30, "Period in days (1-365)")
+	statsEmailCmd.Flags().IntVarP(&emailTop, "top", "t", 20, "Campaigns to show (1-100)")
+	statsEmailCmd.Flags().StringVar(&emailGoal, "goal", "", "Custom event counted as a conversion")
+	statsEmailCmd.Flags().StringVarP(&emailFormat, "format", "f", "table", "Output format (json, table)")
+}
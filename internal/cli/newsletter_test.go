@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunEmailLinks(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	output, err := captureOutput(t, func() error {
+		return runEmailLinks("example.com", "spring", []string{"https://example.com/sale"}, "stats.example.com")
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, `<img src="https://stats.example.com/e/open.gif?campaign=spring&website=`+websiteID.String()+`"`)
+	assert.Contains(t, output, "https://stats.example.com/e/click?campaign=spring&url=https%3A%2F%2Fexample.com%2Fsale&website="+websiteID.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunEmailLinksRejectsBadValues(t *testing.T) {
+	assert.ErrorContains(t, runEmailLinks("example.com", "", nil, "https://stats.example.com"), "--campaign")
+	assert.ErrorContains(t, runEmailLinks("example.com", "spring", []string{"/sale"}, "https://stats.example.com"), "invalid link target")
+	assert.ErrorContains(t, runStatsEmail("example.com", 0, 20, "", "table"), "days")
+	assert.ErrorContains(t, runStatsEmail("example.com", 30, 20, "", "csv"), "invalid format")
+}
+
+func TestRunStatsEmail(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectQuery(`utm_medium = 'email'`).WithArgs(websiteID, 30, "email_open", "email_click", nil, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"campaign", "opens", "unique_opens", "clicks", "unique_clicks", "sessions", "conversions"}).
+			AddRow("spring", 420, 310, 95, 80, 88, 12))
+
+	output, err := captureOutput(t, func() error { return runStatsEmail("example.com", 30, 20, "", "table") })
+	require.NoError(t, err)
+	assert.Contains(t, output, "Email campaigns of example.com (last 30 days)")
+	assert.Regexp(t, `spring\s+420\s+310\s+95\s+80\s+88\s+12`, output)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/notes"
+)
+
+// Note command flags
+var (
+	noteAuthor     string
+	noteListDays   int
+	noteListFormat string
+)
+
+var noteCmd = &cobra.Command{
+	Use:   "note",
+	Short: "Manage notes on days of a website",
+	Long: `Notes explain a day of a website's traffic ("outage 14:00-15:30",
+"newsletter sent"). The dashboard shows them under the pageviews chart, and
+the time series API returns them with ?notes=true.`,
+}
+
+var noteAddCmd = &cobra.Command{
+	Use:   "add <domain> <YYYY-MM-DD> <text> [--author <name>]",
+	Short: "Attach a note to a day",
+	Long: fmt.Sprintf(`Attach a note of at most %d characters to a day of a website.
+
+Examples:
+  kaunta note add example.com 2025-06-15 "Newsletter sent"
+  kaunta note add example.com 2025-06-16 "CDN outage 14:00-15:30" --author ops`, notes.MaxLength),
+	Args: cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runNoteAdd(args[0], args[1], args[2], noteAuthor)
+	},
+}
+
+var noteListCmd = &cobra.Command{
+	Use:   "list <domain> [--days <N>] [--format json|table]",
+	Short: "List the notes of a website's last days",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runNoteList(args[0], noteListDays, noteListFormat)
+	},
+}
+
+var noteRemoveCmd = &cobra.Command{
+	Use:   "remove <domain> <note-id>",
+	Short: "Delete a note",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runNoteRemove(args[0], args[1])
+	},
+}
+
+func runNoteAdd(domain, day, text, author string) error {
+	date, err := notes.ParseDate(day)
+	if err != nil {
+		return err
+	}
+	if _, err := notes.Validate(text); err != nil {
+		return err
+	}
+
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		note, err := notes.Add(ctx, database.DB, websiteID, date, text, author)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Note %s added to %s on %s\n", note.ID, domain, note.Date)
+		return nil
+	})
+}
+
+func runNoteList(domain string, days int, format string) error {
+	if format != "table" && format != "json" {
+		return fmt.Errorf("invalid format: %s (use json or table)", format)
+	}
+	if days < 1 || days > 365 {
+		return fmt.Errorf("days must be between 1 and 365")
+	}
+
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		list, err := notes.LastDays(ctx, database.DB, websiteID, days, time.Now())
+		if err != nil {
+			return err
+		}
+		if format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(list)
+		}
+
+		if len(list) == 0 {
+			fmt.Printf("No notes for %s in the last %d days\n", domain, days)
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "DATE\tNOTE\tAUTHOR\tID")
+		_, _ = fmt.Fprintln(w, "----\t----\t------\t--")
+		for _, n := range list {
+			author := n.Author
+			if author == "" {
+				author = "-"
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", n.Date, n.Text, author, n.ID)
+		}
+		return w.Flush()
+	})
+}
+
+func runNoteRemove(domain, id string) error {
+	noteID, err := uuid.Parse(id)
+	if err != nil {
+		return fmt.Errorf("invalid note ID: %s", id)
+	}
+
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		err := notes.Remove(ctx, database.DB, websiteID, noteID)
+		if errors.Is(err, notes.ErrNotFound) {
+			return fmt.Errorf("no note %s for %s", noteID, domain)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Note %s removed from %s\n", noteID, domain)
+		return nil
+	})
+}
+
+func init() {
+	RootCmd.AddCommand(noteCmd)
+	noteCmd.AddCommand(noteAddCmd, noteListCmd, noteRemoveCmd)
+
+	noteAddCmd.Flags().StringVar(&noteAuthor, "author", "", "Who wrote the note")
+
+	noteListCmd.Flags().IntVarP(&noteListDays, "days", "d", 30, "Number of days to list, today included")
+	noteListCmd.Flags().StringVar(&noteListFormat, "format", "table", "Output format: json, table")
+}
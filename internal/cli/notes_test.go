@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunNoteAdd(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID, noteID := uuid.New(), uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectQuery(`INSERT INTO note`).WithArgs(websiteID, "2025-06-15", "Newsletter sent", "ops").
+		WillReturnRows(sqlmock.NewRows([]string{"note_id", "created_at"}).AddRow(noteID, time.Now()))
+
+	output, err := captureOutput(t, func() error {
+		return runNoteAdd("example.com", "2025-06-15", "Newsletter sent", "ops")
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "Note "+noteID.String()+" added to example.com on 2025-06-15")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunNoteAddInvalid(t *testing.T) {
+	assert.EqualError(t, runNoteAdd("example.com", "June 15", "Newsletter sent", ""),
+		`invalid date: "June 15" (use YYYY-MM-DD)`)
+	assert.EqualError(t, runNoteAdd("example.com", "2025-06-15", " ", ""), "a note needs text")
+	assert.EqualError(t, runNoteRemove("example.com", "42"), "invalid note ID: 42")
+}
+
+func TestRunNoteList(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectQuery(`FROM note`).WithArgs(websiteID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"note_id", "website_id", "note_date", "body", "author", "created_at"}).
+			AddRow(uuid.New(), websiteID, time.Date(2025, 6, 16, 0, 0, 0, 0, time.UTC), "CDN outage 14:00-15:30", "", time.Now()))
+
+	output, err := captureOutput(t, func() error {
+		return runNoteList("example.com", 30, "table")
+	})
+	require.NoError(t, err)
+	assert.Regexp(t, `2025-06-16\s+CDN outage 14:00-15:30\s+-\s+`, output)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,186 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/notify"
+)
+
+// Website notify command flags
+var (
+	notifySlack        string
+	notifyDiscord      string
+	notifyDailySummary string
+	notifyTest         bool
+)
+
+// loadNotifyConfig reads the webhooks of kaunta.toml
+var loadNotifyConfig = func() (config.NotifyConfig, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return config.NotifyConfig{}, err
+	}
+	return cfg.Notify, nil
+}
+
+var websiteNotifyCmd = &cobra.Command{
+	Use:   "notify <domain> [--slack <url>|none] [--discord <url>|none] [--daily-summary on|off|default] [--test]",
+	Short: "Configure a website's Slack and Discord notifications",
+	Long: `Show or change where a website's daily summary and chat alerts are posted.
+
+Websites post to the webhooks of kaunta.toml (slack_webhook_url,
+discord_webhook_url) unless they set their own. The daily summary (visitors,
+pageviews, bounce rate, engagement, top pages and referrers of the day before)
+is posted shortly after midnight UTC when daily_summary = true, unless the
+website turns it off, or on for itself. Alert rules created with
+--notify slack or --notify discord post to the same webhooks.
+
+Options:
+  --slack URL          Slack incoming webhook; none goes back to kaunta.toml's
+  --discord URL        Discord webhook; none goes back to kaunta.toml's
+  --daily-summary      on, off or default (follow kaunta.toml)
+  --test               Post yesterday's summary now
+
+Examples:
+  kaunta website notify example.com --slack https://hooks.slack.com/services/T000/B000/XXXX
+  kaunta website notify example.com --daily-summary on --test
+  kaunta website notify example.com --discord none`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var slack, discord, daily *string
+		if cmd.Flags().Changed("slack") {
+			slack = &notifySlack
+		}
+		if cmd.Flags().Changed("discord") {
+			discord = &notifyDiscord
+		}
+		if cmd.Flags().Changed("daily-summary") {
+			daily = &notifyDailySummary
+		}
+		return runWebsiteNotify(args[0], slack, discord, daily, notifyTest)
+	},
+}
+
+// parseDailySummary reads on, off or default (nil)
+func parseDailySummary(value string) (*bool, error) {
+	switch value {
+	case "on":
+		on := true
+		return &on, nil
+	case "off":
+		off := false
+		return &off, nil
+	case "default":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("invalid --daily-summary: %s (use on, off or default)", value)
+}
+
+// webhookFlag reads a --slack or --discord value; none clears it
+func webhookFlag(service, value string) (string, error) {
+	if value == "none" {
+		return "", nil
+	}
+	return value, notify.ValidURL(service, value)
+}
+
+func runWebsiteNotify(domain string, slack, discord, daily *string, test bool) error {
+	webhooks := map[string]string{}
+	for service, value := range map[string]*string{"slack": slack, "discord": discord} {
+		if value == nil {
+			continue
+		}
+		url, err := webhookFlag(service, *value)
+		if err != nil {
+			return err
+		}
+		webhooks[service] = url
+	}
+	var summary *bool
+	if daily != nil {
+		var err error
+		if summary, err = parseDailySummary(*daily); err != nil {
+			return err
+		}
+	}
+	cfg, err := loadNotifyConfig()
+	if err != nil {
+		return err
+	}
+
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		for _, service := range notify.Services {
+			if url, ok := webhooks[service]; ok {
+				if err := notify.SetWebhook(ctx, database.DB, websiteID, service, url); err != nil {
+					return err
+				}
+			}
+		}
+		if daily != nil {
+			if err := notify.SetDailySummary(ctx, database.DB, websiteID, summary); err != nil {
+				return err
+			}
+		}
+
+		site, err := notify.GetSite(ctx, database.DB, websiteID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Notifications for %s\n", domain)
+		fmt.Printf("  Slack:          %s\n", describeWebhook(site.SlackWebhookURL, cfg.SlackWebhookURL))
+		fmt.Printf("  Discord:        %s\n", describeWebhook(site.DiscordWebhookURL, cfg.DiscordWebhookURL))
+		state := "off"
+		if site.SendsSummary(cfg) {
+			state = "on"
+		}
+		if site.DailySummary == nil {
+			state += " (kaunta.toml)"
+		}
+		fmt.Printf("  Daily summary:  %s\n", state)
+
+		if !test {
+			return nil
+		}
+		targets := site.Targets(cfg)
+		if len(targets) == 0 {
+			return fmt.Errorf("no Slack or Discord webhook set for %s", domain)
+		}
+		m, err := notify.Summary(ctx, database.DB, site, time.Now().UTC().AddDate(0, 0, -1))
+		if err != nil {
+			return err
+		}
+		for _, t := range targets {
+			if err := notify.Post(ctx, t, m); err != nil {
+				return err
+			}
+			fmt.Printf("Posted yesterday's summary to %s\n", t.Service)
+		}
+		return nil
+	})
+}
+
+func describeWebhook(own, configured string) string {
+	switch {
+	case own != "":
+		return own
+	case configured != "":
+		return configured + " (kaunta.toml)"
+	}
+	return "not set"
+}
+
+func init() {
+	websiteCmd.AddCommand(websiteNotifyCmd)
+
+	websiteNotifyCmd.Flags().StringVar(&notifySlack, "slack", "", "Slack incoming webhook URL, or none")
+	websiteNotifyCmd.Flags().StringVar(&notifyDiscord, "discord", "", "Discord webhook URL, or none")
+	websiteNotifyCmd.Flags().StringVar(&notifyDailySummary, "daily-summary", "", "Daily summary: on, off or default")
+	websiteNotifyCmd.Flags().BoolVar(&notifyTest, "test", false, "Post yesterday's summary now")
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/config"
+)
+
+func stubNotifyConfig(t *testing.T, cfg config.NotifyConfig) {
+	t.Helper()
+	original := loadNotifyConfig
+	loadNotifyConfig = func() (config.NotifyConfig, error) { return cfg, nil }
+	t.Cleanup(func() { loadNotifyConfig = original })
+}
+
+func TestRunWebsiteNotify(t *testing.T) {
+	stubNotifyConfig(t, config.NotifyConfig{SlackWebhookURL: "https://hooks.slack.com/services/default", DailySummary: true})
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectExec(`UPDATE website SET discord_webhook_url = NULLIF\(\$2, ''\)`).
+		WithArgs(websiteID, "https://discord.com/api/webhooks/1/x").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE website SET daily_summary = \$2`).WithArgs(websiteID, false).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`FROM website`).WithArgs(websiteID).
+		WillReturnRows(sqlmock.NewRows([]string{"website_id", "domain", "slack", "discord", "daily_summary", "summary_sent_on"}).
+			AddRow(websiteID, "example.com", "", "https://discord.com/api/webhooks/1/x", false, nil))
+
+	discord, daily := "https://discord.com/api/webhooks/1/x", "off"
+	output, err := captureOutput(t, func() error {
+		return runWebsiteNotify("example.com", nil, &discord, &daily, false)
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "Slack:          https://hooks.slack.com/services/default (kaunta.toml)")
+	assert.Contains(t, output, "Discord:        https://discord.com/api/webhooks/1/x")
+	assert.Contains(t, output, "Daily summary:  off\n")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunWebsiteNotifyInvalid(t *testing.T) {
+	slack, daily := "hooks.slack.com", "weekly"
+	assert.EqualError(t, runWebsiteNotify("example.com", &slack, nil, nil, false),
+		`invalid slack webhook URL: "hooks.slack.com"`)
+	assert.EqualError(t, runWebsiteNotify("example.com", nil, nil, &daily, false),
+		"invalid --daily-summary: weekly (use on, off or default)")
+}
+
+func TestParseDailySummary(t *testing.T) {
+	on, err := parseDailySummary("on")
+	require.NoError(t, err)
+	assert.True(t, *on)
+	def, err := parseDailySummary("default")
+	require.NoError(t, err)
+	assert.Nil(t, def)
+}
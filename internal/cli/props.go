@@ -0,0 +1,351 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/eventprops"
+)
+
+var (
+	propsMaxBytes int
+	propsMaxDepth int
+	propsDays     int
+	propsTop      int
+	propsFormat   string
+)
+
+var websitePropsCmd = &cobra.Command{
+	Use:   "props",
+	Short: "Keep a website's event properties lean",
+	Long: `Custom event properties (kaunta.track("signup", {plan: "pro"})) are stored
+with each event. These commands cap their size, choose which keys are
+indexed and find the events whose properties take the most room. Indexes
+and reports require PostgreSQL.`,
+}
+
+var websitePropsLimitsCmd = &cobra.Command{
+	Use:   "limits <domain> [--max-bytes N] [--max-depth N]",
+	Short: "Show or set the size and depth limits of event properties",
+	Long: fmt.Sprintf(`Show or set the limits event properties are cut to at ingestion.
+
+--max-depth drops values nested deeper than N levels, the properties
+themselves being level 1 (default %d). --max-bytes then drops the largest
+values until the properties fit in N bytes of JSON (default %d). A limit
+of 0 goes back to the default.
+
+Examples:
+  kaunta website props limits example.com
+  kaunta website props limits example.com --max-bytes 2048 --max-depth 2`, eventprops.DefaultMaxDepth, eventprops.DefaultMaxBytes),
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var maxBytes, maxDepth *int
+		if cmd.Flags().Changed("max-bytes") {
+			maxBytes = &propsMaxBytes
+		}
+		if cmd.Flags().Changed("max-depth") {
+			maxDepth = &propsMaxDepth
+		}
+		return runWebsitePropsLimits(args[0], maxBytes, maxDepth)
+	},
+}
+
+var websitePropsIndexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Choose which event property keys are indexed",
+	Long: `All event properties are indexed together by default (the catch-all index),
+which grows with every key sent. Index only the keys a website filters on,
+then drop the catch-all index once every website that needs one has its
+keys:
+
+  kaunta website props index add example.com plan
+  kaunta website props index catch-all off
+
+Keys can't be indexed when event properties are encrypted at rest.`,
+}
+
+var websitePropsIndexAddCmd = &cobra.Command{
+	Use:   "add <domain> <key>",
+	Short: "Index a property key",
+	Long: `Index a property key of a website's events. The index covers every
+partition of the events table, which takes writes on hold while it is built.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsitePropsIndexAdd(args[0], args[1])
+	},
+}
+
+var websitePropsIndexListCmd = &cobra.Command{
+	Use:   "list <domain>",
+	Short: "List a website's indexed property keys",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsitePropsIndexList(args[0])
+	},
+}
+
+var websitePropsIndexRemoveCmd = &cobra.Command{
+	Use:   "remove <domain> <key>",
+	Short: "Drop the index of a property key",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsitePropsIndexRemove(args[0], args[1])
+	},
+}
+
+var websitePropsIndexCatchAllCmd = &cobra.Command{
+	Use:   "catch-all [on|off]",
+	Short: "Show, create or drop the index of all properties",
+	Long: `Show, create (on) or drop (off) the index of all event properties. It
+serves every website: drop it once the keys they filter on are indexed.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		value := ""
+		if len(args) == 1 {
+			value = args[0]
+		}
+		return runWebsitePropsCatchAll(value)
+	},
+}
+
+var websitePropsLargestCmd = &cobra.Command{
+	Use:   "largest <domain> [--days N] [--top N] [--format table|json]",
+	Short: "List the events with the largest properties",
+	Long: `Show the room event properties took over a period and the events with the
+largest ones, to find the events worth trimming.
+
+Examples:
+  kaunta website props largest example.com
+  kaunta website props largest example.com --days 7 --top 20`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsitePropsLargest(args[0], propsDays, propsTop, propsFormat)
+	},
+}
+
+// runWebsitePropsLimits sets the limits given (nil leaves them as they are)
+// and shows them
+func runWebsitePropsLimits(domain string, maxBytes, maxDepth *int) error {
+	limits := eventprops.Limits{}
+	if maxBytes != nil {
+		limits.MaxBytes = *maxBytes
+	}
+	if maxDepth != nil {
+		limits.MaxDepth = *maxDepth
+	}
+	if err := limits.Validate(); err != nil {
+		return err
+	}
+
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		if maxBytes != nil || maxDepth != nil {
+			_, err := database.DB.ExecContext(ctx, `
+				UPDATE website SET
+					props_max_bytes = CASE WHEN $2 THEN NULLIF($3, 0) ELSE props_max_bytes END,
+					props_max_depth = CASE WHEN $4 THEN NULLIF($5, 0) ELSE props_max_depth END,
+					updated_at = NOW()
+				WHERE website_id = $1`,
+				websiteID, maxBytes != nil, limits.MaxBytes, maxDepth != nil, limits.MaxDepth)
+			if err != nil {
+				return fmt.Errorf("failed to update limits: %w", err)
+			}
+		}
+
+		var stored eventprops.Limits
+		if err := database.DB.QueryRowContext(ctx, `
+			SELECT COALESCE(props_max_bytes, 0), COALESCE(props_max_depth, 0)
+			FROM website WHERE website_id = $1`, websiteID,
+		).Scan(&stored.MaxBytes, &stored.MaxDepth); err != nil {
+			return fmt.Errorf("failed to read limits: %w", err)
+		}
+		fmt.Printf("Event properties of %s:\n", domain)
+		fmt.Printf("  Max size:  %s\n", limitValue(stored.MaxBytes, eventprops.DefaultMaxBytes, " bytes"))
+		fmt.Printf("  Max depth: %s\n", limitValue(stored.MaxDepth, eventprops.DefaultMaxDepth, ""))
+		return nil
+	})
+}
+
+// limitValue shows a limit, marking the default
+func limitValue(value, def int, unit string) string {
+	if value == 0 {
+		return fmt.Sprintf("%d%s (default)", def, unit)
+	}
+	return fmt.Sprintf("%d%s", value, unit)
+}
+
+func runWebsitePropsIndexAdd(domain, key string) error {
+	if err := eventprops.ValidKey(key); err != nil {
+		return err
+	}
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	// Building the index reads all the events
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	website, err := GetWebsiteByDomain(ctx, domain, nil)
+	if err != nil {
+		return err
+	}
+	websiteID, err := uuid.Parse(website.WebsiteID)
+	if err != nil {
+		return err
+	}
+	if err := eventprops.AddIndex(ctx, database.DB, websiteID, key); err != nil {
+		return err
+	}
+	fmt.Printf("Property %q of %s is indexed (%s)\n", key, website.Domain, eventprops.IndexName(websiteID, key))
+	return nil
+}
+
+func runWebsitePropsIndexList(domain string) error {
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		indexes, err := eventprops.ListIndexes(ctx, database.DB, websiteID)
+		if err != nil {
+			return err
+		}
+		if len(indexes) == 0 {
+			fmt.Printf("%s has no indexed property keys\n", domain)
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "KEY\tINDEX\tSIZE\tCREATED")
+		_, _ = fmt.Fprintln(w, "---\t-----\t----\t-------")
+		for _, i := range indexes {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", i.Key, i.Name, formatSize(i.Bytes), i.CreatedAt.Format("2006-01-02 15:04"))
+		}
+		return w.Flush()
+	})
+}
+
+func runWebsitePropsIndexRemove(domain, key string) error {
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		err := eventprops.RemoveIndex(ctx, database.DB, websiteID, key)
+		if errors.Is(err, eventprops.ErrNotIndexed) {
+			return fmt.Errorf("property %q is not indexed for %s", key, domain)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Property %q of %s is no longer indexed\n", key, domain)
+		return nil
+	})
+}
+
+func runWebsitePropsCatchAll(value string) error {
+	var on bool
+	switch strings.ToLower(value) {
+	case "":
+	case "on":
+		on = true
+	case "off":
+		on = false
+	default:
+		return fmt.Errorf("invalid value: %s (use on or off)", value)
+	}
+
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	if value != "" {
+		if err := eventprops.SetCatchAll(ctx, database.DB, on); err != nil {
+			return err
+		}
+	}
+	return printCatchAll(ctx, database.DB)
+}
+
+func printCatchAll(ctx context.Context, db *sql.DB) error {
+	exists, size, err := eventprops.CatchAll(ctx, db)
+	if err != nil {
+		return err
+	}
+	if exists {
+		fmt.Printf("All event properties are indexed (%s)\n", formatSize(size))
+	} else {
+		fmt.Println("Only the indexed property keys are indexed")
+	}
+	return nil
+}
+
+func runWebsitePropsLargest(domain string, days, top int, format string) error {
+	if days < 1 || days > 365 {
+		return fmt.Errorf("days must be between 1 and 365")
+	}
+	if top < 1 || top > 100 {
+		return fmt.Errorf("top must be between 1 and 100")
+	}
+	if format != "table" && format != "json" {
+		return fmt.Errorf("invalid format: %s (use table or json)", format)
+	}
+
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		report, err := eventprops.Largest(ctx, database.DB, websiteID, days, top)
+		if err != nil {
+			return err
+		}
+		if format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(report)
+		}
+
+		fmt.Printf("Event properties of %s, last %d days: %d events, %s\n",
+			domain, days, report.Events, formatSize(report.TotalBytes))
+		if len(report.Largest) == 0 {
+			return nil
+		}
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "SIZE\tEVENT\tPAGE\tTIME")
+		_, _ = fmt.Fprintln(w, "----\t-----\t----\t----")
+		for _, p := range report.Largest {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", formatSize(p.Bytes), orDash(deref(p.EventName)), orDash(deref(p.Path)),
+				p.Time.Format("2006-01-02 15:04"))
+		}
+		return w.Flush()
+	})
+}
+
+// deref is *s, "" when nil
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func init() {
+	websiteCmd.AddCommand(websitePropsCmd)
+	websitePropsCmd.AddCommand(websitePropsLimitsCmd, websitePropsIndexCmd, websitePropsLargestCmd)
+	websitePropsIndexCmd.AddCommand(websitePropsIndexAddCmd, websitePropsIndexListCmd,
+		websitePropsIndexRemoveCmd, websitePropsIndexCatchAllCmd)
+
+	websitePropsLimitsCmd.Flags().IntVar(&propsMaxBytes, "max-bytes", 0, "Largest properties kept, in bytes of JSON (0: default)")
+	websitePropsLimitsCmd.Flags().IntVar(&propsMaxDepth, "max-depth", 0, "Deepest nesting kept (0: default)")
+	websitePropsLargestCmd.Flags().IntVar(&propsDays, "days", 30, "Period in days (1-365)")
+	websitePropsLargestCmd.Flags().IntVar(&propsTop, "top", 10, "Events to list (1-100)")
+	websitePropsLargestCmd.Flags().StringVar(&propsFormat, "format", "table", "Output format: table or json")
+}
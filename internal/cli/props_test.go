@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunWebsitePropsLimits(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectExec(`UPDATE website SET`).
+		WithArgs(websiteID, true, 2048, false, 0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT COALESCE\(props_max_bytes, 0\)`).WithArgs(websiteID).
+		WillReturnRows(sqlmock.NewRows([]string{"props_max_bytes", "props_max_depth"}).AddRow(2048, 0))
+
+	maxBytes := 2048
+	output, err := captureOutput(t, func() error { return runWebsitePropsLimits("example.com", &maxBytes, nil) })
+	require.NoError(t, err)
+	assert.Contains(t, output, "Max size:  2048 bytes\n")
+	assert.Contains(t, output, "Max depth: 5 (default)")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunWebsitePropsLimitsRejectsBadValues(t *testing.T) {
+	depth := 11
+	assert.ErrorContains(t, runWebsitePropsLimits("example.com", nil, &depth), "max depth")
+	assert.ErrorContains(t, runWebsitePropsCatchAll("maybe"), "invalid value")
+	assert.ErrorContains(t, runWebsitePropsLargest("example.com", 30, 10, "csv"), "invalid format")
+}
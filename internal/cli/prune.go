@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/retention"
+)
+
+var (
+	pruneDryRun bool
+	pruneDays   int
+	pruneFormat string
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune [--dry-run] [--days <N>]",
+	Short: "Delete analytics data past its retention period",
+	Long: `Delete events older than the retention period and the sessions left
+without events.
+
+The global period comes from retention_days (0 keeps data forever); websites
+can override it with 'kaunta website retention'. Daily partitions older than
+every website's retention are dropped outright, other expired events are
+deleted in batches. The 'retention' worker task runs this every 6 hours.
+
+Options:
+  --dry-run   Show what would be deleted without deleting anything
+  --days N    Use N as the global retention instead of retention_days
+  --format    Output format for --dry-run: table, json
+
+Examples:
+  kaunta prune --dry-run
+  kaunta prune --days 395`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		days := pruneDays
+		if !cmd.Flags().Changed("days") {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			days = cfg.RetentionDays
+		}
+		return runPrune(days, pruneDryRun, pruneFormat)
+	},
+}
+
+var websiteRetentionCmd = &cobra.Command{
+	Use:   "retention <domain> <days|default>",
+	Short: "Set a website's data retention",
+	Long: `Override the global retention_days for one website.
+
+Use 0 to keep the website's data forever and 'default' to go back to the
+global setting.
+
+Examples:
+  kaunta website retention example.com 30
+  kaunta website retention example.com default`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteRetention(args[0], args[1])
+	},
+}
+
+func runPrune(days int, dryRun bool, format string) error {
+	if days < 0 {
+		return fmt.Errorf("days must not be negative")
+	}
+	if format != "table" && format != "json" {
+		return fmt.Errorf("invalid format: %s (use json or table)", format)
+	}
+
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	plan, err := retention.BuildPlan(ctx, database.DB, days)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		if format == "json" {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(plan)
+		}
+		return printPrunePlan(plan)
+	}
+
+	result, err := retention.Apply(ctx, database.DB, plan)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Dropped %d partition(s), deleted %d event(s) and %d session(s)\n",
+		result.Partitions, result.Events, result.Sessions)
+	return nil
+}
+
+func printPrunePlan(plan *retention.Plan) error {
+	if len(plan.Partitions) == 0 && len(plan.Websites) == 0 {
+		fmt.Println("Nothing to prune")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if len(plan.Partitions) > 0 {
+		_, _ = fmt.Fprintln(w, "PARTITION\tDAY\tEST. ROWS")
+		_, _ = fmt.Fprintln(w, "---------\t---\t---------")
+		for _, p := range plan.Partitions {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%d\n", p.Name, p.Day.Format("2006-01-02"), p.Rows)
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+	if len(plan.Websites) > 0 {
+		_, _ = fmt.Fprintln(w, "WEBSITE\tRETENTION\tCUTOFF\tEVENTS\tSESSIONS")
+		_, _ = fmt.Fprintln(w, "-------\t---------\t------\t------\t--------")
+		for _, site := range plan.Websites {
+			_, _ = fmt.Fprintf(w, "%s\t%dd\t%s\t%d\t%d\n", site.Domain, site.Days,
+				site.Cutoff.Format("2006-01-02"), site.Events, site.Sessions)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Println("\nDry run: nothing was deleted")
+	return nil
+}
+
+func runWebsiteRetention(domain, value string) error {
+	var days *int
+	if value != "default" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid retention: %s (use a number of days, 0 or default)", value)
+		}
+		days = &n
+	}
+
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	website, err := GetWebsiteByDomain(ctx, domain, nil)
+	if err != nil {
+		return err
+	}
+	websiteID, err := uuid.Parse(website.WebsiteID)
+	if err != nil {
+		return err
+	}
+	if err := retention.SetWebsiteDays(ctx, database.DB, websiteID, days); err != nil {
+		return err
+	}
+
+	switch {
+	case days == nil:
+		fmt.Printf("%s now uses the global retention_days\n", website.Domain)
+	case *days == 0:
+		fmt.Printf("%s keeps its data forever\n", website.Domain)
+	default:
+		fmt.Printf("%s keeps %d days of data\n", website.Domain, *days)
+	}
+	return nil
+}
+
+func init() {
+	RootCmd.AddCommand(pruneCmd)
+	websiteCmd.AddCommand(websiteRetentionCmd)
+
+	pruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "Show what would be deleted")
+	pruneCmd.Flags().IntVar(&pruneDays, "days", 0, "Global retention in days (default: retention_days)")
+	pruneCmd.Flags().StringVar(&pruneFormat, "format", "table", "Output format for --dry-run: json, table")
+}
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunPruneDryRun(t *testing.T) {
+	mock := mockJobsDB(t)
+
+	websiteID := uuid.New()
+	mock.ExpectQuery(`COALESCE\(retention_days, \$1\)`).WithArgs(0).
+		WillReturnRows(sqlmock.NewRows([]string{"website_id", "domain", "retention_days"}).
+			AddRow(websiteID, "example.com", 30))
+	mock.ExpectQuery(`FROM pg_inherits`).
+		WillReturnRows(sqlmock.NewRows([]string{"relname", "reltuples"}))
+	mock.ExpectQuery(`SELECT COUNT\(\*\)\s+FROM website_event`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1234))
+	mock.ExpectQuery(`FROM session s`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(56))
+
+	output, err := captureOutput(t, func() error { return runPrune(0, true, "table") })
+	require.NoError(t, err)
+	assert.Contains(t, output, "WEBSITE")
+	assert.Contains(t, output, "example.com")
+	assert.Contains(t, output, "1234")
+	assert.Contains(t, output, "Dry run: nothing was deleted")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunPruneValidation(t *testing.T) {
+	assert.EqualError(t, runPrune(-1, true, "table"), "days must not be negative")
+	assert.EqualError(t, runPrune(30, true, "csv"), "invalid format: csv (use json or table)")
+	assert.EqualError(t, runWebsiteRetention("example.com", "forever"),
+		"invalid retention: forever (use a number of days, 0 or default)")
+}
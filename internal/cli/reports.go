@@ -0,0 +1,371 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/digest"
+	"github.com/seuros/kaunta/internal/reports"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// Report command flags
+var (
+	reportMetrics    []string
+	reportDimensions []string
+	reportFilters    []string
+	reportDays       int
+	reportLimit      int
+	reportFormat     string
+	reportRunFormat  string
+	reportListFormat string
+	previewLang      string
+	previewTheme     string
+	previewText      bool
+	previewOutput    string
+	previewOpen      bool
+)
+
+// openInBrowser opens a local file with the desktop's default application
+var openInBrowser = func(path string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", path)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", path)
+	default:
+		cmd = exec.Command("xdg-open", path)
+	}
+	return cmd.Start()
+}
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Save report definitions and run them on demand",
+	Long: `Save named reports (metrics, breakdowns, filters, period and output
+format) and run them whenever needed, from the command line or through
+GET /api/reports/<name>. Saved reports require PostgreSQL.`,
+}
+
+var reportSaveCmd = &cobra.Command{
+	Use:   "save <name> <domain> [--metric <m>...] [--by <dimension>...] [--filter key=value...]",
+	Short: "Save a report definition",
+	Long: fmt.Sprintf(`Save a report definition, replacing any report saved under the same name.
+
+Options:
+  --metric     Totals to show: %s (repeatable)
+  --by         Breakdowns of pageviews: country, browser, device, referrer,
+               page, city, region, os, author, asn, screen, viewport or a
+               custom dimension (repeatable)
+  --filter     key=value with the dashboard's filters: country, browser,
+               device, page, bot or dim.<name> (repeatable)
+  --days N     Period in days (1-%d, default %d)
+  --limit N    Rows per breakdown (1-%d, default %d)
+  --format     Output format: %s (default table)
+
+Examples:
+  kaunta report save weekly-seo example.com --metric visitors,pageviews --by referrer,page
+  kaunta report save us-mobile example.com --by page --filter country=US --filter device=mobile --days 30`,
+		strings.Join(reports.Metrics, ", "), reports.MaxDays, reports.DefaultDays,
+		reports.MaxLimit, reports.DefaultLimit, strings.Join(reports.Formats, ", ")),
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		spec, err := reportSpec(reportMetrics, reportDimensions, reportFilters, reportDays, reportLimit, reportFormat)
+		if err != nil {
+			return err
+		}
+		return runReportSave(args[0], args[1], spec)
+	},
+}
+
+var reportRunCmd = &cobra.Command{
+	Use:   "run <name> [--format table|json|csv]",
+	Short: "Run a saved report",
+	Long: `Run a saved report and print it in its format, or the one given with --format.
+
+Examples:
+  kaunta report run weekly-seo
+  kaunta report run weekly-seo --format csv > weekly-seo.csv`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runReportRun(args[0], reportRunFormat)
+	},
+}
+
+var reportPreviewCmd = &cobra.Command{
+	Use:   "preview <name> [--lang <lang>] [--theme light|dark|auto] [--text] [--output <file>] [--open]",
+	Short: "Render a saved report as its digest email",
+	Long: `Run a saved report and render it as the digest email it is sent as, to check
+the templates before scheduling it.
+
+The templates are embedded; files in <data_dir>/templates/digest override them:
+digest.html, digest.txt (subject and text body) and locales/<lang>.json.
+
+Options:
+  --lang      Language of the email (en, de, fr, es or an added locale)
+  --theme     light, dark or auto (follows the reader's system)
+  --text      Render the subject and plain-text body instead of the HTML
+  --output    Write to a file instead of stdout
+  --open      Open the rendered email in the browser
+
+Examples:
+  kaunta report preview weekly-seo --open
+  kaunta report preview weekly-seo --lang de --theme dark --output digest.html
+  kaunta report preview weekly-seo --text`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runReportPreview(args[0], previewLang, previewTheme, previewText, previewOutput, previewOpen)
+	},
+}
+
+var reportListCmd = &cobra.Command{
+	Use:   "list [--format json|table]",
+	Short: "List saved reports",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runReportList(reportListFormat)
+	},
+}
+
+var reportRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Delete a saved report",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runReportRemove(args[0])
+	},
+}
+
+// reportSpec builds a report definition from the save flags
+func reportSpec(metrics, dims, filters []string, days, limit int, format string) (reports.Spec, error) {
+	spec := reports.Spec{
+		Metrics:    metrics,
+		Dimensions: dims,
+		Days:       days,
+		Limit:      limit,
+		Format:     format,
+	}
+	for _, filter := range filters {
+		key, value, ok := strings.Cut(filter, "=")
+		if !ok || key == "" || value == "" {
+			return spec, fmt.Errorf("invalid filter: %q (use key=value)", filter)
+		}
+		if spec.Filters == nil {
+			spec.Filters = make(map[string]string)
+		}
+		spec.Filters[key] = value
+	}
+	return spec, spec.Normalize()
+}
+
+func runReportSave(name, domain string, spec reports.Spec) error {
+	if err := reports.ValidName(name); err != nil {
+		return err
+	}
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		if err := reports.Save(ctx, database.DB, name, websiteID, spec); err != nil {
+			return err
+		}
+		fmt.Printf("Report %q saved for %s (run it with 'kaunta report run %s')\n", name, domain, name)
+		return nil
+	})
+}
+
+func runReportRun(name, format string) error {
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	report, err := reports.Get(ctx, database.DB, name)
+	if errors.Is(err, reports.ErrNotFound) {
+		return fmt.Errorf("no report saved as %q", name)
+	}
+	if err != nil {
+		return err
+	}
+	if format == "" {
+		format = report.Spec.Format
+	}
+
+	result, err := reports.Run(ctx, store.Current(), report)
+	if err != nil {
+		return err
+	}
+	return reports.Write(os.Stdout, result, format)
+}
+
+func runReportPreview(name, lang, theme string, text bool, output string, open bool) error {
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	report, err := reports.Get(ctx, database.DB, name)
+	if errors.Is(err, reports.ErrNotFound) {
+		return fmt.Errorf("no report saved as %q", name)
+	}
+	if err != nil {
+		return err
+	}
+	result, err := reports.Run(ctx, store.Current(), report)
+	if err != nil {
+		return err
+	}
+
+	opts := digest.Options{Lang: lang, Theme: theme}
+	if cfg, err := config.Load(); err == nil && cfg.DataDir != "" {
+		opts.Dir = digest.OverrideDir(cfg.DataDir)
+	}
+	email, err := digest.Render(result, opts)
+	if err != nil {
+		return err
+	}
+	return writePreview(email, name, text, output, open)
+}
+
+// writePreview prints a rendered digest, or writes it to output. Opening it
+// without an output writes it to a temporary file.
+func writePreview(email *digest.Email, name string, text bool, output string, open bool) error {
+	content, ext := email.HTML, ".html"
+	if text {
+		content, ext = "Subject: "+email.Subject+"\n\n"+email.Text, ".txt"
+	}
+
+	if output == "" && !open {
+		_, err := fmt.Print(content)
+		return err
+	}
+	if output == "" {
+		f, err := os.CreateTemp("", "kaunta-"+name+"-*"+ext)
+		if err != nil {
+			return err
+		}
+		_ = f.Close()
+		output = f.Name()
+	}
+	if err := os.WriteFile(output, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("failed to write preview: %w", err)
+	}
+	fmt.Printf("Preview written to %s\n", output)
+
+	if open {
+		if err := openInBrowser(output); err != nil {
+			return fmt.Errorf("failed to open %s: %w", output, err)
+		}
+	}
+	return nil
+}
+
+func runReportList(format string) error {
+	if format != "table" && format != "json" {
+		return fmt.Errorf("invalid format: %s (use json or table)", format)
+	}
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	list, err := reports.List(ctx, database.DB)
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		if list == nil {
+			list = []reports.Report{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(list)
+	}
+
+	if len(list) == 0 {
+		fmt.Println("No saved reports")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tWEBSITE\tDAYS\tMETRICS\tBREAKDOWNS\tFORMAT")
+	_, _ = fmt.Fprintln(w, "----\t-------\t----\t-------\t----------\t------")
+	for _, r := range list {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\n", r.Name, r.Domain, r.Spec.Days,
+			orDash(strings.Join(r.Spec.Metrics, ",")), orDash(strings.Join(r.Spec.Dimensions, ",")), r.Spec.Format)
+	}
+	return w.Flush()
+}
+
+func runReportRemove(name string) error {
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	err = reports.Remove(ctx, database.DB, name)
+	if errors.Is(err, reports.ErrNotFound) {
+		return fmt.Errorf("no report saved as %q", name)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Report %q removed\n", name)
+	return nil
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	RootCmd.AddCommand(reportCmd)
+	reportCmd.AddCommand(reportSaveCmd, reportRunCmd, reportPreviewCmd, reportListCmd, reportRemoveCmd)
+
+	reportSaveCmd.Flags().StringSliceVar(&reportMetrics, "metric", nil, "Totals to show (visitors, pageviews, current_visitors)")
+	reportSaveCmd.Flags().StringSliceVar(&reportDimensions, "by", nil, "Dimensions to break pageviews down by")
+	reportSaveCmd.Flags().StringArrayVar(&reportFilters, "filter", nil, "Filter as key=value")
+	reportSaveCmd.Flags().IntVarP(&reportDays, "days", "d", reports.DefaultDays, "Period in days")
+	reportSaveCmd.Flags().IntVarP(&reportLimit, "limit", "l", reports.DefaultLimit, "Rows per breakdown")
+	reportSaveCmd.Flags().StringVarP(&reportFormat, "format", "f", "table", "Output format: table, json, csv")
+
+	reportRunCmd.Flags().StringVarP(&reportRunFormat, "format", "f", "", "Output format, overriding the report's")
+
+	reportPreviewCmd.Flags().StringVar(&previewLang, "lang", "en", "Language of the email")
+	reportPreviewCmd.Flags().StringVar(&previewTheme, "theme", "light", "Theme: light, dark or auto")
+	reportPreviewCmd.Flags().BoolVar(&previewText, "text", false, "Render the plain-text body instead of the HTML")
+	reportPreviewCmd.Flags().StringVarP(&previewOutput, "output", "o", "", "Write to a file instead of stdout")
+	reportPreviewCmd.Flags().BoolVar(&previewOpen, "open", false, "Open the rendered email in the browser")
+
+	reportListCmd.Flags().StringVar(&reportListFormat, "format", "table", "Output format: json, table")
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/digest"
+	"github.com/seuros/kaunta/internal/reports"
+)
+
+func TestReportSpecFromFlags(t *testing.T) {
+	spec, err := reportSpec([]string{"visitors"}, []string{"referrer"}, []string{"country=US", "dim.plan=pro"}, 30, 0, "csv")
+	require.NoError(t, err)
+	assert.Equal(t, reports.Spec{
+		Metrics:    []string{"visitors"},
+		Dimensions: []string{"referrer"},
+		Filters:    map[string]string{"country": "US", "dim.plan": "pro"},
+		Days:       30,
+		Limit:      reports.DefaultLimit,
+		Format:     "csv",
+	}, spec)
+
+	_, err = reportSpec(nil, []string{"page"}, []string{"country"}, 7, 10, "table")
+	assert.EqualError(t, err, `invalid filter: "country" (use key=value)`)
+}
+
+func TestRunReportRemoveUnknown(t *testing.T) {
+	mock := mockJobsDB(t)
+	mock.ExpectExec(`DELETE FROM saved_report`).WithArgs("weekly-seo").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := runReportRemove("weekly-seo")
+	assert.EqualError(t, err, `no report saved as "weekly-seo"`)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWritePreview(t *testing.T) {
+	email := &digest.Email{Subject: "weekly-seo report for example.com", HTML: "<html></html>", Text: "Visitors: 12\n"}
+
+	var opened string
+	original := openInBrowser
+	openInBrowser = func(path string) error {
+		opened = path
+		return nil
+	}
+	t.Cleanup(func() { openInBrowser = original })
+
+	output, err := captureOutput(t, func() error {
+		return writePreview(email, "weekly-seo", true, "", false)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Subject: weekly-seo report for example.com\n\nVisitors: 12\n", output)
+	assert.Empty(t, opened)
+
+	path := filepath.Join(t.TempDir(), "digest.html")
+	_, err = captureOutput(t, func() error {
+		return writePreview(email, "weekly-seo", false, path, true)
+	})
+	require.NoError(t, err)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "<html></html>", string(data))
+	assert.Equal(t, path, opened)
+
+	// Opening without an output goes through a temporary file
+	_, err = captureOutput(t, func() error {
+		return writePreview(email, "weekly-seo", false, "", true)
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.Remove(opened) })
+	assert.True(t, strings.HasSuffix(opened, ".html"))
+	assert.FileExists(t, opened)
+}
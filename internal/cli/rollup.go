@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/rollup"
+)
+
+var rollupBackfillDays int
+
+var rollupCmd = &cobra.Command{
+	Use:   "rollup",
+	Short: "Manage pre-aggregated stats",
+	Long: `Manage the hourly and daily rollup tables used for long-range reports.
+
+The 'rollups' worker task keeps today's rollups current. Ranges of 2 days or
+more are served from the rollups once they cover the whole range; run
+'kaunta rollup backfill' after upgrading to aggregate existing history.`,
+}
+
+var rollupStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show rollup coverage",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRollupStatus()
+	},
+}
+
+var rollupBackfillCmd = &cobra.Command{
+	Use:   "backfill [--days <N>]",
+	Short: "Aggregate historical events into the rollup tables",
+	Long: `Recompute the rollups for the last N days, newest first.
+
+Each day is committed separately, so an interrupted backfill can be resumed
+and reports can use the days already done.
+
+Examples:
+  kaunta rollup backfill --days 365`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRollupBackfill(rollupBackfillDays)
+	},
+}
+
+func runRollupStatus() error {
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	state, err := rollup.GetState(ctx, database.DB)
+	if err != nil {
+		return err
+	}
+
+	if state.CoveredSince == nil {
+		fmt.Println("Covered since:  never (run 'kaunta rollup backfill' or start the worker)")
+	} else {
+		days := int(rollup.Today().Sub(*state.CoveredSince).Hours() / 24)
+		fmt.Printf("Covered since:  %s (%d days)\n", state.CoveredSince.Format("2006-01-02"), days)
+	}
+	if state.RefreshedAt == nil {
+		fmt.Println("Last refresh:   never")
+	} else {
+		fmt.Printf("Last refresh:   %s\n", state.RefreshedAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func runRollupBackfill(days int) error {
+	if days < 1 || days > 3650 {
+		return fmt.Errorf("days must be between 1 and 3650")
+	}
+
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	start := time.Now()
+	err = rollup.Backfill(ctx, database.DB, days, func(day time.Time) {
+		fmt.Printf("Aggregated %s\n", day.Format("2006-01-02"))
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Backfilled %d days in %s\n", days+1, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+func init() {
+	RootCmd.AddCommand(rollupCmd)
+	rollupCmd.AddCommand(rollupStatusCmd, rollupBackfillCmd)
+
+	rollupBackfillCmd.Flags().IntVar(&rollupBackfillDays, "days", 90, "Number of days to aggregate")
+}
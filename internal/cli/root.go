@@ -3,36 +3,54 @@ package cli
 import (
 	"context"
 	"crypto/sha256"
+	"database/sql"
 	"embed"
 	"encoding/hex"
 	"fmt"
 	"io/fs"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gofiber/contrib/v3/websocket"
-	zapmiddleware "github.com/gofiber/contrib/v3/zap"
 	"github.com/gofiber/fiber/v3"
 	"github.com/gofiber/fiber/v3/extractors"
-	"github.com/gofiber/fiber/v3/middleware/cors"
+	"github.com/gofiber/fiber/v3/middleware/adaptor"
 	"github.com/gofiber/fiber/v3/middleware/csrf"
 	"github.com/gofiber/fiber/v3/middleware/healthcheck"
 	"github.com/gofiber/fiber/v3/middleware/limiter"
 	"github.com/gofiber/fiber/v3/middleware/recover"
 	"github.com/gofiber/fiber/v3/middleware/static"
 	"github.com/gofiber/template/html/v2"
+	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 
+	"github.com/seuros/kaunta/internal/blobstore"
+	"github.com/seuros/kaunta/internal/cardinality"
+	"github.com/seuros/kaunta/internal/chaos"
 	"github.com/seuros/kaunta/internal/config"
 	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/debugcapture"
+	"github.com/seuros/kaunta/internal/dedup"
+	"github.com/seuros/kaunta/internal/features"
 	"github.com/seuros/kaunta/internal/geoip"
 	"github.com/seuros/kaunta/internal/handlers"
+	"github.com/seuros/kaunta/internal/ingest"
 	"github.com/seuros/kaunta/internal/logging"
 	"github.com/seuros/kaunta/internal/middleware"
+	"github.com/seuros/kaunta/internal/newsletter"
+	"github.com/seuros/kaunta/internal/offline"
+	"github.com/seuros/kaunta/internal/privacy"
 	"github.com/seuros/kaunta/internal/realtime"
+	"github.com/seuros/kaunta/internal/shortlink"
+	"github.com/seuros/kaunta/internal/store"
+	"github.com/seuros/kaunta/internal/tracing"
+	"github.com/seuros/kaunta/internal/visits"
+	"github.com/seuros/kaunta/internal/worker"
 	"go.uber.org/zap"
 )
 
@@ -57,6 +75,7 @@ It provides real-time analytics and a clean dashboard interface.`,
 			logging.L().Warn("failed to load config overrides", zap.Error(err))
 			return nil
 		}
+		logging.Configure(cfg.LogLevel, cfg.LogFormat)
 
 		// Set environment variables from config (for backward compatibility)
 		if cfg.DatabaseURL != "" {
@@ -69,6 +88,36 @@ It provides real-time analytics and a clean dashboard interface.`,
 			_ = os.Setenv("DATA_DIR", cfg.DataDir)
 		}
 		_ = os.Setenv("SECURE_COOKIES", strconv.FormatBool(cfg.SecureCookies))
+		offline.Set(cfg.Offline)
+
+		ipMode, err := privacy.ParseMode(cfg.IPMode)
+		if err != nil {
+			return err
+		}
+		privacy.SetMode(ipMode)
+		validation, err := handlers.ParseValidationMode(cfg.PayloadValidation)
+		if err != nil {
+			return err
+		}
+		handlers.SetValidationMode(validation)
+		visitDefinition, err := visits.ParseDefinition(cfg.VisitDefinition)
+		if err != nil {
+			return err
+		}
+		if err := visits.Configure(visits.Rules{Definition: visitDefinition, Timeout: cfg.SessionTimeout}); err != nil {
+			return err
+		}
+		newsletter.SetRecipients(cfg.EmailRecipients)
+		cardinality.Configure(cfg.CardinalityCap, func() *sql.DB { return database.DB })
+		dedup.Configure(cfg.DedupWindow, func() *sql.DB { return database.DB })
+		debugcapture.Configure(func() *sql.DB { return database.DB })
+		if err := features.Configure(cfg.Features, func() *sql.DB { return database.DB }); err != nil {
+			logging.L().Warn("feature flags", zap.Error(err))
+		}
+
+		if err := configureEncryption(cfg); err != nil {
+			return err
+		}
 		return nil
 	},
 	// Default to serve command if no subcommand provided
@@ -88,6 +137,22 @@ It provides real-time analytics and a clean dashboard interface.`,
 	},
 }
 
+// ExitError makes main exit with Code, for commands whose exit code means
+// something to the caller (monitors, cron). The command has already printed
+// why, so main doesn't log it.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
 // Execute is called by main
 func Execute(
 	version string,
@@ -141,47 +206,175 @@ func serveAnalytics(
 		logging.Fatal("DATABASE_URL environment variable is required")
 	}
 
-	// Run migrations
-	logging.L().Info("running database migrations")
-	if err := database.RunMigrations(databaseURL); err != nil {
-		logging.L().Warn("migration warning", zap.Error(err))
+	sqliteMode := database.IsSQLiteURL(databaseURL)
+
+	if sqliteMode {
+		// Single-file SQLite database: migrations are applied on open
+		sqliteStore, err := store.OpenSQLite(databaseURL)
+		if err != nil {
+			logging.Fatal("sqlite database initialization failed", zap.Error(err))
+		}
+		store.SetCurrent(sqliteStore)
+		defer func() {
+			if err := sqliteStore.Close(); err != nil {
+				logging.L().Warn("error closing database", zap.Error(err))
+			}
+		}()
 	} else {
-		logging.L().Info("migrations completed")
-	}
+		// Run migrations
+		logging.L().Info("running database migrations")
+		if err := database.RunMigrations(databaseURL); err != nil {
+			logging.L().Warn("migration warning", zap.Error(err))
+		} else {
+			logging.L().Info("migrations completed")
+		}
 
-	// Connect to database
-	if err := database.Connect(); err != nil {
-		logging.Fatal("database connection failed", zap.Error(err))
-	}
-	defer func() {
-		if err := database.Close(); err != nil {
-			logging.L().Warn("error closing database", zap.Error(err))
+		// Connect to database
+		if err := database.Connect(); err != nil {
+			logging.Fatal("database connection failed", zap.Error(err))
 		}
-	}()
+		defer func() {
+			if err := database.Close(); err != nil {
+				logging.L().Warn("error closing database", zap.Error(err))
+			}
+		}()
+
+		ensureFunctions()
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	realtimeHub := realtime.NewHub()
-	logging.L().Info("starting realtime websocket listener")
-	if err := realtime.StartListener(ctx, databaseURL, realtimeHub); err != nil {
-		logging.L().Error("failed to start realtime listener", zap.Error(err))
+	if sqliteMode {
+		// No LISTEN/NOTIFY: the tracking handler broadcasts in-process
+		realtime.UseLocalHub(realtimeHub)
 	} else {
-		logging.L().Info("realtime websocket listener started successfully")
+		logging.L().Info("starting realtime websocket listener")
+		if err := realtime.StartListener(ctx, databaseURL, realtimeHub); err != nil {
+			logging.L().Error("failed to start realtime listener", zap.Error(err))
+		} else {
+			logging.L().Info("realtime websocket listener started successfully")
+		}
 	}
+	// Live feeds share one active visitor count per website
+	realtimeHub.TrackVisitors(ctx, func(ctx context.Context, websiteID uuid.UUID) (int64, error) {
+		return store.Current().CurrentVisitors(ctx, websiteID)
+	})
 
-	// Sync trusted origins from config to database
 	cfg, err := config.Load()
 	if err != nil {
 		logging.L().Warn("failed to load config for trusted origins", zap.Error(err))
-	} else if len(cfg.TrustedOrigins) > 0 {
+	} else if len(cfg.TrustedOrigins) > 0 && !sqliteMode {
+		// Sync trusted origins from config to database
 		syncTrustedOrigins(cfg.TrustedOrigins)
 	}
 
-	// Initialize trusted origins cache from database
-	logging.L().Info("initializing trusted origins cache")
-	if err := middleware.InitTrustedOriginsCache(); err != nil {
-		logging.L().Warn("failed to initialize trusted origins cache", zap.Error(err))
+	// Tracker served under the site's own paths, next to the default ones
+	var trackerPath, collectPath string
+	if cfg != nil {
+		trackerPath, collectPath = cfg.TrackerPath, cfg.CollectPath
+		if trackerPath != "" {
+			if err := validTrackerPath(trackerPath); err != nil {
+				logging.Fatal("invalid tracker config", zap.Error(err))
+			}
+		}
+		if collectPath != "" {
+			if err := validCollectPath(collectPath); err != nil {
+				logging.Fatal("invalid tracker config", zap.Error(err))
+			}
+		}
+		trackerScript = rewriteTrackerScript(trackerScript, trackerPath, collectPath)
+	}
+
+	if cfg != nil && !sqliteMode {
+		switch cfg.EventStore {
+		case "", "postgres":
+		case "clickhouse":
+			if cfg.ClickHouseURL == "" {
+				logging.Fatal("event_store = \"clickhouse\" requires clickhouse_url")
+			}
+			logging.L().Info("initializing clickhouse event store")
+			clickHouseStore, err := store.OpenClickHouse(ctx, cfg.ClickHouseURL)
+			if err != nil {
+				logging.Fatal("clickhouse initialization failed", zap.Error(err))
+			}
+			store.SetCurrent(clickHouseStore)
+			defer func() { _ = clickHouseStore.Close() }()
+		default:
+			logging.Fatal("unknown event_store", zap.String("event_store", cfg.EventStore))
+		}
+	}
+
+	if cfg != nil {
+		shutdownTracing, err := tracing.Init(ctx, tracing.Config{
+			Endpoint:    cfg.TracingEndpoint,
+			SampleRatio: cfg.TracingSampleRatio,
+			Version:     Version,
+			Headers:     cfg.TracingHeaders,
+		})
+		if err != nil {
+			logging.Fatal("tracing initialization failed", zap.Error(err))
+		}
+		if cfg.TracingEndpoint != "" {
+			logging.L().Info("exporting traces", zap.String("endpoint", cfg.TracingEndpoint))
+		}
+		defer func() {
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = shutdownTracing(flushCtx)
+		}()
+	}
+
+	if cfg != nil {
+		if !sqliteMode {
+			database.SetPool(cfg.DBMaxOpenConns, cfg.DBMaxIdleConns)
+		}
+		handlers.SetCacheTTL(cfg.CacheTTL)
+		worker.SetInterval("rollups", cfg.RollupInterval)
+	}
+
+	// Background maintenance; advisory locks keep it single-run when
+	// `kaunta worker` processes share the database
+	if cfg != nil && cfg.EmbeddedJobs && !sqliteMode {
+		runner := &worker.Runner{Tasks: worker.Tasks(), Locker: worker.AdvisoryLocker{DB: database.DB}}
+		go runner.Run(ctx)
+	}
+
+	// Buffer tracked events and write them in batches
+	ingestCfg := ingest.Config{}
+	if cfg != nil {
+		ingestCfg = ingest.Config{
+			QueueSize:     cfg.IngestQueueSize,
+			BatchSize:     cfg.IngestBatchSize,
+			FlushInterval: cfg.IngestFlushInterval,
+			Sampling: ingest.SamplingConfig{
+				Disabled:         !cfg.AdaptiveSampling,
+				QueueThreshold:   cfg.SamplingQueueThreshold,
+				LatencyThreshold: cfg.SamplingLatencyThreshold,
+				MaxRate:          cfg.SamplingMaxRate,
+			},
+		}
+	}
+	eventQueue := ingest.New(ingestCfg, func(ctx context.Context, events []*store.Event) error {
+		return store.Current().InsertEvents(ctx, events)
+	})
+	eventQueue.Start()
+	ingest.SetCurrent(eventQueue)
+
+	// Limit tracking requests per client IP and per website
+	if cfg != nil {
+		ingest.SetRateLimiter(ingest.NewRateLimiter(cfg.IngestRateLimitIP, cfg.IngestRateLimitWebsite))
+	} else {
+		ingest.SetRateLimiter(ingest.NewRateLimiter(ingest.DefaultRateLimitIP, 0))
+	}
+
+	if !sqliteMode {
+		// Initialize trusted origins cache from database
+		logging.L().Info("initializing trusted origins cache")
+		if err := middleware.InitTrustedOriginsCache(); err != nil {
+			logging.L().Warn("failed to initialize trusted origins cache", zap.Error(err))
+		}
 	}
 
 	// Initialize GeoIP database (downloads if missing)
@@ -189,15 +382,36 @@ func serveAnalytics(
 	if dataDir == "" {
 		dataDir = "./data"
 	}
-	if err := geoip.Init(dataDir); err != nil {
+	var geoipOptions geoip.Options
+	if cfg != nil {
+		geoipOptions = geoip.Options{
+			Provider:         cfg.GeoIPProvider,
+			LicenseKey:       cfg.MaxMindLicenseKey,
+			IP2LocationToken: cfg.IP2LocationToken,
+			UpdateInterval:   cfg.GeoIPUpdateInterval,
+		}
+	}
+	if err := geoip.Init(dataDir, geoipOptions); err != nil {
 		logging.Fatal("geoip initialization failed", zap.Error(err))
 	}
+	// Keep the database fresh; new files are swapped in without a restart
+	geoip.StartAutoUpdate(ctx)
 	defer func() {
 		if err := geoip.Close(); err != nil {
 			logging.L().Warn("error closing geoip", zap.Error(err))
 		}
 	}()
 
+	// Object storage shared by archives, exports and reports
+	if cfg != nil {
+		bucket, err := blobstore.Open(cfg.Storage, dataDir)
+		if err != nil {
+			logging.Fatal("object storage initialization failed", zap.Error(err))
+		}
+		blobstore.SetCurrent(bucket)
+		logging.L().Info("object storage ready", zap.String("backend", bucket.Name()))
+	}
+
 	// Initialize HTML template engine
 	viewsEmbedFS, ok := viewsFS.(embed.FS)
 	if !ok {
@@ -216,21 +430,27 @@ func serveAnalytics(
 
 	// Middleware
 	app.Use(recover.New())
-	app.Use(zapmiddleware.New(zapmiddleware.Config{
-		Logger: logging.L(),
-		Next: func(c fiber.Ctx) bool {
-			path := c.Path()
-			return path == "/up" || path == "/health" // Skip healthcheck logs
-		},
-	}))
-	app.Use(cors.New(cors.Config{
-		AllowOriginsFunc: func(origin string) bool {
-			return true // Allow all origins
-		},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "X-CSRF-Token"},
-		AllowMethods:     []string{"GET", "POST", "OPTIONS"},
-		AllowCredentials: true,
-	}))
+	app.Use(middleware.Tracing)
+	if cfg == nil || cfg.AccessLog {
+		app.Use(middleware.AccessLog(logging.L()))
+	}
+	// The API has its own CORS policy; the tracking paths (tracker, tracking
+	// endpoints, pixels, feeds) accept any origin, and nothing else gets
+	// CORS headers
+	apiCORS := middleware.CORSConfig{MaxAge: 600}
+	if cfg != nil {
+		apiCORS = middleware.CORSConfig{
+			AllowOrigins:     cfg.APICORSOrigins,
+			AllowCredentials: cfg.APICORSCredentials,
+			MaxAge:           cfg.APICORSMaxAge,
+		}
+	}
+	apiCORSHandler, err := middleware.APICORS(apiCORS)
+	if err != nil {
+		logging.Fatal("invalid api_cors_origins", zap.Error(err))
+	}
+	app.Use(middleware.TrackingCORS(trackerPath, collectPath))
+	app.Use(apiCORSHandler)
 
 	// Add version header to all responses
 	app.Use(func(c fiber.Ctx) error {
@@ -249,10 +469,18 @@ func serveAnalytics(
 
 	// CSRF protection middleware - use database-backed trusted origins
 	// Get initial trusted origins from cache
-	trustedOrigins, err := middleware.GetTrustedOrigins()
-	if err != nil {
-		logging.L().Warn("failed to get trusted origins", zap.Error(err))
-		trustedOrigins = []string{} // Empty list if error
+	var trustedOrigins []string
+	if sqliteMode {
+		// SQLite deployments read trusted origins straight from config
+		if cfg != nil {
+			trustedOrigins = cfg.TrustedOrigins
+		}
+	} else {
+		trustedOrigins, err = middleware.GetTrustedOrigins()
+		if err != nil {
+			logging.L().Warn("failed to get trusted origins", zap.Error(err))
+			trustedOrigins = []string{} // Empty list if error
+		}
 	}
 
 	// Transform domain strings to full URLs for CSRF middleware
@@ -279,8 +507,23 @@ func serveAnalytics(
 		TrustedOrigins: trustedOriginURLs, // Loaded from database, transformed to URLs
 		// Skip CSRF protection for public endpoints and static assets
 		Next: func(c fiber.Ctx) bool {
-			// Skip for tracking API endpoint
-			if c.Path() == "/api/send" {
+			// Skip for tracking API endpoints
+			if c.Path() == "/api/send" || c.Path() == "/api/batch" ||
+				c.Path() == "/mp/collect" || c.Path() == "/debug/mp/collect" {
+				return true
+			}
+			if collectPath != "" && (c.Path() == collectPath || c.Path() == collectPath+"/batch") {
+				return true
+			}
+			// Share passwords unlock a read-only page of their own, not a
+			// session a forged request could ride on
+			if c.Method() == "POST" && strings.HasPrefix(c.Path(), "/share/") {
+				return true
+			}
+			// API tokens aren't sent by browsers on their own, so requests
+			// authenticated by one alone can't be forged
+			if (strings.HasPrefix(c.Get("Authorization"), "Bearer ") || c.Get(middleware.UmamiAPIKeyHeader) != "") &&
+				c.Cookies("kaunta_session") == "" {
 				return true
 			}
 			// Skip for GET requests to static assets (JS, CSS)
@@ -349,12 +592,29 @@ func serveAnalytics(
 			return pingDatabase() == nil
 		},
 	}))
+	app.Get("/readyz", handleReady)
 	app.Get("/api/version", handleVersion)
+	if chaos.Enabled {
+		// Failure injection for resilience tests, never in default builds
+		logging.L().Warn("failure injection is compiled in; do not run this build in production")
+		app.Get("/api/chaos", middleware.Auth, handlers.HandleChaos)
+		app.Put("/api/chaos", middleware.Auth, handlers.HandleChaosUpdate)
+	}
+	if cfg != nil && cfg.Metrics {
+		var metricsDB *sql.DB
+		if !sqliteMode {
+			metricsDB = database.DB
+		}
+		app.Get("/metrics", adaptor.HTTPHandler(metricsHandler(metricsDB)))
+	}
 
 	// Tracker script
 	app.Get("/k.js", handleTrackerScript(trackerScript))
 	app.Get("/kaunta.js", handleTrackerScript(trackerScript)) // Long form
 	app.Get("/script.js", handleTrackerScript(trackerScript)) // Umami-compatible alias
+	if trackerPath != "" {
+		app.Get(trackerPath, handleTrackerScript(trackerScript))
+	}
 
 	// Static assets (favicon, etc.) from embedded FS
 	assetsSubFS, err := fs.Sub(assetsFS.(embed.FS), "assets")
@@ -382,9 +642,62 @@ func serveAnalytics(
 		return c.SendStatus(fiber.StatusOK)
 	})
 	app.Post("/api/send", handlers.HandleTracking)
+	app.Options("/api/batch", func(c fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+	app.Post("/api/batch", handlers.HandleBatch)
+	if collectPath != "" {
+		app.Options(collectPath, func(c fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusOK)
+		})
+		app.Post(collectPath, handlers.HandleTracking)
+		app.Options(collectPath+"/batch", func(c fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusOK)
+		})
+		app.Post(collectPath+"/batch", handlers.HandleBatch)
+	}
+
+	// GA4 Measurement Protocol (server-side hits, authenticated by API secret)
+	app.Post("/mp/collect", handlers.HandleGA4Collect)
+	app.Post("/debug/mp/collect", handlers.HandleGA4Debug)
+
+	// Pixel tracking without JavaScript (email, noscript, feed readers)
+	app.Get("/k.gif", handlers.HandlePixel)
+
+	// Baseline pixel for the tracker blocking estimate
+	app.Get("/b/:website_id", handlers.HandleBaselinePixel)
+
+	// Short links: record the click, then redirect (`kaunta link`)
+	app.Get(shortlink.Path+":slug", handlers.HandleShortLink)
+	app.Get(shortlink.Path+":slug/qr.png", handlers.HandleShortLinkQR)
+
+	// Email campaigns: open pixel and tracked links (`kaunta email`)
+	app.Get(newsletter.OpenPath, handlers.HandleEmailOpen)
+	app.Get(newsletter.ClickPath, handlers.HandleEmailClick)
+
+	// API tokens get per-token rate limits and daily quotas; dashboard
+	// sessions are not limited
+	apiRateLimit, apiDailyQuota := 600, 0
+	if cfg != nil {
+		apiRateLimit, apiDailyQuota = cfg.APIRateLimit, cfg.APIDailyQuota
+	}
+	apiLimit := middleware.APIRateLimit(apiRateLimit, apiDailyQuota)
 
 	// Stats API (Plausible-inspired) - protected
-	app.Get("/api/stats/realtime/:website_id", middleware.Auth, handlers.HandleCurrentVisitors)
+	app.Get("/api/stats/realtime/:website_id", middleware.Auth, apiLimit, handlers.HandleCurrentVisitors)
+	// Long-poll fallback of /ws/realtime for proxies that drop WebSockets
+	app.Get("/api/realtime/poll/:website_id", middleware.Auth, apiLimit, realtimeHub.PollHandler())
+	// Per-website live feed: events as they are tracked and active visitors
+	app.Get("/api/ws/live/:website_id", middleware.Auth, realtimeHub.LiveHandler())
+	// The same feed as Server-Sent Events with activity snapshots, for
+	// `kaunta stats live --follow`
+	app.Get("/api/sse/live/:website_id", middleware.Auth, apiLimit, realtimeHub.StreamHandler(handlers.LiveSnapshot))
+
+	// Plausible's Stats API (v1), for dashboards and scripts written for it
+	app.Get("/api/v1/stats/realtime/visitors", middleware.Auth, apiLimit, handlers.HandlePlausibleRealtime)
+	app.Get("/api/v1/stats/aggregate", middleware.Auth, apiLimit, handlers.HandlePlausibleAggregate)
+	app.Get("/api/v1/stats/timeseries", middleware.Auth, apiLimit, handlers.HandlePlausibleTimeseries)
+	app.Get("/api/v1/stats/breakdown", middleware.Auth, apiLimit, handlers.HandlePlausibleBreakdown)
 
 	// Auth API endpoints (public)
 	// Rate limiter for login endpoint (5 requests per minute per IP)
@@ -416,14 +729,17 @@ func serveAnalytics(
 		return c.Render("views/dashboard/home", fiber.Map{
 			"Title":   "Dashboard",
 			"Version": Version,
+			"Offline": offline.Enabled(),
 		}, "views/layouts/dashboard")
 	})
 
 	// Map UI (protected)
+	app.Get("/plain/:website_id", middleware.AuthWithRedirect, handlers.HandlePlainDashboard)
 	app.Get("/dashboard/map", middleware.AuthWithRedirect, func(c fiber.Ctx) error {
 		return c.Render("views/dashboard/map", fiber.Map{
 			"Title":   "Map",
 			"Version": Version,
+			"Offline": offline.Enabled(),
 		})
 	})
 
@@ -432,25 +748,97 @@ func serveAnalytics(
 	app.Get("/api/auth/me", middleware.Auth, handlers.HandleMe)
 
 	// Dashboard API endpoints (protected)
-	app.Get("/api/websites", middleware.Auth, handlers.HandleWebsites)
-	app.Get("/api/dashboard/stats/:website_id", middleware.Auth, handlers.HandleDashboardStats)
-	app.Get("/api/dashboard/pages/:website_id", middleware.Auth, handlers.HandleTopPages)
-	app.Get("/api/dashboard/timeseries/:website_id", middleware.Auth, handlers.HandleTimeSeries)
-	app.Get("/api/dashboard/referrers/:website_id", middleware.Auth, handlers.HandleTopReferrers)
-	app.Get("/api/dashboard/browsers/:website_id", middleware.Auth, handlers.HandleTopBrowsers)
-	app.Get("/api/dashboard/devices/:website_id", middleware.Auth, handlers.HandleTopDevices)
-	app.Get("/api/dashboard/countries/:website_id", middleware.Auth, handlers.HandleTopCountries)
-	app.Get("/api/dashboard/cities/:website_id", middleware.Auth, handlers.HandleTopCities)
-	app.Get("/api/dashboard/regions/:website_id", middleware.Auth, handlers.HandleTopRegions)
-	app.Get("/api/dashboard/map/:website_id", middleware.Auth, handlers.HandleMapData)
+	app.Get("/api/features", middleware.Auth, apiLimit, handlers.HandleFeatures)
+	app.Get("/api/definitions", middleware.Auth, apiLimit, handlers.HandleDefinitions)
+	app.Get("/api/websites", middleware.Auth, apiLimit, handlers.HandleWebsites)
+	app.Get("/api/websites/:id/values", middleware.Auth, apiLimit, handlers.HandleDimensionValues)
+	// Umami's website API, for scripts and integrations written for it
+	app.Get("/api/websites/:website_id", middleware.Auth, apiLimit, handlers.HandleUmamiWebsite)
+	app.Get("/api/websites/:website_id/active", middleware.Auth, apiLimit, handlers.HandleUmamiActive)
+	app.Get("/api/websites/:website_id/stats", middleware.Auth, apiLimit, handlers.HandleUmamiStats)
+	app.Get("/api/websites/:website_id/pageviews", middleware.Auth, apiLimit, handlers.HandleUmamiPageviews)
+	app.Get("/api/websites/:website_id/metrics", middleware.Auth, apiLimit, handlers.HandleUmamiMetrics)
+	// Saved reports (defined with 'kaunta report save')
+	app.Get("/api/reports", middleware.Auth, apiLimit, handlers.HandleListReports)
+	app.Get("/api/reports/:name", middleware.Auth, apiLimit, handlers.HandleRunReport)
+
+	// Alert rules (also managed with 'kaunta alert')
+	app.Get("/api/alerts/:website_id", middleware.Auth, apiLimit, handlers.HandleListAlerts)
+	app.Post("/api/alerts/:website_id", middleware.Auth, apiLimit, handlers.HandleSaveAlert)
+	app.Delete("/api/alerts/:website_id/:name", middleware.Auth, apiLimit, handlers.HandleDeleteAlert)
+
+	// Notes on days of a website (also managed with 'kaunta note')
+	app.Get("/api/notes/:website_id", middleware.Auth, apiLimit, handlers.HandleListNotes)
+	app.Post("/api/notes/:website_id", middleware.Auth, apiLimit, handlers.HandleAddNote)
+	app.Delete("/api/notes/:website_id/:note_id", middleware.Auth, apiLimit, handlers.HandleDeleteNote)
+	// Annotations of moments (deploys, campaigns; also 'kaunta annotate')
+	app.Get("/api/annotations/:website_id", middleware.Auth, apiLimit, handlers.HandleListAnnotations)
+	app.Post("/api/annotations/:website_id", middleware.Auth, apiLimit, handlers.HandleAddAnnotation)
+	app.Delete("/api/annotations/:website_id/:annotation_id", middleware.Auth, apiLimit, handlers.HandleDeleteAnnotation)
+
+	app.Get("/api/dashboard/stats/:website_id", middleware.Auth, apiLimit, handlers.HandleDashboardStats)
+	app.Get("/api/dashboard/pages/:website_id", middleware.Auth, apiLimit, handlers.HandleTopPages)
+	app.Get("/api/dashboard/timeseries/:website_id", middleware.Auth, apiLimit, handlers.HandleTimeSeries)
+	app.Get("/api/dashboard/referrers/:website_id", middleware.Auth, apiLimit, handlers.HandleTopReferrers)
+	app.Get("/api/dashboard/browsers/:website_id", middleware.Auth, apiLimit, handlers.HandleTopBrowsers)
+	app.Get("/api/dashboard/devices/:website_id", middleware.Auth, apiLimit, handlers.HandleTopDevices)
+	app.Get("/api/dashboard/countries/:website_id", middleware.Auth, apiLimit, handlers.HandleTopCountries)
+	app.Get("/api/dashboard/cities/:website_id", middleware.Auth, apiLimit, handlers.HandleTopCities)
+	app.Get("/api/dashboard/regions/:website_id", middleware.Auth, apiLimit, handlers.HandleTopRegions)
+	app.Get("/api/dashboard/asns/:website_id", middleware.Auth, apiLimit, handlers.HandleTopASNs)
+	app.Get("/api/dashboard/screens/:website_id", middleware.Auth, apiLimit, handlers.HandleTopScreens)
+	app.Get("/api/dashboard/viewports/:website_id", middleware.Auth, apiLimit, handlers.HandleTopViewports)
+	app.Get("/api/dashboard/channels/:website_id", middleware.Auth, apiLimit, handlers.HandleTopChannels)
+	app.Get("/api/dashboard/map/:website_id", middleware.Auth, apiLimit, handlers.HandleMapData)
+	app.Get("/api/dashboard/utm/:website_id", middleware.Auth, apiLimit, handlers.HandleUTMBreakdown)
+	app.Get("/api/dashboard/dimensions/:website_id", middleware.Auth, apiLimit, handlers.HandleCustomDimensions)
+	app.Get("/api/dashboard/dimensions/:website_id/:name", middleware.Auth, apiLimit, handlers.HandleCustomDimensionBreakdown)
+	app.Get("/api/dashboard/trending/:website_id", middleware.Auth, apiLimit, handlers.HandleTrending)
+	app.Get("/api/dashboard/events/:website_id", middleware.Auth, apiLimit, handlers.HandleEvents)
+	app.Get("/api/dashboard/event-properties/:website_id", middleware.Auth, apiLimit, handlers.HandleEventProperties)
+	app.Get("/api/dashboard/sessions/:website_id", middleware.Auth, apiLimit, handlers.HandleSessions)
+	app.Get("/api/dashboard/sessions/:website_id/:session_id", middleware.Auth, apiLimit, handlers.HandleSessionJourney)
+	app.Get("/api/dashboard/email/:website_id", middleware.Auth, apiLimit, handlers.HandleEmailCampaigns)
+	// Website groups: several websites rolled up into one view
+	app.Get("/api/groups", middleware.Auth, apiLimit, handlers.HandleListGroups)
+	app.Get("/api/groups/:group_id/stats", middleware.Auth, apiLimit, handlers.HandleGroupStats)
+	app.Get("/api/groups/:group_id/timeseries", middleware.Auth, apiLimit, handlers.HandleGroupTimeSeries)
+	app.Get("/api/groups/:group_id/breakdown/:dimension", middleware.Auth, apiLimit, handlers.HandleGroupBreakdown)
+
+	// Top pages feeds (RSS / JSON Feed), also public for websites with a share ID
+	app.Get("/api/feeds/top-pages/:website_id", middleware.Auth, apiLimit, handlers.HandleTopPagesFeed)
+	app.Get("/share/:share_id/top-pages", handlers.HandleSharedTopPagesFeed)
+
+	// Shared dashboards (read-only, public for websites with a share ID;
+	// passwords are tried at the login rate)
+	app.Get("/share/:share_id", handlers.HandleSharedDashboard)
+	app.Post("/share/:share_id", loginLimiter, handlers.HandleShareUnlock)
+
+	// Embeds of shared websites: a visitors badge and a JSON widget
+	app.Get("/embed/:share_id/badge.svg", handlers.HandleEmbedBadge)
+	app.Get("/embed/:share_id/stats.json", handlers.HandleEmbedStats)
 
 	// Start server
 	port := getEnv("PORT", "3000")
 	logging.L().Info("starting kaunta server", zap.String("port", port))
-	if err := app.Listen(":" + port); err != nil {
+	// SIGINT/SIGTERM finish in-flight requests, then drain buffered events
+	shutdownCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := app.Listen(":"+port, fiber.ListenConfig{
+		GracefulContext: shutdownCtx,
+		ShutdownTimeout: 10 * time.Second,
+	}); err != nil {
 		logging.Fatal("fiber server exited", zap.Error(err))
 	}
 
+	logging.L().Info("draining ingest queue", zap.Int("pending", eventQueue.Len()))
+	ingest.SetCurrent(nil)
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelDrain()
+	if err := eventQueue.Close(drainCtx); err != nil {
+		logging.L().Error("ingest queue drain incomplete", zap.Error(err))
+	}
+
 	return nil
 }
 
@@ -464,16 +852,52 @@ func handleHealth(c fiber.Ctx) error {
 }
 
 var pingDatabase = func() error {
-	if database.DB == nil {
-		return fmt.Errorf("database connection not initialized")
+	return store.Current().Ping(context.Background())
+}
+
+// ensureFunctions (re)creates the SQL functions when the database's don't
+// match the ones this binary calls, by signature or body, e.g. after
+// restoring an older dump
+func ensureFunctions() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	statuses, err := database.CheckFunctions(ctx, database.DB)
+	if err != nil {
+		logging.L().Warn("failed to check SQL functions", zap.Error(err))
+		return
+	}
+	missing := database.MissingFunctions(statuses)
+	if len(missing) == 0 {
+		return
+	}
+	logging.L().Info("applying SQL functions", zap.Strings("functions", missing))
+	if err := database.ApplyFunctions(ctx, database.DB); err != nil {
+		logging.L().Error("failed to apply SQL functions", zap.Error(err))
+	}
+}
+
+// handleReady reports whether the server can answer the dashboard: the
+// database is reachable and, on PostgreSQL, has the SQL functions this
+// binary calls
+func handleReady(c fiber.Ctx) error {
+	if err := pingDatabase(); err != nil {
+		return c.Status(503).JSON(fiber.Map{"status": "unavailable", "error": "Database unreachable"})
+	}
+	if database.DB != nil && store.Current().Name() == "postgres" {
+		statuses, err := database.CheckFunctions(c.Context(), database.DB)
+		if err != nil {
+			return c.Status(503).JSON(fiber.Map{"status": "unavailable", "error": "Failed to check SQL functions"})
+		}
+		if missing := database.MissingFunctions(statuses); len(missing) > 0 {
+			return c.Status(503).JSON(fiber.Map{"status": "unavailable", "missing_functions": missing})
+		}
 	}
-	return database.DB.Ping()
+	return c.JSON(fiber.Map{"status": "ready"})
 }
 
+// handleVersion describes the build, like `kaunta version --format json`
 func handleVersion(c fiber.Ctx) error {
-	return c.JSON(fiber.Map{
-		"version": Version,
-	})
+	return c.JSON(currentBuildInfo())
 }
 
 func handleTrackerScript(trackerScript []byte) fiber.Handler {
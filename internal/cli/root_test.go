@@ -8,11 +8,13 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"runtime"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gofiber/fiber/v3"
 	"github.com/gofiber/fiber/v3/middleware/healthcheck"
+	"github.com/seuros/kaunta/internal/buildinfo"
 	"github.com/seuros/kaunta/internal/config"
 	"github.com/seuros/kaunta/internal/database"
 	"github.com/stretchr/testify/assert"
@@ -85,6 +87,70 @@ func TestHandleUpReturnsServiceUnavailableWhenPingFails(t *testing.T) {
 	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
 }
 
+func TestHandleReadyReportsMissingFunctions(t *testing.T) {
+	stubPingDatabase(t, func() error { return nil })
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	originalDB := database.DB
+	database.DB = mockDB
+	t.Cleanup(func() {
+		database.DB = originalDB
+		_ = mockDB.Close()
+	})
+
+	rows := sqlmock.NewRows([]string{"proname", "oidvectortypes", "md5"})
+	for _, fn := range database.Functions {
+		checksum, err := fn.Checksum()
+		require.NoError(t, err)
+		switch fn.Name {
+		case "get_timeseries":
+		case "get_map_data":
+			// Created by an older migration
+			rows.AddRow(fn.Name, fn.Args, "0cc175b9c0f1b6a831c399e269772661")
+		default:
+			rows.AddRow(fn.Name, fn.Args, checksum)
+		}
+	}
+	mock.ExpectQuery(`FROM pg_proc p`).WillReturnRows(rows)
+
+	app := newFiberApp("/readyz", handleReady)
+	resp := performRequest(t, app, "/readyz")
+
+	var payload map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&payload))
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, []any{"get_timeseries", "get_map_data"}, payload["missing_functions"])
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandleReadyWhenFunctionsMatch(t *testing.T) {
+	stubPingDatabase(t, func() error { return nil })
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	originalDB := database.DB
+	database.DB = mockDB
+	t.Cleanup(func() {
+		database.DB = originalDB
+		_ = mockDB.Close()
+	})
+
+	rows := sqlmock.NewRows([]string{"proname", "oidvectortypes", "md5"})
+	for _, fn := range database.Functions {
+		checksum, err := fn.Checksum()
+		require.NoError(t, err)
+		rows.AddRow(fn.Name, fn.Args, checksum)
+	}
+	mock.ExpectQuery(`FROM pg_proc p`).WillReturnRows(rows)
+
+	app := newFiberApp("/readyz", handleReady)
+	resp := performRequest(t, app, "/readyz")
+
+	var payload map[string]string
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&payload))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "ready", payload["status"])
+}
+
 func TestHandleVersionReturnsCurrentVersion(t *testing.T) {
 	originalVersion := Version
 	Version = "1.2.3"
@@ -95,9 +161,36 @@ func TestHandleVersionReturnsCurrentVersion(t *testing.T) {
 	app := newFiberApp("/api/version", handleVersion)
 	resp := performRequest(t, app, "/api/version")
 
-	var payload map[string]string
+	var payload map[string]any
 	require.NoError(t, json.NewDecoder(resp.Body).Decode(&payload))
 	assert.Equal(t, "1.2.3", payload["version"])
+	assert.Equal(t, runtime.Version(), payload["go_version"])
+	assert.Contains(t, payload, "commit")
+	assert.Contains(t, payload, "build_date")
+	assert.Contains(t, payload["features"], "sqlite")
+}
+
+func TestRunVersionJSON(t *testing.T) {
+	originalVersion := Version
+	Version = "1.2.3"
+	t.Cleanup(func() {
+		Version = originalVersion
+	})
+
+	output, err := captureOutput(t, func() error { return runVersion("json") })
+	require.NoError(t, err)
+	var info buildinfo.Info
+	require.NoError(t, json.Unmarshal([]byte(output), &info))
+	assert.Equal(t, "1.2.3", info.Version)
+	assert.Equal(t, runtime.GOARCH, info.Arch)
+	assert.True(t, info.Features["clickhouse"])
+
+	output, err = captureOutput(t, func() error { return runVersion("text") })
+	require.NoError(t, err)
+	assert.Contains(t, output, "Kaunta 1.2.3")
+	assert.Contains(t, output, "Features:   ")
+
+	assert.EqualError(t, runVersion("yaml"), "invalid format: yaml (use text or json)")
 }
 
 func TestHandleTrackerScriptSetsCachingAndSecurityHeaders(t *testing.T) {
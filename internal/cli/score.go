@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/health"
+)
+
+var (
+	scoreDays   int
+	scoreFormat string
+)
+
+var websiteScoreCmd = &cobra.Command{
+	Use:   "score <domain> [--days <N>] [--format json|table]",
+	Short: "Score a website's tracking and traffic health, with recommendations",
+	Long: `Score a website from 0 to 100 and list what to do first to improve it.
+
+The score is the weighted average of these checks:
+  Tracking setup   events still arrive (30)
+  Bounce rate      the bounce rate and how it moved since the period before (20)
+  Error pages      views of pages titled "404" or "not found", and "404" events (15)
+  Event delivery   events lost on the way to the server (15)
+  Ad-blocker loss  visitors blocking the tracker, with the baseline pixel (20)
+
+Checks without data are left out of the score. Kaunta collects neither Core
+Web Vitals nor uptime, so they aren't scored. Requires PostgreSQL.
+
+Examples:
+  kaunta website score example.com
+  kaunta website score example.com --days 30 --format json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteScore(args[0], scoreDays, scoreFormat)
+	},
+}
+
+func runWebsiteScore(domain string, days int, format string) error {
+	if days < 1 || days > 30 {
+		return fmt.Errorf("days must be between 1 and 30")
+	}
+	if format != "table" && format != "json" {
+		return fmt.Errorf("invalid format: %s (use json or table)", format)
+	}
+
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		signals, err := health.Collect(ctx, database.DB, websiteID, domain, days)
+		if err != nil {
+			return err
+		}
+		report := health.Score(domain, days, *signals, time.Now())
+
+		if format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(report)
+		}
+
+		fmt.Printf("%s: %d/100 (%s), last %d days\n\n", report.Domain, report.Score, report.Grade, report.Days)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "CHECK\tSCORE\tSTATUS\tDETAIL")
+		_, _ = fmt.Fprintln(w, "-----\t-----\t------\t------")
+		for _, c := range report.Checks {
+			score := "-"
+			if c.Score != nil {
+				score = strconv.Itoa(*c.Score)
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.Title, score, c.Status, c.Detail)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		if len(report.Recommendations) == 0 {
+			fmt.Println("\nNothing to recommend")
+			return nil
+		}
+		fmt.Println("\nRecommendations:")
+		for i, r := range report.Recommendations {
+			fmt.Printf("%2d. [%s] %s\n", i+1, r.Priority, r.Text)
+		}
+		return nil
+	})
+}
+
+func init() {
+	websiteCmd.AddCommand(websiteScoreCmd)
+
+	websiteScoreCmd.Flags().IntVarP(&scoreDays, "days", "d", 7, "Time period in days (1-30)")
+	websiteScoreCmd.Flags().StringVarP(&scoreFormat, "format", "f", "table", "Output format (json, table)")
+}
@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunWebsiteScore(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectQuery(`SELECT MAX\(created_at\) FROM website_event`).WithArgs(websiteID).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(time.Now().Add(-10 * time.Minute)))
+	summary := []string{"visitors", "pageviews", "bounce_rate", "avg_engagement"}
+	mock.ExpectQuery(`FROM events`).WillReturnRows(sqlmock.NewRows(summary).AddRow(300, 1000, 45.0, 30.0))
+	mock.ExpectQuery(`FROM events`).WillReturnRows(sqlmock.NewRows(summary).AddRow(300, 1000, 44.0, 30.0))
+	mock.ExpectQuery(`ILIKE '%404%'`).
+		WillReturnRows(sqlmock.NewRows([]string{"path", "views"}).AddRow("/old-pricing", 40))
+	mock.ExpectQuery(`FROM event_sequence s`).
+		WillReturnRows(sqlmock.NewRows([]string{"domain", "pages", "sent", "received"}))
+	mock.ExpectQuery(`FROM baseline_hit b`).
+		WillReturnRows(sqlmock.NewRows([]string{"domain", "pixel", "tracked"}))
+
+	output, err := captureOutput(t, func() error {
+		return runWebsiteScore("example.com", 7, "table")
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "example.com: 87/100 (B), last 7 days")
+	assert.Regexp(t, `Error pages\s+60\s+warn\s+40 views of error pages`, output)
+	assert.Regexp(t, `Event delivery\s+-\s+unmeasured`, output)
+	assert.Contains(t, output, " 1. [medium] Visitors hit missing pages: fix or redirect the links to /old-pricing (40).")
+	assert.Contains(t, output, " 2. [low] Add the baseline pixel")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunWebsiteScoreInvalid(t *testing.T) {
+	assert.EqualError(t, runWebsiteScore("example.com", 31, "table"), "days must be between 1 and 30")
+	assert.EqualError(t, runWebsiteScore("example.com", 7, "csv"), "invalid format: csv (use json or table)")
+}
@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/segments"
+)
+
+// Segment command flags
+var (
+	segmentFilters    []string
+	segmentListFormat string
+)
+
+var segmentCmd = &cobra.Command{
+	Use:   "segment",
+	Short: "Manage saved segments of a website",
+	Long: `Segments are named sets of the dashboard's filters, saved per website
+("german-mobile" for country=DE and device=mobile). Apply one with --segment
+on 'kaunta stats overview', 'pages' and 'breakdown', or with ?segment=<name>
+on the dashboard API, where explicit filter parameters override the
+segment's.`,
+}
+
+var segmentAddCmd = &cobra.Command{
+	Use:   "add <domain> <name> --filter key=value [--filter key=value...]",
+	Short: "Save a segment, replacing the one of the same name",
+	Long: `Save a segment of a website, replacing the one saved under the same name.
+
+Filters are key=value with the dashboard's filters: country, browser,
+device, page, bot (true or false) and dim.<name> for custom dimensions.
+
+Examples:
+  kaunta segment add example.com german-mobile --filter country=DE --filter device=mobile
+  kaunta segment add example.com pro-humans --filter dim.plan=pro --filter bot=false`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSegmentAdd(args[0], args[1], segmentFilters)
+	},
+}
+
+var segmentListCmd = &cobra.Command{
+	Use:   "list <domain> [--format json|table]",
+	Short: "List the segments of a website",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSegmentList(args[0], segmentListFormat)
+	},
+}
+
+var segmentRemoveCmd = &cobra.Command{
+	Use:   "remove <domain> <name>",
+	Short: "Delete a segment",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSegmentRemove(args[0], args[1])
+	},
+}
+
+func runSegmentAdd(domain, name string, filters []string) error {
+	if err := segments.ValidName(name); err != nil {
+		return err
+	}
+	set := make(map[string]string, len(filters))
+	for _, filter := range filters {
+		key, value, ok := strings.Cut(filter, "=")
+		if !ok || key == "" || value == "" {
+			return fmt.Errorf("invalid filter: %q (use key=value)", filter)
+		}
+		set[key] = value
+	}
+	if len(set) == 0 {
+		return errors.New("a segment needs at least one --filter")
+	}
+	if err := segments.ValidateFilters(set); err != nil {
+		return err
+	}
+
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		if err := segments.Save(ctx, database.DB, websiteID, name, set); err != nil {
+			return err
+		}
+		fmt.Printf("Segment %s saved for %s: %s\n", name, domain, formatSegmentFilters(set))
+		return nil
+	})
+}
+
+func runSegmentList(domain, format string) error {
+	if format != "table" && format != "json" {
+		return fmt.Errorf("invalid format: %s (use json or table)", format)
+	}
+
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		list, err := segments.List(ctx, database.DB, websiteID)
+		if err != nil {
+			return err
+		}
+		if format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(list)
+		}
+
+		if len(list) == 0 {
+			fmt.Printf("No segments for %s\n", domain)
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "NAME\tFILTERS\tUPDATED")
+		_, _ = fmt.Fprintln(w, "----\t-------\t-------")
+		for _, s := range list {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", s.Name, formatSegmentFilters(s.Filters), s.UpdatedAt.Format("2006-01-02 15:04"))
+		}
+		return w.Flush()
+	})
+}
+
+func runSegmentRemove(domain, name string) error {
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		err := segments.Remove(ctx, database.DB, websiteID, name)
+		if errors.Is(err, segments.ErrNotFound) {
+			return fmt.Errorf("no segment %s for %s", name, domain)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Segment %s removed from %s\n", name, domain)
+		return nil
+	})
+}
+
+// formatSegmentFilters writes filters as key=value pairs in key order
+func formatSegmentFilters(filters map[string]string) string {
+	pairs := make([]string, 0, len(filters))
+	for key, value := range filters {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
+
+func init() {
+	RootCmd.AddCommand(segmentCmd)
+	segmentCmd.AddCommand(segmentAddCmd, segmentListCmd, segmentRemoveCmd)
+
+	segmentAddCmd.Flags().StringArrayVar(&segmentFilters, "filter", nil, "Filter as key=value (repeatable)")
+
+	segmentListCmd.Flags().StringVar(&segmentListFormat, "format", "table", "Output format: json, table")
+}
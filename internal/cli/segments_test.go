@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/store"
+)
+
+func TestRunSegmentAdd(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectExec(`INSERT INTO segment`).
+		WithArgs(websiteID, "german-mobile", []byte(`{"country":"DE","device":"mobile"}`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	output, err := captureOutput(t, func() error {
+		return runSegmentAdd("example.com", "german-mobile", []string{"country=DE", "device=mobile"})
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "Segment german-mobile saved for example.com: country=DE device=mobile")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunSegmentAddInvalid(t *testing.T) {
+	assert.EqualError(t, runSegmentAdd("example.com", "german-mobile", []string{"country"}),
+		`invalid filter: "country" (use key=value)`)
+	assert.EqualError(t, runSegmentAdd("example.com", "german-mobile", nil), "a segment needs at least one --filter")
+	assert.EqualError(t, runSegmentAdd("example.com", "german-mobile", []string{"utm_source=newsletter"}),
+		"invalid filter: utm_source (use country, browser, device, page, bot or dim.<name>)")
+}
+
+func TestRunSegmentList(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+	updated := time.Date(2025, 6, 15, 9, 30, 0, 0, time.UTC)
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectQuery(`FROM segment WHERE website_id = \$1 ORDER BY name`).WithArgs(websiteID).
+		WillReturnRows(sqlmock.NewRows([]string{"website_id", "name", "filters", "created_at", "updated_at"}).
+			AddRow(websiteID, "german-mobile", []byte(`{"device":"mobile","country":"DE"}`), updated, updated))
+
+	output, err := captureOutput(t, func() error {
+		return runSegmentList("example.com", "table")
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "german-mobile")
+	assert.Contains(t, output, "country=DE device=mobile")
+	assert.Contains(t, output, "2025-06-15 09:30")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunSegmentRemoveUnknown(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectExec(`DELETE FROM segment`).WithArgs(websiteID, "nope").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.EqualError(t, runSegmentRemove("example.com", "nope"), "no segment nope for example.com")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunStatsOverviewSegment(t *testing.T) {
+	stubDB(t)
+	stubConnectClose(t)
+
+	stubWebsiteIDLookup(t, func(ctx context.Context, domain string) (string, error) {
+		return "site-123", nil
+	})
+	original := getSegmentFiltersFn
+	getSegmentFiltersFn = func(ctx context.Context, db *sql.DB, websiteID string, segment string) (store.Filters, error) {
+		assert.Equal(t, "german-mobile", segment)
+		return store.Filters{Country: "DE", Device: "mobile"}, nil
+	}
+	t.Cleanup(func() { getSegmentFiltersFn = original })
+
+	stubOverviewFetcher(t, func(ctx context.Context, db *sql.DB, websiteID string, days int, filters store.Filters, compare bool) (*OverviewStats, error) {
+		assert.Equal(t, store.Filters{Country: "DE", Device: "mobile"}, filters)
+		return &OverviewStats{TotalVisitors: 3}, nil
+	})
+
+	_, err := captureOutput(t, func() error {
+		return runStatsOverview("example.com", 7, dateRange{}, "german-mobile", "json", false, false)
+	})
+	require.NoError(t, err)
+}
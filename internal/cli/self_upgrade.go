@@ -13,8 +13,14 @@ import (
 	"github.com/blang/semver"
 	"github.com/rhysd/go-github-selfupdate/selfupdate"
 	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/offline"
 )
 
+// selfUpgradeAvailable reports whether --self-upgrade is compiled in
+const selfUpgradeAvailable = true
+
 var (
 	selfUpgradeRequested bool
 	selfUpgradeCheckOnly bool
@@ -59,6 +65,11 @@ func handleSelfUpgradeFlags() error {
 		return nil
 	}
 
+	// Config is not loaded yet at this point; read it just for the offline switch
+	if cfg, err := config.Load(); err == nil && cfg.Offline {
+		offline.Set(true)
+	}
+
 	if err := runSelfUpgrade(selfUpgradeCheckOnly, selfUpgradeAutoYes); err != nil {
 		return err
 	}
@@ -80,6 +91,10 @@ func runSelfUpgrade(checkOnly, autoYes bool) error {
 
 	fmt.Printf("Checking current version... v%s\n", current)
 
+	if err := offline.Check("self-upgrade version check", "api.github.com/repos/seuros/kaunta/releases"); err != nil {
+		return fmt.Errorf("cannot check for updates: %w", err)
+	}
+
 	fmt.Print("Checking latest released version... ")
 	latest, found, err := selfupdate.DetectLatest("seuros/kaunta")
 	if err != nil {
@@ -2,6 +2,10 @@
 
 package cli
 
+// selfUpgradeAvailable reports whether --self-upgrade is compiled in; the
+// image is upgraded by pulling a new one
+const selfUpgradeAvailable = false
+
 func setupSelfUpgrade() {}
 
 func hideSelfUpgradeFlagsIfDevBuild() {}
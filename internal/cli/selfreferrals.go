@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/database"
+)
+
+var selfReferralsExcept []string
+
+var websiteSelfReferralsCmd = &cobra.Command{
+	Use:   "self-referrals <domain> <exclude|keep> [--except domains]",
+	Short: "Drop or keep referrals from a website's own domains",
+	Long: `Choose what happens to referrers from the website's own domain and allowed
+domains, and their subdomains (www.example.com, blog.example.com).
+
+exclude (the default) drops them at ingestion, so visitors moving between the
+website's domains don't show the site as its own top referrer; page URLs
+tagged with one of them as utm_source lose their utm_* parameters. keep
+records them as any other referrer.
+
+--except lists domains that stay referrers, for intentional cross-domain
+tracking (an empty value clears the list).
+
+Examples:
+  kaunta website self-referrals example.com exclude --except shop.example.com
+  kaunta website self-referrals example.com keep`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var except []string
+		if cmd.Flags().Changed("except") {
+			except = normalizeDomains(selfReferralsExcept)
+		}
+		return runWebsiteSelfReferrals(args[0], args[1], except)
+	},
+}
+
+// runWebsiteSelfReferrals sets the website's self-referral exclusion; a nil
+// except leaves its exceptions as they are
+func runWebsiteSelfReferrals(domain, value string, except []string) error {
+	var exclude bool
+	switch strings.ToLower(value) {
+	case "exclude":
+		exclude = true
+	case "keep":
+		exclude = false
+	default:
+		return fmt.Errorf("invalid value: %s (use exclude or keep)", value)
+	}
+
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		var err error
+		if except == nil {
+			_, err = database.DB.ExecContext(ctx, `
+				UPDATE website SET exclude_self_referrals = $2, updated_at = NOW()
+				WHERE website_id = $1`, websiteID, exclude)
+		} else {
+			encoded, _ := json.Marshal(except)
+			_, err = database.DB.ExecContext(ctx, `
+				UPDATE website SET exclude_self_referrals = $2, referral_domains = $3::jsonb, updated_at = NOW()
+				WHERE website_id = $1`, websiteID, exclude, string(encoded))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update self-referrals: %w", err)
+		}
+
+		if exclude {
+			fmt.Printf("%s now drops referrals from its own domains\n", domain)
+		} else {
+			fmt.Printf("%s now keeps referrals from its own domains\n", domain)
+		}
+		if len(except) > 0 {
+			fmt.Printf("Kept as referrers: %s\n", strings.Join(except, ", "))
+		}
+		return nil
+	})
+}
+
+// normalizeDomains lowercases and trims domains, dropping empty ones
+func normalizeDomains(domains []string) []string {
+	normalized := []string{}
+	for _, d := range domains {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			normalized = append(normalized, d)
+		}
+	}
+	return normalized
+}
+
+func init() {
+	websiteCmd.AddCommand(websiteSelfReferralsCmd)
+	websiteSelfReferralsCmd.Flags().StringSliceVar(&selfReferralsExcept, "except", nil, "Domains kept as referrers (comma-separated)")
+}
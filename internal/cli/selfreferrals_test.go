@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunWebsiteSelfReferrals(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectExec(`UPDATE website SET exclude_self_referrals = \$2, referral_domains = \$3::jsonb`).
+		WithArgs(websiteID, true, `["shop.example.com"]`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	output, err := captureOutput(t, func() error {
+		return runWebsiteSelfReferrals("example.com", "exclude", normalizeDomains([]string{" Shop.Example.com", ""}))
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "example.com now drops referrals from its own domains")
+	assert.Contains(t, output, "Kept as referrers: shop.example.com")
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectExec(`UPDATE website SET exclude_self_referrals = \$2, updated_at`).
+		WithArgs(websiteID, false).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	output, err = captureOutput(t, func() error { return runWebsiteSelfReferrals("example.com", "keep", nil) })
+	require.NoError(t, err)
+	assert.Contains(t, output, "example.com now keeps referrals from its own domains")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunWebsiteSelfReferralsRejectsBadValue(t *testing.T) {
+	err := runWebsiteSelfReferrals("example.com", "maybe", nil)
+	assert.ErrorContains(t, err, "invalid value")
+}
@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/stats"
+)
+
+// Sessions command flags
+var (
+	sessionsDays    int
+	sessionsTop     int
+	sessionsSession string
+	sessionsFormat  string
+)
+
+var statsSessionsCmd = &cobra.Command{
+	Use:   "sessions <website-domain> [--days <N>] [--top <N>] [--session <id>] [--format json|table]",
+	Short: "List recent sessions and follow a visitor's journey",
+	Long: `List a website's recent sessions, the most recently active first: the
+visitor's country, device and browser, the page they entered on, their
+pageviews and custom events, and how long they stayed (from the first to the
+last event).
+
+With --session, shows that session's journey instead: every pageview and
+custom event in order.
+
+Options:
+  --days N        Period in days (1-90, default 7)
+  --top N         Sessions to show (1-200, default 20)
+  --session ID    Session to show the journey of
+  --format        Output format: json, table (default table)
+
+Examples:
+  kaunta stats sessions example.com
+  kaunta stats sessions example.com --days 1 --top 50
+  kaunta stats sessions example.com --session 2f1c...`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runStatsSessions(args[0], sessionsDays, sessionsTop, sessionsSession, sessionsFormat)
+	},
+}
+
+func runStatsSessions(domain string, days, top int, session, format string) error {
+	if days < 1 || days > 90 {
+		return fmt.Errorf("days must be between 1 and 90")
+	}
+	if top < 1 || top > 200 {
+		return fmt.Errorf("top must be between 1 and 200")
+	}
+	if format != "table" && format != "json" {
+		return fmt.Errorf("invalid format: %s (use json or table)", format)
+	}
+	var sessionID uuid.UUID
+	if session != "" {
+		var err error
+		if sessionID, err = uuid.Parse(session); err != nil {
+			return fmt.Errorf("invalid session ID: %s", session)
+		}
+	}
+
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		if session != "" {
+			journey, err := stats.GetJourney(ctx, database.DB, websiteID, sessionID)
+			if errors.Is(err, stats.ErrSessionNotFound) {
+				return fmt.Errorf("session %s not found on %s", sessionID, domain)
+			}
+			if err != nil {
+				return err
+			}
+			if format == "json" {
+				return printSessionsJSON(journey)
+			}
+			return printJourney(journey)
+		}
+
+		sessions, err := stats.GetSessions(ctx, database.DB, websiteID, days, top)
+		if err != nil {
+			return err
+		}
+		if format == "json" {
+			return printSessionsJSON(sessions)
+		}
+
+		fmt.Printf("Sessions on %s (last %d days)\n\n", domain, days)
+		if len(sessions) == 0 {
+			fmt.Println("No sessions")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "SESSION\tSTARTED\tCOUNTRY\tDEVICE\tBROWSER\tENTRY PAGE\tPAGEVIEWS\tEVENTS\tDURATION")
+		_, _ = fmt.Fprintln(w, "-------\t-------\t-------\t------\t-------\t----------\t---------\t------\t--------")
+		l := outputLocale
+		for _, s := range sessions {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				s.ID, l.DateTime(s.StartedAt.UTC()), orDash(s.Country), orDash(s.Device),
+				orDash(s.Browser), s.EntryPage, l.Int(s.Pageviews), l.Int(s.Events), sessionDuration(s.Duration))
+		}
+		return w.Flush()
+	})
+}
+
+func printSessionsJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func printJourney(j *stats.Journey) error {
+	fmt.Printf("Session %s\n", j.ID)
+	fmt.Printf("Country: %s  Device: %s  Browser: %s\n", orDash(j.Country), orDash(j.Device), orDash(j.Browser))
+	fmt.Printf("%s pageviews, %s events over %s\n\n", outputLocale.Int(j.Pageviews), outputLocale.Int(j.Events), sessionDuration(j.Duration))
+	if len(j.Steps) == 0 {
+		fmt.Println("No events")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "TIME\t+\tTYPE\tPATH\tDETAIL")
+	_, _ = fmt.Fprintln(w, "----\t-\t----\t----\t------")
+	for _, step := range j.Steps {
+		detail := step.Title
+		if step.Type == "event" {
+			detail = step.Name
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", step.At.UTC().Format("15:04:05"),
+			sessionDuration(step.At.Sub(j.StartedAt).Seconds()), step.Type, step.Path, detail)
+	}
+	return w.Flush()
+}
+
+// sessionDuration formats seconds as 1m30s
+func sessionDuration(seconds float64) string {
+	return (time.Duration(seconds) * time.Second).String()
+}
+
+func init() {
+	statsCmd.AddCommand(statsSessionsCmd)
+
+	statsSessionsCmd.Flags().IntVarP(&sessionsDays, "days", "d", 7, "Period in days (1-90)")
+	statsSessionsCmd.Flags().IntVarP(&sessionsTop, "top", "t", 20, "Sessions to show (1-200)")
+	statsSessionsCmd.Flags().StringVar(&sessionsSession, "session", "", "Session to show the journey of")
+	statsSessionsCmd.Flags().StringVarP(&sessionsFormat, "format", "f", "table", "Output format (json, table)")
+}
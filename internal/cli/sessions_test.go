@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunStatsSessions(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID, sessionID := uuid.New(), uuid.New()
+	started := time.Date(2025, 6, 15, 9, 0, 0, 0, time.UTC)
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectQuery(`JOIN session s`).WithArgs(websiteID, 7, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"session_id", "country", "device", "browser", "entry_page",
+			"pageviews", "events", "started_at", "duration"}).
+			AddRow(sessionID, "FR", "desktop", "", "/pricing", 4, 1, started, 95.0))
+
+	output, err := captureOutput(t, func() error {
+		return runStatsSessions("example.com", 7, 20, "", "table")
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "Sessions on example.com (last 7 days)")
+	assert.Regexp(t, sessionID.String()+`\s+2025-06-15 09:00\s+FR\s+desktop\s+-\s+/pricing\s+4\s+1\s+1m35s`, output)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunStatsSessionsJourney(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID, sessionID := uuid.New(), uuid.New()
+	started := time.Date(2025, 6, 15, 9, 0, 0, 0, time.UTC)
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectQuery(`FROM session`).WithArgs(sessionID, websiteID).
+		WillReturnRows(sqlmock.NewRows([]string{"country", "device", "browser"}).AddRow("FR", "mobile", "safari"))
+	mock.ExpectQuery(`WHERE e.session_id = \$1`).WithArgs(sessionID, websiteID).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "event_type", "url_path", "page_title", "event_name"}).
+			AddRow(started, 1, "/", "Home", "").
+			AddRow(started.Add(30*time.Second), 2, "/", "", "signup"))
+
+	output, err := captureOutput(t, func() error {
+		return runStatsSessions("example.com", 7, 20, sessionID.String(), "table")
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "1 pageviews, 1 events over 30s")
+	assert.Regexp(t, `09:00:00\s+0s\s+pageview\s+/\s+Home`, output)
+	assert.Regexp(t, `09:00:30\s+30s\s+event\s+/\s+signup`, output)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunStatsSessionsValidation(t *testing.T) {
+	assert.EqualError(t, runStatsSessions("example.com", 91, 20, "", "table"), "days must be between 1 and 90")
+	assert.EqualError(t, runStatsSessions("example.com", 7, 20, "nope", "table"), "invalid session ID: nope")
+	assert.EqualError(t, runStatsSessions("example.com", 7, 20, "", "csv"), "invalid format: csv (use json or table)")
+}
@@ -0,0 +1,207 @@
+package cli
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/share"
+)
+
+var (
+	sharePassword   string
+	shareNoPassword bool
+	shareExpires    string
+)
+
+var websiteShareCmd = &cobra.Command{
+	Use:   "share",
+	Short: "Manage a website's public share link",
+	Long: `Publish a website's statistics under a share ID, readable without a login:
+
+  /share/<share-id>                         read-only dashboard
+  /share/<share-id>/top-pages               top pages feed (RSS)
+  /share/<share-id>/top-pages?format=json   top pages feed (JSON Feed)
+
+A share link can ask for a password and can expire. Logged-in users can
+always read the feed at /api/feeds/top-pages/<website-id>.`,
+}
+
+var websiteShareEnableCmd = &cobra.Command{
+	Use:   "enable <domain> [--password <password> | --no-password] [--expires <when>]",
+	Short: "Share a website, or change its share link's password and expiry",
+	Long: fmt.Sprintf(`Share a website under a share ID. Enabling a shared website again keeps
+its share ID and changes only the settings given.
+
+--password protects the link (at least %d characters); visitors enter it
+once a week. --expires ends the link after a duration (72h, 30d), after a
+day (YYYY-MM-DD, local time) or never.
+
+Examples:
+  kaunta website share enable example.com
+  kaunta website share enable example.com --password 'board-2025' --expires 30d
+  kaunta website share enable example.com --no-password --expires never`, share.MinPassword),
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var password *string
+		switch {
+		case cmd.Flags().Changed("password") && shareNoPassword:
+			return fmt.Errorf("use either --password or --no-password")
+		case cmd.Flags().Changed("password"):
+			password = &sharePassword
+		case shareNoPassword:
+			password = new(string)
+		}
+		var expires *string
+		if cmd.Flags().Changed("expires") {
+			expires = &shareExpires
+		}
+		return runWebsiteShareEnable(args[0], password, expires)
+	},
+}
+
+var websiteShareDisableCmd = &cobra.Command{
+	Use:   "disable <domain>",
+	Short: "Stop sharing a website",
+	Long: `Stop sharing a website. Its share links stop working at once, and its
+password and expiry are forgotten.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteShareDisable(args[0])
+	},
+}
+
+var websiteShareRotateCmd = &cobra.Command{
+	Use:   "rotate <domain>",
+	Short: "Replace a website's share ID",
+	Long: `Replace the share ID of a shared website, keeping its password and expiry.
+The old links stop working, and visitors who entered the password must enter
+it again.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWebsiteShareRotate(args[0])
+	},
+}
+
+// runWebsiteShareEnable shares a website. A nil password or expires keeps
+// the current setting (none for a website that wasn't shared); an empty
+// password removes it.
+func runWebsiteShareEnable(domain string, password, expires *string) error {
+	var hash interface{}
+	if password != nil && *password != "" {
+		h, err := share.HashPassword(*password)
+		if err != nil {
+			return err
+		}
+		hash = h
+	}
+	var expiresAt interface{}
+	if expires != nil {
+		at, err := share.ParseExpiry(*expires, time.Now())
+		if err != nil {
+			return err
+		}
+		if at != nil {
+			expiresAt = *at
+		}
+	}
+
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		newID, err := newShareID()
+		if err != nil {
+			return err
+		}
+
+		// SET sees the row as it was, so a website shared for the first
+		// time starts without the settings of an earlier share
+		var shareID string
+		var protected bool
+		var until sql.NullTime
+		err = database.DB.QueryRowContext(ctx, `
+			UPDATE website SET
+				share_id = COALESCE(share_id, $2),
+				share_password_hash = CASE WHEN $3::boolean THEN $4 WHEN share_id IS NULL THEN NULL ELSE share_password_hash END,
+				share_expires_at = CASE WHEN $5::boolean THEN $6::timestamptz WHEN share_id IS NULL THEN NULL ELSE share_expires_at END,
+				updated_at = NOW()
+			WHERE website_id = $1
+			RETURNING share_id, share_password_hash IS NOT NULL, share_expires_at
+		`, websiteID, newID, password != nil, hash, expires != nil, expiresAt).Scan(&shareID, &protected, &until)
+		if err != nil {
+			return fmt.Errorf("failed to share website: %w", err)
+		}
+
+		fmt.Printf("%s is shared at /share/%s\n", domain, shareID)
+		fmt.Printf("Top pages feed: /share/%s/top-pages (add ?format=json for JSON Feed)\n", shareID)
+		if protected {
+			fmt.Println("Password: required")
+		} else {
+			fmt.Println("Password: none")
+		}
+		if until.Valid {
+			fmt.Printf("Expires: %s\n", until.Time.Local().Format("2006-01-02 15:04"))
+		} else {
+			fmt.Println("Expires: never")
+		}
+		return nil
+	})
+}
+
+func runWebsiteShareDisable(domain string) error {
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		_, err := database.DB.ExecContext(ctx, `
+			UPDATE website SET share_id = NULL, share_password_hash = NULL, share_expires_at = NULL, updated_at = NOW()
+			WHERE website_id = $1`, websiteID)
+		if err != nil {
+			return fmt.Errorf("failed to stop sharing website: %w", err)
+		}
+		fmt.Printf("%s is no longer shared\n", domain)
+		return nil
+	})
+}
+
+func runWebsiteShareRotate(domain string) error {
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		shareID, err := newShareID()
+		if err != nil {
+			return err
+		}
+		res, err := database.DB.ExecContext(ctx, `
+			UPDATE website SET share_id = $2, updated_at = NOW()
+			WHERE website_id = $1 AND share_id IS NOT NULL`, websiteID, shareID)
+		if err != nil {
+			return fmt.Errorf("failed to rotate share ID: %w", err)
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return fmt.Errorf("%s is not shared (share it with: kaunta website share enable %s)", domain, domain)
+		}
+		fmt.Printf("%s is now shared at /share/%s; the old links no longer work\n", domain, shareID)
+		return nil
+	})
+}
+
+// newShareID returns a random, URL-safe share ID
+func newShareID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate share ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func init() {
+	websiteCmd.AddCommand(websiteShareCmd)
+	websiteShareCmd.AddCommand(websiteShareEnableCmd, websiteShareDisableCmd, websiteShareRotateCmd)
+
+	websiteShareEnableCmd.Flags().StringVar(&sharePassword, "password", "", "Password visitors must enter")
+	websiteShareEnableCmd.Flags().BoolVar(&shareNoPassword, "no-password", false, "Remove the password")
+	websiteShareEnableCmd.Flags().StringVar(&shareExpires, "expires", "", "When the link ends: a duration (72h, 30d), YYYY-MM-DD or never")
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunWebsiteShareEnable(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+	until := time.Date(2025, 7, 1, 0, 0, 0, 0, time.Local)
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectQuery(`UPDATE website SET\s+share_id = COALESCE\(share_id, \$2\)`).
+		WithArgs(websiteID, sqlmock.AnyArg(), true, sqlmock.AnyArg(), true, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"share_id", "protected", "share_expires_at"}).
+			AddRow("abc123", true, until))
+
+	password, expires := "board-2025", "30d"
+	output, err := captureOutput(t, func() error { return runWebsiteShareEnable("example.com", &password, &expires) })
+	require.NoError(t, err)
+	assert.Contains(t, output, "example.com is shared at /share/abc123")
+	assert.Contains(t, output, "Top pages feed: /share/abc123/top-pages")
+	assert.Contains(t, output, "Password: required")
+	assert.Contains(t, output, "Expires: 2025-07-01 00:00")
+
+	// Settings not given are kept
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectQuery(`UPDATE website SET`).
+		WithArgs(websiteID, sqlmock.AnyArg(), false, nil, false, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"share_id", "protected", "share_expires_at"}).
+			AddRow("abc123", false, nil))
+
+	output, err = captureOutput(t, func() error { return runWebsiteShareEnable("example.com", nil, nil) })
+	require.NoError(t, err)
+	assert.Contains(t, output, "Password: none")
+	assert.Contains(t, output, "Expires: never")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunWebsiteShareEnableInvalid(t *testing.T) {
+	short, soon := "short", "soon"
+	assert.EqualError(t, runWebsiteShareEnable("example.com", &short, nil),
+		"a share password needs at least 8 characters")
+	assert.EqualError(t, runWebsiteShareEnable("example.com", nil, &soon),
+		`invalid expiry: "soon" (use a duration like 72h or 30d, YYYY-MM-DD or never)`)
+}
+
+func TestRunWebsiteShareDisableAndRotate(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectExec(`UPDATE website SET share_id = \$2`).WithArgs(websiteID, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	output, err := captureOutput(t, func() error { return runWebsiteShareRotate("example.com") })
+	require.NoError(t, err)
+	assert.Regexp(t, `example.com is now shared at /share/[0-9a-f]{24}`, output)
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectExec(`UPDATE website SET share_id = NULL, share_password_hash = NULL`).WithArgs(websiteID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	output, err = captureOutput(t, func() error { return runWebsiteShareDisable("example.com") })
+	require.NoError(t, err)
+	assert.Contains(t, output, "example.com is no longer shared")
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectExec(`UPDATE website SET share_id = \$2`).WithArgs(websiteID, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.EqualError(t, runWebsiteShareRotate("example.com"),
+		"example.com is not shared (share it with: kaunta website share enable example.com)")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,269 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/shortlink"
+)
+
+var (
+	linkSlug    string
+	linkWebsite string
+	linkDays    int
+	linkFormat  string
+	linkQROut   string
+	linkQRBase  string
+	linkQRSize  int
+)
+
+var linkCmd = &cobra.Command{
+	Use:   "link",
+	Short: "Manage short links",
+	Long: `Short links redirect /l/<slug> to a page of a website and record each click
+as a link_click event of that website, with the slug as a property and the
+visitor's referrer, country and device. Short links require PostgreSQL.`,
+}
+
+var linkCreateCmd = &cobra.Command{
+	Use:   "create <url> [--slug slug] [--website domain]",
+	Short: "Create a short link",
+	Long: `Create a short link to a URL. The link belongs to the website of the URL's
+host unless --website is given, and gets a random slug unless --slug is.
+
+Examples:
+  kaunta link create https://example.com/launch --slug spring
+  kaunta link create https://shop.example.com/sale --website example.com`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runLinkCreate(args[0], linkSlug, linkWebsite)
+	},
+}
+
+var linkListCmd = &cobra.Command{
+	Use:   "list [--website domain] [--days N] [--format table|json]",
+	Short: "List short links and their clicks",
+	Long: `List short links, newest first, with their clicks and the visitors who
+clicked over a period. Clicks aren't counted while event properties are
+encrypted at rest.
+
+Examples:
+  kaunta link list
+  kaunta link list --website example.com --days 7`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runLinkList(linkWebsite, linkDays, linkFormat)
+	},
+}
+
+var linkDeleteCmd = &cobra.Command{
+	Use:   "delete <slug>",
+	Short: "Delete a short link",
+	Long:  `Delete a short link. Its recorded clicks are kept.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runLinkDelete(args[0])
+	},
+}
+
+var linkQRCmd = &cobra.Command{
+	Use:   "qr <slug> [--out file.png] [--base https://stats.example.com] [--size 256]",
+	Short: "Save a QR code of a short link",
+	Long: `Save a PNG QR code of a short link, for posters, flyers and packaging: scans
+are recorded as clicks like any visit of the link. The code holds the
+address of the link on the Kaunta server, --base, which defaults to
+https:// and the first of trusted_origins. The server also serves the code
+at /l/<slug>/qr.png (?size=).
+
+Examples:
+  kaunta link qr spring --out qr.png
+  kaunta link qr spring --base https://stats.example.com --size 1024`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runLinkQR(args[0], linkQROut, linkQRBase, linkQRSize)
+	},
+}
+
+func runLinkCreate(target, slug, website string) error {
+	if err := shortlink.ValidTarget(target); err != nil {
+		return err
+	}
+	if slug != "" {
+		if err := shortlink.ValidSlug(slug); err != nil {
+			return err
+		}
+	}
+	if website == "" {
+		website = shortlink.Host(target)
+	}
+
+	return withWebsite(website, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		link, err := shortlink.Create(ctx, database.DB, websiteID, target, slug)
+		if errors.Is(err, shortlink.ErrSlugTaken) {
+			return fmt.Errorf("slug %q is already in use", slug)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Short link %s%s → %s (%s)\n", shortlink.Path, link.Slug, link.Target, domain)
+		return nil
+	})
+}
+
+func runLinkList(website string, days int, format string) error {
+	if days < 1 || days > 365 {
+		return fmt.Errorf("days must be between 1 and 365")
+	}
+	if format != "table" && format != "json" {
+		return fmt.Errorf("invalid format: %s (use table or json)", format)
+	}
+
+	list := func(ctx context.Context, websiteID uuid.UUID) error {
+		links, err := shortlink.List(ctx, database.DB, websiteID, days)
+		if err != nil {
+			return err
+		}
+		if format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(links)
+		}
+		if len(links) == 0 {
+			fmt.Println("No short links")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintf(w, "SLUG\tTARGET\tWEBSITE\tCLICKS (%dd)\tVISITORS\tCREATED\n", days)
+		_, _ = fmt.Fprintln(w, "----\t------\t-------\t-----------\t--------\t-------")
+		for _, l := range links {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\n", l.Slug, l.Target, l.Domain, l.Clicks, l.Visitors,
+				l.CreatedAt.Format("2006-01-02"))
+		}
+		return w.Flush()
+	}
+
+	if website != "" {
+		return withWebsite(website, func(ctx context.Context, websiteID uuid.UUID, _ string) error {
+			return list(ctx, websiteID)
+		})
+	}
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return list(ctx, uuid.Nil)
+}
+
+func runLinkDelete(slug string) error {
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	err = shortlink.Delete(ctx, database.DB, slug)
+	if errors.Is(err, shortlink.ErrNotFound) {
+		return fmt.Errorf("short link %q not found", slug)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Short link %s%s deleted\n", shortlink.Path, slug)
+	return nil
+}
+
+func runLinkQR(slug, out, base string, size int) error {
+	if size < shortlink.MinQRSize || size > shortlink.MaxQRSize {
+		return fmt.Errorf("size must be between %d and %d", shortlink.MinQRSize, shortlink.MaxQRSize)
+	}
+	if base == "" {
+		base = defaultLinkBase()
+	}
+	if base == "" {
+		return fmt.Errorf("no server address for the link: pass --base https://stats.example.com")
+	}
+	if !strings.Contains(base, "://") {
+		base = "https://" + base
+	}
+	if u, err := url.Parse(base); err != nil || u.Host == "" {
+		return fmt.Errorf("invalid base URL: %q", base)
+	}
+	if out == "" {
+		out = slug + "-qr.png"
+	}
+
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	link, err := shortlink.Resolve(ctx, database.DB, slug)
+	if errors.Is(err, shortlink.ErrNotFound) {
+		return fmt.Errorf("short link %q not found", slug)
+	}
+	if err != nil {
+		return err
+	}
+	address := shortlink.URL(base, link.Slug)
+	png, err := shortlink.QR(address, size)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(out, png, 0o644); err != nil {
+		return fmt.Errorf("failed to write QR code: %w", err)
+	}
+	fmt.Printf("QR code of %s → %s saved to %s\n", address, link.Target, out)
+	return nil
+}
+
+// defaultLinkBase is the address of the server from the first trusted
+// origin, or empty when it is only served locally
+func defaultLinkBase() string {
+	cfg, err := config.Load()
+	if err != nil || len(cfg.TrustedOrigins) == 0 {
+		return ""
+	}
+	origin := cfg.TrustedOrigins[0]
+	if origin == "" || origin == "localhost" {
+		return ""
+	}
+	return origin
+}
+
+func init() {
+	RootCmd.AddCommand(linkCmd)
+	linkCmd.AddCommand(linkCreateCmd, linkListCmd, linkDeleteCmd, linkQRCmd)
+
+	linkCreateCmd.Flags().StringVar(&linkSlug, "slug", "", "Slug of the link (default: random)")
+	linkCreateCmd.Flags().StringVar(&linkWebsite, "website", "", "Website the link belongs to (default: the URL's host)")
+	linkListCmd.Flags().StringVar(&linkWebsite, "website", "", "Only list the links of a website")
+	linkListCmd.Flags().IntVar(&linkDays, "days", 30, "Period of the clicks in days (1-365)")
+	linkListCmd.Flags().StringVar(&linkFormat, "format", "table", "Output format: table or json")
+	linkQRCmd.Flags().StringVar(&linkQROut, "out", "", "File to save the PNG to (default: <slug>-qr.png)")
+	linkQRCmd.Flags().StringVar(&linkQRBase, "base", "", "Address of the Kaunta server (default: first trusted origin)")
+	linkQRCmd.Flags().IntVar(&linkQRSize, "size", shortlink.DefaultQRSize, "Width of the image in pixels")
+}
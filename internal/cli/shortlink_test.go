@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunLinkCreate(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	// The website is the URL's host
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectQuery(`INSERT INTO short_link`).WithArgs("spring", websiteID, "https://www.example.com/launch").
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(time.Now()))
+
+	output, err := captureOutput(t, func() error {
+		return runLinkCreate("https://www.example.com/launch", "spring", "")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Short link /l/spring → https://www.example.com/launch (example.com)\n", output)
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectQuery(`INSERT INTO short_link`).WithArgs("spring", websiteID, "https://shop.example.net/").
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}))
+	_, err = captureOutput(t, func() error {
+		return runLinkCreate("https://shop.example.net/", "spring", "example.com")
+	})
+	assert.EqualError(t, err, `slug "spring" is already in use`)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunLinkCreateRejectsBadValues(t *testing.T) {
+	assert.ErrorContains(t, runLinkCreate("/launch", "", ""), "invalid target URL")
+	assert.ErrorContains(t, runLinkCreate("https://example.com", "a/b", ""), "invalid slug")
+	assert.ErrorContains(t, runLinkList("", 0, "table"), "days")
+	assert.ErrorContains(t, runLinkList("", 30, "csv"), "invalid format")
+}
+
+func TestRunLinkList(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	mock.ExpectQuery(`FROM short_link l`).WithArgs(nil, 30, "link_click").
+		WillReturnRows(sqlmock.NewRows([]string{"slug", "website_id", "domain", "target_url", "created_at", "clicks", "visitors"}).
+			AddRow("spring", websiteID, "example.com", "https://example.com/launch", time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC), 12, 9))
+
+	output, err := captureOutput(t, func() error { return runLinkList("", 30, "table") })
+	require.NoError(t, err)
+	assert.Contains(t, output, "CLICKS (30d)")
+	assert.Regexp(t, `spring\s+https://example.com/launch\s+example.com\s+12\s+9\s+2026-03-01`, output)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunLinkDelete(t *testing.T) {
+	mock := mockJobsDB(t)
+
+	mock.ExpectExec(`DELETE FROM short_link`).WithArgs("spring").WillReturnResult(sqlmock.NewResult(0, 1))
+	output, err := captureOutput(t, func() error { return runLinkDelete("spring") })
+	require.NoError(t, err)
+	assert.Equal(t, "Short link /l/spring deleted\n", output)
+
+	mock.ExpectExec(`DELETE FROM short_link`).WithArgs("autumn").WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.EqualError(t, runLinkDelete("autumn"), `short link "autumn" not found`)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunLinkQR(t *testing.T) {
+	mock := mockJobsDB(t)
+	out := filepath.Join(t.TempDir(), "qr.png")
+
+	mock.ExpectQuery(`FROM short_link l`).WithArgs("spring").
+		WillReturnRows(sqlmock.NewRows([]string{"slug", "website_id", "domain", "target_url", "created_at"}).
+			AddRow("spring", uuid.New(), "example.com", "https://example.com/launch", time.Now()))
+
+	output, err := captureOutput(t, func() error { return runLinkQR("spring", out, "stats.example.com/", 512) })
+	require.NoError(t, err)
+	assert.Equal(t, "QR code of https://stats.example.com/l/spring → https://example.com/launch saved to "+out+"\n", output)
+
+	f, err := os.Open(out)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+	img, err := png.Decode(f)
+	require.NoError(t, err)
+	assert.Equal(t, 512, img.Bounds().Dx())
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	assert.ErrorContains(t, runLinkQR("spring", out, "https://stats.example.com", 10), "size must be between")
+}
@@ -0,0 +1,271 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/stats"
+)
+
+// diffPool is how many pages and referrers of each side are compared for
+// top movers
+const diffPool = 500
+
+// Diff command flags
+var (
+	diffPeriodA string
+	diffPeriodB string
+	diffSiteB   string
+	diffTop     int
+	diffFormat  string
+)
+
+var getStatsDiffFn = statsDiff
+
+var statsDiffCmd = &cobra.Command{
+	Use:   "diff <website-domain> --period-a <period> [--period-b <period>] [--site-b <domain>] [--top <N>] [--format json|table]",
+	Short: "Compare two periods or two websites",
+	Long: `Compare key metrics side by side, between two periods of a website or
+between two websites, along with the pages and referrers gaining and losing
+the most pageviews.
+
+Periods are a month (2025-06), a day (2025-06-15) or a range of days with
+both ends included (2025-06-01..2025-06-15), in UTC.
+
+Options:
+  --period-a    Period of the website (required)
+  --period-b    Period to compare with (default: --period-a, with --site-b)
+  --site-b      Website to compare with (default: the same website)
+  --top N       Gainers and losers to show (1-50, default 5)
+  --format      Output format: json, table (default table)
+
+Examples:
+  kaunta stats diff mysite.com --period-a 2025-05 --period-b 2025-06
+  kaunta stats diff mysite.com --period-a 2025-06 --site-b othersite.com
+  kaunta stats diff mysite.com --period-a 2025-06-01..2025-06-07 --period-b 2025-06-08..2025-06-14`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runStatsDiff(args[0], diffPeriodA, diffSiteB, diffPeriodB, diffTop, diffFormat)
+	},
+}
+
+// DiffSide is one side of a comparison
+type DiffSide struct {
+	Website string `json:"website"`
+	Period  string `json:"period"`
+	stats.Summary
+}
+
+// StatsDiff compares two websites or periods
+type StatsDiff struct {
+	A         DiffSide        `json:"a"`
+	B         DiffSide        `json:"b"`
+	Pages     stats.MoverList `json:"pages"`
+	Referrers stats.MoverList `json:"referrers"`
+}
+
+// diffTarget is a website and period to compare
+type diffTarget struct {
+	websiteID string
+	domain    string
+	period    stats.Period
+}
+
+func runStatsDiff(domain, periodA, siteB, periodB string, top int, format string) error {
+	if periodA == "" {
+		return fmt.Errorf("--period-a is required")
+	}
+	if periodB == "" {
+		if siteB == "" {
+			return fmt.Errorf("--period-b or --site-b is required")
+		}
+		periodB = periodA
+	}
+	if siteB == "" {
+		siteB = domain
+	}
+	if siteB == domain && periodB == periodA {
+		return fmt.Errorf("nothing to compare: both sides are %s %s", domain, periodA)
+	}
+
+	a := diffTarget{domain: domain}
+	b := diffTarget{domain: siteB}
+	var err error
+	if a.period, err = stats.ParsePeriod(periodA); err != nil {
+		return err
+	}
+	if b.period, err = stats.ParsePeriod(periodB); err != nil {
+		return err
+	}
+
+	if top < 1 || top > 50 {
+		return fmt.Errorf("top must be between 1 and 50")
+	}
+	if format == "" {
+		format = "table"
+	}
+	if format != "table" && format != "json" {
+		return fmt.Errorf("invalid format: %s (use json or table)", format)
+	}
+
+	if database.DB == nil {
+		if err := connectDatabase(); err != nil {
+			return fmt.Errorf("database connection failed: %w", err)
+		}
+		defer func() { _ = closeDatabase() }()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	if a.websiteID, err = getWebsiteIDByDomainFn(ctx, a.domain); err != nil {
+		return err
+	}
+	if b.websiteID, err = getWebsiteIDByDomainFn(ctx, b.domain); err != nil {
+		return err
+	}
+
+	diff, err := getStatsDiffFn(ctx, database.DB, a, b, top)
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		data, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	return outputDiffTable(diff)
+}
+
+// statsDiff compares the key metrics and top movers of two websites or
+// periods
+func statsDiff(ctx context.Context, db *sql.DB, a, b diffTarget, top int) (*StatsDiff, error) {
+	diff := &StatsDiff{}
+	var counts [2]map[string]map[string]int64
+	for i, side := range []diffTarget{a, b} {
+		websiteID, err := uuid.Parse(side.websiteID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid website ID: %w", err)
+		}
+		summary, err := stats.GetSummary(ctx, db, websiteID, side.period)
+		if err != nil {
+			return nil, err
+		}
+		result := DiffSide{Website: side.domain, Period: side.period.Label, Summary: *summary}
+		if i == 0 {
+			diff.A = result
+		} else {
+			diff.B = result
+		}
+
+		counts[i] = make(map[string]map[string]int64)
+		for _, d := range []stats.Dimension{stats.ByPage, stats.ByReferrer} {
+			if counts[i][d.Name], err = stats.GetCounts(ctx, db, websiteID, d, side.period, diffPool); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	diff.Pages = stats.Rank(counts[0]["page"], counts[1]["page"], 0, stats.ByChange, top)
+	diff.Referrers = stats.Rank(counts[0]["referrer"], counts[1]["referrer"], 0, stats.ByChange, top)
+	return diff, nil
+}
+
+func outputDiffTable(diff *StatsDiff) error {
+	label := func(side DiffSide) string {
+		if diff.A.Website == diff.B.Website {
+			return side.Period
+		}
+		if diff.A.Period == diff.B.Period {
+			return side.Website
+		}
+		return side.Website + " " + side.Period
+	}
+	a, b := label(diff.A), label(diff.B)
+
+	title := fmt.Sprintf("%s: %s vs %s", diff.A.Website, a, b)
+	if diff.A.Website != diff.B.Website {
+		title = fmt.Sprintf("%s vs %s", a, b)
+		if diff.A.Period == diff.B.Period {
+			title += " (" + diff.A.Period + ")"
+		}
+	}
+	fmt.Println(title)
+	fmt.Println(strings.Repeat("=", 60))
+
+	l := outputLocale
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "METRIC\t%s\t%s\tCHANGE\n", strings.ToUpper(a), strings.ToUpper(b))
+	_, _ = fmt.Fprintf(w, "------\t%s\t%s\t------\n", strings.Repeat("-", len(a)), strings.Repeat("-", len(b)))
+	_, _ = fmt.Fprintf(w, "Visitors\t%s\t%s\t%s\n", l.Int(diff.A.Visitors), l.Int(diff.B.Visitors),
+		percentChange(float64(diff.A.Visitors), float64(diff.B.Visitors)))
+	_, _ = fmt.Fprintf(w, "Pageviews\t%s\t%s\t%s\n", l.Int(diff.A.Pageviews), l.Int(diff.B.Pageviews),
+		percentChange(float64(diff.A.Pageviews), float64(diff.B.Pageviews)))
+	_, _ = fmt.Fprintf(w, "Bounce Rate\t%s\t%s\t%s pts\n", l.Percent(diff.A.BounceRate), l.Percent(diff.B.BounceRate),
+		l.SignedFloat(diff.B.BounceRate-diff.A.BounceRate, 1))
+	_, _ = fmt.Fprintf(w, "Avg Engagement\t%ss\t%ss\t%s\n", l.Float(diff.A.AvgEngagement, 1), l.Float(diff.B.AvgEngagement, 1),
+		percentChange(diff.A.AvgEngagement, diff.B.AvgEngagement))
+	_ = w.Flush()
+
+	outputMovers("Pages", diff.Pages, a, b)
+	outputMovers("Referrers", diff.Referrers, a, b)
+	return nil
+}
+
+func outputMovers(title string, movers stats.MoverList, a, b string) {
+	fmt.Printf("\nTop Movers: %s\n", title)
+	if len(movers.Gainers) == 0 && len(movers.Losers) == 0 {
+		fmt.Println("  No changes")
+		return
+	}
+
+	l := outputLocale
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "NAME\t%s\t%s\tCHANGE\t%%\n", strings.ToUpper(a), strings.ToUpper(b))
+	_, _ = fmt.Fprintf(w, "----\t%s\t%s\t------\t-\n", strings.Repeat("-", len(a)), strings.Repeat("-", len(b)))
+	for _, m := range append(movers.Gainers, movers.Losers...) {
+		percent := "new"
+		if m.ChangePercent != nil {
+			percent = l.SignedPercent(*m.ChangePercent)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", m.Name, l.Int(m.Before), l.Int(m.After), l.Signed(m.Change), percent)
+	}
+	_ = w.Flush()
+}
+
+// percentChange formats the change from a to b, "new" when there was
+// nothing to compare with
+func percentChange(a, b float64) string {
+	switch {
+	case a == b:
+		return outputLocale.Percent(0)
+	case a == 0:
+		return "new"
+	default:
+		return outputLocale.SignedPercent((b - a) / a * 100)
+	}
+}
+
+func init() {
+	statsCmd.AddCommand(statsDiffCmd)
+
+	statsDiffCmd.Flags().StringVar(&diffPeriodA, "period-a", "", "Period of the website (2025-06, 2025-06-15 or 2025-06-01..2025-06-15)")
+	statsDiffCmd.Flags().StringVar(&diffPeriodB, "period-b", "", "Period to compare with")
+	statsDiffCmd.Flags().StringVar(&diffSiteB, "site-b", "", "Website to compare with")
+	statsDiffCmd.Flags().IntVarP(&diffTop, "top", "t", 5, "Gainers and losers to show (1-50)")
+	statsDiffCmd.Flags().StringVarP(&diffFormat, "format", "f", "table", "Output format (json, table)")
+}
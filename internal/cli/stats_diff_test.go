@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/stats"
+)
+
+func stubStatsDiff(t *testing.T, fn func(context.Context, *sql.DB, diffTarget, diffTarget, int) (*StatsDiff, error)) {
+	t.Helper()
+	original := getStatsDiffFn
+	getStatsDiffFn = fn
+	t.Cleanup(func() {
+		getStatsDiffFn = original
+	})
+}
+
+func TestRunStatsDiffPeriodsTable(t *testing.T) {
+	stubDB(t)
+	stubConnectClose(t)
+	stubWebsiteIDLookup(t, func(ctx context.Context, domain string) (string, error) {
+		return "site-" + domain, nil
+	})
+	stubStatsDiff(t, func(ctx context.Context, db *sql.DB, a, b diffTarget, top int) (*StatsDiff, error) {
+		assert.Equal(t, "site-example.com", a.websiteID)
+		assert.Equal(t, "site-example.com", b.websiteID)
+		assert.Equal(t, "2025-05", a.period.Label)
+		assert.Equal(t, "2025-06", b.period.Label)
+		assert.Equal(t, 5, top)
+		return &StatsDiff{
+			A: DiffSide{Website: "example.com", Period: "2025-05", Summary: stats.Summary{Visitors: 100, Pageviews: 200, BounceRate: 50, AvgEngagement: 20}},
+			B: DiffSide{Website: "example.com", Period: "2025-06", Summary: stats.Summary{Visitors: 150, Pageviews: 180, BounceRate: 45, AvgEngagement: 20}},
+			Pages: stats.MoverList{
+				Gainers: []stats.Mover{{Name: "/launch", After: 60, Change: 60}},
+				Losers:  []stats.Mover{{Name: "/old", Before: 80, After: 10, Change: -70}},
+			},
+		}, nil
+	})
+
+	output, err := captureOutput(t, func() error {
+		return runStatsDiff("example.com", "2025-05", "", "2025-06", 5, "table")
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "example.com: 2025-05 vs 2025-06")
+	assert.Contains(t, output, "+50.0%")
+	assert.Contains(t, output, "-10.0%")
+	assert.Contains(t, output, "-5.0 pts")
+	assert.Contains(t, output, "/launch")
+	assert.Contains(t, output, "-70")
+	assert.Contains(t, output, "Top Movers: Referrers\n  No changes")
+}
+
+func TestRunStatsDiffSitesJSON(t *testing.T) {
+	stubDB(t)
+	stubConnectClose(t)
+	stubWebsiteIDLookup(t, func(ctx context.Context, domain string) (string, error) {
+		return "site-" + domain, nil
+	})
+	stubStatsDiff(t, func(ctx context.Context, db *sql.DB, a, b diffTarget, top int) (*StatsDiff, error) {
+		assert.Equal(t, "site-other.com", b.websiteID)
+		// The second site defaults to the same period
+		assert.Equal(t, a.period, b.period)
+		return &StatsDiff{
+			A: DiffSide{Website: a.domain, Period: a.period.Label},
+			B: DiffSide{Website: b.domain, Period: b.period.Label},
+		}, nil
+	})
+
+	output, err := captureOutput(t, func() error {
+		return runStatsDiff("example.com", "2025-06", "other.com", "", 5, "json")
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, `"website": "other.com"`)
+	assert.Contains(t, output, `"period": "2025-06"`)
+}
+
+func TestRunStatsDiffValidation(t *testing.T) {
+	for _, tc := range []struct {
+		periodA, siteB, periodB string
+		top                     int
+		format                  string
+		want                    string
+	}{
+		{"", "", "2025-06", 5, "table", "--period-a is required"},
+		{"2025-05", "", "", 5, "table", "--period-b or --site-b is required"},
+		{"2025-05", "example.com", "2025-05", 5, "table", "nothing to compare"},
+		{"May", "", "2025-06", 5, "table", "invalid period"},
+		{"2025-05", "", "2025-06", 0, "table", "top must be between 1 and 50"},
+		{"2025-05", "", "2025-06", 5, "csv", "invalid format"},
+	} {
+		err := runStatsDiff("example.com", tc.periodA, tc.siteB, tc.periodB, tc.top, tc.format)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), tc.want)
+	}
+}
+
+func TestPercentChange(t *testing.T) {
+	assert.Equal(t, "+25.0%", percentChange(4, 5))
+	assert.Equal(t, "-50.0%", percentChange(4, 2))
+	assert.Equal(t, "0.0%", percentChange(0, 0))
+	assert.Equal(t, "new", percentChange(0, 3))
+}
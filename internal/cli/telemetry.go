@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/logging"
+	"github.com/seuros/kaunta/internal/telemetry"
+	"github.com/seuros/kaunta/internal/worker"
+)
+
+var telemetryCmd = &cobra.Command{
+	Use:   "telemetry",
+	Short: "Inspect the opt-in anonymous usage report",
+	Long: `Kaunta can send its maintainers an anonymous usage report once a week, so
+they learn which subsystems matter. It is off unless the config opts in:
+
+  telemetry = true
+  telemetry_endpoint = "https://..."   (or TELEMETRY=true, TELEMETRY_ENDPOINT)
+
+The report holds the release and platform, the event store, the optional
+subsystems that are on, and the number of websites and of events in the last
+30 days rounded to a power of ten. No domains, URLs, IPs, user names or
+visitor counts. The last report sent is kept in <data_dir>/telemetry.`,
+}
+
+var telemetryPreviewCmd = &cobra.Command{
+	Use:   "preview",
+	Short: "Print the report exactly as it would be sent",
+	Long: `Print the usage report exactly as it would be sent, whether or not telemetry
+is enabled. Nothing is sent. Whether telemetry is on and where reports go is
+written to stderr, so stdout holds only the report.
+
+Examples:
+  kaunta telemetry preview
+  kaunta telemetry preview > report.json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTelemetryPreview()
+	},
+}
+
+func runTelemetryPreview() error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if database.DB == nil {
+		if err := connectDatabase(); err != nil {
+			return fmt.Errorf("database connection failed: %w", err)
+		}
+		defer func() { _ = closeDatabase() }()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	report, err := buildTelemetryReport(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	switch {
+	case !cfg.Telemetry:
+		fmt.Fprintln(os.Stderr, "Telemetry is off (telemetry = false); this report is never sent.")
+	case cfg.TelemetryEndpoint == "":
+		fmt.Fprintln(os.Stderr, "Telemetry is on but telemetry_endpoint is not set; nothing is sent.")
+	default:
+		fmt.Fprintf(os.Stderr, "Telemetry is on; a report like this is sent weekly to %s\n", cfg.TelemetryEndpoint)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// buildTelemetryReport is the report of this instance
+func buildTelemetryReport(ctx context.Context, cfg *config.Config) (*telemetry.Report, error) {
+	instanceID, err := telemetry.InstanceID(cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	return telemetry.Collect(ctx, database.DB, cfg, currentBuildInfo(), instanceID)
+}
+
+// sendTelemetry sends the weekly report when the config opts in
+func sendTelemetry(ctx context.Context) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if !cfg.Telemetry || !telemetry.Due(cfg.DataDir, time.Now()) {
+		return nil
+	}
+	report, err := buildTelemetryReport(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if err := telemetry.Send(ctx, cfg.TelemetryEndpoint, cfg.DataDir, report); err != nil {
+		return err
+	}
+	logging.L().Info("sent usage report", zap.String("path", telemetry.LastReportPath(cfg.DataDir)))
+	return nil
+}
+
+func init() {
+	RootCmd.AddCommand(telemetryCmd)
+	telemetryCmd.AddCommand(telemetryPreviewCmd)
+
+	// Registered here rather than with the other tasks: the report needs
+	// the build information only the CLI has
+	worker.Register(worker.Task{
+		Name:        "telemetry",
+		Description: "Send the anonymous weekly usage report (only with telemetry = true)",
+		Interval:    24 * time.Hour,
+		Run:         sendTelemetry,
+	})
+}
@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/telemetry"
+)
+
+func TestRunTelemetryPreview(t *testing.T) {
+	mock := mockJobsDB(t)
+	dataDir := t.TempDir()
+	t.Setenv("DATA_DIR", dataDir)
+	t.Setenv("TELEMETRY", "false")
+
+	mock.ExpectQuery(`FROM website WHERE deleted_at IS NULL`).
+		WillReturnRows(sqlmock.NewRows([]string{"websites", "events"}).AddRow(12, 0))
+	mock.ExpectQuery(`FROM feature_flag`).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "website_id", "enabled"}))
+
+	output, err := captureOutput(t, runTelemetryPreview)
+	require.NoError(t, err)
+
+	var report telemetry.Report
+	require.NoError(t, json.Unmarshal([]byte(output), &report), "stdout holds only the report")
+	assert.Equal(t, "10+", report.Websites)
+	assert.Equal(t, "0", report.Events)
+
+	id, err := telemetry.InstanceID(dataDir)
+	require.NoError(t, err)
+	assert.Equal(t, id, report.InstanceID)
+	assert.True(t, telemetry.Due(dataDir, time.Now()), "previews are not sent")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,193 @@
+package cli
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// Timeseries command flags
+var (
+	timeseriesDays     int
+	timeseriesInterval string
+	timeseriesFormat   string
+	timeseriesRange    dateRange
+)
+
+// getTimeSeriesFn reads a time series from the store; tests replace it
+var getTimeSeriesFn = func(ctx context.Context, websiteID uuid.UUID, days int, interval store.Interval, f store.Filters) ([]store.TimePoint, error) {
+	return store.Current().TimeSeries(ctx, websiteID, days, interval, f)
+}
+
+var statsTimeseriesCmd = &cobra.Command{
+	Use:   "timeseries <website-domain> [--interval minute|hour|day|week|month] [--days <N> | --from <date> [--to <date>]] [--format json|table|csv]",
+	Short: "Show pageviews over time",
+	Long: `Display pageviews per minute, hour, day, week or month, as charted on the
+dashboard. Buckets without pageviews are left out.
+
+Minutes and hours cover a rolling window of N times 24 hours ending now; days,
+weeks (from Monday) and months start at midnight in the website's time zone.
+
+Options:
+  --interval    Bucket size: minute, hour, day, week, month (default hour)
+  --days N      Time period in days (default 7; capped to 1 for minutes,
+                90 for hours and 366 otherwise)
+  --from, --to  Date range instead, YYYY-MM-DD with both days included
+  --tz ZONE     Time zone of the range's days (default UTC)
+  --format      Output format: json, table, csv (default table)
+
+Examples:
+  kaunta stats timeseries example.com
+  kaunta stats timeseries example.com --interval minute --days 1
+  kaunta stats timeseries example.com --interval week --days 180 --format csv`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runStatsTimeseries(args[0], timeseriesInterval, timeseriesDays, timeseriesRange, timeseriesFormat)
+	},
+}
+
+func runStatsTimeseries(domain, intervalName string, days int, r dateRange, format string) error {
+	interval, err := store.ParseInterval(intervalName)
+	if err != nil {
+		return err
+	}
+	period, err := r.period()
+	if err != nil {
+		return err
+	}
+	if period == nil && days < 1 {
+		return fmt.Errorf("days must be at least 1")
+	}
+	days = min(days, interval.MaxDays())
+	if period != nil && period.From.AddDate(0, 0, interval.MaxDays()).Before(period.To) {
+		if interval.MaxDays() == 1 {
+			return fmt.Errorf("interval %s covers at most 1 day", interval)
+		}
+		return fmt.Errorf("interval %s covers at most %d days", interval, interval.MaxDays())
+	}
+	if format == "" {
+		format = "table"
+	}
+	if format != "table" && format != "json" && format != "csv" {
+		return fmt.Errorf("invalid format: %s (use json, table, or csv)", format)
+	}
+
+	if database.DB == nil {
+		if err := connectDatabase(); err != nil {
+			return fmt.Errorf("database connection failed: %w", err)
+		}
+		defer func() { _ = closeDatabase() }()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	websiteIDStr, err := getWebsiteIDByDomainFn(ctx, domain)
+	if err != nil {
+		return err
+	}
+	websiteID, err := uuid.Parse(websiteIDStr)
+	if err != nil {
+		return err
+	}
+
+	filters := store.Filters{}
+	if period != nil {
+		days, filters = period.Days(time.Now()), period.Narrow(filters)
+	}
+
+	points, err := getTimeSeriesFn(ctx, websiteID, days, interval, filters)
+	if err != nil {
+		return fmt.Errorf("failed to query time series: %w", err)
+	}
+
+	switch format {
+	case "json":
+		return outputTimeseriesJSON(points)
+	case "csv":
+		return outputTimeseriesCSV(points)
+	default:
+		fmt.Printf("Pageviews per %s on %s (%s)\n\n", interval, domain, periodLabel(days, period))
+		return outputTimeseriesTable(points, interval)
+	}
+}
+
+// timeseriesPoint is a bucket of the JSON output
+type timeseriesPoint struct {
+	Timestamp string `json:"timestamp"`
+	Pageviews int64  `json:"pageviews"`
+}
+
+func outputTimeseriesJSON(points []store.TimePoint) error {
+	list := make([]timeseriesPoint, 0, len(points))
+	for _, p := range points {
+		list = append(list, timeseriesPoint{Timestamp: p.Timestamp, Pageviews: p.Views})
+	}
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func outputTimeseriesCSV(points []store.TimePoint) error {
+	w := csv.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	if err := w.Write([]string{"timestamp", "pageviews"}); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+	for _, p := range points {
+		if err := w.Write([]string{p.Timestamp, strconv.FormatInt(p.Views, 10)}); err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+	}
+	return nil
+}
+
+func outputTimeseriesTable(points []store.TimePoint, interval store.Interval) error {
+	if len(points) == 0 {
+		fmt.Println("No pageviews")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "TIME\tPAGEVIEWS")
+	_, _ = fmt.Fprintln(w, "----\t---------")
+	l := outputLocale
+	for _, p := range points {
+		label := p.Timestamp
+		if t, err := time.Parse(time.RFC3339, p.Timestamp); err == nil {
+			if interval.Rolling() {
+				label = l.DateTime(t.UTC())
+			} else {
+				// Days start at midnight in the website's time zone, which
+				// rounds to the same UTC day for offsets up to 12 hours
+				label = l.Date(t.UTC().Add(12 * time.Hour).Truncate(24 * time.Hour))
+			}
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\n", label, l.Int(p.Views))
+	}
+	return w.Flush()
+}
+
+func init() {
+	statsCmd.AddCommand(statsTimeseriesCmd)
+
+	statsTimeseriesCmd.Flags().StringVarP(&timeseriesInterval, "interval", "i", "hour", "Bucket size (minute, hour, day, week, month)")
+	statsTimeseriesCmd.Flags().IntVarP(&timeseriesDays, "days", "d", 7, "Time period in days")
+	statsTimeseriesCmd.Flags().StringVarP(&timeseriesFormat, "format", "f", "table", "Output format (json, table, csv)")
+	addRangeFlags(statsTimeseriesCmd, &timeseriesRange)
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/store"
+)
+
+func stubTimeSeries(t *testing.T, fn func(context.Context, uuid.UUID, int, store.Interval, store.Filters) ([]store.TimePoint, error)) {
+	t.Helper()
+	original := getTimeSeriesFn
+	getTimeSeriesFn = fn
+	t.Cleanup(func() {
+		getTimeSeriesFn = original
+	})
+}
+
+func TestRunStatsTimeseries(t *testing.T) {
+	stubDB(t)
+	stubConnectClose(t)
+
+	websiteID := uuid.New()
+	stubWebsiteIDLookup(t, func(ctx context.Context, domain string) (string, error) {
+		return websiteID.String(), nil
+	})
+	var gotDays int
+	var gotInterval store.Interval
+	stubTimeSeries(t, func(ctx context.Context, id uuid.UUID, days int, interval store.Interval, f store.Filters) ([]store.TimePoint, error) {
+		assert.Equal(t, websiteID, id)
+		gotDays, gotInterval = days, interval
+		return []store.TimePoint{
+			{Timestamp: "2025-06-29T22:00:00Z", Views: 1200},
+			{Timestamp: "2025-06-30T22:00:00Z", Views: 34},
+		}, nil
+	})
+
+	output, err := captureOutput(t, func() error {
+		return runStatsTimeseries("example.com", "day", 30, dateRange{}, "csv")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "timestamp,pageviews\n2025-06-29T22:00:00Z,1200\n2025-06-30T22:00:00Z,34\n", output)
+	assert.Equal(t, 30, gotDays)
+	assert.Equal(t, store.IntervalDay, gotInterval)
+
+	useLocale(t, "en")
+	output, err = captureOutput(t, func() error {
+		return runStatsTimeseries("example.com", "day", 30, dateRange{}, "table")
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "Pageviews per day on example.com (last 30 days)")
+	assert.Regexp(t, `06/30/2025\s+1,200`, output)
+	assert.Regexp(t, `07/01/2025\s+34`, output)
+
+	// Minutes are capped to a day
+	_, err = captureOutput(t, func() error {
+		return runStatsTimeseries("example.com", "minute", 7, dateRange{}, "json")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, gotDays)
+}
+
+func TestRunStatsTimeseriesValidation(t *testing.T) {
+	err := runStatsTimeseries("example.com", "year", 7, dateRange{}, "table")
+	assert.EqualError(t, err, "invalid interval: year (use minute, hour, day, week or month)")
+
+	err = runStatsTimeseries("example.com", "minute", 7, dateRange{From: "2025-06-01", To: "2025-06-03"}, "table")
+	assert.EqualError(t, err, "interval minute covers at most 1 day")
+
+	err = runStatsTimeseries("example.com", "hour", 7, dateRange{}, "xml")
+	assert.EqualError(t, err, "invalid format: xml (use json, table, or csv)")
+}
@@ -0,0 +1,244 @@
+package cli
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/database"
+)
+
+var (
+	tokenName       string
+	tokenExpiresIn  int
+	tokenRateLimit  int
+	tokenDailyQuota int
+)
+
+var userTokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Manage API tokens",
+	Long: `API tokens let integrations call the dashboard API with
+Authorization: Bearer <token>, as the user they belong to.
+
+Requests made with a token are limited to api_rate_limit requests per minute
+(default 600) and api_daily_quota requests per UTC day (default unlimited).
+A token can override both.`,
+}
+
+var userTokenCreateCmd = &cobra.Command{
+	Use:   "create <username> --name <name> [--expires-in <days>] [--rate-limit <n>] [--daily-quota <n>]",
+	Short: "Create an API token",
+	Long: `Create an API token for a user. The token is printed once; only its hash is
+stored.
+
+--rate-limit (requests per minute) and --daily-quota (requests per UTC day)
+override the server settings for this token; 0 means unlimited.
+
+Examples:
+  kaunta user token create admin --name grafana
+  kaunta user token create admin --name nightly-export --rate-limit 60 --daily-quota 5000`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var rateLimit, dailyQuota *int
+		if cmd.Flags().Changed("rate-limit") {
+			rateLimit = &tokenRateLimit
+		}
+		if cmd.Flags().Changed("daily-quota") {
+			dailyQuota = &tokenDailyQuota
+		}
+		return runUserTokenCreate(args[0], tokenName, tokenExpiresIn, rateLimit, dailyQuota)
+	},
+}
+
+var userTokenListCmd = &cobra.Command{
+	Use:   "list [username]",
+	Short: "List API tokens",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		username := ""
+		if len(args) == 1 {
+			username = args[0]
+		}
+		return runUserTokenList(username)
+	},
+}
+
+var userTokenRevokeCmd = &cobra.Command{
+	Use:   "revoke <token-id>",
+	Short: "Revoke an API token",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runUserTokenRevoke(args[0])
+	},
+}
+
+func runUserTokenCreate(username, name string, expiresInDays int, rateLimit, dailyQuota *int) error {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return fmt.Errorf("--name must be 1-100 characters")
+	}
+	if expiresInDays < 1 {
+		return fmt.Errorf("--expires-in must be at least 1 day")
+	}
+	if (rateLimit != nil && *rateLimit < 0) || (dailyQuota != nil && *dailyQuota < 0) {
+		return fmt.Errorf("limits can't be negative (0 means unlimited)")
+	}
+
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var userID uuid.UUID
+	err = database.DB.QueryRowContext(ctx, `SELECT user_id FROM users WHERE username = $1`, username).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("user not found: %s", username)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up user: %w", err)
+	}
+
+	token, tokenHash, err := newAPIToken()
+	if err != nil {
+		return err
+	}
+	tokenID := uuid.New()
+	expiresAt := time.Now().AddDate(0, 0, expiresInDays)
+
+	_, err = database.DB.ExecContext(ctx, `
+		INSERT INTO user_sessions (session_id, user_id, token_hash, expires_at, user_agent, name, rate_limit, daily_quota)
+		VALUES ($1, $2, $3, $4, 'api-token', $5, $6, $7)
+	`, tokenID, userID, tokenHash, expiresAt, name, rateLimit, dailyQuota)
+	if err != nil {
+		return fmt.Errorf("failed to create API token: %w", err)
+	}
+
+	fmt.Printf("API token %q created for %s (ID %s, expires %s)\n",
+		name, username, tokenID, expiresAt.Format("2006-01-02"))
+	fmt.Printf("\n  %s\n\n", token)
+	fmt.Println("Store it now: it can't be shown again.")
+	return nil
+}
+
+func runUserTokenList(username string) error {
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	rows, err := database.DB.QueryContext(ctx, `
+		SELECT s.session_id, s.name, u.username, s.rate_limit, s.daily_quota, s.expires_at, s.last_used_at
+		FROM user_sessions s
+		JOIN users u ON u.user_id = s.user_id
+		WHERE s.name IS NOT NULL AND ($1 = '' OR u.username = $1)
+		ORDER BY u.username, s.created_at
+	`, username)
+	if err != nil {
+		return fmt.Errorf("failed to list API tokens: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tNAME\tUSER\tRATE LIMIT\tDAILY QUOTA\tEXPIRES\tLAST USED")
+	_, _ = fmt.Fprintln(w, "--\t----\t----\t----------\t-----------\t-------\t---------")
+	for rows.Next() {
+		var (
+			tokenID               uuid.UUID
+			name, user            string
+			rateLimit, dailyQuota sql.NullInt32
+			expiresAt, lastUsedAt time.Time
+		)
+		if err := rows.Scan(&tokenID, &name, &user, &rateLimit, &dailyQuota, &expiresAt, &lastUsedAt); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", tokenID, name, user,
+			formatTokenLimit(rateLimit, "/min"), formatTokenLimit(dailyQuota, "/day"),
+			expiresAt.Format("2006-01-02"), lastUsedAt.Format("2006-01-02 15:04"))
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func runUserTokenRevoke(id string) error {
+	tokenID, err := uuid.Parse(id)
+	if err != nil {
+		return fmt.Errorf("invalid token ID: %s", id)
+	}
+
+	done, err := ensureDatabase()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	res, err := database.DB.ExecContext(ctx,
+		`DELETE FROM user_sessions WHERE session_id = $1 AND name IS NOT NULL`, tokenID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API token: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("API token %s not found", tokenID)
+	}
+	fmt.Printf("API token %s revoked\n", tokenID)
+	return nil
+}
+
+// formatTokenLimit shows a token's override, or "default" for the server setting
+func formatTokenLimit(limit sql.NullInt32, unit string) string {
+	switch {
+	case !limit.Valid:
+		return "default"
+	case limit.Int32 == 0:
+		return "unlimited"
+	default:
+		return strconv.Itoa(int(limit.Int32)) + unit
+	}
+}
+
+// newAPIToken returns a random token and the hash stored for it, matching
+// dashboard session tokens
+func newAPIToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate API token: %w", err)
+	}
+	token = hex.EncodeToString(b)
+	sum := sha256.Sum256([]byte(token))
+	return token, hex.EncodeToString(sum[:]), nil
+}
+
+func init() {
+	userCmd.AddCommand(userTokenCmd)
+	userTokenCmd.AddCommand(userTokenCreateCmd, userTokenListCmd, userTokenRevokeCmd)
+
+	userTokenCreateCmd.Flags().StringVar(&tokenName, "name", "", "Token name, e.g. the integration using it (required)")
+	userTokenCreateCmd.Flags().IntVar(&tokenExpiresIn, "expires-in", 365, "Days until the token expires")
+	userTokenCreateCmd.Flags().IntVar(&tokenRateLimit, "rate-limit", 0, "Requests per minute for this token (0: unlimited)")
+	userTokenCreateCmd.Flags().IntVar(&tokenDailyQuota, "daily-quota", 0, "Requests per UTC day for this token (0: unlimited)")
+	_ = userTokenCreateCmd.MarkFlagRequired("name")
+}
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunUserTokenCreate(t *testing.T) {
+	mock := mockJobsDB(t)
+	userID := uuid.New()
+	limit := 60
+
+	mock.ExpectQuery(`SELECT user_id FROM users WHERE username = \$1`).WithArgs("admin").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(userID))
+	mock.ExpectExec(`INSERT INTO user_sessions .*name, rate_limit, daily_quota`).
+		WithArgs(sqlmock.AnyArg(), userID, sqlmock.AnyArg(), sqlmock.AnyArg(), "grafana", 60, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	output, err := captureOutput(t, func() error { return runUserTokenCreate("admin", "grafana", 30, &limit, nil) })
+	require.NoError(t, err)
+	assert.Contains(t, output, `API token "grafana" created for admin`)
+	assert.Regexp(t, `\n  [0-9a-f]{64}\n`, output)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	assert.EqualError(t, runUserTokenCreate("admin", " ", 30, nil, nil), "--name must be 1-100 characters")
+	negative := -1
+	assert.Error(t, runUserTokenCreate("admin", "grafana", 30, nil, &negative))
+}
+
+func TestRunUserTokenList(t *testing.T) {
+	mock := mockJobsDB(t)
+	now := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`FROM user_sessions s`).WithArgs("").
+		WillReturnRows(sqlmock.NewRows([]string{"session_id", "name", "username", "rate_limit", "daily_quota", "expires_at", "last_used_at"}).
+			AddRow(uuid.New(), "grafana", "admin", 60, nil, now.AddDate(1, 0, 0), now))
+
+	output, err := captureOutput(t, func() error { return runUserTokenList("") })
+	require.NoError(t, err)
+	assert.Contains(t, output, "RATE LIMIT")
+	assert.Regexp(t, `grafana\s+admin\s+60/min\s+default\s+2027-03-01`, output)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunUserTokenRevoke(t *testing.T) {
+	mock := mockJobsDB(t)
+	tokenID := uuid.New()
+
+	mock.ExpectExec(`DELETE FROM user_sessions WHERE session_id = \$1 AND name IS NOT NULL`).WithArgs(tokenID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.EqualError(t, runUserTokenRevoke(tokenID.String()), "API token "+tokenID.String()+" not found")
+	assert.EqualError(t, runUserTokenRevoke("nope"), "invalid token ID: nope")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFormatTokenLimit(t *testing.T) {
+	assert.Equal(t, "default", formatTokenLimit(sql.NullInt32{}, "/min"))
+	assert.Equal(t, "unlimited", formatTokenLimit(sql.NullInt32{Valid: true}, "/min"))
+	assert.Equal(t, "5000/day", formatTokenLimit(sql.NullInt32{Int32: 5000, Valid: true}, "/day"))
+}
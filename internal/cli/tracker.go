@@ -0,0 +1,194 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/database"
+)
+
+// Paths the tracker script is written with; tracker_path and collect_path
+// replace them when the script is served
+const (
+	defaultScriptPath = "/k.js"
+	defaultSendPath   = "/api/send"
+	defaultBatchPath  = "/api/batch"
+)
+
+var (
+	proxyServer   string
+	proxyUpstream string
+	proxyWebsite  string
+)
+
+var trackerCmd = &cobra.Command{
+	Use:   "tracker",
+	Short: "Serve the tracker under paths of your choosing",
+	Long: `Content blockers match the default tracker paths (/k.js, /api/send). The
+config can serve the script and the tracking endpoint under other paths too:
+
+  tracker_path = "/assets/app.js"   (or TRACKER_PATH)
+  collect_path = "/events"          (or COLLECT_PATH; batches go to /events/batch)
+
+The script is rewritten at startup to send its events to collect_path. The
+default paths keep working. Serving both from the website's own domain,
+through its reverse proxy, makes the tracker first-party: see
+'kaunta tracker proxy-config'.`,
+}
+
+var trackerProxyConfigCmd = &cobra.Command{
+	Use:   "proxy-config --upstream <kaunta-url> [--server nginx|caddy] [--website domain]",
+	Short: "Print the reverse proxy config serving the tracker first-party",
+	Long: `Print the reverse proxy config that serves the tracker script and the
+tracking endpoint from the website's own domain, forwarding them to Kaunta.
+The paths are tracker_path and collect_path (the defaults when unset).
+
+Kaunta then sees the proxy's address: --website switches the website to
+reading visitors' addresses from the X-Forwarded-For header the config sets.
+
+Examples:
+  kaunta tracker proxy-config --upstream https://kaunta.example.com
+  kaunta tracker proxy-config --upstream https://kaunta.example.com --server caddy --website example.com`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		return runTrackerProxyConfig(proxyServer, proxyUpstream, proxyWebsite, cfg.TrackerPath, cfg.CollectPath)
+	},
+}
+
+// runTrackerProxyConfig prints the proxy config and, given a website,
+// switches it to the X-Forwarded-For proxy mode
+func runTrackerProxyConfig(server, upstream, website, trackerPath, collectPath string) error {
+	out, err := trackerProxyConfig(server, upstream, trackerPath, collectPath)
+	if err != nil {
+		return err
+	}
+	fmt.Print(out)
+	if website == "" {
+		return nil
+	}
+	return withWebsite(website, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		if _, err := database.DB.ExecContext(ctx, `
+			UPDATE website SET proxy_mode = 'xforwarded', updated_at = NOW()
+			WHERE website_id = $1`, websiteID); err != nil {
+			return fmt.Errorf("failed to update proxy mode: %w", err)
+		}
+		fmt.Printf("\n# %s now reads visitor addresses from X-Forwarded-For\n", domain)
+		return nil
+	})
+}
+
+// validTrackerPath checks a tracker_path: an absolute path to a .js file
+func validTrackerPath(path string) error {
+	if err := validServePath(path); err != nil {
+		return fmt.Errorf("invalid tracker_path: %w", err)
+	}
+	if !strings.HasSuffix(path, ".js") {
+		return fmt.Errorf("invalid tracker_path %q: must end in .js", path)
+	}
+	return nil
+}
+
+// validCollectPath checks a collect_path: an absolute path outside the API
+func validCollectPath(path string) error {
+	if err := validServePath(path); err != nil {
+		return fmt.Errorf("invalid collect_path: %w", err)
+	}
+	if path == "/api" || strings.HasPrefix(path, "/api/") {
+		return fmt.Errorf("invalid collect_path %q: /api/ is the stats API", path)
+	}
+	return nil
+}
+
+func validServePath(path string) error {
+	if !strings.HasPrefix(path, "/") || path == "/" || strings.HasSuffix(path, "/") {
+		return fmt.Errorf("%q must start with / and not end with /", path)
+	}
+	if strings.ContainsAny(path, "?#:* \t\"'\\") || strings.Contains(path, "//") || strings.Contains(path, "..") {
+		return fmt.Errorf("%q must be a plain path", path)
+	}
+	return nil
+}
+
+// rewriteTrackerScript makes the script use trackerPath and collectPath
+// (each left as is when empty). Only the quoted path strings are replaced,
+// in either quote style, as minifiers may change quotes.
+func rewriteTrackerScript(script []byte, trackerPath, collectPath string) []byte {
+	replace := func(script []byte, from, to string) []byte {
+		for _, q := range []string{"'", `"`} {
+			script = bytes.ReplaceAll(script, []byte(q+from+q), []byte(q+to+q))
+		}
+		return script
+	}
+	if trackerPath != "" {
+		script = replace(script, defaultScriptPath, trackerPath)
+	}
+	if collectPath != "" {
+		script = replace(script, defaultSendPath, collectPath)
+		script = replace(script, defaultBatchPath, collectPath+"/batch")
+	}
+	return script
+}
+
+// trackerProxyConfig is the nginx or Caddy config forwarding the tracker
+// paths to upstream
+func trackerProxyConfig(server, upstream, trackerPath, collectPath string) (string, error) {
+	u, err := url.Parse(upstream)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid upstream: %q (use https://kaunta.example.com)", upstream)
+	}
+	upstream = strings.TrimSuffix(u.String(), "/")
+	if trackerPath == "" {
+		trackerPath = defaultScriptPath
+	}
+	send, batch := defaultSendPath, defaultBatchPath
+	if collectPath != "" {
+		send, batch = collectPath, collectPath+"/batch"
+	}
+
+	var b strings.Builder
+	switch server {
+	case "nginx":
+		fmt.Fprintf(&b, "# Kaunta tracker, first-party (inside the website's server block)\n")
+		for _, path := range []string{trackerPath, send, batch} {
+			fmt.Fprintf(&b, "location = %s {\n", path)
+			fmt.Fprintf(&b, "    proxy_pass %s%s;\n", upstream, path)
+			fmt.Fprintf(&b, "    proxy_set_header Host %s;\n", u.Host)
+			fmt.Fprintf(&b, "    proxy_set_header X-Forwarded-For $remote_addr;\n")
+			fmt.Fprintf(&b, "    proxy_ssl_server_name on;\n")
+			fmt.Fprintf(&b, "}\n")
+		}
+	case "caddy":
+		fmt.Fprintf(&b, "# Kaunta tracker, first-party (inside the website's site block)\n")
+		fmt.Fprintf(&b, "@kaunta path %s %s %s\n", trackerPath, send, batch)
+		fmt.Fprintf(&b, "handle @kaunta {\n")
+		fmt.Fprintf(&b, "    reverse_proxy %s {\n", upstream)
+		fmt.Fprintf(&b, "        header_up Host {upstream_hostport}\n")
+		fmt.Fprintf(&b, "        header_up X-Forwarded-For {remote_host}\n")
+		fmt.Fprintf(&b, "    }\n")
+		fmt.Fprintf(&b, "}\n")
+	default:
+		return "", fmt.Errorf("invalid server: %s (use nginx or caddy)", server)
+	}
+	fmt.Fprintf(&b, "\n# Then embed <script defer data-website-id=\"...\" src=\"%s\"></script>\n", trackerPath)
+	fmt.Fprintf(&b, "# (--website <domain> makes Kaunta read visitor addresses from X-Forwarded-For)\n")
+	return b.String(), nil
+}
+
+func init() {
+	trackerCmd.AddCommand(trackerProxyConfigCmd)
+	trackerProxyConfigCmd.Flags().StringVar(&proxyServer, "server", "nginx", "Reverse proxy: nginx or caddy")
+	trackerProxyConfigCmd.Flags().StringVar(&proxyUpstream, "upstream", "", "URL of the Kaunta server")
+	trackerProxyConfigCmd.Flags().StringVar(&proxyWebsite, "website", "", "Website proxied this way (sets its proxy mode)")
+	RootCmd.AddCommand(trackerCmd)
+}
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewriteTrackerScript(t *testing.T) {
+	script := []byte(`var SCRIPT_PATH = '/k.js'; var SEND_PATH = "/api/send"; var BATCH_PATH = '/api/batch'; fetch('/api/sender');`)
+
+	got := string(rewriteTrackerScript(script, "/assets/app.js", "/events"))
+	assert.Equal(t, `var SCRIPT_PATH = '/assets/app.js'; var SEND_PATH = "/events"; var BATCH_PATH = '/events/batch'; fetch('/api/sender');`, got)
+
+	assert.Equal(t, string(script), string(rewriteTrackerScript(script, "", "")))
+}
+
+func TestValidTrackerPaths(t *testing.T) {
+	assert.NoError(t, validTrackerPath("/assets/app.js"))
+	for _, path := range []string{"assets/app.js", "/assets/app", "/app.js?v=1", "/a b.js", "/../app.js"} {
+		assert.Error(t, validTrackerPath(path), path)
+	}
+
+	assert.NoError(t, validCollectPath("/events"))
+	for _, path := range []string{"/", "events", "/events/", "/api/events", "/api", "//events"} {
+		assert.Error(t, validCollectPath(path), path)
+	}
+}
+
+func TestTrackerProxyConfig(t *testing.T) {
+	out, err := trackerProxyConfig("nginx", "https://kaunta.example.com/", "/assets/app.js", "/events")
+	require.NoError(t, err)
+	assert.Contains(t, out, "location = /assets/app.js {\n    proxy_pass https://kaunta.example.com/assets/app.js;")
+	assert.Contains(t, out, "location = /events/batch {")
+	assert.Contains(t, out, "proxy_set_header Host kaunta.example.com;")
+	assert.Contains(t, out, `src="/assets/app.js"`)
+
+	out, err = trackerProxyConfig("caddy", "https://kaunta.example.com", "", "")
+	require.NoError(t, err)
+	assert.Contains(t, out, "@kaunta path /k.js /api/send /api/batch")
+	assert.Contains(t, out, "reverse_proxy https://kaunta.example.com {")
+
+	_, err = trackerProxyConfig("apache", "https://kaunta.example.com", "", "")
+	assert.ErrorContains(t, err, "invalid server")
+	_, err = trackerProxyConfig("nginx", "kaunta.example.com", "", "")
+	assert.ErrorContains(t, err, "invalid upstream")
+}
+
+func TestRunTrackerProxyConfigSetsProxyMode(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectExec(`UPDATE website SET proxy_mode = 'xforwarded'`).
+		WithArgs(websiteID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	output, err := captureOutput(t, func() error {
+		return runTrackerProxyConfig("nginx", "https://kaunta.example.com", "example.com", "", "/events")
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "location = /events {")
+	assert.Contains(t, output, "example.com now reads visitor addresses from X-Forwarded-For")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/stats"
+)
+
+// Trending command flags
+var (
+	trendingDays   int
+	trendingTop    int
+	trendingMin    int64
+	trendingSort   string
+	trendingFormat string
+)
+
+var getTrendsFn = GetTrends
+
+var statsTrendingCmd = &cobra.Command{
+	Use:   "trending <website-domain> [--days <N>] [--top <N>] [--min <N>] [--sort change|percent] [--format json|table]",
+	Short: "Show what changed versus the previous period",
+	Long: `Rank the pages and referrers gaining and losing the most pageviews over the
+last N days, compared with the N days before.
+
+Options:
+  --days N      Period in days (1-90, default 7)
+  --top N       Gainers and losers to show (1-50, default 10)
+  --min N       Leave out values with fewer pageviews in both periods (default 10)
+  --sort        Rank by pageviews gained or lost (change) or relative change
+                (percent, new values first) (default change)
+  --format      Output format: json, table (default table)
+
+Examples:
+  kaunta stats trending mysite.com
+  kaunta stats trending mysite.com --days 30 --sort percent --min 50`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runStatsTrending(args[0], trendingDays, trendingTop, trendingMin, trendingSort, trendingFormat)
+	},
+}
+
+func runStatsTrending(domain string, days, top int, minPageviews int64, sort, format string) error {
+	if days < 1 || days > 90 {
+		return fmt.Errorf("days must be between 1 and 90")
+	}
+	if top < 1 || top > 50 {
+		return fmt.Errorf("top must be between 1 and 50")
+	}
+	if minPageviews < 0 {
+		return fmt.Errorf("min must not be negative")
+	}
+	rank, err := stats.ParseRanking(sort)
+	if err != nil {
+		return err
+	}
+	if format == "" {
+		format = "table"
+	}
+	if format != "table" && format != "json" {
+		return fmt.Errorf("invalid format: %s (use json or table)", format)
+	}
+
+	if database.DB == nil {
+		if err := connectDatabase(); err != nil {
+			return fmt.Errorf("database connection failed: %w", err)
+		}
+		defer func() { _ = closeDatabase() }()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	websiteID, err := getWebsiteIDByDomainFn(ctx, domain)
+	if err != nil {
+		return err
+	}
+
+	trends, err := getTrendsFn(ctx, database.DB, websiteID, days, minPageviews, rank, top)
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		data, err := json.MarshalIndent(trends, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("Trending on %s: last %d days vs the %d days before\n", domain, days, days)
+	fmt.Println(strings.Repeat("=", 60))
+	outputMovers("Pages", trends.Pages, "before", "now")
+	outputMovers("Referrers", trends.Referrers, "before", "now")
+	return nil
+}
+
+// GetTrends compares the last days days of pageviews with the days before
+func GetTrends(ctx context.Context, db *sql.DB, websiteID string, days int, minPageviews int64, rank stats.Ranking, limit int) (*stats.Trends, error) {
+	parsedID, err := uuid.Parse(websiteID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid website ID: %w", err)
+	}
+	return stats.GetTrends(ctx, db, parsedID, days, minPageviews, rank, limit)
+}
+
+func init() {
+	statsCmd.AddCommand(statsTrendingCmd)
+
+	statsTrendingCmd.Flags().IntVarP(&trendingDays, "days", "d", 7, "Period in days (1-90)")
+	statsTrendingCmd.Flags().IntVarP(&trendingTop, "top", "t", 10, "Gainers and losers to show (1-50)")
+	statsTrendingCmd.Flags().Int64Var(&trendingMin, "min", 10, "Minimum pageviews in either period")
+	statsTrendingCmd.Flags().StringVar(&trendingSort, "sort", "change", "Rank by change or percent")
+	statsTrendingCmd.Flags().StringVarP(&trendingFormat, "format", "f", "table", "Output format (json, table)")
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/stats"
+)
+
+func stubTrends(t *testing.T, fn func(context.Context, *sql.DB, string, int, int64, stats.Ranking, int) (*stats.Trends, error)) {
+	t.Helper()
+	original := getTrendsFn
+	getTrendsFn = fn
+	t.Cleanup(func() {
+		getTrendsFn = original
+	})
+}
+
+func TestRunStatsTrendingTable(t *testing.T) {
+	stubDB(t)
+	stubConnectClose(t)
+	stubWebsiteIDLookup(t, func(ctx context.Context, domain string) (string, error) {
+		return "site-123", nil
+	})
+	stubTrends(t, func(ctx context.Context, db *sql.DB, websiteID string, days int, minPageviews int64, rank stats.Ranking, limit int) (*stats.Trends, error) {
+		assert.Equal(t, "site-123", websiteID)
+		assert.Equal(t, 30, days)
+		assert.Equal(t, int64(50), minPageviews)
+		assert.Equal(t, stats.ByPercent, rank)
+		assert.Equal(t, 10, limit)
+		fifty := 50.0
+		return &stats.Trends{
+			Days: 30,
+			Pages: stats.MoverList{
+				Gainers: []stats.Mover{{Name: "/launch", After: 80, Change: 80}, {Name: "/", Before: 100, After: 150, Change: 50, ChangePercent: &fifty}},
+			},
+		}, nil
+	})
+
+	output, err := captureOutput(t, func() error {
+		return runStatsTrending("example.com", 30, 10, 50, "percent", "table")
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "last 30 days vs the 30 days before")
+	assert.Regexp(t, `/launch\s+0\s+80\s+\+80\s+new`, output)
+	assert.Regexp(t, `/\s+100\s+150\s+\+50\s+\+50.0%`, output)
+	assert.Contains(t, output, "Top Movers: Referrers\n  No changes")
+}
+
+func TestRunStatsTrendingValidation(t *testing.T) {
+	for _, tc := range []struct {
+		days, top int
+		min       int64
+		sort      string
+		format    string
+		want      string
+	}{
+		{0, 10, 10, "change", "table", "days must be between 1 and 90"},
+		{7, 51, 10, "change", "table", "top must be between 1 and 50"},
+		{7, 10, -1, "change", "table", "min must not be negative"},
+		{7, 10, 10, "ratio", "table", "invalid ranking"},
+		{7, 10, 10, "change", "csv", "invalid format"},
+	} {
+		err := runStatsTrending("example.com", tc.days, tc.top, tc.min, tc.sort, tc.format)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), tc.want)
+	}
+}
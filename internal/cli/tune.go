@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/tune"
+)
+
+// Tune command flags
+var (
+	tuneCPUs     int
+	tuneMemoryMB int
+	tuneWrite    bool
+	tuneFile     string
+)
+
+// inspectMachineFn describes the machine and database; tests replace it
+var inspectMachineFn = tune.Inspect
+
+var tuneCmd = &cobra.Command{
+	Use:   "tune [--cpus N] [--memory-mb N] [--write [--file <path>]]",
+	Short: "Recommend settings for this machine and its traffic",
+	Long: `Inspect the CPUs and memory available (container limits included), the
+database size and the events of the last 24 hours, then recommend the
+connection pool, ingest queue, cache TTL and rollup schedule settings.
+
+Without a database (DATABASE_URL unset or unreachable) the recommendations
+are for the machine alone. --cpus and --memory-mb plan for another machine.
+
+With --write the recommendations are written to the config file in use (or
+./kaunta.toml), replacing the keys it has and keeping everything else.
+Restart the server and workers to apply them.
+
+Examples:
+  kaunta tune
+  kaunta tune --cpus 8 --memory-mb 16384
+  kaunta tune --write`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTune(tuneCPUs, tuneMemoryMB, tuneWrite, tuneFile)
+	},
+}
+
+func runTune(cpus, memoryMB int, write bool, file string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var db *sql.DB
+	done, dbErr := ensureDatabase()
+	if dbErr == nil {
+		defer done()
+		db = database.DB
+	}
+	machine, err := inspectMachineFn(ctx, db)
+	if err != nil {
+		return err
+	}
+	if cpus > 0 {
+		machine.CPUs = cpus
+	}
+	if memoryMB > 0 {
+		machine.Memory = int64(memoryMB) << 20
+	}
+
+	l := outputLocale
+	memory := "unknown memory"
+	if machine.Memory > 0 {
+		memory = formatSize(machine.Memory) + " memory"
+	}
+	fmt.Printf("Machine:  %d CPUs, %s\n", machine.CPUs, memory)
+	if db == nil {
+		fmt.Printf("Database: not connected (%v)\n", dbErr)
+	} else {
+		fmt.Printf("Database: %s, %s events in the last 24 hours\n", formatSize(machine.DatabaseSize), l.Int(machine.EventsPerDay))
+	}
+	fmt.Printf("Size:     %s\n\n", tune.SizeOf(machine))
+
+	settings := tune.Recommend(machine)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "SETTING\tCURRENT\tRECOMMENDED\tWHY")
+	_, _ = fmt.Fprintln(w, "-------\t-------\t-----------\t---")
+	for _, s := range settings {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Key, currentSetting(cfg, s.Key), s.Value, s.Reason)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if !write {
+		fmt.Println("\nWrite them to the config file with --write.")
+		return nil
+	}
+	if file == "" {
+		if file = config.FilePath(); file == "" {
+			file = "kaunta.toml"
+		}
+	}
+	if err := tune.WriteConfig(file, settings); err != nil {
+		return err
+	}
+	fmt.Printf("\nWrote %s; restart the server and workers to apply it.\n", file)
+	return nil
+}
+
+// currentSetting is the configured value of a tuned key, "default" when unset
+func currentSetting(cfg *config.Config, key string) string {
+	var value string
+	switch key {
+	case "db_max_open_conns":
+		value = positive(cfg.DBMaxOpenConns)
+	case "db_max_idle_conns":
+		value = positive(cfg.DBMaxIdleConns)
+	case "ingest_queue_size":
+		value = positive(cfg.IngestQueueSize)
+	case "ingest_batch_size":
+		value = positive(cfg.IngestBatchSize)
+	case "cache_ttl":
+		value = shortDuration(cfg.CacheTTL)
+	case "rollup_interval":
+		value = shortDuration(cfg.RollupInterval)
+	}
+	if value == "" {
+		return "default"
+	}
+	return value
+}
+
+func positive(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// shortDuration writes 5m rather than 5m0s; "" for zero
+func shortDuration(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// formatSize writes bytes as 1.5 GiB
+func formatSize(bytes int64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	size := float64(bytes)
+	unit := 0
+	for size >= 1024 && unit < len(units)-1 {
+		size /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	return outputLocale.Float(size, 1) + " " + units[unit]
+}
+
+func init() {
+	RootCmd.AddCommand(tuneCmd)
+
+	tuneCmd.Flags().IntVar(&tuneCPUs, "cpus", 0, "CPUs to plan for (default: this machine's)")
+	tuneCmd.Flags().IntVar(&tuneMemoryMB, "memory-mb", 0, "Memory to plan for in MiB (default: this machine's)")
+	tuneCmd.Flags().BoolVar(&tuneWrite, "write", false, "Write the recommendations to the config file")
+	tuneCmd.Flags().StringVar(&tuneFile, "file", "", "Config file to write (default: the one in use, else ./kaunta.toml)")
+}
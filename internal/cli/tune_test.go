@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/tune"
+)
+
+func TestRunTune(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("CACHE_TTL", "30s")
+
+	stubDB(t)
+	stubConnectClose(t)
+	original := inspectMachineFn
+	inspectMachineFn = func(ctx context.Context, db *sql.DB) (tune.Machine, error) {
+		require.NotNil(t, db)
+		return tune.Machine{CPUs: 2, Memory: 4 << 30, DatabaseSize: 3 << 29, EventsPerDay: 250000}, nil
+	}
+	t.Cleanup(func() { inspectMachineFn = original })
+
+	output, err := captureOutput(t, func() error {
+		return runTune(0, 0, false, "")
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "Machine:  2 CPUs, 4.0 GiB memory")
+	assert.Contains(t, output, "Database: 1.5 GiB, 250000 events in the last 24 hours")
+	assert.Contains(t, output, "Size:     medium")
+	assert.Regexp(t, `db_max_open_conns\s+default\s+8\s`, output)
+	assert.Regexp(t, `cache_ttl\s+30s\s+2m\s`, output)
+
+	path := filepath.Join(t.TempDir(), "kaunta.toml")
+	_, err = captureOutput(t, func() error {
+		return runTune(16, 0, true, path)
+	})
+	require.NoError(t, err)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "db_max_open_conns = 64\n")
+	assert.Contains(t, string(data), "rollup_interval = \"10m\"\n")
+}
+
+func TestRunTuneWithoutDatabase(t *testing.T) {
+	original := inspectMachineFn
+	inspectMachineFn = func(ctx context.Context, db *sql.DB) (tune.Machine, error) {
+		assert.Nil(t, db)
+		return tune.Machine{CPUs: 1}, nil
+	}
+	t.Cleanup(func() { inspectMachineFn = original })
+	originalConnect := connectDatabase
+	connectDatabase = func() error { return errors.New("DATABASE_URL environment variable not set") }
+	t.Cleanup(func() { connectDatabase = originalConnect })
+
+	output, err := captureOutput(t, func() error {
+		return runTune(0, 0, false, "")
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "Machine:  1 CPUs, unknown memory")
+	assert.Contains(t, output, "Database: not connected (database connection failed: DATABASE_URL environment variable not set)")
+	assert.Contains(t, output, "Size:     small")
+}
+
+func TestCurrentSetting(t *testing.T) {
+	cfg := &config.Config{IngestBatchSize: 250, RollupInterval: time.Hour}
+	assert.Equal(t, "250", currentSetting(cfg, "ingest_batch_size"))
+	assert.Equal(t, "1h", currentSetting(cfg, "rollup_interval"))
+	assert.Equal(t, "default", currentSetting(cfg, "cache_ttl"))
+}
@@ -2,12 +2,15 @@ package cli
 
 import (
 	"bufio"
+	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/spf13/cobra"
@@ -15,6 +18,7 @@ import (
 
 	"github.com/seuros/kaunta/internal/database"
 	"github.com/seuros/kaunta/internal/logging"
+	"github.com/seuros/kaunta/internal/store"
 	"go.uber.org/zap"
 )
 
@@ -29,7 +33,8 @@ var userCreateCmd = &cobra.Command{
 	Short: "Create a new user",
 	Long: `Create a new user with username and password.
 
-The password will be securely hashed using PostgreSQL's pgcrypto extension.
+The password is hashed with bcrypt (pgcrypto on PostgreSQL).
+Works with both the PostgreSQL and SQLite backends.
 
 Example:
   kaunta user create admin`,
@@ -43,20 +48,22 @@ Example:
 		}
 
 		// Connect to database
-		if err := database.Connect(); err != nil {
+		st, err := openStore()
+		if err != nil {
 			return fmt.Errorf("database connection failed: %w", err)
 		}
-		defer func() { _ = database.Close() }()
+		defer func() { _ = st.Close() }()
+
+		ctx := context.Background()
 
 		// Check if user already exists
-		var exists bool
-		err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE username = $1)", username).Scan(&exists)
-		if err != nil {
-			return fmt.Errorf("failed to check existing user: %w", err)
-		}
-		if exists {
+		_, err = st.FindUser(ctx, username)
+		if err == nil {
 			return fmt.Errorf("user '%s' already exists", username)
 		}
+		if !errors.Is(err, store.ErrNotFound) {
+			return fmt.Errorf("failed to check existing user: %w", err)
+		}
 
 		// Get name (optional)
 		name, _ := cmd.Flags().GetString("name")
@@ -103,27 +110,8 @@ Example:
 			return fmt.Errorf("password must be at least 8 characters long")
 		}
 
-		// Create user (password hashed by PostgreSQL)
-		userID := uuid.New()
-		query := `
-			INSERT INTO users (user_id, username, password_hash, name)
-			VALUES ($1, $2, hash_password($3), NULLIF($4, ''))
-			RETURNING user_id, username, name, created_at
-		`
-
-		var user struct {
-			UserID    uuid.UUID
-			Username  string
-			Name      *string
-			CreatedAt string
-		}
-
-		err = database.DB.QueryRow(query, userID, username, password, name).Scan(
-			&user.UserID,
-			&user.Username,
-			&user.Name,
-			&user.CreatedAt,
-		)
+		// Create user (password hashed by the backend)
+		user, err := st.CreateUser(ctx, username, password, name)
 		if err != nil {
 			return fmt.Errorf("failed to create user: %w", err)
 		}
@@ -131,13 +119,13 @@ Example:
 		fmt.Printf("\n✓ User created successfully\n")
 		fmt.Printf("  ID:       %s\n", user.UserID)
 		fmt.Printf("  Username: %s\n", user.Username)
-		if user.Name != nil && *user.Name != "" {
-			fmt.Printf("  Name:     %s\n", *user.Name)
+		if user.Name.Valid && user.Name.String != "" {
+			fmt.Printf("  Name:     %s\n", user.Name.String)
 		}
 		if autoGenerated {
 			fmt.Printf("  Password: %s (auto-generated)\n", password)
 		}
-		fmt.Printf("  Created:  %s\n", user.CreatedAt)
+		fmt.Printf("  Created:  %s\n", user.CreatedAt.Format(time.RFC3339))
 
 		return nil
 	},
@@ -335,6 +323,15 @@ Examples:
 }
 
 // readPassword reads a password from stdin without echoing
+// openStore connects to the backend selected by DATABASE_URL (PostgreSQL or SQLite)
+func openStore() (store.Store, error) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		return nil, fmt.Errorf("DATABASE_URL environment variable not set")
+	}
+	return store.Open(databaseURL)
+}
+
 func readPassword(prompt string) (string, error) {
 	fmt.Print(prompt)
 	bytePassword, err := term.ReadPassword(int(syscall.Stdin))
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/buildinfo"
+	"github.com/seuros/kaunta/internal/chaos"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+var versionFormat string
+
+var versionCmd = &cobra.Command{
+	Use:   "version [--format text|json]",
+	Short: "Show build information",
+	Long: `Show the release, commit and build date of this binary, the Go version it
+was built with, its build tags and the optional features compiled in:
+
+  sqlite        SQLite backend (-tags sqlite, CGO_ENABLED=1)
+  clickhouse    ClickHouse event store
+  geoip         GeoIP lookups
+  self_upgrade  --self-upgrade (not in Docker images)
+
+The same information is served at /api/version.
+
+Examples:
+  kaunta version
+  kaunta version --format json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runVersion(versionFormat)
+	},
+}
+
+// currentBuildInfo describes the running binary
+func currentBuildInfo() buildinfo.Info {
+	return buildinfo.Get(Version, map[string]bool{
+		"sqlite":       store.SQLiteAvailable(),
+		"clickhouse":   true,
+		"geoip":        true,
+		"self_upgrade": selfUpgradeAvailable,
+		"chaos":        chaos.Enabled,
+	})
+}
+
+func runVersion(format string) error {
+	info := currentBuildInfo()
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	case "text":
+	default:
+		return fmt.Errorf("invalid format: %s (use text or json)", format)
+	}
+
+	version := info.Version
+	if version == "" {
+		version = "dev"
+	}
+	commit := orDash(info.ShortCommit())
+	if info.Modified {
+		commit += " (modified)"
+	}
+	var enabled []string
+	for _, name := range []string{"sqlite", "clickhouse", "geoip", "self_upgrade", "chaos"} {
+		if info.Features[name] {
+			enabled = append(enabled, name)
+		}
+	}
+
+	fmt.Printf("Kaunta %s\n", version)
+	fmt.Printf("Commit:     %s\n", commit)
+	fmt.Printf("Built:      %s\n", orDash(info.BuildDate))
+	fmt.Printf("Go:         %s %s/%s\n", info.GoVersion, info.OS, info.Arch)
+	fmt.Printf("Build tags: %s\n", orDash(strings.Join(info.Tags, ", ")))
+	fmt.Printf("Features:   %s\n", orDash(strings.Join(enabled, ", ")))
+	return nil
+}
+
+func init() {
+	RootCmd.AddCommand(versionCmd)
+	versionCmd.Flags().StringVarP(&versionFormat, "format", "f", "text", "Output format (text, json)")
+}
@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/visits"
+)
+
+var (
+	visitsDefinition string
+	visitsTimeout    time.Duration
+)
+
+var websiteVisitsCmd = &cobra.Command{
+	Use:   "visits <domain> [--definition hour|inactivity|default] [--timeout 30m]",
+	Short: "Show or set how a website's sessions are split into visits",
+	Long: `Show or set how a website's sessions are split into visits, which visits,
+bounces and time per visit are counted from.
+
+--definition hour makes a visit of the session's events within a clock hour
+(as Umami does); inactivity ends a visit once the session goes --timeout
+without events (as Google Analytics does). default and a timeout of 0 go
+back to the server's visit_definition and session_timeout. Events already
+stored keep their visits.
+
+Examples:
+  kaunta website visits example.com
+  kaunta website visits example.com --definition inactivity --timeout 30m
+  kaunta website visits example.com --definition default --timeout 0`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var definition *string
+		var timeout *time.Duration
+		if cmd.Flags().Changed("definition") {
+			definition = &visitsDefinition
+		}
+		if cmd.Flags().Changed("timeout") {
+			timeout = &visitsTimeout
+		}
+		return runWebsiteVisits(args[0], definition, timeout)
+	},
+}
+
+// runWebsiteVisits sets the rules given (nil leaves them as they are) and
+// shows them
+func runWebsiteVisits(domain string, definition *string, timeout *time.Duration) error {
+	var stored string
+	if definition != nil && *definition != "default" {
+		d, err := visits.ParseDefinition(*definition)
+		if err != nil {
+			return err
+		}
+		stored = string(d)
+	}
+	if timeout != nil {
+		if err := visits.ValidTimeout(*timeout); err != nil {
+			return err
+		}
+		if *timeout%time.Second != 0 {
+			return fmt.Errorf("session timeout must be whole seconds")
+		}
+	}
+
+	return withWebsite(domain, func(ctx context.Context, websiteID uuid.UUID, domain string) error {
+		if definition != nil || timeout != nil {
+			var seconds int
+			if timeout != nil {
+				seconds = int(*timeout / time.Second)
+			}
+			_, err := database.DB.ExecContext(ctx, `
+				UPDATE website SET
+					visit_definition = CASE WHEN $2 THEN NULLIF($3, '') ELSE visit_definition END,
+					session_timeout = CASE WHEN $4 THEN NULLIF($5, 0) ELSE session_timeout END,
+					updated_at = NOW()
+				WHERE website_id = $1`,
+				websiteID, definition != nil, stored, timeout != nil, seconds)
+			if err != nil {
+				return fmt.Errorf("failed to update visit rules: %w", err)
+			}
+		}
+
+		var storedTimeout int
+		if err := database.DB.QueryRowContext(ctx, `
+			SELECT COALESCE(visit_definition, ''), COALESCE(session_timeout, 0)
+			FROM website WHERE website_id = $1`, websiteID,
+		).Scan(&stored, &storedTimeout); err != nil {
+			return fmt.Errorf("failed to read visit rules: %w", err)
+		}
+
+		defaults := visits.Defaults()
+		rules := defaults.Override(stored, time.Duration(storedTimeout)*time.Second)
+		fmt.Printf("Visits of %s:\n", domain)
+		fmt.Printf("  Definition:      %s%s\n", rules.Definition, configuredMark(stored == ""))
+		fmt.Printf("  Session timeout: %s%s\n", formatTimeout(rules.Timeout), configuredMark(storedTimeout == 0))
+		return nil
+	})
+}
+
+// configuredMark marks a rule the website takes from the server's
+// configuration
+func configuredMark(configured bool) string {
+	if configured {
+		return " (server default)"
+	}
+	return ""
+}
+
+// formatTimeout shows a timeout without trailing zero units (30m, 1h30m)
+func formatTimeout(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+func init() {
+	websiteCmd.AddCommand(websiteVisitsCmd)
+
+	websiteVisitsCmd.Flags().StringVar(&visitsDefinition, "definition", "", "hour, inactivity or default")
+	websiteVisitsCmd.Flags().DurationVar(&visitsTimeout, "timeout", 0, "Time without events that ends a visit, 1m to 24h (0: default)")
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunWebsiteVisits(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectExec(`UPDATE website SET`).
+		WithArgs(websiteID, true, "inactivity", false, 0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT COALESCE\(visit_definition, ''\)`).WithArgs(websiteID).
+		WillReturnRows(sqlmock.NewRows([]string{"visit_definition", "session_timeout"}).AddRow("inactivity", 0))
+
+	definition := "inactivity"
+	output, err := captureOutput(t, func() error { return runWebsiteVisits("example.com", &definition, nil) })
+	require.NoError(t, err)
+	assert.Contains(t, output, "Definition:      inactivity\n")
+	assert.Contains(t, output, "Session timeout: 30m (server default)")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunWebsiteVisitsResetsToDefaults(t *testing.T) {
+	mock := mockJobsDB(t)
+	websiteID := uuid.New()
+
+	expectWebsiteLookup(mock, websiteID, "example.com")
+	mock.ExpectExec(`UPDATE website SET`).
+		WithArgs(websiteID, true, "", true, 0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT COALESCE\(visit_definition, ''\)`).WithArgs(websiteID).
+		WillReturnRows(sqlmock.NewRows([]string{"visit_definition", "session_timeout"}).AddRow("", 0))
+
+	definition, timeout := "default", time.Duration(0)
+	output, err := captureOutput(t, func() error { return runWebsiteVisits("example.com", &definition, &timeout) })
+	require.NoError(t, err)
+	assert.Contains(t, output, "Definition:      hour (server default)")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunWebsiteVisitsRejectsBadValues(t *testing.T) {
+	definition := "day"
+	assert.ErrorContains(t, runWebsiteVisits("example.com", &definition, nil), "invalid visit definition")
+	timeout := 10 * time.Second
+	assert.ErrorContains(t, runWebsiteVisits("example.com", nil, &timeout), "between 1m0s and 24h0m0s")
+	timeout = 90*time.Second + time.Millisecond
+	assert.ErrorContains(t, runWebsiteVisits("example.com", nil, &timeout), "whole seconds")
+}
+
+func TestFormatTimeout(t *testing.T) {
+	assert.Equal(t, "30m", formatTimeout(30*time.Minute))
+	assert.Equal(t, "1h", formatTimeout(time.Hour))
+	assert.Equal(t, "1h30m", formatTimeout(90*time.Minute))
+	assert.Equal(t, "1m30s", formatTimeout(90*time.Second))
+}
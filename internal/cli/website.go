@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/store"
 	"github.com/spf13/cobra"
 )
 
@@ -95,25 +96,44 @@ Examples:
 
 // Update command flags
 var (
-	updateName    string
-	updateAllowed string
+	updateName       string
+	updateAllowed    string
+	updateRespectDNT string
+	updateTimezone   string
 )
 
 var websiteUpdateCmd = &cobra.Command{
-	Use:   "update <domain> [--name <new-name>] [--allowed <domains-csv>]",
+	Use:   "update <domain> [--name <new-name>] [--allowed <domains-csv>] [--respect-dnt <off|drop|anonymize>] [--timezone <zone>]",
 	Short: "Update a website",
 	Long: `Update the configuration of an existing website.
 
 You can update:
   - name: Display name
   - allowed: Allowed CORS domains
+  - respect-dnt: What happens to visitors sending Do-Not-Track (DNT: 1) or
+    Global Privacy Control (Sec-GPC: 1): off tracks them as usual (the
+    default), drop records nothing, anonymize records them with a
+    daily-salted visitor hash and no distinct ID, as with ip_mode = "hash"
+  - timezone: The IANA time zone (Europe/Berlin, America/New_York) the
+    website's days start in: "today", N-day periods, the hourly time series
+    and the daily rollups. UTC by default
 
 Examples:
   kaunta website update example.com --name "Updated Name"
-  kaunta website update example.com --allowed "example.com,new.example.com"`,
+  kaunta website update example.com --allowed "example.com,new.example.com"
+  kaunta website update example.com --respect-dnt drop
+  kaunta website update example.com --timezone Europe/Berlin`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runWebsiteUpdate(args[0], updateName, updateAllowed)
+		var respectDNT *string
+		if cmd.Flags().Changed("respect-dnt") {
+			respectDNT = &updateRespectDNT
+		}
+		var timezone *string
+		if cmd.Flags().Changed("timezone") {
+			timezone = &updateTimezone
+		}
+		return runWebsiteUpdate(args[0], updateName, updateAllowed, respectDNT, timezone)
 	},
 }
 
@@ -287,7 +307,17 @@ func runWebsiteShow(domain, format string) error {
 }
 
 func runWebsiteCreate(domain, name, allowedCSV string) error {
-	if database.DB == nil {
+	createWebsite := createWebsiteFunc
+	if databaseURL := os.Getenv("DATABASE_URL"); database.IsSQLiteURL(databaseURL) {
+		st, err := store.OpenSQLite(databaseURL)
+		if err != nil {
+			return fmt.Errorf("database connection failed: %w", err)
+		}
+		defer func() { _ = st.Close() }()
+		createWebsite = func(ctx context.Context, domain, name string, allowedDomains []string) (*WebsiteDetail, error) {
+			return CreateWebsiteInStore(ctx, st, domain, name, allowedDomains)
+		}
+	} else if database.DB == nil {
 		if err := connectDatabase(); err != nil {
 			return fmt.Errorf("database connection failed: %w", err)
 		}
@@ -323,7 +353,7 @@ func runWebsiteCreate(domain, name, allowedCSV string) error {
 		}
 	}
 
-	website, err := createWebsiteFunc(ctx, domain, name, allowedDomains)
+	website, err := createWebsite(ctx, domain, name, allowedDomains)
 	if err != nil {
 		return err
 	}
@@ -339,7 +369,7 @@ func runWebsiteCreate(domain, name, allowedCSV string) error {
 	return nil
 }
 
-func runWebsiteUpdate(domain, name, allowedCSV string) error {
+func runWebsiteUpdate(domain, name, allowedCSV string, respectDNT, timezone *string) error {
 	if database.DB == nil {
 		if err := connectDatabase(); err != nil {
 			return fmt.Errorf("database connection failed: %w", err)
@@ -347,8 +377,25 @@ func runWebsiteUpdate(domain, name, allowedCSV string) error {
 		defer func() { _ = closeDatabase() }()
 	}
 
-	if name == "" && allowedCSV == "" {
-		return fmt.Errorf("must specify at least one option: --name or --allowed")
+	if name == "" && allowedCSV == "" && respectDNT == nil && timezone == nil {
+		return fmt.Errorf("must specify at least one option: --name, --allowed, --respect-dnt or --timezone")
+	}
+	if respectDNT != nil {
+		value := strings.ToLower(strings.TrimSpace(*respectDNT))
+		switch value {
+		case "off", "drop", "anonymize":
+			respectDNT = &value
+		default:
+			return fmt.Errorf("invalid --respect-dnt: %s (use off, drop or anonymize)", *respectDNT)
+		}
+	}
+	if timezone != nil {
+		value := strings.TrimSpace(*timezone)
+		// Local is the server's zone, which PostgreSQL doesn't know by name
+		if _, err := time.LoadLocation(value); err != nil || value == "" || value == "Local" {
+			return fmt.Errorf("invalid --timezone: %s (use an IANA name such as Europe/Berlin, or UTC)", *timezone)
+		}
+		timezone = &value
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -364,7 +411,7 @@ func runWebsiteUpdate(domain, name, allowedCSV string) error {
 		allowedDomains = ParseAllowedDomains(allowedCSV)
 	}
 
-	website, err := updateWebsiteFunc(ctx, domain, namePtr, allowedDomains)
+	website, err := updateWebsiteFunc(ctx, domain, namePtr, allowedDomains, respectDNT, timezone)
 	if err != nil {
 		return err
 	}
@@ -372,6 +419,12 @@ func runWebsiteUpdate(domain, name, allowedCSV string) error {
 	fmt.Println("Website updated successfully!")
 	fmt.Println()
 	_ = outputSingleTable(website)
+	if respectDNT != nil {
+		fmt.Printf("Do-Not-Track: %s\n", *respectDNT)
+	}
+	if timezone != nil {
+		fmt.Printf("Time zone: %s\n", *timezone)
+	}
 
 	return nil
 }
@@ -712,6 +765,8 @@ func init() {
 	// Update command flags
 	websiteUpdateCmd.Flags().StringVarP(&updateName, "name", "n", "", "New display name for the website")
 	websiteUpdateCmd.Flags().StringVarP(&updateAllowed, "allowed", "a", "", "Comma-separated list of allowed CORS domains")
+	websiteUpdateCmd.Flags().StringVar(&updateRespectDNT, "respect-dnt", "", "Handling of DNT/GPC visitors: off, drop or anonymize")
+	websiteUpdateCmd.Flags().StringVar(&updateTimezone, "timezone", "", "IANA time zone the website's days start in (e.g. Europe/Berlin)")
 
 	// Delete command flags
 	websiteDeleteCmd.Flags().BoolVarP(&deleteForce, "force", "f", false, "Skip confirmation prompt")
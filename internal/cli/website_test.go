@@ -27,6 +27,14 @@ func stubRemoveAllowedDomain(t *testing.T, fn func(ctx context.Context, websiteD
 	})
 }
 
+func stubUpdateWebsite(t *testing.T, fn func(ctx context.Context, domain string, name *string, allowedDomains []string, respectDNT, timezone *string) (*WebsiteDetail, error)) {
+	original := updateWebsiteFunc
+	updateWebsiteFunc = fn
+	t.Cleanup(func() {
+		updateWebsiteFunc = original
+	})
+}
+
 func TestParseAllowedDomains(t *testing.T) {
 	assert.Empty(t, ParseAllowedDomains(""))
 	assert.Equal(t, []string{"example.com"}, ParseAllowedDomains("example.com"))
@@ -220,6 +228,60 @@ func TestRunRemoveDomainError(t *testing.T) {
 	assert.Contains(t, err.Error(), "no such domain")
 }
 
+func TestRunWebsiteUpdateRespectDNT(t *testing.T) {
+	stubDB(t)
+	stubConnectClose(t)
+
+	stubUpdateWebsite(t, func(ctx context.Context, domain string, name *string, allowedDomains []string, respectDNT, timezone *string) (*WebsiteDetail, error) {
+		assert.Nil(t, name)
+		require.NotNil(t, respectDNT)
+		assert.Equal(t, "anonymize", *respectDNT)
+		return sampleWebsite(), nil
+	})
+
+	value := " Anonymize "
+	output, err := captureOutput(t, func() error {
+		return runWebsiteUpdate("example.com", "", "", &value, nil)
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "Do-Not-Track: anonymize")
+
+	value = "ignore"
+	_, err = captureOutput(t, func() error {
+		return runWebsiteUpdate("example.com", "", "", &value, nil)
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid --respect-dnt")
+}
+
+func TestRunWebsiteUpdateTimezone(t *testing.T) {
+	stubDB(t)
+	stubConnectClose(t)
+
+	stubUpdateWebsite(t, func(ctx context.Context, domain string, name *string, allowedDomains []string, respectDNT, timezone *string) (*WebsiteDetail, error) {
+		assert.Nil(t, respectDNT)
+		require.NotNil(t, timezone)
+		assert.Equal(t, "Europe/Berlin", *timezone)
+		return sampleWebsite(), nil
+	})
+
+	value := " Europe/Berlin "
+	output, err := captureOutput(t, func() error {
+		return runWebsiteUpdate("example.com", "", "", nil, &value)
+	})
+	require.NoError(t, err)
+	assert.Contains(t, output, "Time zone: Europe/Berlin")
+
+	for _, invalid := range []string{"Mars/Olympus", "", "Local"} {
+		value = invalid
+		_, err = captureOutput(t, func() error {
+			return runWebsiteUpdate("example.com", "", "", nil, &value)
+		})
+		require.Error(t, err, invalid)
+		assert.Contains(t, err.Error(), "invalid --timezone")
+	}
+}
+
 func sampleWebsite() *WebsiteDetail {
 	share := "public"
 	return &WebsiteDetail{
@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/logging"
+	"github.com/seuros/kaunta/internal/worker"
+	"go.uber.org/zap"
+)
+
+var (
+	workerOnce        bool
+	workerList        bool
+	workerTasks       string
+	workerMetricsAddr string
+)
+
+var workerCmd = &cobra.Command{
+	Use:   "worker [--once] [--tasks a,b] [--list]",
+	Short: "Run background jobs without the HTTP server",
+	Long: `Run Kaunta's scheduled background tasks (partition maintenance, session
+cleanup, rollups, pruning, reports, exports) without serving HTTP traffic.
+
+Run this on a separate machine to keep heavy maintenance away from the
+ingest path, and set embedded_jobs = false on the servers. Each task run
+takes a PostgreSQL advisory lock, so several workers (or servers with
+embedded jobs) can share a database without running a task twice.
+
+Options:
+  --once          Run each task once and exit (for cron)
+  --tasks a,b     Only run the named tasks
+  --list          List available tasks and exit
+  --metrics-addr  Serve Prometheus metrics on this address (e.g. :9090)`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if workerList {
+			return printWorkerTasks()
+		}
+		return runWorker(splitList(workerTasks), workerOnce)
+	},
+}
+
+// splitList splits a comma-separated flag value
+func splitList(value string) []string {
+	var items []string
+	for _, part := range strings.Split(value, ",") {
+		if item := strings.TrimSpace(part); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func printWorkerTasks() error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "Task\tInterval\tDescription")
+	_, _ = fmt.Fprintln(w, "----\t--------\t-----------")
+	for _, t := range worker.Tasks() {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", t.Name, t.Interval, t.Description)
+	}
+	return w.Flush()
+}
+
+func runWorker(names []string, once bool) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	worker.SetInterval("rollups", cfg.RollupInterval)
+
+	tasks, err := worker.Select(names)
+	if err != nil {
+		return err
+	}
+
+	if database.DB == nil {
+		if err := connectDatabase(); err != nil {
+			return fmt.Errorf("database connection failed: %w", err)
+		}
+		defer func() { _ = closeDatabase() }()
+		database.SetPool(cfg.DBMaxOpenConns, cfg.DBMaxIdleConns)
+	}
+
+	runner := &worker.Runner{Tasks: tasks, Locker: worker.AdvisoryLocker{DB: database.DB}}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if once {
+		var failed int
+		for _, t := range tasks {
+			ran, err := runner.RunOnce(ctx, t)
+			switch {
+			case err != nil:
+				failed++
+				fmt.Printf("%-20s failed: %v\n", t.Name, err)
+			case !ran:
+				fmt.Printf("%-20s skipped (running elsewhere)\n", t.Name)
+			default:
+				fmt.Printf("%-20s ok\n", t.Name)
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d task(s) failed", failed)
+		}
+		return nil
+	}
+
+	if workerMetricsAddr != "" {
+		srv := &http.Server{Addr: workerMetricsAddr, Handler: metricsHandler(database.DB), ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logging.L().Error("metrics server failed", zap.Error(err))
+			}
+		}()
+		defer func() { _ = srv.Close() }()
+	}
+
+	logging.L().Info("worker started", zap.Int("tasks", len(tasks)))
+	runner.Run(ctx)
+	logging.L().Info("worker stopped")
+	return nil
+}
+
+func init() {
+	RootCmd.AddCommand(workerCmd)
+
+	workerCmd.Flags().BoolVar(&workerOnce, "once", false, "Run each task once and exit")
+	workerCmd.Flags().BoolVar(&workerList, "list", false, "List available tasks and exit")
+	workerCmd.Flags().StringVar(&workerTasks, "tasks", "", "Comma-separated task names to run (default all)")
+	workerCmd.Flags().StringVar(&workerMetricsAddr, "metrics-addr", "", "Serve Prometheus metrics on this address")
+}
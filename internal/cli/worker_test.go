@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/worker"
+)
+
+func TestPrintWorkerTasks(t *testing.T) {
+	output, err := captureOutput(t, printWorkerTasks)
+	require.NoError(t, err)
+	assert.Contains(t, output, "Task")
+	assert.Contains(t, output, "partitions")
+	assert.Contains(t, output, "expired-sessions")
+}
+
+func TestRunWorkerOnce(t *testing.T) {
+	stubConnectClose(t)
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	originalDB := database.DB
+	database.DB = mockDB
+	t.Cleanup(func() {
+		database.DB = originalDB
+		_ = mockDB.Close()
+	})
+
+	worker.Register(worker.Task{Name: "test-ok", Run: func(ctx context.Context) error { return nil }})
+	worker.Register(worker.Task{Name: "test-fail", Run: func(ctx context.Context) error { return errors.New("boom") }})
+
+	for _, held := range []bool{true, false, true} {
+		mock.ExpectQuery(`SELECT pg_try_advisory_lock`).
+			WillReturnRows(sqlmock.NewRows([]string{"ok"}).AddRow(held))
+		if held {
+			mock.ExpectExec(`SELECT pg_advisory_unlock`).WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+	}
+
+	output, err := captureOutput(t, func() error {
+		return runWorker([]string{"test-ok", "test-ok", "test-fail"}, true)
+	})
+	assert.EqualError(t, err, "1 task(s) failed")
+	assert.Contains(t, output, "test-ok              ok")
+	assert.Contains(t, output, "skipped (running elsewhere)")
+	assert.Contains(t, output, "test-fail            failed: test-fail: boom")
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = captureOutput(t, func() error { return runWorker([]string{"missing"}, true) })
+	assert.EqualError(t, err, "unknown task: missing")
+}
+
+func TestSplitList(t *testing.T) {
+	assert.Equal(t, []string{"a", "b"}, splitList(" a, ,b "))
+	assert.Nil(t, splitList(""))
+}
@@ -1,9 +1,12 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -15,6 +18,197 @@ type Config struct {
 	DataDir        string
 	SecureCookies  bool
 	TrustedOrigins []string
+
+	// Offline disables every outbound network call (air-gapped deployments)
+	Offline bool
+
+	// GeoIPProvider is the geolocation database: maxmind (default), dbip or
+	// ip2location. MaxMindLicenseKey downloads GeoLite2-City from MaxMind
+	// instead of the public mirror; IP2LocationToken downloads IP2Location
+	// LITE DB3. GeoIPUpdateInterval is how often the running server
+	// refreshes the database (default weekly; negative disables it).
+	GeoIPProvider       string
+	MaxMindLicenseKey   string
+	IP2LocationToken    string
+	GeoIPUpdateInterval time.Duration
+
+	// Column encryption (optional). EncryptionKey is a base64-encoded 32-byte
+	// AES key; EncryptionKeyFile points at a file holding it (e.g. a secret
+	// mounted by a KMS agent). Previous keys are only used for decryption.
+	EncryptionKey          string
+	EncryptionKeyFile      string
+	EncryptionPreviousKeys []string
+
+	// EventStore selects where website_event lives: "postgres" (default) or
+	// "clickhouse", which also requires ClickHouseURL.
+	EventStore    string
+	ClickHouseURL string
+
+	// Storage is the object storage shared by archives, exports and reports
+	Storage StorageConfig
+
+	// SMTP sends alert emails
+	SMTP SMTPConfig
+
+	// Notify is the Slack and Discord webhooks websites post to, unless
+	// they set their own
+	Notify NotifyConfig
+
+	// Batched ingestion: events are buffered (up to IngestQueueSize) and
+	// written IngestBatchSize at a time, at least every IngestFlushInterval.
+	// Zero values use the ingest package defaults.
+	IngestQueueSize     int
+	IngestBatchSize     int
+	IngestFlushInterval time.Duration
+
+	// IngestRateLimitIP and IngestRateLimitWebsite are the tracking requests
+	// per minute accepted from each client IP (default 300) and for each
+	// website (default unlimited); past them clients get 429. 0 means
+	// unlimited.
+	IngestRateLimitIP      int
+	IngestRateLimitWebsite int
+
+	// DedupWindow drops an event identical to one of the same session (same
+	// type, name and URL) received within it, as double-fired SPA hooks and
+	// retries send; default 2s, 0 keeps every event
+	DedupWindow time.Duration
+
+	// PayloadValidation is what becomes of tracking payloads with values over
+	// their column's size, too many properties or values of the wrong type:
+	// truncate (default) cuts and converts them, reject answers 400 with the
+	// fields at fault
+	PayloadValidation string
+
+	// VisitDefinition splits sessions into visits by clock hour ("hour",
+	// default) or after SessionTimeout without events ("inactivity");
+	// SessionTimeout (default 30 minutes) also ends hourly visits continued
+	// by umami.js's cache token. Websites can override both.
+	VisitDefinition string
+	SessionTimeout  time.Duration
+
+	// EmailRecipients records the per-recipient tokens of email opens and
+	// clicks, so opens are counted per reader; off (default) they are
+	// dropped. They are never kept under ip_mode = "hash" or Do-Not-Track.
+	EmailRecipients bool
+
+	// DBMaxOpenConns and DBMaxIdleConns size the PostgreSQL connection pool
+	// of the server; zero keeps the database/sql defaults (no limit, 2 idle).
+	DBMaxOpenConns int
+	DBMaxIdleConns int
+
+	// CacheTTL is how long the tracking endpoint reuses a website's
+	// exclusion rules and custom dimensions (default 1 minute)
+	CacheTTL time.Duration
+
+	// RollupInterval is how often the rollups task aggregates recent events
+	// (default 5 minutes)
+	RollupInterval time.Duration
+
+	// AdaptiveSampling keeps one visitor in N while the ingest buffer is
+	// more than SamplingQueueThreshold full (0-1) or batch writes take longer
+	// than SamplingLatencyThreshold, with N doubling up to SamplingMaxRate.
+	// Zero values use the ingest package defaults.
+	AdaptiveSampling         bool
+	SamplingQueueThreshold   float64
+	SamplingLatencyThreshold time.Duration
+	SamplingMaxRate          int
+
+	// EmbeddedJobs runs the background task scheduler inside the server.
+	// Disable it when a dedicated `kaunta worker` handles maintenance.
+	EmbeddedJobs bool
+
+	// Metrics exposes Prometheus metrics at /metrics
+	Metrics bool
+
+	// TracingEndpoint is an OTLP/HTTP collector URL; empty disables tracing.
+	// TracingSampleRatio is the share of requests without a sampled
+	// traceparent that are traced (default 1).
+	// TracingHeaders are added to every export request (collector API keys).
+	TracingEndpoint    string
+	TracingSampleRatio float64
+	TracingHeaders     map[string]string
+
+	// LogLevel is debug, info (default), warn or error; LogFormat is console
+	// (default) or json. AccessLog writes one line per HTTP request with its
+	// status and latency (default on).
+	LogLevel  string
+	LogFormat string
+	AccessLog bool
+
+	// IPMode is how visitor IPs are handled before anything is stored:
+	// full (default), truncate or hash (see internal/privacy)
+	IPMode string
+
+	// CardinalityCap is the distinct values per dimension (page, referrer,
+	// UTM parameters, event names) a website may record per day; new values
+	// past it are recorded as "(other)". 0 disables the quota.
+	CardinalityCap int
+
+	// RetentionDays deletes events older than this many days (0 keeps them
+	// forever); websites can override it with `kaunta website retention`
+	RetentionDays int
+
+	// APIRateLimit is the requests per minute and APIDailyQuota the requests
+	// per UTC day allowed to each API token (Authorization: Bearer); 0 means
+	// unlimited. Tokens can override both (`kaunta user token create`).
+	APIRateLimit  int
+	APIDailyQuota int
+
+	// APICORSOrigins are the origins (e.g. https://grafana.example.com, or
+	// "*" for any) whose pages may call the stats and dashboard API; none by
+	// default. The tracking endpoint keeps its per-website origin checks.
+	// APICORSCredentials lets listed origins send the dashboard cookie, and
+	// APICORSMaxAge is how long browsers cache preflights (seconds).
+	APICORSOrigins     []string
+	APICORSCredentials bool
+	APICORSMaxAge      int
+
+	// Telemetry sends the project an anonymous weekly usage report (see
+	// internal/telemetry) to TelemetryEndpoint; off unless opted in.
+	// `kaunta telemetry preview` prints the report either way.
+	Telemetry         bool
+	TelemetryEndpoint string
+
+	// TrackerPath and CollectPath serve the tracker script (e.g.
+	// /assets/app.js) and the tracking endpoint (e.g. /events, with
+	// /events/batch) under paths of the site's choosing, next to the default
+	// ones; the script is rewritten to use them. See `kaunta tracker`.
+	TrackerPath string
+	CollectPath string
+
+	// Features switches feature flags on (name) or off (-name) for the
+	// whole instance; the database can override them (see internal/features)
+	Features map[string]bool
+}
+
+// StorageConfig selects and configures the object storage backend
+type StorageConfig struct {
+	Backend   string // local (default), s3 or gcs
+	Path      string // local root; defaults to <data_dir>/storage
+	Bucket    string
+	Prefix    string // key prefix inside the bucket
+	Endpoint  string // S3-compatible endpoint; empty means AWS or GCS
+	Region    string
+	AccessKey string
+	SecretKey string
+	Insecure  bool // plain HTTP, for local MinIO
+}
+
+// SMTPConfig is the mail server emails are sent through
+type SMTPConfig struct {
+	Host     string
+	Port     string // default 587
+	Username string // empty sends without authentication
+	Password string
+	From     string
+}
+
+// NotifyConfig is the default chat notifications of websites
+type NotifyConfig struct {
+	SlackWebhookURL   string
+	DiscordWebhookURL string
+	// DailySummary posts yesterday's numbers of every website each morning
+	DailySummary bool
 }
 
 // Load loads configuration from multiple sources with priority:
@@ -27,6 +221,15 @@ func Load() (*Config, error) {
 	return buildConfig(v, "", "", ""), nil
 }
 
+// FilePath returns the config file Load reads, or "" when there is none
+func FilePath() string {
+	v := newBaseViper()
+	if err := v.ReadInConfig(); err != nil {
+		return ""
+	}
+	return v.ConfigFileUsed()
+}
+
 // LoadWithOverrides loads config and applies flag overrides
 func LoadWithOverrides(databaseURL, port, dataDir string) (*Config, error) {
 	v := newBaseViper()
@@ -57,10 +260,22 @@ func newBaseViper() *viper.Viper {
 
 func buildConfig(v *viper.Viper, overrideDatabaseURL, overridePort, overrideDataDir string) *Config {
 	cfg := &Config{
-		Port:           "3000",
-		DataDir:        "./data",
-		SecureCookies:  true, // Default to secure (safe for production/HTTPS proxies)
-		TrustedOrigins: []string{"localhost"},
+		Port:               "3000",
+		DataDir:            "./data",
+		SecureCookies:      true, // Default to secure (safe for production/HTTPS proxies)
+		TrustedOrigins:     []string{"localhost"},
+		EventStore:         "postgres",
+		Storage:            StorageConfig{Backend: "local"},
+		SMTP:               SMTPConfig{Port: "587"},
+		EmbeddedJobs:       true,
+		AdaptiveSampling:   true,
+		TracingSampleRatio: 1,
+		AccessLog:          true,
+		APIRateLimit:       600,
+		IngestRateLimitIP:  300,
+		DedupWindow:        2 * time.Second,
+		APICORSMaxAge:      600,
+		CardinalityCap:     10000,
 	}
 
 	// Apply config file values
@@ -79,6 +294,158 @@ func buildConfig(v *viper.Viper, overrideDatabaseURL, overridePort, overrideData
 	if v.IsSet("secure_cookies") {
 		cfg.SecureCookies = v.GetBool("secure_cookies")
 	}
+	if v.IsSet("offline") {
+		cfg.Offline = v.GetBool("offline")
+	}
+	if v.IsSet("geoip_provider") {
+		cfg.GeoIPProvider = strings.ToLower(v.GetString("geoip_provider"))
+	}
+	if v.IsSet("ip2location_token") {
+		cfg.IP2LocationToken = v.GetString("ip2location_token")
+	}
+	if v.IsSet("maxmind_license_key") {
+		cfg.MaxMindLicenseKey = v.GetString("maxmind_license_key")
+	}
+	if v.IsSet("geoip_update_interval") {
+		cfg.GeoIPUpdateInterval = v.GetDuration("geoip_update_interval")
+	}
+	if v.IsSet("encryption_key") {
+		cfg.EncryptionKey = v.GetString("encryption_key")
+	}
+	if v.IsSet("encryption_key_file") {
+		cfg.EncryptionKeyFile = v.GetString("encryption_key_file")
+	}
+	if v.IsSet("encryption_previous_keys") {
+		cfg.EncryptionPreviousKeys = parseList(v.GetString("encryption_previous_keys"))
+	}
+	if v.IsSet("event_store") {
+		cfg.EventStore = strings.ToLower(v.GetString("event_store"))
+	}
+	if v.IsSet("clickhouse_url") {
+		cfg.ClickHouseURL = v.GetString("clickhouse_url")
+	}
+	applyStorageConfig(v, &cfg.Storage)
+	applySMTPConfig(v, &cfg.SMTP)
+	applyNotifyConfig(v, &cfg.Notify)
+	if v.IsSet("ingest_queue_size") {
+		cfg.IngestQueueSize = v.GetInt("ingest_queue_size")
+	}
+	if v.IsSet("ingest_batch_size") {
+		cfg.IngestBatchSize = v.GetInt("ingest_batch_size")
+	}
+	if v.IsSet("ingest_flush_interval") {
+		cfg.IngestFlushInterval = v.GetDuration("ingest_flush_interval")
+	}
+	if v.IsSet("db_max_open_conns") {
+		cfg.DBMaxOpenConns = v.GetInt("db_max_open_conns")
+	}
+	if v.IsSet("db_max_idle_conns") {
+		cfg.DBMaxIdleConns = v.GetInt("db_max_idle_conns")
+	}
+	if v.IsSet("cache_ttl") {
+		cfg.CacheTTL = v.GetDuration("cache_ttl")
+	}
+	if v.IsSet("rollup_interval") {
+		cfg.RollupInterval = v.GetDuration("rollup_interval")
+	}
+	if v.IsSet("adaptive_sampling") {
+		cfg.AdaptiveSampling = v.GetBool("adaptive_sampling")
+	}
+	if v.IsSet("sampling_queue_threshold") {
+		cfg.SamplingQueueThreshold = v.GetFloat64("sampling_queue_threshold")
+	}
+	if v.IsSet("sampling_latency_threshold") {
+		cfg.SamplingLatencyThreshold = v.GetDuration("sampling_latency_threshold")
+	}
+	if v.IsSet("sampling_max_rate") {
+		cfg.SamplingMaxRate = v.GetInt("sampling_max_rate")
+	}
+	if v.IsSet("embedded_jobs") {
+		cfg.EmbeddedJobs = v.GetBool("embedded_jobs")
+	}
+	if v.IsSet("metrics") {
+		cfg.Metrics = v.GetBool("metrics")
+	}
+	if v.IsSet("retention_days") {
+		cfg.RetentionDays = v.GetInt("retention_days")
+	}
+	if v.IsSet("telemetry") {
+		cfg.Telemetry = v.GetBool("telemetry")
+	}
+	if v.IsSet("telemetry_endpoint") {
+		cfg.TelemetryEndpoint = v.GetString("telemetry_endpoint")
+	}
+	if v.IsSet("tracker_path") {
+		cfg.TrackerPath = v.GetString("tracker_path")
+	}
+	if v.IsSet("collect_path") {
+		cfg.CollectPath = v.GetString("collect_path")
+	}
+	if v.IsSet("cardinality_cap") {
+		cfg.CardinalityCap = v.GetInt("cardinality_cap")
+	}
+	if v.IsSet("ip_mode") {
+		cfg.IPMode = strings.ToLower(v.GetString("ip_mode"))
+	}
+	if v.IsSet("tracing_endpoint") {
+		cfg.TracingEndpoint = v.GetString("tracing_endpoint")
+	}
+	if v.IsSet("tracing_sample_ratio") {
+		cfg.TracingSampleRatio = v.GetFloat64("tracing_sample_ratio")
+	}
+	if v.IsSet("tracing_headers") {
+		cfg.TracingHeaders = v.GetStringMapString("tracing_headers")
+	}
+	if v.IsSet("log_level") {
+		cfg.LogLevel = strings.ToLower(v.GetString("log_level"))
+	}
+	if v.IsSet("log_format") {
+		cfg.LogFormat = strings.ToLower(v.GetString("log_format"))
+	}
+	if v.IsSet("access_log") {
+		cfg.AccessLog = v.GetBool("access_log")
+	}
+	if v.IsSet("ingest_rate_limit_ip") {
+		cfg.IngestRateLimitIP = v.GetInt("ingest_rate_limit_ip")
+	}
+	if v.IsSet("ingest_rate_limit_website") {
+		cfg.IngestRateLimitWebsite = v.GetInt("ingest_rate_limit_website")
+	}
+	if v.IsSet("dedup_window") {
+		cfg.DedupWindow = v.GetDuration("dedup_window")
+	}
+	if v.IsSet("payload_validation") {
+		cfg.PayloadValidation = strings.ToLower(v.GetString("payload_validation"))
+	}
+	if v.IsSet("visit_definition") {
+		cfg.VisitDefinition = strings.ToLower(v.GetString("visit_definition"))
+	}
+	if v.IsSet("session_timeout") {
+		cfg.SessionTimeout = v.GetDuration("session_timeout")
+	}
+	if v.IsSet("email_recipients") {
+		cfg.EmailRecipients = v.GetBool("email_recipients")
+	}
+	if v.IsSet("api_rate_limit") {
+		cfg.APIRateLimit = v.GetInt("api_rate_limit")
+	}
+	if v.IsSet("api_daily_quota") {
+		cfg.APIDailyQuota = v.GetInt("api_daily_quota")
+	}
+	if v.IsSet("api_cors_origins") {
+		// Either a TOML array or a comma-separated string
+		cfg.APICORSOrigins = parseList(strings.Join(v.GetStringSlice("api_cors_origins"), ","))
+	}
+	if v.IsSet("api_cors_credentials") {
+		cfg.APICORSCredentials = v.GetBool("api_cors_credentials")
+	}
+	if v.IsSet("api_cors_max_age") {
+		cfg.APICORSMaxAge = v.GetInt("api_cors_max_age")
+	}
+	if v.IsSet("features") {
+		// Either a TOML array or a comma-separated string
+		cfg.Features = parseFeatures(strings.Join(v.GetStringSlice("features"), ","))
+	}
 
 	// Environment fallback (only if not configured)
 	if cfg.DatabaseURL == "" {
@@ -105,6 +472,175 @@ func buildConfig(v *viper.Viper, overrideDatabaseURL, overridePort, overrideData
 		}
 		// Otherwise keep default (true)
 	}
+	if !v.IsSet("offline") {
+		cfg.Offline = os.Getenv("OFFLINE") == "true"
+	}
+	if cfg.GeoIPProvider == "" {
+		cfg.GeoIPProvider = strings.ToLower(os.Getenv("GEOIP_PROVIDER"))
+	}
+	if cfg.IP2LocationToken == "" {
+		cfg.IP2LocationToken = os.Getenv("IP2LOCATION_TOKEN")
+	}
+	if cfg.MaxMindLicenseKey == "" {
+		cfg.MaxMindLicenseKey = os.Getenv("MAXMIND_LICENSE_KEY")
+	}
+	if !v.IsSet("geoip_update_interval") {
+		cfg.GeoIPUpdateInterval, _ = time.ParseDuration(os.Getenv("GEOIP_UPDATE_INTERVAL"))
+	}
+	if cfg.EncryptionKey == "" {
+		cfg.EncryptionKey = os.Getenv("ENCRYPTION_KEY")
+	}
+	if cfg.EncryptionKeyFile == "" {
+		cfg.EncryptionKeyFile = os.Getenv("ENCRYPTION_KEY_FILE")
+	}
+	if !v.IsSet("encryption_previous_keys") {
+		cfg.EncryptionPreviousKeys = parseList(os.Getenv("ENCRYPTION_PREVIOUS_KEYS"))
+	}
+	if !v.IsSet("event_store") {
+		if envStore := os.Getenv("EVENT_STORE"); envStore != "" {
+			cfg.EventStore = strings.ToLower(envStore)
+		}
+	}
+	if cfg.ClickHouseURL == "" {
+		cfg.ClickHouseURL = os.Getenv("CLICKHOUSE_URL")
+	}
+	if !v.IsSet("ingest_queue_size") {
+		cfg.IngestQueueSize, _ = strconv.Atoi(os.Getenv("INGEST_QUEUE_SIZE"))
+	}
+	if !v.IsSet("ingest_batch_size") {
+		cfg.IngestBatchSize, _ = strconv.Atoi(os.Getenv("INGEST_BATCH_SIZE"))
+	}
+	if !v.IsSet("ingest_flush_interval") {
+		cfg.IngestFlushInterval, _ = time.ParseDuration(os.Getenv("INGEST_FLUSH_INTERVAL"))
+	}
+	if !v.IsSet("db_max_open_conns") {
+		cfg.DBMaxOpenConns, _ = strconv.Atoi(os.Getenv("DB_MAX_OPEN_CONNS"))
+	}
+	if !v.IsSet("db_max_idle_conns") {
+		cfg.DBMaxIdleConns, _ = strconv.Atoi(os.Getenv("DB_MAX_IDLE_CONNS"))
+	}
+	if !v.IsSet("cache_ttl") {
+		cfg.CacheTTL, _ = time.ParseDuration(os.Getenv("CACHE_TTL"))
+	}
+	if !v.IsSet("rollup_interval") {
+		cfg.RollupInterval, _ = time.ParseDuration(os.Getenv("ROLLUP_INTERVAL"))
+	}
+	if !v.IsSet("adaptive_sampling") {
+		if envSampling := os.Getenv("ADAPTIVE_SAMPLING"); envSampling != "" {
+			cfg.AdaptiveSampling = envSampling == "true"
+		}
+	}
+	if !v.IsSet("sampling_queue_threshold") {
+		cfg.SamplingQueueThreshold, _ = strconv.ParseFloat(os.Getenv("SAMPLING_QUEUE_THRESHOLD"), 64)
+	}
+	if !v.IsSet("sampling_latency_threshold") {
+		cfg.SamplingLatencyThreshold, _ = time.ParseDuration(os.Getenv("SAMPLING_LATENCY_THRESHOLD"))
+	}
+	if !v.IsSet("sampling_max_rate") {
+		cfg.SamplingMaxRate, _ = strconv.Atoi(os.Getenv("SAMPLING_MAX_RATE"))
+	}
+	if !v.IsSet("embedded_jobs") {
+		if envJobs := os.Getenv("EMBEDDED_JOBS"); envJobs != "" {
+			cfg.EmbeddedJobs = envJobs == "true"
+		}
+	}
+	if !v.IsSet("metrics") {
+		cfg.Metrics = os.Getenv("METRICS") == "true"
+	}
+	if !v.IsSet("retention_days") {
+		cfg.RetentionDays, _ = strconv.Atoi(os.Getenv("RETENTION_DAYS"))
+	}
+	if !v.IsSet("telemetry") {
+		cfg.Telemetry = os.Getenv("TELEMETRY") == "true"
+	}
+	if cfg.TelemetryEndpoint == "" {
+		cfg.TelemetryEndpoint = os.Getenv("TELEMETRY_ENDPOINT")
+	}
+	if cfg.TrackerPath == "" {
+		cfg.TrackerPath = os.Getenv("TRACKER_PATH")
+	}
+	if cfg.CollectPath == "" {
+		cfg.CollectPath = os.Getenv("COLLECT_PATH")
+	}
+	if !v.IsSet("cardinality_cap") {
+		if envCap, err := strconv.Atoi(os.Getenv("CARDINALITY_CAP")); err == nil {
+			cfg.CardinalityCap = envCap
+		}
+	}
+	if cfg.IPMode == "" {
+		cfg.IPMode = strings.ToLower(os.Getenv("IP_MODE"))
+	}
+	if cfg.TracingEndpoint == "" {
+		cfg.TracingEndpoint = os.Getenv("TRACING_ENDPOINT")
+	}
+	if !v.IsSet("tracing_sample_ratio") {
+		if envRatio := os.Getenv("TRACING_SAMPLE_RATIO"); envRatio != "" {
+			if ratio, err := strconv.ParseFloat(envRatio, 64); err == nil {
+				cfg.TracingSampleRatio = ratio
+			}
+		}
+	}
+	if !v.IsSet("tracing_headers") {
+		cfg.TracingHeaders = parseHeaderList(os.Getenv("TRACING_HEADERS"))
+	}
+	if cfg.LogLevel == "" {
+		cfg.LogLevel = strings.ToLower(os.Getenv("KAUNTA_LOG_LEVEL"))
+	}
+	if cfg.LogFormat == "" {
+		cfg.LogFormat = strings.ToLower(os.Getenv("KAUNTA_LOG_FORMAT"))
+	}
+	if !v.IsSet("access_log") {
+		if envAccessLog := os.Getenv("ACCESS_LOG"); envAccessLog != "" {
+			cfg.AccessLog = envAccessLog == "true"
+		}
+	}
+	if !v.IsSet("ingest_rate_limit_ip") {
+		if envLimit, err := strconv.Atoi(os.Getenv("INGEST_RATE_LIMIT_IP")); err == nil {
+			cfg.IngestRateLimitIP = envLimit
+		}
+	}
+	if !v.IsSet("ingest_rate_limit_website") {
+		cfg.IngestRateLimitWebsite, _ = strconv.Atoi(os.Getenv("INGEST_RATE_LIMIT_WEBSITE"))
+	}
+	if !v.IsSet("dedup_window") {
+		if envWindow, err := time.ParseDuration(os.Getenv("DEDUP_WINDOW")); err == nil {
+			cfg.DedupWindow = envWindow
+		}
+	}
+	if cfg.PayloadValidation == "" {
+		cfg.PayloadValidation = strings.ToLower(os.Getenv("PAYLOAD_VALIDATION"))
+	}
+	if cfg.VisitDefinition == "" {
+		cfg.VisitDefinition = strings.ToLower(os.Getenv("VISIT_DEFINITION"))
+	}
+	if !v.IsSet("session_timeout") {
+		cfg.SessionTimeout, _ = time.ParseDuration(os.Getenv("SESSION_TIMEOUT"))
+	}
+	if !v.IsSet("email_recipients") {
+		cfg.EmailRecipients = os.Getenv("EMAIL_RECIPIENTS") == "true"
+	}
+	if !v.IsSet("api_rate_limit") {
+		if envLimit, err := strconv.Atoi(os.Getenv("API_RATE_LIMIT")); err == nil {
+			cfg.APIRateLimit = envLimit
+		}
+	}
+	if !v.IsSet("api_daily_quota") {
+		cfg.APIDailyQuota, _ = strconv.Atoi(os.Getenv("API_DAILY_QUOTA"))
+	}
+	if !v.IsSet("api_cors_origins") {
+		cfg.APICORSOrigins = parseList(os.Getenv("API_CORS_ORIGINS"))
+	}
+	if !v.IsSet("api_cors_credentials") {
+		cfg.APICORSCredentials = os.Getenv("API_CORS_CREDENTIALS") == "true"
+	}
+	if !v.IsSet("api_cors_max_age") {
+		if envMaxAge, err := strconv.Atoi(os.Getenv("API_CORS_MAX_AGE")); err == nil {
+			cfg.APICORSMaxAge = envMaxAge
+		}
+	}
+	if !v.IsSet("features") {
+		cfg.Features = parseFeatures(os.Getenv("FEATURES"))
+	}
 
 	// Apply overrides (flags) last
 	if overrideDatabaseURL != "" {
@@ -120,6 +656,80 @@ func buildConfig(v *viper.Viper, overrideDatabaseURL, overridePort, overrideData
 	return cfg
 }
 
+// applyStorageConfig reads the storage_* keys, falling back to STORAGE_* env vars
+func applyStorageConfig(v *viper.Viper, s *StorageConfig) {
+	keys := []struct {
+		key   string
+		field *string
+	}{
+		{"storage_backend", &s.Backend},
+		{"storage_path", &s.Path},
+		{"storage_bucket", &s.Bucket},
+		{"storage_prefix", &s.Prefix},
+		{"storage_endpoint", &s.Endpoint},
+		{"storage_region", &s.Region},
+		{"storage_access_key", &s.AccessKey},
+		{"storage_secret_key", &s.SecretKey},
+	}
+	for _, k := range keys {
+		if v.IsSet(k.key) {
+			*k.field = v.GetString(k.key)
+		} else if env := os.Getenv(strings.ToUpper(k.key)); env != "" {
+			*k.field = env
+		}
+	}
+	if v.IsSet("storage_insecure") {
+		s.Insecure = v.GetBool("storage_insecure")
+	} else {
+		s.Insecure = os.Getenv("STORAGE_INSECURE") == "true"
+	}
+	s.Backend = strings.ToLower(s.Backend)
+}
+
+// applySMTPConfig reads the smtp_* keys, falling back to SMTP_* env vars
+func applySMTPConfig(v *viper.Viper, s *SMTPConfig) {
+	keys := []struct {
+		key   string
+		field *string
+	}{
+		{"smtp_host", &s.Host},
+		{"smtp_port", &s.Port},
+		{"smtp_username", &s.Username},
+		{"smtp_password", &s.Password},
+		{"smtp_from", &s.From},
+	}
+	for _, k := range keys {
+		if v.IsSet(k.key) {
+			*k.field = v.GetString(k.key)
+		} else if env := os.Getenv(strings.ToUpper(k.key)); env != "" {
+			*k.field = env
+		}
+	}
+}
+
+// applyNotifyConfig reads slack_webhook_url, discord_webhook_url and
+// daily_summary, falling back to their env vars
+func applyNotifyConfig(v *viper.Viper, n *NotifyConfig) {
+	for _, k := range []struct {
+		key   string
+		field *string
+	}{
+		{"slack_webhook_url", &n.SlackWebhookURL},
+		{"discord_webhook_url", &n.DiscordWebhookURL},
+	} {
+		if v.IsSet(k.key) {
+			*k.field = v.GetString(k.key)
+		} else if env := os.Getenv(strings.ToUpper(k.key)); env != "" {
+			*k.field = env
+		}
+	}
+	if v.IsSet("daily_summary") {
+		n.DailySummary = v.GetBool("daily_summary")
+	} else {
+		n.DailySummary = os.Getenv("DAILY_SUMMARY") == "true"
+	}
+}
+
 // parseTrustedOrigins parses a comma-separated string into a slice of trimmed, lowercased origins
 func parseTrustedOrigins(originsStr string) []string {
 	if originsStr == "" {
@@ -144,3 +754,65 @@ func parseTrustedOrigins(originsStr string) []string {
 
 	return origins
 }
+
+// parseList splits a comma-separated string into trimmed, non-empty values
+func parseList(value string) []string {
+	var items []string
+	for _, part := range strings.Split(value, ",") {
+		if item := strings.TrimSpace(part); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseFeatures parses "name,-name" into the flags switched on and off
+func parseFeatures(value string) map[string]bool {
+	var flags map[string]bool
+	for _, item := range parseList(value) {
+		name, off := strings.CutPrefix(item, "-")
+		if name = strings.ToLower(strings.TrimSpace(name)); name == "" {
+			continue
+		}
+		if flags == nil {
+			flags = make(map[string]bool)
+		}
+		flags[name] = !off
+	}
+	return flags
+}
+
+// parseHeaderList parses "key=value,key2=value2" (the OTEL_EXPORTER_OTLP_HEADERS
+// format); entries without "=" are ignored
+func parseHeaderList(value string) map[string]string {
+	var headers map[string]string
+	for _, item := range parseList(value) {
+		key, val, ok := strings.Cut(item, "=")
+		if key = strings.TrimSpace(key); !ok || key == "" {
+			continue
+		}
+		if headers == nil {
+			headers = make(map[string]string)
+		}
+		headers[key] = strings.TrimSpace(val)
+	}
+	return headers
+}
+
+// ResolveEncryptionKey returns the configured encryption key, reading it from
+// EncryptionKeyFile when no inline key is set. An empty result means column
+// encryption is disabled.
+func (c *Config) ResolveEncryptionKey() (string, error) {
+	if c.EncryptionKey != "" {
+		return c.EncryptionKey, nil
+	}
+	if c.EncryptionKeyFile == "" {
+		return "", nil
+	}
+
+	data, err := os.ReadFile(c.EncryptionKeyFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read encryption key file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -126,3 +127,731 @@ data_dir = "./config-data"
 	assert.True(t, cfg.SecureCookies)
 	assert.Equal(t, []string{"example.com", "foo.test"}, cfg.TrustedOrigins)
 }
+
+func TestLoadEncryptionSettings(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	writeTestConfig(t, home, `
+encryption_key = "config-key"
+encryption_previous_keys = "old-1, old-2"
+`)
+	t.Setenv("ENCRYPTION_KEY", "env-key")
+	unsetEnv(t, "ENCRYPTION_KEY_FILE")
+	unsetEnv(t, "ENCRYPTION_PREVIOUS_KEYS")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "config-key", cfg.EncryptionKey)
+	assert.Equal(t, []string{"old-1", "old-2"}, cfg.EncryptionPreviousKeys)
+
+	key, err := cfg.ResolveEncryptionKey()
+	require.NoError(t, err)
+	assert.Equal(t, "config-key", key)
+}
+
+func TestResolveEncryptionKeyFromFile(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "kaunta.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte("file-key\n"), 0o600))
+
+	cfg := &Config{EncryptionKeyFile: keyFile}
+	key, err := cfg.ResolveEncryptionKey()
+	require.NoError(t, err)
+	assert.Equal(t, "file-key", key)
+
+	cfg = &Config{EncryptionKeyFile: filepath.Join(t.TempDir(), "missing")}
+	_, err = cfg.ResolveEncryptionKey()
+	assert.Error(t, err)
+
+	cfg = &Config{}
+	key, err = cfg.ResolveEncryptionKey()
+	require.NoError(t, err)
+	assert.Empty(t, key)
+}
+
+func TestLoadOfflineMode(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+
+	t.Setenv("OFFLINE", "true")
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.Offline)
+
+	writeTestConfig(t, home, `
+offline = false
+`)
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.Offline)
+}
+
+func TestLoadEventStore(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	unsetEnv(t, "EVENT_STORE")
+	unsetEnv(t, "CLICKHOUSE_URL")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "postgres", cfg.EventStore)
+	assert.Empty(t, cfg.ClickHouseURL)
+
+	t.Setenv("EVENT_STORE", "ClickHouse")
+	t.Setenv("CLICKHOUSE_URL", "http://env:8123/kaunta")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "clickhouse", cfg.EventStore)
+	assert.Equal(t, "http://env:8123/kaunta", cfg.ClickHouseURL)
+
+	writeTestConfig(t, home, `
+event_store = "postgres"
+clickhouse_url = "http://config:8123/events"
+`)
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "postgres", cfg.EventStore)
+	assert.Equal(t, "http://config:8123/events", cfg.ClickHouseURL)
+}
+
+func TestLoadStorageSettings(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	for _, key := range []string{"STORAGE_BACKEND", "STORAGE_PATH", "STORAGE_BUCKET", "STORAGE_PREFIX",
+		"STORAGE_ENDPOINT", "STORAGE_REGION", "STORAGE_ACCESS_KEY", "STORAGE_SECRET_KEY", "STORAGE_INSECURE"} {
+		unsetEnv(t, key)
+	}
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, StorageConfig{Backend: "local"}, cfg.Storage)
+
+	writeTestConfig(t, home, `
+storage_backend = "S3"
+storage_bucket = "kaunta-archive"
+storage_endpoint = "http://minio:9000"
+storage_insecure = true
+`)
+	t.Setenv("STORAGE_BUCKET", "env-bucket")
+	t.Setenv("STORAGE_SECRET_KEY", "env-secret")
+
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "s3", cfg.Storage.Backend)
+	assert.Equal(t, "kaunta-archive", cfg.Storage.Bucket)
+	assert.Equal(t, "http://minio:9000", cfg.Storage.Endpoint)
+	assert.Equal(t, "env-secret", cfg.Storage.SecretKey)
+	assert.True(t, cfg.Storage.Insecure)
+}
+
+func TestLoadSMTPSettings(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	for _, key := range []string{"SMTP_HOST", "SMTP_PORT", "SMTP_USERNAME", "SMTP_PASSWORD", "SMTP_FROM"} {
+		unsetEnv(t, key)
+	}
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, SMTPConfig{Port: "587"}, cfg.SMTP)
+
+	writeTestConfig(t, home, `
+smtp_host = "mail.example.com"
+smtp_from = "kaunta@example.com"
+`)
+	t.Setenv("SMTP_PORT", "465")
+	t.Setenv("SMTP_PASSWORD", "env-secret")
+
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, SMTPConfig{Host: "mail.example.com", Port: "465", Password: "env-secret", From: "kaunta@example.com"}, cfg.SMTP)
+}
+
+func TestLoadNotifySettings(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	for _, key := range []string{"SLACK_WEBHOOK_URL", "DISCORD_WEBHOOK_URL", "DAILY_SUMMARY"} {
+		unsetEnv(t, key)
+	}
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, NotifyConfig{}, cfg.Notify)
+
+	writeTestConfig(t, home, `
+slack_webhook_url = "https://hooks.slack.com/services/T0/B0/X"
+daily_summary = true
+`)
+	t.Setenv("DISCORD_WEBHOOK_URL", "https://discord.com/api/webhooks/1/x")
+	t.Setenv("DAILY_SUMMARY", "false")
+
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, NotifyConfig{
+		SlackWebhookURL:   "https://hooks.slack.com/services/T0/B0/X",
+		DiscordWebhookURL: "https://discord.com/api/webhooks/1/x",
+		DailySummary:      true,
+	}, cfg.Notify)
+}
+
+func TestLoadIngestSettings(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("INGEST_QUEUE_SIZE", "2000")
+	t.Setenv("INGEST_BATCH_SIZE", "100")
+	t.Setenv("INGEST_FLUSH_INTERVAL", "500ms")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 2000, cfg.IngestQueueSize)
+	assert.Equal(t, 100, cfg.IngestBatchSize)
+	assert.Equal(t, 500*time.Millisecond, cfg.IngestFlushInterval)
+
+	writeTestConfig(t, home, `
+ingest_batch_size = 1000
+ingest_flush_interval = "1s"
+`)
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, 2000, cfg.IngestQueueSize)
+	assert.Equal(t, 1000, cfg.IngestBatchSize)
+	assert.Equal(t, time.Second, cfg.IngestFlushInterval)
+}
+
+func TestLoadTuningSettings(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("DB_MAX_OPEN_CONNS", "20")
+	unsetEnv(t, "DB_MAX_IDLE_CONNS")
+	unsetEnv(t, "CACHE_TTL")
+	t.Setenv("ROLLUP_INTERVAL", "15m")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 20, cfg.DBMaxOpenConns)
+	assert.Zero(t, cfg.DBMaxIdleConns)
+	assert.Zero(t, cfg.CacheTTL)
+	assert.Equal(t, 15*time.Minute, cfg.RollupInterval)
+	assert.Empty(t, FilePath())
+
+	writeTestConfig(t, home, `
+db_max_idle_conns = 5
+cache_ttl = "5m"
+`)
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, 20, cfg.DBMaxOpenConns)
+	assert.Equal(t, 5, cfg.DBMaxIdleConns)
+	assert.Equal(t, 5*time.Minute, cfg.CacheTTL)
+	assert.Equal(t, filepath.Join(home, ".config", "kaunta", "kaunta.toml"), FilePath())
+}
+
+func TestLoadSamplingSettings(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	unsetEnv(t, "ADAPTIVE_SAMPLING")
+	unsetEnv(t, "SAMPLING_QUEUE_THRESHOLD")
+	unsetEnv(t, "SAMPLING_LATENCY_THRESHOLD")
+	t.Setenv("SAMPLING_MAX_RATE", "32")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.AdaptiveSampling)
+	assert.Zero(t, cfg.SamplingQueueThreshold)
+	assert.Equal(t, 32, cfg.SamplingMaxRate)
+
+	writeTestConfig(t, home, `
+adaptive_sampling = false
+sampling_queue_threshold = 0.8
+sampling_latency_threshold = "500ms"
+`)
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.AdaptiveSampling)
+	assert.Equal(t, 0.8, cfg.SamplingQueueThreshold)
+	assert.Equal(t, 500*time.Millisecond, cfg.SamplingLatencyThreshold)
+}
+
+func TestLoadEmbeddedJobs(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	unsetEnv(t, "EMBEDDED_JOBS")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.EmbeddedJobs)
+
+	t.Setenv("EMBEDDED_JOBS", "false")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.EmbeddedJobs)
+
+	writeTestConfig(t, home, `
+embedded_jobs = true
+`)
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.EmbeddedJobs)
+}
+
+func TestLoadMetrics(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	unsetEnv(t, "METRICS")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.Metrics)
+
+	t.Setenv("METRICS", "true")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.Metrics)
+
+	writeTestConfig(t, home, `
+metrics = false
+`)
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.Metrics)
+}
+
+func TestLoadTelemetry(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	unsetEnv(t, "TELEMETRY")
+	unsetEnv(t, "TELEMETRY_ENDPOINT")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.Telemetry, "telemetry is opt-in")
+	assert.Empty(t, cfg.TelemetryEndpoint)
+
+	t.Setenv("TELEMETRY", "true")
+	t.Setenv("TELEMETRY_ENDPOINT", "https://telemetry.example.com/report")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.Telemetry)
+	assert.Equal(t, "https://telemetry.example.com/report", cfg.TelemetryEndpoint)
+
+	writeTestConfig(t, home, `
+telemetry = false
+`)
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.Telemetry)
+}
+
+func TestLoadTrackerPaths(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	unsetEnv(t, "TRACKER_PATH")
+	unsetEnv(t, "COLLECT_PATH")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.TrackerPath)
+	assert.Empty(t, cfg.CollectPath)
+
+	t.Setenv("TRACKER_PATH", "/assets/app.js")
+	t.Setenv("COLLECT_PATH", "/events")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "/assets/app.js", cfg.TrackerPath)
+	assert.Equal(t, "/events", cfg.CollectPath)
+
+	writeTestConfig(t, home, `
+tracker_path = "/js/site.js"
+collect_path = "/e"
+`)
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "/js/site.js", cfg.TrackerPath)
+	assert.Equal(t, "/e", cfg.CollectPath)
+}
+
+func TestLoadTracing(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	unsetEnv(t, "TRACING_ENDPOINT")
+	unsetEnv(t, "TRACING_SAMPLE_RATIO")
+	unsetEnv(t, "TRACING_HEADERS")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.TracingEndpoint)
+	assert.Equal(t, 1.0, cfg.TracingSampleRatio)
+	assert.Empty(t, cfg.TracingHeaders)
+
+	t.Setenv("TRACING_ENDPOINT", "http://otel:4318")
+	t.Setenv("TRACING_SAMPLE_RATIO", "0.25")
+	t.Setenv("TRACING_HEADERS", "x-api-key=secret, x-team = ops,broken")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "http://otel:4318", cfg.TracingEndpoint)
+	assert.Equal(t, 0.25, cfg.TracingSampleRatio)
+	assert.Equal(t, map[string]string{"x-api-key": "secret", "x-team": "ops"}, cfg.TracingHeaders)
+
+	writeTestConfig(t, home, `
+tracing_endpoint = "https://collector.example.com/v1/traces"
+tracing_sample_ratio = 0.1
+
+[tracing_headers]
+x-honeycomb-team = "abc123"
+`)
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "https://collector.example.com/v1/traces", cfg.TracingEndpoint)
+	assert.Equal(t, 0.1, cfg.TracingSampleRatio)
+	assert.Equal(t, map[string]string{"x-honeycomb-team": "abc123"}, cfg.TracingHeaders)
+}
+
+func TestLoadRetentionDays(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	unsetEnv(t, "RETENTION_DAYS")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 0, cfg.RetentionDays)
+
+	t.Setenv("RETENTION_DAYS", "395")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, 395, cfg.RetentionDays)
+
+	writeTestConfig(t, home, `
+retention_days = 90
+`)
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, 90, cfg.RetentionDays)
+}
+
+func TestLoadLogging(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	unsetEnv(t, "KAUNTA_LOG_LEVEL")
+	unsetEnv(t, "KAUNTA_LOG_FORMAT")
+	unsetEnv(t, "ACCESS_LOG")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.LogLevel)
+	assert.Empty(t, cfg.LogFormat)
+	assert.True(t, cfg.AccessLog)
+
+	t.Setenv("KAUNTA_LOG_LEVEL", "DEBUG")
+	t.Setenv("ACCESS_LOG", "false")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "debug", cfg.LogLevel)
+	assert.False(t, cfg.AccessLog)
+
+	writeTestConfig(t, home, `
+log_level = "warn"
+log_format = "json"
+access_log = true
+`)
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "warn", cfg.LogLevel)
+	assert.Equal(t, "json", cfg.LogFormat)
+	assert.True(t, cfg.AccessLog)
+}
+
+func TestLoadAPILimits(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	unsetEnv(t, "API_RATE_LIMIT")
+	unsetEnv(t, "API_DAILY_QUOTA")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 600, cfg.APIRateLimit)
+	assert.Zero(t, cfg.APIDailyQuota)
+
+	t.Setenv("API_RATE_LIMIT", "0")
+	t.Setenv("API_DAILY_QUOTA", "5000")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Zero(t, cfg.APIRateLimit)
+	assert.Equal(t, 5000, cfg.APIDailyQuota)
+
+	writeTestConfig(t, home, `
+api_rate_limit = 120
+api_daily_quota = 10000
+`)
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, 120, cfg.APIRateLimit)
+	assert.Equal(t, 10000, cfg.APIDailyQuota)
+}
+
+func TestLoadIngestRateLimits(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	unsetEnv(t, "INGEST_RATE_LIMIT_IP")
+	unsetEnv(t, "INGEST_RATE_LIMIT_WEBSITE")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 300, cfg.IngestRateLimitIP)
+	assert.Zero(t, cfg.IngestRateLimitWebsite)
+
+	t.Setenv("INGEST_RATE_LIMIT_IP", "0")
+	t.Setenv("INGEST_RATE_LIMIT_WEBSITE", "6000")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Zero(t, cfg.IngestRateLimitIP)
+	assert.Equal(t, 6000, cfg.IngestRateLimitWebsite)
+
+	writeTestConfig(t, home, `
+ingest_rate_limit_ip = 60
+ingest_rate_limit_website = 3000
+`)
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, 60, cfg.IngestRateLimitIP)
+	assert.Equal(t, 3000, cfg.IngestRateLimitWebsite)
+}
+
+func TestLoadDedupWindow(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	unsetEnv(t, "DEDUP_WINDOW")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Second, cfg.DedupWindow)
+
+	t.Setenv("DEDUP_WINDOW", "0")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Zero(t, cfg.DedupWindow)
+
+	writeTestConfig(t, home, `dedup_window = "5s"`)
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Second, cfg.DedupWindow)
+}
+
+func TestLoadPayloadValidation(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	unsetEnv(t, "PAYLOAD_VALIDATION")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.PayloadValidation)
+
+	t.Setenv("PAYLOAD_VALIDATION", "Reject")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "reject", cfg.PayloadValidation)
+
+	writeTestConfig(t, home, `payload_validation = "truncate"`)
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "truncate", cfg.PayloadValidation)
+}
+
+func TestLoadVisitRules(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	unsetEnv(t, "VISIT_DEFINITION")
+	unsetEnv(t, "SESSION_TIMEOUT")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.VisitDefinition)
+	assert.Zero(t, cfg.SessionTimeout)
+
+	t.Setenv("VISIT_DEFINITION", "Inactivity")
+	t.Setenv("SESSION_TIMEOUT", "45m")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "inactivity", cfg.VisitDefinition)
+	assert.Equal(t, 45*time.Minute, cfg.SessionTimeout)
+
+	writeTestConfig(t, home, "visit_definition = \"hour\"\nsession_timeout = \"10m\"")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "hour", cfg.VisitDefinition)
+	assert.Equal(t, 10*time.Minute, cfg.SessionTimeout)
+}
+
+func TestLoadEmailRecipients(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	unsetEnv(t, "EMAIL_RECIPIENTS")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.EmailRecipients)
+
+	t.Setenv("EMAIL_RECIPIENTS", "true")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.EmailRecipients)
+
+	writeTestConfig(t, home, `email_recipients = false`)
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.EmailRecipients)
+}
+
+func TestLoadFeatures(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	unsetEnv(t, "FEATURES")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Nil(t, cfg.Features)
+
+	t.Setenv("FEATURES", "funnels, -Sessions")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"funnels": true, "sessions": false}, cfg.Features)
+
+	writeTestConfig(t, home, `features = ["-funnels"]`)
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"funnels": false}, cfg.Features)
+}
+
+func TestLoadCardinalityCap(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	unsetEnv(t, "CARDINALITY_CAP")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 10000, cfg.CardinalityCap)
+
+	t.Setenv("CARDINALITY_CAP", "0")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Zero(t, cfg.CardinalityCap)
+
+	writeTestConfig(t, home, `cardinality_cap = 2500`)
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, 2500, cfg.CardinalityCap)
+}
+
+func TestLoadAPICORS(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	unsetEnv(t, "API_CORS_ORIGINS")
+	unsetEnv(t, "API_CORS_CREDENTIALS")
+	unsetEnv(t, "API_CORS_MAX_AGE")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.APICORSOrigins)
+	assert.False(t, cfg.APICORSCredentials)
+	assert.Equal(t, 600, cfg.APICORSMaxAge)
+
+	t.Setenv("API_CORS_ORIGINS", "https://grafana.example.com, https://intranet.example.com")
+	t.Setenv("API_CORS_CREDENTIALS", "true")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://grafana.example.com", "https://intranet.example.com"}, cfg.APICORSOrigins)
+	assert.True(t, cfg.APICORSCredentials)
+
+	writeTestConfig(t, home, `
+api_cors_origins = ["https://grafana.example.com", "https://status.example.com"]
+api_cors_credentials = false
+api_cors_max_age = 3600
+`)
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://grafana.example.com", "https://status.example.com"}, cfg.APICORSOrigins)
+	assert.False(t, cfg.APICORSCredentials)
+	assert.Equal(t, 3600, cfg.APICORSMaxAge)
+}
+
+func TestLoadIPMode(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	unsetEnv(t, "IP_MODE")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.IPMode)
+
+	t.Setenv("IP_MODE", "Hash")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "hash", cfg.IPMode)
+
+	writeTestConfig(t, home, `ip_mode = "truncate"`)
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "truncate", cfg.IPMode)
+}
+
+func TestLoadGeoIPUpdates(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	unsetEnv(t, "MAXMIND_LICENSE_KEY")
+	unsetEnv(t, "GEOIP_UPDATE_INTERVAL")
+	unsetEnv(t, "GEOIP_PROVIDER")
+	unsetEnv(t, "IP2LOCATION_TOKEN")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.GeoIPProvider)
+	assert.Empty(t, cfg.MaxMindLicenseKey)
+	assert.Zero(t, cfg.GeoIPUpdateInterval)
+
+	t.Setenv("MAXMIND_LICENSE_KEY", "env-key")
+	t.Setenv("GEOIP_UPDATE_INTERVAL", "24h")
+	t.Setenv("GEOIP_PROVIDER", "DBIP")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "dbip", cfg.GeoIPProvider)
+	assert.Equal(t, "env-key", cfg.MaxMindLicenseKey)
+	assert.Equal(t, 24*time.Hour, cfg.GeoIPUpdateInterval)
+
+	writeTestConfig(t, home, `
+maxmind_license_key = "file-key"
+geoip_update_interval = "72h"
+geoip_provider = "ip2location"
+ip2location_token = "file-token"
+`)
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "ip2location", cfg.GeoIPProvider)
+	assert.Equal(t, "file-token", cfg.IP2LocationToken)
+	assert.Equal(t, "file-key", cfg.MaxMindLicenseKey)
+	assert.Equal(t, 72*time.Hour, cfg.GeoIPUpdateInterval)
+}
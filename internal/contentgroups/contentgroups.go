@@ -0,0 +1,149 @@
+// Package contentgroups maps URL paths to content groups (blog, docs,
+// marketing, ...) with per-website rules, so reports can compare sections of
+// a site instead of single pages.
+//
+// A rule's pattern is a path glob where * matches any run of characters:
+// "/blog/*" matches every post, "/pricing" only that page. Rules are evaluated
+// at query time in the order they were added (content_group_rule.position);
+// the first match wins and unmatched paths fall into Other. Because nothing is
+// stored on events, changing the rules regroups past traffic too.
+package contentgroups
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Other is the group of paths no rule matches
+const Other = "Other"
+
+// ErrNotFound is returned by Remove for a pattern without a rule
+var ErrNotFound = errors.New("content group rule not found")
+
+// Rule assigns the paths matching Pattern to Group
+type Rule struct {
+	Pattern  string `json:"pattern"`
+	Group    string `json:"group"`
+	Position int    `json:"position"`
+}
+
+// Validate checks a pattern and group name before they are stored
+func Validate(pattern, group string) error {
+	if !strings.HasPrefix(pattern, "/") || len(pattern) > 500 {
+		return fmt.Errorf("invalid pattern: %q (use a path starting with /, * matches anything)", pattern)
+	}
+	if group = strings.TrimSpace(group); group == "" || len(group) > 100 {
+		return fmt.Errorf("invalid group name: %q (1-100 characters)", group)
+	}
+	return nil
+}
+
+// List returns a website's rules in evaluation order
+func List(ctx context.Context, db *sql.DB, websiteID uuid.UUID) ([]Rule, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT pattern, group_name, position
+		FROM content_group_rule
+		WHERE website_id = $1
+		ORDER BY position, pattern
+	`, websiteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list content group rules: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var rules []Rule
+	for rows.Next() {
+		var r Rule
+		if err := rows.Scan(&r.Pattern, &r.Group, &r.Position); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// Add appends a rule. Adding an existing pattern again changes its group but
+// keeps its position.
+func Add(ctx context.Context, db *sql.DB, websiteID uuid.UUID, pattern, group string) error {
+	if err := Validate(pattern, group); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO content_group_rule (website_id, pattern, group_name, position)
+		SELECT $1, $2, $3, COALESCE(MAX(position), 0) + 1
+		FROM content_group_rule
+		WHERE website_id = $1
+		ON CONFLICT (website_id, pattern) DO UPDATE SET group_name = EXCLUDED.group_name
+	`, websiteID, pattern, strings.TrimSpace(group))
+	if err != nil {
+		return fmt.Errorf("failed to add content group rule: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes the rule for a pattern
+func Remove(ctx context.Context, db *sql.DB, websiteID uuid.UUID, pattern string) error {
+	res, err := db.ExecContext(ctx,
+		`DELETE FROM content_group_rule WHERE website_id = $1 AND pattern = $2`, websiteID, pattern)
+	if err != nil {
+		return fmt.Errorf("failed to remove content group rule: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Match returns the group of path, the same way CaseExpr does in SQL
+func Match(rules []Rule, path string) string {
+	for _, r := range rules {
+		if globRegexp(r.Pattern).MatchString(path) {
+			return r.Group
+		}
+	}
+	return Other
+}
+
+// CaseExpr builds a SQL expression mapping column to its group. Patterns and
+// groups are passed as parameters numbered from first; the returned args
+// follow the caller's.
+func CaseExpr(rules []Rule, column string, first int) (string, []interface{}) {
+	if len(rules) == 0 {
+		return "'" + Other + "'", nil
+	}
+
+	var b strings.Builder
+	args := make([]interface{}, 0, 2*len(rules))
+	b.WriteString("CASE")
+	for _, r := range rules {
+		fmt.Fprintf(&b, " WHEN %s LIKE $%d ESCAPE '\\' THEN $%d", column, first+len(args), first+len(args)+1)
+		args = append(args, likePattern(r.Pattern), r.Group)
+	}
+	b.WriteString(" ELSE '" + Other + "' END")
+	return b.String(), args
+}
+
+// likePattern turns a glob into a LIKE pattern, escaping LIKE's own
+// wildcards
+func likePattern(pattern string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(pattern)
+	return strings.ReplaceAll(escaped, "*", "%")
+}
+
+func globRegexp(pattern string) *regexp.Regexp {
+	parts := strings.Split(pattern, "*")
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
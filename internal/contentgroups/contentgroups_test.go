@@ -0,0 +1,65 @@
+package contentgroups
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRules = []Rule{
+	{Pattern: "/blog/*", Group: "Blog", Position: 1},
+	{Pattern: "/docs*", Group: "Docs", Position: 2},
+	{Pattern: "/", Group: "Marketing", Position: 3},
+	{Pattern: "/pricing", Group: "Marketing", Position: 4},
+}
+
+func TestMatch(t *testing.T) {
+	assert.Equal(t, "Blog", Match(testRules, "/blog/hello-world"))
+	assert.Equal(t, "Docs", Match(testRules, "/docs"))
+	assert.Equal(t, "Docs", Match(testRules, "/docs/api/v2"))
+	assert.Equal(t, "Marketing", Match(testRules, "/"))
+	assert.Equal(t, "Marketing", Match(testRules, "/pricing"))
+	assert.Equal(t, Other, Match(testRules, "/pricing/enterprise"))
+	assert.Equal(t, Other, Match(testRules, "/blog"))
+	assert.Equal(t, Other, Match(nil, "/blog/x"))
+}
+
+func TestCaseExpr(t *testing.T) {
+	expr, args := CaseExpr([]Rule{
+		{Pattern: "/blog/*", Group: "Blog"},
+		{Pattern: "/100%_off", Group: "Promo"},
+	}, "e.url_path", 4)
+
+	assert.Equal(t, `CASE WHEN e.url_path LIKE $4 ESCAPE '\' THEN $5 WHEN e.url_path LIKE $6 ESCAPE '\' THEN $7 ELSE 'Other' END`, expr)
+	assert.Equal(t, []interface{}{"/blog/%", "Blog", `/100\%\_off`, "Promo"}, args)
+
+	expr, args = CaseExpr(nil, "e.url_path", 4)
+	assert.Equal(t, "'Other'", expr)
+	assert.Empty(t, args)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate("/blog/*", "Blog"))
+	assert.Error(t, Validate("blog/*", "Blog"))
+	assert.Error(t, Validate("/blog/*", "  "))
+}
+
+func TestAddAndRemove(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	websiteID := uuid.New()
+
+	mock.ExpectExec(`INSERT INTO content_group_rule`).WithArgs(websiteID, "/blog/*", "Blog").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM content_group_rule`).WithArgs(websiteID, "/docs*").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	require.NoError(t, Add(context.Background(), db, websiteID, "/blog/*", " Blog "))
+	assert.ErrorIs(t, Remove(context.Background(), db, websiteID, "/docs*"), ErrNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
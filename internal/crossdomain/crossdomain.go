@@ -0,0 +1,172 @@
+// Package crossdomain links the sessions of visitors moving between websites
+// tracked separately, such as a marketing site and an app on its own domain,
+// so the journey stays attributed to the campaign that started it.
+//
+// Linking is opt-in for each pair of websites (`kaunta website link`). The
+// tracker of a linked website adds the visitor's signed session token to
+// links to the other website's domains (?_kaunta=...). The first pageview on
+// the other side sends it along: its session is recorded as linked to the
+// one it came from (session_link) and the pageview takes over the referrer
+// and campaign of the visit it came from, instead of showing the first
+// website as its referrer. Links live in PostgreSQL.
+package crossdomain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Param is the query parameter the tracker adds to links between linked
+// websites
+const Param = "_kaunta"
+
+// keepDays is how long session links are kept
+const keepDays = 90
+
+// ErrSameWebsite is returned by Link for a website linked to itself
+var ErrSameWebsite = errors.New("a website can't be linked to itself")
+
+// Source is the session a visitor followed a link from
+type Source struct {
+	WebsiteID uuid.UUID
+	SessionID uuid.UUID
+	VisitID   uuid.UUID
+}
+
+// Attribution is where the visit of a Source came from: its first pageview's
+// referrer and campaign
+type Attribution struct {
+	Referrer    *string
+	UTMSource   *string
+	UTMMedium   *string
+	UTMCampaign *string
+	UTMContent  *string
+	UTMTerm     *string
+}
+
+// Website is a website linked to another one
+type Website struct {
+	WebsiteID uuid.UUID `json:"website_id"`
+	Domain    string    `json:"domain"`
+	Sessions  int64     `json:"sessions_30d"` // sessions linked from it in the last 30 days
+}
+
+// Link links two websites, both ways
+func Link(ctx context.Context, db *sql.DB, a, b uuid.UUID) error {
+	if a == b {
+		return ErrSameWebsite
+	}
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO website_link (website_id, linked_website_id)
+		VALUES ($1, $2), ($2, $1)
+		ON CONFLICT DO NOTHING
+	`, a, b)
+	if err != nil {
+		return fmt.Errorf("failed to link websites: %w", err)
+	}
+	return nil
+}
+
+// Unlink removes the link between two websites; false when there was none
+func Unlink(ctx context.Context, db *sql.DB, a, b uuid.UUID) (bool, error) {
+	res, err := db.ExecContext(ctx, `
+		DELETE FROM website_link
+		WHERE (website_id = $1 AND linked_website_id = $2)
+		   OR (website_id = $2 AND linked_website_id = $1)
+	`, a, b)
+	if err != nil {
+		return false, fmt.Errorf("failed to unlink websites: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// List returns the websites linked to websiteID, by domain
+func List(ctx context.Context, db *sql.DB, websiteID uuid.UUID) ([]Website, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT w.website_id, w.domain,
+			(SELECT COUNT(*) FROM session_link s
+			 WHERE s.website_id = l.website_id
+			   AND s.linked_website_id = l.linked_website_id
+			   AND s.created_at >= NOW() - INTERVAL '30 days')
+		FROM website_link l
+		JOIN website w ON w.website_id = l.linked_website_id
+		WHERE l.website_id = $1 AND w.deleted_at IS NULL
+		ORDER BY w.domain
+	`, websiteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list linked websites: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	websites := []Website{}
+	for rows.Next() {
+		var w Website
+		if err := rows.Scan(&w.WebsiteID, &w.Domain, &w.Sessions); err != nil {
+			return nil, fmt.Errorf("failed to read linked website: %w", err)
+		}
+		websites = append(websites, w)
+	}
+	return websites, rows.Err()
+}
+
+// Record links the session a visitor started on websiteID to the session
+// they came from, when the two websites are linked, and returns the
+// attribution of the visit they came from; nil when they aren't linked
+func Record(ctx context.Context, db *sql.DB, websiteID, sessionID uuid.UUID, from Source) (*Attribution, error) {
+	res, err := db.ExecContext(ctx, `
+		INSERT INTO session_link (website_id, session_id, linked_website_id, linked_session_id, linked_visit_id)
+		SELECT $1, $2, $3, $4, $5
+		WHERE EXISTS (
+			SELECT 1 FROM website_link WHERE website_id = $1 AND linked_website_id = $3
+		)
+		ON CONFLICT DO NOTHING
+	`, websiteID, sessionID, from.WebsiteID, from.SessionID, from.VisitID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to link session: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return nil, err
+	}
+
+	var a Attribution
+	var domain, path, query sql.NullString
+	err = db.QueryRowContext(ctx, `
+		SELECT referrer_domain, referrer_path, referrer_query,
+			utm_source, utm_medium, utm_campaign, utm_content, utm_term
+		FROM website_event
+		WHERE website_id = $1 AND session_id = $2 AND visit_id = $3 AND event_type = 1
+		ORDER BY created_at
+		LIMIT 1
+	`, from.WebsiteID, from.SessionID, from.VisitID).Scan(&domain, &path, &query,
+		&a.UTMSource, &a.UTMMedium, &a.UTMCampaign, &a.UTMContent, &a.UTMTerm)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &a, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read linked visit: %w", err)
+	}
+	if domain.Valid && domain.String != "" {
+		referrer := "https://" + domain.String + path.String
+		if query.String != "" {
+			referrer += "?" + query.String
+		}
+		a.Referrer = &referrer
+	}
+	return &a, nil
+}
+
+// Cleanup deletes session links older than keepDays
+func Cleanup(ctx context.Context, db *sql.DB) (int64, error) {
+	res, err := db.ExecContext(ctx, `DELETE FROM session_link WHERE created_at < $1`,
+		time.Now().AddDate(0, 0, -keepDays))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete session links: %w", err)
+	}
+	return res.RowsAffected()
+}
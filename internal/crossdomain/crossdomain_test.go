@@ -0,0 +1,56 @@
+package crossdomain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/test"
+)
+
+func TestLink(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	a, b := uuid.New(), uuid.New()
+
+	mock.ExpectExec("INSERT INTO website_link").WithArgs(a, b).WillReturnResult(sqlmock.NewResult(0, 2))
+	require.NoError(t, Link(context.Background(), db, a, b))
+	assert.ErrorIs(t, Link(context.Background(), db, a, a), ErrSameWebsite)
+
+	mock.ExpectExec("DELETE FROM website_link").WithArgs(a, b).WillReturnResult(sqlmock.NewResult(0, 0))
+	removed, err := Unlink(context.Background(), db, a, b)
+	require.NoError(t, err)
+	assert.False(t, removed)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecord(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID, sessionID := uuid.New(), uuid.New()
+	from := Source{WebsiteID: uuid.New(), SessionID: uuid.New(), VisitID: uuid.New()}
+
+	mock.ExpectExec("INSERT INTO session_link").
+		WithArgs(websiteID, sessionID, from.WebsiteID, from.SessionID, from.VisitID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("FROM website_event").WithArgs(from.WebsiteID, from.SessionID, from.VisitID).
+		WillReturnRows(sqlmock.NewRows([]string{"referrer_domain", "referrer_path", "referrer_query",
+			"utm_source", "utm_medium", "utm_campaign", "utm_content", "utm_term"}).
+			AddRow("news.ycombinator.com", "/item", "id=1", "hn", "social", "launch", nil, nil))
+
+	a, err := Record(context.Background(), db, websiteID, sessionID, from)
+	require.NoError(t, err)
+	require.NotNil(t, a)
+	assert.Equal(t, "https://news.ycombinator.com/item?id=1", *a.Referrer)
+	assert.Equal(t, "launch", *a.UTMCampaign)
+	assert.Nil(t, a.UTMContent)
+
+	// Websites that aren't linked record nothing
+	mock.ExpectExec("INSERT INTO session_link").WillReturnResult(sqlmock.NewResult(0, 0))
+	a, err = Record(context.Background(), db, websiteID, sessionID, from)
+	require.NoError(t, err)
+	assert.Nil(t, a)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
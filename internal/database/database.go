@@ -4,10 +4,12 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"strings"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 
 	"github.com/seuros/kaunta/internal/logging"
+	"github.com/seuros/kaunta/internal/tracing"
 )
 
 var DB *sql.DB
@@ -26,12 +28,16 @@ func ConnectWithURL(databaseURL string) error {
 	if databaseURL == "" {
 		return fmt.Errorf("database URL cannot be empty")
 	}
+	if IsSQLiteURL(databaseURL) {
+		return fmt.Errorf("this command requires PostgreSQL (DATABASE_URL points to a SQLite database)")
+	}
 
-	var err error
-	DB, err = sql.Open("postgres", databaseURL)
+	connector, err := pq.NewConnector(databaseURL)
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
+	// Queries made under a request span show up in its trace
+	DB = sql.OpenDB(tracing.WrapConnector(connector, "postgresql"))
 
 	// Test connection
 	if err = DB.Ping(); err != nil {
@@ -42,6 +48,26 @@ func ConnectWithURL(databaseURL string) error {
 	return nil
 }
 
+// SetPool sizes the connection pool of DB; zero keeps the database/sql
+// default of each limit
+func SetPool(maxOpen, maxIdle int) {
+	if DB == nil {
+		return
+	}
+	if maxOpen > 0 {
+		DB.SetMaxOpenConns(maxOpen)
+	}
+	if maxIdle > 0 {
+		DB.SetMaxIdleConns(maxIdle)
+	}
+}
+
+// IsSQLiteURL reports whether a database URL selects the SQLite backend
+// (sqlite:///path/to/kaunta.db or file:kaunta.db)
+func IsSQLiteURL(databaseURL string) bool {
+	return strings.HasPrefix(databaseURL, "sqlite:") || strings.HasPrefix(databaseURL, "file:")
+}
+
 func Close() error {
 	if DB != nil {
 		return DB.Close()
@@ -0,0 +1,221 @@
+package database
+
+import (
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// The SQL functions the dashboard and the stats commands call are created by
+// migrations, but a database restored from an old dump or migrated by
+// another build can have other versions of them. The binary carries the
+// definitions it was written against and can check or (re)create them.
+
+//go:embed functions/*.sql
+var functionFS embed.FS
+
+// Function is a SQL function the binary calls
+type Function struct {
+	Name string
+	// Args are the argument types, as PostgreSQL lists them
+	Args string
+	file string
+}
+
+// Functions are the SQL functions the binary calls, in creation order
+var Functions = []Function{
+	{Name: "screen_width", Args: "character varying", file: "screen_width.sql"},
+	{Name: "screen_bucket", Args: "character varying", file: "screen_bucket.sql"},
+	{Name: "viewport_class", Args: "character varying", file: "viewport_class.sql"},
+	{Name: "website_timezone", Args: "uuid", file: "website_timezone.sql"},
+	{Name: "website_today", Args: "uuid", file: "website_today.sql"},
+	{Name: "website_day_start", Args: "uuid, integer", file: "website_day_start.sql"},
+	{Name: "get_dashboard_stats", Args: "uuid, integer, character varying, character varying, character varying, character varying, jsonb, boolean", file: "get_dashboard_stats.sql"},
+	{Name: "get_top_pages", Args: "uuid, integer, integer, integer, character varying, character varying, character varying, jsonb, boolean, timestamp with time zone, timestamp with time zone", file: "get_top_pages.sql"},
+	{Name: "get_timeseries", Args: "uuid, integer, character varying, character varying, character varying, character varying, jsonb, boolean, timestamp with time zone, timestamp with time zone, character varying", file: "get_timeseries.sql"},
+	{Name: "get_map_data", Args: "uuid, integer, character varying, character varying, character varying, character varying, jsonb, boolean, timestamp with time zone, timestamp with time zone", file: "get_map_data.sql"},
+	{Name: "get_breakdown", Args: "uuid, character varying, integer, integer, integer, character varying, character varying, character varying, character varying, jsonb, boolean, timestamp with time zone, timestamp with time zone", file: "get_breakdown.sql"},
+	{Name: "get_utm_breakdown", Args: "uuid, character varying, integer, integer, integer, character varying, jsonb", file: "get_utm_breakdown.sql"},
+}
+
+// Checksum is the MD5 of the function's body, the text between the $$
+// quotes, with runs of whitespace collapsed: the checksum CheckFunctions
+// computes of the database's function
+func (fn Function) Checksum() (string, error) {
+	definition, err := functionFS.ReadFile("functions/" + fn.file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", fn.file, err)
+	}
+	parts := strings.Split(string(definition), "$$")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("%s has no $$-quoted body", fn.file)
+	}
+	sum := md5.Sum([]byte(strings.Join(strings.Fields(parts[1]), " ")))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// FunctionStatus is what the database has of a function
+type FunctionStatus struct {
+	Function
+	// Found are the argument types of the function's overloads in the
+	// database
+	Found []string
+	// Stale is set when the overload with the expected arguments has
+	// another body than the binary's, e.g. one of an older migration
+	Stale bool
+}
+
+// OK reports whether the database has the function with the expected
+// arguments and body
+func (s FunctionStatus) OK() bool {
+	return s.hasSignature() && !s.Stale
+}
+
+func (s FunctionStatus) hasSignature() bool {
+	for _, args := range s.Found {
+		if args == s.Args {
+			return true
+		}
+	}
+	return false
+}
+
+// Problem describes what is wrong with the function, "" when it is OK
+func (s FunctionStatus) Problem() string {
+	switch {
+	case s.OK():
+		return ""
+	case len(s.Found) == 0:
+		return "missing"
+	case s.hasSignature():
+		return "definition differs (older body)"
+	default:
+		return fmt.Sprintf("signature differs (found %s(%s))", s.Name, s.Found[0])
+	}
+}
+
+// CheckFunctions compares the functions of the current schema with the ones
+// the binary calls, by signature and by a checksum of the body
+func CheckFunctions(ctx context.Context, db *sql.DB) ([]FunctionStatus, error) {
+	names := make([]string, len(Functions))
+	for i, fn := range Functions {
+		names[i] = fn.Name
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT p.proname, oidvectortypes(p.proargtypes),
+			md5(btrim(regexp_replace(p.prosrc, '\s+', ' ', 'g')))
+		FROM pg_proc p
+		JOIN pg_namespace n ON n.oid = p.pronamespace
+		WHERE n.nspname = current_schema() AND p.proname = ANY($1)
+		ORDER BY 1, 2
+	`, pq.Array(names))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query functions: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	found := map[string][]string{}
+	checksums := map[string]string{}
+	for rows.Next() {
+		var name, args, checksum string
+		if err := rows.Scan(&name, &args, &checksum); err != nil {
+			return nil, fmt.Errorf("failed to read functions: %w", err)
+		}
+		found[name] = append(found[name], args)
+		checksums[name+"("+args+")"] = checksum
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]FunctionStatus, len(Functions))
+	for i, fn := range Functions {
+		statuses[i] = FunctionStatus{Function: fn, Found: found[fn.Name]}
+		if checksum, ok := checksums[fn.Name+"("+fn.Args+")"]; ok {
+			want, err := fn.Checksum()
+			if err != nil {
+				return nil, err
+			}
+			statuses[i].Stale = checksum != want
+		}
+	}
+	return statuses, nil
+}
+
+// MissingFunctions returns the names of the functions that are missing,
+// have other arguments or an older body
+func MissingFunctions(statuses []FunctionStatus) []string {
+	var missing []string
+	for _, s := range statuses {
+		if !s.OK() {
+			missing = append(missing, s.Name)
+		}
+	}
+	return missing
+}
+
+// ApplyFunctions (re)creates the functions the binary calls in one
+// transaction. Every overload is dropped first, since CREATE OR REPLACE
+// can't change a function's result columns; running it again changes
+// nothing.
+func ApplyFunctions(ctx context.Context, db *sql.DB) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, fn := range Functions {
+		if err := dropOverloads(ctx, tx, fn.Name); err != nil {
+			return err
+		}
+		definition, err := functionFS.ReadFile("functions/" + fn.file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", fn.file, err)
+		}
+		if _, err := tx.ExecContext(ctx, string(definition)); err != nil {
+			return fmt.Errorf("failed to create %s: %w", fn.Name, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit functions: %w", err)
+	}
+	return nil
+}
+
+func dropOverloads(ctx context.Context, tx *sql.Tx, name string) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT p.oid::regprocedure::text
+		FROM pg_proc p
+		JOIN pg_namespace n ON n.oid = p.pronamespace
+		WHERE n.nspname = current_schema() AND p.proname = $1
+	`, name)
+	if err != nil {
+		return fmt.Errorf("failed to query %s: %w", name, err)
+	}
+	var signatures []string
+	for rows.Next() {
+		var signature string
+		if err := rows.Scan(&signature); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		signatures = append(signatures, signature)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, signature := range signatures {
+		if _, err := tx.ExecContext(ctx, "DROP FUNCTION "+signature); err != nil {
+			return fmt.Errorf("failed to drop %s: %w", signature, err)
+		}
+	}
+	return nil
+}
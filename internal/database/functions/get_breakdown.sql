@@ -0,0 +1,73 @@
+-- get_breakdown, as of migration 000050
+CREATE OR REPLACE FUNCTION get_breakdown(
+    p_website_id UUID,
+    p_dimension VARCHAR,
+    p_days INTEGER DEFAULT 1,
+    p_limit INTEGER DEFAULT 10,
+    p_offset INTEGER DEFAULT 0,
+    p_country VARCHAR DEFAULT NULL,
+    p_browser VARCHAR DEFAULT NULL,
+    p_device VARCHAR DEFAULT NULL,
+    p_page_path VARCHAR DEFAULT NULL,
+    p_dimensions JSONB DEFAULT NULL,
+    p_bots BOOLEAN DEFAULT NULL,
+    p_start TIMESTAMPTZ DEFAULT NULL,
+    p_end TIMESTAMPTZ DEFAULT NULL
+)
+RETURNS TABLE (name VARCHAR, count BIGINT, total_count BIGINT) AS $$
+DECLARE
+    v_since TIMESTAMPTZ := website_day_start(p_website_id, p_days);
+BEGIN
+    IF p_dimension NOT IN ('country', 'browser', 'device', 'referrer', 'city', 'region', 'page', 'asn', 'screen', 'viewport', 'channel')
+       AND NOT EXISTS (
+           SELECT 1 FROM custom_dimension cd
+           WHERE cd.website_id = p_website_id AND cd.name = p_dimension
+       ) THEN
+        RAISE EXCEPTION 'Invalid dimension: %. Must be country, browser, device, referrer, city, region, page, asn, screen, viewport, channel or a custom dimension', p_dimension;
+    END IF;
+
+    RETURN QUERY
+    WITH breakdown_data AS (
+        SELECT
+            (CASE p_dimension
+                WHEN 'country' THEN COALESCE(s.country, 'Unknown')
+                WHEN 'browser' THEN COALESCE(s.browser, 'Unknown')
+                WHEN 'device' THEN COALESCE(s.device, 'Unknown')
+                WHEN 'referrer' THEN COALESCE(e.referrer_domain, 'Direct / None')
+                WHEN 'city' THEN COALESCE(s.city || COALESCE(', ' || s.region, '') || COALESCE(', ' || s.country, ''), 'Unknown')
+                WHEN 'region' THEN COALESCE(s.region || COALESCE(', ' || s.country, ''), 'Unknown')
+                WHEN 'page' THEN e.url_path
+                WHEN 'asn' THEN COALESCE('AS' || s.asn || COALESCE(' ' || s.isp, ''), 'Unknown')
+                WHEN 'screen' THEN COALESCE(screen_bucket(s.screen), 'Unknown')
+                WHEN 'viewport' THEN COALESCE(viewport_class(s.screen), 'Unknown')
+                WHEN 'channel' THEN COALESCE(e.channel, 'Unknown')
+                ELSE COALESCE(e.dimensions->>p_dimension, 'Unknown')
+            END)::VARCHAR as dim_name,
+            COUNT(*)::BIGINT as dim_count
+        FROM website_event e
+        JOIN session s ON e.session_id = s.session_id
+        WHERE e.website_id = p_website_id
+          AND e.created_at >= v_since
+          AND e.event_type = 1
+          AND (p_dimension <> 'page' OR e.url_path IS NOT NULL)
+          AND (p_country IS NULL OR p_dimension = 'country' OR s.country = p_country)
+          AND (p_browser IS NULL OR p_dimension = 'browser' OR s.browser = p_browser)
+          AND (p_device IS NULL OR p_dimension = 'device' OR s.device = p_device)
+          AND (p_page_path IS NULL OR p_dimension = 'page' OR e.url_path = p_page_path)
+          AND (p_dimensions IS NULL OR (p_dimensions - p_dimension) = '{}'::jsonb OR e.dimensions @> (p_dimensions - p_dimension))
+          AND (p_bots IS NULL OR e.bot = p_bots)
+          AND (p_start IS NULL OR e.created_at >= p_start)
+          AND (p_end IS NULL OR e.created_at < p_end)
+        GROUP BY 1
+    ),
+    total_count_cte AS (
+        SELECT COUNT(*)::BIGINT as total FROM breakdown_data
+    )
+    SELECT bd.dim_name, bd.dim_count, tc.total
+    FROM breakdown_data bd
+    CROSS JOIN total_count_cte tc
+    ORDER BY bd.dim_count DESC
+    LIMIT p_limit
+    OFFSET p_offset;
+END;
+$$ LANGUAGE plpgsql STABLE;
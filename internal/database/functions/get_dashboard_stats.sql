@@ -0,0 +1,95 @@
+-- get_dashboard_stats, as of migration 000039
+CREATE OR REPLACE FUNCTION get_dashboard_stats(
+    p_website_id UUID,
+    p_days INTEGER DEFAULT 1,
+    p_country VARCHAR DEFAULT NULL,
+    p_browser VARCHAR DEFAULT NULL,
+    p_device VARCHAR DEFAULT NULL,
+    p_page_path VARCHAR DEFAULT NULL,
+    p_dimensions JSONB DEFAULT NULL,
+    p_bots BOOLEAN DEFAULT NULL
+)
+RETURNS TABLE (
+    current_visitors BIGINT,
+    today_pageviews BIGINT,
+    today_visitors BIGINT,
+    bounce_rate NUMERIC(5,2)
+) AS $$
+DECLARE
+    v_current_visitors BIGINT;
+    v_today_pageviews BIGINT;
+    v_today_visitors BIGINT;
+    v_bounce_rate NUMERIC(5,2);
+    v_bounces BIGINT;
+    v_today TIMESTAMPTZ := website_day_start(p_website_id, 0);
+BEGIN
+    -- 1. Current visitors (sessions in last 5 minutes)
+    SELECT COUNT(DISTINCT e.session_id) INTO v_current_visitors
+    FROM website_event e
+    JOIN session s ON e.session_id = s.session_id
+    WHERE e.website_id = p_website_id
+      AND e.created_at >= NOW() - INTERVAL '5 minutes'
+      AND e.event_type = 1
+      AND (p_country IS NULL OR s.country = p_country)
+      AND (p_browser IS NULL OR s.browser = p_browser)
+      AND (p_device IS NULL OR s.device = p_device)
+      AND (p_dimensions IS NULL OR e.dimensions @> p_dimensions)
+      AND (p_bots IS NULL OR e.bot = p_bots)
+      AND (p_page_path IS NULL OR e.url_path = p_page_path);
+
+    -- 2. Today's pageviews
+    SELECT COUNT(*) INTO v_today_pageviews
+    FROM website_event e
+    JOIN session s ON e.session_id = s.session_id
+    WHERE e.website_id = p_website_id
+      AND e.created_at >= v_today
+      AND e.event_type = 1
+      AND (p_country IS NULL OR s.country = p_country)
+      AND (p_browser IS NULL OR s.browser = p_browser)
+      AND (p_device IS NULL OR s.device = p_device)
+      AND (p_dimensions IS NULL OR e.dimensions @> p_dimensions)
+      AND (p_bots IS NULL OR e.bot = p_bots)
+      AND (p_page_path IS NULL OR e.url_path = p_page_path);
+
+    -- 3. Today's unique visitors
+    SELECT COUNT(DISTINCT e.session_id) INTO v_today_visitors
+    FROM website_event e
+    JOIN session s ON e.session_id = s.session_id
+    WHERE e.website_id = p_website_id
+      AND e.created_at >= v_today
+      AND e.event_type = 1
+      AND (p_country IS NULL OR s.country = p_country)
+      AND (p_browser IS NULL OR s.browser = p_browser)
+      AND (p_device IS NULL OR s.device = p_device)
+      AND (p_dimensions IS NULL OR e.dimensions @> p_dimensions)
+      AND (p_bots IS NULL OR e.bot = p_bots)
+      AND (p_page_path IS NULL OR e.url_path = p_page_path);
+
+    -- 4. Bounce rate (sessions with only 1 pageview)
+    v_bounce_rate := 0;
+    IF v_today_visitors > 0 THEN
+        SELECT COUNT(*) INTO v_bounces
+        FROM (
+            SELECT e.session_id
+            FROM website_event e
+            JOIN session s ON e.session_id = s.session_id
+            WHERE e.website_id = p_website_id
+              AND e.created_at >= v_today
+              AND e.event_type = 1
+              AND (p_country IS NULL OR s.country = p_country)
+              AND (p_browser IS NULL OR s.browser = p_browser)
+              AND (p_device IS NULL OR s.device = p_device)
+              AND (p_dimensions IS NULL OR e.dimensions @> p_dimensions)
+              AND (p_bots IS NULL OR e.bot = p_bots)
+              AND (p_page_path IS NULL OR e.url_path = p_page_path)
+            GROUP BY e.session_id
+            HAVING COUNT(*) = 1
+        ) bounced_sessions;
+
+        v_bounce_rate := (v_bounces::NUMERIC / v_today_visitors::NUMERIC) * 100;
+    END IF;
+
+    -- Return all stats as a single row
+    RETURN QUERY SELECT v_current_visitors, v_today_pageviews, v_today_visitors, v_bounce_rate;
+END;
+$$ LANGUAGE plpgsql STABLE;
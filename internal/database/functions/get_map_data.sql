@@ -0,0 +1,67 @@
+-- get_map_data, as of migration 000038
+CREATE OR REPLACE FUNCTION get_map_data(
+    p_website_id UUID,
+    p_days INTEGER DEFAULT 7,
+    p_country VARCHAR DEFAULT NULL,
+    p_browser VARCHAR DEFAULT NULL,
+    p_device VARCHAR DEFAULT NULL,
+    p_page_path VARCHAR DEFAULT NULL,
+    p_dimensions JSONB DEFAULT NULL,
+    p_bots BOOLEAN DEFAULT NULL,
+    p_start TIMESTAMPTZ DEFAULT NULL,
+    p_end TIMESTAMPTZ DEFAULT NULL
+)
+RETURNS TABLE (
+    country VARCHAR,
+    visitors BIGINT,
+    percentage NUMERIC(5,2)
+) AS $$
+BEGIN
+    RETURN QUERY
+    WITH total_visitors AS (
+        SELECT COUNT(DISTINCT e.session_id)::BIGINT as total
+        FROM website_event e
+        JOIN session s ON e.session_id = s.session_id
+        WHERE e.website_id = p_website_id
+          AND e.created_at >= NOW() - (p_days || ' days')::INTERVAL
+          AND e.event_type = 1
+          AND (p_country IS NULL OR s.country = p_country)
+          AND (p_browser IS NULL OR s.browser = p_browser)
+          AND (p_device IS NULL OR s.device = p_device)
+          AND (p_dimensions IS NULL OR e.dimensions @> p_dimensions)
+          AND (p_bots IS NULL OR e.bot = p_bots)
+          AND (p_start IS NULL OR e.created_at >= p_start)
+          AND (p_end IS NULL OR e.created_at < p_end)
+          AND (p_page_path IS NULL OR e.url_path = p_page_path)
+    ),
+    country_breakdown AS (
+        SELECT
+            COALESCE(s.country, 'Unknown')::VARCHAR as country_code,
+            COUNT(DISTINCT e.session_id)::BIGINT as visitor_count
+        FROM website_event e
+        JOIN session s ON e.session_id = s.session_id
+        WHERE e.website_id = p_website_id
+          AND e.created_at >= NOW() - (p_days || ' days')::INTERVAL
+          AND e.event_type = 1
+          AND (p_country IS NULL OR s.country = p_country)
+          AND (p_browser IS NULL OR s.browser = p_browser)
+          AND (p_device IS NULL OR s.device = p_device)
+          AND (p_dimensions IS NULL OR e.dimensions @> p_dimensions)
+          AND (p_bots IS NULL OR e.bot = p_bots)
+          AND (p_start IS NULL OR e.created_at >= p_start)
+          AND (p_end IS NULL OR e.created_at < p_end)
+          AND (p_page_path IS NULL OR e.url_path = p_page_path)
+        GROUP BY s.country
+    )
+    SELECT
+        cb.country_code,
+        cb.visitor_count,
+        CASE
+            WHEN tv.total > 0 THEN ROUND((cb.visitor_count::NUMERIC / tv.total::NUMERIC * 100), 2)
+            ELSE 0
+        END as pct
+    FROM country_breakdown cb
+    CROSS JOIN total_visitors tv
+    ORDER BY cb.visitor_count DESC;
+END;
+$$ LANGUAGE plpgsql STABLE;
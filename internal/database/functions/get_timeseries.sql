@@ -0,0 +1,55 @@
+-- get_timeseries, as of migration 000040
+CREATE OR REPLACE FUNCTION get_timeseries(
+    p_website_id UUID,
+    p_days INTEGER DEFAULT 7,
+    p_country VARCHAR DEFAULT NULL,
+    p_browser VARCHAR DEFAULT NULL,
+    p_device VARCHAR DEFAULT NULL,
+    p_page_path VARCHAR DEFAULT NULL,
+    p_dimensions JSONB DEFAULT NULL,
+    p_bots BOOLEAN DEFAULT NULL,
+    p_start TIMESTAMPTZ DEFAULT NULL,
+    p_end TIMESTAMPTZ DEFAULT NULL,
+    p_interval VARCHAR DEFAULT 'hour'
+)
+RETURNS TABLE (
+    hour TIMESTAMPTZ,
+    views BIGINT
+) AS $$
+DECLARE
+    v_timezone TEXT := website_timezone(p_website_id);
+    v_since TIMESTAMPTZ;
+BEGIN
+    IF p_interval NOT IN ('minute', 'hour', 'day', 'week', 'month') THEN
+        RAISE EXCEPTION 'Invalid interval: %. Must be minute, hour, day, week or month', p_interval;
+    END IF;
+
+    -- Minutes and hours chart a rolling window; days, weeks and months start
+    -- at midnight so their first bucket is whole
+    IF p_interval IN ('minute', 'hour') THEN
+        v_since := NOW() - (p_days || ' days')::INTERVAL;
+    ELSE
+        v_since := website_day_start(p_website_id, p_days);
+    END IF;
+
+    RETURN QUERY
+    SELECT
+        DATE_TRUNC(p_interval, e.created_at AT TIME ZONE v_timezone) AT TIME ZONE v_timezone as hour,
+        COUNT(*)::BIGINT as views
+    FROM website_event e
+    JOIN session s ON e.session_id = s.session_id
+    WHERE e.website_id = p_website_id
+      AND e.created_at >= v_since
+      AND e.event_type = 1
+      AND (p_country IS NULL OR s.country = p_country)
+      AND (p_browser IS NULL OR s.browser = p_browser)
+      AND (p_device IS NULL OR s.device = p_device)
+      AND (p_dimensions IS NULL OR e.dimensions @> p_dimensions)
+      AND (p_bots IS NULL OR e.bot = p_bots)
+      AND (p_start IS NULL OR e.created_at >= p_start)
+      AND (p_end IS NULL OR e.created_at < p_end)
+      AND (p_page_path IS NULL OR e.url_path = p_page_path)
+    GROUP BY hour
+    ORDER BY hour ASC;
+END;
+$$ LANGUAGE plpgsql STABLE;
@@ -0,0 +1,74 @@
+-- get_top_pages, as of migration 000039
+CREATE OR REPLACE FUNCTION get_top_pages(
+    p_website_id UUID,
+    p_days INTEGER DEFAULT 1,
+    p_limit INTEGER DEFAULT 10,
+    p_offset INTEGER DEFAULT 0,
+    p_country VARCHAR DEFAULT NULL,
+    p_browser VARCHAR DEFAULT NULL,
+    p_device VARCHAR DEFAULT NULL,
+    p_dimensions JSONB DEFAULT NULL,
+    p_bots BOOLEAN DEFAULT NULL,
+    p_start TIMESTAMPTZ DEFAULT NULL,
+    p_end TIMESTAMPTZ DEFAULT NULL
+)
+RETURNS TABLE (
+    path VARCHAR,
+    views BIGINT,
+    unique_visitors BIGINT,
+    avg_engagement_time NUMERIC,
+    bounce_rate NUMERIC,
+    total_count BIGINT
+) AS $$
+DECLARE
+    v_since TIMESTAMPTZ := website_day_start(p_website_id, p_days);
+BEGIN
+    RETURN QUERY
+    WITH filtered_events AS (
+        SELECT
+            e.url_path,
+            e.session_id,
+            e.engagement_time,
+            COUNT(*) OVER (PARTITION BY e.session_id) AS session_pageviews
+        FROM website_event e
+        JOIN session s ON e.session_id = s.session_id
+        WHERE e.website_id = p_website_id
+          AND e.created_at >= v_since
+          AND e.event_type = 1
+          AND e.url_path IS NOT NULL
+          AND (p_country IS NULL OR s.country = p_country)
+          AND (p_browser IS NULL OR s.browser = p_browser)
+          AND (p_device IS NULL OR s.device = p_device)
+          AND (p_dimensions IS NULL OR e.dimensions @> p_dimensions)
+          AND (p_bots IS NULL OR e.bot = p_bots)
+          AND (p_start IS NULL OR e.created_at >= p_start)
+          AND (p_end IS NULL OR e.created_at < p_end)
+    ),
+    page_stats AS (
+        SELECT
+            fe.url_path,
+            COUNT(*)::BIGINT as view_count,
+            COUNT(DISTINCT fe.session_id)::BIGINT as unique_visitor_count,
+            ROUND(AVG(COALESCE(fe.engagement_time, 0)), 0) as avg_time,
+            ROUND(COUNT(DISTINCT fe.session_id) FILTER (WHERE fe.session_pageviews = 1)::NUMERIC
+                / COUNT(DISTINCT fe.session_id) * 100, 1) as bounce
+        FROM filtered_events fe
+        GROUP BY fe.url_path
+    ),
+    total_count_cte AS (
+        SELECT COUNT(*)::BIGINT as total FROM page_stats
+    )
+    SELECT
+        ps.url_path::VARCHAR,
+        ps.view_count,
+        ps.unique_visitor_count,
+        ps.avg_time,
+        ps.bounce,
+        tc.total as total_count
+    FROM page_stats ps
+    CROSS JOIN total_count_cte tc
+    ORDER BY ps.view_count DESC
+    LIMIT p_limit
+    OFFSET p_offset;
+END;
+$$ LANGUAGE plpgsql STABLE;
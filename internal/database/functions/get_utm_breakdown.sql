@@ -0,0 +1,77 @@
+-- get_utm_breakdown, as of migration 000046
+CREATE OR REPLACE FUNCTION get_utm_breakdown(
+    p_website_id UUID,
+    p_dimension VARCHAR DEFAULT 'campaign',
+    p_days INTEGER DEFAULT 7,
+    p_limit INTEGER DEFAULT 10,
+    p_offset INTEGER DEFAULT 0,
+    p_event_name VARCHAR DEFAULT NULL,
+    p_goal_props JSONB DEFAULT NULL
+)
+RETURNS TABLE (
+    name TEXT,
+    visitors BIGINT,
+    pageviews BIGINT,
+    conversions BIGINT,
+    total_count BIGINT
+) AS $$
+BEGIN
+    IF p_dimension NOT IN ('source', 'medium', 'campaign', 'content', 'term') THEN
+        RAISE EXCEPTION 'Invalid UTM dimension: %', p_dimension;
+    END IF;
+
+    RETURN QUERY
+    WITH ranged AS (
+        SELECT e.session_id, e.event_type, e.event_name, e.props,
+            CASE p_dimension
+                WHEN 'source' THEN e.utm_source
+                WHEN 'medium' THEN e.utm_medium
+                WHEN 'campaign' THEN e.utm_campaign
+                WHEN 'content' THEN e.utm_content
+                WHEN 'term' THEN e.utm_term
+            END AS utm_value
+        FROM website_event e
+        WHERE e.website_id = p_website_id
+          AND e.created_at >= NOW() - (p_days || ' days')::INTERVAL
+    ),
+    attributed AS (
+        SELECT DISTINCT r.utm_value::TEXT AS utm_value, r.session_id
+        FROM ranged r
+        WHERE r.event_type = 1 AND r.utm_value IS NOT NULL AND r.utm_value <> ''
+    ),
+    session_pageviews AS (
+        SELECT r.session_id, COUNT(*) AS views
+        FROM ranged r
+        WHERE r.event_type = 1
+        GROUP BY r.session_id
+    ),
+    converted AS (
+        SELECT DISTINCT r.session_id
+        FROM ranged r
+        WHERE r.event_type = 2
+          AND (p_event_name IS NULL OR r.event_name = p_event_name)
+          AND (p_goal_props IS NULL OR NOT EXISTS (
+              SELECT 1 FROM jsonb_each(p_goal_props) g
+              WHERE NOT EXISTS (
+                  SELECT 1 FROM jsonb_array_elements(g.value) v
+                  WHERE jsonb_typeof(r.props) = 'object' AND r.props -> g.key @> v.value
+              )
+          ))
+    )
+    SELECT
+        a.utm_value AS name,
+        COUNT(*)::BIGINT AS visitors,
+        COALESCE(SUM(sp.views), 0)::BIGINT AS pageviews,
+        COUNT(c.session_id)::BIGINT AS conversions,
+        COUNT(*) OVER()::BIGINT AS total_count
+    FROM attributed a
+    LEFT JOIN session_pageviews sp ON sp.session_id = a.session_id
+    LEFT JOIN converted c ON c.session_id = a.session_id
+    GROUP BY a.utm_value
+    ORDER BY visitors DESC, name
+    LIMIT p_limit
+    OFFSET p_offset;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+COMMENT ON FUNCTION get_utm_breakdown IS 'UTM campaign breakdown with visitors, pageviews and conversions per value';
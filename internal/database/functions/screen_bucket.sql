@@ -0,0 +1,16 @@
+-- screen_bucket, as of migration 000030
+CREATE OR REPLACE FUNCTION screen_bucket(p_screen VARCHAR)
+RETURNS VARCHAR AS $$
+    SELECT CASE
+        WHEN w IS NULL THEN NULL
+        WHEN w < 576 THEN 'Under 576px'
+        WHEN w < 768 THEN '576-767px'
+        WHEN w < 992 THEN '768-991px'
+        WHEN w < 1200 THEN '992-1199px'
+        WHEN w < 1440 THEN '1200-1439px'
+        WHEN w < 1920 THEN '1440-1919px'
+        WHEN w < 2560 THEN '1920-2559px'
+        ELSE '2560px and up'
+    END
+    FROM (SELECT screen_width(p_screen) AS w) sw;
+$$ LANGUAGE sql IMMUTABLE;
@@ -0,0 +1,6 @@
+-- screen_width, as of migration 000030
+CREATE OR REPLACE FUNCTION screen_width(p_screen VARCHAR)
+RETURNS INTEGER AS $$
+    SELECT CASE WHEN p_screen ~ '^[0-9]{1,5}x[0-9]{1,5}$'
+        THEN split_part(p_screen, 'x', 1)::INTEGER END;
+$$ LANGUAGE sql IMMUTABLE;
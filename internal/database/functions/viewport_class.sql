@@ -0,0 +1,12 @@
+-- viewport_class, as of migration 000030
+CREATE OR REPLACE FUNCTION viewport_class(p_screen VARCHAR)
+RETURNS VARCHAR AS $$
+    SELECT CASE
+        WHEN w IS NULL THEN NULL
+        WHEN w < 768 THEN 'Mobile'
+        WHEN w < 1024 THEN 'Tablet'
+        WHEN w < 1920 THEN 'Desktop'
+        ELSE 'Wide'
+    END
+    FROM (SELECT screen_width(p_screen) AS w) sw;
+$$ LANGUAGE sql IMMUTABLE;
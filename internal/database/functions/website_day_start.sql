@@ -0,0 +1,5 @@
+-- website_day_start, as of migration 000039
+CREATE OR REPLACE FUNCTION website_day_start(p_website_id UUID, p_days INTEGER)
+RETURNS TIMESTAMPTZ AS $$
+    SELECT (website_today(p_website_id) - p_days)::TIMESTAMP AT TIME ZONE website_timezone(p_website_id);
+$$ LANGUAGE sql STABLE;
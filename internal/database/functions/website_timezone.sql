@@ -0,0 +1,5 @@
+-- website_timezone, as of migration 000039
+CREATE OR REPLACE FUNCTION website_timezone(p_website_id UUID)
+RETURNS TEXT AS $$
+    SELECT COALESCE((SELECT timezone FROM website WHERE website_id = p_website_id), 'UTC');
+$$ LANGUAGE sql STABLE;
@@ -0,0 +1,5 @@
+-- website_today, as of migration 000039
+CREATE OR REPLACE FUNCTION website_today(p_website_id UUID)
+RETURNS DATE AS $$
+    SELECT (NOW() AT TIME ZONE website_timezone(p_website_id))::DATE;
+$$ LANGUAGE sql STABLE;
@@ -0,0 +1,150 @@
+package database
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFunctionDefinitions(t *testing.T) {
+	for _, fn := range Functions {
+		definition, err := functionFS.ReadFile("functions/" + fn.file)
+		require.NoError(t, err, fn.Name)
+		assert.Contains(t, string(definition), "CREATE OR REPLACE FUNCTION "+fn.Name+"(", fn.Name)
+		// One parameter per argument type
+		header, _, _ := strings.Cut(string(definition), "RETURNS")
+		params := regexp.MustCompile(`\bp_\w+ [A-Z]+`).FindAllString(header, -1)
+		assert.Len(t, params, len(strings.Split(fn.Args, ", ")), fn.Name)
+	}
+}
+
+func TestCheckFunctions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	rows := sqlmock.NewRows([]string{"proname", "oidvectortypes", "md5"})
+	for _, fn := range Functions {
+		checksum, err := fn.Checksum()
+		require.NoError(t, err)
+		switch fn.Name {
+		case "get_top_pages":
+			// An older build's function
+			rows.AddRow(fn.Name, "uuid, integer, integer, integer, character varying, character varying, character varying, jsonb", checksum)
+		case "get_breakdown":
+			// The expected signature with an older migration's body
+			rows.AddRow(fn.Name, fn.Args, "0cc175b9c0f1b6a831c399e269772661")
+		case "get_utm_breakdown":
+		default:
+			rows.AddRow(fn.Name, fn.Args, checksum)
+		}
+	}
+	mock.ExpectQuery(`FROM pg_proc p`).WillReturnRows(rows)
+
+	statuses, err := CheckFunctions(context.Background(), db)
+	require.NoError(t, err)
+	require.Len(t, statuses, len(Functions))
+	assert.Equal(t, []string{"get_top_pages", "get_breakdown", "get_utm_breakdown"}, MissingFunctions(statuses))
+
+	for _, s := range statuses {
+		switch s.Name {
+		case "get_top_pages":
+			assert.Equal(t, "signature differs (found get_top_pages(uuid, integer, integer, integer, character varying, character varying, character varying, jsonb))", s.Problem())
+		case "get_breakdown":
+			assert.Equal(t, "definition differs (older body)", s.Problem())
+		case "get_utm_breakdown":
+			assert.Equal(t, "missing", s.Problem())
+		default:
+			assert.Empty(t, s.Problem(), s.Name)
+		}
+	}
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFunctionChecksum(t *testing.T) {
+	fn := Function{Name: "website_today", Args: "uuid", file: "website_today.sql"}
+	definition, err := functionFS.ReadFile("functions/website_today.sql")
+	require.NoError(t, err)
+	_, body, _ := strings.Cut(string(definition), "$$")
+	body, _, _ = strings.Cut(body, "$$")
+
+	checksum, err := fn.Checksum()
+	require.NoError(t, err)
+	// What PostgreSQL computes of prosrc, the body as written
+	sum := md5.Sum([]byte(regexp.MustCompile(`\s+`).ReplaceAllString(strings.TrimSpace(body), " ")))
+	assert.Equal(t, hex.EncodeToString(sum[:]), checksum)
+
+	for _, fn := range Functions {
+		_, err := fn.Checksum()
+		assert.NoError(t, err, fn.Name)
+	}
+}
+
+func TestApplyFunctions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectBegin()
+	for _, fn := range Functions {
+		overloads := sqlmock.NewRows([]string{"oid"})
+		if fn.Name == "get_top_pages" {
+			overloads.AddRow("get_top_pages(uuid,integer,integer,integer,character varying,character varying,character varying,jsonb)")
+		}
+		mock.ExpectQuery(`SELECT p.oid::regprocedure::text`).WithArgs(fn.Name).WillReturnRows(overloads)
+		if fn.Name == "get_top_pages" {
+			mock.ExpectExec(regexp.QuoteMeta("DROP FUNCTION get_top_pages(uuid,integer,integer,integer,")).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		mock.ExpectExec(regexp.QuoteMeta("CREATE OR REPLACE FUNCTION " + fn.Name + "(")).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectCommit()
+
+	require.NoError(t, ApplyFunctions(context.Background(), db))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestFunctionDefinitionsMatchMigrations keeps the embedded definitions in
+// step with the migrations: each must be the one of the last migration that
+// creates the function
+func TestFunctionDefinitionsMatchMigrations(t *testing.T) {
+	files, err := fs.Glob(migrationFS, "migrations/*.up.sql")
+	require.NoError(t, err)
+	sort.Strings(files)
+	space := regexp.MustCompile(`\s+`)
+	normalize := func(definition string) string {
+		definition = strings.Replace(definition, "CREATE FUNCTION", "CREATE OR REPLACE FUNCTION", 1)
+		return space.ReplaceAllString(definition, " ")
+	}
+
+	for _, fn := range Functions {
+		create := regexp.MustCompile(`(?s)CREATE (?:OR REPLACE )?FUNCTION ` + fn.Name + `\(.*?\$\$ LANGUAGE [^;]*;`)
+		var lastFile, lastDefinition string
+		for _, file := range files {
+			migration, err := migrationFS.ReadFile(file)
+			require.NoError(t, err)
+			if matches := create.FindAllString(string(migration), -1); len(matches) > 0 {
+				lastFile, lastDefinition = file, matches[len(matches)-1]
+			}
+		}
+		require.NotEmpty(t, lastFile, "no migration creates %s", fn.Name)
+
+		definition, err := functionFS.ReadFile("functions/" + fn.file)
+		require.NoError(t, err)
+		version, _, _ := strings.Cut(strings.TrimPrefix(lastFile, "migrations/"), "_")
+		assert.True(t, strings.HasPrefix(string(definition), "-- "+fn.Name+", as of migration "+version+"\n"),
+			"%s: the last migration creating it is %s", fn.file, lastFile)
+		assert.Equal(t, normalize(lastDefinition), normalize(create.FindString(string(definition))),
+			"%s differs from %s", fn.file, lastFile)
+	}
+}
@@ -2,7 +2,11 @@ package database
 
 import (
 	"embed"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"slices"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
@@ -14,16 +18,9 @@ var migrationFS embed.FS
 
 // RunMigrations runs all pending database migrations
 func RunMigrations(databaseURL string) error {
-	// Create iofs source from embedded files
-	sourceDriver, err := iofs.New(migrationFS, "migrations")
-	if err != nil {
-		return fmt.Errorf("failed to create migration source: %w", err)
-	}
-
-	// Create migrate instance
-	m, err := migrate.NewWithSourceInstance("iofs", sourceDriver, databaseURL)
+	m, err := newMigrate(databaseURL)
 	if err != nil {
-		return fmt.Errorf("failed to create migrate instance: %w", err)
+		return err
 	}
 	defer func() {
 		_, _ = m.Close()
@@ -39,14 +36,9 @@ func RunMigrations(databaseURL string) error {
 
 // GetMigrationVersion returns the current migration version
 func GetMigrationVersion(databaseURL string) (uint, bool, error) {
-	sourceDriver, err := iofs.New(migrationFS, "migrations")
-	if err != nil {
-		return 0, false, fmt.Errorf("failed to create migration source: %w", err)
-	}
-
-	m, err := migrate.NewWithSourceInstance("iofs", sourceDriver, databaseURL)
+	m, err := newMigrate(databaseURL)
 	if err != nil {
-		return 0, false, fmt.Errorf("failed to create migrate instance: %w", err)
+		return 0, false, err
 	}
 	defer func() {
 		_, _ = m.Close()
@@ -59,3 +51,209 @@ func GetMigrationVersion(databaseURL string) (uint, bool, error) {
 
 	return version, dirty, nil
 }
+
+// LatestMigration returns the version of the newest migration built into the
+// binary, the version a database is at once migrated up
+func LatestMigration() (uint, error) {
+	versions, err := migrationVersions()
+	if err != nil {
+		return 0, err
+	}
+	return versions[len(versions)-1], nil
+}
+
+// migrationVersions returns the versions of the migrations built into the
+// binary, oldest first
+func migrationVersions() ([]uint, error) {
+	sourceDriver, err := iofs.New(migrationFS, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migration source: %w", err)
+	}
+	defer func() { _ = sourceDriver.Close() }()
+
+	version, err := sourceDriver.First()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+	versions := []uint{version}
+	for {
+		next, err := sourceDriver.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return versions, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migrations: %w", err)
+		}
+		versions = append(versions, next)
+		version = next
+	}
+}
+
+// Migration is one migration file built into the binary
+type Migration struct {
+	Version    uint
+	Identifier string
+	Up         bool
+	SQL        string
+}
+
+// StepTarget returns the version steps migrations away from version from:
+// newer when steps is positive, older when negative. Version 0 is an empty
+// schema.
+func StepTarget(from uint, steps int) (uint, error) {
+	versions, err := migrationVersions()
+	if err != nil {
+		return 0, err
+	}
+	index := -1
+	if from != 0 {
+		if index = slices.Index(versions, from); index < 0 {
+			return 0, fmt.Errorf("the database is at version %d, which this binary doesn't know", from)
+		}
+	}
+
+	target := index + steps
+	switch {
+	case target < -1:
+		return 0, fmt.Errorf("can't roll back %d migrations from version %d", -steps, from)
+	case target >= len(versions):
+		return 0, fmt.Errorf("can't apply %d migrations past version %d (the newest is %d)", steps, from, versions[len(versions)-1])
+	case target == -1:
+		return 0, nil
+	}
+	return versions[target], nil
+}
+
+// PlanMigrations returns the migrations that take a database from version
+// from to version to, in the order they run: up migrations when to is
+// newer, down migrations newest first when it is older. Version 0 is an
+// empty schema.
+func PlanMigrations(from, to uint) ([]Migration, error) {
+	versions, err := migrationVersions()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range []uint{from, to} {
+		if v != 0 && !slices.Contains(versions, v) {
+			return nil, fmt.Errorf("no migration %d (the newest is %d)", v, versions[len(versions)-1])
+		}
+	}
+
+	sourceDriver, err := iofs.New(migrationFS, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migration source: %w", err)
+	}
+	defer func() { _ = sourceDriver.Close() }()
+
+	read := func(version uint, up bool) (Migration, error) {
+		var (
+			r          io.ReadCloser
+			identifier string
+			err        error
+		)
+		if up {
+			r, identifier, err = sourceDriver.ReadUp(version)
+		} else {
+			r, identifier, err = sourceDriver.ReadDown(version)
+		}
+		if err != nil {
+			return Migration{}, fmt.Errorf("failed to read migration %d: %w", version, err)
+		}
+		defer func() { _ = r.Close() }()
+		sql, err := io.ReadAll(r)
+		if err != nil {
+			return Migration{}, fmt.Errorf("failed to read migration %d: %w", version, err)
+		}
+		return Migration{Version: version, Identifier: identifier, Up: up, SQL: string(sql)}, nil
+	}
+
+	var plan []Migration
+	if to > from {
+		for _, v := range versions {
+			if v > from && v <= to {
+				m, err := read(v, true)
+				if err != nil {
+					return nil, err
+				}
+				plan = append(plan, m)
+			}
+		}
+	} else {
+		for i := len(versions) - 1; i >= 0; i-- {
+			if v := versions[i]; v > to && v <= from {
+				m, err := read(v, false)
+				if err != nil {
+					return nil, err
+				}
+				plan = append(plan, m)
+			}
+		}
+	}
+	return plan, nil
+}
+
+// MigrateTo migrates the database up or down to version; version 0 rolls
+// every migration back
+func MigrateTo(databaseURL string, version uint) error {
+	m, err := newMigrate(databaseURL)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = m.Close()
+	}()
+
+	if version == 0 {
+		err = m.Down()
+	} else {
+		err = m.Migrate(version)
+	}
+	if err != nil && err != migrate.ErrNoChange {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+	return nil
+}
+
+// ForceMigration records version as applied and clears the dirty flag
+// without running any SQL, to recover from a migration that failed halfway;
+// version 0 records that none is applied
+func ForceMigration(databaseURL string, version uint) error {
+	if version != 0 {
+		versions, err := migrationVersions()
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(versions, version) {
+			return fmt.Errorf("no migration %d (the newest is %d)", version, versions[len(versions)-1])
+		}
+	}
+
+	m, err := newMigrate(databaseURL)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = m.Close()
+	}()
+
+	forced := int(version)
+	if version == 0 {
+		forced = -1 // golang-migrate's nil version
+	}
+	if err := m.Force(forced); err != nil {
+		return fmt.Errorf("failed to force version: %w", err)
+	}
+	return nil
+}
+
+func newMigrate(databaseURL string) (*migrate.Migrate, error) {
+	sourceDriver, err := iofs.New(migrationFS, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migration source: %w", err)
+	}
+	m, err := migrate.NewWithSourceInstance("iofs", sourceDriver, databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migrate instance: %w", err)
+	}
+	return m, nil
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationVersionsAreEmbeddedInOrder(t *testing.T) {
+	versions, err := migrationVersions()
+	require.NoError(t, err)
+	require.NotEmpty(t, versions)
+	assert.Equal(t, uint(1), versions[0])
+	for i := 1; i < len(versions); i++ {
+		assert.Greater(t, versions[i], versions[i-1])
+	}
+
+	latest, err := LatestMigration()
+	require.NoError(t, err)
+	assert.Equal(t, versions[len(versions)-1], latest)
+}
+
+func TestStepTarget(t *testing.T) {
+	latest, err := LatestMigration()
+	require.NoError(t, err)
+
+	target, err := StepTarget(latest, -2)
+	require.NoError(t, err)
+	assert.Equal(t, latest-2, target)
+
+	target, err = StepTarget(0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), target)
+
+	target, err = StepTarget(1, -1)
+	require.NoError(t, err)
+	assert.Equal(t, uint(0), target)
+
+	_, err = StepTarget(1, -2)
+	assert.ErrorContains(t, err, "can't roll back 2 migrations from version 1")
+
+	_, err = StepTarget(latest, 1)
+	assert.ErrorContains(t, err, "can't apply 1 migrations past version")
+
+	_, err = StepTarget(latest+1, -1)
+	assert.ErrorContains(t, err, "doesn't know")
+}
+
+func TestPlanMigrations(t *testing.T) {
+	latest, err := LatestMigration()
+	require.NoError(t, err)
+
+	down, err := PlanMigrations(latest, latest-2)
+	require.NoError(t, err)
+	require.Len(t, down, 2)
+	assert.Equal(t, latest, down[0].Version)
+	assert.Equal(t, latest-1, down[1].Version)
+	for _, m := range down {
+		assert.False(t, m.Up)
+		assert.Contains(t, m.SQL, "-- Rollback Migration")
+	}
+
+	up, err := PlanMigrations(latest-2, latest)
+	require.NoError(t, err)
+	require.Len(t, up, 2)
+	assert.Equal(t, latest-1, up[0].Version)
+	assert.Equal(t, latest, up[1].Version)
+	assert.True(t, up[1].Up)
+	assert.NotEmpty(t, up[1].Identifier)
+
+	all, err := PlanMigrations(0, latest)
+	require.NoError(t, err)
+	assert.Len(t, all, int(latest))
+
+	none, err := PlanMigrations(latest, latest)
+	require.NoError(t, err)
+	assert.Empty(t, none)
+
+	_, err = PlanMigrations(0, latest+1)
+	assert.ErrorContains(t, err, "no migration")
+}
@@ -0,0 +1,13 @@
+-- Rollback Migration 000010: Remove UTM Campaign Columns
+
+DROP FUNCTION IF EXISTS get_utm_breakdown(UUID, VARCHAR, INTEGER, INTEGER, INTEGER, VARCHAR);
+
+DROP INDEX IF EXISTS idx_website_event_utm_source;
+DROP INDEX IF EXISTS idx_website_event_utm_campaign;
+
+ALTER TABLE website_event
+    DROP COLUMN IF EXISTS utm_term,
+    DROP COLUMN IF EXISTS utm_content,
+    DROP COLUMN IF EXISTS utm_campaign,
+    DROP COLUMN IF EXISTS utm_medium,
+    DROP COLUMN IF EXISTS utm_source;
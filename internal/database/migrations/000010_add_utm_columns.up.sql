@@ -0,0 +1,135 @@
+-- Migration 000010: Add UTM Campaign Columns
+-- Stores utm_* parameters extracted from the page URL at ingestion time and
+-- adds get_utm_breakdown() for campaign reporting (visitors, pageviews, conversions).
+
+-- ============================================================================
+-- 1. UTM columns on website_event
+-- ============================================================================
+
+ALTER TABLE website_event
+    ADD COLUMN IF NOT EXISTS utm_source VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS utm_medium VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS utm_campaign VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS utm_content VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS utm_term VARCHAR(255);
+
+-- Backfill existing events from the stored query string. Values are
+-- decoded (%XX and +) as at ingestion; url_decode only lives for the
+-- migration's session.
+CREATE FUNCTION pg_temp.url_decode(p_value TEXT)
+RETURNS TEXT AS $$
+DECLARE
+    v_text TEXT := replace(p_value, '+', ' ');
+    v_bytes BYTEA := '';
+    v_hex TEXT;
+    i INTEGER := 1;
+BEGIN
+    WHILE i <= length(v_text) LOOP
+        v_hex := substr(v_text, i + 1, 2);
+        IF substr(v_text, i, 1) = '%' AND v_hex ~ '^[0-9A-Fa-f]{2}$' THEN
+            v_bytes := v_bytes || decode(v_hex, 'hex');
+            i := i + 3;
+        ELSE
+            v_bytes := v_bytes || convert_to(substr(v_text, i, 1), 'UTF8');
+            i := i + 1;
+        END IF;
+    END LOOP;
+    RETURN convert_from(v_bytes, 'UTF8');
+EXCEPTION WHEN OTHERS THEN
+    -- Not UTF-8 once decoded: keep the value as sent
+    RETURN p_value;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+UPDATE website_event
+SET
+    utm_source = LEFT(pg_temp.url_decode(substring(url_query FROM '(?:^|&)utm_source=([^&]*)')), 255),
+    utm_medium = LEFT(pg_temp.url_decode(substring(url_query FROM '(?:^|&)utm_medium=([^&]*)')), 255),
+    utm_campaign = LEFT(pg_temp.url_decode(substring(url_query FROM '(?:^|&)utm_campaign=([^&]*)')), 255),
+    utm_content = LEFT(pg_temp.url_decode(substring(url_query FROM '(?:^|&)utm_content=([^&]*)')), 255),
+    utm_term = LEFT(pg_temp.url_decode(substring(url_query FROM '(?:^|&)utm_term=([^&]*)')), 255)
+WHERE url_query LIKE '%utm_%';
+
+CREATE INDEX IF NOT EXISTS idx_website_event_utm_campaign
+    ON website_event(website_id, created_at, utm_campaign)
+    WHERE utm_campaign IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_website_event_utm_source
+    ON website_event(website_id, created_at, utm_source)
+    WHERE utm_source IS NOT NULL;
+
+-- ============================================================================
+-- 2. get_utm_breakdown() - Campaign attribution per UTM dimension
+-- ============================================================================
+-- A session is attributed to every UTM value it landed with. Pageviews are all
+-- pageviews of attributed sessions; conversions are attributed sessions that
+-- fired a custom event (optionally restricted to p_event_name).
+
+CREATE OR REPLACE FUNCTION get_utm_breakdown(
+    p_website_id UUID,
+    p_dimension VARCHAR DEFAULT 'campaign',
+    p_days INTEGER DEFAULT 7,
+    p_limit INTEGER DEFAULT 10,
+    p_offset INTEGER DEFAULT 0,
+    p_event_name VARCHAR DEFAULT NULL
+)
+RETURNS TABLE (
+    name TEXT,
+    visitors BIGINT,
+    pageviews BIGINT,
+    conversions BIGINT,
+    total_count BIGINT
+) AS $$
+BEGIN
+    IF p_dimension NOT IN ('source', 'medium', 'campaign', 'content', 'term') THEN
+        RAISE EXCEPTION 'Invalid UTM dimension: %', p_dimension;
+    END IF;
+
+    RETURN QUERY
+    WITH ranged AS (
+        SELECT e.session_id, e.event_type, e.event_name,
+            CASE p_dimension
+                WHEN 'source' THEN e.utm_source
+                WHEN 'medium' THEN e.utm_medium
+                WHEN 'campaign' THEN e.utm_campaign
+                WHEN 'content' THEN e.utm_content
+                WHEN 'term' THEN e.utm_term
+            END AS utm_value
+        FROM website_event e
+        WHERE e.website_id = p_website_id
+          AND e.created_at >= NOW() - (p_days || ' days')::INTERVAL
+    ),
+    attributed AS (
+        SELECT DISTINCT r.utm_value::TEXT AS utm_value, r.session_id
+        FROM ranged r
+        WHERE r.event_type = 1 AND r.utm_value IS NOT NULL AND r.utm_value <> ''
+    ),
+    session_pageviews AS (
+        SELECT r.session_id, COUNT(*) AS views
+        FROM ranged r
+        WHERE r.event_type = 1
+        GROUP BY r.session_id
+    ),
+    converted AS (
+        SELECT DISTINCT r.session_id
+        FROM ranged r
+        WHERE r.event_type = 2
+          AND (p_event_name IS NULL OR r.event_name = p_event_name)
+    )
+    SELECT
+        a.utm_value AS name,
+        COUNT(*)::BIGINT AS visitors,
+        COALESCE(SUM(sp.views), 0)::BIGINT AS pageviews,
+        COUNT(c.session_id)::BIGINT AS conversions,
+        COUNT(*) OVER()::BIGINT AS total_count
+    FROM attributed a
+    LEFT JOIN session_pageviews sp ON sp.session_id = a.session_id
+    LEFT JOIN converted c ON c.session_id = a.session_id
+    GROUP BY a.utm_value
+    ORDER BY visitors DESC, name
+    LIMIT p_limit
+    OFFSET p_offset;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+COMMENT ON FUNCTION get_utm_breakdown IS 'UTM campaign breakdown with visitors, pageviews and conversions per value';
@@ -0,0 +1,7 @@
+-- Rollback Migration 000011: Restore distinct_id length limit
+-- Fails if encrypted values longer than 500 characters are still stored;
+-- decrypt them first with `kaunta encryption rotate --decrypt`.
+
+ALTER TABLE session ALTER COLUMN distinct_id TYPE VARCHAR(500);
+
+COMMENT ON COLUMN website_event.props IS 'Custom event properties (JSON)';
@@ -0,0 +1,8 @@
+-- Migration 000011: Widen Encrypted Columns
+-- Encrypted distinct_id values (AES-GCM + base64 + key id prefix) are longer than
+-- the plaintext they replace, so the 500 character limit no longer fits.
+
+ALTER TABLE session ALTER COLUMN distinct_id TYPE TEXT;
+
+COMMENT ON COLUMN session.distinct_id IS 'Caller-provided user identifier (may be encrypted: kenc:v1:<key-id>:<payload>)';
+COMMENT ON COLUMN website_event.props IS 'Custom event properties (JSON, or a JSON string holding kenc:v1 ciphertext when column encryption is enabled)';
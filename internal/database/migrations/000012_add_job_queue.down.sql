@@ -0,0 +1,3 @@
+-- Rollback Migration 000012: Job Queue
+
+DROP TABLE IF EXISTS jobs;
@@ -0,0 +1,29 @@
+-- Migration 000012: Job Queue
+-- Durable queue for webhooks, report emails, exports and imports. Failed jobs
+-- are retried with exponential backoff and parked as 'dead' once they run out
+-- of attempts, so transient failures are never silently dropped.
+
+CREATE TABLE IF NOT EXISTS jobs (
+    job_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    kind VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}'::jsonb,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'running', 'succeeded', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 5,
+    run_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT,
+    locked_by VARCHAR(255),
+    locked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Claim scan: due pending jobs in run_at order
+CREATE INDEX IF NOT EXISTS idx_jobs_pending_run_at ON jobs(run_at) WHERE status = 'pending';
+-- Stale claim recovery and listings
+CREATE INDEX IF NOT EXISTS idx_jobs_status_kind ON jobs(status, kind);
+
+COMMENT ON TABLE jobs IS 'Background job queue (claimed with FOR UPDATE SKIP LOCKED)';
+COMMENT ON COLUMN jobs.status IS 'pending -> running -> succeeded, or back to pending with backoff; dead after max_attempts';
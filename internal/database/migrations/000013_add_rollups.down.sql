@@ -0,0 +1,7 @@
+-- Rollback Migration 000013: Rollups
+
+DROP FUNCTION IF EXISTS refresh_rollup_day(DATE);
+DROP TABLE IF EXISTS rollup_state;
+DROP TABLE IF EXISTS rollup_daily_dimension;
+DROP TABLE IF EXISTS rollup_daily;
+DROP TABLE IF EXISTS rollup_hourly;
@@ -0,0 +1,125 @@
+-- Migration 000013: Rollups
+-- Pre-aggregated hourly and daily stats so long ranges (30, 90, 365 days) are
+-- served without scanning website_event. refresh_rollup_day() recomputes one
+-- UTC day; the `rollups` worker task keeps today current and
+-- `kaunta rollup backfill` fills in history.
+
+CREATE TABLE IF NOT EXISTS rollup_hourly (
+    website_id UUID NOT NULL REFERENCES website(website_id) ON DELETE CASCADE,
+    bucket TIMESTAMPTZ NOT NULL,
+    pageviews BIGINT NOT NULL DEFAULT 0,
+    visitors BIGINT NOT NULL DEFAULT 0,
+    events BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (website_id, bucket)
+);
+
+-- Daily totals. visitors is the number of distinct sessions that day;
+-- engagement_seconds sums each session's first-to-last pageview span.
+CREATE TABLE IF NOT EXISTS rollup_daily (
+    website_id UUID NOT NULL REFERENCES website(website_id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    pageviews BIGINT NOT NULL DEFAULT 0,
+    visitors BIGINT NOT NULL DEFAULT 0,
+    events BIGINT NOT NULL DEFAULT 0,
+    bounces BIGINT NOT NULL DEFAULT 0,
+    engagement_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+    PRIMARY KEY (website_id, day)
+);
+
+-- Daily pageviews per dimension value (page, referrer, country, region, city,
+-- browser, os, device). Missing values are stored as ''.
+CREATE TABLE IF NOT EXISTS rollup_daily_dimension (
+    website_id UUID NOT NULL REFERENCES website(website_id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    dimension VARCHAR(20) NOT NULL,
+    value VARCHAR(500) NOT NULL,
+    pageviews BIGINT NOT NULL DEFAULT 0,
+    visitors BIGINT NOT NULL DEFAULT 0,
+    engagement_time BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (website_id, dimension, day, value)
+);
+
+CREATE INDEX IF NOT EXISTS idx_rollup_daily_dimension_day ON rollup_daily_dimension (day);
+
+-- Single-row bookkeeping: rollups are complete for every day since
+-- covered_since, and were last refreshed at refreshed_at
+CREATE TABLE IF NOT EXISTS rollup_state (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    covered_since DATE,
+    refreshed_at TIMESTAMPTZ
+);
+
+INSERT INTO rollup_state (id) VALUES (TRUE) ON CONFLICT DO NOTHING;
+
+CREATE OR REPLACE FUNCTION refresh_rollup_day(p_day DATE)
+RETURNS VOID AS $$
+DECLARE
+    v_start TIMESTAMPTZ := p_day::TIMESTAMP AT TIME ZONE 'UTC';
+    v_end TIMESTAMPTZ := (p_day + 1)::TIMESTAMP AT TIME ZONE 'UTC';
+BEGIN
+    DELETE FROM rollup_hourly WHERE bucket >= v_start AND bucket < v_end;
+    DELETE FROM rollup_daily WHERE day = p_day;
+    DELETE FROM rollup_daily_dimension WHERE day = p_day;
+
+    INSERT INTO rollup_hourly (website_id, bucket, pageviews, visitors, events)
+    SELECT
+        e.website_id,
+        DATE_TRUNC('hour', e.created_at),
+        COUNT(*) FILTER (WHERE e.event_type = 1),
+        COUNT(DISTINCT e.session_id) FILTER (WHERE e.event_type = 1),
+        COUNT(*) FILTER (WHERE e.event_type = 2)
+    FROM website_event e
+    WHERE e.created_at >= v_start AND e.created_at < v_end
+    GROUP BY e.website_id, DATE_TRUNC('hour', e.created_at);
+
+    INSERT INTO rollup_daily (website_id, day, pageviews, visitors, events, bounces, engagement_seconds)
+    SELECT
+        ss.website_id,
+        p_day,
+        SUM(ss.pageviews),
+        COUNT(*) FILTER (WHERE ss.pageviews > 0),
+        SUM(ss.events),
+        COUNT(*) FILTER (WHERE ss.pageviews = 1),
+        COALESCE(SUM(ss.duration), 0)
+    FROM (
+        SELECT
+            e.website_id,
+            e.session_id,
+            COUNT(*) FILTER (WHERE e.event_type = 1) AS pageviews,
+            COUNT(*) FILTER (WHERE e.event_type = 2) AS events,
+            EXTRACT(EPOCH FROM (
+                MAX(e.created_at) FILTER (WHERE e.event_type = 1) -
+                MIN(e.created_at) FILTER (WHERE e.event_type = 1)
+            )) AS duration
+        FROM website_event e
+        WHERE e.created_at >= v_start AND e.created_at < v_end
+        GROUP BY e.website_id, e.session_id
+    ) ss
+    GROUP BY ss.website_id;
+
+    INSERT INTO rollup_daily_dimension (website_id, day, dimension, value, pageviews, visitors, engagement_time)
+    SELECT
+        e.website_id,
+        p_day,
+        d.dimension,
+        d.value,
+        COUNT(*),
+        COUNT(DISTINCT e.session_id),
+        COALESCE(SUM(e.engagement_time), 0)
+    FROM website_event e
+    JOIN session s ON e.session_id = s.session_id
+    CROSS JOIN LATERAL (VALUES
+        ('page', COALESCE(e.url_path, '')),
+        ('referrer', COALESCE(e.referrer_domain, '')),
+        ('country', COALESCE(s.country::VARCHAR, '')),
+        ('region', COALESCE(s.region, '')),
+        ('city', COALESCE(s.city, '')),
+        ('browser', COALESCE(s.browser, '')),
+        ('os', COALESCE(s.os, '')),
+        ('device', COALESCE(s.device, ''))
+    ) AS d(dimension, value)
+    WHERE e.created_at >= v_start AND e.created_at < v_end
+      AND e.event_type = 1
+    GROUP BY e.website_id, d.dimension, d.value;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,4 @@
+-- Rollback Migration 000014: Data Retention
+
+ALTER TABLE website DROP CONSTRAINT IF EXISTS website_retention_days_check;
+ALTER TABLE website DROP COLUMN IF EXISTS retention_days;
@@ -0,0 +1,8 @@
+-- Migration 000014: Data Retention
+-- Per-website override of the global retention_days setting. NULL inherits
+-- the global value, 0 keeps the website's events forever.
+
+ALTER TABLE website ADD COLUMN IF NOT EXISTS retention_days INTEGER;
+
+ALTER TABLE website DROP CONSTRAINT IF EXISTS website_retention_days_check;
+ALTER TABLE website ADD CONSTRAINT website_retention_days_check CHECK (retention_days >= 0);
@@ -0,0 +1,3 @@
+-- Rollback Migration 000015: Event Sequence Counters
+
+DROP TABLE IF EXISTS event_sequence;
@@ -0,0 +1,16 @@
+-- Migration 000015: Event Sequence Counters
+-- The tracker numbers the events of each page instance (page_id, seq). One
+-- row per page instance records how many arrived and the highest number seen,
+-- so gaps estimate events lost to the network, blockers or ingest drops.
+
+CREATE TABLE IF NOT EXISTS event_sequence (
+    website_id UUID NOT NULL REFERENCES website(website_id) ON DELETE CASCADE,
+    page_id UUID NOT NULL,
+    day DATE NOT NULL,
+    received INTEGER NOT NULL DEFAULT 0,
+    max_seq INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (website_id, page_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_sequence_day ON event_sequence (day);
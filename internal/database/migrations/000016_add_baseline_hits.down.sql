@@ -0,0 +1,3 @@
+-- Rollback Migration 000016: Baseline Pixel Hits
+
+DROP TABLE IF EXISTS baseline_hit;
@@ -0,0 +1,13 @@
+-- Migration 000016: Baseline Pixel Hits
+-- Visitors seen by the blocker-resistant baseline pixel, one row per visitor
+-- and day. Comparing them with the JavaScript tracker's events estimates the
+-- share of traffic that blocks the tracker.
+
+CREATE TABLE IF NOT EXISTS baseline_hit (
+    website_id UUID NOT NULL REFERENCES website(website_id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    session_id UUID NOT NULL,
+    PRIMARY KEY (website_id, day, session_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_baseline_hit_day ON baseline_hit (day);
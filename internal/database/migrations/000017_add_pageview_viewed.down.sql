@@ -0,0 +1,3 @@
+-- Rollback Migration 000017: Pageview Confirmation
+
+ALTER TABLE website_event DROP COLUMN IF EXISTS viewed;
@@ -0,0 +1,6 @@
+-- Migration 000017: Pageview Confirmation
+-- Trackers that confirm views send pageviews as pending (viewed = FALSE) and
+-- confirm them once the page has been visible for a while or scrolled.
+-- NULL marks pageviews from trackers that don't confirm; they count as viewed.
+
+ALTER TABLE website_event ADD COLUMN IF NOT EXISTS viewed BOOLEAN;
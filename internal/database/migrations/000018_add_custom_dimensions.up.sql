@@ -0,0 +1,331 @@
+-- Migration 000018: Custom Dimensions
+-- Websites register up to 5 named dimensions (author, content_type, plan, ...)
+-- that the tracker sets per pageview. Values are stored in website_event.dimensions
+-- and the dashboard functions take a p_dimensions filter (matched with @>, so
+-- the GIN index serves it); get_breakdown() groups by any registered name.
+
+CREATE TABLE IF NOT EXISTS custom_dimension (
+    website_id UUID NOT NULL REFERENCES website(website_id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (website_id, name)
+);
+
+ALTER TABLE website_event ADD COLUMN IF NOT EXISTS dimensions JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_event_dimensions_gin ON website_event USING gin (dimensions jsonb_path_ops) WHERE dimensions IS NOT NULL;
+
+-- ============================================================================
+-- Dashboard functions with a custom dimension filter
+-- ============================================================================
+
+DROP FUNCTION IF EXISTS get_dashboard_stats(UUID, INTEGER, VARCHAR, VARCHAR, VARCHAR, VARCHAR);
+
+CREATE FUNCTION get_dashboard_stats(
+    p_website_id UUID,
+    p_days INTEGER DEFAULT 1,
+    p_country VARCHAR DEFAULT NULL,
+    p_browser VARCHAR DEFAULT NULL,
+    p_device VARCHAR DEFAULT NULL,
+    p_page_path VARCHAR DEFAULT NULL,
+    p_dimensions JSONB DEFAULT NULL
+)
+RETURNS TABLE (
+    current_visitors BIGINT,
+    today_pageviews BIGINT,
+    today_visitors BIGINT,
+    bounce_rate NUMERIC(5,2)
+) AS $$
+DECLARE
+    v_current_visitors BIGINT;
+    v_today_pageviews BIGINT;
+    v_today_visitors BIGINT;
+    v_bounce_rate NUMERIC(5,2);
+    v_bounces BIGINT;
+BEGIN
+    -- 1. Current visitors (sessions in last 5 minutes)
+    SELECT COUNT(DISTINCT e.session_id) INTO v_current_visitors
+    FROM website_event e
+    JOIN session s ON e.session_id = s.session_id
+    WHERE e.website_id = p_website_id
+      AND e.created_at >= NOW() - INTERVAL '5 minutes'
+      AND e.event_type = 1
+      AND (p_country IS NULL OR s.country = p_country)
+      AND (p_browser IS NULL OR s.browser = p_browser)
+      AND (p_device IS NULL OR s.device = p_device)
+      AND (p_dimensions IS NULL OR e.dimensions @> p_dimensions)
+      AND (p_page_path IS NULL OR e.url_path = p_page_path);
+
+    -- 2. Today's pageviews
+    SELECT COUNT(*) INTO v_today_pageviews
+    FROM website_event e
+    JOIN session s ON e.session_id = s.session_id
+    WHERE e.website_id = p_website_id
+      AND e.created_at >= CURRENT_DATE
+      AND e.event_type = 1
+      AND (p_country IS NULL OR s.country = p_country)
+      AND (p_browser IS NULL OR s.browser = p_browser)
+      AND (p_device IS NULL OR s.device = p_device)
+      AND (p_dimensions IS NULL OR e.dimensions @> p_dimensions)
+      AND (p_page_path IS NULL OR e.url_path = p_page_path);
+
+    -- 3. Today's unique visitors
+    SELECT COUNT(DISTINCT e.session_id) INTO v_today_visitors
+    FROM website_event e
+    JOIN session s ON e.session_id = s.session_id
+    WHERE e.website_id = p_website_id
+      AND e.created_at >= CURRENT_DATE
+      AND e.event_type = 1
+      AND (p_country IS NULL OR s.country = p_country)
+      AND (p_browser IS NULL OR s.browser = p_browser)
+      AND (p_device IS NULL OR s.device = p_device)
+      AND (p_dimensions IS NULL OR e.dimensions @> p_dimensions)
+      AND (p_page_path IS NULL OR e.url_path = p_page_path);
+
+    -- 4. Bounce rate (sessions with only 1 pageview)
+    v_bounce_rate := 0;
+    IF v_today_visitors > 0 THEN
+        SELECT COUNT(*) INTO v_bounces
+        FROM (
+            SELECT e.session_id
+            FROM website_event e
+            JOIN session s ON e.session_id = s.session_id
+            WHERE e.website_id = p_website_id
+              AND e.created_at >= CURRENT_DATE
+              AND e.event_type = 1
+              AND (p_country IS NULL OR s.country = p_country)
+              AND (p_browser IS NULL OR s.browser = p_browser)
+              AND (p_device IS NULL OR s.device = p_device)
+              AND (p_dimensions IS NULL OR e.dimensions @> p_dimensions)
+              AND (p_page_path IS NULL OR e.url_path = p_page_path)
+            GROUP BY e.session_id
+            HAVING COUNT(*) = 1
+        ) bounced_sessions;
+
+        v_bounce_rate := (v_bounces::NUMERIC / v_today_visitors::NUMERIC) * 100;
+    END IF;
+
+    -- Return all stats as a single row
+    RETURN QUERY SELECT v_current_visitors, v_today_pageviews, v_today_visitors, v_bounce_rate;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+DROP FUNCTION IF EXISTS get_top_pages(UUID, INTEGER, INTEGER, INTEGER, VARCHAR, VARCHAR, VARCHAR);
+
+CREATE FUNCTION get_top_pages(
+    p_website_id UUID,
+    p_days INTEGER DEFAULT 1,
+    p_limit INTEGER DEFAULT 10,
+    p_offset INTEGER DEFAULT 0,
+    p_country VARCHAR DEFAULT NULL,
+    p_browser VARCHAR DEFAULT NULL,
+    p_device VARCHAR DEFAULT NULL,
+    p_dimensions JSONB DEFAULT NULL
+)
+RETURNS TABLE (
+    path VARCHAR,
+    views BIGINT,
+    unique_visitors BIGINT,
+    avg_engagement_time NUMERIC,
+    total_count BIGINT
+) AS $$
+BEGIN
+    RETURN QUERY
+    WITH filtered_events AS (
+        SELECT e.url_path, e.session_id, e.engagement_time
+        FROM website_event e
+        JOIN session s ON e.session_id = s.session_id
+        WHERE e.website_id = p_website_id
+          AND e.created_at >= CURRENT_DATE - (p_days || ' days')::INTERVAL
+          AND e.event_type = 1
+          AND e.url_path IS NOT NULL
+          AND (p_country IS NULL OR s.country = p_country)
+          AND (p_browser IS NULL OR s.browser = p_browser)
+          AND (p_device IS NULL OR s.device = p_device)
+          AND (p_dimensions IS NULL OR e.dimensions @> p_dimensions)
+    ),
+    page_stats AS (
+        SELECT
+            fe.url_path,
+            COUNT(*)::BIGINT as view_count,
+            COUNT(DISTINCT fe.session_id)::BIGINT as unique_visitor_count,
+            ROUND(AVG(COALESCE(fe.engagement_time, 0)), 0) as avg_time
+        FROM filtered_events fe
+        GROUP BY fe.url_path
+    ),
+    total_count_cte AS (
+        SELECT COUNT(*)::BIGINT as total FROM page_stats
+    )
+    SELECT
+        ps.url_path::VARCHAR,
+        ps.view_count,
+        ps.unique_visitor_count,
+        ps.avg_time,
+        tc.total as total_count
+    FROM page_stats ps
+    CROSS JOIN total_count_cte tc
+    ORDER BY ps.view_count DESC
+    LIMIT p_limit
+    OFFSET p_offset;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+DROP FUNCTION IF EXISTS get_timeseries(UUID, INTEGER, VARCHAR, VARCHAR, VARCHAR, VARCHAR);
+
+CREATE FUNCTION get_timeseries(
+    p_website_id UUID,
+    p_days INTEGER DEFAULT 7,
+    p_country VARCHAR DEFAULT NULL,
+    p_browser VARCHAR DEFAULT NULL,
+    p_device VARCHAR DEFAULT NULL,
+    p_page_path VARCHAR DEFAULT NULL,
+    p_dimensions JSONB DEFAULT NULL
+)
+RETURNS TABLE (
+    hour TIMESTAMPTZ,
+    views BIGINT
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT
+        DATE_TRUNC('hour', e.created_at)::TIMESTAMPTZ as hour,
+        COUNT(*)::BIGINT as views
+    FROM website_event e
+    JOIN session s ON e.session_id = s.session_id
+    WHERE e.website_id = p_website_id
+      AND e.created_at >= NOW() - (p_days || ' days')::INTERVAL
+      AND e.event_type = 1
+      AND (p_country IS NULL OR s.country = p_country)
+      AND (p_browser IS NULL OR s.browser = p_browser)
+      AND (p_device IS NULL OR s.device = p_device)
+      AND (p_dimensions IS NULL OR e.dimensions @> p_dimensions)
+      AND (p_page_path IS NULL OR e.url_path = p_page_path)
+    GROUP BY hour
+    ORDER BY hour ASC;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+DROP FUNCTION IF EXISTS get_map_data(UUID, INTEGER, VARCHAR, VARCHAR, VARCHAR, VARCHAR);
+
+CREATE FUNCTION get_map_data(
+    p_website_id UUID,
+    p_days INTEGER DEFAULT 7,
+    p_country VARCHAR DEFAULT NULL,
+    p_browser VARCHAR DEFAULT NULL,
+    p_device VARCHAR DEFAULT NULL,
+    p_page_path VARCHAR DEFAULT NULL,
+    p_dimensions JSONB DEFAULT NULL
+)
+RETURNS TABLE (
+    country VARCHAR,
+    visitors BIGINT,
+    percentage NUMERIC(5,2)
+) AS $$
+BEGIN
+    RETURN QUERY
+    WITH total_visitors AS (
+        SELECT COUNT(DISTINCT e.session_id)::BIGINT as total
+        FROM website_event e
+        JOIN session s ON e.session_id = s.session_id
+        WHERE e.website_id = p_website_id
+          AND e.created_at >= NOW() - (p_days || ' days')::INTERVAL
+          AND e.event_type = 1
+          AND (p_country IS NULL OR s.country = p_country)
+          AND (p_browser IS NULL OR s.browser = p_browser)
+          AND (p_device IS NULL OR s.device = p_device)
+          AND (p_dimensions IS NULL OR e.dimensions @> p_dimensions)
+          AND (p_page_path IS NULL OR e.url_path = p_page_path)
+    ),
+    country_breakdown AS (
+        SELECT
+            COALESCE(s.country, 'Unknown')::VARCHAR as country_code,
+            COUNT(DISTINCT e.session_id)::BIGINT as visitor_count
+        FROM website_event e
+        JOIN session s ON e.session_id = s.session_id
+        WHERE e.website_id = p_website_id
+          AND e.created_at >= NOW() - (p_days || ' days')::INTERVAL
+          AND e.event_type = 1
+          AND (p_country IS NULL OR s.country = p_country)
+          AND (p_browser IS NULL OR s.browser = p_browser)
+          AND (p_device IS NULL OR s.device = p_device)
+          AND (p_dimensions IS NULL OR e.dimensions @> p_dimensions)
+          AND (p_page_path IS NULL OR e.url_path = p_page_path)
+        GROUP BY s.country
+    )
+    SELECT
+        cb.country_code,
+        cb.visitor_count,
+        CASE
+            WHEN tv.total > 0 THEN ROUND((cb.visitor_count::NUMERIC / tv.total::NUMERIC * 100), 2)
+            ELSE 0
+        END as pct
+    FROM country_breakdown cb
+    CROSS JOIN total_visitors tv
+    ORDER BY cb.visitor_count DESC;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+DROP FUNCTION IF EXISTS get_breakdown(UUID, VARCHAR, INTEGER, INTEGER, INTEGER, VARCHAR, VARCHAR, VARCHAR, VARCHAR);
+
+-- One query for every dimension: a breakdown ignores the filter on its own
+-- dimension, and any other name must be a registered custom dimension
+CREATE FUNCTION get_breakdown(
+    p_website_id UUID,
+    p_dimension VARCHAR,
+    p_days INTEGER DEFAULT 1,
+    p_limit INTEGER DEFAULT 10,
+    p_offset INTEGER DEFAULT 0,
+    p_country VARCHAR DEFAULT NULL,
+    p_browser VARCHAR DEFAULT NULL,
+    p_device VARCHAR DEFAULT NULL,
+    p_page_path VARCHAR DEFAULT NULL,
+    p_dimensions JSONB DEFAULT NULL
+)
+RETURNS TABLE (name VARCHAR, count BIGINT, total_count BIGINT) AS $$
+BEGIN
+    IF p_dimension NOT IN ('country', 'browser', 'device', 'referrer', 'city', 'region', 'page')
+       AND NOT EXISTS (
+           SELECT 1 FROM custom_dimension cd
+           WHERE cd.website_id = p_website_id AND cd.name = p_dimension
+       ) THEN
+        RAISE EXCEPTION 'Invalid dimension: %. Must be country, browser, device, referrer, city, region, page or a custom dimension', p_dimension;
+    END IF;
+
+    RETURN QUERY
+    WITH breakdown_data AS (
+        SELECT
+            (CASE p_dimension
+                WHEN 'country' THEN COALESCE(s.country, 'Unknown')
+                WHEN 'browser' THEN COALESCE(s.browser, 'Unknown')
+                WHEN 'device' THEN COALESCE(s.device, 'Unknown')
+                WHEN 'referrer' THEN COALESCE(e.referrer_domain, 'Direct / None')
+                WHEN 'city' THEN COALESCE(s.city, 'Unknown')
+                WHEN 'region' THEN COALESCE(s.region, 'Unknown')
+                WHEN 'page' THEN e.url_path
+                ELSE COALESCE(e.dimensions->>p_dimension, 'Unknown')
+            END)::VARCHAR as dim_name,
+            COUNT(*)::BIGINT as dim_count
+        FROM website_event e
+        JOIN session s ON e.session_id = s.session_id
+        WHERE e.website_id = p_website_id
+          AND e.created_at >= CURRENT_DATE - (p_days || ' days')::INTERVAL
+          AND e.event_type = 1
+          AND (p_dimension <> 'page' OR e.url_path IS NOT NULL)
+          AND (p_country IS NULL OR p_dimension = 'country' OR s.country = p_country)
+          AND (p_browser IS NULL OR p_dimension = 'browser' OR s.browser = p_browser)
+          AND (p_device IS NULL OR p_dimension = 'device' OR s.device = p_device)
+          AND (p_page_path IS NULL OR p_dimension = 'page' OR e.url_path = p_page_path)
+          AND (p_dimensions IS NULL OR (p_dimensions - p_dimension) = '{}'::jsonb OR e.dimensions @> (p_dimensions - p_dimension))
+        GROUP BY 1
+    ),
+    total_count_cte AS (
+        SELECT COUNT(*)::BIGINT as total FROM breakdown_data
+    )
+    SELECT bd.dim_name, bd.dim_count, tc.total
+    FROM breakdown_data bd
+    CROSS JOIN total_count_cte tc
+    ORDER BY bd.dim_count DESC
+    LIMIT p_limit
+    OFFSET p_offset;
+END;
+$$ LANGUAGE plpgsql STABLE;
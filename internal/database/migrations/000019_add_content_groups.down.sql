@@ -0,0 +1,4 @@
+-- Rollback Migration 000019: Content Groups
+
+DROP INDEX IF EXISTS idx_content_group_rule_position;
+DROP TABLE IF EXISTS content_group_rule;
@@ -0,0 +1,15 @@
+-- Migration 000019: Content Groups
+-- Per-website rules mapping URL path patterns to a content group (blog, docs,
+-- marketing, ...). Rules are evaluated at query time in position order, first
+-- match wins, so editing them regroups past traffic as well.
+
+CREATE TABLE IF NOT EXISTS content_group_rule (
+    website_id UUID NOT NULL REFERENCES website(website_id) ON DELETE CASCADE,
+    pattern VARCHAR(500) NOT NULL,
+    group_name VARCHAR(100) NOT NULL,
+    position INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (website_id, pattern)
+);
+
+CREATE INDEX IF NOT EXISTS idx_content_group_rule_position ON content_group_rule (website_id, position);
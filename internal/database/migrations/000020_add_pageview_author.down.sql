@@ -0,0 +1,5 @@
+-- Rollback Migration 000020: Author Analytics
+
+DROP INDEX IF EXISTS idx_event_author;
+ALTER TABLE website_event DROP COLUMN IF EXISTS read;
+ALTER TABLE website_event DROP COLUMN IF EXISTS author;
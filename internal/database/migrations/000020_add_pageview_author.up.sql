@@ -0,0 +1,10 @@
+-- Migration 000020: Author Analytics
+-- Pageviews carry the article's author (data-author or <meta name="author">)
+-- and are marked read once the visitor scrolls through most of the page.
+-- read is NULL until then; the authors report divides read pageviews by all
+-- pageviews of the author.
+
+ALTER TABLE website_event ADD COLUMN IF NOT EXISTS author VARCHAR(100);
+ALTER TABLE website_event ADD COLUMN IF NOT EXISTS read BOOLEAN;
+
+CREATE INDEX IF NOT EXISTS idx_event_author ON website_event (website_id, author, created_at) WHERE author IS NOT NULL;
@@ -0,0 +1,28 @@
+-- Rollback Migration 000022: API Tokens
+
+DROP FUNCTION IF EXISTS validate_session(VARCHAR);
+
+CREATE FUNCTION validate_session(p_token_hash VARCHAR)
+RETURNS TABLE (user_id UUID, username VARCHAR, session_id UUID) AS $$
+BEGIN
+    -- Update last_used_at and return user info
+    UPDATE user_sessions
+    SET last_used_at = NOW()
+    WHERE token_hash = p_token_hash
+      AND expires_at > NOW()
+    RETURNING user_sessions.user_id, user_sessions.session_id
+    INTO validate_session.user_id, validate_session.session_id;
+
+    IF FOUND THEN
+        SELECT u.username INTO validate_session.username
+        FROM users u
+        WHERE u.user_id = validate_session.user_id;
+
+        RETURN NEXT;
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE user_sessions DROP COLUMN IF EXISTS daily_quota;
+ALTER TABLE user_sessions DROP COLUMN IF EXISTS rate_limit;
+ALTER TABLE user_sessions DROP COLUMN IF EXISTS name;
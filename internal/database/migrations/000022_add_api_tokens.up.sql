@@ -0,0 +1,37 @@
+-- Migration 000022: API Tokens
+-- API tokens are long-lived user sessions created from the CLI and sent as
+-- Authorization: Bearer by integrations. They carry a name and can override
+-- the server-wide API rate limit (requests per minute) and daily quota
+-- (requests per UTC day); NULL uses the server setting, 0 means unlimited.
+
+ALTER TABLE user_sessions ADD COLUMN IF NOT EXISTS name VARCHAR(100);
+ALTER TABLE user_sessions ADD COLUMN IF NOT EXISTS rate_limit INTEGER CHECK (rate_limit >= 0);
+ALTER TABLE user_sessions ADD COLUMN IF NOT EXISTS daily_quota INTEGER CHECK (daily_quota >= 0);
+
+COMMENT ON COLUMN user_sessions.name IS 'API token name; NULL for dashboard login sessions';
+COMMENT ON COLUMN user_sessions.rate_limit IS 'Requests per minute; NULL uses the server setting, 0 is unlimited';
+COMMENT ON COLUMN user_sessions.daily_quota IS 'Requests per UTC day; NULL uses the server setting, 0 is unlimited';
+
+-- validate_session() also returns the token's limits
+DROP FUNCTION IF EXISTS validate_session(VARCHAR);
+
+CREATE FUNCTION validate_session(p_token_hash VARCHAR)
+RETURNS TABLE (user_id UUID, username VARCHAR, session_id UUID, rate_limit INTEGER, daily_quota INTEGER) AS $$
+BEGIN
+    -- Update last_used_at and return user info
+    UPDATE user_sessions
+    SET last_used_at = NOW()
+    WHERE token_hash = p_token_hash
+      AND expires_at > NOW()
+    RETURNING user_sessions.user_id, user_sessions.session_id, user_sessions.rate_limit, user_sessions.daily_quota
+    INTO validate_session.user_id, validate_session.session_id, validate_session.rate_limit, validate_session.daily_quota;
+
+    IF FOUND THEN
+        SELECT u.username INTO validate_session.username
+        FROM users u
+        WHERE u.user_id = validate_session.user_id;
+
+        RETURN NEXT;
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
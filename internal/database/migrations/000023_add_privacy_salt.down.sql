@@ -0,0 +1,3 @@
+-- Rollback Migration 000023: Privacy Salt
+
+DROP TABLE IF EXISTS privacy_salt;
@@ -0,0 +1,13 @@
+-- Migration 000023: Privacy Salt
+-- With ip_mode = "hash", visitors are identified by a hash of IP and user
+-- agent with the salt of the current UTC day. Only today's salt is kept:
+-- older ones are deleted when a new day's salt is created, so past hashes
+-- can't be recomputed.
+
+CREATE TABLE IF NOT EXISTS privacy_salt (
+    day DATE PRIMARY KEY,
+    salt BYTEA NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE privacy_salt IS 'Daily salt for hashed visitor IDs (ip_mode = hash); only the current day is kept';
@@ -0,0 +1,4 @@
+-- Rollback Migration 000024: Respect Do-Not-Track
+
+ALTER TABLE website DROP CONSTRAINT IF EXISTS check_respect_dnt;
+ALTER TABLE website DROP COLUMN IF EXISTS respect_dnt;
@@ -0,0 +1,13 @@
+-- Migration 000024: Respect Do-Not-Track
+-- Websites choose what happens to requests sent with DNT: 1 or Sec-GPC: 1
+-- (Global Privacy Control): off (tracked as usual, the default), drop (not
+-- recorded) or anonymize (recorded with a daily-salted visitor hash and a
+-- truncated IP, as with ip_mode = "hash").
+
+ALTER TABLE website ADD COLUMN IF NOT EXISTS respect_dnt VARCHAR(20) NOT NULL DEFAULT 'off';
+
+ALTER TABLE website DROP CONSTRAINT IF EXISTS check_respect_dnt;
+ALTER TABLE website ADD CONSTRAINT check_respect_dnt
+  CHECK (respect_dnt IN ('off', 'drop', 'anonymize'));
+
+COMMENT ON COLUMN website.respect_dnt IS 'Handling of DNT/GPC requests: off, drop or anonymize';
@@ -0,0 +1,3 @@
+-- Rollback Migration 000025: Website Exclusions
+
+DROP TABLE IF EXISTS website_exclusion;
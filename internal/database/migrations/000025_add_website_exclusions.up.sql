@@ -0,0 +1,15 @@
+-- Migration 000025: Website Exclusions
+-- Rules that keep internal traffic out of a website's stats: requests from
+-- an IP range (ip, stored as CIDR), for a hostname (hostname, exact or
+-- *.example.com) or for a URL path pattern (path, * matches anything) are
+-- dropped by the tracking endpoint before anything is recorded.
+
+CREATE TABLE IF NOT EXISTS website_exclusion (
+    website_id UUID NOT NULL REFERENCES website(website_id) ON DELETE CASCADE,
+    rule_type VARCHAR(20) NOT NULL CHECK (rule_type IN ('ip', 'hostname', 'path')),
+    value VARCHAR(500) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (website_id, rule_type, value)
+);
+
+COMMENT ON TABLE website_exclusion IS 'Per-website rules dropping internal traffic (ip, hostname, path) at ingestion';
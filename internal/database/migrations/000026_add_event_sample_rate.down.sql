@@ -0,0 +1,3 @@
+-- Rollback Migration 000026: Event Sample Rate
+
+ALTER TABLE website_event DROP COLUMN IF EXISTS sample_rate;
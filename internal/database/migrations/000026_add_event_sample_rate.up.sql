@@ -0,0 +1,7 @@
+-- Migration 000026: Event Sample Rate
+-- Under load, ingestion keeps one visitor in N instead of dropping events at
+-- random (see internal/ingest). Each stored event records its N, so
+-- SUM(sample_rate) estimates the true count where COUNT(*) undercounts.
+
+ALTER TABLE website_event ADD COLUMN IF NOT EXISTS sample_rate SMALLINT NOT NULL DEFAULT 1
+    CHECK (sample_rate >= 1);
@@ -0,0 +1,3 @@
+-- Rollback Migration 000031: Saved Reports
+
+DROP TABLE IF EXISTS saved_report;
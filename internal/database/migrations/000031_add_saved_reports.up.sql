@@ -0,0 +1,15 @@
+-- Migration 000031: Saved Reports
+-- Named report definitions (metrics, breakdowns, filters, period and output
+-- format) run on demand with `kaunta report run <name>` or
+-- GET /api/reports/<name>. Names are global: commands address reports by
+-- name alone.
+
+CREATE TABLE IF NOT EXISTS saved_report (
+    name VARCHAR(50) PRIMARY KEY,
+    website_id UUID NOT NULL REFERENCES website(website_id) ON DELETE CASCADE,
+    spec JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_saved_report_website ON saved_report (website_id);
@@ -0,0 +1,3 @@
+-- Rollback Migration 000032: Alert Rules
+
+DROP TABLE IF EXISTS alert_rule;
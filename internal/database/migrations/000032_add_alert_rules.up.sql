@@ -0,0 +1,28 @@
+-- Migration 000032: Alert Rules
+-- Per-website traffic alerts evaluated by the "alerts" worker task. A rule
+-- compares a metric over its window with a threshold (below/above) or with
+-- the window before (drop/rise, in percent) and notifies its channel when it
+-- starts firing and when it resolves.
+
+CREATE TABLE IF NOT EXISTS alert_rule (
+    rule_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    website_id UUID NOT NULL REFERENCES website(website_id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    metric VARCHAR(60) NOT NULL,
+    condition VARCHAR(10) NOT NULL,
+    threshold DOUBLE PRECISION NOT NULL,
+    window_minutes INTEGER NOT NULL,
+    channel VARCHAR(10) NOT NULL,
+    target TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    firing BOOLEAN NOT NULL DEFAULT FALSE,
+    last_value DOUBLE PRECISION,
+    last_checked_at TIMESTAMPTZ,
+    last_fired_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (website_id, name),
+    CONSTRAINT valid_alert_condition CHECK (condition IN ('drop', 'rise', 'below', 'above')),
+    CONSTRAINT valid_alert_channel CHECK (channel IN ('webhook', 'slack', 'email')),
+    CONSTRAINT valid_alert_window CHECK (window_minutes > 0)
+);
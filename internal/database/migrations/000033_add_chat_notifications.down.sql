@@ -0,0 +1,11 @@
+-- Rollback Migration 000033: Chat Notifications
+
+DELETE FROM alert_rule WHERE channel = 'discord' OR (channel = 'slack' AND target = '');
+ALTER TABLE alert_rule DROP CONSTRAINT IF EXISTS valid_alert_channel;
+ALTER TABLE alert_rule ADD CONSTRAINT valid_alert_channel
+  CHECK (channel IN ('webhook', 'slack', 'email'));
+
+ALTER TABLE website DROP COLUMN IF EXISTS summary_sent_on;
+ALTER TABLE website DROP COLUMN IF EXISTS daily_summary;
+ALTER TABLE website DROP COLUMN IF EXISTS discord_webhook_url;
+ALTER TABLE website DROP COLUMN IF EXISTS slack_webhook_url;
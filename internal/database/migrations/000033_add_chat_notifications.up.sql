@@ -0,0 +1,20 @@
+-- Migration 000033: Chat Notifications
+-- Websites can post to their own Slack and Discord incoming webhooks instead
+-- of the ones in kaunta.toml, and opt in or out of the daily summary (NULL
+-- follows daily_summary in kaunta.toml). summary_sent_on is the last day a
+-- summary was posted for, so each day is posted once.
+
+ALTER TABLE website ADD COLUMN IF NOT EXISTS slack_webhook_url TEXT;
+ALTER TABLE website ADD COLUMN IF NOT EXISTS discord_webhook_url TEXT;
+ALTER TABLE website ADD COLUMN IF NOT EXISTS daily_summary BOOLEAN;
+ALTER TABLE website ADD COLUMN IF NOT EXISTS summary_sent_on DATE;
+
+COMMENT ON COLUMN website.slack_webhook_url IS 'Slack incoming webhook, overriding slack_webhook_url in kaunta.toml';
+COMMENT ON COLUMN website.discord_webhook_url IS 'Discord webhook, overriding discord_webhook_url in kaunta.toml';
+COMMENT ON COLUMN website.daily_summary IS 'Post a daily summary; NULL follows daily_summary in kaunta.toml';
+
+-- Alert rules can notify Discord, and Slack and Discord rules without a
+-- target post to the website's webhook
+ALTER TABLE alert_rule DROP CONSTRAINT IF EXISTS valid_alert_channel;
+ALTER TABLE alert_rule ADD CONSTRAINT valid_alert_channel
+  CHECK (channel IN ('webhook', 'slack', 'discord', 'email'));
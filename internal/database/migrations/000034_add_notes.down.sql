@@ -0,0 +1,3 @@
+-- Rollback Migration 000034: Notes
+
+DROP TABLE IF EXISTS note;
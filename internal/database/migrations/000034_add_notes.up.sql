@@ -0,0 +1,16 @@
+-- Migration 000034: Notes
+-- Short notes users attach to a day of a website's traffic ("outage
+-- 14:00-15:30"), returned with the time series so everyone viewing that
+-- range sees them. author keeps the username, so notes outlive their user.
+
+CREATE TABLE IF NOT EXISTS note (
+    note_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    website_id UUID NOT NULL REFERENCES website(website_id) ON DELETE CASCADE,
+    note_date DATE NOT NULL,
+    body VARCHAR(280) NOT NULL,
+    author VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT note_body_not_empty CHECK (body <> '')
+);
+
+CREATE INDEX IF NOT EXISTS idx_note_website_date ON note (website_id, note_date);
@@ -0,0 +1,3 @@
+-- Rollback Migration 000035: Cardinality overflows
+
+DROP TABLE IF EXISTS cardinality_overflow;
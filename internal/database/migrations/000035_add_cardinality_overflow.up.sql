@@ -0,0 +1,13 @@
+-- Migration 000035: Cardinality overflows
+-- Days a website went over the cap of distinct values of a dimension (page,
+-- referrer, UTM parameters, event names); new values of that day were
+-- recorded as "(other)". Reported by `kaunta website check`, kept 30 days.
+
+CREATE TABLE IF NOT EXISTS cardinality_overflow (
+    website_id UUID NOT NULL REFERENCES website(website_id) ON DELETE CASCADE,
+    dimension VARCHAR(50) NOT NULL,
+    day DATE NOT NULL,
+    value_cap INTEGER NOT NULL,
+    first_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (website_id, dimension, day)
+);
@@ -0,0 +1,3 @@
+-- Rollback Migration 000036: Saved Segments
+
+DROP TABLE IF EXISTS segment;
@@ -0,0 +1,13 @@
+-- Migration 000036: Saved Segments
+-- Named sets of dashboard filters (country, browser, device, page, bot and
+-- dim.<name>) applied with `--segment` on the stats commands or ?segment=
+-- on the dashboard API. Names are per website.
+
+CREATE TABLE IF NOT EXISTS segment (
+    website_id UUID NOT NULL REFERENCES website(website_id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    filters JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (website_id, name)
+);
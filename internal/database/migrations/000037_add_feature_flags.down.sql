@@ -0,0 +1,3 @@
+-- Rollback Migration 000037: Feature flags
+
+DROP TABLE IF EXISTS feature_flag;
@@ -0,0 +1,13 @@
+-- Migration 000037: Feature flags
+-- Overrides of the feature flags set in the config, for the whole instance
+-- (website_id NULL) or a website. See internal/features.
+
+CREATE TABLE IF NOT EXISTS feature_flag (
+    name VARCHAR(50) NOT NULL,
+    website_id UUID REFERENCES website(website_id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_feature_flag_website ON feature_flag (name, website_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_feature_flag_instance ON feature_flag (name) WHERE website_id IS NULL;
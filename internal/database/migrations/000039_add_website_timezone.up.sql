@@ -0,0 +1,392 @@
+-- Migration 000039: Website time zones
+-- Days were counted in the database server's time zone (UTC days for the
+-- rollups). A website now has a timezone (an IANA name, UTC by default) and
+-- its "today", its N-day periods, its hourly time series buckets and its
+-- daily rollups start at midnight there. website_day_start() is the start
+-- of a period of p_days for the dashboard functions and the stats queries.
+
+ALTER TABLE website ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+CREATE OR REPLACE FUNCTION website_timezone(p_website_id UUID)
+RETURNS TEXT AS $$
+    SELECT COALESCE((SELECT timezone FROM website WHERE website_id = p_website_id), 'UTC');
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION website_today(p_website_id UUID)
+RETURNS DATE AS $$
+    SELECT (NOW() AT TIME ZONE website_timezone(p_website_id))::DATE;
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION website_day_start(p_website_id UUID, p_days INTEGER)
+RETURNS TIMESTAMPTZ AS $$
+    SELECT (website_today(p_website_id) - p_days)::TIMESTAMP AT TIME ZONE website_timezone(p_website_id);
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION get_dashboard_stats(
+    p_website_id UUID,
+    p_days INTEGER DEFAULT 1,
+    p_country VARCHAR DEFAULT NULL,
+    p_browser VARCHAR DEFAULT NULL,
+    p_device VARCHAR DEFAULT NULL,
+    p_page_path VARCHAR DEFAULT NULL,
+    p_dimensions JSONB DEFAULT NULL,
+    p_bots BOOLEAN DEFAULT NULL
+)
+RETURNS TABLE (
+    current_visitors BIGINT,
+    today_pageviews BIGINT,
+    today_visitors BIGINT,
+    bounce_rate NUMERIC(5,2)
+) AS $$
+DECLARE
+    v_current_visitors BIGINT;
+    v_today_pageviews BIGINT;
+    v_today_visitors BIGINT;
+    v_bounce_rate NUMERIC(5,2);
+    v_bounces BIGINT;
+    v_today TIMESTAMPTZ := website_day_start(p_website_id, 0);
+BEGIN
+    -- 1. Current visitors (sessions in last 5 minutes)
+    SELECT COUNT(DISTINCT e.session_id) INTO v_current_visitors
+    FROM website_event e
+    JOIN session s ON e.session_id = s.session_id
+    WHERE e.website_id = p_website_id
+      AND e.created_at >= NOW() - INTERVAL '5 minutes'
+      AND e.event_type = 1
+      AND (p_country IS NULL OR s.country = p_country)
+      AND (p_browser IS NULL OR s.browser = p_browser)
+      AND (p_device IS NULL OR s.device = p_device)
+      AND (p_dimensions IS NULL OR e.dimensions @> p_dimensions)
+      AND (p_bots IS NULL OR e.bot = p_bots)
+      AND (p_page_path IS NULL OR e.url_path = p_page_path);
+
+    -- 2. Today's pageviews
+    SELECT COUNT(*) INTO v_today_pageviews
+    FROM website_event e
+    JOIN session s ON e.session_id = s.session_id
+    WHERE e.website_id = p_website_id
+      AND e.created_at >= v_today
+      AND e.event_type = 1
+      AND (p_country IS NULL OR s.country = p_country)
+      AND (p_browser IS NULL OR s.browser = p_browser)
+      AND (p_device IS NULL OR s.device = p_device)
+      AND (p_dimensions IS NULL OR e.dimensions @> p_dimensions)
+      AND (p_bots IS NULL OR e.bot = p_bots)
+      AND (p_page_path IS NULL OR e.url_path = p_page_path);
+
+    -- 3. Today's unique visitors
+    SELECT COUNT(DISTINCT e.session_id) INTO v_today_visitors
+    FROM website_event e
+    JOIN session s ON e.session_id = s.session_id
+    WHERE e.website_id = p_website_id
+      AND e.created_at >= v_today
+      AND e.event_type = 1
+      AND (p_country IS NULL OR s.country = p_country)
+      AND (p_browser IS NULL OR s.browser = p_browser)
+      AND (p_device IS NULL OR s.device = p_device)
+      AND (p_dimensions IS NULL OR e.dimensions @> p_dimensions)
+      AND (p_bots IS NULL OR e.bot = p_bots)
+      AND (p_page_path IS NULL OR e.url_path = p_page_path);
+
+    -- 4. Bounce rate (sessions with only 1 pageview)
+    v_bounce_rate := 0;
+    IF v_today_visitors > 0 THEN
+        SELECT COUNT(*) INTO v_bounces
+        FROM (
+            SELECT e.session_id
+            FROM website_event e
+            JOIN session s ON e.session_id = s.session_id
+            WHERE e.website_id = p_website_id
+              AND e.created_at >= v_today
+              AND e.event_type = 1
+              AND (p_country IS NULL OR s.country = p_country)
+              AND (p_browser IS NULL OR s.browser = p_browser)
+              AND (p_device IS NULL OR s.device = p_device)
+              AND (p_dimensions IS NULL OR e.dimensions @> p_dimensions)
+              AND (p_bots IS NULL OR e.bot = p_bots)
+              AND (p_page_path IS NULL OR e.url_path = p_page_path)
+            GROUP BY e.session_id
+            HAVING COUNT(*) = 1
+        ) bounced_sessions;
+
+        v_bounce_rate := (v_bounces::NUMERIC / v_today_visitors::NUMERIC) * 100;
+    END IF;
+
+    -- Return all stats as a single row
+    RETURN QUERY SELECT v_current_visitors, v_today_pageviews, v_today_visitors, v_bounce_rate;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+CREATE OR REPLACE FUNCTION get_top_pages(
+    p_website_id UUID,
+    p_days INTEGER DEFAULT 1,
+    p_limit INTEGER DEFAULT 10,
+    p_offset INTEGER DEFAULT 0,
+    p_country VARCHAR DEFAULT NULL,
+    p_browser VARCHAR DEFAULT NULL,
+    p_device VARCHAR DEFAULT NULL,
+    p_dimensions JSONB DEFAULT NULL,
+    p_bots BOOLEAN DEFAULT NULL,
+    p_start TIMESTAMPTZ DEFAULT NULL,
+    p_end TIMESTAMPTZ DEFAULT NULL
+)
+RETURNS TABLE (
+    path VARCHAR,
+    views BIGINT,
+    unique_visitors BIGINT,
+    avg_engagement_time NUMERIC,
+    bounce_rate NUMERIC,
+    total_count BIGINT
+) AS $$
+DECLARE
+    v_since TIMESTAMPTZ := website_day_start(p_website_id, p_days);
+BEGIN
+    RETURN QUERY
+    WITH filtered_events AS (
+        SELECT
+            e.url_path,
+            e.session_id,
+            e.engagement_time,
+            COUNT(*) OVER (PARTITION BY e.session_id) AS session_pageviews
+        FROM website_event e
+        JOIN session s ON e.session_id = s.session_id
+        WHERE e.website_id = p_website_id
+          AND e.created_at >= v_since
+          AND e.event_type = 1
+          AND e.url_path IS NOT NULL
+          AND (p_country IS NULL OR s.country = p_country)
+          AND (p_browser IS NULL OR s.browser = p_browser)
+          AND (p_device IS NULL OR s.device = p_device)
+          AND (p_dimensions IS NULL OR e.dimensions @> p_dimensions)
+          AND (p_bots IS NULL OR e.bot = p_bots)
+          AND (p_start IS NULL OR e.created_at >= p_start)
+          AND (p_end IS NULL OR e.created_at < p_end)
+    ),
+    page_stats AS (
+        SELECT
+            fe.url_path,
+            COUNT(*)::BIGINT as view_count,
+            COUNT(DISTINCT fe.session_id)::BIGINT as unique_visitor_count,
+            ROUND(AVG(COALESCE(fe.engagement_time, 0)), 0) as avg_time,
+            ROUND(COUNT(DISTINCT fe.session_id) FILTER (WHERE fe.session_pageviews = 1)::NUMERIC
+                / COUNT(DISTINCT fe.session_id) * 100, 1) as bounce
+        FROM filtered_events fe
+        GROUP BY fe.url_path
+    ),
+    total_count_cte AS (
+        SELECT COUNT(*)::BIGINT as total FROM page_stats
+    )
+    SELECT
+        ps.url_path::VARCHAR,
+        ps.view_count,
+        ps.unique_visitor_count,
+        ps.avg_time,
+        ps.bounce,
+        tc.total as total_count
+    FROM page_stats ps
+    CROSS JOIN total_count_cte tc
+    ORDER BY ps.view_count DESC
+    LIMIT p_limit
+    OFFSET p_offset;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+CREATE OR REPLACE FUNCTION get_timeseries(
+    p_website_id UUID,
+    p_days INTEGER DEFAULT 7,
+    p_country VARCHAR DEFAULT NULL,
+    p_browser VARCHAR DEFAULT NULL,
+    p_device VARCHAR DEFAULT NULL,
+    p_page_path VARCHAR DEFAULT NULL,
+    p_dimensions JSONB DEFAULT NULL,
+    p_bots BOOLEAN DEFAULT NULL,
+    p_start TIMESTAMPTZ DEFAULT NULL,
+    p_end TIMESTAMPTZ DEFAULT NULL
+)
+RETURNS TABLE (
+    hour TIMESTAMPTZ,
+    views BIGINT
+) AS $$
+DECLARE
+    v_timezone TEXT := website_timezone(p_website_id);
+BEGIN
+    RETURN QUERY
+    SELECT
+        DATE_TRUNC('hour', e.created_at AT TIME ZONE v_timezone) AT TIME ZONE v_timezone as hour,
+        COUNT(*)::BIGINT as views
+    FROM website_event e
+    JOIN session s ON e.session_id = s.session_id
+    WHERE e.website_id = p_website_id
+      AND e.created_at >= NOW() - (p_days || ' days')::INTERVAL
+      AND e.event_type = 1
+      AND (p_country IS NULL OR s.country = p_country)
+      AND (p_browser IS NULL OR s.browser = p_browser)
+      AND (p_device IS NULL OR s.device = p_device)
+      AND (p_dimensions IS NULL OR e.dimensions @> p_dimensions)
+      AND (p_bots IS NULL OR e.bot = p_bots)
+      AND (p_start IS NULL OR e.created_at >= p_start)
+      AND (p_end IS NULL OR e.created_at < p_end)
+      AND (p_page_path IS NULL OR e.url_path = p_page_path)
+    GROUP BY hour
+    ORDER BY hour ASC;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+CREATE OR REPLACE FUNCTION get_breakdown(
+    p_website_id UUID,
+    p_dimension VARCHAR,
+    p_days INTEGER DEFAULT 1,
+    p_limit INTEGER DEFAULT 10,
+    p_offset INTEGER DEFAULT 0,
+    p_country VARCHAR DEFAULT NULL,
+    p_browser VARCHAR DEFAULT NULL,
+    p_device VARCHAR DEFAULT NULL,
+    p_page_path VARCHAR DEFAULT NULL,
+    p_dimensions JSONB DEFAULT NULL,
+    p_bots BOOLEAN DEFAULT NULL,
+    p_start TIMESTAMPTZ DEFAULT NULL,
+    p_end TIMESTAMPTZ DEFAULT NULL
+)
+RETURNS TABLE (name VARCHAR, count BIGINT, total_count BIGINT) AS $$
+DECLARE
+    v_since TIMESTAMPTZ := website_day_start(p_website_id, p_days);
+BEGIN
+    IF p_dimension NOT IN ('country', 'browser', 'device', 'referrer', 'city', 'region', 'page', 'asn', 'screen', 'viewport')
+       AND NOT EXISTS (
+           SELECT 1 FROM custom_dimension cd
+           WHERE cd.website_id = p_website_id AND cd.name = p_dimension
+       ) THEN
+        RAISE EXCEPTION 'Invalid dimension: %. Must be country, browser, device, referrer, city, region, page, asn, screen, viewport or a custom dimension', p_dimension;
+    END IF;
+
+    RETURN QUERY
+    WITH breakdown_data AS (
+        SELECT
+            (CASE p_dimension
+                WHEN 'country' THEN COALESCE(s.country, 'Unknown')
+                WHEN 'browser' THEN COALESCE(s.browser, 'Unknown')
+                WHEN 'device' THEN COALESCE(s.device, 'Unknown')
+                WHEN 'referrer' THEN COALESCE(e.referrer_domain, 'Direct / None')
+                WHEN 'city' THEN COALESCE(s.city || COALESCE(', ' || s.region, '') || COALESCE(', ' || s.country, ''), 'Unknown')
+                WHEN 'region' THEN COALESCE(s.region || COALESCE(', ' || s.country, ''), 'Unknown')
+                WHEN 'page' THEN e.url_path
+                WHEN 'asn' THEN COALESCE('AS' || s.asn || COALESCE(' ' || s.isp, ''), 'Unknown')
+                WHEN 'screen' THEN COALESCE(screen_bucket(s.screen), 'Unknown')
+                WHEN 'viewport' THEN COALESCE(viewport_class(s.screen), 'Unknown')
+                ELSE COALESCE(e.dimensions->>p_dimension, 'Unknown')
+            END)::VARCHAR as dim_name,
+            COUNT(*)::BIGINT as dim_count
+        FROM website_event e
+        JOIN session s ON e.session_id = s.session_id
+        WHERE e.website_id = p_website_id
+          AND e.created_at >= v_since
+          AND e.event_type = 1
+          AND (p_dimension <> 'page' OR e.url_path IS NOT NULL)
+          AND (p_country IS NULL OR p_dimension = 'country' OR s.country = p_country)
+          AND (p_browser IS NULL OR p_dimension = 'browser' OR s.browser = p_browser)
+          AND (p_device IS NULL OR p_dimension = 'device' OR s.device = p_device)
+          AND (p_page_path IS NULL OR p_dimension = 'page' OR e.url_path = p_page_path)
+          AND (p_dimensions IS NULL OR (p_dimensions - p_dimension) = '{}'::jsonb OR e.dimensions @> (p_dimensions - p_dimension))
+          AND (p_bots IS NULL OR e.bot = p_bots)
+          AND (p_start IS NULL OR e.created_at >= p_start)
+          AND (p_end IS NULL OR e.created_at < p_end)
+        GROUP BY 1
+    ),
+    total_count_cte AS (
+        SELECT COUNT(*)::BIGINT as total FROM breakdown_data
+    )
+    SELECT bd.dim_name, bd.dim_count, tc.total
+    FROM breakdown_data bd
+    CROSS JOIN total_count_cte tc
+    ORDER BY bd.dim_count DESC
+    LIMIT p_limit
+    OFFSET p_offset;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+-- Each website's day runs from its own midnight; hourly buckets are hours of
+-- its time zone, so they never straddle two days of a half-hour offset.
+CREATE OR REPLACE FUNCTION refresh_rollup_day(p_day DATE)
+RETURNS VOID AS $$
+BEGIN
+    CREATE TEMP TABLE rollup_bounds AS
+    SELECT
+        w.website_id,
+        w.timezone,
+        p_day::TIMESTAMP AT TIME ZONE w.timezone AS day_start,
+        (p_day + 1)::TIMESTAMP AT TIME ZONE w.timezone AS day_end
+    FROM website w;
+
+    DELETE FROM rollup_hourly h
+    USING rollup_bounds b
+    WHERE h.website_id = b.website_id AND h.bucket >= b.day_start AND h.bucket < b.day_end;
+    DELETE FROM rollup_daily WHERE day = p_day;
+    DELETE FROM rollup_daily_dimension WHERE day = p_day;
+
+    INSERT INTO rollup_hourly (website_id, bucket, pageviews, visitors, events)
+    SELECT
+        e.website_id,
+        DATE_TRUNC('hour', e.created_at AT TIME ZONE b.timezone) AT TIME ZONE b.timezone,
+        COUNT(*) FILTER (WHERE e.event_type = 1),
+        COUNT(DISTINCT e.session_id) FILTER (WHERE e.event_type = 1),
+        COUNT(*) FILTER (WHERE e.event_type = 2)
+    FROM rollup_bounds b
+    JOIN website_event e ON e.website_id = b.website_id
+     AND e.created_at >= b.day_start AND e.created_at < b.day_end
+    GROUP BY e.website_id, DATE_TRUNC('hour', e.created_at AT TIME ZONE b.timezone) AT TIME ZONE b.timezone;
+
+    INSERT INTO rollup_daily (website_id, day, pageviews, visitors, events, bounces, engagement_seconds)
+    SELECT
+        ss.website_id,
+        p_day,
+        SUM(ss.pageviews),
+        COUNT(*) FILTER (WHERE ss.pageviews > 0),
+        SUM(ss.events),
+        COUNT(*) FILTER (WHERE ss.pageviews = 1),
+        COALESCE(SUM(ss.duration), 0)
+    FROM (
+        SELECT
+            e.website_id,
+            e.session_id,
+            COUNT(*) FILTER (WHERE e.event_type = 1) AS pageviews,
+            COUNT(*) FILTER (WHERE e.event_type = 2) AS events,
+            EXTRACT(EPOCH FROM (
+                MAX(e.created_at) FILTER (WHERE e.event_type = 1) -
+                MIN(e.created_at) FILTER (WHERE e.event_type = 1)
+            )) AS duration
+        FROM rollup_bounds b
+        JOIN website_event e ON e.website_id = b.website_id
+         AND e.created_at >= b.day_start AND e.created_at < b.day_end
+        GROUP BY e.website_id, e.session_id
+    ) ss
+    GROUP BY ss.website_id;
+
+    INSERT INTO rollup_daily_dimension (website_id, day, dimension, value, pageviews, visitors, engagement_time)
+    SELECT
+        e.website_id,
+        p_day,
+        d.dimension,
+        d.value,
+        COUNT(*),
+        COUNT(DISTINCT e.session_id),
+        COALESCE(SUM(e.engagement_time), 0)
+    FROM rollup_bounds b
+    JOIN website_event e ON e.website_id = b.website_id
+     AND e.created_at >= b.day_start AND e.created_at < b.day_end
+    JOIN session s ON e.session_id = s.session_id
+    CROSS JOIN LATERAL (VALUES
+        ('page', COALESCE(e.url_path, '')),
+        ('referrer', COALESCE(e.referrer_domain, '')),
+        ('country', COALESCE(s.country::VARCHAR, '')),
+        ('region', COALESCE(s.region, '')),
+        ('city', COALESCE(s.city, '')),
+        ('browser', COALESCE(s.browser, '')),
+        ('os', COALESCE(s.os, '')),
+        ('device', COALESCE(s.device, ''))
+    ) AS d(dimension, value)
+    WHERE e.event_type = 1
+    GROUP BY e.website_id, d.dimension, d.value;
+
+    DROP TABLE rollup_bounds;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,5 @@
+-- Rollback Migration 000041: GA4 Measurement Protocol
+
+DROP INDEX IF EXISTS idx_website_ga_measurement_id;
+ALTER TABLE website DROP COLUMN IF EXISTS ga_api_secret_hash;
+ALTER TABLE website DROP COLUMN IF EXISTS ga_measurement_id;
@@ -0,0 +1,11 @@
+-- Migration 000041: GA4 Measurement Protocol
+-- A website can receive GA4 Measurement Protocol hits at /mp/collect under
+-- its GA measurement ID (G-XXXXXXX), so server-side GA integrations write to
+-- Kaunta unchanged. Only the SHA-256 of the API secret they send is kept.
+
+ALTER TABLE website ADD COLUMN IF NOT EXISTS ga_measurement_id VARCHAR(32);
+ALTER TABLE website ADD COLUMN IF NOT EXISTS ga_api_secret_hash VARCHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_website_ga_measurement_id
+    ON website (UPPER(ga_measurement_id))
+    WHERE ga_measurement_id IS NOT NULL AND deleted_at IS NULL;
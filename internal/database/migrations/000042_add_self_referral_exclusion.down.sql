@@ -0,0 +1,4 @@
+-- Rollback Migration 000042: Self-referral exclusion
+
+ALTER TABLE website DROP COLUMN IF EXISTS referral_domains;
+ALTER TABLE website DROP COLUMN IF EXISTS exclude_self_referrals;
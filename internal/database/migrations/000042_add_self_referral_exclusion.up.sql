@@ -0,0 +1,12 @@
+-- Migration 000042: Self-referral exclusion
+-- Visitors moving between a website's own domains (its domain and allowed
+-- domains, and their subdomains) showed the site as its own top referrer.
+-- Such referrers are now dropped at ingestion unless the website keeps them
+-- (exclude_self_referrals = FALSE). Domains listed in referral_domains stay
+-- referrers, for own domains that are tracked as separate sources.
+
+ALTER TABLE website ADD COLUMN IF NOT EXISTS exclude_self_referrals BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE website ADD COLUMN IF NOT EXISTS referral_domains JSONB NOT NULL DEFAULT '[]'::jsonb;
+
+COMMENT ON COLUMN website.exclude_self_referrals IS 'Drop referrers from the website''s own domains';
+COMMENT ON COLUMN website.referral_domains IS 'Own domains still recorded as referrers';
@@ -0,0 +1,4 @@
+-- Rollback Migration 000043: Cross-domain linking
+
+DROP TABLE IF EXISTS session_link;
+DROP TABLE IF EXISTS website_link;
@@ -0,0 +1,27 @@
+-- Migration 000043: Cross-domain linking
+-- Websites tracked separately (a marketing site and an app on their own
+-- domains) can be linked with `kaunta website link`; a row is kept for each
+-- direction. Visitors following a link between linked websites carry their
+-- session along, and the session they start on the other side is recorded
+-- in session_link with the session (and visit) it came from.
+
+CREATE TABLE IF NOT EXISTS website_link (
+    website_id UUID NOT NULL REFERENCES website(website_id) ON DELETE CASCADE,
+    linked_website_id UUID NOT NULL REFERENCES website(website_id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (website_id, linked_website_id),
+    CHECK (website_id <> linked_website_id)
+);
+
+CREATE TABLE IF NOT EXISTS session_link (
+    website_id UUID NOT NULL REFERENCES website(website_id) ON DELETE CASCADE,
+    session_id UUID NOT NULL,
+    linked_website_id UUID NOT NULL REFERENCES website(website_id) ON DELETE CASCADE,
+    linked_session_id UUID NOT NULL,
+    linked_visit_id UUID NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (website_id, session_id, linked_session_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_link_linked ON session_link (linked_website_id, linked_session_id);
+CREATE INDEX IF NOT EXISTS idx_session_link_created ON session_link (website_id, created_at);
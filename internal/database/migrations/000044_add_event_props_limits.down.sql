@@ -0,0 +1,7 @@
+-- Rollback Migration 000044: Event properties limits and indexes
+-- The per-key indexes are dropped with `kaunta website props index remove`
+-- before rolling back; they don't depend on event_prop_index.
+
+DROP TABLE IF EXISTS event_prop_index;
+ALTER TABLE website DROP COLUMN IF EXISTS props_max_depth;
+ALTER TABLE website DROP COLUMN IF EXISTS props_max_bytes;
@@ -0,0 +1,21 @@
+-- Migration 000044: Event properties limits and indexes
+-- Large custom event properties bloated website_event. Each website can cap
+-- their size and nesting (props_max_bytes, props_max_depth; NULL for the
+-- defaults), enforced at ingestion. Websites can also have only the
+-- property keys they query indexed: event_prop_index lists them, each with a
+-- partial GIN index on website_event created by `kaunta website props index`.
+
+ALTER TABLE website ADD COLUMN IF NOT EXISTS props_max_bytes INTEGER
+    CHECK (props_max_bytes BETWEEN 1 AND 1048576);
+ALTER TABLE website ADD COLUMN IF NOT EXISTS props_max_depth INTEGER
+    CHECK (props_max_depth BETWEEN 1 AND 10);
+
+CREATE TABLE IF NOT EXISTS event_prop_index (
+    website_id UUID NOT NULL REFERENCES website(website_id) ON DELETE CASCADE,
+    key VARCHAR(50) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (website_id, key)
+);
+
+COMMENT ON COLUMN website.props_max_bytes IS 'Largest event properties kept, in bytes of JSON (NULL: default)';
+COMMENT ON COLUMN website.props_max_depth IS 'Deepest nesting of event properties kept (NULL: default)';
@@ -0,0 +1,3 @@
+-- Rollback Migration 000045: Duplicate events
+
+DROP TABLE IF EXISTS duplicate_event;
@@ -0,0 +1,11 @@
+-- Migration 000045: Duplicate events
+-- Events dropped per website and day as duplicates of one of the same
+-- session received within the dedup window. Reported by `kaunta
+-- diagnostics`, kept 30 days.
+
+CREATE TABLE IF NOT EXISTS duplicate_event (
+    website_id UUID NOT NULL REFERENCES website(website_id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    dropped BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (website_id, day)
+);
@@ -0,0 +1,3 @@
+-- Rollback Migration 000047: Short links
+
+DROP TABLE IF EXISTS short_link;
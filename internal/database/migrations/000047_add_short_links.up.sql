@@ -0,0 +1,12 @@
+-- Migration 000047: Short links
+-- Links served at /l/<slug> that redirect to a website's page; each click is
+-- recorded as a link_click event of the website (`kaunta link`).
+
+CREATE TABLE IF NOT EXISTS short_link (
+    slug VARCHAR(64) PRIMARY KEY,
+    website_id UUID NOT NULL REFERENCES website(website_id) ON DELETE CASCADE,
+    target_url TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_short_link_website ON short_link(website_id, created_at);
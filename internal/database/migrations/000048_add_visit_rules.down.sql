@@ -0,0 +1,4 @@
+-- Rollback Migration 000048: Visit rules
+
+ALTER TABLE website DROP COLUMN IF EXISTS session_timeout;
+ALTER TABLE website DROP COLUMN IF EXISTS visit_definition;
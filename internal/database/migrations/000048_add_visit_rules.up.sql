@@ -0,0 +1,13 @@
+-- Migration 000048: Visit rules
+-- Visits were the session's events within a clock hour. Each website can now
+-- choose how its sessions are split into visits (visit_definition: hour or
+-- inactivity) and after how long without events a visit ends
+-- (session_timeout, in seconds); NULL for the server's configuration.
+
+ALTER TABLE website ADD COLUMN IF NOT EXISTS visit_definition VARCHAR(20)
+    CHECK (visit_definition IN ('hour', 'inactivity'));
+ALTER TABLE website ADD COLUMN IF NOT EXISTS session_timeout INTEGER
+    CHECK (session_timeout BETWEEN 60 AND 86400);
+
+COMMENT ON COLUMN website.visit_definition IS 'How sessions are split into visits: hour or inactivity (NULL: configured)';
+COMMENT ON COLUMN website.session_timeout IS 'Seconds without events after which a visit ends (NULL: configured)';
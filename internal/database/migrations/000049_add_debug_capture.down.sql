@@ -0,0 +1,4 @@
+-- Rollback Migration 000049: Debug capture
+
+DROP TABLE IF EXISTS debug_request;
+DROP TABLE IF EXISTS debug_capture;
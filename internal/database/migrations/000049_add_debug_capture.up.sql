@@ -0,0 +1,28 @@
+-- Migration 000049: Debug capture
+-- While a website's debug capture is on, a sample of its tracking requests
+-- is stored with their (redacted) headers, body and answer to diagnose
+-- ingestion problems (`kaunta website debug`). Captured requests are kept
+-- for 3 days.
+
+CREATE TABLE IF NOT EXISTS debug_capture (
+    website_id UUID PRIMARY KEY REFERENCES website(website_id) ON DELETE CASCADE,
+    sample_rate DOUBLE PRECISION NOT NULL CHECK (sample_rate > 0 AND sample_rate <= 1),
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS debug_request (
+    request_id BIGSERIAL PRIMARY KEY,
+    website_id UUID NOT NULL REFERENCES website(website_id) ON DELETE CASCADE,
+    captured_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    method VARCHAR(10) NOT NULL,
+    url TEXT NOT NULL,
+    ip VARCHAR(64),
+    headers JSONB NOT NULL DEFAULT '{}'::jsonb,
+    body TEXT,
+    status INTEGER NOT NULL,
+    response TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_debug_request_website ON debug_request(website_id, captured_at DESC);
+CREATE INDEX IF NOT EXISTS idx_debug_request_captured ON debug_request(captured_at);
@@ -0,0 +1,3 @@
+-- Rollback Migration 000051: Annotations
+
+DROP TABLE IF EXISTS annotation;
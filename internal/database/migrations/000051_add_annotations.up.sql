@@ -0,0 +1,20 @@
+-- Migration 000051: Annotations
+-- Annotations mark a moment of a website's traffic, a deploy or the start of
+-- a campaign, often posted by a deploy pipeline. Unlike notes (000034), which
+-- explain a day, they have a time, so the dashboard draws them on the
+-- pageviews chart. author keeps the username, so annotations outlive their
+-- user.
+
+CREATE TABLE IF NOT EXISTS annotation (
+    annotation_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    website_id UUID NOT NULL REFERENCES website(website_id) ON DELETE CASCADE,
+    annotated_at TIMESTAMPTZ NOT NULL,
+    label VARCHAR(100) NOT NULL,
+    kind VARCHAR(20) NOT NULL DEFAULT 'other',
+    author VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT annotation_label_not_empty CHECK (label <> ''),
+    CONSTRAINT annotation_kind_valid CHECK (kind IN ('deploy', 'campaign', 'other'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_annotation_website_time ON annotation (website_id, annotated_at);
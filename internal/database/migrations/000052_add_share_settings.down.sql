@@ -0,0 +1,4 @@
+-- Rollback Migration 000052: Share link settings
+
+ALTER TABLE website DROP COLUMN IF EXISTS share_expires_at;
+ALTER TABLE website DROP COLUMN IF EXISTS share_password_hash;
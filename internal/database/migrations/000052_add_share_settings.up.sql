@@ -0,0 +1,7 @@
+-- Migration 000052: Share link settings
+-- A website's share link (share_id) can be protected by a password, stored
+-- as a bcrypt hash, and can expire. Both are optional; NULL leaves the link
+-- open and without end, as before.
+
+ALTER TABLE website ADD COLUMN IF NOT EXISTS share_password_hash VARCHAR(255);
+ALTER TABLE website ADD COLUMN IF NOT EXISTS share_expires_at TIMESTAMPTZ;
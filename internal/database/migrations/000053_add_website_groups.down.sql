@@ -0,0 +1,4 @@
+-- Rollback Migration 000053: Website groups
+
+DROP TABLE IF EXISTS website_group_member;
+DROP TABLE IF EXISTS website_group;
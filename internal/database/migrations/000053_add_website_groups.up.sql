@@ -0,0 +1,20 @@
+-- Migration 000053: Website groups
+-- A website group rolls several websites (all of a company's properties) up
+-- into one view: overview, time series and breakdowns are the sums of its
+-- members. Groups are named like segments (000036); a website can belong to
+-- several groups. Deleted websites drop out of the sums.
+
+CREATE TABLE IF NOT EXISTS website_group (
+    group_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(50) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS website_group_member (
+    group_id UUID NOT NULL REFERENCES website_group(group_id) ON DELETE CASCADE,
+    website_id UUID NOT NULL REFERENCES website(website_id) ON DELETE CASCADE,
+    added_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (group_id, website_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_website_group_member_website ON website_group_member (website_id);
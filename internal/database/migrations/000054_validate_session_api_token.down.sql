@@ -0,0 +1,24 @@
+-- Rollback Migration 000054: Tell API tokens from login sessions
+
+DROP FUNCTION IF EXISTS validate_session(VARCHAR);
+
+CREATE FUNCTION validate_session(p_token_hash VARCHAR)
+RETURNS TABLE (user_id UUID, username VARCHAR, session_id UUID, rate_limit INTEGER, daily_quota INTEGER) AS $$
+BEGIN
+    -- Update last_used_at and return user info
+    UPDATE user_sessions
+    SET last_used_at = NOW()
+    WHERE token_hash = p_token_hash
+      AND expires_at > NOW()
+    RETURNING user_sessions.user_id, user_sessions.session_id, user_sessions.rate_limit, user_sessions.daily_quota
+    INTO validate_session.user_id, validate_session.session_id, validate_session.rate_limit, validate_session.daily_quota;
+
+    IF FOUND THEN
+        SELECT u.username INTO validate_session.username
+        FROM users u
+        WHERE u.user_id = validate_session.user_id;
+
+        RETURN NEXT;
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,3 @@
+-- Rollback Migration 000055: Server Secrets
+
+DROP TABLE IF EXISTS server_secret;
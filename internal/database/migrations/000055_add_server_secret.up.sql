@@ -0,0 +1,12 @@
+-- Migration 000055: Server Secrets
+-- Random keys shared by every server process, each created by whichever
+-- process needs it first. The key signing /api/send cache tokens lives here,
+-- so the tokens stay valid across restarts and on any replica.
+
+CREATE TABLE IF NOT EXISTS server_secret (
+    name VARCHAR(50) PRIMARY KEY,
+    secret BYTEA NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE server_secret IS 'Random keys shared by the server processes (cache token signing)';
@@ -0,0 +1,4 @@
+-- Rollback Migration 000056: Decode backfilled UTM values
+-- The decoded values are kept: they are what ingestion stores.
+
+SELECT 1;
@@ -0,0 +1,61 @@
+-- Migration 000056: Decode backfilled UTM values
+-- Migration 000010 backfilled the utm_* columns of earlier events with the
+-- raw values of the query string, still URL-encoded ("spring%20sale",
+-- "news+letter"), while ingestion stores them decoded. Values equal to the
+-- raw ones and holding an escape are decoded; values stored at ingestion
+-- never equal their raw form once it has an escape, so they are left alone.
+
+CREATE FUNCTION pg_temp.url_decode(p_value TEXT)
+RETURNS TEXT AS $$
+DECLARE
+    v_text TEXT := replace(p_value, '+', ' ');
+    v_bytes BYTEA := '';
+    v_hex TEXT;
+    i INTEGER := 1;
+BEGIN
+    WHILE i <= length(v_text) LOOP
+        v_hex := substr(v_text, i + 1, 2);
+        IF substr(v_text, i, 1) = '%' AND v_hex ~ '^[0-9A-Fa-f]{2}$' THEN
+            v_bytes := v_bytes || decode(v_hex, 'hex');
+            i := i + 3;
+        ELSE
+            v_bytes := v_bytes || convert_to(substr(v_text, i, 1), 'UTF8');
+            i := i + 1;
+        END IF;
+    END LOOP;
+    RETURN convert_from(v_bytes, 'UTF8');
+EXCEPTION WHEN OTHERS THEN
+    -- Not UTF-8 once decoded: keep the value as sent
+    RETURN p_value;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+UPDATE website_event
+SET
+    utm_source = CASE
+        WHEN utm_source = LEFT(substring(url_query FROM '(?:^|&)utm_source=([^&]*)'), 255)
+        THEN LEFT(pg_temp.url_decode(substring(url_query FROM '(?:^|&)utm_source=([^&]*)')), 255)
+        ELSE utm_source
+    END,
+    utm_medium = CASE
+        WHEN utm_medium = LEFT(substring(url_query FROM '(?:^|&)utm_medium=([^&]*)'), 255)
+        THEN LEFT(pg_temp.url_decode(substring(url_query FROM '(?:^|&)utm_medium=([^&]*)')), 255)
+        ELSE utm_medium
+    END,
+    utm_campaign = CASE
+        WHEN utm_campaign = LEFT(substring(url_query FROM '(?:^|&)utm_campaign=([^&]*)'), 255)
+        THEN LEFT(pg_temp.url_decode(substring(url_query FROM '(?:^|&)utm_campaign=([^&]*)')), 255)
+        ELSE utm_campaign
+    END,
+    utm_content = CASE
+        WHEN utm_content = LEFT(substring(url_query FROM '(?:^|&)utm_content=([^&]*)'), 255)
+        THEN LEFT(pg_temp.url_decode(substring(url_query FROM '(?:^|&)utm_content=([^&]*)')), 255)
+        ELSE utm_content
+    END,
+    utm_term = CASE
+        WHEN utm_term = LEFT(substring(url_query FROM '(?:^|&)utm_term=([^&]*)'), 255)
+        THEN LEFT(pg_temp.url_decode(substring(url_query FROM '(?:^|&)utm_term=([^&]*)')), 255)
+        ELSE utm_term
+    END
+WHERE url_query LIKE '%utm_%'
+  AND (utm_source ~ '[%+]' OR utm_medium ~ '[%+]' OR utm_campaign ~ '[%+]' OR utm_content ~ '[%+]' OR utm_term ~ '[%+]');
@@ -62,6 +62,11 @@ func (ps *PartitionScheduler) schedulePartitionCreation() {
 	}
 }
 
+// CreateFuturePartitions creates website_event partitions for the next 30 days
+func CreateFuturePartitions() {
+	(&PartitionScheduler{}).createFuturePartitions()
+}
+
 // createFuturePartitions creates partitions for the next 30 days
 func (ps *PartitionScheduler) createFuturePartitions() {
 	logging.L().Info("creating future partitions")
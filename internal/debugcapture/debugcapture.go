@@ -0,0 +1,415 @@
+// Package debugcapture stores a sample of a website's raw tracking requests
+// while a debug capture is on (`kaunta website debug enable`): their
+// headers, body and the answer they got, to diagnose ingestion problems of
+// particular clients (a browser extension rewriting payloads, a proxy
+// dropping headers) without turning on logging for every website.
+//
+// Captures are safe to leave in the database for a while: credentials (in
+// headers or the query string) and cookies are never stored, visitor IPs only as ip_mode allows, bodies are
+// cut at 16KB, a capture ends after its TTL (at most 7 days) and captured
+// requests are deleted after 3 days (Cleanup). Captures live in PostgreSQL;
+// each server process reloads them at most once a minute.
+package debugcapture
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/logging"
+)
+
+// Bounds and defaults of a capture
+const (
+	DefaultTTL = 24 * time.Hour
+	MaxTTL     = 7 * 24 * time.Hour
+)
+
+// Keep is how long captured requests are kept
+const Keep = 72 * time.Hour
+
+// Sizes at which a request's body and answer are cut
+const (
+	maxBody     = 16 << 10
+	maxResponse = 2 << 10
+)
+
+// refreshInterval is how often a process reloads the captures
+const refreshInterval = time.Minute
+
+// Redacted replaces the value of a header that isn't stored
+const Redacted = "[redacted]"
+
+// credentialHeaders are never stored
+var credentialHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"x-api-key":           true,
+}
+
+// credentialParams are query parameters carrying credentials (the GA4
+// api_secret among them), never stored either
+var credentialParams = map[string]bool{
+	"api_secret":   true,
+	"api_key":      true,
+	"access_token": true,
+	"token":        true,
+	"secret":       true,
+	"password":     true,
+}
+
+// ipHeaders carry the visitor's IP, stored only when IPs are
+var ipHeaders = map[string]bool{
+	"x-forwarded-for":  true,
+	"x-real-ip":        true,
+	"cf-connecting-ip": true,
+	"true-client-ip":   true,
+	"forwarded":        true,
+}
+
+// ErrNotFound is returned for a website without a capture or a request
+// that wasn't captured
+var ErrNotFound = errors.New("not found")
+
+// ParseRate reads a sample rate as a percentage ("1%", "0.5%") or a
+// fraction ("0.01"), more than 0 and at most 100%
+func ParseRate(value string) (float64, error) {
+	s := strings.TrimSpace(value)
+	percent := strings.HasSuffix(s, "%")
+	rate, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err == nil && percent {
+		rate /= 100
+	}
+	if err != nil || !(rate > 0 && rate <= 1) {
+		return 0, fmt.Errorf("invalid sample rate: %q (use a percentage such as 1%% or a fraction up to 1)", value)
+	}
+	return rate, nil
+}
+
+// Capture is a website's debug capture
+type Capture struct {
+	WebsiteID  uuid.UUID `json:"website_id"`
+	Domain     string    `json:"domain"`
+	SampleRate float64   `json:"sample_rate"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Captured counts the website's stored requests
+	Captured int64 `json:"captured"`
+}
+
+// Enable starts (or changes) a website's capture for ttl
+func Enable(ctx context.Context, db *sql.DB, websiteID uuid.UUID, rate float64, ttl time.Duration) (time.Time, error) {
+	if !(rate > 0 && rate <= 1) {
+		return time.Time{}, fmt.Errorf("sample rate must be more than 0 and at most 1")
+	}
+	if ttl <= 0 || ttl > MaxTTL {
+		return time.Time{}, fmt.Errorf("ttl must be more than 0 and at most %s", MaxTTL)
+	}
+	var expiresAt time.Time
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO debug_capture (website_id, sample_rate, expires_at)
+		VALUES ($1, $2, NOW() + make_interval(secs => $3))
+		ON CONFLICT (website_id) DO UPDATE
+		SET sample_rate = EXCLUDED.sample_rate, expires_at = EXCLUDED.expires_at
+		RETURNING expires_at
+	`, websiteID, rate, int64(ttl/time.Second)).Scan(&expiresAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to enable debug capture: %w", err)
+	}
+	return expiresAt, nil
+}
+
+// Disable ends a website's capture; its captured requests are kept until
+// they expire or are cleared
+func Disable(ctx context.Context, db *sql.DB, websiteID uuid.UUID) error {
+	res, err := db.ExecContext(ctx, `DELETE FROM debug_capture WHERE website_id = $1`, websiteID)
+	if err != nil {
+		return fmt.Errorf("failed to disable debug capture: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Clear deletes a website's captured requests, returning how many
+func Clear(ctx context.Context, db *sql.DB, websiteID uuid.UUID) (int64, error) {
+	res, err := db.ExecContext(ctx, `DELETE FROM debug_request WHERE website_id = $1`, websiteID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear captured requests: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// List returns the running captures with their counts of captured
+// requests
+func List(ctx context.Context, db *sql.DB) ([]Capture, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT c.website_id, w.domain, c.sample_rate, c.expires_at,
+			(SELECT COUNT(*) FROM debug_request r WHERE r.website_id = c.website_id)
+		FROM debug_capture c
+		JOIN website w ON w.website_id = c.website_id
+		WHERE c.expires_at > NOW()
+		ORDER BY w.domain
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list debug captures: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	captures := []Capture{}
+	for rows.Next() {
+		var c Capture
+		if err := rows.Scan(&c.WebsiteID, &c.Domain, &c.SampleRate, &c.ExpiresAt, &c.Captured); err != nil {
+			return nil, fmt.Errorf("failed to read debug capture: %w", err)
+		}
+		captures = append(captures, c)
+	}
+	return captures, rows.Err()
+}
+
+// Request is a captured tracking request
+type Request struct {
+	ID         int64             `json:"id"`
+	CapturedAt time.Time         `json:"captured_at"`
+	Method     string            `json:"method"`
+	URL        string            `json:"url"`
+	IP         string            `json:"ip,omitempty"`
+	Headers    map[string]string `json:"headers"`
+	Body       string            `json:"body,omitempty"`
+	Status     int               `json:"status"`
+	Response   string            `json:"response,omitempty"`
+}
+
+// Save stores a captured request of a website, cutting its body and answer
+func Save(ctx context.Context, db *sql.DB, websiteID uuid.UUID, r Request) error {
+	headers, err := json.Marshal(r.Headers)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO debug_request (website_id, method, url, ip, headers, body, status, response)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, NULLIF($6, ''), $7, NULLIF($8, ''))
+	`, websiteID, r.Method, r.URL, r.IP, headers, cut(r.Body, maxBody), r.Status, cut(r.Response, maxResponse))
+	if err != nil {
+		return fmt.Errorf("failed to save captured request: %w", err)
+	}
+	return nil
+}
+
+// Requests returns a website's captured requests, newest first
+func Requests(ctx context.Context, db *sql.DB, websiteID uuid.UUID, limit int) ([]Request, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT request_id, captured_at, method, url, COALESCE(ip, ''), headers,
+			COALESCE(body, ''), status, COALESCE(response, '')
+		FROM debug_request
+		WHERE website_id = $1
+		ORDER BY captured_at DESC, request_id DESC
+		LIMIT $2
+	`, websiteID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list captured requests: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	requests := []Request{}
+	for rows.Next() {
+		var r Request
+		var headers []byte
+		if err := rows.Scan(&r.ID, &r.CapturedAt, &r.Method, &r.URL, &r.IP, &headers, &r.Body, &r.Status, &r.Response); err != nil {
+			return nil, fmt.Errorf("failed to read captured request: %w", err)
+		}
+		if err := json.Unmarshal(headers, &r.Headers); err != nil {
+			return nil, fmt.Errorf("failed to read captured headers: %w", err)
+		}
+		requests = append(requests, r)
+	}
+	return requests, rows.Err()
+}
+
+// Cleanup deletes the requests captured more than Keep ago and the ended
+// captures
+func Cleanup(ctx context.Context, db *sql.DB) (int64, error) {
+	res, err := db.ExecContext(ctx, `DELETE FROM debug_request WHERE captured_at < $1`, time.Now().Add(-Keep))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete captured requests: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, `DELETE FROM debug_capture WHERE expires_at < NOW()`); err != nil {
+		return 0, fmt.Errorf("failed to delete ended debug captures: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// Redact returns the headers to store: credentials and cookies are
+// replaced, and the headers carrying the visitor's IP too unless keepIP
+func Redact(headers map[string]string, keepIP bool) map[string]string {
+	stored := make(map[string]string, len(headers))
+	for key, value := range headers {
+		lower := strings.ToLower(key)
+		if credentialHeaders[lower] || (!keepIP && ipHeaders[lower]) {
+			value = Redacted
+		}
+		stored[key] = value
+	}
+	return stored
+}
+
+// RedactURL returns the request URL to store: the values of credential
+// query parameters are replaced, the rest is kept as sent
+func RedactURL(rawURL string) string {
+	path, query, ok := strings.Cut(rawURL, "?")
+	if !ok {
+		return rawURL
+	}
+	pairs := strings.Split(query, "&")
+	for i, pair := range pairs {
+		key, _, _ := strings.Cut(pair, "=")
+		if name, err := url.QueryUnescape(key); err == nil && credentialParams[strings.ToLower(name)] {
+			pairs[i] = key + "=" + url.QueryEscape(Redacted)
+		}
+	}
+	return path + "?" + strings.Join(pairs, "&")
+}
+
+// cut shortens s to n bytes, on a character boundary
+func cut(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// Capturer samples the requests of the websites with a running capture
+type Capturer struct {
+	db    func() *sql.DB
+	clock func() time.Time
+	// random picks the sampled requests
+	random func() float64
+
+	mu       sync.Mutex
+	rates    map[uuid.UUID]capture
+	loadedAt time.Time
+	loading  bool
+}
+
+// capture is a running capture as cached by a Capturer
+type capture struct {
+	rate      float64
+	expiresAt time.Time
+}
+
+// NewCapturer returns a Capturer reading the captures from db
+func NewCapturer(db func() *sql.DB) *Capturer {
+	return &Capturer{db: db, clock: time.Now, random: rand.Float64, rates: map[uuid.UUID]capture{}}
+}
+
+// Sample reports whether a request of a website is to be captured. The
+// captures are reloaded in the background once they are a minute old. A
+// nil Capturer captures nothing.
+func (c *Capturer) Sample(websiteID uuid.UUID) bool {
+	if c == nil {
+		return false
+	}
+	now := c.clock()
+
+	c.mu.Lock()
+	if now.Sub(c.loadedAt) >= refreshInterval && !c.loading {
+		c.loading = true
+		go c.reload()
+	}
+	rule, ok := c.rates[websiteID]
+	c.mu.Unlock()
+
+	return ok && now.Before(rule.expiresAt) && c.random() < rule.rate
+}
+
+// reload reads the running captures
+func (c *Capturer) reload() {
+	rates, err := c.load()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loading = false
+	c.loadedAt = c.clock()
+	if err != nil {
+		logging.L().Warn("failed to load debug captures", zap.Error(err))
+		return
+	}
+	c.rates = rates
+}
+
+func (c *Capturer) load() (map[uuid.UUID]capture, error) {
+	rates := map[uuid.UUID]capture{}
+	db := c.db()
+	if db == nil {
+		return rates, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	rows, err := db.QueryContext(ctx,
+		`SELECT website_id, sample_rate, expires_at FROM debug_capture WHERE expires_at > NOW()`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var id uuid.UUID
+		var rule capture
+		if err := rows.Scan(&id, &rule.rate, &rule.expiresAt); err != nil {
+			return nil, err
+		}
+		rates[id] = rule
+	}
+	return rates, rows.Err()
+}
+
+// Record saves a captured request off the ingestion path
+func (c *Capturer) Record(websiteID uuid.UUID, r Request) {
+	db := c.db()
+	if db == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := Save(ctx, db, websiteID, r); err != nil {
+			logging.L().Warn("failed to save captured request", zap.String("website_id", websiteID.String()), zap.Error(err))
+		}
+	}()
+}
+
+var (
+	currentMu sync.RWMutex
+	current   *Capturer
+)
+
+// Configure sets the process capturer, reading captures from db
+func Configure(db func() *sql.DB) {
+	currentMu.Lock()
+	current = NewCapturer(db)
+	currentMu.Unlock()
+}
+
+// Current returns the process capturer, nil until configured
+func Current() *Capturer {
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	return current
+}
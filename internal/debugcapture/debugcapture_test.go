@@ -0,0 +1,122 @@
+package debugcapture
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRate(t *testing.T) {
+	for value, want := range map[string]float64{"1%": 0.01, " 0.5% ": 0.005, "100%": 1, "0.25": 0.25, "1": 1} {
+		rate, err := ParseRate(value)
+		require.NoError(t, err, value)
+		assert.InDelta(t, want, rate, 1e-12, value)
+	}
+	for _, value := range []string{"", "0", "0%", "101%", "2", "-1%", "often"} {
+		_, err := ParseRate(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	headers := map[string]string{
+		"Authorization":   "Bearer secret",
+		"Cookie":          "session=abc",
+		"X-Forwarded-For": "203.0.113.7",
+		"User-Agent":      "curl/8.0",
+	}
+
+	stored := Redact(headers, false)
+	assert.Equal(t, Redacted, stored["Authorization"])
+	assert.Equal(t, Redacted, stored["Cookie"])
+	assert.Equal(t, Redacted, stored["X-Forwarded-For"])
+	assert.Equal(t, "curl/8.0", stored["User-Agent"])
+	assert.Equal(t, "session=abc", headers["Cookie"], "the request's headers are left alone")
+
+	stored = Redact(headers, true)
+	assert.Equal(t, "203.0.113.7", stored["X-Forwarded-For"])
+	assert.Equal(t, Redacted, stored["Cookie"])
+}
+
+func TestRedactURL(t *testing.T) {
+	assert.Equal(t, "/mp/collect?measurement_id=G-ABC123&api_secret=%5Bredacted%5D",
+		RedactURL("/mp/collect?measurement_id=G-ABC123&api_secret=s3cr3t"))
+	assert.Equal(t, "/k.gif?Token=%5Bredacted%5D&url=%2Fpricing&api%5Fkey=%5Bredacted%5D",
+		RedactURL("/k.gif?Token=abc&url=%2Fpricing&api%5Fkey=xyz"))
+	assert.Equal(t, "/api/send", RedactURL("/api/send"))
+	assert.Equal(t, "/k.gif?website=abc&flag", RedactURL("/k.gif?website=abc&flag"))
+}
+
+func TestCut(t *testing.T) {
+	assert.Equal(t, "short", cut("short", 10))
+	assert.Equal(t, "abc", cut("abcdef", 3))
+	// Never in the middle of a character
+	assert.Equal(t, "a", cut("aé", 2))
+}
+
+func TestSample(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	site, other := uuid.New(), uuid.New()
+	c := NewCapturer(func() *sql.DB { return nil })
+	c.clock = func() time.Time { return now }
+	c.loadedAt = now
+	c.rates[site] = capture{rate: 0.1, expiresAt: now.Add(time.Hour)}
+
+	roll := 0.05
+	c.random = func() float64 { return roll }
+	assert.True(t, c.Sample(site))
+	assert.False(t, c.Sample(other))
+
+	roll = 0.5
+	assert.False(t, c.Sample(site))
+
+	// An ended capture samples nothing before the next reload
+	roll = 0.05
+	now = now.Add(time.Hour)
+	c.loadedAt = now
+	assert.False(t, c.Sample(site))
+
+	var none *Capturer
+	assert.False(t, none.Sample(site))
+}
+
+func TestSaveCutsBodies(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	websiteID := uuid.New()
+	body := strings.Repeat("x", maxBody+10)
+
+	mock.ExpectExec(`INSERT INTO debug_request`).
+		WithArgs(websiteID, "POST", "/api/send", "", []byte(`{"User-Agent":"curl/8.0"}`), strings.Repeat("x", maxBody), 202, `{"ok":true}`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err = Save(context.Background(), db, websiteID, Request{
+		Method:   "POST",
+		URL:      "/api/send",
+		Headers:  map[string]string{"User-Agent": "curl/8.0"},
+		Body:     body,
+		Status:   202,
+		Response: `{"ok":true}`,
+	})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDisableWithoutCapture(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	websiteID := uuid.New()
+
+	mock.ExpectExec(`DELETE FROM debug_capture`).WithArgs(websiteID).WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.ErrorIs(t, Disable(context.Background(), db, websiteID), ErrNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
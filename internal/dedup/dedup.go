@@ -0,0 +1,254 @@
+// Package dedup drops duplicate events: the same event (type, name and URL)
+// of the same session arriving again within a short window, as when a
+// single-page app fires its route hook twice or a client resends an event
+// that was stored. Events that were deferred or failed to be stored are
+// forgotten, so sending them again isn't a duplicate.
+//
+// Each server process remembers the events of the window (as 64-bit hashes),
+// so duplicates landing on two servers are both kept. Dropped duplicates are
+// counted per website and day in duplicate_event, written off the ingestion
+// path at most once a minute, for `kaunta diagnostics`.
+package dedup
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/logging"
+)
+
+// DefaultWindow is how long an event is remembered unless configured
+// otherwise
+const DefaultWindow = 2 * time.Second
+
+// keepDays is how long daily counts are kept
+const keepDays = 30
+
+// flushInterval is how often counts are written
+const flushInterval = time.Minute
+
+var duplicatesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "kaunta",
+	Subsystem: "ingest",
+	Name:      "duplicates_total",
+	Help:      "Events dropped as duplicates of one received within the dedup window.",
+})
+
+// Collectors returns the dedup metrics
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{duplicatesTotal}
+}
+
+// Filter remembers the events of the last window
+type Filter struct {
+	window time.Duration
+	clock  func() time.Time
+	// record writes the counts of a flush; nil keeps them in memory only
+	record func(counts map[uuid.UUID]int64, day string)
+
+	mu        sync.Mutex
+	seen      map[uint64]time.Time
+	lastSweep time.Time
+	counts    map[uuid.UUID]int64
+	countDay  string
+	lastFlush time.Time
+}
+
+// NewFilter drops duplicates within window; record receives the dropped
+// counts at most once a minute
+func NewFilter(window time.Duration, record func(counts map[uuid.UUID]int64, day string)) *Filter {
+	return &Filter{
+		window: window,
+		clock:  time.Now,
+		record: record,
+		seen:   make(map[uint64]time.Time),
+		counts: make(map[uuid.UUID]int64),
+	}
+}
+
+// Duplicate reports whether the event was already received within the
+// window, remembering it otherwise. A nil Filter keeps every event.
+func (f *Filter) Duplicate(websiteID, sessionID uuid.UUID, eventType, name, url string) bool {
+	if f == nil {
+		return false
+	}
+	key := eventKey(sessionID, eventType, name, url)
+	now := f.clock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sweep(now)
+
+	if at, ok := f.seen[key]; ok && now.Sub(at) < f.window {
+		duplicatesTotal.Inc()
+		f.count(websiteID, now)
+		return true
+	}
+	f.seen[key] = now
+	return false
+}
+
+// Forget drops an event Duplicate remembered, so the client may send it
+// again: it was deferred or failed to be stored
+func (f *Filter) Forget(sessionID uuid.UUID, eventType, name, url string) {
+	if f == nil {
+		return
+	}
+	key := eventKey(sessionID, eventType, name, url)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.seen, key)
+}
+
+// eventKey hashes an event of a session
+func eventKey(sessionID uuid.UUID, eventType, name, url string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write(sessionID[:])
+	for _, part := range []string{eventType, name, url} {
+		_, _ = h.Write([]byte(part))
+		_, _ = h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+// sweep forgets events past the window, at most once per window
+func (f *Filter) sweep(now time.Time) {
+	if now.Sub(f.lastSweep) < f.window {
+		return
+	}
+	f.lastSweep = now
+	for key, at := range f.seen {
+		if now.Sub(at) >= f.window {
+			delete(f.seen, key)
+		}
+	}
+}
+
+// count adds a duplicate to the day's counts, handing them to record when
+// the day changes or a minute has passed
+func (f *Filter) count(websiteID uuid.UUID, now time.Time) {
+	day := now.UTC().Format("2006-01-02")
+	if f.countDay != day && len(f.counts) > 0 {
+		f.flush(now)
+	}
+	if f.lastFlush.IsZero() {
+		f.lastFlush = now
+	}
+	f.countDay = day
+	f.counts[websiteID]++
+	if now.Sub(f.lastFlush) >= flushInterval {
+		f.flush(now)
+	}
+}
+
+func (f *Filter) flush(now time.Time) {
+	counts, day := f.counts, f.countDay
+	f.counts, f.lastFlush = make(map[uuid.UUID]int64), now
+	if f.record != nil {
+		f.record(counts, day)
+	}
+}
+
+var (
+	currentMu sync.RWMutex
+	current   *Filter
+)
+
+// Configure sets the process filter (none for a zero window); counts are
+// stored in db when it isn't nil
+func Configure(window time.Duration, db func() *sql.DB) {
+	var filter *Filter
+	if window > 0 {
+		filter = NewFilter(window, func(counts map[uuid.UUID]int64, day string) {
+			conn := db()
+			if conn == nil {
+				return
+			}
+			// Off the ingestion path: a slow database mustn't hold up tracking
+			go func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+				if err := Record(ctx, conn, counts, day); err != nil {
+					logging.L().Warn("failed to record duplicate events", zap.Error(err))
+				}
+			}()
+		})
+	}
+	currentMu.Lock()
+	current = filter
+	currentMu.Unlock()
+}
+
+// Current returns the process filter, nil when dedup is off
+func Current() *Filter {
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	return current
+}
+
+// Record adds the duplicates dropped on day to the stored counts
+func Record(ctx context.Context, db *sql.DB, counts map[uuid.UUID]int64, day string) error {
+	for websiteID, n := range counts {
+		if _, err := db.ExecContext(ctx, `
+			INSERT INTO duplicate_event (website_id, day, dropped)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (website_id, day) DO UPDATE SET dropped = duplicate_event.dropped + EXCLUDED.dropped
+		`, websiteID, day, n); err != nil {
+			return err
+		}
+	}
+	_, err := db.ExecContext(ctx,
+		`DELETE FROM duplicate_event WHERE day < CURRENT_DATE - $1::int`, keepDays)
+	return err
+}
+
+// Website is the duplicates dropped for one website
+type Website struct {
+	Domain  string `json:"domain"`
+	Dropped int64  `json:"dropped"`
+}
+
+// Summary is the duplicates dropped over the last days
+type Summary struct {
+	Days     int       `json:"days"`
+	Dropped  int64     `json:"dropped"`
+	Websites []Website `json:"websites"`
+}
+
+// Report sums the duplicates dropped over the last days, most first
+func Report(ctx context.Context, db *sql.DB, days int) (*Summary, error) {
+	if days < 1 {
+		return nil, fmt.Errorf("days must be at least 1")
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT w.domain, SUM(d.dropped)::BIGINT
+		FROM duplicate_event d
+		JOIN website w ON w.website_id = d.website_id
+		WHERE d.day >= CURRENT_DATE - $1::int
+		GROUP BY w.domain
+		ORDER BY 2 DESC, 1
+	`, days)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query duplicate events: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	summary := &Summary{Days: days, Websites: []Website{}}
+	for rows.Next() {
+		var w Website
+		if err := rows.Scan(&w.Domain, &w.Dropped); err != nil {
+			return nil, fmt.Errorf("failed to read duplicate events: %w", err)
+		}
+		summary.Dropped += w.Dropped
+		summary.Websites = append(summary.Websites, w)
+	}
+	return summary, rows.Err()
+}
@@ -0,0 +1,123 @@
+package dedup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDuplicate(t *testing.T) {
+	filter := NewFilter(2*time.Second, nil)
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	filter.clock = func() time.Time { return now }
+	site, session, other := uuid.New(), uuid.New(), uuid.New()
+
+	assert.False(t, filter.Duplicate(site, session, "event", "signup", "/pricing"))
+	assert.True(t, filter.Duplicate(site, session, "event", "signup", "/pricing"))
+	// Another name, URL or session is another event
+	assert.False(t, filter.Duplicate(site, session, "event", "signup", "/"))
+	assert.False(t, filter.Duplicate(site, session, "event", "", "/pricing"))
+	assert.False(t, filter.Duplicate(site, other, "event", "signup", "/pricing"))
+
+	// Past the window the event counts again
+	now = now.Add(2 * time.Second)
+	assert.False(t, filter.Duplicate(site, session, "event", "signup", "/pricing"))
+
+	var none *Filter
+	assert.False(t, none.Duplicate(site, session, "event", "signup", "/pricing"))
+}
+
+func TestForget(t *testing.T) {
+	filter := NewFilter(time.Minute, nil)
+	site, session := uuid.New(), uuid.New()
+
+	assert.False(t, filter.Duplicate(site, session, "event", "signup", "/pricing"))
+	filter.Forget(session, "event", "signup", "/pricing")
+	assert.False(t, filter.Duplicate(site, session, "event", "signup", "/pricing"))
+	assert.True(t, filter.Duplicate(site, session, "event", "signup", "/pricing"))
+
+	var none *Filter
+	none.Forget(session, "event", "signup", "/pricing")
+}
+
+func TestDuplicateFieldsDontRunTogether(t *testing.T) {
+	filter := NewFilter(time.Minute, nil)
+	site, session := uuid.New(), uuid.New()
+
+	assert.False(t, filter.Duplicate(site, session, "event", "ab", "/c"))
+	assert.False(t, filter.Duplicate(site, session, "event", "a", "b/c"))
+}
+
+func TestDuplicateCounts(t *testing.T) {
+	type flush struct {
+		counts map[uuid.UUID]int64
+		day    string
+	}
+	var flushes []flush
+	filter := NewFilter(time.Minute, func(counts map[uuid.UUID]int64, day string) {
+		flushes = append(flushes, flush{counts, day})
+	})
+	now := time.Date(2025, 6, 15, 23, 59, 40, 0, time.UTC)
+	filter.clock = func() time.Time { return now }
+	site, session := uuid.New(), uuid.New()
+
+	filter.Duplicate(site, session, "event", "", "/")
+	filter.Duplicate(site, session, "event", "", "/")
+	filter.Duplicate(site, session, "event", "", "/")
+	assert.Empty(t, flushes)
+
+	// A new day hands over the previous day's counts
+	now = now.Add(30 * time.Second)
+	filter.Duplicate(site, session, "event", "", "/")
+	filter.Duplicate(site, session, "event", "", "/")
+	require.Len(t, flushes, 1)
+	assert.Equal(t, flush{map[uuid.UUID]int64{site: 2}, "2025-06-15"}, flushes[0])
+
+	// Then counts are handed over once a minute
+	now = now.Add(70 * time.Second)
+	filter.Duplicate(site, session, "event", "", "/")
+	filter.Duplicate(site, session, "event", "", "/")
+	require.Len(t, flushes, 2)
+	assert.Equal(t, flush{map[uuid.UUID]int64{site: 3}, "2025-06-16"}, flushes[1])
+}
+
+func TestRecord(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	websiteID := uuid.New()
+
+	mock.ExpectExec(`INSERT INTO duplicate_event`).WithArgs(websiteID, "2025-06-15", int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM duplicate_event`).WithArgs(keepDays).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	require.NoError(t, Record(context.Background(), db, map[uuid.UUID]int64{websiteID: 3}, "2025-06-15"))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReport(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectQuery(`FROM duplicate_event`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"domain", "dropped"}).
+			AddRow("example.com", 40).
+			AddRow("blog.example.com", 2))
+
+	summary, err := Report(context.Background(), db, 7)
+	require.NoError(t, err)
+	assert.Equal(t, 7, summary.Days)
+	assert.Equal(t, int64(42), summary.Dropped)
+	assert.Equal(t, []Website{{"example.com", 40}, {"blog.example.com", 2}}, summary.Websites)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = Report(context.Background(), db, 0)
+	assert.Error(t, err)
+}
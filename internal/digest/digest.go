@@ -0,0 +1,159 @@
+// Package digest renders saved report results as emails: a subject, an HTML
+// body and a plain-text body.
+//
+// The layout is made of Go templates embedded in the binary (templates/).
+// Any of them can be replaced by a file of the same name in the override
+// directory, <data_dir>/templates/digest:
+//
+//	digest.html          HTML body (html/template)
+//	digest.txt           subject and plain-text body (text/template)
+//	locales/<lang>.json  strings, number and date format of a language
+//
+// Locale files in the override directory are merged over the embedded ones,
+// so a single string can be changed, or a language added. The HTML comes in
+// a light and a dark variant, or "auto", which follows the reader's system.
+package digest
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	texttemplate "text/template"
+
+	"github.com/seuros/kaunta/internal/reports"
+)
+
+//go:embed templates
+var embedded embed.FS
+
+// Template file names, in the embedded FS and the override directory
+const (
+	htmlTemplate = "digest.html"
+	textTemplate = "digest.txt"
+)
+
+// Themes are the variants of the HTML body
+var Themes = []string{"light", "dark", "auto"}
+
+// Palette is the colors of a theme, inlined in the HTML for mail clients
+// that drop style sheets
+type Palette struct {
+	Background string
+	Surface    string
+	Text       string
+	Muted      string
+	Border     string
+	Accent     string
+}
+
+var palettes = map[string]Palette{
+	"light": {Background: "#f4f5f7", Surface: "#ffffff", Text: "#1f2933", Muted: "#616e7c", Border: "#e4e7eb", Accent: "#2563eb"},
+	"dark":  {Background: "#111827", Surface: "#1f2937", Text: "#f3f4f6", Muted: "#9ca3af", Border: "#374151", Accent: "#60a5fa"},
+}
+
+// Options select how a result is rendered
+type Options struct {
+	// Lang is a language tag such as "de" or "pt-BR"; unknown languages
+	// fall back to their base language, then English
+	Lang string
+	// Theme is light (default), dark or auto
+	Theme string
+	// Dir is the override directory; empty uses the embedded templates only
+	Dir string
+}
+
+// Email is a rendered digest
+type Email struct {
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// OverrideDir is where templates overriding the embedded ones are looked up
+func OverrideDir(dataDir string) string {
+	return filepath.Join(dataDir, "templates", "digest")
+}
+
+// data is what the templates see
+type data struct {
+	*reports.Result
+	Lang   string
+	Theme  string
+	Colors Palette
+	// Dark is the palette the auto theme switches to
+	Dark Palette
+}
+
+// Render writes res as an email
+func Render(res *reports.Result, opts Options) (*Email, error) {
+	theme := opts.Theme
+	if theme == "" {
+		theme = "light"
+	}
+	if !slices.Contains(Themes, theme) {
+		return nil, fmt.Errorf("invalid theme: %s (use light, dark or auto)", theme)
+	}
+	loc, err := loadLocale(opts.Dir, opts.Lang)
+	if err != nil {
+		return nil, err
+	}
+
+	colors := palettes[theme]
+	if theme == "auto" {
+		colors = palettes["light"]
+	}
+	d := data{Result: res, Lang: loc.Tag, Theme: theme, Colors: colors, Dark: palettes["dark"]}
+
+	src, err := readTemplate(opts.Dir, textTemplate)
+	if err != nil {
+		return nil, err
+	}
+	text, err := texttemplate.New(textTemplate).Funcs(loc.funcs()).Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", textTemplate, err)
+	}
+	src, err = readTemplate(opts.Dir, htmlTemplate)
+	if err != nil {
+		return nil, err
+	}
+	html, err := htmltemplate.New(htmlTemplate).Funcs(loc.funcs()).Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", htmlTemplate, err)
+	}
+
+	var subject, body, page bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", d); err != nil {
+		return nil, fmt.Errorf("failed to render subject: %w", err)
+	}
+	if err := text.Execute(&body, d); err != nil {
+		return nil, fmt.Errorf("failed to render text: %w", err)
+	}
+	if err := html.Execute(&page, d); err != nil {
+		return nil, fmt.Errorf("failed to render HTML: %w", err)
+	}
+	return &Email{Subject: string(bytes.TrimSpace(subject.Bytes())), HTML: page.String(), Text: body.String()}, nil
+}
+
+// readTemplate returns the override of a template file, or the embedded one
+func readTemplate(dir, name string) (string, error) {
+	if dir != "" {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return string(data), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("failed to read template override: %w", err)
+		}
+	}
+	data, err := embedded.ReadFile("templates/" + name)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,114 @@
+package digest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/reports"
+)
+
+func result() *reports.Result {
+	return &reports.Result{
+		Report:  "weekly-seo",
+		Website: "example.com",
+		Days:    7,
+		Filters: map[string]string{"country": "DE"},
+		Metrics: []reports.Metric{{Name: "visitors", Value: 1234}, {Name: "pageviews", Value: 5678901}},
+		Breakdowns: []reports.Breakdown{
+			{Dimension: "referrer", Rows: []reports.Row{{Name: "google.com", Pageviews: 1200}, {Name: "<b>x</b>", Pageviews: 3}}},
+			{Dimension: "plan", Rows: []reports.Row{}},
+		},
+		GeneratedAt: time.Date(2025, 6, 9, 8, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestRenderDefaults(t *testing.T) {
+	email, err := Render(result(), Options{})
+	require.NoError(t, err)
+
+	assert.Equal(t, "weekly-seo report for example.com", email.Subject)
+	assert.Contains(t, email.Text, "example.com · last 7 days · Jun 9, 2025")
+	assert.Contains(t, email.Text, "Filters: country=DE")
+	assert.Contains(t, email.Text, "Visitors: 1,234")
+	assert.Contains(t, email.Text, "Pageviews: 5,678,901")
+	assert.Contains(t, email.Text, "Referrers\n  google.com: 1,200")
+	assert.Contains(t, email.Text, "plan\n  No data")
+
+	assert.Contains(t, email.HTML, `<html lang="en">`)
+	assert.Contains(t, email.HTML, `content="light"`)
+	assert.Contains(t, email.HTML, "background:#ffffff")
+	assert.Contains(t, email.HTML, "&lt;b&gt;x&lt;/b&gt;")
+	assert.NotContains(t, email.HTML, "prefers-color-scheme")
+}
+
+func TestRenderThemes(t *testing.T) {
+	dark, err := Render(result(), Options{Theme: "dark"})
+	require.NoError(t, err)
+	assert.Contains(t, dark.HTML, "background:#1f2937")
+	assert.NotContains(t, dark.HTML, "prefers-color-scheme")
+
+	auto, err := Render(result(), Options{Theme: "auto"})
+	require.NoError(t, err)
+	assert.Contains(t, auto.HTML, "background:#ffffff")
+	assert.Contains(t, auto.HTML, "prefers-color-scheme: dark")
+	assert.Contains(t, auto.HTML, "#1f2937")
+
+	_, err = Render(result(), Options{Theme: "sepia"})
+	assert.Error(t, err)
+}
+
+func TestRenderLocales(t *testing.T) {
+	email, err := Render(result(), Options{Lang: "de_CH"})
+	require.NoError(t, err)
+	assert.Equal(t, "weekly-seo-Bericht für example.com", email.Subject)
+	assert.Contains(t, email.Text, "letzte 7 Tage · 09.06.2025")
+	assert.Contains(t, email.Text, "Seitenaufrufe: 5.678.901")
+	assert.Contains(t, email.HTML, `<html lang="de">`)
+
+	// Unknown languages are English
+	email, err = Render(result(), Options{Lang: "xx"})
+	require.NoError(t, err)
+	assert.Contains(t, email.Text, "Visitors: 1,234")
+
+	_, err = Render(result(), Options{Lang: "../en"})
+	assert.Error(t, err)
+}
+
+func TestRenderOverrides(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "locales"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "digest.txt"),
+		[]byte(`{{define "subject"}}{{t "subject" .Report .Website}}{{end}}{{range .Metrics}}{{label "metric" .Name}}={{num .Value}};{{end}}`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "locales", "en.json"),
+		[]byte(`{"messages": {"metric.visitors": "People"}}`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "locales", "sv.json"),
+		[]byte(`{"thousands": " ", "messages": {"subject": "%s för %s"}}`), 0o644))
+
+	email, err := Render(result(), Options{Dir: dir})
+	require.NoError(t, err)
+	assert.Equal(t, "People=1,234;Pageviews=5,678,901;", email.Text)
+	assert.Contains(t, email.HTML, "People")
+
+	// A new language falls back to English for the strings it leaves out
+	email, err = Render(result(), Options{Dir: dir, Lang: "sv"})
+	require.NoError(t, err)
+	assert.Equal(t, "weekly-seo för example.com", email.Subject)
+	assert.Equal(t, "People=1 234;Pageviews=5 678 901;", email.Text)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "digest.html"), []byte(`{{.Missing`), 0o644))
+	_, err = Render(result(), Options{Dir: dir})
+	assert.ErrorContains(t, err, "digest.html")
+}
+
+func TestNumber(t *testing.T) {
+	l := &locale{Thousands: ","}
+	assert.Equal(t, "0", l.number(0))
+	assert.Equal(t, "999", l.number(999))
+	assert.Equal(t, "1,000", l.number(1000))
+	assert.Equal(t, "-12,345", l.number(-12345))
+}
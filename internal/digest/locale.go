@@ -0,0 +1,157 @@
+package digest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultLang is the language every other one falls back to, string by string
+const defaultLang = "en"
+
+// locale is the strings and formats of a language
+type locale struct {
+	Tag string `json:"-"`
+	// Thousands separates groups of digits in numbers
+	Thousands string `json:"thousands"`
+	// Date is a Go time layout
+	Date     string            `json:"date"`
+	Messages map[string]string `json:"messages"`
+}
+
+// loadLocale reads lang from the override directory and the embedded
+// locales, over English. lang "pt-BR" is looked up as pt-br, then pt.
+func loadLocale(dir, lang string) (*locale, error) {
+	loc := &locale{Tag: defaultLang, Messages: make(map[string]string)}
+	if _, err := mergeLocale(loc, dir, defaultLang); err != nil {
+		return nil, err
+	}
+
+	lang = strings.ToLower(strings.ReplaceAll(lang, "_", "-"))
+	candidates := []string{lang}
+	if base, _, ok := strings.Cut(lang, "-"); ok {
+		candidates = append(candidates, base)
+	}
+	for _, tag := range candidates {
+		if tag == "" || tag == defaultLang {
+			break
+		}
+		if strings.ContainsAny(tag, `/\.`) {
+			return nil, fmt.Errorf("invalid language: %s", lang)
+		}
+		found, err := mergeLocale(loc, dir, tag)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			loc.Tag = tag
+			break
+		}
+	}
+	return loc, nil
+}
+
+// mergeLocale applies the embedded, then the override file of tag to loc,
+// reporting whether either exists
+func mergeLocale(loc *locale, dir, tag string) (bool, error) {
+	found := false
+	name := "locales/" + tag + ".json"
+	if data, err := embedded.ReadFile("templates/" + name); err == nil {
+		if err := applyLocale(loc, data); err != nil {
+			return false, fmt.Errorf("embedded locale %s: %w", tag, err)
+		}
+		found = true
+	}
+	if dir != "" {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		switch {
+		case err == nil:
+			if err := applyLocale(loc, data); err != nil {
+				return false, fmt.Errorf("locale override %s: %w", tag, err)
+			}
+			found = true
+		case !errors.Is(err, fs.ErrNotExist):
+			return false, fmt.Errorf("failed to read locale override: %w", err)
+		}
+	}
+	return found, nil
+}
+
+func applyLocale(loc *locale, data []byte) error {
+	var l locale
+	if err := json.Unmarshal(data, &l); err != nil {
+		return err
+	}
+	if l.Thousands != "" {
+		loc.Thousands = l.Thousands
+	}
+	if l.Date != "" {
+		loc.Date = l.Date
+	}
+	maps.Copy(loc.Messages, l.Messages)
+	return nil
+}
+
+// funcs are the template functions of the locale:
+//
+//	t "key" args...       translated string, formatted with args
+//	label "prefix" name   translation of prefix.name, or name itself
+//	num n                 number with grouped digits
+//	date t                date in the locale's format
+func (l *locale) funcs() map[string]interface{} {
+	return map[string]interface{}{
+		"t":     l.translate,
+		"label": l.label,
+		"num":   l.number,
+		"date":  l.date,
+	}
+}
+
+func (l *locale) translate(key string, args ...interface{}) string {
+	msg, ok := l.Messages[key]
+	if !ok {
+		return key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+func (l *locale) label(prefix, name string) string {
+	if msg, ok := l.Messages[prefix+"."+name]; ok {
+		return msg
+	}
+	return name
+}
+
+func (l *locale) number(n int64) string {
+	digits := strconv.FormatInt(n, 10)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	var b strings.Builder
+	for i, c := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(l.Thousands)
+		}
+		b.WriteRune(c)
+	}
+	return sign + b.String()
+}
+
+func (l *locale) date(t time.Time) string {
+	layout := l.Date
+	if layout == "" {
+		layout = time.DateOnly
+	}
+	return t.Format(layout)
+}
@@ -0,0 +1,180 @@
+// Package dimensions manages custom dimensions: named values such as
+// content_type, plan or section that a website registers and the tracker then sets on
+// each pageview.
+//
+// Each website registers up to Max names (custom_dimension). Values arrive
+// in the tracking payload's "dimensions" object; names that are not
+// registered are dropped and the rest are stored in website_event.dimensions
+// (JSONB). Registered names can be used as breakdowns and filters wherever
+// the built-in dimensions are.
+package dimensions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Max is the number of custom dimensions a website can register
+const Max = 5
+
+// MaxValueLength is the longest stored value; longer values are truncated
+const MaxValueLength = 255
+
+// ErrLimit is returned by Add when a website already has Max dimensions
+var ErrLimit = fmt.Errorf("a website can have at most %d custom dimensions", Max)
+
+// ErrNotFound is returned by Remove for a name that is not registered
+var ErrNotFound = errors.New("custom dimension not found")
+
+// builtin names can't be reused: they are breakdowns of their own
+var builtin = map[string]bool{
+	"country": true, "browser": true, "device": true, "referrer": true,
+	"city": true, "region": true, "page": true, "os": true,
+	"source": true, "medium": true, "campaign": true, "content": true, "term": true,
+	"author": true, "asn": true, "isp": true, "screen": true, "viewport": true,
+}
+
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// Dimension is a registered custom dimension
+type Dimension struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ValidName checks that name can be registered: lowercase letters, digits
+// and underscores, starting with a letter, and not a built-in dimension
+func ValidName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid dimension name: %q (use lowercase letters, digits and _, up to 50 characters)", name)
+	}
+	if builtin[name] {
+		return fmt.Errorf("invalid dimension name: %q is a built-in dimension", name)
+	}
+	return nil
+}
+
+// List returns a website's custom dimensions in the order they were added
+func List(ctx context.Context, db *sql.DB, websiteID uuid.UUID) ([]Dimension, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT name, created_at
+		FROM custom_dimension
+		WHERE website_id = $1
+		ORDER BY created_at, name
+	`, websiteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list custom dimensions: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var dims []Dimension
+	for rows.Next() {
+		var d Dimension
+		if err := rows.Scan(&d.Name, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		dims = append(dims, d)
+	}
+	return dims, rows.Err()
+}
+
+// Add registers a custom dimension. Adding a registered name again is a
+// no-op.
+func Add(ctx context.Context, db *sql.DB, websiteID uuid.UUID, name string) error {
+	if err := ValidName(name); err != nil {
+		return err
+	}
+
+	// The limit check and the insert are one statement so concurrent adds
+	// can't both slip under it
+	res, err := db.ExecContext(ctx, `
+		INSERT INTO custom_dimension (website_id, name)
+		SELECT $1, $2
+		WHERE (SELECT COUNT(*) FROM custom_dimension WHERE website_id = $1) < $3
+		   OR EXISTS (SELECT 1 FROM custom_dimension WHERE website_id = $1 AND name = $2)
+		ON CONFLICT (website_id, name) DO NOTHING
+	`, websiteID, name, Max)
+	if err != nil {
+		return fmt.Errorf("failed to add custom dimension: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		var exists bool
+		if err := db.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM custom_dimension WHERE website_id = $1 AND name = $2)`,
+			websiteID, name).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrLimit
+		}
+	}
+	return nil
+}
+
+// Remove unregisters a custom dimension. Values already stored on events are
+// kept but no longer reported.
+func Remove(ctx context.Context, db *sql.DB, websiteID uuid.UUID, name string) error {
+	res, err := db.ExecContext(ctx,
+		`DELETE FROM custom_dimension WHERE website_id = $1 AND name = $2`, websiteID, name)
+	if err != nil {
+		return fmt.Errorf("failed to remove custom dimension: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Clean keeps the values of registered names, converted to strings and
+// truncated to MaxValueLength. Empty values and non-scalar values (objects,
+// arrays) are dropped. It returns nil when nothing is left.
+func Clean(values map[string]interface{}, registered []string) map[string]string {
+	if len(values) == 0 || len(registered) == 0 {
+		return nil
+	}
+
+	var out map[string]string
+	for _, name := range registered {
+		raw, ok := values[name]
+		if !ok {
+			continue
+		}
+		var v string
+		switch value := raw.(type) {
+		case string:
+			v = value
+		case float64:
+			v = strconv.FormatFloat(value, 'f', -1, 64)
+		case bool:
+			v = strconv.FormatBool(value)
+		default:
+			continue
+		}
+		if v == "" {
+			continue
+		}
+		if runes := []rune(v); len(runes) > MaxValueLength {
+			v = string(runes[:MaxValueLength])
+		}
+		if out == nil {
+			out = make(map[string]string)
+		}
+		out[name] = v
+	}
+	return out
+}
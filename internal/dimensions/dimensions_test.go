@@ -0,0 +1,86 @@
+package dimensions
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/test"
+)
+
+func TestValidName(t *testing.T) {
+	for _, name := range []string{"content_type", "plan2", "section", "a"} {
+		assert.NoError(t, ValidName(name), name)
+	}
+	for _, name := range []string{"", "Author", "2plan", "content-type", "plan.tier", "country", "page", "author", strings.Repeat("a", 51)} {
+		assert.Error(t, ValidName(name), name)
+	}
+}
+
+func TestClean(t *testing.T) {
+	registered := []string{"content_type", "plan", "premium", "score"}
+	values := map[string]interface{}{
+		"content_type": "tutorial",
+		"plan":         "",
+		"premium":      true,
+		"score":        4.5,
+		"secret":       "not registered",
+	}
+
+	assert.Equal(t, map[string]string{
+		"content_type": "tutorial",
+		"premium":      "true",
+		"score":        "4.5",
+	}, Clean(values, registered))
+
+	assert.Nil(t, Clean(values, nil))
+	assert.Nil(t, Clean(map[string]interface{}{"content_type": []interface{}{"a"}}, registered))
+
+	long := Clean(map[string]interface{}{"content_type": strings.Repeat("é", 300)}, registered)
+	assert.Equal(t, MaxValueLength, len([]rune(long["content_type"])))
+}
+
+func TestAdd(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+
+	mock.ExpectExec(`INSERT INTO custom_dimension`).WithArgs(websiteID, "content_type", Max).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, Add(context.Background(), db, websiteID, "content_type"))
+
+	// At the limit
+	mock.ExpectExec(`INSERT INTO custom_dimension`).WithArgs(websiteID, "plan", Max).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT EXISTS`).WithArgs(websiteID, "plan").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	assert.ErrorIs(t, Add(context.Background(), db, websiteID, "plan"), ErrLimit)
+
+	// Already registered
+	mock.ExpectExec(`INSERT INTO custom_dimension`).WithArgs(websiteID, "content_type", Max).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT EXISTS`).WithArgs(websiteID, "content_type").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	require.NoError(t, Add(context.Background(), db, websiteID, "content_type"))
+
+	assert.Error(t, Add(context.Background(), db, websiteID, "browser"))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRemove(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+
+	mock.ExpectExec(`DELETE FROM custom_dimension`).WithArgs(websiteID, "content_type").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM custom_dimension`).WithArgs(websiteID, "plan").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	require.NoError(t, Remove(context.Background(), db, websiteID, "content_type"))
+	assert.ErrorIs(t, Remove(context.Background(), db, websiteID, "plan"), ErrNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
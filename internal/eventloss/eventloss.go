@@ -0,0 +1,100 @@
+// Package eventloss estimates how many tracked events never reached the
+// database.
+//
+// The tracker numbers the events of each page instance 1, 2, 3, ... and the
+// PostgreSQL store counts, per page instance, the events received and the
+// highest number seen (event_sequence). A page whose highest number is 5 but
+// which only delivered 3 events lost 2 on the way: to the network, an
+// extension blocking some requests, a rejected payload or a full ingest
+// queue. Losses after the last delivered event, and pages whose events were
+// all blocked, leave no trace, so the figures are a lower bound.
+package eventloss
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// keepDays is how long per-page counters are kept
+const keepDays = 30
+
+// Website is the loss estimate for one website
+type Website struct {
+	Domain   string  `json:"domain"`
+	Pages    int64   `json:"pages"`    // page instances with sequenced events
+	Sent     int64   `json:"sent"`     // events the tracker numbered
+	Received int64   `json:"received"` // events that were stored
+	Lost     int64   `json:"lost"`
+	LossRate float64 `json:"loss_rate"` // percent of sent
+}
+
+// Summary is the loss estimate across all websites
+type Summary struct {
+	Days     int       `json:"days"`
+	Sent     int64     `json:"sent"`
+	Received int64     `json:"received"`
+	Lost     int64     `json:"lost"`
+	LossRate float64   `json:"loss_rate"`
+	Websites []Website `json:"websites"`
+}
+
+// Report reconciles the last days of sequence counters, worst websites first
+func Report(ctx context.Context, db *sql.DB, days int) (*Summary, error) {
+	if days < 1 {
+		return nil, fmt.Errorf("days must be at least 1")
+	}
+
+	// Duplicate deliveries can push received past max_seq; cap per page so
+	// they don't hide losses elsewhere
+	rows, err := db.QueryContext(ctx, `
+		SELECT
+			w.domain,
+			COUNT(*),
+			SUM(s.max_seq)::BIGINT,
+			SUM(LEAST(s.received, s.max_seq))::BIGINT
+		FROM event_sequence s
+		JOIN website w ON w.website_id = s.website_id
+		WHERE s.day >= CURRENT_DATE - $1::int
+		GROUP BY w.domain
+		ORDER BY SUM(s.max_seq - LEAST(s.received, s.max_seq)) DESC, w.domain
+	`, days)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query event sequences: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	summary := &Summary{Days: days, Websites: []Website{}}
+	for rows.Next() {
+		var w Website
+		if err := rows.Scan(&w.Domain, &w.Pages, &w.Sent, &w.Received); err != nil {
+			return nil, err
+		}
+		w.Lost = w.Sent - w.Received
+		w.LossRate = rate(w.Lost, w.Sent)
+		summary.Sent += w.Sent
+		summary.Received += w.Received
+		summary.Websites = append(summary.Websites, w)
+	}
+	summary.Lost = summary.Sent - summary.Received
+	summary.LossRate = rate(summary.Lost, summary.Sent)
+	return summary, rows.Err()
+}
+
+func rate(lost, sent int64) float64 {
+	if sent == 0 {
+		return 0
+	}
+	return float64(lost) / float64(sent) * 100
+}
+
+// Cleanup deletes counters older than keepDays
+func Cleanup(ctx context.Context, db *sql.DB) (int64, error) {
+	res, err := db.ExecContext(ctx, `DELETE FROM event_sequence WHERE day < $1`,
+		time.Now().UTC().AddDate(0, 0, -keepDays).Format("2006-01-02"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete event sequences: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,49 @@
+package eventloss
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReport(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectQuery(`FROM event_sequence s`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"domain", "pages", "sent", "received"}).
+			AddRow("blog.example", 100, 400, 360).
+			AddRow("shop.example", 50, 200, 200))
+
+	summary, err := Report(context.Background(), db, 7)
+	require.NoError(t, err)
+	assert.Equal(t, int64(600), summary.Sent)
+	assert.Equal(t, int64(40), summary.Lost)
+	assert.InDelta(t, 6.67, summary.LossRate, 0.01)
+	require.Len(t, summary.Websites, 2)
+	assert.Equal(t, int64(40), summary.Websites[0].Lost)
+	assert.Equal(t, 10.0, summary.Websites[0].LossRate)
+	assert.Equal(t, 0.0, summary.Websites[1].LossRate)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReportWithoutData(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectQuery(`FROM event_sequence s`).
+		WillReturnRows(sqlmock.NewRows([]string{"domain", "pages", "sent", "received"}))
+
+	summary, err := Report(context.Background(), db, 30)
+	require.NoError(t, err)
+	assert.Zero(t, summary.LossRate)
+	assert.Empty(t, summary.Websites)
+
+	_, err = Report(context.Background(), db, 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,343 @@
+// Package eventprops keeps custom event properties (website_event.props)
+// from bloating the events table.
+//
+// Properties are capped at ingestion: values nested deeper than a website's
+// depth limit are dropped, then the largest values until the properties fit
+// its size limit (website.props_max_bytes and props_max_depth, the defaults
+// when unset).
+//
+// Every property is indexed by the catch-all GIN index on props. A website
+// can instead have only the keys it queries indexed (event_prop_index, with
+// a partial GIN index per key), and the catch-all index can be dropped once
+// the websites that need it have their keys. Largest reports the payloads
+// taking the most room. Indexes and reports need PostgreSQL.
+package eventprops
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/fieldcrypt"
+)
+
+// Defaults and bounds of the limits
+const (
+	DefaultMaxBytes = 8192
+	DefaultMaxDepth = 5
+	MaxBytesLimit   = 1 << 20
+	MaxDepthLimit   = 10
+)
+
+// catchAllIndex is the GIN index on all properties, from the initial schema
+const catchAllIndex = "idx_event_props_gin"
+
+// ErrEncrypted is returned by AddIndex when properties are encrypted at
+// rest: their keys can't be indexed
+var ErrEncrypted = errors.New("event properties are encrypted, their keys can't be indexed")
+
+// ErrNotIndexed is returned by RemoveIndex for a key that isn't indexed
+var ErrNotIndexed = errors.New("property key not indexed")
+
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,50}$`)
+
+// Limits caps a website's event properties; zero fields are the defaults
+type Limits struct {
+	MaxBytes int
+	MaxDepth int
+}
+
+// withDefaults fills the unset limits
+func (l Limits) withDefaults() Limits {
+	if l.MaxBytes <= 0 {
+		l.MaxBytes = DefaultMaxBytes
+	}
+	if l.MaxDepth <= 0 {
+		l.MaxDepth = DefaultMaxDepth
+	}
+	return l
+}
+
+// Validate checks limits set with `kaunta website props limits`
+func (l Limits) Validate() error {
+	if l.MaxBytes < 0 || l.MaxBytes > MaxBytesLimit {
+		return fmt.Errorf("max bytes must be between 1 and %d", MaxBytesLimit)
+	}
+	if l.MaxDepth < 0 || l.MaxDepth > MaxDepthLimit {
+		return fmt.Errorf("max depth must be between 1 and %d", MaxDepthLimit)
+	}
+	return nil
+}
+
+// Index is a property key indexed for a website
+type Index struct {
+	Key       string    `json:"key"`
+	Name      string    `json:"index"`
+	Bytes     int64     `json:"bytes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Payload is an event with large properties
+type Payload struct {
+	Time      time.Time `json:"time"`
+	EventName *string   `json:"event_name"`
+	Path      *string   `json:"path"`
+	Bytes     int64     `json:"bytes"`
+}
+
+// Report is the room properties take on a website
+type Report struct {
+	Days       int       `json:"days"`
+	Events     int64     `json:"events_with_props"`
+	TotalBytes int64     `json:"total_bytes"`
+	Largest    []Payload `json:"largest"`
+}
+
+// Clean caps props to l, returning what is kept and the keys dropped
+// (sorted). Depth counts the properties themselves as 1, so depth 1 keeps
+// only plain values.
+func Clean(props map[string]interface{}, l Limits) (map[string]interface{}, []string) {
+	l = l.withDefaults()
+	kept := make(map[string]interface{}, len(props))
+	sizes := make(map[string]int, len(props))
+	var dropped []string
+	total := 2 // {}
+	for key, value := range props {
+		if depth(value) >= l.MaxDepth {
+			dropped = append(dropped, key)
+			continue
+		}
+		k, _ := json.Marshal(key)
+		v, err := json.Marshal(value)
+		if err != nil {
+			dropped = append(dropped, key)
+			continue
+		}
+		kept[key] = value
+		sizes[key] = len(k) + len(v) + 2 // : and ,
+		total += sizes[key]
+	}
+
+	if total > l.MaxBytes {
+		keys := make([]string, 0, len(kept))
+		for key := range kept {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if sizes[keys[i]] != sizes[keys[j]] {
+				return sizes[keys[i]] > sizes[keys[j]]
+			}
+			return keys[i] < keys[j]
+		})
+		for _, key := range keys {
+			if total <= l.MaxBytes {
+				break
+			}
+			delete(kept, key)
+			total -= sizes[key]
+			dropped = append(dropped, key)
+		}
+	}
+	sort.Strings(dropped)
+	return kept, dropped
+}
+
+// depth is how deep objects and arrays nest in v, 0 for a plain value
+func depth(v interface{}) int {
+	deepest := 0
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, e := range v {
+			deepest = max(deepest, depth(e))
+		}
+	case []interface{}:
+		for _, e := range v {
+			deepest = max(deepest, depth(e))
+		}
+	default:
+		return 0
+	}
+	return deepest + 1
+}
+
+// ValidKey checks a property key can be indexed: letters, digits and _
+func ValidKey(key string) error {
+	if !keyPattern.MatchString(key) {
+		return fmt.Errorf("invalid property key: %q (use letters, digits and _, up to 50 characters)", key)
+	}
+	return nil
+}
+
+// IndexName is the name of the index of a website's key
+func IndexName(websiteID uuid.UUID, key string) string {
+	sum := sha256.Sum256([]byte(websiteID.String() + "/" + key))
+	return "idx_event_prop_" + hex.EncodeToString(sum[:6])
+}
+
+// AddIndex indexes a website's property key. Indexing a key again
+// recreates a missing index. The index is built on every partition of the
+// events table, which locks it against writes while it is built.
+func AddIndex(ctx context.Context, db *sql.DB, websiteID uuid.UUID, key string) error {
+	if err := ValidKey(key); err != nil {
+		return err
+	}
+	if fieldcrypt.Active() != nil {
+		return ErrEncrypted
+	}
+	// DDL can't take parameters: the key is validated and the ID formatted
+	_, err := db.ExecContext(ctx, fmt.Sprintf(`
+		CREATE INDEX IF NOT EXISTS %s ON website_event
+		USING gin ((props -> '%s') jsonb_path_ops)
+		WHERE website_id = '%s' AND props ? '%s'
+	`, IndexName(websiteID, key), key, websiteID, key))
+	if err != nil {
+		return fmt.Errorf("failed to create index: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, `
+		INSERT INTO event_prop_index (website_id, key) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, websiteID, key); err != nil {
+		return fmt.Errorf("failed to record index: %w", err)
+	}
+	return nil
+}
+
+// RemoveIndex drops the index of a website's property key
+func RemoveIndex(ctx context.Context, db *sql.DB, websiteID uuid.UUID, key string) error {
+	res, err := db.ExecContext(ctx,
+		`DELETE FROM event_prop_index WHERE website_id = $1 AND key = $2`, websiteID, key)
+	if err != nil {
+		return fmt.Errorf("failed to remove index: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = ErrNotIndexed
+		}
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `DROP INDEX IF EXISTS `+IndexName(websiteID, key)); err != nil {
+		return fmt.Errorf("failed to drop index: %w", err)
+	}
+	return nil
+}
+
+// ListIndexes returns a website's indexed keys with the size of their
+// index, 0 when it is missing
+func ListIndexes(ctx context.Context, db *sql.DB, websiteID uuid.UUID) ([]Index, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT key, created_at FROM event_prop_index WHERE website_id = $1 ORDER BY key
+	`, websiteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexes: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	indexes := []Index{}
+	for rows.Next() {
+		var i Index
+		if err := rows.Scan(&i.Key, &i.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to read index: %w", err)
+		}
+		i.Name = IndexName(websiteID, i.Key)
+		indexes = append(indexes, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range indexes {
+		if indexes[i].Bytes, err = indexSize(ctx, db, indexes[i].Name); err != nil {
+			return nil, err
+		}
+	}
+	return indexes, nil
+}
+
+// indexSize is the size of an index over all its partitions, 0 when it is
+// missing
+func indexSize(ctx context.Context, db *sql.DB, name string) (int64, error) {
+	var size int64
+	err := db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(pg_relation_size(t.relid)), 0)
+		FROM pg_class c, pg_partition_tree(c.oid) t
+		WHERE c.relname = $1 AND c.relkind IN ('i', 'I')
+	`, name).Scan(&size)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read index size: %w", err)
+	}
+	return size, nil
+}
+
+// CatchAll reports whether the catch-all properties index exists, and its
+// size
+func CatchAll(ctx context.Context, db *sql.DB) (bool, int64, error) {
+	var exists bool
+	if err := db.QueryRowContext(ctx,
+		`SELECT to_regclass($1) IS NOT NULL`, catchAllIndex).Scan(&exists); err != nil {
+		return false, 0, fmt.Errorf("failed to look up index: %w", err)
+	}
+	if !exists {
+		return false, 0, nil
+	}
+	size, err := indexSize(ctx, db, catchAllIndex)
+	return true, size, err
+}
+
+// SetCatchAll creates or drops the catch-all properties index, for all
+// websites
+func SetCatchAll(ctx context.Context, db *sql.DB, on bool) error {
+	query := `DROP INDEX IF EXISTS ` + catchAllIndex
+	if on {
+		query = `CREATE INDEX IF NOT EXISTS ` + catchAllIndex +
+			` ON website_event USING gin (props jsonb_path_ops) WHERE props IS NOT NULL`
+	}
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to update index: %w", err)
+	}
+	return nil
+}
+
+// Largest reports the room properties took on a website over the last days,
+// with its limit largest payloads
+func Largest(ctx context.Context, db *sql.DB, websiteID uuid.UUID, days, limit int) (*Report, error) {
+	report := Report{Days: days, Largest: []Payload{}}
+	err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(pg_column_size(props)), 0)
+		FROM website_event
+		WHERE website_id = $1 AND props IS NOT NULL
+		  AND created_at >= NOW() - make_interval(days => $2)
+	`, websiteID, days).Scan(&report.Events, &report.TotalBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to measure properties: %w", err)
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT created_at, event_name, url_path, pg_column_size(props)
+		FROM website_event
+		WHERE website_id = $1 AND props IS NOT NULL
+		  AND created_at >= NOW() - make_interval(days => $2)
+		ORDER BY 4 DESC, created_at DESC
+		LIMIT $3
+	`, websiteID, days, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query properties: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var p Payload
+		if err := rows.Scan(&p.Time, &p.EventName, &p.Path, &p.Bytes); err != nil {
+			return nil, fmt.Errorf("failed to read properties: %w", err)
+		}
+		report.Largest = append(report.Largest, p)
+	}
+	return &report, rows.Err()
+}
@@ -0,0 +1,141 @@
+package eventprops
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/test"
+)
+
+func TestCleanDepth(t *testing.T) {
+	props := map[string]interface{}{
+		"plan":  "pro",
+		"cart":  map[string]interface{}{"total": 42.0},
+		"items": []interface{}{map[string]interface{}{"sku": "a1"}},
+		"empty": map[string]interface{}{},
+	}
+
+	kept, dropped := Clean(props, Limits{MaxDepth: 2})
+	assert.Equal(t, []string{"items"}, dropped)
+	assert.Contains(t, kept, "cart")
+	assert.Contains(t, kept, "empty")
+
+	kept, dropped = Clean(props, Limits{MaxDepth: 1})
+	assert.Equal(t, []string{"cart", "empty", "items"}, dropped)
+	assert.Equal(t, map[string]interface{}{"plan": "pro"}, kept)
+}
+
+func TestCleanSize(t *testing.T) {
+	props := map[string]interface{}{
+		"plan":    "pro",
+		"note":    strings.Repeat("x", 200),
+		"comment": strings.Repeat("y", 100),
+	}
+
+	kept, dropped := Clean(props, Limits{MaxBytes: 150})
+	assert.Equal(t, []string{"note"}, dropped)
+	assert.Len(t, kept, 2)
+
+	kept, dropped = Clean(props, Limits{MaxBytes: 50})
+	assert.Equal(t, []string{"comment", "note"}, dropped)
+	assert.Equal(t, map[string]interface{}{"plan": "pro"}, kept)
+
+	kept, dropped = Clean(props, Limits{})
+	assert.Empty(t, dropped)
+	assert.Len(t, kept, 3)
+}
+
+func TestLimitsValidate(t *testing.T) {
+	assert.NoError(t, Limits{}.Validate())
+	assert.NoError(t, Limits{MaxBytes: 2048, MaxDepth: 2}.Validate())
+	assert.Error(t, Limits{MaxBytes: MaxBytesLimit + 1}.Validate())
+	assert.Error(t, Limits{MaxDepth: -1}.Validate())
+	assert.Error(t, Limits{MaxDepth: MaxDepthLimit + 1}.Validate())
+}
+
+func TestValidKey(t *testing.T) {
+	for _, key := range []string{"plan", "Plan_2", "a"} {
+		assert.NoError(t, ValidKey(key), key)
+	}
+	for _, key := range []string{"", "plan-tier", "plan'; DROP TABLE website; --", "a.b", strings.Repeat("a", 51)} {
+		assert.Error(t, ValidKey(key), key)
+	}
+}
+
+func TestAddIndex(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+	name := IndexName(websiteID, "plan")
+	assert.Regexp(t, `^idx_event_prop_[0-9a-f]{12}$`, name)
+
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE INDEX IF NOT EXISTS ` + name + ` ON website_event
+		USING gin ((props -> 'plan') jsonb_path_ops)
+		WHERE website_id = '` + websiteID.String() + `' AND props ? 'plan'`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO event_prop_index`).WithArgs(websiteID, "plan").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, AddIndex(context.Background(), db, websiteID, "plan"))
+	assert.Error(t, AddIndex(context.Background(), db, websiteID, "plan'"))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRemoveIndex(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+
+	mock.ExpectExec(`DELETE FROM event_prop_index`).WithArgs(websiteID, "plan").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DROP INDEX IF EXISTS ` + IndexName(websiteID, "plan")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, RemoveIndex(context.Background(), db, websiteID, "plan"))
+
+	mock.ExpectExec(`DELETE FROM event_prop_index`).WithArgs(websiteID, "plan").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.ErrorIs(t, RemoveIndex(context.Background(), db, websiteID, "plan"), ErrNotIndexed)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListIndexes(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`FROM event_prop_index`).WithArgs(websiteID).
+		WillReturnRows(sqlmock.NewRows([]string{"key", "created_at"}).AddRow("plan", created))
+	mock.ExpectQuery(`pg_partition_tree`).WithArgs(IndexName(websiteID, "plan")).
+		WillReturnRows(sqlmock.NewRows([]string{"size"}).AddRow(int64(16384)))
+
+	indexes, err := ListIndexes(context.Background(), db, websiteID)
+	require.NoError(t, err)
+	assert.Equal(t, []Index{{Key: "plan", Name: IndexName(websiteID, "plan"), Bytes: 16384, CreatedAt: created}}, indexes)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLargest(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	name, path := "checkout", "/cart"
+
+	mock.ExpectQuery(`SUM\(pg_column_size\(props\)\)`).WithArgs(websiteID, 30).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "sum"}).AddRow(int64(120), int64(48000)))
+	mock.ExpectQuery(`ORDER BY 4 DESC`).WithArgs(websiteID, 30, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "event_name", "url_path", "size"}).
+			AddRow(at, name, path, int64(6000)))
+
+	report, err := Largest(context.Background(), db, websiteID, 30, 5)
+	require.NoError(t, err)
+	assert.Equal(t, int64(120), report.Events)
+	assert.Equal(t, int64(48000), report.TotalBytes)
+	assert.Equal(t, []Payload{{Time: at, EventName: &name, Path: &path, Bytes: 6000}}, report.Largest)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
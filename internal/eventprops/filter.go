@@ -0,0 +1,152 @@
+package eventprops
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FilterPrefix marks a property filter parameter: prop.plan=pro
+const FilterPrefix = "prop."
+
+// ErrFilterEncrypted is returned for property filters PostgreSQL can't
+// apply because properties are encrypted at rest
+var ErrFilterEncrypted = errors.New("event properties are encrypted, they can't be filtered")
+
+// Filter keeps the events whose properties have the given values, by key.
+// A value matches the property as a string, or as the number or boolean it
+// reads as (prop.seats=3 matches "3" and 3); an array property matches when
+// it holds the value, except on SQLite and ClickHouse.
+type Filter map[string]string
+
+// ParseFilter reads the prop.<key>=value parameters of params, skipping
+// empty values
+func ParseFilter(params map[string]string) (Filter, error) {
+	var f Filter
+	for name, value := range params {
+		key, ok := strings.CutPrefix(name, FilterPrefix)
+		if !ok || value == "" {
+			continue
+		}
+		if err := ValidKey(key); err != nil {
+			return nil, err
+		}
+		if f == nil {
+			f = make(Filter)
+		}
+		f[key] = value
+	}
+	return f, nil
+}
+
+// ParseFilterArgs reads key=value arguments, as given on the command line
+func ParseFilterArgs(args []string) (Filter, error) {
+	params := make(map[string]string, len(args))
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("invalid property filter: %q (use key=value)", arg)
+		}
+		params[FilterPrefix+key] = value
+	}
+	return ParseFilter(params)
+}
+
+// keys returns the filtered keys, sorted so queries are stable
+func (f Filter) keys() []string {
+	keys := make([]string, 0, len(f))
+	for key := range f {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Values returns the JSON values matching value: the string, then the
+// number or boolean it reads as
+func Values(value string) []string {
+	quoted, _ := json.Marshal(value)
+	values := []string{string(quoted)}
+	if value == "true" || value == "false" {
+		return append(values, value)
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil && json.Valid([]byte(value)) {
+		values = append(values, value)
+	}
+	return values
+}
+
+// Condition writes the PostgreSQL conditions keeping the events of column
+// (their props) that f matches, binding the values with bind; "" when f is
+// empty. Keys are validated, so they are written inline: the conditions
+// then match the partial index of each key indexed for the website
+// (AddIndex), and the catch-all index otherwise.
+func (f Filter) Condition(column string, bind func(interface{}) string) string {
+	var conditions []string
+	for _, key := range f.keys() {
+		var matches []string
+		for _, value := range Values(f[key]) {
+			matches = append(matches, fmt.Sprintf("%s -> '%s' @> %s::jsonb", column, key, bind(value)))
+		}
+		conditions = append(conditions, fmt.Sprintf("%s ? '%s' AND (%s)", column, key, strings.Join(matches, " OR ")))
+	}
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " AND " + strings.Join(conditions, " AND ")
+}
+
+// Matches reports whether props (an event's decoded properties) have the
+// filtered values, for properties PostgreSQL can't read
+func (f Filter) Matches(props map[string]json.RawMessage) bool {
+	for key, value := range f {
+		raw, ok := props[key]
+		if !ok || !matchesValue(raw, Values(value)) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesValue reports whether raw is one of values, or an array holding
+// one of them
+func matchesValue(raw json.RawMessage, values []string) bool {
+	var decoded interface{}
+	if json.Unmarshal(raw, &decoded) != nil {
+		return false
+	}
+	candidates := []interface{}{decoded}
+	if items, ok := decoded.([]interface{}); ok {
+		candidates = items
+	}
+	for _, value := range values {
+		var want interface{}
+		_ = json.Unmarshal([]byte(value), &want)
+		// want is a string, number or boolean, so comparing never panics
+		for _, c := range candidates {
+			if c == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// JSON returns f as a JSON object of each key's matching values, for SQL
+// functions taking the filter as one parameter; nil when f is empty
+func (f Filter) JSON() interface{} {
+	if len(f) == 0 {
+		return nil
+	}
+	doc := make(map[string][]json.RawMessage, len(f))
+	for key, value := range f {
+		for _, v := range Values(value) {
+			doc[key] = append(doc[key], json.RawMessage(v))
+		}
+	}
+	data, _ := json.Marshal(doc)
+	return string(data)
+}
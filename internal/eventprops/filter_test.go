@@ -0,0 +1,79 @@
+package eventprops
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	f, err := ParseFilter(map[string]string{"prop.plan": "pro", "prop.seats": "", "prop": "plan", "page": "/"})
+	require.NoError(t, err)
+	assert.Equal(t, Filter{"plan": "pro"}, f)
+
+	f, err = ParseFilter(map[string]string{"page": "/"})
+	require.NoError(t, err)
+	assert.Nil(t, f)
+
+	_, err = ParseFilter(map[string]string{"prop.plan') OR ('1": "pro"})
+	assert.Error(t, err)
+}
+
+func TestParseFilterArgs(t *testing.T) {
+	f, err := ParseFilterArgs([]string{"plan=pro", "ref=a=b"})
+	require.NoError(t, err)
+	assert.Equal(t, Filter{"plan": "pro", "ref": "a=b"}, f)
+
+	_, err = ParseFilterArgs([]string{"plan"})
+	assert.EqualError(t, err, `invalid property filter: "plan" (use key=value)`)
+	_, err = ParseFilterArgs([]string{"pl an=pro"})
+	assert.Error(t, err)
+}
+
+func TestValues(t *testing.T) {
+	assert.Equal(t, []string{`"pro"`}, Values("pro"))
+	assert.Equal(t, []string{`"3"`, "3"}, Values("3"))
+	assert.Equal(t, []string{`"1.5"`, "1.5"}, Values("1.5"))
+	assert.Equal(t, []string{`"true"`, "true"}, Values("true"))
+	// Only JSON numbers: NaN and hex parse as floats but aren't JSON
+	assert.Equal(t, []string{`"NaN"`}, Values("NaN"))
+	assert.Equal(t, []string{`"0x10"`}, Values("0x10"))
+}
+
+func TestCondition(t *testing.T) {
+	var args []interface{}
+	bind := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	assert.Empty(t, Filter(nil).Condition("e.props", bind))
+
+	cond := Filter{"seats": "3", "plan": "pro"}.Condition("e.props", bind)
+	assert.Equal(t, " AND e.props ? 'plan' AND (e.props -> 'plan' @> $1::jsonb)"+
+		" AND e.props ? 'seats' AND (e.props -> 'seats' @> $2::jsonb OR e.props -> 'seats' @> $3::jsonb)", cond)
+	assert.Equal(t, []interface{}{`"pro"`, `"3"`, "3"}, args)
+}
+
+func TestMatches(t *testing.T) {
+	props := func(doc string) map[string]json.RawMessage {
+		var p map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal([]byte(doc), &p))
+		return p
+	}
+	f := Filter{"plan": "pro", "seats": "3"}
+
+	assert.True(t, f.Matches(props(`{"plan":"pro","seats":3}`)))
+	assert.True(t, f.Matches(props(`{"plan":["free","pro"],"seats":"3","other":1}`)))
+	assert.False(t, f.Matches(props(`{"plan":"pro"}`)))
+	assert.False(t, f.Matches(props(`{"plan":"pro","seats":4}`)))
+	assert.False(t, f.Matches(props(`{"plan":{"name":"pro"},"seats":3}`)))
+	assert.True(t, Filter(nil).Matches(props(`{}`)))
+}
+
+func TestFilterJSON(t *testing.T) {
+	assert.Nil(t, Filter(nil).JSON())
+	assert.JSONEq(t, `{"plan":["pro"],"seats":["3",3]}`, Filter{"plan": "pro", "seats": "3"}.JSON().(string))
+}
@@ -0,0 +1,243 @@
+// Package exclusions keeps internal traffic out of a website's stats.
+//
+// A website has rules of three types (website_exclusion):
+//
+//   - ip: an address or CIDR range, e.g. the office network
+//   - hostname: the page's hostname, exact (staging.example.com) or with a
+//     leading wildcard (*.internal.example.com, which also matches
+//     internal.example.com)
+//   - path: a URL path pattern where * matches any characters (/admin/*)
+//
+// The tracking endpoint drops a request matching any rule before recording
+// anything about it.
+package exclusions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Rule types
+const (
+	IP       = "ip"
+	Hostname = "hostname"
+	Path     = "path"
+)
+
+// MaxValueLength matches the website_exclusion.value column
+const MaxValueLength = 500
+
+// ErrNotFound is returned by Remove for a rule that doesn't exist
+var ErrNotFound = errors.New("exclusion rule not found")
+
+// Rule is an exclusion rule of a website
+type Rule struct {
+	Type      string    `json:"type"`
+	Value     string    `json:"value"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Normalize validates a rule and returns its value in stored form: IP
+// ranges as CIDR, hostnames lowercased, paths with a leading slash
+func Normalize(ruleType, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || len(value) > MaxValueLength {
+		return "", fmt.Errorf("invalid %s rule: value must be 1-%d characters", ruleType, MaxValueLength)
+	}
+
+	switch ruleType {
+	case IP:
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return "", fmt.Errorf("invalid ip rule: %s (use an address or CIDR range)", value)
+			}
+			if ip.To4() != nil {
+				return ip.String() + "/32", nil
+			}
+			return ip.String() + "/128", nil
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid ip rule: %s (use an address or CIDR range)", value)
+		}
+		return network.String(), nil
+	case Hostname:
+		host := strings.ToLower(strings.TrimSuffix(value, "."))
+		if strings.ContainsAny(strings.TrimPrefix(host, "*."), "*/: ") {
+			return "", fmt.Errorf("invalid hostname rule: %s (use host.example.com or *.example.com)", value)
+		}
+		return host, nil
+	case Path:
+		if !strings.HasPrefix(value, "/") && !strings.HasPrefix(value, "*") {
+			value = "/" + value
+		}
+		return value, nil
+	default:
+		return "", fmt.Errorf("invalid rule type: %s (use ip, hostname or path)", ruleType)
+	}
+}
+
+// Matcher checks requests against a website's rules
+type Matcher struct {
+	networks  []*net.IPNet
+	hostnames []string
+	paths     []string
+}
+
+// NewMatcher compiles rules; rules that don't parse are skipped
+func NewMatcher(rules []Rule) *Matcher {
+	m := &Matcher{}
+	for _, r := range rules {
+		switch r.Type {
+		case IP:
+			if _, network, err := net.ParseCIDR(r.Value); err == nil {
+				m.networks = append(m.networks, network)
+			}
+		case Hostname:
+			m.hostnames = append(m.hostnames, r.Value)
+		case Path:
+			m.paths = append(m.paths, r.Value)
+		}
+	}
+	return m
+}
+
+// Empty reports whether the matcher has no rules
+func (m *Matcher) Empty() bool {
+	return m == nil || len(m.networks)+len(m.hostnames)+len(m.paths) == 0
+}
+
+// Match reports whether a request from ip for hostname and path is excluded.
+// Empty arguments match no rule of their type.
+func (m *Matcher) Match(ip, hostname, path string) bool {
+	if m.Empty() {
+		return false
+	}
+	if parsed := net.ParseIP(ip); parsed != nil {
+		for _, network := range m.networks {
+			if network.Contains(parsed) {
+				return true
+			}
+		}
+	}
+	if hostname != "" {
+		hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+		for _, rule := range m.hostnames {
+			if matchHostname(rule, hostname) {
+				return true
+			}
+		}
+	}
+	if path != "" {
+		for _, rule := range m.paths {
+			if matchWildcard(rule, path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchHostname matches host.example.com exactly, and *.example.com against
+// example.com and any of its subdomains
+func matchHostname(rule, hostname string) bool {
+	if base, ok := strings.CutPrefix(rule, "*."); ok {
+		return hostname == base || strings.HasSuffix(hostname, "."+base)
+	}
+	return hostname == rule
+}
+
+// matchWildcard matches s against pattern, where * matches any (possibly
+// empty) run of characters
+func matchWildcard(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, last)
+}
+
+// List returns a website's rules by type and value
+func List(ctx context.Context, db *sql.DB, websiteID uuid.UUID) ([]Rule, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT rule_type, value, created_at
+		FROM website_exclusion
+		WHERE website_id = $1
+		ORDER BY rule_type, value
+	`, websiteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list exclusion rules: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var rules []Rule
+	for rows.Next() {
+		var r Rule
+		if err := rows.Scan(&r.Type, &r.Value, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// Add stores a rule and returns its normalized value. Adding an existing
+// rule again is a no-op.
+func Add(ctx context.Context, db *sql.DB, websiteID uuid.UUID, ruleType, value string) (string, error) {
+	value, err := Normalize(ruleType, value)
+	if err != nil {
+		return "", err
+	}
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO website_exclusion (website_id, rule_type, value)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (website_id, rule_type, value) DO NOTHING
+	`, websiteID, ruleType, value)
+	if err != nil {
+		return "", fmt.Errorf("failed to add exclusion rule: %w", err)
+	}
+	return value, nil
+}
+
+// Remove deletes a rule; value is normalized first, so 10.0.0.1 removes the
+// 10.0.0.1/32 rule
+func Remove(ctx context.Context, db *sql.DB, websiteID uuid.UUID, ruleType, value string) error {
+	value, err := Normalize(ruleType, value)
+	if err != nil {
+		return err
+	}
+	res, err := db.ExecContext(ctx,
+		`DELETE FROM website_exclusion WHERE website_id = $1 AND rule_type = $2 AND value = $3`,
+		websiteID, ruleType, value)
+	if err != nil {
+		return fmt.Errorf("failed to remove exclusion rule: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package exclusions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		ruleType, value, want string
+	}{
+		{IP, "203.0.113.7", "203.0.113.7/32"},
+		{IP, "10.1.2.3/8", "10.0.0.0/8"},
+		{IP, "2001:db8::1", "2001:db8::1/128"},
+		{Hostname, " Staging.Example.com. ", "staging.example.com"},
+		{Hostname, "*.internal.example.com", "*.internal.example.com"},
+		{Path, "admin/*", "/admin/*"},
+		{Path, "*/preview", "*/preview"},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(tt.ruleType, tt.value)
+		require.NoError(t, err, tt.value)
+		assert.Equal(t, tt.want, got, tt.value)
+	}
+
+	for _, bad := range [][2]string{{IP, "office"}, {IP, "10.0.0.0/33"}, {Hostname, "a*.example.com"}, {Hostname, "https://example.com"}, {Path, ""}, {"country", "FR"}} {
+		_, err := Normalize(bad[0], bad[1])
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	m := NewMatcher([]Rule{
+		{Type: IP, Value: "10.0.0.0/8"},
+		{Type: IP, Value: "2001:db8::/32"},
+		{Type: Hostname, Value: "localhost"},
+		{Type: Hostname, Value: "*.staging.example.com"},
+		{Type: Path, Value: "/admin/*"},
+		{Type: Path, Value: "*/preview"},
+	})
+
+	assert.True(t, m.Match("10.20.30.40", "", ""))
+	assert.True(t, m.Match("2001:db8::7", "", ""))
+	assert.False(t, m.Match("203.0.113.7", "example.com", "/blog"))
+
+	assert.True(t, m.Match("", "LOCALHOST", ""))
+	assert.True(t, m.Match("", "staging.example.com", ""))
+	assert.True(t, m.Match("", "eu.staging.example.com", ""))
+	assert.False(t, m.Match("", "notstaging.example.com", ""))
+
+	assert.True(t, m.Match("", "", "/admin/users"))
+	assert.True(t, m.Match("", "", "/admin/"))
+	assert.False(t, m.Match("", "", "/admin"))
+	assert.True(t, m.Match("", "", "/posts/42/preview"))
+	assert.False(t, m.Match("", "", "/posts/42/preview/comments"))
+}
+
+func TestEmptyMatcher(t *testing.T) {
+	var m *Matcher
+	assert.True(t, m.Empty())
+	assert.False(t, m.Match("10.0.0.1", "localhost", "/admin"))
+	assert.True(t, NewMatcher(nil).Empty())
+}
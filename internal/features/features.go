@@ -0,0 +1,243 @@
+// Package features switches optional and experimental subsystems on and off
+// at runtime, so operators can try them per instance or per website without
+// rebuilding, and the dashboard can discover what is available.
+//
+// A flag's value comes from, in order of precedence:
+//
+//  1. a website override (feature_flag row of the website)
+//  2. an instance override (feature_flag row without a website)
+//  3. the features setting of the config (`features = "name,-name"`)
+//  4. the flag's default
+//
+// Overrides live in PostgreSQL; without a database only the config and the
+// defaults apply.
+package features
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Flag is an optional subsystem. New experimental subsystems register a flag
+// here, off by default, and check Enabled before serving.
+type Flag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Default     bool   `json:"default"`
+}
+
+// Flags are the known flags, by name
+var Flags = []Flag{
+	{Name: "sessions", Description: "Session list and visitor journeys on the dashboard API", Default: true},
+}
+
+// Sources of a flag's value
+const (
+	SourceDefault  = "default"
+	SourceConfig   = "config"
+	SourceInstance = "instance"
+	SourceWebsite  = "website"
+)
+
+// State is a flag's value for the instance or a website
+type State struct {
+	Flag
+	Enabled bool   `json:"enabled"`
+	Source  string `json:"source"`
+}
+
+var (
+	mu         sync.RWMutex
+	configured = map[string]bool{}
+	dbFunc     = func() *sql.DB { return nil }
+)
+
+// Lookup returns the flag called name
+func Lookup(name string) (Flag, bool) {
+	for _, f := range Flags {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Flag{}, false
+}
+
+// Names lists the known flags
+func Names() []string {
+	names := make([]string, len(Flags))
+	for i, f := range Flags {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// Configure sets the values from the config and where overrides are read
+// from. Unknown flags are kept (a newer release may know them) and returned
+// in the error so they can be reported.
+func Configure(values map[string]bool, db func() *sql.DB) error {
+	var unknown []string
+	set := make(map[string]bool, len(values))
+	for name, enabled := range values {
+		if _, ok := Lookup(name); !ok {
+			unknown = append(unknown, name)
+		}
+		set[name] = enabled
+	}
+
+	mu.Lock()
+	configured = set
+	if db != nil {
+		dbFunc = db
+	}
+	mu.Unlock()
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown feature flags: %s (known: %s)", strings.Join(unknown, ", "), strings.Join(Names(), ", "))
+	}
+	return nil
+}
+
+// Resolve returns the state of every flag for a website, or for the
+// instance when websiteID is nil, with the overrides stored in db (none when
+// db is nil)
+func Resolve(ctx context.Context, db *sql.DB, websiteID *uuid.UUID) ([]State, error) {
+	mu.RLock()
+	config := configured
+	mu.RUnlock()
+
+	states := make([]State, len(Flags))
+	byName := make(map[string]*State, len(Flags))
+	for i, f := range Flags {
+		states[i] = State{Flag: f, Enabled: f.Default, Source: SourceDefault}
+		if enabled, ok := config[f.Name]; ok {
+			states[i].Enabled, states[i].Source = enabled, SourceConfig
+		}
+		byName[f.Name] = &states[i]
+	}
+	if db == nil {
+		return states, nil
+	}
+
+	overrides, err := List(ctx, db, websiteID)
+	if err != nil {
+		return states, err
+	}
+	// Instance overrides first, so the website's win
+	sort.SliceStable(overrides, func(i, j int) bool { return overrides[i].WebsiteID == nil && overrides[j].WebsiteID != nil })
+	for _, o := range overrides {
+		if s, ok := byName[o.Name]; ok {
+			s.Enabled = o.Enabled
+			s.Source = SourceInstance
+			if o.WebsiteID != nil {
+				s.Source = SourceWebsite
+			}
+		}
+	}
+	return states, nil
+}
+
+// Enabled reports whether a flag is on for a website, with the overrides of
+// the database given to Configure. When they can't be read, the config and
+// default decide.
+func Enabled(ctx context.Context, name string, websiteID uuid.UUID) bool {
+	mu.RLock()
+	db := dbFunc()
+	mu.RUnlock()
+
+	states, _ := Resolve(ctx, db, &websiteID)
+	for _, s := range states {
+		if s.Name == name {
+			return s.Enabled
+		}
+	}
+	return false
+}
+
+// Override is a flag set in the database, for the instance when WebsiteID is
+// nil
+type Override struct {
+	Name      string     `json:"name"`
+	WebsiteID *uuid.UUID `json:"website_id,omitempty"`
+	Enabled   bool       `json:"enabled"`
+}
+
+// ErrUnknown is returned for names no flag is registered under
+var ErrUnknown = errors.New("unknown feature flag")
+
+// List returns the instance overrides and, when websiteID isn't nil, the
+// website's
+func List(ctx context.Context, db *sql.DB, websiteID *uuid.UUID) ([]Override, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT name, website_id, enabled
+		FROM feature_flag
+		WHERE website_id IS NULL OR website_id = $1
+		ORDER BY name`, websiteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list feature flags: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var list []Override
+	for rows.Next() {
+		var o Override
+		var website uuid.NullUUID
+		if err := rows.Scan(&o.Name, &website, &o.Enabled); err != nil {
+			return nil, err
+		}
+		if website.Valid {
+			o.WebsiteID = &website.UUID
+		}
+		list = append(list, o)
+	}
+	return list, rows.Err()
+}
+
+// Set overrides a flag for a website, or for the instance when websiteID is
+// nil
+func Set(ctx context.Context, db *sql.DB, name string, websiteID *uuid.UUID, enabled bool) error {
+	if _, ok := Lookup(name); !ok {
+		return fmt.Errorf("%w: %s (known: %s)", ErrUnknown, name, strings.Join(Names(), ", "))
+	}
+
+	// The unique index on (name, website_id) doesn't cover instance rows,
+	// whose website_id is NULL
+	conflict := `(name, website_id)`
+	if websiteID == nil {
+		conflict = `(name) WHERE website_id IS NULL`
+	}
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO feature_flag (name, website_id, enabled)
+		VALUES ($1, $2, $3)
+		ON CONFLICT `+conflict+` DO UPDATE
+		SET enabled = EXCLUDED.enabled, updated_at = NOW()
+	`, name, websiteID, enabled)
+	if err != nil {
+		return fmt.Errorf("failed to set feature flag: %w", err)
+	}
+	return nil
+}
+
+// Reset removes a flag's override of a website, or of the instance when
+// websiteID is nil; it reports whether there was one
+func Reset(ctx context.Context, db *sql.DB, name string, websiteID *uuid.UUID) (bool, error) {
+	query := `DELETE FROM feature_flag WHERE name = $1 AND website_id = $2`
+	args := []interface{}{name, websiteID}
+	if websiteID == nil {
+		query = `DELETE FROM feature_flag WHERE name = $1 AND website_id IS NULL`
+		args = args[:1]
+	}
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to reset feature flag: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,90 @@
+package features
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withFlags(t *testing.T, flags []Flag) {
+	t.Helper()
+	original := Flags
+	Flags = flags
+	t.Cleanup(func() {
+		Flags = original
+		_ = Configure(nil, nil)
+		dbFunc = func() *sql.DB { return nil }
+	})
+}
+
+func TestConfigureReportsUnknownFlags(t *testing.T) {
+	withFlags(t, []Flag{{Name: "funnels"}})
+
+	err := Configure(map[string]bool{"funnels": true, "warp": true}, nil)
+	assert.EqualError(t, err, "unknown feature flags: warp (known: funnels)")
+
+	states, err := Resolve(context.Background(), nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []State{{Flag: Flag{Name: "funnels"}, Enabled: true, Source: SourceConfig}}, states)
+}
+
+func TestResolvePrecedence(t *testing.T) {
+	withFlags(t, []Flag{{Name: "funnels"}, {Name: "vitals", Default: true}, {Name: "errors"}})
+	require.NoError(t, Configure(map[string]bool{"errors": true}, nil))
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	websiteID := uuid.New()
+
+	// Rows come by name, so the website's vitals row precedes the instance's
+	mock.ExpectQuery(`FROM feature_flag\s+WHERE website_id IS NULL OR website_id = \$1`).WithArgs(&websiteID).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "website_id", "enabled"}).
+			AddRow("funnels", nil, true).
+			AddRow("vitals", websiteID, true).
+			AddRow("vitals", nil, false))
+
+	states, err := Resolve(context.Background(), db, &websiteID)
+	require.NoError(t, err)
+	assert.Equal(t, []State{
+		{Flag: Flag{Name: "funnels"}, Enabled: true, Source: SourceInstance},
+		{Flag: Flag{Name: "vitals", Default: true}, Enabled: true, Source: SourceWebsite},
+		{Flag: Flag{Name: "errors"}, Enabled: true, Source: SourceConfig},
+	}, states)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEnabledFallsBackWhenOverridesFail(t *testing.T) {
+	withFlags(t, []Flag{{Name: "funnels", Default: true}})
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	require.NoError(t, Configure(nil, func() *sql.DB { return db }))
+
+	mock.ExpectQuery(`FROM feature_flag`).WillReturnError(sql.ErrConnDone)
+
+	assert.True(t, Enabled(context.Background(), "funnels", uuid.New()))
+	assert.False(t, Enabled(context.Background(), "unknown", uuid.New()))
+}
+
+func TestSetInstanceOverride(t *testing.T) {
+	withFlags(t, []Flag{{Name: "funnels"}})
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectExec(`ON CONFLICT \(name\) WHERE website_id IS NULL DO UPDATE`).
+		WithArgs("funnels", nil, true).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, Set(context.Background(), db, "funnels", nil, true))
+	assert.ErrorIs(t, Set(context.Background(), db, "warp", nil, true), ErrUnknown)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
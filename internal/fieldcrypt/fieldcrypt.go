@@ -0,0 +1,247 @@
+// Package fieldcrypt provides optional application-level encryption for
+// sensitive columns (session.distinct_id, website_event.props).
+//
+// Values are sealed with AES-256-GCM and stored as
+//
+//	kenc:v1:<key-id>:<base64url(nonce||ciphertext)>
+//
+// The key id lets a keyring hold the current key plus retired ones, so rows
+// written before a rotation can still be read until `kaunta encryption rotate`
+// re-encrypts them.
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Prefix marks a value sealed by this package
+const Prefix = "kenc:v1:"
+
+// KeySize is the required raw key length (AES-256)
+const KeySize = 32
+
+var (
+	// ErrUnknownKey is returned when a value was sealed with a key that is not in the keyring
+	ErrUnknownKey = errors.New("fieldcrypt: value encrypted with unknown key")
+	// ErrMalformed is returned when an encrypted value cannot be parsed
+	ErrMalformed = errors.New("fieldcrypt: malformed encrypted value")
+)
+
+type key struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// Keyring holds the primary key used for writes and any previous keys still accepted for reads
+type Keyring struct {
+	primary *key
+	keys    map[string]*key
+}
+
+// NewKeyring builds a keyring from base64-encoded 32-byte keys.
+// The primary key encrypts new values; previous keys only decrypt.
+func NewKeyring(primary string, previous ...string) (*Keyring, error) {
+	pk, err := parseKey(primary)
+	if err != nil {
+		return nil, fmt.Errorf("primary key: %w", err)
+	}
+
+	kr := &Keyring{
+		primary: pk,
+		keys:    map[string]*key{pk.id: pk},
+	}
+
+	for i, raw := range previous {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		k, err := parseKey(raw)
+		if err != nil {
+			return nil, fmt.Errorf("previous key %d: %w", i+1, err)
+		}
+		kr.keys[k.id] = k
+	}
+
+	return kr, nil
+}
+
+// GenerateKey returns a new random base64-encoded key suitable for NewKeyring
+func GenerateKey() (string, error) {
+	buf := make([]byte, KeySize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
+
+func parseKey(encoded string) (*key, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64: %w", err)
+	}
+	if len(raw) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(raw))
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(raw)
+	return &key{id: hex.EncodeToString(sum[:4]), aead: aead}, nil
+}
+
+// PrimaryKeyID returns the id of the key used for new writes
+func (k *Keyring) PrimaryKeyID() string {
+	return k.primary.id
+}
+
+// Encrypt seals plaintext with the primary key
+func (k *Keyring) Encrypt(plaintext []byte) (string, error) {
+	nonce := make([]byte, k.primary.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := k.primary.aead.Seal(nonce, nonce, plaintext, nil)
+	return Prefix + k.primary.id + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value produced by Encrypt. Values without the prefix are
+// returned unchanged so plaintext rows written before encryption was enabled
+// keep working.
+func (k *Keyring) Decrypt(value string) ([]byte, error) {
+	if !IsEncrypted(value) {
+		return []byte(value), nil
+	}
+
+	keyID, payload, ok := strings.Cut(strings.TrimPrefix(value, Prefix), ":")
+	if !ok {
+		return nil, ErrMalformed
+	}
+
+	kk, found := k.keys[keyID]
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || len(sealed) < kk.aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+
+	nonce, ciphertext := sealed[:kk.aead.NonceSize()], sealed[kk.aead.NonceSize():]
+	return kk.aead.Open(nil, nonce, ciphertext, nil)
+}
+
+// NeedsRotation reports whether value is plaintext or sealed with a non-primary key
+func (k *Keyring) NeedsRotation(value string) bool {
+	return !strings.HasPrefix(value, Prefix+k.primary.id+":")
+}
+
+// IsEncrypted reports whether value carries the fieldcrypt prefix
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+var (
+	activeMu sync.RWMutex
+	active   *Keyring
+)
+
+// Configure installs the process-wide keyring. Passing nil disables encryption.
+func Configure(k *Keyring) {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	active = k
+}
+
+// Active returns the process-wide keyring, or nil when encryption is disabled
+func Active() *Keyring {
+	activeMu.RLock()
+	defer activeMu.RUnlock()
+	return active
+}
+
+// Enabled reports whether column encryption is configured
+func Enabled() bool {
+	return Active() != nil
+}
+
+// EncryptString seals a nullable column value with the active keyring.
+// It is a no-op when encryption is disabled or the value is nil.
+func EncryptString(value *string) (*string, error) {
+	k := Active()
+	if k == nil || value == nil {
+		return value, nil
+	}
+
+	sealed, err := k.Encrypt([]byte(*value))
+	if err != nil {
+		return nil, err
+	}
+	return &sealed, nil
+}
+
+// DecryptString opens a nullable column value sealed by EncryptString
+func DecryptString(value *string) (*string, error) {
+	if value == nil || !IsEncrypted(*value) {
+		return value, nil
+	}
+
+	k := Active()
+	if k == nil {
+		return nil, errors.New("fieldcrypt: encrypted value found but no encryption key configured")
+	}
+
+	plain, err := k.Decrypt(*value)
+	if err != nil {
+		return nil, err
+	}
+	s := string(plain)
+	return &s, nil
+}
+
+// EncryptJSON seals a JSON document for storage in a JSONB column.
+// The result is itself valid JSON (a quoted string) so the column type is unchanged.
+func EncryptJSON(doc []byte) ([]byte, error) {
+	k := Active()
+	if k == nil || doc == nil {
+		return doc, nil
+	}
+
+	sealed, err := k.Encrypt(doc)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(sealed)
+}
+
+// DecryptJSON reverses EncryptJSON. Plain JSON documents are returned unchanged.
+func DecryptJSON(doc []byte) ([]byte, error) {
+	var sealed string
+	if len(doc) == 0 || doc[0] != '"' || json.Unmarshal(doc, &sealed) != nil || !IsEncrypted(sealed) {
+		return doc, nil
+	}
+
+	k := Active()
+	if k == nil {
+		return nil, errors.New("fieldcrypt: encrypted value found but no encryption key configured")
+	}
+	return k.Decrypt(sealed)
+}
@@ -0,0 +1,109 @@
+package fieldcrypt
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKeyring(t *testing.T, previous ...string) (*Keyring, string) {
+	t.Helper()
+	primary, err := GenerateKey()
+	require.NoError(t, err)
+	kr, err := NewKeyring(primary, previous...)
+	require.NoError(t, err)
+	return kr, primary
+}
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	kr, _ := newTestKeyring(t)
+
+	sealed, err := kr.Encrypt([]byte("user-42"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(sealed, Prefix+kr.PrimaryKeyID()+":"))
+	assert.NotContains(t, sealed, "user-42")
+
+	plain, err := kr.Decrypt(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "user-42", string(plain))
+}
+
+func TestDecryptPassesThroughPlaintext(t *testing.T) {
+	kr, _ := newTestKeyring(t)
+
+	plain, err := kr.Decrypt("legacy-value")
+	require.NoError(t, err)
+	assert.Equal(t, "legacy-value", string(plain))
+	assert.True(t, kr.NeedsRotation("legacy-value"))
+}
+
+func TestRotationKeepsOldKeyReadable(t *testing.T) {
+	oldRing, oldKey := newTestKeyring(t)
+	sealed, err := oldRing.Encrypt([]byte("secret"))
+	require.NoError(t, err)
+
+	newRing, _ := newTestKeyring(t, oldKey)
+	assert.True(t, newRing.NeedsRotation(sealed))
+
+	plain, err := newRing.Decrypt(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", string(plain))
+
+	resealed, err := newRing.Encrypt(plain)
+	require.NoError(t, err)
+	assert.False(t, newRing.NeedsRotation(resealed))
+}
+
+func TestDecryptUnknownKey(t *testing.T) {
+	a, _ := newTestKeyring(t)
+	b, _ := newTestKeyring(t)
+
+	sealed, err := a.Encrypt([]byte("x"))
+	require.NoError(t, err)
+
+	_, err = b.Decrypt(sealed)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestNewKeyringRejectsBadKeys(t *testing.T) {
+	_, err := NewKeyring("not-base64!")
+	assert.Error(t, err)
+
+	_, err = NewKeyring("c2hvcnQ=") // "short"
+	assert.Error(t, err)
+}
+
+func TestJSONHelpers(t *testing.T) {
+	kr, _ := newTestKeyring(t)
+	Configure(kr)
+	t.Cleanup(func() { Configure(nil) })
+
+	doc := []byte(`{"plan":"pro"}`)
+	sealed, err := EncryptJSON(doc)
+	require.NoError(t, err)
+	assert.Equal(t, byte('"'), sealed[0])
+
+	opened, err := DecryptJSON(sealed)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(doc), string(opened))
+
+	// Plain documents pass through
+	opened, err = DecryptJSON(doc)
+	require.NoError(t, err)
+	assert.Equal(t, doc, opened)
+}
+
+func TestStringHelpersDisabled(t *testing.T) {
+	Configure(nil)
+	value := "abc"
+
+	out, err := EncryptString(&value)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", *out)
+
+	nilOut, err := EncryptString(nil)
+	require.NoError(t, err)
+	assert.Nil(t, nilOut)
+}
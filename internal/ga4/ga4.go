@@ -0,0 +1,165 @@
+// Package ga4 reads GA4 Measurement Protocol hits, so server-side Google
+// Analytics integrations can send their events to Kaunta by changing only
+// the host they post to.
+//
+// A website receives hits under its GA measurement ID (G-XXXXXXX), set with
+// `kaunta website ga4`. Hits must carry the API secret given there; only its
+// SHA-256 is stored. Measurement IDs live in PostgreSQL (website).
+package ga4
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxEvents is the most events a hit may carry, as in GA
+const MaxEvents = 25
+
+// ErrUnknownMeasurement is returned by Lookup for measurement IDs no
+// website has
+var ErrUnknownMeasurement = errors.New("unknown measurement ID")
+
+// ErrInvalidSecret is returned by Lookup when the API secret doesn't match
+var ErrInvalidSecret = errors.New("invalid API secret")
+
+var (
+	measurementIDPattern = regexp.MustCompile(`^G-[A-Z0-9]{4,20}$`)
+	eventNamePattern     = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,39}$`)
+)
+
+// reservedEvents are the event names GA collects itself and rejects in hits
+var reservedEvents = map[string]bool{
+	"ad_activeview": true, "ad_click": true, "ad_exposure": true, "ad_query": true,
+	"ad_reward": true, "adunit_exposure": true, "app_clear_data": true, "app_exception": true,
+	"app_install": true, "app_remove": true, "app_store_refund": true, "app_update": true,
+	"app_upgrade": true, "dynamic_link_app_open": true, "dynamic_link_app_update": true,
+	"dynamic_link_first_open": true, "error": true, "first_open": true, "first_visit": true,
+	"in_app_purchase": true, "notification_dismiss": true, "notification_foreground": true,
+	"notification_open": true, "notification_receive": true, "os_update": true,
+	"screen_view": true, "session_start": true, "user_engagement": true, "app_background": true,
+}
+
+// Request is a Measurement Protocol hit
+type Request struct {
+	ClientID        string  `json:"client_id"`
+	UserID          string  `json:"user_id,omitempty"`
+	TimestampMicros int64   `json:"timestamp_micros,omitempty"`
+	IPOverride      string  `json:"ip_override,omitempty"`
+	Device          *Device `json:"device,omitempty"`
+	Events          []Event `json:"events"`
+}
+
+// Device describes the visitor's device, for hits sent without its user
+// agent
+type Device struct {
+	Category         string `json:"category,omitempty"`
+	Language         string `json:"language,omitempty"`
+	ScreenResolution string `json:"screen_resolution,omitempty"`
+	OperatingSystem  string `json:"operating_system,omitempty"`
+	Browser          string `json:"browser,omitempty"`
+}
+
+// Event is an event of a hit
+type Event struct {
+	Name            string                 `json:"name"`
+	Params          map[string]interface{} `json:"params,omitempty"`
+	TimestampMicros int64                  `json:"timestamp_micros,omitempty"`
+}
+
+// ValidationMessage is a problem with a hit, as /debug/mp/collect reports
+// them
+type ValidationMessage struct {
+	FieldPath      string `json:"fieldPath"`
+	Description    string `json:"description"`
+	ValidationCode string `json:"validationCode"`
+}
+
+// Validate lists what is wrong with r; hits with problems are not recorded
+func (r *Request) Validate() []ValidationMessage {
+	messages := []ValidationMessage{}
+	invalid := func(path, code, format string, args ...interface{}) {
+		messages = append(messages, ValidationMessage{FieldPath: path, Description: fmt.Sprintf(format, args...), ValidationCode: code})
+	}
+	if strings.TrimSpace(r.ClientID) == "" {
+		invalid("client_id", "VALUE_REQUIRED", "client_id is required")
+	}
+	if len(r.Events) == 0 {
+		invalid("events", "VALUE_REQUIRED", "at least one event is required")
+	}
+	if len(r.Events) > MaxEvents {
+		invalid("events", "VALUE_INVALID", "a hit carries at most %d events", MaxEvents)
+	}
+	for i, e := range r.Events {
+		path := fmt.Sprintf("events[%d].name", i)
+		switch {
+		case !eventNamePattern.MatchString(e.Name):
+			invalid(path, "NAME_INVALID", "event name %q must start with a letter and use letters, digits and underscores (at most 40)", e.Name)
+		case reservedEvents[e.Name]:
+			invalid(path, "NAME_RESERVED", "event name %q is reserved", e.Name)
+		}
+	}
+	return messages
+}
+
+// Time is when e happened: its own timestamp, else the hit's, else now
+func (r *Request) Time(e Event, now time.Time) time.Time {
+	switch {
+	case e.TimestampMicros > 0:
+		return time.UnixMicro(e.TimestampMicros)
+	case r.TimestampMicros > 0:
+		return time.UnixMicro(r.TimestampMicros)
+	default:
+		return now
+	}
+}
+
+// Param returns a string parameter of e, "" when missing or not a string
+func (e Event) Param(name string) string {
+	s, _ := e.Params[name].(string)
+	return s
+}
+
+// ValidMeasurementID checks id is a GA4 web stream's measurement ID
+func ValidMeasurementID(id string) error {
+	if !measurementIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid measurement ID: %q (use G-XXXXXXX)", id)
+	}
+	return nil
+}
+
+// HashSecret is what is stored of an API secret
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Lookup returns the website receiving hits for measurementID, checking
+// apiSecret against the one it was given
+func Lookup(ctx context.Context, db *sql.DB, measurementID, apiSecret string) (uuid.UUID, error) {
+	var websiteID uuid.UUID
+	var secretHash string
+	err := db.QueryRowContext(ctx, `
+		SELECT website_id, ga_api_secret_hash FROM website
+		WHERE UPPER(ga_measurement_id) = UPPER($1) AND deleted_at IS NULL
+	`, measurementID).Scan(&websiteID, &secretHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, ErrUnknownMeasurement
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to look up measurement ID: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(HashSecret(apiSecret)), []byte(secretHash)) != 1 {
+		return uuid.Nil, ErrInvalidSecret
+	}
+	return websiteID, nil
+}
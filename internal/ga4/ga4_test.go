@@ -0,0 +1,90 @@
+package ga4
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	valid := Request{ClientID: "123.456", Events: []Event{{Name: "page_view"}, {Name: "sign_up"}}}
+	assert.Empty(t, valid.Validate())
+
+	tooMany := Request{ClientID: "123.456", Events: make([]Event, MaxEvents+1)}
+	for i := range tooMany.Events {
+		tooMany.Events[i].Name = "click"
+	}
+
+	tests := []struct {
+		name string
+		req  Request
+		path string
+		code string
+	}{
+		{"no client", Request{Events: []Event{{Name: "click"}}}, "client_id", "VALUE_REQUIRED"},
+		{"no events", Request{ClientID: "1"}, "events", "VALUE_REQUIRED"},
+		{"too many events", tooMany, "events", "VALUE_INVALID"},
+		{"bad name", Request{ClientID: "1", Events: []Event{{Name: "1st-click"}}}, "events[0].name", "NAME_INVALID"},
+		{"long name", Request{ClientID: "1", Events: []Event{{Name: strings.Repeat("a", 41)}}}, "events[0].name", "NAME_INVALID"},
+		{"reserved name", Request{ClientID: "1", Events: []Event{{Name: "click"}, {Name: "first_visit"}}}, "events[1].name", "NAME_RESERVED"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := tt.req.Validate()
+			require.Len(t, messages, 1)
+			assert.Equal(t, tt.path, messages[0].FieldPath)
+			assert.Equal(t, tt.code, messages[0].ValidationCode)
+		})
+	}
+}
+
+func TestTime(t *testing.T) {
+	now := time.Unix(1750000000, 0)
+	hitTime := now.Add(-time.Hour)
+	eventTime := now.Add(-2 * time.Hour)
+
+	r := Request{}
+	assert.Equal(t, now, r.Time(Event{}, now))
+	r.TimestampMicros = hitTime.UnixMicro()
+	assert.True(t, hitTime.Equal(r.Time(Event{}, now)))
+	assert.True(t, eventTime.Equal(r.Time(Event{TimestampMicros: eventTime.UnixMicro()}, now)))
+}
+
+func TestValidMeasurementID(t *testing.T) {
+	assert.NoError(t, ValidMeasurementID("G-ABC123XYZ"))
+	assert.Error(t, ValidMeasurementID("UA-12345-1"))
+	assert.Error(t, ValidMeasurementID("G-abc"))
+}
+
+func TestLookup(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	websiteID := uuid.New()
+	rows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"website_id", "ga_api_secret_hash"}).AddRow(websiteID, HashSecret("s3cr3t"))
+	}
+	mock.ExpectQuery(`UPPER\(ga_measurement_id\) = UPPER\(\$1\)`).WithArgs("g-abc123").WillReturnRows(rows())
+	mock.ExpectQuery(`UPPER\(ga_measurement_id\)`).WithArgs("G-ABC123").WillReturnRows(rows())
+	mock.ExpectQuery(`UPPER\(ga_measurement_id\)`).WithArgs("G-NONE00").
+		WillReturnRows(sqlmock.NewRows([]string{"website_id", "ga_api_secret_hash"}))
+
+	ctx := context.Background()
+	got, err := Lookup(ctx, db, "g-abc123", "s3cr3t")
+	require.NoError(t, err)
+	assert.Equal(t, websiteID, got)
+
+	_, err = Lookup(ctx, db, "G-ABC123", "wrong")
+	assert.ErrorIs(t, err, ErrInvalidSecret)
+
+	_, err = Lookup(ctx, db, "G-NONE00", "s3cr3t")
+	assert.ErrorIs(t, err, ErrUnknownMeasurement)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,266 @@
+// Package gdpr answers data-subject access requests: it gathers everything
+// Kaunta stores about one visitor, known by the distinct ID the website
+// tracked them with (the id field of the tracking payload).
+//
+// Distinct IDs may be encrypted at rest with a random nonce (see
+// fieldcrypt), so sessions can't be looked up by value: every session with
+// an encrypted distinct ID is read and decrypted. The visitor data lives in
+// PostgreSQL.
+package gdpr
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/fieldcrypt"
+)
+
+// ErrNotFound is returned by Export when no session has the distinct ID
+var ErrNotFound = errors.New("no sessions with this distinct ID")
+
+// notes explain the export to the visitor reading it
+var notes = []string{
+	"A session is a visitor's activity on one website; its ID is derived from the IP address and browser, and no IP address is stored with it.",
+	"Country, region and city are looked up from the IP address when the event is received; the address itself is not kept.",
+	"Events are the pages viewed (type pageview) and the actions recorded by the website (type event), in the order they happened.",
+	"Linked sessions are sessions on other websites of the same owner that the visitor moved to or came from.",
+	"Baseline hits are the days an image used to estimate how many visitors block tracking was loaded.",
+	"Aggregated statistics (daily and hourly counts) hold no data about individual visitors and are not included.",
+}
+
+// Visitor is everything stored about a visitor
+type Visitor struct {
+	DistinctID string    `json:"distinct_id"`
+	ExportedAt time.Time `json:"exported_at"`
+	Notes      []string  `json:"notes"`
+	Sessions   []Session `json:"sessions"`
+}
+
+// Session is a session of the visitor, with what was recorded in it
+type Session struct {
+	SessionID      uuid.UUID     `json:"session_id"`
+	Website        string        `json:"website"`
+	StartedAt      time.Time     `json:"started_at"`
+	Browser        *string       `json:"browser"`
+	OS             *string       `json:"os"`
+	Device         *string       `json:"device"`
+	Screen         *string       `json:"screen"`
+	Language       *string       `json:"language"`
+	Country        *string       `json:"country"`
+	Region         *string       `json:"region"`
+	City           *string       `json:"city"`
+	ASN            *int64        `json:"network_asn"`
+	ISP            *string       `json:"network_isp"`
+	Events         []Event       `json:"events"`
+	LinkedSessions []LinkedVisit `json:"linked_sessions"`
+	BaselineHits   []string      `json:"baseline_hits"`
+}
+
+// Event is a pageview or custom event
+type Event struct {
+	Time           time.Time       `json:"time"`
+	VisitID        uuid.UUID       `json:"visit_id"`
+	Type           string          `json:"type"`
+	Name           *string         `json:"name,omitempty"`
+	Hostname       *string         `json:"hostname"`
+	Path           *string         `json:"path"`
+	Query          *string         `json:"query,omitempty"`
+	Title          *string         `json:"title,omitempty"`
+	Referrer       *string         `json:"referrer,omitempty"`
+	UTMSource      *string         `json:"utm_source,omitempty"`
+	UTMMedium      *string         `json:"utm_medium,omitempty"`
+	UTMCampaign    *string         `json:"utm_campaign,omitempty"`
+	UTMContent     *string         `json:"utm_content,omitempty"`
+	UTMTerm        *string         `json:"utm_term,omitempty"`
+	Properties     json.RawMessage `json:"properties,omitempty"`
+	Dimensions     json.RawMessage `json:"dimensions,omitempty"`
+	Author         *string         `json:"author,omitempty"`
+	ScrollDepth    *int64          `json:"scroll_depth_percent,omitempty"`
+	EngagementTime *int64          `json:"engagement_time_ms,omitempty"`
+	Viewed         *bool           `json:"viewed,omitempty"`
+	Read           *bool           `json:"read,omitempty"`
+	Bot            bool            `json:"flagged_as_bot"`
+}
+
+// LinkedVisit is a session on another website linked to a session of the
+// visitor (see crossdomain)
+type LinkedVisit struct {
+	SessionID uuid.UUID `json:"session_id"`
+	Website   string    `json:"website"`
+	LinkedAt  time.Time `json:"linked_at"`
+}
+
+// Export gathers the sessions tracked with distinctID, on one website or on
+// all of them (websiteID uuid.Nil)
+func Export(ctx context.Context, db *sql.DB, distinctID string, websiteID uuid.UUID, now time.Time) (*Visitor, error) {
+	sessions, err := findSessions(ctx, db, distinctID, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, ErrNotFound
+	}
+
+	for i := range sessions {
+		s := &sessions[i]
+		if s.Events, err = sessionEvents(ctx, db, s.SessionID); err != nil {
+			return nil, err
+		}
+		if s.LinkedSessions, err = linkedSessions(ctx, db, s.SessionID); err != nil {
+			return nil, err
+		}
+		if s.BaselineHits, err = baselineHits(ctx, db, s.SessionID); err != nil {
+			return nil, err
+		}
+	}
+	return &Visitor{DistinctID: distinctID, ExportedAt: now.UTC(), Notes: notes, Sessions: sessions}, nil
+}
+
+// findSessions reads the sessions whose distinct ID is distinctID, in plain
+// text or encrypted
+func findSessions(ctx context.Context, db *sql.DB, distinctID string, websiteID uuid.UUID) ([]Session, error) {
+	var website interface{}
+	if websiteID != uuid.Nil {
+		website = websiteID
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT s.session_id, w.domain, s.distinct_id, s.created_at,
+			s.browser, s.os, s.device, s.screen, s.language,
+			s.country, s.region, s.city, s.asn, s.isp
+		FROM session s
+		JOIN website w ON w.website_id = s.website_id
+		WHERE (s.distinct_id = $1 OR s.distinct_id LIKE $2)
+		  AND ($3::uuid IS NULL OR s.website_id = $3)
+		ORDER BY s.created_at
+	`, distinctID, fieldcrypt.Prefix+"%", website)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var s Session
+		var stored string
+		if err := rows.Scan(&s.SessionID, &s.Website, &stored, &s.StartedAt,
+			&s.Browser, &s.OS, &s.Device, &s.Screen, &s.Language,
+			&s.Country, &s.Region, &s.City, &s.ASN, &s.ISP); err != nil {
+			return nil, fmt.Errorf("failed to read session: %w", err)
+		}
+		// An export missing sessions it can't decrypt would be incomplete
+		plain, err := fieldcrypt.DecryptString(&stored)
+		if err != nil {
+			return nil, fmt.Errorf("session %s: %w", s.SessionID, err)
+		}
+		if *plain == distinctID {
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions, rows.Err()
+}
+
+func sessionEvents(ctx context.Context, db *sql.DB, sessionID uuid.UUID) ([]Event, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT created_at, visit_id, event_type, event_name, hostname, url_path, url_query, page_title,
+			referrer_domain, referrer_path, referrer_query,
+			utm_source, utm_medium, utm_campaign, utm_content, utm_term,
+			props, dimensions, author, scroll_depth, engagement_time, viewed, read, bot
+		FROM website_event
+		WHERE session_id = $1
+		ORDER BY created_at
+	`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	events := []Event{}
+	for rows.Next() {
+		var e Event
+		var eventType int
+		var refDomain, refPath, refQuery sql.NullString
+		var props, dimensions []byte
+		if err := rows.Scan(&e.Time, &e.VisitID, &eventType, &e.Name, &e.Hostname, &e.Path, &e.Query, &e.Title,
+			&refDomain, &refPath, &refQuery,
+			&e.UTMSource, &e.UTMMedium, &e.UTMCampaign, &e.UTMContent, &e.UTMTerm,
+			&props, &dimensions, &e.Author, &e.ScrollDepth, &e.EngagementTime, &e.Viewed, &e.Read, &e.Bot); err != nil {
+			return nil, fmt.Errorf("failed to read event: %w", err)
+		}
+		e.Type = "pageview"
+		if eventType == 2 {
+			e.Type = "event"
+		}
+		if refDomain.String != "" {
+			referrer := refDomain.String + refPath.String
+			if refQuery.String != "" {
+				referrer += "?" + refQuery.String
+			}
+			e.Referrer = &referrer
+		}
+		if len(props) > 0 {
+			plain, err := fieldcrypt.DecryptJSON(props)
+			if err != nil {
+				return nil, fmt.Errorf("event of session %s: %w", sessionID, err)
+			}
+			e.Properties = plain
+		}
+		if len(dimensions) > 0 {
+			e.Dimensions = dimensions
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func linkedSessions(ctx context.Context, db *sql.DB, sessionID uuid.UUID) ([]LinkedVisit, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT l.linked_session_id, w.domain, l.created_at
+		FROM session_link l JOIN website w ON w.website_id = l.linked_website_id
+		WHERE l.session_id = $1
+		UNION ALL
+		SELECT l.session_id, w.domain, l.created_at
+		FROM session_link l JOIN website w ON w.website_id = l.website_id
+		WHERE l.linked_session_id = $1
+		ORDER BY 3
+	`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query linked sessions: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	linked := []LinkedVisit{}
+	for rows.Next() {
+		var l LinkedVisit
+		if err := rows.Scan(&l.SessionID, &l.Website, &l.LinkedAt); err != nil {
+			return nil, fmt.Errorf("failed to read linked session: %w", err)
+		}
+		linked = append(linked, l)
+	}
+	return linked, rows.Err()
+}
+
+func baselineHits(ctx context.Context, db *sql.DB, sessionID uuid.UUID) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT day FROM baseline_hit WHERE session_id = $1 ORDER BY day
+	`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query baseline hits: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	days := []string{}
+	for rows.Next() {
+		var day time.Time
+		if err := rows.Scan(&day); err != nil {
+			return nil, fmt.Errorf("failed to read baseline hit: %w", err)
+		}
+		days = append(days, day.Format("2006-01-02"))
+	}
+	return days, rows.Err()
+}
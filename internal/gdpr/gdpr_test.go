@@ -0,0 +1,86 @@
+package gdpr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/fieldcrypt"
+	"github.com/seuros/kaunta/internal/test"
+)
+
+var sessionColumns = []string{"session_id", "domain", "distinct_id", "created_at", "browser", "os", "device",
+	"screen", "language", "country", "region", "city", "asn", "isp"}
+
+var eventColumns = []string{"created_at", "visit_id", "event_type", "event_name", "hostname", "url_path", "url_query",
+	"page_title", "referrer_domain", "referrer_path", "referrer_query", "utm_source", "utm_medium", "utm_campaign",
+	"utm_content", "utm_term", "props", "dimensions", "author", "scroll_depth", "engagement_time", "viewed", "read", "bot"}
+
+func TestExport(t *testing.T) {
+	key, err := fieldcrypt.GenerateKey()
+	require.NoError(t, err)
+	keyring, err := fieldcrypt.NewKeyring(key)
+	require.NoError(t, err)
+	fieldcrypt.Configure(keyring)
+	t.Cleanup(func() { fieldcrypt.Configure(nil) })
+
+	mine, err := keyring.Encrypt([]byte("user-1842"))
+	require.NoError(t, err)
+	theirs, err := keyring.Encrypt([]byte("user-7"))
+	require.NoError(t, err)
+	props, err := keyring.Encrypt([]byte(`{"plan":"pro"}`))
+	require.NoError(t, err)
+
+	db, mock := test.NewMockDB(t)
+	sessionID, otherID, visitID := uuid.New(), uuid.New(), uuid.New()
+	at := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("FROM session s").WithArgs("user-1842", fieldcrypt.Prefix+"%", nil).
+		WillReturnRows(sqlmock.NewRows(sessionColumns).
+			AddRow(sessionID, "example.com", mine, at, "Firefox", "Linux", "desktop", "1920x1080", "en-US", "DE", "DE-BE", "Berlin", int64(3320), "Deutsche Telekom").
+			AddRow(otherID, "example.com", theirs, at, "Chrome", "macOS", "desktop", nil, nil, nil, nil, nil, nil, nil))
+	mock.ExpectQuery("FROM website_event").WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows(eventColumns).
+			AddRow(at, visitID, 1, nil, "example.com", "/pricing", nil, "Pricing", "google.com", "/", nil,
+				"newsletter", nil, nil, nil, nil, nil, []byte(`{"section":"sales"}`), nil, int64(80), int64(12000), true, nil, false).
+			AddRow(at.Add(time.Minute), visitID, 2, "signup", "example.com", "/pricing", nil, nil, nil, nil, nil,
+				nil, nil, nil, nil, nil, []byte(`"`+props+`"`), nil, nil, nil, nil, nil, nil, false))
+	mock.ExpectQuery("FROM session_link").WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows([]string{"session_id", "domain", "created_at"}).
+			AddRow(otherID, "app.example.io", at.Add(2*time.Minute)))
+	mock.ExpectQuery("FROM baseline_hit").WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows([]string{"day"}).AddRow(at))
+
+	visitor, err := Export(context.Background(), db, "user-1842", uuid.Nil, at)
+	require.NoError(t, err)
+	require.Len(t, visitor.Sessions, 1, "sessions of other distinct IDs are left out")
+
+	s := visitor.Sessions[0]
+	assert.Equal(t, sessionID, s.SessionID)
+	assert.Equal(t, "Berlin", *s.City)
+	require.Len(t, s.Events, 2)
+	assert.Equal(t, "pageview", s.Events[0].Type)
+	assert.Equal(t, "google.com/", *s.Events[0].Referrer)
+	assert.JSONEq(t, `{"section":"sales"}`, string(s.Events[0].Dimensions))
+	assert.Equal(t, "event", s.Events[1].Type)
+	assert.JSONEq(t, `{"plan":"pro"}`, string(s.Events[1].Properties))
+	assert.Equal(t, "app.example.io", s.LinkedSessions[0].Website)
+	assert.Equal(t, []string{"2025-06-01"}, s.BaselineHits)
+	assert.NotEmpty(t, visitor.Notes)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExportNotFound(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+	mock.ExpectQuery("FROM session s").WithArgs("nobody", fieldcrypt.Prefix+"%", websiteID).
+		WillReturnRows(sqlmock.NewRows(sessionColumns))
+
+	_, err := Export(context.Background(), db, "nobody", websiteID, time.Now())
+	assert.ErrorIs(t, err, ErrNotFound)
+}
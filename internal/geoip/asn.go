@@ -0,0 +1,147 @@
+package geoip
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/oschwald/geoip2-golang"
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/logging"
+	"github.com/seuros/kaunta/internal/offline"
+)
+
+// errNoASNSource is returned when no configured source publishes an ASN
+// database
+var errNoASNSource = errors.New("no ASN database source: set a MaxMind license key or use the dbip provider")
+
+var (
+	// asnReader is guarded by mu, like provider
+	asnReader *geoip2.Reader
+	asnPath   string
+)
+
+// asnFileName is the ASN database file of a provider in the data directory.
+// IP2Location's ASN data is a separate product; its users can install
+// GeoLite2-ASN.mmdb instead.
+func asnFileName(provider string) string {
+	if provider == DBIP {
+		return "dbip-asn-lite.mmdb"
+	}
+	return "GeoLite2-ASN.mmdb"
+}
+
+// hasASNSource reports whether the ASN database can be downloaded
+func hasASNSource() bool {
+	return opts.Provider == DBIP || (opts.Provider == MaxMind && opts.LicenseKey != "")
+}
+
+// initASN loads the ASN database from dataDir, downloading it when missing
+// and a source is configured. ASN lookups are optional: without the
+// database they return nothing.
+func initASN(dataDir string) {
+	asnPath = filepath.Join(dataDir, asnFileName(opts.Provider))
+
+	if _, err := os.Stat(asnPath); os.IsNotExist(err) {
+		if !hasASNSource() || offline.Enabled() {
+			logging.L().Info("asn database not found; ASN lookups disabled", zap.String("path", asnPath))
+			return
+		}
+		if err := downloadASNDatabase(asnPath); err != nil {
+			logging.L().Warn("asn database download failed; ASN lookups disabled", zap.Error(err))
+			return
+		}
+	}
+
+	if err := loadASN(asnPath); err != nil {
+		logging.L().Warn("could not load asn database", zap.Error(err))
+		return
+	}
+	logging.L().Info("asn database loaded", zap.String("path", asnPath))
+}
+
+// downloadASNDatabase downloads the ASN database of the configured source
+func downloadASNDatabase(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	switch {
+	case opts.Provider == DBIP:
+		return downloadFromDBIP(path, "asn", time.Now())
+	case opts.Provider == MaxMind && opts.LicenseKey != "":
+		return downloadFromMaxMind(path, opts.LicenseKey, "GeoLite2-ASN")
+	default:
+		return errNoASNSource
+	}
+}
+
+// updateASN refreshes the ASN database like updateLocation; without a
+// source there is nothing to do
+func updateASN() error {
+	if asnPath == "" || !hasASNSource() {
+		return nil
+	}
+	tmp := asnPath + ".download"
+	defer func() { _ = os.Remove(tmp) }()
+
+	if err := downloadASNDatabase(tmp); err != nil {
+		return err
+	}
+	check, err := geoip2.Open(tmp)
+	if err != nil {
+		return fmt.Errorf("downloaded asn database is invalid: %w", err)
+	}
+	_ = check.Close()
+
+	if err := os.Rename(tmp, asnPath); err != nil {
+		return err
+	}
+	return loadASN(asnPath)
+}
+
+// loadASN opens the ASN database at path and swaps it in
+func loadASN(path string) error {
+	next, err := geoip2.Open(path)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	previous := asnReader
+	asnReader = next
+	mu.Unlock()
+
+	if previous != nil {
+		if err := previous.Close(); err != nil {
+			logging.L().Warn("failed to close previous asn database", zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// LookupASN returns the autonomous system number and organization (the
+// ISP, hosting company or VPN operator) of an IP address; 0 and "" when
+// unknown
+func LookupASN(ipStr string) (asn uint, org string) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if asnReader == nil {
+		return 0, ""
+	}
+
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return 0, ""
+	}
+
+	record, err := asnReader.ASN(ip)
+	if err != nil {
+		logging.L().Warn("asn lookup error", zap.String("ip", ipStr), zap.Error(err))
+		return 0, ""
+	}
+	return record.AutonomousSystemNumber, record.AutonomousSystemOrganization
+}
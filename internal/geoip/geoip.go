@@ -1,60 +1,203 @@
 package geoip
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
 	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/oschwald/geoip2-golang"
 	"go.uber.org/zap"
 
+	"github.com/seuros/kaunta/internal/chaos"
 	"github.com/seuros/kaunta/internal/logging"
+	"github.com/seuros/kaunta/internal/offline"
 )
 
+// DefaultUpdateInterval is how often the database is refreshed; MaxMind
+// publishes GeoLite2 updates twice a week
+const DefaultUpdateInterval = 7 * 24 * time.Hour
+
+// Options configure where the database comes from and how often it is
+// refreshed
+type Options struct {
+	// Provider is the database used: MaxMind (default), DBIP or IP2Location
+	Provider string
+	// LicenseKey downloads GeoLite2-City from MaxMind, with its published
+	// SHA-256 checksum verified. Without one the jsDelivr mirror is used.
+	LicenseKey string
+	// IP2LocationToken downloads IP2Location LITE DB3. Without one the BIN
+	// file must be installed manually.
+	IP2LocationToken string
+	// UpdateInterval is how often StartAutoUpdate refreshes the database
+	// (DefaultUpdateInterval when zero; negative disables updates)
+	UpdateInterval time.Duration
+}
+
+var (
+	// mu guards provider: lookups share it, a refresh swaps it and closes
+	// the old one once no lookup uses it
+	mu       sync.RWMutex
+	provider Provider
+	dbPath   string
+	opts     Options
+)
+
+// Download sources; variables so tests can point them at a local server
 var (
-	reader *geoip2.Reader
-	dbPath string
+	maxMindURL     = "https://download.maxmind.com/app/geoip_download"
+	mirrorURL      = "https://cdn.jsdelivr.net/npm/geolite2-city/GeoLite2-City.mmdb.gz"
+	dbipURL        = "https://download.db-ip.com/free"
+	ip2locationURL = "https://www.ip2location.com/download/"
 )
 
 // Init initializes the GeoIP database
-// Downloads GeoLite2-City if not present locally (optional - warns if missing)
-func Init(dataDir string) error {
-	dbPath = filepath.Join(dataDir, "GeoLite2-City.mmdb")
+// Downloads the provider's database if not present locally (optional - warns if missing)
+func Init(dataDir string, options Options) error {
+	name, err := ParseProvider(options.Provider)
+	if err != nil {
+		return err
+	}
+	options.Provider = name
+	dbPath = filepath.Join(dataDir, fileName(name))
+	opts = options
 
 	// Download if missing
 	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+		if offline.Enabled() {
+			logging.L().Warn("geoip database not found and offline mode is enabled; skipping download", zap.String("path", dbPath))
+			logging.L().Warn("geoip lookups will return 'Unknown' until database is installed manually")
+			return nil
+		}
 		logging.L().Info("geoip database not found; attempting download", zap.String("path", dbPath))
 		if err := downloadDatabase(dbPath); err != nil {
 			logging.L().Warn("geoip database download failed", zap.Error(err))
 			logging.L().Warn("geoip lookups will return 'Unknown' until database is installed manually")
-			logging.L().Info("download GeoIP from https://geoip.maxmind.com/ and place file", zap.String("path", dbPath))
+			logging.L().Info("download the GeoIP database and place file", zap.String("provider", name), zap.String("path", dbPath))
 			// Don't fail - continue without GeoIP
+			initASN(dataDir)
 			return nil
 		}
 		logging.L().Info("geoip database downloaded successfully")
 	}
 
 	// Open database
-	var err error
-	reader, err = geoip2.Open(dbPath)
-	if err != nil {
+	if err := load(dbPath); err != nil {
 		logging.L().Warn("could not load geoip database", zap.Error(err))
 		logging.L().Warn("geoip lookups will return 'Unknown'")
 		// Don't fail - continue without GeoIP
+		initASN(dataDir)
 		return nil
 	}
 
-	logging.L().Info("geoip database loaded")
+	logging.L().Info("geoip database loaded", zap.String("provider", name))
+	initASN(dataDir)
 	return nil
 }
 
+// load opens the database at path and swaps it in for the current one
+func load(path string) error {
+	next, err := openProvider(opts.Provider, path)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	previous := provider
+	provider = next
+	mu.Unlock()
+
+	if previous != nil {
+		if err := previous.Close(); err != nil {
+			logging.L().Warn("failed to close previous geoip database", zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// StartAutoUpdate refreshes the database every UpdateInterval until ctx is
+// done, swapping the new file in without a restart. A database older than
+// the interval at startup is refreshed right away. It does nothing in
+// offline mode or when updates are disabled.
+func StartAutoUpdate(ctx context.Context) {
+	interval := opts.UpdateInterval
+	if interval == 0 {
+		interval = DefaultUpdateInterval
+	}
+	if interval < 0 || offline.Enabled() || dbPath == "" {
+		return
+	}
+
+	go func() {
+		first := interval
+		if info, err := os.Stat(dbPath); err == nil {
+			first = max(interval-time.Since(info.ModTime()), time.Minute)
+		}
+		timer := time.NewTimer(first)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+				if err := Update(); err != nil {
+					logging.L().Warn("geoip database update failed", zap.Error(err))
+				} else {
+					logging.L().Info("geoip database updated", zap.String("path", dbPath))
+				}
+				timer.Reset(interval)
+			}
+		}
+	}()
+}
+
+// Update downloads fresh databases (location, then ASN when it has a
+// source) and swaps them in. The current file and reader are kept when the
+// download or the new file is bad.
+func Update() error {
+	return errors.Join(updateLocation(), updateASN())
+}
+
+func updateLocation() error {
+	tmp := dbPath + ".download"
+	defer func() { _ = os.Remove(tmp) }()
+
+	if err := downloadDatabase(tmp); err != nil {
+		return err
+	}
+	// Check the file opens before it replaces the current one
+	check, err := openProvider(opts.Provider, tmp)
+	if err != nil {
+		return fmt.Errorf("downloaded database is invalid: %w", err)
+	}
+	_ = check.Close()
+
+	if err := os.Rename(tmp, dbPath); err != nil {
+		return err
+	}
+	return load(dbPath)
+}
+
 // LookupIP returns country, city, and region for an IP address
 func LookupIP(ipStr string) (country, city, region string) {
-	if reader == nil {
+	chaos.GeoIPDelay()
+	mu.RLock()
+	defer mu.RUnlock()
+	if provider == nil {
 		return "", "", ""
 	}
 
@@ -63,35 +206,33 @@ func LookupIP(ipStr string) (country, city, region string) {
 		return "", "", ""
 	}
 
-	record, err := reader.City(ip)
+	loc, err := provider.Lookup(ip)
 	if err != nil {
 		logging.L().Warn("geoip lookup error", zap.String("ip", ipStr), zap.Error(err))
 		return "", "", ""
 	}
-
-	country = record.Country.IsoCode
-	// Keep country empty if not found (don't use "Unknown" - session.country is CHAR(2))
-
-	city = record.City.Names["en"]
-
-	// Handle subdivisions safely - only access if present
-	if len(record.Subdivisions) > 0 {
-		region = record.Subdivisions[0].Names["en"]
-	}
-
-	return country, city, region
+	return loc.Country, loc.City, loc.Region
 }
 
-// Close closes the GeoIP database
+// Close closes the GeoIP databases
 func Close() error {
-	if reader != nil {
-		return reader.Close()
+	mu.Lock()
+	defer mu.Unlock()
+	var errs []error
+	if provider != nil {
+		errs = append(errs, provider.Close())
+		provider = nil
 	}
-	return nil
+	if asnReader != nil {
+		errs = append(errs, asnReader.Close())
+		asnReader = nil
+	}
+	return errors.Join(errs...)
 }
 
-// downloadDatabase downloads GeoLite2-City database from jsDelivr CDN
-// Using the geolite2-city package mirror hosted by jsDelivr
+// downloadDatabase downloads the provider's database to dbPath. GeoLite2-City
+// comes from MaxMind when a license key is configured, otherwise from the
+// jsDelivr CDN mirror.
 func downloadDatabase(dbPath string) error {
 	// Create directory if needed
 	dir := filepath.Dir(dbPath)
@@ -99,13 +240,25 @@ func downloadDatabase(dbPath string) error {
 		return err
 	}
 
+	switch opts.Provider {
+	case DBIP:
+		return downloadFromDBIP(dbPath, "city", time.Now())
+	case IP2Location:
+		return downloadFromIP2Location(dbPath, opts.IP2LocationToken)
+	}
+	if opts.LicenseKey != "" {
+		return downloadFromMaxMind(dbPath, opts.LicenseKey, "GeoLite2-City")
+	}
+
 	// Use jsDelivr CDN mirror of geolite2-city
 	// Source: https://www.npmjs.com/package/geolite2-city
-	url := "https://cdn.jsdelivr.net/npm/geolite2-city/GeoLite2-City.mmdb.gz"
+	if err := offline.Check("geoip download", mirrorURL); err != nil {
+		return err
+	}
 
-	logging.L().Info("downloading geoip database", zap.String("url", url))
+	logging.L().Info("downloading geoip database", zap.String("url", mirrorURL))
 
-	resp, err := http.Get(url)
+	resp, err := http.Get(mirrorURL)
 	if err != nil {
 		return fmt.Errorf("download failed: %w", err)
 	}
@@ -130,20 +283,164 @@ func downloadDatabase(dbPath string) error {
 		}
 	}()
 
-	// Write to file
-	out, err := os.Create(dbPath)
+	return writeFile(dbPath, gzReader)
+}
+
+// downloadFromMaxMind fetches the tarball of edition (GeoLite2-City,
+// GeoLite2-ASN) with licenseKey, checks it against the SHA-256 MaxMind
+// publishes next to it and extracts the .mmdb file
+func downloadFromMaxMind(dbPath, licenseKey, edition string) error {
+	// The key is a credential: never log or report the full URL
+	if err := offline.Check("geoip download", maxMindURL); err != nil {
+		return err
+	}
+	download := func(suffix string) ([]byte, error) {
+		query := url.Values{"edition_id": {edition}, "license_key": {licenseKey}, "suffix": {suffix}}
+		resp, err := http.Get(maxMindURL + "?" + query.Encode())
+		if err != nil {
+			return nil, fmt.Errorf("download failed: %s", redactKey(err.Error(), licenseKey))
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("download of %s failed with status %d (check the MaxMind license key)", suffix, resp.StatusCode)
+		}
+		return io.ReadAll(resp.Body)
+	}
+
+	logging.L().Info("downloading geoip database from MaxMind", zap.String("url", maxMindURL), zap.String("edition", edition))
+
+	checksum, err := download("tar.gz.sha256")
 	if err != nil {
 		return err
 	}
-	defer func() {
-		if err := out.Close(); err != nil {
-			logging.L().Warn("failed to close geoip output file", zap.Error(err))
+	archive, err := download("tar.gz")
+	if err != nil {
+		return err
+	}
+
+	// The checksum file reads "<sha256>  <edition>_YYYYMMDD.tar.gz"
+	fields := strings.Fields(string(checksum))
+	if len(fields) == 0 {
+		return fmt.Errorf("empty checksum file")
+	}
+	sum := sha256.Sum256(archive)
+	if !strings.EqualFold(fields[0], hex.EncodeToString(sum[:])) {
+		return fmt.Errorf("checksum mismatch: expected %s, got %x", fields[0], sum)
+	}
+
+	gzReader, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	defer func() { _ = gzReader.Close() }()
+
+	tr := tar.NewReader(gzReader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return fmt.Errorf("no .mmdb file in the archive")
 		}
-	}()
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		if header.Typeflag == tar.TypeReg && strings.HasSuffix(header.Name, ".mmdb") {
+			return writeFile(dbPath, tr)
+		}
+	}
+}
 
-	if _, err := io.Copy(out, gzReader); err != nil {
+// writeFile writes r to path
+func writeFile(path string, r io.Reader) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		_ = out.Close()
 		return fmt.Errorf("failed to write database: %w", err)
 	}
+	return out.Close()
+}
 
-	return nil
+// downloadFromDBIP fetches the Lite database of kind (city, asn) of the
+// month of now, or of the month before while the new one isn't published yet
+func downloadFromDBIP(dbPath, kind string, now time.Time) error {
+	if err := offline.Check("geoip download", dbipURL); err != nil {
+		return err
+	}
+	var lastErr error
+	for _, month := range []time.Time{now, now.AddDate(0, 0, -now.Day())} {
+		fileURL := fmt.Sprintf("%s/dbip-%s-lite-%s.mmdb.gz", dbipURL, kind, month.Format("2006-01"))
+		logging.L().Info("downloading geoip database from DB-IP", zap.String("url", fileURL))
+
+		resp, err := http.Get(fileURL)
+		if err != nil {
+			return fmt.Errorf("download failed: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			_ = resp.Body.Close()
+			lastErr = fmt.Errorf("download failed with status %d", resp.StatusCode)
+			continue
+		}
+		err = func() error {
+			defer func() { _ = resp.Body.Close() }()
+			gzReader, err := gzip.NewReader(resp.Body)
+			if err != nil {
+				return fmt.Errorf("failed to create gzip reader: %w", err)
+			}
+			defer func() { _ = gzReader.Close() }()
+			return writeFile(dbPath, gzReader)
+		}()
+		return err
+	}
+	return lastErr
+}
+
+// downloadFromIP2Location fetches the LITE DB3 (IPv4 and IPv6) zip with the
+// account's download token and extracts the BIN file
+func downloadFromIP2Location(dbPath, token string) error {
+	if token == "" {
+		return fmt.Errorf("IP2Location downloads need a token (ip2location_token); install %s manually otherwise", filepath.Base(dbPath))
+	}
+	if err := offline.Check("geoip download", ip2locationURL); err != nil {
+		return err
+	}
+	logging.L().Info("downloading geoip database from IP2Location", zap.String("url", ip2locationURL))
+
+	query := url.Values{"token": {token}, "file": {"DB3LITEBINIPV6"}}
+	resp, err := http.Get(ip2locationURL + "?" + query.Encode())
+	if err != nil {
+		return fmt.Errorf("download failed: %s", redactKey(err.Error(), token))
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download failed with status %d", resp.StatusCode)
+	}
+	archive, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("download failed: %w", err)
+	}
+
+	// Errors (bad token, download limit) come back as a short text body
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return fmt.Errorf("download failed: %s", strings.TrimSpace(string(archive[:min(len(archive), 200)])))
+	}
+	for _, file := range zr.File {
+		if !strings.HasSuffix(strings.ToUpper(file.Name), ".BIN") {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		defer func() { _ = rc.Close() }()
+		return writeFile(dbPath, rc)
+	}
+	return fmt.Errorf("no .BIN file in the archive")
+}
+
+// redactKey hides the license key in error messages that quote the URL
+func redactKey(s, key string) string {
+	return strings.ReplaceAll(s, key, "REDACTED")
 }
@@ -1,9 +1,21 @@
 package geoip
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/offline"
 )
 
 func TestLookupIP(t *testing.T) {
@@ -43,7 +55,7 @@ func TestLookupIP(t *testing.T) {
 
 			// When DB is not loaded, should return empty string for country
 			// to comply with CHAR(2) database constraint
-			if reader == nil {
+			if provider == nil {
 				assert.Equal(t, "", country)
 				assert.Equal(t, "", city)
 				assert.Equal(t, "", region)
@@ -77,7 +89,7 @@ func TestLookupIPWithDatabase(t *testing.T) {
 	}
 
 	// Only run if database is loaded
-	if reader == nil {
+	if provider == nil {
 		t.Skip("GeoIP database not initialized")
 	}
 
@@ -92,3 +104,149 @@ func TestLookupIPWithDatabase(t *testing.T) {
 		})
 	}
 }
+
+func TestInitOfflineSkipsDownload(t *testing.T) {
+	offline.Set(true)
+	t.Cleanup(func() { offline.Set(false) })
+
+	dir := t.TempDir()
+	assert.NoError(t, Init(dir, Options{}))
+	assert.Nil(t, provider)
+	assert.NoFileExists(t, filepath.Join(dir, "GeoLite2-City.mmdb"))
+
+	err := downloadDatabase(filepath.Join(dir, "GeoLite2-City.mmdb"))
+	assert.ErrorIs(t, err, offline.ErrOffline)
+}
+
+// maxMindTarball builds a GeoLite2 release archive holding files
+func maxMindTarball(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+// serveMaxMind points downloads at a fake MaxMind serving archive with
+// checksum, and sets the license key
+func serveMaxMind(t *testing.T, archive []byte, checksum string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("license_key") != "test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Query().Get("suffix") {
+		case "tar.gz":
+			_, _ = w.Write(archive)
+		case "tar.gz.sha256":
+			_, _ = fmt.Fprintf(w, "%s  GeoLite2-City_20250601.tar.gz\n", checksum)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	previousURL, previousOpts := maxMindURL, opts
+	maxMindURL = server.URL
+	opts = Options{LicenseKey: "test-key"}
+	t.Cleanup(func() { maxMindURL, opts = previousURL, previousOpts })
+}
+
+func TestDownloadFromMaxMind(t *testing.T) {
+	archive := maxMindTarball(t, map[string]string{
+		"GeoLite2-City_20250601/LICENSE.txt":        "license",
+		"GeoLite2-City_20250601/GeoLite2-City.mmdb": "mmdb-bytes",
+	})
+	serveMaxMind(t, archive, fmt.Sprintf("%x", sha256.Sum256(archive)))
+
+	path := filepath.Join(t.TempDir(), "GeoLite2-City.mmdb")
+	require.NoError(t, downloadDatabase(path))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "mmdb-bytes", string(content))
+}
+
+func TestDownloadFromMaxMindRejectsBadChecksum(t *testing.T) {
+	archive := maxMindTarball(t, map[string]string{"GeoLite2-City.mmdb": "mmdb-bytes"})
+	serveMaxMind(t, archive, fmt.Sprintf("%x", sha256.Sum256([]byte("something else"))))
+
+	path := filepath.Join(t.TempDir(), "GeoLite2-City.mmdb")
+	err := downloadDatabase(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "checksum mismatch")
+	assert.NoFileExists(t, path)
+}
+
+func TestDownloadFromMaxMindWithoutDatabase(t *testing.T) {
+	archive := maxMindTarball(t, map[string]string{"README.txt": "nothing here"})
+	serveMaxMind(t, archive, fmt.Sprintf("%x", sha256.Sum256(archive)))
+
+	err := downloadDatabase(filepath.Join(t.TempDir(), "GeoLite2-City.mmdb"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no .mmdb file")
+}
+
+func TestDownloadFromMaxMindHidesLicenseKey(t *testing.T) {
+	serveMaxMind(t, nil, "")
+	opts.LicenseKey = "wrong-key"
+
+	err := downloadDatabase(filepath.Join(t.TempDir(), "GeoLite2-City.mmdb"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 401")
+	assert.NotContains(t, err.Error(), "wrong-key")
+}
+
+func TestUpdateKeepsDatabaseWhenDownloadIsInvalid(t *testing.T) {
+	archive := maxMindTarball(t, map[string]string{"GeoLite2-City.mmdb": "not a maxmind database"})
+	serveMaxMind(t, archive, fmt.Sprintf("%x", sha256.Sum256(archive)))
+
+	dir := t.TempDir()
+	previousPath := dbPath
+	dbPath = filepath.Join(dir, "GeoLite2-City.mmdb")
+	t.Cleanup(func() { dbPath = previousPath })
+	require.NoError(t, os.WriteFile(dbPath, []byte("current"), 0644))
+
+	err := Update()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid")
+	content, err := os.ReadFile(dbPath)
+	require.NoError(t, err)
+	assert.Equal(t, "current", string(content))
+	assert.NoFileExists(t, dbPath+".download")
+}
+
+func TestLookupASNWithoutDatabase(t *testing.T) {
+	asn, org := LookupASN("8.8.8.8")
+	assert.Zero(t, asn)
+	assert.Empty(t, org)
+}
+
+func TestDownloadASNDatabase(t *testing.T) {
+	archive := maxMindTarball(t, map[string]string{"GeoLite2-ASN_20250601/GeoLite2-ASN.mmdb": "asn-bytes"})
+	serveMaxMind(t, archive, fmt.Sprintf("%x", sha256.Sum256(archive)))
+	opts.Provider = MaxMind
+
+	path := filepath.Join(t.TempDir(), asnFileName(MaxMind))
+	require.NoError(t, downloadASNDatabase(path))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "asn-bytes", string(content))
+
+	// GeoLite2-ASN needs a license key; IP2Location has no free ASN file
+	opts = Options{Provider: MaxMind}
+	assert.False(t, hasASNSource())
+	assert.ErrorIs(t, downloadASNDatabase(path), errNoASNSource)
+	opts = Options{Provider: IP2Location, IP2LocationToken: "token"}
+	assert.ErrorIs(t, downloadASNDatabase(path), errNoASNSource)
+	opts = Options{Provider: DBIP}
+	assert.True(t, hasASNSource())
+	assert.Equal(t, "dbip-asn-lite.mmdb", asnFileName(DBIP))
+}
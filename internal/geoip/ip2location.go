@@ -0,0 +1,228 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+)
+
+// ip2locationHeaderSize covers the fields of the BIN header read here
+const ip2locationHeaderSize = 30
+
+// ip2locationDB reads an IP2Location BIN database.
+//
+// The file starts with a header of table sizes and offsets (offsets are
+// 1-based), then the IPv4 and IPv6 tables: rows of ip_from followed by one
+// 4-byte pointer per column, sorted so an address belongs to the row whose
+// ip_from is the last one not above it. Pointers lead to length-prefixed
+// strings. Optional index tables narrow the search to a /16 (IPv4) or the
+// first 16 bits (IPv6). All integers are little-endian.
+type ip2locationDB struct {
+	f *os.File
+
+	dbType   uint8 // DB1 (country) to DB26; region and city from DB3
+	dbColumn uint8
+
+	ipv4Count, ipv4Addr, ipv4Index uint32
+	ipv6Count, ipv6Addr, ipv6Index uint32
+}
+
+// openIP2Location opens an IP2Location BIN file
+func openIP2Location(path string) (*ip2locationDB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, ip2locationHeaderSize)
+	if _, err := f.ReadAt(header, 0); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("invalid IP2Location BIN file: %w", err)
+	}
+
+	db := &ip2locationDB{
+		f:         f,
+		dbType:    header[0],
+		dbColumn:  header[1],
+		ipv4Count: binary.LittleEndian.Uint32(header[5:]),
+		ipv4Addr:  binary.LittleEndian.Uint32(header[9:]),
+		ipv6Count: binary.LittleEndian.Uint32(header[13:]),
+		ipv6Addr:  binary.LittleEndian.Uint32(header[17:]),
+		ipv4Index: binary.LittleEndian.Uint32(header[21:]),
+		ipv6Index: binary.LittleEndian.Uint32(header[25:]),
+	}
+	year, productCode := header[2], header[29]
+	// Product code 1 is IP2Location (2 is IP2Proxy); files from before 2021
+	// left it zero
+	if db.dbType == 0 || db.dbColumn < 2 || (productCode != 1 && !(productCode == 0 && year <= 20)) {
+		_ = f.Close()
+		return nil, fmt.Errorf("invalid IP2Location BIN file: not an IP2Location database")
+	}
+	return db, nil
+}
+
+func (db *ip2locationDB) Lookup(ip net.IP) (Location, error) {
+	var row []byte
+	var err error
+	if v4 := ip.To4(); v4 != nil {
+		row, err = db.findIPv4(binary.BigEndian.Uint32(v4))
+	} else if v6 := ip.To16(); v6 != nil {
+		row, err = db.findIPv6(v6)
+	} else {
+		return Location{}, fmt.Errorf("invalid IP address")
+	}
+	if err != nil || row == nil {
+		return Location{}, err
+	}
+
+	// Columns after ip_from: country, then region and city from DB3 on
+	var loc Location
+	if loc.Country, err = db.column(row, 0); err != nil {
+		return Location{}, err
+	}
+	if db.dbType >= 3 && len(row) >= 12 {
+		if loc.Region, err = db.column(row, 1); err != nil {
+			return Location{}, err
+		}
+		if loc.City, err = db.column(row, 2); err != nil {
+			return Location{}, err
+		}
+	}
+	return loc, nil
+}
+
+func (db *ip2locationDB) Close() error {
+	return db.f.Close()
+}
+
+// findIPv4 returns the columns of the row holding ip, or nil
+func (db *ip2locationDB) findIPv4(ip uint32) ([]byte, error) {
+	if db.ipv4Count == 0 {
+		return nil, nil
+	}
+	// The last row's upper bound is the broadcast address itself
+	if ip == math.MaxUint32 {
+		ip--
+	}
+	colSize := uint32(db.dbColumn) * 4
+
+	low, high := uint32(0), db.ipv4Count
+	if db.ipv4Index > 0 {
+		bounds, err := db.read(db.ipv4Index+(ip>>16)*8, 8)
+		if err != nil {
+			return nil, err
+		}
+		low, high = binary.LittleEndian.Uint32(bounds), binary.LittleEndian.Uint32(bounds[4:])
+	}
+
+	for low <= high {
+		mid := low + (high-low)/2
+		// The row and the ip_from of the next one, its upper bound
+		buf, err := db.read(db.ipv4Addr+mid*colSize, colSize+4)
+		if err != nil {
+			return nil, err
+		}
+		from := binary.LittleEndian.Uint32(buf)
+		to := binary.LittleEndian.Uint32(buf[colSize:])
+		switch {
+		case ip >= from && ip < to:
+			return buf[4:colSize], nil
+		case ip < from:
+			if mid == 0 {
+				return nil, nil
+			}
+			high = mid - 1
+		default:
+			low = mid + 1
+		}
+	}
+	return nil, nil
+}
+
+// findIPv6 returns the columns of the row holding ip (16 bytes), or nil
+func (db *ip2locationDB) findIPv6(ip net.IP) ([]byte, error) {
+	if db.ipv6Count == 0 {
+		return nil, nil
+	}
+	colSize := 16 + (uint32(db.dbColumn)-1)*4
+
+	low, high := uint32(0), db.ipv6Count
+	if db.ipv6Index > 0 {
+		bounds, err := db.read(db.ipv6Index+uint32(binary.BigEndian.Uint16(ip))*8, 8)
+		if err != nil {
+			return nil, err
+		}
+		low, high = binary.LittleEndian.Uint32(bounds), binary.LittleEndian.Uint32(bounds[4:])
+	}
+
+	for low <= high {
+		mid := low + (high-low)/2
+		buf, err := db.read(db.ipv6Addr+mid*colSize, colSize+16)
+		if err != nil {
+			return nil, err
+		}
+		from := bigEndian128(buf[:16])
+		to := bigEndian128(buf[colSize : colSize+16])
+		switch {
+		case bytes.Compare(ip, from) >= 0 && bytes.Compare(ip, to) < 0:
+			return buf[16:colSize], nil
+		case bytes.Compare(ip, from) < 0:
+			if mid == 0 {
+				return nil, nil
+			}
+			high = mid - 1
+		default:
+			low = mid + 1
+		}
+	}
+	return nil, nil
+}
+
+// column reads the string the i-th pointer of row leads to. IP2Location
+// writes "-" for unknown values.
+func (db *ip2locationDB) column(row []byte, i int) (string, error) {
+	s, err := db.readString(binary.LittleEndian.Uint32(row[i*4:]))
+	if s == "-" {
+		s = ""
+	}
+	return s, err
+}
+
+// read returns n bytes at the 1-based offset pos
+func (db *ip2locationDB) read(pos, n uint32) ([]byte, error) {
+	if pos == 0 {
+		return nil, fmt.Errorf("invalid IP2Location BIN file: zero offset")
+	}
+	buf := make([]byte, n)
+	if _, err := db.f.ReadAt(buf, int64(pos)-1); err != nil {
+		return nil, fmt.Errorf("invalid IP2Location BIN file: %w", err)
+	}
+	return buf, nil
+}
+
+// readString reads the length-prefixed string at the 0-based offset pos
+func (db *ip2locationDB) readString(pos uint32) (string, error) {
+	buf := make([]byte, 256)
+	n, err := db.f.ReadAt(buf, int64(pos))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	if n == 0 || int(buf[0]) >= n {
+		return "", fmt.Errorf("invalid IP2Location BIN file: string out of range")
+	}
+	return string(buf[1 : 1+int(buf[0])]), nil
+}
+
+// bigEndian128 turns a little-endian 128-bit number into the byte order of
+// net.IP, so addresses compare with bytes.Compare
+func bigEndian128(le []byte) []byte {
+	be := make([]byte, 16)
+	for i := range be {
+		be[i] = le[15-i]
+	}
+	return be
+}
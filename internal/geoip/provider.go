@@ -0,0 +1,107 @@
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// Database providers, selected with Options.Provider
+const (
+	// MaxMind is GeoLite2-City (the default)
+	MaxMind = "maxmind"
+	// DBIP is DB-IP's IP to City Lite, an MMDB file under CC BY 4.0
+	DBIP = "dbip"
+	// IP2Location is an IP2Location LITE BIN database (DB3 or richer)
+	IP2Location = "ip2location"
+)
+
+// Location is where an IP address is. Fields the database doesn't know are
+// empty.
+type Location struct {
+	Country string // ISO 3166-1 alpha-2 code
+	Region  string
+	City    string
+}
+
+// Provider looks up IP addresses in an open database
+type Provider interface {
+	Lookup(ip net.IP) (Location, error)
+	Close() error
+}
+
+// ParseProvider validates a provider name; empty means MaxMind
+func ParseProvider(name string) (string, error) {
+	switch name = strings.ToLower(strings.TrimSpace(name)); name {
+	case "":
+		return MaxMind, nil
+	case MaxMind, DBIP, IP2Location:
+		return name, nil
+	default:
+		return "", fmt.Errorf("invalid geoip provider: %s (use maxmind, dbip or ip2location)", name)
+	}
+}
+
+// fileName is the database file of a provider in the data directory
+func fileName(provider string) string {
+	switch provider {
+	case DBIP:
+		return "dbip-city-lite.mmdb"
+	case IP2Location:
+		return "IP2LOCATION-LITE-DB3.IPV6.BIN"
+	default:
+		return "GeoLite2-City.mmdb"
+	}
+}
+
+// DatabaseFile returns where Init keeps the database of a provider ("" for
+// the default) in dataDir
+func DatabaseFile(dataDir, provider string) (string, error) {
+	name, err := ParseProvider(provider)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, fileName(name)), nil
+}
+
+// openProvider opens the database at path with the reader of provider
+func openProvider(provider, path string) (Provider, error) {
+	if provider == IP2Location {
+		return openIP2Location(path)
+	}
+	// DB-IP publishes its Lite databases in the GeoIP2 City layout
+	reader, err := geoip2.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &mmdbProvider{reader: reader}, nil
+}
+
+// mmdbProvider reads MaxMind DB files (GeoLite2, DB-IP)
+type mmdbProvider struct {
+	reader *geoip2.Reader
+}
+
+func (p *mmdbProvider) Lookup(ip net.IP) (Location, error) {
+	record, err := p.reader.City(ip)
+	if err != nil {
+		return Location{}, err
+	}
+	loc := Location{
+		// Keep country empty if not found (don't use "Unknown" - session.country is CHAR(2))
+		Country: record.Country.IsoCode,
+		City:    record.City.Names["en"],
+	}
+	// Handle subdivisions safely - only access if present
+	if len(record.Subdivisions) > 0 {
+		loc.Region = record.Subdivisions[0].Names["en"]
+	}
+	return loc, nil
+}
+
+func (p *mmdbProvider) Close() error {
+	return p.reader.Close()
+}
@@ -0,0 +1,193 @@
+package geoip
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProvider(t *testing.T) {
+	for value, want := range map[string]string{"": MaxMind, "MaxMind": MaxMind, " dbip ": DBIP, "ip2location": IP2Location} {
+		got, err := ParseProvider(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, want, got, value)
+	}
+	_, err := ParseProvider("ipinfo")
+	assert.Error(t, err)
+}
+
+// binRange is one row of a test IP2Location database
+type binRange struct {
+	from                  net.IP
+	country, region, city string
+}
+
+// writeIP2LocationBIN writes a DB3 BIN file (country, region, city) with the
+// given IPv4 and IPv6 ranges, each ending where the next one starts
+func writeIP2LocationBIN(t *testing.T, path string, v4, v6 []binRange) {
+	t.Helper()
+	const headerSize = 64
+	v4ColSize, v6ColSize := 16, 16+3*4
+	v4Addr := headerSize + 1
+	// One extra row per table holds the upper bound of the last range
+	v6Addr := v4Addr + (len(v4)+1)*v4ColSize
+	stringsAt := v6Addr - 1 + (len(v6)+1)*v6ColSize
+
+	var strs bytes.Buffer
+	pointer := func(s string) []byte {
+		p := make([]byte, 4)
+		binary.LittleEndian.PutUint32(p, uint32(stringsAt+strs.Len()))
+		strs.WriteByte(byte(len(s)))
+		strs.WriteString(s)
+		return p
+	}
+	columns := func(r binRange) []byte {
+		country := pointer(r.country)
+		pointer("Long " + r.country)
+		return append(append(country, pointer(r.region)...), pointer(r.city)...)
+	}
+
+	header := make([]byte, headerSize)
+	header[0], header[1], header[2], header[3], header[4] = 3, 4, 25, 6, 1
+	binary.LittleEndian.PutUint32(header[5:], uint32(len(v4)))
+	binary.LittleEndian.PutUint32(header[9:], uint32(v4Addr))
+	binary.LittleEndian.PutUint32(header[13:], uint32(len(v6)))
+	binary.LittleEndian.PutUint32(header[17:], uint32(v6Addr))
+	header[29] = 1
+
+	var tables bytes.Buffer
+	for _, r := range append(v4, binRange{from: net.IPv4bcast, country: "-", region: "-", city: "-"}) {
+		from := make([]byte, 4)
+		binary.LittleEndian.PutUint32(from, binary.BigEndian.Uint32(r.from.To4()))
+		tables.Write(from)
+		tables.Write(columns(r))
+	}
+	last := net.ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")
+	for _, r := range append(v6, binRange{from: last, country: "-", region: "-", city: "-"}) {
+		tables.Write(bigEndian128(r.from.To16()))
+		tables.Write(columns(r))
+	}
+
+	file := append(append(header, tables.Bytes()...), strs.Bytes()...)
+	require.NoError(t, os.WriteFile(path, file, 0644))
+}
+
+func TestIP2LocationLookup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "IP2LOCATION-LITE-DB3.IPV6.BIN")
+	writeIP2LocationBIN(t, path,
+		[]binRange{
+			{from: net.ParseIP("0.0.0.0"), country: "-", region: "-", city: "-"},
+			{from: net.ParseIP("8.8.8.0"), country: "US", region: "California", city: "Mountain View"},
+			{from: net.ParseIP("8.8.9.0"), country: "-", region: "-", city: "-"},
+			{from: net.ParseIP("81.2.69.0"), country: "GB", region: "England", city: "London"},
+			{from: net.ParseIP("81.2.70.0"), country: "-", region: "-", city: "-"},
+		},
+		[]binRange{
+			{from: net.ParseIP("::"), country: "-", region: "-", city: "-"},
+			{from: net.ParseIP("2a02:ec80::"), country: "DE", region: "Hessen", city: "Frankfurt am Main"},
+			{from: net.ParseIP("2a02:ec81::"), country: "-", region: "-", city: "-"},
+		})
+
+	db, err := openProvider(IP2Location, path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	for ip, want := range map[string]Location{
+		"8.8.8.8":        {Country: "US", Region: "California", City: "Mountain View"},
+		"8.8.8.255":      {Country: "US", Region: "California", City: "Mountain View"},
+		"8.8.9.0":        {},
+		"81.2.69.160":    {Country: "GB", Region: "England", City: "London"},
+		"1.1.1.1":        {},
+		"2a02:ec80::1":   {Country: "DE", Region: "Hessen", City: "Frankfurt am Main"},
+		"2001:db8::1":    {},
+		"::ffff:8.8.8.8": {Country: "US", Region: "California", City: "Mountain View"},
+	} {
+		got, err := db.Lookup(net.ParseIP(ip))
+		require.NoError(t, err, ip)
+		assert.Equal(t, want, got, ip)
+	}
+}
+
+func TestIP2LocationRejectsOtherFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "GeoLite2-City.mmdb")
+	require.NoError(t, os.WriteFile(path, bytes.Repeat([]byte{0}, 64), 0644))
+	_, err := openProvider(IP2Location, path)
+	assert.ErrorContains(t, err, "not an IP2Location database")
+
+	_, err = openProvider(DBIP, path)
+	assert.Error(t, err)
+}
+
+func TestDownloadFromDBIPFallsBackToPreviousMonth(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, _ = w.Write([]byte("mmdb-bytes"))
+	require.NoError(t, w.Close())
+
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		if r.URL.Path != "/dbip-city-lite-2025-05.mmdb.gz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(gz.Bytes())
+	}))
+	t.Cleanup(server.Close)
+	previous := dbipURL
+	dbipURL = server.URL
+	t.Cleanup(func() { dbipURL = previous })
+
+	path := filepath.Join(t.TempDir(), "dbip-city-lite.mmdb")
+	require.NoError(t, downloadFromDBIP(path, "city", time.Date(2025, 6, 1, 3, 0, 0, 0, time.UTC)))
+	assert.Equal(t, []string{"/dbip-city-lite-2025-06.mmdb.gz", "/dbip-city-lite-2025-05.mmdb.gz"}, requested)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "mmdb-bytes", string(content))
+}
+
+func TestDownloadFromIP2Location(t *testing.T) {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for name, content := range map[string]string{"README_LITE.TXT": "readme", "IP2LOCATION-LITE-DB3.IPV6.BIN": "bin-bytes"} {
+		f, err := zw.Create(name)
+		require.NoError(t, err)
+		_, _ = f.Write([]byte(content))
+	}
+	require.NoError(t, zw.Close())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("token") != "test-token" || r.URL.Query().Get("file") != "DB3LITEBINIPV6" {
+			_, _ = w.Write([]byte("NO PERMISSION"))
+			return
+		}
+		_, _ = w.Write(archive.Bytes())
+	}))
+	t.Cleanup(server.Close)
+	previous := ip2locationURL
+	ip2locationURL = server.URL
+	t.Cleanup(func() { ip2locationURL = previous })
+
+	path := filepath.Join(t.TempDir(), "IP2LOCATION-LITE-DB3.IPV6.BIN")
+	require.NoError(t, downloadFromIP2Location(path, "test-token"))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "bin-bytes", string(content))
+
+	err = downloadFromIP2Location(path, "wrong-token")
+	assert.ErrorContains(t, err, "NO PERMISSION")
+	assert.NotContains(t, err.Error(), "wrong-token")
+
+	assert.ErrorContains(t, downloadFromIP2Location(path, ""), "token")
+}
@@ -0,0 +1,90 @@
+package groups
+
+import (
+	"context"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// memberRows is how many breakdown rows are read per member. A value
+// outside every member's top memberRows can be missing from a group's
+// breakdown, which only matters far down its long tail.
+const memberRows = 1000
+
+// DashboardStats adds up the dashboard stats of websites; the bounce rate is
+// the members' average weighted by their visitors
+func DashboardStats(ctx context.Context, st store.Store, websiteIDs []uuid.UUID, f store.Filters) (*store.DashboardStats, error) {
+	total := &store.DashboardStats{}
+	var bounced float64
+	for _, id := range websiteIDs {
+		s, err := st.DashboardStats(ctx, id, f)
+		if err != nil {
+			return nil, err
+		}
+		total.CurrentVisitors += s.CurrentVisitors
+		total.TodayPageviews += s.TodayPageviews
+		total.TodayVisitors += s.TodayVisitors
+		bounced += s.BounceRate * float64(s.TodayVisitors)
+	}
+	if total.TodayVisitors > 0 {
+		total.BounceRate = bounced / float64(total.TodayVisitors)
+	}
+	return total, nil
+}
+
+// TimeSeries adds up the time series of websites bucket by bucket
+func TimeSeries(ctx context.Context, st store.Store, websiteIDs []uuid.UUID, days int, interval store.Interval, f store.Filters) ([]store.TimePoint, error) {
+	views := map[string]int64{}
+	for _, id := range websiteIDs {
+		points, err := st.TimeSeries(ctx, id, days, interval, f)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range points {
+			views[p.Timestamp] += p.Views
+		}
+	}
+
+	points := make([]store.TimePoint, 0, len(views))
+	for ts, n := range views {
+		points = append(points, store.TimePoint{Timestamp: ts, Views: n})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Timestamp < points[j].Timestamp })
+	return points, nil
+}
+
+// Breakdown adds up a breakdown of websites by name and pages through the
+// sums like Store.Breakdown: by count, then name. The total is the number of
+// names.
+func Breakdown(ctx context.Context, st store.Store, websiteIDs []uuid.UUID, dimension string, days, limit, offset int, f store.Filters) ([]store.NamedCount, int64, error) {
+	counts := map[string]int64{}
+	for _, id := range websiteIDs {
+		rows, _, err := st.Breakdown(ctx, id, dimension, days, memberRows, 0, f)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, r := range rows {
+			counts[r.Name] += r.Count
+		}
+	}
+
+	items := make([]store.NamedCount, 0, len(counts))
+	for name, n := range counts {
+		items = append(items, store.NamedCount{Name: name, Count: n})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Count != items[j].Count {
+			return items[i].Count > items[j].Count
+		}
+		return items[i].Name < items[j].Name
+	})
+
+	total := int64(len(items))
+	if offset >= len(items) {
+		return []store.NamedCount{}, total, nil
+	}
+	return items[offset:min(offset+limit, len(items))], total, nil
+}
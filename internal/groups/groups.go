@@ -0,0 +1,191 @@
+// Package groups manages website groups: roll-up views that add several
+// websites together, such as all of a company's properties. A group's
+// overview, time series and breakdowns are the sums of its members' (see
+// aggregate.go), so they work on every store.
+//
+// Sessions belong to one website, so a visitor of two members counts twice,
+// as on the members' own dashboards. Groups live in PostgreSQL
+// (website_group, website_group_member); deleted websites drop out of them.
+package groups
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotFound is returned for a group name or ID that doesn't exist
+var ErrNotFound = errors.New("website group not found")
+
+// ErrExists is returned when creating a group under a name already taken
+var ErrExists = errors.New("website group already exists")
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+
+// Member is a website of a group
+type Member struct {
+	WebsiteID uuid.UUID `json:"website_id"`
+	Domain    string    `json:"domain"`
+}
+
+// Group is a named set of websites
+type Group struct {
+	ID        uuid.UUID `json:"group_id"`
+	Name      string    `json:"name"`
+	Members   []Member  `json:"members"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebsiteIDs returns the IDs of the group's websites
+func (g *Group) WebsiteIDs() []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(g.Members))
+	for _, m := range g.Members {
+		ids = append(ids, m.WebsiteID)
+	}
+	return ids
+}
+
+// ValidName checks that name can be saved: lowercase letters, digits, _ and
+// -, starting with a letter or digit
+func ValidName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid group name: %q (use lowercase letters, digits, _ and -, up to 50 characters)", name)
+	}
+	return nil
+}
+
+// Create adds an empty group
+func Create(ctx context.Context, db *sql.DB, name string) (*Group, error) {
+	if err := ValidName(name); err != nil {
+		return nil, err
+	}
+	g := &Group{Name: name, Members: []Member{}}
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO website_group (name) VALUES ($1)
+		ON CONFLICT (name) DO NOTHING
+		RETURNING group_id, created_at
+	`, name).Scan(&g.ID, &g.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create website group: %w", err)
+	}
+	return g, nil
+}
+
+// Delete removes a group; its websites stay
+func Delete(ctx context.Context, db *sql.DB, name string) error {
+	res, err := db.ExecContext(ctx, `DELETE FROM website_group WHERE name = $1`, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete website group: %w", err)
+	}
+	return affected(res)
+}
+
+// AddMember adds a website to a group; adding a member again does nothing
+func AddMember(ctx context.Context, db *sql.DB, name string, websiteID uuid.UUID) error {
+	res, err := db.ExecContext(ctx, `
+		INSERT INTO website_group_member (group_id, website_id)
+		SELECT group_id, $2 FROM website_group WHERE name = $1
+		ON CONFLICT (group_id, website_id) DO UPDATE SET added_at = website_group_member.added_at
+	`, name, websiteID)
+	if err != nil {
+		return fmt.Errorf("failed to add website to group: %w", err)
+	}
+	return affected(res)
+}
+
+// RemoveMember takes a website out of a group. ErrNotFound means the group
+// doesn't exist or the website isn't one of its members.
+func RemoveMember(ctx context.Context, db *sql.DB, name string, websiteID uuid.UUID) error {
+	res, err := db.ExecContext(ctx, `
+		DELETE FROM website_group_member m
+		USING website_group g
+		WHERE m.group_id = g.group_id AND g.name = $1 AND m.website_id = $2
+	`, name, websiteID)
+	if err != nil {
+		return fmt.Errorf("failed to remove website from group: %w", err)
+	}
+	return affected(res)
+}
+
+// Get returns the group of a name with its websites
+func Get(ctx context.Context, db *sql.DB, name string) (*Group, error) {
+	list, err := query(ctx, db, `g.name = $1`, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrNotFound
+	}
+	return &list[0], nil
+}
+
+// Find returns the group of an ID with its websites
+func Find(ctx context.Context, db *sql.DB, groupID uuid.UUID) (*Group, error) {
+	list, err := query(ctx, db, `g.group_id = $1`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrNotFound
+	}
+	return &list[0], nil
+}
+
+// List returns every group with its websites, by name
+func List(ctx context.Context, db *sql.DB) ([]Group, error) {
+	return query(ctx, db, `TRUE`)
+}
+
+// query reads the groups matching where, each with its websites by domain
+func query(ctx context.Context, db *sql.DB, where string, args ...interface{}) ([]Group, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT g.group_id, g.name, g.created_at, w.website_id, w.domain
+		FROM website_group g
+		LEFT JOIN website_group_member m ON m.group_id = g.group_id
+		LEFT JOIN website w ON w.website_id = m.website_id AND w.deleted_at IS NULL
+		WHERE `+where+`
+		ORDER BY g.name, w.domain
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list website groups: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	list := []Group{}
+	for rows.Next() {
+		var g Group
+		var websiteID uuid.NullUUID
+		var domain sql.NullString
+		if err := rows.Scan(&g.ID, &g.Name, &g.CreatedAt, &websiteID, &domain); err != nil {
+			return nil, fmt.Errorf("failed to read website group: %w", err)
+		}
+		if len(list) == 0 || list[len(list)-1].ID != g.ID {
+			g.Members = []Member{}
+			list = append(list, g)
+		}
+		if websiteID.Valid {
+			last := &list[len(list)-1]
+			last.Members = append(last.Members, Member{WebsiteID: websiteID.UUID, Domain: domain.String})
+		}
+	}
+	return list, rows.Err()
+}
+
+func affected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package groups
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/store"
+)
+
+func TestValidName(t *testing.T) {
+	assert.NoError(t, ValidName("acme"))
+	assert.NoError(t, ValidName("acme-eu_2"))
+	assert.Error(t, ValidName(""))
+	assert.Error(t, ValidName("Acme"))
+	assert.Error(t, ValidName("-acme"))
+}
+
+func TestCreateExisting(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectQuery(`INSERT INTO website_group`).WithArgs("acme").
+		WillReturnRows(sqlmock.NewRows([]string{"group_id", "created_at"}))
+	_, err = Create(context.Background(), db, "acme")
+	assert.ErrorIs(t, err, ErrExists)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddMemberUnknownGroup(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	websiteID := uuid.New()
+	mock.ExpectExec(`INSERT INTO website_group_member`).WithArgs("missing", websiteID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.ErrorIs(t, AddMember(context.Background(), db, "missing", websiteID), ErrNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestList(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	acme, empty := uuid.New(), uuid.New()
+	shop, blog := uuid.New(), uuid.New()
+	created := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`FROM website_group g`).
+		WillReturnRows(sqlmock.NewRows([]string{"group_id", "name", "created_at", "website_id", "domain"}).
+			AddRow(acme, "acme", created, blog, "blog.acme.com").
+			AddRow(acme, "acme", created, shop, "shop.acme.com").
+			AddRow(empty, "empty", created, nil, nil))
+
+	list, err := List(context.Background(), db)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "acme", list[0].Name)
+	assert.Equal(t, []uuid.UUID{blog, shop}, list[0].WebsiteIDs())
+	assert.Equal(t, "shop.acme.com", list[0].Members[1].Domain)
+	assert.Equal(t, "empty", list[1].Name)
+	assert.Empty(t, list[1].Members)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectQuery(`FROM website_group g`).WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows([]string{"group_id", "name", "created_at", "website_id", "domain"}))
+	_, err = Get(context.Background(), db, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// fakeStore answers each website's dashboard reads from maps
+type fakeStore struct {
+	store.Store
+	stats     map[uuid.UUID]store.DashboardStats
+	series    map[uuid.UUID][]store.TimePoint
+	breakdown map[uuid.UUID][]store.NamedCount
+}
+
+func (f *fakeStore) DashboardStats(ctx context.Context, websiteID uuid.UUID, filters store.Filters) (*store.DashboardStats, error) {
+	s := f.stats[websiteID]
+	return &s, nil
+}
+
+func (f *fakeStore) TimeSeries(ctx context.Context, websiteID uuid.UUID, days int, interval store.Interval, filters store.Filters) ([]store.TimePoint, error) {
+	return f.series[websiteID], nil
+}
+
+func (f *fakeStore) Breakdown(ctx context.Context, websiteID uuid.UUID, dimension string, days, limit, offset int, filters store.Filters) ([]store.NamedCount, int64, error) {
+	rows := f.breakdown[websiteID]
+	return rows, int64(len(rows)), nil
+}
+
+func TestAggregate(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	st := &fakeStore{
+		stats: map[uuid.UUID]store.DashboardStats{
+			a: {CurrentVisitors: 3, TodayPageviews: 100, TodayVisitors: 30, BounceRate: 40},
+			b: {CurrentVisitors: 1, TodayPageviews: 50, TodayVisitors: 10, BounceRate: 80},
+		},
+		series: map[uuid.UUID][]store.TimePoint{
+			a: {{Timestamp: "2025-06-01T00:00:00Z", Views: 5}, {Timestamp: "2025-06-01T01:00:00Z", Views: 7}},
+			b: {{Timestamp: "2025-06-01T01:00:00Z", Views: 2}, {Timestamp: "2025-06-01T02:00:00Z", Views: 4}},
+		},
+		breakdown: map[uuid.UUID][]store.NamedCount{
+			a: {{Name: "Chrome", Count: 20}, {Name: "Firefox", Count: 5}},
+			b: {{Name: "Safari", Count: 12}, {Name: "Firefox", Count: 10}},
+		},
+	}
+	ctx := context.Background()
+	ids := []uuid.UUID{a, b}
+
+	stats, err := DashboardStats(ctx, st, ids, store.Filters{})
+	require.NoError(t, err)
+	assert.Equal(t, &store.DashboardStats{CurrentVisitors: 4, TodayPageviews: 150, TodayVisitors: 40, BounceRate: 50}, stats)
+
+	points, err := TimeSeries(ctx, st, ids, 1, store.IntervalHour, store.Filters{})
+	require.NoError(t, err)
+	assert.Equal(t, []store.TimePoint{
+		{Timestamp: "2025-06-01T00:00:00Z", Views: 5},
+		{Timestamp: "2025-06-01T01:00:00Z", Views: 9},
+		{Timestamp: "2025-06-01T02:00:00Z", Views: 4},
+	}, points)
+
+	rows, total, err := Breakdown(ctx, st, ids, "browser", 1, 2, 0, store.Filters{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	assert.Equal(t, []store.NamedCount{{Name: "Chrome", Count: 20}, {Name: "Firefox", Count: 15}}, rows)
+
+	rows, _, err = Breakdown(ctx, st, ids, "browser", 1, 2, 2, store.Filters{})
+	require.NoError(t, err)
+	assert.Equal(t, []store.NamedCount{{Name: "Safari", Count: 12}}, rows)
+
+	rows, _, err = Breakdown(ctx, st, ids, "browser", 1, 2, 10, store.Filters{})
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+}
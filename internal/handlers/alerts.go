@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/alerts"
+	"github.com/seuros/kaunta/internal/database"
+)
+
+// HandleListAlerts lists the alert rules of a website with their state
+// GET /api/alerts/:website_id
+func HandleListAlerts(c fiber.Ctx) error {
+	websiteID, err := uuid.Parse(c.Params("website_id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid website ID"})
+	}
+	if database.DB == nil {
+		return c.Status(501).JSON(fiber.Map{"error": "Alerts require PostgreSQL"})
+	}
+	rules, err := alerts.List(c.Context(), database.DB, websiteID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to list alert rules"})
+	}
+	if rules == nil {
+		rules = []*alerts.Rule{}
+	}
+	return c.JSON(rules)
+}
+
+// HandleSaveAlert adds a rule to a website, replacing its rule of the same
+// name. The body is a rule: name, metric, condition, threshold, window (e.g.
+// "1h"), channel, target and optionally enabled (default true).
+// POST /api/alerts/:website_id
+func HandleSaveAlert(c fiber.Ctx) error {
+	websiteID, err := uuid.Parse(c.Params("website_id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid website ID"})
+	}
+	if database.DB == nil {
+		return c.Status(501).JSON(fiber.Map{"error": "Alerts require PostgreSQL"})
+	}
+
+	rule := &alerts.Rule{Enabled: true}
+	if err := json.Unmarshal(c.Body(), rule); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid alert rule: " + err.Error()})
+	}
+	rule.WebsiteID = websiteID
+	if err := rule.Validate(); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var exists bool
+	if err := database.DB.QueryRowContext(c.Context(),
+		`SELECT EXISTS (SELECT 1 FROM website WHERE website_id = $1 AND deleted_at IS NULL)`,
+		websiteID).Scan(&exists); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to look up website"})
+	}
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{"error": "Website not found"})
+	}
+
+	if err := alerts.Save(c.Context(), database.DB, rule); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save alert rule"})
+	}
+	return c.Status(201).JSON(rule)
+}
+
+// HandleDeleteAlert deletes a rule of a website
+// DELETE /api/alerts/:website_id/:name
+func HandleDeleteAlert(c fiber.Ctx) error {
+	websiteID, err := uuid.Parse(c.Params("website_id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid website ID"})
+	}
+	if database.DB == nil {
+		return c.Status(501).JSON(fiber.Map{"error": "Alerts require PostgreSQL"})
+	}
+	err = alerts.Remove(c.Context(), database.DB, websiteID, c.Params("name"))
+	if errors.Is(err, alerts.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Alert rule not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete alert rule"})
+	}
+	return c.SendStatus(204)
+}
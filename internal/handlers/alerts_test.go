@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleSaveAlert(t *testing.T) {
+	websiteID := uuid.New()
+	ruleID := uuid.New()
+	responses := []mockResponse{
+		{
+			match:   "SELECT EXISTS (SELECT 1 FROM website",
+			columns: []string{"exists"},
+			rows:    [][]interface{}{{true}},
+			args:    []interface{}{websiteID},
+		},
+		{
+			match:   "INSERT INTO alert_rule",
+			columns: []string{"rule_id", "created_at"},
+			rows:    [][]interface{}{{ruleID.String(), time.Now()}},
+			args:    []interface{}{websiteID, "traffic-drop", "pageviews", "drop", 50.0, 60, "webhook", "https://example.com/hook", true},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/alerts/:website_id", HandleSaveAlert, responses)
+	defer cleanup()
+	app.Post("/api/alerts/:website_id", HandleSaveAlert)
+
+	body := `{"name":"traffic-drop","metric":"pageviews","condition":"drop","threshold":50,"window":"1h",` +
+		`"channel":"webhook","target":"https://example.com/hook"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/alerts/"+websiteID.String(), strings.NewReader(body))
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	var rule map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&rule))
+	assert.Equal(t, ruleID.String(), rule["rule_id"])
+	assert.Equal(t, "1h", rule["window"])
+	assert.Equal(t, true, rule["enabled"])
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleSaveAlertInvalid(t *testing.T) {
+	app, queue, cleanup := setupFiberTest(t, "/api/alerts/:website_id", HandleSaveAlert, nil)
+	defer cleanup()
+	app.Post("/api/alerts/:website_id", HandleSaveAlert)
+
+	body := `{"name":"traffic-drop","metric":"pageviews","condition":"drop","threshold":150,"window":"1h",` +
+		`"channel":"webhook","target":"https://example.com/hook"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/alerts/"+uuid.NewString(), strings.NewReader(body))
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleDeleteAlertInvalidWebsiteID(t *testing.T) {
+	app, _, cleanup := setupFiberTest(t, "/api/alerts/:website_id/:name", HandleDeleteAlert, nil)
+	defer cleanup()
+	app.Delete("/api/alerts/:website_id/:name", HandleDeleteAlert)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/alerts/not-a-uuid/traffic-drop", nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/annotations"
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/middleware"
+)
+
+// HandleListAnnotations lists the annotations of a website's last days
+// (default 7, max 90)
+// GET /api/annotations/:website_id?days=7
+func HandleListAnnotations(c fiber.Ctx) error {
+	websiteID, err := uuid.Parse(c.Params("website_id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid website ID"})
+	}
+	if database.DB == nil {
+		return c.Status(501).JSON(fiber.Map{"error": "Annotations require PostgreSQL"})
+	}
+	list, err := annotations.LastDays(c.Context(), database.DB, websiteID, noteDays(c), time.Now())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to list annotations"})
+	}
+	return c.JSON(list)
+}
+
+// HandleAddAnnotation marks a moment of a website, signed by the signed-in
+// user. The body is {"label": "v2.3.0", "kind": "deploy", "at":
+// "2025-06-15T14:00:00Z"}; kind defaults to other and at to now.
+// POST /api/annotations/:website_id
+func HandleAddAnnotation(c fiber.Ctx) error {
+	websiteID, err := uuid.Parse(c.Params("website_id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid website ID"})
+	}
+	if database.DB == nil {
+		return c.Status(501).JSON(fiber.Map{"error": "Annotations require PostgreSQL"})
+	}
+
+	var body struct {
+		Label string     `json:"label"`
+		Kind  string     `json:"kind"`
+		At    *time.Time `json:"at"`
+	}
+	if err := json.Unmarshal(c.Body(), &body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid annotation: " + err.Error()})
+	}
+	if _, err := annotations.Validate(body.Label); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if _, err := annotations.ParseKind(body.Kind); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	at := time.Now()
+	if body.At != nil {
+		at = *body.At
+	}
+
+	var exists bool
+	if err := database.DB.QueryRowContext(c.Context(),
+		`SELECT EXISTS (SELECT 1 FROM website WHERE website_id = $1 AND deleted_at IS NULL)`,
+		websiteID).Scan(&exists); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to look up website"})
+	}
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{"error": "Website not found"})
+	}
+
+	author := ""
+	if user := middleware.GetUser(c); user != nil {
+		author = user.Username
+	}
+	annotation, err := annotations.Add(c.Context(), database.DB, websiteID, at, body.Label, body.Kind, author)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to add annotation"})
+	}
+	return c.Status(201).JSON(annotation)
+}
+
+// HandleDeleteAnnotation deletes an annotation of a website
+// DELETE /api/annotations/:website_id/:annotation_id
+func HandleDeleteAnnotation(c fiber.Ctx) error {
+	websiteID, err := uuid.Parse(c.Params("website_id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid website ID"})
+	}
+	annotationID, err := uuid.Parse(c.Params("annotation_id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid annotation ID"})
+	}
+	if database.DB == nil {
+		return c.Status(501).JSON(fiber.Map{"error": "Annotations require PostgreSQL"})
+	}
+	err = annotations.Remove(c.Context(), database.DB, websiteID, annotationID)
+	if errors.Is(err, annotations.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Annotation not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete annotation"})
+	}
+	return c.SendStatus(204)
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/middleware"
+)
+
+func TestHandleAddAnnotation(t *testing.T) {
+	websiteID, annotationID := uuid.New(), uuid.New()
+	at := time.Date(2025, 6, 15, 14, 0, 0, 0, time.UTC)
+	responses := []mockResponse{
+		{
+			match:   "SELECT EXISTS (SELECT 1 FROM website",
+			columns: []string{"exists"},
+			rows:    [][]interface{}{{true}},
+			args:    []interface{}{websiteID},
+		},
+		{
+			match:   "INSERT INTO annotation",
+			columns: []string{"annotation_id", "created_at"},
+			rows:    [][]interface{}{{annotationID.String(), time.Now()}},
+			args:    []interface{}{websiteID, at, "v2.3.0", "deploy", "ci"},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/annotations/:website_id", HandleListAnnotations, responses)
+	defer cleanup()
+	app.Post("/api/annotations/:website_id", func(c fiber.Ctx) error {
+		c.Locals("user", &middleware.UserContext{Username: "ci"})
+		return HandleAddAnnotation(c)
+	})
+
+	body := `{"label":" v2.3.0 ","kind":"Deploy","at":"2025-06-15T16:00:00+02:00"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/annotations/"+websiteID.String(), strings.NewReader(body))
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	var annotation map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&annotation))
+	assert.Equal(t, annotationID.String(), annotation["annotation_id"])
+	assert.Equal(t, "2025-06-15T14:00:00Z", annotation["at"])
+	assert.Equal(t, "deploy", annotation["kind"])
+	assert.Equal(t, "ci", annotation["author"])
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleAddAnnotationInvalid(t *testing.T) {
+	app, queue, cleanup := setupFiberTest(t, "/api/annotations/:website_id", HandleListAnnotations, nil)
+	defer cleanup()
+	app.Post("/api/annotations/:website_id", HandleAddAnnotation)
+
+	for _, body := range []string{
+		`{"label":"   "}`,
+		`{"label":"` + strings.Repeat("x", 101) + `"}`,
+		`{"label":"v2.3.0","kind":"release"}`,
+		`{"label":"v2.3.0","at":"2025-06-15 14:00"}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/annotations/"+uuid.NewString(), strings.NewReader(body))
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+	}
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleListAnnotations(t *testing.T) {
+	websiteID := uuid.New()
+	responses := []mockResponse{
+		{
+			match:   "FROM annotation",
+			columns: []string{"annotation_id", "website_id", "annotated_at", "label", "kind", "author", "created_at"},
+			rows: [][]interface{}{
+				{uuid.NewString(), websiteID.String(), time.Now().Add(-time.Hour), "Summer sale", "campaign", "", time.Now()},
+			},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/annotations/:website_id", HandleListAnnotations, responses)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/annotations/"+websiteID.String()+"?days=30", nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var list []map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	require.Len(t, list, 1)
+	assert.Equal(t, "Summer sale", list[0]["label"])
+	assert.Equal(t, "campaign", list[0]["kind"])
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleDeleteAnnotationInvalidAnnotationID(t *testing.T) {
+	app, _, cleanup := setupFiberTest(t, "/api/annotations/:website_id/:annotation_id", HandleDeleteAnnotation, nil)
+	defer cleanup()
+	app.Delete("/api/annotations/:website_id/:annotation_id", HandleDeleteAnnotation)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/annotations/"+uuid.NewString()+"/1", nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"database/sql"
@@ -13,9 +14,9 @@ import (
 	"github.com/gofiber/fiber/v3/middleware/csrf"
 	"github.com/google/uuid"
 
-	"github.com/seuros/kaunta/internal/database"
 	"github.com/seuros/kaunta/internal/logging"
 	"github.com/seuros/kaunta/internal/middleware"
+	"github.com/seuros/kaunta/internal/store"
 	"go.uber.org/zap"
 )
 
@@ -240,66 +241,44 @@ func HandleMe(c fiber.Ctx) error {
 }
 
 func fetchUserFromDB(username string) (*userRecord, error) {
-	query := `
-		SELECT user_id, username, name, password_hash
-		FROM users
-		WHERE username = $1
-	`
-
-	var record userRecord
-	err := database.DB.QueryRow(query, username).Scan(
-		&record.UserID,
-		&record.Username,
-		&record.Name,
-		&record.PasswordHash,
-	)
+	user, err := store.Current().FindUser(context.Background(), username)
 	if err != nil {
 		return nil, err
 	}
-	return &record, nil
+	return &userRecord{
+		UserID:       user.UserID,
+		Username:     user.Username,
+		Name:         user.Name,
+		PasswordHash: user.PasswordHash,
+	}, nil
 }
 
 func verifyPasswordInDB(password, passwordHash string) (bool, error) {
-	var passwordValid bool
-	err := database.DB.QueryRow("SELECT verify_password($1, $2)", password, passwordHash).Scan(&passwordValid)
-	if err != nil {
-		return false, err
-	}
-	return passwordValid, nil
+	return store.Current().VerifyPassword(context.Background(), password, passwordHash)
 }
 
 func insertSessionInDB(sessionID uuid.UUID, userID uuid.UUID, tokenHash string, expiresAt time.Time, userAgent, ipAddress string) error {
-	insertQuery := `
-		INSERT INTO user_sessions (session_id, user_id, token_hash, expires_at, user_agent, ip_address)
-		VALUES ($1, $2, $3, $4, $5, $6)
-	`
-
-	// Handle empty IP address (e.g., from Docker networking)
-	var ipParam interface{} = ipAddress
-	if ipAddress == "" {
-		ipParam = nil
-	}
-
-	_, err := database.DB.Exec(insertQuery, sessionID, userID, tokenHash, expiresAt, userAgent, ipParam)
-	return err
+	// Empty IP address (e.g., from Docker networking) is stored as NULL
+	return store.Current().CreateUserSession(context.Background(), &store.UserSession{
+		SessionID: sessionID,
+		UserID:    userID,
+		TokenHash: tokenHash,
+		ExpiresAt: expiresAt,
+		UserAgent: userAgent,
+		IPAddress: ipAddress,
+	})
 }
 
 func deleteSessionInDB(sessionID uuid.UUID) error {
-	query := `DELETE FROM user_sessions WHERE session_id = $1`
-	_, err := database.DB.Exec(query, sessionID)
-	return err
+	return store.Current().DeleteUserSession(context.Background(), sessionID)
 }
 
 func fetchUserDetailsFromDB(userID uuid.UUID) (sql.NullString, time.Time, error) {
-	var name sql.NullString
-	var createdAt time.Time
-
-	query := `SELECT name, created_at FROM users WHERE user_id = $1`
-	err := database.DB.QueryRow(query, userID).Scan(&name, &createdAt)
+	user, err := store.Current().FindUserByID(context.Background(), userID)
 	if err != nil {
 		return sql.NullString{}, time.Time{}, err
 	}
-	return name, createdAt, nil
+	return user.Name, user.CreatedAt, nil
 }
 
 // generateSessionToken creates a random session token and its hash
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/logging"
+	"github.com/seuros/kaunta/internal/privacy"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// transparentGIF is a 1x1 transparent GIF
+var transparentGIF = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// HandleBaselinePixel serves GET /b/:website_id, a plain <img> beacon that
+// content blockers rarely target. It records the visitor under the session
+// the JavaScript tracker would use, so `kaunta stats blockers` can tell how
+// many pixel visitors never reached the tracker. It always answers with the
+// image so a failure never shows up as a broken image on the page.
+func HandleBaselinePixel(c fiber.Ctx) error {
+	c.Set("Content-Type", "image/gif")
+	c.Set("Cache-Control", "no-store, max-age=0")
+
+	websiteID, err := uuid.Parse(strings.TrimSuffix(c.Params("website_id"), ".gif"))
+	if err != nil || isSpeculative(speculativePurpose(c, nil)) {
+		return c.Send(transparentGIF)
+	}
+
+	db := store.Current()
+	ctx := c.Context()
+
+	settings, err := db.WebsiteSettings(ctx, websiteID)
+	if err != nil {
+		return c.Send(transparentGIF)
+	}
+
+	switch doNotTrackAction(c, settings) {
+	case RespectDNTDrop:
+		return c.Send(transparentGIF)
+	case RespectDNTAnonymize:
+		ctx = privacy.WithMode(ctx, privacy.Hash)
+	}
+
+	ip := getClientIP(c, settings.ProxyMode)
+	userAgent := c.Get("User-Agent")
+	if isExcluded(ctx, websiteID, ip, PayloadData{}) {
+		return c.Send(transparentGIF)
+	}
+	if isBot, err := db.DetectBot(ctx, privacy.StoredIP(ctx, ip), userAgent); err == nil && isBot {
+		return c.Send(transparentGIF)
+	}
+
+	now := time.Now()
+	sessionID, err := visitorSessionID(ctx, websiteID, ip, userAgent, now)
+	if err == nil {
+		err = db.RecordBaselineHit(ctx, websiteID, sessionID, now)
+	}
+	if err != nil {
+		logging.L().Warn("failed to record baseline hit", zap.String("website_id", websiteID.String()), zap.Error(err))
+	}
+	return c.Send(transparentGIF)
+}
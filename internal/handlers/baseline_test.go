@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBaselinePixelAlwaysServesImage(t *testing.T) {
+	app := fiber.New()
+	app.Get("/b/:website_id", HandleBaselinePixel)
+
+	// An invalid website ID never reaches the store but still gets the image
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/b/not-a-uuid.gif", nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "image/gif", resp.Header.Get("Content-Type"))
+	assert.Contains(t, resp.Header.Get("Cache-Control"), "no-store")
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, transparentGIF, body)
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// MaxBatchEvents is how many events one /api/batch request may carry. Later
+// events are left for the client to send again (see BatchResponse).
+const MaxBatchEvents = 50
+
+// BatchResponse reports what became of each event of a batch
+type BatchResponse struct {
+	// Accepted counts the events ingested or deliberately dropped (bots,
+	// speculative loads, exclusions): sending them again changes nothing
+	Accepted int `json:"accepted"`
+	// Rejected lists the events that failed, by index in the batch
+	Rejected []BatchRejection `json:"rejected"`
+	// Processed is how many events, from the start of the batch, were
+	// handled; the client sends the ones after it again (after Retry-After
+	// when the server deferred them)
+	Processed int `json:"processed"`
+}
+
+// BatchRejection is one failed event of a batch
+type BatchRejection struct {
+	Index  int             `json:"index"`
+	Status int             `json:"status"`
+	Error  json.RawMessage `json:"error"`
+	// Fields lists the values at fault of a rejected payload
+	Fields json.RawMessage `json:"fields,omitempty"`
+}
+
+// HandleBatch is the /api/batch endpoint: a JSON array of /api/send
+// messages, as the tracker sends when it flushes on page exit with
+// sendBeacon or a keepalive fetch (both limited to 64KB per page). Each
+// event is handled like its own /api/send request. The answer is 202 when
+// every event was accepted and 207 when some were rejected or, past
+// MaxBatchEvents or from an event deferred under load, not processed.
+func HandleBatch(c fiber.Ctx) error {
+	var batch []json.RawMessage
+	if err := json.Unmarshal(c.Body(), &batch); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid JSON payload: expected an array of events",
+		})
+	}
+
+	resp := BatchResponse{Rejected: []BatchRejection{}, Processed: min(len(batch), MaxBatchEvents)}
+	for i, raw := range batch[:resp.Processed] {
+		payload, err := decodePayload(raw)
+		if err != nil {
+			resp.Rejected = append(resp.Rejected, BatchRejection{
+				Index: i, Status: 400, Error: json.RawMessage(`"Invalid JSON payload"`),
+			})
+			continue
+		}
+
+		// Each event writes its own response; keep its status and clear it
+		payload.canRetry = true
+		if err := track(c, payload); err != nil {
+			return err
+		}
+		status := c.Response().StatusCode()
+		// A deferred event ends the batch: its Retry-After header stays on
+		// the response and the client resends the rest after the delay
+		if len(c.Response().Header.Peek(fiber.HeaderRetryAfter)) > 0 {
+			c.Response().ResetBody()
+			resp.Processed = i
+			break
+		}
+		if status < 300 {
+			resp.Accepted++
+		} else {
+			rejection := BatchRejection{Index: i, Status: status}
+			rejection.Error, rejection.Fields = batchError(c.Response().Body())
+			resp.Rejected = append(resp.Rejected, rejection)
+		}
+		c.Response().ResetBody()
+	}
+
+	status := fiber.StatusAccepted
+	if len(resp.Rejected) > 0 || resp.Processed < len(batch) {
+		status = fiber.StatusMultiStatus
+	}
+	return c.Status(status).JSON(resp)
+}
+
+// batchError extracts the "error" and "fields" of an event's JSON response
+func batchError(body []byte) (json.RawMessage, json.RawMessage) {
+	var parsed struct {
+		Error  json.RawMessage `json:"error"`
+		Fields json.RawMessage `json:"fields"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil || parsed.Error == nil {
+		return json.RawMessage(`"Rejected"`), nil
+	}
+	return parsed.Error, parsed.Fields
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/ingest"
+)
+
+func postBatch(t *testing.T, app *fiber.App, contentType, body string) (int, BatchResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept-Language", "en")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	var result BatchResponse
+	if resp.StatusCode != http.StatusBadRequest {
+		require.NoError(t, json.Unmarshal(raw, &result), string(raw))
+	}
+	return resp.StatusCode, result
+}
+
+func TestHandleBatchPartialAcceptance(t *testing.T) {
+	websiteID := uuid.New()
+	app, queue, cleanup := setupFiberTest(t, "/unused", func(c fiber.Ctx) error { return nil }, []mockResponse{
+		{match: "FROM website WHERE website_id", columns: []string{"proxy_mode", "bot_filter", "respect_dnt", "domain", "allowed_domains", "exclude_self_referrals", "referral_domains", "props_max_bytes", "props_max_depth", "visit_definition", "session_timeout"}, rows: [][]interface{}{{"none", true, "off", "example.com", []byte(`[]`), true, []byte(`[]`), 0, 0, "", 0}}},
+		{match: "SELECT validate_origin", columns: []string{"validate_origin"}, rows: [][]interface{}{{true}}},
+		{match: "FROM website_exclusion", columns: []string{"rule_type", "value", "created_at"}},
+		{match: "update_ip_metadata", columns: []string{"update_ip_metadata"}, rows: [][]interface{}{{false}}},
+	})
+	defer cleanup()
+	app.Post("/api/batch", HandleBatch)
+
+	// sendBeacon posts text/plain
+	body := fmt.Sprintf(`[
+		{"type":"event","payload":{"website":%q,"url":"/","screen":"1920x1080","purpose":"prefetch"}},
+		{"type":"event","payload":{"website":"not-a-uuid"}},
+		"nope"
+	]`, websiteID)
+	status, result := postBatch(t, app, "text/plain;charset=UTF-8", body)
+
+	assert.Equal(t, http.StatusMultiStatus, status)
+	assert.Equal(t, 1, result.Accepted)
+	assert.Equal(t, 3, result.Processed)
+	require.Len(t, result.Rejected, 2)
+	assert.Equal(t, 1, result.Rejected[0].Index)
+	assert.Equal(t, http.StatusBadRequest, result.Rejected[0].Status)
+	assert.JSONEq(t, `"Invalid website ID"`, string(result.Rejected[0].Error))
+	assert.Equal(t, 2, result.Rejected[1].Index)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleBatchStopsAtDeferredEvent(t *testing.T) {
+	websiteID := uuid.New()
+	app, queue, cleanup := setupFiberTest(t, "/unused", func(c fiber.Ctx) error { return nil }, []mockResponse{
+		{match: "FROM website WHERE website_id", columns: []string{"proxy_mode", "bot_filter", "respect_dnt", "domain", "allowed_domains", "exclude_self_referrals", "referral_domains", "props_max_bytes", "props_max_depth", "visit_definition", "session_timeout"}, rows: [][]interface{}{{"none", true, "off", "example.com", []byte(`[]`), true, []byte(`[]`), 0, 0, "", 0}}},
+		{match: "SELECT validate_origin", columns: []string{"validate_origin"}, rows: [][]interface{}{{true}}},
+		{match: "FROM website_exclusion", columns: []string{"rule_type", "value", "created_at"}},
+	})
+	defer cleanup()
+	app.Post("/api/batch", HandleBatch)
+
+	// A nearly full buffer defers new events
+	nearlyFullBuffer(t)
+
+	event := fmt.Sprintf(`{"type":"event","payload":{"website":%q,"url":"/","screen":"1920x1080"}}`, websiteID)
+	req := httptest.NewRequest(http.MethodPost, "/api/batch", strings.NewReader("["+event+","+event+"]"))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", "en")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	var result BatchResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	assert.Equal(t, "1", resp.Header.Get("Retry-After"))
+	assert.Equal(t, 0, result.Processed)
+	assert.Equal(t, 0, result.Accepted)
+	assert.Empty(t, result.Rejected)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleBatchProcessesAtMostMaxEvents(t *testing.T) {
+	app := fiber.New()
+	app.Post("/api/batch", HandleBatch)
+
+	events := make([]string, MaxBatchEvents+5)
+	for i := range events {
+		events[i] = `{"type":"event","payload":{"website":"not-a-uuid"}}`
+	}
+	status, result := postBatch(t, app, "application/json", "["+strings.Join(events, ",")+"]")
+
+	assert.Equal(t, http.StatusMultiStatus, status)
+	assert.Equal(t, MaxBatchEvents, result.Processed)
+	assert.Len(t, result.Rejected, MaxBatchEvents)
+}
+
+func TestHandleBatchRejectsNonArray(t *testing.T) {
+	app := fiber.New()
+	app.Post("/api/batch", HandleBatch)
+
+	status, _ := postBatch(t, app, "application/json", `{"type":"event"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestHandleTrackingRateLimited(t *testing.T) {
+	websiteID := uuid.New()
+	app, queue, cleanup := setupFiberTest(t, "/unused", func(c fiber.Ctx) error { return nil }, []mockResponse{
+		{match: "FROM website WHERE website_id", columns: []string{"proxy_mode", "bot_filter", "respect_dnt", "domain", "allowed_domains", "exclude_self_referrals", "referral_domains", "props_max_bytes", "props_max_depth", "visit_definition", "session_timeout"}, rows: [][]interface{}{{"none", true, "off", "example.com", []byte(`[]`), true, []byte(`[]`), 0, 0, "", 0}}},
+	})
+	defer cleanup()
+	app.Post("/api/send", HandleTracking)
+
+	// The website's one request a minute is used up
+	limiter := ingest.NewRateLimiter(0, 1)
+	_, wait := limiter.Allow("", websiteID)
+	require.Zero(t, wait)
+	ingest.SetRateLimiter(limiter)
+	t.Cleanup(func() { ingest.SetRateLimiter(nil) })
+
+	body := fmt.Sprintf(`{"type":"event","payload":{"website":%q,"url":"/"}}`, websiteID)
+	resp, err := app.Test(httptest.NewRequest(http.MethodPost, "/api/send", strings.NewReader(body)))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	var result map[string]string
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "60", resp.Header.Get("Retry-After"))
+	assert.Equal(t, "website", result["limit"])
+	require.NoError(t, queue.expectationsMet())
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// looksHeadless catches automated browsers whose user agent looks like a
+// regular browser: the tracker reports navigator.webdriver, and headless
+// browsers often have a 0x0 screen or send no Accept-Language header.
+func looksHeadless(c fiber.Ctx, p PayloadData) bool {
+	if p.Webdriver != nil && *p.Webdriver {
+		return true
+	}
+	if p.Screen != nil {
+		if w, h, ok := strings.Cut(*p.Screen, "x"); ok && (w == "0" || h == "0") {
+			return true
+		}
+	}
+	// Server-side senders forward the visitor's user agent in the payload and
+	// don't send browser headers of their own
+	if p.UserAgent == nil && c.Get(fiber.HeaderAcceptLanguage) == "" {
+		return true
+	}
+	return false
+}
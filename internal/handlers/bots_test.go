@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLooksHeadless(t *testing.T) {
+	str := func(s string) *string { return &s }
+	yes := true
+	browser := map[string]string{"Accept-Language": "en-US,en;q=0.9"}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		payload PayloadData
+		want    bool
+	}{
+		{name: "regular browser", headers: browser, payload: PayloadData{Screen: str("1920x1080")}},
+		{name: "webdriver", headers: browser, payload: PayloadData{Webdriver: &yes}, want: true},
+		{name: "empty screen", headers: browser, payload: PayloadData{Screen: str("0x0")}, want: true},
+		{name: "no accept-language", payload: PayloadData{Screen: str("1920x1080")}, want: true},
+		{name: "server-side sender", payload: PayloadData{UserAgent: str("Mozilla/5.0")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got bool
+			app := fiber.New()
+			app.Post("/", func(c fiber.Ctx) error {
+				got = looksHeadless(c, tt.payload)
+				return nil
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -3,11 +3,11 @@ package handlers
 import (
 	"github.com/gofiber/fiber/v3"
 	"github.com/google/uuid"
-	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/store"
 )
 
 // handleBreakdown is a generic handler for all breakdown dimensions
-// Uses PostgreSQL function get_breakdown() to reduce code duplication
+// Uses get_breakdown() on PostgreSQL to reduce code duplication
 func handleBreakdown(c fiber.Ctx, dimension string) error {
 	websiteIDStr := c.Params("website_id")
 	websiteID, err := uuid.Parse(websiteIDStr)
@@ -18,56 +18,25 @@ func handleBreakdown(c fiber.Ctx, dimension string) error {
 	// Parse pagination parameters
 	pagination := ParsePaginationParams(c)
 
-	// Extract query parameters
-	country := c.Query("country")
-	browser := c.Query("browser")
-	device := c.Query("device")
-	page := c.Query("page")
+	// Today by default; the map asks for its own period (clamped like map data)
+	days := min(max(fiber.Query[int](c, "days", 1), 1), 90)
 
-	// Convert empty strings to NULL for SQL
-	var countryParam, browserParam, deviceParam, pageParam interface{}
-	if country != "" {
-		countryParam = country
-	}
-	if browser != "" {
-		browserParam = browser
-	}
-	if device != "" {
-		deviceParam = device
+	filters, err := dashboardFilters(c, websiteID)
+	if err != nil {
+		return filtersError(c, err)
 	}
-	if page != "" {
-		pageParam = page
+	if days, err = dashboardRange(c, days, &filters); err != nil {
+		return rangeError(c, err)
 	}
 
-	// Call get_breakdown() function with appropriate dimension and pagination
-	query := `SELECT * FROM get_breakdown($1, $2, 1, $3, $4, $5, $6, $7, $8)`
-	rows, err := database.DB.Query(
-		query,
-		websiteID,
-		dimension,
-		pagination.Per,
-		pagination.Offset,
-		countryParam,
-		browserParam,
-		deviceParam,
-		pageParam,
-	)
-
+	rows, totalCount, err := store.Current().Breakdown(c.Context(), websiteID, dimension, days, pagination.Per, pagination.Offset, filters)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to query " + dimension})
 	}
-	defer func() { _ = rows.Close() }()
-
-	items := make([]BreakdownItem, 0)
-	var totalCount int64
-	for rows.Next() {
-		var item BreakdownItem
-		var rowTotal int64
-		if err := rows.Scan(&item.Name, &item.Count, &rowTotal); err != nil {
-			continue
-		}
-		totalCount = rowTotal // Capture total count from function
-		items = append(items, item)
+
+	items := make([]BreakdownItem, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, BreakdownItem{Name: row.Name, Count: int(row.Count)})
 	}
 
 	// Return paginated response
@@ -94,18 +63,42 @@ func HandleTopCountries(c fiber.Ctx) error {
 	return handleBreakdown(c, "country")
 }
 
-// HandleTopCities returns top cities breakdown
+// HandleTopCities returns top cities breakdown, named "City, Region, CC" so
+// same-named cities of different regions and countries stay apart
 func HandleTopCities(c fiber.Ctx) error {
 	return handleBreakdown(c, "city")
 }
 
-// HandleTopRegions returns top regions breakdown
+// HandleTopRegions returns top regions breakdown, named "Region, CC"
 func HandleTopRegions(c fiber.Ctx) error {
 	return handleBreakdown(c, "region")
 }
 
+// HandleTopASNs returns the autonomous systems (networks) visitors come
+// from, which separates datacenter and VPN traffic from residential ISPs
+func HandleTopASNs(c fiber.Ctx) error {
+	return handleBreakdown(c, "asn")
+}
+
+// HandleTopScreens returns screen widths grouped into ranges
+func HandleTopScreens(c fiber.Ctx) error {
+	return handleBreakdown(c, "screen")
+}
+
+// HandleTopViewports returns the viewport classes (Mobile, Tablet, Desktop,
+// Wide) derived from screen widths
+func HandleTopViewports(c fiber.Ctx) error {
+	return handleBreakdown(c, "viewport")
+}
+
+// HandleTopChannels returns the channels visitors came through (Organic
+// Search, Social, Email, Paid, Referral, Direct)
+func HandleTopChannels(c fiber.Ctx) error {
+	return handleBreakdown(c, "channel")
+}
+
 // HandleMapData returns visitor data aggregated by country for choropleth maps
-// Uses PostgreSQL function get_map_data() for optimized aggregation with percentage calculation
+// Uses get_map_data() on PostgreSQL for optimized aggregation with percentage calculation
 func HandleMapData(c fiber.Ctx) error {
 	websiteIDStr := c.Params("website_id")
 	websiteID, err := uuid.Parse(websiteIDStr)
@@ -116,63 +109,32 @@ func HandleMapData(c fiber.Ctx) error {
 	// Get date range (default 7 days, clamp between 1 and 90)
 	days := min(max(fiber.Query[int](c, "days", 7), 1), 90)
 
-	// Extract filter parameters
-	country := c.Query("country")
-	browser := c.Query("browser")
-	device := c.Query("device")
-	page := c.Query("page")
-
-	// Convert empty strings to NULL for SQL
-	var countryParam, browserParam, deviceParam, pageParam interface{}
-	if country != "" {
-		countryParam = country
-	}
-	if browser != "" {
-		browserParam = browser
-	}
-	if device != "" {
-		deviceParam = device
+	filters, err := dashboardFilters(c, websiteID)
+	if err != nil {
+		return filtersError(c, err)
 	}
-	if page != "" {
-		pageParam = page
+	if days, err = dashboardRange(c, days, &filters); err != nil {
+		return rangeError(c, err)
 	}
 
 	// Call get_map_data() function - replaces 2 queries + percentage calculation
-	query := `SELECT * FROM get_map_data($1, $2, $3, $4, $5, $6)`
-	rows, err := database.DB.Query(
-		query,
-		websiteID,
-		days,
-		countryParam,
-		browserParam,
-		deviceParam,
-		pageParam,
-	)
-
+	rows, err := store.Current().MapData(c.Context(), websiteID, days, filters)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to query map data"})
 	}
-	defer func() { _ = rows.Close() }()
 
 	var data []MapDataPoint
 	var totalVisitors int64 = 0
-	for rows.Next() {
-		var countryCode string
-		var visitors int64
-		var percentage float64
-		if err := rows.Scan(&countryCode, &visitors, &percentage); err != nil {
-			continue
-		}
-
+	for _, row := range rows {
 		// Accumulate total visitors
-		totalVisitors += visitors
+		totalVisitors += row.Visitors
 
 		data = append(data, MapDataPoint{
-			Country:     countryCode,
-			CountryName: getCountryName(countryCode),
-			Code:        getTopoJSONCode(countryCode),
-			Visitors:    int(visitors),
-			Percentage:  percentage,
+			Country:     row.Country,
+			CountryName: getCountryName(row.Country),
+			Code:        getTopoJSONCode(row.Country),
+			Visitors:    int(row.Visitors),
+			Percentage:  row.Percentage,
 		})
 	}
 
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -136,6 +137,107 @@ func TestHandleTopCountries_Success(t *testing.T) {
 	require.NoError(t, queue.expectationsMet())
 }
 
+func TestHandleTopCities_CountryAndPeriod(t *testing.T) {
+	websiteID := uuid.New()
+	responses := []mockResponse{
+		{
+			match:   "SELECT * FROM get_breakdown(",
+			columns: []string{"name", "count", "total_count"},
+			rows:    [][]interface{}{{"Springfield, Illinois, US", int64(6), int64(2)}, {"Springfield, Missouri, US", int64(3), int64(2)}},
+			args:    []interface{}{websiteID, "city", 30, 10, 0, "US", nil, nil, nil, nil, nil, nil, nil},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/dashboard/cities/:website_id", HandleTopCities, responses)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/dashboard/cities/"+websiteID.String()+"?days=30&country=US", nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleTopASNs_Success(t *testing.T) {
+	websiteID := uuid.New()
+	responses := []mockResponse{
+		{
+			match:   "SELECT * FROM get_breakdown(",
+			columns: []string{"name", "count", "total_count"},
+			rows:    [][]interface{}{{"AS16509 Amazon.com, Inc.", int64(4), int64(1)}},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/dashboard/asns/:website_id", HandleTopASNs, responses)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/dashboard/asns/"+websiteID.String(), nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleTopScreensAndViewports(t *testing.T) {
+	websiteID := uuid.New()
+	for _, tc := range []struct {
+		route, dimension, name string
+		handler                fiber.Handler
+	}{
+		{"/api/dashboard/screens/", "screen", "1920-2559px", HandleTopScreens},
+		{"/api/dashboard/viewports/", "viewport", "Desktop", HandleTopViewports},
+	} {
+		responses := []mockResponse{
+			{
+				match:   "SELECT * FROM get_breakdown(",
+				columns: []string{"name", "count", "total_count"},
+				rows:    [][]interface{}{{tc.name, int64(12), int64(1)}},
+			},
+		}
+		app, queue, cleanup := setupFiberTest(t, tc.route+":website_id", tc.handler, responses)
+
+		req := httptest.NewRequest(http.MethodGet, tc.route+websiteID.String(), nil)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Contains(t, string(body), tc.name)
+		require.NoError(t, queue.expectationsMet())
+		cleanup()
+	}
+}
+
+func TestHandleTopChannels(t *testing.T) {
+	websiteID := uuid.New()
+	responses := []mockResponse{
+		{
+			match:   "SELECT * FROM get_breakdown(",
+			columns: []string{"name", "count", "total_count"},
+			rows:    [][]interface{}{{"Organic Search", int64(30), int64(2)}, {"Direct", int64(12), int64(2)}},
+		},
+	}
+	app, queue, cleanup := setupFiberTest(t, "/api/dashboard/channels/:website_id", HandleTopChannels, responses)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/dashboard/channels/"+websiteID.String(), nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), `"name":"Organic Search"`)
+	require.NoError(t, queue.expectationsMet())
+}
+
 func TestBreakdownHandlers_InvalidWebsiteID(t *testing.T) {
 	type invalidCase struct {
 		route   string
@@ -146,6 +248,7 @@ func TestBreakdownHandlers_InvalidWebsiteID(t *testing.T) {
 		{"/api/dashboard/browsers/:website_id", HandleTopBrowsers},
 		{"/api/dashboard/devices/:website_id", HandleTopDevices},
 		{"/api/dashboard/countries/:website_id", HandleTopCountries},
+		{"/api/dashboard/channels/:website_id", HandleTopChannels},
 	}
 
 	for _, tc := range cases {
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/gofiber/fiber/v3"
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/chaos"
+	"github.com/seuros/kaunta/internal/logging"
+)
+
+// HandleChaos returns the failures being injected. The chaos endpoints are
+// only routed in builds with -tags chaos.
+// GET /api/chaos
+func HandleChaos(c fiber.Ctx) error {
+	return c.JSON(chaos.Get())
+}
+
+// HandleChaosUpdate replaces the failures being injected; an empty body
+// stops them
+// PUT /api/chaos
+func HandleChaosUpdate(c fiber.Ctx) error {
+	var settings chaos.Settings
+	if len(c.Body()) > 0 {
+		if err := json.Unmarshal(c.Body(), &settings); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+	}
+	if err := chaos.Set(settings); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	logging.L().Warn("failure injection changed",
+		zap.Int("drop_writes_percent", settings.DropWrites),
+		zap.Int("geoip_latency_ms", settings.GeoIPLatencyMS),
+		zap.Int("fail_webhooks_percent", settings.FailWebhooks))
+	return c.JSON(settings)
+}
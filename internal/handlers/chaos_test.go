@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/chaos"
+)
+
+func TestHandleChaosUpdate(t *testing.T) {
+	app, _, cleanup := setupFiberTest(t, "/api/chaos", HandleChaosUpdate, nil)
+	defer cleanup()
+	t.Cleanup(func() { _ = chaos.Set(chaos.Settings{}) })
+
+	body := `{"drop_writes_percent": 150}`
+	req := httptest.NewRequest(http.MethodGet, "/api/chaos", strings.NewReader(body))
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	body = `{"drop_writes_percent": 20, "geoip_latency_ms": 100}`
+	req = httptest.NewRequest(http.MethodGet, "/api/chaos", strings.NewReader(body))
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	if chaos.Enabled {
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, 20, chaos.Get().DropWrites)
+	} else {
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "not compiled in")
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/crossdomain"
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/logging"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// linkSession handles a pageview sent with the link token of a visitor
+// coming from another website (the cache token of their last event there).
+// When the websites are linked, the session is recorded as linked to the
+// one the token names, and unless the page has a campaign of its own, the
+// pageview takes over the referrer and campaign of the visit it came from.
+func linkSession(ctx context.Context, websiteID, sessionID uuid.UUID, p *PayloadData) {
+	if database.DB == nil || store.Current().Name() != "postgres" {
+		return
+	}
+	from, ok := decodeUmamiCache(*p.Link)
+	if !ok || from.WebsiteID == websiteID {
+		return
+	}
+	if time.Since(time.Unix(from.IssuedAt, 0)) > umamiVisitTimeout {
+		return
+	}
+
+	attribution, err := crossdomain.Record(ctx, database.DB, websiteID, sessionID, crossdomain.Source{
+		WebsiteID: from.WebsiteID,
+		SessionID: from.SessionID,
+		VisitID:   from.VisitID,
+	})
+	if err != nil {
+		logging.L().Warn("failed to link session", zap.String("website_id", websiteID.String()), zap.Error(err))
+		return
+	}
+	if attribution == nil {
+		return
+	}
+
+	if p.URL != nil {
+		if u, err := url.Parse(*p.URL); err == nil && parseUTMParams(u.Query()) != (utmParams{}) {
+			return
+		}
+	}
+	p.Referrer = attribution.Referrer
+	p.campaign = &utmParams{
+		Source:   attribution.UTMSource,
+		Medium:   attribution.UTMMedium,
+		Campaign: attribution.UTMCampaign,
+		Content:  attribution.UTMContent,
+		Term:     attribution.UTMTerm,
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/ingest"
+	"github.com/seuros/kaunta/internal/privacy"
+	"github.com/seuros/kaunta/internal/stats"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// HandleDefinitions explains how each metric is computed under the live
+// settings, for the instance or, with ?website_id=, for a website, so
+// consumers of the numbers can check the methodology. ?metric= returns one.
+// GET /api/definitions
+func HandleDefinitions(c fiber.Ctx) error {
+	settings := stats.Settings{
+		IPMode:           privacy.CurrentMode(),
+		AdaptiveSampling: ingest.Current().Sampling(),
+	}
+	var websiteID *uuid.UUID
+	if raw := c.Query("website_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid website ID"})
+		}
+		website, err := store.Current().WebsiteSettings(c.Context(), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.Status(404).JSON(fiber.Map{"error": "Website not found"})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to load website settings"})
+		}
+		websiteID, settings.Website = &id, website
+	}
+
+	if metric := c.Query("metric"); metric != "" {
+		definition, ok := stats.Explain(metric, settings)
+		if !ok {
+			return c.Status(404).JSON(fiber.Map{"error": "Unknown metric: " + metric})
+		}
+		return c.JSON(definition)
+	}
+	return c.JSON(fiber.Map{
+		"website_id":  websiteID,
+		"settings":    settings,
+		"definitions": stats.Definitions(settings),
+	})
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/stats"
+)
+
+func TestHandleDefinitionsForWebsite(t *testing.T) {
+	websiteID := uuid.New()
+	responses := []mockResponse{
+		{
+			match:   "FROM website WHERE website_id = $1",
+			args:    []interface{}{websiteID},
+			columns: []string{"proxy_mode", "bot_filter", "respect_dnt", "domain", "allowed_domains", "exclude_self_referrals", "referral_domains", "props_max_bytes", "props_max_depth", "visit_definition", "session_timeout"},
+			rows:    [][]interface{}{{"none", true, "anonymize", "example.com", []byte(`[]`), true, []byte(`[]`), 0, 0, "", 0}},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/definitions", HandleDefinitions, responses)
+	defer cleanup()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/definitions?website_id="+websiteID.String()+"&metric=visitors", nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var body stats.Definition
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "visitors", body.Metric)
+	assert.Contains(t, body.Notes, "Bot filtering is on: detected bots are dropped and never counted.")
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleDefinitionsUnknownMetric(t *testing.T) {
+	app, _, cleanup := setupFiberTest(t, "/api/definitions", HandleDefinitions, nil)
+	defer cleanup()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/definitions?metric=revenue", nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
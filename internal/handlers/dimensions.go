@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/dimensions"
+	"github.com/seuros/kaunta/internal/logging"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+type cachedDimensions struct {
+	names   []string
+	expires time.Time
+}
+
+var (
+	dimensionCacheMu sync.Mutex
+	dimensionCache   = make(map[uuid.UUID]cachedDimensions)
+)
+
+// registeredDimensions returns a website's custom dimension names, cached
+// for websiteCacheTTL so pageviews don't each cost a query
+func registeredDimensions(ctx context.Context, websiteID uuid.UUID) []string {
+	now := time.Now()
+	dimensionCacheMu.Lock()
+	cached, ok := dimensionCache[websiteID]
+	dimensionCacheMu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.names
+	}
+
+	names, err := store.Current().CustomDimensions(ctx, websiteID)
+	if err != nil {
+		// Keep tracking; the values of this event are dropped
+		logging.L().Warn("failed to load custom dimensions",
+			zap.String("website_id", websiteID.String()),
+			zap.Error(err))
+		return nil
+	}
+
+	dimensionCacheMu.Lock()
+	dimensionCache[websiteID] = cachedDimensions{names: names, expires: now.Add(websiteCacheTTL)}
+	dimensionCacheMu.Unlock()
+	return names
+}
+
+// eventDimensions keeps the payload's values for registered dimensions
+func eventDimensions(ctx context.Context, websiteID uuid.UUID, values map[string]interface{}) map[string]string {
+	if len(values) == 0 {
+		return nil
+	}
+	return dimensions.Clean(values, registeredDimensions(ctx, websiteID))
+}
+
+// HandleCustomDimensions lists the custom dimensions registered for a website
+func HandleCustomDimensions(c fiber.Ctx) error {
+	websiteID, err := uuid.Parse(c.Params("website_id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid website ID"})
+	}
+
+	names, err := store.Current().CustomDimensions(c.Context(), websiteID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query custom dimensions"})
+	}
+	if names == nil {
+		names = []string{}
+	}
+	return c.JSON(fiber.Map{"dimensions": names})
+}
+
+// HandleCustomDimensionBreakdown returns the breakdown of a custom dimension
+func HandleCustomDimensionBreakdown(c fiber.Ctx) error {
+	name := c.Params("name")
+	if err := dimensions.ValidName(name); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return handleBreakdown(c, name)
+}
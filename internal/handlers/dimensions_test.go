@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleCustomDimensions(t *testing.T) {
+	websiteID := uuid.New()
+	responses := []mockResponse{
+		{
+			match:   "SELECT name FROM custom_dimension",
+			columns: []string{"name"},
+			rows:    [][]interface{}{{"content_type"}, {"plan"}},
+			args:    []interface{}{websiteID},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/dashboard/dimensions/:website_id", HandleCustomDimensions, responses)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/dashboard/dimensions/"+websiteID.String(), nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var body struct {
+		Dimensions []string `json:"dimensions"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, []string{"content_type", "plan"}, body.Dimensions)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleCustomDimensionBreakdown_Filtered(t *testing.T) {
+	websiteID := uuid.New()
+	responses := []mockResponse{
+		{
+			match:   "SELECT * FROM get_breakdown(",
+			columns: []string{"name", "count", "total_count"},
+			rows:    [][]interface{}{{"tutorial", int64(7), int64(1)}},
+			args: []interface{}{websiteID, "content_type", 1, 10, 0, nil, nil, nil, nil,
+				[]byte(`{"plan":"pro"}`), nil, nil, nil},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/dashboard/dimensions/:website_id/:name", HandleCustomDimensionBreakdown, responses)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/dashboard/dimensions/"+websiteID.String()+"/content_type?dim.plan=pro&dim.Bad-Name=x", nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleCustomDimensionBreakdown_BuiltinName(t *testing.T) {
+	app := fiber.New()
+	app.Get("/api/dashboard/dimensions/:website_id/:name", HandleCustomDimensionBreakdown)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/dashboard/dimensions/"+uuid.NewString()+"/country", nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
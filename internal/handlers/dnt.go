@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// Values of website.respect_dnt
+const (
+	RespectDNTOff       = "off"
+	RespectDNTDrop      = "drop"
+	RespectDNTAnonymize = "anonymize"
+)
+
+// doNotTrack reports whether the visitor asked not to be tracked, with the
+// Do-Not-Track header or Global Privacy Control
+func doNotTrack(c fiber.Ctx) bool {
+	return c.Get("DNT") == "1" || c.Get("Sec-GPC") == "1"
+}
+
+// doNotTrackAction returns what to do with a request under the website's
+// respect_dnt setting: RespectDNTDrop, RespectDNTAnonymize, or
+// RespectDNTOff to ingest it as usual
+func doNotTrackAction(c fiber.Ctx, settings *store.WebsiteSettings) string {
+	if !doNotTrack(c) {
+		return RespectDNTOff
+	}
+	switch settings.RespectDNT {
+	case RespectDNTDrop, RespectDNTAnonymize:
+		return settings.RespectDNT
+	default:
+		return RespectDNTOff
+	}
+}
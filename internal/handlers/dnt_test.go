@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/store"
+)
+
+func TestDoNotTrackAction(t *testing.T) {
+	tests := []struct {
+		name       string
+		respectDNT string
+		headers    map[string]string
+		want       string
+	}{
+		{"no signal", RespectDNTDrop, nil, RespectDNTOff},
+		{"dnt ignored", RespectDNTOff, map[string]string{"DNT": "1"}, RespectDNTOff},
+		{"dnt dropped", RespectDNTDrop, map[string]string{"DNT": "1"}, RespectDNTDrop},
+		{"dnt opt-in is not a signal", RespectDNTDrop, map[string]string{"DNT": "0"}, RespectDNTOff},
+		{"gpc anonymized", RespectDNTAnonymize, map[string]string{"Sec-GPC": "1"}, RespectDNTAnonymize},
+		{"unknown setting", "sometimes", map[string]string{"Sec-GPC": "1"}, RespectDNTOff},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := &store.WebsiteSettings{RespectDNT: tt.respectDNT}
+			app := fiber.New()
+			app.Get("/", func(c fiber.Ctx) error {
+				return c.SendString(doNotTrackAction(c, settings))
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			defer func() { _ = resp.Body.Close() }()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(body))
+		})
+	}
+}
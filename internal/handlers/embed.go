@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/stats"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// embedCacheTTL is how long a website's embed counts are reused; badges are
+// fetched on every page view of a README or footer
+const embedCacheTTL = 5 * time.Minute
+
+// embedMaxLabel is the longest badge label
+const embedMaxLabel = 30
+
+// embedPeriods are the periods embeds count, in days
+var embedPeriods = map[string]int{"7d": 7, "30d": 30}
+
+// EmbedCounts are the visitors and pageviews of a shared website's last 7
+// and 30 days
+type EmbedCounts struct {
+	Domain    string           `json:"domain"`
+	Name      string           `json:"name"`
+	Visitors  map[string]int64 `json:"visitors"`
+	Pageviews map[string]int64 `json:"pageviews"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+var (
+	embedCacheMu sync.Mutex
+	embedCache   = make(map[uuid.UUID]*EmbedCounts)
+)
+
+// embedCounts returns the counts of a shared website, cached for
+// embedCacheTTL
+func embedCounts(ctx context.Context, website *store.WebsiteRow) (*EmbedCounts, error) {
+	websiteID, err := uuid.Parse(website.WebsiteID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	embedCacheMu.Lock()
+	cached, ok := embedCache[websiteID]
+	embedCacheMu.Unlock()
+	if ok && now.Before(cached.UpdatedAt.Add(embedCacheTTL)) {
+		return cached, nil
+	}
+
+	counts := &EmbedCounts{
+		Domain:    website.Domain,
+		Name:      website.Domain,
+		Visitors:  map[string]int64{},
+		Pageviews: map[string]int64{},
+		UpdatedAt: now,
+	}
+	if website.Name != nil && *website.Name != "" {
+		counts.Name = *website.Name
+	}
+	for _, period := range []string{"7d", "30d"} {
+		summary, err := stats.GetDaysSummary(ctx, database.DB, websiteID, embedPeriods[period], store.Filters{})
+		if err != nil {
+			return nil, err
+		}
+		counts.Visitors[period] = summary.Visitors
+		counts.Pageviews[period] = summary.Pageviews
+	}
+
+	embedCacheMu.Lock()
+	embedCache[websiteID] = counts
+	embedCacheMu.Unlock()
+	return counts, nil
+}
+
+// embedShare resolves the share of an embed request. Embeds can't ask for
+// a password, so password-protected shares aren't embeddable.
+func embedShare(c fiber.Ctx) (*store.Share, error) {
+	if database.DB == nil {
+		return nil, c.Status(501).JSON(fiber.Map{"error": "Embeds require PostgreSQL"})
+	}
+	s, err := findShare(c)
+	if err != nil {
+		return nil, sharePageError(c, err)
+	}
+	if s.PasswordHash != "" {
+		return nil, c.Status(403).JSON(fiber.Map{"error": "Password-protected shares can't be embedded"})
+	}
+	return s, nil
+}
+
+// HandleEmbedStats returns the visitors and pageviews of a shared website's
+// last 7 and 30 days as JSON for widgets (TrackingCORS lets any origin
+// read it)
+// GET /embed/:share_id/stats.json
+func HandleEmbedStats(c fiber.Ctx) error {
+	s, err := embedShare(c)
+	if s == nil {
+		return err
+	}
+	counts, err := embedCounts(c.Context(), &s.Website)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query stats"})
+	}
+	c.Set("Cache-Control", "public, max-age=300")
+	return c.JSON(counts)
+}
+
+// HandleEmbedBadge renders a shared website's visitors (or ?metric=pageviews)
+// of the last 7 days (or ?period=30d) as an SVG badge; ?label= replaces the
+// label
+// GET /embed/:share_id/badge.svg
+func HandleEmbedBadge(c fiber.Ctx) error {
+	period := c.Query("period", "7d")
+	if _, ok := embedPeriods[period]; !ok {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid period (use 7d or 30d)"})
+	}
+	metric := c.Query("metric", "visitors")
+	if metric != "visitors" && metric != "pageviews" {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid metric (use visitors or pageviews)"})
+	}
+	label := c.Query("label", metric)
+	if label == "" || utf8.RuneCountInString(label) > embedMaxLabel {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("Invalid label (1-%d characters)", embedMaxLabel)})
+	}
+
+	s, err := embedShare(c)
+	if s == nil {
+		return err
+	}
+	counts, err := embedCounts(c.Context(), &s.Website)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query stats"})
+	}
+	value := counts.Visitors[period]
+	if metric == "pageviews" {
+		value = counts.Pageviews[period]
+	}
+
+	c.Set("Content-Type", "image/svg+xml; charset=utf-8")
+	c.Set("Cache-Control", "public, max-age=300")
+	return c.Send(badgeSVG(label, compactCount(value)+" / "+period))
+}
+
+// badgeSVG draws a flat two-part badge, label on grey and value on blue
+func badgeSVG(label, value string) []byte {
+	// Verdana at 11px averages about 7px per character
+	labelWidth := utf8.RuneCountInString(label)*7 + 10
+	valueWidth := utf8.RuneCountInString(value)*7 + 10
+	width := labelWidth + valueWidth
+	label, value = html.EscapeString(label), html.EscapeString(value)
+	return fmt.Appendf(nil, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s: %s">`+
+		`<title>%s: %s</title>`+
+		`<clipPath id="r"><rect width="%d" height="20" rx="3" fill="#fff"/></clipPath>`+
+		`<g clip-path="url(#r)"><rect width="%d" height="20" fill="#555"/><rect x="%d" width="%d" height="20" fill="#3b82f6"/></g>`+
+		`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`+
+		`<text x="%d" y="14">%s</text><text x="%d" y="14">%s</text></g></svg>`,
+		width, label, value, label, value, width, labelWidth, labelWidth, valueWidth,
+		labelWidth/2, label, labelWidth+valueWidth/2, value)
+}
+
+// compactCount shortens a count for a badge, rounding down so a badge never
+// claims more than was counted: 950, 1.2k, 34k, 1.5M
+func compactCount(n int64) string {
+	switch {
+	case n < 1000:
+		return strconv.FormatInt(n, 10)
+	case n < 10_000:
+		return fmt.Sprintf("%d.%dk", n/1000, n/100%10)
+	case n < 1_000_000:
+		return strconv.FormatInt(n/1000, 10) + "k"
+	case n < 10_000_000:
+		return fmt.Sprintf("%d.%dM", n/1_000_000, n/100_000%10)
+	}
+	return strconv.FormatInt(n/1_000_000, 10) + "M"
+}
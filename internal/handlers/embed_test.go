@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func embedResponses(websiteID uuid.UUID) []mockResponse {
+	summary := []string{"visitors", "pageviews", "bounce_rate", "avg_engagement"}
+	return []mockResponse{
+		shareLookup(websiteID, nil, nil),
+		{match: "FROM events", args: []interface{}{websiteID, 7}, columns: summary,
+			rows: [][]interface{}{{int64(1234), int64(4000), 40.0, 30.0}}},
+		{match: "FROM events", args: []interface{}{websiteID, 30}, columns: summary,
+			rows: [][]interface{}{{int64(5100), int64(17000), 42.0, 31.0}}},
+	}
+}
+
+func TestHandleEmbedStats(t *testing.T) {
+	websiteID := uuid.New()
+	app, queue, cleanup := setupFiberTest(t, "/embed/:share_id/stats.json", HandleEmbedStats, embedResponses(websiteID))
+	defer cleanup()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/embed/abc123/stats.json", nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var counts EmbedCounts
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&counts))
+	assert.Equal(t, "Example", counts.Name)
+	assert.Equal(t, map[string]int64{"7d": 1234, "30d": 5100}, counts.Visitors)
+	assert.Equal(t, map[string]int64{"7d": 4000, "30d": 17000}, counts.Pageviews)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleEmbedBadge(t *testing.T) {
+	websiteID := uuid.New()
+	responses := append(embedResponses(websiteID), shareLookup(websiteID, nil, nil))
+	app, queue, cleanup := setupFiberTest(t, "/embed/:share_id/badge.svg", HandleEmbedBadge, responses)
+	defer cleanup()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/embed/abc123/badge.svg", nil))
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "image/svg+xml; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Contains(t, string(body), `aria-label="visitors: 1.2k / 7d"`)
+
+	// The second badge reuses the cached counts
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/embed/abc123/badge.svg?period=30d&metric=pageviews&label=%3Creads%3E", nil))
+	require.NoError(t, err)
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), `<text x="29" y="14">&lt;reads&gt;</text>`)
+	assert.Contains(t, string(body), ">17k / 30d</text>")
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleEmbedBadgeErrors(t *testing.T) {
+	app, _, cleanup := setupFiberTest(t, "/embed/:share_id/badge.svg", HandleEmbedBadge, []mockResponse{
+		shareLookup(uuid.New(), "$2a$10$hash", nil),
+	})
+	defer cleanup()
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/embed/abc123/badge.svg?period=1y", http.StatusBadRequest},
+		{"/embed/abc123/badge.svg?metric=bounce", http.StatusBadRequest},
+		{"/embed/abc123/badge.svg", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, tt.path, nil))
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, tt.status, resp.StatusCode, tt.path)
+	}
+}
+
+func TestCompactCount(t *testing.T) {
+	for n, want := range map[int64]string{
+		0: "0", 950: "950", 1234: "1.2k", 9999: "9.9k", 34_567: "34k", 1_560_000: "1.5M", 25_000_000: "25M",
+	} {
+		assert.Equal(t, want, compactCount(n), n)
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/eventprops"
+	"github.com/seuros/kaunta/internal/stats"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// HandleEvents counts a website's custom events by name with their trend,
+// over the last ?days= (default 30, at most 365) with up to ?limit= events
+// (default 10, at most 100). prop.<key>=value keeps the events sent with
+// that property value (?prop.plan=pro).
+// GET /api/dashboard/events/:website_id
+func HandleEvents(c fiber.Ctx) error {
+	websiteID, err := uuid.Parse(c.Params("website_id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid website ID"})
+	}
+	match, err := eventprops.ParseFilter(c.Queries())
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if database.DB == nil || store.Current().Name() != "postgres" {
+		return c.Status(501).JSON(fiber.Map{"error": "Custom events require PostgreSQL"})
+	}
+
+	days := min(max(fiber.Query[int](c, "days", 30), 1), 365)
+	limit := min(max(fiber.Query[int](c, "limit", 10), 1), 100)
+
+	events, err := stats.GetEvents(c.Context(), database.DB, websiteID, days, limit, match)
+	if errors.Is(err, eventprops.ErrFilterEncrypted) {
+		return c.Status(501).JSON(fiber.Map{"error": "Property filters aren't available while event properties are encrypted"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query events"})
+	}
+	return c.JSON(events)
+}
+
+// HandleEventProperties breaks a custom event down by the values of one of
+// its properties, e.g. ?name=signup&prop=plan, over the last ?days= (default
+// 30, at most 365) with up to ?limit= values (default 10, at most 100).
+// prop.<key>=value keeps the events sent with that property value.
+// GET /api/dashboard/event-properties/:website_id
+func HandleEventProperties(c fiber.Ctx) error {
+	websiteID, err := uuid.Parse(c.Params("website_id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid website ID"})
+	}
+	name := strings.TrimSpace(c.Query("name"))
+	property := strings.TrimSpace(c.Query("prop"))
+	if name == "" || property == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "name and prop are required"})
+	}
+	match, err := eventprops.ParseFilter(c.Queries())
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if database.DB == nil || store.Current().Name() != "postgres" {
+		return c.Status(501).JSON(fiber.Map{"error": "Event properties require PostgreSQL"})
+	}
+
+	days := min(max(fiber.Query[int](c, "days", 30), 1), 365)
+	limit := min(max(fiber.Query[int](c, "limit", 10), 1), 100)
+
+	props, err := stats.GetEventProperties(c.Context(), database.DB, websiteID, name, property, days, limit, match)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query event properties"})
+	}
+	return c.JSON(props)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/stats"
+)
+
+func TestHandleEventProperties(t *testing.T) {
+	websiteID := uuid.New()
+	responses := []mockResponse{
+		{
+			match:   "e.props ->> $4",
+			args:    []interface{}{websiteID, 7, "signup", "plan", 5},
+			columns: []string{"value", "events", "visitors"},
+			rows:    [][]interface{}{{"free", int64(40), int64(38)}, {"pro", int64(12), int64(10)}},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/dashboard/event-properties/:website_id", HandleEventProperties, responses)
+	defer cleanup()
+
+	url := "/api/dashboard/event-properties/" + websiteID.String() + "?name=signup&prop=plan&days=7&limit=5"
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, url, nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var props stats.EventProperties
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&props))
+	assert.Equal(t, "plan", props.Property)
+	assert.Equal(t, []stats.EventCount{
+		{Name: "free", Events: 40, Visitors: 38},
+		{Name: "pro", Events: 12, Visitors: 10},
+	}, props.Values)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleEventPropertiesRequiresNameAndProp(t *testing.T) {
+	app, _, cleanup := setupFiberTest(t, "/api/dashboard/event-properties/:website_id", HandleEventProperties, nil)
+	defer cleanup()
+
+	url := "/api/dashboard/event-properties/" + uuid.NewString() + "?name=signup"
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, url, nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestHandleEventsFilteredByProperty(t *testing.T) {
+	websiteID := uuid.New()
+	responses := []mockResponse{
+		{
+			match:   "e.props ? 'plan' AND (e.props -> 'plan' @> $4::jsonb)",
+			args:    []interface{}{websiteID, 30, 10, `"pro"`},
+			columns: []string{"name", "events", "visitors", "previous"},
+			rows:    [][]interface{}{{"billing_opened", int64(12), int64(9), int64(6)}},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/dashboard/events/:website_id", HandleEvents, responses)
+	defer cleanup()
+
+	url := "/api/dashboard/events/" + websiteID.String() + "?prop.plan=pro"
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, url, nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var events []stats.EventTrend
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&events))
+	require.Len(t, events, 1)
+	assert.Equal(t, "billing_opened", events[0].Name)
+	assert.Equal(t, int64(9), events[0].Visitors)
+	require.NoError(t, queue.expectationsMet())
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/exclusions"
+	"github.com/seuros/kaunta/internal/logging"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// websiteCacheTTL is how long the tracking endpoint reuses a website's
+// exclusion rules and dimension names before looking them up again
+var websiteCacheTTL = time.Minute
+
+// SetCacheTTL changes how long website settings are cached (cache_ttl); zero
+// keeps the default. Call it before serving requests.
+func SetCacheTTL(ttl time.Duration) {
+	if ttl > 0 {
+		websiteCacheTTL = ttl
+	}
+}
+
+type cachedExclusions struct {
+	matcher *exclusions.Matcher
+	expires time.Time
+}
+
+var (
+	exclusionCacheMu sync.Mutex
+	exclusionCache   = make(map[uuid.UUID]cachedExclusions)
+)
+
+// exclusionMatcher returns a website's compiled exclusion rules, cached for
+// websiteCacheTTL so requests don't each cost a query
+func exclusionMatcher(ctx context.Context, websiteID uuid.UUID) *exclusions.Matcher {
+	now := time.Now()
+	exclusionCacheMu.Lock()
+	cached, ok := exclusionCache[websiteID]
+	exclusionCacheMu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.matcher
+	}
+
+	rules, err := store.Current().ExclusionRules(ctx, websiteID)
+	if err != nil {
+		// Keep tracking rather than lose real traffic
+		logging.L().Warn("failed to load exclusion rules",
+			zap.String("website_id", websiteID.String()),
+			zap.Error(err))
+		return nil
+	}
+
+	matcher := exclusions.NewMatcher(rules)
+	exclusionCacheMu.Lock()
+	exclusionCache[websiteID] = cachedExclusions{matcher: matcher, expires: now.Add(websiteCacheTTL)}
+	exclusionCacheMu.Unlock()
+	return matcher
+}
+
+// isExcluded reports whether a tracking request matches one of the website's
+// exclusion rules, by client IP and by the page's hostname and path
+func isExcluded(ctx context.Context, websiteID uuid.UUID, ip string, payload PayloadData) bool {
+	matcher := exclusionMatcher(ctx, websiteID)
+	if matcher.Empty() {
+		return false
+	}
+
+	var hostname, path string
+	if payload.URL != nil {
+		if u, err := url.Parse(*payload.URL); err == nil {
+			hostname, path = u.Hostname(), u.Path
+		}
+	}
+	if payload.Hostname != nil {
+		hostname = *payload.Hostname
+	}
+	return matcher.Match(ip, hostname, path)
+}
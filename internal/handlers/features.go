@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/features"
+)
+
+// HandleFeatures lists the feature flags and whether they are on, for the
+// instance or, with ?website_id=, for a website, so the dashboard shows what
+// is available
+// GET /api/features
+func HandleFeatures(c fiber.Ctx) error {
+	var websiteID *uuid.UUID
+	if raw := c.Query("website_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid website ID"})
+		}
+		websiteID = &id
+	}
+
+	states, err := features.Resolve(c.Context(), database.DB, websiteID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load feature flags"})
+	}
+	return c.JSON(fiber.Map{
+		"website_id": websiteID,
+		"features":   states,
+	})
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/features"
+)
+
+func TestHandleFeatures(t *testing.T) {
+	websiteID := uuid.New()
+	responses := []mockResponse{
+		{
+			match:   "FROM feature_flag",
+			args:    []interface{}{websiteID},
+			columns: []string{"name", "website_id", "enabled"},
+			rows:    [][]interface{}{{"sessions", websiteID.String(), false}},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/features", HandleFeatures, responses)
+	defer cleanup()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/features?website_id="+websiteID.String(), nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var body struct {
+		WebsiteID uuid.UUID        `json:"website_id"`
+		Features  []features.State `json:"features"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, websiteID, body.WebsiteID)
+	require.Len(t, body.Features, 1)
+	assert.Equal(t, "sessions", body.Features[0].Name)
+	assert.False(t, body.Features[0].Enabled)
+	assert.Equal(t, features.SourceWebsite, body.Features[0].Source)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleFeaturesInvalidWebsiteID(t *testing.T) {
+	app, _, cleanup := setupFiberTest(t, "/api/features", HandleFeatures, nil)
+	defer cleanup()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/features?website_id=nope", nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/store"
+)
+
+const (
+	feedDays         = 7
+	feedDefaultLimit = 10
+	feedMaxLimit     = 50
+)
+
+// HandleTopPagesFeed serves a website's top pages of the last 7 days as a
+// feed (?format=rss, the default, or ?format=json for JSON Feed). Dashboard
+// filters apply.
+func HandleTopPagesFeed(c fiber.Ctx) error {
+	websiteID, err := uuid.Parse(c.Params("website_id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid website ID",
+		})
+	}
+
+	filters, err := dashboardFilters(c, websiteID)
+	if err != nil {
+		return filtersError(c, err)
+	}
+
+	website, err := store.Current().FindWebsite(c.Context(), websiteID)
+	return serveTopPagesFeed(c, website, err, filters)
+}
+
+// HandleSharedTopPagesFeed serves the same feed without a login for websites
+// with a share ID, so newsletter tools and chat integrations can subscribe.
+// Filters are not available on shared feeds, and password-protected shares
+// only serve it to browsers that entered the password.
+func HandleSharedTopPagesFeed(c fiber.Ctx) error {
+	s, err := findShare(c)
+	if errors.Is(err, errShareExpired) {
+		return c.Status(410).JSON(fiber.Map{
+			"error": "Share link expired",
+		})
+	}
+	if err != nil {
+		return serveTopPagesFeed(c, nil, err, store.Filters{})
+	}
+	if !shareUnlocked(c, s) {
+		return c.Status(401).JSON(fiber.Map{
+			"error": "Share link is password protected",
+		})
+	}
+	return serveTopPagesFeed(c, &s.Website, nil, store.Filters{})
+}
+
+func serveTopPagesFeed(c fiber.Ctx, website *store.WebsiteRow, err error, f store.Filters) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Website not found",
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to load website",
+		})
+	}
+
+	format := c.Query("format", "rss")
+	if format != "rss" && format != "json" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid format (use rss or json)",
+		})
+	}
+
+	limit := feedDefaultLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > feedMaxLimit {
+			return c.Status(400).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid limit (use 1-%d)", feedMaxLimit),
+			})
+		}
+		limit = n
+	}
+
+	websiteID, err := uuid.Parse(website.WebsiteID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to load website",
+		})
+	}
+	pages, _, err := store.Current().TopPages(c.Context(), websiteID, feedDays, limit, 0, f)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to query top pages",
+		})
+	}
+
+	feed := newTopPagesFeed(website, pages, time.Now().UTC())
+	c.Set("Cache-Control", "max-age=3600")
+	if format == "json" {
+		c.Set("Content-Type", "application/feed+json; charset=utf-8")
+		return c.Send(feed.jsonFeed(c.BaseURL() + c.OriginalURL()))
+	}
+	c.Set("Content-Type", "application/rss+xml; charset=utf-8")
+	return c.Send(feed.rss())
+}
+
+// topPagesFeed is the format-independent content of a top pages feed
+type topPagesFeed struct {
+	title   string
+	homeURL string
+	week    string // ISO week, e.g. 2025-W23
+	updated time.Time
+	items   []topPagesFeedItem
+}
+
+type topPagesFeedItem struct {
+	id       string
+	url      string
+	path     string
+	rank     int
+	views    int64
+	visitors int64
+}
+
+func newTopPagesFeed(website *store.WebsiteRow, pages []store.PageRow, now time.Time) *topPagesFeed {
+	name := website.Domain
+	if website.Name != nil && *website.Name != "" {
+		name = *website.Name
+	}
+	year, week := now.ISOWeek()
+
+	feed := &topPagesFeed{
+		title:   "Top pages on " + name,
+		homeURL: "https://" + website.Domain,
+		week:    fmt.Sprintf("%d-W%02d", year, week),
+		updated: now.Truncate(time.Hour),
+	}
+	for i, page := range pages {
+		url := feed.homeURL + page.Path
+		feed.items = append(feed.items, topPagesFeedItem{
+			// A page that stays on top gets a new entry every week
+			id:       url + "#" + feed.week,
+			url:      url,
+			path:     page.Path,
+			rank:     i + 1,
+			views:    page.Views,
+			visitors: page.UniqueVisitors,
+		})
+	}
+	return feed
+}
+
+func (it topPagesFeedItem) title() string {
+	return fmt.Sprintf("#%d %s", it.rank, it.path)
+}
+
+func (it topPagesFeedItem) summary() string {
+	return fmt.Sprintf("%d views from %d visitors in the last %d days", it.views, it.visitors, feedDays)
+}
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	TTL           int       `xml:"ttl"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func (f *topPagesFeed) rss() []byte {
+	doc := rssDocument{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         f.title,
+			Link:          f.homeURL,
+			Description:   fmt.Sprintf("Most viewed pages of the last %d days (%s)", feedDays, f.week),
+			LastBuildDate: f.updated.Format(time.RFC1123Z),
+			TTL:           60,
+		},
+	}
+	for _, it := range f.items {
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       it.title(),
+			Link:        it.url,
+			Description: it.summary(),
+			GUID:        rssGUID{Value: it.id},
+			PubDate:     f.updated.Format(time.RFC1123Z),
+		})
+	}
+
+	// Marshalling these plain structs can't fail
+	body, _ := xml.MarshalIndent(doc, "", "  ")
+	return append([]byte(xml.Header), body...)
+}
+
+type jsonFeedDocument struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Description string         `json:"description"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string            `json:"id"`
+	URL           string            `json:"url"`
+	Title         string            `json:"title"`
+	ContentText   string            `json:"content_text"`
+	DatePublished string            `json:"date_published"`
+	Kaunta        jsonFeedItemStats `json:"_kaunta"`
+}
+
+// jsonFeedItemStats is a JSON Feed extension carrying the raw numbers
+type jsonFeedItemStats struct {
+	Rank     int   `json:"rank"`
+	Views    int64 `json:"views"`
+	Visitors int64 `json:"visitors"`
+}
+
+func (f *topPagesFeed) jsonFeed(feedURL string) []byte {
+	doc := jsonFeedDocument{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.title,
+		HomePageURL: f.homeURL,
+		FeedURL:     feedURL,
+		Description: fmt.Sprintf("Most viewed pages of the last %d days (%s)", feedDays, f.week),
+		Items:       []jsonFeedItem{},
+	}
+	for _, it := range f.items {
+		doc.Items = append(doc.Items, jsonFeedItem{
+			ID:            it.id,
+			URL:           it.url,
+			Title:         it.title(),
+			ContentText:   it.summary(),
+			DatePublished: f.updated.Format(time.RFC3339),
+			Kaunta:        jsonFeedItemStats{Rank: it.rank, Views: it.views, Visitors: it.visitors},
+		})
+	}
+
+	body, _ := json.MarshalIndent(doc, "", "  ")
+	return body
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/store"
+)
+
+func topPagesFeedResponses(websiteID uuid.UUID, lookup mockResponse) []mockResponse {
+	return []mockResponse{
+		lookup,
+		{
+			match:   "FROM rollup_state",
+			columns: []string{"covered_since", "refreshed_at"},
+			rows:    [][]interface{}{{nil, nil}},
+		},
+		{
+			match:   "SELECT * FROM get_top_pages(",
+			args:    []interface{}{websiteID, 7, 10, 0, nil, nil, nil, nil, nil, nil, nil},
+			columns: []string{"path", "views", "unique_visitors", "avg_engagement_time", "bounce_rate", "total_count"},
+			rows: [][]interface{}{
+				{"/pricing", int64(120), int64(80), 30.0, 40.0, int64(2)},
+				{"/blog/launch", int64(64), int64(50), 95.0, 12.5, int64(2)},
+			},
+		},
+	}
+}
+
+func TestHandleSharedTopPagesFeed_RSS(t *testing.T) {
+	websiteID := uuid.New()
+	app, queue, cleanup := setupFiberTest(t, "/share/:share_id/top-pages", HandleSharedTopPagesFeed,
+		topPagesFeedResponses(websiteID, shareLookup(websiteID, nil, nil)))
+	defer cleanup()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/share/abc123/top-pages", nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	assert.Equal(t, "application/rss+xml; charset=utf-8", resp.Header.Get("Content-Type"))
+
+	var doc rssDocument
+	require.NoError(t, xml.Unmarshal(body, &doc))
+	assert.Equal(t, "Top pages on Example", doc.Channel.Title)
+	require.Len(t, doc.Channel.Items, 2)
+	assert.Equal(t, "#1 /pricing", doc.Channel.Items[0].Title)
+	assert.Equal(t, "https://example.com/pricing", doc.Channel.Items[0].Link)
+	assert.Equal(t, "120 views from 80 visitors in the last 7 days", doc.Channel.Items[0].Description)
+	assert.False(t, doc.Channel.Items[0].GUID.IsPermaLink)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleTopPagesFeed_JSON(t *testing.T) {
+	websiteID := uuid.New()
+	app, queue, cleanup := setupFiberTest(t, "/api/feeds/top-pages/:website_id", HandleTopPagesFeed,
+		topPagesFeedResponses(websiteID, mockResponse{
+			match:   "SELECT website_id, domain, name FROM website WHERE deleted_at IS NULL AND website_id = $1",
+			columns: []string{"website_id", "domain", "name"},
+			rows:    [][]interface{}{{websiteID.String(), "example.com", "Example"}},
+		}))
+	defer cleanup()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/feeds/top-pages/"+websiteID.String()+"?format=json", nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	assert.Equal(t, "application/feed+json; charset=utf-8", resp.Header.Get("Content-Type"))
+
+	var doc jsonFeedDocument
+	require.NoError(t, json.Unmarshal(body, &doc))
+	assert.Equal(t, "https://jsonfeed.org/version/1.1", doc.Version)
+	require.Len(t, doc.Items, 2)
+	assert.Equal(t, "https://example.com/blog/launch", doc.Items[1].URL)
+	assert.Equal(t, jsonFeedItemStats{Rank: 2, Views: 64, Visitors: 50}, doc.Items[1].Kaunta)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleSharedTopPagesFeed_Errors(t *testing.T) {
+	expired := time.Now().Add(-time.Hour)
+	app, _, cleanup := setupFiberTest(t, "/share/:share_id/top-pages", HandleSharedTopPagesFeed, []mockResponse{
+		{match: "AND share_id = $1", columns: shareColumns},
+		shareLookup(uuid.New(), nil, nil),
+		shareLookup(uuid.New(), nil, nil),
+		shareLookup(uuid.New(), nil, expired),
+		shareLookup(uuid.New(), "$2a$10$hash", nil),
+	})
+	defer cleanup()
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/share/unknown/top-pages", http.StatusNotFound},
+		{"/share/abc123/top-pages?format=md", http.StatusBadRequest},
+		{"/share/abc123/top-pages?limit=500", http.StatusBadRequest},
+		{"/share/abc123/top-pages", http.StatusGone},
+		{"/share/abc123/top-pages", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, tt.path, nil))
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, tt.status, resp.StatusCode, tt.path)
+	}
+}
+
+func TestNewTopPagesFeed(t *testing.T) {
+	now := time.Date(2025, 6, 4, 9, 30, 0, 0, time.UTC)
+	feed := newTopPagesFeed(&store.WebsiteRow{Domain: "example.com"},
+		[]store.PageRow{{Path: "/", Views: 3, UniqueVisitors: 2}}, now)
+
+	assert.Equal(t, "Top pages on example.com", feed.title)
+	assert.Equal(t, "2025-W23", feed.week)
+	require.Len(t, feed.items, 1)
+	assert.Equal(t, "https://example.com/#2025-W23", feed.items[0].id)
+}
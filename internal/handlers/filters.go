@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/dimensions"
+	"github.com/seuros/kaunta/internal/segments"
+	"github.com/seuros/kaunta/internal/stats"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+var errSegmentsUnsupported = errors.New("segments require PostgreSQL")
+
+// dimensionFilterPrefix marks a custom dimension filter: dim.content_type=guide
+const dimensionFilterPrefix = "dim."
+
+// parseFilters extracts the dashboard filter parameters (country, browser,
+// device, page, bot and dim.<name>) from the query string. Empty values are
+// ignored by the store.
+func parseFilters(c fiber.Ctx) store.Filters {
+	f := store.Filters{
+		Country: c.Query("country"),
+		Browser: c.Query("browser"),
+		Device:  c.Query("device"),
+		Page:    c.Query("page"),
+	}
+	// bot=true shows only bot traffic, bot=false only humans
+	if bot, err := strconv.ParseBool(c.Query("bot")); err == nil {
+		f.Bot = &bot
+	}
+	for key, value := range c.Queries() {
+		name, ok := strings.CutPrefix(key, dimensionFilterPrefix)
+		if !ok || value == "" || dimensions.ValidName(name) != nil {
+			continue
+		}
+		if f.Dimensions == nil {
+			f.Dimensions = make(map[string]string)
+		}
+		f.Dimensions[name] = value
+	}
+	return f
+}
+
+// dashboardFilters applies parseFilters on top of the website's saved segment
+// named by ?segment=, so explicit parameters narrow or override it
+func dashboardFilters(c fiber.Ctx, websiteID uuid.UUID) (store.Filters, error) {
+	f := parseFilters(c)
+	name := c.Query("segment")
+	if name == "" {
+		return f, nil
+	}
+	if database.DB == nil || store.Current().Name() != "postgres" {
+		return f, errSegmentsUnsupported
+	}
+	segment, err := segments.Filters(c.Context(), database.DB, websiteID, name)
+	if err != nil {
+		return f, err
+	}
+	return segments.Merge(segment, f), nil
+}
+
+// filtersError responds to an error from dashboardFilters
+func filtersError(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, segments.ErrNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "Segment not found"})
+	case errors.Is(err, errSegmentsUnsupported):
+		return c.Status(501).JSON(fiber.Map{"error": "Segments require PostgreSQL"})
+	default:
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load segment"})
+	}
+}
+
+// dashboardRange narrows f to the dates of ?start= and ?end= (ISO dates, both
+// included, in the IANA time zone ?tz=, UTC by default) and returns the
+// period of days reaching back to the start; days when neither is set
+func dashboardRange(c fiber.Ctx, days int, f *store.Filters) (int, error) {
+	start, end := c.Query("start"), c.Query("end")
+	if start == "" && end == "" {
+		return days, nil
+	}
+	now := time.Now()
+	period, err := stats.ParseRange(start, end, c.Query("tz"), now)
+	if err != nil {
+		return days, err
+	}
+	*f = period.Narrow(*f)
+	return period.Days(now), nil
+}
+
+// rangeError responds to an error from dashboardRange
+func rangeError(c fiber.Ctx, err error) error {
+	return c.Status(400).JSON(fiber.Map{"error": "Invalid date range: " + err.Error()})
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/ga4"
+	"github.com/seuros/kaunta/internal/logging"
+	"github.com/seuros/kaunta/internal/store"
+	"go.uber.org/zap"
+)
+
+// ga4PageParams are the event parameters read into the pageview itself
+// rather than kept as event data
+var ga4PageParams = map[string]bool{
+	"page_location":        true,
+	"page_title":           true,
+	"page_referrer":        true,
+	"engagement_time_msec": true,
+	"session_id":           true,
+}
+
+// HandleGA4Collect is the /mp/collect endpoint: GA4 Measurement Protocol
+// hits, for the website given the hit's ?measurement_id= and ?api_secret=.
+// Each event is recorded like an /api/send event, page_view as a pageview;
+// the answer is 204 as from GA. Senders don't retry hits, so they are never
+// deferred under load.
+func HandleGA4Collect(c fiber.Ctx) error {
+	hit, websiteID, err := readGA4Hit(c)
+	if hit == nil {
+		return err
+	}
+	if messages := hit.Validate(); len(messages) > 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error":              messages[0].Description,
+			"validationMessages": messages,
+		})
+	}
+
+	now := time.Now()
+	for _, e := range hit.Events {
+		// Each event writes its own response; only failures are reported
+		if err := track(c, ga4Payload(websiteID, hit, e, now)); err != nil {
+			return err
+		}
+		status := c.Response().StatusCode()
+		c.Response().ResetBody()
+		if status >= 500 || status == fiber.StatusTooManyRequests {
+			logging.L().Warn("ga4 event not recorded",
+				zap.String("website_id", websiteID.String()), zap.String("event", e.Name), zap.Int("status", status))
+			return c.Status(status).JSON(fiber.Map{"error": "Failed to record event " + e.Name})
+		}
+	}
+	return c.SendStatus(204)
+}
+
+// HandleGA4Debug is the /debug/mp/collect endpoint: it checks a hit as
+// /mp/collect would, without recording it, and lists its problems
+func HandleGA4Debug(c fiber.Ctx) error {
+	hit, _, err := readGA4Hit(c)
+	if hit == nil {
+		return err
+	}
+	return c.JSON(fiber.Map{"validationMessages": hit.Validate()})
+}
+
+// readGA4Hit decodes the hit of c and finds its website; it writes the
+// error response itself and returns a nil hit when it can't
+func readGA4Hit(c fiber.Ctx) (*ga4.Request, uuid.UUID, error) {
+	if database.DB == nil || store.Current().Name() != "postgres" {
+		return nil, uuid.Nil, c.Status(501).JSON(fiber.Map{"error": "GA4 ingestion requires PostgreSQL"})
+	}
+	var hit ga4.Request
+	if err := json.Unmarshal(c.Body(), &hit); err != nil {
+		return nil, uuid.Nil, c.Status(400).JSON(fiber.Map{"error": "Invalid JSON payload"})
+	}
+
+	websiteID, err := ga4.Lookup(c.Context(), database.DB, c.Query("measurement_id"), c.Query("api_secret"))
+	switch {
+	case errors.Is(err, ga4.ErrUnknownMeasurement):
+		return nil, uuid.Nil, c.Status(404).JSON(fiber.Map{"error": "Unknown measurement ID"})
+	case errors.Is(err, ga4.ErrInvalidSecret):
+		return nil, uuid.Nil, c.Status(401).JSON(fiber.Map{"error": "Invalid API secret"})
+	case err != nil:
+		logging.L().Error("ga4 lookup failed", zap.Error(err))
+		return nil, uuid.Nil, c.Status(500).JSON(fiber.Map{"error": "Failed to look up measurement ID"})
+	}
+	return &hit, websiteID, nil
+}
+
+// ga4Payload is the /api/send payload of an event of hit
+func ga4Payload(websiteID uuid.UUID, hit *ga4.Request, e ga4.Event, now time.Time) TrackingPayload {
+	timestamp := hit.Time(e, now).Unix()
+	p := PayloadData{
+		Website:   websiteID.String(),
+		Timestamp: &timestamp,
+		URL:       optional(e.Param("page_location")),
+		Title:     optional(e.Param("page_title")),
+		Referrer:  optional(e.Param("page_referrer")),
+		IP:        optional(hit.IPOverride),
+	}
+	if p.URL != nil {
+		if u, err := url.Parse(*p.URL); err == nil && u.Host != "" {
+			p.Hostname = &u.Host
+		}
+	}
+	if ms, ok := ga4Number(e.Params["engagement_time_msec"]); ok {
+		p.EngagementTime = &ms
+	}
+
+	// user_id identifies signed-in visitors across devices, as distinct_id
+	if hit.UserID != "" {
+		p.ID = &hit.UserID
+	}
+
+	if e.Name != "page_view" {
+		name := e.Name
+		p.Name = &name
+		for k, v := range e.Params {
+			if ga4PageParams[k] {
+				continue
+			}
+			if p.Data == nil {
+				p.Data = map[string]interface{}{}
+			}
+			p.Data[k] = v
+		}
+	}
+
+	relay := &relayedHit{visitor: "ga4:" + hit.ClientID}
+	browser, os, device := "Unknown", "Unknown", "desktop"
+	if d := hit.Device; d != nil {
+		p.Language = optional(d.Language)
+		p.Screen = optional(d.ScreenResolution)
+		browser = orDefault(d.Browser, browser)
+		os = orDefault(d.OperatingSystem, os)
+		device = orDefault(d.Category, device)
+	}
+	relay.browser, relay.os, relay.device = &browser, &os, &device
+
+	return TrackingPayload{Type: "event", Payload: p, relay: relay}
+}
+
+// ga4Number reads a numeric parameter, sent as a number or a string
+func ga4Number(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case float64:
+		return int(n), true
+	case string:
+		i, err := strconv.Atoi(n)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// optional is s, nil when empty
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// deref is *s, empty when nil
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// orDefault is s, def when empty
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/ga4"
+)
+
+func TestGA4Payload(t *testing.T) {
+	websiteID := uuid.New()
+	now := time.Unix(1750000000, 0)
+	hit := &ga4.Request{
+		ClientID: "123.456",
+		UserID:   "user-7",
+		Device:   &ga4.Device{Category: "mobile", Language: "en-us", ScreenResolution: "390x844", Browser: "Safari"},
+	}
+
+	view := ga4Payload(websiteID, hit, ga4.Event{Name: "page_view", Params: map[string]interface{}{
+		"page_location":        "https://example.com/pricing?plan=pro",
+		"page_title":           "Pricing",
+		"engagement_time_msec": "1500",
+	}}, now)
+	assert.Nil(t, view.Payload.Name, "page_view is a pageview")
+	assert.Equal(t, "example.com", *view.Payload.Hostname)
+	assert.Equal(t, "Pricing", *view.Payload.Title)
+	assert.Equal(t, 1500, *view.Payload.EngagementTime)
+	assert.Equal(t, now.Unix(), *view.Payload.Timestamp)
+	assert.Equal(t, "user-7", *view.Payload.ID)
+	assert.Equal(t, "390x844", *view.Payload.Screen)
+	assert.Equal(t, "ga4:123.456", view.relay.visitor)
+	assert.Equal(t, "Safari", *view.relay.browser)
+	assert.Equal(t, "Unknown", *view.relay.os)
+	assert.Equal(t, "mobile", *view.relay.device)
+
+	purchase := ga4Payload(websiteID, hit, ga4.Event{Name: "purchase", Params: map[string]interface{}{
+		"page_location": "https://example.com/checkout",
+		"value":         float64(42),
+		"currency":      "EUR",
+		"session_id":    "99",
+	}}, now)
+	assert.Equal(t, "purchase", *purchase.Payload.Name)
+	assert.Equal(t, map[string]interface{}{"value": float64(42), "currency": "EUR"}, purchase.Payload.Data)
+	assert.Equal(t, "https://example.com/checkout", *purchase.Payload.URL)
+}
+
+func TestHandleGA4Credentials(t *testing.T) {
+	websiteID := uuid.New()
+	responses := []mockResponse{
+		{
+			match:   "FROM website",
+			args:    []interface{}{"G-ABC123"},
+			columns: []string{"website_id", "ga_api_secret_hash"},
+			rows:    [][]interface{}{{websiteID.String(), ga4.HashSecret("s3cr3t")}},
+		},
+		{
+			match:   "FROM website",
+			args:    []interface{}{"G-ABC123"},
+			columns: []string{"website_id", "ga_api_secret_hash"},
+			rows:    [][]interface{}{{websiteID.String(), ga4.HashSecret("s3cr3t")}},
+		},
+		{
+			match:   "FROM website",
+			args:    []interface{}{"G-NOPE00"},
+			columns: []string{"website_id", "ga_api_secret_hash"},
+			rows:    [][]interface{}{},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/unused", HandleGA4Collect, responses)
+	defer cleanup()
+	app.Post("/mp/collect", HandleGA4Collect)
+	app.Post("/debug/mp/collect", HandleGA4Debug)
+
+	post := func(path string) *http.Response {
+		body := `{"client_id":"123.456","events":[{"name":"session_start"},{"name":"sign_up"}]}`
+		resp, err := app.Test(httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp
+	}
+
+	resp := post("/debug/mp/collect?measurement_id=G-ABC123&api_secret=s3cr3t")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var debug struct {
+		ValidationMessages []ga4.ValidationMessage `json:"validationMessages"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&debug))
+	require.Len(t, debug.ValidationMessages, 1)
+	assert.Equal(t, "events[0].name", debug.ValidationMessages[0].FieldPath)
+	assert.Equal(t, "NAME_RESERVED", debug.ValidationMessages[0].ValidationCode)
+
+	assert.Equal(t, http.StatusUnauthorized, post("/mp/collect?measurement_id=G-ABC123&api_secret=wrong").StatusCode)
+	assert.Equal(t, http.StatusNotFound, post("/mp/collect?measurement_id=G-NOPE00&api_secret=s3cr3t").StatusCode)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleGA4CollectStoredNearCapacity(t *testing.T) {
+	websiteID := uuid.New()
+	app, queue, cleanup := setupFiberTest(t, "/unused", HandleGA4Collect, []mockResponse{
+		{
+			match:   "FROM website",
+			args:    []interface{}{"G-ABC123"},
+			columns: []string{"website_id", "ga_api_secret_hash"},
+			rows:    [][]interface{}{{websiteID.String(), ga4.HashSecret("s3cr3t")}},
+		},
+	})
+	defer cleanup()
+	useIngestStore(t)
+	buffer := nearlyFullBuffer(t)
+	app.Post("/mp/collect", HandleGA4Collect)
+
+	body := `{"client_id":"123.456","events":[{"name":"sign_up","params":{"page_location":"https://example.com/"}}]}`
+	req := httptest.NewRequest(http.MethodPost, "/mp/collect?measurement_id=G-ABC123&api_secret=s3cr3t", strings.NewReader(body))
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, 10, buffer.Len())
+	require.NoError(t, queue.expectationsMet())
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/groups"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// groupDimensions are the breakdowns a website group serves
+var groupDimensions = []string{"page", "referrer", "browser", "device", "country", "region", "city", "asn", "screen", "viewport", "channel"}
+
+// findGroup resolves :group_id, responding itself when it can't; segments
+// are saved per website, so groups take the plain filters only
+func findGroup(c fiber.Ctx) (*groups.Group, error) {
+	if database.DB == nil {
+		return nil, c.Status(501).JSON(fiber.Map{"error": "Website groups require PostgreSQL"})
+	}
+	groupID, err := uuid.Parse(c.Params("group_id"))
+	if err != nil {
+		return nil, c.Status(400).JSON(fiber.Map{"error": "Invalid group ID"})
+	}
+	if c.Query("segment") != "" {
+		return nil, c.Status(400).JSON(fiber.Map{"error": "Segments apply to single websites, not groups"})
+	}
+	g, err := groups.Find(c.Context(), database.DB, groupID)
+	if errors.Is(err, groups.ErrNotFound) {
+		return nil, c.Status(404).JSON(fiber.Map{"error": "Website group not found"})
+	}
+	if err != nil {
+		return nil, c.Status(500).JSON(fiber.Map{"error": "Failed to load website group"})
+	}
+	return g, nil
+}
+
+// HandleListGroups returns the website groups with their websites
+// GET /api/groups
+func HandleListGroups(c fiber.Ctx) error {
+	if database.DB == nil {
+		return c.Status(501).JSON(fiber.Map{"error": "Website groups require PostgreSQL"})
+	}
+	list, err := groups.List(c.Context(), database.DB)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to list website groups"})
+	}
+	return c.JSON(list)
+}
+
+// HandleGroupStats returns today's stats of a website group, summed over
+// its websites like HandleDashboardStats
+// GET /api/groups/:group_id/stats
+func HandleGroupStats(c fiber.Ctx) error {
+	g, err := findGroup(c)
+	if g == nil {
+		return err
+	}
+	stats, err := groups.DashboardStats(c.Context(), store.Current(), g.WebsiteIDs(), parseFilters(c))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query stats"})
+	}
+	return c.JSON(DashboardStats{
+		CurrentVisitors: int(stats.CurrentVisitors),
+		TodayPageviews:  int(stats.TodayPageviews),
+		TodayVisitors:   int(stats.TodayVisitors),
+		TodayBounceRate: fmt.Sprintf("%.1f%%", stats.BounceRate),
+	})
+}
+
+// HandleGroupTimeSeries returns the pageviews of a website group over time,
+// with the parameters of HandleTimeSeries (without notes or annotations,
+// which belong to single websites)
+// GET /api/groups/:group_id/timeseries
+func HandleGroupTimeSeries(c fiber.Ctx) error {
+	interval, err := store.ParseInterval(c.Query("interval"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	days := min(fiber.Query[int](c, "days", 7), interval.MaxDays())
+	filters := parseFilters(c)
+	if days, err = dashboardRange(c, days, &filters); err != nil {
+		return rangeError(c, err)
+	}
+	if interval == store.IntervalMinute && !filters.From.IsZero() && filters.From.AddDate(0, 0, 1).Before(filters.To) {
+		return rangeError(c, fmt.Errorf("interval minute covers at most 1 day"))
+	}
+
+	g, err := findGroup(c)
+	if g == nil {
+		return err
+	}
+	rows, err := groups.TimeSeries(c.Context(), store.Current(), g.WebsiteIDs(), days, interval, filters)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query time series"})
+	}
+	points := make([]TimeSeriesPoint, 0, len(rows))
+	for _, row := range rows {
+		points = append(points, TimeSeriesPoint{Timestamp: row.Timestamp, Value: int(row.Views)})
+	}
+	return c.JSON(points)
+}
+
+// HandleGroupBreakdown returns a breakdown of a website group, paginated
+// like the website breakdowns
+// GET /api/groups/:group_id/breakdown/:dimension
+func HandleGroupBreakdown(c fiber.Ctx) error {
+	dimension := c.Params("dimension")
+	if !slices.Contains(groupDimensions, dimension) {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid dimension: " + dimension})
+	}
+	pagination := ParsePaginationParams(c)
+	days := min(max(fiber.Query[int](c, "days", 1), 1), 90)
+	filters := parseFilters(c)
+	var err error
+	if days, err = dashboardRange(c, days, &filters); err != nil {
+		return rangeError(c, err)
+	}
+
+	g, err := findGroup(c)
+	if g == nil {
+		return err
+	}
+	rows, total, err := groups.Breakdown(c.Context(), store.Current(), g.WebsiteIDs(), dimension, days, pagination.Per, pagination.Offset, filters)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query " + dimension})
+	}
+	items := make([]BreakdownItem, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, BreakdownItem{Name: row.Name, Count: int(row.Count)})
+	}
+	return c.JSON(NewPaginatedResponse(items, pagination, total))
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var groupColumns = []string{"group_id", "name", "created_at", "website_id", "domain"}
+
+// groupLookup answers groups.Find with a group of the given websites
+func groupLookup(groupID uuid.UUID, websiteIDs ...uuid.UUID) mockResponse {
+	rows := [][]interface{}{}
+	for _, id := range websiteIDs {
+		rows = append(rows, []interface{}{groupID.String(), "acme", time.Now(), id.String(), id.String()[:8] + ".example"})
+	}
+	return mockResponse{match: "FROM website_group g", columns: groupColumns, rows: rows, args: []interface{}{groupID}}
+}
+
+func TestHandleGroupStats(t *testing.T) {
+	groupID, a, b := uuid.New(), uuid.New(), uuid.New()
+	statsColumns := []string{"current_visitors", "today_pageviews", "today_visitors", "bounce_rate"}
+	responses := []mockResponse{
+		groupLookup(groupID, a, b),
+		{match: "get_dashboard_stats", columns: statsColumns, rows: [][]interface{}{{int64(2), int64(90), int64(30), 40.0}}, args: []interface{}{a, nil, nil, nil, nil, nil, nil}},
+		{match: "get_dashboard_stats", columns: statsColumns, rows: [][]interface{}{{int64(1), int64(10), int64(10), 80.0}}, args: []interface{}{b, nil, nil, nil, nil, nil, nil}},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/groups/:group_id/stats", HandleGroupStats, responses)
+	defer cleanup()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/groups/"+groupID.String()+"/stats", nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var stats DashboardStats
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&stats))
+	assert.Equal(t, DashboardStats{CurrentVisitors: 3, TodayPageviews: 100, TodayVisitors: 40, TodayBounceRate: "50.0%"}, stats)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleGroupBreakdown(t *testing.T) {
+	groupID, a, b := uuid.New(), uuid.New(), uuid.New()
+	columns := []string{"name", "count", "total_count"}
+	responses := []mockResponse{
+		groupLookup(groupID, a, b),
+		{match: "get_breakdown(", columns: columns, rows: [][]interface{}{{"Chrome", int64(20), int64(2)}, {"Firefox", int64(5), int64(2)}}},
+		{match: "get_breakdown(", columns: columns, rows: [][]interface{}{{"Firefox", int64(30), int64(1)}}},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/groups/:group_id/breakdown/:dimension", HandleGroupBreakdown, responses)
+	defer cleanup()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/groups/"+groupID.String()+"/breakdown/browser", nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var paginated PaginatedResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&paginated))
+	itemsJSON, err := json.Marshal(paginated.Data)
+	require.NoError(t, err)
+	var items []BreakdownItem
+	require.NoError(t, json.Unmarshal(itemsJSON, &items))
+	assert.Equal(t, []BreakdownItem{{Name: "Firefox", Count: 35}, {Name: "Chrome", Count: 20}}, items)
+	assert.Equal(t, int64(2), paginated.Pagination.Total)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleGroupErrors(t *testing.T) {
+	groupID := uuid.New()
+	tests := []struct {
+		name      string
+		path      string
+		responses []mockResponse
+		status    int
+	}{
+		{"invalid group ID", "/api/groups/nope/breakdown/browser", nil, http.StatusBadRequest},
+		{"invalid dimension", "/api/groups/" + groupID.String() + "/breakdown/password", nil, http.StatusBadRequest},
+		{"segment", "/api/groups/" + groupID.String() + "/breakdown/browser?segment=mobile", nil, http.StatusBadRequest},
+		{"unknown group", "/api/groups/" + groupID.String() + "/breakdown/browser", []mockResponse{groupLookup(groupID)}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, queue, cleanup := setupFiberTest(t, "/api/groups/:group_id/breakdown/:dimension", HandleGroupBreakdown, tt.responses)
+			defer cleanup()
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, tt.path, nil))
+			require.NoError(t, err)
+			defer func() { _ = resp.Body.Close() }()
+
+			assert.Equal(t, tt.status, resp.StatusCode)
+			require.NoError(t, queue.expectationsMet())
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"net/url"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/logging"
+	"github.com/seuros/kaunta/internal/newsletter"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// HandleEmailOpen serves GET /e/open.gif, the open pixel of an email:
+//
+//	/e/open.gif?website=<id>&campaign=<name>&source=<utm_source>&r=<recipient>
+//
+// The open is recorded as an email_open event of the campaign. The answer
+// is always the image, as for /k.gif, and opens are never deferred under
+// load: mail clients don't load an image again.
+func HandleEmailOpen(c fiber.Ctx) error {
+	if campaign := c.Query("campaign"); campaign != "" {
+		name := newsletter.OpenEvent
+		source := c.Query("source", newsletter.DefaultSource)
+		medium := newsletter.Medium
+		p := PayloadData{
+			Website:  c.Query("website"),
+			Name:     &name,
+			Props:    recipientProps(c),
+			campaign: &utmParams{Source: &source, Medium: &medium, Campaign: &campaign},
+		}
+		if err := track(c, TrackingPayload{Type: "event", Payload: p, pixel: true, email: true}); err != nil {
+			return err
+		}
+		if status := c.Response().StatusCode(); status >= 400 {
+			logging.L().Debug("email open not recorded", zap.String("website_id", p.Website), zap.Int("status", status))
+		}
+	}
+
+	c.Response().ResetBody()
+	c.Response().Header.Del(fiber.HeaderRetryAfter)
+	c.Status(fiber.StatusOK)
+	c.Set("Content-Type", "image/gif")
+	c.Set("Cache-Control", "no-store, max-age=0")
+	return c.Send(transparentGIF)
+}
+
+// HandleEmailClick serves GET /e/click, the tracked links of an email:
+//
+//	/e/click?website=<id>&campaign=<name>&url=<target>&source=<utm_source>&r=<recipient>
+//
+// The target, which must be on one of the website's domains, is tagged
+// with the campaign's utm_* parameters; the click is recorded on it as an
+// email_click event, then the visitor is redirected to it, also when the
+// click isn't recorded (bots, excluded traffic, errors). As short link
+// clicks, email clicks are never deferred under load.
+func HandleEmailClick(c fiber.Ctx) error {
+	websiteID, err := uuid.Parse(c.Query("website"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid website ID")
+	}
+	target, err := newsletter.Tag(c.Query("url"), c.Query("campaign"), c.Query("source"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid link target")
+	}
+	settings, err := store.Current().WebsiteSettings(c.Context(), websiteID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).SendString("Website not found")
+	}
+	// Only the website's pages: anything else would make an open redirect
+	u, _ := url.Parse(target)
+	if !settings.OwnDomain(u.Hostname()) {
+		return c.Status(fiber.StatusBadRequest).SendString("Link target isn't on the website's domains")
+	}
+
+	if c.Query("campaign") != "" {
+		name := newsletter.ClickEvent
+		p := PayloadData{
+			Website:  websiteID.String(),
+			URL:      &target,
+			Hostname: &u.Host,
+			Referrer: optional(c.Get(fiber.HeaderReferer)),
+			Name:     &name,
+			Props:    recipientProps(c),
+		}
+		if err := track(c, TrackingPayload{Type: "event", Payload: p, shortLink: true, email: true}); err != nil {
+			return err
+		}
+		if status := c.Response().StatusCode(); status >= 400 {
+			logging.L().Debug("email click not recorded", zap.String("website_id", p.Website), zap.Int("status", status))
+		}
+	}
+
+	c.Response().ResetBody()
+	c.Response().Header.Del(fiber.HeaderAccessControlAllowOrigin)
+	c.Response().Header.Del(fiber.HeaderRetryAfter)
+	c.Set(fiber.HeaderCacheControl, "no-store, max-age=0")
+	return c.Redirect().Status(fiber.StatusFound).To(target)
+}
+
+// recipientProps holds the recipient token of an email hit, when it is a
+// valid one; track drops it unless recipients may be recorded
+func recipientProps(c fiber.Ctx) map[string]interface{} {
+	token := c.Query("r")
+	if !newsletter.ValidRecipient(token) {
+		return nil
+	}
+	return map[string]interface{}{newsletter.RecipientProp: token}
+}
+
+// HandleEmailCampaigns returns a website's email campaigns of the last
+// ?days= (default 30, at most 365): opens, clicks, the sessions they
+// brought and their conversions (?goal= counts one custom event), up to
+// ?limit= campaigns (default 20, at most 100)
+// GET /api/dashboard/email/:website_id
+func HandleEmailCampaigns(c fiber.Ctx) error {
+	websiteID, err := uuid.Parse(c.Params("website_id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid website ID"})
+	}
+	if database.DB == nil || store.Current().Name() != "postgres" {
+		return c.Status(501).JSON(fiber.Map{"error": "Email campaigns require PostgreSQL"})
+	}
+
+	days := min(max(fiber.Query[int](c, "days", 30), 1), 365)
+	limit := min(max(fiber.Query[int](c, "limit", 20), 1), 100)
+
+	campaigns, err := newsletter.Report(c.Context(), database.DB, websiteID, days, limit, c.Query("goal"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query email campaigns"})
+	}
+	return c.JSON(campaigns)
+}
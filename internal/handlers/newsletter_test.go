@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/store"
+)
+
+var emailSettingsColumns = []string{"proxy_mode", "bot_filter", "respect_dnt", "domain", "allowed_domains",
+	"exclude_self_referrals", "referral_domains", "props_max_bytes", "props_max_depth", "visit_definition", "session_timeout"}
+
+func TestHandleEmailOpenAlwaysServesImage(t *testing.T) {
+	app := fiber.New()
+	app.Get("/e/open.gif", HandleEmailOpen)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/e/open.gif?website=not-a-uuid&campaign=spring", nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "image/gif", resp.Header.Get("Content-Type"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, transparentGIF, body)
+}
+
+func TestHandleEmailClickRedirectsTagged(t *testing.T) {
+	websiteID := uuid.New()
+	app, queue, cleanup := setupFiberTest(t, "/e/click", HandleEmailClick, []mockResponse{
+		{match: "FROM website WHERE website_id", columns: emailSettingsColumns,
+			rows: [][]interface{}{{"none", true, "off", "example.com", []byte(`[]`), true, []byte(`[]`), 0, 0, "", 0}}},
+		// The click isn't recorded: the visitor is redirected all the same
+		{match: "FROM website WHERE website_id", err: sql.ErrNoRows},
+	})
+	defer cleanup()
+	previous := store.Current()
+	store.SetCurrent(store.NewPostgres())
+	defer store.SetCurrent(previous)
+
+	query := url.Values{"website": {websiteID.String()}, "campaign": {"spring"}, "url": {"https://www.example.com/sale?utm_source=digest"}}
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/e/click?"+query.Encode(), nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, "https://www.example.com/sale?utm_campaign=spring&utm_medium=email&utm_source=digest", resp.Header.Get("Location"))
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleEmailClickRejectsOtherDomains(t *testing.T) {
+	websiteID := uuid.New()
+	app, _, cleanup := setupFiberTest(t, "/e/click", HandleEmailClick, []mockResponse{
+		{match: "FROM website WHERE website_id", columns: emailSettingsColumns,
+			rows: [][]interface{}{{"none", true, "off", "example.com", []byte(`[]`), true, []byte(`[]`), 0, 0, "", 0}}},
+	})
+	defer cleanup()
+	previous := store.Current()
+	store.SetCurrent(store.NewPostgres())
+	defer store.SetCurrent(previous)
+
+	query := url.Values{"website": {websiteID.String()}, "campaign": {"spring"}, "url": {"https://example.com.evil.net/"}}
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/e/click?"+query.Encode(), nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Location"))
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/e/click?website="+websiteID.String()+"&url=javascript:alert(1)", nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestEmailOpensAndClicksStoredNearCapacity(t *testing.T) {
+	st := useIngestStore(t)
+	buffer := nearlyFullBuffer(t)
+	app := fiber.New()
+	app.Get("/e/open.gif", HandleEmailOpen)
+	app.Get("/e/click", HandleEmailClick)
+	websiteID := uuid.New()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/e/open.gif?website="+websiteID.String()+"&campaign=spring", nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 10, buffer.Len())
+
+	// The buffer is full now: the click is written directly
+	query := url.Values{"website": {websiteID.String()}, "campaign": {"spring"}, "url": {"https://example.com/sale"}}
+	req := httptest.NewRequest(http.MethodGet, "/e/click?"+query.Encode(), nil)
+	req.Header.Set("Accept-Language", "en")
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Retry-After"))
+	require.Len(t, st.events, 1)
+	assert.Equal(t, "email_click", *st.events[0].EventName)
+}
+
+func TestRecipientProps(t *testing.T) {
+	app := fiber.New()
+	var got map[string]interface{}
+	app.Get("/e/open.gif", func(c fiber.Ctx) error {
+		got = recipientProps(c)
+		return nil
+	})
+
+	_, err := app.Test(httptest.NewRequest(http.MethodGet, "/e/open.gif?r=sub_4821", nil))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"recipient": "sub_4821"}, got)
+
+	// Addresses aren't tokens
+	_, err = app.Test(httptest.NewRequest(http.MethodGet, "/e/open.gif?r=jane%40example.com", nil))
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/middleware"
+	"github.com/seuros/kaunta/internal/notes"
+)
+
+// noteDays reads the days query parameter of the notes and annotations
+// endpoints, like the time series (default 7, max 90)
+func noteDays(c fiber.Ctx) int {
+	days := fiber.Query[int](c, "days", 7)
+	if days < 1 {
+		days = 1
+	}
+	if days > 90 {
+		days = 90
+	}
+	return days
+}
+
+// HandleListNotes lists the notes of a website's last days
+// GET /api/notes/:website_id?days=7
+func HandleListNotes(c fiber.Ctx) error {
+	websiteID, err := uuid.Parse(c.Params("website_id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid website ID"})
+	}
+	if database.DB == nil {
+		return c.Status(501).JSON(fiber.Map{"error": "Notes require PostgreSQL"})
+	}
+	list, err := notes.LastDays(c.Context(), database.DB, websiteID, noteDays(c), time.Now())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to list notes"})
+	}
+	return c.JSON(list)
+}
+
+// HandleAddNote attaches a note to a day of a website, signed by the
+// signed-in user. The body is {"date": "YYYY-MM-DD", "text": "..."}.
+// POST /api/notes/:website_id
+func HandleAddNote(c fiber.Ctx) error {
+	websiteID, err := uuid.Parse(c.Params("website_id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid website ID"})
+	}
+	if database.DB == nil {
+		return c.Status(501).JSON(fiber.Map{"error": "Notes require PostgreSQL"})
+	}
+
+	var body struct {
+		Date string `json:"date"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(c.Body(), &body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid note: " + err.Error()})
+	}
+	date, err := notes.ParseDate(body.Date)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if _, err := notes.Validate(body.Text); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var exists bool
+	if err := database.DB.QueryRowContext(c.Context(),
+		`SELECT EXISTS (SELECT 1 FROM website WHERE website_id = $1 AND deleted_at IS NULL)`,
+		websiteID).Scan(&exists); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to look up website"})
+	}
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{"error": "Website not found"})
+	}
+
+	author := ""
+	if user := middleware.GetUser(c); user != nil {
+		author = user.Username
+	}
+	note, err := notes.Add(c.Context(), database.DB, websiteID, date, body.Text, author)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to add note"})
+	}
+	return c.Status(201).JSON(note)
+}
+
+// HandleDeleteNote deletes a note of a website
+// DELETE /api/notes/:website_id/:note_id
+func HandleDeleteNote(c fiber.Ctx) error {
+	websiteID, err := uuid.Parse(c.Params("website_id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid website ID"})
+	}
+	noteID, err := uuid.Parse(c.Params("note_id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid note ID"})
+	}
+	if database.DB == nil {
+		return c.Status(501).JSON(fiber.Map{"error": "Notes require PostgreSQL"})
+	}
+	err = notes.Remove(c.Context(), database.DB, websiteID, noteID)
+	if errors.Is(err, notes.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Note not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete note"})
+	}
+	return c.SendStatus(204)
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/middleware"
+)
+
+func TestHandleAddNote(t *testing.T) {
+	websiteID, noteID := uuid.New(), uuid.New()
+	responses := []mockResponse{
+		{
+			match:   "SELECT EXISTS (SELECT 1 FROM website",
+			columns: []string{"exists"},
+			rows:    [][]interface{}{{true}},
+			args:    []interface{}{websiteID},
+		},
+		{
+			match:   "INSERT INTO note",
+			columns: []string{"note_id", "created_at"},
+			rows:    [][]interface{}{{noteID.String(), time.Now()}},
+			args:    []interface{}{websiteID, "2025-11-05", "outage 14:00-15:30", "alice"},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/notes/:website_id", HandleListNotes, responses)
+	defer cleanup()
+	app.Post("/api/notes/:website_id", func(c fiber.Ctx) error {
+		c.Locals("user", &middleware.UserContext{Username: "alice"})
+		return HandleAddNote(c)
+	})
+
+	body := `{"date":"2025-11-05","text":" outage 14:00-15:30 "}`
+	req := httptest.NewRequest(http.MethodPost, "/api/notes/"+websiteID.String(), strings.NewReader(body))
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	var note map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&note))
+	assert.Equal(t, noteID.String(), note["note_id"])
+	assert.Equal(t, "alice", note["author"])
+	assert.Equal(t, "2025-11-05", note["date"])
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleAddNoteInvalid(t *testing.T) {
+	app, queue, cleanup := setupFiberTest(t, "/api/notes/:website_id", HandleListNotes, nil)
+	defer cleanup()
+	app.Post("/api/notes/:website_id", HandleAddNote)
+
+	for _, body := range []string{
+		`{"date":"05/11/2025","text":"newsletter sent"}`,
+		`{"date":"2025-11-05","text":"   "}`,
+		`{"date":"2025-11-05","text":"` + strings.Repeat("x", 281) + `"}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/notes/"+uuid.NewString(), strings.NewReader(body))
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+	}
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleListNotes(t *testing.T) {
+	websiteID := uuid.New()
+	responses := []mockResponse{
+		{
+			match:   "FROM note",
+			columns: []string{"note_id", "website_id", "note_date", "body", "author", "created_at"},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/notes/:website_id", HandleListNotes, responses)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/notes/"+websiteID.String()+"?days=30", nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var list []interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	assert.NotNil(t, list)
+	assert.Empty(t, list)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleDeleteNoteInvalidNoteID(t *testing.T) {
+	app, _, cleanup := setupFiberTest(t, "/api/notes/:website_id/:note_id", HandleDeleteNote, nil)
+	defer cleanup()
+	app.Delete("/api/notes/:website_id/:note_id", HandleDeleteNote)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/notes/"+uuid.NewString()+"/1", nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
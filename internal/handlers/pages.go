@@ -3,11 +3,12 @@ package handlers
 import (
 	"github.com/gofiber/fiber/v3"
 	"github.com/google/uuid"
-	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/stats"
+	"github.com/seuros/kaunta/internal/store"
 )
 
 // HandleTopPages returns top pages for the dashboard
-// Uses PostgreSQL function get_top_pages() for optimized query execution
+// Uses get_top_pages() on PostgreSQL for optimized query execution
 func HandleTopPages(c fiber.Ctx) error {
 	websiteIDStr := c.Params("website_id")
 	websiteID, err := uuid.Parse(websiteIDStr)
@@ -20,61 +21,33 @@ func HandleTopPages(c fiber.Ctx) error {
 	// Parse pagination parameters
 	pagination := ParsePaginationParams(c)
 
-	// Extract filter parameters
-	country := c.Query("country")
-	browser := c.Query("browser")
-	device := c.Query("device")
-
-	// Convert empty strings to NULL for SQL
-	var countryParam, browserParam, deviceParam interface{}
-	if country != "" {
-		countryParam = country
-	}
-	if browser != "" {
-		browserParam = browser
+	filters, err := dashboardFilters(c, websiteID)
+	if err != nil {
+		return filtersError(c, err)
 	}
-	if device != "" {
-		deviceParam = device
+
+	// Today unless the query asks for a range
+	days, err := dashboardRange(c, 1, &filters)
+	if err != nil {
+		return rangeError(c, err)
 	}
 
 	// Call get_top_pages() function with pagination
-	// Function returns: (path, views, unique_visitors, avg_engagement_time, total_count)
-	query := `SELECT * FROM get_top_pages($1, 1, $2, $3, $4, $5, $6)`
-	rows, err := database.DB.Query(
-		query,
-		websiteID,
-		pagination.Per,
-		pagination.Offset,
-		countryParam,
-		browserParam,
-		deviceParam,
-	)
-
+	rows, totalCount, err := store.Current().TopPages(c.Context(), websiteID, days, pagination.Per, pagination.Offset, filters)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to query top pages",
 		})
 	}
-	defer func() { _ = rows.Close() }()
-
-	pages := make([]TopPage, 0)
-	var totalCount int64
-	for rows.Next() {
-		var path string
-		var views int64
-		var uniqueVisitors int64   // Not used in response, but returned by function
-		var avgEngagement *float64 // Not used in response, but returned by function
-		var rowTotal int64
-
-		if err := rows.Scan(&path, &views, &uniqueVisitors, &avgEngagement, &rowTotal); err != nil {
-			continue
-		}
-
-		totalCount = rowTotal // Capture total count from function
 
+	pages := make([]TopPage, 0, len(rows))
+	for _, row := range rows {
 		pages = append(pages, TopPage{
-			Path:  path,
-			Views: int(views),
+			Path:           row.Path,
+			Views:          int(row.Views),
+			UniqueVisitors: int(row.UniqueVisitors),
+			AvgEngagement:  stats.Seconds(row.AvgEngagement),
+			BounceRate:     row.BounceRate,
 		})
 	}
 
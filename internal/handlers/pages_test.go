@@ -19,10 +19,10 @@ func TestHandleTopPages_Success(t *testing.T) {
 	responses := []mockResponse{
 		{
 			match:   "SELECT * FROM get_top_pages(",
-			columns: []string{"path", "views", "unique_visitors", "avg_engagement_time", "total_count"},
+			columns: []string{"path", "views", "unique_visitors", "avg_engagement_time", "bounce_rate", "total_count"},
 			rows: [][]interface{}{
-				{"/", int64(42), int64(30), 45.2, int64(2)},
-				{"/docs", int64(21), int64(15), 32.5, int64(2)},
+				{"/", int64(42), int64(30), 45200.0, 60.0, int64(2)},
+				{"/docs", int64(21), int64(15), 32500.0, 20.0, int64(2)},
 			},
 		},
 	}
@@ -52,6 +52,9 @@ func TestHandleTopPages_Success(t *testing.T) {
 	assert.Len(t, pages, 2)
 	assert.Equal(t, "/", pages[0].Path)
 	assert.Equal(t, 42, pages[0].Views)
+	assert.Equal(t, 30, pages[0].UniqueVisitors)
+	assert.Equal(t, 45.2, *pages[0].AvgEngagement)
+	assert.Equal(t, 60.0, *pages[0].BounceRate)
 	assert.Equal(t, int64(2), paginatedResp.Pagination.Total)
 
 	require.NoError(t, queue.expectationsMet())
@@ -62,9 +65,9 @@ func TestHandleTopPages_WithFilters(t *testing.T) {
 	responses := []mockResponse{
 		{
 			match:   "SELECT * FROM get_top_pages(",
-			columns: []string{"path", "views", "unique_visitors", "avg_engagement_time", "total_count"},
+			columns: []string{"path", "views", "unique_visitors", "avg_engagement_time", "bounce_rate", "total_count"},
 			rows: [][]interface{}{
-				{"/docs", int64(12), int64(10), 40.0, int64(1)},
+				{"/docs", int64(12), int64(10), 40.0, 10.0, int64(1)},
 			},
 		},
 	}
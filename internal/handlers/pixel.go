@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/url"
+
+	"github.com/gofiber/fiber/v3"
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/logging"
+)
+
+// HandlePixel serves GET /k.gif, tracking without JavaScript: email opens,
+// <noscript> visitors and feed readers load it as an image. The pageview is
+// recorded as by /api/send from the query string:
+//
+//	/k.gif?website=<id>&url=<page>&title=<title>&referrer=<referrer>
+//
+// Without url, the page embedding the image (its Referer) is recorded. The
+// answer is always the image, so a dropped or failed hit never shows up as a
+// broken image. An image isn't loaded again, so hits are never deferred
+// under load, and a rate-limited hit carries no Retry-After.
+func HandlePixel(c fiber.Ctx) error {
+	p := pixelPayload(c)
+	if err := track(c, TrackingPayload{Type: "event", Payload: p, pixel: true}); err != nil {
+		return err
+	}
+	if status := c.Response().StatusCode(); status >= 400 {
+		logging.L().Debug("pixel hit not recorded", zap.String("website_id", p.Website), zap.Int("status", status))
+	}
+
+	c.Response().ResetBody()
+	c.Response().Header.Del(fiber.HeaderRetryAfter)
+	c.Status(fiber.StatusOK)
+	c.Set("Content-Type", "image/gif")
+	c.Set("Cache-Control", "no-store, max-age=0")
+	return c.Send(transparentGIF)
+}
+
+// pixelPayload is the pageview of a /k.gif request
+func pixelPayload(c fiber.Ctx) PayloadData {
+	p := PayloadData{
+		Website:  c.Query("website"),
+		URL:      optional(c.Query("url")),
+		Title:    optional(c.Query("title")),
+		Referrer: optional(c.Query("referrer")),
+	}
+	if p.URL == nil {
+		p.URL = optional(c.Get(fiber.HeaderReferer))
+	}
+	if p.URL != nil {
+		if u, err := url.Parse(*p.URL); err == nil && u.Host != "" {
+			p.Hostname = &u.Host
+		}
+	}
+	return p
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/ingest"
+)
+
+func TestPixelPayload(t *testing.T) {
+	app := fiber.New()
+	var got PayloadData
+	app.Get("/k.gif", func(c fiber.Ctx) error {
+		got = pixelPayload(c)
+		return nil
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/k.gif?website=abc&url=https%3A%2F%2Fexample.com%2Femail%2Fjune&title=June+issue", nil)
+	_, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", got.Website)
+	assert.Equal(t, "https://example.com/email/june", *got.URL)
+	assert.Equal(t, "example.com", *got.Hostname)
+	assert.Equal(t, "June issue", *got.Title)
+	assert.Nil(t, got.Referrer)
+
+	// <noscript> images record the page they are on
+	req = httptest.NewRequest(http.MethodGet, "/k.gif?website=abc", nil)
+	req.Header.Set("Referer", "https://example.com/pricing")
+	_, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/pricing", *got.URL)
+	assert.Equal(t, "example.com", *got.Hostname)
+}
+
+func TestPixelAlwaysServesImage(t *testing.T) {
+	app := fiber.New()
+	app.Get("/k.gif", HandlePixel)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/k.gif?website=not-a-uuid", nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "image/gif", resp.Header.Get("Content-Type"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, transparentGIF, body)
+}
+
+func TestPixelStoredNearCapacity(t *testing.T) {
+	useIngestStore(t)
+	buffer := nearlyFullBuffer(t)
+	app := fiber.New()
+	app.Get("/k.gif", HandlePixel)
+
+	// An <img> is never loaded again: the hit is buffered, not deferred
+	req := httptest.NewRequest(http.MethodGet, "/k.gif?website="+uuid.NewString()+"&url=https%3A%2F%2Fexample.com%2F", nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Retry-After"))
+	assert.Equal(t, 10, buffer.Len())
+}
+
+func TestPixelRateLimitedServesImage(t *testing.T) {
+	useIngestStore(t)
+	websiteID := uuid.New()
+	limiter := ingest.NewRateLimiter(0, 1)
+	_, wait := limiter.Allow("", websiteID)
+	require.Zero(t, wait)
+	ingest.SetRateLimiter(limiter)
+	t.Cleanup(func() { ingest.SetRateLimiter(nil) })
+	app := fiber.New()
+	app.Get("/k.gif", HandlePixel)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/k.gif?website="+websiteID.String(), nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "image/gif", resp.Header.Get("Content-Type"))
+	assert.Empty(t, resp.Header.Get("Retry-After"))
+}
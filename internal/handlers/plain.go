@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"embed"
+	"errors"
+	"html/template"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/store"
+)
+
+//go:embed templates/plain.html
+var plainFS embed.FS
+
+var plainTemplate = template.Must(template.ParseFS(plainFS, "templates/plain.html"))
+
+// plainListLimit is the rows of each top list of the plain dashboard
+const plainListLimit = 10
+
+// plainBreakdowns are the top lists of the plain dashboard after the pages
+var plainBreakdowns = []struct{ dimension, title, column string }{
+	{"referrer", "Top referrers", "Referrer"},
+	{"country", "Top countries", "Country"},
+	{"browser", "Top browsers", "Browser"},
+	{"device", "Top devices", "Device"},
+}
+
+// plainList is a top list of the plain dashboard
+type plainList struct {
+	Title  string
+	Column string
+	Unit   string
+	Rows   []store.NamedCount
+}
+
+// HandlePlainDashboard renders today's key numbers and top lists as plain
+// HTML, without JavaScript, for screen readers, text browsers and checking
+// on a slow connection. ?refresh=N (10-3600) reloads the page every N seconds.
+// GET /plain/:website_id
+func HandlePlainDashboard(c fiber.Ctx) error {
+	websiteID, err := uuid.Parse(c.Params("website_id"))
+	if err != nil {
+		return c.Status(400).SendString("Invalid website ID")
+	}
+
+	website, err := store.Current().FindWebsite(c.Context(), websiteID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(404).SendString("Website not found")
+	}
+	if err != nil {
+		return c.Status(500).SendString("Failed to load website")
+	}
+	return renderPlainDashboard(c, websiteID, website)
+}
+
+// renderPlainDashboard renders the plain dashboard of a website, for its
+// users and behind share links
+func renderPlainDashboard(c fiber.Ctx, websiteID uuid.UUID, website *store.WebsiteRow) error {
+	stats, err := store.Current().DashboardStats(c.Context(), websiteID, store.Filters{})
+	if err != nil {
+		return c.Status(500).SendString("Failed to query stats")
+	}
+
+	pages, _, err := store.Current().TopPages(c.Context(), websiteID, 1, plainListLimit, 0, store.Filters{})
+	if err != nil {
+		return c.Status(500).SendString("Failed to query top pages")
+	}
+	lists := []plainList{{Title: "Top pages", Column: "Page", Unit: "Pageviews"}}
+	for _, page := range pages {
+		lists[0].Rows = append(lists[0].Rows, store.NamedCount{Name: page.Path, Count: page.Views})
+	}
+	for _, b := range plainBreakdowns {
+		rows, _, err := store.Current().Breakdown(c.Context(), websiteID, b.dimension, 1, plainListLimit, 0, store.Filters{})
+		if err != nil {
+			return c.Status(500).SendString("Failed to query " + b.dimension)
+		}
+		if b.dimension == "country" {
+			for i := range rows {
+				rows[i].Name = getCountryName(rows[i].Name)
+			}
+		}
+		lists = append(lists, plainList{Title: b.title, Column: b.column, Unit: "Pageviews", Rows: rows})
+	}
+
+	title := website.Domain
+	if website.Name != nil && *website.Name != "" {
+		title = *website.Name
+	}
+	refresh := fiber.Query[int](c, "refresh", 0)
+	if refresh != 0 {
+		refresh = min(max(refresh, 10), 3600)
+	}
+
+	var page bytes.Buffer
+	if err := plainTemplate.Execute(&page, fiber.Map{
+		"Title":   title,
+		"Updated": time.Now().UTC().Format("15:04 UTC"),
+		"Refresh": refresh,
+		"Stats":   stats,
+		"Lists":   lists,
+	}); err != nil {
+		return c.Status(500).SendString("Failed to render page")
+	}
+	c.Set("Content-Type", "text/html; charset=utf-8")
+	c.Set("Cache-Control", "no-store")
+	return c.Send(page.Bytes())
+}
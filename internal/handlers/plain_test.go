@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlePlainDashboard(t *testing.T) {
+	websiteID := uuid.New()
+	breakdown := func(rows ...[]interface{}) mockResponse {
+		return mockResponse{
+			match:   "SELECT * FROM get_breakdown(",
+			columns: []string{"name", "count", "total_count"},
+			rows:    rows,
+		}
+	}
+	responses := []mockResponse{
+		{
+			match:   "SELECT website_id, domain, name FROM website WHERE deleted_at IS NULL AND website_id = $1",
+			columns: []string{"website_id", "domain", "name"},
+			rows:    [][]interface{}{{websiteID.String(), "example.com", "Example <Blog>"}},
+		},
+		{
+			match:   "SELECT * FROM get_dashboard_stats(",
+			columns: []string{"current_visitors", "today_pageviews", "today_visitors", "bounce_rate"},
+			rows:    [][]interface{}{{int64(3), int64(250), int64(90), 42.5}},
+		},
+		{
+			match:   "SELECT * FROM get_top_pages(",
+			args:    []interface{}{websiteID, 1, 10, 0, nil, nil, nil, nil, nil, nil, nil},
+			columns: []string{"path", "views", "unique_visitors", "avg_engagement_time", "bounce_rate", "total_count"},
+			rows:    [][]interface{}{{"/pricing", int64(120), int64(80), 30.0, 40.0, int64(1)}},
+		},
+		breakdown([]interface{}{"news.ycombinator.com", int64(40), int64(1)}),
+		breakdown([]interface{}{"DE", int64(70), int64(1)}),
+		breakdown(),
+		breakdown([]interface{}{"mobile", int64(150), int64(1)}),
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/plain/:website_id", HandlePlainDashboard, responses)
+	defer cleanup()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/plain/"+websiteID.String()+"?refresh=5", nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	page := string(body)
+	assert.Contains(t, page, "<h1>Example &lt;Blog&gt;</h1>")
+	assert.Contains(t, page, `<meta http-equiv="refresh" content="10">`)
+	assert.Contains(t, page, "<dt>Pageviews today</dt><dd>250</dd>")
+	assert.Contains(t, page, "<dt>Bounce rate</dt><dd>42.5%</dd>")
+	assert.Contains(t, page, `<tr><td>/pricing</td><td class="n">120</td></tr>`)
+	assert.Contains(t, page, `<tr><td>Germany</td><td class="n">70</td></tr>`)
+	assert.Contains(t, page, "<caption>Top browsers</caption>")
+	assert.Contains(t, page, `<tr><td colspan="2">No data yet</td></tr>`)
+	assert.NotContains(t, page, "<script")
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandlePlainDashboardUnknownWebsite(t *testing.T) {
+	responses := []mockResponse{{match: "FROM website WHERE deleted_at IS NULL"}}
+	app, _, cleanup := setupFiberTest(t, "/plain/:website_id", HandlePlainDashboard, responses)
+	defer cleanup()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/plain/"+uuid.NewString(), nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,446 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/stats"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// The /api/v1/stats/* endpoints answer in the shapes of Plausible's Stats
+// API (v1), so dashboards, Grafana plugins and scripts written for it work
+// against Kaunta. site_id is the website's domain. Metrics are visitors,
+// pageviews, bounce_rate and visit_duration, the last being Kaunta's average
+// engagement in seconds.
+
+// plausibleMetrics are the metrics the façade serves, in Plausible's names
+var plausibleMetrics = []string{"visitors", "pageviews", "bounce_rate", "visit_duration"}
+
+// plausibleProperties maps Plausible's breakdown properties to dimensions;
+// event:props:<name> breaks down by a custom dimension
+var plausibleProperties = map[string]string{
+	"event:page":    "page",
+	"visit:source":  "referrer",
+	"visit:channel": "channel",
+	"visit:country": "country",
+	"visit:region":  "region",
+	"visit:city":    "city",
+	"visit:browser": "browser",
+	"visit:os":      "os",
+	"visit:device":  "device",
+}
+
+// plausibleIntervals maps Plausible's intervals to time series intervals
+var plausibleIntervals = map[string]store.Interval{
+	"minute": store.IntervalMinute,
+	"hour":   store.IntervalHour,
+	"date":   store.IntervalDay,
+	"week":   store.IntervalWeek,
+	"month":  store.IntervalMonth,
+}
+
+// plausibleSite is the website of ?site_id=
+type plausibleSite struct {
+	id       uuid.UUID
+	timezone string
+}
+
+// plausibleError answers in Plausible's error shape
+func plausibleError(c fiber.Ctx, status int, message string) error {
+	return c.Status(status).JSON(fiber.Map{"error": message})
+}
+
+// lookupPlausibleSite finds the website of ?site_id=, answering the request
+// itself when it can't
+func lookupPlausibleSite(c fiber.Ctx) (*plausibleSite, error) {
+	if database.DB == nil || store.Current().Name() != "postgres" {
+		return nil, plausibleError(c, 501, "The Stats API requires PostgreSQL")
+	}
+	domain := c.Query("site_id")
+	if domain == "" {
+		return nil, plausibleError(c, 400, "Missing site ID. Please provide the required site_id parameter with your request.")
+	}
+	site := &plausibleSite{}
+	err := database.DB.QueryRowContext(c.Context(),
+		`SELECT website_id, website_timezone(website_id) FROM website WHERE LOWER(domain) = LOWER($1) AND deleted_at IS NULL`,
+		domain).Scan(&site.id, &site.timezone)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, plausibleError(c, 404, "Site does not exist or user does not have sufficient access.")
+	}
+	if err != nil {
+		return nil, plausibleError(c, 500, "Failed to look up site")
+	}
+	return site, nil
+}
+
+// plausiblePeriod reads ?period= (day, Nd, month, 6mo, 12mo or custom,
+// default 30d) and ?date= into a period of days and, for anything but Nd
+// ending today, the range of days it covers in the website's time zone
+func plausiblePeriod(period, date, tz string, now time.Time) (int, *stats.Period, error) {
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		loc = time.UTC
+	}
+	day := now.In(loc)
+	if date != "" && period != "custom" {
+		if day, err = time.ParseInLocation(time.DateOnly, date, loc); err != nil {
+			return 0, nil, fmt.Errorf("invalid date: %s (use YYYY-MM-DD)", date)
+		}
+	}
+	monthStart := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, loc)
+
+	var from, to time.Time
+	switch {
+	case period == "" || strings.HasSuffix(period, "d") && period != "d":
+		n := 30
+		if period != "" {
+			if n, err = strconv.Atoi(strings.TrimSuffix(period, "d")); err != nil || n < 1 || n > stats.MaxRangeDays {
+				return 0, nil, fmt.Errorf("invalid period: %s", period)
+			}
+		}
+		if date == "" {
+			return n, nil, nil
+		}
+		from, to = day.AddDate(0, 0, 1-n), day
+	case period == "day":
+		from, to = day, day
+	case period == "month":
+		from, to = monthStart, monthStart.AddDate(0, 1, -1)
+	case period == "6mo" || period == "12mo":
+		months, _ := strconv.Atoi(strings.TrimSuffix(period, "mo"))
+		from, to = monthStart.AddDate(0, 1-months, 0), monthStart.AddDate(0, 1, -1)
+	case period == "custom":
+		start, end, ok := strings.Cut(date, ",")
+		if !ok {
+			return 0, nil, fmt.Errorf("the custom period needs date=YYYY-MM-DD,YYYY-MM-DD")
+		}
+		p, err := stats.ParseRange(start, end, loc.String(), now)
+		if err != nil {
+			return 0, nil, err
+		}
+		return p.Days(now), &p, nil
+	default:
+		return 0, nil, fmt.Errorf("invalid period: %s (use day, 7d, 30d, month, 6mo, 12mo or custom)", period)
+	}
+
+	// Periods may end after today; they stop there
+	if today := now.In(loc); to.After(today) {
+		to = today
+	}
+	p, err := stats.ParseRange(from.Format(time.DateOnly), to.Format(time.DateOnly), loc.String(), now)
+	if err != nil {
+		return 0, nil, err
+	}
+	return p.Days(now), &p, nil
+}
+
+// plausibleFilters reads ?filters=, Plausible's "visit:country==DE;event:page==/blog"
+func plausibleFilters(value string) (store.Filters, error) {
+	var f store.Filters
+	for _, filter := range strings.Split(value, ";") {
+		if strings.TrimSpace(filter) == "" {
+			continue
+		}
+		property, expr, ok := strings.Cut(filter, "==")
+		if !ok || strings.Contains(expr, "|") {
+			return f, fmt.Errorf("unsupported filter: %s (only property==value)", filter)
+		}
+		property, expr = strings.TrimSpace(property), strings.TrimSpace(expr)
+		switch property {
+		case "visit:country":
+			f.Country = expr
+		case "visit:browser":
+			f.Browser = expr
+		case "visit:device":
+			f.Device = expr
+		case "event:page":
+			f.Page = expr
+		default:
+			name, ok := strings.CutPrefix(property, "event:props:")
+			if !ok || name == "" {
+				return f, fmt.Errorf("unsupported filter property: %s", property)
+			}
+			if f.Dimensions == nil {
+				f.Dimensions = make(map[string]string)
+			}
+			f.Dimensions[name] = expr
+		}
+	}
+	return f, nil
+}
+
+// plausibleMetricList reads ?metrics= (default visitors), keeping only those
+// the endpoint supports
+func plausibleMetricList(value string, supported []string) ([]string, error) {
+	if value == "" {
+		return []string{"visitors"}, nil
+	}
+	var metrics []string
+	for _, metric := range strings.Split(value, ",") {
+		metric = strings.TrimSpace(metric)
+		ok := false
+		for _, s := range supported {
+			ok = ok || s == metric
+		}
+		if !ok {
+			return nil, fmt.Errorf("the metric `%s` is not supported (use %s)", metric, strings.Join(supported, ", "))
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics, nil
+}
+
+// plausibleValue is a metric of a summary as Plausible reports it: counts,
+// whole percentages and whole seconds
+func plausibleValue(s stats.Summary, metric string) int64 {
+	switch metric {
+	case "pageviews":
+		return s.Pageviews
+	case "bounce_rate":
+		return int64(math.Round(s.BounceRate))
+	case "visit_duration":
+		return int64(math.Round(s.AvgEngagement))
+	default:
+		return s.Visitors
+	}
+}
+
+// plausibleChange is the change of a metric: percent for counts and
+// durations, percentage points for the bounce rate; nil without a previous
+// value
+func plausibleChange(c *stats.Comparison, metric string) interface{} {
+	change := c.Visitors
+	switch metric {
+	case "pageviews":
+		change = c.Pageviews
+	case "bounce_rate":
+		return int64(math.Round(c.BounceRate.Delta))
+	case "visit_duration":
+		change = c.AvgEngagement
+	}
+	if change.Percent == nil {
+		return nil
+	}
+	return int64(math.Round(*change.Percent))
+}
+
+// plausibleQuery is what the aggregate, timeseries and breakdown endpoints
+// share: the website, period and filters
+type plausibleQuery struct {
+	site    *plausibleSite
+	days    int
+	period  *stats.Period
+	filters store.Filters
+}
+
+// parsePlausibleQuery reads site_id, period, date and filters, answering the
+// request itself when they are invalid
+func parsePlausibleQuery(c fiber.Ctx) (*plausibleQuery, error) {
+	site, err := lookupPlausibleSite(c)
+	if site == nil {
+		return nil, err
+	}
+	q := &plausibleQuery{site: site}
+	if q.days, q.period, err = plausiblePeriod(c.Query("period"), c.Query("date"), site.timezone, time.Now()); err != nil {
+		return nil, plausibleError(c, 400, err.Error())
+	}
+	if q.filters, err = plausibleFilters(c.Query("filters")); err != nil {
+		return nil, plausibleError(c, 400, err.Error())
+	}
+	if q.period != nil {
+		q.filters = q.period.Narrow(q.filters)
+	}
+	return q, nil
+}
+
+// HandlePlausibleRealtime returns the visitors of the last 5 minutes as a
+// bare number
+// GET /api/v1/stats/realtime/visitors?site_id=
+func HandlePlausibleRealtime(c fiber.Ctx) error {
+	site, err := lookupPlausibleSite(c)
+	if site == nil {
+		return err
+	}
+	visitors, err := store.Current().CurrentVisitors(c.Context(), site.id)
+	if err != nil {
+		return plausibleError(c, 500, "Failed to query current visitors")
+	}
+	return c.JSON(visitors)
+}
+
+// HandlePlausibleAggregate returns the metrics of the period as
+// {"results": {"visitors": {"value": N}}}; with compare=previous_period each
+// also has the change from the period of the same length before
+// GET /api/v1/stats/aggregate?site_id=&period=&date=&metrics=&filters=&compare=
+func HandlePlausibleAggregate(c fiber.Ctx) error {
+	q, err := parsePlausibleQuery(c)
+	if q == nil {
+		return err
+	}
+	metrics, err := plausibleMetricList(c.Query("metrics"), plausibleMetrics)
+	if err != nil {
+		return plausibleError(c, 400, err.Error())
+	}
+	compare := c.Query("compare")
+	if compare != "" && compare != "previous_period" {
+		return plausibleError(c, 400, "invalid compare: "+compare+" (use previous_period)")
+	}
+
+	var current stats.Summary
+	var comparison *stats.Comparison
+	switch {
+	case compare == "":
+		summary, err := stats.GetDaysSummary(c.Context(), database.DB, q.site.id, q.days, q.filters)
+		if err != nil {
+			return plausibleError(c, 500, "Failed to query stats")
+		}
+		current = *summary
+	case q.period == nil:
+		if comparison, err = stats.GetComparison(c.Context(), database.DB, q.site.id, q.days, q.filters); err != nil {
+			return plausibleError(c, 500, "Failed to query stats")
+		}
+		current = comparison.Current
+	default:
+		// The same number of days just before the range
+		now := time.Now()
+		length := int(math.Round(q.period.To.Sub(q.period.From).Hours() / 24))
+		before := stats.Period{From: q.period.From.AddDate(0, 0, -length), To: q.period.From}
+		summary, err := stats.GetDaysSummary(c.Context(), database.DB, q.site.id, q.days, q.filters)
+		if err != nil {
+			return plausibleError(c, 500, "Failed to query stats")
+		}
+		previous, err := stats.GetDaysSummary(c.Context(), database.DB, q.site.id, before.Days(now), before.Narrow(q.filters))
+		if err != nil {
+			return plausibleError(c, 500, "Failed to query stats")
+		}
+		comparison = stats.NewComparison(*summary, *previous)
+		current = *summary
+	}
+
+	results := fiber.Map{}
+	for _, metric := range metrics {
+		result := fiber.Map{"value": plausibleValue(current, metric)}
+		if comparison != nil {
+			result["change"] = plausibleChange(comparison, metric)
+		}
+		results[metric] = result
+	}
+	return c.JSON(fiber.Map{"results": results})
+}
+
+// HandlePlausibleTimeseries returns the metrics of the period bucketed by
+// ?interval= (minute, hour, date, week or month; hour for a day, month for
+// 6mo and 12mo, date otherwise) as {"results": [{"date": ..., "visitors": N}]}
+// GET /api/v1/stats/timeseries?site_id=&period=&date=&interval=&metrics=&filters=
+func HandlePlausibleTimeseries(c fiber.Ctx) error {
+	q, err := parsePlausibleQuery(c)
+	if q == nil {
+		return err
+	}
+	metrics, err := plausibleMetricList(c.Query("metrics"), plausibleMetrics)
+	if err != nil {
+		return plausibleError(c, 400, err.Error())
+	}
+
+	name := c.Query("interval")
+	if name == "" {
+		switch c.Query("period") {
+		case "day":
+			name = "hour"
+		case "6mo", "12mo":
+			name = "month"
+		default:
+			name = "date"
+		}
+	}
+	interval, ok := plausibleIntervals[name]
+	if !ok {
+		return plausibleError(c, 400, "invalid interval: "+name+" (use minute, hour, date, week or month)")
+	}
+	length := q.days
+	if q.period != nil {
+		length = int(math.Round(q.period.To.Sub(q.period.From).Hours() / 24))
+	}
+	if length > interval.MaxDays() {
+		return plausibleError(c, 400, fmt.Sprintf("interval %s covers at most %d days", name, interval.MaxDays()))
+	}
+
+	points, err := stats.GetSeries(c.Context(), database.DB, q.site.id, q.days, interval, q.filters)
+	if err != nil {
+		return plausibleError(c, 500, "Failed to query time series")
+	}
+	layout := time.DateOnly
+	if interval.Rolling() {
+		layout = time.DateTime
+	}
+	results := make([]fiber.Map, 0, len(points))
+	for _, p := range points {
+		result := fiber.Map{"date": p.Bucket.Format(layout)}
+		for _, metric := range metrics {
+			result[metric] = plausibleValue(p.Summary, metric)
+		}
+		results = append(results, result)
+	}
+	return c.JSON(fiber.Map{"results": results})
+}
+
+// HandlePlausibleBreakdown returns the metrics of the period by ?property=
+// (visit:source, visit:country, event:page, event:props:<dimension>, ...)
+// as {"results": [{"source": "google.com", "visitors": N}]}, ?limit= rows
+// (default 100, at most 1000) from ?page= (default 1)
+// GET /api/v1/stats/breakdown?site_id=&period=&date=&property=&metrics=&filters=&limit=&page=
+func HandlePlausibleBreakdown(c fiber.Ctx) error {
+	q, err := parsePlausibleQuery(c)
+	if q == nil {
+		return err
+	}
+	metrics, err := plausibleMetricList(c.Query("metrics"), plausibleMetrics[:3])
+	if err != nil {
+		return plausibleError(c, 400, err.Error())
+	}
+
+	property := c.Query("property")
+	dimension, ok := plausibleProperties[property]
+	if name, custom := strings.CutPrefix(property, "event:props:"); custom && name != "" {
+		dimension, ok = name, true
+	}
+	if !ok {
+		return plausibleError(c, 400, "unsupported property: "+property)
+	}
+	key := property[strings.LastIndex(property, ":")+1:]
+
+	d := stats.ByPage
+	if dimension != "page" {
+		if d, err = stats.Resolve(c.Context(), database.DB, q.site.id, dimension); err != nil {
+			return plausibleError(c, 400, "unsupported property: "+property)
+		}
+	}
+
+	limit := min(max(fiber.Query[int](c, "limit", 100), 1), 1000)
+	page := max(fiber.Query[int](c, "page", 1), 1)
+	breakdown, err := stats.GetBreakdown(c.Context(), database.DB, q.site.id, d, q.days, limit*page, q.filters)
+	if err != nil {
+		return plausibleError(c, 500, "Failed to query breakdown")
+	}
+
+	results := []fiber.Map{}
+	for i := (page - 1) * limit; i < len(breakdown.Items); i++ {
+		item := breakdown.Items[i]
+		result := fiber.Map{key: item.Name}
+		for _, metric := range metrics {
+			result[metric] = plausibleValue(stats.Summary{Visitors: item.Visitors, Pageviews: item.Pageviews, BounceRate: item.BounceRate}, metric)
+		}
+		results = append(results, result)
+	}
+	return c.JSON(fiber.Map{"results": results})
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func plausibleSiteResponse(websiteID uuid.UUID) mockResponse {
+	return mockResponse{
+		match:   "FROM website WHERE LOWER(domain) = LOWER($1)",
+		args:    []interface{}{"example.com"},
+		columns: []string{"website_id", "website_timezone"},
+		rows:    [][]interface{}{{websiteID.String(), "UTC"}},
+	}
+}
+
+func TestHandlePlausibleAggregate(t *testing.T) {
+	websiteID := uuid.New()
+	columns := []string{"visitors", "pageviews", "bounce_rate", "avg_engagement"}
+	responses := []mockResponse{
+		plausibleSiteResponse(websiteID),
+		{
+			match:   "COUNT(DISTINCT session_id), COUNT(*)",
+			args:    []interface{}{websiteID, 7, "DE"},
+			columns: columns,
+			rows:    [][]interface{}{{int64(150), int64(400), 42.4, 61.6}},
+		},
+		{
+			match:   "COUNT(DISTINCT session_id), COUNT(*)",
+			args:    []interface{}{websiteID, 7, "DE"},
+			columns: columns,
+			rows:    [][]interface{}{{int64(100), int64(0), 40.0, 60.0}},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/v1/stats/aggregate", HandlePlausibleAggregate, responses)
+	defer cleanup()
+
+	url := "/api/v1/stats/aggregate?site_id=example.com&period=7d&compare=previous_period" +
+		"&metrics=visitors,pageviews,bounce_rate,visit_duration&filters=visit:country%3D%3DDE"
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, url, nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var body struct {
+		Results map[string]struct {
+			Value  int64  `json:"value"`
+			Change *int64 `json:"change"`
+		} `json:"results"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, int64(150), body.Results["visitors"].Value)
+	assert.Equal(t, int64(50), *body.Results["visitors"].Change)
+	assert.Nil(t, body.Results["pageviews"].Change, "no change from zero")
+	assert.Equal(t, int64(42), body.Results["bounce_rate"].Value)
+	assert.Equal(t, int64(2), *body.Results["bounce_rate"].Change, "percentage points")
+	assert.Equal(t, int64(62), body.Results["visit_duration"].Value)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandlePlausibleBreakdownPages(t *testing.T) {
+	websiteID := uuid.New()
+	responses := []mockResponse{
+		plausibleSiteResponse(websiteID),
+		{
+			match:   "GROUP BY name",
+			args:    []interface{}{websiteID, 30, 4, "Direct / None"},
+			columns: []string{"name", "visitors", "pageviews", "bounce_rate"},
+			rows: [][]interface{}{
+				{"Google", int64(90), int64(120), 30.0},
+				{"Direct / None", int64(80), int64(100), 50.0},
+				{"example.org", int64(10), int64(12), 20.0},
+			},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/v1/stats/breakdown", HandlePlausibleBreakdown, responses)
+	defer cleanup()
+
+	url := "/api/v1/stats/breakdown?site_id=example.com&property=visit:source&limit=2&page=2"
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, url, nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var body struct {
+		Results []map[string]interface{} `json:"results"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, []map[string]interface{}{{"source": "example.org", "visitors": float64(10)}}, body.Results)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandlePlausibleUnknownSite(t *testing.T) {
+	responses := []mockResponse{
+		{
+			match:   "FROM website WHERE LOWER(domain) = LOWER($1)",
+			args:    []interface{}{"example.com"},
+			columns: []string{"website_id", "website_timezone"},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/v1/stats/aggregate", HandlePlausibleAggregate, responses)
+	defer cleanup()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/v1/stats/aggregate?site_id=example.com", nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandlePlausibleUnsupportedFilter(t *testing.T) {
+	websiteID := uuid.New()
+	app, queue, cleanup := setupFiberTest(t, "/api/v1/stats/aggregate", HandlePlausibleAggregate,
+		[]mockResponse{plausibleSiteResponse(websiteID)})
+	defer cleanup()
+
+	url := "/api/v1/stats/aggregate?site_id=example.com&filters=visit:country!%3DDE"
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, url, nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestPlausiblePeriod(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		period, date string
+		days         int
+		from, to     string
+	}{
+		{"", "", 30, "", ""},
+		{"7d", "", 7, "", ""},
+		{"7d", "2025-06-10", 13, "2025-06-04", "2025-06-11"},
+		{"day", "", 2, "2025-06-15", "2025-06-16"},
+		{"month", "2025-05-20", 47, "2025-05-01", "2025-06-01"},
+		{"month", "", 16, "2025-06-01", "2025-06-16"},
+		{"6mo", "", 167, "2025-01-01", "2025-06-16"},
+		{"custom", "2025-06-01,2025-06-03", 16, "2025-06-01", "2025-06-04"},
+	}
+	for _, tt := range tests {
+		days, p, err := plausiblePeriod(tt.period, tt.date, "UTC", now)
+		require.NoError(t, err, tt.period)
+		assert.Equal(t, tt.days, days, tt.period)
+		if tt.from == "" {
+			assert.Nil(t, p, tt.period)
+			continue
+		}
+		require.NotNil(t, p, tt.period)
+		assert.Equal(t, tt.from, p.From.Format(time.DateOnly), tt.period)
+		assert.Equal(t, tt.to, p.To.Format(time.DateOnly), tt.period)
+	}
+
+	for _, period := range []string{"week", "0d", "custom"} {
+		_, _, err := plausiblePeriod(period, "", "UTC", now)
+		assert.Error(t, err, period)
+	}
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/reports"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// reportContentTypes are the content types of the report formats
+var reportContentTypes = map[string]string{
+	"json":  fiber.MIMEApplicationJSONCharsetUTF8,
+	"csv":   "text/csv; charset=utf-8",
+	"table": fiber.MIMETextPlainCharsetUTF8,
+}
+
+// HandleListReports lists the saved reports
+// GET /api/reports
+func HandleListReports(c fiber.Ctx) error {
+	if database.DB == nil {
+		return c.Status(501).JSON(fiber.Map{"error": "Saved reports require PostgreSQL"})
+	}
+	list, err := reports.List(c.Context(), database.DB)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to list reports"})
+	}
+	if list == nil {
+		list = []reports.Report{}
+	}
+	return c.JSON(list)
+}
+
+// HandleRunReport runs a saved report, in its format or ?format=
+// (table, json or csv)
+// GET /api/reports/:name
+func HandleRunReport(c fiber.Ctx) error {
+	if database.DB == nil {
+		return c.Status(501).JSON(fiber.Map{"error": "Saved reports require PostgreSQL"})
+	}
+	report, err := reports.Get(c.Context(), database.DB, c.Params("name"))
+	if errors.Is(err, reports.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Report not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load report"})
+	}
+
+	format := c.Query("format", report.Spec.Format)
+	if !slices.Contains(reports.Formats, format) {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid format: " + format})
+	}
+
+	result, err := reports.Run(c.Context(), store.Current(), report)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to run report"})
+	}
+
+	var body bytes.Buffer
+	if err := reports.Write(&body, result, format); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to write report"})
+	}
+	if format == "csv" {
+		c.Attachment(report.Name + ".csv")
+	}
+	c.Set(fiber.HeaderContentType, reportContentTypes[format])
+	return c.Send(body.Bytes())
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleRunReport(t *testing.T) {
+	websiteID := uuid.New()
+	now := time.Now()
+	responses := []mockResponse{
+		{
+			match:   "FROM saved_report r",
+			columns: []string{"name", "website_id", "domain", "spec", "created_at", "updated_at"},
+			rows: [][]interface{}{{"weekly-seo", websiteID.String(), "example.com",
+				[]byte(`{"dimensions":["referrer"],"days":1,"limit":5,"format":"table"}`), now, now}},
+			args: []interface{}{"weekly-seo"},
+		},
+		{
+			match:   "SELECT * FROM get_breakdown(",
+			columns: []string{"name", "count", "total_count"},
+			rows:    [][]interface{}{{"google.com", int64(40), int64(2)}, {"Direct / None", int64(12), int64(2)}},
+			args:    []interface{}{websiteID, "referrer", 1, 5, 0, nil, nil, nil, nil, nil, nil, nil, nil},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/reports/:name", HandleRunReport, responses)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/reports/weekly-seo?format=csv", nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/csv; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Contains(t, resp.Header.Get("Content-Disposition"), "weekly-seo.csv")
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "section,name,value\nreferrer,google.com,40\nreferrer,Direct / None,12\n", string(body))
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleRunReportNotFound(t *testing.T) {
+	responses := []mockResponse{
+		{
+			match:   "FROM saved_report r",
+			columns: []string{"name", "website_id", "domain", "spec", "created_at", "updated_at"},
+		},
+	}
+
+	app, _, cleanup := setupFiberTest(t, "/api/reports/:name", HandleRunReport, responses)
+	defer cleanup()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/reports/missing", nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
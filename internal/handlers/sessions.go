@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/features"
+	"github.com/seuros/kaunta/internal/stats"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// HandleSessions lists a website's sessions of the last ?days= (default 7,
+// at most 90), the most recently active first, up to ?limit= sessions
+// (default 50, at most 200)
+// GET /api/dashboard/sessions/:website_id
+func HandleSessions(c fiber.Ctx) error {
+	websiteID, err := uuid.Parse(c.Params("website_id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid website ID"})
+	}
+	if database.DB == nil || store.Current().Name() != "postgres" {
+		return c.Status(501).JSON(fiber.Map{"error": "Sessions require PostgreSQL"})
+	}
+	if !features.Enabled(c.Context(), "sessions", websiteID) {
+		return c.Status(404).JSON(fiber.Map{"error": "Sessions are disabled"})
+	}
+
+	days := min(max(fiber.Query[int](c, "days", 7), 1), 90)
+	limit := min(max(fiber.Query[int](c, "limit", 50), 1), 200)
+
+	sessions, err := stats.GetSessions(c.Context(), database.DB, websiteID, days, limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query sessions"})
+	}
+	return c.JSON(sessions)
+}
+
+// HandleSessionJourney returns a session with its pageviews and custom
+// events in order
+// GET /api/dashboard/sessions/:website_id/:session_id
+func HandleSessionJourney(c fiber.Ctx) error {
+	websiteID, err := uuid.Parse(c.Params("website_id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid website ID"})
+	}
+	sessionID, err := uuid.Parse(c.Params("session_id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid session ID"})
+	}
+	if database.DB == nil || store.Current().Name() != "postgres" {
+		return c.Status(501).JSON(fiber.Map{"error": "Sessions require PostgreSQL"})
+	}
+	if !features.Enabled(c.Context(), "sessions", websiteID) {
+		return c.Status(404).JSON(fiber.Map{"error": "Sessions are disabled"})
+	}
+
+	journey, err := stats.GetJourney(c.Context(), database.DB, websiteID, sessionID)
+	if errors.Is(err, stats.ErrSessionNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Session not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query session"})
+	}
+	return c.JSON(journey)
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/stats"
+)
+
+func TestHandleSessions(t *testing.T) {
+	websiteID, sessionID := uuid.New(), uuid.New()
+	started := time.Date(2025, 6, 15, 9, 0, 0, 0, time.UTC)
+	responses := []mockResponse{
+		{
+			match: "JOIN session s ON s.session_id = e.session_id",
+			args:  []interface{}{websiteID, 90, 200},
+			columns: []string{"session_id", "country", "device", "browser", "entry_page",
+				"pageviews", "events", "started_at", "duration"},
+			rows: [][]interface{}{{sessionID.String(), "DE", "mobile", "chrome", "/blog", int64(3), int64(0), started, 42.5}},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/dashboard/sessions/:website_id", HandleSessions, responses)
+	defer cleanup()
+
+	url := "/api/dashboard/sessions/" + websiteID.String() + "?days=400&limit=1000"
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, url, nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var sessions []stats.SessionSummary
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&sessions))
+	require.Len(t, sessions, 1)
+	assert.Equal(t, sessionID, sessions[0].ID)
+	assert.Equal(t, "/blog", sessions[0].EntryPage)
+	assert.Equal(t, 42.5, sessions[0].Duration)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleSessionJourneyNotFound(t *testing.T) {
+	websiteID, sessionID := uuid.New(), uuid.New()
+	responses := []mockResponse{
+		{
+			match:   "FROM session",
+			args:    []interface{}{sessionID, websiteID},
+			columns: []string{"country", "device", "browser"},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/dashboard/sessions/:website_id/:session_id", HandleSessionJourney, responses)
+	defer cleanup()
+
+	url := "/api/dashboard/sessions/" + websiteID.String() + "/" + sessionID.String()
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, url, nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	require.NoError(t, queue.expectationsMet())
+}
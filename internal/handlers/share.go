@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"embed"
+	"errors"
+	"html/template"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/share"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+//go:embed templates/share_password.html
+var sharePasswordFS embed.FS
+
+var sharePasswordTemplate = template.Must(template.ParseFS(sharePasswordFS, "templates/share_password.html"))
+
+// shareCookieMaxAge is how long an entered share password is remembered
+const shareCookieMaxAge = 7 * 24 * time.Hour
+
+// errShareExpired tells an expired share from an unknown one
+var errShareExpired = errors.New("share expired")
+
+// findShare looks up the share of :share_id, failing with sql.ErrNoRows
+// for unknown share IDs and errShareExpired for expired ones
+func findShare(c fiber.Ctx) (*store.Share, error) {
+	s, err := store.Current().FindShare(c.Context(), c.Params("share_id"))
+	if err != nil {
+		return nil, err
+	}
+	if s.ExpiresAt != nil && !time.Now().Before(*s.ExpiresAt) {
+		return nil, errShareExpired
+	}
+	return s, nil
+}
+
+// shareUnlocked reports whether the request may see a share: it has no
+// password, or the browser entered it
+func shareUnlocked(c fiber.Ctx, s *store.Share) bool {
+	return s.PasswordHash == "" || share.ValidToken(c.Params("share_id"), s.PasswordHash, c.Cookies(share.CookieName))
+}
+
+// HandleSharedDashboard renders the plain dashboard of a shared website,
+// read-only and without a login. Password-protected shares first ask for
+// the password.
+// GET /share/:share_id
+func HandleSharedDashboard(c fiber.Ctx) error {
+	c.Set("X-Robots-Tag", "noindex, nofollow")
+	s, err := findShare(c)
+	if err != nil {
+		return sharePageError(c, err)
+	}
+	if !shareUnlocked(c, s) {
+		return renderSharePassword(c, 200, "")
+	}
+	websiteID, err := uuid.Parse(s.Website.WebsiteID)
+	if err != nil {
+		return c.Status(500).SendString("Failed to load website")
+	}
+	return renderPlainDashboard(c, websiteID, &s.Website)
+}
+
+// HandleShareUnlock checks the password of a shared website and remembers
+// it in a cookie for the share's pages
+// POST /share/:share_id
+func HandleShareUnlock(c fiber.Ctx) error {
+	c.Set("X-Robots-Tag", "noindex, nofollow")
+	s, err := findShare(c)
+	if err != nil {
+		return sharePageError(c, err)
+	}
+	shareID := c.Params("share_id")
+	if s.PasswordHash == "" {
+		return c.Redirect().Status(303).To("/share/" + shareID)
+	}
+	if !share.CheckPassword(s.PasswordHash, c.FormValue("password")) {
+		return renderSharePassword(c, 401, "Wrong password")
+	}
+
+	expires := time.Now().Add(shareCookieMaxAge)
+	if s.ExpiresAt != nil && s.ExpiresAt.Before(expires) {
+		expires = *s.ExpiresAt
+	}
+	c.Cookie(&fiber.Cookie{
+		Name:     share.CookieName,
+		Value:    share.Token(shareID, s.PasswordHash),
+		Expires:  expires,
+		HTTPOnly: true,
+		Secure:   secureCookiesEnabled(),
+		SameSite: "Lax",
+		Path:     "/share/" + shareID,
+	})
+	return c.Redirect().Status(303).To("/share/" + shareID)
+}
+
+func sharePageError(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return c.Status(404).SendString("Share link not found")
+	case errors.Is(err, errShareExpired):
+		return c.Status(410).SendString("This share link has expired")
+	}
+	return c.Status(500).SendString("Failed to load share link")
+}
+
+func renderSharePassword(c fiber.Ctx, status int, message string) error {
+	var page bytes.Buffer
+	if err := sharePasswordTemplate.Execute(&page, fiber.Map{
+		"ShareID": c.Params("share_id"),
+		"Error":   message,
+	}); err != nil {
+		return c.Status(500).SendString("Failed to render page")
+	}
+	c.Set("Content-Type", "text/html; charset=utf-8")
+	c.Set("Cache-Control", "no-store")
+	return c.Status(status).Send(page.Bytes())
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/share"
+)
+
+var shareColumns = []string{"website_id", "domain", "name", "share_password_hash", "share_expires_at"}
+
+// shareLookup answers the share lookup with a website; hash and expiresAt
+// may be nil
+func shareLookup(websiteID uuid.UUID, hash, expiresAt interface{}) mockResponse {
+	return mockResponse{
+		match:   "AND share_id = $1",
+		columns: shareColumns,
+		rows:    [][]interface{}{{websiteID.String(), "example.com", "Example", hash, expiresAt}},
+	}
+}
+
+// plainDashboardResponses answers the queries of an empty plain dashboard
+func plainDashboardResponses() []mockResponse {
+	responses := []mockResponse{
+		{
+			match:   "SELECT * FROM get_dashboard_stats(",
+			columns: []string{"current_visitors", "today_pageviews", "today_visitors", "bounce_rate"},
+			rows:    [][]interface{}{{int64(1), int64(12), int64(5), 40.0}},
+		},
+		{
+			match:   "SELECT * FROM get_top_pages(",
+			columns: []string{"path", "views", "unique_visitors", "avg_engagement_time", "bounce_rate", "total_count"},
+		},
+	}
+	for range plainBreakdowns {
+		responses = append(responses, mockResponse{
+			match:   "SELECT * FROM get_breakdown(",
+			columns: []string{"name", "count", "total_count"},
+		})
+	}
+	return responses
+}
+
+func TestHandleSharedDashboard(t *testing.T) {
+	responses := append([]mockResponse{shareLookup(uuid.New(), nil, nil)}, plainDashboardResponses()...)
+	app, queue, cleanup := setupFiberTest(t, "/share/:share_id", HandleSharedDashboard, responses)
+	defer cleanup()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/share/abc123", nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "noindex, nofollow", resp.Header.Get("X-Robots-Tag"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "<h1>Example</h1>")
+	assert.Contains(t, string(body), "<dt>Pageviews today</dt><dd>12</dd>")
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleSharedDashboardPassword(t *testing.T) {
+	hash, err := share.HashPassword("correct horse")
+	require.NoError(t, err)
+	websiteID := uuid.New()
+	responses := []mockResponse{
+		shareLookup(websiteID, hash, nil), // GET without the cookie
+		shareLookup(websiteID, hash, nil), // POST with a wrong password
+		shareLookup(websiteID, hash, nil), // POST with the password
+		shareLookup(websiteID, hash, nil), // GET with the cookie
+	}
+	responses = append(responses, plainDashboardResponses()...)
+	app, queue, cleanup := setupFiberTest(t, "/share/:share_id", HandleSharedDashboard, responses)
+	defer cleanup()
+	app.Post("/share/:share_id", HandleShareUnlock)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/share/abc123", nil))
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), `<form method="post" action="/share/abc123">`)
+	assert.NotContains(t, string(body), "Example")
+
+	unlock := func(password string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/share/abc123", strings.NewReader(url.Values{"password": {password}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp
+	}
+	resp = unlock("wrong horse")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Empty(t, resp.Cookies())
+
+	resp = unlock("correct horse")
+	assert.Equal(t, http.StatusSeeOther, resp.StatusCode)
+	assert.Equal(t, "/share/abc123", resp.Header.Get("Location"))
+	require.Len(t, resp.Cookies(), 1)
+	cookie := resp.Cookies()[0]
+	assert.Equal(t, share.CookieName, cookie.Name)
+	assert.Equal(t, "/share/abc123", cookie.Path)
+	assert.True(t, cookie.HttpOnly)
+
+	req := httptest.NewRequest(http.MethodGet, "/share/abc123", nil)
+	req.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "<h1>Example</h1>")
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleSharedDashboardUnavailable(t *testing.T) {
+	app, _, cleanup := setupFiberTest(t, "/share/:share_id", HandleSharedDashboard, []mockResponse{
+		{match: "AND share_id = $1", columns: shareColumns},
+	})
+	defer cleanup()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/share/unknown", nil))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
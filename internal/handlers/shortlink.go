@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/logging"
+	"github.com/seuros/kaunta/internal/shortlink"
+)
+
+// HandleShortLink serves GET /l/:slug: it records the click as a link_click
+// event on the target page, as /api/send would with the visitor's referrer,
+// then redirects to the target. The visitor is redirected even when the
+// click isn't recorded (bots, excluded traffic, errors). A click happens
+// once, so it is never deferred under load.
+func HandleShortLink(c fiber.Ctx) error {
+	if database.DB == nil {
+		return c.Status(fiber.StatusNotFound).SendString("Link not found")
+	}
+	link, err := shortlink.Resolve(c.Context(), database.DB, c.Params("slug"))
+	if errors.Is(err, shortlink.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).SendString("Link not found")
+	}
+	if err != nil {
+		logging.L().Error("failed to resolve short link", zap.String("slug", c.Params("slug")), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to resolve link")
+	}
+
+	name := shortlink.EventName
+	p := PayloadData{
+		Website:  link.WebsiteID.String(),
+		URL:      &link.Target,
+		Referrer: optional(c.Get(fiber.HeaderReferer)),
+		Name:     &name,
+		Props:    map[string]interface{}{"slug": link.Slug},
+	}
+	if u, err := url.Parse(link.Target); err == nil && u.Host != "" {
+		p.Hostname = &u.Host
+	}
+	if err := track(c, TrackingPayload{Type: "event", Payload: p, shortLink: true}); err != nil {
+		return err
+	}
+	if status := c.Response().StatusCode(); status >= 400 {
+		logging.L().Debug("short link click not recorded", zap.String("slug", link.Slug), zap.Int("status", status))
+	}
+
+	c.Response().ResetBody()
+	c.Response().Header.Del(fiber.HeaderAccessControlAllowOrigin)
+	c.Response().Header.Del(fiber.HeaderRetryAfter)
+	c.Set(fiber.HeaderCacheControl, "no-store, max-age=0")
+	return c.Redirect().Status(fiber.StatusFound).To(link.Target)
+}
+
+// HandleShortLinkQR serves GET /l/:slug/qr.png: a QR code of the short link,
+// ?size= pixels wide (default 256), for print and other offline campaigns.
+// Scans are recorded as clicks when the link is followed.
+func HandleShortLinkQR(c fiber.Ctx) error {
+	if database.DB == nil {
+		return c.Status(fiber.StatusNotFound).SendString("Link not found")
+	}
+	size := shortlink.DefaultQRSize
+	if raw := c.Query("size"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < shortlink.MinQRSize || n > shortlink.MaxQRSize {
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid size")
+		}
+		size = n
+	}
+	link, err := shortlink.Resolve(c.Context(), database.DB, c.Params("slug"))
+	if errors.Is(err, shortlink.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).SendString("Link not found")
+	}
+	if err != nil {
+		logging.L().Error("failed to resolve short link", zap.String("slug", c.Params("slug")), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to resolve link")
+	}
+
+	png, err := shortlink.QR(shortlink.URL(c.BaseURL(), link.Slug), size)
+	if err != nil {
+		logging.L().Error("failed to render QR code", zap.String("slug", link.Slug), zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to render QR code")
+	}
+	c.Set(fiber.HeaderContentType, "image/png")
+	c.Set(fiber.HeaderCacheControl, "public, max-age=86400")
+	return c.Send(png)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"database/sql"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/store"
+)
+
+func TestHandleShortLinkRedirects(t *testing.T) {
+	websiteID := uuid.New()
+	app, queue, cleanup := setupFiberTest(t, "/l/:slug", HandleShortLink, []mockResponse{
+		{
+			match:   "FROM short_link l",
+			args:    []interface{}{"spring"},
+			columns: []string{"slug", "website_id", "domain", "target_url", "created_at"},
+			rows:    [][]interface{}{{"spring", websiteID.String(), "example.com", "https://example.com/launch?utm_source=x", time.Now()}},
+		},
+		// The click isn't recorded: the visitor is redirected all the same
+		{match: "FROM website", err: sql.ErrNoRows},
+	})
+	defer cleanup()
+	previous := store.Current()
+	store.SetCurrent(store.NewPostgres())
+	defer store.SetCurrent(previous)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/l/spring", nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, "https://example.com/launch?utm_source=x", resp.Header.Get("Location"))
+	assert.Equal(t, "no-store, max-age=0", resp.Header.Get("Cache-Control"))
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleShortLinkStoredNearCapacity(t *testing.T) {
+	websiteID := uuid.New()
+	app, queue, cleanup := setupFiberTest(t, "/l/:slug", HandleShortLink, []mockResponse{
+		{
+			match:   "FROM short_link l",
+			args:    []interface{}{"spring"},
+			columns: []string{"slug", "website_id", "domain", "target_url", "created_at"},
+			rows:    [][]interface{}{{"spring", websiteID.String(), "example.com", "https://example.com/launch", time.Now()}},
+		},
+	})
+	defer cleanup()
+	useIngestStore(t)
+	buffer := nearlyFullBuffer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/l/spring", nil)
+	req.Header.Set("Accept-Language", "en")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Retry-After"))
+	assert.Equal(t, 10, buffer.Len())
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleShortLinkNotFound(t *testing.T) {
+	app, queue, cleanup := setupFiberTest(t, "/l/:slug", HandleShortLink, []mockResponse{
+		{match: "FROM short_link l", args: []interface{}{"autumn"}, err: sql.ErrNoRows},
+	})
+	defer cleanup()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/l/autumn", nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleShortLinkQR(t *testing.T) {
+	app, queue, cleanup := setupFiberTest(t, "/l/:slug/qr.png", HandleShortLinkQR, []mockResponse{
+		{
+			match:   "FROM short_link l",
+			args:    []interface{}{"spring"},
+			columns: []string{"slug", "website_id", "domain", "target_url", "created_at"},
+			rows:    [][]interface{}{{"spring", uuid.New().String(), "example.com", "https://example.com/launch", time.Now()}},
+		},
+	})
+	defer cleanup()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/l/spring/qr.png?size=300", nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "image/png", resp.Header.Get("Content-Type"))
+	img, err := png.Decode(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, 300, img.Bounds().Dx())
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleShortLinkQRRejectsBadSize(t *testing.T) {
+	app, _, cleanup := setupFiberTest(t, "/l/:slug/qr.png", HandleShortLinkQR, nil)
+	defer cleanup()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/l/spring/qr.png?size=10000", nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Purposes of a speculative load, as reported by speculativePurpose
+const (
+	purposePrerender = "prerender"
+	purposePrefetch  = "prefetch"
+	purposeActivate  = "activate" // a prerendered page that is now shown
+)
+
+// speculativeTotal counts requests dropped because they came from a page
+// that was loaded speculatively and not (yet) shown
+var speculativeTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "kaunta",
+	Subsystem: "ingest",
+	Name:      "speculative_total",
+	Help:      "Tracking requests dropped as prerender or prefetch loads.",
+}, []string{"purpose"})
+
+// Collectors returns the ingestion metrics
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{speculativeTotal, invalidPayloadsTotal}
+}
+
+// speculativePurpose tells whether a request comes from a speculative load:
+// "prerender", "prefetch", "activate" or "" for a normal page. The tracker
+// declares the purpose itself (it holds events while prerendering and marks
+// the first one after activation); otherwise the browser's Sec-Purpose,
+// Purpose, X-Purpose and X-Moz headers are used.
+func speculativePurpose(c fiber.Ctx, declared *string) string {
+	if declared != nil {
+		switch p := strings.ToLower(strings.TrimSpace(*declared)); p {
+		case purposePrerender, purposePrefetch, purposeActivate:
+			return p
+		}
+	}
+
+	// Chrome: "prefetch" or "prefetch;prerender"
+	if v := strings.ToLower(c.Get("Sec-Purpose")); strings.Contains(v, "prefetch") {
+		if strings.Contains(v, "prerender") {
+			return purposePrerender
+		}
+		return purposePrefetch
+	}
+	for _, header := range []string{"Purpose", "X-Purpose", "X-Moz"} {
+		switch strings.ToLower(c.Get(header)) {
+		case "prefetch", "preview", "instant":
+			return purposePrefetch
+		}
+	}
+	return ""
+}
+
+// isSpeculative reports whether a request should not be counted yet, and
+// counts it if so
+func isSpeculative(purpose string) bool {
+	if purpose != purposePrerender && purpose != purposePrefetch {
+		return false
+	}
+	speculativeTotal.WithLabelValues(purpose).Inc()
+	return true
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpeculativePurpose(t *testing.T) {
+	declared := func(s string) *string { return &s }
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		declared *string
+		want     string
+	}{
+		{name: "normal request", want: ""},
+		{name: "chrome prerender", headers: map[string]string{"Sec-Purpose": "prefetch;prerender"}, want: "prerender"},
+		{name: "chrome prefetch", headers: map[string]string{"Sec-Purpose": "prefetch"}, want: "prefetch"},
+		{name: "safari preload", headers: map[string]string{"Purpose": "prefetch"}, want: "prefetch"},
+		{name: "preview", headers: map[string]string{"X-Purpose": "preview"}, want: "prefetch"},
+		{name: "firefox prefetch", headers: map[string]string{"X-Moz": "prefetch"}, want: "prefetch"},
+		{name: "tracker declares activation", headers: map[string]string{"Sec-Purpose": "prefetch;prerender"}, declared: declared("activate"), want: "activate"},
+		{name: "tracker declares prerender", declared: declared("Prerender"), want: "prerender"},
+		{name: "unknown declaration falls back to headers", declared: declared("bogus"), want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/", func(c fiber.Ctx) error {
+				return c.SendString(speculativePurpose(c, tt.declared))
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			defer func() { _ = resp.Body.Close() }()
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(body))
+		})
+	}
+}
+
+func TestIsSpeculativeCountsDrops(t *testing.T) {
+	before := testutil.ToFloat64(speculativeTotal.WithLabelValues("prerender"))
+
+	assert.True(t, isSpeculative("prerender"))
+	assert.False(t, isSpeculative("activate"))
+	assert.False(t, isSpeculative(""))
+
+	assert.Equal(t, before+1, testutil.ToFloat64(speculativeTotal.WithLabelValues("prerender")))
+}
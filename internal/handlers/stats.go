@@ -5,11 +5,11 @@ import (
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/google/uuid"
-	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/store"
 )
 
 // HandleDashboardStats returns aggregated stats for the dashboard
-// Uses PostgreSQL function get_dashboard_stats() for optimized query execution
+// Uses get_dashboard_stats() on PostgreSQL for optimized query execution
 func HandleDashboardStats(c fiber.Ctx) error {
 	websiteIDStr := c.Params("website_id")
 	websiteID, err := uuid.Parse(websiteIDStr)
@@ -19,41 +19,13 @@ func HandleDashboardStats(c fiber.Ctx) error {
 		})
 	}
 
-	// Extract filter parameters from query string
-	country := c.Query("country")
-	browser := c.Query("browser")
-	device := c.Query("device")
-	page := c.Query("page")
-
-	// Convert empty strings to NULL for SQL
-	var countryParam, browserParam, deviceParam, pageParam interface{}
-	if country != "" {
-		countryParam = country
-	}
-	if browser != "" {
-		browserParam = browser
-	}
-	if device != "" {
-		deviceParam = device
-	}
-	if page != "" {
-		pageParam = page
+	filters, err := dashboardFilters(c, websiteID)
+	if err != nil {
+		return filtersError(c, err)
 	}
 
-	// Call get_dashboard_stats() function - replaces 4 separate queries
-	var currentVisitors, todayPageviews, todayVisitors int64
-	var bounceRateNumeric float64
-
-	query := `SELECT * FROM get_dashboard_stats($1, 1, $2, $3, $4, $5)`
-	err = database.DB.QueryRow(
-		query,
-		websiteID,
-		countryParam,
-		browserParam,
-		deviceParam,
-		pageParam,
-	).Scan(&currentVisitors, &todayPageviews, &todayVisitors, &bounceRateNumeric)
-
+	// Single get_dashboard_stats() call on PostgreSQL - replaces 4 separate queries
+	stats, err := store.Current().DashboardStats(c.Context(), websiteID, filters)
 	if err != nil {
 		// On error, return zero values
 		return c.JSON(DashboardStats{
@@ -65,12 +37,12 @@ func HandleDashboardStats(c fiber.Ctx) error {
 	}
 
 	// Format bounce rate as percentage string
-	bounceRate := fmt.Sprintf("%.1f%%", bounceRateNumeric)
+	bounceRate := fmt.Sprintf("%.1f%%", stats.BounceRate)
 
 	return c.JSON(DashboardStats{
-		CurrentVisitors: int(currentVisitors),
-		TodayPageviews:  int(todayPageviews),
-		TodayVisitors:   int(todayVisitors),
+		CurrentVisitors: int(stats.CurrentVisitors),
+		TodayPageviews:  int(stats.TodayPageviews),
+		TodayVisitors:   int(stats.TodayVisitors),
 		TodayBounceRate: bounceRate,
 	})
 }
@@ -1,9 +1,14 @@
 package handlers
 
 import (
+	"context"
+	"time"
+
 	"github.com/gofiber/fiber/v3"
 	"github.com/google/uuid"
 	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/stats"
+	"github.com/seuros/kaunta/internal/store"
 )
 
 // HandleCurrentVisitors returns count of visitors in last 5 minutes
@@ -19,16 +24,7 @@ func HandleCurrentVisitors(c fiber.Ctx) error {
 
 	// Count distinct sessions from last 5 minutes
 	// (Plausible uses last 5 minutes as default)
-	query := `
-		SELECT COUNT(DISTINCT session_id)
-		FROM website_event
-		WHERE website_id = $1
-		  AND created_at >= NOW() - INTERVAL '5 minutes'
-		  AND event_type = 1
-	`
-
-	var count int
-	err = database.DB.QueryRow(query, websiteID).Scan(&count)
+	count, err := store.Current().CurrentVisitors(c.Context(), websiteID)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to query current visitors",
@@ -39,3 +35,17 @@ func HandleCurrentVisitors(c fiber.Ctx) error {
 		"value": count,
 	})
 }
+
+// LiveSnapshot is the activity of the last few minutes that live streams
+// send. Only PostgreSQL answers it in full; the other stores report the
+// active visitors.
+func LiveSnapshot(ctx context.Context, websiteID uuid.UUID) (any, error) {
+	if store.Current().Name() == "postgres" {
+		return stats.GetLive(ctx, database.DB, websiteID)
+	}
+	visitors, err := store.Current().CurrentVisitors(ctx, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	return &stats.Live{Timestamp: time.Now(), ActiveVisitorsNow: visitors}, nil
+}
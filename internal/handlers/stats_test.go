@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/google/uuid"
@@ -18,7 +19,7 @@ func TestHandleDashboardStats_Success(t *testing.T) {
 	responses := []mockResponse{
 		{
 			match:   "SELECT * FROM get_dashboard_stats",
-			args:    []interface{}{websiteID, nil, nil, nil, nil},
+			args:    []interface{}{websiteID, nil, nil, nil, nil, nil, nil},
 			columns: []string{"current_visitors", "today_pageviews", "today_visitors", "bounce_rate"},
 			rows:    [][]interface{}{{int64(3), int64(12), int64(6), 33.3}},
 		},
@@ -44,6 +45,61 @@ func TestHandleDashboardStats_Success(t *testing.T) {
 	require.NoError(t, queue.expectationsMet())
 }
 
+func TestHandleDashboardStats_Segment(t *testing.T) {
+	websiteID := uuid.New()
+	now := time.Now()
+
+	responses := []mockResponse{
+		{
+			match:   "FROM segment WHERE website_id = $1 AND name = $2",
+			args:    []interface{}{websiteID, "german-mobile"},
+			columns: []string{"website_id", "name", "filters", "created_at", "updated_at"},
+			rows:    [][]interface{}{{websiteID.String(), "german-mobile", []byte(`{"country":"DE","device":"mobile"}`), now, now}},
+		},
+		{
+			// The device parameter overrides the segment's
+			match:   "SELECT * FROM get_dashboard_stats",
+			args:    []interface{}{websiteID, "DE", nil, "desktop", nil, nil, nil},
+			columns: []string{"current_visitors", "today_pageviews", "today_visitors", "bounce_rate"},
+			rows:    [][]interface{}{{int64(1), int64(2), int64(1), 0.0}},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/dashboard/stats/:website_id", HandleDashboardStats, responses)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/dashboard/stats/"+websiteID.String()+"?segment=german-mobile&device=desktop", nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleDashboardStats_UnknownSegment(t *testing.T) {
+	websiteID := uuid.New()
+
+	responses := []mockResponse{
+		{
+			match:   "FROM segment",
+			args:    []interface{}{websiteID, "nope"},
+			columns: []string{"website_id", "name", "filters", "created_at", "updated_at"},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/dashboard/stats/:website_id", HandleDashboardStats, responses)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/dashboard/stats/"+websiteID.String()+"?segment=nope", nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	require.NoError(t, queue.expectationsMet())
+}
+
 func TestHandleDashboardStats_InvalidWebsiteID(t *testing.T) {
 	app := fiber.New()
 	app.Get("/api/dashboard/stats/:website_id", HandleDashboardStats)
@@ -62,7 +118,7 @@ func TestHandleDashboardStats_QueryErrors(t *testing.T) {
 	responses := []mockResponse{
 		{
 			match: "SELECT * FROM get_dashboard_stats",
-			args:  []interface{}{websiteID, nil, nil, nil, nil},
+			args:  []interface{}{websiteID, nil, nil, nil, nil, nil, nil},
 			err:   assert.AnError,
 		},
 	}
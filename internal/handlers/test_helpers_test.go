@@ -12,7 +12,12 @@ import (
 	"testing"
 
 	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
 	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/exclusions"
+	"github.com/seuros/kaunta/internal/ingest"
+	"github.com/seuros/kaunta/internal/realtime"
+	"github.com/seuros/kaunta/internal/store"
 	"github.com/stretchr/testify/require"
 )
 
@@ -187,3 +192,73 @@ func setupFiberTest(t *testing.T, route string, handler fiber.Handler, responses
 
 	return app, queue, cleanup
 }
+
+// ingestStore accepts the tracking requests of any website, from any origin,
+// and keeps the events written directly to it
+type ingestStore struct {
+	store.Store
+	mu     sync.Mutex
+	events []*store.Event
+}
+
+// Name is "postgres", as the handlers of Postgres-only features check
+func (s *ingestStore) Name() string { return "postgres" }
+
+func (s *ingestStore) WebsiteSettings(context.Context, uuid.UUID) (*store.WebsiteSettings, error) {
+	return &store.WebsiteSettings{ProxyMode: "none", BotFilter: true, RespectDNT: "off", Domain: "example.com"}, nil
+}
+
+func (s *ingestStore) ValidateOrigin(context.Context, uuid.UUID, string) (bool, error) {
+	return true, nil
+}
+
+func (s *ingestStore) DetectBot(context.Context, string, string) (bool, error) { return false, nil }
+func (s *ingestStore) UpsertSession(context.Context, *store.Session) error     { return nil }
+
+func (s *ingestStore) ExclusionRules(context.Context, uuid.UUID) ([]exclusions.Rule, error) {
+	return nil, nil
+}
+
+func (s *ingestStore) CustomDimensions(context.Context, uuid.UUID) ([]string, error) {
+	return nil, nil
+}
+
+func (s *ingestStore) ServerSecret(_ context.Context, name string) ([]byte, error) {
+	return []byte("secret " + name), nil
+}
+
+func (s *ingestStore) InsertEvent(_ context.Context, e *store.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+	return nil
+}
+
+// useIngestStore makes an ingestStore the current store and broadcasts
+// realtime events locally
+func useIngestStore(t *testing.T) *ingestStore {
+	t.Helper()
+	st := &ingestStore{}
+	previous := store.Current()
+	store.SetCurrent(st)
+	realtime.UseLocalHub(realtime.NewHub())
+	t.Cleanup(func() {
+		store.SetCurrent(previous)
+		realtime.UseLocalHub(nil)
+	})
+	return st
+}
+
+// nearlyFullBuffer makes the ingest buffer one event short of full, so new
+// events are deferred
+func nearlyFullBuffer(t *testing.T) *ingest.Queue {
+	t.Helper()
+	buffer := ingest.New(ingest.Config{QueueSize: 10, BatchSize: 5, Sampling: ingest.SamplingConfig{Disabled: true}},
+		func(context.Context, []*store.Event) error { return nil })
+	for i := 0; i < 9; i++ {
+		require.NoError(t, buffer.Enqueue(&store.Event{}))
+	}
+	ingest.SetCurrent(buffer)
+	t.Cleanup(func() { ingest.SetCurrent(nil) })
+	return buffer
+}
@@ -1,13 +1,34 @@
 package handlers
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/gofiber/fiber/v3"
 	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/annotations"
 	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/notes"
+	"github.com/seuros/kaunta/internal/store"
 )
 
+// TimeSeriesWithNotes is the time series with the notes of its days and
+// the annotations of its period, each when asked for
+type TimeSeriesWithNotes struct {
+	Points      []TimeSeriesPoint        `json:"points"`
+	Notes       []notes.Note             `json:"notes"`
+	Annotations []annotations.Annotation `json:"annotations"`
+}
+
 // HandleTimeSeries returns time-series data for charts
-// Uses PostgreSQL function get_timeseries() for optimized hourly aggregation
+// Uses get_timeseries() on PostgreSQL for optimized aggregation.
+// ?interval= is minute, hour (the default), day, week or month; ?days is
+// capped to what the interval charts (1 day of minutes, 90 of hours, 366
+// otherwise). With ?notes=true or ?annotations=true the response is
+// {"points": [...], "notes": [...], "annotations": [...]}, the notes of the
+// same days and the annotations of the period (as asked for) alongside the
+// points.
 func HandleTimeSeries(c fiber.Ctx) error {
 	websiteIDStr := c.Params("website_id")
 	websiteID, err := uuid.Parse(websiteIDStr)
@@ -17,64 +38,77 @@ func HandleTimeSeries(c fiber.Ctx) error {
 		})
 	}
 
-	// Get date range (default 7 days, max 90)
-	days := fiber.Query[int](c, "days", 7)
-	if days > 90 {
-		days = 90
+	interval, err := store.ParseInterval(c.Query("interval"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
 	}
 
-	// Extract filter parameters
-	country := c.Query("country")
-	browser := c.Query("browser")
-	device := c.Query("device")
-	page := c.Query("page")
-
-	// Convert empty strings to NULL for SQL
-	var countryParam, browserParam, deviceParam, pageParam interface{}
-	if country != "" {
-		countryParam = country
+	// Get date range (default 7 days)
+	days := fiber.Query[int](c, "days", 7)
+	if days > interval.MaxDays() {
+		days = interval.MaxDays()
 	}
-	if browser != "" {
-		browserParam = browser
+
+	filters, err := dashboardFilters(c, websiteID)
+	if err != nil {
+		return filtersError(c, err)
 	}
-	if device != "" {
-		deviceParam = device
+	if days, err = dashboardRange(c, days, &filters); err != nil {
+		return rangeError(c, err)
 	}
-	if page != "" {
-		pageParam = page
+	if interval == store.IntervalMinute && !filters.From.IsZero() && filters.From.AddDate(0, 0, 1).Before(filters.To) {
+		return rangeError(c, fmt.Errorf("interval minute covers at most 1 day"))
 	}
 
 	// Call get_timeseries() function
-	query := `SELECT * FROM get_timeseries($1, $2, $3, $4, $5, $6)`
-	rows, err := database.DB.Query(
-		query,
-		websiteID,
-		days,
-		countryParam,
-		browserParam,
-		deviceParam,
-		pageParam,
-	)
-
+	rows, err := store.Current().TimeSeries(c.Context(), websiteID, days, interval, filters)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to query time series",
 		})
 	}
-	defer func() { _ = rows.Close() }()
 
-	points := make([]TimeSeriesPoint, 0)
-	for rows.Next() {
-		var timestamp string
-		var value int64
-		if err := rows.Scan(&timestamp, &value); err != nil {
-			continue
-		}
+	points := make([]TimeSeriesPoint, 0, len(rows))
+	for _, row := range rows {
 		points = append(points, TimeSeriesPoint{
-			Timestamp: timestamp,
-			Value:     int(value),
+			Timestamp: row.Timestamp,
+			Value:     int(row.Views),
 		})
 	}
 
-	return c.JSON(points)
+	withNotes, withAnnotations := fiber.Query[bool](c, "notes"), fiber.Query[bool](c, "annotations")
+	if !withNotes && !withAnnotations {
+		return c.JSON(points)
+	}
+
+	// Notes and annotations are PostgreSQL only; other stores chart without
+	// them
+	body := TimeSeriesWithNotes{Points: points, Notes: []notes.Note{}, Annotations: []annotations.Annotation{}}
+	if database.DB != nil && withNotes {
+		if filters.From.IsZero() {
+			body.Notes, err = notes.LastDays(c.Context(), database.DB, websiteID, days, time.Now())
+		} else {
+			body.Notes, err = notes.List(c.Context(), database.DB, websiteID, filters.From, filters.To.AddDate(0, 0, -1))
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to query notes",
+			})
+		}
+	}
+	if database.DB != nil && withAnnotations {
+		if filters.From.IsZero() {
+			body.Annotations, err = annotations.LastDays(c.Context(), database.DB, websiteID, days, time.Now())
+		} else {
+			body.Annotations, err = annotations.List(c.Context(), database.DB, websiteID, filters.From, filters.To)
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to query annotations",
+			})
+		}
+	}
+	return c.JSON(body)
 }
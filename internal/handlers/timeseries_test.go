@@ -5,19 +5,27 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/stats"
 )
 
 func TestHandleTimeSeries_Success(t *testing.T) {
 	websiteID := uuid.New()
 	responses := []mockResponse{
+		{
+			match:   "FROM rollup_state",
+			columns: []string{"covered_since", "refreshed_at"},
+			rows:    [][]interface{}{{nil, nil}},
+		},
 		{
 			match:   "SELECT * FROM get_timeseries",
-			args:    []interface{}{websiteID, 7, nil, nil, nil, nil},
+			args:    []interface{}{websiteID, 7, nil, nil, nil, nil, nil, nil, nil, nil, "hour"},
 			columns: []string{"hour", "views"},
 			rows: [][]interface{}{
 				{"2025-11-05T14:00:00Z", int64(10)},
@@ -42,12 +50,47 @@ func TestHandleTimeSeries_Success(t *testing.T) {
 	require.NoError(t, queue.expectationsMet())
 }
 
+func TestHandleTimeSeries_UsesRollups(t *testing.T) {
+	websiteID := uuid.New()
+	covered := time.Now().AddDate(0, 0, -30)
+	responses := []mockResponse{
+		{
+			match:   "FROM rollup_state",
+			columns: []string{"covered_since", "refreshed_at"},
+			rows:    [][]interface{}{{covered, time.Now()}},
+		},
+		{
+			match:   "FROM rollup_hourly",
+			args:    []interface{}{websiteID, 7, "hour"},
+			columns: []string{"bucket", "pageviews"},
+			rows: [][]interface{}{
+				{"2025-11-05T14:00:00Z", int64(10)},
+			},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/dashboard/timeseries/:website_id", HandleTimeSeries, responses)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/dashboard/timeseries/"+websiteID.String(), nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var points []TimeSeriesPoint
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&points))
+	assert.Len(t, points, 1)
+
+	require.NoError(t, queue.expectationsMet())
+}
+
 func TestHandleTimeSeries_WithFilters(t *testing.T) {
 	websiteID := uuid.New()
 	responses := []mockResponse{
 		{
 			match:   "SELECT * FROM get_timeseries",
-			args:    []interface{}{websiteID, 30, "US", "Chrome", "mobile", "/docs"},
+			args:    []interface{}{websiteID, 30, "US", "Chrome", "mobile", "/docs", nil, false, nil, nil, "hour"},
 			columns: []string{"hour", "views"},
 			rows: [][]interface{}{
 				{"2025-11-05T14:00:00Z", int64(5)},
@@ -58,7 +101,7 @@ func TestHandleTimeSeries_WithFilters(t *testing.T) {
 	app, queue, cleanup := setupFiberTest(t, "/api/dashboard/timeseries/:website_id", HandleTimeSeries, responses)
 	defer cleanup()
 
-	url := "/api/dashboard/timeseries/" + websiteID.String() + "?days=30&country=US&browser=Chrome&device=mobile&page=/docs"
+	url := "/api/dashboard/timeseries/" + websiteID.String() + "?days=30&country=US&browser=Chrome&device=mobile&page=/docs&bot=false"
 	req := httptest.NewRequest(http.MethodGet, url, nil)
 	resp, err := app.Test(req)
 	require.NoError(t, err)
@@ -85,7 +128,7 @@ func TestHandleTimeSeries_QueryError(t *testing.T) {
 	responses := []mockResponse{
 		{
 			match: "SELECT * FROM get_timeseries",
-			args:  []interface{}{websiteID, 7, nil, nil, nil, nil},
+			args:  []interface{}{websiteID, 7, nil, nil, nil, nil, nil, nil, nil, nil, "hour"},
 			err:   assert.AnError,
 		},
 	}
@@ -101,3 +144,169 @@ func TestHandleTimeSeries_QueryError(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
 	require.NoError(t, queue.expectationsMet())
 }
+
+func TestHandleTimeSeries_WithNotes(t *testing.T) {
+	websiteID := uuid.New()
+	today := time.Now().UTC()
+	responses := []mockResponse{
+		{
+			match:   "SELECT * FROM get_timeseries",
+			args:    []interface{}{websiteID, 30, "US", nil, nil, nil, nil, nil, nil, nil, "hour"},
+			columns: []string{"hour", "views"},
+			rows: [][]interface{}{
+				{"2025-11-05T14:00:00Z", int64(5)},
+			},
+		},
+		{
+			match:   "FROM note",
+			args:    []interface{}{websiteID, today.AddDate(0, 0, -30).Format(time.DateOnly), today.Format(time.DateOnly)},
+			columns: []string{"note_id", "website_id", "note_date", "body", "author", "created_at"},
+			rows: [][]interface{}{
+				{uuid.NewString(), websiteID.String(), time.Date(2025, 11, 5, 0, 0, 0, 0, time.UTC), "newsletter sent", "alice", time.Now()},
+			},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/dashboard/timeseries/:website_id", HandleTimeSeries, responses)
+	defer cleanup()
+
+	url := "/api/dashboard/timeseries/" + websiteID.String() + "?days=30&country=US&notes=true"
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var body TimeSeriesWithNotes
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Len(t, body.Points, 1)
+	require.Len(t, body.Notes, 1)
+	assert.Equal(t, "2025-11-05", body.Notes[0].Date)
+	assert.Equal(t, "newsletter sent", body.Notes[0].Text)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleTimeSeries_WithAnnotations(t *testing.T) {
+	websiteID := uuid.New()
+	period, err := stats.ParseRange("2025-06-01", "2025-06-15", "UTC", time.Now())
+	require.NoError(t, err)
+	responses := []mockResponse{
+		{
+			match:   "SELECT * FROM get_timeseries",
+			args:    []interface{}{websiteID, period.Days(time.Now()), nil, nil, nil, nil, nil, nil, period.From, period.To, "hour"},
+			columns: []string{"hour", "views"},
+			rows:    [][]interface{}{{"2025-06-03T09:00:00Z", int64(4)}},
+		},
+		{
+			match:   "FROM annotation",
+			args:    []interface{}{websiteID, period.From, period.To},
+			columns: []string{"annotation_id", "website_id", "annotated_at", "label", "kind", "author", "created_at"},
+			rows: [][]interface{}{
+				{uuid.NewString(), websiteID.String(), time.Date(2025, 6, 3, 9, 30, 0, 0, time.UTC), "v2.3.0", "deploy", "ci", time.Now()},
+			},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/dashboard/timeseries/:website_id", HandleTimeSeries, responses)
+	defer cleanup()
+
+	url := "/api/dashboard/timeseries/" + websiteID.String() + "?start=2025-06-01&end=2025-06-15&tz=UTC&annotations=true"
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, url, nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var body TimeSeriesWithNotes
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Len(t, body.Points, 1)
+	assert.NotNil(t, body.Notes)
+	assert.Empty(t, body.Notes)
+	require.Len(t, body.Annotations, 1)
+	assert.Equal(t, "v2.3.0", body.Annotations[0].Label)
+	assert.Equal(t, "deploy", body.Annotations[0].Kind)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleTimeSeries_DateRange(t *testing.T) {
+	websiteID := uuid.New()
+	period, err := stats.ParseRange("2025-06-01", "2025-06-15", "Europe/Berlin", time.Now())
+	require.NoError(t, err)
+	responses := []mockResponse{
+		{
+			match:   "SELECT * FROM get_timeseries",
+			args:    []interface{}{websiteID, period.Days(time.Now()), nil, nil, nil, nil, nil, nil, period.From, period.To, "hour"},
+			columns: []string{"hour", "views"},
+			rows:    [][]interface{}{{"2025-06-03T09:00:00Z", int64(4)}},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/dashboard/timeseries/:website_id", HandleTimeSeries, responses)
+	defer cleanup()
+
+	url := "/api/dashboard/timeseries/" + websiteID.String() + "?start=2025-06-01&end=2025-06-15&tz=Europe/Berlin"
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, url, nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleTimeSeries_InvalidDateRange(t *testing.T) {
+	websiteID := uuid.New()
+	app, _, cleanup := setupFiberTest(t, "/api/dashboard/timeseries/:website_id", HandleTimeSeries, nil)
+	defer cleanup()
+
+	url := "/api/dashboard/timeseries/" + websiteID.String() + "?start=2025-06-15&end=2025-06-01"
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, url, nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	var body map[string]string
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "Invalid date range: the range ends (2025-06-01) before it starts (2025-06-15)", body["error"])
+}
+
+func TestHandleTimeSeries_Interval(t *testing.T) {
+	websiteID := uuid.New()
+	responses := []mockResponse{
+		{
+			match:   "SELECT * FROM get_timeseries",
+			args:    []interface{}{websiteID, 1, nil, nil, nil, nil, nil, nil, nil, nil, "minute"},
+			columns: []string{"hour", "views"},
+			rows:    [][]interface{}{{"2025-11-05T14:03:00Z", int64(2)}},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/dashboard/timeseries/:website_id", HandleTimeSeries, responses)
+	defer cleanup()
+
+	// Minutes cover at most a day
+	url := "/api/dashboard/timeseries/" + websiteID.String() + "?interval=minute&days=30"
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, url, nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleTimeSeries_InvalidInterval(t *testing.T) {
+	websiteID := uuid.New()
+	app, _, cleanup := setupFiberTest(t, "/api/dashboard/timeseries/:website_id", HandleTimeSeries, nil)
+	defer cleanup()
+
+	for query, message := range map[string]string{
+		"?interval=year": "invalid interval: year (use minute, hour, day, week or month)",
+		"?interval=minute&start=2025-06-01&end=2025-06-03": "Invalid date range: interval minute covers at most 1 day",
+	} {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/dashboard/timeseries/"+websiteID.String()+query, nil))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		_ = resp.Body.Close()
+		assert.Equal(t, message, body["error"])
+	}
+}
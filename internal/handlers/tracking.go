@@ -6,16 +6,31 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/google/uuid"
 
-	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/cardinality"
+	"github.com/seuros/kaunta/internal/channels"
+	"github.com/seuros/kaunta/internal/debugcapture"
+	"github.com/seuros/kaunta/internal/dedup"
+	"github.com/seuros/kaunta/internal/eventprops"
+	"github.com/seuros/kaunta/internal/fieldcrypt"
 	"github.com/seuros/kaunta/internal/geoip"
+	"github.com/seuros/kaunta/internal/ingest"
 	"github.com/seuros/kaunta/internal/logging"
+	"github.com/seuros/kaunta/internal/newsletter"
+	"github.com/seuros/kaunta/internal/privacy"
 	"github.com/seuros/kaunta/internal/realtime"
+	"github.com/seuros/kaunta/internal/store"
+	"github.com/seuros/kaunta/internal/tracing"
+	"github.com/seuros/kaunta/internal/useragent"
+	"github.com/seuros/kaunta/internal/visits"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -39,8 +54,45 @@ var spamReferrers = []string{
 
 // TrackingPayload matches Umami's /api/send payload
 type TrackingPayload struct {
-	Type    string      `json:"type"` // "event" or "identify"
+	Type    string      `json:"type"` // "event", "identify", "view" or "read" (see view.go)
 	Payload PayloadData `json:"payload"`
+
+	// Traceparent is the W3C trace context for requests that can't set
+	// headers (sendBeacon); the traceparent header takes precedence
+	Traceparent string `json:"traceparent,omitempty"`
+
+	// relay is set for hits relayed by a server (see ga4.go)
+	relay *relayedHit
+
+	// pixel is set for /k.gif hits (see pixel.go): they come from <img> tags
+	// in pages, mail and feed readers, so neither their origin nor browser
+	// signals can be checked
+	pixel bool
+
+	// shortLink is set for short link clicks (see shortlink.go): visitors
+	// arrive from other sites, so the origin isn't checked
+	shortLink bool
+
+	// email is set for email opens and clicks (see newsletter.go), whose
+	// recipient token is dropped unless it may be recorded
+	email bool
+
+	// canRetry is set for the tracker's own requests (/api/send and
+	// /api/batch), which send an event again when asked to: only they are
+	// deferred near capacity. Pixels, relays and links have no one to retry.
+	canRetry bool
+
+	// invalid lists the payload values decodePayload converted or dropped
+	// for being of the wrong type
+	invalid []fieldError
+}
+
+// relayedHit describes the visitor of a hit a server sent on their behalf:
+// the request's user agent is the server's, so the session is keyed by the
+// visitor's own ID and the device comes from the hit
+type relayedHit struct {
+	visitor             string
+	browser, os, device *string
 }
 
 type PayloadData struct {
@@ -63,16 +115,73 @@ type PayloadData struct {
 	ScrollDepth    *int                   `json:"scroll_depth,omitempty"`    // 0-100 percentage
 	EngagementTime *int                   `json:"engagement_time,omitempty"` // milliseconds
 	Props          map[string]interface{} `json:"props,omitempty"`           // custom properties
+
+	// Loss accounting: the tracker numbers each page instance's events
+	PageID *string `json:"page_id,omitempty"`
+	Seq    *int    `json:"seq,omitempty"`
+
+	// Purpose is "prerender" or "prefetch" for speculative loads and
+	// "activate" for the first event of a prerendered page once shown
+	Purpose *string `json:"purpose,omitempty"`
+
+	// View is "pending" for a pageview the tracker will confirm once the
+	// page has been seen
+	View *string `json:"view,omitempty"`
+
+	// Author is the page's byline; kept on pageviews only
+	Author *string `json:"author,omitempty"`
+
+	// Webdriver is set by the tracker when navigator.webdriver is true
+	// (browser automation)
+	Webdriver *bool `json:"webdriver,omitempty"`
+
+	// Dimensions holds custom dimension values; only names registered for
+	// the website are kept
+	Dimensions map[string]interface{} `json:"dimensions,omitempty"`
+
+	// Link is the token of a visitor who followed a link from a linked
+	// website (see crossdomain.go)
+	Link *string `json:"link,omitempty"`
+
+	// campaign is the campaign a linked pageview takes over
+	campaign *utmParams
+
+	// propsLimits caps the website's event properties
+	propsLimits eventprops.Limits
 }
 
 // HandleTracking is the /api/send endpoint - compatible with Umami
 func HandleTracking(c fiber.Ctx) error {
-	var payload TrackingPayload
-	if err := c.Bind().Body(&payload); err != nil {
+	// The body is decoded whatever its Content-Type: sendBeacon can only send
+	// text/plain without a CORS preflight
+	payload, err := decodePayload(c.Body())
+	if err != nil {
 		return c.Status(400).JSON(fiber.Map{
 			"error": "Invalid JSON payload",
 		})
 	}
+	payload.canRetry = true
+	return track(c, payload)
+}
+
+// track ingests one tracking payload and writes its response to c
+func track(c fiber.Ctx, payload TrackingPayload) error {
+	// Continue the tracker's trace when it sent one: the tracing middleware
+	// already did for the header, sendBeacon requests carry it in the payload
+	ctx := c.Context()
+	if c.Get("traceparent") == "" && payload.Traceparent != "" {
+		ctx = tracing.WithTraceparent(ctx, payload.Traceparent)
+	}
+	ctx, span := tracing.Start(ctx, "kaunta.ingest",
+		trace.WithAttributes(attribute.String("kaunta.event_type", payload.Type)))
+	defer func() {
+		span.SetAttributes(attribute.Int("http.response.status_code", c.Response().StatusCode()))
+		span.End()
+	}()
+
+	if len(payload.invalid) > 0 && validationMode == ValidationReject {
+		return rejectPayload(c, "Invalid payload", payload.invalid)
+	}
 
 	// Validate website UUID
 	websiteID, err := uuid.Parse(payload.Payload.Website)
@@ -82,31 +191,54 @@ func HandleTracking(c fiber.Ctx) error {
 		})
 	}
 
-	// Verify website exists and fetch proxy_mode
-	var proxyMode string
-	err = database.DB.QueryRow(
-		"SELECT COALESCE(proxy_mode, 'none') FROM website WHERE website_id = $1",
-		websiteID,
-	).Scan(&proxyMode)
+	span.SetAttributes(attribute.String("kaunta.website_id", websiteID.String()))
+	db := store.Current()
 
+	// Verify website exists and fetch its ingestion settings
+	spanCtx, dbSpan := storeSpan(ctx, "website_settings")
+	settings, err := db.WebsiteSettings(spanCtx, websiteID)
+	tracing.End(dbSpan, err)
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"error": "Website not found",
 		})
 	}
 
+	// A sample of the website's requests is kept while it is being debugged,
+	// with the answer it got; ctx is read when the request is done
+	if debugcapture.Current().Sample(websiteID) {
+		defer func() { captureRequest(ctx, c, websiteID, settings.ProxyMode) }()
+	}
+
+	// Abusive or broken clients are turned away before they cost more
+	// queries. Relayed hits and pixels (often loaded through mail proxies)
+	// speak for many visitors from one address: only their website's limit
+	// applies.
+	limitIP := getClientIP(c, settings.ProxyMode)
+	if payload.relay != nil || payload.pixel {
+		limitIP = ""
+	}
+	if limit, wait := ingest.CurrentRateLimiter().Allow(limitIP, websiteID); limit != "" {
+		span.SetAttributes(attribute.String("kaunta.rate_limited", limit))
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(wait/time.Second)))
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"error": "Too many requests, retry in " + strconv.Itoa(int(wait/time.Second)) + " seconds",
+			"limit": limit,
+		})
+	}
+
 	// Origin validation (CORS security)
 	origin := c.Get("Origin")
 	if origin == "" {
 		origin = c.Get("Referer") // Fallback to Referer header
 	}
 
-	var originAllowed bool
-	err = database.DB.QueryRow(
-		"SELECT validate_origin($1, $2)",
-		websiteID, origin,
-	).Scan(&originAllowed)
-
+	originAllowed := true
+	if !payload.pixel && !payload.shortLink {
+		spanCtx, dbSpan = storeSpan(ctx, "validate_origin")
+		originAllowed, err = db.ValidateOrigin(spanCtx, websiteID, origin)
+		tracing.End(dbSpan, err)
+	}
 	if err != nil {
 		logging.L().Warn("origin validation error", zap.String("website_id", websiteID.String()), zap.Error(err))
 		return c.Status(500).JSON(fiber.Map{
@@ -130,8 +262,23 @@ func HandleTracking(c fiber.Ctx) error {
 		c.Set("Access-Control-Allow-Origin", "*")
 	}
 
+	// Visitors asking not to be tracked are dropped, or anonymized as with
+	// ip_mode = "hash" and without their distinct ID, as the website chose
+	switch doNotTrackAction(c, settings) {
+	case RespectDNTDrop:
+		span.SetAttributes(attribute.String("kaunta.dnt", RespectDNTDrop))
+		return c.Status(202).JSON(fiber.Map{"dropped": "dnt"})
+	case RespectDNTAnonymize:
+		span.SetAttributes(attribute.String("kaunta.dnt", RespectDNTAnonymize))
+		ctx = privacy.WithMode(ctx, privacy.Hash)
+		payload.Payload.ID = nil
+	}
+	if payload.email && !newsletter.KeepRecipient(ctx) {
+		delete(payload.Payload.Props, newsletter.RecipientProp)
+	}
+
 	// Get client info
-	ip := getClientIP(c, proxyMode)
+	ip := getClientIP(c, settings.ProxyMode)
 	userAgent := c.Get("User-Agent")
 
 	// Override with payload if provided
@@ -142,32 +289,59 @@ func HandleTracking(c fiber.Ctx) error {
 		userAgent = *payload.Payload.UserAgent
 	}
 
-	// Bot detection using PostgreSQL (dictatorship approach - all logic in DB)
-	// This updates IP metadata and returns bot status in one call
-	var isBot *bool // Use pointer to handle NULL values
-	err = database.DB.QueryRow(`
-		SELECT update_ip_metadata($1::inet, $2, NULL)
-	`, ip, userAgent).Scan(&isBot)
+	// Internal traffic (office IPs, staging hosts, admin pages) is dropped
+	// before anything about it is recorded
+	if isExcluded(ctx, websiteID, ip, payload.Payload) {
+		span.SetAttributes(attribute.Bool("kaunta.excluded", true))
+		return c.Status(202).JSON(fiber.Map{"dropped": "excluded"})
+	}
 
-	if err != nil {
-		// Log error but don't block traffic on bot detection failure
-		logging.L().Warn("bot detection error", zap.String("ip", ip), zap.Error(err))
-		// Default to not a bot if detection fails
-		isBotVal := false
-		isBot = &isBotVal
+	// Bot detection (on PostgreSQL this also updates IP metadata in the same
+	// call, so it only gets the IP in the form ip_mode allows to be stored)
+	// Relayed hits are authenticated and carry no visitor user agent
+	isBot := false
+	if payload.relay == nil {
+		storedIP := privacy.StoredIP(ctx, ip)
+		spanCtx, dbSpan = storeSpan(ctx, "detect_bot")
+		isBot, err = db.DetectBot(spanCtx, storedIP, userAgent)
+		tracing.End(dbSpan, err)
+		if err != nil {
+			// Log error but don't block traffic on bot detection failure
+			logging.L().Warn("bot detection error", zap.String("ip", storedIP), zap.Error(err))
+			// Default to not a bot if detection fails
+			isBot = false
+		}
+		if !isBot && !payload.pixel {
+			isBot = looksHeadless(c, payload.Payload)
+		}
 	}
 
-	// Check if it's a bot (handle nil gracefully)
-	if isBot != nil && *isBot {
-		// Return 202 for bots (acknowledged but not processed)
-		return c.Status(202).JSON(fiber.Map{"beep": "boop", "bot_detected": true})
+	if isBot {
+		span.SetAttributes(attribute.Bool("kaunta.bot", true))
+		if settings.BotFilter {
+			// Return 202 for bots (acknowledged but not processed)
+			return c.Status(202).JSON(fiber.Map{"beep": "boop", "bot_detected": true})
+		}
+	}
+
+	// Prerendered and prefetched pages may never be shown; the tracker sends
+	// their pageview again on activation
+	if purpose := speculativePurpose(c, payload.Payload.Purpose); isSpeculative(purpose) {
+		span.SetAttributes(attribute.String("kaunta.purpose", purpose))
+		return c.Status(202).JSON(fiber.Map{"dropped": "speculative", "purpose": purpose})
 	}
 
 	// Validate URL length
 	if payload.Payload.URL != nil && len(*payload.Payload.URL) > MaxURLSize {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "URL too long (max 2000 characters)",
-		})
+		return rejectPayload(c, "URL too long (max 2000 characters)",
+			[]fieldError{{Field: "payload.url", Reason: reasonTooLong, Limit: MaxURLSize}})
+	}
+
+	if payload.Type == "view" {
+		return confirmView(ctx, c, websiteID, ip, userAgent, payload.Payload)
+	}
+	if payload.Type == "read" {
+		return markRead(ctx, c, websiteID, ip, userAgent, payload.Payload)
 	}
 
 	// Check spam referrer
@@ -175,14 +349,53 @@ func HandleTracking(c fiber.Ctx) error {
 		return c.Status(202).JSON(fiber.Map{"dropped": "spam_referrer"})
 	}
 
-	// Parse client info
-	browser, os, device := parseUserAgent(userAgent)
+	// Moving between the website's own domains isn't a referral
+	if payload.Payload.Referrer != nil {
+		if u, err := url.Parse(*payload.Payload.Referrer); err == nil && settings.SelfReferral(u.Hostname()) {
+			payload.Payload.Referrer = nil
+		}
+	}
+	if payload.Payload.URL != nil {
+		if stripped, ok := dropSelfUTM(*payload.Payload.URL, settings); ok {
+			payload.Payload.URL = &stripped
+		}
+	}
+	payload.Payload.propsLimits = eventprops.Limits{MaxBytes: settings.PropsMaxBytes, MaxDepth: settings.PropsMaxDepth}
+
+	// Values over their column's size are cut to fit, or the payload is
+	// rejected (payload_validation)
+	reject := validationMode == ValidationReject
+	if over := limitPayload(&payload.Payload, !reject); len(over) > 0 {
+		if reject {
+			return rejectPayload(c, "Invalid payload", over)
+		}
+		payload.invalid = append(payload.invalid, over...)
+	}
+	if len(payload.invalid) > 0 {
+		invalidPayloadsTotal.WithLabelValues("fixed").Inc()
+		logging.L().Debug("invalid payload values fixed",
+			zap.String("website_id", websiteID.String()), zap.Any("fields", payload.invalid))
+	}
+
+	// Parse client info. Client hints describe the requesting browser, not
+	// a user agent forwarded in the payload.
+	var hints useragent.Hints
+	if payload.Payload.UserAgent == nil {
+		hints = useragent.HintsFrom(c.Get)
+	}
+	browser, os, device := parseClient(userAgent, hints)
+	visitor := userAgent
+	if r := payload.relay; r != nil {
+		visitor = r.visitor
+		browser, os, device = r.browser, r.os, r.device
+	}
 
 	// GeoIP lookup from IP address
 	countryStr, cityStr, regionStr := geoIPLookup(ip)
 	country := &countryStr
 	region := &regionStr
 	city := &cityStr
+	asn, isp := asnLookup(ip)
 
 	// Generate session ID (deterministic based on IP + UA + date)
 	createdAt := time.Now()
@@ -190,16 +403,81 @@ func HandleTracking(c fiber.Ctx) error {
 		createdAt = time.Unix(*payload.Payload.Timestamp, 0)
 	}
 
-	sessionSalt := hashDate(createdAt, "month")
-	sessionID := generateUUID(websiteID.String(), ip, userAgent, sessionSalt)
+	sessionID, err := visitorSessionID(ctx, websiteID, ip, visitor, createdAt)
+	if err != nil {
+		logging.L().Error("failed to derive session", zap.String("website_id", websiteID.String()), zap.Error(err))
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to create session",
+		})
+	}
 
-	// Create or update session
-	distinctID := payload.Payload.ID
-	err = upsertSession(sessionID, websiteID, browser, os, device,
-		payload.Payload.Screen, payload.Payload.Language,
-		country, region, city, distinctID)
+	// Under load only one visitor in N is kept, with all their events, rather
+	// than events being lost at random; kept events record N
+	sampleRate := 1
+	if payload.Type == "event" {
+		var keep bool
+		sampleRate, keep = ingest.Current().Sample(sessionID)
+		if !keep {
+			return c.Status(202).JSON(fiber.Map{"dropped": "sampled"})
+		}
+		// Near capacity, the tracker is asked to send the event again later;
+		// it retries with jitter so the backlog drains instead of being hit
+		// by every visitor at once. Other hits are never sent again, so they
+		// are buffered (or written directly) like any other time.
+		if payload.canRetry {
+			if wait := ingest.Current().RetryAfter(); wait > 0 {
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(wait/time.Second)))
+				return c.Status(202).JSON(fiber.Map{"deferred": "overloaded"})
+			}
+		}
+	}
 
+	// A double-fired route hook or a resent event isn't counted twice. The
+	// event is only remembered once it is kept: a deferred event is sent
+	// again on purpose, and one that fails to be stored is forgotten.
+	var dedupName, dedupURL string
+	if payload.Type == "event" {
+		if payload.Payload.Name != nil {
+			dedupName = *payload.Payload.Name
+		}
+		if payload.Payload.URL != nil {
+			dedupURL = *payload.Payload.URL
+		}
+		if dedup.Current().Duplicate(websiteID, sessionID, payload.Type, dedupName, dedupURL) {
+			return c.Status(202).JSON(fiber.Map{"dropped": "duplicate"})
+		}
+	}
+
+	// Create or update session (distinct_id is encrypted at rest when configured)
+	distinctID, err := fieldcrypt.EncryptString(payload.Payload.ID)
 	if err != nil {
+		dedup.Current().Forget(sessionID, payload.Type, dedupName, dedupURL)
+		logging.L().Error("failed to encrypt distinct_id", zap.Error(err))
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to create session",
+		})
+	}
+	session := &store.Session{
+		SessionID:  sessionID,
+		WebsiteID:  websiteID,
+		Browser:    browser,
+		OS:         os,
+		Device:     device,
+		Screen:     payload.Payload.Screen,
+		Language:   payload.Payload.Language,
+		Country:    country,
+		Region:     region,
+		City:       city,
+		DistinctID: distinctID,
+		ASN:        asn,
+		ISP:        isp,
+	}
+	spanCtx, dbSpan = storeSpan(ctx, "upsert_session")
+	err = db.UpsertSession(spanCtx, session)
+	tracing.End(dbSpan, err)
+
+	if err != nil {
+		dedup.Current().Forget(sessionID, payload.Type, dedupName, dedupURL)
 		logging.L().Error("session creation error",
 			zap.String("website_id", websiteID.String()),
 			zap.String("session_id", sessionID.String()),
@@ -211,13 +489,19 @@ func HandleTracking(c fiber.Ctx) error {
 
 	// Handle event type
 	if payload.Type == "event" {
-		visitSalt := hashDate(createdAt, "hour")
-		visitID := generateUUID(sessionID.String(), visitSalt)
+		if payload.Payload.Link != nil && payload.Payload.Name == nil {
+			linkSession(ctx, websiteID, sessionID, &payload.Payload)
+		}
+
+		// Events of a page sending umami.js's cache token stay in its visit
+		rules := visits.Defaults().Override(settings.VisitDefinition, settings.SessionTimeout)
+		cached := cachedVisit(c, websiteID, sessionID, rules.Timeout)
+		visitID := visits.Current().Visit(sessionID, createdAt, rules, cached)
 
-		err = saveEvent(websiteID, sessionID, visitID, createdAt, payload.Payload,
-			browser, os, device, country, region, city)
+		err = saveEvent(ctx, session, visitID, createdAt, payload.Payload, isBot, sampleRate)
 
 		if err != nil {
+			dedup.Current().Forget(sessionID, payload.Type, dedupName, dedupURL)
 			return c.Status(500).JSON(fiber.Map{
 				"error": "Failed to save event: " + err.Error(),
 			})
@@ -231,21 +515,24 @@ func HandleTracking(c fiber.Ctx) error {
 		if payload.Payload.Title != nil {
 			eventTitle = *payload.Payload.Title
 		}
-		realtime.NotifyEvent(
-			context.Background(),
-			realtime.NewEventPayload(
-				payload.Type,
-				websiteID,
-				sessionID,
-				visitID,
-				eventPath,
-				eventTitle,
-				createdAt,
-			),
+		event := realtime.NewEventPayload(
+			payload.Type,
+			websiteID,
+			sessionID,
+			visitID,
+			eventPath,
+			eventTitle,
+			createdAt,
 		)
+		if payload.Payload.Name != nil {
+			event.Name = *payload.Payload.Name
+		}
+		realtime.NotifyEvent(context.Background(), event)
 
 		// Return 202 Accepted (acknowledges receipt, not completion)
+		cache := umamiCache{WebsiteID: websiteID, SessionID: sessionID, VisitID: visitID, IssuedAt: time.Now().Unix()}
 		return c.Status(202).JSON(fiber.Map{
+			"cache":     cache.encode(),
 			"sessionId": sessionID.String(),
 			"visitId":   visitID.String(),
 		})
@@ -264,23 +551,11 @@ func HandleTracking(c fiber.Ctx) error {
 	})
 }
 
-// upsertSession creates or updates a session (INSERT ON CONFLICT DO NOTHING)
-func upsertSession(sessionID, websiteID uuid.UUID, browser, os, device, screen, language, country, region, city *string, distinctID *string) error {
-	query := `
-		INSERT INTO session (
-			session_id, website_id, browser, os, device, screen, language,
-			country, region, city, created_at, distinct_id
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW(), $11)
-		ON CONFLICT (session_id) DO NOTHING
-	`
-	_, err := database.DB.Exec(query, sessionID, websiteID, browser, os, device,
-		screen, language, country, region, city, distinctID)
-	return err
-}
-
 // saveEvent saves a pageview or custom event
-func saveEvent(websiteID, sessionID, visitID uuid.UUID, createdAt time.Time,
-	payload PayloadData, browser, os, device, country, region, city *string) error {
+func saveEvent(ctx context.Context, session *store.Session, visitID uuid.UUID, createdAt time.Time,
+	payload PayloadData, bot bool, sampleRate int) error {
+
+	websiteID, sessionID := session.WebsiteID, session.SessionID
 
 	eventID := uuid.New()
 	eventType := 1
@@ -290,13 +565,17 @@ func saveEvent(websiteID, sessionID, visitID uuid.UUID, createdAt time.Time,
 
 	// Parse URL
 	var urlPath, urlQuery, hostname, referrerPath, referrerQuery, referrerDomain *string
+	var utm utmParams
+	var query url.Values
 	if payload.URL != nil {
 		if u, err := url.Parse(*payload.URL); err == nil {
 			path := u.Path
 			urlPath = &path
-			query := u.RawQuery
-			if query != "" {
-				urlQuery = &query
+			rawQuery := u.RawQuery
+			if rawQuery != "" {
+				urlQuery = &rawQuery
+				query = u.Query()
+				utm = parseUTMParams(query)
 			}
 			if payload.Hostname != nil {
 				hostname = payload.Hostname
@@ -307,6 +586,10 @@ func saveEvent(websiteID, sessionID, visitID uuid.UUID, createdAt time.Time,
 		}
 	}
 
+	if utm == (utmParams{}) && payload.campaign != nil {
+		utm = *payload.campaign
+	}
+
 	// Parse referrer
 	if payload.Referrer != nil {
 		if u, err := url.Parse(*payload.Referrer); err == nil {
@@ -323,23 +606,55 @@ func saveEvent(websiteID, sessionID, visitID uuid.UUID, createdAt time.Time,
 		}
 	}
 
-	// Convert props/data to JSON (Phase 2)
-	var propsJSON interface{}
-	if payload.Props != nil || payload.Data != nil {
-		combined := make(map[string]interface{})
-		if payload.Props != nil {
-			for key, value := range payload.Props {
-				combined[key] = value
-			}
+	// The channel is read from the full values, before they are clipped or
+	// bucketed
+	channel := channels.Classify(channels.Source{
+		ReferrerDomain: deref(referrerDomain),
+		UTMSource:      deref(utm.Source),
+		UTMMedium:      deref(utm.Medium),
+		Query:          query,
+	})
+
+	// Parts over their column's size were let through by limitPayload in
+	// truncate mode
+	urlPath, urlQuery = clip(urlPath, maxPathLength), clip(urlQuery, maxPathLength)
+	hostname = clip(hostname, maxHostnameLength)
+	referrerPath, referrerQuery = clip(referrerPath, maxPathLength), clip(referrerQuery, maxPathLength)
+	referrerDomain = clip(referrerDomain, maxPathLength)
+
+	// Past the day's cap of distinct values, new ones are bucketed
+	if quota := cardinality.Current(); quota != nil {
+		urlPath = quota.LimitPtr(websiteID, cardinality.Page, urlPath)
+		if limited := quota.LimitPtr(websiteID, cardinality.Referrer, referrerDomain); limited != referrerDomain {
+			referrerDomain, referrerPath, referrerQuery = limited, nil, nil
 		}
-		if payload.Data != nil {
-			for key, value := range payload.Data {
-				combined[key] = value
-			}
+		utm.Source = quota.LimitPtr(websiteID, cardinality.UTMSource, utm.Source)
+		utm.Medium = quota.LimitPtr(websiteID, cardinality.UTMMedium, utm.Medium)
+		utm.Campaign = quota.LimitPtr(websiteID, cardinality.UTMCampaign, utm.Campaign)
+		utm.Content = quota.LimitPtr(websiteID, cardinality.UTMContent, utm.Content)
+		utm.Term = quota.LimitPtr(websiteID, cardinality.UTMTerm, utm.Term)
+		if eventType == 2 {
+			payload.Name = quota.LimitPtr(websiteID, cardinality.Event, payload.Name)
+		}
+	}
+
+	// Convert props/data to JSON (Phase 2)
+	var propsJSON []byte
+	if combined := combinedProps(payload); combined != nil {
+		combined, _ = limitProps(combined)
+		combined, dropped := eventprops.Clean(combined, payload.propsLimits)
+		if len(dropped) > 0 {
+			logging.L().Debug("event properties over the website's limits dropped",
+				zap.String("website_id", websiteID.String()), zap.Strings("keys", dropped))
 		}
 		if len(combined) > 0 {
 			jsonBytes, _ := json.Marshal(combined)
-			propsJSON = jsonBytes
+			sealed, err := fieldcrypt.EncryptJSON(jsonBytes)
+			if err != nil {
+				logging.L().Error("failed to encrypt event props", zap.Error(err))
+				return err
+			}
+			propsJSON = sealed
 		}
 	}
 
@@ -361,22 +676,24 @@ func saveEvent(websiteID, sessionID, visitID uuid.UUID, createdAt time.Time,
 		}
 	}
 
-	// Enhanced schema: includes Phase 2 fields
-	query := `
-		INSERT INTO website_event (
-			event_id, website_id, session_id, visit_id, created_at,
-			page_title, hostname, url_path, url_query,
-			referrer_path, referrer_query, referrer_domain,
-			event_name, tag, event_type,
-			scroll_depth, engagement_time, props
-		) VALUES (
-			$1, $2, $3, $4, $5,
-			$6, $7, $8, $9,
-			$10, $11, $12,
-			$13, $14, $15,
-			$16, $17, $18
-		)
-	`
+	var author *string
+	if eventType == 1 && payload.Author != nil {
+		author = truncatedAuthor(*payload.Author)
+	}
+
+	var viewed *bool
+	if eventType == 1 && payload.View != nil && *payload.View == viewPending {
+		viewed = new(bool)
+	}
+
+	// Sequence numbers are only meaningful with a valid page instance
+	var pageID uuid.UUID
+	var seq int
+	if payload.PageID != nil && payload.Seq != nil && *payload.Seq > 0 {
+		if id, err := uuid.Parse(*payload.PageID); err == nil {
+			pageID, seq = id, *payload.Seq
+		}
+	}
 
 	logging.L().Debug("inserting event",
 		zap.Int("event_type", eventType),
@@ -384,16 +701,67 @@ func saveEvent(websiteID, sessionID, visitID uuid.UUID, createdAt time.Time,
 		zap.String("website_id", websiteID.String()),
 		zap.String("session_id", sessionID.String()),
 		zap.String("visit_id", visitID.String()),
+		zap.String("trace_id", tracing.TraceID(ctx)),
 	)
 
-	_, err := database.DB.Exec(query,
-		eventID, websiteID, sessionID, visitID, createdAt,
-		payload.Title, hostname, urlPath, urlQuery,
-		referrerPath, referrerQuery, referrerDomain,
-		payload.Name, payload.Tag, eventType,
-		scrollDepth, engagementTime, propsJSON,
-	)
+	// Enhanced schema: includes Phase 2 fields
+	event := &store.Event{
+		EventID:        eventID,
+		WebsiteID:      websiteID,
+		SessionID:      sessionID,
+		VisitID:        visitID,
+		CreatedAt:      createdAt,
+		PageTitle:      payload.Title,
+		Hostname:       hostname,
+		URLPath:        urlPath,
+		URLQuery:       urlQuery,
+		ReferrerPath:   referrerPath,
+		ReferrerQuery:  referrerQuery,
+		ReferrerDomain: referrerDomain,
+		EventName:      payload.Name,
+		Tag:            payload.Tag,
+		EventType:      eventType,
+		ScrollDepth:    scrollDepth,
+		EngagementTime: engagementTime,
+		Props:          propsJSON,
+		Bot:            bot,
+		SampleRate:     sampleRate,
+		UTMSource:      utm.Source,
+		UTMMedium:      utm.Medium,
+		UTMCampaign:    utm.Campaign,
+		UTMContent:     utm.Content,
+		UTMTerm:        utm.Term,
+		Viewed:         viewed,
+		Author:         author,
+		Channel:        channel,
+		Dimensions:     eventDimensions(ctx, websiteID, payload.Dimensions),
+		PageID:         pageID,
+		Seq:            seq,
+		SpanContext:    trace.SpanContextFromContext(ctx),
+		Browser:        session.Browser,
+		OS:             session.OS,
+		Device:         session.Device,
+		Country:        session.Country,
+		Region:         session.Region,
+		City:           session.City,
+		ASN:            session.ASN,
+		ISP:            session.ISP,
+		Screen:         session.Screen,
+	}
+
+	// Hand off to the batch writer; fall back to a direct INSERT when the
+	// buffer is full so a spike slows requests down instead of dropping events
+	if queue := ingest.Current(); queue != nil {
+		err := queue.Enqueue(event)
+		if err == nil {
+			return nil
+		}
+		logging.L().Debug("ingest queue unavailable, writing event directly", zap.Error(err))
+	}
 
+	spanCtx, dbSpan := storeSpan(ctx, "insert_event")
+	err := store.Current().InsertEvent(spanCtx, event)
+	tracing.End(dbSpan, err)
 	if err != nil {
 		logging.L().Error("failed to insert event", zap.Error(err))
 	}
@@ -401,6 +769,102 @@ func saveEvent(websiteID, sessionID, visitID uuid.UUID, createdAt time.Time,
 	return err
 }
 
+// storeSpan starts a span around a store call on the ingestion path
+func storeSpan(ctx context.Context, op string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "store."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system", store.Current().Name())))
+}
+
+// visitorSessionID derives the session of a visitor: the same IP and user
+// agent map to the same session for a calendar month. In hash mode (ip_mode,
+// or a Do-Not-Track visitor being anonymized) they are hashed with the day's
+// salt instead, so sessions end at midnight UTC; in truncate mode only the
+// IP's network is used.
+func visitorSessionID(ctx context.Context, websiteID uuid.UUID, ip, userAgent string, at time.Time) (uuid.UUID, error) {
+	switch privacy.ModeOf(ctx) {
+	case privacy.Hash:
+		salt, err := privacy.Salt(ctx, store.Current(), time.Now())
+		if err != nil {
+			return uuid.Nil, err
+		}
+		return generateUUID(privacy.HashVisitor(salt, websiteID.String(), ip, userAgent)), nil
+	case privacy.Truncate:
+		ip = privacy.TruncateIP(ip)
+	}
+	return generateUUID(websiteID.String(), ip, userAgent, hashDate(at, "month")), nil
+}
+
+// utmParams holds the campaign parameters extracted from a page URL
+type utmParams struct {
+	Source   *string
+	Medium   *string
+	Campaign *string
+	Content  *string
+	Term     *string
+}
+
+// dropSelfUTM removes the utm_* parameters of a page URL tagged with one of
+// the website's own domains as utm_source (internal banners and links), so
+// they don't end the visit's campaign attribution
+func dropSelfUTM(rawURL string, settings *store.WebsiteSettings) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.RawQuery == "" {
+		return rawURL, false
+	}
+	query := u.Query()
+	if !settings.SelfReferral(query.Get("utm_source")) {
+		return rawURL, false
+	}
+	for _, key := range []string{"utm_source", "utm_medium", "utm_campaign", "utm_content", "utm_term"} {
+		query.Del(key)
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), true
+}
+
+// maxUTMLength matches the VARCHAR(255) utm_* columns
+const maxUTMLength = 255
+
+// parseUTMParams extracts utm_* values from a parsed query string.
+// Empty values are stored as NULL so they don't show up as a blank campaign.
+func parseUTMParams(values url.Values) utmParams {
+	get := func(key string) *string {
+		v := strings.TrimSpace(values.Get(key))
+		if v == "" {
+			return nil
+		}
+		if runes := []rune(v); len(runes) > maxUTMLength {
+			v = string(runes[:maxUTMLength])
+		}
+		return &v
+	}
+
+	return utmParams{
+		Source:   get("utm_source"),
+		Medium:   get("utm_medium"),
+		Campaign: get("utm_campaign"),
+		Content:  get("utm_content"),
+		Term:     get("utm_term"),
+	}
+}
+
+// maxAuthorLength matches the VARCHAR(100) author column
+const maxAuthorLength = 100
+
+// truncatedAuthor trims a byline to maxAuthorLength, returning nil for an
+// empty one
+func truncatedAuthor(author string) *string {
+	author = strings.TrimSpace(author)
+	if author == "" {
+		return nil
+	}
+	if runes := []rune(author); len(runes) > maxAuthorLength {
+		author = strings.TrimSpace(string(runes[:maxAuthorLength]))
+	}
+	return &author
+}
+
 // generateUUID creates a deterministic UUID from components
 func generateUUID(parts ...string) uuid.UUID {
 	combined := strings.Join(parts, "|")
@@ -452,55 +916,16 @@ func isSpamReferrer(referrer string) bool {
 	return false
 }
 
-// parseUserAgent extracts browser, OS, device from UA string
-func parseUserAgent(ua string) (browser, os, device *string) {
-	// Simple parsing (TODO: use proper UA parser library)
-	ua = strings.ToLower(ua)
-
-	// Browser
-	var b string
-	switch {
-	case strings.Contains(ua, "edg"):
-		b = "Edge"
-	case strings.Contains(ua, "chrome"):
-		b = "Chrome"
-	case strings.Contains(ua, "firefox"):
-		b = "Firefox"
-	case strings.Contains(ua, "safari"):
-		b = "Safari"
-	default:
-		b = "Unknown"
-	}
-	browser = &b
-
-	// OS
-	var o string
-	switch {
-	case strings.Contains(ua, "android"):
-		o = "Android"
-	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad") || strings.Contains(ua, "ios"):
-		o = "iOS"
-	case strings.Contains(ua, "windows"):
-		o = "Windows"
-	case strings.Contains(ua, "mac os x") || strings.Contains(ua, "macintosh"):
-		o = "macOS"
-	case strings.Contains(ua, "linux"):
-		o = "Linux"
-	default:
-		o = "Unknown"
-	}
-	os = &o
-
-	// Device
-	var d string
-	if strings.Contains(ua, "mobile") || strings.Contains(ua, "iphone") || strings.Contains(ua, "android") || strings.Contains(ua, "ipad") {
-		d = "mobile"
-	} else {
-		d = "desktop"
-	}
-	device = &d
+// ParseUserAgent extracts browser, OS, device from UA string
+func ParseUserAgent(ua string) (browser, os, device *string) {
+	return parseClient(ua, useragent.Hints{})
+}
 
-	return
+// parseClient extracts browser, OS, device from a UA string and the
+// request's client hints
+func parseClient(ua string, hints useragent.Hints) (browser, os, device *string) {
+	client := useragent.Parse(ua, hints)
+	return &client.Browser, &client.OS, &client.Device
 }
 
 // geoIPLookup performs country/city/region lookup for an IP address
@@ -509,6 +934,45 @@ func geoIPLookup(ip string) (country, city, region string) {
 	return
 }
 
+// maxISPLength matches the VARCHAR(255) session.isp column
+const maxISPLength = 255
+
+// asnLookup returns the autonomous system and ISP of an IP, nil when unknown
+func asnLookup(ip string) (asn *int, isp *string) {
+	number, org := geoip.LookupASN(ip)
+	if number == 0 {
+		return nil, nil
+	}
+	n := int(number)
+	if org = strings.TrimSpace(org); org == "" {
+		return &n, nil
+	}
+	if runes := []rune(org); len(runes) > maxISPLength {
+		org = string(runes[:maxISPLength])
+	}
+	return &n, &org
+}
+
+// captureRequest keeps a tracking request of a website being debugged: its
+// URL and headers without credentials, its body and the answer track gave.
+// IPs are kept only in the form ip_mode (or Do-Not-Track) allows for the
+// request.
+func captureRequest(ctx context.Context, c fiber.Ctx, websiteID uuid.UUID, proxyMode string) {
+	headers := make(map[string]string)
+	for key, values := range c.GetReqHeaders() {
+		headers[key] = strings.Join(values, ", ")
+	}
+	debugcapture.Current().Record(websiteID, debugcapture.Request{
+		Method:   c.Method(),
+		URL:      debugcapture.RedactURL(c.OriginalURL()),
+		IP:       privacy.StoredIP(ctx, getClientIP(c, proxyMode)),
+		Headers:  debugcapture.Redact(headers, privacy.ModeOf(ctx) == privacy.Full),
+		Body:     string(c.Body()),
+		Status:   c.Response().StatusCode(),
+		Response: string(c.Response().Body()),
+	})
+}
+
 // getClientIP extracts client IP based on proxy_mode configuration
 // Supports:
 // - "none": direct connection IP (default)
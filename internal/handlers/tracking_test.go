@@ -1,8 +1,25 @@
 package handlers
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/dedup"
+	"github.com/seuros/kaunta/internal/ingest"
+	"github.com/seuros/kaunta/internal/privacy"
+	"github.com/seuros/kaunta/internal/store"
+	"github.com/seuros/kaunta/internal/visits"
 )
 
 // TestGetClientIPLogic tests the IP extraction logic without Fiber dependency
@@ -112,3 +129,127 @@ func TestProxyModeValues(t *testing.T) {
 		})
 	}
 }
+
+func TestParseUTMParams(t *testing.T) {
+	u, err := url.Parse("https://example.com/landing?utm_source=newsletter&utm_medium=email&utm_campaign=spring+sale&utm_term=")
+	if err != nil {
+		t.Fatalf("parse url: %v", err)
+	}
+
+	utm := parseUTMParams(u.Query())
+
+	if utm.Source == nil || *utm.Source != "newsletter" {
+		t.Errorf("expected source newsletter, got %v", utm.Source)
+	}
+	if utm.Medium == nil || *utm.Medium != "email" {
+		t.Errorf("expected medium email, got %v", utm.Medium)
+	}
+	if utm.Campaign == nil || *utm.Campaign != "spring sale" {
+		t.Errorf("expected campaign 'spring sale', got %v", utm.Campaign)
+	}
+	if utm.Content != nil {
+		t.Errorf("expected nil content, got %q", *utm.Content)
+	}
+	if utm.Term != nil {
+		t.Errorf("expected empty term to be nil, got %q", *utm.Term)
+	}
+
+	long := url.Values{"utm_campaign": {strings.Repeat("é", maxUTMLength+10)}}
+	utm = parseUTMParams(long)
+	if utm.Campaign == nil || len([]rune(*utm.Campaign)) != maxUTMLength {
+		t.Errorf("expected campaign truncated to %d runes", maxUTMLength)
+	}
+}
+
+func TestDropSelfUTM(t *testing.T) {
+	settings := &store.WebsiteSettings{Domain: "example.com"}
+
+	got, ok := dropSelfUTM("https://example.com/pricing?plan=pro&utm_source=www.example.com&utm_medium=banner", settings)
+	assert.True(t, ok)
+	assert.Equal(t, "https://example.com/pricing?plan=pro", got)
+
+	_, ok = dropSelfUTM("https://example.com/?utm_source=newsletter", settings)
+	assert.False(t, ok)
+
+	settings.KeepSelfReferrals = true
+	_, ok = dropSelfUTM("https://example.com/?utm_source=example.com", settings)
+	assert.False(t, ok)
+}
+
+func TestTruncatedAuthor(t *testing.T) {
+	if a := truncatedAuthor("  Jane Doe "); a == nil || *a != "Jane Doe" {
+		t.Errorf("expected trimmed author, got %v", a)
+	}
+	if a := truncatedAuthor("   "); a != nil {
+		t.Errorf("expected blank author to be nil, got %q", *a)
+	}
+	if a := truncatedAuthor(strings.Repeat("é", maxAuthorLength+5)); a == nil || len([]rune(*a)) != maxAuthorLength {
+		t.Errorf("expected author truncated to %d runes", maxAuthorLength)
+	}
+}
+
+func TestHourlyVisitsKeepTheirIDs(t *testing.T) {
+	// Events stored before visit rules were configurable belong to the same
+	// visits as the ones sent after
+	session := uuid.New()
+	at := time.Date(2025, 6, 1, 12, 30, 0, 0, time.UTC)
+	assert.Equal(t, generateUUID(session.String(), hashDate(at, "hour")),
+		visits.Current().Visit(session, at, visits.Rules{Definition: visits.Hour}, uuid.Nil))
+}
+
+func TestVisitorSessionIDFollowsIPMode(t *testing.T) {
+	t.Cleanup(func() { privacy.SetMode(privacy.Full) })
+
+	websiteID := uuid.New()
+	at := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	session := func(ip string) uuid.UUID {
+		id, err := visitorSessionID(context.Background(), websiteID, ip, "test-agent", at)
+		require.NoError(t, err)
+		return id
+	}
+
+	privacy.SetMode(privacy.Full)
+	assert.NotEqual(t, session("203.0.113.7"), session("203.0.113.8"))
+
+	// Visitors of the same /24 share a session once IPs are truncated
+	privacy.SetMode(privacy.Truncate)
+	assert.Equal(t, session("203.0.113.7"), session("203.0.113.8"))
+	assert.NotEqual(t, session("203.0.113.7"), session("198.51.100.7"))
+}
+
+func TestDeferredEventIsStoredWhenResent(t *testing.T) {
+	st := useIngestStore(t)
+	nearlyFullBuffer(t)
+	dedup.Configure(dedup.DefaultWindow, nil)
+	t.Cleanup(func() { dedup.Configure(0, nil) })
+	app := fiber.New()
+	app.Post("/api/send", HandleTracking)
+
+	body := fmt.Sprintf(`{"type":"event","payload":{"website":%q,"url":"/pricing","name":"signup"}}`, uuid.New())
+	send := func() *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/api/send", strings.NewReader(body))
+		req.Header.Set("Accept-Language", "en")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp
+	}
+
+	resp := send()
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Equal(t, "1", resp.Header.Get("Retry-After"))
+	require.Empty(t, st.events)
+
+	// The backlog drained: the tracker's resend, within the dedup window,
+	// is stored
+	ingest.SetCurrent(nil)
+	resp = send()
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Retry-After"))
+	require.Len(t, st.events, 1)
+	assert.Equal(t, "signup", *st.events[0].EventName)
+
+	// A third copy is a duplicate
+	send()
+	assert.Len(t, st.events, 1)
+}
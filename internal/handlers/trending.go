@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/stats"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// HandleTrending returns the pages and referrers gaining and losing the most
+// pageviews over the last ?days= (default 7, at most 90), compared with the
+// days before. ?sort=percent ranks them by relative change instead of
+// pageviews, ?min= leaves out values with fewer pageviews in both periods
+// (default 10) and ?limit= sets how many to return (default 10, at most 50).
+// GET /api/dashboard/trending/:website_id
+func HandleTrending(c fiber.Ctx) error {
+	websiteID, err := uuid.Parse(c.Params("website_id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid website ID"})
+	}
+	if database.DB == nil || store.Current().Name() != "postgres" {
+		return c.Status(501).JSON(fiber.Map{"error": "Trending requires PostgreSQL"})
+	}
+
+	rank, err := stats.ParseRanking(c.Query("sort"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	days := min(max(fiber.Query[int](c, "days", 7), 1), 90)
+	limit := min(max(fiber.Query[int](c, "limit", 10), 1), 50)
+	minPageviews := max(fiber.Query[int64](c, "min", 10), 0)
+
+	trends, err := stats.GetTrends(c.Context(), database.DB, websiteID, days, minPageviews, rank, limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query trending"})
+	}
+	return c.JSON(trends)
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/stats"
+)
+
+func TestHandleTrending(t *testing.T) {
+	websiteID := uuid.New()
+	counts := func(rows ...[]interface{}) mockResponse {
+		return mockResponse{match: "e.created_at >= $2 AND e.created_at < $3", columns: []string{"name", "pageviews"}, rows: rows}
+	}
+	responses := []mockResponse{
+		// Pages, previous then current period
+		counts([]interface{}{"/", int64(100)}, []interface{}{"/old", int64(40)}, []interface{}{"/rare", int64(2)}),
+		counts([]interface{}{"/", int64(150)}, []interface{}{"/new", int64(30)}, []interface{}{"/rare", int64(6)}),
+		// Referrers
+		counts([]interface{}{"google.com", int64(50)}),
+		counts([]interface{}{"google.com", int64(25)}),
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/dashboard/trending/:website_id", HandleTrending, responses)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/dashboard/trending/"+websiteID.String()+"?days=7", nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var trends stats.Trends
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&trends))
+	assert.Equal(t, 7, trends.Days)
+	require.Len(t, trends.Pages.Gainers, 2)
+	assert.Equal(t, "/", trends.Pages.Gainers[0].Name)
+	assert.Equal(t, 50.0, *trends.Pages.Gainers[0].ChangePercent)
+	assert.Equal(t, "/new", trends.Pages.Gainers[1].Name)
+	assert.Nil(t, trends.Pages.Gainers[1].ChangePercent)
+	require.Len(t, trends.Pages.Losers, 1)
+	assert.Equal(t, "/old", trends.Pages.Losers[0].Name)
+	require.Len(t, trends.Referrers.Losers, 1)
+	assert.Equal(t, int64(-25), trends.Referrers.Losers[0].Change)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleTrendingInvalidSort(t *testing.T) {
+	app, _, cleanup := setupFiberTest(t, "/api/dashboard/trending/:website_id", HandleTrending, nil)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/dashboard/trending/"+uuid.NewString()+"?sort=ratio", nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -17,8 +17,11 @@ type DashboardStats struct {
 
 // TopPage represents a page with stats
 type TopPage struct {
-	Path  string `json:"path"`
-	Views int    `json:"views"`
+	Path           string   `json:"path"`
+	Views          int      `json:"views"`
+	UniqueVisitors int      `json:"unique_visitors"`
+	AvgEngagement  *float64 `json:"avg_engagement"` // seconds; null when unknown
+	BounceRate     *float64 `json:"bounce_rate"`    // percent; null from rollups
 }
 
 // TimeSeriesPoint represents a data point in time series
@@ -33,6 +36,12 @@ type BreakdownItem struct {
 	Count int    `json:"count"`
 }
 
+// DimensionValue is a value seen for a dimension, with its pageviews
+type DimensionValue struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
 // MapDataPoint represents a country on the choropleth map
 type MapDataPoint struct {
 	Country     string  `json:"country"`      // ISO 3166-1 alpha-2 (e.g., "US")
@@ -48,3 +57,12 @@ type MapResponse struct {
 	TotalVisitors int            `json:"total_visitors"`
 	PeriodDays    int            `json:"period_days"`
 }
+
+// UTMItem represents campaign metrics for a single UTM parameter value
+type UTMItem struct {
+	Name           string  `json:"name"`
+	Visitors       int     `json:"visitors"`
+	Pageviews      int     `json:"pageviews"`
+	Conversions    int     `json:"conversions"`
+	ConversionRate float64 `json:"conversion_rate"`
+}
@@ -106,6 +106,8 @@ func TestDashboardStats_JSONMarshaling(t *testing.T) {
 	}
 }
 
+func floatPtr(f float64) *float64 { return &f }
+
 func TestTopPage_JSONMarshaling(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -115,10 +117,13 @@ func TestTopPage_JSONMarshaling(t *testing.T) {
 		{
 			name: "Homepage",
 			page: TopPage{
-				Path:  "/",
-				Views: 1000,
+				Path:           "/",
+				Views:          1000,
+				UniqueVisitors: 700,
+				AvgEngagement:  floatPtr(42),
+				BounceRate:     floatPtr(55.5),
 			},
-			expected: `{"path":"/","views":1000}`,
+			expected: `{"path":"/","views":1000,"unique_visitors":700,"avg_engagement":42,"bounce_rate":55.5}`,
 		},
 		{
 			name: "Deep path",
@@ -126,7 +131,7 @@ func TestTopPage_JSONMarshaling(t *testing.T) {
 				Path:  "/blog/posts/2024/my-article",
 				Views: 42,
 			},
-			expected: `{"path":"/blog/posts/2024/my-article","views":42}`,
+			expected: `{"path":"/blog/posts/2024/my-article","views":42,"unique_visitors":0,"avg_engagement":null,"bounce_rate":null}`,
 		},
 		{
 			name: "Path with query params",
@@ -134,7 +139,7 @@ func TestTopPage_JSONMarshaling(t *testing.T) {
 				Path:  "/search?q=test",
 				Views: 15,
 			},
-			expected: `{"path":"/search?q=test","views":15}`,
+			expected: `{"path":"/search?q=test","views":15,"unique_visitors":0,"avg_engagement":null,"bounce_rate":null}`,
 		},
 	}
 
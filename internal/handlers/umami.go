@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/logging"
+	"github.com/seuros/kaunta/internal/stats"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// UmamiCacheHeader carries the cache token /api/send answered with back to
+// it, as umami.js does
+const UmamiCacheHeader = "X-Umami-Cache"
+
+// umamiVisitTimeout is how long a visit lasts without events in Umami; link
+// tokens (see crossdomain.go) older than it are ignored
+const umamiVisitTimeout = 30 * time.Minute
+
+// umamiCache is what the cache token of /api/send holds: the visit the
+// page's events belong to, and when (by the server's clock) the latest of
+// them was received
+type umamiCache struct {
+	WebsiteID uuid.UUID `json:"websiteId"`
+	SessionID uuid.UUID `json:"sessionId"`
+	VisitID   uuid.UUID `json:"visitId"`
+	IssuedAt  int64     `json:"iat"`
+}
+
+// umamiCacheSecret names the server secret signing cache tokens: every
+// server process shares it, so tokens stay valid across restarts and
+// replicas
+const umamiCacheSecret = "umami_cache"
+
+var (
+	umamiCacheKeyMu sync.Mutex
+	umamiCacheKey   []byte
+)
+
+// cacheKey returns the key signing cache tokens, read from the store once;
+// nil while it can't be read
+func cacheKey() []byte {
+	umamiCacheKeyMu.Lock()
+	defer umamiCacheKeyMu.Unlock()
+	if umamiCacheKey == nil {
+		key, err := store.Current().ServerSecret(context.Background(), umamiCacheSecret)
+		if err != nil {
+			logging.L().Warn("failed to load the cache token key", zap.Error(err))
+			return nil
+		}
+		umamiCacheKey = key
+	}
+	return umamiCacheKey
+}
+
+func umamiCacheSignature(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// encode signs the cache as payload.signature; empty without a key
+func (u umamiCache) encode() string {
+	key := cacheKey()
+	if key == nil {
+		return ""
+	}
+	data, _ := json.Marshal(u)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + umamiCacheSignature(key, payload)
+}
+
+// decodeUmamiCache reads a cache token; false for anything not signed here
+func decodeUmamiCache(token string) (umamiCache, bool) {
+	var u umamiCache
+	key := cacheKey()
+	payload, signature, ok := strings.Cut(token, ".")
+	if key == nil || !ok || !hmac.Equal([]byte(signature), []byte(umamiCacheSignature(key, payload))) {
+		return u, false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || json.Unmarshal(data, &u) != nil {
+		return u, false
+	}
+	return u, true
+}
+
+// cachedVisit returns the visit of the request's cache token when it is of
+// the same website and session and the visit hasn't gone timeout without
+// events; uuid.Nil otherwise. Idle time is measured on the server's clock:
+// the timestamps of payloads come from the client.
+func cachedVisit(c fiber.Ctx, websiteID, sessionID uuid.UUID, timeout time.Duration) uuid.UUID {
+	token := c.Get(UmamiCacheHeader)
+	if token == "" {
+		return uuid.Nil
+	}
+	cache, ok := decodeUmamiCache(token)
+	if !ok || cache.WebsiteID != websiteID || cache.SessionID != sessionID {
+		return uuid.Nil
+	}
+	if time.Since(time.Unix(cache.IssuedAt, 0)) > timeout {
+		return uuid.Nil
+	}
+	return cache.VisitID
+}
+
+// The routes below mimic Umami's website API (v2), so scripts and
+// integrations written for it read Kaunta's data. Periods are ?startAt= and
+// ?endAt= in milliseconds since the epoch; days and buckets follow the
+// website's time zone rather than ?timezone=. The stats, pageviews and
+// metrics routes require PostgreSQL.
+
+// umamiWebsite is a website in Umami's shape
+type umamiWebsite struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Domain string `json:"domain"`
+}
+
+// umamiPoint is an entry of Umami's series and metrics
+type umamiPoint struct {
+	X string `json:"x"`
+	Y int64  `json:"y"`
+}
+
+// umamiUnits maps Umami's units to time series intervals
+var umamiUnits = map[string]store.Interval{
+	"minute": store.IntervalMinute,
+	"hour":   store.IntervalHour,
+	"day":    store.IntervalDay,
+	"month":  store.IntervalMonth,
+}
+
+// umamiMetricTypes maps Umami's metric types to dimensions; those of events
+// count pageviews, the others visitors
+var umamiMetricTypes = map[string]string{
+	"url":      "page",
+	"referrer": "referrer",
+	"browser":  "browser",
+	"os":       "os",
+	"device":   "device",
+	"country":  "country",
+	"region":   "region",
+	"city":     "city",
+}
+
+// umamiWebsiteID reads :website_id, answering the request itself when it is
+// invalid or stats need PostgreSQL and don't have it
+func umamiWebsiteID(c fiber.Ctx, postgres bool) (uuid.UUID, error) {
+	if postgres && (database.DB == nil || store.Current().Name() != "postgres") {
+		return uuid.Nil, c.Status(501).JSON(fiber.Map{"error": "Umami's stats API requires PostgreSQL"})
+	}
+	websiteID, err := uuid.Parse(c.Params("website_id"))
+	if err != nil {
+		return uuid.Nil, c.Status(400).JSON(fiber.Map{"error": "Invalid website ID"})
+	}
+	return websiteID, nil
+}
+
+// umamiPeriod reads ?startAt= and ?endAt=, both included
+func umamiPeriod(c fiber.Ctx) (stats.Period, error) {
+	startAt, err := strconv.ParseInt(c.Query("startAt"), 10, 64)
+	if err != nil {
+		return stats.Period{}, errors.New("startAt is required (milliseconds since the epoch)")
+	}
+	endAt, err := strconv.ParseInt(c.Query("endAt"), 10, 64)
+	if err != nil {
+		return stats.Period{}, errors.New("endAt is required (milliseconds since the epoch)")
+	}
+	p := stats.Period{From: time.UnixMilli(startAt), To: time.UnixMilli(endAt + 1)}
+	if !p.From.Before(p.To) {
+		return stats.Period{}, errors.New("startAt must be before endAt")
+	}
+	if p.To.Sub(p.From) > stats.MaxRangeDays*24*time.Hour {
+		return stats.Period{}, errors.New("the period is longer than 366 days")
+	}
+	p.Label = p.From.UTC().Format(time.DateTime) + ".." + p.To.UTC().Format(time.DateTime)
+	return p, nil
+}
+
+// umamiFilters reads Umami's ?url=, ?country=, ?browser= and ?device=
+func umamiFilters(c fiber.Ctx) store.Filters {
+	return store.Filters{
+		Page:    c.Query("url"),
+		Country: c.Query("country"),
+		Browser: c.Query("browser"),
+		Device:  c.Query("device"),
+	}
+}
+
+// HandleUmamiWebsite returns a website in Umami's shape
+// GET /api/websites/:website_id
+func HandleUmamiWebsite(c fiber.Ctx) error {
+	websiteID, err := umamiWebsiteID(c, false)
+	if websiteID == uuid.Nil {
+		return err
+	}
+	website, err := store.Current().FindWebsite(c.Context(), websiteID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(404).JSON(fiber.Map{"error": "Website not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load website"})
+	}
+	name := website.Domain
+	if website.Name != nil {
+		name = *website.Name
+	}
+	return c.JSON(umamiWebsite{ID: website.WebsiteID, Name: name, Domain: website.Domain})
+}
+
+// HandleUmamiActive returns the visitors of the last 5 minutes, under the
+// keys of current (visitors) and older (x) Umami versions
+// GET /api/websites/:website_id/active
+func HandleUmamiActive(c fiber.Ctx) error {
+	websiteID, err := umamiWebsiteID(c, false)
+	if websiteID == uuid.Nil {
+		return err
+	}
+	visitors, err := store.Current().CurrentVisitors(c.Context(), websiteID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query current visitors"})
+	}
+	return c.JSON(fiber.Map{"visitors": visitors, "x": visitors})
+}
+
+// HandleUmamiStats returns the pageviews, visitors, visits, bounces and
+// total time of the period, each with its value in the period of the same
+// length before
+// GET /api/websites/:website_id/stats?startAt=&endAt=
+func HandleUmamiStats(c fiber.Ctx) error {
+	websiteID, err := umamiWebsiteID(c, true)
+	if websiteID == uuid.Nil {
+		return err
+	}
+	p, err := umamiPeriod(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	f := umamiFilters(c)
+
+	current, err := stats.GetVisitTotals(c.Context(), database.DB, websiteID, p, f)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query stats"})
+	}
+	before := stats.Period{From: p.From.Add(-p.To.Sub(p.From)), To: p.From}
+	previous, err := stats.GetVisitTotals(c.Context(), database.DB, websiteID, before, f)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query stats"})
+	}
+
+	value := func(now, prev int64) fiber.Map { return fiber.Map{"value": now, "prev": prev} }
+	return c.JSON(fiber.Map{
+		"pageviews": value(current.Pageviews, previous.Pageviews),
+		"visitors":  value(current.Visitors, previous.Visitors),
+		"visits":    value(current.Visits, previous.Visits),
+		"bounces":   value(current.Bounces, previous.Bounces),
+		"totaltime": value(current.TotalTime, previous.TotalTime),
+	})
+}
+
+// HandleUmamiPageviews returns the pageviews and sessions of the period by
+// ?unit= (minute, hour, day or month; default day)
+// GET /api/websites/:website_id/pageviews?startAt=&endAt=&unit=
+func HandleUmamiPageviews(c fiber.Ctx) error {
+	websiteID, err := umamiWebsiteID(c, true)
+	if websiteID == uuid.Nil {
+		return err
+	}
+	p, err := umamiPeriod(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	unit := c.Query("unit", "day")
+	interval, ok := umamiUnits[unit]
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid unit: " + unit + " (use minute, hour, day or month)"})
+	}
+	if p.To.Sub(p.From) > time.Duration(interval.MaxDays())*24*time.Hour {
+		return c.Status(400).JSON(fiber.Map{"error": "The period is too long for unit " + unit})
+	}
+
+	now := time.Now()
+	points, err := stats.GetSeries(c.Context(), database.DB, websiteID, p.Days(now), interval, p.Narrow(umamiFilters(c)))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query pageviews"})
+	}
+	pageviews := make([]umamiPoint, 0, len(points))
+	sessions := make([]umamiPoint, 0, len(points))
+	for _, point := range points {
+		x := point.Bucket.Format(time.DateTime)
+		pageviews = append(pageviews, umamiPoint{X: x, Y: point.Pageviews})
+		sessions = append(sessions, umamiPoint{X: x, Y: point.Visitors})
+	}
+	return c.JSON(fiber.Map{"pageviews": pageviews, "sessions": sessions})
+}
+
+// HandleUmamiMetrics returns the period's top values of ?type= (url,
+// referrer, browser, os, device, country, region or city): pageviews for
+// url and referrer, visitors otherwise. Direct traffic is not a referrer.
+// GET /api/websites/:website_id/metrics?startAt=&endAt=&type=&limit=
+func HandleUmamiMetrics(c fiber.Ctx) error {
+	websiteID, err := umamiWebsiteID(c, true)
+	if websiteID == uuid.Nil {
+		return err
+	}
+	p, err := umamiPeriod(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	metric := c.Query("type")
+	name, ok := umamiMetricTypes[metric]
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid type: " + metric})
+	}
+	d := stats.ByPage
+	if name != "page" {
+		d, _ = stats.Lookup(name)
+	}
+	limit := min(max(fiber.Query[int](c, "limit", 500), 1), 1000)
+
+	breakdown, err := stats.GetBreakdown(c.Context(), database.DB, websiteID, d, p.Days(time.Now()), limit, p.Narrow(umamiFilters(c)))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query metrics"})
+	}
+	points := make([]umamiPoint, 0, len(breakdown.Items))
+	for _, item := range breakdown.Items {
+		switch {
+		case metric == "referrer" && item.Name == d.Unknown:
+			continue
+		case metric == "url" || metric == "referrer":
+			points = append(points, umamiPoint{X: item.Name, Y: item.Pageviews})
+		default:
+			points = append(points, umamiPoint{X: item.Name, Y: item.Visitors})
+		}
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Y > points[j].Y })
+	return c.JSON(points)
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useCacheKey signs cache tokens with a fixed key
+func useCacheKey(t *testing.T) {
+	t.Helper()
+	umamiCacheKeyMu.Lock()
+	umamiCacheKey = []byte("test cache token key")
+	umamiCacheKeyMu.Unlock()
+	t.Cleanup(forgetCacheKey)
+}
+
+// forgetCacheKey makes the next token read the key from the store again, as
+// after a restart
+func forgetCacheKey() {
+	umamiCacheKeyMu.Lock()
+	umamiCacheKey = nil
+	umamiCacheKeyMu.Unlock()
+}
+
+func TestCachedVisit(t *testing.T) {
+	useCacheKey(t)
+	websiteID, sessionID, visitID := uuid.New(), uuid.New(), uuid.New()
+	now := time.Now()
+	cache := umamiCache{WebsiteID: websiteID, SessionID: sessionID, VisitID: visitID, IssuedAt: now.Add(-10 * time.Minute).Unix()}
+
+	tests := []struct {
+		name      string
+		token     string
+		sessionID uuid.UUID
+		want      uuid.UUID
+	}{
+		{"fresh", cache.encode(), sessionID, visitID},
+		{"no token", "", sessionID, uuid.Nil},
+		{"other session", cache.encode(), uuid.New(), uuid.Nil},
+		{"tampered", cache.encode() + "x", sessionID, uuid.Nil},
+		{"timed out", umamiCache{WebsiteID: websiteID, SessionID: sessionID, VisitID: visitID,
+			IssuedAt: now.Add(-31 * time.Minute).Unix()}.encode(), sessionID, uuid.Nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			var got uuid.UUID
+			app.Get("/", func(c fiber.Ctx) error {
+				got = cachedVisit(c, websiteID, tt.sessionID, umamiVisitTimeout)
+				return nil
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(UmamiCacheHeader, tt.token)
+			_, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCacheTokensOutliveRestarts(t *testing.T) {
+	useIngestStore(t)
+	forgetCacheKey()
+	t.Cleanup(forgetCacheKey)
+	cache := umamiCache{WebsiteID: uuid.New(), SessionID: uuid.New(), VisitID: uuid.New(), IssuedAt: time.Now().Unix()}
+	token := cache.encode()
+	require.NotEmpty(t, token)
+
+	// Another process (or this one restarted) reads the same key
+	forgetCacheKey()
+	decoded, ok := decodeUmamiCache(token)
+	require.True(t, ok)
+	assert.Equal(t, cache, decoded)
+}
+
+func TestCachedVisitIgnoresClientTimestamps(t *testing.T) {
+	useIngestStore(t)
+	useCacheKey(t)
+	app := fiber.New()
+	app.Post("/api/send", HandleTracking)
+	websiteID := uuid.New()
+
+	send := func(body, cache string) map[string]string {
+		req := httptest.NewRequest(http.MethodPost, "/api/send", strings.NewReader(body))
+		req.Header.Set("Accept-Language", "en")
+		req.Header.Set(UmamiCacheHeader, cache)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+		require.Equal(t, http.StatusAccepted, resp.StatusCode)
+		var out map[string]string
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		return out
+	}
+
+	first := send(fmt.Sprintf(`{"type":"event","payload":{"website":%q,"url":"/"}}`, websiteID), "")
+	require.NotEmpty(t, first["cache"])
+
+	// A client clock an hour behind neither ends the visit nor stretches it
+	stale := time.Now().Add(-time.Hour).Unix()
+	second := send(fmt.Sprintf(`{"type":"event","payload":{"website":%q,"url":"/pricing","timestamp":%d}}`, websiteID, stale), first["cache"])
+	assert.Equal(t, first["visitId"], second["visitId"])
+
+	cache, ok := decodeUmamiCache(second["cache"])
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now(), time.Unix(cache.IssuedAt, 0), 2*time.Second)
+}
+
+func TestHandleUmamiStats(t *testing.T) {
+	websiteID := uuid.New()
+	from := time.Date(2025, 6, 8, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+	columns := []string{"pageviews", "visitors", "visits", "bounces", "totaltime"}
+	responses := []mockResponse{
+		{
+			match:   "GROUP BY e.session_id, e.visit_id",
+			args:    []interface{}{websiteID, from, to},
+			columns: columns,
+			rows:    [][]interface{}{{int64(300), int64(100), int64(120), int64(50), int64(9000)}},
+		},
+		{
+			match:   "GROUP BY e.session_id, e.visit_id",
+			args:    []interface{}{websiteID, from.AddDate(0, 0, -7), from},
+			columns: columns,
+			rows:    [][]interface{}{{int64(200), int64(80), int64(90), int64(40), int64(6000)}},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/websites/:website_id/stats", HandleUmamiStats, responses)
+	defer cleanup()
+
+	url := "/api/websites/" + websiteID.String() + "/stats?startAt=" +
+		strconv.FormatInt(from.UnixMilli(), 10) + "&endAt=" + strconv.FormatInt(to.UnixMilli()-1, 10)
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, url, nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var body map[string]struct {
+		Value int64 `json:"value"`
+		Prev  int64 `json:"prev"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, int64(300), body["pageviews"].Value)
+	assert.Equal(t, int64(200), body["pageviews"].Prev)
+	assert.Equal(t, int64(120), body["visits"].Value)
+	assert.Equal(t, int64(9000), body["totaltime"].Value)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleUmamiMetricsReferrers(t *testing.T) {
+	websiteID := uuid.New()
+	responses := []mockResponse{
+		{
+			match:   "GROUP BY name",
+			columns: []string{"name", "visitors", "pageviews", "bounce_rate"},
+			rows: [][]interface{}{
+				{"Direct / None", int64(90), int64(100), 50.0},
+				{"google.com", int64(40), int64(60), 30.0},
+				{"github.com", int64(35), int64(80), 20.0},
+			},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/websites/:website_id/metrics", HandleUmamiMetrics, responses)
+	defer cleanup()
+
+	url := "/api/websites/" + websiteID.String() + "/metrics?type=referrer&startAt=1749340800000&endAt=1749945599999"
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, url, nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var points []umamiPoint
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&points))
+	assert.Equal(t, []umamiPoint{{X: "github.com", Y: 80}, {X: "google.com", Y: 60}}, points)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleUmamiStatsNeedsPeriod(t *testing.T) {
+	app, _, cleanup := setupFiberTest(t, "/api/websites/:website_id/stats", HandleUmamiStats, nil)
+	defer cleanup()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/websites/"+uuid.NewString()+"/stats", nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
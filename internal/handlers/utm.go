@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/seuros/kaunta/internal/eventprops"
+	"github.com/seuros/kaunta/internal/fieldcrypt"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// validUTMDimensions lists the utm_* parameters that can be broken down
+var validUTMDimensions = map[string]bool{
+	"source":   true,
+	"medium":   true,
+	"campaign": true,
+	"content":  true,
+	"term":     true,
+}
+
+// HandleUTMBreakdown returns campaign metrics grouped by a UTM parameter
+// Query params: by (source|medium|campaign|content|term, default campaign),
+// days (1-365, default 7), goal (optional custom event name counted as conversion),
+// prop.<key>=value (optional property values the goal event must be sent with)
+func HandleUTMBreakdown(c fiber.Ctx) error {
+	websiteIDStr := c.Params("website_id")
+	websiteID, err := uuid.Parse(websiteIDStr)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid website ID"})
+	}
+
+	dimension := c.Query("by", "campaign")
+	if !validUTMDimensions[dimension] {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid UTM dimension"})
+	}
+
+	goalProps, err := eventprops.ParseFilter(c.Queries())
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if len(goalProps) > 0 && fieldcrypt.Active() != nil {
+		return c.Status(501).JSON(fiber.Map{"error": "Property filters aren't available while event properties are encrypted"})
+	}
+
+	days := min(max(fiber.Query[int](c, "days", 7), 1), 365)
+	pagination := ParsePaginationParams(c)
+
+	goal := store.Goal{Event: c.Query("goal"), Props: goalProps}
+	rows, totalCount, err := store.Current().UTMBreakdown(c.Context(), websiteID, dimension, days, pagination.Per, pagination.Offset, goal)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query utm " + dimension})
+	}
+
+	items := make([]UTMItem, 0, len(rows))
+	for _, row := range rows {
+		item := UTMItem{
+			Name:        row.Name,
+			Visitors:    int(row.Visitors),
+			Pageviews:   int(row.Pageviews),
+			Conversions: int(row.Conversions),
+		}
+		if item.Visitors > 0 {
+			item.ConversionRate = float64(item.Conversions) / float64(item.Visitors) * 100
+		}
+		items = append(items, item)
+	}
+
+	return c.JSON(NewPaginatedResponse(items, pagination, totalCount))
+}
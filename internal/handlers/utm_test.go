@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleUTMBreakdown_Success(t *testing.T) {
+	websiteID := uuid.New()
+	responses := []mockResponse{
+		{
+			match:   "SELECT * FROM get_utm_breakdown(",
+			columns: []string{"name", "visitors", "pageviews", "conversions", "total_count"},
+			rows:    [][]interface{}{{"spring-sale", int64(40), int64(90), int64(10), int64(1)}},
+			args:    []interface{}{websiteID, "source", 30, 10, 0, "signup", nil},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/dashboard/utm/:website_id", HandleUTMBreakdown, responses)
+	defer cleanup()
+
+	url := "/api/dashboard/utm/" + websiteID.String() + "?by=source&days=30&goal=signup"
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var paginatedResp PaginatedResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&paginatedResp))
+
+	itemsJSON, err := json.Marshal(paginatedResp.Data)
+	require.NoError(t, err)
+	var items []UTMItem
+	require.NoError(t, json.Unmarshal(itemsJSON, &items))
+
+	require.Len(t, items, 1)
+	assert.Equal(t, "spring-sale", items[0].Name)
+	assert.Equal(t, 40, items[0].Visitors)
+	assert.Equal(t, 90, items[0].Pageviews)
+	assert.Equal(t, 10, items[0].Conversions)
+	assert.InDelta(t, 25.0, items[0].ConversionRate, 0.001)
+
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleUTMBreakdown_GoalProps(t *testing.T) {
+	websiteID := uuid.New()
+	responses := []mockResponse{
+		{
+			match:   "SELECT * FROM get_utm_breakdown(",
+			columns: []string{"name", "visitors", "pageviews", "conversions", "total_count"},
+			rows:    [][]interface{}{{"spring-sale", int64(40), int64(90), int64(4), int64(1)}},
+			args:    []interface{}{websiteID, "campaign", 7, 10, 0, "signup", `{"plan":["pro"]}`},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/dashboard/utm/:website_id", HandleUTMBreakdown, responses)
+	defer cleanup()
+
+	url := "/api/dashboard/utm/" + websiteID.String() + "?goal=signup&prop.plan=pro"
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, url, nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, queue.expectationsMet())
+
+	// Keys are letters, digits and _
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/api/dashboard/utm/"+websiteID.String()+"?prop.pl%27an=pro", nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestHandleUTMBreakdown_InvalidInput(t *testing.T) {
+	app, _, cleanup := setupFiberTest(t, "/api/dashboard/utm/:website_id", HandleUTMBreakdown, nil)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/dashboard/utm/invalid", nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	_ = resp.Body.Close()
+
+	req = httptest.NewRequest(http.MethodGet, "/api/dashboard/utm/"+uuid.New().String()+"?by=gclid", nil)
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	_ = resp.Body.Close()
+}
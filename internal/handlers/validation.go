@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/seuros/kaunta/internal/eventprops"
+)
+
+// What becomes of tracking payloads with values over their column's size,
+// too many properties or values of the wrong JSON type (payload_validation)
+const (
+	// ValidationTruncate cuts values to fit and converts or drops values of
+	// the wrong type (default)
+	ValidationTruncate = "truncate"
+	// ValidationReject answers 400 with the fields at fault
+	ValidationReject = "reject"
+)
+
+// Limits of payload values, matching their columns
+const (
+	maxTitleLength     = 500 // page_title
+	maxPathLength      = 500 // url_path, url_query, referrer_path, referrer_query, referrer_domain
+	maxHostnameLength  = 100
+	maxEventNameLength = 50
+	maxTagLength       = 50
+	maxScreenLength    = 11
+	maxLanguageLength  = 35
+
+	// maxProps is how many properties an event may carry
+	maxProps = 50
+	// maxPropValueLength caps string property values, nested ones included
+	maxPropValueLength = 500
+)
+
+// Reasons of a fieldError
+const (
+	reasonTooLong     = "too_long"
+	reasonTooMany     = "too_many"
+	reasonTooLarge    = "too_large"
+	reasonInvalidType = "invalid_type"
+)
+
+// validationMode is ValidationTruncate or ValidationReject
+var validationMode = ValidationTruncate
+
+// ParseValidationMode validates a payload_validation setting; empty means
+// ValidationTruncate
+func ParseValidationMode(value string) (string, error) {
+	switch mode := strings.ToLower(strings.TrimSpace(value)); mode {
+	case "":
+		return ValidationTruncate, nil
+	case ValidationTruncate, ValidationReject:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid payload_validation: %s (use truncate or reject)", value)
+	}
+}
+
+// SetValidationMode sets what becomes of invalid payloads (see
+// ParseValidationMode). Call it before serving requests.
+func SetValidationMode(mode string) {
+	validationMode = mode
+}
+
+var invalidPayloadsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "kaunta",
+	Subsystem: "ingest",
+	Name:      "invalid_payloads_total",
+	Help:      "Tracking payloads with values over their limits or of the wrong type, rejected or fixed (cut to fit, converted).",
+}, []string{"action"})
+
+// fieldError is a payload value over its limit or of the wrong type, as
+// listed in the "fields" of a rejected payload
+type fieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+	Limit  int    `json:"limit,omitempty"`
+}
+
+// rejectPayload answers 400 with the fields at fault
+func rejectPayload(c fiber.Ctx, message string, fields []fieldError) error {
+	invalidPayloadsTotal.WithLabelValues("rejected").Inc()
+	return c.Status(400).JSON(fiber.Map{
+		"error":  message,
+		"fields": fields,
+	})
+}
+
+// decodePayload decodes an /api/send message. Payload values of the wrong
+// JSON type are converted to their field's type when they read as one
+// ("50" or 49.6 for scroll_depth, 404 for title) and dropped otherwise, and
+// listed in the payload's invalid fields for track to reject or count.
+func decodePayload(data []byte) (TrackingPayload, error) {
+	var payload TrackingPayload
+	err := json.Unmarshal(data, &payload)
+	var typeErr *json.UnmarshalTypeError
+	if err == nil || !errors.As(err, &typeErr) {
+		return payload, err
+	}
+	return coercePayload(data)
+}
+
+// payloadFields maps the JSON names of PayloadData's fields to their types
+var payloadFields = func() map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	t := reflect.TypeOf(PayloadData{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		ft := t.Field(i).Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		fields[name] = ft
+	}
+	return fields
+}()
+
+// coercePayload decodes a message whose payload has values of the wrong type
+func coercePayload(data []byte) (TrackingPayload, error) {
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return TrackingPayload{}, err
+	}
+	var fields []fieldError
+	if raw, ok := msg["payload"]; ok {
+		var values map[string]interface{}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&values); err == nil {
+			for name, value := range values {
+				t, ok := payloadFields[name]
+				if !ok || value == nil {
+					continue
+				}
+				converted, ok := coerce(value, t)
+				if ok && reflect.DeepEqual(converted, value) {
+					continue
+				}
+				fields = append(fields, fieldError{Field: "payload." + name, Reason: reasonInvalidType})
+				if ok {
+					values[name] = converted
+				} else {
+					delete(values, name)
+				}
+			}
+			if msg["payload"], err = json.Marshal(values); err != nil {
+				return TrackingPayload{}, err
+			}
+		}
+	}
+
+	fixed, err := json.Marshal(msg)
+	if err != nil {
+		return TrackingPayload{}, err
+	}
+	var payload TrackingPayload
+	if err := json.Unmarshal(fixed, &payload); err != nil {
+		return TrackingPayload{}, err
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+	payload.invalid = fields
+	return payload, nil
+}
+
+// coerce converts a decoded JSON value (numbers as json.Number) to a value
+// of type t, reporting false when it doesn't read as one
+func coerce(value interface{}, t reflect.Type) (interface{}, bool) {
+	switch t.Kind() {
+	case reflect.String:
+		switch v := value.(type) {
+		case string:
+			return v, true
+		case json.Number:
+			return v.String(), true
+		case bool:
+			return strconv.FormatBool(v), true
+		}
+	case reflect.Int, reflect.Int64:
+		var f float64
+		var err error
+		switch v := value.(type) {
+		case json.Number:
+			if _, err := v.Int64(); err == nil {
+				return v, true
+			}
+			f, err = v.Float64()
+		case string:
+			f, err = strconv.ParseFloat(strings.TrimSpace(v), 64)
+		default:
+			return nil, false
+		}
+		if err != nil || math.IsNaN(f) || math.Abs(f) >= 1<<53 {
+			return nil, false
+		}
+		return json.Number(strconv.FormatInt(int64(math.Round(f)), 10)), true
+	case reflect.Bool:
+		switch v := value.(type) {
+		case bool:
+			return v, true
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b, true
+			}
+		}
+	case reflect.Map:
+		if v, ok := value.(map[string]interface{}); ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// limitPayload checks the values of p against their limits. In truncate
+// mode the payload's own strings are cut to fit (the parts of its URL and
+// referrer, and its properties, are cut by saveEvent); in reject mode
+// nothing changes. It returns the fields over a limit.
+func limitPayload(p *PayloadData, truncate bool) []fieldError {
+	var fields []fieldError
+	limit := func(field string, s *string, n int) *string {
+		if s == nil {
+			return nil
+		}
+		runes := []rune(*s)
+		if len(runes) <= n {
+			return s
+		}
+		fields = append(fields, fieldError{Field: field, Reason: reasonTooLong, Limit: n})
+		if !truncate {
+			return s
+		}
+		cut := string(runes[:n])
+		return &cut
+	}
+	p.Title = limit("payload.title", p.Title, maxTitleLength)
+	p.Hostname = limit("payload.hostname", p.Hostname, maxHostnameLength)
+	p.Name = limit("payload.name", p.Name, maxEventNameLength)
+	p.Tag = limit("payload.tag", p.Tag, maxTagLength)
+	p.Screen = limit("payload.screen", p.Screen, maxScreenLength)
+	p.Language = limit("payload.language", p.Language, maxLanguageLength)
+
+	urlParts := func(field string, raw *string, domain bool) {
+		if raw == nil {
+			return
+		}
+		u, err := url.Parse(*raw)
+		if err != nil {
+			return
+		}
+		parts := []string{u.Path, u.RawQuery}
+		if domain {
+			parts = append(parts, u.Hostname())
+		}
+		for _, part := range parts {
+			if len([]rune(part)) > maxPathLength {
+				fields = append(fields, fieldError{Field: field, Reason: reasonTooLong, Limit: maxPathLength})
+				return
+			}
+		}
+	}
+	urlParts("payload.url", p.URL, false)
+	urlParts("payload.referrer", p.Referrer, true)
+
+	if !truncate {
+		props, over := limitProps(combinedProps(*p))
+		fields = append(fields, over...)
+		_, dropped := eventprops.Clean(props, p.propsLimits)
+		for _, key := range dropped {
+			fields = append(fields, fieldError{Field: "payload.props." + key, Reason: reasonTooLarge})
+		}
+	}
+	return fields
+}
+
+// combinedProps merges the props and data of a payload, data winning
+func combinedProps(p PayloadData) map[string]interface{} {
+	if p.Props == nil && p.Data == nil {
+		return nil
+	}
+	combined := make(map[string]interface{}, len(p.Props)+len(p.Data))
+	for key, value := range p.Props {
+		combined[key] = value
+	}
+	for key, value := range p.Data {
+		combined[key] = value
+	}
+	return combined
+}
+
+// limitProps keeps the first maxProps properties in key order and cuts
+// their string values to maxPropValueLength, returning the properties kept
+// and the fields over a limit
+func limitProps(props map[string]interface{}) (map[string]interface{}, []fieldError) {
+	var fields []fieldError
+	keys := make([]string, 0, len(props))
+	for key := range props {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) > maxProps {
+		fields = append(fields, fieldError{Field: "payload.props", Reason: reasonTooMany, Limit: maxProps})
+		keys = keys[:maxProps]
+	}
+
+	kept := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		value, cut := cutStrings(props[key])
+		if cut {
+			fields = append(fields, fieldError{Field: "payload.props." + key, Reason: reasonTooLong, Limit: maxPropValueLength})
+		}
+		kept[key] = value
+	}
+	return kept, fields
+}
+
+// cutStrings cuts the strings of a property value to maxPropValueLength,
+// reporting whether any was
+func cutStrings(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case string:
+		if runes := []rune(v); len(runes) > maxPropValueLength {
+			return string(runes[:maxPropValueLength]), true
+		}
+	case []interface{}:
+		cut := false
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			var c bool
+			items[i], c = cutStrings(item)
+			cut = cut || c
+		}
+		return items, cut
+	case map[string]interface{}:
+		cut := false
+		nested := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			var c bool
+			nested[key], c = cutStrings(item)
+			cut = cut || c
+		}
+		return nested, cut
+	}
+	return value, false
+}
+
+// clip cuts a column value to n characters
+func clip(s *string, n int) *string {
+	if s == nil {
+		return nil
+	}
+	if runes := []rune(*s); len(runes) > n {
+		cut := string(runes[:n])
+		return &cut
+	}
+	return s
+}
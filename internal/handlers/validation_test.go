@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseValidationMode(t *testing.T) {
+	mode, err := ParseValidationMode("")
+	require.NoError(t, err)
+	assert.Equal(t, ValidationTruncate, mode)
+	mode, err = ParseValidationMode(" Reject ")
+	require.NoError(t, err)
+	assert.Equal(t, ValidationReject, mode)
+	_, err = ParseValidationMode("drop")
+	assert.EqualError(t, err, "invalid payload_validation: drop (use truncate or reject)")
+}
+
+func TestDecodePayloadCoercesTypes(t *testing.T) {
+	payload, err := decodePayload([]byte(`{"type":"event","payload":{
+		"website":"abc","title":404,"scroll_depth":"49.6","engagement_time":1200.4,
+		"webdriver":"false","seq":"many","props":"plan","url":"/pricing"}}`))
+	require.NoError(t, err)
+
+	p := payload.Payload
+	assert.Equal(t, "404", *p.Title)
+	assert.Equal(t, 50, *p.ScrollDepth)
+	assert.Equal(t, 1200, *p.EngagementTime)
+	assert.False(t, *p.Webdriver)
+	assert.Nil(t, p.Seq)
+	assert.Nil(t, p.Props)
+	assert.Equal(t, "/pricing", *p.URL)
+	assert.Equal(t, []fieldError{
+		{Field: "payload.engagement_time", Reason: reasonInvalidType},
+		{Field: "payload.props", Reason: reasonInvalidType},
+		{Field: "payload.scroll_depth", Reason: reasonInvalidType},
+		{Field: "payload.seq", Reason: reasonInvalidType},
+		{Field: "payload.title", Reason: reasonInvalidType},
+		{Field: "payload.webdriver", Reason: reasonInvalidType},
+	}, payload.invalid)
+
+	// Well-typed payloads are decoded as they are
+	payload, err = decodePayload([]byte(`{"type":"event","payload":{"website":"abc","scroll_depth":50}}`))
+	require.NoError(t, err)
+	assert.Empty(t, payload.invalid)
+
+	// A message that isn't an object, or whose type isn't a string, is invalid
+	_, err = decodePayload([]byte(`{"type":1,"payload":{}}`))
+	assert.Error(t, err)
+	_, err = decodePayload([]byte(`[1]`))
+	assert.Error(t, err)
+}
+
+func TestLimitPayload(t *testing.T) {
+	long := strings.Repeat("é", 600)
+	name := strings.Repeat("n", 60)
+	url := "https://example.com/" + strings.Repeat("a", 600)
+	newPayload := func() PayloadData {
+		return PayloadData{Title: &long, Name: &name, URL: &url, Props: map[string]interface{}{"plan": "pro"}}
+	}
+	want := []fieldError{
+		{Field: "payload.title", Reason: reasonTooLong, Limit: maxTitleLength},
+		{Field: "payload.name", Reason: reasonTooLong, Limit: maxEventNameLength},
+		{Field: "payload.url", Reason: reasonTooLong, Limit: maxPathLength},
+	}
+
+	p := newPayload()
+	assert.Equal(t, want, limitPayload(&p, true))
+	assert.Len(t, []rune(*p.Title), maxTitleLength)
+	assert.Len(t, *p.Name, maxEventNameLength)
+	assert.Equal(t, url, *p.URL, "the URL's path is cut by saveEvent")
+
+	p = newPayload()
+	assert.Equal(t, want, limitPayload(&p, false))
+	assert.Equal(t, long, *p.Title)
+
+	short := "Pricing"
+	p = PayloadData{Title: &short, URL: &short}
+	assert.Empty(t, limitPayload(&p, false))
+}
+
+func TestLimitPayloadRejectsProps(t *testing.T) {
+	props := map[string]interface{}{"bio": strings.Repeat("x", 501), "tags": []interface{}{"a", map[string]interface{}{"deep": map[string]interface{}{"deeper": 1}}}}
+	p := PayloadData{Props: props}
+	p.propsLimits.MaxDepth = 2
+
+	assert.Equal(t, []fieldError{
+		{Field: "payload.props.bio", Reason: reasonTooLong, Limit: maxPropValueLength},
+		{Field: "payload.props.tags", Reason: reasonTooLarge},
+	}, limitPayload(&p, false))
+}
+
+func TestLimitProps(t *testing.T) {
+	props := make(map[string]interface{})
+	for i := 0; i < maxProps+5; i++ {
+		props[string(rune('a'+i/26))+string(rune('a'+i%26))] = i
+	}
+	props["aa"] = []interface{}{strings.Repeat("x", 600), "ok"}
+
+	kept, fields := limitProps(props)
+	assert.Len(t, kept, maxProps)
+	assert.NotContains(t, kept, "cc", "the last keys are dropped")
+	assert.Equal(t, []interface{}{strings.Repeat("x", maxPropValueLength), "ok"}, kept["aa"])
+	assert.Equal(t, []fieldError{
+		{Field: "payload.props", Reason: reasonTooMany, Limit: maxProps},
+		{Field: "payload.props.aa", Reason: reasonTooLong, Limit: maxPropValueLength},
+	}, fields)
+}
+
+func TestHandleTrackingRejectsWrongTypes(t *testing.T) {
+	SetValidationMode(ValidationReject)
+	t.Cleanup(func() { SetValidationMode(ValidationTruncate) })
+	app := fiber.New()
+	app.Post("/api/send", HandleTracking)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/send",
+		strings.NewReader(`{"type":"event","payload":{"website":"abc","scroll_depth":"half"}}`))
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	var parsed struct {
+		Error  string       `json:"error"`
+		Fields []fieldError `json:"fields"`
+	}
+	require.NoError(t, json.Unmarshal(body, &parsed))
+	assert.Equal(t, "Invalid payload", parsed.Error)
+	assert.Equal(t, []fieldError{{Field: "payload.scroll_depth", Reason: reasonInvalidType}}, parsed.Fields)
+}
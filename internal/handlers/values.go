@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"slices"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// maxDimensionValues caps the values listed for one dimension
+const maxDimensionValues = 500
+
+// HandleDimensionValues lists the values of a dimension seen in the period
+// (?dimension=browser&days=7), most frequent first, so filter dropdowns and
+// completions offer values that exist. Other active filters narrow the list;
+// the dimension's own filter doesn't.
+func HandleDimensionValues(c fiber.Ctx) error {
+	websiteID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid website ID"})
+	}
+
+	dimension := c.Query("dimension")
+	if dimension == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "dimension is required"})
+	}
+	if !store.IsBreakdownDimension(dimension) && !slices.Contains(registeredDimensions(c.Context(), websiteID), dimension) {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid dimension: " + dimension})
+	}
+
+	days := min(max(fiber.Query[int](c, "days", 1), 1), 90)
+	limit := min(max(fiber.Query[int](c, "limit", 100), 1), maxDimensionValues)
+
+	filters, err := dashboardFilters(c, websiteID)
+	if err != nil {
+		return filtersError(c, err)
+	}
+	if days, err = dashboardRange(c, days, &filters); err != nil {
+		return rangeError(c, err)
+	}
+
+	rows, total, err := store.Current().Breakdown(c.Context(), websiteID, dimension, days, limit, 0, filters)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query values"})
+	}
+
+	values := make([]DimensionValue, 0, len(rows))
+	for _, row := range rows {
+		values = append(values, DimensionValue{Value: row.Name, Count: int(row.Count)})
+	}
+	return c.JSON(fiber.Map{
+		"dimension": dimension,
+		"days":      days,
+		"values":    values,
+		"total":     total,
+	})
+}
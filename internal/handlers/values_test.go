@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleDimensionValues(t *testing.T) {
+	websiteID := uuid.New()
+	responses := []mockResponse{
+		{
+			match:   "SELECT * FROM get_breakdown(",
+			columns: []string{"name", "count", "total_count"},
+			rows:    [][]interface{}{{"Firefox", int64(12), int64(2)}, {"Chrome", int64(9), int64(2)}},
+			args:    []interface{}{websiteID, "browser", 7, 100, 0, "DE", nil, nil, nil, nil, nil, nil, nil},
+		},
+	}
+
+	app, queue, cleanup := setupFiberTest(t, "/api/websites/:id/values", HandleDimensionValues, responses)
+	defer cleanup()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/websites/"+websiteID.String()+"/values?dimension=browser&days=7&country=DE", nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var body struct {
+		Dimension string           `json:"dimension"`
+		Values    []DimensionValue `json:"values"`
+		Total     int64            `json:"total"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "browser", body.Dimension)
+	assert.Equal(t, []DimensionValue{{Value: "Firefox", Count: 12}, {Value: "Chrome", Count: 9}}, body.Values)
+	assert.Equal(t, int64(2), body.Total)
+	require.NoError(t, queue.expectationsMet())
+}
+
+func TestHandleDimensionValuesRejectsUnknownDimension(t *testing.T) {
+	websiteID := uuid.New()
+	responses := []mockResponse{
+		{
+			match:   "SELECT name FROM custom_dimension",
+			columns: []string{"name"},
+			rows:    [][]interface{}{{"plan"}},
+		},
+	}
+
+	app, _, cleanup := setupFiberTest(t, "/api/websites/:id/values", HandleDimensionValues, responses)
+	defer cleanup()
+
+	for _, query := range []string{"", "?dimension=plan%27%3B", "?dimension=os"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/websites/"+websiteID.String()+"/values"+query, nil)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/logging"
+	"github.com/seuros/kaunta/internal/store"
+	"github.com/seuros/kaunta/internal/tracing"
+)
+
+// viewPending marks a pageview that the tracker will confirm
+const viewPending = "pending"
+
+// viewWindow is how long after a pageview a confirmation or read signal is
+// accepted (a background tab may be opened long after it was loaded)
+const viewWindow = 24 * time.Hour
+
+// confirmView handles a "view" message: the tracker saw the page visible for
+// its minimum time (or scrolled) after sending a pending pageview. The
+// pageview is found again by session and path, as the tracker never learns
+// the event ID.
+func confirmView(ctx context.Context, c fiber.Ctx, websiteID uuid.UUID, ip, userAgent string, payload PayloadData) error {
+	return updatePageview(ctx, c, websiteID, ip, userAgent, payload,
+		"confirm_view", "confirmed", store.Current().ConfirmView)
+}
+
+// markRead handles a "read" message: the visitor scrolled through most of a
+// page that has an author (see the authors report)
+func markRead(ctx context.Context, c fiber.Ctx, websiteID uuid.UUID, ip, userAgent string, payload PayloadData) error {
+	return updatePageview(ctx, c, websiteID, ip, userAgent, payload,
+		"mark_read", "read", store.Current().MarkRead)
+}
+
+// pageviewUpdate updates the session's latest matching pageview of a path
+type pageviewUpdate func(ctx context.Context, websiteID, sessionID uuid.UUID, urlPath string, since time.Time) (bool, error)
+
+func updatePageview(ctx context.Context, c fiber.Ctx, websiteID uuid.UUID, ip, userAgent string, payload PayloadData,
+	op, field string, update pageviewUpdate) error {
+	if payload.URL == nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "url is required",
+		})
+	}
+	u, err := url.Parse(*payload.URL)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid URL",
+		})
+	}
+
+	now := time.Now()
+	sessionID, err := visitorSessionID(ctx, websiteID, ip, userAgent, now)
+	if err != nil {
+		logging.L().Error("failed to derive session", zap.String("website_id", websiteID.String()), zap.Error(err))
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to update pageview",
+		})
+	}
+
+	spanCtx, dbSpan := storeSpan(ctx, op)
+	updated, err := update(spanCtx, websiteID, sessionID, u.Path, now.Add(-viewWindow))
+	tracing.End(dbSpan, err)
+	if err != nil {
+		logging.L().Error("failed to update pageview",
+			zap.String("op", op),
+			zap.String("website_id", websiteID.String()),
+			zap.String("session_id", sessionID.String()),
+			zap.Error(err))
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to update pageview",
+		})
+	}
+
+	return c.Status(202).JSON(fiber.Map{
+		"sessionId": sessionID.String(),
+		field:       updated,
+	})
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/database"
+)
+
+func TestConfirmView(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	original := database.DB
+	database.DB = mockDB
+	t.Cleanup(func() {
+		database.DB = original
+		_ = mockDB.Close()
+	})
+
+	websiteID := uuid.New()
+	sessionID, err := visitorSessionID(context.Background(), websiteID, "203.0.113.7", "test-agent", time.Now())
+	require.NoError(t, err)
+	mock.ExpectExec(`UPDATE website_event SET viewed = TRUE`).
+		WithArgs(websiteID, sessionID, "/docs/start", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	app := fiber.New()
+	app.Post("/", func(c fiber.Ctx) error {
+		var payload PayloadData
+		if err := c.Bind().Body(&payload); err != nil {
+			return err
+		}
+		return confirmView(context.Background(), c, websiteID, "203.0.113.7", "test-agent", payload)
+	})
+
+	send := func(body string) (int, map[string]any) {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+		var out map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		return resp.StatusCode, out
+	}
+
+	status, out := send(`{"url":"https://example.com/docs/start?ref=nav"}`)
+	assert.Equal(t, http.StatusAccepted, status)
+	assert.Equal(t, true, out["confirmed"])
+	assert.Equal(t, sessionID.String(), out["sessionId"])
+
+	status, _ = send(`{}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkRead(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	original := database.DB
+	database.DB = mockDB
+	t.Cleanup(func() {
+		database.DB = original
+		_ = mockDB.Close()
+	})
+
+	websiteID := uuid.New()
+	sessionID, err := visitorSessionID(context.Background(), websiteID, "203.0.113.7", "test-agent", time.Now())
+	require.NoError(t, err)
+	mock.ExpectExec(`UPDATE website_event SET read = TRUE`).
+		WithArgs(websiteID, sessionID, "/blog/launch", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	app := fiber.New()
+	app.Post("/", func(c fiber.Ctx) error {
+		var payload PayloadData
+		if err := c.Bind().Body(&payload); err != nil {
+			return err
+		}
+		return markRead(context.Background(), c, websiteID, "203.0.113.7", "test-agent", payload)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"url":"https://example.com/blog/launch"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	var out map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Equal(t, false, out["read"])
+	require.NoError(t, mock.ExpectationsWereMet())
+}
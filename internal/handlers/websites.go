@@ -2,51 +2,52 @@ package handlers
 
 import (
 	"github.com/gofiber/fiber/v3"
-	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/store"
 )
 
 // HandleWebsites returns list of all websites with pagination
 func HandleWebsites(c fiber.Ctx) error {
 	// Parse pagination parameters
 	pagination := ParsePaginationParams(c)
+	// Umami's clients page with ?pageSize=
+	if size := fiber.Query[int](c, "pageSize", 0); size > 0 && c.Query("per") == "" {
+		pagination.Per = min(size, 100)
+		pagination.Offset = (pagination.Page - 1) * pagination.Per
+	}
 
 	// Query with COUNT and pagination
-	rows, err := database.DB.Query(`
-		WITH total AS (
-			SELECT COUNT(*)::BIGINT as count FROM website
-		)
-		SELECT w.website_id, w.domain, w.name, t.count as total_count
-		FROM website w
-		CROSS JOIN total t
-		ORDER BY w.name, w.domain
-		LIMIT $1 OFFSET $2
-	`, pagination.Per, pagination.Offset)
-
+	rows, totalCount, err := store.Current().ListWebsites(c.Context(), pagination.Per, pagination.Offset)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to query websites",
 		})
 	}
-	defer func() { _ = rows.Close() }()
 
-	var websites []Website
-	var totalCount int64
-	for rows.Next() {
-		var website Website
-		var name *string
-		var rowTotal int64
-		if err := rows.Scan(&website.ID, &website.Domain, &name, &rowTotal); err != nil {
-			continue
-		}
-		totalCount = rowTotal // Capture total count
-		if name != nil {
-			website.Name = *name
+	websites := []Website{}
+	for _, row := range rows {
+		website := Website{ID: row.WebsiteID, Domain: row.Domain}
+		if row.Name != nil {
+			website.Name = *row.Name
 		} else {
 			website.Name = website.Domain
 		}
 		websites = append(websites, website)
 	}
 
-	// Return paginated response
-	return c.JSON(NewPaginatedResponse(websites, pagination, totalCount))
+	// Return paginated response, with the count, page and pageSize Umami's
+	// clients read
+	return c.JSON(websitePage{
+		PaginatedResponse: NewPaginatedResponse(websites, pagination, totalCount),
+		Count:             totalCount,
+		Page:              pagination.Page,
+		PageSize:          pagination.Per,
+	})
+}
+
+// websitePage is a page of websites in Kaunta's and Umami's shapes
+type websitePage struct {
+	PaginatedResponse
+	Count    int64 `json:"count"`
+	Page     int   `json:"page"`
+	PageSize int   `json:"pageSize"`
 }
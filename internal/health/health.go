@@ -0,0 +1,392 @@
+// Package health scores how well a website is tracked and how its traffic
+// fares, from signals Kaunta already records: whether events still arrive,
+// the bounce rate and its trend, visits to error pages, events lost on the
+// way (eventloss) and visitors blocking the tracker (blockers). Each check
+// scores 0-100; the website's score is their weighted average, and every
+// check that falls short comes with a recommendation for site owners who
+// don't read analytics for a living.
+//
+// Kaunta collects neither Core Web Vitals nor uptime, so they aren't scored.
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/blockers"
+	"github.com/seuros/kaunta/internal/eventloss"
+	"github.com/seuros/kaunta/internal/stats"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// Statuses of a check
+const (
+	OK         = "ok"
+	Warn       = "warn"
+	Critical   = "critical"
+	Unmeasured = "unmeasured"
+)
+
+// Priorities of a recommendation
+const (
+	High   = "high"
+	Medium = "medium"
+	Low    = "low"
+)
+
+// Check is one scored signal
+type Check struct {
+	Name   string `json:"name"`
+	Title  string `json:"title"`
+	Status string `json:"status"`
+	// Score is 0-100, nil when the check couldn't be measured
+	Score  *int   `json:"score"`
+	Weight int    `json:"weight"`
+	Detail string `json:"detail"`
+}
+
+// Recommendation is what to do about a check that fell short
+type Recommendation struct {
+	Priority string `json:"priority"`
+	Check    string `json:"check"`
+	Text     string `json:"text"`
+}
+
+// Report is a website's score with its checks and recommendations, most
+// pressing first
+type Report struct {
+	Domain          string           `json:"domain"`
+	Days            int              `json:"days"`
+	Score           int              `json:"score"`
+	Grade           string           `json:"grade"`
+	Checks          []Check          `json:"checks"`
+	Recommendations []Recommendation `json:"recommendations"`
+}
+
+// Page is a path and how many times it was visited
+type Page struct {
+	Path  string `json:"path"`
+	Views int64  `json:"views"`
+}
+
+// Signals are the figures a report is scored from
+type Signals struct {
+	// LastEvent is when the website's latest event arrived, nil if none did
+	LastEvent *time.Time
+	// Current and Previous are the key metrics of the period and of the
+	// period of the same length before it
+	Current  stats.Summary
+	Previous stats.Summary
+	// ErrorViews counts the period's views of error pages, ErrorPages the
+	// most visited of them
+	ErrorViews int64
+	ErrorPages []Page
+	// EventsSent and EventsLost come from the event sequence counters
+	EventsSent int64
+	EventsLost int64
+	// Blocking is the baseline pixel's estimate, nil without pixel hits
+	Blocking *blockers.Website
+}
+
+// errorPages are the period's pageviews of pages titled as error pages, and
+// the custom events named "404" some sites send from their error page
+var errorPages = `
+	SELECT COALESCE(e.url_path, '/'), COUNT(*)
+	FROM website_event e
+	WHERE e.website_id = $1 AND ` + stats.Since("e.created_at", "$2") + `
+	  AND ((e.event_type = 1 AND (e.page_title ILIKE '%404%' OR e.page_title ILIKE '%not found%'))
+	    OR (e.event_type = 2 AND e.event_name = '404'))
+	GROUP BY 1
+	ORDER BY 2 DESC, 1
+`
+
+// maxErrorPages is how many error pages a report names
+const maxErrorPages = 3
+
+// Collect reads the signals of a website's last days
+func Collect(ctx context.Context, db *sql.DB, websiteID uuid.UUID, domain string, days int) (*Signals, error) {
+	if days < 1 {
+		return nil, fmt.Errorf("days must be at least 1")
+	}
+	s := &Signals{}
+
+	var last sql.NullTime
+	if err := db.QueryRowContext(ctx, `SELECT MAX(created_at) FROM website_event WHERE website_id = $1`,
+		websiteID).Scan(&last); err != nil {
+		return nil, fmt.Errorf("failed to query last event: %w", err)
+	}
+	if last.Valid {
+		s.LastEvent = &last.Time
+	}
+
+	comparison, err := stats.GetComparison(ctx, db, websiteID, days, store.Filters{})
+	if err != nil {
+		return nil, err
+	}
+	s.Current, s.Previous = comparison.Current, comparison.Previous
+
+	rows, err := db.QueryContext(ctx, errorPages, websiteID, days)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query error pages: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var p Page
+		if err := rows.Scan(&p.Path, &p.Views); err != nil {
+			return nil, fmt.Errorf("failed to read error page: %w", err)
+		}
+		s.ErrorViews += p.Views
+		if len(s.ErrorPages) < maxErrorPages {
+			s.ErrorPages = append(s.ErrorPages, p)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	loss, err := eventloss.Report(ctx, db, days)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range loss.Websites {
+		if w.Domain == domain {
+			s.EventsSent, s.EventsLost = w.Sent, w.Lost
+		}
+	}
+
+	blocking, err := blockers.Report(ctx, db, days)
+	if err != nil {
+		return nil, err
+	}
+	for i := range blocking {
+		if blocking[i].Domain == domain {
+			s.Blocking = &blocking[i]
+		}
+	}
+	return s, nil
+}
+
+// Score rates a website's signals at now
+func Score(domain string, days int, s Signals, now time.Time) *Report {
+	r := &Report{Domain: domain, Days: days, Recommendations: []Recommendation{}}
+	for _, check := range []func(string, Signals, time.Time) (Check, string){
+		trackingCheck, bounceCheck, errorPagesCheck, eventLossCheck, blockingCheck,
+	} {
+		c, advice := check(domain, s, now)
+		c.Status = status(c.Score)
+		r.Checks = append(r.Checks, c)
+		if advice != "" {
+			r.Recommendations = append(r.Recommendations, Recommendation{Priority: priority(c.Status), Check: c.Name, Text: advice})
+		}
+	}
+
+	weighted, weights := 0, 0
+	for _, c := range r.Checks {
+		if c.Score != nil {
+			weighted += *c.Score * c.Weight
+			weights += c.Weight
+		}
+	}
+	if weights > 0 {
+		r.Score = int(math.Round(float64(weighted) / float64(weights)))
+	}
+	r.Grade = grade(r.Score)
+
+	// Most pressing first: by priority, then by the points the check costs
+	lost := func(rec Recommendation) int {
+		for _, c := range r.Checks {
+			if c.Name == rec.Check && c.Score != nil {
+				return (100 - *c.Score) * c.Weight
+			}
+		}
+		return 0
+	}
+	rank := map[string]int{High: 0, Medium: 1, Low: 2}
+	sort.SliceStable(r.Recommendations, func(i, j int) bool {
+		a, b := r.Recommendations[i], r.Recommendations[j]
+		if rank[a.Priority] != rank[b.Priority] {
+			return rank[a.Priority] < rank[b.Priority]
+		}
+		return lost(a) > lost(b)
+	})
+	return r
+}
+
+// trackingCheck asks whether events still arrive
+func trackingCheck(domain string, s Signals, now time.Time) (Check, string) {
+	c := Check{Name: "tracking", Title: "Tracking setup", Weight: 30}
+	if s.LastEvent == nil {
+		c.Score, c.Detail = score(0), "no event received yet"
+		return c, fmt.Sprintf("Install the tracker: add the snippet of 'kaunta website tracking-code %s' to every page.", domain)
+	}
+	since := now.Sub(*s.LastEvent)
+	c.Detail = "last event " + ago(since)
+	switch {
+	case since > 72*time.Hour:
+		c.Score = score(20)
+		return c, fmt.Sprintf("Tracking stopped %s: check the tracker is still on your pages and that the domain serving them is allowed ('kaunta website list-domains %s').",
+			ago(since), domain)
+	case since > 24*time.Hour:
+		c.Score = score(70)
+		return c, "No event arrived for over a day: if the site had visitors, check the tracker is still on your pages."
+	}
+	c.Score = score(100)
+	return c, ""
+}
+
+// bounceCheck rates the bounce rate and how it moved since the previous
+// period
+func bounceCheck(domain string, s Signals, _ time.Time) (Check, string) {
+	c := Check{Name: "bounce", Title: "Bounce rate", Weight: 20}
+	if s.Current.Pageviews == 0 {
+		c.Detail = "no pageviews in the period"
+		return c, ""
+	}
+	rate := s.Current.BounceRate
+	points := 100 - (rate-40)*2
+	c.Detail = fmt.Sprintf("%.1f%% of visitors left after one page", rate)
+	rise := 0.0
+	if s.Previous.Pageviews > 0 {
+		rise = rate - s.Previous.BounceRate
+		c.Detail += fmt.Sprintf(" (%+.1f points)", rise)
+		if rise > 0 {
+			points -= rise * 2
+		}
+	}
+	c.Score = score(points)
+
+	switch {
+	case rise >= 10:
+		return c, fmt.Sprintf("The bounce rate rose %.1f points: look for a recent change to your landing pages or a new source of traffic ('kaunta stats breakdown %s --by channel').",
+			rise, domain)
+	case rate > 65:
+		return c, fmt.Sprintf("Most visitors leave after one page: link your most visited pages ('kaunta stats pages %s') to related content and check they load quickly on phones.",
+			domain)
+	}
+	return c, ""
+}
+
+// errorPagesCheck rates the share of views that ended on an error page
+func errorPagesCheck(_ string, s Signals, _ time.Time) (Check, string) {
+	c := Check{Name: "errors", Title: "Error pages", Weight: 15}
+	if s.Current.Pageviews == 0 {
+		c.Detail = "no pageviews in the period"
+		return c, ""
+	}
+	share := float64(s.ErrorViews) / float64(s.Current.Pageviews) * 100
+	c.Score = score(100 - share*10)
+	c.Detail = fmt.Sprintf("%d views of error pages (%.1f%% of pageviews)", s.ErrorViews, share)
+	if s.ErrorViews == 0 || share < 0.5 {
+		return c, ""
+	}
+	pages := make([]string, len(s.ErrorPages))
+	for i, p := range s.ErrorPages {
+		pages[i] = fmt.Sprintf("%s (%d)", p.Path, p.Views)
+	}
+	return c, "Visitors hit missing pages: fix or redirect the links to " + strings.Join(pages, ", ") + "."
+}
+
+// eventLossCheck rates the share of events that never arrived
+func eventLossCheck(domain string, s Signals, _ time.Time) (Check, string) {
+	c := Check{Name: "event_loss", Title: "Event delivery", Weight: 15}
+	if s.EventsSent == 0 {
+		c.Detail = "no numbered events in the period"
+		return c, ""
+	}
+	rate := float64(s.EventsLost) / float64(s.EventsSent) * 100
+	c.Score = score(100 - rate*5)
+	c.Detail = fmt.Sprintf("%.1f%% of events lost on the way", rate)
+	if rate < 2 {
+		return c, ""
+	}
+	return c, fmt.Sprintf("Some events never arrive: capture a sample of the tracking requests ('kaunta website debug enable %s') to see what the server rejects.", domain)
+}
+
+// blockingCheck rates the share of visitors whose browser blocks the
+// tracker
+func blockingCheck(_ string, s Signals, _ time.Time) (Check, string) {
+	c := Check{Name: "blockers", Title: "Ad-blocker loss", Weight: 20}
+	if s.Blocking == nil {
+		c.Detail = "no baseline pixel hits"
+		return c, "Add the baseline pixel next to the tracker to measure how many visitors block it ('kaunta stats blockers --help')."
+	}
+	if !s.Blocking.Reliable {
+		c.Detail = fmt.Sprintf("%d pixel visitors, too few to estimate (%d needed)", s.Blocking.PixelVisitors, blockers.MinSample)
+		return c, ""
+	}
+	c.Score = score(100 - s.Blocking.BlockRate*2)
+	c.Detail = fmt.Sprintf("%.1f%% of visitors block the tracker", s.Blocking.BlockRate)
+	if s.Blocking.BlockRate < 10 {
+		return c, ""
+	}
+	return c, "Many visitors block the tracker: serve it first-party with custom tracker paths ('kaunta tracker proxy-config') and count the rest with 'kaunta stats overview --adjust-blocked'."
+}
+
+// score clamps points to 0-100
+func score(points float64) *int {
+	s := int(math.Round(math.Max(0, math.Min(100, points))))
+	return &s
+}
+
+func status(score *int) string {
+	switch {
+	case score == nil:
+		return Unmeasured
+	case *score >= 80:
+		return OK
+	case *score >= 50:
+		return Warn
+	}
+	return Critical
+}
+
+func priority(status string) string {
+	switch status {
+	case Critical:
+		return High
+	case Warn:
+		return Medium
+	}
+	return Low
+}
+
+func grade(score int) string {
+	switch {
+	case score >= 90:
+		return "A"
+	case score >= 75:
+		return "B"
+	case score >= 60:
+		return "C"
+	case score >= 40:
+		return "D"
+	}
+	return "F"
+}
+
+// ago shows how long ago something happened, roughly
+func ago(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return plural(int(d.Minutes()), "minute") + " ago"
+	case d < 48*time.Hour:
+		return plural(int(d.Hours()), "hour") + " ago"
+	}
+	return plural(int(d.Hours()/24), "day") + " ago"
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
@@ -0,0 +1,127 @@
+package health
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/blockers"
+	"github.com/seuros/kaunta/internal/stats"
+)
+
+func TestScoreHealthyWebsite(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	last := now.Add(-5 * time.Minute)
+	report := Score("example.com", 7, Signals{
+		LastEvent:  &last,
+		Current:    stats.Summary{Pageviews: 1000, BounceRate: 35},
+		Previous:   stats.Summary{Pageviews: 900, BounceRate: 38},
+		EventsSent: 2000,
+		EventsLost: 10,
+		Blocking:   &blockers.Website{Domain: "example.com", PixelVisitors: 500, BlockRate: 4, Reliable: true},
+	}, now)
+
+	assert.Equal(t, 98, report.Score)
+	assert.Equal(t, "A", report.Grade)
+	require.Len(t, report.Checks, 5)
+	for _, c := range report.Checks {
+		assert.Equal(t, OK, c.Status, c.Name)
+	}
+	assert.Equal(t, "last event 5 minutes ago", report.Checks[0].Detail)
+	assert.Empty(t, report.Recommendations)
+}
+
+func TestScoreRecommendations(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	last := now.Add(-5 * 24 * time.Hour)
+	report := Score("example.com", 7, Signals{
+		LastEvent:  &last,
+		Current:    stats.Summary{Pageviews: 1000, BounceRate: 60},
+		Previous:   stats.Summary{Pageviews: 1000, BounceRate: 45},
+		ErrorViews: 30,
+		ErrorPages: []Page{{Path: "/old-pricing", Views: 25}, {Path: "/blog/typo", Views: 5}},
+		EventsSent: 1000,
+		EventsLost: 30,
+	}, now)
+
+	checks := map[string]Check{}
+	for _, c := range report.Checks {
+		checks[c.Name] = c
+	}
+	assert.Equal(t, Critical, checks["tracking"].Status)
+	assert.Equal(t, 20, *checks["tracking"].Score)
+	assert.Equal(t, Critical, checks["bounce"].Status)
+	assert.Equal(t, "60.0% of visitors left after one page (+15.0 points)", checks["bounce"].Detail)
+	assert.Equal(t, 70, *checks["errors"].Score)
+	assert.Equal(t, 85, *checks["event_loss"].Score)
+	assert.Equal(t, Unmeasured, checks["blockers"].Status)
+	assert.Nil(t, checks["blockers"].Score)
+
+	// Weighted over the measured checks only: (20*30 + 30*20 + 70*15 + 85*15) / 80
+	assert.Equal(t, 44, report.Score)
+	assert.Equal(t, "D", report.Grade)
+
+	require.Len(t, report.Recommendations, 5)
+	assert.Equal(t, "tracking", report.Recommendations[0].Check)
+	assert.Equal(t, High, report.Recommendations[0].Priority)
+	assert.Contains(t, report.Recommendations[0].Text, "Tracking stopped 5 days ago")
+	assert.Equal(t, "bounce", report.Recommendations[1].Check)
+	assert.Equal(t, "errors", report.Recommendations[2].Check)
+	assert.Equal(t, Medium, report.Recommendations[2].Priority)
+	assert.Contains(t, report.Recommendations[2].Text, "/old-pricing (25), /blog/typo (5)")
+	assert.Equal(t, "event_loss", report.Recommendations[3].Check)
+	assert.Equal(t, "blockers", report.Recommendations[4].Check)
+	assert.Equal(t, Low, report.Recommendations[4].Priority)
+}
+
+func TestScoreWithoutEvents(t *testing.T) {
+	report := Score("example.com", 7, Signals{}, time.Now())
+
+	assert.Equal(t, 0, report.Score)
+	assert.Equal(t, "F", report.Grade)
+	require.NotEmpty(t, report.Recommendations)
+	assert.Contains(t, report.Recommendations[0].Text, "kaunta website tracking-code example.com")
+}
+
+func TestCollect(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	websiteID := uuid.New()
+	last := time.Now().Add(-time.Hour)
+	mock.ExpectQuery(`SELECT MAX\(created_at\) FROM website_event`).WithArgs(websiteID).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(last))
+	summary := []string{"visitors", "pageviews", "bounce_rate", "avg_engagement"}
+	mock.ExpectQuery(`FROM events`).WithArgs(websiteID, 7).
+		WillReturnRows(sqlmock.NewRows(summary).AddRow(300, 1000, 42.5, 30.0))
+	mock.ExpectQuery(`FROM events`).WithArgs(websiteID, 7).
+		WillReturnRows(sqlmock.NewRows(summary).AddRow(280, 950, 40.0, 28.0))
+	mock.ExpectQuery(`ILIKE '%404%'`).WithArgs(websiteID, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"path", "views"}).
+			AddRow("/a", 9).AddRow("/b", 4).AddRow("/c", 2).AddRow("/d", 1))
+	mock.ExpectQuery(`FROM event_sequence s`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"domain", "pages", "sent", "received"}).
+			AddRow("other.example", 10, 40, 40).
+			AddRow("example.com", 100, 400, 380))
+	mock.ExpectQuery(`FROM baseline_hit b`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"domain", "pixel", "tracked"}).AddRow("example.com", 200, 150))
+
+	s, err := Collect(context.Background(), db, websiteID, "example.com", 7)
+	require.NoError(t, err)
+	require.NotNil(t, s.LastEvent)
+	assert.Equal(t, int64(1000), s.Current.Pageviews)
+	assert.Equal(t, 40.0, s.Previous.BounceRate)
+	assert.Equal(t, int64(16), s.ErrorViews)
+	assert.Len(t, s.ErrorPages, maxErrorPages)
+	assert.Equal(t, int64(400), s.EventsSent)
+	assert.Equal(t, int64(20), s.EventsLost)
+	require.NotNil(t, s.Blocking)
+	assert.Equal(t, 25.0, s.Blocking.BlockRate)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
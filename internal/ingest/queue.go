@@ -0,0 +1,316 @@
+// Package ingest buffers tracked events in memory and writes them to the
+// store in batches, so traffic spikes cost one multi-row INSERT per batch
+// instead of one round trip per request.
+//
+// The tracking handler enqueues events without waiting for the database. A
+// single background flusher writes a batch whenever BatchSize events are
+// waiting or FlushInterval has elapsed. Close stops accepting events and
+// drains whatever is still buffered.
+//
+// When the buffer fills up or writes slow down, a Sampler keeps one visitor
+// in N instead of letting events be lost at random. Past backPressureFill,
+// RetryAfter asks clients to send events again later.
+package ingest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/chaos"
+	"github.com/seuros/kaunta/internal/logging"
+	"github.com/seuros/kaunta/internal/store"
+	"github.com/seuros/kaunta/internal/tracing"
+)
+
+// ErrQueueFull is returned by Enqueue when the buffer is at capacity
+var ErrQueueFull = errors.New("ingest queue is full")
+
+// ErrClosed is returned by Enqueue after Close
+var ErrClosed = errors.New("ingest queue is closed")
+
+// Defaults used when Config fields are zero
+const (
+	DefaultQueueSize     = 10000
+	DefaultBatchSize     = 500
+	DefaultFlushInterval = 250 * time.Millisecond
+)
+
+// writeTimeout bounds a single batch write
+const writeTimeout = 30 * time.Second
+
+// backPressureFill is the share of the buffer in use from which new events
+// are deferred to the client instead of accepted
+const backPressureFill = 0.9
+
+// maxRetryAfter caps the retry hint given to clients
+const maxRetryAfter = time.Minute
+
+// Config tunes the queue
+type Config struct {
+	QueueSize     int           // events buffered before Enqueue reports ErrQueueFull
+	BatchSize     int           // events written per INSERT
+	FlushInterval time.Duration // maximum time an event waits in the buffer
+	Sampling      SamplingConfig
+}
+
+// WriteFunc persists a batch of events
+type WriteFunc func(ctx context.Context, events []*store.Event) error
+
+// Queue is a bounded in-memory event buffer with a background flusher
+type Queue struct {
+	cfg    Config
+	write  WriteFunc
+	events chan *store.Event
+	done   chan struct{}
+
+	// sampler is nil when adaptive sampling is disabled
+	sampler *Sampler
+	// latency is the duration of the latest batch write, in nanoseconds
+	latency atomic.Int64
+
+	mu      sync.RWMutex
+	closed  bool
+	started bool
+}
+
+// New returns a queue that writes batches with write. Call Start to begin flushing.
+func New(cfg Config, write WriteFunc) *Queue {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultQueueSize
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = DefaultFlushInterval
+	}
+	q := &Queue{
+		cfg:    cfg,
+		write:  write,
+		events: make(chan *store.Event, cfg.QueueSize),
+		done:   make(chan struct{}),
+	}
+	if !cfg.Sampling.Disabled {
+		q.sampler = NewSampler(cfg.Sampling)
+	}
+	return q
+}
+
+// Start launches the background flusher
+func (q *Queue) Start() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.started {
+		return
+	}
+	q.started = true
+	go q.run()
+}
+
+// Enqueue buffers an event without blocking
+func (q *Queue) Enqueue(e *store.Event) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrClosed
+	}
+	select {
+	case q.events <- e:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Len returns the number of buffered events
+func (q *Queue) Len() int {
+	return len(q.events)
+}
+
+// SampleRate returns the current adaptive sampling rate N (1 on a nil queue)
+func (q *Queue) SampleRate() int {
+	if q == nil {
+		return 1
+	}
+	return q.sampler.Rate()
+}
+
+// Sampling reports whether adaptive sampling is on (false on a nil queue)
+func (q *Queue) Sampling() bool {
+	return q != nil && q.sampler != nil
+}
+
+// Sample reports whether the visitor of sessionID is kept under the current
+// load, and the rate to record on their events. A nil queue keeps everyone.
+func (q *Queue) Sample(sessionID uuid.UUID) (rate int, keep bool) {
+	if q == nil {
+		return 1, true
+	}
+	return q.sampler.Keep(sessionID)
+}
+
+// RetryAfter returns how long clients should wait before sending events
+// again while the buffer is nearly full, and 0 when events are accepted. The
+// hint is the time the flusher needs to work through the backlog at its
+// current pace, in whole seconds between one second and maxRetryAfter.
+func (q *Queue) RetryAfter() time.Duration {
+	if q == nil {
+		return 0
+	}
+	pending := q.Len()
+	if float64(pending) < backPressureFill*float64(q.cfg.QueueSize) {
+		return 0
+	}
+	perBatch := max(q.cfg.FlushInterval, time.Duration(q.latency.Load()))
+	wait := time.Duration((pending+q.cfg.BatchSize-1)/q.cfg.BatchSize) * perBatch
+	wait = (wait + time.Second - 1).Truncate(time.Second)
+	deferredTotal.Inc()
+	return min(max(wait, time.Second), maxRetryAfter)
+}
+
+// observe reports the load to the sampler
+func (q *Queue) observe() {
+	q.sampler.Observe(time.Now(), float64(q.Len())/float64(q.cfg.QueueSize), time.Duration(q.latency.Load()))
+}
+
+// Close stops accepting events and waits until the buffer is drained or ctx
+// is done. Events still buffered when ctx expires are lost.
+func (q *Queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.events)
+	}
+	if !q.started {
+		// Never started: run the flusher just to drain the buffer
+		q.started = true
+		go q.run()
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		logging.L().Error("ingest queue drain timed out", zap.Int("pending", q.Len()))
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) run() {
+	defer close(q.done)
+
+	ticker := time.NewTicker(q.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*store.Event, 0, q.cfg.BatchSize)
+	for {
+		select {
+		case e, ok := <-q.events:
+			if !ok {
+				q.flush(batch)
+				return
+			}
+			batch = append(batch, e)
+			if len(batch) >= q.cfg.BatchSize {
+				q.flush(batch)
+				batch = make([]*store.Event, 0, q.cfg.BatchSize)
+				q.observe()
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				q.flush(batch)
+				batch = make([]*store.Event, 0, q.cfg.BatchSize)
+			} else {
+				q.latency.Store(0)
+			}
+			q.observe()
+		}
+	}
+}
+
+// flush writes a batch. If the batch fails (one bad row aborts a multi-row
+// INSERT) the events are retried one by one so only the bad rows are lost.
+func (q *Queue) flush(batch []*store.Event) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+
+	// One write serves many requests: link their spans instead of picking a parent
+	links := make([]trace.Link, 0, len(batch))
+	for _, e := range batch {
+		if e.SpanContext.IsValid() {
+			links = append(links, trace.Link{SpanContext: e.SpanContext})
+		}
+	}
+	ctx, span := tracing.Start(ctx, "ingest.flush",
+		trace.WithLinks(links...),
+		trace.WithAttributes(attribute.Int("kaunta.batch_size", len(batch))))
+	defer span.End()
+
+	start := time.Now()
+	err := q.writeEvents(ctx, batch)
+	q.latency.Store(int64(time.Since(start)))
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	logging.L().Warn("batch insert failed, retrying events individually",
+		zap.Int("events", len(batch)), zap.Error(err))
+
+	failed := 0
+	for _, e := range batch {
+		if err := q.writeEvents(ctx, []*store.Event{e}); err != nil {
+			failed++
+			logging.L().Error("failed to insert event",
+				zap.String("event_id", e.EventID.String()),
+				zap.String("website_id", e.WebsiteID.String()),
+				zap.Error(err))
+		}
+	}
+	span.SetAttributes(attribute.Int("kaunta.dropped", failed))
+	if failed > 0 {
+		span.SetStatus(codes.Error, "events dropped")
+		logging.L().Error("dropped events after batch failure",
+			zap.Int("failed", failed), zap.Int("batch", len(batch)))
+	}
+}
+
+// writeEvents writes events, unless failure injection drops the write
+func (q *Queue) writeEvents(ctx context.Context, events []*store.Event) error {
+	if err := chaos.DropWrite(); err != nil {
+		return err
+	}
+	return q.write(ctx, events)
+}
+
+var (
+	currentMu sync.RWMutex
+	current   *Queue
+)
+
+// Current returns the process-wide queue, or nil when events are written synchronously
+func Current() *Queue {
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	return current
+}
+
+// SetCurrent installs the process-wide queue (nil restores synchronous writes)
+func SetCurrent(q *Queue) {
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	current = q
+}
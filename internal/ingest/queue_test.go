@@ -0,0 +1,198 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/seuros/kaunta/internal/store"
+	"github.com/seuros/kaunta/internal/tracing"
+)
+
+// recorder collects the batches handed to the write function
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]*store.Event
+	fail    func(events []*store.Event) error
+}
+
+func (r *recorder) write(ctx context.Context, events []*store.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail != nil {
+		if err := r.fail(events); err != nil {
+			return err
+		}
+	}
+	r.batches = append(r.batches, append([]*store.Event(nil), events...))
+	return nil
+}
+
+func (r *recorder) sizes() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sizes := make([]int, len(r.batches))
+	for i, b := range r.batches {
+		sizes[i] = len(b)
+	}
+	return sizes
+}
+
+func (r *recorder) total() int {
+	n := 0
+	for _, size := range r.sizes() {
+		n += size
+	}
+	return n
+}
+
+func newEvent() *store.Event {
+	return &store.Event{EventID: uuid.New(), WebsiteID: uuid.New(), EventType: 1}
+}
+
+func TestQueueFlushesFullBatches(t *testing.T) {
+	rec := &recorder{}
+	q := New(Config{QueueSize: 100, BatchSize: 10, FlushInterval: time.Hour}, rec.write)
+	q.Start()
+
+	for i := 0; i < 25; i++ {
+		require.NoError(t, q.Enqueue(newEvent()))
+	}
+
+	assert.Eventually(t, func() bool { return rec.total() == 20 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []int{10, 10}, rec.sizes())
+
+	// The remainder is written on drain
+	require.NoError(t, q.Close(context.Background()))
+	assert.Equal(t, []int{10, 10, 5}, rec.sizes())
+}
+
+func TestQueueFlushesOnInterval(t *testing.T) {
+	rec := &recorder{}
+	q := New(Config{QueueSize: 100, BatchSize: 50, FlushInterval: 10 * time.Millisecond}, rec.write)
+	q.Start()
+	t.Cleanup(func() { _ = q.Close(context.Background()) })
+
+	require.NoError(t, q.Enqueue(newEvent()))
+	require.NoError(t, q.Enqueue(newEvent()))
+
+	assert.Eventually(t, func() bool { return rec.total() == 2 }, time.Second, 5*time.Millisecond)
+}
+
+func TestQueueReportsFullAndClosed(t *testing.T) {
+	rec := &recorder{}
+	q := New(Config{QueueSize: 2, BatchSize: 10, FlushInterval: time.Hour}, rec.write)
+
+	// Not started: nothing drains the buffer
+	require.NoError(t, q.Enqueue(newEvent()))
+	require.NoError(t, q.Enqueue(newEvent()))
+	assert.ErrorIs(t, q.Enqueue(newEvent()), ErrQueueFull)
+	assert.Equal(t, 2, q.Len())
+
+	require.NoError(t, q.Close(context.Background()))
+	assert.Equal(t, []int{2}, rec.sizes())
+	assert.ErrorIs(t, q.Enqueue(newEvent()), ErrClosed)
+
+	// Closing twice is harmless
+	require.NoError(t, q.Close(context.Background()))
+}
+
+func TestQueueRetryAfter(t *testing.T) {
+	q := New(Config{QueueSize: 20, BatchSize: 5, FlushInterval: time.Second}, (&recorder{}).write)
+	for i := 0; i < 17; i++ {
+		require.NoError(t, q.Enqueue(newEvent()))
+	}
+	assert.Zero(t, q.RetryAfter())
+
+	// 18 of 20 buffered: four batches to go, at one flush interval each
+	require.NoError(t, q.Enqueue(newEvent()))
+	assert.Equal(t, 4*time.Second, q.RetryAfter())
+
+	// Slow writes stretch the hint, up to the cap
+	q.latency.Store(int64(2500 * time.Millisecond))
+	assert.Equal(t, 10*time.Second, q.RetryAfter())
+	q.latency.Store(int64(time.Hour))
+	assert.Equal(t, maxRetryAfter, q.RetryAfter())
+
+	var nilQueue *Queue
+	assert.Zero(t, nilQueue.RetryAfter())
+}
+
+func TestQueueRetriesFailedBatchIndividually(t *testing.T) {
+	bad := newEvent()
+	rec := &recorder{fail: func(events []*store.Event) error {
+		for _, e := range events {
+			if e == bad {
+				return errors.New("invalid input syntax for type json")
+			}
+		}
+		return nil
+	}}
+	q := New(Config{QueueSize: 10, BatchSize: 3, FlushInterval: time.Hour}, rec.write)
+	q.Start()
+
+	require.NoError(t, q.Enqueue(newEvent()))
+	require.NoError(t, q.Enqueue(bad))
+	require.NoError(t, q.Enqueue(newEvent()))
+	require.NoError(t, q.Close(context.Background()))
+
+	// Only the good events made it, one per write
+	assert.Equal(t, []int{1, 1}, rec.sizes())
+}
+
+func TestQueueCloseRespectsDeadline(t *testing.T) {
+	release := make(chan struct{})
+	q := New(Config{QueueSize: 10, BatchSize: 1, FlushInterval: time.Hour}, func(ctx context.Context, events []*store.Event) error {
+		<-release
+		return nil
+	})
+	q.Start()
+	defer close(release)
+
+	require.NoError(t, q.Enqueue(newEvent()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.Close(ctx), context.DeadlineExceeded)
+}
+
+func TestNewAppliesDefaults(t *testing.T) {
+	q := New(Config{}, nil)
+	assert.Equal(t, DefaultQueueSize, cap(q.events))
+	assert.Equal(t, DefaultBatchSize, q.cfg.BatchSize)
+	assert.Equal(t, DefaultFlushInterval, q.cfg.FlushInterval)
+}
+
+func TestQueueFlushLinksRequestSpans(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	original := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	t.Cleanup(func() { otel.SetTracerProvider(original) })
+
+	rec := &recorder{}
+	q := New(Config{QueueSize: 10, BatchSize: 10, FlushInterval: time.Hour}, rec.write)
+	q.Start()
+
+	traced := newEvent()
+	traced.SpanContext = trace.SpanContextFromContext(
+		tracing.WithTraceparent(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"))
+	require.NoError(t, q.Enqueue(traced))
+	require.NoError(t, q.Enqueue(newEvent()))
+	require.NoError(t, q.Close(context.Background()))
+
+	ended := spans.Ended()
+	require.Len(t, ended, 1)
+	assert.Equal(t, "ingest.flush", ended[0].Name())
+	require.Len(t, ended[0].Links(), 1, "only events with a trace are linked")
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", ended[0].Links()[0].SpanContext.TraceID().String())
+}
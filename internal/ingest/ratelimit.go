@@ -0,0 +1,165 @@
+package ingest
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Limits a request can run into, as reported by RateLimiter.Allow
+const (
+	LimitIP      = "ip"
+	LimitWebsite = "website"
+)
+
+// DefaultRateLimitIP is the tracking requests a client IP may send per
+// minute unless configured otherwise
+const DefaultRateLimitIP = 300
+
+var rateLimitedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "kaunta",
+	Subsystem: "ingest",
+	Name:      "rate_limited_total",
+	Help:      "Tracking requests rejected with 429 because a client IP or a website sent too many.",
+}, []string{"limit"})
+
+// RateLimiter limits tracking requests per client IP and per website, each
+// with a token bucket holding a minute's worth of requests and refilled
+// continuously: clients can burst up to the limit, then send at its rate.
+// Limits are requests per minute; 0 means unlimited. Buckets live in
+// memory, so limits are per server process.
+type RateLimiter struct {
+	perIP      int
+	perWebsite int
+	now        func() time.Time
+
+	mu        sync.Mutex
+	ips       map[string]*bucket
+	websites  map[uuid.UUID]*bucket
+	lastSweep time.Time
+}
+
+// bucket holds the tokens left at updated
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewRateLimiter limits clients to perIP and websites to perWebsite
+// requests per minute; nil when both are unlimited
+func NewRateLimiter(perIP, perWebsite int) *RateLimiter {
+	if perIP <= 0 && perWebsite <= 0 {
+		return nil
+	}
+	return newRateLimiter(perIP, perWebsite, time.Now)
+}
+
+func newRateLimiter(perIP, perWebsite int, now func() time.Time) *RateLimiter {
+	return &RateLimiter{
+		perIP:      perIP,
+		perWebsite: perWebsite,
+		now:        now,
+		ips:        make(map[string]*bucket),
+		websites:   make(map[uuid.UUID]*bucket),
+	}
+}
+
+// Allow takes a token for the request from the IP's and the website's
+// buckets when both have one. Otherwise nothing is taken and it returns the
+// limit reached with the wait until a token is back. An empty ip is only
+// limited per website (server-side relays sending for many visitors).
+func (l *RateLimiter) Allow(ip string, websiteID uuid.UUID) (string, time.Duration) {
+	if l == nil {
+		return "", 0
+	}
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	var ipBucket, websiteBucket *bucket
+	if l.perIP > 0 && ip != "" {
+		ipBucket = fill(l.ips, ip, l.perIP, now)
+		if ipBucket.tokens < 1 {
+			rateLimitedTotal.WithLabelValues(LimitIP).Inc()
+			return LimitIP, wait(ipBucket, l.perIP)
+		}
+	}
+	if l.perWebsite > 0 {
+		websiteBucket = fill(l.websites, websiteID, l.perWebsite, now)
+		if websiteBucket.tokens < 1 {
+			rateLimitedTotal.WithLabelValues(LimitWebsite).Inc()
+			return LimitWebsite, wait(websiteBucket, l.perWebsite)
+		}
+	}
+	if ipBucket != nil {
+		ipBucket.tokens--
+	}
+	if websiteBucket != nil {
+		websiteBucket.tokens--
+	}
+	return "", 0
+}
+
+// fill returns the bucket of key, refilled for the time since it was last
+// used; new buckets start full
+func fill[K comparable](buckets map[K]*bucket, key K, perMinute int, now time.Time) *bucket {
+	b := buckets[key]
+	if b == nil {
+		b = &bucket{tokens: float64(perMinute), updated: now}
+		buckets[key] = b
+		return b
+	}
+	refill := now.Sub(b.updated).Minutes() * float64(perMinute)
+	b.tokens = math.Min(float64(perMinute), b.tokens+refill)
+	b.updated = now
+	return b
+}
+
+// wait is how long until b has a token again, rounded up to a second
+func wait(b *bucket, perMinute int) time.Duration {
+	seconds := math.Ceil((1 - b.tokens) * 60 / float64(perMinute))
+	return time.Duration(math.Max(seconds, 1)) * time.Second
+}
+
+// sweep forgets the buckets that have refilled, at most once a minute
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.ips {
+		if now.Sub(b.updated) >= time.Minute {
+			delete(l.ips, key)
+		}
+	}
+	for key, b := range l.websites {
+		if now.Sub(b.updated) >= time.Minute {
+			delete(l.websites, key)
+		}
+	}
+}
+
+var (
+	limiterMu      sync.RWMutex
+	currentLimiter *RateLimiter
+)
+
+// CurrentRateLimiter returns the process-wide rate limiter, nil when
+// tracking requests aren't limited
+func CurrentRateLimiter() *RateLimiter {
+	limiterMu.RLock()
+	defer limiterMu.RUnlock()
+	return currentLimiter
+}
+
+// SetRateLimiter installs the process-wide rate limiter (nil for none)
+func SetRateLimiter(l *RateLimiter) {
+	limiterMu.Lock()
+	defer limiterMu.Unlock()
+	currentLimiter = l
+}
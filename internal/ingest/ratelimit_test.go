@@ -0,0 +1,70 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterPerIP(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	l := newRateLimiter(2, 0, func() time.Time { return now })
+	websiteID := uuid.New()
+
+	for i := 0; i < 2; i++ {
+		limit, _ := l.Allow("203.0.113.7", websiteID)
+		assert.Empty(t, limit)
+	}
+	limit, wait := l.Allow("203.0.113.7", websiteID)
+	assert.Equal(t, LimitIP, limit)
+	assert.Equal(t, 30*time.Second, wait)
+
+	// Other clients have their own bucket, relays have none
+	limit, _ = l.Allow("203.0.113.8", websiteID)
+	assert.Empty(t, limit)
+	limit, _ = l.Allow("", websiteID)
+	assert.Empty(t, limit)
+
+	// The bucket refills at the limit's rate
+	now = now.Add(30 * time.Second)
+	limit, _ = l.Allow("203.0.113.7", websiteID)
+	assert.Empty(t, limit)
+	limit, _ = l.Allow("203.0.113.7", websiteID)
+	assert.Equal(t, LimitIP, limit)
+}
+
+func TestRateLimiterPerWebsite(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	l := newRateLimiter(10, 1, func() time.Time { return now })
+	websiteID := uuid.New()
+
+	limit, _ := l.Allow("203.0.113.7", websiteID)
+	assert.Empty(t, limit)
+	limit, wait := l.Allow("203.0.113.8", websiteID)
+	assert.Equal(t, LimitWebsite, limit)
+	assert.Equal(t, time.Minute, wait)
+
+	// A rejected request costs the client nothing
+	assert.Equal(t, 9.0, l.ips["203.0.113.7"].tokens)
+	assert.Equal(t, 10.0, l.ips["203.0.113.8"].tokens)
+
+	limit, _ = l.Allow("203.0.113.8", uuid.New())
+	assert.Empty(t, limit)
+
+	// Refilled buckets are forgotten
+	now = now.Add(2 * time.Minute)
+	l.Allow("203.0.113.9", uuid.New())
+	assert.Len(t, l.ips, 1)
+	assert.Len(t, l.websites, 1)
+}
+
+func TestRateLimiterUnlimited(t *testing.T) {
+	assert.Nil(t, NewRateLimiter(0, 0))
+
+	var l *RateLimiter
+	limit, wait := l.Allow("203.0.113.7", uuid.New())
+	assert.Empty(t, limit)
+	assert.Zero(t, wait)
+}
@@ -0,0 +1,167 @@
+package ingest
+
+import (
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/logging"
+)
+
+// Sampling defaults used when SamplingConfig fields are zero
+const (
+	DefaultSamplingQueueThreshold   = 0.5
+	DefaultSamplingLatencyThreshold = 2 * time.Second
+	DefaultSamplingMaxRate          = 16
+	DefaultSamplingCooldown         = 30 * time.Second
+)
+
+// raiseInterval spaces out rate increases so one slow batch doesn't jump
+// straight to the maximum
+const raiseInterval = time.Second
+
+// SamplingConfig tunes adaptive sampling
+type SamplingConfig struct {
+	Disabled         bool
+	QueueThreshold   float64       // share of the buffer in use that counts as overload
+	LatencyThreshold time.Duration // batch write time that counts as overload
+	MaxRate          int           // highest N of "keep one visitor in N", a power of two
+	Cooldown         time.Duration // time without overload before N is halved
+}
+
+var (
+	sampledOutTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "kaunta",
+		Subsystem: "ingest",
+		Name:      "sampled_out_total",
+		Help:      "Events not stored because adaptive sampling was active.",
+	})
+	deferredTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "kaunta",
+		Subsystem: "ingest",
+		Name:      "deferred_total",
+		Help:      "Events the client was asked to send again later because the buffer was nearly full.",
+	})
+	sampleRateGauge = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "kaunta",
+		Subsystem: "ingest",
+		Name:      "sample_rate",
+		Help:      "Current adaptive sampling rate N (one visitor in N is kept); 1 means full fidelity.",
+	}, func() float64 {
+		return float64(Current().SampleRate())
+	})
+	queueLengthGauge = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "kaunta",
+		Subsystem: "ingest",
+		Name:      "queue_length",
+		Help:      "Events buffered and waiting to be written.",
+	}, func() float64 {
+		if q := Current(); q != nil {
+			return float64(q.Len())
+		}
+		return 0
+	})
+)
+
+// Collectors returns the ingestion queue metrics
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{sampledOutTotal, deferredTotal, rateLimitedTotal, sampleRateGauge, queueLengthGauge}
+}
+
+// Sampler decides which visitors to keep while ingestion is overloaded.
+//
+// When the buffer fills past QueueThreshold or a batch write takes longer
+// than LatencyThreshold, the rate N doubles (at most once per second, up to
+// MaxRate) and only one visitor in N is kept. Visitors are picked by their
+// session ID, so a kept visitor keeps all their events and, N being a power
+// of two, stays kept as N grows. Once the store has kept up for Cooldown, N
+// halves again step by step. Stored events record their N, so totals can be
+// estimated with SUM(sample_rate).
+type Sampler struct {
+	cfg  SamplingConfig
+	rate atomic.Int64
+
+	mu         sync.Mutex
+	changed    time.Time // last change of rate
+	overloaded time.Time // last overload seen
+}
+
+// NewSampler returns a sampler at full fidelity
+func NewSampler(cfg SamplingConfig) *Sampler {
+	if cfg.QueueThreshold <= 0 {
+		cfg.QueueThreshold = DefaultSamplingQueueThreshold
+	}
+	if cfg.LatencyThreshold <= 0 {
+		cfg.LatencyThreshold = DefaultSamplingLatencyThreshold
+	}
+	if cfg.MaxRate <= 0 {
+		cfg.MaxRate = DefaultSamplingMaxRate
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = DefaultSamplingCooldown
+	}
+	s := &Sampler{cfg: cfg}
+	s.rate.Store(1)
+	return s
+}
+
+// Rate returns the current N; 1 when every event is kept
+func (s *Sampler) Rate() int {
+	if s == nil {
+		return 1
+	}
+	return int(s.rate.Load())
+}
+
+// Keep reports whether the visitor with session key is kept, and the rate
+// to record on their events
+func (s *Sampler) Keep(key uuid.UUID) (rate int, keep bool) {
+	rate = s.Rate()
+	if rate <= 1 {
+		return 1, true
+	}
+	if binary.BigEndian.Uint32(key[:4])%uint32(rate) != 0 {
+		sampledOutTotal.Inc()
+		return rate, false
+	}
+	return rate, true
+}
+
+// Observe feeds the sampler the buffer fill (0-1) and the duration of the
+// latest batch write, and adjusts the rate
+func (s *Sampler) Observe(now time.Time, fill float64, latency time.Duration) {
+	if s == nil || s.cfg.Disabled {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rate := s.rate.Load()
+	if fill >= s.cfg.QueueThreshold || latency >= s.cfg.LatencyThreshold {
+		s.overloaded = now
+		if rate*2 <= int64(s.cfg.MaxRate) && now.Sub(s.changed) >= raiseInterval {
+			s.rate.Store(rate * 2)
+			s.changed = now
+			logging.L().Warn("ingestion overloaded, sampling events to keep up",
+				zap.Int64("sample_rate", rate*2),
+				zap.Float64("queue_fill", fill),
+				zap.Duration("write_latency", latency))
+		}
+		return
+	}
+
+	if rate > 1 && now.Sub(s.overloaded) >= s.cfg.Cooldown && now.Sub(s.changed) >= s.cfg.Cooldown {
+		s.rate.Store(rate / 2)
+		s.changed = now
+		if rate/2 == 1 {
+			logging.L().Info("ingestion recovered, sampling stopped")
+		} else {
+			logging.L().Info("ingestion recovering, lowering sample rate", zap.Int64("sample_rate", rate/2))
+		}
+	}
+}
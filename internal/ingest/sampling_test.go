@@ -0,0 +1,86 @@
+package ingest
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// sessionKey returns a session ID whose sampling bucket is n
+func sessionKey(n uint32) uuid.UUID {
+	var id uuid.UUID
+	binary.BigEndian.PutUint32(id[:4], n)
+	return id
+}
+
+func TestSamplerRaisesRateUnderLoad(t *testing.T) {
+	s := NewSampler(SamplingConfig{MaxRate: 8})
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	s.Observe(start, 0.1, 10*time.Millisecond)
+	assert.Equal(t, 1, s.Rate())
+
+	s.Observe(start, 0.6, 0)
+	assert.Equal(t, 2, s.Rate())
+	// At most one step per second
+	s.Observe(start.Add(500*time.Millisecond), 0.9, 0)
+	assert.Equal(t, 2, s.Rate())
+
+	s.Observe(start.Add(time.Second), 0, 3*time.Second)
+	assert.Equal(t, 4, s.Rate())
+	s.Observe(start.Add(2*time.Second), 1, 0)
+	s.Observe(start.Add(3*time.Second), 1, 0)
+	assert.Equal(t, 8, s.Rate(), "capped at MaxRate")
+}
+
+func TestSamplerLowersRateAfterCooldown(t *testing.T) {
+	s := NewSampler(SamplingConfig{Cooldown: 10 * time.Second})
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	s.Observe(start, 1, 0)
+	s.Observe(start.Add(time.Second), 1, 0)
+	assert.Equal(t, 4, s.Rate())
+
+	s.Observe(start.Add(5*time.Second), 0, 0)
+	assert.Equal(t, 4, s.Rate(), "still cooling down")
+	s.Observe(start.Add(11*time.Second), 0, 0)
+	assert.Equal(t, 2, s.Rate())
+	s.Observe(start.Add(15*time.Second), 0, 0)
+	assert.Equal(t, 2, s.Rate(), "one step per cooldown")
+	s.Observe(start.Add(21*time.Second), 0, 0)
+	assert.Equal(t, 1, s.Rate())
+}
+
+func TestSamplerKeepsWholeVisitors(t *testing.T) {
+	s := NewSampler(SamplingConfig{})
+	rate, keep := s.Keep(sessionKey(3))
+	assert.Equal(t, 1, rate)
+	assert.True(t, keep)
+
+	s.rate.Store(4)
+	rate, keep = s.Keep(sessionKey(8))
+	assert.Equal(t, 4, rate)
+	assert.True(t, keep)
+	_, keep = s.Keep(sessionKey(6))
+	assert.False(t, keep)
+
+	// A visitor kept at N is kept at N/2 too
+	s.rate.Store(2)
+	_, keep = s.Keep(sessionKey(8))
+	assert.True(t, keep)
+}
+
+func TestQueueWithoutSampling(t *testing.T) {
+	q := New(Config{Sampling: SamplingConfig{Disabled: true}}, (&recorder{}).write)
+	q.sampler.Observe(time.Now(), 1, time.Minute)
+	assert.Equal(t, 1, q.SampleRate())
+	rate, keep := q.Sample(sessionKey(1))
+	assert.Equal(t, 1, rate)
+	assert.True(t, keep)
+
+	var nilQueue *Queue
+	_, keep = nilQueue.Sample(sessionKey(1))
+	assert.True(t, keep)
+}
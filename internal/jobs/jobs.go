@@ -0,0 +1,334 @@
+// Package jobs is a durable PostgreSQL-backed job queue for work that must
+// not be lost on a transient failure: webhooks, report emails, exports and
+// imports.
+//
+// Producers call Enqueue with a kind and a JSON payload. Workers claim due
+// jobs with FOR UPDATE SKIP LOCKED, so any number of processes can drain the
+// queue concurrently. A failed job is retried with exponential backoff until
+// it has used max_attempts, then parked with status 'dead' where
+// `kaunta jobs retry` can revive it.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Job statuses
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusDead      = "dead"
+)
+
+// DefaultMaxAttempts is used when EnqueueOptions.MaxAttempts is zero
+const DefaultMaxAttempts = 5
+
+// Backoff bounds: the first retry waits baseBackoff, doubling up to maxBackoff
+const (
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+)
+
+// staleAfter is how long a running job may go without finishing before
+// another worker assumes its owner crashed and claims it again
+const staleAfter = 15 * time.Minute
+
+// Job is one row of the jobs table
+type Job struct {
+	ID          uuid.UUID       `json:"job_id"`
+	Kind        string          `json:"kind"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	LastError   *string         `json:"last_error,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+}
+
+// Handler performs a job. Returning an error schedules a retry.
+type Handler func(ctx context.Context, job *Job) error
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = map[string]Handler{}
+)
+
+// Register installs the handler for a job kind
+func Register(kind string, h Handler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers[kind] = h
+}
+
+// Kinds returns the registered job kinds
+func Kinds() []string {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	kinds := make([]string, 0, len(handlers))
+	for kind := range handlers {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+func handlerFor(kind string) (Handler, bool) {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	h, ok := handlers[kind]
+	return h, ok
+}
+
+// EnqueueOptions tunes a single job
+type EnqueueOptions struct {
+	MaxAttempts int       // 0 uses DefaultMaxAttempts
+	RunAt       time.Time // zero runs as soon as possible
+}
+
+// Enqueue adds a job. payload is encoded as JSON.
+func Enqueue(ctx context.Context, db *sql.DB, kind string, payload interface{}, opts EnqueueOptions) (uuid.UUID, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to encode job payload: %w", err)
+	}
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	var runAt interface{}
+	if !opts.RunAt.IsZero() {
+		runAt = opts.RunAt
+	}
+
+	var id uuid.UUID
+	err = db.QueryRowContext(ctx, `
+		INSERT INTO jobs (kind, payload, max_attempts, run_at)
+		VALUES ($1, $2, $3, COALESCE($4, NOW()))
+		RETURNING job_id
+	`, kind, string(data), maxAttempts, runAt).Scan(&id)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to enqueue %s job: %w", kind, err)
+	}
+	return id, nil
+}
+
+// Backoff returns the delay before retrying after the given attempt (1-based)
+func Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := float64(baseBackoff) * math.Pow(2, float64(attempt-1))
+	if delay > float64(maxBackoff) {
+		return maxBackoff
+	}
+	return time.Duration(delay)
+}
+
+// Claim marks up to limit due jobs as running for workerID and returns them.
+// Jobs stuck in running for longer than staleAfter are reclaimed.
+func Claim(ctx context.Context, db *sql.DB, workerID string, limit int) ([]*Job, error) {
+	rows, err := db.QueryContext(ctx, `
+		UPDATE jobs
+		SET status = 'running', attempts = attempts + 1,
+		    locked_by = $1, locked_at = NOW(), updated_at = NOW()
+		WHERE job_id IN (
+			SELECT job_id FROM jobs
+			WHERE (status = 'pending' AND run_at <= NOW())
+			   OR (status = 'running' AND locked_at < NOW() - make_interval(secs => $3))
+			ORDER BY run_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING job_id, kind, payload, attempts, max_attempts, run_at, created_at
+	`, workerID, limit, staleAfter.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim jobs: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var claimed []*Job
+	for rows.Next() {
+		job := &Job{Status: StatusRunning}
+		var payload []byte
+		if err := rows.Scan(&job.ID, &job.Kind, &payload, &job.Attempts, &job.MaxAttempts, &job.RunAt, &job.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		job.Payload = payload
+		claimed = append(claimed, job)
+	}
+	return claimed, rows.Err()
+}
+
+// complete marks a job as succeeded
+func complete(ctx context.Context, db *sql.DB, id uuid.UUID) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE jobs
+		SET status = 'succeeded', finished_at = NOW(), updated_at = NOW(),
+		    locked_by = NULL, locked_at = NULL
+		WHERE job_id = $1
+	`, id)
+	return err
+}
+
+// fail records a failed attempt, scheduling a retry or parking the job as dead.
+// It returns the status the job ended up in.
+func fail(ctx context.Context, db *sql.DB, job *Job, cause error) (string, error) {
+	status := StatusPending
+	var finished interface{}
+	if job.Attempts >= job.MaxAttempts {
+		status = StatusDead
+		finished = time.Now()
+	}
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE jobs
+		SET status = $2, last_error = $3, run_at = NOW() + make_interval(secs => $4),
+		    finished_at = $5, updated_at = NOW(), locked_by = NULL, locked_at = NULL
+		WHERE job_id = $1
+	`, job.ID, status, cause.Error(), Backoff(job.Attempts).Seconds(), finished)
+	return status, err
+}
+
+// Retry moves dead jobs back to pending with a fresh attempt budget. With ids
+// set only those jobs are revived; otherwise kind (if not empty) narrows the
+// set of dead jobs.
+func Retry(ctx context.Context, db *sql.DB, ids []uuid.UUID, kind string) (int64, error) {
+	query := `
+		UPDATE jobs
+		SET status = 'pending', attempts = 0, run_at = NOW(),
+		    finished_at = NULL, updated_at = NOW()
+		WHERE status = 'dead'`
+	var args []interface{}
+	if len(ids) > 0 {
+		strIDs := make([]string, len(ids))
+		for i, id := range ids {
+			strIDs[i] = id.String()
+		}
+		args = append(args, pq.Array(strIDs))
+		query += fmt.Sprintf(" AND job_id = ANY($%d::uuid[])", len(args))
+	}
+	if kind != "" {
+		args = append(args, kind)
+		query += fmt.Sprintf(" AND kind = $%d", len(args))
+	}
+
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retry jobs: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// Purge deletes finished jobs in the given statuses (succeeded and/or dead)
+// that finished more than olderThan ago
+func Purge(ctx context.Context, db *sql.DB, statuses []string, olderThan time.Duration) (int64, error) {
+	for _, status := range statuses {
+		if status != StatusSucceeded && status != StatusDead {
+			return 0, fmt.Errorf("only succeeded and dead jobs can be purged, not %q", status)
+		}
+	}
+
+	res, err := db.ExecContext(ctx, `
+		DELETE FROM jobs
+		WHERE status = ANY($1::text[])
+		  AND COALESCE(finished_at, updated_at) < NOW() - make_interval(secs => $2)
+	`, pq.Array(statuses), olderThan.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge jobs: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// Count is the number of jobs of one kind in one status
+type Count struct {
+	Kind   string `json:"kind"`
+	Status string `json:"status"`
+	Jobs   int64  `json:"jobs"`
+}
+
+// Counts returns job counts grouped by kind and status
+func Counts(ctx context.Context, db *sql.DB) ([]Count, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT kind, status, COUNT(*)
+		FROM jobs
+		GROUP BY kind, status
+		ORDER BY kind, status
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count jobs: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var counts []Count
+	for rows.Next() {
+		var c Count
+		if err := rows.Scan(&c.Kind, &c.Status, &c.Jobs); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// ListOptions filters List
+type ListOptions struct {
+	Status string
+	Kind   string
+	Limit  int
+}
+
+// List returns jobs, most recently updated first
+func List(ctx context.Context, db *sql.DB, opts ListOptions) ([]*Job, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT job_id, kind, payload, status, attempts, max_attempts, run_at,
+		       last_error, created_at, updated_at, finished_at
+		FROM jobs
+		WHERE ($1::text IS NULL OR status = $1)
+		  AND ($2::text IS NULL OR kind = $2)
+		ORDER BY updated_at DESC
+		LIMIT $3
+	`, nullable(opts.Status), nullable(opts.Kind), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var list []*Job
+	for rows.Next() {
+		job := &Job{}
+		var payload []byte
+		if err := rows.Scan(&job.ID, &job.Kind, &payload, &job.Status, &job.Attempts, &job.MaxAttempts,
+			&job.RunAt, &job.LastError, &job.CreatedAt, &job.UpdatedAt, &job.FinishedAt); err != nil {
+			return nil, err
+		}
+		job.Payload = payload
+		list = append(list, job)
+	}
+	return list, rows.Err()
+}
+
+func nullable(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,141 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/test"
+)
+
+func claimRows(jobs ...*Job) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"job_id", "kind", "payload", "attempts", "max_attempts", "run_at", "created_at"})
+	for _, j := range jobs {
+		rows.AddRow(j.ID, j.Kind, []byte(j.Payload), j.Attempts, j.MaxAttempts, time.Now(), time.Now())
+	}
+	return rows
+}
+
+func TestEnqueue(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	id := uuid.New()
+
+	mock.ExpectQuery(`INSERT INTO jobs`).
+		WithArgs("webhook", `{"url":"https://example.com/hook"}`, DefaultMaxAttempts, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}).AddRow(id))
+
+	got, err := Enqueue(context.Background(), db, "webhook", map[string]string{"url": "https://example.com/hook"}, EnqueueOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, id, got)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, Backoff(0))
+	assert.Equal(t, 30*time.Second, Backoff(1))
+	assert.Equal(t, time.Minute, Backoff(2))
+	assert.Equal(t, 4*time.Minute, Backoff(4))
+	assert.Equal(t, 6*time.Hour, Backoff(20))
+}
+
+func TestProcessAvailable(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+
+	ok := &Job{ID: uuid.New(), Kind: "test-ok", Payload: []byte(`{}`), Attempts: 1, MaxAttempts: 5}
+	retry := &Job{ID: uuid.New(), Kind: "test-fail", Payload: []byte(`{}`), Attempts: 2, MaxAttempts: 5}
+	dead := &Job{ID: uuid.New(), Kind: "test-fail", Payload: []byte(`{}`), Attempts: 5, MaxAttempts: 5}
+	unknown := &Job{ID: uuid.New(), Kind: "test-unknown", Payload: []byte(`{}`), Attempts: 1, MaxAttempts: 5}
+
+	Register("test-ok", func(ctx context.Context, job *Job) error { return nil })
+	Register("test-fail", func(ctx context.Context, job *Job) error { return errors.New("smtp: connection refused") })
+
+	mock.ExpectQuery(`UPDATE jobs\s+SET status = 'running'`).
+		WithArgs("worker-1", 10, staleAfter.Seconds()).
+		WillReturnRows(claimRows(ok, retry, dead, unknown))
+	mock.ExpectExec(`SET status = 'succeeded'`).WithArgs(ok.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`SET status = \$2, last_error = \$3`).
+		WithArgs(retry.ID, StatusPending, "smtp: connection refused", Backoff(2).Seconds(), nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`SET status = \$2, last_error = \$3`).
+		WithArgs(dead.ID, StatusDead, "smtp: connection refused", Backoff(5).Seconds(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`SET status = \$2, last_error = \$3`).
+		WithArgs(unknown.ID, StatusPending, `no handler registered for job kind "test-unknown"`, sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`UPDATE jobs\s+SET status = 'running'`).WillReturnRows(claimRows())
+
+	deadBefore := testutil.ToFloat64(processedTotal.WithLabelValues("test-fail", StatusDead))
+
+	p := &Processor{DB: db, WorkerID: "worker-1"}
+	n, err := p.ProcessAvailable(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, deadBefore+1, testutil.ToFloat64(processedTotal.WithLabelValues("test-fail", StatusDead)))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProcessRecoversPanics(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	job := &Job{ID: uuid.New(), Kind: "test-panic", Payload: []byte(`{}`), Attempts: 1, MaxAttempts: 1}
+	Register("test-panic", func(ctx context.Context, job *Job) error { panic("nil map") })
+
+	mock.ExpectExec(`SET status = \$2, last_error = \$3`).
+		WithArgs(job.ID, StatusDead, "job panicked: nil map", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	(&Processor{DB: db}).process(context.Background(), job)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRetry(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	id := uuid.New()
+
+	mock.ExpectExec(`WHERE status = 'dead' AND job_id = ANY\(\$1::uuid\[\]\) AND kind = \$2`).
+		WithArgs(sqlmock.AnyArg(), "webhook").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	n, err := Retry(context.Background(), db, []uuid.UUID{id}, "webhook")
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+
+	mock.ExpectExec(`WHERE status = 'dead'$`).WillReturnResult(sqlmock.NewResult(0, 7))
+	n, err = Retry(context.Background(), db, nil, "")
+	require.NoError(t, err)
+	assert.EqualValues(t, 7, n)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurge(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+
+	mock.ExpectExec(`DELETE FROM jobs`).
+		WithArgs(sqlmock.AnyArg(), (7 * 24 * time.Hour).Seconds()).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	n, err := Purge(context.Background(), db, []string{StatusSucceeded, StatusDead}, 7*24*time.Hour)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, n)
+
+	_, err = Purge(context.Background(), db, []string{StatusPending}, 0)
+	assert.EqualError(t, err, `only succeeded and dead jobs can be purged, not "pending"`)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBacklogCollector(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	mock.ExpectQuery(`SELECT kind, status, COUNT\(\*\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"kind", "status", "count"}).
+			AddRow("webhook", "dead", 2).
+			AddRow("webhook", "pending", 5))
+
+	assert.Equal(t, 2, testutil.CollectAndCount(&backlogCollector{db: db}, "kaunta_jobs"))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
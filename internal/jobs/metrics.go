@@ -0,0 +1,60 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/logging"
+)
+
+// processedTotal counts job attempts by outcome: succeeded, pending (will
+// retry) or dead
+var processedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "kaunta",
+	Subsystem: "jobs",
+	Name:      "processed_total",
+	Help:      "Job attempts by kind and resulting status.",
+}, []string{"kind", "result"})
+
+// Collectors returns the job queue metrics. backlog reads the jobs table on
+// every scrape; pass a nil db to export only the in-process counters.
+func Collectors(db *sql.DB) []prometheus.Collector {
+	collectors := []prometheus.Collector{processedTotal}
+	if db != nil {
+		collectors = append(collectors, &backlogCollector{db: db})
+	}
+	return collectors
+}
+
+var backlogDesc = prometheus.NewDesc(
+	"kaunta_jobs",
+	"Jobs in the queue by kind and status.",
+	[]string{"kind", "status"}, nil,
+)
+
+// backlogCollector exports the per-status job counts
+type backlogCollector struct {
+	db *sql.DB
+}
+
+func (c *backlogCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- backlogDesc
+}
+
+func (c *backlogCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	counts, err := Counts(ctx, c.db)
+	if err != nil {
+		logging.L().Warn("failed to collect job metrics", zap.Error(err))
+		return
+	}
+	for _, cnt := range counts {
+		ch <- prometheus.MustNewConstMetric(backlogDesc, prometheus.GaugeValue, float64(cnt.Jobs), cnt.Kind, cnt.Status)
+	}
+}
@@ -0,0 +1,107 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/logging"
+)
+
+// DefaultBatch is how many jobs a processor claims per poll
+const DefaultBatch = 10
+
+// jobTimeout bounds a single handler run
+const jobTimeout = 5 * time.Minute
+
+// Processor claims due jobs and runs their handlers
+type Processor struct {
+	DB       *sql.DB
+	WorkerID string // recorded in locked_by; defaults to hostname:pid
+	Batch    int
+}
+
+func (p *Processor) workerID() string {
+	if p.WorkerID != "" {
+		return p.WorkerID
+	}
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}
+
+// ProcessAvailable runs every due job, claiming Batch at a time, until the
+// queue has nothing due. It returns the number of jobs attempted.
+func (p *Processor) ProcessAvailable(ctx context.Context) (int, error) {
+	batch := p.Batch
+	if batch <= 0 {
+		batch = DefaultBatch
+	}
+
+	processed := 0
+	for ctx.Err() == nil {
+		claimed, err := Claim(ctx, p.DB, p.workerID(), batch)
+		if err != nil {
+			return processed, err
+		}
+		if len(claimed) == 0 {
+			return processed, nil
+		}
+		for _, job := range claimed {
+			p.process(ctx, job)
+			processed++
+		}
+	}
+	return processed, ctx.Err()
+}
+
+func (p *Processor) process(ctx context.Context, job *Job) {
+	err := p.run(ctx, job)
+	if err == nil {
+		if err := complete(ctx, p.DB, job.ID); err != nil {
+			logging.L().Error("failed to mark job succeeded", zap.String("job_id", job.ID.String()), zap.Error(err))
+		}
+		processedTotal.WithLabelValues(job.Kind, StatusSucceeded).Inc()
+		return
+	}
+
+	status, ferr := fail(ctx, p.DB, job, err)
+	if ferr != nil {
+		logging.L().Error("failed to record job failure", zap.String("job_id", job.ID.String()), zap.Error(ferr))
+		return
+	}
+	processedTotal.WithLabelValues(job.Kind, status).Inc()
+
+	fields := []zap.Field{
+		zap.String("job_id", job.ID.String()),
+		zap.String("kind", job.Kind),
+		zap.Int("attempt", job.Attempts),
+		zap.Int("max_attempts", job.MaxAttempts),
+		zap.Error(err),
+	}
+	if status == StatusDead {
+		logging.L().Error("job moved to dead-letter", fields...)
+	} else {
+		logging.L().Warn("job failed, will retry", append(fields, zap.Duration("backoff", Backoff(job.Attempts)))...)
+	}
+}
+
+func (p *Processor) run(ctx context.Context, job *Job) (err error) {
+	h, ok := handlerFor(job.Kind)
+	if !ok {
+		return fmt.Errorf("no handler registered for job kind %q", job.Kind)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, jobTimeout)
+	defer cancel()
+	return h(ctx, job)
+}
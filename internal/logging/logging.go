@@ -23,6 +23,24 @@ func L() *zap.Logger {
 	return logger
 }
 
+// Configure replaces the shared logger with one using the given level
+// (debug, info, warn, error) and format (console or json). Empty values fall
+// back to KAUNTA_LOG_LEVEL and KAUNTA_LOG_FORMAT. Call it before the logger is
+// handed to long-lived components; they keep the logger they were given.
+func Configure(level, format string) {
+	initOnce.Do(func() {})
+	if level == "" {
+		level = os.Getenv("KAUNTA_LOG_LEVEL")
+	}
+	if format == "" {
+		format = os.Getenv("KAUNTA_LOG_FORMAT")
+	}
+	if logger != nil {
+		_ = logger.Sync()
+	}
+	logger = build(level, format)
+}
+
 // Sync flushes any buffered log entries
 func Sync() error {
 	if logger != nil {
@@ -32,14 +50,15 @@ func Sync() error {
 }
 
 func newLogger() *zap.Logger {
-	config := zap.NewProductionConfig()
+	return build(os.Getenv("KAUNTA_LOG_LEVEL"), os.Getenv("KAUNTA_LOG_FORMAT"))
+}
 
-	// Parse log level from environment
-	level := parseLevel(os.Getenv("KAUNTA_LOG_LEVEL"))
-	config.Level = zap.NewAtomicLevelAt(level)
+func build(level, format string) *zap.Logger {
+	config := zap.NewProductionConfig()
+	config.Level = zap.NewAtomicLevelAt(parseLevel(level))
 
 	// Configure encoder based on format
-	format := strings.ToLower(os.Getenv("KAUNTA_LOG_FORMAT"))
+	format = strings.ToLower(format)
 	if format == "json" || format == "structured" {
 		config.Encoding = "json"
 	} else {
@@ -61,3 +61,22 @@ func TestSync(t *testing.T) {
 	L()
 	_ = Sync() // Error is acceptable for stderr
 }
+
+func TestConfigureReplacesLogger(t *testing.T) {
+	resetLoggerForTest()
+	t.Setenv("KAUNTA_LOG_LEVEL", "")
+	t.Setenv("KAUNTA_LOG_FORMAT", "")
+
+	first := L()
+	Configure("warn", "json")
+	second := L()
+
+	assert.NotSame(t, first, second)
+	assert.False(t, second.Core().Enabled(zapcore.InfoLevel))
+	assert.True(t, second.Core().Enabled(zapcore.WarnLevel))
+
+	// Empty values fall back to the environment
+	t.Setenv("KAUNTA_LOG_LEVEL", "debug")
+	Configure("", "")
+	assert.True(t, L().Core().Enabled(zapcore.DebugLevel))
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// AccessLog writes one log entry per request with its route, status and
+// latency: server errors at error level, client errors at warn, the rest at
+// info. Healthchecks, metrics scrapes and static assets are skipped like in
+// Tracing. Visitor IPs are left out on purpose. Register it after Tracing so
+// entries carry the request's trace ID.
+func AccessLog(logger *zap.Logger) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		path := c.Path()
+		for _, prefix := range untracedPaths {
+			if strings.HasPrefix(path, prefix) {
+				return c.Next()
+			}
+		}
+
+		start := time.Now()
+		err := c.Next()
+		latency := time.Since(start)
+
+		status := c.Response().StatusCode()
+		if fe, ok := err.(*fiber.Error); ok {
+			status = fe.Code
+		} else if err != nil {
+			status = fiber.StatusInternalServerError
+		}
+
+		level := zapcore.InfoLevel
+		switch {
+		case status >= 500:
+			level = zapcore.ErrorLevel
+		case status >= 400:
+			level = zapcore.WarnLevel
+		}
+		ce := logger.Check(level, "request")
+		if ce == nil {
+			return err
+		}
+
+		fields := []zap.Field{
+			zap.String("method", c.Method()),
+			zap.String("path", strings.Clone(path)), // fiber reuses the buffer
+			zap.Int("status", status),
+			zap.Duration("latency", latency),
+			zap.Int("bytes", len(c.Response().Body())),
+		}
+		if route := c.Route(); route != nil && route.Path != "" && route.Path != path {
+			fields = append(fields, zap.String("route", route.Path))
+		}
+		if sc := trace.SpanContextFromContext(c.Context()); sc.HasTraceID() {
+			fields = append(fields, zap.String("trace_id", sc.TraceID().String()))
+		}
+		if err != nil {
+			fields = append(fields, zap.Error(err))
+		}
+		ce.Write(fields...)
+		return err
+	}
+}
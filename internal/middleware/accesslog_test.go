@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestAccessLog(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	recordSpans(t)
+
+	app := fiber.New()
+	app.Use(Tracing)
+	app.Use(AccessLog(zap.New(core)))
+	app.Get("/api/dashboard/stats/:website_id", func(c fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+	app.Get("/api/broken", func(c fiber.Ctx) error {
+		return fiber.NewError(fiber.StatusBadGateway, "upstream down")
+	})
+	app.Get("/up", func(c fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	for _, path := range []string{"/api/dashboard/stats/abc", "/api/broken", "/up"} {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+
+	entries := logs.All()
+	require.Len(t, entries, 2)
+
+	ok := entries[0]
+	assert.Equal(t, zapcore.InfoLevel, ok.Level)
+	assert.Equal(t, "request", ok.Message)
+	fields := ok.ContextMap()
+	assert.Equal(t, "/api/dashboard/stats/abc", fields["path"])
+	assert.Equal(t, "/api/dashboard/stats/:website_id", fields["route"])
+	assert.EqualValues(t, 200, fields["status"])
+	assert.EqualValues(t, 2, fields["bytes"])
+	assert.IsType(t, time.Duration(0), fields["latency"])
+	assert.Len(t, fields["trace_id"], 32)
+	assert.NotContains(t, fields, "ip")
+
+	failed := entries[1]
+	assert.Equal(t, zapcore.ErrorLevel, failed.Level)
+	assert.EqualValues(t, 502, failed.ContextMap()["status"])
+	assert.Equal(t, "upstream down", failed.ContextMap()["error"])
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
@@ -9,7 +10,7 @@ import (
 	"github.com/gofiber/fiber/v3"
 	"github.com/google/uuid"
 
-	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/store"
 )
 
 // UserContext holds the authenticated user information
@@ -17,19 +18,39 @@ type UserContext struct {
 	UserID    uuid.UUID
 	Username  string
 	SessionID uuid.UUID
+
+	// Bearer is set when the token came from the Authorization header (API
+	// clients) rather than the dashboard cookie
+	Bearer bool
+	// APIToken is set for named API tokens (kaunta user token create), however
+	// they were sent
+	APIToken bool
+	// RateLimit and DailyQuota override the server's API limits for this
+	// token; nil uses the server setting
+	RateLimit  *int
+	DailyQuota *int
 }
 
 var sessionValidator = validateSessionFromDB
 
+// UmamiAPIKeyHeader is where Umami's API clients send their API key
+const UmamiAPIKeyHeader = "X-Umami-Api-Key"
+
 // Auth middleware validates session tokens and loads user context
 func Auth(c fiber.Ctx) error {
 	// Extract token from cookie
 	token := c.Cookies("kaunta_session")
+	bearer := false
 	if token == "" {
 		// Also check Authorization header for API clients
 		authHeader := c.Get("Authorization")
 		if strings.HasPrefix(authHeader, "Bearer ") {
 			token = strings.TrimPrefix(authHeader, "Bearer ")
+			bearer = true
+		} else if key := c.Get(UmamiAPIKeyHeader); key != "" {
+			// API tokens also work where Umami's clients put their API key
+			token = key
+			bearer = true
 		}
 	}
 
@@ -53,6 +74,7 @@ func Auth(c fiber.Ctx) error {
 			"error": "Authentication error",
 		})
 	}
+	userCtx.Bearer = bearer
 
 	// Store user context in Fiber locals
 	c.Locals("user", userCtx)
@@ -108,16 +130,16 @@ func hashToken(token string) string {
 }
 
 func validateSessionFromDB(tokenHash string) (*UserContext, error) {
-	var userCtx UserContext
-	query := `SELECT user_id, username, session_id FROM validate_session($1)`
-
-	err := database.DB.QueryRow(query, tokenHash).Scan(
-		&userCtx.UserID,
-		&userCtx.Username,
-		&userCtx.SessionID,
-	)
+	session, err := store.Current().ValidateUserSession(context.Background(), tokenHash)
 	if err != nil {
 		return nil, err
 	}
-	return &userCtx, nil
+	return &UserContext{
+		UserID:     session.UserID,
+		Username:   session.Username,
+		SessionID:  session.SessionID,
+		RateLimit:  session.RateLimit,
+		DailyQuota: session.DailyQuota,
+		APIToken:   session.APIToken,
+	}, nil
 }
@@ -167,6 +167,27 @@ func TestAuthUsesAuthorizationHeader(t *testing.T) {
 	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
 }
 
+func TestAuthUsesUmamiAPIKeyHeader(t *testing.T) {
+	var bearer bool
+	stubSessionValidator(t, func(tokenHash string) (*UserContext, error) {
+		assert.Equal(t, hashToken("api-token"), tokenHash)
+		return &UserContext{UserID: uuid.New(), Username: "api-user"}, nil
+	})
+
+	app := newTestApp(func(c fiber.Ctx) error {
+		bearer = GetUser(c).Bearer
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(UmamiAPIKeyHeader, "api-token")
+
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.True(t, bearer, "rate limited as an API token")
+}
+
 func TestAuthWithRedirectNoToken(t *testing.T) {
 	app := newTestAppWithRedirect(func(c fiber.Ctx) error {
 		return c.SendStatus(fiber.StatusOK)
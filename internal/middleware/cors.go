@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/cors"
+
+	"github.com/seuros/kaunta/internal/newsletter"
+	"github.com/seuros/kaunta/internal/shortlink"
+)
+
+// CORSConfig is the CORS policy of the stats and dashboard API
+type CORSConfig struct {
+	// AllowOrigins lists scheme://host[:port] origins, or "*" for any origin.
+	// Empty means the API sends no CORS headers (same-origin only).
+	AllowOrigins []string
+	// AllowCredentials lets the listed origins send the dashboard cookie;
+	// it is ignored with "*"
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight, in seconds
+	MaxAge int
+}
+
+// isAPIPath reports whether a path belongs to the stats and dashboard API.
+// /api/send and /api/batch are the tracking endpoints and follow the
+// tracking policy.
+func isAPIPath(path string) bool {
+	return strings.HasPrefix(path, "/api/") && path != "/api/send" && path != "/api/batch"
+}
+
+// APICORS applies cfg to the stats and dashboard API (/api/* except the
+// tracking endpoints), so third-party dashboards can be allowed to read
+// Kaunta data without opening the API to every site.
+func APICORS(cfg CORSConfig) (fiber.Handler, error) {
+	origins := make([]string, 0, len(cfg.AllowOrigins))
+	wildcard := false
+	for _, origin := range cfg.AllowOrigins {
+		if origin == "*" {
+			wildcard = true
+			continue
+		}
+		normalized, err := normalizeOrigin(origin)
+		if err != nil {
+			return nil, err
+		}
+		origins = append(origins, normalized)
+	}
+
+	if !wildcard && len(origins) == 0 {
+		return func(c fiber.Ctx) error { return c.Next() }, nil
+	}
+	if wildcard {
+		origins = []string{"*"}
+	}
+
+	return cors.New(cors.Config{
+		Next:             func(c fiber.Ctx) bool { return !isAPIPath(c.Path()) },
+		AllowOrigins:     origins,
+		AllowCredentials: cfg.AllowCredentials && !wildcard,
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", UmamiAPIKeyHeader, "X-CSRF-Token", "traceparent"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		ExposeHeaders: []string{
+			"RateLimit-Policy", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset",
+			"Retry-After", "X-Kaunta-Version",
+		},
+		MaxAge: cfg.MaxAge,
+	}), nil
+}
+
+// trackingPaths are the public paths any site may call: the tracker script,
+// the tracking endpoints (which check each website's allowed domains
+// themselves) and the pixel
+var trackingPaths = []string{"/k.js", "/kaunta.js", "/script.js", "/api/send", "/api/batch", "/k.gif"}
+
+// trackingPrefixes are the public paths served under a prefix: the baseline
+// pixel, short links, email opens and clicks, and embeds
+var trackingPrefixes = []string{"/b/", shortlink.Path, path.Dir(newsletter.OpenPath) + "/", "/embed/"}
+
+// isTrackingPath reports whether a path is one of the public tracking paths.
+// Shared dashboards are not: only their top pages feed is, at
+// /share/:share_id/top-pages.
+func isTrackingPath(p string, custom []string) bool {
+	if slices.Contains(trackingPaths, p) || slices.Contains(custom, p) {
+		return true
+	}
+	for _, prefix := range trackingPrefixes {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	shareID, ok := strings.CutPrefix(p, "/share/")
+	if !ok {
+		return false
+	}
+	shareID, ok = strings.CutSuffix(shareID, "/top-pages")
+	return ok && shareID != "" && !strings.Contains(shareID, "/")
+}
+
+// TrackingCORS allows any origin on the tracking paths, plus the site's own
+// tracker and collect paths (tracker_path, collect_path) when set. Every
+// other path, the dashboard and its views among them, gets no CORS headers
+// from it.
+func TrackingCORS(trackerPath, collectPath string) fiber.Handler {
+	var custom []string
+	if trackerPath != "" {
+		custom = append(custom, trackerPath)
+	}
+	if collectPath != "" {
+		custom = append(custom, collectPath, collectPath+"/batch")
+	}
+	return cors.New(cors.Config{
+		Next: func(c fiber.Ctx) bool { return !isTrackingPath(c.Path(), custom) },
+		AllowOriginsFunc: func(origin string) bool {
+			return true
+		},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "X-CSRF-Token", "traceparent", "X-Umami-Cache"},
+		AllowMethods:     []string{"GET", "POST", "OPTIONS"},
+		AllowCredentials: true,
+		// The tracker reads Retry-After when ingestion defers its events
+		ExposeHeaders: []string{"Retry-After"},
+	})
+}
+
+// normalizeOrigin checks that origin is scheme://host[:port] and lowercases it
+func normalizeOrigin(origin string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(origin))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+		(u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return "", fmt.Errorf("invalid CORS origin %q (use scheme://host[:port] or *)", origin)
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host), nil
+}
@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCORSTestApp(t *testing.T, cfg CORSConfig) *fiber.App {
+	t.Helper()
+	apiCORS, err := APICORS(cfg)
+	require.NoError(t, err)
+
+	app := fiber.New()
+	app.Use(TrackingCORS("/js/site.js", "/t/event"))
+	app.Use(apiCORS)
+	ok := func(c fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+	app.Get("/api/dashboard/stats/:id", ok)
+	app.Post("/api/send", ok)
+	app.Get("/k.js", ok)
+	app.Get("/js/site.js", ok)
+	app.Post("/t/event/batch", ok)
+	app.Get("/b/:id", ok)
+	app.Get("/e/open.gif", ok)
+	app.Get("/embed/:id/stats.json", ok)
+	app.Get("/share/:id/top-pages", ok)
+	app.Get("/share/:id", ok)
+	app.Get("/dashboard", ok)
+	app.Get("/plain/:id", ok)
+	return app
+}
+
+func corsRequest(t *testing.T, app *fiber.App, method, path, origin string) *http.Response {
+	t.Helper()
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Origin", origin)
+	if method == http.MethodOptions {
+		req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	return resp
+}
+
+func TestAPICORSDefaultsToSameOrigin(t *testing.T) {
+	app := newCORSTestApp(t, CORSConfig{})
+
+	resp := corsRequest(t, app, http.MethodGet, "/api/dashboard/stats/1", "https://evil.example")
+	assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+
+	// Tracking keeps accepting any origin
+	resp = corsRequest(t, app, http.MethodPost, "/api/send", "https://blog.example")
+	assert.Equal(t, "https://blog.example", resp.Header.Get("Access-Control-Allow-Origin"))
+	resp = corsRequest(t, app, http.MethodGet, "/k.js", "https://blog.example")
+	assert.Equal(t, "https://blog.example", resp.Header.Get("Access-Control-Allow-Origin"))
+}
+
+func TestAPICORSAllowedOrigins(t *testing.T) {
+	app := newCORSTestApp(t, CORSConfig{
+		AllowOrigins:     []string{"https://Grafana.example.com/"},
+		AllowCredentials: true,
+		MaxAge:           600,
+	})
+
+	resp := corsRequest(t, app, http.MethodOptions, "/api/dashboard/stats/1", "https://grafana.example.com")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, "https://grafana.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", resp.Header.Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "600", resp.Header.Get("Access-Control-Max-Age"))
+	assert.Contains(t, resp.Header.Get("Access-Control-Allow-Headers"), "Authorization")
+	assert.Equal(t, "GET, POST, PUT, DELETE, OPTIONS", resp.Header.Get("Access-Control-Allow-Methods"))
+
+	resp = corsRequest(t, app, http.MethodGet, "/api/dashboard/stats/1", "https://grafana.example.com")
+	assert.Contains(t, resp.Header.Get("Access-Control-Expose-Headers"), "RateLimit-Remaining")
+
+	resp = corsRequest(t, app, http.MethodGet, "/api/dashboard/stats/1", "https://other.example.com")
+	assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+}
+
+func TestAPICORSWildcardNeverSendsCredentials(t *testing.T) {
+	app := newCORSTestApp(t, CORSConfig{AllowOrigins: []string{"*"}, AllowCredentials: true})
+
+	resp := corsRequest(t, app, http.MethodGet, "/api/dashboard/stats/1", "https://any.example")
+	assert.Equal(t, "*", resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, resp.Header.Get("Access-Control-Allow-Credentials"))
+}
+
+func TestAPICORSRejectsInvalidOrigins(t *testing.T) {
+	for _, origin := range []string{"grafana.example.com", "ftp://example.com", "https://example.com/path", "https://"} {
+		_, err := APICORS(CORSConfig{AllowOrigins: []string{origin}})
+		assert.Error(t, err, origin)
+	}
+}
+
+func TestTrackingCORSCoversOnlyTrackingPaths(t *testing.T) {
+	app := newCORSTestApp(t, CORSConfig{})
+
+	for _, path := range []string{"/k.js", "/js/site.js", "/t/event/batch", "/b/1", "/e/open.gif", "/embed/1/stats.json", "/share/1/top-pages"} {
+		method := http.MethodGet
+		if path == "/t/event/batch" {
+			method = http.MethodPost
+		}
+		resp := corsRequest(t, app, method, path, "https://blog.example")
+		assert.Equal(t, "https://blog.example", resp.Header.Get("Access-Control-Allow-Origin"), path)
+	}
+
+	// Authenticated views and shared dashboards send no CORS headers
+	for _, path := range []string{"/dashboard", "/plain/1", "/share/1"} {
+		resp := corsRequest(t, app, http.MethodGet, path, "https://evil.example")
+		assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"), path)
+		assert.Empty(t, resp.Header.Get("Access-Control-Allow-Credentials"), path)
+	}
+}
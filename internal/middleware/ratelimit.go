@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+)
+
+// APIRateLimit limits requests made with an API token (however it is sent,
+// the kaunta_session cookie included) or any token sent as Authorization:
+// Bearer to rateLimit per minute and dailyQuota per UTC day, unless the
+// token carries its own limits; 0 means unlimited. Dashboard login sessions
+// (cookie) are not limited. It must run after Auth.
+//
+// Responses carry RateLimit-Policy, RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset for whichever limit is closest to running out; rejected
+// requests get 429 with Retry-After. Counters live in memory, so they are
+// per server process and start over on restart.
+func APIRateLimit(rateLimit, dailyQuota int) fiber.Handler {
+	return newAPILimiter(rateLimit, dailyQuota, time.Now).handle
+}
+
+type apiLimiter struct {
+	rateLimit  int
+	dailyQuota int
+	now        func() time.Time
+
+	mu        sync.Mutex
+	usage     map[uuid.UUID]*tokenUsage
+	lastSweep time.Time
+}
+
+// tokenUsage counts a token's requests in the current minute and UTC day
+type tokenUsage struct {
+	minute      time.Time
+	requests    int
+	day         time.Time
+	dayRequests int
+}
+
+// rateDecision is the outcome of one request against a token's limits
+type rateDecision struct {
+	allowed   bool
+	policy    string
+	limit     int
+	remaining int
+	reset     time.Duration
+	daily     bool // the reported limit is the daily quota
+}
+
+func newAPILimiter(rateLimit, dailyQuota int, now func() time.Time) *apiLimiter {
+	return &apiLimiter{
+		rateLimit:  rateLimit,
+		dailyQuota: dailyQuota,
+		now:        now,
+		usage:      make(map[uuid.UUID]*tokenUsage),
+	}
+}
+
+func (l *apiLimiter) handle(c fiber.Ctx) error {
+	user := GetUser(c)
+	if user == nil || (!user.Bearer && !user.APIToken) {
+		return c.Next()
+	}
+
+	limit, quota := l.rateLimit, l.dailyQuota
+	if user.RateLimit != nil {
+		limit = *user.RateLimit
+	}
+	if user.DailyQuota != nil {
+		quota = *user.DailyQuota
+	}
+	if limit <= 0 && quota <= 0 {
+		return c.Next()
+	}
+
+	d := l.take(user.SessionID, limit, quota)
+	reset := strconv.Itoa(int((d.reset + time.Second - 1) / time.Second))
+	c.Set("RateLimit-Policy", d.policy)
+	c.Set("RateLimit-Limit", strconv.Itoa(d.limit))
+	c.Set("RateLimit-Remaining", strconv.Itoa(d.remaining))
+	c.Set("RateLimit-Reset", reset)
+
+	if !d.allowed {
+		c.Set("Retry-After", reset)
+		message := "Rate limit exceeded, retry in " + reset + " seconds"
+		if d.daily {
+			message = "Daily API quota exceeded, retry in " + reset + " seconds"
+		}
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+	return c.Next()
+}
+
+// take counts a request for the token if both limits allow it
+func (l *apiLimiter) take(key uuid.UUID, limit, quota int) rateDecision {
+	now := l.now().UTC()
+	minute := now.Truncate(time.Minute)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now, day)
+
+	u := l.usage[key]
+	if u == nil {
+		u = &tokenUsage{}
+		l.usage[key] = u
+	}
+	if !u.minute.Equal(minute) {
+		u.minute, u.requests = minute, 0
+	}
+	if !u.day.Equal(day) {
+		u.day, u.dayRequests = day, 0
+	}
+
+	minuteOK := limit <= 0 || u.requests < limit
+	dayOK := quota <= 0 || u.dayRequests < quota
+	d := rateDecision{allowed: minuteOK && dayOK}
+	if d.allowed {
+		u.requests++
+		u.dayRequests++
+	}
+
+	var policies []string
+	if limit > 0 {
+		policies = append(policies, strconv.Itoa(limit)+";w=60")
+	}
+	if quota > 0 {
+		policies = append(policies, strconv.Itoa(quota)+";w=86400")
+	}
+	d.policy = strings.Join(policies, ", ")
+
+	// Report the limit closest to running out (the daily quota when it is
+	// the one blocking, so Retry-After points past midnight)
+	minuteLeft, dayLeft := limit-u.requests, quota-u.dayRequests
+	d.daily = quota > 0 && (limit <= 0 || !dayOK || (minuteOK && dayLeft < minuteLeft))
+	if d.daily {
+		d.limit, d.remaining, d.reset = quota, dayLeft, day.Add(24*time.Hour).Sub(now)
+	} else {
+		d.limit, d.remaining, d.reset = limit, minuteLeft, minute.Add(time.Minute).Sub(now)
+	}
+	if d.remaining < 0 {
+		d.remaining = 0
+	}
+	return d
+}
+
+// sweep forgets tokens not seen today, at most once a minute
+func (l *apiLimiter) sweep(now, day time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, u := range l.usage {
+		if !u.day.Equal(day) {
+			delete(l.usage, key)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPILimiterMinuteWindow(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 10, 0, time.UTC)
+	l := newAPILimiter(2, 0, func() time.Time { return now })
+	token := uuid.New()
+
+	d := l.take(token, 2, 0)
+	assert.True(t, d.allowed)
+	assert.Equal(t, "2;w=60", d.policy)
+	assert.Equal(t, 1, d.remaining)
+	assert.Equal(t, 50*time.Second, d.reset)
+
+	assert.True(t, l.take(token, 2, 0).allowed)
+	d = l.take(token, 2, 0)
+	assert.False(t, d.allowed)
+	assert.False(t, d.daily)
+	assert.Equal(t, 0, d.remaining)
+
+	// Other tokens have their own counters
+	assert.True(t, l.take(uuid.New(), 2, 0).allowed)
+
+	now = now.Add(time.Minute)
+	assert.True(t, l.take(token, 2, 0).allowed)
+}
+
+func TestAPILimiterDailyQuota(t *testing.T) {
+	now := time.Date(2025, 6, 1, 18, 0, 0, 0, time.UTC)
+	l := newAPILimiter(0, 0, func() time.Time { return now })
+	token := uuid.New()
+
+	d := l.take(token, 10, 2)
+	assert.True(t, d.allowed)
+	assert.Equal(t, "10;w=60, 2;w=86400", d.policy)
+	assert.True(t, d.daily, "the quota is closer to running out")
+	assert.Equal(t, 1, d.remaining)
+
+	assert.True(t, l.take(token, 10, 2).allowed)
+	now = now.Add(time.Hour)
+	d = l.take(token, 10, 2)
+	assert.False(t, d.allowed)
+	assert.True(t, d.daily)
+	assert.Equal(t, 5*time.Hour, d.reset)
+
+	// A new UTC day starts over, and yesterday's counters are swept
+	now = time.Date(2025, 6, 2, 0, 0, 1, 0, time.UTC)
+	assert.True(t, l.take(token, 10, 2).allowed)
+	assert.Len(t, l.usage, 1)
+}
+
+func TestAPIRateLimitHandler(t *testing.T) {
+	one := 1
+	users := map[string]*UserContext{
+		"bearer":   {SessionID: uuid.New(), Bearer: true},
+		"cookie":   {SessionID: uuid.New()},
+		"override": {SessionID: uuid.New(), Bearer: true, RateLimit: &one},
+		// An API token sent as the dashboard cookie
+		"token-cookie": {SessionID: uuid.New(), APIToken: true},
+	}
+
+	app := fiber.New()
+	app.Use(func(c fiber.Ctx) error {
+		c.Locals("user", users[c.Get("X-Test-User")])
+		return c.Next()
+	})
+	app.Use(APIRateLimit(2, 0))
+	app.Get("/", func(c fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+
+	request := func(user string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Test-User", user)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp
+	}
+
+	resp := request("bearer")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "2", resp.Header.Get("RateLimit-Limit"))
+	assert.Equal(t, "1", resp.Header.Get("RateLimit-Remaining"))
+	assert.NotEmpty(t, resp.Header.Get("RateLimit-Reset"))
+
+	// Dashboard login sessions are never limited
+	for i := 0; i < 3; i++ {
+		resp = request("cookie")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("RateLimit-Limit"))
+	}
+
+	assert.Equal(t, http.StatusOK, request("override").StatusCode)
+	resp = request("override")
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "1;w=60", resp.Header.Get("RateLimit-Policy"))
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+
+	// API tokens are limited however they are sent
+	assert.Equal(t, http.StatusOK, request("token-cookie").StatusCode)
+	assert.Equal(t, http.StatusOK, request("token-cookie").StatusCode)
+	assert.Equal(t, http.StatusTooManyRequests, request("token-cookie").StatusCode)
+}
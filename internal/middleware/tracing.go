@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/seuros/kaunta/internal/tracing"
+)
+
+// untracedPaths are polled often enough that their spans would be noise
+var untracedPaths = []string{"/up", "/health", "/metrics", "/assets/"}
+
+// Tracing starts a server span for each request, continuing the caller's
+// traceparent header. Handlers reach it through c.Context(), so the store and
+// database calls they make become child spans. Without a tracing endpoint
+// the spans are no-ops.
+func Tracing(c fiber.Ctx) error {
+	path := c.Path()
+	for _, prefix := range untracedPaths {
+		if strings.HasPrefix(path, prefix) {
+			return c.Next()
+		}
+	}
+
+	method := c.Method()
+	ctx := tracing.WithTraceparent(c.Context(), c.Get("traceparent"))
+	ctx, span := tracing.Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", method),
+			attribute.String("url.path", strings.Clone(path)), // exported after fiber reuses the buffer
+		))
+	defer span.End()
+	c.SetContext(ctx)
+
+	err := c.Next()
+
+	// The matched route is only known once the router has run
+	if route := c.Route(); route != nil && route.Path != "" {
+		span.SetName(method + " " + route.Path)
+		span.SetAttributes(attribute.String("http.route", route.Path))
+	}
+	status := c.Response().StatusCode()
+	if fe, ok := err.(*fiber.Error); ok {
+		status = fe.Code
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", status))
+	if err != nil {
+		span.RecordError(err)
+	}
+	if status >= 500 {
+		span.SetStatus(codes.Error, "")
+	}
+	return err
+}
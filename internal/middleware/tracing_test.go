@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	spans := tracetest.NewSpanRecorder()
+	original := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	t.Cleanup(func() { otel.SetTracerProvider(original) })
+	return spans
+}
+
+func TestTracingContinuesTraceparent(t *testing.T) {
+	spans := recordSpans(t)
+
+	var handlerTraceID string
+	app := fiber.New()
+	app.Use(Tracing)
+	app.Get("/api/dashboard/stats/:website_id", func(c fiber.Ctx) error {
+		handlerTraceID = trace.SpanContextFromContext(c.Context()).TraceID().String()
+		return c.Status(500).SendString("boom")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/dashboard/stats/abc", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	ended := spans.Ended()
+	require.Len(t, ended, 1)
+	span := ended[0]
+	assert.Equal(t, "GET /api/dashboard/stats/:website_id", span.Name())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	assert.Equal(t, handlerTraceID, span.SpanContext().TraceID().String())
+	assert.Equal(t, codes.Error, span.Status().Code)
+}
+
+func TestTracingSkipsHealthChecks(t *testing.T) {
+	spans := recordSpans(t)
+
+	app := fiber.New()
+	app.Use(Tracing)
+	app.Get("/up", func(c fiber.Ctx) error { return c.SendString("ok") })
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/up", nil))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Empty(t, spans.Ended())
+}
@@ -0,0 +1,181 @@
+// Package newsletter measures email campaigns without a mailing tool's
+// help: an image in the email (OpenPath) records an open, and links sent
+// through ClickPath record a click before redirecting to the website.
+//
+// Both are custom events (OpenEvent, ClickEvent) tagged with
+// utm_medium=email and the campaign as utm_campaign, so the sessions a
+// click starts are attributed to the campaign in the UTM reports as well,
+// and Report can follow them to conversions.
+//
+// An email may carry a token per recipient (?r=) so opens are counted per
+// reader rather than per mail proxy. Tokens are recorded only when enabled
+// (SetRecipients, email_recipients), never for visitors anonymized by
+// ip_mode = "hash" or Do-Not-Track, and must be opaque IDs, not addresses.
+package newsletter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/privacy"
+)
+
+// Paths of the open pixel and click redirect
+const (
+	OpenPath  = "/e/open.gif"
+	ClickPath = "/e/click"
+)
+
+// Custom events recorded for opens and clicks
+const (
+	OpenEvent  = "email_open"
+	ClickEvent = "email_click"
+)
+
+// Medium is the utm_medium of email traffic, and DefaultSource the
+// utm_source of campaigns that don't name one
+const (
+	Medium        = "email"
+	DefaultSource = "newsletter"
+)
+
+// RecipientProp is the event property holding a recipient's token
+const RecipientProp = "recipient"
+
+var recipientPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+var recipients atomic.Bool
+
+// SetRecipients sets whether recipient tokens are recorded
+func SetRecipients(enabled bool) {
+	recipients.Store(enabled)
+}
+
+// KeepRecipient reports whether a request may record its recipient token:
+// tokens are enabled and the visitor isn't anonymized
+func KeepRecipient(ctx context.Context) bool {
+	return recipients.Load() && privacy.ModeOf(ctx) != privacy.Hash
+}
+
+// ValidRecipient checks a recipient token: letters, digits, ., - and _, so
+// email addresses can't be used as tokens
+func ValidRecipient(token string) bool {
+	return recipientPattern.MatchString(token)
+}
+
+// Tag adds the campaign's utm_medium, utm_campaign and utm_source (empty
+// for DefaultSource) to a link target, keeping those it already has.
+// Without a campaign the target is only checked.
+func Tag(target, campaign, source string) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid link target: %q (use an absolute http or https URL)", target)
+	}
+	if campaign == "" {
+		return target, nil
+	}
+	if source == "" {
+		source = DefaultSource
+	}
+	query := u.Query()
+	for key, value := range map[string]string{"utm_medium": Medium, "utm_campaign": campaign, "utm_source": source} {
+		if query.Get(key) == "" {
+			query.Set(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// ClickURL returns the address of a tracked link to target served by the
+// Kaunta server at base, for use in an email template
+func ClickURL(base string, websiteID uuid.UUID, campaign, target string) string {
+	query := url.Values{"website": {websiteID.String()}, "campaign": {campaign}, "url": {target}}
+	return strings.TrimRight(base, "/") + ClickPath + "?" + query.Encode()
+}
+
+// OpenURL returns the address of the open pixel served by the Kaunta server
+// at base, for use in an email template
+func OpenURL(base string, websiteID uuid.UUID, campaign string) string {
+	query := url.Values{"website": {websiteID.String()}, "campaign": {campaign}}
+	return strings.TrimRight(base, "/") + OpenPath + "?" + query.Encode()
+}
+
+// Campaign is the reach of an email campaign over a period
+type Campaign struct {
+	Name string `json:"name"`
+	// Opens and Clicks count every open and click; UniqueOpens and
+	// UniqueClicks count recipients where tokens are recorded, sessions
+	// (for opens, mostly mail proxies) otherwise
+	Opens        int64 `json:"opens"`
+	UniqueOpens  int64 `json:"unique_opens"`
+	Clicks       int64 `json:"clicks"`
+	UniqueClicks int64 `json:"unique_clicks"`
+	// Sessions are the website sessions that came from the campaign's
+	// emails, and Conversions those of them that fired a custom event (or
+	// the goal)
+	Sessions    int64 `json:"sessions"`
+	Conversions int64 `json:"conversions"`
+}
+
+// Report returns a website's email campaigns of the last days, the most
+// opened first. goal is the custom event counted as a conversion; empty
+// counts any but opens and clicks.
+func Report(ctx context.Context, db *sql.DB, websiteID uuid.UUID, days, limit int, goal string) ([]Campaign, error) {
+	var goalParam interface{}
+	if goal != "" {
+		goalParam = goal
+	}
+	rows, err := db.QueryContext(ctx, `
+		WITH ranged AS (
+			SELECT e.session_id, e.event_type, e.event_name, e.utm_medium, e.utm_campaign,
+				COALESCE(CASE WHEN jsonb_typeof(e.props) = 'object' THEN e.props ->> 'recipient' END,
+					e.session_id::text) AS reader
+			FROM website_event e
+			WHERE e.website_id = $1
+			  AND e.created_at >= NOW() - make_interval(days => $2)
+		),
+		email AS (
+			SELECT * FROM ranged
+			WHERE utm_medium = 'email' AND utm_campaign IS NOT NULL AND utm_campaign <> ''
+		),
+		converted AS (
+			SELECT DISTINCT session_id FROM ranged
+			WHERE event_type = 2 AND event_name NOT IN ($3, $4)
+			  AND ($5::text IS NULL OR event_name = $5)
+		)
+		SELECT m.utm_campaign,
+			COUNT(*) FILTER (WHERE m.event_name = $3),
+			COUNT(DISTINCT m.reader) FILTER (WHERE m.event_name = $3),
+			COUNT(*) FILTER (WHERE m.event_name = $4),
+			COUNT(DISTINCT m.reader) FILTER (WHERE m.event_name = $4),
+			COUNT(DISTINCT m.session_id) FILTER (WHERE m.event_name IS DISTINCT FROM $3),
+			COUNT(DISTINCT c.session_id) FILTER (WHERE m.event_name IS DISTINCT FROM $3)
+		FROM email m
+		LEFT JOIN converted c ON c.session_id = m.session_id
+		GROUP BY m.utm_campaign
+		ORDER BY 2 DESC, 6 DESC, m.utm_campaign
+		LIMIT $6
+	`, websiteID, days, OpenEvent, ClickEvent, goalParam, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query email campaigns: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	campaigns := []Campaign{}
+	for rows.Next() {
+		var c Campaign
+		if err := rows.Scan(&c.Name, &c.Opens, &c.UniqueOpens, &c.Clicks, &c.UniqueClicks, &c.Sessions, &c.Conversions); err != nil {
+			return nil, fmt.Errorf("failed to read email campaign: %w", err)
+		}
+		campaigns = append(campaigns, c)
+	}
+	return campaigns, rows.Err()
+}
@@ -0,0 +1,70 @@
+package newsletter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/privacy"
+)
+
+func TestTag(t *testing.T) {
+	tagged, err := Tag("https://example.com/sale", "spring", "")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/sale?utm_campaign=spring&utm_medium=email&utm_source=newsletter", tagged)
+
+	// The target's own parameters win
+	tagged, err = Tag("https://example.com/?utm_source=digest&ref=1", "spring", "weekly")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/?ref=1&utm_campaign=spring&utm_medium=email&utm_source=digest", tagged)
+
+	tagged, err = Tag("https://example.com/sale", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/sale", tagged)
+
+	_, err = Tag("javascript:alert(1)", "spring", "")
+	assert.Error(t, err)
+	_, err = Tag("/sale", "spring", "")
+	assert.Error(t, err)
+}
+
+func TestRecipients(t *testing.T) {
+	assert.True(t, ValidRecipient("sub_4821"))
+	assert.False(t, ValidRecipient("jane@example.com"))
+	assert.False(t, ValidRecipient(""))
+
+	defer SetRecipients(false)
+	ctx := context.Background()
+	assert.False(t, KeepRecipient(ctx))
+	SetRecipients(true)
+	assert.True(t, KeepRecipient(ctx))
+	assert.False(t, KeepRecipient(privacy.WithMode(ctx, privacy.Hash)), "anonymized visitors")
+}
+
+func TestURLs(t *testing.T) {
+	websiteID := uuid.MustParse("6f1e2c1a-7b7a-4c7e-9a52-0d1b1f0e5a11")
+	assert.Equal(t, "https://stats.example.com/e/open.gif?campaign=spring&website="+websiteID.String(),
+		OpenURL("https://stats.example.com/", websiteID, "spring"))
+	assert.Equal(t, "https://stats.example.com/e/click?campaign=spring&url=https%3A%2F%2Fexample.com%2Fsale&website="+websiteID.String(),
+		ClickURL("https://stats.example.com", websiteID, "spring", "https://example.com/sale"))
+}
+
+func TestReport(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	websiteID := uuid.New()
+
+	mock.ExpectQuery(`utm_medium = 'email'`).WithArgs(websiteID, 30, OpenEvent, ClickEvent, "signup", 20).
+		WillReturnRows(sqlmock.NewRows([]string{"campaign", "opens", "unique_opens", "clicks", "unique_clicks", "sessions", "conversions"}).
+			AddRow("spring", 420, 310, 95, 80, 88, 12))
+
+	campaigns, err := Report(context.Background(), db, websiteID, 30, 20, "signup")
+	require.NoError(t, err)
+	assert.Equal(t, []Campaign{{Name: "spring", Opens: 420, UniqueOpens: 310, Clicks: 95, UniqueClicks: 80, Sessions: 88, Conversions: 12}}, campaigns)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
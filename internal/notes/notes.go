@@ -0,0 +1,124 @@
+// Package notes keeps short notes users attach to a day of a website's
+// traffic ("outage 14:00-15:30", "newsletter sent"). The dashboard shows them
+// with the pageviews chart, so everyone viewing that range has the context.
+//
+// Notes live in PostgreSQL (note).
+package notes
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// MaxLength is the longest note, in characters
+const MaxLength = 280
+
+// ErrNotFound is returned for a note the website doesn't have
+var ErrNotFound = errors.New("note not found")
+
+// Note is a note on a day of a website
+type Note struct {
+	ID        uuid.UUID `json:"note_id"`
+	WebsiteID uuid.UUID `json:"website_id"`
+	// Date is the day the note is about, as YYYY-MM-DD
+	Date      string    `json:"date"`
+	Text      string    `json:"text"`
+	Author    string    `json:"author"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ParseDate reads a day written YYYY-MM-DD
+func ParseDate(s string) (time.Time, error) {
+	d, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date: %q (use YYYY-MM-DD)", s)
+	}
+	return d, nil
+}
+
+// Validate checks the text of a note, returning it trimmed
+func Validate(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", errors.New("a note needs text")
+	}
+	if utf8.RuneCountInString(text) > MaxLength {
+		return "", fmt.Errorf("a note is at most %d characters", MaxLength)
+	}
+	return text, nil
+}
+
+// Add attaches a note to a day of a website
+func Add(ctx context.Context, db *sql.DB, websiteID uuid.UUID, date time.Time, text, author string) (*Note, error) {
+	text, err := Validate(text)
+	if err != nil {
+		return nil, err
+	}
+	n := &Note{WebsiteID: websiteID, Date: date.Format(time.DateOnly), Text: text, Author: author}
+	err = db.QueryRowContext(ctx, `
+		INSERT INTO note (website_id, note_date, body, author)
+		VALUES ($1, $2, $3, $4)
+		RETURNING note_id, created_at
+	`, websiteID, n.Date, text, author).Scan(&n.ID, &n.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add note: %w", err)
+	}
+	return n, nil
+}
+
+// List returns the notes of a website from one day to another, both
+// included, by day
+func List(ctx context.Context, db *sql.DB, websiteID uuid.UUID, from, to time.Time) ([]Note, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT note_id, website_id, note_date, body, author, created_at
+		FROM note
+		WHERE website_id = $1 AND note_date >= $2 AND note_date <= $3
+		ORDER BY note_date, created_at
+	`, websiteID, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notes: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	list := []Note{}
+	for rows.Next() {
+		var n Note
+		var date time.Time
+		if err := rows.Scan(&n.ID, &n.WebsiteID, &date, &n.Text, &n.Author, &n.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to read note: %w", err)
+		}
+		n.Date = date.Format(time.DateOnly)
+		list = append(list, n)
+	}
+	return list, rows.Err()
+}
+
+// LastDays returns the notes of a website's last days days, today included
+// (UTC), the range of the dashboard's time series
+func LastDays(ctx context.Context, db *sql.DB, websiteID uuid.UUID, days int, now time.Time) ([]Note, error) {
+	today := now.UTC()
+	return List(ctx, db, websiteID, today.AddDate(0, 0, -days), today)
+}
+
+// Remove deletes a note of a website
+func Remove(ctx context.Context, db *sql.DB, websiteID, noteID uuid.UUID) error {
+	res, err := db.ExecContext(ctx, `DELETE FROM note WHERE website_id = $1 AND note_id = $2`, websiteID, noteID)
+	if err != nil {
+		return fmt.Errorf("failed to remove note: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
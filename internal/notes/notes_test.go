@@ -0,0 +1,78 @@
+package notes
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/test"
+)
+
+func TestValidate(t *testing.T) {
+	text, err := Validate("  outage 14:00-15:30 \n")
+	require.NoError(t, err)
+	assert.Equal(t, "outage 14:00-15:30", text)
+
+	_, err = Validate("   ")
+	assert.EqualError(t, err, "a note needs text")
+	_, err = Validate(strings.Repeat("é", MaxLength))
+	assert.NoError(t, err)
+	_, err = Validate(strings.Repeat("é", MaxLength+1))
+	assert.EqualError(t, err, "a note is at most 280 characters")
+}
+
+func TestParseDate(t *testing.T) {
+	d, err := ParseDate("2025-06-15")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 6, 15, 0, 0, 0, 0, time.UTC), d)
+
+	_, err = ParseDate("15/06/2025")
+	assert.EqualError(t, err, `invalid date: "15/06/2025" (use YYYY-MM-DD)`)
+}
+
+func TestAdd(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID, noteID := uuid.New(), uuid.New()
+	created := time.Date(2025, 6, 15, 16, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`INSERT INTO note`).WithArgs(websiteID, "2025-06-15", "outage 14:00-15:30", "alice").
+		WillReturnRows(sqlmock.NewRows([]string{"note_id", "created_at"}).AddRow(noteID, created))
+
+	n, err := Add(context.Background(), db, websiteID, time.Date(2025, 6, 15, 0, 0, 0, 0, time.UTC), " outage 14:00-15:30 ", "alice")
+	require.NoError(t, err)
+	assert.Equal(t, &Note{ID: noteID, WebsiteID: websiteID, Date: "2025-06-15", Text: "outage 14:00-15:30", Author: "alice", CreatedAt: created}, n)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLastDays(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+	now := time.Date(2025, 6, 15, 23, 30, 0, 0, time.FixedZone("CEST", 2*3600))
+
+	mock.ExpectQuery(`FROM note\s+WHERE website_id = \$1 AND note_date >= \$2 AND note_date <= \$3`).
+		WithArgs(websiteID, "2025-06-08", "2025-06-15").
+		WillReturnRows(sqlmock.NewRows([]string{"note_id", "website_id", "note_date", "body", "author", "created_at"}).
+			AddRow(uuid.New(), websiteID, time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC), "newsletter sent", "bob", now))
+
+	list, err := LastDays(context.Background(), db, websiteID, 7, now)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "2025-06-10", list[0].Date)
+	assert.Equal(t, "newsletter sent", list[0].Text)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRemoveUnknown(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID, noteID := uuid.New(), uuid.New()
+	mock.ExpectExec(`DELETE FROM note`).WithArgs(websiteID, noteID).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.ErrorIs(t, Remove(context.Background(), db, websiteID, noteID), ErrNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
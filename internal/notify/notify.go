@@ -0,0 +1,169 @@
+// Package notify posts messages to Slack and Discord incoming webhooks: alert
+// notifications and the daily summary of websites.
+//
+// The webhooks of kaunta.toml (slack_webhook_url, discord_webhook_url) are
+// used by every website that doesn't set its own with `kaunta website
+// notify`. Messages go through the job queue (kind "notify"), so a webhook
+// that is down is retried.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seuros/kaunta/internal/chaos"
+	"github.com/seuros/kaunta/internal/jobs"
+	"github.com/seuros/kaunta/internal/offline"
+)
+
+// JobKind is the job kind of chat messages
+const JobKind = "notify"
+
+// Services are the chats messages can be posted to
+var Services = []string{"slack", "discord"}
+
+// Message colors
+const (
+	ColorInfo     = "#2563eb"
+	ColorFiring   = "#dc2626"
+	ColorResolved = "#16a34a"
+)
+
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// Field is a named value of a message. Short fields are laid out side by
+// side.
+type Field struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Short bool   `json:"short,omitempty"`
+}
+
+// Message is what is posted, formatted for each service when sent
+type Message struct {
+	Title  string  `json:"title"`
+	Text   string  `json:"text,omitempty"`
+	Fields []Field `json:"fields,omitempty"`
+	// Color is the hex color of the message's side bar
+	Color string `json:"color,omitempty"`
+}
+
+// Target is a webhook of a service
+type Target struct {
+	Service string `json:"service"`
+	URL     string `json:"url"`
+}
+
+// ValidURL checks a webhook URL
+func ValidURL(service, raw string) error {
+	if !slices.Contains(Services, service) {
+		return fmt.Errorf("invalid service: %q (use slack or discord)", service)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid %s webhook URL: %q", service, raw)
+	}
+	return nil
+}
+
+// payload formats m for a service: Slack attachments or a Discord embed
+func payload(service string, m *Message) (interface{}, error) {
+	switch service {
+	case "slack":
+		fields := make([]map[string]interface{}, 0, len(m.Fields))
+		for _, f := range m.Fields {
+			fields = append(fields, map[string]interface{}{"title": f.Name, "value": f.Value, "short": f.Short})
+		}
+		return map[string]interface{}{
+			"text": m.Title,
+			"attachments": []map[string]interface{}{{
+				"color":  m.Color,
+				"text":   m.Text,
+				"fields": fields,
+			}},
+		}, nil
+	case "discord":
+		fields := make([]map[string]interface{}, 0, len(m.Fields))
+		for _, f := range m.Fields {
+			fields = append(fields, map[string]interface{}{"name": f.Name, "value": f.Value, "inline": f.Short})
+		}
+		color, _ := strconv.ParseInt(strings.TrimPrefix(m.Color, "#"), 16, 32)
+		return map[string]interface{}{
+			"username": "Kaunta",
+			"embeds": []map[string]interface{}{{
+				"title":       m.Title,
+				"description": m.Text,
+				"color":       color,
+				"fields":      fields,
+			}},
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown service: %s", service)
+}
+
+// Post sends m to a webhook now, expecting a 2xx answer
+func Post(ctx context.Context, t Target, m *Message) error {
+	if err := offline.Check(t.Service+" notification", t.URL); err != nil {
+		return err
+	}
+	if err := chaos.FailWebhook(); err != nil {
+		return fmt.Errorf("failed to post to %s: %w", t.Service, err)
+	}
+	body, err := payload(t.Service, m)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Kaunta-Notify")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to %s: %w", t.Service, err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s answered %s", t.Service, resp.Status)
+	}
+	return nil
+}
+
+// job is the payload of a notify job
+type job struct {
+	Target  Target   `json:"target"`
+	Message *Message `json:"message"`
+}
+
+// Enqueue queues m for t
+func Enqueue(ctx context.Context, db *sql.DB, t Target, m *Message) error {
+	_, err := jobs.Enqueue(ctx, db, JobKind, job{Target: t, Message: m}, jobs.EnqueueOptions{})
+	return err
+}
+
+// Deliver posts the message of a notify job
+func Deliver(ctx context.Context, j *jobs.Job) error {
+	var p job
+	if err := json.Unmarshal(j.Payload, &p); err != nil {
+		return fmt.Errorf("invalid notify payload: %w", err)
+	}
+	if p.Message == nil {
+		return fmt.Errorf("notify payload has no message")
+	}
+	return Post(ctx, p.Target, p.Message)
+}
@@ -0,0 +1,117 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/jobs"
+	"github.com/seuros/kaunta/internal/offline"
+)
+
+func message() *Message {
+	return &Message{
+		Title:  "example.com on Monday, Jun 9",
+		Text:   "Yesterday's numbers",
+		Color:  ColorFiring,
+		Fields: []Field{{Name: "Visitors", Value: "120", Short: true}},
+	}
+}
+
+// capture starts a webhook recording what it receives
+func capture(t *testing.T, status int) (*httptest.Server, *map[string]interface{}) {
+	t.Helper()
+	got := map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &got
+}
+
+func TestPostSlack(t *testing.T) {
+	server, got := capture(t, http.StatusOK)
+	require.NoError(t, Post(context.Background(), Target{Service: "slack", URL: server.URL}, message()))
+
+	assert.Equal(t, "example.com on Monday, Jun 9", (*got)["text"])
+	attachment := (*got)["attachments"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "#dc2626", attachment["color"])
+	assert.Equal(t, "Yesterday's numbers", attachment["text"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"title": "Visitors", "value": "120", "short": true}}, attachment["fields"])
+}
+
+func TestPostDiscord(t *testing.T) {
+	server, got := capture(t, http.StatusNoContent)
+	require.NoError(t, Post(context.Background(), Target{Service: "discord", URL: server.URL}, message()))
+
+	assert.Equal(t, "Kaunta", (*got)["username"])
+	embed := (*got)["embeds"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "example.com on Monday, Jun 9", embed["title"])
+	assert.Equal(t, "Yesterday's numbers", embed["description"])
+	assert.Equal(t, float64(0xdc2626), embed["color"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"name": "Visitors", "value": "120", "inline": true}}, embed["fields"])
+}
+
+func TestPostFailure(t *testing.T) {
+	server, _ := capture(t, http.StatusNotFound)
+	err := Post(context.Background(), Target{Service: "discord", URL: server.URL}, message())
+	assert.EqualError(t, err, "discord answered 404 Not Found")
+
+	err = Post(context.Background(), Target{Service: "teams", URL: server.URL}, message())
+	assert.EqualError(t, err, "unknown service: teams")
+}
+
+func TestPostOffline(t *testing.T) {
+	offline.Set(true)
+	t.Cleanup(func() { offline.Set(false) })
+	err := Post(context.Background(), Target{Service: "slack", URL: "https://hooks.slack.com/services/T0/B0/X"}, message())
+	assert.ErrorIs(t, err, offline.ErrOffline)
+}
+
+func TestDeliver(t *testing.T) {
+	server, got := capture(t, http.StatusOK)
+	payload, err := json.Marshal(job{Target: Target{Service: "slack", URL: server.URL}, Message: message()})
+	require.NoError(t, err)
+
+	require.NoError(t, Deliver(context.Background(), &jobs.Job{Kind: JobKind, Payload: payload}))
+	assert.Equal(t, "example.com on Monday, Jun 9", (*got)["text"])
+
+	err = Deliver(context.Background(), &jobs.Job{Kind: JobKind, Payload: []byte(`{"target":{}}`)})
+	assert.EqualError(t, err, "notify payload has no message")
+}
+
+func TestValidURL(t *testing.T) {
+	require.NoError(t, ValidURL("slack", "https://hooks.slack.com/services/T0/B0/X"))
+	assert.Error(t, ValidURL("slack", "hooks.slack.com"))
+	assert.Error(t, ValidURL("teams", "https://example.com"))
+}
+
+func TestSiteTargets(t *testing.T) {
+	cfg := config.NotifyConfig{SlackWebhookURL: "https://hooks.slack.com/services/default", DailySummary: true}
+	s := &Site{Domain: "example.com", DiscordWebhookURL: "https://discord.com/api/webhooks/1/x"}
+	assert.Equal(t, []Target{
+		{Service: "slack", URL: "https://hooks.slack.com/services/default"},
+		{Service: "discord", URL: "https://discord.com/api/webhooks/1/x"},
+	}, s.Targets(cfg))
+	assert.True(t, s.SendsSummary(cfg))
+
+	s.SlackWebhookURL = "https://hooks.slack.com/services/own"
+	target, ok := s.Target("slack", cfg)
+	assert.True(t, ok)
+	assert.Equal(t, "https://hooks.slack.com/services/own", target.URL)
+
+	off := false
+	s.DailySummary = &off
+	assert.False(t, s.SendsSummary(cfg))
+
+	_, ok = (&Site{}).Target("discord", cfg)
+	assert.False(t, ok)
+}
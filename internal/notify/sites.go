@@ -0,0 +1,156 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/config"
+)
+
+// ErrWebsiteNotFound is returned for a website that doesn't exist
+var ErrWebsiteNotFound = errors.New("website not found")
+
+// Site is the chat notification settings of a website
+type Site struct {
+	WebsiteID         uuid.UUID `json:"website_id"`
+	Domain            string    `json:"domain"`
+	SlackWebhookURL   string    `json:"slack_webhook_url,omitempty"`
+	DiscordWebhookURL string    `json:"discord_webhook_url,omitempty"`
+	// DailySummary is nil when the website follows kaunta.toml
+	DailySummary  *bool      `json:"daily_summary"`
+	SummarySentOn *time.Time `json:"summary_sent_on,omitempty"`
+}
+
+// Target returns the website's webhook of a service, else the configured one
+func (s *Site) Target(service string, cfg config.NotifyConfig) (Target, bool) {
+	url := ""
+	switch service {
+	case "slack":
+		url = firstNonEmpty(s.SlackWebhookURL, cfg.SlackWebhookURL)
+	case "discord":
+		url = firstNonEmpty(s.DiscordWebhookURL, cfg.DiscordWebhookURL)
+	}
+	return Target{Service: service, URL: url}, url != ""
+}
+
+// Targets returns the webhooks the website posts to
+func (s *Site) Targets(cfg config.NotifyConfig) []Target {
+	var targets []Target
+	for _, service := range Services {
+		if t, ok := s.Target(service, cfg); ok {
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+// SendsSummary reports whether the website's daily summary is posted
+func (s *Site) SendsSummary(cfg config.NotifyConfig) bool {
+	if s.DailySummary != nil {
+		return *s.DailySummary
+	}
+	return cfg.DailySummary
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+const selectSites = `
+	SELECT website_id, domain, COALESCE(slack_webhook_url, ''), COALESCE(discord_webhook_url, ''),
+		daily_summary, summary_sent_on
+	FROM website
+	WHERE deleted_at IS NULL`
+
+func scanSite(row interface{ Scan(...interface{}) error }) (*Site, error) {
+	var s Site
+	var daily sql.NullBool
+	var sentOn sql.NullTime
+	if err := row.Scan(&s.WebsiteID, &s.Domain, &s.SlackWebhookURL, &s.DiscordWebhookURL, &daily, &sentOn); err != nil {
+		return nil, err
+	}
+	if daily.Valid {
+		s.DailySummary = &daily.Bool
+	}
+	if sentOn.Valid {
+		s.SummarySentOn = &sentOn.Time
+	}
+	return &s, nil
+}
+
+// GetSite returns the settings of a website
+func GetSite(ctx context.Context, db *sql.DB, websiteID uuid.UUID) (*Site, error) {
+	s, err := scanSite(db.QueryRowContext(ctx, selectSites+` AND website_id = $1`, websiteID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWebsiteNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load notification settings: %w", err)
+	}
+	return s, nil
+}
+
+// Sites returns the settings of every website by domain
+func Sites(ctx context.Context, db *sql.DB) ([]*Site, error) {
+	rows, err := db.QueryContext(ctx, selectSites+` ORDER BY domain`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load notification settings: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var sites []*Site
+	for rows.Next() {
+		s, err := scanSite(rows)
+		if err != nil {
+			return nil, err
+		}
+		sites = append(sites, s)
+	}
+	return sites, rows.Err()
+}
+
+// SetWebhook sets the website's webhook of a service; an empty URL goes
+// back to the configured one
+func SetWebhook(ctx context.Context, db *sql.DB, websiteID uuid.UUID, service, url string) error {
+	if url != "" {
+		if err := ValidURL(service, url); err != nil {
+			return err
+		}
+	}
+	column := ""
+	switch service {
+	case "slack":
+		column = "slack_webhook_url"
+	case "discord":
+		column = "discord_webhook_url"
+	default:
+		return fmt.Errorf("invalid service: %q (use slack or discord)", service)
+	}
+	_, err := db.ExecContext(ctx,
+		`UPDATE website SET `+column+` = NULLIF($2, ''), updated_at = NOW() WHERE website_id = $1`, websiteID, url)
+	if err != nil {
+		return fmt.Errorf("failed to update %s webhook: %w", service, err)
+	}
+	return nil
+}
+
+// SetDailySummary turns the website's daily summary on or off; nil follows
+// kaunta.toml
+func SetDailySummary(ctx context.Context, db *sql.DB, websiteID uuid.UUID, on *bool) error {
+	_, err := db.ExecContext(ctx,
+		`UPDATE website SET daily_summary = $2, updated_at = NOW() WHERE website_id = $1`, websiteID, on)
+	if err != nil {
+		return fmt.Errorf("failed to update daily summary: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/logging"
+	"github.com/seuros/kaunta/internal/stats"
+)
+
+// summaryTop is the number of pages and referrers a summary lists
+const summaryTop = 5
+
+// Summary builds the summary of a website's day (UTC), compared with the
+// day before
+func Summary(ctx context.Context, db *sql.DB, s *Site, day time.Time) (*Message, error) {
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	p := stats.Period{Label: from.Format(time.DateOnly), From: from, To: from.AddDate(0, 0, 1)}
+
+	now, err := stats.GetSummary(ctx, db, s.WebsiteID, p)
+	if err != nil {
+		return nil, err
+	}
+	before, err := stats.GetSummary(ctx, db, s.WebsiteID, p.Previous())
+	if err != nil {
+		return nil, err
+	}
+	pages, err := stats.GetCounts(ctx, db, s.WebsiteID, stats.ByPage, p, summaryTop)
+	if err != nil {
+		return nil, err
+	}
+	referrers, err := stats.GetCounts(ctx, db, s.WebsiteID, stats.ByReferrer, p, summaryTop)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		Title: fmt.Sprintf("%s on %s", s.Domain, from.Format("Monday, Jan 2")),
+		Color: ColorInfo,
+		Fields: []Field{
+			{Name: "Visitors", Value: withChange(now.Visitors, before.Visitors), Short: true},
+			{Name: "Pageviews", Value: withChange(now.Pageviews, before.Pageviews), Short: true},
+			{Name: "Bounce rate", Value: fmt.Sprintf("%.0f%%", now.BounceRate), Short: true},
+			{Name: "Avg. engagement", Value: fmt.Sprintf("%.0fs", now.AvgEngagement), Short: true},
+			{Name: "Top pages", Value: top(pages)},
+			{Name: "Top referrers", Value: top(referrers)},
+		},
+	}, nil
+}
+
+// withChange writes a count with its change from the day before
+func withChange(now, before int64) string {
+	if before == 0 {
+		return fmt.Sprint(now)
+	}
+	return fmt.Sprintf("%d (%+.0f%%)", now, float64(now-before)/float64(before)*100)
+}
+
+// top lists counts from the highest, one per line
+func top(counts map[string]int64) string {
+	if len(counts) == 0 {
+		return "No pageviews"
+	}
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	lines := make([]string, len(names))
+	for i, name := range names {
+		lines[i] = fmt.Sprintf("%s (%d)", name, counts[name])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SendSummaries queues yesterday's summary (UTC) of every website that posts
+// one and hasn't for that day yet
+func SendSummaries(ctx context.Context, db *sql.DB, cfg config.NotifyConfig, now time.Time) error {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+
+	sites, err := Sites(ctx, db)
+	if err != nil {
+		return err
+	}
+	for _, s := range sites {
+		targets := s.Targets(cfg)
+		if !s.SendsSummary(cfg) || len(targets) == 0 {
+			continue
+		}
+		if s.SummarySentOn != nil && !s.SummarySentOn.Before(day) {
+			continue
+		}
+
+		m, err := Summary(ctx, db, s, day)
+		if err != nil {
+			logging.L().Warn("failed to build daily summary", zap.String("website", s.Domain), zap.Error(err))
+			continue
+		}
+		for _, t := range targets {
+			if err := Enqueue(ctx, db, t, m); err != nil {
+				return fmt.Errorf("failed to queue daily summary of %s: %w", s.Domain, err)
+			}
+		}
+		if _, err := db.ExecContext(ctx,
+			`UPDATE website SET summary_sent_on = $2 WHERE website_id = $1`, s.WebsiteID, day); err != nil {
+			return fmt.Errorf("failed to record daily summary of %s: %w", s.Domain, err)
+		}
+	}
+	return nil
+}
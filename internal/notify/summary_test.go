@@ -0,0 +1,73 @@
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/test"
+)
+
+func siteRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"website_id", "domain", "slack", "discord", "daily_summary", "summary_sent_on"})
+}
+
+func expectSummary(mock sqlmock.Sqlmock, websiteID uuid.UUID) {
+	summary := []string{"visitors", "pageviews", "bounce_rate", "avg_engagement"}
+	mock.ExpectQuery(`WITH events AS`).WithArgs(websiteID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(summary).AddRow(120, 300, 41.6, 35.2))
+	mock.ExpectQuery(`WITH events AS`).WithArgs(websiteID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(summary).AddRow(100, 0, 0, 0))
+	mock.ExpectQuery(`e.url_path AS name`).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "pageviews"}).AddRow("/pricing", 40).AddRow("/", 90))
+	mock.ExpectQuery(`e.referrer_domain, \$5\) AS name`).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "pageviews"}))
+}
+
+func TestSummary(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	s := &Site{WebsiteID: uuid.New(), Domain: "example.com"}
+	expectSummary(mock, s.WebsiteID)
+
+	m, err := Summary(context.Background(), db, s, time.Date(2025, 6, 9, 15, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, "example.com on Monday, Jun 9", m.Title)
+	assert.Equal(t, []Field{
+		{Name: "Visitors", Value: "120 (+20%)", Short: true},
+		{Name: "Pageviews", Value: "300", Short: true},
+		{Name: "Bounce rate", Value: "42%", Short: true},
+		{Name: "Avg. engagement", Value: "35s", Short: true},
+		{Name: "Top pages", Value: "/ (90)\n/pricing (40)"},
+		{Name: "Top referrers", Value: "No pageviews"},
+	}, m.Fields)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSendSummaries(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	now := time.Date(2025, 6, 10, 0, 30, 0, 0, time.UTC)
+	yesterday := time.Date(2025, 6, 9, 0, 0, 0, 0, time.UTC)
+	cfg := config.NotifyConfig{SlackWebhookURL: "https://hooks.slack.com/services/default", DailySummary: true}
+
+	due, sent, optedOut := uuid.New(), uuid.New(), uuid.New()
+	mock.ExpectQuery(`FROM website`).WillReturnRows(siteRows().
+		AddRow(due, "a.example.com", "", "https://discord.com/api/webhooks/1/x", nil, yesterday.AddDate(0, 0, -1)).
+		AddRow(sent, "b.example.com", "", "", nil, yesterday).
+		AddRow(optedOut, "c.example.com", "", "", false, nil))
+	expectSummary(mock, due)
+	for i := 0; i < 2; i++ {
+		mock.ExpectQuery(`INSERT INTO jobs`).WithArgs(JobKind, sqlmock.AnyArg(), 5, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"job_id"}).AddRow(uuid.New()))
+	}
+	mock.ExpectExec(`UPDATE website SET summary_sent_on`).WithArgs(due, yesterday).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, SendSummaries(context.Background(), db, cfg, now))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
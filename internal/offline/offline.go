@@ -0,0 +1,57 @@
+// Package offline gates every outbound network call made by the Kaunta binary.
+//
+// When `offline = true` is configured the server must never phone out. Each
+// call site asks Check before dialing and degrades gracefully when refused:
+//
+//   - GeoIP database download (geoip.Init) - lookups return empty until the
+//     .mmdb file is installed manually
+//   - Release version check and download (--self-upgrade, --self-upgrade-check)
+//   - Map tiles in the dashboard (fetched by the browser from OpenStreetMap);
+//     the tile layer is not rendered in offline mode
+//   - Alert notifications (webhook, Slack, Discord, email) and daily
+//     summaries - the jobs fail and end up dead in the job queue
+//   - Usage telemetry (opt-in) - the weekly report is not sent
+//
+// Favicons and all other dashboard assets are served from the embedded FS and
+// never trigger outbound requests.
+package offline
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/logging"
+)
+
+// ErrOffline is returned when an outbound call is refused
+var ErrOffline = errors.New("outbound network access disabled (offline = true)")
+
+var enabled atomic.Bool
+
+// Set enables or disables offline mode for the process
+func Set(on bool) {
+	enabled.Store(on)
+}
+
+// Enabled reports whether outbound network calls are disabled
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Check must be called before any outbound network call. It returns nil when
+// the call may proceed, or an error wrapping ErrOffline after logging which
+// feature was skipped and where it would have connected.
+func Check(feature, target string) error {
+	if !Enabled() {
+		return nil
+	}
+
+	logging.L().Info("outbound call blocked by offline mode",
+		zap.String("feature", feature),
+		zap.String("target", target),
+	)
+	return fmt.Errorf("%s: %w", feature, ErrOffline)
+}
@@ -0,0 +1,20 @@
+package offline
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	t.Cleanup(func() { Set(false) })
+
+	Set(false)
+	assert.NoError(t, Check("geoip download", "cdn.jsdelivr.net"))
+
+	Set(true)
+	err := Check("geoip download", "cdn.jsdelivr.net")
+	assert.True(t, errors.Is(err, ErrOffline))
+	assert.Contains(t, err.Error(), "geoip download")
+}
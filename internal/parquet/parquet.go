@@ -0,0 +1,394 @@
+// Package parquet reads and writes flat Apache Parquet files.
+//
+// It covers what the event archive needs and nothing more: a flat schema of
+// optional columns (strings, 32/64-bit integers and microsecond UTC
+// timestamps), PLAIN encoding and gzip-compressed v1 data pages with one page
+// per column chunk. The files open in DuckDB, Spark, pandas and other
+// Parquet readers; the Reader only has to understand files written here.
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+var magic = []byte("PAR1")
+
+// Type is a column's value type
+type Type int
+
+const (
+	String    Type = iota // UTF-8 BYTE_ARRAY
+	Int32                 // INT32
+	Int64                 // INT64
+	Timestamp             // INT64 microseconds since the epoch, UTC
+)
+
+func (t Type) String() string {
+	switch t {
+	case String:
+		return "string"
+	case Int32:
+		return "int32"
+	case Int64:
+		return "int64"
+	case Timestamp:
+		return "timestamp"
+	}
+	return fmt.Sprintf("Type(%d)", int(t))
+}
+
+// Column is one field of the flat schema. All columns are nullable.
+type Column struct {
+	Name string
+	Type Type
+}
+
+// Parquet physical types, converted types, encodings and codecs
+const (
+	physicalInt32     = 1
+	physicalInt64     = 2
+	physicalByteArray = 6
+
+	convertedUTF8            = 0
+	convertedTimestampMicros = 10
+
+	repetitionOptional = 1
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecUncompressed = 0
+	codecGzip         = 2
+
+	pageData = 0
+)
+
+func (t Type) physical() int32 {
+	switch t {
+	case String:
+		return physicalByteArray
+	case Int32:
+		return physicalInt32
+	default:
+		return physicalInt64
+	}
+}
+
+// DefaultRowGroupSize is the number of rows buffered before a row group is
+// written out
+const DefaultRowGroupSize = 50000
+
+type chunkMeta struct {
+	offset       int64
+	uncompressed int64
+	compressed   int64
+	values       int64
+}
+
+type rowGroup struct {
+	rows   int64
+	chunks []chunkMeta
+}
+
+// Writer writes rows to a Parquet file. Close must be called to write the
+// footer.
+type Writer struct {
+	w            io.Writer
+	offset       int64
+	columns      []Column
+	rowGroupSize int
+	buffered     [][]any // per column
+	groups       []rowGroup
+	rows         int64
+	closed       bool
+}
+
+// NewWriter starts a Parquet file with the given columns
+func NewWriter(w io.Writer, columns []Column) (*Writer, error) {
+	if len(columns) == 0 {
+		return nil, errors.New("parquet: no columns")
+	}
+	seen := map[string]bool{}
+	for _, col := range columns {
+		if col.Name == "" || seen[col.Name] {
+			return nil, fmt.Errorf("parquet: invalid or duplicate column name %q", col.Name)
+		}
+		if col.Type < String || col.Type > Timestamp {
+			return nil, fmt.Errorf("parquet: column %s has unknown type", col.Name)
+		}
+		seen[col.Name] = true
+	}
+
+	pw := &Writer{
+		w:            w,
+		columns:      columns,
+		rowGroupSize: DefaultRowGroupSize,
+		buffered:     make([][]any, len(columns)),
+	}
+	if err := pw.write(magic); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+func (w *Writer) write(b []byte) error {
+	n, err := w.w.Write(b)
+	w.offset += int64(n)
+	return err
+}
+
+// Write appends a row. Values must be nil or match the column type: string,
+// int32 (or int16), int64 (or int), time.Time.
+func (w *Writer) Write(row []any) error {
+	if w.closed {
+		return errors.New("parquet: write to closed writer")
+	}
+	if len(row) != len(w.columns) {
+		return fmt.Errorf("parquet: row has %d values, want %d", len(row), len(w.columns))
+	}
+	for i, v := range row {
+		v, err := normalize(w.columns[i], v)
+		if err != nil {
+			return err
+		}
+		w.buffered[i] = append(w.buffered[i], v)
+	}
+	if len(w.buffered[0]) >= w.rowGroupSize {
+		return w.flush()
+	}
+	return nil
+}
+
+func normalize(col Column, v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch col.Type {
+	case String:
+		switch s := v.(type) {
+		case string:
+			return s, nil
+		case []byte:
+			return string(s), nil
+		}
+	case Int32:
+		switch n := v.(type) {
+		case int32:
+			return n, nil
+		case int16:
+			return int32(n), nil
+		}
+	case Int64:
+		switch n := v.(type) {
+		case int64:
+			return n, nil
+		case int:
+			return int64(n), nil
+		case int32:
+			return int64(n), nil
+		}
+	case Timestamp:
+		if t, ok := v.(time.Time); ok {
+			return t.UnixMicro(), nil
+		}
+	}
+	return nil, fmt.Errorf("parquet: column %s (%s) cannot hold %T", col.Name, col.Type, v)
+}
+
+// flush writes the buffered rows as a row group
+func (w *Writer) flush() error {
+	rows := len(w.buffered[0])
+	if rows == 0 {
+		return nil
+	}
+
+	group := rowGroup{rows: int64(rows)}
+	for i, col := range w.columns {
+		chunk, err := w.writeChunk(col, w.buffered[i])
+		if err != nil {
+			return err
+		}
+		group.chunks = append(group.chunks, chunk)
+		w.buffered[i] = w.buffered[i][:0]
+	}
+	w.groups = append(w.groups, group)
+	w.rows += int64(rows)
+	return nil
+}
+
+// writeChunk writes one column of a row group as a single data page
+func (w *Writer) writeChunk(col Column, values []any) (chunkMeta, error) {
+	present := make([]bool, len(values))
+	var data bytes.Buffer
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+		present[i] = true
+		switch v := v.(type) {
+		case string:
+			data.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(v))))
+			data.WriteString(v)
+		case int32:
+			data.Write(binary.LittleEndian.AppendUint32(nil, uint32(v)))
+		case int64:
+			data.Write(binary.LittleEndian.AppendUint64(nil, uint64(v)))
+		}
+	}
+
+	levels := encodeLevels(present)
+	raw := make([]byte, 0, 4+len(levels)+data.Len())
+	raw = binary.LittleEndian.AppendUint32(raw, uint32(len(levels)))
+	raw = append(raw, levels...)
+	raw = append(raw, data.Bytes()...)
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write(raw); err != nil {
+		return chunkMeta{}, err
+	}
+	if err := zw.Close(); err != nil {
+		return chunkMeta{}, err
+	}
+
+	e := newEncoder()
+	e.i32(1, pageData)
+	e.i32(2, int32(len(raw)))
+	e.i32(3, int32(compressed.Len()))
+	e.beginStruct(5)
+	e.i32(1, int32(len(values)))
+	e.i32(2, encodingPlain)
+	e.i32(3, encodingRLE)
+	e.i32(4, encodingRLE)
+	e.endStruct()
+	e.buf = append(e.buf, 0)
+
+	chunk := chunkMeta{
+		offset:       w.offset,
+		uncompressed: int64(len(e.buf) + len(raw)),
+		compressed:   int64(len(e.buf) + compressed.Len()),
+		values:       int64(len(values)),
+	}
+	if err := w.write(e.buf); err != nil {
+		return chunkMeta{}, err
+	}
+	if err := w.write(compressed.Bytes()); err != nil {
+		return chunkMeta{}, err
+	}
+	return chunk, nil
+}
+
+// encodeLevels writes definition levels (0 null, 1 present) with the RLE
+// hybrid encoding at bit width 1, using only RLE runs
+func encodeLevels(present []bool) []byte {
+	var out []byte
+	for i := 0; i < len(present); {
+		j := i
+		for j < len(present) && present[j] == present[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i)<<1)
+		if present[i] {
+			out = append(out, 1)
+		} else {
+			out = append(out, 0)
+		}
+		i = j
+	}
+	return out
+}
+
+// Close writes any buffered rows and the file footer. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	if err := w.flush(); err != nil {
+		return err
+	}
+	w.closed = true
+
+	footer := w.footer()
+	if err := w.write(footer); err != nil {
+		return err
+	}
+	if err := w.write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer)))); err != nil {
+		return err
+	}
+	return w.write(magic)
+}
+
+// Rows returns the number of rows written so far, including buffered ones
+func (w *Writer) Rows() int64 {
+	return w.rows + int64(len(w.buffered[0]))
+}
+
+// footer encodes the FileMetaData struct
+func (w *Writer) footer() []byte {
+	e := newEncoder()
+	e.i32(1, 1) // version
+
+	e.list(2, tStruct, len(w.columns)+1)
+	e.beginElement()
+	e.binary(4, "schema")
+	e.i32(5, int32(len(w.columns)))
+	e.endStruct()
+	for _, col := range w.columns {
+		e.beginElement()
+		e.i32(1, col.Type.physical())
+		e.i32(3, repetitionOptional)
+		e.binary(4, col.Name)
+		switch col.Type {
+		case String:
+			e.i32(6, convertedUTF8)
+		case Timestamp:
+			e.i32(6, convertedTimestampMicros)
+		}
+		e.endStruct()
+	}
+
+	e.i64(3, w.rows)
+
+	e.list(4, tStruct, len(w.groups))
+	for _, g := range w.groups {
+		e.beginElement()
+		var total int64
+		e.list(1, tStruct, len(g.chunks))
+		for i, c := range g.chunks {
+			col := w.columns[i]
+			total += c.uncompressed
+
+			e.beginElement()
+			e.i64(2, c.offset)
+			e.beginStruct(3)
+			e.i32(1, col.Type.physical())
+			e.list(2, tI32, 2)
+			e.varint(encodingPlain)
+			e.varint(encodingRLE)
+			e.list(3, tBinary, 1)
+			e.uvarint(uint64(len(col.Name)))
+			e.buf = append(e.buf, col.Name...)
+			e.i32(4, codecGzip)
+			e.i64(5, c.values)
+			e.i64(6, c.uncompressed)
+			e.i64(7, c.compressed)
+			e.i64(9, c.offset)
+			e.endStruct()
+			e.endStruct()
+		}
+		e.i64(2, total)
+		e.i64(3, g.rows)
+		e.endStruct()
+	}
+
+	e.binary(6, "kaunta")
+	e.buf = append(e.buf, 0)
+	return e.buf
+}
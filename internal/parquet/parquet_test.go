@@ -0,0 +1,97 @@
+package parquet
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testColumns = []Column{
+	{Name: "id", Type: String},
+	{Name: "created_at", Type: Timestamp},
+	{Name: "kind", Type: Int32},
+	{Name: "count", Type: Int64},
+	{Name: "note", Type: String},
+}
+
+func TestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, testColumns)
+	require.NoError(t, err)
+	w.rowGroupSize = 3 // force several row groups
+
+	at := time.Date(2025, 5, 9, 13, 45, 12, 123456000, time.UTC)
+	var want [][]any
+	for i := 0; i < 8; i++ {
+		var note any
+		if i%3 == 0 {
+			note = "ünïcode note"
+		}
+		row := []any{"event-" + string(rune('a'+i)), at.Add(time.Duration(i) * time.Minute), int32(i % 2), int64(i * 1000), note}
+		require.NoError(t, w.Write(row))
+		want = append(want, []any{row[0], row[1], row[2], row[3], row[4]})
+	}
+	require.NoError(t, w.Write([]any{"", nil, nil, nil, ""}))
+	want = append(want, []any{"", nil, nil, nil, ""})
+	assert.Equal(t, int64(9), w.Rows())
+	require.NoError(t, w.Close())
+
+	data := buf.Bytes()
+	assert.Equal(t, magic, data[:4])
+	assert.Equal(t, magic, data[len(data)-4:])
+
+	r, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	assert.Equal(t, testColumns, r.Columns())
+	assert.Equal(t, int64(9), r.Rows())
+	assert.Len(t, r.groups, 3)
+
+	var got [][]any
+	require.NoError(t, r.Each(func(row []any) error {
+		got = append(got, append([]any(nil), row...))
+		return nil
+	}))
+	assert.Equal(t, want, got)
+}
+
+func TestEmptyFile(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, testColumns)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), r.Rows())
+	require.NoError(t, r.Each(func(row []any) error {
+		t.Fatal("no rows expected")
+		return nil
+	}))
+}
+
+func TestWriteRejectsMismatchedValues(t *testing.T) {
+	w, err := NewWriter(&bytes.Buffer{}, testColumns)
+	require.NoError(t, err)
+	assert.Error(t, w.Write([]any{"a"}))
+	assert.Error(t, w.Write([]any{1, nil, nil, nil, nil}))
+	assert.Error(t, w.Write([]any{"a", "yesterday", nil, nil, nil}))
+
+	_, err = NewWriter(&bytes.Buffer{}, []Column{{Name: "a"}, {Name: "a"}})
+	assert.Error(t, err)
+}
+
+func TestReaderRejectsOtherFiles(t *testing.T) {
+	data := []byte("event_id,created_at\n1,2025-05-09\n")
+	_, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorIs(t, err, ErrNotParquet)
+}
+
+func TestDecodeBitPackedLevels(t *testing.T) {
+	// One bit-packed group (header 0b11): 1,0,1,1,0,0,0,0
+	levels, err := decodeLevels([]byte{0x03, 0x0d}, 5)
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true, false, true, true, false}, levels)
+}
@@ -0,0 +1,300 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ErrNotParquet is returned for input without the Parquet magic bytes
+var ErrNotParquet = errors.New("parquet: not a parquet file")
+
+// maxFooter bounds the footer size read from a file
+const maxFooter = 64 << 20
+
+// Reader reads the rows of a Parquet file
+type Reader struct {
+	r       io.ReaderAt
+	columns []Column
+	rows    int64
+	groups  []tstruct
+}
+
+// NewReader reads the footer of a Parquet file of the given size
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	if size < 12 {
+		return nil, ErrNotParquet
+	}
+	tail := make([]byte, 8)
+	if _, err := r.ReadAt(tail, size-8); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(tail[4:], magic) {
+		return nil, ErrNotParquet
+	}
+	footerLen := int64(binary.LittleEndian.Uint32(tail[:4]))
+	if footerLen > maxFooter || footerLen > size-12 {
+		return nil, fmt.Errorf("parquet: invalid footer length %d", footerLen)
+	}
+
+	footer := make([]byte, footerLen)
+	if _, err := r.ReadAt(footer, size-8-footerLen); err != nil {
+		return nil, err
+	}
+	meta, err := newDecoder(bytes.NewReader(footer)).readStruct()
+	if err != nil {
+		return nil, fmt.Errorf("parquet: invalid footer: %w", err)
+	}
+
+	pr := &Reader{r: r}
+	pr.rows, _ = meta.int(3)
+
+	schema := meta.list(2)
+	if len(schema) < 2 {
+		return nil, errors.New("parquet: empty schema")
+	}
+	for _, el := range schema[1:] {
+		s, _ := el.(tstruct)
+		if children, _ := s.int(5); children > 0 {
+			return nil, errors.New("parquet: nested schemas are not supported")
+		}
+		if rep, _ := s.int(3); rep > repetitionOptional {
+			return nil, errors.New("parquet: repeated columns are not supported")
+		}
+		col, err := schemaColumn(s)
+		if err != nil {
+			return nil, err
+		}
+		pr.columns = append(pr.columns, col)
+	}
+
+	for _, g := range meta.list(4) {
+		group, _ := g.(tstruct)
+		if len(group.list(1)) != len(pr.columns) {
+			return nil, errors.New("parquet: row group does not match schema")
+		}
+		pr.groups = append(pr.groups, group)
+	}
+	return pr, nil
+}
+
+func schemaColumn(s tstruct) (Column, error) {
+	col := Column{Name: s.str(4)}
+	physical, _ := s.int(1)
+	converted, hasConverted := s.int(6)
+	switch {
+	case physical == physicalByteArray:
+		col.Type = String
+	case physical == physicalInt32:
+		col.Type = Int32
+	case physical == physicalInt64 && hasConverted && converted == convertedTimestampMicros:
+		col.Type = Timestamp
+	case physical == physicalInt64:
+		col.Type = Int64
+	default:
+		return col, fmt.Errorf("parquet: column %s has unsupported type %d", col.Name, physical)
+	}
+	return col, nil
+}
+
+// Columns returns the file's schema
+func (r *Reader) Columns() []Column {
+	return r.columns
+}
+
+// Rows returns the number of rows in the file
+func (r *Reader) Rows() int64 {
+	return r.rows
+}
+
+// Each calls fn for every row in file order. Values are nil, string, int32,
+// int64 or time.Time (UTC). The row slice is reused between calls.
+func (r *Reader) Each(fn func(row []any) error) error {
+	row := make([]any, len(r.columns))
+	for _, group := range r.groups {
+		numRows, _ := group.int(3)
+		columns := make([][]any, len(r.columns))
+		for i, c := range group.list(1) {
+			chunk, _ := c.(tstruct)
+			values, err := r.readChunk(r.columns[i], chunk.child(3))
+			if err != nil {
+				return err
+			}
+			if int64(len(values)) != numRows {
+				return fmt.Errorf("parquet: column %s has %d values, want %d", r.columns[i].Name, len(values), numRows)
+			}
+			columns[i] = values
+		}
+		for n := int64(0); n < numRows; n++ {
+			for i := range columns {
+				row[i] = columns[i][n]
+			}
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Reader) readChunk(col Column, meta tstruct) ([]any, error) {
+	if meta == nil {
+		return nil, fmt.Errorf("parquet: column %s has no metadata", col.Name)
+	}
+	if _, ok := meta.int(11); ok {
+		return nil, fmt.Errorf("parquet: column %s is dictionary encoded, which is not supported", col.Name)
+	}
+	codec, _ := meta.int(4)
+	numValues, _ := meta.int(5)
+	size, _ := meta.int(7)
+	offset, _ := meta.int(9)
+	if size <= 0 || size > maxFooter*16 || offset < 4 {
+		return nil, fmt.Errorf("parquet: column %s has an invalid chunk", col.Name)
+	}
+
+	buf := make([]byte, size)
+	if _, err := r.r.ReadAt(buf, offset); err != nil {
+		return nil, err
+	}
+	chunk := bytes.NewReader(buf)
+
+	values := make([]any, 0, numValues)
+	for int64(len(values)) < numValues {
+		header, err := newDecoder(chunk).readStruct()
+		if err != nil {
+			return nil, fmt.Errorf("parquet: column %s: invalid page header: %w", col.Name, err)
+		}
+		if typ, _ := header.int(1); typ != pageData {
+			return nil, fmt.Errorf("parquet: column %s: unsupported page type %d", col.Name, typ)
+		}
+		compressedSize, _ := header.int(3)
+		if compressedSize < 0 || compressedSize > int64(chunk.Len()) {
+			return nil, fmt.Errorf("parquet: column %s: truncated page", col.Name)
+		}
+		page := make([]byte, compressedSize)
+		if _, err := io.ReadFull(chunk, page); err != nil {
+			return nil, err
+		}
+		if page, err = decompress(codec, page); err != nil {
+			return nil, fmt.Errorf("parquet: column %s: %w", col.Name, err)
+		}
+
+		dataHeader := header.child(5)
+		count, _ := dataHeader.int(1)
+		if encoding, _ := dataHeader.int(2); encoding != encodingPlain {
+			return nil, fmt.Errorf("parquet: column %s: unsupported encoding %d", col.Name, encoding)
+		}
+		if values, err = decodePage(col, page, int(count), values); err != nil {
+			return nil, fmt.Errorf("parquet: column %s: %w", col.Name, err)
+		}
+	}
+	return values, nil
+}
+
+func decompress(codec int64, page []byte) ([]byte, error) {
+	switch codec {
+	case codecUncompressed:
+		return page, nil
+	case codecGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(page))
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = zr.Close() }()
+		return io.ReadAll(zr)
+	default:
+		return nil, fmt.Errorf("unsupported compression codec %d", codec)
+	}
+}
+
+// decodePage appends the count values of a v1 data page to values
+func decodePage(col Column, page []byte, count int, values []any) ([]any, error) {
+	if len(page) < 4 {
+		return nil, errors.New("truncated page")
+	}
+	n := int(binary.LittleEndian.Uint32(page))
+	if n > len(page)-4 {
+		return nil, errors.New("truncated definition levels")
+	}
+	present, err := decodeLevels(page[4:4+n], count)
+	if err != nil {
+		return nil, err
+	}
+	data := page[4+n:]
+
+	for _, ok := range present {
+		if !ok {
+			values = append(values, nil)
+			continue
+		}
+		switch col.Type {
+		case String:
+			if len(data) < 4 {
+				return nil, errors.New("truncated value")
+			}
+			l := int(binary.LittleEndian.Uint32(data))
+			if l > len(data)-4 {
+				return nil, errors.New("truncated value")
+			}
+			values = append(values, string(data[4:4+l]))
+			data = data[4+l:]
+		case Int32:
+			if len(data) < 4 {
+				return nil, errors.New("truncated value")
+			}
+			values = append(values, int32(binary.LittleEndian.Uint32(data)))
+			data = data[4:]
+		case Int64, Timestamp:
+			if len(data) < 8 {
+				return nil, errors.New("truncated value")
+			}
+			v := int64(binary.LittleEndian.Uint64(data))
+			data = data[8:]
+			if col.Type == Timestamp {
+				values = append(values, time.UnixMicro(v).UTC())
+			} else {
+				values = append(values, v)
+			}
+		}
+	}
+	return values, nil
+}
+
+// decodeLevels reads count bit-width-1 definition levels in the RLE hybrid
+// encoding
+func decodeLevels(b []byte, count int) ([]bool, error) {
+	levels := make([]bool, 0, count)
+	r := bytes.NewReader(b)
+	for len(levels) < count {
+		header, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, errors.New("truncated definition levels")
+		}
+		if header&1 == 0 {
+			run := int(header >> 1)
+			v, err := r.ReadByte()
+			if err != nil || run > count-len(levels) {
+				return nil, errors.New("invalid definition levels")
+			}
+			for i := 0; i < run; i++ {
+				levels = append(levels, v == 1)
+			}
+			continue
+		}
+		// Bit-packed groups of 8 values, one byte per group at bit width 1
+		for groups := int(header >> 1); groups > 0; groups-- {
+			v, err := r.ReadByte()
+			if err != nil {
+				return nil, errors.New("truncated definition levels")
+			}
+			for bit := 0; bit < 8 && len(levels) < count; bit++ {
+				levels = append(levels, v&(1<<bit) != 0)
+			}
+		}
+	}
+	return levels, nil
+}
@@ -0,0 +1,273 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Parquet metadata is serialised with Thrift's compact protocol. Only the
+// parts needed for the file footer and page headers are implemented.
+
+// Compact protocol type ids
+const (
+	tBoolTrue  = 1
+	tBoolFalse = 2
+	tByte      = 3
+	tI16       = 4
+	tI32       = 5
+	tI64       = 6
+	tDouble    = 7
+	tBinary    = 8
+	tList      = 9
+	tSet       = 10
+	tMap       = 11
+	tStruct    = 12
+)
+
+// maxContainer bounds list and binary lengths read from a file
+const maxContainer = 1 << 26
+
+type encoder struct {
+	buf  []byte
+	last []int16 // last field id of each open struct
+}
+
+func newEncoder() *encoder {
+	return &encoder{last: []int16{0}}
+}
+
+func (e *encoder) uvarint(v uint64) {
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+func (e *encoder) varint(v int64) {
+	e.uvarint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (e *encoder) field(id int16, typ byte) {
+	top := len(e.last) - 1
+	if delta := id - e.last[top]; delta > 0 && delta <= 15 {
+		e.buf = append(e.buf, byte(delta)<<4|typ)
+	} else {
+		e.buf = append(e.buf, typ)
+		e.varint(int64(id))
+	}
+	e.last[top] = id
+}
+
+func (e *encoder) i32(id int16, v int32) {
+	e.field(id, tI32)
+	e.varint(int64(v))
+}
+
+func (e *encoder) i64(id int16, v int64) {
+	e.field(id, tI64)
+	e.varint(v)
+}
+
+func (e *encoder) binary(id int16, v string) {
+	e.field(id, tBinary)
+	e.uvarint(uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *encoder) beginStruct(id int16) {
+	e.field(id, tStruct)
+	e.last = append(e.last, 0)
+}
+
+// beginElement starts a struct inside a list
+func (e *encoder) beginElement() {
+	e.last = append(e.last, 0)
+}
+
+func (e *encoder) endStruct() {
+	e.buf = append(e.buf, 0)
+	e.last = e.last[:len(e.last)-1]
+}
+
+func (e *encoder) list(id int16, elem byte, n int) {
+	e.field(id, tList)
+	if n < 15 {
+		e.buf = append(e.buf, byte(n)<<4|elem)
+	} else {
+		e.buf = append(e.buf, 0xf0|elem)
+		e.uvarint(uint64(n))
+	}
+}
+
+// tstruct is a decoded Thrift struct keyed by field id. Values are bool,
+// int64 (all integer types), float64, []byte, []any or tstruct.
+type tstruct map[int16]any
+
+func (s tstruct) int(id int16) (int64, bool) {
+	v, ok := s[id].(int64)
+	return v, ok
+}
+
+func (s tstruct) str(id int16) string {
+	b, _ := s[id].([]byte)
+	return string(b)
+}
+
+func (s tstruct) list(id int16) []any {
+	l, _ := s[id].([]any)
+	return l
+}
+
+func (s tstruct) child(id int16) tstruct {
+	c, _ := s[id].(tstruct)
+	return c
+}
+
+type decoder struct {
+	r     *bytes.Reader
+	depth int
+}
+
+func newDecoder(r *bytes.Reader) *decoder {
+	return &decoder{r: r}
+}
+
+func (d *decoder) varint() (int64, error) {
+	u, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return 0, err
+	}
+	return int64(u>>1) ^ -int64(u&1), nil
+}
+
+func (d *decoder) size() (int, error) {
+	n, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return 0, err
+	}
+	if n > maxContainer {
+		return 0, fmt.Errorf("thrift: length %d too large", n)
+	}
+	return int(n), nil
+}
+
+func (d *decoder) readStruct() (tstruct, error) {
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.depth > 32 {
+		return nil, errors.New("thrift: structs nested too deep")
+	}
+
+	s := tstruct{}
+	var last int16
+	for {
+		b, err := d.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b == 0 {
+			return s, nil
+		}
+		typ := b & 0x0f
+		id := last + int16(b>>4)
+		if b>>4 == 0 {
+			v, err := d.varint()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(v)
+		}
+		last = id
+
+		var v any
+		switch typ {
+		case tBoolTrue:
+			v = true
+		case tBoolFalse:
+			v = false
+		default:
+			if v, err = d.value(typ); err != nil {
+				return nil, err
+			}
+		}
+		s[id] = v
+	}
+}
+
+func (d *decoder) value(typ byte) (any, error) {
+	switch typ {
+	case tBoolTrue, tBoolFalse, tByte:
+		b, err := d.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if typ == tByte {
+			return int64(int8(b)), nil
+		}
+		return b == tBoolTrue, nil
+	case tI16, tI32, tI64:
+		return d.varint()
+	case tDouble:
+		var b [8]byte
+		if _, err := io.ReadFull(d.r, b[:]); err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b[:])), nil
+	case tBinary:
+		n, err := d.size()
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(d.r, b); err != nil {
+			return nil, err
+		}
+		return b, nil
+	case tList, tSet:
+		h, err := d.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		n := int(h >> 4)
+		if n == 15 {
+			if n, err = d.size(); err != nil {
+				return nil, err
+			}
+		}
+		elems := make([]any, 0, min(n, 1024))
+		for i := 0; i < n; i++ {
+			v, err := d.value(h & 0x0f)
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, v)
+		}
+		return elems, nil
+	case tMap:
+		n, err := d.size()
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, nil
+		}
+		kv, err := d.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < n; i++ {
+			if _, err := d.value(kv >> 4); err != nil {
+				return nil, err
+			}
+			if _, err := d.value(kv & 0x0f); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil // maps are not needed
+	case tStruct:
+		return d.readStruct()
+	default:
+		return nil, fmt.Errorf("thrift: unknown type %d", typ)
+	}
+}
@@ -0,0 +1,142 @@
+// Package privacy decides what a visitor's IP address turns into before
+// anything derived from it is stored (ip_mode):
+//
+//   - full (default): the IP is used as is. Bot detection keeps it in
+//     ip_metadata, and sessions are a hash of IP and user agent per month.
+//   - truncate: the IP is cut to its /24 (IPv4) or /48 (IPv6) network for
+//     everything, sessions included.
+//   - hash: visitors are identified by a hash of IP and user agent with a
+//     random salt that rotates every UTC day and is then deleted, so hashes
+//     can't be linked across days or reversed (Plausible-style). Sessions
+//     last at most a day, and bot detection only keeps the truncated IP.
+//
+// In every mode the GeoIP lookup sees the full IP in memory; it is never
+// written anywhere but by the full mode.
+package privacy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Mode is how visitor IPs are handled
+type Mode string
+
+const (
+	Full     Mode = "full"
+	Truncate Mode = "truncate"
+	Hash     Mode = "hash"
+)
+
+// ParseMode validates an ip_mode setting; empty means Full
+func ParseMode(value string) (Mode, error) {
+	switch m := Mode(strings.ToLower(strings.TrimSpace(value))); m {
+	case "":
+		return Full, nil
+	case Full, Truncate, Hash:
+		return m, nil
+	default:
+		return "", fmt.Errorf("invalid ip_mode: %s (use full, truncate or hash)", value)
+	}
+}
+
+var current atomic.Value
+
+// SetMode sets the mode for the process
+func SetMode(m Mode) {
+	current.Store(m)
+}
+
+// CurrentMode returns the process mode (Full unless SetMode was called)
+func CurrentMode() Mode {
+	if m, ok := current.Load().(Mode); ok {
+		return m
+	}
+	return Full
+}
+
+type modeKey struct{}
+
+// WithMode overrides the process mode for one request, e.g. to anonymize a
+// visitor who sent Do-Not-Track
+func WithMode(ctx context.Context, m Mode) context.Context {
+	return context.WithValue(ctx, modeKey{}, m)
+}
+
+// ModeOf returns the mode of a request: its override, or the process mode
+func ModeOf(ctx context.Context) Mode {
+	if m, ok := ctx.Value(modeKey{}).(Mode); ok {
+		return m
+	}
+	return CurrentMode()
+}
+
+// StoredIP returns the form of ip that may be persisted in the request's mode
+func StoredIP(ctx context.Context, ip string) string {
+	if ModeOf(ctx) == Full {
+		return ip
+	}
+	return TruncateIP(ip)
+}
+
+// TruncateIP keeps the /24 network of an IPv4 address or the /48 of an IPv6
+// address. Anything that doesn't parse as an IP becomes "".
+func TruncateIP(ip string) string {
+	parsed := net.ParseIP(strings.TrimSpace(ip))
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return parsed.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// SaltStore returns the salt of a UTC day, creating it on first use, and
+// deletes the salts of earlier days
+type SaltStore interface {
+	DailySalt(ctx context.Context, day time.Time) ([]byte, error)
+}
+
+var salts struct {
+	mu   sync.Mutex
+	day  time.Time
+	salt []byte
+}
+
+// Salt returns today's salt, asking the store once per day
+func Salt(ctx context.Context, store SaltStore, now time.Time) ([]byte, error) {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	salts.mu.Lock()
+	defer salts.mu.Unlock()
+	if salts.day.Equal(day) && salts.salt != nil {
+		return salts.salt, nil
+	}
+
+	salt, err := store.DailySalt(ctx, day)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load daily salt: %w", err)
+	}
+	salts.day, salts.salt = day, salt
+	return salt, nil
+}
+
+// HashVisitor identifies a visitor of a website for the day of the salt
+func HashVisitor(salt []byte, websiteID, ip, userAgent string) string {
+	h := sha256.New()
+	h.Write(salt)
+	for _, part := range []string{websiteID, ip, userAgent} {
+		h.Write([]byte{0})
+		h.Write([]byte(part))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,73 @@
+package privacy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSaltStore struct {
+	calls []time.Time
+}
+
+func (f *fakeSaltStore) DailySalt(ctx context.Context, day time.Time) ([]byte, error) {
+	f.calls = append(f.calls, day)
+	return []byte(day.Format("2006-01-02")), nil
+}
+
+func TestParseMode(t *testing.T) {
+	for value, want := range map[string]Mode{"": Full, "full": Full, " Hash ": Hash, "truncate": Truncate} {
+		got, err := ParseMode(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, want, got, value)
+	}
+	_, err := ParseMode("anonymize")
+	assert.Error(t, err)
+}
+
+func TestTruncateIP(t *testing.T) {
+	assert.Equal(t, "203.0.113.0", TruncateIP("203.0.113.77"))
+	assert.Equal(t, "2001:db8:85a3::", TruncateIP("2001:db8:85a3:8d3:1319:8a2e:370:7348"))
+	assert.Equal(t, "", TruncateIP("not-an-ip"))
+}
+
+func TestStoredIP(t *testing.T) {
+	t.Cleanup(func() { SetMode(Full) })
+
+	ctx := context.Background()
+	SetMode(Full)
+	assert.Equal(t, "203.0.113.77", StoredIP(ctx, "203.0.113.77"))
+	assert.Equal(t, "203.0.113.0", StoredIP(WithMode(ctx, Hash), "203.0.113.77"))
+	SetMode(Hash)
+	assert.Equal(t, "203.0.113.0", StoredIP(ctx, "203.0.113.77"))
+}
+
+func TestSaltIsLoadedOncePerDay(t *testing.T) {
+	store := &fakeSaltStore{}
+	morning := time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)
+
+	salt, err := Salt(context.Background(), store, morning)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("2025-06-01"), salt)
+	_, err = Salt(context.Background(), store, morning.Add(10*time.Hour))
+	require.NoError(t, err)
+	assert.Len(t, store.calls, 1)
+
+	salt, err = Salt(context.Background(), store, morning.Add(24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("2025-06-02"), salt)
+	assert.Len(t, store.calls, 2)
+}
+
+func TestHashVisitor(t *testing.T) {
+	a := HashVisitor([]byte("salt-1"), "site", "203.0.113.7", "Firefox")
+	assert.Len(t, a, 64)
+	assert.Equal(t, a, HashVisitor([]byte("salt-1"), "site", "203.0.113.7", "Firefox"))
+	assert.NotEqual(t, a, HashVisitor([]byte("salt-2"), "site", "203.0.113.7", "Firefox"))
+	assert.NotEqual(t, a, HashVisitor([]byte("salt-1"), "other", "203.0.113.7", "Firefox"))
+	// Parts are separated, so shifting text between them changes the hash
+	assert.NotEqual(t, HashVisitor(nil, "ab", "c", ""), HashVisitor(nil, "a", "bc", ""))
+}
@@ -1,6 +1,7 @@
 package realtime
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/gofiber/contrib/v3/websocket"
@@ -13,9 +14,17 @@ import (
 type Hub struct {
 	register    chan *Client
 	unregister  chan *Client
-	broadcast   chan []byte
+	broadcast   chan message
 	clientCount chan chan int // For thread-safe client count queries
 	clients     map[*Client]struct{}
+	history     *history
+	presence    *presence
+}
+
+// message is a payload for the clients following websiteID
+type message struct {
+	websiteID string
+	data      []byte
 }
 
 type wsConn interface {
@@ -28,6 +37,8 @@ type Client struct {
 	hub  *Hub
 	conn wsConn
 	send chan []byte
+	// websiteID limits the client to one website's payloads; empty for all
+	websiteID string
 }
 
 type pingTicker interface {
@@ -51,9 +62,10 @@ func NewHub() *Hub {
 	h := &Hub{
 		register:    make(chan *Client),
 		unregister:  make(chan *Client),
-		broadcast:   make(chan []byte, 512),
+		broadcast:   make(chan message, 512),
 		clientCount: make(chan chan int),
 		clients:     make(map[*Client]struct{}),
+		history:     newHistory(),
 	}
 
 	go h.run()
@@ -71,10 +83,13 @@ func (h *Hub) run() {
 				close(client.send)
 				_ = client.conn.Close()
 			}
-		case message := <-h.broadcast:
+		case msg := <-h.broadcast:
 			for client := range h.clients {
+				if client.websiteID != "" && client.websiteID != msg.websiteID {
+					continue
+				}
 				select {
-				case client.send <- message:
+				case client.send <- msg.data:
 				default:
 					close(client.send)
 					delete(h.clients, client)
@@ -87,8 +102,20 @@ func (h *Hub) run() {
 }
 
 func (h *Hub) Broadcast(msg []byte) {
+	var payload struct {
+		WebsiteID string `json:"website_id"`
+	}
+	_ = json.Unmarshal(msg, &payload)
+
+	h.history.append(payload.WebsiteID, msg)
+	h.presence.touch(payload.WebsiteID)
+	h.publish(payload.WebsiteID, msg)
+}
+
+// publish sends msg to the connected clients without keeping it for polls
+func (h *Hub) publish(websiteID string, msg []byte) {
 	select {
-	case h.broadcast <- msg:
+	case h.broadcast <- message{websiteID: websiteID, data: msg}:
 	default:
 		logging.L().Warn("dropping realtime payload", zap.String("reason", "slow consumers"))
 	}
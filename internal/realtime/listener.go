@@ -3,6 +3,7 @@ package realtime
 import (
 	"context"
 	"encoding/json"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,11 +21,26 @@ type EventPayload struct {
 	WebsiteID string    `json:"website_id"`
 	SessionID string    `json:"session_id"`
 	VisitID   string    `json:"visit_id"`
+	Name      string    `json:"name,omitempty"` // custom event name; empty for pageviews
 	Path      string    `json:"path,omitempty"`
 	Title     string    `json:"title,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
+var (
+	localHubMu sync.RWMutex
+	localHub   *Hub
+)
+
+// UseLocalHub makes NotifyEvent broadcast straight to hub instead of going
+// through PostgreSQL LISTEN/NOTIFY (used by single-process SQLite deployments).
+// Passing nil restores pg_notify delivery.
+func UseLocalHub(hub *Hub) {
+	localHubMu.Lock()
+	defer localHubMu.Unlock()
+	localHub = hub
+}
+
 func NotifyEvent(ctx context.Context, payload EventPayload) {
 	data, err := json.Marshal(payload)
 	if err != nil {
@@ -32,12 +48,20 @@ func NotifyEvent(ctx context.Context, payload EventPayload) {
 		return
 	}
 
+	localHubMu.RLock()
+	hub := localHub
+	localHubMu.RUnlock()
+	if hub != nil {
+		hub.Broadcast(data)
+		return
+	}
+
 	if _, err := database.DB.ExecContext(ctx, "SELECT pg_notify($1, $2)", ChannelName, string(data)); err != nil {
 		logging.L().Warn("failed to send realtime notification", zap.Error(err))
 	}
 }
 
-func StartListener(ctx context.Context, databaseURL string, hub *Hub) error {
+func newListener(databaseURL string) (*pq.Listener, error) {
 	listener := pq.NewListener(databaseURL, 5*time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
 		if err != nil {
 			logging.L().Warn("realtime listener event", zap.Int("event", int(event)), zap.Error(err))
@@ -45,6 +69,15 @@ func StartListener(ctx context.Context, databaseURL string, hub *Hub) error {
 	})
 
 	if err := listener.Listen(ChannelName); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+func StartListener(ctx context.Context, databaseURL string, hub *Hub) error {
+	listener, err := newListener(databaseURL)
+	if err != nil {
 		return err
 	}
 
@@ -73,6 +106,50 @@ func StartListener(ctx context.Context, databaseURL string, hub *Hub) error {
 	return nil
 }
 
+// Listen delivers the events of websiteID published through PostgreSQL
+// NOTIFY, for processes without a hub such as `kaunta stats live`. The
+// channel is closed once ctx is done.
+func Listen(ctx context.Context, databaseURL, websiteID string) (<-chan EventPayload, error) {
+	listener, err := newListener(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan EventPayload, 64)
+	go func() {
+		defer func() {
+			_ = listener.Close()
+			close(events)
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case n := <-listener.Notify:
+				if n == nil {
+					continue
+				}
+				var payload EventPayload
+				if err := json.Unmarshal([]byte(n.Extra), &payload); err != nil || payload.WebsiteID != websiteID {
+					continue
+				}
+				select {
+				case events <- payload:
+				default:
+					// The reader refreshes on any event; dropping extras loses nothing
+				}
+			case <-time.After(time.Minute):
+				if err := listener.Ping(); err != nil {
+					logging.L().Warn("realtime listener ping failed", zap.Error(err))
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
 func NewEventPayload(eventType string, websiteID, sessionID, visitID uuid.UUID, path, title string, createdAt time.Time) EventPayload {
 	return EventPayload{
 		Type:      eventType,
@@ -90,3 +90,29 @@ func TestNotifyEventHandlesExecError(t *testing.T) {
 
 	require.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestNotifyEventUsesLocalHub(t *testing.T) {
+	hub := NewHub()
+	UseLocalHub(hub)
+	t.Cleanup(func() { UseLocalHub(nil) })
+
+	client := &Client{
+		hub:  hub,
+		conn: &testConn{},
+		send: make(chan []byte, 1),
+	}
+	hub.register <- client
+	waitForCondition(t, time.Second, func() bool { return hub.GetClientCount() == 1 })
+
+	payload := EventPayload{Type: "event", WebsiteID: uuid.NewString(), CreatedAt: time.Now()}
+	NotifyEvent(context.Background(), payload)
+
+	select {
+	case got := <-client.send:
+		var decoded EventPayload
+		require.NoError(t, json.Unmarshal(got, &decoded))
+		assert.Equal(t, payload.WebsiteID, decoded.WebsiteID)
+	case <-time.After(time.Second):
+		t.Fatal("did not receive local broadcast")
+	}
+}
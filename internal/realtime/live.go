@@ -0,0 +1,225 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gofiber/contrib/v3/websocket"
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/logging"
+)
+
+const (
+	// presenceInterval is how often changed visitor counts are pushed
+	presenceInterval = 2 * time.Second
+	// presenceRefresh recounts quiet websites: their visitors leave the
+	// active window without any event telling
+	presenceRefresh = 30 * time.Second
+)
+
+// VisitorCounter counts the active visitors of a website
+type VisitorCounter func(ctx context.Context, websiteID uuid.UUID) (int64, error)
+
+// VisitorsPayload tells live feeds the active visitors of their website
+type VisitorsPayload struct {
+	Type           string    `json:"type"` // always "visitors"
+	WebsiteID      string    `json:"website_id"`
+	ActiveVisitors int64     `json:"active_visitors"`
+	CountedAt      time.Time `json:"counted_at"`
+}
+
+// presence keeps the active visitor counts of the websites followed by live
+// feeds. Each website is counted once for all its feeds, when it had events
+// (at most every presenceInterval) or every presenceRefresh otherwise.
+type presence struct {
+	hub   *Hub
+	count VisitorCounter
+
+	mu    sync.Mutex
+	sites map[string]*presenceSite
+}
+
+type presenceSite struct {
+	feeds    int
+	visitors int64
+	counted  time.Time // zero until the first count
+	changed  bool      // had events since the last count
+}
+
+// TrackVisitors makes live feeds report the active visitors of their
+// website, as counted by count, until ctx is done
+func (h *Hub) TrackVisitors(ctx context.Context, count VisitorCounter) {
+	p := &presence{hub: h, count: count, sites: make(map[string]*presenceSite)}
+	h.presence = p
+
+	go func() {
+		ticker := time.NewTicker(presenceInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				p.refresh(ctx, now)
+			}
+		}
+	}()
+}
+
+// follow adds a feed of websiteID and returns the website's visitors
+func (p *presence) follow(ctx context.Context, websiteID string) (VisitorsPayload, bool) {
+	p.mu.Lock()
+	site := p.sites[websiteID]
+	if site == nil {
+		site = &presenceSite{}
+		p.sites[websiteID] = site
+	}
+	site.feeds++
+	if !site.counted.IsZero() {
+		payload := site.payload(websiteID)
+		p.mu.Unlock()
+		return payload, true
+	}
+	p.mu.Unlock()
+
+	id, err := uuid.Parse(websiteID)
+	if err != nil {
+		return VisitorsPayload{}, false
+	}
+	visitors, err := p.count(ctx, id)
+	if err != nil {
+		logging.L().Warn("failed to count active visitors", zap.String("website_id", websiteID), zap.Error(err))
+		return VisitorsPayload{}, false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if site.counted.IsZero() {
+		site.visitors = visitors
+		site.counted = time.Now()
+	}
+	return site.payload(websiteID), true
+}
+
+// unfollow removes a feed of websiteID, forgetting the website after its
+// last one
+func (p *presence) unfollow(websiteID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if site := p.sites[websiteID]; site != nil {
+		if site.feeds--; site.feeds <= 0 {
+			delete(p.sites, websiteID)
+		}
+	}
+}
+
+// touch notes an event of websiteID, which may bring a new visitor
+func (p *presence) touch(websiteID string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if site := p.sites[websiteID]; site != nil {
+		site.changed = true
+	}
+}
+
+// refresh recounts the websites due and pushes the counts that changed
+func (p *presence) refresh(ctx context.Context, now time.Time) {
+	p.mu.Lock()
+	var due []string
+	for websiteID, site := range p.sites {
+		if site.changed || now.Sub(site.counted) >= presenceRefresh {
+			site.changed = false
+			due = append(due, websiteID)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, websiteID := range due {
+		id, err := uuid.Parse(websiteID)
+		if err != nil {
+			continue
+		}
+		visitors, err := p.count(ctx, id)
+		if err != nil {
+			logging.L().Warn("failed to count active visitors", zap.String("website_id", websiteID), zap.Error(err))
+			continue
+		}
+
+		p.mu.Lock()
+		site := p.sites[websiteID]
+		if site == nil {
+			p.mu.Unlock()
+			continue
+		}
+		moved := site.counted.IsZero() || site.visitors != visitors
+		site.visitors = visitors
+		site.counted = now
+		payload := site.payload(websiteID)
+		p.mu.Unlock()
+
+		if moved {
+			if data, err := json.Marshal(payload); err == nil {
+				p.hub.publish(websiteID, data)
+			}
+		}
+	}
+}
+
+func (s *presenceSite) payload(websiteID string) VisitorsPayload {
+	return VisitorsPayload{
+		Type:           "visitors",
+		WebsiteID:      websiteID,
+		ActiveVisitors: s.visitors,
+		CountedAt:      s.counted,
+	}
+}
+
+// LiveHandler serves the live feed of the website in the :website_id route
+// parameter: a WebSocket receiving the website's events as they are tracked
+// and, once TrackVisitors runs, "visitors" payloads with its active visitors
+// on connect and whenever they change
+func (h *Hub) LiveHandler() fiber.Handler {
+	upgrade := websocket.New(func(conn *websocket.Conn) {
+		websiteID, _ := conn.Locals("live_website_id").(string)
+		client := &Client{
+			hub:       h,
+			conn:      conn,
+			send:      make(chan []byte, 512),
+			websiteID: websiteID,
+		}
+
+		if h.presence != nil {
+			if payload, ok := h.presence.follow(context.Background(), websiteID); ok {
+				if data, err := json.Marshal(payload); err == nil {
+					client.send <- data
+				}
+			}
+			defer h.presence.unfollow(websiteID)
+		}
+
+		h.register <- client
+
+		go client.writePump()
+		client.readPump()
+	})
+
+	return func(c fiber.Ctx) error {
+		websiteID, err := uuid.Parse(c.Params("website_id"))
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid website ID"})
+		}
+		if !websocket.IsWebSocketUpgrade(c) {
+			return fiber.ErrUpgradeRequired
+		}
+		c.Locals("live_website_id", websiteID.String())
+		return upgrade(c)
+	}
+}
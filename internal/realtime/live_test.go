@@ -0,0 +1,97 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHubSendsWebsiteClientsOnlyTheirPayloads(t *testing.T) {
+	hub := NewHub()
+	websiteID := uuid.NewString()
+
+	live := &Client{hub: hub, conn: &testConn{}, send: make(chan []byte, 2), websiteID: websiteID}
+	all := &Client{hub: hub, conn: &testConn{}, send: make(chan []byte, 2)}
+	hub.register <- live
+	hub.register <- all
+	waitForCondition(t, time.Second, func() bool { return hub.GetClientCount() == 2 })
+
+	other, _ := json.Marshal(EventPayload{Type: "event", WebsiteID: uuid.NewString()})
+	own, _ := json.Marshal(EventPayload{Type: "event", WebsiteID: websiteID})
+	hub.Broadcast(other)
+	hub.Broadcast(own)
+
+	assert.Equal(t, other, <-all.send)
+	assert.Equal(t, own, <-all.send)
+	assert.Equal(t, own, <-live.send)
+	assert.Empty(t, live.send)
+}
+
+func TestPresencePushesChangedVisitorCounts(t *testing.T) {
+	hub := NewHub()
+	websiteID := uuid.New()
+
+	var visitors, counts atomic.Int64
+	visitors.Store(3)
+	p := &presence{hub: hub, sites: make(map[string]*presenceSite), count: func(ctx context.Context, id uuid.UUID) (int64, error) {
+		assert.Equal(t, websiteID, id)
+		counts.Add(1)
+		return visitors.Load(), nil
+	}}
+	hub.presence = p
+
+	client := &Client{hub: hub, conn: &testConn{}, send: make(chan []byte, 4), websiteID: websiteID.String()}
+	hub.register <- client
+	waitForCondition(t, time.Second, func() bool { return hub.GetClientCount() == 1 })
+
+	first, ok := p.follow(context.Background(), websiteID.String())
+	require.True(t, ok)
+	assert.Equal(t, "visitors", first.Type)
+	assert.Equal(t, int64(3), first.ActiveVisitors)
+
+	// A second feed of the website shares the count
+	_, ok = p.follow(context.Background(), websiteID.String())
+	require.True(t, ok)
+	assert.Equal(t, int64(1), counts.Load())
+
+	// Quiet websites aren't recounted before presenceRefresh
+	now := time.Now()
+	p.refresh(context.Background(), now)
+	assert.Equal(t, int64(1), counts.Load())
+
+	// An event brings a recount, pushed when it changed
+	visitors.Store(4)
+	event, _ := json.Marshal(EventPayload{Type: "event", WebsiteID: websiteID.String()})
+	hub.Broadcast(event)
+	assert.Equal(t, event, <-client.send)
+	p.refresh(context.Background(), now)
+	assert.Equal(t, int64(2), counts.Load())
+
+	var pushed VisitorsPayload
+	select {
+	case msg := <-client.send:
+		require.NoError(t, json.Unmarshal(msg, &pushed))
+	case <-time.After(time.Second):
+		t.Fatal("visitor count not pushed")
+	}
+	assert.Equal(t, int64(4), pushed.ActiveVisitors)
+
+	// An unchanged count isn't pushed again
+	p.refresh(context.Background(), now.Add(presenceRefresh))
+	assert.Equal(t, int64(3), counts.Load())
+	assert.Empty(t, client.send)
+
+	// Nor kept for long polls
+	result := hub.Poll(context.Background(), 1, websiteID.String(), 0)
+	assert.Len(t, result.Events, 0)
+
+	p.unfollow(websiteID.String())
+	p.unfollow(websiteID.String())
+	assert.Empty(t, p.sites)
+}
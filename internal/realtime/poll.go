@@ -0,0 +1,142 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+)
+
+const (
+	// historySize is how many recent events long-poll clients can catch up on
+	historySize = 512
+	// defaultPollWait and maxPollWait bound how long a poll waits for an
+	// event; both stay under the usual 60s proxy read timeout
+	defaultPollWait = 25 * time.Second
+	maxPollWait     = 55 * time.Second
+)
+
+type historyEntry struct {
+	seq       uint64
+	websiteID string
+	data      []byte
+}
+
+// history keeps the latest broadcasts numbered by a sequence, for clients
+// that can't hold a WebSocket open and long-poll instead
+type history struct {
+	mu      sync.Mutex
+	seq     uint64
+	entries []historyEntry
+	// changed is closed and replaced on every append to wake waiting polls
+	changed chan struct{}
+}
+
+func newHistory() *history {
+	return &history{changed: make(chan struct{})}
+}
+
+func (h *history) append(websiteID string, msg []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq++
+	h.entries = append(h.entries, historyEntry{seq: h.seq, websiteID: websiteID, data: msg})
+	if len(h.entries) > historySize {
+		h.entries = append(h.entries[:0:0], h.entries[len(h.entries)-historySize:]...)
+	}
+	close(h.changed)
+	h.changed = make(chan struct{})
+}
+
+// PollResult is the answer to a long poll
+type PollResult struct {
+	Events []json.RawMessage `json:"events"`
+	// Cursor is the since value of the next poll
+	Cursor uint64 `json:"cursor"`
+	// Reset means events may have been missed: the cursor is from another
+	// server process or older than the history kept
+	Reset bool `json:"reset"`
+}
+
+// since collects the events of websiteID (every website if empty) after
+// cursor, with the channel that signals the next event
+func (h *history) since(cursor uint64, websiteID string) (PollResult, <-chan struct{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	result := PollResult{Events: []json.RawMessage{}, Cursor: h.seq}
+	if cursor == 0 {
+		return result, h.changed
+	}
+	if cursor > h.seq {
+		result.Reset = true
+		return result, h.changed
+	}
+	if len(h.entries) > 0 && cursor+1 < h.entries[0].seq {
+		result.Reset = true
+	}
+	for _, e := range h.entries {
+		if e.seq > cursor && (websiteID == "" || e.websiteID == websiteID) {
+			result.Events = append(result.Events, json.RawMessage(e.data))
+		}
+	}
+	return result, h.changed
+}
+
+// Poll returns the events of websiteID after cursor, waiting up to wait for
+// one when there are none yet. A zero cursor starts a client: it returns the
+// current cursor right away.
+func (h *Hub) Poll(ctx context.Context, cursor uint64, websiteID string, wait time.Duration) PollResult {
+	result, changed := h.history.since(cursor, websiteID)
+	if cursor == 0 || result.Reset || len(result.Events) > 0 {
+		return result
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case <-changed:
+			result, changed = h.history.since(cursor, websiteID)
+			if result.Reset || len(result.Events) > 0 {
+				return result
+			}
+			// Events of other websites only move the cursor
+		case <-timer.C:
+			return result
+		case <-ctx.Done():
+			return result
+		}
+	}
+}
+
+// PollHandler serves the long-poll fallback of the WebSocket feed:
+// GET ...?since=<cursor>&wait=<seconds>, for the website in the
+// :website_id route parameter
+func (h *Hub) PollHandler() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		websiteID, err := uuid.Parse(c.Params("website_id"))
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid website ID"})
+		}
+		cursor, err := strconv.ParseUint(c.Query("since", "0"), 10, 64)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid since cursor"})
+		}
+		wait := defaultPollWait
+		if v := c.Query("wait"); v != "" {
+			seconds, err := strconv.Atoi(v)
+			if err != nil || seconds < 0 {
+				return c.Status(400).JSON(fiber.Map{"error": "Invalid wait"})
+			}
+			wait = min(time.Duration(seconds)*time.Second, maxPollWait)
+		}
+
+		c.Set("Cache-Control", "no-store")
+		return c.JSON(h.Poll(c.Context(), cursor, websiteID.String(), wait))
+	}
+}
@@ -0,0 +1,123 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	pollSiteA = "11111111-1111-1111-1111-111111111111"
+	pollSiteB = "22222222-2222-2222-2222-222222222222"
+)
+
+func pollEvent(websiteID, path string) []byte {
+	return []byte(`{"type":"event","website_id":"` + websiteID + `","path":"` + path + `"}`)
+}
+
+func TestPollStartsWithCurrentCursor(t *testing.T) {
+	hub := NewHub()
+	hub.Broadcast(pollEvent(pollSiteA, "/"))
+	hub.Broadcast(pollEvent(pollSiteA, "/about"))
+
+	result := hub.Poll(context.Background(), 0, pollSiteA, time.Second)
+	assert.Equal(t, uint64(2), result.Cursor)
+	assert.Empty(t, result.Events)
+	assert.False(t, result.Reset)
+}
+
+func TestPollReturnsEventsSinceCursor(t *testing.T) {
+	hub := NewHub()
+	hub.Broadcast(pollEvent(pollSiteA, "/"))
+	hub.Broadcast(pollEvent(pollSiteB, "/other"))
+	hub.Broadcast(pollEvent(pollSiteA, "/about"))
+
+	result := hub.Poll(context.Background(), 1, pollSiteA, time.Second)
+	assert.Equal(t, uint64(3), result.Cursor)
+	require.Len(t, result.Events, 1)
+	assert.JSONEq(t, string(pollEvent(pollSiteA, "/about")), string(result.Events[0]))
+}
+
+func TestPollWaitsForNextEvent(t *testing.T) {
+	hub := NewHub()
+	hub.Broadcast(pollEvent(pollSiteA, "/"))
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		// Another website's event doesn't end the wait
+		hub.Broadcast(pollEvent(pollSiteB, "/other"))
+		time.Sleep(20 * time.Millisecond)
+		hub.Broadcast(pollEvent(pollSiteA, "/pricing"))
+	}()
+
+	start := time.Now()
+	result := hub.Poll(context.Background(), 1, pollSiteA, 5*time.Second)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Equal(t, uint64(3), result.Cursor)
+	require.Len(t, result.Events, 1)
+	assert.Contains(t, string(result.Events[0]), "/pricing")
+}
+
+func TestPollTimesOutWithoutEvents(t *testing.T) {
+	hub := NewHub()
+	hub.Broadcast(pollEvent(pollSiteA, "/"))
+
+	result := hub.Poll(context.Background(), 1, pollSiteA, 10*time.Millisecond)
+	assert.Equal(t, uint64(1), result.Cursor)
+	assert.Empty(t, result.Events)
+	assert.False(t, result.Reset)
+}
+
+func TestPollResetsUnknownCursor(t *testing.T) {
+	hub := NewHub()
+	hub.Broadcast(pollEvent(pollSiteA, "/"))
+
+	// A cursor from another server process
+	result := hub.Poll(context.Background(), 40, pollSiteA, time.Second)
+	assert.True(t, result.Reset)
+	assert.Equal(t, uint64(1), result.Cursor)
+
+	// A cursor older than the history kept
+	for i := 0; i < historySize+5; i++ {
+		hub.Broadcast(pollEvent(pollSiteB, "/"))
+	}
+	result = hub.Poll(context.Background(), 1, pollSiteA, time.Second)
+	assert.True(t, result.Reset)
+}
+
+func TestPollHandler(t *testing.T) {
+	hub := NewHub()
+	hub.Broadcast(pollEvent(pollSiteA, "/"))
+	hub.Broadcast(pollEvent(pollSiteA, "/about"))
+
+	app := fiber.New()
+	app.Get("/poll/:website_id", hub.PollHandler())
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/poll/"+pollSiteA+"?since=1&wait=0", nil))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	var result PollResult
+	require.NoError(t, json.Unmarshal(body, &result))
+	assert.Equal(t, uint64(2), result.Cursor)
+	assert.Len(t, result.Events, 1)
+
+	for _, path := range []string{"/poll/not-a-uuid", "/poll/" + pollSiteA + "?since=-1", "/poll/" + pollSiteA + "?wait=soon"} {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, path)
+	}
+}
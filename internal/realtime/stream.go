@@ -0,0 +1,132 @@
+package realtime
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/logging"
+)
+
+const (
+	// streamThrottle spaces out the snapshots events bring
+	streamThrottle = 2 * time.Second
+	// streamRefresh is how often a quiet stream gets a snapshot anyway; it
+	// also keeps proxies from closing the connection
+	streamRefresh = 30 * time.Second
+)
+
+// Snapshot summarizes the current activity of a website for streams
+type Snapshot func(ctx context.Context, websiteID uuid.UUID) (any, error)
+
+// nopConn stands in for the WebSocket of clients read from in-process
+type nopConn struct{}
+
+func (nopConn) ReadMessage() (int, []byte, error) { return 0, nil, context.Canceled }
+func (nopConn) WriteMessage(int, []byte) error    { return nil }
+func (nopConn) Close() error                      { return nil }
+
+// Subscribe returns the payloads of websiteID as the hub broadcasts them,
+// and the function ending the subscription. The channel is closed when the
+// subscription ends or falls too far behind.
+func (h *Hub) Subscribe(websiteID string) (<-chan []byte, func()) {
+	client := &Client{hub: h, conn: nopConn{}, send: make(chan []byte, 512), websiteID: websiteID}
+	h.register <- client
+	return client.send, func() { h.unregister <- client }
+}
+
+// StreamHandler serves the live feed of the website in the :website_id
+// route parameter as Server-Sent Events, for clients without WebSockets such
+// as `kaunta stats live --follow`. The stream sends a "live" event with the
+// snapshot on connect, after new events (at most every streamThrottle) and
+// every streamRefresh, and an "event" event for each tracked event.
+func (h *Hub) StreamHandler(snapshot Snapshot) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		websiteID, err := uuid.Parse(c.Params("website_id"))
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid website ID"})
+		}
+
+		c.Set(fiber.HeaderContentType, "text/event-stream")
+		c.Set(fiber.HeaderCacheControl, "no-store")
+		c.Set(fiber.HeaderConnection, "keep-alive")
+		// Proxies such as nginx would otherwise buffer the stream
+		c.Set("X-Accel-Buffering", "no")
+
+		return c.SendStreamWriter(func(w *bufio.Writer) {
+			payloads, unsubscribe := h.Subscribe(websiteID.String())
+			defer unsubscribe()
+
+			sendSnapshot := func() error {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+				live, err := snapshot(ctx, websiteID)
+				if err != nil {
+					logging.L().Warn("failed to take live snapshot", zap.String("website_id", websiteID.String()), zap.Error(err))
+					return nil
+				}
+				data, err := json.Marshal(live)
+				if err != nil {
+					return err
+				}
+				return writeEvent(w, "live", data)
+			}
+
+			if err := sendSnapshot(); err != nil {
+				return
+			}
+
+			throttle := time.NewTicker(streamThrottle)
+			defer throttle.Stop()
+			refresh := time.NewTicker(streamRefresh)
+			defer refresh.Stop()
+
+			pending := false
+			for {
+				var err error
+				select {
+				case msg, ok := <-payloads:
+					if !ok {
+						return
+					}
+					var payload struct {
+						Type string `json:"type"`
+					}
+					_ = json.Unmarshal(msg, &payload)
+					// Snapshots already carry the visitor count
+					if payload.Type == "visitors" {
+						continue
+					}
+					pending = true
+					err = writeEvent(w, "event", msg)
+				case <-throttle.C:
+					if pending {
+						pending = false
+						err = sendSnapshot()
+					}
+				case <-refresh.C:
+					pending = false
+					err = sendSnapshot()
+				}
+				if err != nil {
+					// The client went away
+					return
+				}
+			}
+		})
+	}
+}
+
+// writeEvent writes one Server-Sent Event and flushes it to the client
+func writeEvent(w *bufio.Writer, name string, data []byte) error {
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+		return err
+	}
+	return w.Flush()
+}
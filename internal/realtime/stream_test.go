@@ -0,0 +1,45 @@
+package realtime
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribeReceivesWebsitePayloads(t *testing.T) {
+	hub := NewHub()
+	websiteID := uuid.NewString()
+
+	payloads, unsubscribe := hub.Subscribe(websiteID)
+	waitForCondition(t, time.Second, func() bool { return hub.GetClientCount() == 1 })
+
+	other, _ := json.Marshal(EventPayload{Type: "event", WebsiteID: uuid.NewString()})
+	own, _ := json.Marshal(EventPayload{Type: "event", WebsiteID: websiteID, Path: "/pricing"})
+	hub.Broadcast(other)
+	hub.Broadcast(own)
+
+	select {
+	case got := <-payloads:
+		assert.Equal(t, own, got)
+	case <-time.After(time.Second):
+		t.Fatal("did not receive website payload")
+	}
+
+	unsubscribe()
+	waitForCondition(t, time.Second, func() bool { return hub.GetClientCount() == 0 })
+	_, ok := <-payloads
+	assert.False(t, ok)
+}
+
+func TestWriteEvent(t *testing.T) {
+	var out bytes.Buffer
+	w := bufio.NewWriter(&out)
+	require.NoError(t, writeEvent(w, "live", []byte(`{"active_visitors_now":3}`)))
+	assert.Equal(t, "event: live\ndata: {\"active_visitors_now\":3}\n\n", out.String())
+}
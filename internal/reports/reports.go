@@ -0,0 +1,212 @@
+// Package reports manages saved reports: named report definitions (metrics,
+// breakdowns, filters, period and output format) that are stored once and
+// run on demand, from `kaunta report run <name>` or GET /api/reports/<name>.
+//
+// Definitions live in PostgreSQL (saved_report). Running one reads through
+// the dashboard store, so a report shows the numbers the dashboard does.
+package reports
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/dimensions"
+	"github.com/seuros/kaunta/internal/segments"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// Period and breakdown size limits
+const (
+	MaxDays      = 365
+	DefaultDays  = 7
+	DefaultLimit = 10
+	MaxLimit     = 100
+)
+
+// Metrics are the totals a report can show
+var Metrics = []string{"visitors", "pageviews", "current_visitors"}
+
+// Formats are the outputs a report can be written in
+var Formats = []string{"table", "json", "csv"}
+
+// ErrNotFound is returned for a name no report is saved under
+var ErrNotFound = errors.New("report not found")
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+
+// Spec is what a report shows
+type Spec struct {
+	Metrics []string `json:"metrics,omitempty"`
+	// Dimensions are breakdowns of the period's pageviews, each a table
+	Dimensions []string          `json:"dimensions,omitempty"`
+	Filters    map[string]string `json:"filters,omitempty"`
+	Days       int               `json:"days"`
+	// Limit is the number of rows of each breakdown
+	Limit  int    `json:"limit"`
+	Format string `json:"format"`
+}
+
+// Report is a saved report definition
+type Report struct {
+	Name      string    `json:"name"`
+	WebsiteID uuid.UUID `json:"website_id"`
+	Domain    string    `json:"domain"`
+	Spec      Spec      `json:"spec"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ValidName checks that name can be saved: lowercase letters, digits, _ and
+// -, starting with a letter or digit
+func ValidName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid report name: %q (use lowercase letters, digits, _ and -, up to 50 characters)", name)
+	}
+	return nil
+}
+
+// Normalize fills in the defaults of spec and checks it. Custom dimensions
+// are only checked for their names: whether a website registered them is
+// known when the report runs.
+func (s *Spec) Normalize() error {
+	if s.Days == 0 {
+		s.Days = DefaultDays
+	}
+	if s.Limit == 0 {
+		s.Limit = DefaultLimit
+	}
+	if s.Format == "" {
+		s.Format = "table"
+	}
+
+	if len(s.Metrics) == 0 && len(s.Dimensions) == 0 {
+		return errors.New("a report needs at least one metric or dimension")
+	}
+	for _, m := range s.Metrics {
+		if !slices.Contains(Metrics, m) {
+			return fmt.Errorf("invalid metric: %s (use %s)", m, strings.Join(Metrics, ", "))
+		}
+	}
+	for _, d := range s.Dimensions {
+		if !store.IsBreakdownDimension(d) && dimensions.ValidName(d) != nil {
+			return fmt.Errorf("invalid dimension: %s", d)
+		}
+	}
+	if err := segments.ValidateFilters(s.Filters); err != nil {
+		return err
+	}
+	if s.Days < 1 || s.Days > MaxDays {
+		return fmt.Errorf("days must be between 1 and %d", MaxDays)
+	}
+	if s.Limit < 1 || s.Limit > MaxLimit {
+		return fmt.Errorf("limit must be between 1 and %d", MaxLimit)
+	}
+	if !slices.Contains(Formats, s.Format) {
+		return fmt.Errorf("invalid format: %s (use %s)", s.Format, strings.Join(Formats, ", "))
+	}
+	return nil
+}
+
+// StoreFilters converts the report's filters for the store
+func (s Spec) StoreFilters() store.Filters {
+	return segments.StoreFilters(s.Filters)
+}
+
+// Save stores a report, replacing the one saved under the same name
+func Save(ctx context.Context, db *sql.DB, name string, websiteID uuid.UUID, spec Spec) error {
+	if err := ValidName(name); err != nil {
+		return err
+	}
+	if err := spec.Normalize(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO saved_report (name, website_id, spec)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE
+		SET website_id = EXCLUDED.website_id, spec = EXCLUDED.spec, updated_at = NOW()
+	`, name, websiteID, data)
+	if err != nil {
+		return fmt.Errorf("failed to save report: %w", err)
+	}
+	return nil
+}
+
+const selectReports = `
+	SELECT r.name, r.website_id, w.domain, r.spec, r.created_at, r.updated_at
+	FROM saved_report r
+	JOIN website w ON w.website_id = r.website_id
+	WHERE w.deleted_at IS NULL`
+
+func scanReport(row interface{ Scan(...interface{}) error }) (*Report, error) {
+	var r Report
+	var spec []byte
+	if err := row.Scan(&r.Name, &r.WebsiteID, &r.Domain, &spec, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(spec, &r.Spec); err != nil {
+		return nil, fmt.Errorf("report %s has an invalid definition: %w", r.Name, err)
+	}
+	return &r, nil
+}
+
+// Get returns the report saved under name
+func Get(ctx context.Context, db *sql.DB, name string) (*Report, error) {
+	r, err := scanReport(db.QueryRowContext(ctx, selectReports+` AND r.name = $1`, name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load report: %w", err)
+	}
+	return r, nil
+}
+
+// List returns the saved reports by name
+func List(ctx context.Context, db *sql.DB) ([]Report, error) {
+	rows, err := db.QueryContext(ctx, selectReports+` ORDER BY r.name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reports: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var list []Report
+	for rows.Next() {
+		r, err := scanReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *r)
+	}
+	return list, rows.Err()
+}
+
+// Remove deletes the report saved under name
+func Remove(ctx context.Context, db *sql.DB, name string) error {
+	res, err := db.ExecContext(ctx, `DELETE FROM saved_report WHERE name = $1`, name)
+	if err != nil {
+		return fmt.Errorf("failed to remove report: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package reports
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/store"
+	"github.com/seuros/kaunta/internal/test"
+)
+
+func TestSpecNormalize(t *testing.T) {
+	spec := Spec{Dimensions: []string{"referrer", "plan"}}
+	require.NoError(t, spec.Normalize())
+	assert.Equal(t, Spec{Dimensions: []string{"referrer", "plan"}, Days: DefaultDays, Limit: DefaultLimit, Format: "table"}, spec)
+
+	for _, bad := range []Spec{
+		{},
+		{Metrics: []string{"revenue"}},
+		{Dimensions: []string{"plan'; --"}},
+		{Metrics: []string{"visitors"}, Filters: map[string]string{"referrer": "google.com"}},
+		{Metrics: []string{"visitors"}, Filters: map[string]string{"bot": "maybe"}},
+		{Metrics: []string{"visitors"}, Days: MaxDays + 1},
+		{Metrics: []string{"visitors"}, Format: "pdf"},
+	} {
+		assert.Error(t, bad.Normalize(), "%+v", bad)
+	}
+
+	assert.NoError(t, ValidName("weekly-seo"))
+	assert.Error(t, ValidName("Weekly SEO"))
+}
+
+func TestSpecStoreFilters(t *testing.T) {
+	spec := Spec{Filters: map[string]string{"country": "US", "bot": "false", "dim.plan": "pro"}}
+	bot := false
+	assert.Equal(t, store.Filters{Country: "US", Bot: &bot, Dimensions: map[string]string{"plan": "pro"}}, spec.StoreFilters())
+}
+
+func TestSaveAndGet(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+
+	mock.ExpectExec(`INSERT INTO saved_report .* ON CONFLICT \(name\) DO UPDATE`).
+		WithArgs("weekly-seo", websiteID, []byte(`{"metrics":["visitors"],"days":7,"limit":10,"format":"table"}`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, Save(context.Background(), db, "weekly-seo", websiteID, Spec{Metrics: []string{"visitors"}}))
+
+	mock.ExpectQuery(`FROM saved_report r`).WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "website_id", "domain", "spec", "created_at", "updated_at"}))
+	_, err := Get(context.Background(), db, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// fakeStore answers the dashboard reads reports use
+type fakeStore struct {
+	store.Store
+	filters store.Filters
+}
+
+func (f *fakeStore) MapData(ctx context.Context, websiteID uuid.UUID, days int, filters store.Filters) ([]store.MapRow, error) {
+	f.filters = filters
+	return []store.MapRow{{Country: "US", Visitors: 30}, {Country: "DE", Visitors: 12}}, nil
+}
+
+func (f *fakeStore) TimeSeries(ctx context.Context, websiteID uuid.UUID, days int, interval store.Interval, filters store.Filters) ([]store.TimePoint, error) {
+	return []store.TimePoint{{Views: 50}, {Views: 25}}, nil
+}
+
+func (f *fakeStore) Breakdown(ctx context.Context, websiteID uuid.UUID, dimension string, days, limit, offset int, filters store.Filters) ([]store.NamedCount, int64, error) {
+	return []store.NamedCount{{Name: "/pricing", Count: 40}, {Name: "/", Count: 35}}, 2, nil
+}
+
+func TestRunAndWrite(t *testing.T) {
+	st := &fakeStore{}
+	report := &Report{
+		Name:   "weekly-seo",
+		Domain: "example.com",
+		Spec: Spec{
+			Metrics:    []string{"pageviews", "visitors"},
+			Dimensions: []string{"page"},
+			Filters:    map[string]string{"device": "mobile"},
+		},
+	}
+
+	result, err := Run(context.Background(), st, report)
+	require.NoError(t, err)
+	assert.Equal(t, "mobile", st.filters.Device)
+	assert.Equal(t, []Metric{{Name: "pageviews", Value: 75}, {Name: "visitors", Value: 42}}, result.Metrics)
+	assert.Equal(t, []Breakdown{{Dimension: "page", Rows: []Row{{"/pricing", 40}, {"/", 35}}}}, result.Breakdowns)
+
+	var out bytes.Buffer
+	require.NoError(t, Write(&out, result, "table"))
+	assert.Equal(t, "weekly-seo: example.com, last 7 days\n\n"+
+		"pageviews  75\n"+
+		"visitors   42\n"+
+		"\nPAGE      PAGEVIEWS\n"+
+		"/pricing  40\n"+
+		"/         35\n", out.String())
+
+	out.Reset()
+	require.NoError(t, Write(&out, result, "csv"))
+	assert.Equal(t, "section,name,value\nmetric,pageviews,75\nmetric,visitors,42\npage,/pricing,40\npage,/,35\n", out.String())
+}
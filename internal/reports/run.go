@@ -0,0 +1,167 @@
+package reports
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// Result is a report run
+type Result struct {
+	Report      string            `json:"report"`
+	Website     string            `json:"website"`
+	Days        int               `json:"days"`
+	Filters     map[string]string `json:"filters,omitempty"`
+	Metrics     []Metric          `json:"metrics,omitempty"`
+	Breakdowns  []Breakdown       `json:"breakdowns,omitempty"`
+	GeneratedAt time.Time         `json:"generated_at"`
+}
+
+// Metric is one total of a report, in the order the spec lists them
+type Metric struct {
+	Name  string `json:"name"`
+	Value int64  `json:"value"`
+}
+
+// Breakdown is the period's pageviews grouped by one dimension
+type Breakdown struct {
+	Dimension string `json:"dimension"`
+	Rows      []Row  `json:"rows"`
+}
+
+// Row is the pageviews of one dimension value
+type Row struct {
+	Name      string `json:"name"`
+	Pageviews int64  `json:"pageviews"`
+}
+
+// Run computes r from st
+func Run(ctx context.Context, st store.Store, r *Report) (*Result, error) {
+	spec := r.Spec
+	if err := spec.Normalize(); err != nil {
+		return nil, fmt.Errorf("report %s: %w", r.Name, err)
+	}
+	f := spec.StoreFilters()
+
+	res := &Result{
+		Report:      r.Name,
+		Website:     r.Domain,
+		Days:        spec.Days,
+		Filters:     spec.Filters,
+		GeneratedAt: time.Now().UTC(),
+	}
+
+	for _, m := range spec.Metrics {
+		value, err := metric(ctx, st, r, m, f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute %s: %w", m, err)
+		}
+		res.Metrics = append(res.Metrics, Metric{Name: m, Value: value})
+	}
+
+	for _, d := range spec.Dimensions {
+		rows, _, err := st.Breakdown(ctx, r.WebsiteID, d, spec.Days, spec.Limit, 0, f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to break down by %s: %w", d, err)
+		}
+		b := Breakdown{Dimension: d, Rows: make([]Row, 0, len(rows))}
+		for _, row := range rows {
+			b.Rows = append(b.Rows, Row{Name: row.Name, Pageviews: row.Count})
+		}
+		res.Breakdowns = append(res.Breakdowns, b)
+	}
+	return res, nil
+}
+
+// metric computes one total from the dashboard's own queries
+func metric(ctx context.Context, st store.Store, r *Report, name string, f store.Filters) (int64, error) {
+	switch name {
+	case "visitors":
+		// Each visitor has one country: the map's counts add up to them
+		rows, err := st.MapData(ctx, r.WebsiteID, r.Spec.Days, f)
+		if err != nil {
+			return 0, err
+		}
+		var total int64
+		for _, row := range rows {
+			total += row.Visitors
+		}
+		return total, nil
+	case "pageviews":
+		points, err := st.TimeSeries(ctx, r.WebsiteID, r.Spec.Days, store.IntervalHour, f)
+		if err != nil {
+			return 0, err
+		}
+		var total int64
+		for _, p := range points {
+			total += p.Views
+		}
+		return total, nil
+	case "current_visitors":
+		stats, err := st.DashboardStats(ctx, r.WebsiteID, f)
+		if err != nil {
+			return 0, err
+		}
+		return stats.CurrentVisitors, nil
+	}
+	return 0, fmt.Errorf("invalid metric: %s", name)
+}
+
+// Write outputs res as table, json or csv
+func Write(w io.Writer, res *Result, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	case "csv":
+		return writeCSV(w, res)
+	case "table", "":
+		return writeTable(w, res)
+	}
+	return fmt.Errorf("invalid format: %s (use table, json or csv)", format)
+}
+
+// writeCSV writes one line per value: the metrics under section "metric",
+// then each breakdown under its dimension
+func writeCSV(w io.Writer, res *Result) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"section", "name", "value"})
+	for _, m := range res.Metrics {
+		_ = cw.Write([]string{"metric", m.Name, strconv.FormatInt(m.Value, 10)})
+	}
+	for _, b := range res.Breakdowns {
+		for _, row := range b.Rows {
+			_ = cw.Write([]string{b.Dimension, row.Name, strconv.FormatInt(row.Pageviews, 10)})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeTable(w io.Writer, res *Result) error {
+	_, _ = fmt.Fprintf(w, "%s: %s, last %d days\n", res.Report, res.Website, res.Days)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if len(res.Metrics) > 0 {
+		_, _ = fmt.Fprintln(tw)
+		for _, m := range res.Metrics {
+			_, _ = fmt.Fprintf(tw, "%s\t%d\n", m.Name, m.Value)
+		}
+	}
+	for _, b := range res.Breakdowns {
+		_, _ = fmt.Fprintf(tw, "\n%s\tPAGEVIEWS\n", strings.ToUpper(b.Dimension))
+		for _, row := range b.Rows {
+			_, _ = fmt.Fprintf(tw, "%s\t%d\n", row.Name, row.Pageviews)
+		}
+	}
+	return tw.Flush()
+}
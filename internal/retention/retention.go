@@ -0,0 +1,274 @@
+// Package retention deletes analytics data older than the configured
+// retention period.
+//
+// Retention is set globally (retention_days) and can be overridden per
+// website (website.retention_days: NULL inherits, 0 keeps forever). Daily
+// website_event partitions older than every website's retention are dropped
+// outright; events of websites with a shorter retention are deleted in
+// batches from the partitions that remain. Sessions left without events are
+// removed afterwards. Rollups are aggregates and are kept.
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// partitionPrefix names the daily website_event partitions
+const partitionPrefix = "website_event_"
+
+// deleteBatch bounds each DELETE so pruning never holds long locks
+const deleteBatch = 10000
+
+// now is swapped in tests
+var now = time.Now
+
+// Website is a website's effective retention
+type Website struct {
+	WebsiteID uuid.UUID `json:"website_id"`
+	Domain    string    `json:"domain"`
+	Days      int       `json:"retention_days"` // 0 keeps forever
+	Cutoff    time.Time `json:"cutoff"`         // events before this are expired
+	Events    int64     `json:"events"`         // expired events outside dropped partitions
+	Sessions  int64     `json:"sessions"`       // sessions left without events
+}
+
+// Partition is a daily website_event partition due to be dropped
+type Partition struct {
+	Name string    `json:"name"`
+	Day  time.Time `json:"day"`
+	Rows int64     `json:"estimated_rows"`
+}
+
+// Plan is what a prune will remove
+type Plan struct {
+	Partitions []Partition `json:"partitions"`
+	Websites   []Website   `json:"websites"`
+
+	// DropBefore is the day before which every partition is dropped; zero
+	// when a website keeps its data forever
+	DropBefore time.Time `json:"drop_before,omitempty"`
+}
+
+// Result counts what a prune removed
+type Result struct {
+	Partitions int   `json:"partitions_dropped"`
+	Events     int64 `json:"events_deleted"`
+	Sessions   int64 `json:"sessions_deleted"`
+}
+
+// cutoff is the start of the UTC day days ago; retention is kept in whole
+// days so it lines up with the daily partitions
+func cutoff(days int) time.Time {
+	today := now().UTC().Truncate(24 * time.Hour)
+	return today.AddDate(0, 0, -days)
+}
+
+// Websites returns the effective retention of every website
+func Websites(ctx context.Context, db *sql.DB, globalDays int) ([]Website, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT website_id, domain, COALESCE(retention_days, $1)
+		FROM website
+		ORDER BY domain
+	`, globalDays)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load website retention: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var websites []Website
+	for rows.Next() {
+		var w Website
+		if err := rows.Scan(&w.WebsiteID, &w.Domain, &w.Days); err != nil {
+			return nil, err
+		}
+		if w.Days > 0 {
+			w.Cutoff = cutoff(w.Days)
+		}
+		websites = append(websites, w)
+	}
+	return websites, rows.Err()
+}
+
+// SetWebsiteDays overrides a website's retention; nil restores the global
+// setting and 0 keeps its data forever
+func SetWebsiteDays(ctx context.Context, db *sql.DB, websiteID uuid.UUID, days *int) error {
+	if days != nil && *days < 0 {
+		return fmt.Errorf("retention days must not be negative")
+	}
+	res, err := db.ExecContext(ctx, `UPDATE website SET retention_days = $2, updated_at = NOW() WHERE website_id = $1`, websiteID, days)
+	if err != nil {
+		return fmt.Errorf("failed to set retention: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("website %s not found", websiteID)
+	}
+	return nil
+}
+
+// BuildPlan works out what a prune with the given global retention would
+// remove, without changing anything
+func BuildPlan(ctx context.Context, db *sql.DB, globalDays int) (*Plan, error) {
+	if globalDays < 0 {
+		return nil, fmt.Errorf("retention days must not be negative")
+	}
+	websites, err := Websites(ctx, db, globalDays)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{}
+
+	// Whole partitions can only go once no website wants their data
+	longest := 0
+	for _, w := range websites {
+		if w.Days == 0 {
+			longest = 0
+			break
+		}
+		if w.Days > longest {
+			longest = w.Days
+		}
+	}
+	if longest > 0 {
+		plan.DropBefore = cutoff(longest)
+		if plan.Partitions, err = ExpiredPartitions(ctx, db, plan.DropBefore); err != nil {
+			return nil, err
+		}
+	}
+
+	var dropBefore interface{}
+	if !plan.DropBefore.IsZero() {
+		dropBefore = plan.DropBefore
+	}
+	for _, w := range websites {
+		if w.Days == 0 {
+			continue
+		}
+		err := db.QueryRowContext(ctx, `
+			SELECT COUNT(*)
+			FROM website_event
+			WHERE website_id = $1 AND created_at < $2
+			  AND ($3::timestamptz IS NULL OR created_at >= $3)
+		`, w.WebsiteID, w.Cutoff, dropBefore).Scan(&w.Events)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count expired events for %s: %w", w.Domain, err)
+		}
+		err = db.QueryRowContext(ctx, `
+			SELECT COUNT(*)
+			FROM session s
+			WHERE s.website_id = $1 AND s.created_at < $2
+			  AND NOT EXISTS (
+				SELECT 1 FROM website_event e
+				WHERE e.session_id = s.session_id AND e.created_at >= $2
+			  )
+		`, w.WebsiteID, w.Cutoff).Scan(&w.Sessions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count expired sessions for %s: %w", w.Domain, err)
+		}
+		if w.Events > 0 || w.Sessions > 0 {
+			plan.Websites = append(plan.Websites, w)
+		}
+	}
+	return plan, nil
+}
+
+// ExpiredPartitions lists daily partitions that end on or before the cutoff,
+// with the planner's row estimate
+func ExpiredPartitions(ctx context.Context, db *sql.DB, before time.Time) ([]Partition, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT c.relname, GREATEST(c.reltuples, 0)::BIGINT
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		JOIN pg_class p ON p.oid = i.inhparent
+		WHERE p.relname = 'website_event'
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var partitions []Partition
+	for rows.Next() {
+		var p Partition
+		if err := rows.Scan(&p.Name, &p.Rows); err != nil {
+			return nil, err
+		}
+		day, err := time.Parse("2006_01_02", strings.TrimPrefix(p.Name, partitionPrefix))
+		if err != nil || !strings.HasPrefix(p.Name, partitionPrefix) {
+			continue // not a daily partition
+		}
+		if day.AddDate(0, 0, 1).After(before) {
+			continue
+		}
+		p.Day = day
+		partitions = append(partitions, p)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].Day.Before(partitions[j].Day) })
+	return partitions, rows.Err()
+}
+
+// Apply carries out a plan from BuildPlan
+func Apply(ctx context.Context, db *sql.DB, plan *Plan) (*Result, error) {
+	result := &Result{}
+
+	for _, p := range plan.Partitions {
+		if _, err := db.ExecContext(ctx, "DROP TABLE IF EXISTS "+pq.QuoteIdentifier(p.Name)); err != nil {
+			return result, fmt.Errorf("failed to drop partition %s: %w", p.Name, err)
+		}
+		result.Partitions++
+	}
+
+	for _, w := range plan.Websites {
+		for {
+			res, err := db.ExecContext(ctx, `
+				DELETE FROM website_event
+				WHERE website_id = $1 AND created_at < $2
+				  AND event_id IN (
+					SELECT event_id FROM website_event
+					WHERE website_id = $1 AND created_at < $2
+					LIMIT $3
+				  )
+			`, w.WebsiteID, w.Cutoff, deleteBatch)
+			if err != nil {
+				return result, fmt.Errorf("failed to delete events for %s: %w", w.Domain, err)
+			}
+			n, _ := res.RowsAffected()
+			result.Events += n
+			if n < deleteBatch {
+				break
+			}
+		}
+
+		res, err := db.ExecContext(ctx, `
+			DELETE FROM session s
+			WHERE s.website_id = $1 AND s.created_at < $2
+			  AND NOT EXISTS (
+				SELECT 1 FROM website_event e
+				WHERE e.session_id = s.session_id AND e.created_at >= $2
+			  )
+		`, w.WebsiteID, w.Cutoff)
+		if err != nil {
+			return result, fmt.Errorf("failed to delete sessions for %s: %w", w.Domain, err)
+		}
+		n, _ := res.RowsAffected()
+		result.Sessions += n
+	}
+	return result, nil
+}
+
+// Prune builds and applies a plan in one go
+func Prune(ctx context.Context, db *sql.DB, globalDays int) (*Result, error) {
+	plan, err := BuildPlan(ctx, db, globalDays)
+	if err != nil {
+		return nil, err
+	}
+	return Apply(ctx, db, plan)
+}
@@ -0,0 +1,148 @@
+package retention
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/test"
+)
+
+func fixNow(t *testing.T, ts time.Time) {
+	t.Helper()
+	original := now
+	now = func() time.Time { return ts }
+	t.Cleanup(func() { now = original })
+}
+
+func day(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func websiteRows(rows ...[]driver.Value) *sqlmock.Rows {
+	r := sqlmock.NewRows([]string{"website_id", "domain", "retention_days"})
+	for _, row := range rows {
+		r.AddRow(row...)
+	}
+	return r
+}
+
+func TestBuildPlanDropsPartitionsPastLongestRetention(t *testing.T) {
+	fixNow(t, time.Date(2025, 6, 10, 15, 0, 0, 0, time.UTC))
+	db, mock := test.NewMockDB(t)
+
+	short, long := uuid.New(), uuid.New()
+	mock.ExpectQuery(`FROM website`).WithArgs(30).
+		WillReturnRows(websiteRows(
+			[]driver.Value{short, "short.example", 7},
+			[]driver.Value{long, "long.example", 30},
+		))
+	mock.ExpectQuery(`FROM pg_inherits`).
+		WillReturnRows(sqlmock.NewRows([]string{"relname", "reltuples"}).
+			AddRow("website_event_2025_05_11", 900).
+			AddRow("website_event_2025_05_09", 1000).
+			AddRow("website_event_2025_05_10", 1100).
+			AddRow("website_event_default", 5))
+
+	// short.example expires a week of data beyond the dropped partitions
+	mock.ExpectQuery(`SELECT COUNT\(\*\)\s+FROM website_event`).
+		WithArgs(short, day("2025-06-03"), day("2025-05-11")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(4200))
+	mock.ExpectQuery(`FROM session s`).
+		WithArgs(short, day("2025-06-03")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(300))
+	mock.ExpectQuery(`SELECT COUNT\(\*\)\s+FROM website_event`).
+		WithArgs(long, day("2025-05-11"), day("2025-05-11")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`FROM session s`).
+		WithArgs(long, day("2025-05-11")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	plan, err := BuildPlan(context.Background(), db, 30)
+	require.NoError(t, err)
+
+	assert.Equal(t, day("2025-05-11"), plan.DropBefore)
+	require.Len(t, plan.Partitions, 2)
+	assert.Equal(t, "website_event_2025_05_09", plan.Partitions[0].Name)
+	assert.Equal(t, "website_event_2025_05_10", plan.Partitions[1].Name)
+	require.Len(t, plan.Websites, 1, "websites with nothing to delete are left out")
+	assert.Equal(t, "short.example", plan.Websites[0].Domain)
+	assert.Equal(t, int64(4200), plan.Websites[0].Events)
+	assert.Equal(t, int64(300), plan.Websites[0].Sessions)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBuildPlanKeepsPartitionsWhenAWebsiteKeepsForever(t *testing.T) {
+	fixNow(t, time.Date(2025, 6, 10, 15, 0, 0, 0, time.UTC))
+	db, mock := test.NewMockDB(t)
+
+	expiring := uuid.New()
+	mock.ExpectQuery(`FROM website`).WithArgs(90).
+		WillReturnRows(websiteRows(
+			[]driver.Value{uuid.New(), "archive.example", 0},
+			[]driver.Value{expiring, "blog.example", 90},
+		))
+	mock.ExpectQuery(`SELECT COUNT\(\*\)\s+FROM website_event`).
+		WithArgs(expiring, day("2025-03-12"), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(10))
+	mock.ExpectQuery(`FROM session s`).
+		WithArgs(expiring, day("2025-03-12")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	plan, err := BuildPlan(context.Background(), db, 90)
+	require.NoError(t, err)
+	assert.True(t, plan.DropBefore.IsZero())
+	assert.Empty(t, plan.Partitions)
+	require.Len(t, plan.Websites, 1)
+	assert.Equal(t, "blog.example", plan.Websites[0].Domain)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApplyDeletesInBatches(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+	cutoff := day("2025-06-03")
+
+	plan := &Plan{
+		Partitions: []Partition{{Name: "website_event_2025_05_09"}},
+		Websites:   []Website{{WebsiteID: websiteID, Domain: "short.example", Days: 7, Cutoff: cutoff}},
+	}
+
+	mock.ExpectExec(`DROP TABLE IF EXISTS "website_event_2025_05_09"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM website_event`).WithArgs(websiteID, cutoff, deleteBatch).
+		WillReturnResult(sqlmock.NewResult(0, deleteBatch))
+	mock.ExpectExec(`DELETE FROM website_event`).WithArgs(websiteID, cutoff, deleteBatch).
+		WillReturnResult(sqlmock.NewResult(0, 25))
+	mock.ExpectExec(`DELETE FROM session s`).WithArgs(websiteID, cutoff).
+		WillReturnResult(sqlmock.NewResult(0, 7))
+
+	result, err := Apply(context.Background(), db, plan)
+	require.NoError(t, err)
+	assert.Equal(t, &Result{Partitions: 1, Events: deleteBatch + 25, Sessions: 7}, result)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetWebsiteDays(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+
+	days := 30
+	mock.ExpectExec(`UPDATE website SET retention_days`).WithArgs(websiteID, 30).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, SetWebsiteDays(context.Background(), db, websiteID, &days))
+
+	mock.ExpectExec(`UPDATE website SET retention_days`).WithArgs(websiteID, nil).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.Error(t, SetWebsiteDays(context.Background(), db, websiteID, nil))
+
+	negative := -1
+	assert.Error(t, SetWebsiteDays(context.Background(), db, websiteID, &negative))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,187 @@
+// Package rollup maintains the pre-aggregated hourly and daily stats tables
+// (rollup_hourly, rollup_daily, rollup_daily_dimension) so long-range reports
+// don't scan raw events.
+//
+// Days are calendar days in each website's time zone (website.timezone, UTC
+// by default). refresh_rollup_day() recomputes a whole day for every website
+// from website_event, which makes every refresh idempotent: the worker task
+// re-aggregates today (and any day since its last run) every few minutes,
+// and Backfill walks history newest-first. rollup_state.covered_since records
+// the oldest day from which the rollups are complete; readers only use the
+// rollups when a range lies entirely inside that coverage.
+//
+// Visitors over multi-day ranges are the sum of daily distinct sessions, so a
+// session that spans midnight is counted once per day.
+package rollup
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// zoneMargin is how many days a website's time zone can be ahead of or
+// behind UTC: its today is one of the UTC days around Today()
+const zoneMargin = 1
+
+// MinDays is the shortest range served from rollups. Shorter ranges stay on
+// raw events so today's dashboard is always live.
+const MinDays = 2
+
+// Dimensions are the breakdowns kept in rollup_daily_dimension
+var Dimensions = []string{"page", "referrer", "country", "region", "city", "browser", "os", "device"}
+
+// HasDimension reports whether a breakdown dimension is rolled up
+func HasDimension(dimension string) bool {
+	for _, d := range Dimensions {
+		if d == dimension {
+			return true
+		}
+	}
+	return false
+}
+
+// now is overridden in tests
+var now = time.Now
+
+// Today returns the current UTC day at midnight
+func Today() time.Time {
+	return now().UTC().Truncate(24 * time.Hour)
+}
+
+// State is the rollup bookkeeping row
+type State struct {
+	CoveredSince *time.Time
+	RefreshedAt  *time.Time
+}
+
+// GetState reads rollup_state
+func GetState(ctx context.Context, db *sql.DB) (State, error) {
+	var s State
+	var covered, refreshed sql.NullTime
+	err := db.QueryRowContext(ctx, `SELECT covered_since, refreshed_at FROM rollup_state`).Scan(&covered, &refreshed)
+	if err == sql.ErrNoRows {
+		return s, nil
+	}
+	if err != nil {
+		return s, fmt.Errorf("failed to read rollup state: %w", err)
+	}
+	if covered.Valid {
+		t := covered.Time.UTC()
+		s.CoveredSince = &t
+	}
+	if refreshed.Valid {
+		t := refreshed.Time
+		s.RefreshedAt = &t
+	}
+	return s, nil
+}
+
+// Covers reports whether the rollups are complete for the last days days
+func Covers(ctx context.Context, db *sql.DB, days int) (bool, error) {
+	if days < MinDays {
+		return false, nil
+	}
+	state, err := GetState(ctx, db)
+	if err != nil {
+		return false, err
+	}
+	if state.CoveredSince == nil {
+		return false, nil
+	}
+	// A website behind UTC starts its range a day earlier
+	return !state.CoveredSince.After(Since(days).AddDate(0, 0, -zoneMargin)), nil
+}
+
+// Since returns the first UTC day of a days-long range ending now; in a
+// website's time zone the range may start a day earlier or later.
+func Since(days int) time.Time {
+	return Today().AddDate(0, 0, -days)
+}
+
+// RefreshDay recomputes the rollups for one day of every website
+func RefreshDay(ctx context.Context, db *sql.DB, day time.Time) error {
+	if _, err := db.ExecContext(ctx, `SELECT refresh_rollup_day($1::date)`, day.UTC().Format("2006-01-02")); err != nil {
+		return fmt.Errorf("failed to refresh rollups for %s: %w", day.Format("2006-01-02"), err)
+	}
+	return nil
+}
+
+// lateEvents is how far back the worker looks for events that arrived after
+// their day was last refreshed (ingest buffering, retried batches)
+const lateEvents = time.Hour
+
+// Refresh brings the rollups up to date: every day since the previous
+// refresh (minus a margin for late events) through today is recomputed, in
+// every website's time zone. The first refresh on an empty database starts
+// coverage at yesterday (UTC), still today for websites behind UTC.
+func Refresh(ctx context.Context, db *sql.DB) error {
+	state, err := GetState(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	started := now()
+	today := Today()
+	from := today
+	if state.RefreshedAt != nil {
+		from = state.RefreshedAt.Add(-lateEvents).UTC().Truncate(24 * time.Hour)
+	}
+	from = from.AddDate(0, 0, -zoneMargin)
+	if state.CoveredSince != nil && from.Before(*state.CoveredSince) {
+		from = *state.CoveredSince
+	}
+
+	for day := from; !day.After(today.AddDate(0, 0, zoneMargin)); day = day.AddDate(0, 0, 1) {
+		if err := RefreshDay(ctx, db, day); err != nil {
+			return err
+		}
+	}
+
+	coveredSince := from
+	if state.CoveredSince != nil && state.CoveredSince.Before(from) {
+		coveredSince = *state.CoveredSince
+	}
+	_, err = db.ExecContext(ctx, `
+		UPDATE rollup_state SET covered_since = $1::date, refreshed_at = $2
+	`, coveredSince.Format("2006-01-02"), started)
+	return err
+}
+
+// Backfill recomputes the last days days, newest first (from tomorrow, today
+// for websites ahead of UTC), extending coverage
+// after each day so an interrupted backfill still leaves usable rollups.
+// progress (optional) is called after each day.
+func Backfill(ctx context.Context, db *sql.DB, days int, progress func(day time.Time)) error {
+	if days < 0 {
+		return fmt.Errorf("days must not be negative")
+	}
+
+	today := Today()
+	if _, err := db.ExecContext(ctx, `
+		UPDATE rollup_state SET refreshed_at = COALESCE(refreshed_at, $1)
+	`, now()); err != nil {
+		return err
+	}
+
+	for i := -zoneMargin; i <= days; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		day := today.AddDate(0, 0, -i)
+		if err := RefreshDay(ctx, db, day); err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, `
+			UPDATE rollup_state
+			SET covered_since = LEAST(COALESCE(covered_since, $1::date), $1::date)
+		`, day.Format("2006-01-02")); err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(day)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package rollup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/test"
+)
+
+func fixNow(t *testing.T, ts time.Time) {
+	t.Helper()
+	original := now
+	now = func() time.Time { return ts }
+	t.Cleanup(func() { now = original })
+}
+
+func stateRows(covered, refreshed interface{}) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"covered_since", "refreshed_at"}).AddRow(covered, refreshed)
+}
+
+func day(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func TestCovers(t *testing.T) {
+	fixNow(t, time.Date(2025, 3, 10, 15, 0, 0, 0, time.UTC))
+	db, mock := test.NewMockDB(t)
+
+	ok, err := Covers(context.Background(), db, 1)
+	require.NoError(t, err)
+	assert.False(t, ok, "single-day ranges stay on raw events")
+
+	mock.ExpectQuery(`FROM rollup_state`).WillReturnRows(stateRows(nil, nil))
+	ok, err = Covers(context.Background(), db, 7)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	mock.ExpectQuery(`FROM rollup_state`).WillReturnRows(stateRows(day("2025-03-02"), time.Now()))
+	ok, err = Covers(context.Background(), db, 7)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// Websites behind UTC start the range a day earlier
+	mock.ExpectQuery(`FROM rollup_state`).WillReturnRows(stateRows(day("2025-03-03"), time.Now()))
+	ok, err = Covers(context.Background(), db, 7)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	mock.ExpectQuery(`FROM rollup_state`).WillReturnRows(stateRows(day("2025-03-03"), time.Now()))
+	ok, err = Covers(context.Background(), db, 30)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshFirstRunStartsCoverageYesterday(t *testing.T) {
+	ts := time.Date(2025, 3, 10, 15, 0, 0, 0, time.UTC)
+	fixNow(t, ts)
+	db, mock := test.NewMockDB(t)
+
+	mock.ExpectQuery(`FROM rollup_state`).WillReturnRows(stateRows(nil, nil))
+	// Today in every time zone, from UTC-12 to UTC+14
+	for _, d := range []string{"2025-03-09", "2025-03-10", "2025-03-11"} {
+		mock.ExpectExec(`SELECT refresh_rollup_day`).WithArgs(d).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec(`UPDATE rollup_state SET covered_since`).WithArgs("2025-03-09", ts).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, Refresh(context.Background(), db))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshCatchesUpSinceLastRun(t *testing.T) {
+	ts := time.Date(2025, 3, 10, 0, 30, 0, 0, time.UTC)
+	fixNow(t, ts)
+	db, mock := test.NewMockDB(t)
+
+	// Last run two days ago; coverage goes back further
+	lastRun := time.Date(2025, 3, 8, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`FROM rollup_state`).WillReturnRows(stateRows(day("2025-01-01"), lastRun))
+	for _, d := range []string{"2025-03-07", "2025-03-08", "2025-03-09", "2025-03-10", "2025-03-11"} {
+		mock.ExpectExec(`SELECT refresh_rollup_day`).WithArgs(d).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec(`UPDATE rollup_state SET covered_since`).WithArgs("2025-01-01", ts).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, Refresh(context.Background(), db))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBackfillExtendsCoverageNewestFirst(t *testing.T) {
+	fixNow(t, time.Date(2025, 3, 10, 15, 0, 0, 0, time.UTC))
+	db, mock := test.NewMockDB(t)
+
+	mock.ExpectExec(`SET refreshed_at = COALESCE`).WillReturnResult(sqlmock.NewResult(0, 1))
+	for _, d := range []string{"2025-03-11", "2025-03-10", "2025-03-09", "2025-03-08"} {
+		mock.ExpectExec(`SELECT refresh_rollup_day`).WithArgs(d).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`SET covered_since = LEAST`).WithArgs(d).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	var seen []string
+	require.NoError(t, Backfill(context.Background(), db, 2, func(d time.Time) {
+		seen = append(seen, d.Format("2006-01-02"))
+	}))
+	assert.Equal(t, []string{"2025-03-11", "2025-03-10", "2025-03-09", "2025-03-08"}, seen)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHasDimension(t *testing.T) {
+	assert.True(t, HasDimension("country"))
+	assert.True(t, HasDimension("page"))
+	assert.False(t, HasDimension("language"))
+}
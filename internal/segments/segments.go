@@ -0,0 +1,227 @@
+// Package segments manages saved segments: named sets of the dashboard's
+// filters (country, browser, device, page, bot and custom dimensions) that a
+// website stores once and applies by name, with `--segment` on the stats
+// commands or ?segment= on the dashboard API.
+//
+// Segments live in PostgreSQL (segment) and are named per website.
+package segments
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/dimensions"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// ErrNotFound is returned for a name a website saved no segment under
+var ErrNotFound = errors.New("segment not found")
+
+// FilterKeys are the filters a segment accepts besides dim.<name>, with the
+// dashboard API's names
+var FilterKeys = []string{"country", "browser", "device", "page", "bot"}
+
+// DimensionFilterPrefix marks custom dimension filters, as on the dashboard
+const DimensionFilterPrefix = "dim."
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+
+// Segment is a saved set of filters
+type Segment struct {
+	WebsiteID uuid.UUID         `json:"website_id"`
+	Name      string            `json:"name"`
+	Filters   map[string]string `json:"filters"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// ValidName checks that name can be saved: lowercase letters, digits, _ and
+// -, starting with a letter or digit
+func ValidName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid segment name: %q (use lowercase letters, digits, _ and -, up to 50 characters)", name)
+	}
+	return nil
+}
+
+// ValidateFilters checks filter names and values. Custom dimensions are only
+// checked for their names: whether a website registered them matters when
+// the filters are applied.
+func ValidateFilters(filters map[string]string) error {
+	for key := range filters {
+		if name, ok := strings.CutPrefix(key, DimensionFilterPrefix); ok {
+			if err := dimensions.ValidName(name); err != nil {
+				return fmt.Errorf("invalid filter %q: %w", key, err)
+			}
+			continue
+		}
+		if !slices.Contains(FilterKeys, key) {
+			return fmt.Errorf("invalid filter: %s (use %s or dim.<name>)", key, strings.Join(FilterKeys, ", "))
+		}
+	}
+	if bot, ok := filters["bot"]; ok {
+		if _, err := strconv.ParseBool(bot); err != nil {
+			return fmt.Errorf("invalid filter bot=%s (use true or false)", bot)
+		}
+	}
+	return nil
+}
+
+// StoreFilters converts filters for the store; empty values are left out
+func StoreFilters(filters map[string]string) store.Filters {
+	f := store.Filters{
+		Country: filters["country"],
+		Browser: filters["browser"],
+		Device:  filters["device"],
+		Page:    filters["page"],
+	}
+	if bot, err := strconv.ParseBool(filters["bot"]); err == nil {
+		f.Bot = &bot
+	}
+	for key, value := range filters {
+		if name, ok := strings.CutPrefix(key, DimensionFilterPrefix); ok && value != "" {
+			if f.Dimensions == nil {
+				f.Dimensions = make(map[string]string)
+			}
+			f.Dimensions[name] = value
+		}
+	}
+	return f
+}
+
+// Merge applies the filters set in override on top of base
+func Merge(base, override store.Filters) store.Filters {
+	merged := base
+	for _, field := range []struct{ dst, src *string }{
+		{&merged.Country, &override.Country},
+		{&merged.Browser, &override.Browser},
+		{&merged.Device, &override.Device},
+		{&merged.Page, &override.Page},
+	} {
+		if *field.src != "" {
+			*field.dst = *field.src
+		}
+	}
+	if override.Bot != nil {
+		merged.Bot = override.Bot
+	}
+	if len(override.Dimensions) > 0 {
+		merged.Dimensions = make(map[string]string, len(base.Dimensions)+len(override.Dimensions))
+		for name, value := range base.Dimensions {
+			merged.Dimensions[name] = value
+		}
+		for name, value := range override.Dimensions {
+			merged.Dimensions[name] = value
+		}
+	}
+	return merged
+}
+
+// Save stores a website's segment, replacing the one saved under the same
+// name
+func Save(ctx context.Context, db *sql.DB, websiteID uuid.UUID, name string, filters map[string]string) error {
+	if err := ValidName(name); err != nil {
+		return err
+	}
+	if len(filters) == 0 {
+		return errors.New("a segment needs at least one filter")
+	}
+	if err := ValidateFilters(filters); err != nil {
+		return err
+	}
+	data, err := json.Marshal(filters)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO segment (website_id, name, filters)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (website_id, name) DO UPDATE
+		SET filters = EXCLUDED.filters, updated_at = NOW()
+	`, websiteID, name, data)
+	if err != nil {
+		return fmt.Errorf("failed to save segment: %w", err)
+	}
+	return nil
+}
+
+const selectSegments = `SELECT website_id, name, filters, created_at, updated_at FROM segment`
+
+func scanSegment(row interface{ Scan(...interface{}) error }) (*Segment, error) {
+	var s Segment
+	var filters []byte
+	if err := row.Scan(&s.WebsiteID, &s.Name, &filters, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(filters, &s.Filters); err != nil {
+		return nil, fmt.Errorf("segment %s has invalid filters: %w", s.Name, err)
+	}
+	return &s, nil
+}
+
+// Get returns a website's segment saved under name
+func Get(ctx context.Context, db *sql.DB, websiteID uuid.UUID, name string) (*Segment, error) {
+	s, err := scanSegment(db.QueryRowContext(ctx, selectSegments+` WHERE website_id = $1 AND name = $2`, websiteID, name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load segment: %w", err)
+	}
+	return s, nil
+}
+
+// List returns a website's segments by name
+func List(ctx context.Context, db *sql.DB, websiteID uuid.UUID) ([]Segment, error) {
+	rows, err := db.QueryContext(ctx, selectSegments+` WHERE website_id = $1 ORDER BY name`, websiteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list segments: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	list := []Segment{}
+	for rows.Next() {
+		s, err := scanSegment(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *s)
+	}
+	return list, rows.Err()
+}
+
+// Remove deletes a website's segment saved under name
+func Remove(ctx context.Context, db *sql.DB, websiteID uuid.UUID, name string) error {
+	res, err := db.ExecContext(ctx, `DELETE FROM segment WHERE website_id = $1 AND name = $2`, websiteID, name)
+	if err != nil {
+		return fmt.Errorf("failed to remove segment: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Filters returns the store filters of a website's segment
+func Filters(ctx context.Context, db *sql.DB, websiteID uuid.UUID, name string) (store.Filters, error) {
+	s, err := Get(ctx, db, websiteID, name)
+	if err != nil {
+		return store.Filters{}, err
+	}
+	return StoreFilters(s.Filters), nil
+}
@@ -0,0 +1,78 @@
+package segments
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/store"
+	"github.com/seuros/kaunta/internal/test"
+)
+
+func TestValidateFilters(t *testing.T) {
+	assert.NoError(t, ValidateFilters(map[string]string{"country": "DE", "device": "mobile", "dim.plan": "pro"}))
+	assert.EqualError(t, ValidateFilters(map[string]string{"utm_source": "newsletter"}),
+		"invalid filter: utm_source (use country, browser, device, page, bot or dim.<name>)")
+	assert.EqualError(t, ValidateFilters(map[string]string{"bot": "maybe"}), "invalid filter bot=maybe (use true or false)")
+	assert.ErrorContains(t, ValidateFilters(map[string]string{"dim.Bad Name": "x"}), `invalid filter "dim.Bad Name"`)
+}
+
+func TestStoreFiltersAndMerge(t *testing.T) {
+	f := StoreFilters(map[string]string{"country": "DE", "device": "mobile", "bot": "false", "dim.plan": "pro"})
+	bot := false
+	assert.Equal(t, store.Filters{Country: "DE", Device: "mobile", Bot: &bot, Dimensions: map[string]string{"plan": "pro"}}, f)
+
+	merged := Merge(f, store.Filters{Device: "desktop", Page: "/pricing", Dimensions: map[string]string{"team": "a"}})
+	assert.Equal(t, store.Filters{Country: "DE", Device: "desktop", Page: "/pricing", Bot: &bot,
+		Dimensions: map[string]string{"plan": "pro", "team": "a"}}, merged)
+	// The segment's own map is left alone
+	assert.Equal(t, map[string]string{"plan": "pro"}, f.Dimensions)
+}
+
+func TestSave(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+	mock.ExpectExec(`INSERT INTO segment`).
+		WithArgs(websiteID, "german-mobile", []byte(`{"country":"DE","device":"mobile"}`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, Save(context.Background(), db, websiteID, "german-mobile", map[string]string{"country": "DE", "device": "mobile"}))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	assert.EqualError(t, Save(context.Background(), db, websiteID, "German Mobile", map[string]string{"country": "DE"}),
+		`invalid segment name: "German Mobile" (use lowercase letters, digits, _ and -, up to 50 characters)`)
+	assert.EqualError(t, Save(context.Background(), db, websiteID, "empty", nil), "a segment needs at least one filter")
+}
+
+func TestFilters(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+	now := time.Now()
+	mock.ExpectQuery(`FROM segment WHERE website_id = \$1 AND name = \$2`).WithArgs(websiteID, "german-mobile").
+		WillReturnRows(sqlmock.NewRows([]string{"website_id", "name", "filters", "created_at", "updated_at"}).
+			AddRow(websiteID, "german-mobile", []byte(`{"country":"DE","device":"mobile"}`), now, now))
+	mock.ExpectQuery(`FROM segment`).WithArgs(websiteID, "nope").WillReturnError(sql.ErrNoRows)
+
+	f, err := Filters(context.Background(), db, websiteID, "german-mobile")
+	require.NoError(t, err)
+	assert.Equal(t, store.Filters{Country: "DE", Device: "mobile"}, f)
+
+	_, err = Filters(context.Background(), db, websiteID, "nope")
+	assert.ErrorIs(t, err, ErrNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRemoveUnknown(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+	mock.ExpectExec(`DELETE FROM segment`).WithArgs(websiteID, "nope").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.ErrorIs(t, Remove(context.Background(), db, websiteID, "nope"), ErrNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
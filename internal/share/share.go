@@ -0,0 +1,96 @@
+// Package share holds the rules of website share links: a share ID opens a
+// read-only dashboard and the top pages feed without a login, optionally
+// behind a password and until an expiry.
+//
+// Passwords are stored as bcrypt hashes. Entering the password gives the
+// browser a token derived from the share ID and the hash, so a new password
+// or a rotated share ID signs every visitor out.
+package share
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MinPassword is the shortest password a share accepts
+const MinPassword = 8
+
+// CookieName is the cookie holding the token of an unlocked share
+const CookieName = "kaunta_share"
+
+// HashPassword hashes a share password with bcrypt
+func HashPassword(password string) (string, error) {
+	if utf8.RuneCountInString(password) < MinPassword {
+		return "", fmt.Errorf("a share password needs at least %d characters", MinPassword)
+	}
+	// bcrypt ignores what follows the 72nd byte; refuse rather than
+	// silently accept a shorter password than the one typed
+	if len(password) > 72 {
+		return "", fmt.Errorf("a share password is at most 72 bytes")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash share password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches a share's hash
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// Token is what a browser keeps once it entered a share's password
+func Token(shareID, hash string) string {
+	mac := hmac.New(sha256.New, []byte(hash))
+	mac.Write([]byte(shareID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidToken reports whether token unlocks the share
+func ValidToken(shareID, hash, token string) bool {
+	return token != "" && hmac.Equal([]byte(token), []byte(Token(shareID, hash)))
+}
+
+// ParseExpiry reads when a share ends: a duration from now ("72h", "30d"),
+// the last day it works (YYYY-MM-DD, through the end of that day in local
+// time), or "never"
+func ParseExpiry(value string, now time.Time) (*time.Time, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "never" {
+		return nil, nil
+	}
+	var at time.Time
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 1 {
+			return nil, expiryError(value)
+		}
+		at = now.AddDate(0, 0, n)
+	} else if d, err := time.ParseDuration(value); err == nil {
+		if d <= 0 {
+			return nil, expiryError(value)
+		}
+		at = now.Add(d)
+	} else if day, err := time.ParseInLocation(time.DateOnly, value, now.Location()); err == nil {
+		at = day.AddDate(0, 0, 1)
+		if !at.After(now) {
+			return nil, fmt.Errorf("expiry %s is in the past", value)
+		}
+	} else {
+		return nil, expiryError(value)
+	}
+	return &at, nil
+}
+
+func expiryError(value string) error {
+	return fmt.Errorf("invalid expiry: %q (use a duration like 72h or 30d, YYYY-MM-DD or never)", value)
+}
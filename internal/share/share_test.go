@@ -0,0 +1,55 @@
+package share
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	require.NoError(t, err)
+	assert.True(t, CheckPassword(hash, "correct horse"))
+	assert.False(t, CheckPassword(hash, "wrong horse"))
+
+	_, err = HashPassword("short")
+	assert.EqualError(t, err, "a share password needs at least 8 characters")
+	_, err = HashPassword(strings.Repeat("x", 73))
+	assert.Error(t, err)
+}
+
+func TestToken(t *testing.T) {
+	token := Token("abc123", "$2a$10$hash")
+	assert.True(t, ValidToken("abc123", "$2a$10$hash", token))
+	assert.False(t, ValidToken("def456", "$2a$10$hash", token), "rotated share ID")
+	assert.False(t, ValidToken("abc123", "$2a$10$other", token), "new password")
+	assert.False(t, ValidToken("abc123", "$2a$10$hash", ""))
+}
+
+func TestParseExpiry(t *testing.T) {
+	now := time.Date(2025, 6, 15, 14, 0, 0, 0, time.UTC)
+
+	at, err := ParseExpiry("72h", now)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(72*time.Hour), *at)
+
+	at, err = ParseExpiry("30d", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 7, 15, 14, 0, 0, 0, time.UTC), *at)
+
+	at, err = ParseExpiry("2025-06-30", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), *at)
+
+	at, err = ParseExpiry("never", now)
+	require.NoError(t, err)
+	assert.Nil(t, at)
+
+	for _, value := range []string{"soon", "0d", "-1h", "2025-06-01"} {
+		_, err := ParseExpiry(value, now)
+		assert.Error(t, err, value)
+	}
+}
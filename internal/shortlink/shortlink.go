@@ -0,0 +1,232 @@
+// Package shortlink serves short links (/l/<slug>) that redirect to a
+// website's pages and record each click, so campaign links are measured
+// alongside the rest of the website's traffic.
+//
+// A click is recorded as a custom event (EventName) on the target page, with
+// the slug as a property: referrer, country and device come from the
+// visitor's request as for any event, and UTM parameters of the target are
+// attributed to the visit. Links live in PostgreSQL.
+package shortlink
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skip2/go-qrcode"
+)
+
+// Path is where short links are served: Path + slug
+const Path = "/l/"
+
+// EventName is the custom event recorded for each click, with the slug in
+// its slug property
+const EventName = "link_click"
+
+// Sizes of QR codes in pixels
+const (
+	DefaultQRSize = 256
+	MinQRSize     = 64
+	MaxQRSize     = 2048
+)
+
+// generatedLength is the length of slugs picked by Create
+const generatedLength = 7
+
+const slugAlphabet = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+var slugPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ErrNotFound is returned for a slug that isn't a short link
+var ErrNotFound = errors.New("short link not found")
+
+// ErrSlugTaken is returned by Create for a slug already in use
+var ErrSlugTaken = errors.New("slug already in use")
+
+// Link is a short link
+type Link struct {
+	Slug      string    `json:"slug"`
+	WebsiteID uuid.UUID `json:"website_id"`
+	Domain    string    `json:"domain"`
+	Target    string    `json:"target"`
+	CreatedAt time.Time `json:"created_at"`
+	// Clicks and Visitors count the clicks of a period, for List
+	Clicks   int64 `json:"clicks"`
+	Visitors int64 `json:"visitors"`
+}
+
+// ValidSlug checks a slug: letters, digits, - and _
+func ValidSlug(slug string) error {
+	if !slugPattern.MatchString(slug) {
+		return fmt.Errorf("invalid slug: %q (use letters, digits, - and _, up to 64 characters)", slug)
+	}
+	return nil
+}
+
+// ValidTarget checks a target is an absolute http(s) URL
+func ValidTarget(target string) error {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid target URL: %q (use an absolute http or https URL)", target)
+	}
+	return nil
+}
+
+// Host returns the host of a target, without www., to find its website
+func Host(target string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// URL returns the address of a short link served by the Kaunta server at
+// base (https://stats.example.com)
+func URL(base, slug string) string {
+	return strings.TrimRight(base, "/") + Path + slug
+}
+
+// QR renders a PNG QR code of a short link's address, size pixels wide.
+// Medium error correction keeps codes readable when printed small or
+// slightly damaged.
+func QR(address string, size int) ([]byte, error) {
+	if size < MinQRSize || size > MaxQRSize {
+		return nil, fmt.Errorf("QR code size must be between %d and %d pixels", MinQRSize, MaxQRSize)
+	}
+	png, err := qrcode.Encode(address, qrcode.Medium, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render QR code: %w", err)
+	}
+	return png, nil
+}
+
+// Create adds a short link to target for a website. An empty slug picks a
+// random one.
+func Create(ctx context.Context, db *sql.DB, websiteID uuid.UUID, target, slug string) (*Link, error) {
+	if err := ValidTarget(target); err != nil {
+		return nil, err
+	}
+	generated := slug == ""
+	if !generated {
+		if err := ValidSlug(slug); err != nil {
+			return nil, err
+		}
+	}
+	// A random slug is picked again in the unlikely case it is taken
+	for attempt := 0; attempt < 3; attempt++ {
+		if generated {
+			var err error
+			if slug, err = randomSlug(); err != nil {
+				return nil, err
+			}
+		}
+		link := Link{Slug: slug, WebsiteID: websiteID, Target: target}
+		err := db.QueryRowContext(ctx, `
+			INSERT INTO short_link (slug, website_id, target_url)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (slug) DO NOTHING
+			RETURNING created_at
+		`, slug, websiteID, target).Scan(&link.CreatedAt)
+		if err == nil {
+			return &link, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to create short link: %w", err)
+		}
+		if !generated {
+			return nil, ErrSlugTaken
+		}
+	}
+	return nil, ErrSlugTaken
+}
+
+// randomSlug picks a slug from an alphabet without look-alike characters
+func randomSlug() (string, error) {
+	b := make([]byte, generatedLength)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(slugAlphabet))))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate slug: %w", err)
+		}
+		b[i] = slugAlphabet[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// Resolve returns the short link of a slug
+func Resolve(ctx context.Context, db *sql.DB, slug string) (*Link, error) {
+	var link Link
+	err := db.QueryRowContext(ctx, `
+		SELECT l.slug, l.website_id, w.domain, l.target_url, l.created_at
+		FROM short_link l
+		JOIN website w ON w.website_id = l.website_id
+		WHERE l.slug = $1 AND w.deleted_at IS NULL
+	`, slug).Scan(&link.Slug, &link.WebsiteID, &link.Domain, &link.Target, &link.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up short link: %w", err)
+	}
+	return &link, nil
+}
+
+// List returns the short links, of one website unless websiteID is nil,
+// newest first, with their clicks over the last days
+func List(ctx context.Context, db *sql.DB, websiteID uuid.UUID, days int) ([]Link, error) {
+	var website interface{}
+	if websiteID != uuid.Nil {
+		website = websiteID
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT l.slug, l.website_id, w.domain, l.target_url, l.created_at,
+			COUNT(e.event_id), COUNT(DISTINCT e.session_id)
+		FROM short_link l
+		JOIN website w ON w.website_id = l.website_id
+		LEFT JOIN website_event e ON e.website_id = l.website_id
+			AND e.event_type = 2 AND e.event_name = $3
+			AND e.props ->> 'slug' = l.slug
+			AND e.created_at >= NOW() - make_interval(days => $2)
+		WHERE ($1::uuid IS NULL OR l.website_id = $1) AND w.deleted_at IS NULL
+		GROUP BY l.slug, l.website_id, w.domain, l.target_url, l.created_at
+		ORDER BY l.created_at DESC, l.slug
+	`, website, days, EventName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list short links: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	links := []Link{}
+	for rows.Next() {
+		var l Link
+		if err := rows.Scan(&l.Slug, &l.WebsiteID, &l.Domain, &l.Target, &l.CreatedAt, &l.Clicks, &l.Visitors); err != nil {
+			return nil, fmt.Errorf("failed to read short link: %w", err)
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+// Delete removes a short link; its recorded clicks are kept
+func Delete(ctx context.Context, db *sql.DB, slug string) error {
+	res, err := db.ExecContext(ctx, `DELETE FROM short_link WHERE slug = $1`, slug)
+	if err != nil {
+		return fmt.Errorf("failed to delete short link: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
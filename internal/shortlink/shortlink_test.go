@@ -0,0 +1,146 @@
+package shortlink
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidSlug(t *testing.T) {
+	assert.NoError(t, ValidSlug("spring"))
+	assert.NoError(t, ValidSlug("Spring_2026-launch"))
+	assert.Error(t, ValidSlug(""))
+	assert.Error(t, ValidSlug("spring/launch"))
+	assert.Error(t, ValidSlug("été"))
+}
+
+func TestValidTarget(t *testing.T) {
+	assert.NoError(t, ValidTarget("https://example.com/launch?utm_source=x"))
+	assert.NoError(t, ValidTarget("http://example.com"))
+	assert.Error(t, ValidTarget("/launch"))
+	assert.Error(t, ValidTarget("javascript:alert(1)"))
+	assert.Error(t, ValidTarget("ftp://example.com/file"))
+}
+
+func TestHost(t *testing.T) {
+	assert.Equal(t, "example.com", Host("https://WWW.Example.com:8443/launch"))
+	assert.Equal(t, "shop.example.com", Host("https://shop.example.com/"))
+}
+
+func TestCreate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	websiteID := uuid.New()
+	created := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`INSERT INTO short_link`).WithArgs("spring", websiteID, "https://example.com/launch").
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(created))
+	link, err := Create(context.Background(), db, websiteID, "https://example.com/launch", "spring")
+	require.NoError(t, err)
+	assert.Equal(t, &Link{Slug: "spring", WebsiteID: websiteID, Target: "https://example.com/launch", CreatedAt: created}, link)
+
+	// A slug in use inserts nothing
+	mock.ExpectQuery(`INSERT INTO short_link`).WithArgs("spring", websiteID, "https://example.com/launch").
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}))
+	_, err = Create(context.Background(), db, websiteID, "https://example.com/launch", "spring")
+	assert.ErrorIs(t, err, ErrSlugTaken)
+
+	// A random slug is picked again when taken
+	mock.ExpectQuery(`INSERT INTO short_link`).WithArgs(sqlmock.AnyArg(), websiteID, "https://example.com/launch").
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}))
+	mock.ExpectQuery(`INSERT INTO short_link`).WithArgs(sqlmock.AnyArg(), websiteID, "https://example.com/launch").
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(created))
+	link, err = Create(context.Background(), db, websiteID, "https://example.com/launch", "")
+	require.NoError(t, err)
+	assert.Len(t, link.Slug, generatedLength)
+	assert.NoError(t, ValidSlug(link.Slug))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = Create(context.Background(), db, websiteID, "/launch", "spring")
+	assert.Error(t, err)
+	_, err = Create(context.Background(), db, websiteID, "https://example.com", "a b")
+	assert.Error(t, err)
+}
+
+func TestResolve(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	websiteID := uuid.New()
+	created := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`FROM short_link l\s+JOIN website w`).WithArgs("spring").
+		WillReturnRows(sqlmock.NewRows([]string{"slug", "website_id", "domain", "target_url", "created_at"}).
+			AddRow("spring", websiteID, "example.com", "https://example.com/launch", created))
+	link, err := Resolve(context.Background(), db, "spring")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/launch", link.Target)
+	assert.Equal(t, websiteID, link.WebsiteID)
+	assert.Equal(t, "example.com", link.Domain)
+
+	mock.ExpectQuery(`FROM short_link l`).WithArgs("autumn").WillReturnError(sql.ErrNoRows)
+	_, err = Resolve(context.Background(), db, "autumn")
+	assert.ErrorIs(t, err, ErrNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestList(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	websiteID := uuid.New()
+	created := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	columns := []string{"slug", "website_id", "domain", "target_url", "created_at", "clicks", "visitors"}
+
+	mock.ExpectQuery(`LEFT JOIN website_event e`).WithArgs(websiteID, 30, EventName).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("spring", websiteID, "example.com", "https://example.com/launch", created, 12, 9))
+	links, err := List(context.Background(), db, websiteID, 30)
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.Equal(t, int64(12), links[0].Clicks)
+	assert.Equal(t, int64(9), links[0].Visitors)
+
+	// Every website's links
+	mock.ExpectQuery(`LEFT JOIN website_event e`).WithArgs(nil, 7, EventName).
+		WillReturnRows(sqlmock.NewRows(columns))
+	links, err = List(context.Background(), db, uuid.Nil, 7)
+	require.NoError(t, err)
+	assert.Empty(t, links)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDelete(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectExec(`DELETE FROM short_link`).WithArgs("spring").WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, Delete(context.Background(), db, "spring"))
+
+	mock.ExpectExec(`DELETE FROM short_link`).WithArgs("autumn").WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.ErrorIs(t, Delete(context.Background(), db, "autumn"), ErrNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQR(t *testing.T) {
+	assert.Equal(t, "https://stats.example.com/l/spring", URL("https://stats.example.com/", "spring"))
+
+	data, err := QR(URL("https://stats.example.com", "spring"), DefaultQRSize)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, DefaultQRSize, img.Bounds().Dx())
+
+	_, err = QR("https://stats.example.com/l/spring", MaxQRSize+1)
+	assert.Error(t, err)
+}
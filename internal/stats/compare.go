@@ -0,0 +1,94 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// Comparison sets a period's key metrics next to those of the period of the
+// same length just before it
+type Comparison struct {
+	Current  Summary `json:"current"`
+	Previous Summary `json:"previous"`
+	// Changes from the previous period; the bounce rate's is in percentage
+	// points
+	Visitors      Change `json:"visitors"`
+	Pageviews     Change `json:"pageviews"`
+	BounceRate    Change `json:"bounce_rate"`
+	AvgEngagement Change `json:"avg_engagement_seconds"`
+}
+
+// Change is how much a metric moved, and by how many percent of its
+// previous value (nil when it was zero)
+type Change struct {
+	Delta   float64  `json:"delta"`
+	Percent *float64 `json:"percent"`
+}
+
+func change(before, after float64) Change {
+	c := Change{Delta: math.Round((after-before)*10) / 10}
+	if before != 0 {
+		percent := math.Round((after-before)/before*1000) / 10
+		c.Percent = &percent
+	}
+	return c
+}
+
+// GetComparison compares the last days days with the days days before, over
+// the events f matches
+func GetComparison(ctx context.Context, db *sql.DB, websiteID uuid.UUID, days int, f store.Filters) (*Comparison, error) {
+	current, err := summarize(ctx, db, "current period", Since("e.created_at", "$2"), websiteID, days, f)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := summarize(ctx, db, "previous period",
+		Since("e.created_at", "($2::int * 2)")+" AND NOT "+Since("e.created_at", "$2"), websiteID, days, f)
+	if err != nil {
+		return nil, err
+	}
+	return NewComparison(*current, *previous), nil
+}
+
+// GetDaysSummary computes the key metrics of the last days days, over the
+// events f matches
+func GetDaysSummary(ctx context.Context, db *sql.DB, websiteID uuid.UUID, days int, f store.Filters) (*Summary, error) {
+	return summarize(ctx, db, "period", Since("e.created_at", "$2"), websiteID, days, f)
+}
+
+// NewComparison sets the metrics of two periods side by side
+func NewComparison(current, previous Summary) *Comparison {
+	return &Comparison{
+		Current:       current,
+		Previous:      previous,
+		Visitors:      change(float64(previous.Visitors), float64(current.Visitors)),
+		Pageviews:     change(float64(previous.Pageviews), float64(current.Pageviews)),
+		BounceRate:    change(previous.BounceRate, current.BounceRate),
+		AvgEngagement: change(previous.AvgEngagement, current.AvgEngagement),
+	}
+}
+
+// summarize computes the key metrics of the pageviews of website $1 matching
+// where, in which $2 is days
+func summarize(ctx context.Context, db *sql.DB, label, where string, websiteID uuid.UUID, days int, f store.Filters) (*Summary, error) {
+	summary := &Summary{}
+	args := Args{websiteID, days}
+	err := db.QueryRowContext(ctx, `
+		WITH events AS (
+			SELECT e.session_id, e.engagement_time, `+SessionPageviews+` AS session_pageviews
+			FROM website_event e
+			WHERE e.website_id = $1 AND `+where+` AND e.event_type = 1`+filtersIn(f, &args)+`
+		)
+		SELECT COUNT(DISTINCT session_id), COUNT(*), `+BounceRate+`, `+AvgEngagement+`
+		FROM events`, args...).
+		Scan(&summary.Visitors, &summary.Pageviews, &summary.BounceRate, &summary.AvgEngagement)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", label, err)
+	}
+	return summary, nil
+}
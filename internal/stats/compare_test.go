@@ -0,0 +1,37 @@
+package stats
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/store"
+	"github.com/seuros/kaunta/internal/test"
+)
+
+func TestGetComparison(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+	columns := []string{"visitors", "pageviews", "bounce", "engagement"}
+
+	mock.ExpectQuery(`e.created_at >= website_day_start\(\$1, \$2\) AND e.event_type = 1 AND e.url_path = \$3`).
+		WithArgs(websiteID, 7, "/pricing").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(120, 300, 40.0, 12.5))
+	mock.ExpectQuery(`\(\$2::int \* 2\)\) AND NOT e.created_at >= website_day_start\(\$1, \$2\)`).
+		WithArgs(websiteID, 7, "/pricing").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(100, 0, 45.0, 10.0))
+
+	c, err := GetComparison(context.Background(), db, websiteID, 7, store.Filters{Page: "/pricing"})
+	require.NoError(t, err)
+	assert.Equal(t, Summary{Visitors: 120, Pageviews: 300, BounceRate: 40, AvgEngagement: 12.5}, c.Current)
+	assert.Equal(t, Change{Delta: 20, Percent: percent(20)}, c.Visitors)
+	// Nothing to compare with
+	assert.Equal(t, Change{Delta: 300}, c.Pageviews)
+	assert.Equal(t, Change{Delta: -5, Percent: percent(-11.1)}, c.BounceRate)
+	assert.Equal(t, Change{Delta: 2.5, Percent: percent(25)}, c.AvgEngagement)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,158 @@
+package stats
+
+import (
+	"strings"
+
+	"github.com/seuros/kaunta/internal/privacy"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// Definition says how a metric is computed, so the people reading the
+// numbers can check the methodology against the live settings
+type Definition struct {
+	Metric     string `json:"metric"`
+	Title      string `json:"title"`
+	Definition string `json:"definition"`
+	// Notes are how the current settings affect the metric
+	Notes []string `json:"notes,omitempty"`
+}
+
+// Settings are the configuration the metrics depend on
+type Settings struct {
+	IPMode           privacy.Mode `json:"ip_mode"`
+	AdaptiveSampling bool         `json:"adaptive_sampling"`
+	// Website are the settings of the website the definitions are for; nil
+	// for the instance
+	Website *store.WebsiteSettings `json:"-"`
+}
+
+// definitions are the metrics, in the order they are listed
+var definitions = []Definition{
+	{
+		Metric: "period",
+		Title:  "Period",
+		Definition: "A period of N days covers the events since midnight N days ago in the website's time zone " +
+			"(UTC unless set), today so far included. " +
+			"A date range (--from/--to, start/end) covers its days in full, from midnight to midnight in its time zone.",
+		Notes: []string{"The dashboard's time series by minute or hour is a rolling window of N times 24 hours ending now; " +
+			"by day, week or month it starts at midnight N days ago."},
+	},
+	{
+		Metric:     "visitors",
+		Title:      "Visitors",
+		Definition: "Distinct sessions with at least one pageview in the period.",
+	},
+	{
+		Metric:     "pageviews",
+		Title:      "Pageviews",
+		Definition: "Pageview events in the period, each page load counted once.",
+	},
+	{
+		Metric: "viewed_pageviews",
+		Title:  "Viewed pageviews",
+		Definition: "Pageviews except those still pending: a page loaded in a background or prerendered tab is " +
+			"pending until the tracker sees it shown, and one never shown stays in pageviews only.",
+	},
+	{
+		Metric: "bounce_rate",
+		Title:  "Bounce rate",
+		Definition: "The percentage of visitors whose session has a single pageview in the period. Custom events " +
+			"and engagement don't turn a bounce into a visit. A page's bounce rate is that of the visitors who viewed it.",
+	},
+	{
+		Metric: "avg_engagement",
+		Title:  "Average engagement",
+		Definition: "The time a page was visible and focused, as measured by the tracker, averaged over pageviews " +
+			"in seconds. Pageviews the tracker never timed (no JavaScript, left at once) count as zero.",
+	},
+	{
+		Metric:     "current_visitors",
+		Title:      "Current visitors",
+		Definition: "Distinct sessions with a pageview in the last 5 minutes.",
+	},
+}
+
+// Metrics are the names of the defined metrics
+func Metrics() []string {
+	names := make([]string, len(definitions))
+	for i, d := range definitions {
+		names[i] = d.Metric
+	}
+	return names
+}
+
+// Definitions returns the definitions of every metric under s
+func Definitions(s Settings) []Definition {
+	list := make([]Definition, len(definitions))
+	for i, d := range definitions {
+		list[i] = explain(d, s)
+	}
+	return list
+}
+
+// Explain returns the definition of metric under s; names may use dashes
+// (bounce-rate)
+func Explain(metric string, s Settings) (Definition, bool) {
+	metric = strings.ReplaceAll(strings.ToLower(metric), "-", "_")
+	for _, d := range definitions {
+		if d.Metric == metric {
+			return explain(d, s), true
+		}
+	}
+	return Definition{}, false
+}
+
+// explain adds the notes of the settings affecting d
+func explain(d Definition, s Settings) Definition {
+	notes := append([]string(nil), d.Notes...)
+	switch d.Metric {
+	case "visitors", "current_visitors", "bounce_rate":
+		notes = append(notes, sessionNote(s.IPMode))
+		if s.Website != nil {
+			notes = append(notes, dntNote(s.Website.RespectDNT))
+		}
+	}
+	switch d.Metric {
+	case "visitors", "pageviews", "viewed_pageviews", "current_visitors":
+		if s.Website != nil {
+			if s.Website.BotFilter {
+				notes = append(notes, "Bot filtering is on: detected bots are dropped and never counted.")
+			} else {
+				notes = append(notes, "Bot filtering is off: detected bots are stored and counted unless filtered out with bot=false.")
+			}
+		}
+		if s.AdaptiveSampling {
+			notes = append(notes, "Adaptive sampling is on: under load only one visitor in N is stored, so counts "+
+				"from those times are below the real traffic (each event records its N).")
+		}
+	}
+	d.Notes = notes
+	return d
+}
+
+// sessionNote is what makes a session under the ip_mode m
+func sessionNote(m privacy.Mode) string {
+	switch m {
+	case privacy.Hash:
+		return "ip_mode is hash: a session is a hash of IP address and user agent with a salt that rotates " +
+			"every UTC day, so a visitor returning on another day counts again."
+	case privacy.Truncate:
+		return "ip_mode is truncate: a session is the /24 (IPv4) or /48 (IPv6) network and user agent within a " +
+			"calendar month (UTC), so visitors sharing a network and browser count as one."
+	default:
+		return "ip_mode is full: a session is the IP address and user agent within a calendar month (UTC), so a " +
+			"returning visitor counts once a month."
+	}
+}
+
+// dntNote is what happens to Do Not Track visitors under respect_dnt
+func dntNote(respectDNT string) string {
+	switch respectDNT {
+	case "drop":
+		return "Do Not Track and Global Privacy Control visitors are not recorded."
+	case "anonymize":
+		return "Do Not Track and Global Privacy Control visitors are identified as with ip_mode hash, so they count again every day."
+	default:
+		return "Do Not Track and Global Privacy Control are ignored."
+	}
+}
@@ -0,0 +1,44 @@
+package stats
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/privacy"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+func TestExplainFollowsSettings(t *testing.T) {
+	d, ok := Explain("bounce-rate", Settings{IPMode: privacy.Hash})
+	require.True(t, ok)
+	assert.Equal(t, "bounce_rate", d.Metric)
+	require.Len(t, d.Notes, 1)
+	assert.Contains(t, d.Notes[0], "ip_mode is hash")
+
+	d, ok = Explain("visitors", Settings{
+		IPMode:           privacy.Full,
+		AdaptiveSampling: true,
+		Website:          &store.WebsiteSettings{BotFilter: false, RespectDNT: "drop"},
+	})
+	require.True(t, ok)
+	assert.Equal(t, []string{
+		sessionNote(privacy.Full),
+		"Do Not Track and Global Privacy Control visitors are not recorded.",
+		"Bot filtering is off: detected bots are stored and counted unless filtered out with bot=false.",
+		"Adaptive sampling is on: under load only one visitor in N is stored, so counts from those times are below the real traffic (each event records its N).",
+	}, d.Notes)
+
+	_, ok = Explain("revenue", Settings{})
+	assert.False(t, ok)
+}
+
+func TestDefinitionsKeepTheirOwnNotes(t *testing.T) {
+	list := Definitions(Settings{AdaptiveSampling: true})
+	require.Len(t, list, len(Metrics()))
+	assert.Equal(t, "period", list[0].Metric)
+	assert.Len(t, list[0].Notes, 1)
+	// Adding notes must not change the shared definitions
+	assert.Len(t, definitions[0].Notes, 1)
+}
@@ -0,0 +1,124 @@
+package stats
+
+import (
+	"strconv"
+
+	"github.com/seuros/kaunta/internal/contentgroups"
+)
+
+// Source is the table a dimension's value is read from
+type Source int
+
+const (
+	// Event values come from website_event e
+	Event Source = iota
+	// Session values come from session s, joined to the event
+	Session
+)
+
+// Dimension is something pageviews can be broken down by. Its SQL is fixed
+// here; anything taken from users (custom dimension names, content group
+// patterns) is bound as a query parameter.
+type Dimension struct {
+	Name   string
+	Source Source
+	// Unknown labels events without a value; empty when there are none
+	Unknown string
+	// value writes the SQL expression of the value, binding its parameters
+	value func(args *Args) string
+}
+
+// column is the value of a dimension read straight from a column
+func column(expr string) func(*Args) string {
+	return func(*Args) string { return expr }
+}
+
+// Built-in dimensions, matching get_breakdown()
+var (
+	ByCountry  = Dimension{Name: "country", Source: Session, Unknown: "Unknown", value: column("s.country")}
+	ByBrowser  = Dimension{Name: "browser", Source: Session, Unknown: "Unknown", value: column("s.browser")}
+	ByDevice   = Dimension{Name: "device", Source: Session, Unknown: "Unknown", value: column("s.device")}
+	ByReferrer = Dimension{Name: "referrer", Source: Event, Unknown: "Direct / None", value: column("e.referrer_domain")}
+	ByOS       = Dimension{Name: "os", Source: Session, Unknown: "Unknown", value: column("s.os")}
+	// ByCity and ByRegion are qualified with what contains them: city names
+	// repeat across regions and countries
+	ByCity = Dimension{Name: "city", Source: Session, Unknown: "Unknown",
+		value: column("s.city || COALESCE(', ' || s.region, '') || COALESCE(', ' || s.country, '')")}
+	ByRegion = Dimension{Name: "region", Source: Session, Unknown: "Unknown",
+		value: column("s.region || COALESCE(', ' || s.country, '')")}
+	ByAuthor = Dimension{Name: "author", Source: Event, Unknown: "Unknown", value: column("e.author")}
+	ByASN    = Dimension{Name: "asn", Source: Session, Unknown: "Unknown",
+		value: column("'AS' || s.asn || COALESCE(' ' || s.isp, '')")}
+	// ByScreen groups screen widths into ranges and ByViewport into device
+	// classes (Mobile, Tablet, Desktop, Wide)
+	ByScreen   = Dimension{Name: "screen", Source: Session, Unknown: "Unknown", value: column("screen_bucket(s.screen)")}
+	ByViewport = Dimension{Name: "viewport", Source: Session, Unknown: "Unknown", value: column("viewport_class(s.screen)")}
+	// ByChannel groups traffic into channels (see package channels); events
+	// recorded before channels have none
+	ByChannel = Dimension{Name: "channel", Source: Event, Unknown: "Unknown", value: column("e.channel")}
+)
+
+// Builtin lists the built-in dimensions in the order help texts show them
+var Builtin = []Dimension{ByCountry, ByBrowser, ByDevice, ByReferrer, ByOS, ByCity, ByRegion, ByAuthor, ByASN, ByScreen, ByViewport, ByChannel}
+
+// Lookup returns the built-in dimension called name
+func Lookup(name string) (Dimension, bool) {
+	for _, d := range Builtin {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return Dimension{}, false
+}
+
+// Names lists the built-in dimensions and content-group, for help texts
+func Names() []string {
+	names := make([]string, 0, len(Builtin)+1)
+	for _, d := range Builtin {
+		names = append(names, d.Name)
+	}
+	return append(names, "content-group")
+}
+
+// Custom is a custom dimension, read from the event's dimensions
+func Custom(name string) Dimension {
+	return Dimension{Name: name, Source: Event, Unknown: "Unknown", value: func(args *Args) string {
+		return "e.dimensions->>" + args.Bind(name)
+	}}
+}
+
+// ContentGroups groups pages by the first content group rule matching their
+// path, or contentgroups.Other
+func ContentGroups(rules []contentgroups.Rule) Dimension {
+	return Dimension{Name: "content-group", Source: Event, value: func(args *Args) string {
+		expr, params := contentgroups.CaseExpr(rules, "e.url_path", len(*args)+1)
+		*args = append(*args, params...)
+		return expr
+	}}
+}
+
+// Label writes the SQL of the dimension's display value, binding its
+// parameters to args
+func (d Dimension) Label(args *Args) string {
+	if d.Unknown == "" {
+		return d.value(args)
+	}
+	return "COALESCE(" + d.value(args) + ", " + args.Bind(d.Unknown) + ")"
+}
+
+// Join is the join the dimension needs on top of website_event e
+func (d Dimension) Join() string {
+	if d.Source == Session {
+		return "JOIN session s ON e.session_id = s.session_id"
+	}
+	return ""
+}
+
+// Args collects the parameters of a query, numbering them $1, $2...
+type Args []interface{}
+
+// Bind adds a parameter and returns its placeholder
+func (a *Args) Bind(v interface{}) string {
+	*a = append(*a, v)
+	return "$" + strconv.Itoa(len(*a))
+}
@@ -0,0 +1,242 @@
+package stats
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/eventprops"
+	"github.com/seuros/kaunta/internal/fieldcrypt"
+)
+
+// EventCount counts custom events by name, property or property value
+type EventCount struct {
+	Name     string `json:"name"`
+	Events   int64  `json:"events"`
+	Visitors int64  `json:"visitors"`
+}
+
+// EventTrend is a custom event's count over a period and the period before
+type EventTrend struct {
+	EventCount
+	PreviousEvents int64 `json:"previous_events"`
+	// ChangePercent is nil for events the period before didn't have
+	ChangePercent *float64 `json:"change_percent"`
+}
+
+// EventProperties are a custom event's events grouped by the value of one of
+// its properties. Events without the property are left out.
+type EventProperties struct {
+	Event    string       `json:"event"`
+	Property string       `json:"property"`
+	Days     int          `json:"days"`
+	Values   []EventCount `json:"values"`
+}
+
+// customEventsIn keeps the custom events of website $1 in a period of $2 days
+var customEventsIn = `e.website_id = $1 AND ` + Since("e.created_at", "$2") + ` AND e.event_type = 2`
+
+// GetEvents counts the custom events of a period by name, most frequent
+// first, with their count over the days before for the trend. match keeps
+// the events sent with its property values.
+func GetEvents(ctx context.Context, db *sql.DB, websiteID uuid.UUID, days, limit int, match eventprops.Filter) ([]EventTrend, error) {
+	if len(match) > 0 && fieldcrypt.Active() != nil {
+		return nil, eventprops.ErrFilterEncrypted
+	}
+	args := Args{websiteID, days, limit}
+	filter := match.Condition("e.props", args.Bind)
+	rows, err := db.QueryContext(ctx, `
+		SELECT e.event_name,
+			COUNT(*) FILTER (WHERE `+Since("e.created_at", "$2")+`),
+			COUNT(DISTINCT e.session_id) FILTER (WHERE `+Since("e.created_at", "$2")+`),
+			COUNT(*) FILTER (WHERE NOT `+Since("e.created_at", "$2")+`)
+		FROM website_event e
+		WHERE e.website_id = $1 AND `+Since("e.created_at", "($2::int * 2)")+`
+		  AND e.event_type = 2 AND e.event_name IS NOT NULL`+filter+`
+		GROUP BY 1
+		ORDER BY 2 DESC, 4 DESC, 1
+		LIMIT $3
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	trends := []EventTrend{}
+	for rows.Next() {
+		var t EventTrend
+		if err := rows.Scan(&t.Name, &t.Events, &t.Visitors, &t.PreviousEvents); err != nil {
+			return nil, fmt.Errorf("failed to read events: %w", err)
+		}
+		t.ChangePercent = ChangePercent(t.PreviousEvents, t.Events)
+		trends = append(trends, t)
+	}
+	return trends, rows.Err()
+}
+
+// GetEventPropertyKeys counts a custom event's properties: how many of its
+// events of a period carry each of them
+func GetEventPropertyKeys(ctx context.Context, db *sql.DB, websiteID uuid.UUID, name string, days, limit int, match eventprops.Filter) ([]EventCount, error) {
+	if fieldcrypt.Active() != nil {
+		return aggregateProps(ctx, db, websiteID, name, days, limit, match, func(props map[string]json.RawMessage, add func(string)) {
+			for key := range props {
+				add(key)
+			}
+		})
+	}
+	// Sealed props (a JSON string) have no keys
+	args := Args{websiteID, days, name, limit}
+	filter := match.Condition("e.props", args.Bind)
+	return queryEventCounts(ctx, db, `
+		SELECT k.key, COUNT(*), COUNT(DISTINCT e.session_id)
+		FROM website_event e
+		CROSS JOIN LATERAL jsonb_object_keys(
+			CASE WHEN jsonb_typeof(e.props) = 'object' THEN e.props ELSE '{}'::jsonb END
+		) AS k(key)
+		WHERE `+customEventsIn+` AND e.event_name = $3`+filter+`
+		GROUP BY 1
+		ORDER BY 2 DESC, 1
+		LIMIT $4
+	`, args...)
+}
+
+// GetEventProperties breaks a custom event's events of a period down by the
+// value of one property. Values that aren't strings are written as JSON
+// (42, true), like PostgreSQL's ->> operator.
+func GetEventProperties(ctx context.Context, db *sql.DB, websiteID uuid.UUID, name, property string, days, limit int, match eventprops.Filter) (*EventProperties, error) {
+	var values []EventCount
+	var err error
+	if fieldcrypt.Active() != nil {
+		values, err = aggregateProps(ctx, db, websiteID, name, days, limit, match, func(props map[string]json.RawMessage, add func(string)) {
+			if value, ok := propValue(props[property]); ok {
+				add(value)
+			}
+		})
+	} else {
+		args := Args{websiteID, days, name, property, limit}
+		filter := match.Condition("e.props", args.Bind)
+		values, err = queryEventCounts(ctx, db, `
+			SELECT e.props ->> $4, COUNT(*), COUNT(DISTINCT e.session_id)
+			FROM website_event e
+			WHERE `+customEventsIn+` AND e.event_name = $3
+			  AND jsonb_typeof(e.props) = 'object' AND e.props ->> $4 IS NOT NULL`+filter+`
+			GROUP BY 1
+			ORDER BY 2 DESC, 1
+			LIMIT $5
+		`, args...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &EventProperties{Event: name, Property: property, Days: days, Values: values}, nil
+}
+
+func queryEventCounts(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]EventCount, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	counts := []EventCount{}
+	for rows.Next() {
+		var c EventCount
+		if err := rows.Scan(&c.Name, &c.Events, &c.Visitors); err != nil {
+			return nil, fmt.Errorf("failed to read events: %w", err)
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// aggregateProps counts a custom event's events in Go, for props sealed by
+// fieldcrypt that PostgreSQL can't read, of the events match keeps. group
+// calls add with the names each event counts for.
+func aggregateProps(ctx context.Context, db *sql.DB, websiteID uuid.UUID, name string, days, limit int, match eventprops.Filter,
+	group func(props map[string]json.RawMessage, add func(string))) ([]EventCount, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT e.session_id, e.props::text
+		FROM website_event e
+		WHERE `+customEventsIn+` AND e.event_name = $3 AND e.props IS NOT NULL
+	`, websiteID, days, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	type tally struct {
+		events   int64
+		sessions map[uuid.UUID]struct{}
+	}
+	tallies := map[string]*tally{}
+	for rows.Next() {
+		var sessionID uuid.UUID
+		var doc string
+		if err := rows.Scan(&sessionID, &doc); err != nil {
+			return nil, fmt.Errorf("failed to read events: %w", err)
+		}
+		plain, err := fieldcrypt.DecryptJSON([]byte(doc))
+		if err != nil {
+			return nil, err
+		}
+		var props map[string]json.RawMessage
+		if json.Unmarshal(plain, &props) != nil || !match.Matches(props) {
+			continue
+		}
+		group(props, func(name string) {
+			t := tallies[name]
+			if t == nil {
+				t = &tally{sessions: map[uuid.UUID]struct{}{}}
+				tallies[name] = t
+			}
+			t.events++
+			t.sessions[sessionID] = struct{}{}
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	counts := make([]EventCount, 0, len(tallies))
+	for name, t := range tallies {
+		counts = append(counts, EventCount{Name: name, Events: t.events, Visitors: int64(len(t.sessions))})
+	}
+	sortEventCounts(counts)
+	if len(counts) > limit {
+		counts = counts[:limit]
+	}
+	return counts, nil
+}
+
+// sortEventCounts orders counts like the queries: most events first, then
+// by name
+func sortEventCounts(counts []EventCount) {
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Events != counts[j].Events {
+			return counts[i].Events > counts[j].Events
+		}
+		return counts[i].Name < counts[j].Name
+	})
+}
+
+// propValue reads a property value as text; null and missing values have
+// none
+func propValue(raw json.RawMessage) (string, bool) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", false
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s, true
+	}
+	var compact bytes.Buffer
+	if json.Compact(&compact, raw) != nil {
+		return string(raw), true
+	}
+	return compact.String(), true
+}
@@ -0,0 +1,144 @@
+package stats
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/eventprops"
+	"github.com/seuros/kaunta/internal/fieldcrypt"
+	"github.com/seuros/kaunta/internal/test"
+)
+
+func TestGetEventProperties(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+
+	mock.ExpectQuery(`SELECT e.props ->> \$4, COUNT\(\*\), COUNT\(DISTINCT e.session_id\).*`+
+		`e.event_type = 2 AND e.event_name = \$3`).
+		WithArgs(websiteID, 30, "signup", "plan", 10).
+		WillReturnRows(sqlmock.NewRows([]string{"value", "events", "visitors"}).
+			AddRow("pro", 12, 10).AddRow("free", 40, 38))
+
+	props, err := GetEventProperties(context.Background(), db, websiteID, "signup", "plan", 30, 10, nil)
+	require.NoError(t, err)
+	assert.Equal(t, &EventProperties{Event: "signup", Property: "plan", Days: 30, Values: []EventCount{
+		{Name: "pro", Events: 12, Visitors: 10},
+		{Name: "free", Events: 40, Visitors: 38},
+	}}, props)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetEventPropertiesFiltered(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+
+	// Keys are written inline so the per-key partial indexes apply
+	mock.ExpectQuery(`e.props ->> \$4 IS NOT NULL AND e.props \? 'plan' AND \(e.props -> 'plan' @> \$6::jsonb\) `+
+		`AND e.props \? 'seats' AND \(e.props -> 'seats' @> \$7::jsonb OR e.props -> 'seats' @> \$8::jsonb\)`).
+		WithArgs(websiteID, 30, "signup", "source", 10, `"pro"`, `"3"`, "3").
+		WillReturnRows(sqlmock.NewRows([]string{"value", "events", "visitors"}).AddRow("ads", 4, 4))
+
+	props, err := GetEventProperties(context.Background(), db, websiteID, "signup", "source", 30, 10,
+		eventprops.Filter{"plan": "pro", "seats": "3"})
+	require.NoError(t, err)
+	assert.Equal(t, []EventCount{{Name: "ads", Events: 4, Visitors: 4}}, props.Values)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetEventPropertiesEncrypted(t *testing.T) {
+	key, err := fieldcrypt.GenerateKey()
+	require.NoError(t, err)
+	keyring, err := fieldcrypt.NewKeyring(key)
+	require.NoError(t, err)
+	fieldcrypt.Configure(keyring)
+	t.Cleanup(func() { fieldcrypt.Configure(nil) })
+
+	seal := func(doc string) string {
+		sealed, err := fieldcrypt.EncryptJSON([]byte(doc))
+		require.NoError(t, err)
+		return string(sealed)
+	}
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+	alice, bob := uuid.New(), uuid.New()
+
+	// Rows from before encryption was enabled are read as they are
+	mock.ExpectQuery(`SELECT e.session_id, e.props::text`).
+		WithArgs(websiteID, 7, "signup").
+		WillReturnRows(sqlmock.NewRows([]string{"session_id", "props"}).
+			AddRow(alice, seal(`{"plan":"pro","seats":5}`)).
+			AddRow(alice, seal(`{"plan":"pro"}`)).
+			AddRow(bob, `{"plan":"free","seats":1}`).
+			AddRow(bob, seal(`{"plan":null}`)))
+
+	props, err := GetEventProperties(context.Background(), db, websiteID, "signup", "plan", 7, 10, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []EventCount{
+		{Name: "pro", Events: 2, Visitors: 1},
+		{Name: "free", Events: 1, Visitors: 1},
+	}, props.Values)
+
+	mock.ExpectQuery(`SELECT e.session_id, e.props::text`).
+		WithArgs(websiteID, 7, "signup").
+		WillReturnRows(sqlmock.NewRows([]string{"session_id", "props"}).
+			AddRow(alice, seal(`{"plan":"pro","seats":5}`)).
+			AddRow(bob, `{"plan":"free","seats":1}`))
+
+	keys, err := GetEventPropertyKeys(context.Background(), db, websiteID, "signup", 7, 1, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []EventCount{{Name: "plan", Events: 2, Visitors: 2}}, keys)
+
+	// Property filters are applied once the properties are decrypted
+	mock.ExpectQuery(`SELECT e.session_id, e.props::text`).
+		WithArgs(websiteID, 7, "signup").
+		WillReturnRows(sqlmock.NewRows([]string{"session_id", "props"}).
+			AddRow(alice, seal(`{"plan":"pro","seats":5}`)).
+			AddRow(bob, `{"plan":"free","seats":5}`))
+
+	props, err = GetEventProperties(context.Background(), db, websiteID, "signup", "seats", 7, 10,
+		eventprops.Filter{"plan": "pro"})
+	require.NoError(t, err)
+	assert.Equal(t, []EventCount{{Name: "5", Events: 1, Visitors: 1}}, props.Values)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = GetEvents(context.Background(), db, websiteID, 7, 10, eventprops.Filter{"plan": "pro"})
+	assert.ErrorIs(t, err, eventprops.ErrFilterEncrypted)
+}
+
+func TestPropValue(t *testing.T) {
+	for raw, want := range map[string]string{`"pro"`: "pro", `42`: "42", `true`: "true", `{"a": 1}`: `{"a":1}`} {
+		value, ok := propValue([]byte(raw))
+		assert.True(t, ok, raw)
+		assert.Equal(t, want, value, raw)
+	}
+	_, ok := propValue([]byte(`null`))
+	assert.False(t, ok)
+	_, ok = propValue(nil)
+	assert.False(t, ok)
+}
+
+func TestGetEventsWithTrend(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+
+	// The previous period is the same number of days before
+	mock.ExpectQuery(`FILTER \(WHERE NOT e.created_at >= website_day_start\(\$1, \$2\)\).*`+
+		`e.created_at >= website_day_start\(\$1, \(\$2::int \* 2\)\)`).
+		WithArgs(websiteID, 7, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "events", "visitors", "previous"}).
+			AddRow("signup", 30, 25, 20).AddRow("download", 12, 9, 0).AddRow("trial", 0, 0, 4))
+
+	events, err := GetEvents(context.Background(), db, websiteID, 7, 10, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []EventTrend{
+		{EventCount: EventCount{Name: "signup", Events: 30, Visitors: 25}, PreviousEvents: 20, ChangePercent: percent(50)},
+		{EventCount: EventCount{Name: "download", Events: 12, Visitors: 9}},
+		{EventCount: EventCount{Name: "trial"}, PreviousEvents: 4, ChangePercent: percent(-100)},
+	}, events)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,173 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// moverPool is how many pages and referrers of each period are compared
+const moverPool = 500
+
+// Mover is a value whose pageviews changed between two periods
+type Mover struct {
+	Name   string `json:"name"`
+	Before int64  `json:"before"`
+	After  int64  `json:"after"`
+	Change int64  `json:"change"`
+	// ChangePercent is nil for values new in the second period
+	ChangePercent *float64 `json:"change_percent"`
+}
+
+// relative is the mover's change in percent, infinite for new values
+func (m Mover) relative() float64 {
+	if m.ChangePercent == nil {
+		return math.Inf(1)
+	}
+	return *m.ChangePercent
+}
+
+// MoverList are the values gaining and losing the most pageviews
+type MoverList struct {
+	Gainers []Mover `json:"gainers"`
+	Losers  []Mover `json:"losers"`
+}
+
+// Ranking orders movers
+type Ranking int
+
+const (
+	// ByChange ranks movers by pageviews gained or lost
+	ByChange Ranking = iota
+	// ByPercent ranks movers by their change relative to the first period
+	ByPercent
+)
+
+// ParseRanking reads a ranking name: change or percent
+func ParseRanking(name string) (Ranking, error) {
+	switch name {
+	case "", "change":
+		return ByChange, nil
+	case "percent":
+		return ByPercent, nil
+	}
+	return ByChange, fmt.Errorf("invalid ranking: %s (use change or percent)", name)
+}
+
+// Rank returns the n values gaining and the n losing the most pageviews from
+// before to after, leaving out those with fewer than minPageviews in both
+// periods: small numbers swing too much to mean anything
+func Rank(before, after map[string]int64, minPageviews int64, rank Ranking, n int) MoverList {
+	var all []Mover
+	add := func(name string, b, a int64) {
+		if a == b || (a < minPageviews && b < minPageviews) {
+			return
+		}
+		all = append(all, Mover{Name: name, Before: b, After: a, Change: a - b, ChangePercent: ChangePercent(b, a)})
+	}
+	for name, b := range before {
+		add(name, b, after[name])
+	}
+	for name, a := range after {
+		if _, seen := before[name]; !seen {
+			add(name, 0, a)
+		}
+	}
+
+	// Biggest changes first, either way
+	sort.Slice(all, func(i, j int) bool {
+		if rank == ByPercent {
+			if ri, rj := math.Abs(all[i].relative()), math.Abs(all[j].relative()); ri != rj {
+				return ri > rj
+			}
+		}
+		if ci, cj := abs(all[i].Change), abs(all[j].Change); ci != cj {
+			return ci > cj
+		}
+		return all[i].Name < all[j].Name
+	})
+
+	list := MoverList{Gainers: []Mover{}, Losers: []Mover{}}
+	for _, m := range all {
+		switch {
+		case m.Change > 0 && len(list.Gainers) < n:
+			list.Gainers = append(list.Gainers, m)
+		case m.Change < 0 && len(list.Losers) < n:
+			list.Losers = append(list.Losers, m)
+		}
+	}
+	return list
+}
+
+// ChangePercent is the change from before to after in percent, rounded to
+// a tenth; nil when there was nothing before
+func ChangePercent(before, after int64) *float64 {
+	if before == 0 {
+		return nil
+	}
+	percent := math.Round(float64(after-before)/float64(before)*1000) / 10
+	return &percent
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// LastDays is the period of the last days days, up to now
+func LastDays(days int, now time.Time) Period {
+	return Period{
+		Label: fmt.Sprintf("last %d days", days),
+		From:  now.Add(-time.Duration(days) * 24 * time.Hour),
+		To:    now,
+	}
+}
+
+// Previous is the period of the same length just before p
+func (p Period) Previous() Period {
+	return Period{Label: "previous period", From: p.From.Add(-p.To.Sub(p.From)), To: p.From}
+}
+
+// Trends are the pages and referrers gaining and losing the most pageviews
+// over a period, compared with the period before
+type Trends struct {
+	Days         int       `json:"days"`
+	From         time.Time `json:"from"`
+	To           time.Time `json:"to"`
+	PreviousFrom time.Time `json:"previous_from"`
+	Pages        MoverList `json:"pages"`
+	Referrers    MoverList `json:"referrers"`
+}
+
+// GetTrends compares the pageviews of the last days days with the days before
+func GetTrends(ctx context.Context, db *sql.DB, websiteID uuid.UUID, days int, minPageviews int64, rank Ranking, limit int) (*Trends, error) {
+	current := LastDays(days, time.Now().UTC())
+	previous := current.Previous()
+	trends := &Trends{Days: days, From: current.From, To: current.To, PreviousFrom: previous.From}
+
+	for _, d := range []struct {
+		dimension Dimension
+		list      *MoverList
+	}{
+		{ByPage, &trends.Pages},
+		{ByReferrer, &trends.Referrers},
+	} {
+		before, err := GetCounts(ctx, db, websiteID, d.dimension, previous, moverPool)
+		if err != nil {
+			return nil, err
+		}
+		after, err := GetCounts(ctx, db, websiteID, d.dimension, current, moverPool)
+		if err != nil {
+			return nil, err
+		}
+		*d.list = Rank(before, after, minPageviews, rank, limit)
+	}
+	return trends, nil
+}
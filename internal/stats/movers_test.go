@@ -0,0 +1,73 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func percent(v float64) *float64 { return &v }
+
+func TestRankByChange(t *testing.T) {
+	before := map[string]int64{"/": 100, "/pricing": 40, "/old": 30, "/same": 5}
+	after := map[string]int64{"/": 120, "/pricing": 10, "/new": 50, "/same": 5}
+
+	list := Rank(before, after, 0, ByChange, 5)
+	assert.Equal(t, []Mover{
+		{Name: "/new", After: 50, Change: 50},
+		{Name: "/", Before: 100, After: 120, Change: 20, ChangePercent: percent(20)},
+	}, list.Gainers)
+	assert.Equal(t, []Mover{
+		{Name: "/old", Before: 30, Change: -30, ChangePercent: percent(-100)},
+		{Name: "/pricing", Before: 40, After: 10, Change: -30, ChangePercent: percent(-75)},
+	}, list.Losers)
+
+	list = Rank(before, after, 0, ByChange, 1)
+	assert.Len(t, list.Gainers, 1)
+	assert.Len(t, list.Losers, 1)
+
+	list = Rank(map[string]int64{}, map[string]int64{}, 0, ByChange, 5)
+	assert.NotNil(t, list.Gainers)
+	assert.NotNil(t, list.Losers)
+}
+
+func TestRankByPercentWithMinimum(t *testing.T) {
+	before := map[string]int64{"/": 1000, "/blog": 10, "/tiny": 1}
+	after := map[string]int64{"/": 1300, "/blog": 40, "/tiny": 5, "/launch": 20}
+
+	list := Rank(before, after, 10, ByPercent, 5)
+	// New pages lead, then the biggest relative growth; /tiny is too small
+	// to count
+	names := []string{}
+	for _, m := range list.Gainers {
+		names = append(names, m.Name)
+	}
+	assert.Equal(t, []string{"/launch", "/blog", "/"}, names)
+
+	list = Rank(before, after, 10, ByChange, 5)
+	assert.Equal(t, "/", list.Gainers[0].Name)
+}
+
+func TestParseRanking(t *testing.T) {
+	for name, want := range map[string]Ranking{"": ByChange, "change": ByChange, "percent": ByPercent} {
+		got, err := ParseRanking(name)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := ParseRanking("ratio")
+	assert.Error(t, err)
+}
+
+func TestLastDaysAndPrevious(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	current := LastDays(7, now)
+	assert.Equal(t, time.Date(2025, 6, 8, 12, 0, 0, 0, time.UTC), current.From)
+	assert.Equal(t, now, current.To)
+
+	previous := current.Previous()
+	assert.Equal(t, time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC), previous.From)
+	assert.Equal(t, current.From, previous.To)
+	assert.Equal(t, "previous period", previous.Label)
+}
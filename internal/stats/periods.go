@@ -0,0 +1,202 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// Period is a range of time, from From up to (excluding) To
+type Period struct {
+	Label string
+	From  time.Time
+	To    time.Time
+}
+
+// ParsePeriod reads a month (2025-06), a day (2025-06-15) or a range of days
+// with both ends included (2025-06-01..2025-06-15)
+func ParsePeriod(s string) (Period, error) {
+	if from, to, ok := strings.Cut(s, ".."); ok {
+		start, err := time.Parse(time.DateOnly, from)
+		if err != nil {
+			return Period{}, fmt.Errorf("invalid period %q: %w", s, err)
+		}
+		end, err := time.Parse(time.DateOnly, to)
+		if err != nil {
+			return Period{}, fmt.Errorf("invalid period %q: %w", s, err)
+		}
+		if end.Before(start) {
+			return Period{}, fmt.Errorf("invalid period %q: ends before it starts", s)
+		}
+		return Period{Label: s, From: start, To: end.AddDate(0, 0, 1)}, nil
+	}
+	if month, err := time.Parse("2006-01", s); err == nil {
+		return Period{Label: s, From: month, To: month.AddDate(0, 1, 0)}, nil
+	}
+	if day, err := time.Parse(time.DateOnly, s); err == nil {
+		return Period{Label: s, From: day, To: day.AddDate(0, 0, 1)}, nil
+	}
+	return Period{}, fmt.Errorf("invalid period %q (use 2025-06, 2025-06-15 or 2025-06-01..2025-06-15)", s)
+}
+
+// MaxRangeDays is the longest range ParseRange accepts
+const MaxRangeDays = 366
+
+// ParseRange reads a range of ISO dates with both ends included, as given
+// by --from/--to or start/end. The dates are days in the time zone tz (an
+// IANA name, UTC when empty); an empty to ends the range today.
+func ParseRange(from, to, tz string, now time.Time) (Period, error) {
+	loc := time.UTC
+	if tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return Period{}, fmt.Errorf("invalid time zone %q", tz)
+		}
+	}
+	if from == "" {
+		return Period{}, fmt.Errorf("a range needs a start date")
+	}
+	start, err := time.ParseInLocation(time.DateOnly, from, loc)
+	if err != nil {
+		return Period{}, fmt.Errorf("invalid start date %q (use YYYY-MM-DD)", from)
+	}
+	today := now.In(loc)
+	end := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, loc)
+	if to != "" {
+		if end, err = time.ParseInLocation(time.DateOnly, to, loc); err != nil {
+			return Period{}, fmt.Errorf("invalid end date %q (use YYYY-MM-DD)", to)
+		}
+	}
+
+	switch {
+	case end.Before(start):
+		return Period{}, fmt.Errorf("the range ends (%s) before it starts (%s)", end.Format(time.DateOnly), from)
+	case start.After(now):
+		return Period{}, fmt.Errorf("the range starts in the future (%s)", from)
+	case end.Sub(start) >= MaxRangeDays*24*time.Hour:
+		return Period{}, fmt.Errorf("the range is longer than %d days", MaxRangeDays)
+	}
+	return Period{
+		Label: start.Format(time.DateOnly) + ".." + end.Format(time.DateOnly),
+		From:  start,
+		To:    end.AddDate(0, 0, 1),
+	}, nil
+}
+
+// Days is the shortest period of days (see Since) reaching back to the start
+// of p, so queries by days can be narrowed to p
+func (p Period) Days(now time.Time) int {
+	return max(int(now.Sub(p.From).Hours()/24)+2, 1)
+}
+
+// Narrow limits f to the events of p
+func (p Period) Narrow(f store.Filters) store.Filters {
+	f.From, f.To = p.From, p.To
+	return f
+}
+
+// pageviewsDuring selects the pageviews of website $1 from $2 up to $3
+const pageviewsDuring = `e.website_id = $1 AND e.created_at >= $2 AND e.created_at < $3 AND e.event_type = 1`
+
+// ByPage counts pages by path; `kaunta stats pages` is their breakdown, so
+// it isn't one of the Builtin dimensions
+var ByPage = Dimension{Name: "page", Source: Event, value: column("e.url_path")}
+
+// Summary is the key metrics of a period
+type Summary struct {
+	Visitors      int64   `json:"visitors"`
+	Pageviews     int64   `json:"pageviews"`
+	BounceRate    float64 `json:"bounce_rate"`
+	AvgEngagement float64 `json:"avg_engagement_seconds"`
+}
+
+// GetSummary computes the key metrics of a period
+func GetSummary(ctx context.Context, db *sql.DB, websiteID uuid.UUID, p Period) (*Summary, error) {
+	summary := &Summary{}
+	err := db.QueryRowContext(ctx, `
+		WITH events AS (
+			SELECT e.session_id, e.engagement_time, `+SessionPageviews+` AS session_pageviews
+			FROM website_event e
+			WHERE `+pageviewsDuring+`
+		)
+		SELECT COUNT(DISTINCT session_id), COUNT(*), `+BounceRate+`, `+AvgEngagement+`
+		FROM events`,
+		websiteID, p.From, p.To).Scan(&summary.Visitors, &summary.Pageviews, &summary.BounceRate, &summary.AvgEngagement)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", p.Label, err)
+	}
+	return summary, nil
+}
+
+// VisitTotals are the totals of a period counted by visit, as Umami reports
+// them
+type VisitTotals struct {
+	Pageviews int64
+	Visitors  int64
+	Visits    int64
+	// Bounces are the visits of a single pageview
+	Bounces int64
+	// TotalTime is the engagement of all visits in seconds
+	TotalTime int64
+}
+
+// GetVisitTotals computes the visit totals of a period over the events f
+// matches
+func GetVisitTotals(ctx context.Context, db *sql.DB, websiteID uuid.UUID, p Period, f store.Filters) (*VisitTotals, error) {
+	totals := &VisitTotals{}
+	args := Args{websiteID, p.From, p.To}
+	err := db.QueryRowContext(ctx, `
+		WITH visits AS (
+			SELECT e.session_id, COUNT(*) AS pageviews, COALESCE(SUM(e.engagement_time), 0) AS engagement
+			FROM website_event e
+			WHERE `+pageviewsDuring+filtersIn(f, &args)+`
+			GROUP BY e.session_id, e.visit_id
+		)
+		SELECT
+			COALESCE(SUM(pageviews), 0),
+			COUNT(DISTINCT session_id),
+			COUNT(*),
+			COUNT(*) FILTER (WHERE pageviews = 1),
+			COALESCE(SUM(engagement), 0) / 1000
+		FROM visits`, args...).
+		Scan(&totals.Pageviews, &totals.Visitors, &totals.Visits, &totals.Bounces, &totals.TotalTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query visit totals: %w", err)
+	}
+	return totals, nil
+}
+
+// GetCounts returns the pageviews of the limit most viewed values of d in a
+// period
+func GetCounts(ctx context.Context, db *sql.DB, websiteID uuid.UUID, d Dimension, p Period, limit int) (map[string]int64, error) {
+	args := Args{websiteID, p.From, p.To, limit}
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+d.Label(&args)+` AS name, COUNT(*) AS pageviews
+		FROM website_event e
+		`+d.Join()+`
+		WHERE `+pageviewsDuring+`
+		GROUP BY name
+		ORDER BY pageviews DESC
+		LIMIT $4`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count %s: %w", d.Name, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var name string
+		var n int64
+		if err := rows.Scan(&name, &n); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", d.Name, err)
+		}
+		counts[name] = n
+	}
+	return counts, rows.Err()
+}
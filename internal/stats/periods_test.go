@@ -0,0 +1,124 @@
+package stats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/store"
+	"github.com/seuros/kaunta/internal/test"
+)
+
+func TestParsePeriod(t *testing.T) {
+	day := func(s string) time.Time {
+		d, err := time.Parse(time.DateOnly, s)
+		require.NoError(t, err)
+		return d
+	}
+
+	for _, tc := range []struct {
+		in       string
+		from, to string
+	}{
+		{"2025-06", "2025-06-01", "2025-07-01"},
+		{"2025-12", "2025-12-01", "2026-01-01"},
+		{"2025-06-15", "2025-06-15", "2025-06-16"},
+		{"2025-06-01..2025-06-15", "2025-06-01", "2025-06-16"},
+	} {
+		p, err := ParsePeriod(tc.in)
+		require.NoError(t, err, tc.in)
+		assert.Equal(t, Period{Label: tc.in, From: day(tc.from), To: day(tc.to)}, p)
+	}
+
+	for _, in := range []string{"", "June", "2025-13", "2025-06-15..2025-06-01", "2025-06..2025-07"} {
+		_, err := ParsePeriod(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	now := time.Date(2025, 6, 20, 12, 0, 0, 0, time.UTC)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	p, err := ParseRange("2025-06-01", "2025-06-15", "Europe/Berlin", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 6, 1, 0, 0, 0, 0, berlin), p.From)
+	assert.Equal(t, time.Date(2025, 6, 16, 0, 0, 0, 0, berlin), p.To)
+	assert.Equal(t, "2025-06-01..2025-06-15", p.Label)
+	assert.Equal(t, 21, p.Days(now))
+
+	// Without an end, the range runs through today
+	p, err = ParseRange("2025-06-10", "", "", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 6, 21, 0, 0, 0, 0, time.UTC), p.To)
+
+	for _, tc := range []struct{ from, to, tz, err string }{
+		{"", "2025-06-15", "", "a range needs a start date"},
+		{"June 1", "", "", `invalid start date "June 1" (use YYYY-MM-DD)`},
+		{"2025-06-01", "2025-06-31", "", `invalid end date "2025-06-31" (use YYYY-MM-DD)`},
+		{"2025-06-15", "2025-06-01", "", "the range ends (2025-06-01) before it starts (2025-06-15)"},
+		{"2025-07-01", "2025-07-02", "", "the range starts in the future (2025-07-01)"},
+		{"2024-01-01", "2025-06-01", "", "the range is longer than 366 days"},
+		{"2025-06-01", "", "Mars/Olympus", `invalid time zone "Mars/Olympus"`},
+	} {
+		_, err := ParseRange(tc.from, tc.to, tc.tz, now)
+		assert.EqualError(t, err, tc.err, tc.from)
+	}
+}
+
+func TestGetSummaryQueriesThePeriod(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+	p, err := ParsePeriod("2025-06")
+	require.NoError(t, err)
+
+	mock.ExpectQuery(`e.created_at >= \$2 AND e.created_at < \$3`).
+		WithArgs(websiteID, p.From, p.To).
+		WillReturnRows(sqlmock.NewRows([]string{"visitors", "pageviews", "bounce", "engagement"}).
+			AddRow(10, 25, 40.0, 12.5))
+
+	summary, err := GetSummary(context.Background(), db, websiteID, p)
+	require.NoError(t, err)
+	assert.Equal(t, &Summary{Visitors: 10, Pageviews: 25, BounceRate: 40, AvgEngagement: 12.5}, summary)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetVisitTotals(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+	p, err := ParsePeriod("2025-06")
+	require.NoError(t, err)
+
+	mock.ExpectQuery(`GROUP BY e.session_id, e.visit_id`).
+		WithArgs(websiteID, p.From, p.To, "DE").
+		WillReturnRows(sqlmock.NewRows([]string{"pageviews", "visitors", "visits", "bounces", "totaltime"}).
+			AddRow(25, 10, 12, 5, 340))
+
+	totals, err := GetVisitTotals(context.Background(), db, websiteID, p, store.Filters{Country: "DE"})
+	require.NoError(t, err)
+	assert.Equal(t, &VisitTotals{Pageviews: 25, Visitors: 10, Visits: 12, Bounces: 5, TotalTime: 340}, totals)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetCountsBindsUnknownAfterTheLimit(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+	p, err := ParsePeriod("2025-06-15")
+	require.NoError(t, err)
+
+	mock.ExpectQuery(`COALESCE\(e.referrer_domain, \$5\) AS name.*LIMIT \$4`).
+		WithArgs(websiteID, p.From, p.To, 50, "Direct / None").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "pageviews"}).
+			AddRow("google.com", 7).AddRow("Direct / None", 3))
+
+	counts, err := GetCounts(context.Background(), db, websiteID, ByReferrer, p, 50)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"google.com": 7, "Direct / None": 3}, counts)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,419 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/contentgroups"
+	"github.com/seuros/kaunta/internal/rollup"
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// pageviewsIn selects the pageviews of website $1 in a period of $2 days
+var pageviewsIn = `e.website_id = $1 AND ` + Since("e.created_at", "$2") + ` AND e.event_type = 1`
+
+// filtersIn writes the conditions keeping the events f matches, as the
+// dashboard's get_*() functions do, binding their values to args; "" when f
+// has no filter
+func filtersIn(f store.Filters, args *Args) string {
+	var conditions []string
+	var session []string
+	for _, c := range []struct{ column, value string }{
+		{"country", f.Country}, {"browser", f.Browser}, {"device", f.Device},
+	} {
+		if c.value != "" {
+			session = append(session, "fs."+c.column+" = "+args.Bind(c.value))
+		}
+	}
+	if len(session) > 0 {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM session fs WHERE fs.session_id = e.session_id AND "+
+			strings.Join(session, " AND ")+")")
+	}
+	if f.Page != "" {
+		conditions = append(conditions, "e.url_path = "+args.Bind(f.Page))
+	}
+	if len(f.Dimensions) > 0 {
+		data, _ := json.Marshal(f.Dimensions)
+		conditions = append(conditions, "e.dimensions @> "+args.Bind(string(data))+"::jsonb")
+	}
+	if f.Bot != nil {
+		conditions = append(conditions, "e.bot = "+args.Bind(*f.Bot))
+	}
+	if !f.From.IsZero() {
+		conditions = append(conditions, "e.created_at >= "+args.Bind(f.From))
+	}
+	if !f.To.IsZero() {
+		conditions = append(conditions, "e.created_at < "+args.Bind(f.To))
+	}
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " AND " + strings.Join(conditions, " AND ")
+}
+
+// GetOverview summarizes a period of days, of the events f matches
+func GetOverview(ctx context.Context, db *sql.DB, websiteID uuid.UUID, days int, f store.Filters) (*Overview, error) {
+	args := Args{websiteID, days}
+	filters := filtersIn(f, &args)
+
+	// Long ranges come from the rollups once they cover the whole range;
+	// rollups aren't kept per filter
+	if filters == "" {
+		if covered, _ := rollup.Covers(ctx, db, days); covered {
+			return overviewFromRollups(ctx, db, websiteID, days)
+		}
+	}
+
+	// Pending pageviews were never confirmed as seen
+	overview := &Overview{}
+	err := db.QueryRowContext(ctx, `
+		SELECT COUNT(DISTINCT session_id), COUNT(*), COUNT(*) FILTER (WHERE viewed IS NOT FALSE),
+			`+AvgEngagement+`
+		FROM website_event e
+		WHERE `+pageviewsIn+filters,
+		args...).Scan(&overview.TotalVisitors, &overview.TotalPageviews, &overview.ViewedPageviews, &overview.AvgEngagement)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query totals: %w", err)
+	}
+
+	if pages, err := GetTopPages(ctx, db, websiteID, days, 1, f); err == nil && len(pages) > 0 {
+		overview.TopPage = pages[0]
+	}
+
+	top := func(d Dimension, limit int) ([]*Referrer, error) {
+		return topValues(ctx, db, websiteID, d, days, limit, f)
+	}
+	if refs, err := top(ByReferrer, 1); err == nil && len(refs) > 0 {
+		overview.TopReferrer = refs[0]
+	}
+	overview.BrowserDistribution = distribution(top(ByBrowser, 3))
+	overview.DeviceDistribution = distribution(top(ByDevice, 100))
+	overview.CountryDistribution = distribution(top(ByCountry, 3))
+
+	return overview, nil
+}
+
+// topValues returns the values of d with the most visitors in the period,
+// as domain
+func topValues(ctx context.Context, db *sql.DB, websiteID uuid.UUID, d Dimension, days, limit int, f store.Filters) ([]*Referrer, error) {
+	args := Args{websiteID, days, limit}
+	label := d.Label(&args)
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+label+` AS name, COUNT(DISTINCT e.session_id) AS visitors, COUNT(*)
+		FROM website_event e
+		`+d.Join()+`
+		WHERE `+pageviewsIn+filtersIn(f, &args)+`
+		GROUP BY name
+		ORDER BY visitors DESC
+		LIMIT $3`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var items []*Referrer
+	for rows.Next() {
+		item := &Referrer{}
+		if err := rows.Scan(&item.Domain, &item.Visitors, &item.Pageviews); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// distribution maps values to their visitors, empty when the query failed
+func distribution(items []*Referrer, err error) map[string]int64 {
+	dist := make(map[string]int64)
+	if err != nil {
+		return dist
+	}
+	for _, item := range items {
+		dist[item.Domain] = item.Visitors
+	}
+	return dist
+}
+
+// overviewFromRollups answers GetOverview from the daily rollups
+func overviewFromRollups(ctx context.Context, db *sql.DB, websiteID uuid.UUID, days int) (*Overview, error) {
+	overview := &Overview{}
+
+	// Engagement comes from the page rollups, which sum the tracker's time
+	var engagementMs float64
+	err := db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(visitors), 0), COALESCE(SUM(pageviews), 0),
+			COALESCE((SELECT SUM(engagement_time) FROM rollup_daily_dimension
+				WHERE website_id = $1 AND dimension = 'page' AND day >= website_today($1) - $2::int), 0)
+		FROM rollup_daily
+		WHERE website_id = $1 AND day >= website_today($1) - $2::int
+	`, websiteID, days).Scan(&overview.TotalVisitors, &overview.TotalPageviews, &engagementMs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rollups: %w", err)
+	}
+	if overview.TotalPageviews > 0 {
+		overview.AvgEngagement = engagementMs / 1000 / float64(overview.TotalPageviews)
+	}
+	// Rollups don't track view confirmations
+	overview.ViewedPageviews = overview.TotalPageviews
+
+	top := func(dimension, unknown string, limit int) ([]*Referrer, error) {
+		rows, err := db.QueryContext(ctx, `
+			SELECT COALESCE(NULLIF(value, ''), $3), SUM(visitors)::BIGINT AS visitors, SUM(pageviews)::BIGINT
+			FROM rollup_daily_dimension
+			WHERE website_id = $1 AND dimension = $2 AND day >= website_today($1) - $4::int
+			GROUP BY 1
+			ORDER BY visitors DESC
+			LIMIT $5
+		`, websiteID, dimension, unknown, days, limit)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = rows.Close() }()
+
+		var items []*Referrer
+		for rows.Next() {
+			item := &Referrer{}
+			if err := rows.Scan(&item.Domain, &item.Visitors, &item.Pageviews); err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, rows.Err()
+	}
+
+	// Top page by pageviews, skipping events without a path
+	var page Page
+	err = db.QueryRowContext(ctx, `
+		SELECT value, SUM(pageviews)::BIGINT AS pageviews, SUM(visitors)::BIGINT,
+			COALESCE(SUM(engagement_time) / 1000.0 / NULLIF(SUM(pageviews), 0), 0)::float
+		FROM rollup_daily_dimension
+		WHERE website_id = $1 AND dimension = 'page' AND day >= website_today($1) - $2::int AND value <> ''
+		GROUP BY value
+		ORDER BY pageviews DESC
+		LIMIT 1
+	`, websiteID, days).Scan(&page.Path, &page.Pageviews, &page.UniqueVisitors, &page.AvgTime)
+	if err == nil {
+		overview.TopPage = &page
+	}
+
+	if refs, err := top("referrer", "Direct / None", 1); err == nil && len(refs) > 0 {
+		overview.TopReferrer = refs[0]
+	}
+	overview.BrowserDistribution = distribution(top("browser", "Unknown", 3))
+	overview.DeviceDistribution = distribution(top("device", "Unknown", 100))
+	overview.CountryDistribution = distribution(top("country", "Unknown", 3))
+
+	return overview, nil
+}
+
+// GetTopPages returns the most viewed pages of the period from
+// get_top_pages(), the dashboard's source. Like the dashboard's, the list
+// ignores the page filter.
+func GetTopPages(ctx context.Context, db *sql.DB, websiteID uuid.UUID, days, limit int, f store.Filters) ([]*Page, error) {
+	call, args := "get_top_pages($1, $2, $3)", []interface{}{websiteID, days, limit}
+	f.Page = ""
+	var probe Args
+	if filtersIn(f, &probe) != "" {
+		var dims interface{}
+		if len(f.Dimensions) > 0 {
+			data, _ := json.Marshal(f.Dimensions)
+			dims = string(data)
+		}
+		call = "get_top_pages($1, $2, $3, 0, $4, $5, $6, $7::jsonb, $8, $9, $10)"
+		args = append(args, nullable(f.Country), nullable(f.Browser), nullable(f.Device), dims, f.Bot,
+			nullableTime(f.From), nullableTime(f.To))
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT path, views, unique_visitors,
+			COALESCE(bounce_rate, 0)::float, COALESCE(avg_engagement_time, 0)::float / 1000
+		FROM `+call, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top pages: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var pages []*Page
+	for rows.Next() {
+		page := &Page{}
+		if err := rows.Scan(&page.Path, &page.Pageviews, &page.UniqueVisitors, &page.BounceRate, &page.AvgTime); err != nil {
+			return nil, fmt.Errorf("failed to read top pages: %w", err)
+		}
+		pages = append(pages, page)
+	}
+	return pages, rows.Err()
+}
+
+// Resolve returns the dimension called name: a built-in one, content-group
+// or one of the website's custom dimensions
+func Resolve(ctx context.Context, db *sql.DB, websiteID uuid.UUID, name string) (Dimension, error) {
+	if d, ok := Lookup(name); ok {
+		return d, nil
+	}
+	if name == "content-group" {
+		rules, err := ContentGroupRules(ctx, db, websiteID)
+		if err != nil {
+			return Dimension{}, err
+		}
+		return ContentGroups(rules), nil
+	}
+
+	var registered bool
+	if err := db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM custom_dimension WHERE website_id = $1 AND name = $2)`,
+		websiteID, name).Scan(&registered); err != nil {
+		return Dimension{}, fmt.Errorf("failed to look up custom dimension: %w", err)
+	}
+	if !registered {
+		return Dimension{}, fmt.Errorf("invalid dimension: %s", name)
+	}
+	return Custom(name), nil
+}
+
+// GetBreakdown groups the period's pageviews by d, of the events f matches.
+// Like the dashboard's breakdowns, it ignores the filter on d itself.
+func GetBreakdown(ctx context.Context, db *sql.DB, websiteID uuid.UUID, d Dimension, days, limit int, f store.Filters) (*Breakdown, error) {
+	args := Args{websiteID, days, limit}
+	label := d.Label(&args)
+	rows, err := db.QueryContext(ctx, `
+		WITH events AS (
+			SELECT
+				e.session_id,
+				`+label+` AS name,
+				`+SessionPageviews+` AS session_pageviews
+			FROM website_event e
+			`+d.Join()+`
+			WHERE `+pageviewsIn+filtersIn(withoutFilter(f, d.Name), &args)+`
+		)
+		SELECT
+			name,
+			COUNT(DISTINCT session_id) AS visitors,
+			COUNT(*) AS pageviews,
+			`+BounceRate+` AS bounce_rate
+		FROM events
+		GROUP BY name
+		ORDER BY visitors DESC
+		LIMIT $3`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query breakdown: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	breakdown := &Breakdown{Dimension: d.Name, Items: []BreakdownItem{}}
+	for rows.Next() {
+		var item BreakdownItem
+		if err := rows.Scan(&item.Name, &item.Visitors, &item.Pageviews, &item.BounceRate); err != nil {
+			return nil, fmt.Errorf("failed to read breakdown: %w", err)
+		}
+		breakdown.Items = append(breakdown.Items, item)
+	}
+	return breakdown, rows.Err()
+}
+
+// withoutFilter drops the filter on the dimension called name
+func withoutFilter(f store.Filters, name string) store.Filters {
+	switch name {
+	case "country":
+		f.Country = ""
+	case "browser":
+		f.Browser = ""
+	case "device":
+		f.Device = ""
+	default:
+		if _, ok := f.Dimensions[name]; ok {
+			dims := make(map[string]string, len(f.Dimensions))
+			for k, v := range f.Dimensions {
+				if k != name {
+					dims[k] = v
+				}
+			}
+			f.Dimensions = dims
+		}
+	}
+	return f
+}
+
+// nullable passes empty strings as NULL
+func nullable(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// nullableTime passes a zero time as SQL NULL
+func nullableTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+// ContentGroupRules lists the website's content group rules, failing when
+// there are none to group by
+func ContentGroupRules(ctx context.Context, db *sql.DB, websiteID uuid.UUID) ([]contentgroups.Rule, error) {
+	rules, err := contentgroups.List(ctx, db, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("no content group rules (add one with 'kaunta website content-group add')")
+	}
+	return rules, nil
+}
+
+// recentPageviews selects the pageviews of website $1 in the last five
+// minutes, the window of "now" on the dashboard
+const recentPageviews = `e.website_id = $1 AND e.created_at >= NOW() - INTERVAL '5 minutes' AND e.event_type = 1`
+
+// GetLive reports the last few minutes of activity
+func GetLive(ctx context.Context, db *sql.DB, websiteID uuid.UUID) (*Live, error) {
+	live := &Live{Timestamp: time.Now()}
+
+	err := db.QueryRowContext(ctx, `
+		SELECT COUNT(DISTINCT e.session_id), COUNT(*),
+			COUNT(*) FILTER (WHERE e.created_at >= NOW() - INTERVAL '1 minute')
+		FROM website_event e
+		WHERE `+recentPageviews, websiteID).Scan(&live.ActiveVisitorsNow, &live.RecentEvents, &live.PageviewsLastMinute)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query live stats: %w", err)
+	}
+
+	var page Page
+	err = db.QueryRowContext(ctx, `
+		SELECT e.url_path, COUNT(*) AS pageviews, COUNT(DISTINCT e.session_id)
+		FROM website_event e
+		WHERE `+recentPageviews+` AND e.url_path IS NOT NULL
+		GROUP BY e.url_path
+		ORDER BY pageviews DESC
+		LIMIT 1`, websiteID).Scan(&page.Path, &page.Pageviews, &page.UniqueVisitors)
+	if err == nil {
+		live.TopPageNow = &page
+	}
+
+	args := Args{websiteID}
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+ByReferrer.Label(&args)+` AS referrer, COUNT(*) AS count
+		FROM website_event e
+		WHERE `+recentPageviews+`
+		GROUP BY referrer
+		ORDER BY count DESC
+		LIMIT 5`, args...)
+	if err != nil {
+		return live, nil
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var ref RecentReferrer
+		if err := rows.Scan(&ref.Referrer, &ref.Count); err != nil {
+			break
+		}
+		live.RecentReferrers = append(live.RecentReferrers, ref)
+	}
+	return live, nil
+}
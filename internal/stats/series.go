@@ -0,0 +1,69 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/store"
+)
+
+// SeriesPoint is the key metrics of one bucket of a time series
+type SeriesPoint struct {
+	// Bucket is the start of the bucket in the website's time zone, as a
+	// wall clock time (its location is meaningless)
+	Bucket time.Time
+	Summary
+}
+
+// GetSeries computes the key metrics of a period of days bucketed by
+// interval in the website's time zone, over the events f matches. Every
+// bucket of the period is listed, those without pageviews as zero. Bounce
+// rates use the pageviews of the whole period, so they add up to its own.
+func GetSeries(ctx context.Context, db *sql.DB, websiteID uuid.UUID, days int, interval store.Interval, f store.Filters) ([]SeriesPoint, error) {
+	args := Args{websiteID, days, string(interval), nullableTime(f.From), nullableTime(f.To)}
+	rows, err := db.QueryContext(ctx, `
+		WITH events AS (
+			SELECT
+				e.session_id,
+				e.engagement_time,
+				DATE_TRUNC($3, e.created_at AT TIME ZONE website_timezone($1)) AS bucket,
+				`+SessionPageviews+` AS session_pageviews
+			FROM website_event e
+			WHERE `+pageviewsIn+filtersIn(f, &args)+`
+		),
+		buckets AS (
+			SELECT generate_series(
+				DATE_TRUNC($3, COALESCE($4::timestamptz, website_day_start($1, $2)) AT TIME ZONE website_timezone($1)),
+				DATE_TRUNC($3, (COALESCE($5::timestamptz, NOW()) - INTERVAL '1 second') AT TIME ZONE website_timezone($1)),
+				('1 ' || $3)::interval
+			) AS bucket
+		)
+		SELECT
+			b.bucket,
+			COUNT(DISTINCT session_id),
+			COUNT(session_id),
+			`+BounceRate+`,
+			`+AvgEngagement+`
+		FROM buckets b
+		LEFT JOIN events ev ON ev.bucket = b.bucket
+		GROUP BY b.bucket
+		ORDER BY b.bucket`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query time series: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	points := []SeriesPoint{}
+	for rows.Next() {
+		var p SeriesPoint
+		if err := rows.Scan(&p.Bucket, &p.Visitors, &p.Pageviews, &p.BounceRate, &p.AvgEngagement); err != nil {
+			return nil, fmt.Errorf("failed to read time series: %w", err)
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
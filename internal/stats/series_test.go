@@ -0,0 +1,36 @@
+package stats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/store"
+	"github.com/seuros/kaunta/internal/test"
+)
+
+func TestGetSeries(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+	from := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 2)
+
+	mock.ExpectQuery(`DATE_TRUNC\(\$3, e.created_at AT TIME ZONE website_timezone\(\$1\)\) AS bucket(?s).*`+
+		`e.created_at >= \$6 AND e.created_at < \$7(?s).*generate_series(?s).*LEFT JOIN events ev ON ev.bucket = b.bucket`).
+		WithArgs(websiteID, 3, "day", from, to, from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"bucket", "visitors", "pageviews", "bounce", "engagement"}).
+			AddRow(from, 10, 25, 40.0, 12.5).
+			AddRow(from.AddDate(0, 0, 1), 0, 0, 0.0, 0.0))
+
+	points, err := GetSeries(context.Background(), db, websiteID, 3, store.IntervalDay, store.Filters{From: from, To: to})
+	require.NoError(t, err)
+	require.Len(t, points, 2)
+	assert.Equal(t, SeriesPoint{Bucket: from, Summary: Summary{Visitors: 10, Pageviews: 25, BounceRate: 40, AvgEngagement: 12.5}}, points[0])
+	assert.Zero(t, points[1].Visitors)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,136 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrSessionNotFound is returned for sessions a website doesn't have
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionSummary is a visit: who it was and what it did in the period
+type SessionSummary struct {
+	ID        uuid.UUID `json:"session_id"`
+	Country   string    `json:"country"`
+	Device    string    `json:"device"`
+	Browser   string    `json:"browser"`
+	EntryPage string    `json:"entry_page"`
+	Pageviews int64     `json:"pageviews"`
+	Events    int64     `json:"events"`
+	StartedAt time.Time `json:"started_at"`
+	// Duration is the time from the first to the last event, in seconds
+	Duration float64 `json:"duration"`
+}
+
+// JourneyStep is a pageview or a custom event of a session
+type JourneyStep struct {
+	At   time.Time `json:"at"`
+	Type string    `json:"type"`
+	Path string    `json:"path"`
+	// Title is the page title of pageviews, Name the name of custom events
+	Title string `json:"title,omitempty"`
+	Name  string `json:"name,omitempty"`
+}
+
+// Journey is a session with its pageviews and events in order
+type Journey struct {
+	SessionSummary
+	Steps []JourneyStep `json:"steps"`
+}
+
+// GetSessions lists the sessions of a period with a pageview, the most
+// recently active first
+func GetSessions(ctx context.Context, db *sql.DB, websiteID uuid.UUID, days, limit int) ([]SessionSummary, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT s.session_id, COALESCE(s.country, ''), COALESCE(s.device, ''), COALESCE(s.browser, ''),
+			COALESCE((ARRAY_AGG(e.url_path ORDER BY e.created_at) FILTER (WHERE e.event_type = 1))[1], ''),
+			COUNT(*) FILTER (WHERE e.event_type = 1),
+			COUNT(*) FILTER (WHERE e.event_type = 2),
+			MIN(e.created_at),
+			EXTRACT(EPOCH FROM MAX(e.created_at) - MIN(e.created_at))::float
+		FROM website_event e
+		JOIN session s ON s.session_id = e.session_id
+		WHERE e.website_id = $1 AND `+Since("e.created_at", "$2")+`
+		GROUP BY s.session_id, s.country, s.device, s.browser
+		HAVING COUNT(*) FILTER (WHERE e.event_type = 1) > 0
+		ORDER BY MAX(e.created_at) DESC
+		LIMIT $3
+	`, websiteID, days, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	sessions := []SessionSummary{}
+	for rows.Next() {
+		var s SessionSummary
+		if err := rows.Scan(&s.ID, &s.Country, &s.Device, &s.Browser, &s.EntryPage,
+			&s.Pageviews, &s.Events, &s.StartedAt, &s.Duration); err != nil {
+			return nil, fmt.Errorf("failed to read sessions: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// GetJourney returns a session of a website with all its pageviews and
+// custom events, oldest first
+func GetJourney(ctx context.Context, db *sql.DB, websiteID, sessionID uuid.UUID) (*Journey, error) {
+	j := &Journey{SessionSummary: SessionSummary{ID: sessionID}, Steps: []JourneyStep{}}
+	err := db.QueryRowContext(ctx, `
+		SELECT COALESCE(country, ''), COALESCE(device, ''), COALESCE(browser, '')
+		FROM session
+		WHERE session_id = $1 AND website_id = $2
+	`, sessionID, websiteID).Scan(&j.Country, &j.Device, &j.Browser)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query session: %w", err)
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT e.created_at, e.event_type, COALESCE(e.url_path, ''),
+			COALESCE(e.page_title, ''), COALESCE(e.event_name, '')
+		FROM website_event e
+		WHERE e.session_id = $1 AND e.website_id = $2
+		ORDER BY e.created_at
+	`, sessionID, websiteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query session events: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var step JourneyStep
+		var eventType int
+		var title, name string
+		if err := rows.Scan(&step.At, &eventType, &step.Path, &title, &name); err != nil {
+			return nil, fmt.Errorf("failed to read session events: %w", err)
+		}
+		if eventType == 2 {
+			step.Type, step.Name = "event", name
+			j.Events++
+		} else {
+			step.Type, step.Title = "pageview", title
+			if j.Pageviews == 0 {
+				j.EntryPage = step.Path
+			}
+			j.Pageviews++
+		}
+		j.Steps = append(j.Steps, step)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if n := len(j.Steps); n > 0 {
+		j.StartedAt = j.Steps[0].At
+		j.Duration = j.Steps[n-1].At.Sub(j.StartedAt).Seconds()
+	}
+	return j, nil
+}
@@ -0,0 +1,72 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/test"
+)
+
+func TestGetSessions(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID, sessionID := uuid.New(), uuid.New()
+	started := time.Date(2025, 6, 15, 9, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`FROM website_event e\s+JOIN session s.*HAVING COUNT\(\*\) FILTER \(WHERE e.event_type = 1\) > 0`).
+		WithArgs(websiteID, 7, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"session_id", "country", "device", "browser", "entry_page",
+			"pageviews", "events", "started_at", "duration"}).
+			AddRow(sessionID, "FR", "desktop", "firefox", "/pricing", 4, 1, started, 125.0))
+
+	sessions, err := GetSessions(context.Background(), db, websiteID, 7, 20)
+	require.NoError(t, err)
+	assert.Equal(t, []SessionSummary{{ID: sessionID, Country: "FR", Device: "desktop", Browser: "firefox",
+		EntryPage: "/pricing", Pageviews: 4, Events: 1, StartedAt: started, Duration: 125}}, sessions)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetJourney(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID, sessionID := uuid.New(), uuid.New()
+	started := time.Date(2025, 6, 15, 9, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`FROM session\s+WHERE session_id = \$1 AND website_id = \$2`).
+		WithArgs(sessionID, websiteID).
+		WillReturnRows(sqlmock.NewRows([]string{"country", "device", "browser"}).AddRow("FR", "mobile", "safari"))
+	mock.ExpectQuery(`FROM website_event e\s+WHERE e.session_id = \$1 AND e.website_id = \$2\s+ORDER BY e.created_at`).
+		WithArgs(sessionID, websiteID).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "event_type", "url_path", "page_title", "event_name"}).
+			AddRow(started, 1, "/", "Home", "").
+			AddRow(started.Add(40*time.Second), 1, "/pricing", "Pricing", "").
+			AddRow(started.Add(90*time.Second), 2, "/pricing", "", "signup"))
+
+	j, err := GetJourney(context.Background(), db, websiteID, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, "/", j.EntryPage)
+	assert.Equal(t, int64(2), j.Pageviews)
+	assert.Equal(t, int64(1), j.Events)
+	assert.Equal(t, started, j.StartedAt)
+	assert.Equal(t, 90.0, j.Duration)
+	assert.Equal(t, []JourneyStep{
+		{At: started, Type: "pageview", Path: "/", Title: "Home"},
+		{At: started.Add(40 * time.Second), Type: "pageview", Path: "/pricing", Title: "Pricing"},
+		{At: started.Add(90 * time.Second), Type: "event", Path: "/pricing", Name: "signup"},
+	}, j.Steps)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetJourneyUnknownSession(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	mock.ExpectQuery(`FROM session`).WillReturnError(sql.ErrNoRows)
+
+	_, err := GetJourney(context.Background(), db, uuid.New(), uuid.New())
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
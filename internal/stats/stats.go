@@ -0,0 +1,110 @@
+// Package stats defines the visitor metrics reported both by `kaunta stats`
+// and by the dashboard, so the two can't disagree:
+//
+//   - A period of N days covers events since midnight N days ago in the
+//     website's time zone, the range the get_*() SQL functions use (the
+//     SQLite and ClickHouse stores count UTC days).
+//   - Visitors are distinct sessions with a pageview in the period.
+//   - Bounce rate is the percentage of visitors whose session has a single
+//     pageview in the period.
+//   - Engagement is the tracker's engagement time averaged over pageviews,
+//     reported in seconds (the tracker measures milliseconds).
+//
+// The SQL fragments and queries here are PostgreSQL's; the dashboard reads
+// through the store package, whose backends follow the same definitions.
+package stats
+
+import (
+	"time"
+)
+
+// SessionPageviews numbers the pageviews of each event's session, over the
+// events of the query. BounceRate expects it as column session_pageviews.
+const SessionPageviews = "COUNT(*) OVER (PARTITION BY e.session_id)"
+
+// BounceRate aggregates columns session_id and session_pageviews into the
+// percentage of visitors who bounced
+const BounceRate = `COALESCE(COUNT(DISTINCT session_id) FILTER (WHERE session_pageviews = 1)::float
+	/ NULLIF(COUNT(DISTINCT session_id), 0) * 100, 0)`
+
+// AvgEngagement aggregates column engagement_time into the average seconds
+// per pageview; pageviews the tracker never timed count as zero
+const AvgEngagement = "COALESCE(AVG(COALESCE(engagement_time, 0)) / 1000.0, 0)::float"
+
+// Since is the condition keeping rows whose column falls in a period of
+// days, given as a placeholder, of the website $1
+func Since(column, days string) string {
+	return column + " >= website_day_start($1, " + days + ")"
+}
+
+// Seconds converts an engagement time from the stores, in milliseconds
+func Seconds(ms *float64) *float64 {
+	if ms == nil {
+		return nil
+	}
+	s := *ms / 1000
+	return &s
+}
+
+// Overview is the summary of a period
+type Overview struct {
+	TotalVisitors       int64            `json:"total_visitors"`
+	TotalPageviews      int64            `json:"total_pageviews"`
+	ViewedPageviews     int64            `json:"viewed_pageviews"`
+	TopPage             *Page            `json:"top_page,omitempty"`
+	TopReferrer         *Referrer        `json:"top_referrer,omitempty"`
+	BrowserDistribution map[string]int64 `json:"browser_distribution"`
+	DeviceDistribution  map[string]int64 `json:"device_distribution"`
+	CountryDistribution map[string]int64 `json:"country_distribution"`
+	AvgEngagement       float64          `json:"avg_engagement_seconds"`
+	BlockerCorrection   float64          `json:"blocker_correction,omitempty"`
+	AdjustedVisitors    int64            `json:"adjusted_visitors,omitempty"`
+	// Comparison with the period before, when asked for
+	Comparison *Comparison `json:"comparison,omitempty"`
+}
+
+// Page is one row of the top pages report
+type Page struct {
+	Path           string  `json:"path"`
+	Pageviews      int64   `json:"pageviews"`
+	UniqueVisitors int64   `json:"unique_visitors"`
+	BounceRate     float64 `json:"bounce_rate"`
+	AvgTime        float64 `json:"avg_time_seconds"`
+}
+
+// Referrer is the traffic of one referring domain
+type Referrer struct {
+	Domain    string `json:"domain"`
+	Visitors  int64  `json:"visitors"`
+	Pageviews int64  `json:"pageviews"`
+}
+
+// Breakdown is a period's traffic grouped by one dimension
+type Breakdown struct {
+	Dimension string          `json:"dimension"`
+	Items     []BreakdownItem `json:"items"`
+}
+
+// BreakdownItem is the traffic of one dimension value
+type BreakdownItem struct {
+	Name       string  `json:"name"`
+	Visitors   int64   `json:"visitors"`
+	Pageviews  int64   `json:"pageviews"`
+	BounceRate float64 `json:"bounce_rate"`
+}
+
+// Live is the activity of the last few minutes
+type Live struct {
+	Timestamp           time.Time        `json:"timestamp"`
+	ActiveVisitorsNow   int64            `json:"active_visitors_now"`
+	PageviewsLastMinute int64            `json:"pageviews_last_minute"`
+	TopPageNow          *Page            `json:"top_page_now,omitempty"`
+	RecentReferrers     []RecentReferrer `json:"recent_referrers,omitempty"`
+	RecentEvents        int64            `json:"recent_events"`
+}
+
+// RecentReferrer counts the latest pageviews from one referring domain
+type RecentReferrer struct {
+	Referrer string `json:"referrer"`
+	Count    int64  `json:"count"`
+}
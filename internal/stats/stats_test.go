@@ -0,0 +1,145 @@
+package stats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/store"
+	"github.com/seuros/kaunta/internal/test"
+)
+
+func TestSeconds(t *testing.T) {
+	assert.Nil(t, Seconds(nil))
+	ms := 1500.0
+	assert.Equal(t, 1.5, *Seconds(&ms))
+}
+
+func TestGetOverviewUsesSharedDefinitions(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+
+	// Same period as get_top_pages(), engagement from the tracker in seconds
+	mock.ExpectQuery(`COUNT\(DISTINCT session_id\), COUNT\(\*\).*AVG\(COALESCE\(engagement_time, 0\)\) / 1000.0.*`+
+		`e.created_at >= website_day_start\(\$1, \$2\)`).
+		WithArgs(websiteID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"visitors", "pageviews", "viewed", "engagement"}).
+			AddRow(10, 25, 24, 12.5))
+	mock.ExpectQuery(`FROM get_top_pages\(\$1, \$2, \$3\)`).
+		WithArgs(websiteID, 1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"path", "views", "unique_visitors", "bounce_rate", "avg"}).
+			AddRow("/", 20, 9, 40.0, 15.0))
+	for _, d := range []struct {
+		dimension Dimension
+		limit     int
+		name      string
+	}{
+		{ByReferrer, 1, "google.com"},
+		{ByBrowser, 3, "Firefox"},
+		{ByDevice, 100, "desktop"},
+		{ByCountry, 3, "DE"},
+	} {
+		mock.ExpectQuery(`COALESCE\(\w+\.\w+, \$4\) AS name, COUNT\(DISTINCT e.session_id\) AS visitors`).
+			WithArgs(websiteID, 1, d.limit, d.dimension.Unknown).
+			WillReturnRows(sqlmock.NewRows([]string{"name", "visitors", "pageviews"}).AddRow(d.name, 6, 8))
+	}
+
+	overview, err := GetOverview(context.Background(), db, websiteID, 1, store.Filters{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), overview.TotalVisitors)
+	assert.Equal(t, int64(24), overview.ViewedPageviews)
+	assert.Equal(t, 12.5, overview.AvgEngagement)
+	assert.Equal(t, &Page{Path: "/", Pageviews: 20, UniqueVisitors: 9, BounceRate: 40, AvgTime: 15}, overview.TopPage)
+	assert.Equal(t, "google.com", overview.TopReferrer.Domain)
+	assert.Equal(t, map[string]int64{"Firefox": 6}, overview.BrowserDistribution)
+	assert.Equal(t, map[string]int64{"DE": 6}, overview.CountryDistribution)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTopPagesReportsSeconds(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+
+	mock.ExpectQuery(`COALESCE\(avg_engagement_time, 0\)::float / 1000\s+FROM get_top_pages\(\$1, \$2, \$3\)`).
+		WithArgs(websiteID, 7, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"path", "views", "unique_visitors", "bounce_rate", "avg"}).
+			AddRow("/pricing", 40, 30, 10.0, 12.5))
+
+	pages, err := GetTopPages(context.Background(), db, websiteID, 7, 10, store.Filters{})
+	require.NoError(t, err)
+	assert.Equal(t, []*Page{{Path: "/pricing", Pageviews: 40, UniqueVisitors: 30, BounceRate: 10, AvgTime: 12.5}}, pages)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTopPagesWithFilters(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+	bot := false
+
+	mock.ExpectQuery(`FROM get_top_pages\(\$1, \$2, \$3, 0, \$4, \$5, \$6, \$7::jsonb, \$8, \$9, \$10\)`).
+		WithArgs(websiteID, 7, 10, "DE", nil, "mobile", `{"plan":"pro"}`, &bot, nil, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"path", "views", "unique_visitors", "bounce_rate", "avg"}))
+
+	_, err := GetTopPages(context.Background(), db, websiteID, 7, 10,
+		store.Filters{Country: "DE", Device: "mobile", Page: "/ignored", Dimensions: map[string]string{"plan": "pro"}, Bot: &bot})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFiltersIn(t *testing.T) {
+	args := Args{"website", 7}
+	assert.Equal(t, "", filtersIn(store.Filters{}, &args))
+
+	bot := true
+	where := filtersIn(store.Filters{Country: "DE", Browser: "Firefox", Page: "/pricing", Bot: &bot}, &args)
+	assert.Equal(t, " AND EXISTS (SELECT 1 FROM session fs WHERE fs.session_id = e.session_id AND fs.country = $3 AND fs.browser = $4)"+
+		" AND e.url_path = $5 AND e.bot = $6", where)
+	assert.Equal(t, Args{"website", 7, "DE", "Firefox", "/pricing", true}, args)
+
+	from := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+	args = Args{"website", 7}
+	assert.Equal(t, " AND e.created_at >= $3 AND e.created_at < $4", filtersIn(store.Filters{From: from, To: to}, &args))
+	assert.Equal(t, Args{"website", 7, from, to}, args)
+}
+
+func TestWithoutFilter(t *testing.T) {
+	f := store.Filters{Country: "DE", Dimensions: map[string]string{"plan": "pro", "team": "a"}}
+	assert.Equal(t, store.Filters{Dimensions: f.Dimensions}, withoutFilter(f, "country"))
+	assert.Equal(t, store.Filters{Country: "DE", Dimensions: map[string]string{"team": "a"}}, withoutFilter(f, "plan"))
+	assert.Len(t, f.Dimensions, 2)
+}
+
+func TestResolveContentGroupsWithoutRules(t *testing.T) {
+	db, mock := test.NewMockDB(t)
+	websiteID := uuid.New()
+
+	mock.ExpectQuery(`FROM content_group_rule`).
+		WithArgs(websiteID).
+		WillReturnRows(sqlmock.NewRows([]string{"pattern", "group_name", "position"}))
+
+	_, err := Resolve(context.Background(), db, websiteID, "content-group")
+	assert.ErrorContains(t, err, "no content group rules")
+}
+
+func TestDimensionLabelBindsParameters(t *testing.T) {
+	args := Args{"website", 7}
+	assert.Equal(t, "COALESCE(e.referrer_domain, $3)", ByReferrer.Label(&args))
+	assert.Equal(t, "COALESCE(e.dimensions->>$4, $5)", Custom("plan'; DROP TABLE session; --").Label(&args))
+	assert.Equal(t, Args{"website", 7, "Direct / None", "plan'; DROP TABLE session; --", "Unknown"}, args)
+
+	assert.Equal(t, "JOIN session s ON e.session_id = s.session_id", ByCity.Join())
+	assert.Empty(t, ByAuthor.Join())
+
+	d, ok := Lookup("asn")
+	assert.True(t, ok)
+	assert.Equal(t, "asn", d.Name)
+	_, ok = Lookup("content-group")
+	assert.False(t, ok)
+	assert.Contains(t, Names(), "content-group")
+}
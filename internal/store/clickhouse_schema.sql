@@ -0,0 +1,43 @@
+-- website_event for the ClickHouse event store.
+-- Session dimensions are denormalized onto each event so the dashboard
+-- queries never join back to PostgreSQL.
+CREATE TABLE IF NOT EXISTS website_event (
+    event_id        UUID,
+    website_id      UUID,
+    session_id      UUID,
+    visit_id        UUID,
+    created_at      DateTime64(3, 'UTC'),
+    page_title      Nullable(String),
+    hostname        LowCardinality(Nullable(String)),
+    url_path        Nullable(String),
+    url_query       Nullable(String),
+    referrer_path   Nullable(String),
+    referrer_query  Nullable(String),
+    referrer_domain Nullable(String),
+    event_name      Nullable(String),
+    tag             Nullable(String),
+    event_type      UInt8,
+    scroll_depth    Nullable(UInt8),
+    engagement_time Nullable(UInt32),
+    props           Nullable(String),
+    utm_source      Nullable(String),
+    utm_medium      Nullable(String),
+    utm_campaign    Nullable(String),
+    utm_content     Nullable(String),
+    utm_term        Nullable(String),
+    browser         LowCardinality(Nullable(String)),
+    os              LowCardinality(Nullable(String)),
+    device          LowCardinality(Nullable(String)),
+    country         LowCardinality(Nullable(String)),
+    region          Nullable(String),
+    city            Nullable(String),
+    bot             Bool DEFAULT false,
+    sample_rate     UInt16 DEFAULT 1,
+    asn             Nullable(UInt32),
+    isp             Nullable(String),
+    screen          Nullable(String),
+    channel         LowCardinality(Nullable(String))
+)
+ENGINE = MergeTree
+PARTITION BY toYYYYMM(created_at)
+ORDER BY (website_id, created_at)
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"encoding/json"
+	"regexp"
+	"slices"
+)
+
+// botUserAgentPatterns mirrors the seed rows of bot_user_agent_patterns in
+// the PostgreSQL schema. Matching is case-insensitive like the ~* operator.
+var botUserAgentPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)ChatGPT-User`),
+	regexp.MustCompile(`(?i)GPTBot`),
+	regexp.MustCompile(`(?i)Claude-Web|anthropic-ai`),
+	regexp.MustCompile(`(?i)PerplexityBot|Perplexity`),
+	regexp.MustCompile(`(?i)Google-Extended`),
+	regexp.MustCompile(`(?i)Bytespider`),
+	regexp.MustCompile(`(?i)Claude`),
+	regexp.MustCompile(`(?i)Bard|Google-Bard`),
+	regexp.MustCompile(`(?i)Playwright`),
+	regexp.MustCompile(`(?i)Puppeteer|HeadlessChrome`),
+	regexp.MustCompile(`(?i)Selenium`),
+	regexp.MustCompile(`(?i)PhantomJS|phantom`),
+	regexp.MustCompile(`(?i)Splash`),
+	regexp.MustCompile(`(?i)Zombie\.js|zombie`),
+	regexp.MustCompile(`(?i)axios/`),
+	regexp.MustCompile(`(?i)node-fetch`),
+	regexp.MustCompile(`(?i)python-requests/`),
+	regexp.MustCompile(`(?i)Go-http-client/`),
+	regexp.MustCompile(`(?i)^Java/`),
+	regexp.MustCompile(`(?i)bot|crawler|spider|scraper`),
+}
+
+// IsBotUserAgent reports whether a user agent matches a known bot pattern
+func IsBotUserAgent(userAgent string) bool {
+	if userAgent == "" {
+		return false
+	}
+	for _, pattern := range botUserAgentPatterns {
+		if pattern.MatchString(userAgent) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	originSchemeRe = regexp.MustCompile(`^https?://`)
+	originPortRe   = regexp.MustCompile(`:\d+$`)
+)
+
+// originAllowed applies the validate_origin() rules to a website's
+// allowed_domains JSON array: empty and "null" origins are accepted, otherwise
+// the origin minus scheme and port must be listed.
+func originAllowed(origin string, allowedDomainsJSON []byte) bool {
+	if origin == "" || origin == "null" {
+		return true
+	}
+
+	var allowed []string
+	if len(allowedDomainsJSON) > 0 {
+		if err := json.Unmarshal(allowedDomainsJSON, &allowed); err != nil {
+			return false
+		}
+	}
+
+	domain := originSchemeRe.ReplaceAllString(origin, "")
+	domain = originPortRe.ReplaceAllString(domain, "")
+	return slices.Contains(allowed, domain)
+}
+
+// encodeDomains serialises allowed domains as a JSON array
+func encodeDomains(domains []string) (string, error) {
+	if domains == nil {
+		domains = []string{}
+	}
+	data, err := json.Marshal(domains)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// decodeDomains reads a JSON array of domains; anything else is no domains
+func decodeDomains(data []byte) []string {
+	var domains []string
+	if err := json.Unmarshal(data, &domains); err != nil {
+		return nil
+	}
+	return domains
+}
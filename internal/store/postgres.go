@@ -0,0 +1,701 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/eventprops"
+	"github.com/seuros/kaunta/internal/exclusions"
+	"github.com/seuros/kaunta/internal/rollup"
+)
+
+// Postgres is the default Store backed by database.DB. Aggregations are
+// delegated to the SQL functions installed by the PostgreSQL migrations.
+type Postgres struct{}
+
+// NewPostgres returns a store that uses the shared database.DB connection
+func NewPostgres() *Postgres {
+	return &Postgres{}
+}
+
+func (p *Postgres) db() *sql.DB {
+	return database.DB
+}
+
+// Name implements Store
+func (p *Postgres) Name() string { return "postgres" }
+
+// Ping implements Store
+func (p *Postgres) Ping(ctx context.Context) error {
+	if p.db() == nil {
+		return fmt.Errorf("database connection not initialized")
+	}
+	return p.db().PingContext(ctx)
+}
+
+// Close implements Store
+func (p *Postgres) Close() error {
+	return database.Close()
+}
+
+// WebsiteSettings implements Store
+func (p *Postgres) WebsiteSettings(ctx context.Context, websiteID uuid.UUID) (*WebsiteSettings, error) {
+	var settings WebsiteSettings
+	var allowed, referral []byte
+	var exclude bool
+	var sessionTimeout int
+	err := p.db().QueryRowContext(ctx, `
+		SELECT COALESCE(proxy_mode, 'none'), bot_filter, respect_dnt,
+		       domain, COALESCE(allowed_domains, '[]'::jsonb), exclude_self_referrals, referral_domains,
+		       COALESCE(props_max_bytes, 0), COALESCE(props_max_depth, 0),
+		       COALESCE(visit_definition, ''), COALESCE(session_timeout, 0)
+		FROM website WHERE website_id = $1`,
+		websiteID,
+	).Scan(&settings.ProxyMode, &settings.BotFilter, &settings.RespectDNT,
+		&settings.Domain, &allowed, &exclude, &referral, &settings.PropsMaxBytes, &settings.PropsMaxDepth,
+		&settings.VisitDefinition, &sessionTimeout)
+	if err != nil {
+		return nil, err
+	}
+	settings.SessionTimeout = time.Duration(sessionTimeout) * time.Second
+	settings.KeepSelfReferrals = !exclude
+	settings.AllowedDomains, settings.ReferralDomains = decodeDomains(allowed), decodeDomains(referral)
+	return &settings, nil
+}
+
+// DailySalt implements Store
+func (p *Postgres) DailySalt(ctx context.Context, day time.Time) ([]byte, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return nil, err
+	}
+	date := day.Format("2006-01-02")
+
+	// Concurrent servers agree on whichever salt was inserted first
+	if _, err := p.db().ExecContext(ctx,
+		`INSERT INTO privacy_salt (day, salt) VALUES ($1, $2) ON CONFLICT (day) DO NOTHING`, date, salt,
+	); err != nil {
+		return nil, err
+	}
+	if err := p.db().QueryRowContext(ctx,
+		`SELECT salt FROM privacy_salt WHERE day = $1`, date,
+	).Scan(&salt); err != nil {
+		return nil, err
+	}
+	if _, err := p.db().ExecContext(ctx, `DELETE FROM privacy_salt WHERE day < $1`, date); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// ServerSecret implements Store
+func (p *Postgres) ServerSecret(ctx context.Context, name string) ([]byte, error) {
+	secret, err := newSalt()
+	if err != nil {
+		return nil, err
+	}
+	// Concurrent servers agree on whichever secret was inserted first
+	if _, err := p.db().ExecContext(ctx,
+		`INSERT INTO server_secret (name, secret) VALUES ($1, $2) ON CONFLICT (name) DO NOTHING`, name, secret,
+	); err != nil {
+		return nil, err
+	}
+	if err := p.db().QueryRowContext(ctx,
+		`SELECT secret FROM server_secret WHERE name = $1`, name,
+	).Scan(&secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// ValidateOrigin implements Store using validate_origin()
+func (p *Postgres) ValidateOrigin(ctx context.Context, websiteID uuid.UUID, origin string) (bool, error) {
+	var allowed bool
+	err := p.db().QueryRowContext(ctx,
+		"SELECT validate_origin($1, $2)",
+		websiteID, origin,
+	).Scan(&allowed)
+	return allowed, err
+}
+
+// DetectBot implements Store using update_ip_metadata(), which also records IP metadata
+func (p *Postgres) DetectBot(ctx context.Context, ip, userAgent string) (bool, error) {
+	var isBot *bool // NULL when the function cannot classify the request
+	err := p.db().QueryRowContext(ctx, `
+		SELECT update_ip_metadata($1::inet, $2, NULL)
+	`, ip, userAgent).Scan(&isBot)
+	if err != nil {
+		return false, err
+	}
+	return isBot != nil && *isBot, nil
+}
+
+// UpsertSession implements Store (INSERT ON CONFLICT DO NOTHING)
+func (p *Postgres) UpsertSession(ctx context.Context, s *Session) error {
+	query := `
+		INSERT INTO session (
+			session_id, website_id, browser, os, device, screen, language,
+			country, region, city, created_at, distinct_id, asn, isp
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW(), $11, $12, $13)
+		ON CONFLICT (session_id) DO NOTHING
+	`
+	_, err := p.db().ExecContext(ctx, query, s.SessionID, s.WebsiteID, s.Browser, s.OS, s.Device,
+		s.Screen, s.Language, s.Country, s.Region, s.City, s.DistinctID, s.ASN, s.ISP)
+	return err
+}
+
+// eventColumns lists the website_event columns written by InsertEvents
+const eventColumns = `
+			event_id, website_id, session_id, visit_id, created_at,
+			page_title, hostname, url_path, url_query,
+			referrer_path, referrer_query, referrer_domain,
+			event_name, tag, event_type,
+			scroll_depth, engagement_time, props,
+			utm_source, utm_medium, utm_campaign, utm_content, utm_term,
+			viewed, dimensions, author, bot, sample_rate, channel`
+
+// eventColumnCount is the number of placeholders per row
+const eventColumnCount = 29
+
+// maxEventsPerInsert keeps multi-row INSERTs under PostgreSQL's 65535 bind
+// parameter limit
+const maxEventsPerInsert = 1000
+
+// InsertEvent implements Store
+func (p *Postgres) InsertEvent(ctx context.Context, e *Event) error {
+	return p.InsertEvents(ctx, []*Event{e})
+}
+
+// InsertEvents implements Store with one multi-row INSERT per chunk
+func (p *Postgres) InsertEvents(ctx context.Context, events []*Event) error {
+	for start := 0; start < len(events); start += maxEventsPerInsert {
+		end := min(start+maxEventsPerInsert, len(events))
+		chunk := events[start:end]
+
+		var sb strings.Builder
+		sb.WriteString("\n\t\tINSERT INTO website_event (")
+		sb.WriteString(eventColumns)
+		sb.WriteString("\n\t\t) VALUES ")
+
+		args := make([]interface{}, 0, len(chunk)*eventColumnCount)
+		for i, e := range chunk {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString("(")
+			for col := 1; col <= eventColumnCount; col++ {
+				if col > 1 {
+					sb.WriteString(", ")
+				}
+				fmt.Fprintf(&sb, "$%d", i*eventColumnCount+col)
+			}
+			sb.WriteString(")")
+
+			var props interface{}
+			if e.Props != nil {
+				props = e.Props
+			}
+			args = append(args,
+				e.EventID, e.WebsiteID, e.SessionID, e.VisitID, e.CreatedAt,
+				e.PageTitle, e.Hostname, e.URLPath, e.URLQuery,
+				e.ReferrerPath, e.ReferrerQuery, e.ReferrerDomain,
+				e.EventName, e.Tag, e.EventType,
+				e.ScrollDepth, e.EngagementTime, props,
+				e.UTMSource, e.UTMMedium, e.UTMCampaign, e.UTMContent, e.UTMTerm,
+				e.Viewed, nullableJSON(e.Dimensions), e.Author, e.Bot, e.StoredSampleRate(), nullable(e.Channel),
+			)
+		}
+
+		if _, err := p.db().ExecContext(ctx, sb.String(), args...); err != nil {
+			return err
+		}
+		p.recordSequences(ctx, chunk)
+	}
+	return nil
+}
+
+// CustomDimensions implements Store
+func (p *Postgres) CustomDimensions(ctx context.Context, websiteID uuid.UUID) ([]string, error) {
+	rows, err := p.db().QueryContext(ctx,
+		`SELECT name FROM custom_dimension WHERE website_id = $1 ORDER BY name`, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// ExclusionRules implements Store
+func (p *Postgres) ExclusionRules(ctx context.Context, websiteID uuid.UUID) ([]exclusions.Rule, error) {
+	return exclusions.List(ctx, p.db(), websiteID)
+}
+
+// ConfirmView implements Store
+func (p *Postgres) ConfirmView(ctx context.Context, websiteID, sessionID uuid.UUID, urlPath string, since time.Time) (bool, error) {
+	res, err := p.db().ExecContext(ctx, `
+		UPDATE website_event SET viewed = TRUE
+		WHERE (event_id, created_at) = (
+			SELECT event_id, created_at
+			FROM website_event
+			WHERE website_id = $1
+			  AND session_id = $2
+			  AND url_path = $3
+			  AND created_at >= $4
+			  AND event_type = 1
+			  AND viewed = FALSE
+			ORDER BY created_at DESC
+			LIMIT 1
+		)
+	`, websiteID, sessionID, urlPath, since)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// MarkRead implements Store. A read pageview has been seen, so a pending
+// one is confirmed as well.
+func (p *Postgres) MarkRead(ctx context.Context, websiteID, sessionID uuid.UUID, urlPath string, since time.Time) (bool, error) {
+	res, err := p.db().ExecContext(ctx, `
+		UPDATE website_event SET read = TRUE, viewed = viewed OR viewed IS NOT NULL
+		WHERE (event_id, created_at) = (
+			SELECT event_id, created_at
+			FROM website_event
+			WHERE website_id = $1
+			  AND session_id = $2
+			  AND url_path = $3
+			  AND created_at >= $4
+			  AND event_type = 1
+			  AND read IS NULL
+			ORDER BY created_at DESC
+			LIMIT 1
+		)
+	`, websiteID, sessionID, urlPath, since)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// RecordBaselineHit implements Store; repeat visits on a day are ignored
+func (p *Postgres) RecordBaselineHit(ctx context.Context, websiteID, sessionID uuid.UUID, at time.Time) error {
+	_, err := p.db().ExecContext(ctx, `
+		INSERT INTO baseline_hit (website_id, day, session_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+	`, websiteID, at.UTC().Format("2006-01-02"), sessionID)
+	return err
+}
+
+// DashboardStats implements Store using get_dashboard_stats()
+func (p *Postgres) DashboardStats(ctx context.Context, websiteID uuid.UUID, f Filters) (*DashboardStats, error) {
+	var stats DashboardStats
+	query := `SELECT * FROM get_dashboard_stats($1, 1, $2, $3, $4, $5, $6, $7)`
+	err := p.db().QueryRowContext(ctx, query,
+		websiteID,
+		nullable(f.Country),
+		nullable(f.Browser),
+		nullable(f.Device),
+		nullable(f.Page),
+		nullableJSON(f.Dimensions),
+		f.Bot,
+	).Scan(&stats.CurrentVisitors, &stats.TodayPageviews, &stats.TodayVisitors, &stats.BounceRate)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// TopPages implements Store using get_top_pages()
+func (p *Postgres) TopPages(ctx context.Context, websiteID uuid.UUID, days, limit, offset int, f Filters) ([]PageRow, int64, error) {
+	if p.useRollups(ctx, days, f) {
+		return p.rollupTopPages(ctx, websiteID, days, limit, offset)
+	}
+
+	// Function returns: (path, views, unique_visitors, avg_engagement_time, bounce_rate, total_count)
+	query := `SELECT * FROM get_top_pages($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	rows, err := p.db().QueryContext(ctx, query,
+		websiteID,
+		days,
+		limit,
+		offset,
+		nullable(f.Country),
+		nullable(f.Browser),
+		nullable(f.Device),
+		nullableJSON(f.Dimensions),
+		f.Bot,
+		nullableTime(f.From),
+		nullableTime(f.To),
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	pages := make([]PageRow, 0)
+	var total int64
+	for rows.Next() {
+		var row PageRow
+		if err := rows.Scan(&row.Path, &row.Views, &row.UniqueVisitors, &row.AvgEngagement, &row.BounceRate, &total); err != nil {
+			continue
+		}
+		pages = append(pages, row)
+	}
+	return pages, total, rows.Err()
+}
+
+// TimeSeries implements Store using get_timeseries()
+func (p *Postgres) TimeSeries(ctx context.Context, websiteID uuid.UUID, days int, interval Interval, f Filters) ([]TimePoint, error) {
+	// Rollups are hourly
+	if interval != IntervalMinute && p.useRollups(ctx, days, f) {
+		return p.rollupTimeSeries(ctx, websiteID, days, interval)
+	}
+
+	query := `SELECT * FROM get_timeseries($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	rows, err := p.db().QueryContext(ctx, query,
+		websiteID,
+		days,
+		nullable(f.Country),
+		nullable(f.Browser),
+		nullable(f.Device),
+		nullable(f.Page),
+		nullableJSON(f.Dimensions),
+		f.Bot,
+		nullableTime(f.From),
+		nullableTime(f.To),
+		string(interval),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	points := make([]TimePoint, 0)
+	for rows.Next() {
+		var point TimePoint
+		if err := rows.Scan(&point.Timestamp, &point.Views); err != nil {
+			continue
+		}
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
+
+// Breakdown implements Store using get_breakdown()
+func (p *Postgres) Breakdown(ctx context.Context, websiteID uuid.UUID, dimension string, days, limit, offset int, f Filters) ([]NamedCount, int64, error) {
+	if rollup.HasDimension(dimension) && p.useRollups(ctx, days, f) {
+		return p.rollupBreakdown(ctx, websiteID, dimension, days, limit, offset)
+	}
+
+	query := `SELECT * FROM get_breakdown($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+	rows, err := p.db().QueryContext(ctx, query,
+		websiteID,
+		dimension,
+		days,
+		limit,
+		offset,
+		nullable(f.Country),
+		nullable(f.Browser),
+		nullable(f.Device),
+		nullable(f.Page),
+		nullableJSON(f.Dimensions),
+		f.Bot,
+		nullableTime(f.From),
+		nullableTime(f.To),
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	items := make([]NamedCount, 0)
+	var total int64
+	for rows.Next() {
+		var item NamedCount
+		if err := rows.Scan(&item.Name, &item.Count, &total); err != nil {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, total, rows.Err()
+}
+
+// MapData implements Store using get_map_data()
+func (p *Postgres) MapData(ctx context.Context, websiteID uuid.UUID, days int, f Filters) ([]MapRow, error) {
+	if p.useRollups(ctx, days, f) {
+		return p.rollupMapData(ctx, websiteID, days)
+	}
+
+	query := `SELECT * FROM get_map_data($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	rows, err := p.db().QueryContext(ctx, query,
+		websiteID,
+		days,
+		nullable(f.Country),
+		nullable(f.Browser),
+		nullable(f.Device),
+		nullable(f.Page),
+		nullableJSON(f.Dimensions),
+		f.Bot,
+		nullableTime(f.From),
+		nullableTime(f.To),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var data []MapRow
+	for rows.Next() {
+		var row MapRow
+		if err := rows.Scan(&row.Country, &row.Visitors, &row.Percentage); err != nil {
+			continue
+		}
+		data = append(data, row)
+	}
+	return data, rows.Err()
+}
+
+// UTMBreakdown implements Store using get_utm_breakdown()
+func (p *Postgres) UTMBreakdown(ctx context.Context, websiteID uuid.UUID, dimension string, days, limit, offset int, goal Goal) ([]UTMRow, int64, error) {
+	query := `SELECT * FROM get_utm_breakdown($1, $2, $3, $4, $5, $6, $7::jsonb)`
+	rows, err := p.db().QueryContext(ctx, query,
+		websiteID,
+		dimension,
+		days,
+		limit,
+		offset,
+		nullable(goal.Event),
+		eventprops.Filter(goal.Props).JSON(),
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	items := make([]UTMRow, 0)
+	var total int64
+	for rows.Next() {
+		var row UTMRow
+		if err := rows.Scan(&row.Name, &row.Visitors, &row.Pageviews, &row.Conversions, &total); err != nil {
+			continue
+		}
+		items = append(items, row)
+	}
+	return items, total, rows.Err()
+}
+
+// CurrentVisitors implements Store (distinct sessions in the last 5 minutes)
+func (p *Postgres) CurrentVisitors(ctx context.Context, websiteID uuid.UUID) (int64, error) {
+	query := `
+		SELECT COUNT(DISTINCT session_id)
+		FROM website_event
+		WHERE website_id = $1
+		  AND created_at >= NOW() - INTERVAL '5 minutes'
+		  AND event_type = 1
+	`
+
+	var count int64
+	err := p.db().QueryRowContext(ctx, query, websiteID).Scan(&count)
+	return count, err
+}
+
+// ListWebsites implements Store
+func (p *Postgres) ListWebsites(ctx context.Context, limit, offset int) ([]WebsiteRow, int64, error) {
+	rows, err := p.db().QueryContext(ctx, `
+		WITH total AS (
+			SELECT COUNT(*)::BIGINT as count FROM website
+		)
+		SELECT w.website_id, w.domain, w.name, t.count as total_count
+		FROM website w
+		CROSS JOIN total t
+		ORDER BY w.name, w.domain
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var websites []WebsiteRow
+	var total int64
+	for rows.Next() {
+		var w WebsiteRow
+		if err := rows.Scan(&w.WebsiteID, &w.Domain, &w.Name, &total); err != nil {
+			continue
+		}
+		websites = append(websites, w)
+	}
+	return websites, total, rows.Err()
+}
+
+// FindWebsite implements Store
+func (p *Postgres) FindWebsite(ctx context.Context, websiteID uuid.UUID) (*WebsiteRow, error) {
+	return p.findWebsite(ctx, "website_id = $1", websiteID)
+}
+
+// FindShare implements Store
+func (p *Postgres) FindShare(ctx context.Context, shareID string) (*Share, error) {
+	var s Share
+	var hash sql.NullString
+	var expiresAt sql.NullTime
+	err := p.db().QueryRowContext(ctx, `
+		SELECT website_id, domain, name, share_password_hash, share_expires_at
+		FROM website WHERE deleted_at IS NULL AND share_id = $1
+	`, shareID).Scan(&s.Website.WebsiteID, &s.Website.Domain, &s.Website.Name, &hash, &expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	s.PasswordHash = hash.String
+	if expiresAt.Valid {
+		s.ExpiresAt = &expiresAt.Time
+	}
+	return &s, nil
+}
+
+func (p *Postgres) findWebsite(ctx context.Context, where string, arg interface{}) (*WebsiteRow, error) {
+	var w WebsiteRow
+	err := p.db().QueryRowContext(ctx,
+		"SELECT website_id, domain, name FROM website WHERE deleted_at IS NULL AND "+where, arg,
+	).Scan(&w.WebsiteID, &w.Domain, &w.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// CreateWebsite implements Store
+func (p *Postgres) CreateWebsite(ctx context.Context, domain, name string, allowedDomains []string) (uuid.UUID, error) {
+	allowed, err := encodeDomains(allowedDomains)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	websiteID := uuid.New()
+	_, err = p.db().ExecContext(ctx, `
+		INSERT INTO website (website_id, domain, name, allowed_domains, created_at, updated_at)
+		VALUES ($1, $2, $3, $4::jsonb, NOW(), NOW())
+	`, websiteID, domain, name, allowed)
+	return websiteID, err
+}
+
+// CreateUser implements Store (password hashed by hash_password())
+func (p *Postgres) CreateUser(ctx context.Context, username, password, name string) (*User, error) {
+	user := &User{UserID: uuid.New()}
+	err := p.db().QueryRowContext(ctx, `
+		INSERT INTO users (user_id, username, password_hash, name)
+		VALUES ($1, $2, hash_password($3), NULLIF($4, ''))
+		RETURNING user_id, username, name, password_hash, created_at
+	`, user.UserID, username, password, name).Scan(
+		&user.UserID, &user.Username, &user.Name, &user.PasswordHash, &user.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// FindUser implements Store
+func (p *Postgres) FindUser(ctx context.Context, username string) (*User, error) {
+	query := `
+		SELECT user_id, username, name, password_hash
+		FROM users
+		WHERE username = $1
+	`
+
+	var user User
+	err := p.db().QueryRowContext(ctx, query, username).Scan(
+		&user.UserID,
+		&user.Username,
+		&user.Name,
+		&user.PasswordHash,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// FindUserByID implements Store
+func (p *Postgres) FindUserByID(ctx context.Context, userID uuid.UUID) (*User, error) {
+	user := User{UserID: userID}
+	query := `SELECT name, created_at FROM users WHERE user_id = $1`
+	if err := p.db().QueryRowContext(ctx, query, userID).Scan(&user.Name, &user.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// VerifyPassword implements Store using verify_password()
+func (p *Postgres) VerifyPassword(ctx context.Context, password, passwordHash string) (bool, error) {
+	var valid bool
+	err := p.db().QueryRowContext(ctx, "SELECT verify_password($1, $2)", password, passwordHash).Scan(&valid)
+	if err != nil {
+		return false, err
+	}
+	return valid, nil
+}
+
+// CreateUserSession implements Store
+func (p *Postgres) CreateUserSession(ctx context.Context, s *UserSession) error {
+	insertQuery := `
+		INSERT INTO user_sessions (session_id, user_id, token_hash, expires_at, user_agent, ip_address)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	// Handle empty IP address (e.g., from Docker networking)
+	_, err := p.db().ExecContext(ctx, insertQuery,
+		s.SessionID, s.UserID, s.TokenHash, s.ExpiresAt, s.UserAgent, nullable(s.IPAddress))
+	return err
+}
+
+// DeleteUserSession implements Store
+func (p *Postgres) DeleteUserSession(ctx context.Context, sessionID uuid.UUID) error {
+	query := `DELETE FROM user_sessions WHERE session_id = $1`
+	_, err := p.db().ExecContext(ctx, query, sessionID)
+	return err
+}
+
+// ValidateUserSession implements Store using validate_session()
+func (p *Postgres) ValidateUserSession(ctx context.Context, tokenHash string) (*AuthSession, error) {
+	var session AuthSession
+	var rateLimit, dailyQuota sql.NullInt32
+	query := `SELECT user_id, username, session_id, rate_limit, daily_quota, api_token FROM validate_session($1)`
+
+	err := p.db().QueryRowContext(ctx, query, tokenHash).Scan(
+		&session.UserID,
+		&session.Username,
+		&session.SessionID,
+		&rateLimit,
+		&dailyQuota,
+		&session.APIToken,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if rateLimit.Valid {
+		limit := int(rateLimit.Int32)
+		session.RateLimit = &limit
+	}
+	if dailyQuota.Valid {
+		quota := int(dailyQuota.Int32)
+		session.DailyQuota = &quota
+	}
+	return &session, nil
+}
@@ -0,0 +1,162 @@
+package store
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/logging"
+	"github.com/seuros/kaunta/internal/rollup"
+)
+
+// useRollups reports whether an unfiltered days-long range can be answered
+// from the rollup tables instead of raw events
+func (p *Postgres) useRollups(ctx context.Context, days int, f Filters) bool {
+	if days < rollup.MinDays || !f.empty() {
+		return false
+	}
+	ok, err := rollup.Covers(ctx, p.db(), days)
+	if err != nil {
+		logging.L().Debug("rollup coverage check failed, using raw events", zap.Error(err))
+		return false
+	}
+	return ok
+}
+
+// rollupUnknown is the display name for a missing dimension value, matching
+// get_breakdown()
+func rollupUnknown(dimension string) string {
+	if dimension == "referrer" {
+		return "Direct / None"
+	}
+	return "Unknown"
+}
+
+// rollupTimeSeries sums the hourly rollups into buckets of interval, in the
+// website's time zone like get_timeseries()
+func (p *Postgres) rollupTimeSeries(ctx context.Context, websiteID uuid.UUID, days int, interval Interval) ([]TimePoint, error) {
+	start := "DATE_TRUNC('hour', NOW() - ($2 || ' days')::INTERVAL)"
+	if !interval.Rolling() {
+		start = "website_day_start($1, $2)"
+	}
+	rows, err := p.db().QueryContext(ctx, `
+		SELECT DATE_TRUNC($3, bucket AT TIME ZONE website_timezone($1)) AT TIME ZONE website_timezone($1) AS bucket,
+			SUM(pageviews)::BIGINT
+		FROM rollup_hourly
+		WHERE website_id = $1
+		  AND bucket >= `+start+`
+		  AND pageviews > 0
+		GROUP BY 1
+		ORDER BY 1 ASC
+	`, websiteID, days, string(interval))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	points := make([]TimePoint, 0)
+	for rows.Next() {
+		var point TimePoint
+		if err := rows.Scan(&point.Timestamp, &point.Views); err != nil {
+			continue
+		}
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
+
+func (p *Postgres) rollupBreakdown(ctx context.Context, websiteID uuid.UUID, dimension string, days, limit, offset int) ([]NamedCount, int64, error) {
+	rows, err := p.db().QueryContext(ctx, `
+		WITH breakdown_data AS (
+			SELECT COALESCE(NULLIF(value, ''), $5) AS dim_name, SUM(pageviews)::BIGINT AS dim_count
+			FROM rollup_daily_dimension
+			WHERE website_id = $1 AND dimension = $2 AND day >= website_today($1) - $6::int
+			GROUP BY 1
+		)
+		SELECT dim_name, dim_count, COUNT(*) OVER ()
+		FROM breakdown_data
+		ORDER BY dim_count DESC
+		LIMIT $3 OFFSET $4
+	`, websiteID, dimension, limit, offset, rollupUnknown(dimension), days)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	items := make([]NamedCount, 0)
+	var total int64
+	for rows.Next() {
+		var item NamedCount
+		if err := rows.Scan(&item.Name, &item.Count, &total); err != nil {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, total, rows.Err()
+}
+
+func (p *Postgres) rollupTopPages(ctx context.Context, websiteID uuid.UUID, days, limit, offset int) ([]PageRow, int64, error) {
+	rows, err := p.db().QueryContext(ctx, `
+		WITH page_stats AS (
+			SELECT
+				value AS path,
+				SUM(pageviews)::BIGINT AS views,
+				SUM(visitors)::BIGINT AS unique_visitors,
+				ROUND(SUM(engagement_time)::NUMERIC / NULLIF(SUM(pageviews), 0), 0) AS avg_time
+			FROM rollup_daily_dimension
+			WHERE website_id = $1 AND dimension = 'page' AND day >= website_today($1) - $4::int AND value <> ''
+			GROUP BY value
+		)
+		SELECT path, views, unique_visitors, avg_time, COUNT(*) OVER ()
+		FROM page_stats
+		ORDER BY views DESC
+		LIMIT $2 OFFSET $3
+	`, websiteID, limit, offset, days)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	pages := make([]PageRow, 0)
+	var total int64
+	for rows.Next() {
+		var row PageRow
+		if err := rows.Scan(&row.Path, &row.Views, &row.UniqueVisitors, &row.AvgEngagement, &total); err != nil {
+			continue
+		}
+		pages = append(pages, row)
+	}
+	return pages, total, rows.Err()
+}
+
+func (p *Postgres) rollupMapData(ctx context.Context, websiteID uuid.UUID, days int) ([]MapRow, error) {
+	rows, err := p.db().QueryContext(ctx, `
+		WITH country_breakdown AS (
+			SELECT COALESCE(NULLIF(value, ''), 'Unknown') AS country_code, SUM(visitors)::BIGINT AS visitor_count
+			FROM rollup_daily_dimension
+			WHERE website_id = $1 AND dimension = 'country' AND day >= website_today($1) - $2::int
+			GROUP BY 1
+		)
+		SELECT
+			country_code,
+			visitor_count,
+			COALESCE(ROUND(visitor_count::NUMERIC / NULLIF(SUM(visitor_count) OVER (), 0) * 100, 2), 0)
+		FROM country_breakdown
+		ORDER BY visitor_count DESC
+	`, websiteID, days)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var data []MapRow
+	for rows.Next() {
+		var row MapRow
+		if err := rows.Scan(&row.Country, &row.Visitors, &row.Percentage); err != nil {
+			continue
+		}
+		data = append(data, row)
+	}
+	return data, rows.Err()
+}
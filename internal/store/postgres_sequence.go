@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/logging"
+)
+
+type sequenceKey struct {
+	websiteID uuid.UUID
+	pageID    uuid.UUID
+}
+
+type sequenceCount struct {
+	day      string
+	received int
+	maxSeq   int
+}
+
+// recordSequences adds a batch's sequenced events to event_sequence. The
+// events are already stored, so a failure here is logged and swallowed rather
+// than failing (and retrying) the insert.
+func (p *Postgres) recordSequences(ctx context.Context, events []*Event) {
+	counts := make(map[sequenceKey]*sequenceCount)
+	var keys []sequenceKey
+	for _, e := range events {
+		if e.Seq <= 0 || e.PageID == uuid.Nil {
+			continue
+		}
+		key := sequenceKey{e.WebsiteID, e.PageID}
+		c, ok := counts[key]
+		if !ok {
+			// One row per key: ON CONFLICT can't touch a row twice per statement
+			c = &sequenceCount{day: e.CreatedAt.UTC().Format("2006-01-02")}
+			counts[key] = c
+			keys = append(keys, key)
+		}
+		c.received++
+		c.maxSeq = max(c.maxSeq, e.Seq)
+	}
+	if len(keys) == 0 {
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("INSERT INTO event_sequence (website_id, page_id, day, received, max_seq) VALUES ")
+	args := make([]interface{}, 0, len(keys)*5)
+	for i, key := range keys {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		fmt.Fprintf(&sb, "($%d, $%d, $%d, $%d, $%d)", i*5+1, i*5+2, i*5+3, i*5+4, i*5+5)
+		c := counts[key]
+		args = append(args, key.websiteID, key.pageID, c.day, c.received, c.maxSeq)
+	}
+	sb.WriteString(`
+		ON CONFLICT (website_id, page_id) DO UPDATE SET
+			received = event_sequence.received + EXCLUDED.received,
+			max_seq = GREATEST(event_sequence.max_seq, EXCLUDED.max_seq),
+			updated_at = NOW()`)
+
+	if _, err := p.db().ExecContext(ctx, sb.String(), args...); err != nil {
+		logging.L().Warn("failed to record event sequences", zap.Int("pages", len(keys)), zap.Error(err))
+	}
+}
@@ -0,0 +1,950 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/seuros/kaunta/internal/eventprops"
+	"github.com/seuros/kaunta/internal/exclusions"
+	"github.com/seuros/kaunta/internal/logging"
+	"go.uber.org/zap"
+)
+
+//go:embed sqlite_migrations/*.sql
+var sqliteMigrationFS embed.FS
+
+// sqliteTimeLayout matches SQLite's CURRENT_TIMESTAMP so text comparisons order correctly
+const sqliteTimeLayout = "2006-01-02 15:04:05"
+
+// bcryptCost matches gen_salt('bf', 10) used by hash_password() in PostgreSQL,
+// so password hashes are portable between backends.
+const bcryptCost = 10
+
+// SQLite is a Store backed by a single SQLite database file
+type SQLite struct {
+	db *sql.DB
+}
+
+// SQLitePath extracts the database file path from a sqlite: or file: URL.
+// sqlite:///var/lib/kaunta.db and sqlite://./kaunta.db are both accepted.
+func SQLitePath(databaseURL string) (string, error) {
+	switch {
+	case strings.HasPrefix(databaseURL, "sqlite://"):
+		path := strings.TrimPrefix(databaseURL, "sqlite://")
+		path, _, _ = strings.Cut(path, "?")
+		if path == "" {
+			return "", fmt.Errorf("sqlite URL is missing a file path")
+		}
+		return url.PathUnescape(path)
+	case strings.HasPrefix(databaseURL, "sqlite:"):
+		path, _, _ := strings.Cut(strings.TrimPrefix(databaseURL, "sqlite:"), "?")
+		if path == "" {
+			return "", fmt.Errorf("sqlite URL is missing a file path")
+		}
+		return path, nil
+	case strings.HasPrefix(databaseURL, "file:"):
+		path, _, _ := strings.Cut(strings.TrimPrefix(databaseURL, "file:"), "?")
+		if path == "" {
+			return "", fmt.Errorf("sqlite URL is missing a file path")
+		}
+		return path, nil
+	default:
+		return "", fmt.Errorf("not a sqlite URL: %s", databaseURL)
+	}
+}
+
+// SQLiteAvailable reports whether this build has the SQLite backend
+func SQLiteAvailable() bool {
+	return sqliteAvailable
+}
+
+// OpenSQLite opens (creating if needed) and migrates a SQLite database
+func OpenSQLite(databaseURL string) (*SQLite, error) {
+	if !sqliteAvailable {
+		return nil, fmt.Errorf("SQLite support is not compiled in (rebuild with -tags sqlite and CGO_ENABLED=1)")
+	}
+
+	path, err := SQLitePath(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	dsn := "file:" + path + "?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL"
+	db, err := sql.Open(sqliteDriverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	// SQLite allows a single writer; serialising through one connection avoids SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+
+	s := &SQLite{db: db}
+	if err := s.Migrate(context.Background()); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	logging.L().Info("sqlite database ready", zap.String("path", path))
+	return s, nil
+}
+
+// Migrate applies pending embedded SQLite migrations in order
+func (s *SQLite) Migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	files, err := fs.Glob(sqliteMigrationFS, "sqlite_migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		name := strings.TrimPrefix(file, "sqlite_migrations/")
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return fmt.Errorf("invalid migration file name: %s", name)
+		}
+
+		var applied bool
+		if err := s.db.QueryRowContext(ctx,
+			`SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = ?)`, version,
+		).Scan(&applied); err != nil {
+			return err
+		}
+		if applied {
+			continue
+		}
+
+		body, err := sqliteMigrationFS.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, string(body)); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("migration %s failed: %w", name, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES (?)`, version); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		logging.L().Info("applied sqlite migration", zap.String("migration", name))
+	}
+
+	return nil
+}
+
+// DB exposes the underlying connection (for tests and maintenance commands)
+func (s *SQLite) DB() *sql.DB { return s.db }
+
+// Name implements Store
+func (s *SQLite) Name() string { return "sqlite" }
+
+// Ping implements Store
+func (s *SQLite) Ping(ctx context.Context) error { return s.db.PingContext(ctx) }
+
+// Close implements Store
+func (s *SQLite) Close() error { return s.db.Close() }
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeLayout)
+}
+
+// startOfDay returns midnight UTC days ago, matching website_day_start() in
+// PostgreSQL for a website in UTC (SQLite websites have no time zone)
+func startOfDay(days int) string {
+	now := time.Now().UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return formatTime(midnight.AddDate(0, 0, -days))
+}
+
+// since returns the cutoff for a rolling window, matching NOW() - interval
+func since(d time.Duration) string {
+	return formatTime(time.Now().Add(-d))
+}
+
+// pageviewScope builds the WHERE clause shared by the pageview aggregations.
+// skip names a filter that the caller applies itself (breakdowns ignore the
+// filter on their own dimension).
+func pageviewScope(websiteID uuid.UUID, from string, f Filters, skip string) (string, []interface{}) {
+	clauses := []string{"e.website_id = ?", "e.created_at >= ?", "e.event_type = 1"}
+	args := []interface{}{websiteID.String(), from}
+
+	if f.Country != "" && skip != "country" {
+		clauses = append(clauses, "s.country = ?")
+		args = append(args, f.Country)
+	}
+	if f.Browser != "" && skip != "browser" {
+		clauses = append(clauses, "s.browser = ?")
+		args = append(args, f.Browser)
+	}
+	if f.Device != "" && skip != "device" {
+		clauses = append(clauses, "s.device = ?")
+		args = append(args, f.Device)
+	}
+	if f.Page != "" && skip != "page" {
+		clauses = append(clauses, "e.url_path = ?")
+		args = append(args, f.Page)
+	}
+	// Custom dimensions are not stored in SQLite, so no event has a value
+	if len(f.Dimensions) > 0 {
+		clauses = append(clauses, "1 = 0")
+	}
+	// SQLite drops bots, so every stored event is human
+	if f.Bot != nil && *f.Bot {
+		clauses = append(clauses, "1 = 0")
+	}
+	if !f.From.IsZero() {
+		clauses = append(clauses, "e.created_at >= ?")
+		args = append(args, formatTime(f.From))
+	}
+	if !f.To.IsZero() {
+		clauses = append(clauses, "e.created_at < ?")
+		args = append(args, formatTime(f.To))
+	}
+
+	return strings.Join(clauses, " AND "), args
+}
+
+// WebsiteSettings implements Store. SQLite always drops detected bots.
+func (s *SQLite) WebsiteSettings(ctx context.Context, websiteID uuid.UUID) (*WebsiteSettings, error) {
+	settings := WebsiteSettings{BotFilter: true}
+	var allowed, referral string
+	var exclude bool
+	var sessionTimeout int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COALESCE(proxy_mode, 'none'), respect_dnt, domain, allowed_domains, exclude_self_referrals, referral_domains,
+		       COALESCE(props_max_bytes, 0), COALESCE(props_max_depth, 0),
+		       COALESCE(visit_definition, ''), COALESCE(session_timeout, 0)
+		FROM website WHERE website_id = ? AND deleted_at IS NULL`,
+		websiteID.String(),
+	).Scan(&settings.ProxyMode, &settings.RespectDNT, &settings.Domain, &allowed, &exclude, &referral,
+		&settings.PropsMaxBytes, &settings.PropsMaxDepth, &settings.VisitDefinition, &sessionTimeout)
+	if err != nil {
+		return nil, err
+	}
+	settings.SessionTimeout = time.Duration(sessionTimeout) * time.Second
+	settings.KeepSelfReferrals = !exclude
+	settings.AllowedDomains, settings.ReferralDomains = decodeDomains([]byte(allowed)), decodeDomains([]byte(referral))
+	return &settings, nil
+}
+
+// DailySalt implements Store
+func (s *SQLite) DailySalt(ctx context.Context, day time.Time) ([]byte, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return nil, err
+	}
+	date := day.Format("2006-01-02")
+
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO privacy_salt (day, salt) VALUES (?, ?) ON CONFLICT (day) DO NOTHING`, date, salt,
+	); err != nil {
+		return nil, err
+	}
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT salt FROM privacy_salt WHERE day = ?`, date,
+	).Scan(&salt); err != nil {
+		return nil, err
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM privacy_salt WHERE day < ?`, date); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// ServerSecret implements Store
+func (s *SQLite) ServerSecret(ctx context.Context, name string) ([]byte, error) {
+	secret, err := newSalt()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO server_secret (name, secret) VALUES (?, ?) ON CONFLICT (name) DO NOTHING`, name, secret,
+	); err != nil {
+		return nil, err
+	}
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT secret FROM server_secret WHERE name = ?`, name,
+	).Scan(&secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// ValidateOrigin implements Store with the same rules as validate_origin()
+func (s *SQLite) ValidateOrigin(ctx context.Context, websiteID uuid.UUID, origin string) (bool, error) {
+	if origin == "" || origin == "null" {
+		return true, nil
+	}
+
+	var allowed string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT allowed_domains FROM website WHERE website_id = ? AND deleted_at IS NULL`,
+		websiteID.String(),
+	).Scan(&allowed)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return originAllowed(origin, []byte(allowed)), nil
+}
+
+// DetectBot implements Store. SQLite only matches user agent patterns; the
+// request-rate heuristics of update_ip_metadata() are PostgreSQL-only.
+func (s *SQLite) DetectBot(ctx context.Context, ip, userAgent string) (bool, error) {
+	return IsBotUserAgent(userAgent), nil
+}
+
+// CustomDimensions implements Store. Custom dimensions are PostgreSQL-only;
+// without registered names the tracker's values are not kept.
+func (s *SQLite) CustomDimensions(ctx context.Context, websiteID uuid.UUID) ([]string, error) {
+	return nil, nil
+}
+
+// ExclusionRules implements Store. Exclusion rules are PostgreSQL-only.
+func (s *SQLite) ExclusionRules(ctx context.Context, websiteID uuid.UUID) ([]exclusions.Rule, error) {
+	return nil, nil
+}
+
+// ConfirmView implements Store. The SQLite schema has no viewed column, so
+// every pageview counts as viewed and confirmations are ignored.
+func (s *SQLite) ConfirmView(ctx context.Context, websiteID, sessionID uuid.UUID, urlPath string, since time.Time) (bool, error) {
+	return false, nil
+}
+
+// MarkRead implements Store. Author analytics are PostgreSQL-only, so read
+// signals are ignored.
+func (s *SQLite) MarkRead(ctx context.Context, websiteID, sessionID uuid.UUID, urlPath string, since time.Time) (bool, error) {
+	return false, nil
+}
+
+// RecordBaselineHit implements Store. Blocker estimates are PostgreSQL-only,
+// so baseline hits are not kept.
+func (s *SQLite) RecordBaselineHit(ctx context.Context, websiteID, sessionID uuid.UUID, at time.Time) error {
+	return nil
+}
+
+// UpsertSession implements Store
+func (s *SQLite) UpsertSession(ctx context.Context, sess *Session) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO session (
+			session_id, website_id, browser, os, device, screen, language,
+			country, region, city, created_at, distinct_id, asn, isp
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (session_id) DO NOTHING
+	`, sess.SessionID.String(), sess.WebsiteID.String(), sess.Browser, sess.OS, sess.Device,
+		sess.Screen, sess.Language, sess.Country, sess.Region, sess.City,
+		formatTime(time.Now()), sess.DistinctID, sess.ASN, sess.ISP)
+	return err
+}
+
+const sqliteInsertEvent = `
+		INSERT INTO website_event (
+			event_id, website_id, session_id, visit_id, created_at,
+			page_title, hostname, url_path, url_query,
+			referrer_path, referrer_query, referrer_domain,
+			event_name, tag, event_type,
+			scroll_depth, engagement_time, props,
+			utm_source, utm_medium, utm_campaign, utm_content, utm_term,
+			sample_rate, channel
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+// InsertEvent implements Store
+func (s *SQLite) InsertEvent(ctx context.Context, e *Event) error {
+	return s.InsertEvents(ctx, []*Event{e})
+}
+
+// InsertEvents implements Store in a single transaction
+func (s *SQLite) InsertEvents(ctx context.Context, events []*Event) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := tx.PrepareContext(ctx, sqliteInsertEvent)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stmt.Close() }()
+
+	for _, e := range events {
+		var props interface{}
+		if e.Props != nil {
+			props = string(e.Props)
+		}
+
+		if _, err := stmt.ExecContext(ctx,
+			e.EventID.String(), e.WebsiteID.String(), e.SessionID.String(), e.VisitID.String(), formatTime(e.CreatedAt),
+			e.PageTitle, e.Hostname, e.URLPath, e.URLQuery,
+			e.ReferrerPath, e.ReferrerQuery, e.ReferrerDomain,
+			e.EventName, e.Tag, e.EventType,
+			e.ScrollDepth, e.EngagementTime, props,
+			e.UTMSource, e.UTMMedium, e.UTMCampaign, e.UTMContent, e.UTMTerm,
+			e.StoredSampleRate(), nullable(e.Channel),
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DashboardStats implements Store (see get_dashboard_stats)
+func (s *SQLite) DashboardStats(ctx context.Context, websiteID uuid.UUID, f Filters) (*DashboardStats, error) {
+	var stats DashboardStats
+
+	where, args := pageviewScope(websiteID, since(5*time.Minute), f, "")
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(DISTINCT e.session_id)
+		FROM website_event e
+		JOIN session s ON e.session_id = s.session_id
+		WHERE `+where, args...).Scan(&stats.CurrentVisitors); err != nil {
+		return nil, err
+	}
+
+	where, args = pageviewScope(websiteID, startOfDay(0), f, "")
+	var bounces int64
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(views), 0), COUNT(*), COALESCE(SUM(CASE WHEN views = 1 THEN 1 ELSE 0 END), 0)
+		FROM (
+			SELECT e.session_id, COUNT(*) AS views
+			FROM website_event e
+			JOIN session s ON e.session_id = s.session_id
+			WHERE `+where+`
+			GROUP BY e.session_id
+		)`, args...).Scan(&stats.TodayPageviews, &stats.TodayVisitors, &bounces); err != nil {
+		return nil, err
+	}
+
+	if stats.TodayVisitors > 0 {
+		stats.BounceRate = math.Round(float64(bounces)/float64(stats.TodayVisitors)*10000) / 100
+	}
+	return &stats, nil
+}
+
+// TopPages implements Store (see get_top_pages)
+func (s *SQLite) TopPages(ctx context.Context, websiteID uuid.UUID, days, limit, offset int, f Filters) ([]PageRow, int64, error) {
+	where, args := pageviewScope(websiteID, startOfDay(days), f, "page")
+	args = append(args, limit, offset)
+
+	rows, err := s.db.QueryContext(ctx, `
+		WITH events AS (
+			SELECT
+				e.url_path, e.session_id, e.engagement_time,
+				COUNT(*) OVER (PARTITION BY e.session_id) AS session_pageviews
+			FROM website_event e
+			JOIN session s ON e.session_id = s.session_id
+			WHERE `+where+` AND e.url_path IS NOT NULL
+		),
+		page_stats AS (
+			SELECT
+				url_path AS path,
+				COUNT(*) AS views,
+				COUNT(DISTINCT session_id) AS unique_visitors,
+				ROUND(AVG(COALESCE(engagement_time, 0)), 0) AS avg_time,
+				ROUND(COUNT(DISTINCT CASE WHEN session_pageviews = 1 THEN session_id END) * 100.0
+					/ COUNT(DISTINCT session_id), 1) AS bounce_rate
+			FROM events
+			GROUP BY url_path
+		)
+		SELECT path, views, unique_visitors, avg_time, bounce_rate, COUNT(*) OVER () AS total_count
+		FROM page_stats
+		ORDER BY views DESC, path
+		LIMIT ? OFFSET ?`, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	pages := make([]PageRow, 0)
+	var total int64
+	for rows.Next() {
+		var row PageRow
+		if err := rows.Scan(&row.Path, &row.Views, &row.UniqueVisitors, &row.AvgEngagement, &row.BounceRate, &total); err != nil {
+			return nil, 0, err
+		}
+		pages = append(pages, row)
+	}
+	return pages, total, rows.Err()
+}
+
+// sqliteBuckets are the start of each interval's bucket of e.created_at
+var sqliteBuckets = map[Interval]string{
+	IntervalMinute: "strftime('%Y-%m-%dT%H:%M:00Z', e.created_at)",
+	IntervalHour:   "strftime('%Y-%m-%dT%H:00:00Z', e.created_at)",
+	IntervalDay:    "strftime('%Y-%m-%dT00:00:00Z', e.created_at)",
+	IntervalWeek:   "strftime('%Y-%m-%dT00:00:00Z', e.created_at, 'weekday 0', '-6 days')",
+	IntervalMonth:  "strftime('%Y-%m-01T00:00:00Z', e.created_at)",
+}
+
+// TimeSeries implements Store (see get_timeseries)
+func (s *SQLite) TimeSeries(ctx context.Context, websiteID uuid.UUID, days int, interval Interval, f Filters) ([]TimePoint, error) {
+	bucket, ok := sqliteBuckets[interval]
+	if !ok {
+		return nil, fmt.Errorf("invalid interval: %s", interval)
+	}
+	from := since(time.Duration(days) * 24 * time.Hour)
+	if !interval.Rolling() {
+		from = startOfDay(days)
+	}
+	where, args := pageviewScope(websiteID, from, f, "")
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+bucket+` AS bucket, COUNT(*) AS views
+		FROM website_event e
+		JOIN session s ON e.session_id = s.session_id
+		WHERE `+where+`
+		GROUP BY bucket
+		ORDER BY bucket ASC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	points := make([]TimePoint, 0)
+	for rows.Next() {
+		var point TimePoint
+		if err := rows.Scan(&point.Timestamp, &point.Views); err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
+
+// breakdownColumns maps a dimension to its label expression and grouping column
+var breakdownColumns = map[string][2]string{
+	"country":  {"COALESCE(s.country, 'Unknown')", "s.country"},
+	"browser":  {"COALESCE(s.browser, 'Unknown')", "s.browser"},
+	"device":   {"COALESCE(s.device, 'Unknown')", "s.device"},
+	"referrer": {"COALESCE(e.referrer_domain, 'Direct / None')", "e.referrer_domain"},
+	"city":     {"COALESCE(s.city || COALESCE(', ' || s.region, '') || COALESCE(', ' || s.country, ''), 'Unknown')", "s.city, s.region, s.country"},
+	"region":   {"COALESCE(s.region || COALESCE(', ' || s.country, ''), 'Unknown')", "s.region, s.country"},
+	"page":     {"COALESCE(e.url_path, 'Unknown')", "e.url_path"},
+	"asn":      {"COALESCE('AS' || s.asn || COALESCE(' ' || s.isp, ''), 'Unknown')", "s.asn, s.isp"},
+	"screen":   {"COALESCE(" + widthCase(sqliteScreenWidth, screenBuckets) + ", 'Unknown')", "name"},
+	"viewport": {"COALESCE(" + widthCase(sqliteScreenWidth, viewportClasses) + ", 'Unknown')", "name"},
+	"channel":  {"COALESCE(e.channel, 'Unknown')", "e.channel"},
+}
+
+// sqliteScreenWidth is the width of a "1920x1080" screen, NULL when malformed
+const sqliteScreenWidth = "(CASE WHEN s.screen GLOB '[0-9]*x[0-9]*' AND NOT s.screen GLOB '*[^0-9x]*' " +
+	"THEN CAST(substr(s.screen, 1, instr(s.screen, 'x') - 1) AS INTEGER) END)"
+
+// Breakdown implements Store (see get_breakdown)
+func (s *SQLite) Breakdown(ctx context.Context, websiteID uuid.UUID, dimension string, days, limit, offset int, f Filters) ([]NamedCount, int64, error) {
+	if err := checkDimension(dimension); err != nil {
+		return nil, 0, err
+	}
+	cols := breakdownColumns[dimension]
+
+	where, args := pageviewScope(websiteID, startOfDay(days), f, dimension)
+	if dimension == "page" {
+		where += " AND e.url_path IS NOT NULL"
+	}
+	args = append(args, limit, offset)
+
+	rows, err := s.db.QueryContext(ctx, `
+		WITH breakdown_data AS (
+			SELECT `+cols[0]+` AS name, COUNT(*) AS count
+			FROM website_event e
+			JOIN session s ON e.session_id = s.session_id
+			WHERE `+where+`
+			GROUP BY `+cols[1]+`
+		)
+		SELECT name, count, COUNT(*) OVER () AS total_count
+		FROM breakdown_data
+		ORDER BY count DESC, name
+		LIMIT ? OFFSET ?`, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	items := make([]NamedCount, 0)
+	var total int64
+	for rows.Next() {
+		var item NamedCount
+		if err := rows.Scan(&item.Name, &item.Count, &total); err != nil {
+			return nil, 0, err
+		}
+		items = append(items, item)
+	}
+	return items, total, rows.Err()
+}
+
+// MapData implements Store (see get_map_data)
+func (s *SQLite) MapData(ctx context.Context, websiteID uuid.UUID, days int, f Filters) ([]MapRow, error) {
+	where, args := pageviewScope(websiteID, since(time.Duration(days)*24*time.Hour), f, "")
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT COALESCE(s.country, 'Unknown') AS country, COUNT(DISTINCT e.session_id) AS visitors
+		FROM website_event e
+		JOIN session s ON e.session_id = s.session_id
+		WHERE `+where+`
+		GROUP BY s.country
+		ORDER BY visitors DESC, country`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var data []MapRow
+	for rows.Next() {
+		var row MapRow
+		if err := rows.Scan(&row.Country, &row.Visitors); err != nil {
+			return nil, err
+		}
+		data = append(data, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Sessions have a single country, so the per-country counts add up to the total
+	var total int64
+	for _, row := range data {
+		total += row.Visitors
+	}
+	for i := range data {
+		if total > 0 {
+			data[i].Percentage = math.Round(float64(data[i].Visitors)/float64(total)*10000) / 100
+		}
+	}
+	return data, nil
+}
+
+// UTMBreakdown implements Store (see get_utm_breakdown)
+func (s *SQLite) UTMBreakdown(ctx context.Context, websiteID uuid.UUID, dimension string, days, limit, offset int, goal Goal) ([]UTMRow, int64, error) {
+	if !validUTMDimensions[dimension] {
+		return nil, 0, fmt.Errorf("invalid UTM dimension: %s", dimension)
+	}
+	column := "e.utm_" + dimension
+	conversion, goalArgs := sqliteGoal(goal)
+
+	args := []interface{}{websiteID.String(), since(time.Duration(days) * 24 * time.Hour)}
+	args = append(args, goalArgs...)
+	args = append(args, limit, offset)
+	rows, err := s.db.QueryContext(ctx, `
+		WITH ranged AS (
+			SELECT e.session_id, e.event_type, e.event_name, e.props, `+column+` AS utm_value
+			FROM website_event e
+			WHERE e.website_id = ? AND e.created_at >= ?
+		),
+		attributed AS (
+			SELECT DISTINCT utm_value, session_id
+			FROM ranged
+			WHERE event_type = 1 AND utm_value IS NOT NULL AND utm_value <> ''
+		),
+		session_pageviews AS (
+			SELECT session_id, COUNT(*) AS views
+			FROM ranged
+			WHERE event_type = 1
+			GROUP BY session_id
+		),
+		converted AS (
+			SELECT DISTINCT session_id
+			FROM ranged
+			WHERE event_type = 2`+conversion+`
+		)
+		SELECT
+			a.utm_value AS name,
+			COUNT(*) AS visitors,
+			COALESCE(SUM(sp.views), 0) AS pageviews,
+			COUNT(c.session_id) AS conversions,
+			COUNT(*) OVER () AS total_count
+		FROM attributed a
+		LEFT JOIN session_pageviews sp ON sp.session_id = a.session_id
+		LEFT JOIN converted c ON c.session_id = a.session_id
+		GROUP BY a.utm_value
+		ORDER BY visitors DESC, name
+		LIMIT ? OFFSET ?`, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	items := make([]UTMRow, 0)
+	var total int64
+	for rows.Next() {
+		var row UTMRow
+		if err := rows.Scan(&row.Name, &row.Visitors, &row.Pageviews, &row.Conversions, &total); err != nil {
+			return nil, 0, err
+		}
+		items = append(items, row)
+	}
+	return items, total, rows.Err()
+}
+
+// sqliteGoal writes the conditions keeping the conversions of goal, with
+// their arguments. Property values are compared as JSON text, so arrays
+// don't match their items.
+func sqliteGoal(goal Goal) (string, []interface{}) {
+	var conditions string
+	var args []interface{}
+	if goal.Event != "" {
+		conditions += " AND event_name = ?"
+		args = append(args, goal.Event)
+	}
+	keys := make([]string, 0, len(goal.Props))
+	for key := range goal.Props {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		values := eventprops.Values(goal.Props[key])
+		// Keys are validated (eventprops.ValidKey), so the path is written inline
+		conditions += " AND (CASE WHEN json_valid(props) THEN props -> '$." + key + "' END) IN (" +
+			strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ") + ")"
+		for _, v := range values {
+			args = append(args, v)
+		}
+	}
+	return conditions, args
+}
+
+// CurrentVisitors implements Store
+func (s *SQLite) CurrentVisitors(ctx context.Context, websiteID uuid.UUID) (int64, error) {
+	var count int64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(DISTINCT session_id)
+		FROM website_event
+		WHERE website_id = ? AND created_at >= ? AND event_type = 1
+	`, websiteID.String(), since(5*time.Minute)).Scan(&count)
+	return count, err
+}
+
+// ListWebsites implements Store
+func (s *SQLite) ListWebsites(ctx context.Context, limit, offset int) ([]WebsiteRow, int64, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT website_id, domain, name, COUNT(*) OVER () AS total_count
+		FROM website
+		WHERE deleted_at IS NULL
+		ORDER BY name, domain
+		LIMIT ? OFFSET ?
+	`, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var websites []WebsiteRow
+	var total int64
+	for rows.Next() {
+		var w WebsiteRow
+		if err := rows.Scan(&w.WebsiteID, &w.Domain, &w.Name, &total); err != nil {
+			return nil, 0, err
+		}
+		websites = append(websites, w)
+	}
+	return websites, total, rows.Err()
+}
+
+// FindWebsite implements Store
+func (s *SQLite) FindWebsite(ctx context.Context, websiteID uuid.UUID) (*WebsiteRow, error) {
+	return s.findWebsite(ctx, "website_id = ?", websiteID.String())
+}
+
+// FindShare implements Store
+func (s *SQLite) FindShare(ctx context.Context, shareID string) (*Share, error) {
+	var share Share
+	var hash, expiresAt sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		SELECT website_id, domain, name, share_password_hash, share_expires_at
+		FROM website WHERE deleted_at IS NULL AND share_id = ?
+	`, shareID).Scan(&share.Website.WebsiteID, &share.Website.Domain, &share.Website.Name, &hash, &expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	share.PasswordHash = hash.String
+	if expiresAt.Valid {
+		t, err := time.Parse(sqliteTimeLayout, expiresAt.String)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse share expiry: %w", err)
+		}
+		share.ExpiresAt = &t
+	}
+	return &share, nil
+}
+
+func (s *SQLite) findWebsite(ctx context.Context, where string, arg interface{}) (*WebsiteRow, error) {
+	var w WebsiteRow
+	err := s.db.QueryRowContext(ctx,
+		"SELECT website_id, domain, name FROM website WHERE deleted_at IS NULL AND "+where, arg,
+	).Scan(&w.WebsiteID, &w.Domain, &w.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// CreateWebsite implements Store
+func (s *SQLite) CreateWebsite(ctx context.Context, domain, name string, allowedDomains []string) (uuid.UUID, error) {
+	allowed, err := encodeDomains(allowedDomains)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	websiteID := uuid.New()
+	now := formatTime(time.Now())
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO website (website_id, domain, name, allowed_domains, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, websiteID.String(), domain, name, allowed, now, now)
+	return websiteID, err
+}
+
+// CreateUser implements Store, hashing the password with bcrypt
+func (s *SQLite) CreateUser(ctx context.Context, username, password, name string) (*User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user := &User{
+		UserID:       uuid.New(),
+		Username:     username,
+		Name:         sql.NullString{String: name, Valid: name != ""},
+		PasswordHash: string(hash),
+		CreatedAt:    time.Now().UTC().Truncate(time.Second),
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO users (user_id, username, password_hash, name, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, user.UserID.String(), user.Username, user.PasswordHash, nullable(name),
+		formatTime(user.CreatedAt), formatTime(user.CreatedAt))
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// FindUser implements Store
+func (s *SQLite) FindUser(ctx context.Context, username string) (*User, error) {
+	var user User
+	var userID string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT user_id, username, name, password_hash
+		FROM users
+		WHERE username = ?
+	`, username).Scan(&userID, &user.Username, &user.Name, &user.PasswordHash)
+	if err != nil {
+		return nil, err
+	}
+	if user.UserID, err = uuid.Parse(userID); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// FindUserByID implements Store
+func (s *SQLite) FindUserByID(ctx context.Context, userID uuid.UUID) (*User, error) {
+	user := User{UserID: userID}
+	var createdAt string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT name, strftime('%Y-%m-%d %H:%M:%S', created_at) FROM users WHERE user_id = ?`,
+		userID.String(),
+	).Scan(&user.Name, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	if user.CreatedAt, err = time.Parse(sqliteTimeLayout, createdAt); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// VerifyPassword implements Store. Hashes created by pgcrypto's crypt() are
+// standard bcrypt, so users migrated from PostgreSQL keep working.
+func (s *SQLite) VerifyPassword(ctx context.Context, password, passwordHash string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CreateUserSession implements Store
+func (s *SQLite) CreateUserSession(ctx context.Context, us *UserSession) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO user_sessions (session_id, user_id, token_hash, expires_at, user_agent, ip_address)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, us.SessionID.String(), us.UserID.String(), us.TokenHash, formatTime(us.ExpiresAt),
+		us.UserAgent, nullable(us.IPAddress))
+	return err
+}
+
+// DeleteUserSession implements Store
+func (s *SQLite) DeleteUserSession(ctx context.Context, sessionID uuid.UUID) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM user_sessions WHERE session_id = ?`, sessionID.String())
+	return err
+}
+
+// ValidateUserSession implements Store (see validate_session)
+func (s *SQLite) ValidateUserSession(ctx context.Context, tokenHash string) (*AuthSession, error) {
+	now := formatTime(time.Now())
+
+	var userID, username, sessionID string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT us.user_id, u.username, us.session_id
+		FROM user_sessions us
+		JOIN users u ON u.user_id = us.user_id
+		WHERE us.token_hash = ? AND us.expires_at > ?
+	`, tokenHash, now).Scan(&userID, &username, &sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.db.ExecContext(ctx,
+		`UPDATE user_sessions SET last_used_at = ? WHERE session_id = ?`, now, sessionID,
+	); err != nil {
+		return nil, err
+	}
+
+	session := &AuthSession{Username: username}
+	if session.UserID, err = uuid.Parse(userID); err != nil {
+		return nil, err
+	}
+	if session.SessionID, err = uuid.Parse(sessionID); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
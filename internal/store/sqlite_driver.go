@@ -0,0 +1,12 @@
+//go:build sqlite && cgo
+
+package store
+
+import (
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteAvailable reports whether the SQLite driver is compiled in
+const sqliteAvailable = true
+
+const sqliteDriverName = "sqlite3"
@@ -0,0 +1,93 @@
+-- SQLite Migration 0001: Initial Schema
+-- ============================================================================
+-- Single-file schema for small deployments. Mirrors the PostgreSQL tables used
+-- by the tracking endpoint and dashboard; aggregations that PostgreSQL runs in
+-- SQL functions are implemented as queries in internal/store/sqlite.go.
+--
+-- Timestamps are stored as UTC text ('YYYY-MM-DD HH:MM:SS') so they compare
+-- correctly against datetime('now', ...).
+
+CREATE TABLE IF NOT EXISTS users (
+    user_id TEXT PRIMARY KEY,
+    username TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    name TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS user_sessions (
+    session_id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    user_agent TEXT,
+    ip_address TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_sessions_token ON user_sessions(token_hash);
+
+CREATE TABLE IF NOT EXISTS website (
+    website_id TEXT PRIMARY KEY,
+    domain TEXT NOT NULL,
+    name TEXT,
+    share_id TEXT UNIQUE,
+    allowed_domains TEXT NOT NULL DEFAULT '[]',
+    proxy_mode TEXT NOT NULL DEFAULT 'none' CHECK (proxy_mode IN ('none', 'xforwarded', 'cloudflare')),
+    user_id TEXT REFERENCES users(user_id) ON DELETE SET NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at DATETIME
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_website_domain ON website(lower(domain)) WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS session (
+    session_id TEXT PRIMARY KEY,
+    website_id TEXT NOT NULL REFERENCES website(website_id) ON DELETE CASCADE,
+    hostname TEXT,
+    browser TEXT,
+    os TEXT,
+    device TEXT,
+    screen TEXT,
+    language TEXT,
+    country TEXT,
+    city TEXT,
+    region TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    distinct_id TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_website_created ON session(website_id, created_at);
+
+CREATE TABLE IF NOT EXISTS website_event (
+    event_id TEXT PRIMARY KEY,
+    website_id TEXT NOT NULL REFERENCES website(website_id) ON DELETE CASCADE,
+    session_id TEXT NOT NULL REFERENCES session(session_id) ON DELETE CASCADE,
+    visit_id TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    url_path TEXT,
+    url_query TEXT,
+    referrer_path TEXT,
+    referrer_query TEXT,
+    referrer_domain TEXT,
+    page_title TEXT,
+    hostname TEXT,
+    event_type INTEGER NOT NULL DEFAULT 1 CHECK (event_type IN (1, 2)),
+    event_name TEXT,
+    tag TEXT,
+    scroll_depth INTEGER,
+    engagement_time INTEGER,
+    props TEXT,
+    utm_source TEXT,
+    utm_medium TEXT,
+    utm_campaign TEXT,
+    utm_content TEXT,
+    utm_term TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_website_created ON website_event(website_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_event_session_created ON website_event(session_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_event_type ON website_event(website_id, event_type, created_at);
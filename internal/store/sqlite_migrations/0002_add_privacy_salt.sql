@@ -0,0 +1,9 @@
+-- SQLite Migration 0002: Privacy Salt
+-- Daily salt for hashed visitor IDs (ip_mode = hash); only the current UTC
+-- day is kept, see migration 000023 for PostgreSQL.
+
+CREATE TABLE IF NOT EXISTS privacy_salt (
+    day TEXT PRIMARY KEY,
+    salt BLOB NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,6 @@
+-- SQLite Migration 0003: Respect Do-Not-Track
+-- What happens to DNT/GPC requests: off, drop or anonymize; see migration
+-- 000024 for PostgreSQL.
+
+ALTER TABLE website ADD COLUMN respect_dnt TEXT NOT NULL DEFAULT 'off'
+    CHECK (respect_dnt IN ('off', 'drop', 'anonymize'));
@@ -0,0 +1,5 @@
+-- SQLite Migration 0004: Event Sample Rate
+-- How many events each stored event stands for; see migration 000026 for
+-- PostgreSQL.
+
+ALTER TABLE website_event ADD COLUMN sample_rate INTEGER NOT NULL DEFAULT 1;
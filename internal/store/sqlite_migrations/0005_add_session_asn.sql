@@ -0,0 +1,6 @@
+-- SQLite Migration 0005: Session ASN
+-- Autonomous system and ISP of the visitor's IP; see migration 000027 for
+-- PostgreSQL.
+
+ALTER TABLE session ADD COLUMN asn INTEGER;
+ALTER TABLE session ADD COLUMN isp TEXT;
@@ -0,0 +1,6 @@
+-- SQLite Migration 0006: Self-referral exclusion
+-- Referrers from the website's own domains are dropped unless it keeps
+-- them; see migration 000042 for PostgreSQL.
+
+ALTER TABLE website ADD COLUMN exclude_self_referrals INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE website ADD COLUMN referral_domains TEXT NOT NULL DEFAULT '[]';
@@ -0,0 +1,6 @@
+-- SQLite Migration 0007: Event properties limits
+-- Custom event properties are capped in size and nesting at ingestion; see
+-- migration 000044 for PostgreSQL.
+
+ALTER TABLE website ADD COLUMN props_max_bytes INTEGER;
+ALTER TABLE website ADD COLUMN props_max_depth INTEGER;
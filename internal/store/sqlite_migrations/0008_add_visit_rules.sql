@@ -0,0 +1,6 @@
+-- SQLite Migration 0008: Visit rules
+-- Websites can override how sessions are split into visits; see migration
+-- 000048 for PostgreSQL.
+
+ALTER TABLE website ADD COLUMN visit_definition TEXT;
+ALTER TABLE website ADD COLUMN session_timeout INTEGER;
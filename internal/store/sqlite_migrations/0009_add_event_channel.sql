@@ -0,0 +1,5 @@
+-- SQLite Migration 0009: Traffic channels
+-- Events record the channel their visitor came through; see migration
+-- 000050 for PostgreSQL.
+
+ALTER TABLE website_event ADD COLUMN channel TEXT;
@@ -0,0 +1,6 @@
+-- SQLite Migration 0010: Share link settings
+-- Share links may have a bcrypt password hash and an expiry; see migration
+-- 000052 for PostgreSQL.
+
+ALTER TABLE website ADD COLUMN share_password_hash TEXT;
+ALTER TABLE website ADD COLUMN share_expires_at DATETIME;
@@ -0,0 +1,9 @@
+-- SQLite Migration 0011: Server Secrets
+-- Random keys created on first use (cache token signing); see migration
+-- 000055 for PostgreSQL.
+
+CREATE TABLE IF NOT EXISTS server_secret (
+    name TEXT PRIMARY KEY,
+    secret BLOB NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,10 @@
+//go:build !(sqlite && cgo)
+
+package store
+
+// sqliteAvailable reports whether the SQLite driver is compiled in.
+// Default builds are CGO-free and PostgreSQL-only; build with -tags sqlite
+// and CGO_ENABLED=1 to enable the SQLite backend.
+const sqliteAvailable = false
+
+const sqliteDriverName = "sqlite3"
@@ -0,0 +1,254 @@
+//go:build sqlite && cgo
+
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func strPtr(s string) *string { return &s }
+
+func openTestSQLite(t *testing.T) *SQLite {
+	t.Helper()
+	s, err := OpenSQLite("sqlite://" + filepath.Join(t.TempDir(), "kaunta.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func TestSQLiteMigrateIsIdempotent(t *testing.T) {
+	s := openTestSQLite(t)
+	require.NoError(t, s.Migrate(context.Background()))
+
+	entries, err := sqliteMigrationFS.ReadDir("sqlite_migrations")
+	require.NoError(t, err)
+	var count int
+	require.NoError(t, s.DB().QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&count))
+	assert.Equal(t, len(entries), count)
+}
+
+func TestSQLiteTrackingAndDashboard(t *testing.T) {
+	ctx := context.Background()
+	s := openTestSQLite(t)
+
+	websiteID, err := s.CreateWebsite(ctx, "example.com", "Example", []string{"example.com"})
+	require.NoError(t, err)
+
+	settings, err := s.WebsiteSettings(ctx, websiteID)
+	require.NoError(t, err)
+	// referral_domains defaults to '[]', decoded to an empty list as on Postgres
+	assert.Equal(t, &WebsiteSettings{
+		ProxyMode: "none", BotFilter: true, RespectDNT: "off",
+		Domain: "example.com", AllowedDomains: []string{"example.com"}, ReferralDomains: []string{},
+	}, settings)
+
+	ok, err := s.ValidateOrigin(ctx, websiteID, "https://example.com")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = s.ValidateOrigin(ctx, websiteID, "https://evil.com")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = s.ValidateOrigin(ctx, uuid.New(), "https://example.com")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Two sessions: one bounces, one views two pages and converts
+	sessions := []uuid.UUID{uuid.New(), uuid.New()}
+	countries := []string{"US", "DE"}
+	screens := []string{"390x844", "1920x1080"}
+	asn := 16509
+	for i, id := range sessions {
+		session := &Session{
+			SessionID: id, WebsiteID: websiteID, Browser: strPtr("Chrome"), Country: strPtr(countries[i]),
+			Screen: strPtr(screens[i]),
+		}
+		if i == 1 {
+			session.ASN, session.ISP = &asn, strPtr("Amazon.com, Inc.")
+			session.Region, session.City = strPtr("Hesse"), strPtr("Frankfurt am Main")
+		}
+		require.NoError(t, s.UpsertSession(ctx, session))
+	}
+	// Upserting again is a no-op
+	require.NoError(t, s.UpsertSession(ctx, &Session{SessionID: sessions[0], WebsiteID: websiteID}))
+
+	now := time.Now()
+	insert := func(session uuid.UUID, path string, eventType int, name *string, campaign *string) {
+		channel := "Direct"
+		if campaign != nil {
+			channel = "Email"
+		}
+		require.NoError(t, s.InsertEvent(ctx, &Event{
+			EventID: uuid.New(), WebsiteID: websiteID, SessionID: session, VisitID: uuid.New(),
+			CreatedAt: now, URLPath: strPtr(path), EventType: eventType, EventName: name,
+			UTMCampaign: campaign, Props: []byte(`{"plan":"pro"}`), Channel: channel,
+		}))
+	}
+	insert(sessions[0], "/", 1, nil, nil)
+	insert(sessions[1], "/", 1, nil, strPtr("launch"))
+	insert(sessions[1], "/pricing", 1, nil, nil)
+	insert(sessions[1], "/pricing", 2, strPtr("signup"), nil)
+
+	stats, err := s.DashboardStats(ctx, websiteID, Filters{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stats.CurrentVisitors)
+	assert.Equal(t, int64(3), stats.TodayPageviews)
+	assert.Equal(t, int64(2), stats.TodayVisitors)
+	assert.Equal(t, 50.0, stats.BounceRate)
+
+	filtered, err := s.DashboardStats(ctx, websiteID, Filters{Country: "DE"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), filtered.TodayPageviews)
+
+	pages, total, err := s.TopPages(ctx, websiteID, 1, 10, 0, Filters{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	require.Len(t, pages, 2)
+	assert.Equal(t, int64(2), pages[0].Views)
+	require.NotNil(t, pages[0].BounceRate)
+	assert.Equal(t, 50.0, *pages[0].BounceRate, "one of the two visitors of / saw no other page")
+
+	countriesBreakdown, total, err := s.Breakdown(ctx, websiteID, "country", 1, 10, 0, Filters{Country: "US"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total, "breakdown ignores the filter on its own dimension")
+	assert.Equal(t, "DE", countriesBreakdown[0].Name)
+
+	networks, _, err := s.Breakdown(ctx, websiteID, "asn", 1, 10, 0, Filters{})
+	require.NoError(t, err)
+	require.Len(t, networks, 2)
+	assert.ElementsMatch(t, []string{"AS16509 Amazon.com, Inc.", "Unknown"}, []string{networks[0].Name, networks[1].Name})
+
+	screenBreakdown, _, err := s.Breakdown(ctx, websiteID, "screen", 1, 10, 0, Filters{})
+	require.NoError(t, err)
+	require.Len(t, screenBreakdown, 2)
+	assert.Equal(t, NamedCount{Name: "1920-2559px", Count: 2}, screenBreakdown[0])
+	assert.Equal(t, NamedCount{Name: "Under 576px", Count: 1}, screenBreakdown[1])
+	viewports, _, err := s.Breakdown(ctx, websiteID, "viewport", 1, 10, 0, Filters{})
+	require.NoError(t, err)
+	assert.Equal(t, []NamedCount{{Name: "Wide", Count: 2}, {Name: "Mobile", Count: 1}}, viewports)
+
+	channelBreakdown, _, err := s.Breakdown(ctx, websiteID, "channel", 1, 10, 0, Filters{})
+	require.NoError(t, err)
+	assert.Equal(t, []NamedCount{{Name: "Direct", Count: 2}, {Name: "Email", Count: 1}}, channelBreakdown)
+
+	cities, _, err := s.Breakdown(ctx, websiteID, "city", 1, 10, 0, Filters{Country: "DE"})
+	require.NoError(t, err)
+	require.Len(t, cities, 1)
+	assert.Equal(t, "Frankfurt am Main, Hesse, DE", cities[0].Name)
+
+	_, _, err = s.Breakdown(ctx, websiteID, "bogus", 1, 10, 0, Filters{})
+	assert.Error(t, err)
+
+	points, err := s.TimeSeries(ctx, websiteID, 7, IntervalHour, Filters{})
+	require.NoError(t, err)
+	require.Len(t, points, 1)
+	assert.Equal(t, int64(3), points[0].Views)
+
+	for _, interval := range []Interval{IntervalDay, IntervalWeek, IntervalMonth} {
+		points, err := s.TimeSeries(ctx, websiteID, 7, interval, Filters{})
+		require.NoError(t, err, interval)
+		require.Len(t, points, 1, interval)
+		assert.Equal(t, int64(3), points[0].Views, interval)
+		start, err := time.Parse(time.RFC3339, points[0].Timestamp)
+		require.NoError(t, err, interval)
+		assert.Zero(t, start.Hour(), interval)
+		if interval == IntervalWeek {
+			assert.Equal(t, time.Monday, start.Weekday())
+		}
+		if interval == IntervalMonth {
+			assert.Equal(t, 1, start.Day())
+		}
+	}
+
+	mapRows, err := s.MapData(ctx, websiteID, 7, Filters{})
+	require.NoError(t, err)
+	require.Len(t, mapRows, 2)
+	assert.Equal(t, 50.0, mapRows[0].Percentage)
+
+	utm, total, err := s.UTMBreakdown(ctx, websiteID, "campaign", 7, 10, 0, Goal{Event: "signup"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, UTMRow{Name: "launch", Visitors: 1, Pageviews: 2, Conversions: 1}, utm[0])
+
+	// Goal events must have been sent with the goal's property values
+	utm, _, err = s.UTMBreakdown(ctx, websiteID, "campaign", 7, 10, 0, Goal{Event: "signup", Props: map[string]string{"plan": "pro"}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), utm[0].Conversions)
+	utm, _, err = s.UTMBreakdown(ctx, websiteID, "campaign", 7, 10, 0, Goal{Props: map[string]string{"plan": "free"}})
+	require.NoError(t, err)
+	assert.Zero(t, utm[0].Conversions)
+
+	current, err := s.CurrentVisitors(ctx, websiteID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), current)
+
+	websites, total, err := s.ListWebsites(ctx, 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, "example.com", websites[0].Domain)
+}
+
+func TestSQLiteUsersAndSessions(t *testing.T) {
+	ctx := context.Background()
+	s := openTestSQLite(t)
+
+	user, err := s.CreateUser(ctx, "admin", "correct-horse", "Admin")
+	require.NoError(t, err)
+
+	found, err := s.FindUser(ctx, "admin")
+	require.NoError(t, err)
+	assert.Equal(t, user.UserID, found.UserID)
+
+	_, err = s.FindUser(ctx, "nobody")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	valid, err := s.VerifyPassword(ctx, "correct-horse", found.PasswordHash)
+	require.NoError(t, err)
+	assert.True(t, valid)
+	valid, err = s.VerifyPassword(ctx, "wrong", found.PasswordHash)
+	require.NoError(t, err)
+	assert.False(t, valid)
+
+	details, err := s.FindUserByID(ctx, user.UserID)
+	require.NoError(t, err)
+	assert.Equal(t, "Admin", details.Name.String)
+	assert.False(t, details.CreatedAt.IsZero())
+
+	sessionID := uuid.New()
+	require.NoError(t, s.CreateUserSession(ctx, &UserSession{
+		SessionID: sessionID, UserID: user.UserID, TokenHash: "hash",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}))
+
+	auth, err := s.ValidateUserSession(ctx, "hash")
+	require.NoError(t, err)
+	assert.Equal(t, "admin", auth.Username)
+	assert.Equal(t, sessionID, auth.SessionID)
+
+	require.NoError(t, s.DeleteUserSession(ctx, sessionID))
+	_, err = s.ValidateUserSession(ctx, "hash")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSQLiteServerSecret(t *testing.T) {
+	ctx := context.Background()
+	s := openTestSQLite(t)
+
+	key, err := s.ServerSecret(ctx, "umami_cache")
+	require.NoError(t, err)
+	assert.Len(t, key, 32)
+
+	// The same key for every later caller, another for another name
+	again, err := s.ServerSecret(ctx, "umami_cache")
+	require.NoError(t, err)
+	assert.Equal(t, key, again)
+	other, err := s.ServerSecret(ctx, "other")
+	require.NoError(t, err)
+	assert.NotEqual(t, key, other)
+}
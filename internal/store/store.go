@@ -0,0 +1,559 @@
+// Package store abstracts the analytics data layer behind a Store interface.
+//
+// The default backend is PostgreSQL, which delegates to the SQL functions
+// installed by the migrations in internal/database. A SQLite backend
+// (build tag "sqlite", requires cgo) implements the same queries in portable
+// SQL so tiny single-site deployments can run without an external database.
+// High-volume sites can move website_event to ClickHouse while keeping
+// websites and users in PostgreSQL.
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/exclusions"
+)
+
+// ErrNotFound is returned when a lookup matches no rows
+var ErrNotFound = sql.ErrNoRows
+
+// Filters narrows dashboard queries. Empty fields are ignored.
+type Filters struct {
+	Country string
+	Browser string
+	Device  string
+	Page    string
+
+	// Dimensions matches custom dimension values by name
+	Dimensions map[string]string
+
+	// Bot keeps only bot (true) or only human (false) traffic; nil keeps
+	// both. Bot events exist only for websites with bot filtering off.
+	Bot *bool
+
+	// From and To narrow a period of days to the events from From up to
+	// (excluding) To; the period must reach back to From
+	From time.Time
+	To   time.Time
+}
+
+// empty reports whether no filter is set
+func (f Filters) empty() bool {
+	return f.Country == "" && f.Browser == "" && f.Device == "" && f.Page == "" && len(f.Dimensions) == 0 && f.Bot == nil &&
+		f.From.IsZero() && f.To.IsZero()
+}
+
+// WebsiteSettings are the per-website options the tracking endpoint applies
+type WebsiteSettings struct {
+	ProxyMode string
+	// BotFilter drops detected bots; when off they are stored with Event.Bot
+	BotFilter bool
+	// RespectDNT is what happens to requests sent with DNT or Sec-GPC:
+	// "off", "drop" or "anonymize"
+	RespectDNT string
+
+	// Domain and AllowedDomains are the website's own domains. Unless
+	// KeepSelfReferrals is set, referrers from them (or their subdomains)
+	// are dropped, except from ReferralDomains.
+	Domain            string
+	AllowedDomains    []string
+	KeepSelfReferrals bool
+	ReferralDomains   []string
+
+	// PropsMaxBytes and PropsMaxDepth cap custom event properties; zero for
+	// the defaults (see eventprops)
+	PropsMaxBytes int
+	PropsMaxDepth int
+
+	// VisitDefinition ("hour" or "inactivity") and SessionTimeout split the
+	// website's sessions into visits; empty and zero for the configured
+	// rules (see visits)
+	VisitDefinition string
+	SessionTimeout  time.Duration
+}
+
+// SelfReferral reports whether a referrer host is one of the website's own
+// domains, whose referrals are dropped
+func (s *WebsiteSettings) SelfReferral(host string) bool {
+	if s.KeepSelfReferrals || host == "" {
+		return false
+	}
+	if domainMatch(strings.TrimPrefix(strings.ToLower(host), "www."), s.ReferralDomains) {
+		return false
+	}
+	return s.OwnDomain(host)
+}
+
+// OwnDomain reports whether a host is the website's domain, one of its
+// allowed domains or a subdomain of them
+func (s *WebsiteSettings) OwnDomain(host string) bool {
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	return host != "" && domainMatch(host, append([]string{s.Domain}, s.AllowedDomains...))
+}
+
+// domainMatch reports whether host is one of domains or a subdomain of one
+func domainMatch(host string, domains []string) bool {
+	for _, d := range domains {
+		d = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), "www.")
+		if d != "" && (host == d || strings.HasSuffix(host, "."+d)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Session is a visitor session as written by the tracking endpoint
+type Session struct {
+	SessionID  uuid.UUID
+	WebsiteID  uuid.UUID
+	Browser    *string
+	OS         *string
+	Device     *string
+	Screen     *string
+	Language   *string
+	Country    *string
+	Region     *string
+	City       *string
+	DistinctID *string
+
+	// ASN is the autonomous system of the visitor's IP and ISP its
+	// organization (nil without an ASN database)
+	ASN *int
+	ISP *string
+}
+
+// Event is a pageview (EventType 1) or custom event (EventType 2)
+type Event struct {
+	EventID        uuid.UUID
+	WebsiteID      uuid.UUID
+	SessionID      uuid.UUID
+	VisitID        uuid.UUID
+	CreatedAt      time.Time
+	PageTitle      *string
+	Hostname       *string
+	URLPath        *string
+	URLQuery       *string
+	ReferrerPath   *string
+	ReferrerQuery  *string
+	ReferrerDomain *string
+	EventName      *string
+	Tag            *string
+	EventType      int
+	ScrollDepth    *int
+	EngagementTime *int
+	Props          []byte // JSON document, possibly sealed by fieldcrypt
+	UTMSource      *string
+	UTMMedium      *string
+	UTMCampaign    *string
+	UTMContent     *string
+	UTMTerm        *string
+
+	// Dimensions holds the website's custom dimension values (see package
+	// dimensions)
+	Dimensions map[string]string
+
+	// Author is the byline of the page (pageviews only)
+	Author *string
+
+	// Channel is the channel the visitor came through (see package
+	// channels)
+	Channel string
+
+	// Bot marks traffic detected as a bot, kept because the website has bot
+	// filtering off
+	Bot bool
+
+	// SampleRate is N when the event stands for N events: under load,
+	// ingestion keeps one visitor in N (see ingest.Sampler). Zero and one
+	// both mean the event was not sampled.
+	SampleRate int
+
+	// Viewed is false for a pageview the tracker will confirm once it has
+	// been seen (see ConfirmView) and nil when the tracker doesn't confirm
+	Viewed *bool
+
+	// PageID and Seq number the event within the tracker's page instance
+	// (Seq 0 when the tracker sent none). PostgreSQL counts them in
+	// event_sequence for loss accounting; they are not stored on the event.
+	PageID uuid.UUID
+	Seq    int
+
+	// SpanContext is the span of the request that recorded the event, so
+	// batched writes can link back to it. Not persisted.
+	SpanContext trace.SpanContext
+
+	// Session dimensions, denormalized for backends without a session table
+	// join (ClickHouse). PostgreSQL and SQLite read them from session instead.
+	Browser *string
+	OS      *string
+	Device  *string
+	Country *string
+	Region  *string
+	City    *string
+	ASN     *int
+	ISP     *string
+	Screen  *string
+}
+
+// StoredSampleRate is the sample_rate column value of e: at least 1
+func (e *Event) StoredSampleRate() int {
+	return max(e.SampleRate, 1)
+}
+
+// DashboardStats holds the headline numbers for today
+type DashboardStats struct {
+	CurrentVisitors int64
+	TodayPageviews  int64
+	TodayVisitors   int64
+	BounceRate      float64
+}
+
+// PageRow is one row of the top pages report
+type PageRow struct {
+	Path           string
+	Views          int64
+	UniqueVisitors int64
+	AvgEngagement  *float64
+	// BounceRate is the percentage of the page's visitors who viewed no
+	// other page; nil when the source (rollups) doesn't track it
+	BounceRate *float64
+}
+
+// TimePoint is one bucket of the pageview time series
+type TimePoint struct {
+	Timestamp string
+	Views     int64
+}
+
+// Interval is the bucket size of a time series
+type Interval string
+
+// Time series intervals. Weeks start on Monday.
+const (
+	IntervalMinute Interval = "minute"
+	IntervalHour   Interval = "hour"
+	IntervalDay    Interval = "day"
+	IntervalWeek   Interval = "week"
+	IntervalMonth  Interval = "month"
+)
+
+// Intervals are the time series intervals, finest first
+var Intervals = []Interval{IntervalMinute, IntervalHour, IntervalDay, IntervalWeek, IntervalMonth}
+
+// ParseInterval reads an interval name; "" is hour
+func ParseInterval(s string) (Interval, error) {
+	if s == "" {
+		return IntervalHour, nil
+	}
+	for _, i := range Intervals {
+		if string(i) == s {
+			return i, nil
+		}
+	}
+	return "", fmt.Errorf("invalid interval: %s (use minute, hour, day, week or month)", s)
+}
+
+// MaxDays is the longest period a time series of the interval covers, so
+// a chart never has more than a few thousand points
+func (i Interval) MaxDays() int {
+	switch i {
+	case IntervalMinute:
+		return 1
+	case IntervalHour:
+		return 90
+	default:
+		return 366
+	}
+}
+
+// Rolling reports whether a time series of the interval is a rolling window
+// of days times 24 hours ending now; coarser intervals start at midnight
+// days ago, like the other period queries, so their first bucket is whole
+func (i Interval) Rolling() bool {
+	return i == IntervalMinute || i == IntervalHour
+}
+
+// NamedCount is one row of a dimension breakdown
+type NamedCount struct {
+	Name  string
+	Count int64
+}
+
+// MapRow is the visitor count for one country
+type MapRow struct {
+	Country    string
+	Visitors   int64
+	Percentage float64
+}
+
+// UTMRow is the attribution summary for one UTM value
+type UTMRow struct {
+	Name        string
+	Visitors    int64
+	Pageviews   int64
+	Conversions int64
+}
+
+// Goal is what UTMBreakdown counts as a conversion: a custom event (any when
+// Event is empty) sent with the property values in Props, matched as
+// eventprops.Filter does
+type Goal struct {
+	Event string
+	Props map[string]string
+}
+
+// WebsiteRow is a website as listed on the dashboard
+type WebsiteRow struct {
+	WebsiteID string
+	Domain    string
+	Name      *string
+}
+
+// Share is a website published under a share ID
+type Share struct {
+	Website WebsiteRow
+	// PasswordHash is the bcrypt hash of the share's password, empty when
+	// it has none
+	PasswordHash string
+	// ExpiresAt is when the share stops working, nil when it doesn't
+	ExpiresAt *time.Time
+}
+
+// User is a dashboard user account
+type User struct {
+	UserID       uuid.UUID
+	Username     string
+	Name         sql.NullString
+	PasswordHash string
+	CreatedAt    time.Time
+}
+
+// UserSession is a dashboard login session
+type UserSession struct {
+	SessionID uuid.UUID
+	UserID    uuid.UUID
+	TokenHash string
+	ExpiresAt time.Time
+	UserAgent string
+	IPAddress string
+}
+
+// AuthSession identifies the user behind a valid session token. API tokens
+// can carry their own rate limit and daily quota; nil uses the server setting.
+type AuthSession struct {
+	UserID     uuid.UUID
+	Username   string
+	SessionID  uuid.UUID
+	RateLimit  *int
+	DailyQuota *int
+	// APIToken is set for named API tokens, as opposed to login sessions
+	APIToken bool
+}
+
+// Store is the data layer used by the HTTP handlers
+type Store interface {
+	// Name identifies the backend ("postgres", "sqlite" or "clickhouse")
+	Name() string
+	Ping(ctx context.Context) error
+	Close() error
+
+	// Ingestion
+	WebsiteSettings(ctx context.Context, websiteID uuid.UUID) (*WebsiteSettings, error)
+	ValidateOrigin(ctx context.Context, websiteID uuid.UUID, origin string) (bool, error)
+	DetectBot(ctx context.Context, ip, userAgent string) (bool, error)
+	UpsertSession(ctx context.Context, s *Session) error
+	InsertEvent(ctx context.Context, e *Event) error
+	// InsertEvents writes a batch of events in as few round trips as possible
+	InsertEvents(ctx context.Context, events []*Event) error
+	// CustomDimensions lists the names of a website's custom dimensions
+	CustomDimensions(ctx context.Context, websiteID uuid.UUID) ([]string, error)
+	// ExclusionRules lists the rules dropping a website's internal traffic
+	ExclusionRules(ctx context.Context, websiteID uuid.UUID) ([]exclusions.Rule, error)
+	// ConfirmView marks the session's latest pending pageview of urlPath
+	// since the given time as viewed, reporting whether one was found
+	ConfirmView(ctx context.Context, websiteID, sessionID uuid.UUID, urlPath string, since time.Time) (bool, error)
+	// MarkRead marks the session's latest unread pageview of urlPath since
+	// the given time as read (and viewed), reporting whether one was found
+	MarkRead(ctx context.Context, websiteID, sessionID uuid.UUID, urlPath string, since time.Time) (bool, error)
+	// DailySalt returns the visitor hash salt of a UTC day, creating it on
+	// first use, and deletes the salts of earlier days
+	DailySalt(ctx context.Context, day time.Time) ([]byte, error)
+	// ServerSecret returns the random key of the given name shared by every
+	// server process, creating it on first use
+	ServerSecret(ctx context.Context, name string) ([]byte, error)
+	// RecordBaselineHit notes a baseline pixel visitor for blocker estimates
+	RecordBaselineHit(ctx context.Context, websiteID, sessionID uuid.UUID, at time.Time) error
+
+	// Dashboard reads
+	DashboardStats(ctx context.Context, websiteID uuid.UUID, f Filters) (*DashboardStats, error)
+	TopPages(ctx context.Context, websiteID uuid.UUID, days, limit, offset int, f Filters) ([]PageRow, int64, error)
+	TimeSeries(ctx context.Context, websiteID uuid.UUID, days int, interval Interval, f Filters) ([]TimePoint, error)
+	Breakdown(ctx context.Context, websiteID uuid.UUID, dimension string, days, limit, offset int, f Filters) ([]NamedCount, int64, error)
+	MapData(ctx context.Context, websiteID uuid.UUID, days int, f Filters) ([]MapRow, error)
+	UTMBreakdown(ctx context.Context, websiteID uuid.UUID, dimension string, days, limit, offset int, goal Goal) ([]UTMRow, int64, error)
+	CurrentVisitors(ctx context.Context, websiteID uuid.UUID) (int64, error)
+	ListWebsites(ctx context.Context, limit, offset int) ([]WebsiteRow, int64, error)
+	// FindWebsite and FindShare return sql.ErrNoRows for unknown websites
+	// and share IDs
+	FindWebsite(ctx context.Context, websiteID uuid.UUID) (*WebsiteRow, error)
+	FindShare(ctx context.Context, shareID string) (*Share, error)
+
+	// Websites and users
+	CreateWebsite(ctx context.Context, domain, name string, allowedDomains []string) (uuid.UUID, error)
+	CreateUser(ctx context.Context, username, password, name string) (*User, error)
+	FindUser(ctx context.Context, username string) (*User, error)
+	FindUserByID(ctx context.Context, userID uuid.UUID) (*User, error)
+	VerifyPassword(ctx context.Context, password, passwordHash string) (bool, error)
+
+	// Dashboard login sessions
+	CreateUserSession(ctx context.Context, s *UserSession) error
+	DeleteUserSession(ctx context.Context, sessionID uuid.UUID) error
+	ValidateUserSession(ctx context.Context, tokenHash string) (*AuthSession, error)
+}
+
+var (
+	currentMu sync.RWMutex
+	current   Store = NewPostgres()
+)
+
+// Current returns the process-wide store (PostgreSQL unless SetCurrent was called)
+func Current() Store {
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	return current
+}
+
+// SetCurrent replaces the process-wide store
+func SetCurrent(s Store) {
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	current = s
+}
+
+// Open returns the store for a database URL. SQLite URLs (sqlite: or file:)
+// open and migrate a SQLite database; anything else connects to PostgreSQL.
+func Open(databaseURL string) (Store, error) {
+	if database.IsSQLiteURL(databaseURL) {
+		return OpenSQLite(databaseURL)
+	}
+
+	if err := database.ConnectWithURL(databaseURL); err != nil {
+		return nil, err
+	}
+	return NewPostgres(), nil
+}
+
+// nullable converts an empty filter value to SQL NULL
+// newSalt returns 32 random bytes for DailySalt and ServerSecret
+func newSalt() ([]byte, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return salt, nil
+}
+
+func nullable(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// nullableTime passes a zero time as SQL NULL
+func nullableTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+// nullableJSON encodes custom dimension values as a JSON object, or SQL NULL
+// when there are none
+func nullableJSON(values map[string]string) interface{} {
+	if len(values) == 0 {
+		return nil
+	}
+	encoded, _ := json.Marshal(values)
+	return encoded
+}
+
+// validDimensions lists the breakdown dimensions accepted by Breakdown
+var validDimensions = map[string]bool{
+	"country":  true,
+	"browser":  true,
+	"device":   true,
+	"referrer": true,
+	"city":     true,
+	"region":   true,
+	"page":     true,
+	"asn":      true,
+	"screen":   true,
+	"viewport": true,
+	"channel":  true,
+}
+
+// validUTMDimensions lists the utm_* parameters accepted by UTMBreakdown
+var validUTMDimensions = map[string]bool{
+	"source":   true,
+	"medium":   true,
+	"campaign": true,
+	"content":  true,
+	"term":     true,
+}
+
+// IsBreakdownDimension reports whether dimension is a built-in dimension
+// accepted by Breakdown (custom dimensions aside)
+func IsBreakdownDimension(dimension string) bool {
+	return validDimensions[dimension]
+}
+
+func checkDimension(dimension string) error {
+	if !validDimensions[dimension] {
+		return fmt.Errorf("invalid dimension: %s", dimension)
+	}
+	return nil
+}
+
+// widthBucket names the screen widths below Below (0 for the last bucket)
+type widthBucket struct {
+	Below int
+	Label string
+}
+
+// screenBuckets and viewportClasses group sessions by screen width, like
+// screen_bucket() and viewport_class() on PostgreSQL
+var (
+	screenBuckets = []widthBucket{
+		{576, "Under 576px"}, {768, "576-767px"}, {992, "768-991px"}, {1200, "992-1199px"},
+		{1440, "1200-1439px"}, {1920, "1440-1919px"}, {2560, "1920-2559px"}, {0, "2560px and up"},
+	}
+	viewportClasses = []widthBucket{{768, "Mobile"}, {1024, "Tablet"}, {1920, "Desktop"}, {0, "Wide"}}
+)
+
+// widthCase builds a CASE expression labelling the width expression by
+// bucket, NULL when the width is
+func widthCase(width string, buckets []widthBucket) string {
+	var b strings.Builder
+	b.WriteString("CASE WHEN " + width + " IS NULL THEN NULL")
+	for _, bucket := range buckets {
+		if bucket.Below == 0 {
+			b.WriteString(" ELSE '" + bucket.Label + "'")
+			continue
+		}
+		fmt.Fprintf(&b, " WHEN %s < %d THEN '%s'", width, bucket.Below, bucket.Label)
+	}
+	b.WriteString(" END")
+	return b.String()
+}
@@ -0,0 +1,327 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/database"
+)
+
+func TestIsBotUserAgent(t *testing.T) {
+	bots := []string{
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+		"Mozilla/5.0 AppleWebKit/537.36 (KHTML, like Gecko; compatible; GPTBot/1.0)",
+		"python-requests/2.31.0",
+		"Go-http-client/1.1",
+		"Java/17.0.2",
+		"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 HeadlessChrome/120.0",
+	}
+	for _, ua := range bots {
+		assert.True(t, IsBotUserAgent(ua), ua)
+	}
+
+	humans := []string{
+		"",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36",
+	}
+	for _, ua := range humans {
+		assert.False(t, IsBotUserAgent(ua), ua)
+	}
+}
+
+func TestOriginAllowed(t *testing.T) {
+	allowed := []byte(`["example.com", "www.example.com"]`)
+
+	assert.True(t, originAllowed("", allowed))
+	assert.True(t, originAllowed("null", allowed))
+	assert.True(t, originAllowed("https://example.com", allowed))
+	assert.True(t, originAllowed("http://www.example.com:8080", allowed))
+	assert.False(t, originAllowed("https://evil.com", allowed))
+	assert.False(t, originAllowed("https://example.com", []byte(`[]`)))
+	assert.False(t, originAllowed("https://example.com", []byte(`not json`)))
+}
+
+func TestSQLitePath(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"sqlite:///var/lib/kaunta/kaunta.db", "/var/lib/kaunta/kaunta.db"},
+		{"sqlite://./data/kaunta.db", "./data/kaunta.db"},
+		{"sqlite:kaunta.db", "kaunta.db"},
+		{"file:kaunta.db?cache=shared", "kaunta.db"},
+	}
+	for _, tt := range tests {
+		got, err := SQLitePath(tt.url)
+		require.NoError(t, err, tt.url)
+		assert.Equal(t, tt.want, got, tt.url)
+	}
+
+	_, err := SQLitePath("sqlite://")
+	assert.Error(t, err)
+	_, err = SQLitePath("postgres://localhost/kaunta")
+	assert.Error(t, err)
+}
+
+func TestCurrentDefaultsToPostgres(t *testing.T) {
+	assert.Equal(t, "postgres", Current().Name())
+}
+
+func withMockDB(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	original := database.DB
+	database.DB = mockDB
+	t.Cleanup(func() {
+		database.DB = original
+		_ = mockDB.Close()
+	})
+	return mock
+}
+
+func TestSelfReferral(t *testing.T) {
+	settings := WebsiteSettings{
+		Domain:          "example.com",
+		AllowedDomains:  []string{"shop.example.net"},
+		ReferralDomains: []string{"blog.example.com"},
+	}
+
+	assert.True(t, settings.SelfReferral("example.com"))
+	assert.True(t, settings.SelfReferral("www.example.com"))
+	assert.True(t, settings.SelfReferral("docs.example.com"))
+	assert.True(t, settings.SelfReferral("Shop.Example.NET"))
+	assert.False(t, settings.SelfReferral("blog.example.com"), "referral domains stay referrers")
+	assert.False(t, settings.SelfReferral("google.com"))
+	assert.False(t, settings.SelfReferral("notexample.com"))
+	assert.False(t, settings.SelfReferral(""))
+
+	settings.KeepSelfReferrals = true
+	assert.False(t, settings.SelfReferral("example.com"))
+}
+
+func TestOwnDomain(t *testing.T) {
+	settings := WebsiteSettings{Domain: "example.com", AllowedDomains: []string{"shop.example.net"}, KeepSelfReferrals: true}
+
+	assert.True(t, settings.OwnDomain("www.example.com"))
+	assert.True(t, settings.OwnDomain("shop.example.net"))
+	assert.False(t, settings.OwnDomain("example.com.evil.net"))
+	assert.False(t, settings.OwnDomain(""))
+}
+
+func TestPostgresWebsiteSettings(t *testing.T) {
+	mock := withMockDB(t)
+	websiteID := uuid.New()
+	mock.ExpectQuery("FROM website WHERE website_id").WithArgs(websiteID).
+		WillReturnRows(sqlmock.NewRows([]string{"proxy_mode", "bot_filter", "respect_dnt", "domain",
+			"allowed_domains", "exclude_self_referrals", "referral_domains", "props_max_bytes", "props_max_depth", "visit_definition", "session_timeout"}).
+			AddRow("none", true, "off", "example.com", []byte(`["example.org"]`), false, []byte(`["blog.example.com"]`), 2048, 0, "inactivity", 900))
+
+	settings, err := NewPostgres().WebsiteSettings(context.Background(), websiteID)
+	require.NoError(t, err)
+	assert.Equal(t, "example.com", settings.Domain)
+	assert.Equal(t, []string{"example.org"}, settings.AllowedDomains)
+	assert.Equal(t, []string{"blog.example.com"}, settings.ReferralDomains)
+	assert.True(t, settings.KeepSelfReferrals)
+	assert.Equal(t, 2048, settings.PropsMaxBytes)
+	assert.Zero(t, settings.PropsMaxDepth)
+	assert.Equal(t, "inactivity", settings.VisitDefinition)
+	assert.Equal(t, 15*time.Minute, settings.SessionTimeout)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresDetectBotHandlesNull(t *testing.T) {
+	mock := withMockDB(t)
+	mock.ExpectQuery("SELECT update_ip_metadata").
+		WithArgs("203.0.113.9", "curl/8.0").
+		WillReturnRows(sqlmock.NewRows([]string{"update_ip_metadata"}).AddRow(nil))
+
+	isBot, err := NewPostgres().DetectBot(context.Background(), "203.0.113.9", "curl/8.0")
+	require.NoError(t, err)
+	assert.False(t, isBot)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresDashboardStatsPassesNullFilters(t *testing.T) {
+	mock := withMockDB(t)
+	websiteID := uuid.New()
+
+	mock.ExpectQuery(`SELECT \* FROM get_dashboard_stats`).
+		WithArgs(websiteID, "US", nil, nil, nil, nil, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"a", "b", "c", "d"}).AddRow(2, 10, 4, 25.0))
+
+	stats, err := NewPostgres().DashboardStats(context.Background(), websiteID, Filters{Country: "US"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), stats.TodayPageviews)
+	assert.Equal(t, 25.0, stats.BounceRate)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresTopPagesPassesDimensionFilter(t *testing.T) {
+	mock := withMockDB(t)
+	websiteID := uuid.New()
+
+	mock.ExpectQuery(`SELECT \* FROM get_top_pages`).
+		WithArgs(websiteID, 7, 10, 0, nil, nil, nil, []byte(`{"content_type":"guide"}`), nil, nil, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"path", "views", "unique", "avg", "bounce", "total"}))
+
+	_, _, err := NewPostgres().TopPages(context.Background(), websiteID, 7, 10, 0,
+		Filters{Dimensions: map[string]string{"content_type": "guide"}})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresTimeSeriesPassesRange(t *testing.T) {
+	mock := withMockDB(t)
+	websiteID := uuid.New()
+	from := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 15)
+
+	mock.ExpectQuery(`SELECT \* FROM get_timeseries`).
+		WithArgs(websiteID, 30, nil, nil, nil, nil, nil, nil, from, to, "day").
+		WillReturnRows(sqlmock.NewRows([]string{"hour", "views"}))
+
+	_, err := NewPostgres().TimeSeries(context.Background(), websiteID, 30, IntervalDay, Filters{From: from, To: to})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestParseInterval(t *testing.T) {
+	interval, err := ParseInterval("")
+	require.NoError(t, err)
+	assert.Equal(t, IntervalHour, interval)
+
+	interval, err = ParseInterval("week")
+	require.NoError(t, err)
+	assert.Equal(t, IntervalWeek, interval)
+	assert.Equal(t, 366, interval.MaxDays())
+	assert.False(t, interval.Rolling())
+
+	assert.Equal(t, 1, IntervalMinute.MaxDays())
+	assert.True(t, IntervalMinute.Rolling())
+
+	_, err = ParseInterval("year")
+	assert.EqualError(t, err, "invalid interval: year (use minute, hour, day, week or month)")
+}
+
+func TestPostgresPingWithoutConnection(t *testing.T) {
+	original := database.DB
+	database.DB = nil
+	t.Cleanup(func() { database.DB = original })
+
+	assert.Error(t, NewPostgres().Ping(context.Background()))
+}
+
+func TestPostgresInsertEventsUsesOneMultiRowInsert(t *testing.T) {
+	mock := withMockDB(t)
+	events := []*Event{
+		{EventID: uuid.New(), WebsiteID: uuid.New(), EventType: 1},
+		{EventID: uuid.New(), WebsiteID: uuid.New(), EventType: 2},
+	}
+
+	mock.ExpectExec(`INSERT INTO website_event .* VALUES \(\$1, .*\$29\), \(\$30, .*\$58\)$`).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	require.NoError(t, NewPostgres().InsertEvents(context.Background(), events))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresInsertEventsRecordsSequences(t *testing.T) {
+	mock := withMockDB(t)
+	websiteID, pageID := uuid.New(), uuid.New()
+	createdAt := time.Date(2025, 6, 1, 23, 0, 0, 0, time.UTC)
+	events := []*Event{
+		{EventID: uuid.New(), WebsiteID: websiteID, PageID: pageID, Seq: 1, CreatedAt: createdAt, EventType: 1},
+		{EventID: uuid.New(), WebsiteID: websiteID, PageID: pageID, Seq: 3, CreatedAt: createdAt, EventType: 2},
+		{EventID: uuid.New(), WebsiteID: websiteID, CreatedAt: createdAt, EventType: 1},
+	}
+
+	mock.ExpectExec(`INSERT INTO website_event`).WillReturnResult(sqlmock.NewResult(0, 3))
+	// Both sequenced events of the page collapse into one upsert row
+	mock.ExpectExec(`INSERT INTO event_sequence .* VALUES \(\$1, \$2, \$3, \$4, \$5\)\s+ON CONFLICT`).
+		WithArgs(websiteID, pageID, "2025-06-01", 2, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, NewPostgres().InsertEvents(context.Background(), events))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresInsertEventsIgnoresSequenceFailures(t *testing.T) {
+	mock := withMockDB(t)
+	events := []*Event{{EventID: uuid.New(), WebsiteID: uuid.New(), PageID: uuid.New(), Seq: 1, EventType: 1}}
+
+	mock.ExpectExec(`INSERT INTO website_event`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO event_sequence`).WillReturnError(assert.AnError)
+
+	require.NoError(t, NewPostgres().InsertEvents(context.Background(), events))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresBreakdownUsesRollupsForLongRanges(t *testing.T) {
+	mock := withMockDB(t)
+	websiteID := uuid.New()
+
+	mock.ExpectQuery(`FROM rollup_state`).
+		WillReturnRows(sqlmock.NewRows([]string{"covered_since", "refreshed_at"}).AddRow(time.Now().AddDate(-1, 0, 0), time.Now()))
+	mock.ExpectQuery(`FROM rollup_daily_dimension`).
+		WithArgs(websiteID, "referrer", 10, 0, "Direct / None", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "count", "total"}).AddRow("example.com", 42, 3))
+
+	items, total, err := NewPostgres().Breakdown(context.Background(), websiteID, "referrer", 90, 10, 0, Filters{})
+	require.NoError(t, err)
+	assert.Equal(t, []NamedCount{{Name: "example.com", Count: 42}}, items)
+	assert.Equal(t, int64(3), total)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// Filtered queries always read raw events
+	mock.ExpectQuery(`SELECT \* FROM get_breakdown`).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "count", "total"}))
+	_, _, err = NewPostgres().Breakdown(context.Background(), websiteID, "referrer", 90, 10, 0, Filters{Country: "US"})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresConfirmView(t *testing.T) {
+	mock := withMockDB(t)
+	websiteID, sessionID := uuid.New(), uuid.New()
+	since := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec(`UPDATE website_event SET viewed = TRUE\s+WHERE \(event_id, created_at\) = \(.*viewed = FALSE`).
+		WithArgs(websiteID, sessionID, "/pricing", since).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE website_event SET viewed = TRUE`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	confirmed, err := NewPostgres().ConfirmView(context.Background(), websiteID, sessionID, "/pricing", since)
+	require.NoError(t, err)
+	assert.True(t, confirmed)
+
+	// Already confirmed, or never sent as pending
+	confirmed, err = NewPostgres().ConfirmView(context.Background(), websiteID, sessionID, "/pricing", since)
+	require.NoError(t, err)
+	assert.False(t, confirmed)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresMarkRead(t *testing.T) {
+	mock := withMockDB(t)
+	websiteID, sessionID := uuid.New(), uuid.New()
+	since := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec(`UPDATE website_event SET read = TRUE, viewed = viewed OR viewed IS NOT NULL\s+WHERE .*read IS NULL`).
+		WithArgs(websiteID, sessionID, "/blog/post", since).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	read, err := NewPostgres().MarkRead(context.Background(), websiteID, sessionID, "/blog/post", since)
+	require.NoError(t, err)
+	assert.True(t, read)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,239 @@
+// Package telemetry reports anonymous usage of a Kaunta instance to the
+// project, only when its operator opts in (`telemetry = true`), so the
+// maintainers learn which subsystems are used.
+//
+// A report holds the release and platform, the event store, the optional
+// subsystems that are on, and the number of websites and of events recorded
+// in the last 30 days, each rounded to a bucket. It never holds domains,
+// URLs, IPs, user names or visitor counts. The instance ID is random and
+// kept in the data directory; it only tells reports of one instance apart.
+//
+// Reports are built locally: `kaunta telemetry preview` prints one exactly
+// as it would be sent, whether or not telemetry is on, and the last report
+// sent is kept next to the instance ID for operators to audit.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/seuros/kaunta/internal/buildinfo"
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/features"
+	"github.com/seuros/kaunta/internal/offline"
+)
+
+// Interval is how often a report is sent
+const Interval = 7 * 24 * time.Hour
+
+// ErrNoEndpoint is returned by Send when telemetry_endpoint isn't set
+var ErrNoEndpoint = errors.New("telemetry_endpoint is not set")
+
+// Files of the telemetry directory, under the data directory
+const (
+	dirName        = "telemetry"
+	instanceIDFile = "instance-id"
+	lastReportFile = "last-report.json"
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Report is what is sent
+type Report struct {
+	InstanceID string `json:"instance_id"`
+	Version    string `json:"version"`
+	GoVersion  string `json:"go_version"`
+	OS         string `json:"os"`
+	Arch       string `json:"arch"`
+	EventStore string `json:"event_store"`
+	// Websites and Events (in the last 30 days) are buckets, see Bucket
+	Websites string `json:"websites"`
+	Events   string `json:"events"`
+	// Features are the optional subsystems that are on, sorted
+	Features []string `json:"features"`
+}
+
+// Collect builds the report of the instance. Without a database the
+// website and event buckets are "unknown".
+func Collect(ctx context.Context, db *sql.DB, cfg *config.Config, info buildinfo.Info, instanceID string) (*Report, error) {
+	r := &Report{
+		InstanceID: instanceID,
+		Version:    info.Version,
+		GoVersion:  info.GoVersion,
+		OS:         info.OS,
+		Arch:       info.Arch,
+		EventStore: cfg.EventStore,
+		Websites:   "unknown",
+		Events:     "unknown",
+		Features:   Subsystems(cfg, info),
+	}
+	if r.Version == "" {
+		r.Version = "dev"
+	}
+	if db == nil {
+		return r, nil
+	}
+
+	var websites, events int64
+	err := db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM website WHERE deleted_at IS NULL),
+			(SELECT COALESCE(SUM(pageviews + events), 0) FROM rollup_daily WHERE day >= CURRENT_DATE - 30)
+	`).Scan(&websites, &events)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count usage: %w", err)
+	}
+	r.Websites, r.Events = Bucket(websites), Bucket(events)
+
+	states, err := features.Resolve(ctx, db, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range states {
+		if s.Enabled {
+			r.Features = append(r.Features, "flag:"+s.Name)
+		}
+	}
+	sort.Strings(r.Features)
+	return r, nil
+}
+
+// Subsystems lists the optional subsystems cfg and the build switch on
+func Subsystems(cfg *config.Config, info buildinfo.Info) []string {
+	on := map[string]bool{
+		"encryption":        cfg.EncryptionKey != "" || cfg.EncryptionKeyFile != "",
+		"tracing":           cfg.TracingEndpoint != "",
+		"metrics":           cfg.Metrics,
+		"adaptive_sampling": cfg.AdaptiveSampling,
+		"embedded_jobs":     cfg.EmbeddedJobs,
+		"retention":         cfg.RetentionDays > 0,
+		"smtp":              cfg.SMTP.Host != "",
+		"chat_notify":       cfg.Notify.SlackWebhookURL != "" || cfg.Notify.DiscordWebhookURL != "",
+		"daily_summary":     cfg.Notify.DailySummary,
+		"api_cors":          len(cfg.APICORSOrigins) > 0,
+		"cardinality_cap":   cfg.CardinalityCap > 0,
+	}
+	if cfg.Storage.Backend != "" && cfg.Storage.Backend != "local" {
+		on["storage:"+cfg.Storage.Backend] = true
+	}
+	if cfg.IPMode != "" && cfg.IPMode != "full" {
+		on["ip_mode:"+cfg.IPMode] = true
+	}
+	if cfg.GeoIPProvider != "" {
+		on["geoip:"+cfg.GeoIPProvider] = true
+	}
+	for name, built := range info.Features {
+		on["build:"+name] = built
+	}
+
+	names := []string{}
+	for name, enabled := range on {
+		if enabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Bucket rounds a count down to a power of ten: "0", "1+", "10+", "100+",
+// "1k+", "10k+" and so on up to "1B+"
+func Bucket(n int64) string {
+	if n <= 0 {
+		return "0"
+	}
+	labels := []string{"1+", "10+", "100+", "1k+", "10k+", "100k+", "1M+", "10M+", "100M+", "1B+"}
+	i := 0
+	for limit := int64(10); n >= limit && i < len(labels)-1; limit *= 10 {
+		i++
+	}
+	return labels[i]
+}
+
+// InstanceID returns the instance's random ID, creating it in dataDir on
+// first use
+func InstanceID(dataDir string) (string, error) {
+	path := filepath.Join(dataDir, dirName, instanceIDFile)
+	if data, err := os.ReadFile(path); err == nil {
+		if id := strings.TrimSpace(string(data)); id != "" {
+			return id, nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to read instance ID: %w", err)
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate instance ID: %w", err)
+	}
+	id := hex.EncodeToString(b)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(id+"\n"), 0o644); err != nil {
+		return "", fmt.Errorf("failed to save instance ID: %w", err)
+	}
+	return id, nil
+}
+
+// Due reports whether a report should be sent: none was sent from dataDir
+// in the last Interval
+func Due(dataDir string, now time.Time) bool {
+	stat, err := os.Stat(filepath.Join(dataDir, dirName, lastReportFile))
+	return err != nil || now.Sub(stat.ModTime()) >= Interval
+}
+
+// LastReportPath is where the last report sent is kept
+func LastReportPath(dataDir string) string {
+	return filepath.Join(dataDir, dirName, lastReportFile)
+}
+
+// Send posts r to endpoint and keeps a copy in dataDir
+func Send(ctx context.Context, endpoint, dataDir string, r *Report) error {
+	if endpoint == "" {
+		return ErrNoEndpoint
+	}
+	if err := offline.Check("telemetry", endpoint); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Kaunta-Telemetry")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send telemetry: %w", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("telemetry endpoint answered %s", resp.Status)
+	}
+
+	path := LastReportPath(dataDir)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to keep sent report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seuros/kaunta/internal/buildinfo"
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/offline"
+)
+
+func TestBucket(t *testing.T) {
+	tests := map[int64]string{
+		0: "0", 1: "1+", 9: "1+", 10: "10+", 999: "100+", 1000: "1k+",
+		54321: "10k+", 2_500_000: "1M+", 7_000_000_000: "1B+", 900_000_000_000: "1B+",
+	}
+	for n, want := range tests {
+		assert.Equal(t, want, Bucket(n), "Bucket(%d)", n)
+	}
+}
+
+func TestSubsystems(t *testing.T) {
+	cfg := &config.Config{
+		EncryptionKeyFile: "/run/secrets/key",
+		EmbeddedJobs:      true,
+		IPMode:            "hash",
+		Storage:           config.StorageConfig{Backend: "s3", Bucket: "private-bucket"},
+		Notify:            config.NotifyConfig{SlackWebhookURL: "https://hooks.slack.com/secret"},
+	}
+	info := buildinfo.Info{Features: map[string]bool{"geoip": true, "sqlite": false}}
+
+	assert.Equal(t, []string{"build:geoip", "chat_notify", "embedded_jobs", "encryption", "ip_mode:hash", "storage:s3"},
+		Subsystems(cfg, info))
+}
+
+func TestCollect(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectQuery(`FROM website WHERE deleted_at IS NULL`).
+		WillReturnRows(sqlmock.NewRows([]string{"websites", "events"}).AddRow(3, 48213))
+	mock.ExpectQuery(`FROM feature_flag`).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "website_id", "enabled"}))
+
+	cfg := &config.Config{EventStore: "postgres", Metrics: true}
+	info := buildinfo.Info{GoVersion: "go1.25.0", OS: "linux", Arch: "amd64"}
+	r, err := Collect(context.Background(), db, cfg, info, "abc123")
+	require.NoError(t, err)
+
+	assert.Equal(t, &Report{
+		InstanceID: "abc123",
+		Version:    "dev",
+		GoVersion:  "go1.25.0",
+		OS:         "linux",
+		Arch:       "amd64",
+		EventStore: "postgres",
+		Websites:   "1+",
+		Events:     "10k+",
+		Features:   []string{"flag:sessions", "metrics"},
+	}, r)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInstanceIDIsKept(t *testing.T) {
+	dir := t.TempDir()
+	id, err := InstanceID(dir)
+	require.NoError(t, err)
+	assert.Len(t, id, 32)
+
+	again, err := InstanceID(dir)
+	require.NoError(t, err)
+	assert.Equal(t, id, again)
+}
+
+func TestSend(t *testing.T) {
+	var got Report
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Kaunta-Telemetry", r.Header.Get("User-Agent"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	now := time.Now()
+	assert.True(t, Due(dir, now))
+
+	report := &Report{InstanceID: "abc123", Version: "1.0.0", Websites: "10+", Features: []string{}}
+	require.NoError(t, Send(context.Background(), server.URL, dir, report))
+	assert.Equal(t, *report, got)
+
+	kept, err := os.ReadFile(LastReportPath(dir))
+	require.NoError(t, err)
+	assert.Contains(t, string(kept), `"instance_id": "abc123"`)
+	assert.False(t, Due(dir, now), "a report was just sent")
+	assert.True(t, Due(dir, now.Add(Interval+time.Minute)))
+
+	assert.ErrorIs(t, Send(context.Background(), "", dir, report), ErrNoEndpoint)
+	offline.Set(true)
+	defer offline.Set(false)
+	assert.ErrorIs(t, Send(context.Background(), server.URL, dir, report), offline.ErrOffline)
+}
@@ -0,0 +1,148 @@
+package tracing
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxStatementLength caps the db.query.text attribute; the dashboard
+// functions are called with short statements, ad-hoc reports can be long
+const maxStatementLength = 2048
+
+// WrapConnector returns a connector whose connections record a client span
+// for every query and exec. Only statements issued under a span are traced
+// (an HTTP request, the ingest flush, ...), so background loops don't start
+// traces of their own. Spans carry the SQL text but never its arguments.
+//
+// A query span ends when the database starts returning rows; reading them is
+// not included.
+func WrapConnector(c driver.Connector, system string) driver.Connector {
+	return &tracedConnector{Connector: c, system: system}
+}
+
+type tracedConnector struct {
+	driver.Connector
+	system string
+}
+
+func (c *tracedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{Conn: conn, system: c.system}, nil
+}
+
+// tracedConn forwards to the driver's connection, falling back the way
+// database/sql would when the driver lacks an optional interface
+type tracedConn struct {
+	driver.Conn
+	system string
+}
+
+func (c *tracedConn) startQuery(ctx context.Context, query string) (context.Context, trace.Span, bool) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, nil, false
+	}
+	text := query
+	if len(text) > maxStatementLength {
+		text = text[:maxStatementLength]
+	}
+	ctx, span := Start(ctx, "db."+operation(query),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", c.system),
+			attribute.String("db.operation.name", operation(query)),
+			attribute.String("db.query.text", text),
+		))
+	return ctx, span, true
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, span, traced := c.startQuery(ctx, query)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if traced {
+		End(span, skipErr(err))
+	}
+	return rows, err
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, span, traced := c.startQuery(ctx, query)
+	result, err := execer.ExecContext(ctx, query, args)
+	if traced {
+		if err == nil {
+			if n, rowsErr := result.RowsAffected(); rowsErr == nil {
+				span.SetAttributes(attribute.Int64("db.response.affected_rows", n))
+			}
+		}
+		End(span, skipErr(err))
+	}
+	return result, err
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Prepare(query)
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Begin() //nolint:staticcheck // drivers without BeginTx only have Begin
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// skipErr hides driver.ErrSkip, which only tells database/sql to retry
+// another way
+func skipErr(err error) error {
+	if err == driver.ErrSkip {
+		return nil
+	}
+	return err
+}
+
+// operation returns the statement's leading keyword (select, insert, with,
+// ...) for the span name
+func operation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "query"
+	}
+	return strings.ToLower(fields[0])
+}
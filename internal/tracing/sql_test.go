@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// fakeConn answers every query with no rows and fails execs on "bad"
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (fakeConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return fakeRows{}, nil
+}
+
+func (fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if query == "bad" {
+		return nil, errors.New("syntax error")
+	}
+	return driver.RowsAffected(3), nil
+}
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string         { return []string{"n"} }
+func (fakeRows) Close() error              { return nil }
+func (fakeRows) Next([]driver.Value) error { return io.EOF }
+
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                        { return nil }
+
+func TestWrapConnectorTracesStatements(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	original := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	t.Cleanup(func() { otel.SetTracerProvider(original) })
+
+	db := sql.OpenDB(WrapConnector(fakeConnector{}, "postgresql"))
+	defer func() { _ = db.Close() }()
+
+	// Without a span in the context nothing is recorded
+	_, err := db.ExecContext(context.Background(), "DELETE FROM session")
+	require.NoError(t, err)
+	assert.Empty(t, spans.Ended())
+
+	ctx, parent := Start(context.Background(), "request")
+	rows, err := db.QueryContext(ctx, "SELECT * FROM get_dashboard_stats($1)", 1)
+	require.NoError(t, err)
+	_ = rows.Close()
+	_, err = db.ExecContext(ctx, "bad")
+	require.Error(t, err)
+	parent.End()
+
+	ended := spans.Ended()
+	require.Len(t, ended, 3)
+
+	query := ended[0]
+	assert.Equal(t, "db.select", query.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), query.Parent().SpanID())
+	assert.Contains(t, query.Attributes(), attribute.String("db.query.text", "SELECT * FROM get_dashboard_stats($1)"))
+	assert.Contains(t, query.Attributes(), attribute.String("db.system", "postgresql"))
+
+	assert.Equal(t, "db.bad", ended[1].Name())
+	assert.Equal(t, codes.Error, ended[1].Status().Code)
+}
@@ -0,0 +1,141 @@
+// Package tracing wires OpenTelemetry tracing for the HTTP server, database
+// queries and the ingestion pipeline.
+//
+// Every request gets a server span (middleware.Tracing) and the PostgreSQL
+// queries it runs become child spans (WrapConnector), so a slow dashboard
+// call shows which query took the time. A pageview can carry a W3C traceparent from the tracker (data-trace="true")
+// through HandleTracking, the store calls it makes and the batched write that
+// finally persists it. Batch writes serve many requests at once, so the flush
+// span links to every originating request span instead of having a parent.
+//
+// Without an OTLP endpoint the global no-op provider stays in place and spans
+// cost next to nothing; traceparent values are still parsed so trace IDs show
+// up in debug logs.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies Kaunta's spans
+const instrumentationName = "github.com/seuros/kaunta"
+
+// Config selects the exporter and sampling
+type Config struct {
+	Endpoint    string  // OTLP/HTTP endpoint URL; empty disables export
+	SampleRatio float64 // share of untraced requests to sample (0-1)
+	Version     string  // reported as service.version
+
+	// Headers are sent with every export, e.g. a collector API key
+	Headers map[string]string
+}
+
+var propagator = propagation.TraceContext{}
+
+func init() {
+	otel.SetTextMapPropagator(propagator)
+}
+
+// Init installs the global tracer provider. The returned function flushes
+// and stops the exporter; it is safe to call when tracing is disabled.
+//
+// Sampling is parent-based: a request whose traceparent is marked sampled is
+// always traced, others are sampled at SampleRatio.
+func Init(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	if cfg.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return nil, fmt.Errorf("tracing sample ratio must be between 0 and 1, got %v", cfg.SampleRatio)
+	}
+
+	endpoint, err := traceURL(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpointURL(endpoint)}
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	attrs := []attribute.KeyValue{attribute.String("service.name", "kaunta")}
+	if cfg.Version != "" {
+		attrs = append(attrs, attribute.String("service.version", cfg.Version))
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attrs...)),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// traceURL accepts a collector base URL (http://collector:4318) or a full
+// traces URL and returns the latter
+func traceURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid tracing endpoint %q: expected http(s)://host[:port][/path]", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+	return u.String(), nil
+}
+
+// Start begins a span as a child of the span in ctx
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// End records err (if any) on span and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// WithTraceparent returns ctx carrying the remote span described by a W3C
+// traceparent value. Invalid or empty values leave ctx unchanged.
+func WithTraceparent(ctx context.Context, traceparent string) context.Context {
+	if traceparent == "" {
+		return ctx
+	}
+	return propagator.Extract(ctx, propagation.MapCarrier{"traceparent": traceparent})
+}
+
+// Traceparent formats the span in ctx as a W3C traceparent value, or returns
+// "" when ctx carries no valid span
+func Traceparent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// TraceID returns the trace ID of the span in ctx, or "" when there is none
+func TraceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	ctx := WithTraceparent(context.Background(), sampleTraceparent)
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", TraceID(ctx))
+	assert.Equal(t, sampleTraceparent, Traceparent(ctx))
+}
+
+func TestWithTraceparentIgnoresInvalidValues(t *testing.T) {
+	for _, tp := range []string{"", "garbage", "00-00000000000000000000000000000000-00f067aa0ba902b7-01"} {
+		ctx := WithTraceparent(context.Background(), tp)
+		assert.Empty(t, TraceID(ctx), tp)
+		assert.Empty(t, Traceparent(ctx), tp)
+	}
+}
+
+func TestStartContinuesRemoteTrace(t *testing.T) {
+	ctx := WithTraceparent(context.Background(), sampleTraceparent)
+	ctx, span := Start(ctx, "test")
+	defer End(span, nil)
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", TraceID(ctx))
+}
+
+func TestInitWithoutEndpointIsNoop(t *testing.T) {
+	shutdown, err := Init(context.Background(), Config{})
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+}
+
+func TestInitValidatesConfig(t *testing.T) {
+	_, err := Init(context.Background(), Config{Endpoint: "http://localhost:4318", SampleRatio: 2})
+	assert.Error(t, err)
+
+	_, err = Init(context.Background(), Config{Endpoint: "localhost:4318", SampleRatio: 1})
+	assert.Error(t, err)
+}
+
+func TestTraceURL(t *testing.T) {
+	u, err := traceURL("http://collector:4318")
+	require.NoError(t, err)
+	assert.Equal(t, "http://collector:4318/v1/traces", u)
+
+	u, err = traceURL("https://collector.example.com/custom/traces")
+	require.NoError(t, err)
+	assert.Equal(t, "https://collector.example.com/custom/traces", u)
+}
@@ -0,0 +1,245 @@
+// Package tune recommends settings for the machine Kaunta runs on and the
+// traffic it records, for `kaunta tune`.
+//
+// The recommendations are deliberately coarse: the traffic puts an instance
+// in one of three sizes (small, medium, large) that pick the batch sizes,
+// cache TTLs and rollup schedule, while the connection pool follows the CPUs
+// and the ingest queue the peak rate, bounded by the memory.
+package tune
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Machine is what the recommendations are based on. Zero Memory means
+// unknown.
+type Machine struct {
+	CPUs   int
+	Memory int64 // bytes available to the process
+	// DatabaseSize is the PostgreSQL database in bytes and EventsPerDay the
+	// events recorded in the last 24 hours; both zero without a database
+	DatabaseSize int64
+	EventsPerDay int64
+}
+
+// Setting is a recommended config file value
+type Setting struct {
+	Key    string
+	Value  string
+	Reason string
+}
+
+// TOML returns the value as written to kaunta.toml: numbers bare, anything
+// else quoted
+func (s Setting) TOML() string {
+	if _, err := strconv.Atoi(s.Value); err == nil {
+		return s.Value
+	}
+	return strconv.Quote(s.Value)
+}
+
+// Size is how much traffic an instance handles
+type Size int
+
+// Instance sizes
+const (
+	Small Size = iota
+	Medium
+	Large
+)
+
+func (s Size) String() string {
+	switch s {
+	case Large:
+		return "large"
+	case Medium:
+		return "medium"
+	default:
+		return "small"
+	}
+}
+
+const (
+	gib = 1 << 30
+	// eventBytes is roughly what a queued event holds in memory
+	eventBytes = 2048
+	// peakFactor is the peak over the average event rate of a day
+	peakFactor = 10
+	// queueSeconds is the peak traffic the ingest queue absorbs while the
+	// database is slow
+	queueSeconds = 60
+	// defaultQueueSize is the ingest package default
+	defaultQueueSize = 10000
+)
+
+// SizeOf places m in a size by its events per day or, for a database that
+// grew over years, by its size
+func SizeOf(m Machine) Size {
+	switch {
+	case m.EventsPerDay >= 2_000_000 || m.DatabaseSize >= 100*gib:
+		return Large
+	case m.EventsPerDay >= 100_000 || m.DatabaseSize >= 10*gib:
+		return Medium
+	default:
+		return Small
+	}
+}
+
+// Recommend returns the settings recommended for m
+func Recommend(m Machine) []Setting {
+	size := SizeOf(m)
+	cpus := max(m.CPUs, 1)
+
+	open := min(max(cpus*4, 8), 64)
+	openReason := fmt.Sprintf("%d CPUs, 4 connections each (8-64)", cpus)
+	if m.Memory > 0 && m.Memory < gib {
+		open = min(open, 10)
+		openReason = "under 1 GiB of memory"
+	}
+	idle := max(open/4, 2)
+
+	peak := m.EventsPerDay * peakFactor / 86400
+	queue := max(peak*queueSeconds, defaultQueueSize)
+	queueReason := fmt.Sprintf("%d s of the peak rate (about %d events/s)", queueSeconds, peak)
+	if m.Memory > 0 {
+		// At most 5% of the memory
+		if limit := m.Memory / 20 / eventBytes; queue > limit {
+			queue = max(limit, 1000)
+			queueReason = "5% of the memory"
+		}
+	}
+	queue = (queue + 999) / 1000 * 1000
+
+	batch, ttl, rollups := "500", "1m", "5m"
+	switch size {
+	case Medium:
+		batch, ttl, rollups = "1000", "2m", "10m"
+	case Large:
+		batch, ttl, rollups = "2000", "5m", "15m"
+	}
+	traffic := fmt.Sprintf("%s instance", size)
+
+	return []Setting{
+		{Key: "db_max_open_conns", Value: strconv.Itoa(open), Reason: openReason},
+		{Key: "db_max_idle_conns", Value: strconv.Itoa(idle), Reason: "a quarter of the open connections"},
+		{Key: "ingest_queue_size", Value: strconv.FormatInt(queue, 10), Reason: queueReason},
+		{Key: "ingest_batch_size", Value: batch, Reason: traffic + ", fewer and larger writes"},
+		{Key: "cache_ttl", Value: ttl, Reason: traffic + ", fewer settings lookups per pageview"},
+		{Key: "rollup_interval", Value: rollups, Reason: traffic + ", multi-day stats lag by at most this"},
+	}
+}
+
+// Inspect describes this machine and, with a db, the database
+func Inspect(ctx context.Context, db *sql.DB) (Machine, error) {
+	m := Machine{CPUs: runtime.GOMAXPROCS(0), Memory: Memory()}
+	if db == nil {
+		return m, nil
+	}
+	if err := db.QueryRowContext(ctx, `SELECT pg_database_size(current_database())`).Scan(&m.DatabaseSize); err != nil {
+		return m, fmt.Errorf("failed to read database size: %w", err)
+	}
+	if err := db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM website_event WHERE created_at >= NOW() - INTERVAL '1 day'`,
+	).Scan(&m.EventsPerDay); err != nil {
+		return m, fmt.Errorf("failed to count events: %w", err)
+	}
+	return m, nil
+}
+
+// memoryFiles are where Memory looks, the container limits first
+var memoryFiles = []string{
+	"/sys/fs/cgroup/memory.max",                   // cgroup v2
+	"/sys/fs/cgroup/memory/memory.limit_in_bytes", // cgroup v1
+	"/proc/meminfo",
+}
+
+// Memory returns the bytes of memory available to the process: the
+// container's limit or else the machine's memory. 0 means unknown (not
+// Linux).
+func Memory() int64 {
+	var total int64
+	for _, path := range memoryFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		value := parseMemory(path, string(data))
+		if value > 0 && (total == 0 || value < total) {
+			total = value
+		}
+	}
+	return total
+}
+
+// parseMemory reads a memory limit file or the MemTotal of /proc/meminfo;
+// 0 for no limit
+func parseMemory(path, data string) int64 {
+	if strings.HasSuffix(path, "meminfo") {
+		for _, line := range strings.Split(data, "\n") {
+			if rest, ok := strings.CutPrefix(line, "MemTotal:"); ok {
+				kb, _ := strconv.ParseInt(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(rest), "kB")), 10, 64)
+				return kb * 1024
+			}
+		}
+		return 0
+	}
+	value, err := strconv.ParseInt(strings.TrimSpace(data), 10, 64)
+	if err != nil || value >= 1<<60 {
+		// "max", or cgroup v1's page-aligned int64 max
+		return 0
+	}
+	return value
+}
+
+// WriteConfig sets the settings in the TOML config file at path, replacing
+// the keys it already has and appending the others. Comments and other keys
+// are kept.
+func WriteConfig(path string, settings []Setting) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(data) == 0 {
+		lines = nil
+	}
+	written := make(map[string]bool, len(settings))
+	for i, line := range lines {
+		key, _, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		for _, s := range settings {
+			if s.Key == key {
+				lines[i] = s.Key + " = " + s.TOML()
+				written[key] = true
+			}
+		}
+	}
+	var added []string
+	for _, s := range settings {
+		if !written[s.Key] {
+			added = append(added, s.Key+" = "+s.TOML())
+		}
+	}
+	if len(added) > 0 {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "# Recommended by kaunta tune")
+		lines = append(lines, added...)
+	}
+
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
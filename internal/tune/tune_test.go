@@ -0,0 +1,88 @@
+package tune
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// values maps the recommended keys to their values
+func values(settings []Setting) map[string]string {
+	m := make(map[string]string, len(settings))
+	for _, s := range settings {
+		m[s.Key] = s.Value
+	}
+	return m
+}
+
+func TestRecommendSmall(t *testing.T) {
+	m := Machine{CPUs: 1, Memory: 512 << 20, EventsPerDay: 5000}
+	assert.Equal(t, Small, SizeOf(m))
+	assert.Equal(t, map[string]string{
+		"db_max_open_conns": "8",
+		"db_max_idle_conns": "2",
+		"ingest_queue_size": "10000",
+		"ingest_batch_size": "500",
+		"cache_ttl":         "1m",
+		"rollup_interval":   "5m",
+	}, values(Recommend(m)))
+}
+
+func TestRecommendLarge(t *testing.T) {
+	m := Machine{CPUs: 32, Memory: 64 << 30, DatabaseSize: 20 << 30, EventsPerDay: 5_000_000}
+	assert.Equal(t, Large, SizeOf(m))
+	got := values(Recommend(m))
+	assert.Equal(t, "64", got["db_max_open_conns"])
+	assert.Equal(t, "16", got["db_max_idle_conns"])
+	// 578 events/s at the peak, for 60 seconds
+	assert.Equal(t, "35000", got["ingest_queue_size"])
+	assert.Equal(t, "15m", got["rollup_interval"])
+
+	// A small machine bounds the queue by its memory
+	m.Memory = 512 << 20
+	got = values(Recommend(m))
+	assert.Equal(t, "10", got["db_max_open_conns"])
+	assert.Equal(t, "14000", got["ingest_queue_size"])
+}
+
+func TestSizeOfDatabase(t *testing.T) {
+	assert.Equal(t, Medium, SizeOf(Machine{DatabaseSize: 15 << 30}))
+	assert.Equal(t, Large, SizeOf(Machine{DatabaseSize: 200 << 30}))
+}
+
+func TestParseMemory(t *testing.T) {
+	assert.Equal(t, int64(2<<30), parseMemory("/sys/fs/cgroup/memory.max", "2147483648\n"))
+	assert.Zero(t, parseMemory("/sys/fs/cgroup/memory.max", "max\n"))
+	assert.Zero(t, parseMemory("/sys/fs/cgroup/memory/memory.limit_in_bytes", "9223372036854771712\n"))
+	assert.Equal(t, int64(16384000*1024), parseMemory("/proc/meminfo", "MemTotal:       16384000 kB\nMemFree:         1000 kB\n"))
+}
+
+func TestWriteConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kaunta.toml")
+	require.NoError(t, os.WriteFile(path, []byte("# Kaunta\nport = \"3000\"\ncache_ttl = \"30s\"\n"), 0o600))
+
+	settings := []Setting{
+		{Key: "cache_ttl", Value: "2m"},
+		{Key: "db_max_open_conns", Value: "16"},
+	}
+	require.NoError(t, WriteConfig(path, settings))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "# Kaunta\nport = \"3000\"\ncache_ttl = \"2m\"\n\n# Recommended by kaunta tune\ndb_max_open_conns = 16\n", string(data))
+
+	// Writing again changes nothing
+	require.NoError(t, WriteConfig(path, settings))
+	again, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(data), string(again))
+
+	// A new file only has the settings
+	fresh := filepath.Join(t.TempDir(), "kaunta.toml")
+	require.NoError(t, WriteConfig(fresh, settings[1:]))
+	data, err = os.ReadFile(fresh)
+	require.NoError(t, err)
+	assert.Equal(t, "# Recommended by kaunta tune\ndb_max_open_conns = 16\n", string(data))
+}
@@ -0,0 +1,122 @@
+// Package useragent tells a visitor's browser, OS and device type from their
+// User-Agent and, where the browser sends them, Sec-CH-UA client hints.
+//
+// Chromium browsers freeze their user agent (a fixed OS version, "Android
+// 10; K" for every phone) and Brave, among others, can't be told from
+// Chrome by it; their low-entropy client hints, sent on every HTTPS request,
+// name the browser brand, the platform and whether the device is mobile.
+// Hints win over the user agent where they say something.
+package useragent
+
+import (
+	"regexp"
+	"strings"
+
+	parser "github.com/mileusna/useragent"
+)
+
+// Device types
+const (
+	Desktop = "desktop"
+	Mobile  = "mobile"
+	Tablet  = "tablet"
+)
+
+// Unknown is the browser or OS of an agent that isn't recognized
+const Unknown = "Unknown"
+
+// Client is what a visitor's browser says about itself
+type Client struct {
+	Browser string
+	OS      string
+	Device  string
+}
+
+// Hints are the low-entropy client hints of a request: Sec-CH-UA,
+// Sec-CH-UA-Platform and Sec-CH-UA-Mobile as sent
+type Hints struct {
+	Brands   string
+	Platform string
+	Mobile   string
+}
+
+// HintsFrom reads the client hints of a request from its headers
+func HintsFrom(header func(key string, defaultValue ...string) string) Hints {
+	return Hints{
+		Brands:   header("Sec-CH-UA"),
+		Platform: header("Sec-CH-UA-Platform"),
+		Mobile:   header("Sec-CH-UA-Mobile"),
+	}
+}
+
+// brandNames maps the brands of Sec-CH-UA to browser names; Chromium and
+// made-up "Not A Brand" entries say nothing of their own
+var brandNames = map[string]string{
+	"Google Chrome":    parser.Chrome,
+	"Microsoft Edge":   parser.Edge,
+	"Opera":            parser.Opera,
+	"Opera GX":         parser.Opera,
+	"Brave":            "Brave",
+	"Vivaldi":          parser.Vivaldi,
+	"Samsung Internet": parser.SamsungBrowser,
+	"YaBrowser":        "Yandex Browser",
+	"HeadlessChrome":   parser.HeadlessChrome,
+}
+
+// platformNames maps Sec-CH-UA-Platform values to OS names where they
+// differ from the user agent parser's
+var platformNames = map[string]string{
+	"Chrome OS":   parser.ChromeOS,
+	"Chromium OS": parser.ChromeOS,
+}
+
+var brandPattern = regexp.MustCompile(`"([^"]*)"\s*;\s*v\s*=`)
+
+// Parse tells the client from a user agent and its client hints (zero
+// Hints when there are none)
+func Parse(userAgent string, hints Hints) Client {
+	ua := parser.Parse(userAgent)
+	client := Client{Browser: ua.Name, OS: ua.OS, Device: Desktop}
+	switch {
+	case ua.Tablet:
+		client.Device = Tablet
+	case ua.Mobile:
+		client.Device = Mobile
+	}
+	// Continuity with browsers recorded before the parser
+	if client.Browser == parser.MobileSafari {
+		client.Browser = parser.Safari
+	}
+
+	for _, match := range brandPattern.FindAllStringSubmatch(hints.Brands, -1) {
+		if name, ok := brandNames[match[1]]; ok {
+			client.Browser = name
+			break
+		}
+	}
+	if platform := strings.Trim(strings.TrimSpace(hints.Platform), `"`); platform != "" && platform != Unknown {
+		if name, ok := platformNames[platform]; ok {
+			platform = name
+		}
+		client.OS = platform
+	}
+	switch strings.TrimSpace(hints.Mobile) {
+	case "?1":
+		client.Device = Mobile
+	case "?0":
+		// Frozen Android user agents of tablets and phones look alike
+		if client.OS == parser.Android {
+			client.Device = Tablet
+		} else if client.Device == Mobile {
+			client.Device = Desktop
+		}
+	}
+
+	if client.Browser == "" {
+		client.Browser = Unknown
+	}
+	if client.OS == "" {
+		client.OS = Unknown
+	}
+	return client
+}
@@ -0,0 +1,58 @@
+package useragent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	chromeWindows  = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+	chromeAndroid  = "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36"
+	chromeTablet   = "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+	safariIPhone   = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1"
+	firefoxLinux   = "Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0"
+	edgeMac        = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.2478.51"
+	chromebookUser = "Mozilla/5.0 (X11; CrOS x86_64 14541.0.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	assert.Equal(t, Client{Browser: "Chrome", OS: "Windows", Device: Desktop}, Parse(chromeWindows, Hints{}))
+	assert.Equal(t, Client{Browser: "Chrome", OS: "Android", Device: Mobile}, Parse(chromeAndroid, Hints{}))
+	assert.Equal(t, Client{Browser: "Safari", OS: "iOS", Device: Mobile}, Parse(safariIPhone, Hints{}))
+	assert.Equal(t, Client{Browser: "Firefox", OS: "Linux", Device: Desktop}, Parse(firefoxLinux, Hints{}))
+	assert.Equal(t, Client{Browser: "Edge", OS: "macOS", Device: Desktop}, Parse(edgeMac, Hints{}))
+	assert.Equal(t, Client{Browser: Unknown, OS: Unknown, Device: Desktop}, Parse("", Hints{}))
+}
+
+func TestParseClientHints(t *testing.T) {
+	// Brave sends Chrome's user agent but its own brand
+	brave := Parse(chromeWindows, Hints{
+		Brands:   `"Chromium";v="124", "Brave";v="124", "Not-A.Brand";v="99"`,
+		Platform: `"Windows"`,
+		Mobile:   "?0",
+	})
+	assert.Equal(t, Client{Browser: "Brave", OS: "Windows", Device: Desktop}, brave)
+
+	// Chromium alone says nothing over the user agent
+	chromium := Parse(firefoxLinux, Hints{Brands: `"Not/A)Brand";v="8", "Chromium";v="124"`, Platform: `"Linux"`})
+	assert.Equal(t, "Firefox", chromium.Browser)
+
+	// A frozen Android user agent without "Mobile" is a tablet
+	tablet := Parse(chromeTablet, Hints{Brands: `"Google Chrome";v="124"`, Platform: `"Android"`, Mobile: "?0"})
+	assert.Equal(t, Client{Browser: "Chrome", OS: "Android", Device: Tablet}, tablet)
+	phone := Parse(chromeTablet, Hints{Platform: `"Android"`, Mobile: "?1"})
+	assert.Equal(t, Mobile, phone.Device)
+
+	chromebook := Parse(chromebookUser, Hints{Brands: `"Google Chrome";v="124"`, Platform: `"Chrome OS"`, Mobile: "?0"})
+	assert.Equal(t, Client{Browser: "Chrome", OS: "ChromeOS", Device: Desktop}, chromebook)
+
+	unknown := Parse(chromeWindows, Hints{Platform: `"Unknown"`})
+	assert.Equal(t, "Windows", unknown.OS)
+}
+
+func TestHintsFrom(t *testing.T) {
+	headers := map[string]string{"Sec-CH-UA": `"Brave";v="124"`, "Sec-CH-UA-Platform": `"macOS"`, "Sec-CH-UA-Mobile": "?0"}
+	hints := HintsFrom(func(key string, _ ...string) string { return headers[key] })
+	assert.Equal(t, Hints{Brands: `"Brave";v="124"`, Platform: `"macOS"`, Mobile: "?0"}, hints)
+}
@@ -0,0 +1,201 @@
+// Package visits decides which visit of its session an event belongs to.
+// How a session is split into visits (visit_definition) is either:
+//
+//   - hour (default, as in Umami): a visit is the session's events within a
+//     clock hour. Events of a page sending umami.js's cache token stay in its
+//     visit as long as they come within the session timeout.
+//   - inactivity (as in Google Analytics): a visit ends once the session has
+//     gone the session timeout (default 30 minutes) without events.
+//
+// Websites can override both (`kaunta website visits`). Inactivity is
+// tracked in memory by each server process: after a restart, or when a
+// visitor's events land on several servers, a visit may be split in two.
+package visits
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Definition is how sessions are split into visits
+type Definition string
+
+const (
+	Hour       Definition = "hour"
+	Inactivity Definition = "inactivity"
+)
+
+// DefaultTimeout is how long a session goes without events before its visit
+// ends, unless configured otherwise
+const DefaultTimeout = 30 * time.Minute
+
+// Bounds of the session timeout
+const (
+	MinTimeout = time.Minute
+	MaxTimeout = 24 * time.Hour
+)
+
+// sweepInterval is how often sessions past their timeout are forgotten
+const sweepInterval = time.Minute
+
+// ParseDefinition validates a visit_definition setting; empty means Hour
+func ParseDefinition(value string) (Definition, error) {
+	switch d := Definition(strings.ToLower(strings.TrimSpace(value))); d {
+	case "":
+		return Hour, nil
+	case Hour, Inactivity:
+		return d, nil
+	default:
+		return "", fmt.Errorf("invalid visit definition: %s (use hour or inactivity)", value)
+	}
+}
+
+// ValidTimeout checks a session timeout; zero means the default
+func ValidTimeout(timeout time.Duration) error {
+	if timeout != 0 && (timeout < MinTimeout || timeout > MaxTimeout) {
+		return fmt.Errorf("session timeout must be between %s and %s", MinTimeout, MaxTimeout)
+	}
+	return nil
+}
+
+// Rules split a session into visits
+type Rules struct {
+	Definition Definition    `json:"visit_definition"`
+	Timeout    time.Duration `json:"session_timeout"`
+}
+
+// Override returns r with a website's settings applied: an empty definition
+// or a zero timeout keeps r's
+func (r Rules) Override(definition string, timeout time.Duration) Rules {
+	if d, err := ParseDefinition(definition); err == nil && definition != "" {
+		r.Definition = d
+	}
+	if timeout > 0 {
+		r.Timeout = timeout
+	}
+	return r
+}
+
+// withDefaults fills the unset rules
+func (r Rules) withDefaults() Rules {
+	if r.Definition == "" {
+		r.Definition = Hour
+	}
+	if r.Timeout == 0 {
+		r.Timeout = DefaultTimeout
+	}
+	return r
+}
+
+var (
+	defaultsMu sync.RWMutex
+	defaults   = Rules{Definition: Hour, Timeout: DefaultTimeout}
+)
+
+// Configure sets the rules of websites that don't override them; unset
+// rules keep the defaults
+func Configure(r Rules) error {
+	if _, err := ParseDefinition(string(r.Definition)); err != nil {
+		return err
+	}
+	if err := ValidTimeout(r.Timeout); err != nil {
+		return err
+	}
+	defaultsMu.Lock()
+	defaults = r.withDefaults()
+	defaultsMu.Unlock()
+	return nil
+}
+
+// Defaults returns the configured rules
+func Defaults() Rules {
+	defaultsMu.RLock()
+	defer defaultsMu.RUnlock()
+	return defaults
+}
+
+// visit is the current visit of a session
+type visit struct {
+	id      uuid.UUID
+	last    time.Time
+	timeout time.Duration
+}
+
+// Tracker remembers the current visit of recently active sessions
+type Tracker struct {
+	clock func() time.Time
+
+	mu        sync.Mutex
+	visits    map[uuid.UUID]visit
+	lastSweep time.Time
+}
+
+// NewTracker returns an empty Tracker
+func NewTracker() *Tracker {
+	return &Tracker{clock: time.Now, visits: make(map[uuid.UUID]visit)}
+}
+
+// Visit returns the visit of a session's event at at under r. cached is the
+// visit named by the request's cache token, still running, or uuid.Nil.
+func (t *Tracker) Visit(sessionID uuid.UUID, at time.Time, r Rules, cached uuid.UUID) uuid.UUID {
+	r = r.withDefaults()
+	if r.Definition == Hour {
+		if cached != uuid.Nil {
+			return cached
+		}
+		return hourVisit(sessionID, at)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sweep(t.clock())
+
+	v, ok := t.visits[sessionID]
+	switch {
+	case cached != uuid.Nil:
+		v.id = cached
+	case !ok || at.Sub(v.last) > r.Timeout:
+		// Events sent late (batches, timestamps) stay in the current visit
+		v = visit{id: uuid.NewSHA1(sessionID, []byte(at.UTC().Format(time.RFC3339Nano)))}
+	}
+	if at.After(v.last) {
+		v.last = at
+	}
+	v.timeout = r.Timeout
+	t.visits[sessionID] = v
+	return v.id
+}
+
+// sweep forgets the sessions past their timeout, at most once a minute
+func (t *Tracker) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < sweepInterval {
+		return
+	}
+	t.lastSweep = now
+	for id, v := range t.visits {
+		if now.Sub(v.last) > v.timeout {
+			delete(t.visits, id)
+		}
+	}
+}
+
+// hourVisit is the visit of a session's events within the hour of at
+func hourVisit(sessionID uuid.UUID, at time.Time) uuid.UUID {
+	hour := md5.Sum([]byte(at.Format("2006-01-02T15")))
+	sum := md5.Sum([]byte(sessionID.String() + "|" + hex.EncodeToString(hour[:])))
+	id, _ := uuid.FromBytes(sum[:])
+	return id
+}
+
+var current = NewTracker()
+
+// Current returns the process tracker
+func Current() *Tracker {
+	return current
+}
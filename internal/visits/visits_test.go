@@ -0,0 +1,85 @@
+package visits
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDefinition(t *testing.T) {
+	d, err := ParseDefinition("")
+	require.NoError(t, err)
+	assert.Equal(t, Hour, d)
+	d, err = ParseDefinition(" Inactivity ")
+	require.NoError(t, err)
+	assert.Equal(t, Inactivity, d)
+	_, err = ParseDefinition("day")
+	assert.EqualError(t, err, "invalid visit definition: day (use hour or inactivity)")
+}
+
+func TestValidTimeout(t *testing.T) {
+	assert.NoError(t, ValidTimeout(0))
+	assert.NoError(t, ValidTimeout(15*time.Minute))
+	assert.Error(t, ValidTimeout(30*time.Second))
+	assert.Error(t, ValidTimeout(48*time.Hour))
+}
+
+func TestConfigureAndOverride(t *testing.T) {
+	t.Cleanup(func() { _ = Configure(Rules{}) })
+
+	require.NoError(t, Configure(Rules{Definition: Inactivity}))
+	assert.Equal(t, Rules{Definition: Inactivity, Timeout: DefaultTimeout}, Defaults())
+	assert.Error(t, Configure(Rules{Timeout: time.Second}))
+
+	rules := Defaults()
+	assert.Equal(t, rules, rules.Override("", 0))
+	assert.Equal(t, Rules{Definition: Hour, Timeout: 10 * time.Minute}, rules.Override("hour", 10*time.Minute))
+}
+
+func TestVisitByHour(t *testing.T) {
+	tracker := NewTracker()
+	session := uuid.New()
+	at := time.Date(2026, 3, 1, 9, 10, 0, 0, time.UTC)
+	rules := Rules{Definition: Hour}
+
+	first := tracker.Visit(session, at, rules, uuid.Nil)
+	assert.Equal(t, first, tracker.Visit(session, at.Add(40*time.Minute), rules, uuid.Nil))
+	assert.NotEqual(t, first, tracker.Visit(session, at.Add(55*time.Minute), rules, uuid.Nil))
+
+	// The cache token's visit continues past the hour
+	assert.Equal(t, first, tracker.Visit(session, at.Add(55*time.Minute), rules, first))
+}
+
+func TestVisitByInactivity(t *testing.T) {
+	tracker := NewTracker()
+	session, other := uuid.New(), uuid.New()
+	at := time.Date(2026, 3, 1, 9, 50, 0, 0, time.UTC)
+	rules := Rules{Definition: Inactivity, Timeout: 30 * time.Minute}
+
+	first := tracker.Visit(session, at, rules, uuid.Nil)
+	// Across the hour, each event within the timeout of the last one
+	assert.Equal(t, first, tracker.Visit(session, at.Add(25*time.Minute), rules, uuid.Nil))
+	assert.Equal(t, first, tracker.Visit(session, at.Add(50*time.Minute), rules, uuid.Nil))
+	// An event sent late stays in the visit
+	assert.Equal(t, first, tracker.Visit(session, at.Add(time.Minute), rules, uuid.Nil))
+	assert.NotEqual(t, first, tracker.Visit(other, at, rules, uuid.Nil))
+
+	second := tracker.Visit(session, at.Add(81*time.Minute), rules, uuid.Nil)
+	assert.NotEqual(t, first, second)
+	assert.Equal(t, second, tracker.Visit(session, at.Add(90*time.Minute), rules, uuid.Nil))
+}
+
+func TestVisitSweepsIdleSessions(t *testing.T) {
+	tracker := NewTracker()
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	tracker.clock = func() time.Time { return now }
+	rules := Rules{Definition: Inactivity, Timeout: 5 * time.Minute}
+
+	tracker.Visit(uuid.New(), now, rules, uuid.Nil)
+	now = now.Add(10 * time.Minute)
+	tracker.Visit(uuid.New(), now, rules, uuid.Nil)
+	assert.Len(t, tracker.visits, 1)
+}
@@ -0,0 +1,165 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/alerts"
+	"github.com/seuros/kaunta/internal/blockers"
+	"github.com/seuros/kaunta/internal/config"
+	"github.com/seuros/kaunta/internal/crossdomain"
+	"github.com/seuros/kaunta/internal/database"
+	"github.com/seuros/kaunta/internal/debugcapture"
+	"github.com/seuros/kaunta/internal/eventloss"
+	"github.com/seuros/kaunta/internal/jobs"
+	"github.com/seuros/kaunta/internal/logging"
+	"github.com/seuros/kaunta/internal/notify"
+	"github.com/seuros/kaunta/internal/retention"
+	"github.com/seuros/kaunta/internal/rollup"
+)
+
+func init() {
+	Register(Task{
+		Name:        "partitions",
+		Description: "Create website_event partitions 30 days ahead",
+		Interval:    24 * time.Hour,
+		Run: func(ctx context.Context) error {
+			database.CreateFuturePartitions()
+			return nil
+		},
+	})
+
+	Register(Task{
+		Name:        "expired-sessions",
+		Description: "Delete expired dashboard login sessions",
+		Interval:    time.Hour,
+		Run:         cleanupExpiredSessions,
+	})
+
+	Register(Task{
+		Name:        "rollups",
+		Description: "Aggregate hourly and daily stats for long-range reports",
+		Interval:    5 * time.Minute,
+		Run: func(ctx context.Context) error {
+			return rollup.Refresh(ctx, database.DB)
+		},
+	})
+
+	Register(Task{
+		Name:        "retention",
+		Description: "Drop expired partitions and delete events past their retention",
+		Interval:    6 * time.Hour,
+		Run:         pruneExpiredData,
+	})
+
+	Register(Task{
+		Name:        "event-loss",
+		Description: "Delete event loss counters older than 30 days",
+		Interval:    24 * time.Hour,
+		Run: func(ctx context.Context) error {
+			_, err := eventloss.Cleanup(ctx, database.DB)
+			return err
+		},
+	})
+
+	Register(Task{
+		Name:        "debug-captures",
+		Description: "Delete captured debug requests older than 3 days",
+		Interval:    time.Hour,
+		Run: func(ctx context.Context) error {
+			_, err := debugcapture.Cleanup(ctx, database.DB)
+			return err
+		},
+	})
+
+	Register(Task{
+		Name:        "baseline-hits",
+		Description: "Delete baseline pixel hits older than 90 days",
+		Interval:    24 * time.Hour,
+		Run: func(ctx context.Context) error {
+			_, err := blockers.Cleanup(ctx, database.DB)
+			return err
+		},
+	})
+
+	Register(Task{
+		Name:        "session-links",
+		Description: "Delete cross-domain session links older than 90 days",
+		Interval:    24 * time.Hour,
+		Run: func(ctx context.Context) error {
+			_, err := crossdomain.Cleanup(ctx, database.DB)
+			return err
+		},
+	})
+
+	Register(Task{
+		Name:        "alerts",
+		Description: "Evaluate alert rules and queue their notifications",
+		Interval:    5 * time.Minute,
+		Run: func(ctx context.Context) error {
+			return alerts.Run(ctx, database.DB, time.Now())
+		},
+	})
+	jobs.Register(alerts.JobKind, alerts.Deliver)
+
+	Register(Task{
+		Name:        "daily-summary",
+		Description: "Post yesterday's summary of websites to Slack and Discord",
+		Interval:    time.Hour,
+		Run:         sendDailySummaries,
+	})
+	jobs.Register(notify.JobKind, notify.Deliver)
+
+	Register(Task{
+		Name:        "jobs",
+		Description: "Run queued webhooks, report emails, exports and imports",
+		Interval:    5 * time.Second,
+		Concurrent:  true,
+		Run:         processJobs,
+	})
+}
+
+func processJobs(ctx context.Context) error {
+	p := &jobs.Processor{DB: database.DB}
+	_, err := p.ProcessAvailable(ctx)
+	return err
+}
+
+func pruneExpiredData(ctx context.Context) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	result, err := retention.Prune(ctx, database.DB, cfg.RetentionDays)
+	if err != nil {
+		return err
+	}
+	if result.Partitions > 0 || result.Events > 0 || result.Sessions > 0 {
+		logging.L().Info("pruned expired data",
+			zap.Int("partitions", result.Partitions),
+			zap.Int64("events", result.Events),
+			zap.Int64("sessions", result.Sessions))
+	}
+	return nil
+}
+
+func sendDailySummaries(ctx context.Context) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	return notify.SendSummaries(ctx, database.DB, cfg.Notify, time.Now())
+}
+
+func cleanupExpiredSessions(ctx context.Context) error {
+	var deleted int
+	if err := database.DB.QueryRowContext(ctx, "SELECT cleanup_expired_sessions()").Scan(&deleted); err != nil {
+		return err
+	}
+	if deleted > 0 {
+		logging.L().Info("deleted expired sessions", zap.Int("count", deleted))
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+// Package worker runs Kaunta's periodic background tasks (partition
+// maintenance, session cleanup, rollups, pruning, reports, exports).
+//
+// Tasks register themselves with Register. The same Runner is used by the
+// HTTP server (embedded_jobs = true) and by `kaunta worker`, which runs the
+// tasks without serving traffic so heavy maintenance can live on a separate
+// machine. Every run is guarded by a PostgreSQL advisory lock, so any number
+// of servers and workers can share a database without running a task twice.
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/seuros/kaunta/internal/logging"
+)
+
+// Task is a periodic background job
+type Task struct {
+	Name        string
+	Description string
+	Interval    time.Duration
+	Run         func(ctx context.Context) error
+
+	// Concurrent tasks skip the advisory lock because they coordinate
+	// themselves (e.g. job claims with FOR UPDATE SKIP LOCKED)
+	Concurrent bool
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Task{}
+)
+
+// Register adds a task to the default set. Registering a name twice replaces
+// the earlier task.
+func Register(t Task) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[t.Name] = t
+}
+
+// SetInterval changes how often a registered task runs; unknown names and
+// non-positive intervals are ignored
+func SetInterval(name string, interval time.Duration) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if t, ok := registry[name]; ok && interval > 0 {
+		t.Interval = interval
+		registry[name] = t
+	}
+}
+
+// Tasks returns the registered tasks sorted by name
+func Tasks() []Task {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	tasks := make([]Task, 0, len(registry))
+	for _, t := range registry {
+		tasks = append(tasks, t)
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].Name < tasks[j].Name })
+	return tasks
+}
+
+// Select returns the registered tasks with the given names (all when names is empty)
+func Select(names []string) ([]Task, error) {
+	all := Tasks()
+	if len(names) == 0 {
+		return all, nil
+	}
+
+	byName := make(map[string]Task, len(all))
+	for _, t := range all {
+		byName[t.Name] = t
+	}
+	selected := make([]Task, 0, len(names))
+	for _, name := range names {
+		t, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown task: %s", name)
+		}
+		selected = append(selected, t)
+	}
+	return selected, nil
+}
+
+// Locker provides cross-process mutual exclusion for task runs
+type Locker interface {
+	// TryLock acquires the named lock without waiting. ok is false when
+	// another process holds it; unlock must be called when ok is true.
+	TryLock(ctx context.Context, name string) (unlock func(), ok bool, err error)
+}
+
+// AdvisoryLocker implements Locker with PostgreSQL session advisory locks
+type AdvisoryLocker struct {
+	DB *sql.DB
+}
+
+// lockKey maps a task name to a stable advisory lock key
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("kaunta:worker:" + name))
+	return int64(h.Sum64())
+}
+
+// TryLock implements Locker. The lock is held on a dedicated connection
+// because session advisory locks must be released by the session that took them.
+func (l AdvisoryLocker) TryLock(ctx context.Context, name string) (func(), bool, error) {
+	conn, err := l.DB.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	key := lockKey(name)
+	var ok bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&ok); err != nil {
+		_ = conn.Close()
+		return nil, false, err
+	}
+	if !ok {
+		_ = conn.Close()
+		return nil, false, nil
+	}
+
+	unlock := func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key); err != nil {
+			logging.L().Warn("failed to release advisory lock", zap.String("task", name), zap.Error(err))
+		}
+		_ = conn.Close()
+	}
+	return unlock, true, nil
+}
+
+// Runner schedules tasks on their intervals
+type Runner struct {
+	Tasks  []Task
+	Locker Locker
+}
+
+// RunOnce runs a task if its lock is free. It reports whether the task ran.
+func (r *Runner) RunOnce(ctx context.Context, t Task) (bool, error) {
+	if r.Locker != nil && !t.Concurrent {
+		unlock, ok, err := r.Locker.TryLock(ctx, t.Name)
+		if err != nil {
+			return false, fmt.Errorf("lock %s: %w", t.Name, err)
+		}
+		if !ok {
+			logging.L().Debug("task already running elsewhere", zap.String("task", t.Name))
+			return false, nil
+		}
+		defer unlock()
+	}
+
+	start := time.Now()
+	if err := t.Run(ctx); err != nil {
+		return true, fmt.Errorf("%s: %w", t.Name, err)
+	}
+	logging.L().Debug("task completed", zap.String("task", t.Name), zap.Duration("duration", time.Since(start)))
+	return true, nil
+}
+
+// Run starts every task immediately and then on its interval, until ctx is
+// cancelled. It returns once all in-flight runs have finished.
+func (r *Runner) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, t := range r.Tasks {
+		wg.Add(1)
+		go func(t Task) {
+			defer wg.Done()
+			r.loop(ctx, t)
+		}(t)
+	}
+	wg.Wait()
+}
+
+func (r *Runner) loop(ctx context.Context, t Task) {
+	interval := t.Interval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.RunOnce(ctx, t); err != nil && ctx.Err() == nil {
+			logging.L().Error("task failed", zap.String("task", t.Name), zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
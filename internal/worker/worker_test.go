@@ -0,0 +1,149 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLocker grants or refuses every lock
+type fakeLocker struct {
+	free     bool
+	released atomic.Int32
+}
+
+func (l *fakeLocker) TryLock(ctx context.Context, name string) (func(), bool, error) {
+	if !l.free {
+		return nil, false, nil
+	}
+	return func() { l.released.Add(1) }, true, nil
+}
+
+func TestDefaultTasksRegistered(t *testing.T) {
+	tasks, err := Select([]string{"partitions", "expired-sessions"})
+	require.NoError(t, err)
+	assert.Len(t, tasks, 2)
+
+	_, err = Select([]string{"nope"})
+	assert.ErrorContains(t, err, "unknown task: nope")
+
+	all, err := Select(nil)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, len(all), 2)
+	for i := 1; i < len(all); i++ {
+		assert.Less(t, all[i-1].Name, all[i].Name)
+	}
+}
+
+func TestSetInterval(t *testing.T) {
+	Register(Task{Name: "test-interval", Interval: time.Minute})
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "test-interval")
+		registryMu.Unlock()
+	})
+
+	SetInterval("test-interval", 0)
+	SetInterval("nope", time.Hour)
+	tasks, err := Select([]string{"test-interval"})
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, tasks[0].Interval)
+
+	SetInterval("test-interval", 15*time.Minute)
+	tasks, err = Select([]string{"test-interval"})
+	require.NoError(t, err)
+	assert.Equal(t, 15*time.Minute, tasks[0].Interval)
+}
+
+func TestRunOnceRespectsLock(t *testing.T) {
+	var runs atomic.Int32
+	task := Task{Name: "count", Run: func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}}
+
+	held := &fakeLocker{free: false}
+	ran, err := (&Runner{Locker: held}).RunOnce(context.Background(), task)
+	require.NoError(t, err)
+	assert.False(t, ran)
+	assert.Equal(t, int32(0), runs.Load())
+
+	free := &fakeLocker{free: true}
+	ran, err = (&Runner{Locker: free}).RunOnce(context.Background(), task)
+	require.NoError(t, err)
+	assert.True(t, ran)
+	assert.Equal(t, int32(1), runs.Load())
+	assert.Equal(t, int32(1), free.released.Load())
+}
+
+func TestRunOnceWrapsTaskError(t *testing.T) {
+	task := Task{Name: "broken", Run: func(ctx context.Context) error { return errors.New("boom") }}
+	locker := &fakeLocker{free: true}
+
+	ran, err := (&Runner{Locker: locker}).RunOnce(context.Background(), task)
+	assert.True(t, ran)
+	assert.EqualError(t, err, "broken: boom")
+	assert.Equal(t, int32(1), locker.released.Load())
+}
+
+func TestRunStopsOnCancel(t *testing.T) {
+	var runs atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	runner := &Runner{Tasks: []Task{{
+		Name:     "tick",
+		Interval: 5 * time.Millisecond,
+		Run: func(ctx context.Context) error {
+			if runs.Add(1) == 3 {
+				cancel()
+			}
+			return nil
+		},
+	}}}
+
+	done := make(chan struct{})
+	go func() {
+		runner.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("runner did not stop")
+	}
+	assert.GreaterOrEqual(t, runs.Load(), int32(3))
+}
+
+func TestAdvisoryLocker(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	key := lockKey("partitions")
+	assert.Equal(t, key, lockKey("partitions"))
+	assert.NotEqual(t, key, lockKey("expired-sessions"))
+
+	mock.ExpectQuery(`SELECT pg_try_advisory_lock\(\$1\)`).WithArgs(key).
+		WillReturnRows(sqlmock.NewRows([]string{"ok"}).AddRow(true))
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(key).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT pg_try_advisory_lock\(\$1\)`).WithArgs(key).
+		WillReturnRows(sqlmock.NewRows([]string{"ok"}).AddRow(false))
+
+	locker := AdvisoryLocker{DB: db}
+	unlock, ok, err := locker.TryLock(context.Background(), "partitions")
+	require.NoError(t, err)
+	require.True(t, ok)
+	unlock()
+
+	_, ok, err = locker.TryLock(context.Background(), "partitions")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"strings"
+	"time"
 
 	"github.com/magefile/mage/mg"
 	"github.com/magefile/mage/sh"
@@ -43,6 +45,46 @@ func BuildLocal() error {
 	return sh.Run("go", "build", "-o", "kaunta", "./cmd/kaunta")
 }
 
+// releaseTargets are the platforms release binaries are built for, as in
+// the release workflow
+var releaseTargets = []struct{ goos, goarch string }{
+	{"linux", "amd64"},
+	{"linux", "arm64"},
+	{"darwin", "amd64"},
+	{"darwin", "arm64"},
+	{"freebsd", "amd64"},
+}
+
+// Release builds kaunta-<os>-<arch> for every release platform, stamped
+// with the version, commit and build date `kaunta version` reports
+func Release() error {
+	if err := buildAssets(); err != nil {
+		return err
+	}
+	version, err := os.ReadFile("cmd/kaunta/VERSION")
+	if err != nil {
+		return err
+	}
+	commit, err := sh.Output("git", "rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+	ldflags := fmt.Sprintf("-w -s -X github.com/seuros/kaunta/internal/cli.Version=%s"+
+		" -X github.com/seuros/kaunta/internal/buildinfo.Commit=%s"+
+		" -X github.com/seuros/kaunta/internal/buildinfo.Date=%s",
+		strings.TrimSpace(string(version)), commit, time.Now().UTC().Format(time.RFC3339))
+
+	for _, target := range releaseTargets {
+		output := fmt.Sprintf("kaunta-%s-%s", target.goos, target.goarch)
+		fmt.Printf("Building %s...\n", output)
+		env := map[string]string{"CGO_ENABLED": "0", "GOOS": target.goos, "GOARCH": target.goarch}
+		if err := sh.RunWith(env, "go", "build", "-ldflags", ldflags, "-o", output, "./cmd/kaunta"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Test runs tests
 func Test() error {
 	fmt.Println("Running tests...")
@@ -53,7 +95,9 @@ func Test() error {
 func Clean() error {
 	fmt.Println("Cleaning build artifacts...")
 	os.Remove("kaunta")
-	os.Remove("kaunta-linux-amd64")
+	for _, target := range releaseTargets {
+		os.Remove(fmt.Sprintf("kaunta-%s-%s", target.goos, target.goarch))
+	}
 	return nil
 }
 
@@ -8,6 +8,9 @@
  * - Custom event tracking
  * - Scroll depth tracking
  * - Engagement time tracking
+ * - View confirmation (visible for a few seconds or scrolled)
+ * - Author read tracking (scrolled through 75% of an article)
+ * - Cross-domain linking between linked websites
  * - Respects Do Not Track
  * - No cookies, no localStorage (privacy-first)
  * - <3KB minified
@@ -58,18 +61,46 @@
 
   var dataset = currentScript.dataset;
 
+  // Paths of the script and of the tracking endpoint; a server configured
+  // with its own (tracker_path, collect_path) rewrites these strings when
+  // serving the script
+  var SCRIPT_PATH = '/k.js';
+  var SEND_PATH = '/api/send';
+  var BATCH_PATH = '/api/batch';
+
+  // The API lives where the script is served from: the URL before
+  // SCRIPT_PATH, or else the script's directory
+  function scriptBase(src) {
+    var path = src.split(/[?#]/)[0];
+    if (path.endsWith(SCRIPT_PATH)) {
+      return path.slice(0, path.lastIndexOf(SCRIPT_PATH));
+    }
+    return path.split('/').slice(0, -1).join('/');
+  }
+
   var websiteId = dataset.websiteId;
-  var apiUrl = dataset.apiUrl || currentScript.src.split('/').slice(0, -1).join('/');
+  var apiUrl = dataset.apiUrl || scriptBase(currentScript.src);
   var autoTrack = dataset.autoTrack !== 'false';
   var trackOutbound = dataset.trackOutbound !== 'false';
   var respectDnt = dataset.respectDnt !== 'false';
   var excludeHash = dataset.excludeHash === 'true';
+  // Seconds a page must be visible before its pageview counts as viewed (0: off)
+  var viewThreshold = parseFloat(dataset.viewThreshold);
+  if (isNaN(viewThreshold) || viewThreshold < 0) viewThreshold = 3;
   var domain = dataset.domains || '';
   var domains = domain.split(',').map(function(n) {
     return n.trim().toLowerCase().replace(/:\d+$/, '');
   });
 
-  var endpoint = apiUrl.replace(/\/$/, '') + '/api/send';
+  // Domains of linked websites (see `kaunta website link`): links to them
+  // carry the visitor's session token
+  var linkDomains = (dataset.linkDomains || '').split(',').map(function(n) {
+    return n.trim().toLowerCase().replace(/:\d+$/, '');
+  }).filter(Boolean);
+  var LINK_PARAM = '_kaunta';
+
+  var endpoint = apiUrl.replace(/\/$/, '') + SEND_PATH;
+  var batchEndpoint = apiUrl.replace(/\/$/, '') + BATCH_PATH;
   var screen = width + 'x' + height;
   var { hostname, origin } = location;
 
@@ -78,7 +109,10 @@
     website: websiteId,
     hostname: hostname,
     screen: screen,
-    language: language
+    language: language,
+    // Browser automation (Selenium, Playwright, ...); the server treats it as
+    // a bot. Left out of the JSON when false.
+    webdriver: navigator.webdriver === true || undefined
   });
 
   // ============================================================================
@@ -86,6 +120,7 @@
   // ============================================================================
 
   var debug = dataset.debug === 'true';
+  var traceEnabled = dataset.trace === 'true';
 
   function logDebug() {
     if (!debug || !window.console) return;
@@ -155,6 +190,86 @@
     }
   }
 
+  // Pageviews are sent as pending and confirmed once the page has been
+  // visible for viewThreshold seconds or scrolled, so instant bounces and
+  // background tabs don't count as viewed
+  var viewUrl = null;
+  var viewSentAt = 0;
+  var viewVisibleMs = 0;
+  var viewVisibleSince = 0;
+  var viewScrolled = false;
+  var viewTimer = null;
+
+  function confirmView() {
+    clearTimeout(viewTimer);
+    if (!viewUrl) return;
+    var payload = Object.assign({}, staticPayload, { url: viewUrl });
+    viewUrl = null;
+    send(payload, 'view');
+  }
+
+  function resumeView() {
+    clearTimeout(viewTimer);
+    if (!viewUrl || document.visibilityState !== 'visible') return;
+    viewVisibleSince = Date.now();
+    viewTimer = setTimeout(confirmView, Math.max(0, viewThreshold * 1000 - viewVisibleMs));
+  }
+
+  function pauseView() {
+    clearTimeout(viewTimer);
+    if (viewVisibleSince) {
+      viewVisibleMs += Date.now() - viewVisibleSince;
+      viewVisibleSince = 0;
+    }
+  }
+
+  function onViewScroll() {
+    if (!viewUrl || viewScrolled) return;
+    viewScrolled = true;
+    // Leave the server a moment to write the pageview before confirming it
+    clearTimeout(viewTimer);
+    viewTimer = setTimeout(confirmView, Math.max(0, 1000 - (Date.now() - viewSentAt)));
+  }
+
+  function startView(url) {
+    clearTimeout(viewTimer);
+    viewUrl = url;
+    viewSentAt = Date.now();
+    viewVisibleMs = 0;
+    viewVisibleSince = 0;
+    viewScrolled = false;
+    resumeView();
+  }
+
+  // Pageviews with an author are marked read once the visitor has scrolled
+  // through readDepth percent of the page
+  var readDepth = 75;
+  var readUrl = null;
+  var readSentAt = 0;
+  var readTimer = null;
+
+  function sendRead() {
+    readTimer = null;
+    if (!readUrl) return;
+    var payload = Object.assign({}, staticPayload, { url: readUrl });
+    readUrl = null;
+    send(payload, 'read');
+  }
+
+  function checkRead() {
+    if (!readUrl || readTimer || currentDocHeight <= 0) return;
+    if (maxScrollDepthPx / currentDocHeight * 100 < readDepth) return;
+    // Same grace as view confirmation: the pageview must be written first
+    readTimer = setTimeout(sendRead, Math.max(0, 1000 - (Date.now() - readSentAt)));
+  }
+
+  function startRead(url) {
+    clearTimeout(readTimer);
+    readTimer = null;
+    readUrl = url;
+    readSentAt = Date.now();
+  }
+
   function onVisibilityChange() {
     if (document.visibilityState === 'visible' && document.hasFocus() && engagementStartTime === 0) {
       engagementStartTime = Date.now();
@@ -176,15 +291,24 @@
 
       // rAF-batched scroll tracking to prevent layout thrashing
       document.addEventListener('scroll', function() {
+        onViewScroll();
         if (scrollScheduled) return;
         scrollScheduled = true;
         requestAnimationFrame(function() {
           scrollScheduled = false;
           updateScrollDepth();
+          checkRead();
         });
       }, Object.assign({ passive: true }, signal));
 
       document.addEventListener('visibilitychange', onVisibilityChange, Object.assign({ passive: true }, signal));
+      document.addEventListener('visibilitychange', function() {
+        if (document.visibilityState === 'visible') {
+          resumeView();
+        } else {
+          pauseView();
+        }
+      }, Object.assign({ passive: true }, signal));
       window.addEventListener('blur', onVisibilityChange, Object.assign({ passive: true }, signal));
       window.addEventListener('focus', onVisibilityChange, Object.assign({ passive: true }, signal));
 
@@ -264,6 +388,56 @@
   // NETWORK REQUEST (from Plausible - minimal, modern)
   // ============================================================================
 
+  function randomHex(bytes) {
+    var buf = new Uint8Array(bytes);
+    window.crypto.getRandomValues(buf);
+    var out = '';
+    for (var i = 0; i < buf.length; i++) {
+      out += (buf[i] < 16 ? '0' : '') + buf[i].toString(16);
+    }
+    return out;
+  }
+
+  // W3C traceparent for following one request through the server (data-trace)
+  function newTraceparent() {
+    if (!traceEnabled || !window.crypto || !window.crypto.getRandomValues) return null;
+    return '00-' + randomHex(16) + '-' + randomHex(8) + '-01';
+  }
+
+  // Events of this page instance are numbered so the server can count gaps
+  // (events lost to the network or blockers); see `kaunta diagnostics`
+  var pageId = null;
+  var seq = 0;
+  if (window.crypto && window.crypto.getRandomValues) {
+    var idHex = randomHex(16);
+    pageId = idHex.slice(0, 8) + '-' + idHex.slice(8, 12) + '-4' + idHex.slice(13, 16) + '-' +
+      ((parseInt(idHex.charAt(16), 16) & 3) | 8).toString(16) + idHex.slice(17, 20) + '-' + idHex.slice(20);
+  }
+
+  // Chrome prerenders run the page before it is shown (and maybe never).
+  // Events are held until activation, and the first one sent afterwards is
+  // marked so the server knows the page was shown after a prerender.
+  var held = [];
+  var purpose = null;
+
+  function wasPrerendered() {
+    var nav = window.performance && performance.getEntriesByType &&
+      performance.getEntriesByType('navigation')[0];
+    return !!(nav && nav.activationStart > 0);
+  }
+
+  if (document.prerendering) {
+    purpose = 'activate';
+    document.addEventListener('prerenderingchange', function() {
+      logDebug('Prerendered page activated, sending', held.length, 'held event(s)');
+      held.splice(0).forEach(function(h) {
+        send(h[0], h[1]);
+      });
+    }, { once: true });
+  } else if (wasPrerendered()) {
+    purpose = 'activate';
+  }
+
   function send(payload, type) {
     if (isTrackingDisabled()) {
       logDebug('Tracking disabled: SKIP', type, payload);
@@ -272,24 +446,65 @@
 
     type = type || 'event';
 
+    if (document.prerendering) {
+      held.push([payload, type]);
+      return;
+    }
+
+    if (purpose && type === 'event') {
+      payload.purpose = purpose;
+      purpose = null;
+    }
+
+    if (pageId && type === 'event') {
+      payload.page_id = pageId;
+      payload.seq = ++seq;
+    }
+
     logDebug('Sending', type, payload);
 
-    var body = JSON.stringify({ type: type, payload: payload });
+    var message = { type: type, payload: payload };
+    var headers = { 'Content-Type': 'application/json' };
+    var traceparent = newTraceparent();
+    if (traceparent) {
+      // Header for fetch; body field for sendBeacon, which can't set headers
+      headers.traceparent = traceparent;
+      message.traceparent = traceparent;
+      logDebug('Trace', traceparent.split('-')[1]);
+    }
+
+    var body = JSON.stringify(message);
+
+    // The page may be unloading: queue the event and flush everything sent
+    // in the same task as few batches (see flushOutbox)
+    if (document.visibilityState === 'hidden') {
+      outbox.push(body);
+      if (outbox.length === 1) {
+        if (window.Promise) {
+          Promise.resolve().then(flushOutbox);
+        } else {
+          setTimeout(flushOutbox, 0);
+        }
+      }
+      return;
+    }
 
     // Silent fail - no console spam unless debug
     try {
-      // Use sendBeacon for better reliability when page is hidden/unloading
-      if (navigator.sendBeacon && document.visibilityState === 'hidden') {
-        navigator.sendBeacon(endpoint, body);
-      } else if (window.fetch) {
+      if (window.fetch) {
         fetch(endpoint, {
           method: 'POST',
-          headers: { 'Content-Type': 'application/json' },
+          headers: headers,
           body: body,
           keepalive: true,
           credentials: 'omit'
-        }).catch(function(err) {
+        }).then(function(res) {
+          var wait = retryAfter(res);
+          if (wait) retryLater([{ message: message, attempts: 0 }], wait);
+          if (linkDomains.length && type === 'event') readLinkToken(res);
+        }, function(err) {
           if (debug) logDebug('Fetch error', err);
+          retryLater([{ message: message, attempts: 0 }], 0);
         });
       }
     } catch (e) {
@@ -297,21 +512,273 @@
     }
   }
 
+  // ============================================================================
+  // CROSS-DOMAIN LINKING
+  // ============================================================================
+
+  // The session token of the latest event, added to links to linked
+  // websites; it is only kept in memory
+  var linkToken = null;
+
+  function readLinkToken(res) {
+    if (res.status !== 202) return;
+    res.clone().json().then(function(body) {
+      if (body && body.cache) linkToken = body.cache;
+    }, function() {});
+  }
+
+  function isLinkedDomain(link) {
+    var host = (link.hostname || '').toLowerCase();
+    return linkDomains.some(function(d) {
+      return host === d || host.endsWith('.' + d);
+    });
+  }
+
+  // linkUrl adds the session token to a URL of a linked website
+  function linkUrl(url) {
+    if (!linkToken || !window.URL) return url;
+    try {
+      var u = new URL(url, location.href);
+      if (!isLinkedDomain(u)) return url;
+      u.searchParams.set(LINK_PARAM, linkToken);
+      return u.toString();
+    } catch (e) {
+      return url;
+    }
+  }
+
+  // takeLinkToken returns the token of a visitor coming from a linked
+  // website and takes it off the address before anything records it
+  function takeLinkToken() {
+    if (!window.URL || location.search.indexOf(LINK_PARAM + '=') < 0) return null;
+    try {
+      var u = new URL(location.href);
+      var token = u.searchParams.get(LINK_PARAM);
+      u.searchParams.delete(LINK_PARAM);
+      if (history.replaceState) history.replaceState(history.state, '', u.toString());
+      return token;
+    } catch (e) {
+      return null;
+    }
+  }
+
+  // Events to send again: the server defers events with 202 and
+  // Retry-After when its ingestion buffer is nearly full (429 when the
+  // visitor's network or the website sends too many), and failed
+  // requests (offline, flaky network) get a few more tries. They wait in
+  // memory only and go out as one batch once the delay has passed. Each
+  // delay is stretched by a random factor of up to two, so visitors
+  // deferred together don't all come back at the same moment.
+  var retryQueue = [];
+  var retryTimer = null;
+  var retryDue = 0;
+  var RETRY_MAX_EVENTS = 100;
+  var RETRY_MAX_ATTEMPTS = 5;
+  var RETRY_BASE_SECONDS = 2;
+
+  // retryAfter returns the Retry-After seconds of a deferral, or 0
+  function retryAfter(res) {
+    if (res.status !== 202 && res.status !== 207 && res.status !== 429) return 0;
+    var wait = parseInt(res.headers.get('Retry-After'), 10);
+    return wait > 0 ? wait : 0;
+  }
+
+  // retryLater queues entries ({ message, attempts }) to be sent again
+  // after wait seconds (0: exponential backoff on the attempt count)
+  function retryLater(entries, wait) {
+    entries.forEach(function(entry) {
+      if (++entry.attempts > RETRY_MAX_ATTEMPTS || retryQueue.length >= RETRY_MAX_EVENTS) {
+        logDebug('Giving up on', entry.message.type, entry.message.payload);
+        return;
+      }
+      // Keep the original time: the event may be stored minutes later
+      var payload = entry.message.payload;
+      if (!payload.timestamp) payload.timestamp = Math.floor(Date.now() / 1000);
+      retryQueue.push(entry);
+      if (!wait) wait = RETRY_BASE_SECONDS * Math.pow(2, entry.attempts - 1);
+    });
+    if (!retryQueue.length) return;
+
+    var due = Date.now() + wait * 1000 * (1 + Math.random());
+    if (retryTimer && due <= retryDue) return;
+    clearTimeout(retryTimer);
+    retryDue = due;
+    retryTimer = setTimeout(flushRetries, due - Date.now());
+    logDebug('Retrying', retryQueue.length, 'event(s) in', Math.round((due - Date.now()) / 1000), 's');
+  }
+
+  function flushRetries() {
+    retryTimer = null;
+    var entries = retryQueue.splice(0, BATCH_MAX_EVENTS);
+    if (!entries.length) return;
+
+    // The page is going away: hand the events to the exit batches
+    if (document.visibilityState === 'hidden') {
+      entries.concat(retryQueue.splice(0)).forEach(function(entry) {
+        outbox.push(JSON.stringify(entry.message));
+      });
+      flushOutbox();
+      return;
+    }
+
+    var body = '[' + entries.map(function(entry) {
+      return JSON.stringify(entry.message);
+    }).join(',') + ']';
+    fetch(batchEndpoint, {
+      method: 'POST',
+      headers: { 'Content-Type': 'text/plain' },
+      body: body,
+      keepalive: body.length < BATCH_MAX_BYTES,
+      credentials: 'omit'
+    }).then(function(res) {
+      var wait = retryAfter(res);
+      if (res.status === 207) {
+        // Send again what the server didn't get to (see BatchResponse)
+        return res.json().then(function(result) {
+          retryLater(entries.slice(result.processed), wait);
+        });
+      }
+      if (res.status >= 500) retryLater(entries, wait);
+    }).catch(function(err) {
+      if (debug) logDebug('Retry error', err);
+      retryLater(entries, 0);
+    }).then(function() {
+      if (retryQueue.length && !retryTimer) flushRetries();
+    });
+  }
+
+  // Events sent while the page is hidden. Browsers give sendBeacon and
+  // keepalive fetches 64KB in flight per page, so a page closing with many
+  // events (engagement, reads, views) sends them to /api/batch in chunks that
+  // stay under the budget and under the server's 50 events per batch.
+  var outbox = [];
+  var BATCH_MAX_BYTES = 60000;
+  var BATCH_MAX_EVENTS = 50;
+
+  function flushOutbox() {
+    var chunk = [];
+    var size = 2;
+    outbox.splice(0).forEach(function(body) {
+      if (chunk.length && (size + body.length + 1 > BATCH_MAX_BYTES || chunk.length === BATCH_MAX_EVENTS)) {
+        sendBatch(chunk);
+        chunk = [];
+        size = 2;
+      }
+      chunk.push(body);
+      size += body.length + 1;
+    });
+    if (chunk.length) sendBatch(chunk);
+  }
+
+  function sendBatch(chunk) {
+    var body = '[' + chunk.join(',') + ']';
+    logDebug('Flushing', chunk.length, 'event(s),', body.length, 'bytes');
+    try {
+      // sendBeacon returns false when the browser refuses the payload
+      // (budget used up); a keepalive fetch is the second chance
+      if (navigator.sendBeacon && navigator.sendBeacon(batchEndpoint, body)) return;
+      if (window.fetch) {
+        fetch(batchEndpoint, {
+          method: 'POST',
+          headers: { 'Content-Type': 'text/plain' },
+          body: body,
+          keepalive: true,
+          credentials: 'omit'
+        }).catch(function(err) {
+          if (debug) logDebug('Batch error', err);
+        });
+      }
+    } catch (e) {
+      if (debug) logDebug('Batch exception', e);
+    }
+  }
+
   // ============================================================================
   // TRACKING FUNCTIONS
   // ============================================================================
 
+  // Custom dimensions set with kaunta.setDimensions(); they apply to every
+  // later pageview and win over <meta name="kaunta-dimension-NAME"> tags
+  var dimensionValues = {};
+
+  function setDimensions(values) {
+    if (!values || typeof values !== 'object') return;
+    for (var name in values) {
+      if (!Object.prototype.hasOwnProperty.call(values, name)) continue;
+      if (values[name] === null || values[name] === undefined) {
+        delete dimensionValues[name];
+      } else {
+        dimensionValues[name] = values[name];
+      }
+    }
+  }
+
+  function getDimensions() {
+    var dimensions = {};
+    var found = false;
+    // Read on every pageview: SPAs swap the meta tags on navigation
+    var metas = document.querySelectorAll('meta[name^="kaunta-dimension-"]');
+    for (var i = 0; i < metas.length; i++) {
+      var name = metas[i].getAttribute('name').slice('kaunta-dimension-'.length);
+      var content = metas[i].getAttribute('content');
+      if (name && content) {
+        dimensions[name] = content;
+        found = true;
+      }
+    }
+    for (var key in dimensionValues) {
+      dimensions[key] = dimensionValues[key];
+      found = true;
+    }
+    return found ? dimensions : null;
+  }
+
+  // Author of the current page: a data-author attribute anywhere on the
+  // page (script tag, article element) or <meta name="author">
+  function getAuthor() {
+    var el = document.querySelector('[data-author]');
+    var author = el ? el.getAttribute('data-author') : null;
+    if (!author) {
+      var meta = document.querySelector('meta[name="author"]');
+      author = meta ? meta.getAttribute('content') : null;
+    }
+    return author ? author.trim() : null;
+  }
+
   function trackPageview() {
     // Include engagement metrics for pageviews
     var payload = getBasePayload(true);
 
+    var dimensions = getDimensions();
+    if (dimensions) {
+      payload.dimensions = dimensions;
+    }
+
+    // Read on every pageview: SPAs change the byline on navigation
+    var author = getAuthor();
+    if (author) {
+      payload.author = author;
+    }
+
     // Reset engagement tracking for new page
     maxScrollDepthPx = getCurrentScrollDepthPx();
     totalEngagementTime = 0;
     engagementStartTime = Date.now();
     engagementIgnored = false;
 
+    if (incomingLink) {
+      payload.link = incomingLink;
+      incomingLink = null;
+    }
+
+    if (viewThreshold > 0) {
+      payload.view = 'pending';
+    }
     send(payload, 'event');
+    if (viewThreshold > 0) {
+      startView(payload.url);
+    }
+    startRead(author ? payload.url : null);
   }
 
   function track(eventName, properties) {
@@ -404,6 +871,11 @@
   function onLinkClick(event) {
     var link = getLinkElement(event.target);
 
+    var href = link && link.href;
+    if (link && linkToken && isLinkedDomain(link)) {
+      link.href = linkUrl(href);
+    }
+
     if (trackOutbound && isOutboundLink(link)) {
       var followed = false;
 
@@ -415,7 +887,7 @@
       };
 
       // Track the outbound click
-      track('Outbound Link: Click', { url: normalize(link.href) });
+      track('Outbound Link: Click', { url: normalize(href) });
 
       if (shouldInterceptNav(event, link)) {
         event.preventDefault();
@@ -428,6 +900,7 @@
   // INITIALIZATION
   // ============================================================================
 
+  var incomingLink = takeLinkToken();
   currentPageUrl = normalize(location.href);
   var currentRef = normalize((referrer || '').startsWith(origin) ? '' : referrer);
   var initialized = false;
@@ -444,8 +917,8 @@
     // Track initial pageview
     trackPageview();
 
-    // Setup click handlers for outbound links
-    if (trackOutbound) {
+    // Setup click handlers for outbound links and links to linked websites
+    if (trackOutbound || linkDomains.length) {
       document.addEventListener('click', onLinkClick, true);
     }
   }
@@ -465,8 +938,10 @@
       heightObserver.disconnect();
     }
 
-    // Clear pending pageview
+    // Clear pending pageview and view confirmation
     clearTimeout(pendingPageview);
+    clearTimeout(viewTimer);
+    clearTimeout(readTimer);
 
     // Reset state
     initialized = false;
@@ -479,6 +954,8 @@
     window.kaunta = {
       track: track,
       trackPageview: trackPageview,
+      setDimensions: setDimensions,
+      linkUrl: linkUrl,
       destroy: destroy
     };
   }
@@ -487,11 +964,20 @@
   // AUTO-START
   // ============================================================================
 
+  function start() {
+    // Engagement time starts when a prerendered page is actually shown
+    if (document.prerendering) {
+      document.addEventListener('prerenderingchange', init, { once: true });
+    } else {
+      init();
+    }
+  }
+
   if (autoTrack && !isTrackingDisabled()) {
     if (document.readyState === 'complete') {
-      init();
+      start();
     } else {
-      window.addEventListener('load', init);
+      window.addEventListener('load', start);
     }
   }
 
@@ -147,3 +147,297 @@ test('tracker extracts website ID from data attribute', async ({ page }) => {
 
   expect(websiteIdFromRequest).toBe('special-website-id-123');
 });
+
+/**
+ * Test that events of a page instance carry increasing sequence numbers
+ */
+test('tracker numbers events per page instance', async ({ page }) => {
+  const html = createTestHtmlPage('defer', {
+    'website-id': 'test-123'
+  });
+
+  const sent: { page_id?: string; seq?: number }[] = [];
+  page.on('request', (request) => {
+    if (request.url().includes('/api/send')) {
+      const body = request.postData();
+      if (body) {
+        sent.push(JSON.parse(body).payload || {});
+      }
+    }
+  });
+
+  await page.setContent(html);
+  await page.waitForTimeout(500);
+
+  await page.evaluate(() => {
+    window.kaunta?.track('First');
+    window.kaunta?.track('Second');
+  });
+
+  await page.waitForTimeout(500);
+
+  expect(sent.length).toBeGreaterThanOrEqual(2);
+  const pageIds = new Set(sent.map((p) => p.page_id));
+  expect(pageIds.size).toBe(1);
+  expect(sent.map((p) => p.seq)).toEqual(sent.map((_, i) => i + 1));
+});
+
+/**
+ * Test that a prerendered page sends nothing until it is activated
+ */
+test('tracker holds events while prerendering', async ({ page }) => {
+  await page.addInitScript(() => {
+    Object.defineProperty(document, 'prerendering', { configurable: true, get: () => true });
+  });
+
+  const html = createTestHtmlPage('defer', {
+    'website-id': 'test-123'
+  });
+
+  const sent: { purpose?: string }[] = [];
+  page.on('request', (request) => {
+    if (request.url().includes('/api/send')) {
+      const body = request.postData();
+      if (body) {
+        sent.push(JSON.parse(body).payload || {});
+      }
+    }
+  });
+
+  await page.setContent(html);
+  await page.waitForTimeout(500);
+  expect(sent.length).toBe(0);
+
+  await page.evaluate(() => {
+    Object.defineProperty(document, 'prerendering', { configurable: true, get: () => false });
+    document.dispatchEvent(new Event('prerenderingchange'));
+  });
+  await page.waitForTimeout(500);
+
+  expect(sent.length).toBeGreaterThanOrEqual(1);
+  expect(sent[0].purpose).toBe('activate');
+  expect(sent.slice(1).every((p) => p.purpose === undefined)).toBe(true);
+});
+
+/**
+ * Test that pageviews are sent as pending and confirmed once seen
+ */
+test('tracker confirms pageviews after the view threshold', async ({ page }) => {
+  const html = createTestHtmlPage('defer', {
+    'website-id': 'test-123',
+    'view-threshold': '1'
+  });
+
+  const sent: { type: string; payload: { view?: string; url?: string } }[] = [];
+  page.on('request', (request) => {
+    if (request.url().includes('/api/send')) {
+      const body = request.postData();
+      if (body) {
+        sent.push(JSON.parse(body));
+      }
+    }
+  });
+
+  await page.setContent(html);
+  await page.waitForTimeout(500);
+
+  expect(sent.length).toBe(1);
+  expect(sent[0].type).toBe('event');
+  expect(sent[0].payload.view).toBe('pending');
+
+  await page.waitForTimeout(1000);
+
+  expect(sent.length).toBe(2);
+  expect(sent[1].type).toBe('view');
+  expect(sent[1].payload.url).toBe(sent[0].payload.url);
+});
+
+/**
+ * Test that custom dimensions from meta tags and setDimensions() are sent
+ */
+test('tracker sends custom dimensions with pageviews', async ({ page }) => {
+  const html = createTestHtmlPage('defer', {
+    'website-id': 'test-123'
+  }).replace(
+    '<title>Test Page</title>',
+    '<title>Test Page</title><meta name="kaunta-dimension-content_type" content="tutorial">'
+  );
+
+  const sent: { name?: string; dimensions?: Record<string, string> }[] = [];
+  page.on('request', (request) => {
+    if (request.url().includes('/api/send')) {
+      const body = request.postData();
+      if (body) {
+        sent.push(JSON.parse(body).payload || {});
+      }
+    }
+  });
+
+  await page.setContent(html);
+  await page.waitForTimeout(500);
+
+  expect(sent.length).toBeGreaterThanOrEqual(1);
+  expect(sent[0].dimensions).toEqual({ content_type: 'tutorial' });
+
+  await page.evaluate(() => {
+    window.kaunta?.setDimensions?.({ content_type: 'guide', plan: 'pro' });
+    window.kaunta?.trackPageview?.();
+    window.kaunta?.track('Signup');
+  });
+  await page.waitForTimeout(500);
+
+  const pageviews = sent.filter((p) => p.name === undefined);
+  expect(pageviews[pageviews.length - 1].dimensions).toEqual({ content_type: 'guide', plan: 'pro' });
+  expect(sent.find((p) => p.name === 'Signup')?.dimensions).toBeUndefined();
+});
+
+/**
+ * Test that the author is sent and the pageview marked read after scrolling
+ */
+test('tracker marks authored pageviews read after scrolling', async ({ page }) => {
+  const html = createTestHtmlPage('defer', {
+    'website-id': 'test-123',
+    'author': 'Jane Doe'
+  }).replace('</body>', '<div style="height: 5000px"></div></body>');
+
+  const sent: { type: string; payload: { author?: string; url?: string } }[] = [];
+  page.on('request', (request) => {
+    if (request.url().includes('/api/send')) {
+      const body = request.postData();
+      if (body) {
+        sent.push(JSON.parse(body));
+      }
+    }
+  });
+
+  await page.setContent(html);
+  await page.waitForTimeout(500);
+
+  expect(sent.length).toBe(1);
+  expect(sent[0].payload.author).toBe('Jane Doe');
+
+  await page.evaluate(() => window.scrollTo(0, document.body.scrollHeight));
+  await page.waitForTimeout(1500);
+
+  const reads = sent.filter((m) => m.type === 'read');
+  expect(reads.length).toBe(1);
+  expect(reads[0].payload.url).toBe(sent[0].payload.url);
+});
+
+/**
+ * Test that automated browsers report navigator.webdriver
+ */
+test('tracker reports webdriver automation', async ({ page }) => {
+  const html = createTestHtmlPage('defer', {
+    'website-id': 'test-123'
+  });
+
+  const sent: { webdriver?: boolean }[] = [];
+  page.on('request', (request) => {
+    if (request.url().includes('/api/send')) {
+      const body = request.postData();
+      if (body) {
+        sent.push(JSON.parse(body).payload || {});
+      }
+    }
+  });
+
+  await page.setContent(html);
+  await page.waitForTimeout(500);
+
+  // Playwright sets navigator.webdriver
+  expect(sent.length).toBeGreaterThanOrEqual(1);
+  expect(sent[0].webdriver).toBe(true);
+});
+
+/**
+ * Test that events deferred with Retry-After are sent again as a batch,
+ * keeping the time they happened
+ */
+test('tracker retries deferred events after Retry-After', async ({ page }) => {
+  await page.route('**/api/send', (route) =>
+    route.fulfill({
+      status: 202,
+      headers: { 'Retry-After': '1', 'Access-Control-Expose-Headers': 'Retry-After', 'Access-Control-Allow-Origin': '*' },
+      contentType: 'application/json',
+      body: '{"deferred":"overloaded"}'
+    })
+  );
+  const batches: { payload: { name?: string; timestamp?: number } }[][] = [];
+  await page.route('**/api/batch', (route) => {
+    batches.push(JSON.parse(route.request().postData() || '[]'));
+    route.fulfill({ status: 202, contentType: 'application/json', body: '{"accepted":1,"rejected":[],"processed":1}' });
+  });
+
+  const html = createTestHtmlPage('defer', {
+    'website-id': 'test-123'
+  });
+  await page.setContent(html);
+  await page.waitForTimeout(500);
+  await page.evaluate(() => window.kaunta?.track('Deferred'));
+
+  // Retry-After 1 is stretched by jitter to at most 2 seconds
+  await page.waitForTimeout(2500);
+
+  const retried = batches.flat().filter((m) => m.payload.name === 'Deferred');
+  expect(retried.length).toBe(1);
+  expect(retried[0].payload.timestamp).toBeGreaterThan(0);
+});
+
+/**
+ * Test that rate-limited events are sent again once the limit allows
+ */
+test('tracker retries rate-limited events after Retry-After', async ({ page }) => {
+  await page.route('**/api/send', (route) =>
+    route.fulfill({
+      status: 429,
+      headers: { 'Retry-After': '1', 'Access-Control-Expose-Headers': 'Retry-After', 'Access-Control-Allow-Origin': '*' },
+      contentType: 'application/json',
+      body: '{"error":"Too many requests, retry in 1 seconds","limit":"ip"}'
+    })
+  );
+  const batches: { payload: { name?: string } }[][] = [];
+  await page.route('**/api/batch', (route) => {
+    batches.push(JSON.parse(route.request().postData() || '[]'));
+    route.fulfill({ status: 202, contentType: 'application/json', body: '{"accepted":1,"rejected":[],"processed":1}' });
+  });
+
+  const html = createTestHtmlPage('defer', {
+    'website-id': 'test-123'
+  });
+  await page.setContent(html);
+  await page.waitForTimeout(500);
+  await page.evaluate(() => window.kaunta?.track('Limited'));
+  await page.waitForTimeout(2500);
+
+  expect(batches.flat().filter((m) => m.payload.name === 'Limited').length).toBe(1);
+});
+
+/**
+ * Test that links to linked websites carry the session token of the latest
+ * event, and other links don't
+ */
+test('tracker adds the session token to links to linked websites', async ({ page }) => {
+  await page.route('**/api/send', (route) =>
+    route.fulfill({
+      status: 202,
+      headers: { 'Access-Control-Allow-Origin': '*' },
+      contentType: 'application/json',
+      body: '{"cache":"token.signature"}'
+    })
+  );
+
+  const html = createTestHtmlPage('defer', {
+    'website-id': 'test-123',
+    'link-domains': 'app.example.io'
+  });
+  await page.setContent(html);
+  await page.waitForTimeout(500);
+
+  const urls = await page.evaluate(() => [
+    window.kaunta?.linkUrl?.('https://app.example.io/signup?plan=pro'),
+    window.kaunta?.linkUrl?.('https://other.example.org/')
+  ]);
+  expect(urls[0]).toBe('https://app.example.io/signup?plan=pro&_kaunta=token.signature');
+  expect(urls[1]).toBe('https://other.example.org/');
+});